@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// client calls the GoPay HTTP API with a tenant token
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL, token string) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// apiError is a response of the API that was not successful
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// do sends body as JSON and decodes the data of the response into out. Responses that are not
+// successful are returned as *apiError.
+func (c *client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		response.Response
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode %s %s response (%d): %w", method, path, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest || !envelope.Success {
+		message := envelope.Message
+		if envelope.Error != "" {
+			message += ": " + envelope.Error
+		}
+		return &apiError{StatusCode: resp.StatusCode, Message: message}
+	}
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode %s %s data: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// login exchanges a username and password for a token and uses it for later calls
+func (c *client) login(ctx context.Context, username, password string) error {
+	if username == "" || password == "" {
+		return errors.New("username and password are required to log in")
+	}
+	var login auth.LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/auth/login", map[string]string{"username": username, "password": password}, &login); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	c.token = login.Token
+	return nil
+}
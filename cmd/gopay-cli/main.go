@@ -0,0 +1,344 @@
+// Command gopay-cli operates a GoPay server over its HTTP API: it creates tenants, sets
+// provider configurations, fires sandbox test payments, queries payment status and tails
+// provider logs. It exits non-zero on failure, so it can run as a CI smoke test.
+//
+// The server and credentials are taken from flags or the GOPAY_URL, GOPAY_TOKEN,
+// GOPAY_USERNAME and GOPAY_PASSWORD environment variables.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const usage = `Usage: gopay-cli [global flags] <command> [flags]
+
+Commands:
+  create-tenant  Create a tenant (admin token required)
+  set-config     Set the provider configuration of the tenant: set-config -provider iyzico apiKey=... secretKey=...
+  test-payment   Create a sandbox test payment and fail unless it succeeds
+  status         Get the status of a payment
+  logs           List or follow the provider logs of the tenant
+
+Global flags:
+`
+
+// errUsage reports wrong arguments; the usage was already printed
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "gopay-cli:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// commands run a subcommand with its arguments after the global flags
+var commands = map[string]func(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error{
+	"create-tenant": createTenant,
+	"set-config":    setConfig,
+	"test-payment":  testPayment,
+	"status":        paymentStatus,
+	"logs":          tailLogs,
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	global := flag.NewFlagSet("gopay-cli", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	baseURL := global.String("url", envOr("GOPAY_URL", "http://localhost:9999"), "GoPay server URL")
+	token := global.String("token", os.Getenv("GOPAY_TOKEN"), "JWT of the tenant, instead of logging in")
+	username := global.String("username", os.Getenv("GOPAY_USERNAME"), "username to log in with")
+	password := global.String("password", os.Getenv("GOPAY_PASSWORD"), "password to log in with")
+	global.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		return errUsage
+	}
+
+	name := global.Arg(0)
+	command, ok := commands[name]
+	if !ok {
+		if name != "" {
+			fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		}
+		global.Usage()
+		return errUsage
+	}
+
+	c := newClient(*baseURL, *token)
+	if c.token == "" {
+		if err := c.login(ctx, *username, *password); err != nil {
+			return err
+		}
+	}
+	return command(ctx, c, global.Args()[1:], stdout, stderr)
+}
+
+// newFlagSet returns the flags of a command, which report wrong arguments as errUsage
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("gopay-cli "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string, required ...string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	for _, name := range required {
+		if flags.Lookup(name).Value.String() == "" {
+			fmt.Fprintf(flags.Output(), "-%s is required\n", name)
+			flags.Usage()
+			return errUsage
+		}
+	}
+	return nil
+}
+
+func createTenant(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("create-tenant", stderr)
+	username := flags.String("tenant-username", "", "username of the new tenant")
+	password := flags.String("tenant-password", "", "password of the new tenant")
+	if err := parseFlags(flags, args, "tenant-username", "tenant-password"); err != nil {
+		return err
+	}
+
+	var tenant map[string]any
+	if err := c.do(ctx, http.MethodPost, "/v1/auth/create-tenant", map[string]string{"username": *username, "password": *password}, &tenant); err != nil {
+		return err
+	}
+	return printJSON(stdout, tenant)
+}
+
+func setConfig(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("set-config", stderr)
+	providerName := flags.String("provider", "", "provider to configure")
+	environment := flags.String("environment", "sandbox", "sandbox or production")
+	if err := parseFlags(flags, args, "provider"); err != nil {
+		return err
+	}
+
+	type configEntry struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	var configs []configEntry
+	for _, arg := range flags.Args() {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			fmt.Fprintf(stderr, "expected key=value, got %q\n", arg)
+			return errUsage
+		}
+		configs = append(configs, configEntry{Key: key, Value: value})
+	}
+	if len(configs) == 0 {
+		fmt.Fprintln(stderr, "at least one key=value is required")
+		return errUsage
+	}
+
+	body := map[string]any{"provider": *providerName, "environment": *environment, "configs": configs}
+	if err := c.do(ctx, http.MethodPost, "/v1/config/tenant", body, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Configured %s (%s) with %d keys\n", *providerName, *environment, len(configs))
+	return nil
+}
+
+func testPayment(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("test-payment", stderr)
+	providerName := flags.String("provider", "", "provider to pay with")
+	amount := flags.Float64("amount", 100, "amount of the payment")
+	currency := flags.String("currency", "TRY", "currency of the payment")
+	cardNumber := flags.String("card", "5528790000000008", "test card number of the provider's sandbox")
+	expiry := flags.String("expiry", "12/2030", "card expiry as MM/YYYY")
+	cvv := flags.String("cvv", "123", "card CVV")
+	use3D := flags.Bool("3d", false, "request a 3D Secure payment")
+	callbackURL := flags.String("callback-url", "https://example.com/callback", "callback URL of 3D payments")
+	if err := parseFlags(flags, args, "provider"); err != nil {
+		return err
+	}
+	month, year, ok := strings.Cut(*expiry, "/")
+	if !ok {
+		fmt.Fprintf(stderr, "expected -expiry as MM/YYYY, got %q\n", *expiry)
+		return errUsage
+	}
+
+	request := provider.PaymentRequest{
+		Amount:   *amount,
+		Currency: *currency,
+		Customer: provider.Customer{
+			ID:        "gopay-cli",
+			Name:      "John",
+			Surname:   "Doe",
+			Email:     "john.doe@example.com",
+			IPAddress: "127.0.0.1",
+			Address:   &provider.Address{City: "Istanbul", Country: "Turkey", Address: "GoPay CLI test address", ZipCode: "34000"},
+		},
+		CardInfo: provider.CardInfo{
+			CardHolderName: "John Doe",
+			CardNumber:     *cardNumber,
+			ExpireMonth:    month,
+			ExpireYear:     year,
+			CVV:            *cvv,
+		},
+		Items:       []provider.Item{{ID: "cli-item", Name: "GoPay CLI test", Category: "Test", Price: *amount, Quantity: 1}},
+		Description: "gopay-cli test payment",
+		Use3D:       *use3D,
+		CallbackURL: *callbackURL,
+		ClientIP:    "127.0.0.1",
+	}
+
+	// Test payments never reach a production account
+	path := "/v1/payments/" + url.PathEscape(*providerName) + "?environment=sandbox"
+	var payment provider.PaymentResponse
+	if err := c.do(ctx, http.MethodPost, path, request, &payment); err != nil {
+		return err
+	}
+	if err := printJSON(stdout, payment); err != nil {
+		return err
+	}
+	if !payment.Success {
+		return fmt.Errorf("payment %s failed with status %s: %s", payment.PaymentID, payment.Status, payment.Message)
+	}
+	return nil
+}
+
+func paymentStatus(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("status", stderr)
+	providerName := flags.String("provider", "", "provider of the payment")
+	paymentID := flags.String("payment-id", "", "payment ID")
+	environment := flags.String("environment", "sandbox", "sandbox or production")
+	if err := parseFlags(flags, args, "provider", "payment-id"); err != nil {
+		return err
+	}
+
+	path := "/v1/payments/" + url.PathEscape(*providerName) + "/" + url.PathEscape(*paymentID) + "?environment=" + url.QueryEscape(*environment)
+	var payment provider.PaymentResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &payment); err != nil {
+		return err
+	}
+	return printJSON(stdout, payment)
+}
+
+// logsResponse is the data of GET /v1/logs/{provider}
+type logsResponse struct {
+	Logs []postgres.PaymentLog `json:"logs"`
+}
+
+func tailLogs(ctx context.Context, c *client, args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("logs", stderr)
+	providerName := flags.String("provider", "", "provider of the logs")
+	paymentID := flags.String("payment-id", "", "only the logs of a payment")
+	errorsOnly := flags.Bool("errors", false, "only failed requests")
+	hours := flags.Int("hours", 1, "logs of the last hours (1-168)")
+	follow := flags.Bool("follow", false, "keep printing new logs until interrupted")
+	interval := flags.Duration("interval", 5*time.Second, "poll interval with -follow")
+	if err := parseFlags(flags, args, "provider"); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(stderr, "-interval must be positive")
+		return errUsage
+	}
+
+	query := url.Values{"hours": {strconv.Itoa(*hours)}}
+	if *paymentID != "" {
+		query.Set("paymentId", *paymentID)
+	}
+	if *errorsOnly {
+		query.Set("errorsOnly", "true")
+	}
+	path := "/v1/logs/" + url.PathEscape(*providerName) + "?" + query.Encode()
+
+	var lastID int64
+	for {
+		var page logsResponse
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return err
+		}
+		// Logs are listed newest first and printed oldest first, once each
+		slices.SortFunc(page.Logs, func(a, b postgres.PaymentLog) int { return compareInt64(a.ID, b.ID) })
+		for _, log := range page.Logs {
+			if log.ID <= lastID {
+				continue
+			}
+			lastID = log.ID
+			fmt.Fprintln(stdout, formatLog(log))
+		}
+
+		if !*follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// formatLog prints a log on one line: time, method, endpoint, payment, status and error
+func formatLog(log postgres.PaymentLog) string {
+	fields := []string{log.Timestamp.Format(time.RFC3339), log.Method, log.Endpoint}
+	if info := log.PaymentInfo; info != nil {
+		if info.PaymentID != "" {
+			fields = append(fields, "payment="+info.PaymentID)
+		}
+		if info.Amount != 0 {
+			fields = append(fields, fmt.Sprintf("amount=%.2f %s", info.Amount, info.Currency))
+		}
+		if info.Status != "" {
+			fields = append(fields, "status="+info.Status)
+		}
+	}
+	fields = append(fields, fmt.Sprintf("%dms", log.ProcessingMs))
+	if log.Error != nil {
+		fields = append(fields, fmt.Sprintf("error=%s %s", log.Error.Code, log.Error.Message))
+	}
+	return strings.Join(fields, " ")
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func printJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// testServer answers like GoPay and records the requests it received
+type testServer struct {
+	*httptest.Server
+	requests []string
+	bodies   []map[string]any
+	payment  provider.PaymentResponse
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{payment: provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful, PaymentID: "pay_1"}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.bodies = append(s.bodies, body)
+
+		if r.URL.Path != "/v1/auth/login" && r.Header.Get("Authorization") != "Bearer tok" {
+			_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Message: "Invalid or missing authentication"})
+			return
+		}
+		switch {
+		case r.URL.Path == "/v1/auth/login":
+			response.Success(w, http.StatusOK, "Login successful", map[string]string{"token": "tok"})
+		case r.URL.Path == "/v1/config/tenant":
+			response.Success(w, http.StatusOK, "Configuration saved", nil)
+		case strings.HasPrefix(r.URL.Path, "/v1/payments/"):
+			response.Success(w, http.StatusOK, "Payment processed", s.payment)
+		case strings.HasPrefix(r.URL.Path, "/v1/logs/"):
+			now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			response.Success(w, http.StatusOK, "Logs retrieved successfully", map[string]any{"logs": []postgres.PaymentLog{
+				{ID: 2, Timestamp: now, Method: "POST", Endpoint: "/payment", Error: &postgres.ErrorInfo{Code: "DECLINED", Message: "Card declined"}},
+				{ID: 1, Timestamp: now, Method: "POST", Endpoint: "/payment", PaymentInfo: &postgres.PaymentInfo{PaymentID: "pay_1", Amount: 100, Currency: "TRY"}},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRun_SetConfig(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-url", server.URL, "-username", "admin", "-password", "secret", "set-config", "-provider", "iyzico", "apiKey=key", "secretKey=a=b"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("set-config failed: %v (%s)", err, stderr.String())
+	}
+	if len(server.requests) != 2 || server.requests[0] != "POST /v1/auth/login" || server.requests[1] != "POST /v1/config/tenant" {
+		t.Fatalf("Expected a login and the config request, got %v", server.requests)
+	}
+	configs := server.bodies[1]["configs"].([]any)
+	if server.bodies[1]["environment"] != "sandbox" || len(configs) != 2 || configs[1].(map[string]any)["value"] != "a=b" {
+		t.Errorf("Unexpected config request %v", server.bodies[1])
+	}
+}
+
+func TestRun_TestPayment(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	args := []string{"-url", server.URL, "-token", "tok", "test-payment", "-provider", "iyzico", "-amount", "25"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("test-payment failed: %v (%s)", err, stderr.String())
+	}
+	if server.requests[0] != "POST /v1/payments/iyzico?environment=sandbox" {
+		t.Errorf("Expected a sandbox payment, got %v", server.requests)
+	}
+	if card := server.bodies[0]["cardInfo"].(map[string]any); card["expireMonth"] != "12" || card["expireYear"] != "2030" {
+		t.Errorf("Unexpected card %v", card)
+	}
+	if !strings.Contains(stdout.String(), `"paymentId": "pay_1"`) {
+		t.Errorf("Expected the payment to be printed, got %s", stdout.String())
+	}
+
+	// A payment that did not succeed fails the smoke test
+	server.payment = provider.PaymentResponse{Success: false, Status: provider.StatusFailed, PaymentID: "pay_2", Message: "Card declined"}
+	if err := run(context.Background(), args, &stdout, &stderr); err == nil || !strings.Contains(err.Error(), "Card declined") {
+		t.Errorf("Expected the failed payment to be an error, got %v", err)
+	}
+}
+
+func TestRun_Logs(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	if err := run(context.Background(), []string{"-url", server.URL, "-token", "tok", "logs", "-provider", "iyzico", "-errors"}, &stdout, &stderr); err != nil {
+		t.Fatalf("logs failed: %v (%s)", err, stderr.String())
+	}
+	if server.requests[0] != "GET /v1/logs/iyzico?errorsOnly=true&hours=1" {
+		t.Errorf("Unexpected logs request %v", server.requests)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "payment=pay_1 amount=100.00 TRY") || !strings.Contains(lines[1], "error=DECLINED Card declined") {
+		t.Errorf("Expected the logs oldest first, got %q", lines)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	server := newTestServer(t)
+	var stdout, stderr bytes.Buffer
+
+	if err := run(context.Background(), []string{"-url", server.URL, "-token", "tok", "refund"}, &stdout, &stderr); !errors.Is(err, errUsage) {
+		t.Errorf("Expected errUsage for an unknown command, got %v", err)
+	}
+	if err := run(context.Background(), []string{"-url", server.URL, "-token", "tok", "status", "-provider", "iyzico"}, &stdout, &stderr); !errors.Is(err, errUsage) {
+		t.Errorf("Expected errUsage without -payment-id, got %v", err)
+	}
+
+	var apiErr *apiError
+	err := run(context.Background(), []string{"-url", server.URL, "-token", "expired", "status", "-provider", "iyzico", "-payment-id", "pay_1"}, &stdout, &stderr)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the API's 401, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,6 +19,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"github.com/mstgnz/gopay/handler"
+	"github.com/mstgnz/gopay/infra/audit"
 	"github.com/mstgnz/gopay/infra/auth"
 	"github.com/mstgnz/gopay/infra/config"
 	"github.com/mstgnz/gopay/infra/logger"
@@ -26,15 +28,23 @@ import (
 	"github.com/mstgnz/gopay/infra/response"
 	"github.com/mstgnz/gopay/infra/validate"
 	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/plugin"
+	"github.com/mstgnz/gopay/router/grpcapi"
 	v1 "github.com/mstgnz/gopay/router/v1"
 )
 
 var (
-	PORT           string
-	postgresLogger *postgres.Logger
-	jwtService     *auth.JWTService
-	tenantService  *auth.TenantService
-	paymentHandler *handler.PaymentHandler
+	PORT                string
+	postgresLogger      *postgres.Logger
+	jwtService          *auth.JWTService
+	tenantService       *auth.TenantService
+	apiKeyService       *auth.APIKeyService
+	oidcService         *auth.OIDCService
+	roleService         *auth.RoleService
+	userService         *auth.UserService
+	signingKeys         *auth.SigningKeyRing
+	refreshTokenService *auth.RefreshTokenService
+	paymentHandler      *handler.PaymentHandler
 )
 
 func init() {
@@ -66,8 +76,48 @@ func init() {
 	// Initialize JWT service
 	jwtService = auth.NewJWTService()
 
+	// With JWT_SIGNING_KEY_STORE=postgres tokens are signed with rotating keys kept encrypted in
+	// PostgreSQL instead of JWT_SECRET, shared by every instance and kept across deploys
+	if config.GetEnv("JWT_SIGNING_KEY_STORE", "") == "postgres" {
+		signingKeySecret, err := auth.SigningKeySecretFromEnv()
+		if err != nil {
+			logger.Fatal("Refusing to store JWT signing keys", err)
+		}
+		signingKeys = auth.NewSigningKeyRing(auth.NewPostgresSigningKeyStore(config.App().DB.DB, signingKeySecret), jwtService.Expiry())
+		if err := signingKeys.Load(context.Background()); err != nil {
+			logger.Fatal("Failed to load JWT signing keys", err)
+		}
+		jwtService.SetSigningKeys(signingKeys)
+	}
+
+	// Track login sessions so they can be listed, revoked and limited per tenant
+	jwtService.SetSessionService(auth.NewSessionService(auth.NewPostgresSessionStore(config.App().DB.DB)))
+
+	// Opaque refresh tokens, rotated on use, returned with each login
+	refreshTokenService = auth.NewRefreshTokenService(auth.NewPostgresRefreshTokenStore(config.App().DB.DB))
+	jwtService.SetRefreshTokens(refreshTokenService)
+
+	// Per-tenant API keys, accepted with X-API-Key as an alternative to JWTs
+	apiKeyService = auth.NewAPIKeyService(auth.NewPostgresAPIKeyStore(config.App().DB.DB))
+
+	// Roles of tenant users (owner, developer, finance, read-only), checked on /v1 routes
+	roleService = auth.NewRoleService(auth.NewPostgresRoleStore(config.App().DB.DB))
+
+	// Users of a tenant with their own credentials and role, logged out when removed
+	userService = auth.NewUserService(auth.NewPostgresUserStore(config.App().DB.DB), roleService)
+	userService.SetSessionService(jwtService.Sessions())
+
 	// Initialize tenant service
 	tenantService = auth.NewTenantService(config.App().DB, jwtService)
+	tenantService.SetUserService(userService)
+
+	// Dashboard login at the corporate IdP, when OIDC_ISSUER_URL is set
+	if oidcConfig := auth.OIDCConfigFromEnv(); oidcConfig.Enabled() {
+		var err error
+		if oidcService, err = auth.NewOIDCService(context.Background(), oidcConfig, []byte(config.App().SecretKey)); err != nil {
+			logger.Warn(fmt.Sprintf("OIDC login is disabled: %v", err))
+		}
+	}
 
 	// Initialize global system logger
 	logger.InitGlobalLogger(postgresLogger)
@@ -80,6 +130,57 @@ func main() {
 	paymentService := provider.NewPaymentService(paymentLogger)
 	providerConfig := config.NewProviderConfig()
 
+	// Auto-capture: payments authorized with autoCaptureAfter are captured by a background job
+	autoCaptureScheduler := provider.NewAutoCaptureScheduler(provider.NewPostgresAutoCaptureStore(config.App().DB.DB), paymentService.CaptureScheduledPayment)
+	paymentService.SetAutoCaptureScheduler(autoCaptureScheduler)
+
+	// Subscriptions: saved cards charged on a schedule by a background job
+	subscriptionCards := provider.NewCardService(paymentLogger, provider.NewSavedCardRepository(config.App().DB.DB), providerConfig)
+	subscriptionService := provider.NewSubscriptionService(provider.NewPostgresSubscriptionStore(config.App().DB.DB), subscriptionCards)
+
+	// Outbound webhooks: retried with exponential backoff, dead-lettered when attempts run out
+	webhookDispatcher := provider.NewWebhookDispatcher(provider.NewPostgresWebhookDeliveryStore(config.App().DB.DB))
+
+	// Async payments: POST /v1/payments/{provider}?async=true is answered with a job processed by workers
+	paymentJobQueue := provider.NewPaymentJobQueue(provider.NewPostgresPaymentJobStore(config.App().DB.DB), paymentService.CreatePayment, webhookDispatcher)
+
+	// Payment links: shareable checkout pages paid through the tenant's provider
+	paymentLinkService := provider.NewPaymentLinkService(provider.NewPostgresPaymentLinkStore(config.App().DB.DB), paymentService, provider.NewWebhookPaymentLinkNotifier(webhookDispatcher))
+
+	// Refunds sent with an idempotency key are deduplicated per tenant and payment
+	paymentService.SetRefundIdempotencyStore(provider.NewPostgresRefundIdempotencyStore(config.App().DB.DB))
+
+	// Payments sent with an idempotency key are deduplicated per tenant until the key expires
+	paymentIdempotencyStore := provider.NewPostgresPaymentIdempotencyStore(config.App().DB.DB, provider.PaymentIdempotencyTTL())
+	paymentService.SetPaymentIdempotencyStore(paymentIdempotencyStore)
+
+	// Papara and PayTR payments are answered with a GoPay payment ID, linked to the provider's own
+	paymentService.SetPaymentReferenceStore(provider.NewPostgresPaymentReferenceStore(config.App().DB.DB))
+
+	// 3D callback states live in PostgreSQL unless CALLBACK_STATE_STORE=memory
+	callbackStateStore, err := provider.NewCallbackStateStore(config.App().DB.DB)
+	if err != nil {
+		log.Fatalf("Failed to initialize callback state store: %v", err)
+	}
+	provider.SetCallbackStateStore(callbackStateStore)
+
+	// Append-only lifecycle events of each payment, listed by GET /v1/payments/{provider}/{paymentID}/events
+	paymentService.SetPaymentEventStore(provider.NewPostgresPaymentEventStore(config.App().DB.DB))
+
+	// Status requests with debug=true report the payment's provider round-trips from the logs
+	paymentService.SetPaymentCallLogStore(provider.NewPostgresPaymentCallLogStore(config.App().DB.DB))
+
+	// Pre-provider risk rules (RISK_* env); payments they stop are returned with status "blocked"
+	if riskEvaluator := provider.NewRuleRiskEvaluator(); riskEvaluator.Enabled() {
+		paymentService.AddRiskEvaluator(riskEvaluator)
+	}
+
+	// Proprietary providers served by plugin processes (PROVIDER_PLUGINS)
+	providerPlugins, err := plugin.RegisterFromEnv(provider.DefaultRegistry)
+	if err != nil {
+		log.Fatalf("Failed to register provider plugins: %v", err)
+	}
+
 	// Initialize payment handler
 	validatorInstance := validator.New()
 	paymentHandler = handler.NewPaymentHandler(paymentService, validatorInstance)
@@ -90,12 +191,22 @@ func main() {
 	// Basic Middleware
 	r.Use(middle.PanicRecoveryMiddleware())
 	r.Use(middleware.Logger)
+	// Record the connection's peer before RealIP replaces it with the proxy headers' claim
+	r.Use(middle.PeerAddrMiddleware())
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Timeout(60 * time.Second))
 
 	// Security Middleware
 	tenantRateLimiter := middle.NewTenantRateLimiter()
+	// Limits the admin set per tenant, kept in PostgreSQL
+	if err := tenantRateLimiter.SetLimitStore(context.Background(), middle.NewPostgresTenantLimitStore(config.App().DB.DB)); err != nil {
+		logger.Warn("Failed to load tenant rate limits, using the defaults", logger.LogContext{
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+	}
 	r.Use(middle.SecurityHeadersMiddleware())
 	r.Use(middle.IPWhitelistMiddleware())
 	r.Use(middle.TenantRateLimitMiddleware(tenantRateLimiter))
@@ -111,7 +222,7 @@ func main() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Timestamp", "Hash", "Origin", "X-Requested-With"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "X-API-Key", "Content-Type", "Timestamp", "Hash", "Origin", "X-Requested-With"},
 		ExposedHeaders:   []string{"Link", "Content-Length", "Access-Control-Allow-Origin"},
 		AllowCredentials: true,
 		MaxAge:           300, // Preflight cache time (second)
@@ -124,7 +235,7 @@ func main() {
 	healthHandler := handler.NewHealthHandler(config.App().DB.DB, postgresLogger, paymentService, providerConfig)
 
 	// Initialize tenant rate limit handler
-	rateLimitHandler := handler.NewTenantRateLimitHandler(tenantRateLimiter)
+	rateLimitHandler := handler.NewTenantRateLimitHandler(tenantRateLimiter, validator.New())
 
 	// Health check endpoint (no auth required)
 	r.Get("/health", healthHandler.CheckHealth)
@@ -146,6 +257,9 @@ func main() {
 		_, _ = w.Write(scalarContent)
 	})
 
+	// OpenAPI document generated from the registered routes and handlers
+	r.Get("/openapi.json", v1.OpenAPIHandler(r))
+
 	// Login page route
 	r.Get("/login", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, filepath.Join(workDir, "public", "login.html"))
@@ -173,6 +287,25 @@ func main() {
 	r.Route("/v1/webhooks", func(r chi.Router) {
 		// Provider-specific webhook routes
 		r.Post("/{provider}", paymentHandler.HandleWebhook)
+
+		// Outbound webhook deliveries of the tenant (require JWT)
+		r.Group(func(r chi.Router) {
+			r.Use(middle.APIKeyAuthMiddleware(apiKeyService))
+			r.Use(middle.JWTAuthMiddleware(jwtService))
+			r.Use(middle.AdminClientCertMiddleware())
+			r.Use(middle.RoleMiddleware(roleService))
+			r.Use(middle.AuditMiddleware(audit.NewPostgresStore(config.App().DB.DB)))
+			webhookDeliveryHandler := handler.NewWebhookDeliveryHandler(webhookDispatcher)
+			r.With(middle.RequirePermission(auth.PermissionPaymentsWrite)).Post("/deliveries/{deliveryID}/replay", webhookDeliveryHandler.ReplayDelivery) // POST /v1/webhooks/deliveries/15/replay
+		})
+	})
+
+	// Hosted checkout pages of payment links (no auth required, the link ID is the credential)
+	r.Route("/pay", func(r chi.Router) {
+		paymentLinkHandler := handler.NewPaymentLinkHandler(paymentLinkService, validatorInstance)
+		r.Get("/{linkID}", paymentLinkHandler.ShowCheckout)
+		r.Post("/{linkID}", paymentLinkHandler.SubmitCheckout)
+		r.HandleFunc("/{linkID}/complete", paymentLinkHandler.CompleteCheckout)
 	})
 
 	// Public v1 auth routes (no authentication required)
@@ -186,26 +319,90 @@ func main() {
 		r.Post("/refresh", authHandler.RefreshToken)
 		r.Get("/validate", authHandler.ValidateToken)
 
+		// Dashboard single sign-on; machine traffic keeps using JWTs and API keys
+		oidcHandler := handler.NewOIDCHandler(oidcService, tenantService, roleService, jwtService)
+		r.Get("/oidc", oidcHandler.Status)
+		r.Get("/oidc/login", oidcHandler.Login)
+		r.Get("/oidc/callback", oidcHandler.Callback)
+
 		// Protected auth endpoints (require JWT)
 		r.Group(func(r chi.Router) {
 			r.Use(middle.JWTAuthMiddleware(jwtService))
+			r.Use(middle.AdminClientCertMiddleware())
+			r.Use(middle.RoleMiddleware(roleService))
+			r.Use(middle.AuditMiddleware(audit.NewPostgresStore(config.App().DB.DB)))
 			r.Post("/create-tenant", authHandler.CreateTenant) // Admin-only tenant creation
 			r.Post("/logout", authHandler.Logout)
 			r.Post("/change-password", authHandler.ChangePassword)
 			r.Get("/profile", authHandler.GetProfile)
+			r.Get("/sessions", authHandler.ListSessions)
+			r.Delete("/sessions/{sessionID}", authHandler.RevokeSession)
+			r.Post("/revoke", authHandler.Revoke)
+
+			// Owners manage API keys, which act as owners, and the roles of the tenant's users
+			r.Group(func(r chi.Router) {
+				r.Use(middle.RequirePermission(auth.PermissionManageUsers))
+
+				// API keys for server-to-server integrations, managed with a JWT only
+				apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, validatorInstance)
+				r.Post("/api-keys", apiKeyHandler.CreateAPIKey)
+				r.Get("/api-keys", apiKeyHandler.ListAPIKeys)
+				r.Post("/api-keys/{keyID}/rotate", apiKeyHandler.RotateAPIKey)
+				r.Delete("/api-keys/{keyID}", apiKeyHandler.RevokeAPIKey)
+
+				roleHandler := handler.NewRoleHandler(roleService, validatorInstance)
+				r.Get("/roles", roleHandler.ListRoles)
+				r.Put("/roles/{username}", roleHandler.AssignRole)
+				r.Delete("/roles/{username}", roleHandler.RemoveRole)
+			})
 		})
 	})
 
+	// Users of a tenant, managed by its owners or the admin with a JWT only
+	r.Route("/v1/tenants/{tenantID}/users", func(r chi.Router) {
+		r.Use(middle.JWTAuthMiddleware(jwtService))
+		r.Use(middle.AdminClientCertMiddleware())
+		r.Use(middle.RoleMiddleware(roleService))
+		r.Use(middle.RequirePermission(auth.PermissionManageUsers))
+		r.Use(middle.AuditMiddleware(audit.NewPostgresStore(config.App().DB.DB)))
+
+		userHandler := handler.NewUserHandler(userService, validator.New())
+		r.Get("/", userHandler.ListUsers)               // GET /v1/tenants/7/users
+		r.Post("/", userHandler.CreateUser)             // POST /v1/tenants/7/users
+		r.Get("/{username}", userHandler.GetUser)       // GET /v1/tenants/7/users/bob
+		r.Put("/{username}", userHandler.UpdateUser)    // PUT /v1/tenants/7/users/bob
+		r.Delete("/{username}", userHandler.DeleteUser) // DELETE /v1/tenants/7/users/bob
+	})
+
 	// Protected v1 routes with authentication
 	r.Route("/v1", func(r chi.Router) {
-		// Add JWT authentication middleware only to protected routes
+		// Add JWT authentication middleware only to protected routes; an X-API-Key is accepted instead
+		r.Use(middle.APIKeyAuthMiddleware(apiKeyService))
 		r.Use(middle.JWTAuthMiddleware(jwtService))
 
+		// Admin requests need a pinned client certificate when ADMIN_CLIENT_CERT_FINGERPRINTS is set
+		r.Use(middle.AdminClientCertMiddleware())
+
+		// Resolve the role of the tenant user for the permission checks of the routes
+		r.Use(middle.RoleMiddleware(roleService))
+
+		// Record who initiated each mutating operation (payments, refunds, cancels, config changes)
+		r.Use(middle.AuditMiddleware(audit.NewPostgresStore(config.App().DB.DB)))
+
 		// Import v1 routes with required services (auth routes are handled above)
-		v1.Routes(r, postgresLogger, paymentService, providerConfig)
+		v1.Routes(r, postgresLogger, paymentService, providerConfig, subscriptionService, paymentLinkService, paymentJobQueue)
 
 		// Add tenant rate limiting stats endpoint
 		r.Get("/rate-limit/stats", rateLimitHandler.GetTenantStats)
+
+		// Per-tenant rate limits, set by the admin with a JWT only
+		r.Route("/admin/tenants/{tenantID}/rate-limits", func(r chi.Router) {
+			r.Use(middle.JWTOnlyMiddleware())
+			r.Use(middle.RequirePermission(auth.PermissionManageUsers))
+			r.Get("/", rateLimitHandler.GetTenantLimits)      // GET /v1/admin/tenants/7/rate-limits
+			r.Put("/", rateLimitHandler.SetTenantLimits)      // PUT /v1/admin/tenants/7/rate-limits
+			r.Delete("/", rateLimitHandler.ResetTenantLimits) // DELETE /v1/admin/tenants/7/rate-limits
+		})
 	})
 
 	// Not Found
@@ -213,7 +410,7 @@ func main() {
 		_ = response.WriteJSON(w, http.StatusUnauthorized, response.Response{Success: false, Message: "Not Found"})
 	})
 
-	// Start background task for cleaning expired callback states
+	// Start background task for cleaning expired callback states, idempotency keys and refresh tokens
 	go func() {
 		ticker := time.NewTicker(15 * time.Minute) // Cleanup every 15 minutes
 		defer ticker.Stop()
@@ -227,6 +424,20 @@ func main() {
 					},
 				})
 			}
+			if err := paymentIdempotencyStore.Cleanup(cleanupCtx); err != nil {
+				logger.Warn("Failed to cleanup expired payment idempotency keys", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+			if err := refreshTokenService.Cleanup(cleanupCtx); err != nil {
+				logger.Warn("Failed to cleanup expired refresh tokens", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
 			cancel()
 		}
 	}()
@@ -235,6 +446,26 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGKILL)
 	defer stop()
 
+	// Capture authorized payments whose auto-capture delay has elapsed
+	go autoCaptureScheduler.Start(ctx, time.Minute)
+
+	// Rotate the JWT signing key when it is due and pick up keys rotated by other instances
+	if signingKeys != nil {
+		go signingKeys.Start(ctx, time.Minute)
+	}
+
+	// Charge subscriptions that are due
+	go subscriptionService.Start(ctx, time.Minute)
+
+	// Expire payment links past their expiry and notify their webhooks
+	go paymentLinkService.Start(ctx, time.Minute)
+
+	// Retry outbound webhook deliveries that are due
+	go webhookDispatcher.Start(ctx, 30*time.Second)
+
+	// Process async payments and fail the jobs lost with a previous run
+	go paymentJobQueue.Start(ctx, 10*time.Minute)
+
 	// Run your HTTP server in a goroutine
 	go func() {
 		server := &http.Server{
@@ -245,12 +476,45 @@ func main() {
 			IdleTimeout:       60 * time.Second,
 			ReadHeaderTimeout: 60 * time.Second,
 		}
-		err := server.ListenAndServe()
+		// With TLS_CERT_FILE the server terminates TLS itself and can see client certificates
+		tlsConfig, err := middle.ServerTLSConfigFromEnv()
+		if err != nil {
+			logger.Fatal("Invalid TLS configuration", err)
+		}
+		if tlsConfig != nil {
+			server.TLSConfig = tlsConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server failed to start", err)
 		}
 	}()
 
+	// Serve the payment operations over gRPC for internal services, when GRPC_PORT is set
+	if grpcPort := config.GetEnv("GRPC_PORT", ""); grpcPort != "" {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
+		if err != nil {
+			logger.Fatal("gRPC server failed to listen", err)
+		}
+		grpcServer := grpcapi.NewServer(paymentService, jwtService, roleService)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Fatal("gRPC server failed to start", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			grpcServer.GracefulStop()
+		}()
+		logger.Info("gRPC API is running", logger.LogContext{
+			Fields: map[string]any{
+				"port": grpcPort,
+			},
+		})
+	}
+
 	logger.Info("API is running", logger.LogContext{
 		Fields: map[string]any{
 			"port": PORT,
@@ -265,6 +529,17 @@ func main() {
 			"port": PORT,
 		},
 	})
+
+	for _, providerPlugin := range providerPlugins {
+		if err := providerPlugin.Close(); err != nil {
+			logger.Warn("Failed to stop provider plugin", logger.LogContext{
+				Provider: providerPlugin.Name(),
+				Fields: map[string]any{
+					"error": err.Error(),
+				},
+			})
+		}
+	}
 }
 
 func fileServer(r chi.Router, path string, root http.FileSystem) {
@@ -172,6 +172,15 @@
 //	    "IYZICO_ENVIRONMENT": "production", // or "sandbox"
 //	}
 //
+// # Embedded Library
+//
+// GoPay can run inside another Go service, without the HTTP server and JWT authentication.
+// New wires the payment service over a database opened by the caller; tenant provider
+// configurations come from Options.TenantConfig, or from tenant_configs without it:
+//
+//	payments, err := gopay.New(gopay.Options{DB: db, TenantConfig: credentials})
+//	response, err := payments.CreatePayment(gopay.WithTenant(ctx, tenantID), "sandbox", "iyzico", request)
+//
 // # HTTP API
 //
 // GoPay provides a comprehensive REST API with JWT authentication:
@@ -233,6 +242,9 @@
 //	UNAUTHENTICATED_RATE_LIMIT=10     # Unauthenticated requests per minute
 //	PREMIUM_TENANTS=tenant1,tenant2   # Premium tenant list
 //
+// The admin overrides the defaults of a tenant with PUT /v1/admin/tenants/{id}/rate-limits;
+// those limits are stored in PostgreSQL.
+//
 // # Callbacks and Webhooks
 //
 // GoPay handles provider callbacks and webhooks automatically with multi-tenant support:
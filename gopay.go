@@ -0,0 +1,98 @@
+package gopay
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/tenantctx"
+	"github.com/mstgnz/gopay/provider"
+
+	// Import for side-effect registration
+	_ "github.com/mstgnz/gopay/provider/akbank"
+	_ "github.com/mstgnz/gopay/provider/craftgate"
+	_ "github.com/mstgnz/gopay/provider/garanti"
+	_ "github.com/mstgnz/gopay/provider/halkbank"
+	_ "github.com/mstgnz/gopay/provider/isbank"
+	_ "github.com/mstgnz/gopay/provider/iyzico"
+	_ "github.com/mstgnz/gopay/provider/klarna"
+	_ "github.com/mstgnz/gopay/provider/kuveytturk"
+	_ "github.com/mstgnz/gopay/provider/mercadopago"
+	_ "github.com/mstgnz/gopay/provider/nkolay"
+	_ "github.com/mstgnz/gopay/provider/ozanpay"
+	_ "github.com/mstgnz/gopay/provider/papara"
+	_ "github.com/mstgnz/gopay/provider/param"
+	_ "github.com/mstgnz/gopay/provider/paratika"
+	_ "github.com/mstgnz/gopay/provider/paycell"
+	_ "github.com/mstgnz/gopay/provider/payten"
+	_ "github.com/mstgnz/gopay/provider/paytr"
+	_ "github.com/mstgnz/gopay/provider/payu"
+	_ "github.com/mstgnz/gopay/provider/qnb"
+	_ "github.com/mstgnz/gopay/provider/razorpay"
+	_ "github.com/mstgnz/gopay/provider/sipay"
+	_ "github.com/mstgnz/gopay/provider/stripe"
+	_ "github.com/mstgnz/gopay/provider/tosla"
+	_ "github.com/mstgnz/gopay/provider/ziraat"
+)
+
+// Options configures GoPay embedded in another Go service
+type Options struct {
+	// DB is a PostgreSQL database with the GoPay schema (gopay.sql), opened by the caller.
+	// Payment logs, 3D callback states, idempotency keys and payment events are kept there,
+	// as by the server.
+	DB *sql.DB
+
+	// TenantConfig supplies the provider configuration of each tenant, keyed like
+	// tenant_configs (apiKey, secretKey, ...). Without it configurations are read from the
+	// tenant_configs table of DB.
+	TenantConfig provider.TenantConfigSource
+}
+
+// New returns a PaymentService wired like the server's, without its HTTP router and JWT
+// authentication. Payments are made for the tenant of their context, see WithTenant:
+//
+//	db, _ := sql.Open("postgres", dsn)
+//	payments, err := gopay.New(gopay.Options{
+//	    DB: db,
+//	    TenantConfig: provider.TenantConfigFunc(func(tenantID int, providerName, environment string) (map[string]string, error) {
+//	        return vault.ProviderCredentials(tenantID, providerName, environment)
+//	    }),
+//	})
+//	response, err := payments.CreatePayment(gopay.WithTenant(ctx, 1), "sandbox", "iyzico", request)
+//
+// Providers, their configuration source and the callback state store are shared by the
+// process, so a process embeds a single GoPay. Background jobs of the server, like
+// auto-capture and subscriptions, are not started.
+func New(opts Options) (*provider.PaymentService, error) {
+	if opts.DB == nil {
+		return nil, errors.New("gopay: a database is required")
+	}
+	config.UseDatabase(opts.DB)
+	provider.SetTenantConfigSource(opts.TenantConfig)
+
+	callbackStateStore, err := provider.NewCallbackStateStore(opts.DB)
+	if err != nil {
+		return nil, fmt.Errorf("gopay: %w", err)
+	}
+	provider.SetCallbackStateStore(callbackStateStore)
+
+	paymentService := provider.NewPaymentService(provider.NewDBPaymentLogger(config.App().DB))
+	paymentService.SetRefundIdempotencyStore(provider.NewPostgresRefundIdempotencyStore(opts.DB))
+	paymentService.SetPaymentIdempotencyStore(provider.NewPostgresPaymentIdempotencyStore(opts.DB, provider.PaymentIdempotencyTTL()))
+	paymentService.SetPaymentReferenceStore(provider.NewPostgresPaymentReferenceStore(opts.DB))
+	paymentService.SetPaymentEventStore(provider.NewPostgresPaymentEventStore(opts.DB))
+	paymentService.SetPaymentCallLogStore(provider.NewPostgresPaymentCallLogStore(opts.DB))
+	if riskEvaluator := provider.NewRuleRiskEvaluator(); riskEvaluator.Enabled() {
+		paymentService.AddRiskEvaluator(riskEvaluator)
+	}
+	return paymentService, nil
+}
+
+// WithTenant returns a context whose payment operations are made for tenantID, as the JWT of
+// a request to the server does
+func WithTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantctx.IDKey, strconv.Itoa(tenantID))
+}
@@ -406,6 +406,8 @@ CREATE TABLE "public"."saved_cards" (
     "is_active" boolean NOT NULL DEFAULT true,
     "created_at" timestamp DEFAULT now(),
     "updated_at" timestamp,
+    "last_failure_reason" varchar(50),
+    "last_failure_at" timestamp,
     PRIMARY KEY ("id")
 );
 
@@ -415,3 +417,972 @@ CREATE INDEX saved_cards_lookup ON public.saved_cards USING btree (tenant_id, pr
 CREATE INDEX saved_cards_tenant_id ON public.saved_cards USING btree (tenant_id);
 ALTER TABLE "public"."saved_cards" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
 ALTER TABLE "public"."saved_cards" ADD FOREIGN KEY ("provider_id") REFERENCES "public"."providers"("id");
+
+-- Merchant metadata search (request->'metadata' @> '{"campaign":"summer2024"}')
+CREATE INDEX iyzico_request_metadata ON public.iyzico USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX stripe_request_metadata ON public.stripe USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX shopier_request_metadata ON public.shopier USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX nkolay_request_metadata ON public.nkolay USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX ozanpay_request_metadata ON public.ozanpay USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX papara_request_metadata ON public.papara USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX paycell_request_metadata ON public.paycell USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX paytr_request_metadata ON public.paytr USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX payu_request_metadata ON public.payu USING gin ((request -> 'metadata') jsonb_path_ops);
+
+-- Subscription charges (request->>'subscriptionId' = 'sub_123')
+CREATE INDEX iyzico_request_subscription ON public.iyzico USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX stripe_request_subscription ON public.stripe USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX shopier_request_subscription ON public.shopier USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX nkolay_request_subscription ON public.nkolay USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX ozanpay_request_subscription ON public.ozanpay USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX papara_request_subscription ON public.papara USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX paycell_request_subscription ON public.paycell USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX paytr_request_subscription ON public.paytr USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+CREATE INDEX payu_request_subscription ON public.payu USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS auto_captures_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."auto_captures" (
+    "id" int8 NOT NULL DEFAULT nextval('auto_captures_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "payment_id" varchar(100) NOT NULL,
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "capture_at" timestamp NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" int4 NOT NULL DEFAULT 0,
+    "last_error" text,
+    "created_at" timestamp DEFAULT now(),
+    "updated_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX auto_captures_due ON public.auto_captures USING btree (capture_at) WHERE status = 'pending';
+CREATE INDEX auto_captures_payment ON public.auto_captures USING btree (tenant_id, provider, payment_id);
+ALTER TABLE "public"."auto_captures" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."auth_sessions" (
+    "id" varchar(36) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "username" varchar(255),
+    "client_ip" varchar(64),
+    "user_agent" text,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "refreshed_at" timestamp,
+    "expires_at" timestamp NOT NULL,
+    "revoked_at" timestamp,
+    "revoke_reason" varchar(20),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX auth_sessions_active ON public.auth_sessions USING btree (tenant_id, created_at) WHERE revoked_at IS NULL;
+CREATE INDEX auth_sessions_user ON public.auth_sessions USING btree (tenant_id, username) WHERE revoked_at IS NULL;
+ALTER TABLE "public"."auth_sessions" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."tenant_rate_limits" (
+    "tenant_id" int4 NOT NULL,
+    "global_rate" int4 NOT NULL,
+    "payment_rate" int4 NOT NULL,
+    "refund_rate" int4 NOT NULL,
+    "status_rate" int4 NOT NULL,
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("tenant_id")
+);
+
+-- Column Comment
+COMMENT ON COLUMN "public"."tenant_rate_limits"."global_rate" IS 'requests per minute, replaces TENANT_GLOBAL_RATE_LIMIT';
+
+-- Indices
+ALTER TABLE "public"."tenant_rate_limits" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."refresh_tokens" (
+    "id" varchar(36) NOT NULL,
+    "session_id" varchar(36) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "username" varchar(255),
+    "token_hash" varchar(64) NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "expires_at" timestamp NOT NULL,
+    "used_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Column Comment
+COMMENT ON COLUMN "public"."refresh_tokens"."token_hash" IS 'SHA-256 of the token, the token itself is never stored';
+
+-- Indices
+CREATE UNIQUE INDEX refresh_tokens_token_hash ON public.refresh_tokens USING btree (token_hash);
+CREATE INDEX refresh_tokens_expires_at ON public.refresh_tokens USING btree (expires_at);
+ALTER TABLE "public"."refresh_tokens" ADD FOREIGN KEY ("session_id") REFERENCES "public"."auth_sessions"("id");
+ALTER TABLE "public"."refresh_tokens" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."jwt_signing_keys" (
+    "id" varchar(36) NOT NULL,
+    "secret" text NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "retired_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Column Comment
+COMMENT ON COLUMN "public"."jwt_signing_keys"."secret" IS 'AES-GCM encrypted with ENCRYPT_SECRET';
+
+-- Table Definition
+CREATE TABLE "public"."api_keys" (
+    "id" varchar(36) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "name" varchar(100) NOT NULL DEFAULT '',
+    "prefix" varchar(16) NOT NULL,
+    "key_hash" varchar(64) NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "last_used_at" timestamp,
+    "revoked_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE UNIQUE INDEX api_keys_hash ON public.api_keys USING btree (key_hash);
+CREATE INDEX api_keys_active ON public.api_keys USING btree (tenant_id, created_at) WHERE revoked_at IS NULL;
+ALTER TABLE "public"."api_keys" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."tenant_user_roles" (
+    "tenant_id" int4 NOT NULL,
+    "username" varchar(255) NOT NULL,
+    "role" varchar(20) NOT NULL CHECK (role IN ('owner', 'developer', 'finance', 'read-only')),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("tenant_id", "username")
+);
+
+-- Indices
+ALTER TABLE "public"."tenant_user_roles" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS tenant_users_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."tenant_users" (
+    "id" int4 NOT NULL DEFAULT nextval('tenant_users_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "username" varchar(50) NOT NULL,
+    "password" varchar NOT NULL,
+    "last_login" timestamp,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE UNIQUE INDEX tenant_users_username ON public.tenant_users USING btree (username);
+CREATE INDEX tenant_users_tenant ON public.tenant_users USING btree (tenant_id);
+ALTER TABLE "public"."tenant_users" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."refund_idempotency_keys" (
+    "tenant_id" int4 NOT NULL,
+    "payment_id" varchar(100) NOT NULL,
+    "idempotency_key" varchar(255) NOT NULL,
+    "refund_amount" numeric(15,2),
+    "response" jsonb,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "completed_at" timestamp,
+    PRIMARY KEY ("tenant_id", "payment_id", "idempotency_key")
+);
+
+-- Indices
+ALTER TABLE "public"."refund_idempotency_keys" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."payment_idempotency_keys" (
+    "tenant_id" int4 NOT NULL,
+    "idempotency_key" varchar(255) NOT NULL,
+    "fingerprint" varchar(64) NOT NULL,
+    "response" jsonb,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "completed_at" timestamp,
+    "expires_at" timestamp NOT NULL,
+    PRIMARY KEY ("tenant_id", "idempotency_key")
+);
+
+-- Indices
+CREATE INDEX payment_idempotency_keys_expires_at ON public.payment_idempotency_keys USING btree (expires_at);
+ALTER TABLE "public"."payment_idempotency_keys" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."payment_references" (
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "payment_id" varchar(100) NOT NULL,
+    "provider_payment_id" varchar(100) NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("tenant_id", "provider", "payment_id")
+);
+
+-- Indices
+CREATE INDEX payment_references_provider_payment_id ON public.payment_references USING btree (tenant_id, provider, provider_payment_id);
+ALTER TABLE "public"."payment_references" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS payment_events_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."payment_events" (
+    "id" int8 NOT NULL DEFAULT nextval('payment_events_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "payment_id" varchar(100) NOT NULL,
+    "event_type" varchar(30) NOT NULL,
+    "success" bool NOT NULL DEFAULT false,
+    "status" varchar(50),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "reference_id" varchar(100),
+    "error_code" varchar(100),
+    "message" text,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX payment_events_payment ON public.payment_events USING btree (tenant_id, provider, payment_id, created_at);
+ALTER TABLE "public"."payment_events" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."payment_jobs" (
+    "id" varchar(50) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "status" varchar(20) NOT NULL CHECK ((status)::text = ANY ((ARRAY['queued'::character varying, 'processing'::character varying, 'completed'::character varying, 'failed'::character varying])::text[])),
+    "result" jsonb,
+    "error" text,
+    "webhook_url" text,
+    "webhook_secret" varchar(100),
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "started_at" timestamp,
+    "completed_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX payment_jobs_tenant ON public.payment_jobs USING btree (tenant_id, created_at);
+CREATE INDEX payment_jobs_unfinished ON public.payment_jobs USING btree (created_at) WHERE status IN ('queued', 'processing');
+ALTER TABLE "public"."payment_jobs" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."config_templates" (
+    "name" varchar(100) NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "config" jsonb NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("name")
+);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS audit_events_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."audit_events" (
+    "id" int8 NOT NULL DEFAULT nextval('audit_events_id_seq'::regclass),
+    "action" varchar(50) NOT NULL,
+    "actor_tenant_id" varchar(50) NOT NULL,
+    "actor" jsonb NOT NULL,
+    "tenant_id" varchar(50) NOT NULL,
+    "provider" varchar(50) NOT NULL DEFAULT '',
+    "resource_id" varchar(255) NOT NULL DEFAULT '',
+    "method" varchar(10) NOT NULL,
+    "path" text NOT NULL,
+    "status_code" int4 NOT NULL,
+    "request_id" varchar(100) NOT NULL DEFAULT '',
+    "success" bool NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX audit_events_tenant_created ON public.audit_events USING btree (tenant_id, created_at);
+CREATE INDEX audit_events_action_created ON public.audit_events USING btree (action, created_at);
+
+-- Table Definition
+CREATE TABLE "public"."subscriptions" (
+    "id" varchar(50) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "card_id" int4 NOT NULL,
+    "amount" numeric(15,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "interval" varchar(10) NOT NULL,
+    "interval_count" int4 NOT NULL DEFAULT 1,
+    "status" varchar(20) NOT NULL DEFAULT 'active',
+    "next_charge_at" timestamp NOT NULL,
+    "charge_count" int4 NOT NULL DEFAULT 0,
+    "attempts" int4 NOT NULL DEFAULT 0,
+    "last_payment_id" varchar(100),
+    "last_error" text,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp,
+    "cancelled_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX subscriptions_due ON public.subscriptions USING btree (next_charge_at) WHERE status IN ('active', 'past_due');
+CREATE INDEX subscriptions_tenant_id ON public.subscriptions USING btree (tenant_id);
+ALTER TABLE "public"."subscriptions" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+ALTER TABLE "public"."subscriptions" ADD FOREIGN KEY ("card_id") REFERENCES "public"."saved_cards"("id");
+
+-- Table Definition
+CREATE TABLE "public"."payouts" (
+    "id" varchar(50) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "amount" numeric(15,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "recipient" jsonb NOT NULL,
+    "description" varchar(255),
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "provider_payout_id" varchar(100),
+    "fee" numeric(15,2) NOT NULL DEFAULT 0,
+    "error_code" varchar(100),
+    "last_error" text,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX payouts_tenant_id ON public.payouts USING btree (tenant_id, created_at);
+ALTER TABLE "public"."payouts" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Table Definition
+CREATE TABLE "public"."payment_links" (
+    "id" varchar(50) NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "environment" varchar NOT NULL CHECK ((environment)::text = ANY ((ARRAY['sandbox'::character varying, 'production'::character varying])::text[])),
+    "amount" numeric(15,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "description" varchar(255),
+    "status" varchar(20) NOT NULL DEFAULT 'active',
+    "payment_id" varchar(100),
+    "webhook_url" text,
+    "webhook_secret" varchar(100),
+    "last_error" text,
+    "expires_at" timestamp NOT NULL,
+    "claimed_until" timestamp,
+    "paid_at" timestamp,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX payment_links_tenant_id ON public.payment_links USING btree (tenant_id, created_at);
+CREATE INDEX payment_links_expiry ON public.payment_links USING btree (expires_at) WHERE status = 'active';
+ALTER TABLE "public"."payment_links" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS webhook_deliveries_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."webhook_deliveries" (
+    "id" int8 NOT NULL DEFAULT nextval('webhook_deliveries_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "event" varchar(50) NOT NULL,
+    "url" text NOT NULL,
+    "body" bytea NOT NULL,
+    "signature" varchar(128) NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" int4 NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamp NOT NULL,
+    "last_status_code" int4,
+    "last_error" text,
+    "delivered_at" timestamp,
+    "created_at" timestamp NOT NULL DEFAULT now(),
+    "updated_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX webhook_deliveries_due ON public.webhook_deliveries USING btree (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_tenant_id ON public.webhook_deliveries USING btree (tenant_id, created_at);
+ALTER TABLE "public"."webhook_deliveries" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS webhook_dead_letters_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."webhook_dead_letters" (
+    "id" int8 NOT NULL DEFAULT nextval('webhook_dead_letters_id_seq'::regclass),
+    "delivery_id" int8 NOT NULL,
+    "tenant_id" int4 NOT NULL,
+    "event" varchar(50) NOT NULL,
+    "url" text NOT NULL,
+    "attempts" int4 NOT NULL,
+    "last_status_code" int4,
+    "last_error" text,
+    "dead_at" timestamp NOT NULL DEFAULT now(),
+    "replayed_at" timestamp,
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX webhook_dead_letters_delivery_id ON public.webhook_dead_letters USING btree (delivery_id);
+CREATE INDEX webhook_dead_letters_tenant_id ON public.webhook_dead_letters USING btree (tenant_id, dead_at);
+ALTER TABLE "public"."webhook_dead_letters" ADD FOREIGN KEY ("delivery_id") REFERENCES "public"."webhook_deliveries"("id");
+ALTER TABLE "public"."webhook_dead_letters" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS garanti_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."garanti" (
+    "id" int4 NOT NULL DEFAULT nextval('garanti_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX garanti_tenant_id ON public.garanti USING btree (tenant_id);
+CREATE INDEX garanti_request_metadata ON public.garanti USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX garanti_request_subscription ON public.garanti USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."garanti" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('garanti', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS isbank_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."isbank" (
+    "id" int4 NOT NULL DEFAULT nextval('isbank_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX isbank_tenant_id ON public.isbank USING btree (tenant_id);
+CREATE INDEX isbank_request_metadata ON public.isbank USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX isbank_request_subscription ON public.isbank USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."isbank" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('isbank', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS akbank_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."akbank" (
+    "id" int4 NOT NULL DEFAULT nextval('akbank_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX akbank_tenant_id ON public.akbank USING btree (tenant_id);
+CREATE INDEX akbank_request_metadata ON public.akbank USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX akbank_request_subscription ON public.akbank USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."akbank" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('akbank', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS halkbank_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."halkbank" (
+    "id" int4 NOT NULL DEFAULT nextval('halkbank_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX halkbank_tenant_id ON public.halkbank USING btree (tenant_id);
+CREATE INDEX halkbank_request_metadata ON public.halkbank USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX halkbank_request_subscription ON public.halkbank USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."halkbank" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('halkbank', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS kuveytturk_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."kuveytturk" (
+    "id" int4 NOT NULL DEFAULT nextval('kuveytturk_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX kuveytturk_tenant_id ON public.kuveytturk USING btree (tenant_id);
+CREATE INDEX kuveytturk_request_metadata ON public.kuveytturk USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX kuveytturk_request_subscription ON public.kuveytturk USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."kuveytturk" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('kuveytturk', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS qnb_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."qnb" (
+    "id" int4 NOT NULL DEFAULT nextval('qnb_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX qnb_tenant_id ON public.qnb USING btree (tenant_id);
+CREATE INDEX qnb_request_metadata ON public.qnb USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX qnb_request_subscription ON public.qnb USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."qnb" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('qnb', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS param_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."param" (
+    "id" int4 NOT NULL DEFAULT nextval('param_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX param_tenant_id ON public.param USING btree (tenant_id);
+CREATE INDEX param_request_metadata ON public.param USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX param_request_subscription ON public.param USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."param" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('param', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS sipay_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."sipay" (
+    "id" int4 NOT NULL DEFAULT nextval('sipay_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX sipay_tenant_id ON public.sipay USING btree (tenant_id);
+CREATE INDEX sipay_request_metadata ON public.sipay USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX sipay_request_subscription ON public.sipay USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."sipay" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('sipay', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS craftgate_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."craftgate" (
+    "id" int4 NOT NULL DEFAULT nextval('craftgate_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX craftgate_tenant_id ON public.craftgate USING btree (tenant_id);
+CREATE INDEX craftgate_request_metadata ON public.craftgate USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX craftgate_request_subscription ON public.craftgate USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."craftgate" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('craftgate', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS klarna_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."klarna" (
+    "id" int4 NOT NULL DEFAULT nextval('klarna_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX klarna_tenant_id ON public.klarna USING btree (tenant_id);
+CREATE INDEX klarna_request_metadata ON public.klarna USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX klarna_request_subscription ON public.klarna USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."klarna" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('klarna', true);
+
+CREATE SEQUENCE IF NOT EXISTS razorpay_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."razorpay" (
+    "id" int4 NOT NULL DEFAULT nextval('razorpay_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX razorpay_tenant_id ON public.razorpay USING btree (tenant_id);
+CREATE INDEX razorpay_request_metadata ON public.razorpay USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX razorpay_request_subscription ON public.razorpay USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."razorpay" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('razorpay', true);
+
+CREATE SEQUENCE IF NOT EXISTS mercadopago_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."mercadopago" (
+    "id" int4 NOT NULL DEFAULT nextval('mercadopago_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX mercadopago_tenant_id ON public.mercadopago USING btree (tenant_id);
+CREATE INDEX mercadopago_request_metadata ON public.mercadopago USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX mercadopago_request_subscription ON public.mercadopago USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."mercadopago" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('mercadopago', true);
+
+CREATE SEQUENCE IF NOT EXISTS paratika_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."paratika" (
+    "id" int4 NOT NULL DEFAULT nextval('paratika_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX paratika_tenant_id ON public.paratika USING btree (tenant_id);
+CREATE INDEX paratika_request_metadata ON public.paratika USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX paratika_request_subscription ON public.paratika USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."paratika" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('paratika', true);
+
+CREATE SEQUENCE IF NOT EXISTS tosla_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."tosla" (
+    "id" int4 NOT NULL DEFAULT nextval('tosla_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX tosla_tenant_id ON public.tosla USING btree (tenant_id);
+CREATE INDEX tosla_request_metadata ON public.tosla USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX tosla_request_subscription ON public.tosla USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."tosla" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('tosla', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS payten_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."payten" (
+    "id" int4 NOT NULL DEFAULT nextval('payten_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX payten_tenant_id ON public.payten USING btree (tenant_id);
+CREATE INDEX payten_request_metadata ON public.payten USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX payten_request_subscription ON public.payten USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."payten" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('payten', true);
+
+-- Sequence and defined type
+CREATE SEQUENCE IF NOT EXISTS ziraat_id_seq;
+
+-- Table Definition
+CREATE TABLE "public"."ziraat" (
+    "id" int4 NOT NULL DEFAULT nextval('ziraat_id_seq'::regclass),
+    "tenant_id" int4 NOT NULL,
+    "request" jsonb,
+    "response" jsonb,
+    "request_at" timestamp DEFAULT now(),
+    "response_at" timestamp,
+    "method" varchar(50),
+    "endpoint" varchar(255),
+    "request_id" varchar(100),
+    "payment_id" varchar(100),
+    "transaction_id" varchar(100),
+    "amount" numeric(15,2),
+    "currency" varchar(3),
+    "status" varchar(50),
+    "error_code" varchar(50),
+    "processing_ms" int8,
+    "user_agent" varchar(500),
+    "client_ip" varchar(45),
+    PRIMARY KEY ("id")
+);
+
+-- Indices
+CREATE INDEX ziraat_tenant_id ON public.ziraat USING btree (tenant_id);
+CREATE INDEX ziraat_request_metadata ON public.ziraat USING gin ((request -> 'metadata') jsonb_path_ops);
+CREATE INDEX ziraat_request_subscription ON public.ziraat USING btree (tenant_id, (request ->> 'subscriptionId')) WHERE request ? 'subscriptionId';
+ALTER TABLE "public"."ziraat" ADD FOREIGN KEY ("tenant_id") REFERENCES "public"."tenants"("id");
+
+INSERT INTO "public"."providers" ("name", "active") VALUES ('ziraat', true);
@@ -0,0 +1,69 @@
+package gopay
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/provider"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("Expected an error without a database")
+	}
+
+	// sql.Open does not connect, and no query is made before the provider is configured
+	db, err := sql.Open("postgres", "host=localhost port=1 dbname=gopay sslmode=disable")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	var requested int
+	payments, err := New(Options{
+		DB: db,
+		TenantConfig: provider.TenantConfigFunc(func(tenantID int, providerName, environment string) (map[string]string, error) {
+			requested = tenantID
+			return nil, nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { provider.SetTenantConfigSource(nil) })
+
+	_, err = payments.CreatePayment(WithTenant(context.Background(), 42), "sandbox", "iyzico", provider.PaymentRequest{Amount: 100, Currency: "TRY"})
+	if !errors.Is(err, provider.ErrProviderNotConfigured) {
+		t.Errorf("Expected ErrProviderNotConfigured, got %v", err)
+	}
+	if requested != 42 {
+		t.Errorf("Expected the configuration of tenant 42 to be requested, got %d", requested)
+	}
+}
+
+func TestWithTenant(t *testing.T) {
+	if got := middle.GetTenantIDFromContext(WithTenant(context.Background(), 7)); got != "7" {
+		t.Errorf("Expected tenant 7, got %q", got)
+	}
+}
+
+func TestProviderTables(t *testing.T) {
+	schema, err := os.ReadFile("gopay.sql")
+	if err != nil {
+		t.Fatalf("Failed to read gopay.sql: %v", err)
+	}
+
+	// Request logs, payment search and analytics read each provider's own table
+	for _, name := range provider.GetAvailableProviders() {
+		table := postgres.ProviderTableName(name)
+		if !strings.Contains(string(schema), `CREATE TABLE "public"."`+table+`" (`) {
+			t.Errorf("Provider %s logs to table %q, which gopay.sql does not create", name, table)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,11 +16,15 @@ import (
 	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/infra/postgres"
 	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 )
 
 // AnalyticsHandler handles analytics related HTTP requests
 type AnalyticsHandler struct {
-	logger *postgres.Logger
+	logger  *postgres.Logger
+	fxRates provider.FXRateSource
 }
 
 // getTenantContext extracts tenant information from request context
@@ -29,9 +37,31 @@ func (h *AnalyticsHandler) getTenantContext(r *http.Request) (tenantID string, i
 
 // NewAnalyticsHandler creates a new analytics handler
 func NewAnalyticsHandler(logger *postgres.Logger) *AnalyticsHandler {
-	return &AnalyticsHandler{
+	h := &AnalyticsHandler{
 		logger: logger,
 	}
+
+	// Volumes are only converted to a reporting currency when rates are configured
+	if rates, err := provider.FXRatesFromEnv(); err != nil {
+		logInvalidFXRates(err)
+	} else if rates != nil {
+		h.fxRates = rates
+	}
+	return h
+}
+
+// SetFXRateSource sets the exchange rates volumes are converted to the reporting currency with
+func (h *AnalyticsHandler) SetFXRateSource(source provider.FXRateSource) {
+	h.fxRates = source
+}
+
+// logInvalidFXRates reports FX rates that cannot be used
+func logInvalidFXRates(err error) {
+	logger.Warn("Invalid FX rates, volumes are not converted", logger.LogContext{
+		Fields: map[string]any{
+			"error": err.Error(),
+		},
+	})
 }
 
 // DashboardStats represents the main dashboard statistics
@@ -47,6 +77,25 @@ type DashboardStats struct {
 	ActiveTenants       int     `json:"activeTenants"`
 	ActiveProviders     int     `json:"activeProviders"`
 	Environment         string  `json:"environment"`
+	// VolumeByCurrency is the raw volume of each currency. TotalVolume adds them up as they
+	// are, whatever their currency.
+	VolumeByCurrency map[string]float64 `json:"volumeByCurrency,omitempty"`
+	// ConvertedVolume is the volume in the reporting currency, when one is set and FX rates
+	// are configured
+	ConvertedVolume *ConvertedVolume `json:"convertedVolume,omitempty"`
+}
+
+// unknownCurrency is the VolumeByCurrency key of payments logged without a currency
+const unknownCurrency = "UNKNOWN"
+
+// ConvertedVolume is a volume of several currencies converted to one. It is an estimate:
+// the FX rates are indicative and not those the payments settled at.
+type ConvertedVolume struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+	Estimate bool    `json:"estimate"`
+	// Unconverted is the volume of the currencies without a rate, left out of Amount
+	Unconverted map[string]float64 `json:"unconverted,omitempty"`
 }
 
 // ProviderStats represents provider-specific statistics
@@ -73,6 +122,39 @@ type RecentActivity struct {
 	Request  string `json:"request"`
 	Response string `json:"response"`
 	Endpoint string `json:"endpoint"`
+	// Category tells a failed payment apart: "provider_error" when the provider could not
+	// process it, "decline" when it was declined
+	Category string `json:"category,omitempty"`
+	// Message is the text of a provider_outage banner
+	Message string `json:"message,omitempty"`
+}
+
+// Recent activity categories and the type of the banner a provider outage is reported with
+const (
+	ActivityCategoryProviderError = "provider_error"
+	ActivityCategoryDecline       = "decline"
+	ActivityTypeProviderOutage    = "provider_outage"
+)
+
+// A provider is reported as having issues when at least outageMinErrors of its recent
+// payments failed with provider errors and they make up outageMinErrorRatio of them
+const (
+	outageMinErrors     = 3
+	outageMinErrorRatio = 0.5
+)
+
+// outagePhrases are the wordings of provider errors that are logged as a failed response
+// rather than as an error, e.g. a maintenance page or a gateway timeout
+var outagePhrases = []string{
+	"maintenance",
+	"service unavailable",
+	"temporarily unavailable",
+	"bad gateway",
+	"gateway timeout",
+	"timeout",
+	"connection refused",
+	"bakım",
+	"hizmet veremiyor",
 }
 
 // AnalyticsFilters represents the filters for analytics queries
@@ -80,9 +162,15 @@ type AnalyticsFilters struct {
 	TenantID    *int    `json:"tenantId,omitempty"`
 	ProviderID  *string `json:"providerId,omitempty"`
 	Environment *string `json:"environment,omitempty"`
-	Hours       int     `json:"hours"` // Keep for backwards compatibility
-	Month       int     `json:"month"` // For trends chart
-	Year        int     `json:"year"`  // For trends chart
+	// LiveMode keeps only live (true) or test (false) transactions, whatever environment
+	// they were sent to
+	LiveMode *bool `json:"liveMode,omitempty"`
+	Hours    int   `json:"hours"` // Keep for backwards compatibility
+	Month    int   `json:"month"` // For trends chart
+	Year     int   `json:"year"`  // For trends chart
+	// ReportingCurrency is the currency volumes are presented in: the reporting_currency
+	// parameter, or else the requesting tenant's preference
+	ReportingCurrency string `json:"reportingCurrency,omitempty"`
 }
 
 // GetDashboardStats returns main dashboard statistics
@@ -175,31 +263,35 @@ func (h *AnalyticsHandler) parseAnalyticsFilters(r *http.Request) AnalyticsFilte
 		}
 	}
 
+	// Parse live_mode
+	if liveModeStr := r.URL.Query().Get("live_mode"); liveModeStr != "" && liveModeStr != "all" {
+		if liveMode, err := strconv.ParseBool(liveModeStr); err == nil {
+			filters.LiveMode = &liveMode
+		}
+	}
+
+	// Parse reporting_currency, defaulting to the requesting tenant's preference
+	if currency := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("reporting_currency"))); len(currency) == 3 {
+		filters.ReportingCurrency = currency
+	} else if userTenantIDInt, err := strconv.Atoi(userTenantID); err == nil {
+		filters.ReportingCurrency = provider.ReportingCurrencyFor(userTenantIDInt)
+	}
+
 	return filters
 }
 
 // getRealDashboardStats fetches real analytics data from PostgreSQL
 func (h *AnalyticsHandler) getRealDashboardStats(ctx context.Context, filters AnalyticsFilters) (DashboardStats, error) {
-	// Get providers that actually have tenant configurations
-	configuredProviders, err := h.logger.GetActiveProviders(ctx)
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
 	if err != nil {
 		return DashboardStats{}, fmt.Errorf("failed to get active providers: %w", err)
 	}
 
-	// Extract provider keys
-	var providers []string
-	for _, provider := range configuredProviders {
-		providers = append(providers, provider["id"].(string))
-	}
-
-	// Filter by specific provider if requested
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
-	}
-
 	var totalPayments int
 	var totalSuccessful int
 	var totalVolume float64
+	volumeByCurrency := make(map[string]float64)
 	var totalResponseTime float64
 	var responseTimeCount int
 	activeTenants := make(map[int]bool)
@@ -217,7 +309,7 @@ func (h *AnalyticsHandler) getRealDashboardStats(ctx context.Context, filters An
 	for _, tenantID := range tenantIDs {
 		for _, provider := range providers {
 			// Get provider stats from PostgreSQL with environment filter
-			providerStats, err := h.getPaymentStatsWithEnv(ctx, tenantID, provider, filters.Hours, filters.Environment)
+			providerStats, err := h.getPaymentStatsWithEnv(ctx, tenantID, provider, filters.Hours, filters.Environment, filters.LiveMode)
 			if err != nil {
 				continue // Skip provider if error
 			}
@@ -237,9 +329,12 @@ func (h *AnalyticsHandler) getRealDashboardStats(ctx context.Context, filters An
 			}
 
 			// Get payment volumes from PostgreSQL
-			volume, err := h.getProviderVolumeWithFilters(ctx, tenantID, provider, filters)
+			volumes, err := h.getProviderVolumeWithFilters(ctx, tenantID, provider, filters)
 			if err == nil {
-				totalVolume += volume
+				for currency, volume := range volumes {
+					totalVolume += volume
+					volumeByCurrency[currency] += volume
+				}
 			}
 		}
 	}
@@ -260,6 +355,9 @@ func (h *AnalyticsHandler) getRealDashboardStats(ctx context.Context, filters An
 	successRate = float64(int(successRate*100)) / 100
 	totalVolume = float64(int(totalVolume*100)) / 100
 	avgResponseTime = float64(int(avgResponseTime*100)) / 100
+	for currency, volume := range volumeByCurrency {
+		volumeByCurrency[currency] = float64(int(volume*100)) / 100
+	}
 
 	environment := "all"
 	if filters.Environment != nil {
@@ -278,11 +376,37 @@ func (h *AnalyticsHandler) getRealDashboardStats(ctx context.Context, filters An
 		ActiveTenants:       len(activeTenants),
 		ActiveProviders:     len(activeProviders),
 		Environment:         environment,
+		VolumeByCurrency:    volumeByCurrency,
+		ConvertedVolume:     convertVolume(volumeByCurrency, filters.ReportingCurrency, h.fxRates),
 	}, nil
 }
 
-// getProviderVolumeWithFilters calculates total payment volume for a provider with filters
-func (h *AnalyticsHandler) getProviderVolumeWithFilters(ctx context.Context, tenantID int, provider string, filters AnalyticsFilters) (float64, error) {
+// convertVolume converts the volume of each currency to the reporting currency. Currencies
+// without a rate are kept apart in Unconverted. It returns nil without a reporting currency or
+// rates.
+func convertVolume(volumeByCurrency map[string]float64, reportingCurrency string, rates provider.FXRateSource) *ConvertedVolume {
+	if reportingCurrency == "" || rates == nil {
+		return nil
+	}
+
+	converted := &ConvertedVolume{Currency: reportingCurrency, Estimate: true}
+	for currency, volume := range volumeByCurrency {
+		rate, ok := rates.Rate(currency, reportingCurrency)
+		if !ok || currency == unknownCurrency {
+			if converted.Unconverted == nil {
+				converted.Unconverted = make(map[string]float64)
+			}
+			converted.Unconverted[currency] = volume
+			continue
+		}
+		converted.Amount += volume * rate
+	}
+	converted.Amount = math.Round(converted.Amount*100) / 100
+	return converted
+}
+
+// getProviderVolumeWithFilters calculates the payment volume of each currency for a provider with filters
+func (h *AnalyticsHandler) getProviderVolumeWithFilters(ctx context.Context, tenantID int, provider string, filters AnalyticsFilters) (map[string]float64, error) {
 	// Create filters for PostgreSQL search
 	searchFilters := map[string]any{
 		"start_date": time.Now().Add(-time.Duration(filters.Hours) * time.Hour),
@@ -296,17 +420,28 @@ func (h *AnalyticsHandler) getProviderVolumeWithFilters(ctx context.Context, ten
 
 	logs, err := h.logger.SearchPaymentLogs(ctx, tenantID, provider, searchFilters)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	var totalVolume float64
+	return volumeByCurrency(logs, filters.LiveMode), nil
+}
+
+// volumeByCurrency adds up the amounts of the logged payments per currency
+func volumeByCurrency(logs []postgres.PaymentLog, liveMode *bool) map[string]float64 {
+	volumes := make(map[string]float64)
 	for _, log := range logs {
+		if !matchesLiveMode(liveMode, log.Request, log.Response) {
+			continue
+		}
 		if log.PaymentInfo != nil && log.PaymentInfo.Amount > 0 {
-			totalVolume += log.PaymentInfo.Amount
+			currency := strings.ToUpper(strings.TrimSpace(log.PaymentInfo.Currency))
+			if currency == "" {
+				currency = unknownCurrency
+			}
+			volumes[currency] += log.PaymentInfo.Amount
 		}
 	}
-
-	return totalVolume, nil
+	return volumes
 }
 
 // GetProviderStats returns provider-specific statistics
@@ -341,24 +476,12 @@ func (h *AnalyticsHandler) GetProviderStats(w http.ResponseWriter, r *http.Reque
 
 // getRealProviderStats fetches real provider statistics from PostgreSQL
 func (h *AnalyticsHandler) getRealProviderStats(ctx context.Context, filters AnalyticsFilters) ([]ProviderStats, error) {
-	// Get providers that actually have tenant configurations
-	configuredProviders, err := h.logger.GetActiveProviders(ctx)
+	// Get the providers to report on
+	configuredProviders, err := h.analyticsProviders(ctx, filters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active providers: %w", err)
 	}
 
-	// Filter by specific provider if requested
-	if filters.ProviderID != nil {
-		filtered := []map[string]any{}
-		for _, provider := range configuredProviders {
-			if provider["id"].(string) == *filters.ProviderID {
-				filtered = append(filtered, provider)
-				break
-			}
-		}
-		configuredProviders = filtered
-	}
-
 	stats := make([]ProviderStats, len(configuredProviders))
 
 	// Get tenant IDs to process
@@ -382,7 +505,7 @@ func (h *AnalyticsHandler) getRealProviderStats(ctx context.Context, filters Ana
 		// Aggregate stats across all tenants for this provider
 		for _, tenantID := range tenantIDs {
 			// Get provider stats from PostgreSQL with environment consideration
-			providerStats, err := h.getPaymentStatsWithEnv(ctx, tenantID, providerKey, filters.Hours, filters.Environment)
+			providerStats, err := h.getPaymentStatsWithEnv(ctx, tenantID, providerKey, filters.Hours, filters.Environment, filters.LiveMode)
 
 			if err == nil {
 				// Extract stats from PostgreSQL response
@@ -455,8 +578,8 @@ func (h *AnalyticsHandler) getActiveTenants(ctx context.Context) []int {
 	return tenantIDs
 }
 
-// getPaymentStatsWithEnv gets payment stats with environment filter (wrapper method)
-func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID int, provider string, hours int, environment *string) (map[string]any, error) {
+// getPaymentStatsWithEnv gets payment stats with environment and live mode filters (wrapper method)
+func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID int, provider string, hours int, environment *string, liveMode *bool) (map[string]any, error) {
 	// This is a wrapper method since GetPaymentStatsWithEnv doesn't exist in postgres.Logger
 	// For now, we'll use the existing method and filter by environment in application logic
 	stats, err := h.logger.GetPaymentStats(ctx, tenantID, provider, hours)
@@ -464,9 +587,9 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 		return nil, err
 	}
 
-	// If environment filter is specified, filter the results based on environment
-	if environment != nil {
-		// Get detailed logs to filter by environment
+	// If environment or live mode filter is specified, filter the results based on them
+	if environment != nil || liveMode != nil {
+		// Get detailed logs to filter by environment and live mode
 		searchFilters := map[string]any{
 			"start_date": time.Now().Add(-time.Duration(hours) * time.Hour),
 			"end_date":   time.Now(),
@@ -478,26 +601,11 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 			return stats, nil
 		}
 
-		// Filter logs by environment
+		// Filter logs by environment and live mode
 		var filteredLogs []postgres.PaymentLog
 		for _, log := range logs {
-			// Check if the log contains environment information
-			if log.Request != nil {
-				// Check for environment in request data
-				if env, ok := log.Request["environment"].(string); ok {
-					if env == *environment {
-						filteredLogs = append(filteredLogs, log)
-					}
-				} else {
-					// If no environment in request, check response data
-					if log.Response != nil {
-						if env, ok := log.Response["environment"].(string); ok {
-							if env == *environment {
-								filteredLogs = append(filteredLogs, log)
-							}
-						}
-					}
-				}
+			if logMatchesEnvironment(log, environment) && matchesLiveMode(liveMode, log.Request, log.Response) {
+				filteredLogs = append(filteredLogs, log)
 			}
 		}
 
@@ -506,14 +614,18 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 			totalRequests := len(filteredLogs)
 			successCount := 0
 			errorCount := 0
+			blockedCount := 0
 			var totalProcessingMs float64
 			processingCount := 0
 
 			for _, log := range filteredLogs {
 				// Check success status
+				// Blocked payments never reached the provider, so they are not provider errors
 				if log.Response != nil {
 					if success, ok := log.Response["success"].(bool); ok && success {
 						successCount++
+					} else if status, _ := log.Response["status"].(string); status == "blocked" {
+						blockedCount++
 					} else {
 						errorCount++
 					}
@@ -530,6 +642,7 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 			stats["total_requests"] = totalRequests
 			stats["success_count"] = successCount
 			stats["error_count"] = errorCount
+			stats["blocked_count"] = blockedCount
 
 			if totalRequests > 0 {
 				stats["success_rate"] = (float64(successCount) / float64(totalRequests)) * 100
@@ -543,6 +656,7 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 			stats["total_requests"] = 0
 			stats["success_count"] = 0
 			stats["error_count"] = 0
+			stats["blocked_count"] = 0
 			stats["success_rate"] = 0.0
 			stats["avg_processing_ms"] = 0.0
 		}
@@ -551,6 +665,50 @@ func (h *AnalyticsHandler) getPaymentStatsWithEnv(ctx context.Context, tenantID
 	return stats, nil
 }
 
+// logMatchesEnvironment reports whether a log was made in the environment, read from its
+// request or else its response. Every log with a request matches a nil environment.
+func logMatchesEnvironment(log postgres.PaymentLog, environment *string) bool {
+	if log.Request == nil {
+		return false
+	}
+	if environment == nil {
+		return true
+	}
+	if env, ok := log.Request["environment"].(string); ok {
+		return env == *environment
+	}
+	if env, ok := log.Response["environment"].(string); ok {
+		return env == *environment
+	}
+	return false
+}
+
+// loggedLiveMode returns whether a logged payment was live: the liveMode of its response, or
+// for payments logged without one, the environment of its request. ok is false when neither
+// tells.
+func loggedLiveMode(request, response map[string]any) (live bool, ok bool) {
+	if live, ok := response["liveMode"].(bool); ok {
+		return live, true
+	}
+	switch request["environment"] {
+	case "production":
+		return true, true
+	case "sandbox":
+		return false, true
+	}
+	return false, false
+}
+
+// matchesLiveMode reports whether a logged payment passes the live mode filter. Payments
+// whose mode is unknown only pass without a filter.
+func matchesLiveMode(liveMode *bool, request, response map[string]any) bool {
+	if liveMode == nil {
+		return true
+	}
+	live, ok := loggedLiveMode(request, response)
+	return ok && live == *liveMode
+}
+
 // GetRecentActivity returns recent payment activity
 func (h *AnalyticsHandler) GetRecentActivity(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
@@ -594,7 +752,12 @@ func (h *AnalyticsHandler) GetRecentActivity(w http.ResponseWriter, r *http.Requ
 // getRealRecentActivity fetches real recent activity from PostgreSQL
 func (h *AnalyticsHandler) getRealRecentActivity(ctx context.Context, filters AnalyticsFilters, limit int) ([]RecentActivity, error) {
 	// Get all recent activities from provider tables
-	activities, err := h.logger.GetAllRecentActivity(ctx, limit*2) // Get more to filter
+	providers, err := h.analyticsProviderIDs(ctx, AnalyticsFilters{})
+	if err != nil {
+		return []RecentActivity{}, err
+	}
+
+	activities, err := h.logger.GetAllRecentActivity(ctx, providers, limit*2) // Get more to filter
 	if err != nil {
 		return []RecentActivity{}, err
 	}
@@ -623,6 +786,15 @@ func (h *AnalyticsHandler) getRealRecentActivity(ctx context.Context, filters An
 			// Could be enhanced later to detect from the actual data
 		}
 
+		if filters.LiveMode != nil {
+			var request, response map[string]any
+			_ = json.Unmarshal([]byte(activity["request"].(string)), &request)
+			_ = json.Unmarshal([]byte(activity["response"].(string)), &response)
+			if !matchesLiveMode(filters.LiveMode, request, response) {
+				continue
+			}
+		}
+
 		// Convert to RecentActivity struct
 		recentActivity := RecentActivity{
 			Type:     activity["type"].(string),
@@ -637,16 +809,98 @@ func (h *AnalyticsHandler) getRealRecentActivity(ctx context.Context, filters An
 			Response: activity["response"].(string),
 			Endpoint: activity["endpoint"].(string),
 		}
+		recentActivity.Category = activityCategory(recentActivity)
 
 		filteredActivities = append(filteredActivities, recentActivity)
+	}
+
+	// Outages are detected over everything fetched, the banners come on top of the limit
+	banners := providerOutages(filteredActivities)
+	if len(filteredActivities) > limit {
+		filteredActivities = filteredActivities[:limit]
+	}
+
+	return append(banners, filteredActivities...), nil
+}
+
+// activityCategory classifies a recent activity that did not succeed. Payments logged with
+// LogError failed in the provider call itself, and failed responses reading like an outage
+// are counted with them; any other failure is a decline.
+func activityCategory(activity RecentActivity) string {
+	if activity.Status == "success" || activity.Status == "blocked" {
+		return ""
+	}
 
-		// Stop if we have enough activities
-		if len(filteredActivities) >= limit {
-			break
+	var logged struct {
+		Error   bool   `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(activity.Response), &logged); err == nil && logged.Error {
+		return ActivityCategoryProviderError
+	}
+	if activity.Status != "failed" {
+		return ""
+	}
+
+	response := strings.ToLower(activity.Response)
+	for _, phrase := range outagePhrases {
+		if strings.Contains(response, phrase) {
+			return ActivityCategoryProviderError
+		}
+	}
+	return ActivityCategoryDecline
+}
+
+// providerOutages returns a provider_outage banner for every provider whose recent payments
+// mostly failed with provider errors. Activities are expected to be categorized and newest
+// first; a banner carries the time of the provider's latest error.
+func providerOutages(activities []RecentActivity) []RecentActivity {
+	type providerCounts struct {
+		total, errors, declines int
+		lastError               string
+	}
+
+	var order []string
+	counts := make(map[string]*providerCounts)
+	for _, activity := range activities {
+		if activity.Type == ActivityTypeProviderOutage {
+			continue
+		}
+		c, ok := counts[activity.Provider]
+		if !ok {
+			c = &providerCounts{}
+			counts[activity.Provider] = c
+			order = append(order, activity.Provider)
+		}
+		c.total++
+		switch activity.Category {
+		case ActivityCategoryProviderError:
+			if c.errors == 0 {
+				c.lastError = activity.Time
+			}
+			c.errors++
+		case ActivityCategoryDecline:
+			c.declines++
 		}
 	}
 
-	return filteredActivities, nil
+	banners := []RecentActivity{}
+	for _, name := range order {
+		c := counts[name]
+		if c.errors < outageMinErrors || float64(c.errors) < outageMinErrorRatio*float64(c.total) {
+			continue
+		}
+		banners = append(banners, RecentActivity{
+			Type:     ActivityTypeProviderOutage,
+			Provider: name,
+			Status:   "failed",
+			Time:     c.lastError,
+			Category: ActivityCategoryProviderError,
+			Message: fmt.Sprintf("%s is having issues: %d of its last %d payments failed with provider errors (%d declined)",
+				name, c.errors, c.total, c.declines),
+		})
+	}
+	return banners
 }
 
 // GetPaymentTrends returns payment trends data for charts
@@ -715,23 +969,12 @@ func (h *AnalyticsHandler) GetPaymentTrends(w http.ResponseWriter, r *http.Reque
 
 // getRealPaymentTrends fetches real payment trends from PostgreSQL
 func (h *AnalyticsHandler) getRealPaymentTrends(ctx context.Context, filters AnalyticsFilters) (map[string]any, error) {
-	// Get providers that actually have tenant configurations
-	configuredProviders, err := h.logger.GetActiveProviders(ctx)
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active providers: %w", err)
 	}
 
-	// Extract provider keys
-	var providers []string
-	for _, provider := range configuredProviders {
-		providers = append(providers, provider["id"].(string))
-	}
-
-	// Filter by specific provider if requested
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
-	}
-
 	// Track combined daily data
 	dailyData := make(map[string]struct {
 		successful int
@@ -884,23 +1127,12 @@ func (h *AnalyticsHandler) calculatePaymentChangeWithFilters(filters AnalyticsFi
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Get providers that actually have tenant configurations
-	configuredProviders, err := h.logger.GetActiveProviders(ctx)
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
 	if err != nil {
 		return "+12.5% from yesterday" // fallback
 	}
 
-	// Extract provider keys
-	var providers []string
-	for _, provider := range configuredProviders {
-		providers = append(providers, provider["id"].(string))
-	}
-
-	// Filter by specific provider if requested
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
-	}
-
 	var currentTotal, previousTotal int
 
 	// Get tenant IDs to process
@@ -955,10 +1187,10 @@ func (h *AnalyticsHandler) calculateSuccessRateChangeWithFilters(filters Analyti
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Get all providers or filter by specific provider
-	providers := []string{"iyzico", "stripe", "ozanpay", "paycell", "papara", "nkolay", "paytr", "payu"}
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
+	if err != nil {
+		return "+0.8% from yesterday" // fallback
 	}
 
 	var currentTotal, currentSuccess, previousTotal, previousSuccess int
@@ -1030,10 +1262,10 @@ func (h *AnalyticsHandler) calculateVolumeChangeWithFilters(filters AnalyticsFil
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Get all providers or filter by specific provider
-	providers := []string{"iyzico", "stripe", "ozanpay", "paycell", "papara", "nkolay", "paytr", "payu"}
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
+	if err != nil {
+		return "+18.2% from yesterday" // fallback
 	}
 
 	var currentVolume, previousVolume float64
@@ -1090,10 +1322,10 @@ func (h *AnalyticsHandler) calculateResponseTimeChangeWithFilters(filters Analyt
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Get all providers or filter by specific provider
-	providers := []string{"iyzico", "stripe", "ozanpay", "paycell", "papara", "nkolay", "paytr", "payu"}
-	if filters.ProviderID != nil {
-		providers = []string{*filters.ProviderID}
+	// Get the providers to aggregate over
+	providers, err := h.analyticsProviderIDs(ctx, filters)
+	if err != nil {
+		return "-15ms from yesterday" // fallback
 	}
 
 	var currentSum, previousSum float64
@@ -1205,6 +1437,86 @@ func (h *AnalyticsHandler) getRealActiveProviders(ctx context.Context) ([]map[st
 	return h.logger.GetActiveProviders(ctx)
 }
 
+// analyticsProviders returns the providers analytics aggregate over, narrowed to the
+// provider filter when one is set
+func (h *AnalyticsHandler) analyticsProviders(ctx context.Context, filters AnalyticsFilters) ([]map[string]any, error) {
+	configuredProviders, err := h.logger.GetActiveProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterAnalyticsProviders(canonicalProviders(configuredProviders, provider.GetAvailableProviders()), filters), nil
+}
+
+// analyticsProviderIDs returns the keys of analyticsProviders
+func (h *AnalyticsHandler) analyticsProviderIDs(ctx context.Context, filters AnalyticsFilters) ([]string, error) {
+	providers, err := h.analyticsProviders(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(providers))
+	for i, provider := range providers {
+		ids[i] = provider["id"].(string)
+	}
+	return ids, nil
+}
+
+// canonicalProviders merges the providers configured for tenants with the providers
+// registered in this build into one list sorted by key. Keys are lower-cased so a provider
+// is listed once; registered providers without tenants have a tenant count of 0.
+func canonicalProviders(configured []map[string]any, registered []string) []map[string]any {
+	byID := make(map[string]map[string]any, len(configured)+len(registered))
+	for _, configuredProvider := range configured {
+		id, _ := configuredProvider["id"].(string)
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		if existing, ok := byID[id]; ok {
+			existing["tenant_count"] = existing["tenant_count"].(int) + tenantCountOf(configuredProvider)
+			continue
+		}
+		name, _ := configuredProvider["name"].(string)
+		if name == "" {
+			name = cases.Title(language.English).String(id)
+		}
+		byID[id] = map[string]any{"id": id, "name": name, "tenant_count": tenantCountOf(configuredProvider)}
+	}
+	for _, name := range registered {
+		id := strings.ToLower(strings.TrimSpace(name))
+		if _, ok := byID[id]; ok || id == "" {
+			continue
+		}
+		byID[id] = map[string]any{"id": id, "name": cases.Title(language.English).String(id), "tenant_count": 0}
+	}
+
+	providers := make([]map[string]any, 0, len(byID))
+	for _, id := range slices.Sorted(maps.Keys(byID)) {
+		providers = append(providers, byID[id])
+	}
+	return providers
+}
+
+// filterAnalyticsProviders keeps the provider named by the provider filter, if any
+func filterAnalyticsProviders(providers []map[string]any, filters AnalyticsFilters) []map[string]any {
+	if filters.ProviderID == nil {
+		return providers
+	}
+
+	id := strings.ToLower(strings.TrimSpace(*filters.ProviderID))
+	for _, provider := range providers {
+		if provider["id"] == id {
+			return []map[string]any{provider}
+		}
+	}
+	return []map[string]any{}
+}
+
+func tenantCountOf(provider map[string]any) int {
+	count, _ := provider["tenant_count"].(int)
+	return count
+}
+
 // getRealActiveTenants fetches all tenants from PostgreSQL
 func (h *AnalyticsHandler) getRealActiveTenants(ctx context.Context) ([]map[string]any, error) {
 	return h.logger.GetAllTenants(ctx)
@@ -1222,6 +1534,7 @@ func (h *AnalyticsHandler) SearchPaymentByID(w http.ResponseWriter, r *http.Requ
 	tenantIDStr := r.URL.Query().Get("tenant_id")
 	providerID := r.URL.Query().Get("provider_id")
 	paymentID := r.URL.Query().Get("payment_id")
+	subscriptionID := r.URL.Query().Get("subscription_id")
 
 	// Validate required parameters
 	if tenantIDStr == "" || tenantIDStr == "all" {
@@ -1234,8 +1547,14 @@ func (h *AnalyticsHandler) SearchPaymentByID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if paymentID == "" {
-		response.Error(w, http.StatusBadRequest, "payment_id is required", fmt.Errorf("payment_id parameter is missing"))
+	metadata, err := parseMetadataFilter(r.URL.Query()["metadata"])
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "invalid metadata filter", err)
+		return
+	}
+
+	if paymentID == "" && len(metadata) == 0 && subscriptionID == "" {
+		response.Error(w, http.StatusBadRequest, "payment_id, metadata or subscription_id is required", fmt.Errorf("payment_id, metadata and subscription_id parameters are missing"))
 		return
 	}
 
@@ -1269,24 +1588,30 @@ func (h *AnalyticsHandler) SearchPaymentByID(w http.ResponseWriter, r *http.Requ
 	var searchErr error
 
 	if h.logger != nil {
-		activities, searchErr = h.searchPaymentInDatabase(ctx, finalTenantID, providerID, paymentID)
+		activities, searchErr = h.searchPaymentInDatabase(ctx, finalTenantID, providerID, postgres.PaymentSearchFilter{
+			PaymentID:      paymentID,
+			Metadata:       metadata,
+			SubscriptionID: subscriptionID,
+		})
 		if searchErr != nil {
 			logger.Warn("Failed to search payment", logger.LogContext{
 				TenantID: fmt.Sprintf("%d", finalTenantID),
 				Fields: map[string]any{
-					"error":       searchErr.Error(),
-					"tenant_id":   finalTenantID,
-					"provider":    providerID,
-					"payment_id":  paymentID,
-					"user_tenant": userTenantID,
-					"is_admin":    isAdmin,
+					"error":        searchErr.Error(),
+					"tenant_id":    finalTenantID,
+					"provider":     providerID,
+					"payment_id":   paymentID,
+					"metadata":     metadata,
+					"subscription": subscriptionID,
+					"user_tenant":  userTenantID,
+					"is_admin":     isAdmin,
 				},
 			})
 		}
 	}
 
 	if len(activities) == 0 {
-		response.Error(w, http.StatusNotFound, "Payment not found", fmt.Errorf("no payment found with ID %s for tenant %d and provider %s", paymentID, finalTenantID, providerID))
+		response.Error(w, http.StatusNotFound, "Payment not found", fmt.Errorf("no payment found matching the search for tenant %d and provider %s", finalTenantID, providerID))
 		return
 	}
 
@@ -1294,10 +1619,28 @@ func (h *AnalyticsHandler) SearchPaymentByID(w http.ResponseWriter, r *http.Requ
 	response.Success(w, http.StatusOK, "Payment found successfully", activities)
 }
 
-// searchPaymentInDatabase searches for a payment in the specified provider table
-func (h *AnalyticsHandler) searchPaymentInDatabase(ctx context.Context, tenantID int, provider, paymentID string) ([]*RecentActivity, error) {
-	// Search in the provider table for the specific payment
-	payments, err := h.logger.SearchPaymentByID(ctx, tenantID, provider, paymentID)
+// parseMetadataFilter parses repeated metadata=key:value query parameters
+func parseMetadataFilter(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("metadata filter %q must be in key:value form", value)
+		}
+		metadata[key] = strings.TrimSpace(val)
+	}
+	return metadata, nil
+}
+
+// searchPaymentInDatabase searches for payments in the specified provider table
+func (h *AnalyticsHandler) searchPaymentInDatabase(ctx context.Context, tenantID int, provider string, filter postgres.PaymentSearchFilter) ([]*RecentActivity, error) {
+	// Search in the provider table by identifier and/or metadata
+	payments, err := h.logger.SearchPayments(ctx, tenantID, provider, filter)
 	if err != nil {
 		return nil, err
 	}
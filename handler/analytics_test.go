@@ -1,13 +1,17 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/provider"
 )
 
 func TestNewAnalyticsHandler(t *testing.T) {
@@ -749,3 +753,310 @@ func TestAnalyticsHandler_CalculationFunctionsEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestParseMetadataFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []string
+		expected    map[string]string
+		expectError bool
+	}{
+		{name: "no filter", values: nil, expected: nil},
+		{name: "single pair", values: []string{"campaign:summer2024"}, expected: map[string]string{"campaign": "summer2024"}},
+		{name: "multiple pairs", values: []string{"campaign:summer2024", "channel:mobile"}, expected: map[string]string{"campaign": "summer2024", "channel": "mobile"}},
+		{name: "value containing colon", values: []string{"slot:10:30"}, expected: map[string]string{"slot": "10:30"}},
+		{name: "missing separator", values: []string{"campaign"}, expectError: true},
+		{name: "empty key", values: []string{":summer2024"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseMetadataFilter(tt.values)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, result)
+			}
+			for k, v := range tt.expected {
+				if result[k] != v {
+					t.Errorf("Expected %s=%s, got %v", k, v, result)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyticsHandler_SearchPaymentByID_RequiresIdentifierOrMetadata(t *testing.T) {
+	handler := NewAnalyticsHandler(nil)
+
+	req := httptest.NewRequest("GET", "/v1/analytics/search?tenant_id=1&provider_id=paycell", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, "1"))
+	w := httptest.NewRecorder()
+
+	handler.SearchPaymentByID(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestCanonicalProviders(t *testing.T) {
+	provider.Register("analyticstestpay", func() provider.PaymentProvider { return nil })
+
+	configured := []map[string]any{
+		{"id": "stripe", "name": "Stripe", "tenant_count": 2},
+		{"id": "Iyzico", "name": "Iyzico", "tenant_count": 1},
+		{"id": "iyzico", "name": "Iyzico", "tenant_count": 3},
+	}
+	providers := canonicalProviders(configured, provider.GetAvailableProviders())
+
+	var ids []string
+	counts := make(map[string]int)
+	for _, p := range providers {
+		id := p["id"].(string)
+		ids = append(ids, id)
+		counts[id]++
+	}
+
+	// Every analytics method aggregates over this list, so a newly registered provider
+	// shows up in all of them without being configured for a tenant
+	if counts["analyticstestpay"] != 1 {
+		t.Fatalf("expected the registered provider once, got providers %v", ids)
+	}
+	for id, count := range counts {
+		if count != 1 {
+			t.Errorf("expected %s once, got %d", id, count)
+		}
+	}
+	if !slices.IsSorted(ids) {
+		t.Errorf("expected providers sorted by key, got %v", ids)
+	}
+
+	for _, p := range providers {
+		switch p["id"] {
+		case "iyzico":
+			if p["tenant_count"] != 4 {
+				t.Errorf("expected iyzico tenant count 4, got %v", p["tenant_count"])
+			}
+		case "analyticstestpay":
+			if p["name"] != "Analyticstestpay" || p["tenant_count"] != 0 {
+				t.Errorf("unexpected registered provider entry %v", p)
+			}
+		}
+	}
+
+	providerID := "AnalyticsTestPay"
+	filtered := filterAnalyticsProviders(providers, AnalyticsFilters{ProviderID: &providerID})
+	if len(filtered) != 1 || filtered[0]["id"] != "analyticstestpay" {
+		t.Errorf("expected the provider filter to keep analyticstestpay, got %v", filtered)
+	}
+
+	unknown := "unknown"
+	if filtered := filterAnalyticsProviders(providers, AnalyticsFilters{ProviderID: &unknown}); len(filtered) != 0 {
+		t.Errorf("expected no providers for an unknown filter, got %v", filtered)
+	}
+}
+
+func TestActivityCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		activity RecentActivity
+		want     string
+	}{
+		{"success", RecentActivity{Status: "success", Response: `{"success":true}`}, ""},
+		{"logged provider error", RecentActivity{Status: "processing", Response: `{"error":true,"code":"PROVIDER_ERROR","message":"dial tcp: i/o timeout"}`}, ActivityCategoryProviderError},
+		{"maintenance response", RecentActivity{Status: "failed", Response: `{"success":false,"message":"Sistem bakım çalışması nedeniyle hizmet verilemiyor"}`}, ActivityCategoryProviderError},
+		{"gateway timeout", RecentActivity{Status: "failed", Response: `{"success":false,"message":"504 Gateway Timeout"}`}, ActivityCategoryProviderError},
+		{"card declined", RecentActivity{Status: "failed", Response: `{"success":false,"errorCode":"51","message":"Insufficient funds"}`}, ActivityCategoryDecline},
+		{"in progress", RecentActivity{Status: "processing", Response: `{"status":"pending"}`}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activityCategory(tt.activity); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProviderOutages(t *testing.T) {
+	activity := func(provider, category, time string) RecentActivity {
+		return RecentActivity{Type: "payment", Provider: provider, Category: category, Time: time}
+	}
+	activities := []RecentActivity{
+		// iyzico is down: most of its payments never got an answer
+		activity("iyzico", ActivityCategoryProviderError, "2m ago"),
+		activity("iyzico", ActivityCategoryProviderError, "3m ago"),
+		activity("iyzico", ActivityCategoryDecline, "4m ago"),
+		activity("iyzico", ActivityCategoryProviderError, "5m ago"),
+		// stripe customers' cards are declining, the provider is fine
+		activity("stripe", ActivityCategoryDecline, "1m ago"),
+		activity("stripe", ActivityCategoryDecline, "2m ago"),
+		activity("stripe", ActivityCategoryDecline, "3m ago"),
+		activity("stripe", ActivityCategoryDecline, "4m ago"),
+		// paytr had a few errors among many successful payments
+		activity("paytr", ActivityCategoryProviderError, "1m ago"),
+		activity("paytr", ActivityCategoryProviderError, "2m ago"),
+		activity("paytr", ActivityCategoryProviderError, "3m ago"),
+		activity("paytr", "", "4m ago"),
+		activity("paytr", "", "5m ago"),
+		activity("paytr", "", "6m ago"),
+		activity("paytr", "", "7m ago"),
+		// a single error is not an outage
+		activity("papara", ActivityCategoryProviderError, "1m ago"),
+	}
+
+	banners := providerOutages(activities)
+	if len(banners) != 1 {
+		t.Fatalf("expected one banner, got %+v", banners)
+	}
+	banner := banners[0]
+	if banner.Type != ActivityTypeProviderOutage || banner.Provider != "iyzico" || banner.Time != "2m ago" {
+		t.Errorf("unexpected banner %+v", banner)
+	}
+	if !strings.Contains(banner.Message, "3 of its last 4 payments") {
+		t.Errorf("expected the counts in the message, got %q", banner.Message)
+	}
+
+	if banners := providerOutages(nil); banners == nil || len(banners) != 0 {
+		t.Errorf("expected an empty banner list, got %v", banners)
+	}
+}
+
+func TestAnalyticsFilters_LiveMode(t *testing.T) {
+	handler := NewAnalyticsHandler(nil)
+
+	tests := map[string]string{"": "all", "all": "all", "maybe": "all", "true": "live", "false": "test"}
+
+	for value, expected := range tests {
+		req := httptest.NewRequest("GET", "/v1/analytics/dashboard?live_mode="+value, nil)
+		filters := handler.parseAnalyticsFilters(req)
+
+		got := "all"
+		if filters.LiveMode != nil && *filters.LiveMode {
+			got = "live"
+		} else if filters.LiveMode != nil {
+			got = "test"
+		}
+		if got != expected {
+			t.Errorf("live_mode=%q: expected %s transactions, got %s", value, expected, got)
+		}
+	}
+}
+
+func TestMatchesLiveMode(t *testing.T) {
+	live, test := true, false
+	tests := []struct {
+		name     string
+		request  map[string]any
+		response map[string]any
+		filter   *bool
+		expected bool
+	}{
+		{"no filter", nil, nil, nil, true},
+		{"live response", map[string]any{"environment": "sandbox"}, map[string]any{"liveMode": true}, &live, true},
+		{"test response", map[string]any{"environment": "production"}, map[string]any{"liveMode": false}, &live, false},
+		{"test response kept by test filter", nil, map[string]any{"liveMode": false}, &test, true},
+		{"production request without live mode", map[string]any{"environment": "production"}, map[string]any{"success": true}, &live, true},
+		{"sandbox request without live mode", map[string]any{"environment": "sandbox"}, nil, &live, false},
+		{"unknown mode", map[string]any{"amount": 10}, map[string]any{"success": true}, &test, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesLiveMode(tt.filter, tt.request, tt.response); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAnalyticsFilters_ReportingCurrency(t *testing.T) {
+	t.Setenv("REPORTING_CURRENCY_TENANT_7", "eur")
+	handler := NewAnalyticsHandler(nil)
+
+	tests := []struct {
+		name     string
+		tenantID string
+		query    string
+		expected string
+	}{
+		{"tenant preference", "7", "", "EUR"},
+		{"parameter wins", "7", "?reporting_currency=usd", "USD"},
+		{"invalid parameter", "7", "?reporting_currency=dollars", "EUR"},
+		{"no preference", "8", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/analytics/dashboard"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, tt.tenantID))
+			if got := handler.parseAnalyticsFilters(req).ReportingCurrency; got != tt.expected {
+				t.Errorf("Expected reporting currency %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVolumeByCurrency(t *testing.T) {
+	live := true
+	logs := []postgres.PaymentLog{
+		{Request: map[string]any{"environment": "production"}, PaymentInfo: &postgres.PaymentInfo{Amount: 100, Currency: "TRY"}},
+		{Request: map[string]any{"environment": "production"}, PaymentInfo: &postgres.PaymentInfo{Amount: 50.5, Currency: "try"}},
+		{Request: map[string]any{"environment": "production"}, PaymentInfo: &postgres.PaymentInfo{Amount: 10, Currency: "USD"}},
+		{Request: map[string]any{"environment": "production"}, PaymentInfo: &postgres.PaymentInfo{Amount: 5}},
+		{Request: map[string]any{"environment": "sandbox"}, PaymentInfo: &postgres.PaymentInfo{Amount: 999, Currency: "EUR"}},
+		{Request: map[string]any{"environment": "production"}},
+	}
+
+	volumes := volumeByCurrency(logs, &live)
+	expected := map[string]float64{"TRY": 150.5, "USD": 10, unknownCurrency: 5}
+	if len(volumes) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, volumes)
+	}
+	for currency, volume := range expected {
+		if volumes[currency] != volume {
+			t.Errorf("Expected %s volume %.2f, got %.2f", currency, volume, volumes[currency])
+		}
+	}
+
+	if all := volumeByCurrency(logs, nil); all["EUR"] != 999 {
+		t.Errorf("Expected the sandbox EUR payment without a live mode filter, got %v", all)
+	}
+}
+
+func TestConvertVolume(t *testing.T) {
+	rates := provider.StaticFXRates{"TRY": 1, "USD": 32, "EUR": 35}
+	volumes := map[string]float64{"TRY": 1000, "USD": 10, "EUR": 2, "GBP": 7, unknownCurrency: 3}
+
+	converted := convertVolume(volumes, "TRY", rates)
+	if converted == nil {
+		t.Fatal("Expected a converted volume")
+	}
+	if converted.Currency != "TRY" || !converted.Estimate {
+		t.Errorf("Expected an estimate in TRY, got %+v", converted)
+	}
+	if converted.Amount != 1390 {
+		t.Errorf("Expected 1000 + 10*32 + 2*35 = 1390, got %.2f", converted.Amount)
+	}
+	if len(converted.Unconverted) != 2 || converted.Unconverted["GBP"] != 7 || converted.Unconverted[unknownCurrency] != 3 {
+		t.Errorf("Expected GBP and unknown volumes to stay unconverted, got %v", converted.Unconverted)
+	}
+
+	if usd := convertVolume(map[string]float64{"TRY": 64}, "USD", rates); usd.Amount != 2 {
+		t.Errorf("Expected 64 TRY to be 2 USD, got %.2f", usd.Amount)
+	}
+	if convertVolume(volumes, "", rates) != nil {
+		t.Error("Expected no conversion without a reporting currency")
+	}
+	if convertVolume(volumes, "TRY", nil) != nil {
+		t.Error("Expected no conversion without FX rates")
+	}
+}
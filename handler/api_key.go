@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// APIKeyHandler manages the API keys of the authenticated tenant. Keys are managed with a JWT
+// only, so a leaked key cannot issue more keys.
+type APIKeyHandler struct {
+	apiKeys  *auth.APIKeyService
+	validate *validator.Validate
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeys *auth.APIKeyService, validate *validator.Validate) *APIKeyHandler {
+	return &APIKeyHandler{apiKeys: apiKeys, validate: validate}
+}
+
+// CreateAPIKeyRequest names a new API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// CreatedAPIKeyResponse is a new API key with its secret, which is shown only once
+type CreatedAPIKeyResponse struct {
+	auth.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey handles POST /v1/auth/api-keys
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := response.ReadJSON(w, r, &req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	key, secret, err := h.apiKeys.Create(r.Context(), tenantID, req.Name)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to create API key", err)
+		return
+	}
+	response.Success(w, http.StatusCreated, "API key created, store it now: it is not shown again", CreatedAPIKeyResponse{APIKey: *key, Key: secret})
+}
+
+// ListAPIKeys handles GET /v1/auth/api-keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeys.List(r.Context(), tenantID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list API keys", err)
+		return
+	}
+	if keys == nil {
+		keys = []auth.APIKey{}
+	}
+	response.Success(w, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+// RotateAPIKey handles POST /v1/auth/api-keys/{keyID}/rotate. The key is replaced with a new
+// one of the same name and stops working at once.
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	key, secret, err := h.apiKeys.Rotate(r.Context(), tenantID, chi.URLParam(r, "keyID"))
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			response.Error(w, http.StatusNotFound, "API key not found", nil)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to rotate API key", err)
+		return
+	}
+	response.Success(w, http.StatusCreated, "API key rotated, store it now: it is not shown again", CreatedAPIKeyResponse{APIKey: *key, Key: secret})
+}
+
+// RevokeAPIKey handles DELETE /v1/auth/api-keys/{keyID}
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	keyID := chi.URLParam(r, "keyID")
+	if err := h.apiKeys.Revoke(r.Context(), tenantID, keyID); err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			response.Error(w, http.StatusNotFound, "API key not found", nil)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to revoke API key", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "API key revoked", map[string]string{"id": keyID})
+}
+
+// apiKeyTenant returns the authenticated tenant, writing the error response when there is none
+func apiKeyTenant(w http.ResponseWriter, r *http.Request) (int, bool) {
+	tenantID, err := strconv.Atoi(middle.GetTenantIDFromContext(r.Context()))
+	if err != nil {
+		response.Error(w, http.StatusUnauthorized, "Invalid or missing authentication", nil)
+		return 0, false
+	}
+	return tenantID, true
+}
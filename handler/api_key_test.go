@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+)
+
+// memoryAPIKeyStore keeps API keys in memory, without revocation history
+type memoryAPIKeyStore struct {
+	keys []auth.APIKey
+}
+
+func (m *memoryAPIKeyStore) Create(_ context.Context, key *auth.APIKey, _ string) error {
+	m.keys = append(m.keys, *key)
+	return nil
+}
+
+func (m *memoryAPIKeyStore) GetByHash(context.Context, string) (*auth.APIKey, error) {
+	return nil, auth.ErrAPIKeyNotFound
+}
+
+func (m *memoryAPIKeyStore) List(_ context.Context, tenantID int) ([]auth.APIKey, error) {
+	var keys []auth.APIKey
+	for _, key := range m.keys {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memoryAPIKeyStore) Revoke(_ context.Context, tenantID int, id string, _ time.Time) (bool, error) {
+	for i, key := range m.keys {
+		if key.ID == id && key.TenantID == tenantID {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryAPIKeyStore) Touch(context.Context, string, time.Time) error { return nil }
+
+func TestAPIKeyHandler(t *testing.T) {
+	h := NewAPIKeyHandler(auth.NewAPIKeyService(&memoryAPIKeyStore{}), validator.New())
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middle.TenantIDKey, "7")))
+		})
+	})
+	r.Post("/api-keys", h.CreateAPIKey)
+	r.Get("/api-keys", h.ListAPIKeys)
+	r.Post("/api-keys/{keyID}/rotate", h.RotateAPIKey)
+	r.Delete("/api-keys/{keyID}", h.RevokeAPIKey)
+
+	serve := func(method, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var resp map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	if rec, _ := serve(http.MethodPost, "/api-keys", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", rec.Code)
+	}
+
+	rec, resp := serve(http.MethodPost, "/api-keys", `{"name":"ci"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	created := resp["data"].(map[string]any)
+	if !strings.HasPrefix(created["key"].(string), auth.APIKeyPrefix) || created["name"] != "ci" {
+		t.Errorf("Expected the key with its secret, got %v", created)
+	}
+
+	rec, resp = serve(http.MethodGet, "/api-keys", "")
+	if keys := resp["data"].([]any); rec.Code != http.StatusOK || len(keys) != 1 || keys[0].(map[string]any)["key"] != nil {
+		t.Errorf("Expected the key listed without its secret, got %d %v", rec.Code, resp["data"])
+	}
+
+	rec, resp = serve(http.MethodPost, "/api-keys/"+created["id"].(string)+"/rotate", "")
+	if rec.Code != http.StatusCreated || resp["data"].(map[string]any)["key"] == created["key"] {
+		t.Fatalf("Expected a new key, got %d %v", rec.Code, resp["data"])
+	}
+	rotatedID := resp["data"].(map[string]any)["id"].(string)
+
+	if rec, _ := serve(http.MethodDelete, "/api-keys/"+created["id"].(string), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the rotated key to be gone, got %d", rec.Code)
+	}
+	if rec, _ := serve(http.MethodDelete, "/api-keys/"+rotatedID, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the key to be revoked, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/audit"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditHandler serves the audit trail of mutating operations
+type AuditHandler struct {
+	store audit.Store
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(store audit.Store) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// ListAuditEvents lists audit events, newest first.
+// Query params: from and to (RFC3339 or YYYY-MM-DD, default the last 24 hours), action and
+// limit. Tenants see the events on their own data; the admin (tenant_id = "1") sees every
+// tenant's events, or one tenant's with tenant_id.
+func (h *AuditHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	tenantID := middle.GetTenantIDFromContext(r.Context())
+	if tenantID == "" {
+		response.Error(w, http.StatusUnauthorized, "Invalid or missing authentication", nil)
+		return
+	}
+
+	filter, err := parseAuditFilter(r, time.Now())
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	requestedTenant := strings.TrimSpace(r.URL.Query().Get("tenant_id"))
+	if tenantID == "1" {
+		if requestedTenant != "all" {
+			filter.TenantID = requestedTenant
+		}
+	} else {
+		if requestedTenant != "" && requestedTenant != tenantID {
+			response.Error(w, http.StatusForbidden, "Access denied: you can only view your own audit events", nil)
+			return
+		}
+		filter.TenantID = tenantID
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	events, err := h.store.List(ctx, filter)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to retrieve audit events", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Audit events retrieved successfully", map[string]any{
+		"events": events,
+		"count":  len(events),
+		"from":   filter.From,
+		"to":     filter.To,
+	})
+}
+
+// parseAuditFilter reads the time range, action and limit of an audit query
+func parseAuditFilter(r *http.Request, now time.Time) (audit.Filter, error) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Action: strings.TrimSpace(query.Get("action")),
+		From:   now.Add(-24 * time.Hour),
+		To:     now,
+		Limit:  defaultAuditLimit,
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := parseAuditTime(value, false)
+		if err != nil {
+			return filter, errors.New("invalid from parameter")
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := parseAuditTime(value, true)
+		if err != nil {
+			return filter, errors.New("invalid to parameter")
+		}
+		filter.To = to
+	}
+	if filter.From.After(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("invalid limit parameter")
+		}
+		filter.Limit = min(limit, maxAuditLimit)
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses an RFC3339 time or a date; a date used as the end of a range covers
+// the whole day
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/audit"
+	"github.com/mstgnz/gopay/infra/middle"
+)
+
+// filterRecordingAuditStore remembers the filter of the last List call
+type filterRecordingAuditStore struct {
+	filter *audit.Filter
+	events []audit.Event
+}
+
+func (s *filterRecordingAuditStore) Record(ctx context.Context, event audit.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *filterRecordingAuditStore) List(ctx context.Context, filter audit.Filter) ([]audit.Event, error) {
+	s.filter = &filter
+	return s.events, nil
+}
+
+func TestAuditHandler_ListAuditEvents_Scoping(t *testing.T) {
+	tests := []struct {
+		name           string
+		tenantID       string
+		query          string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{"tenant sees own events", "7", "", http.StatusOK, "7"},
+		{"tenant asks for own events", "7", "?tenant_id=7", http.StatusOK, "7"},
+		{"tenant asks for another tenant", "7", "?tenant_id=8", http.StatusForbidden, ""},
+		{"admin sees every tenant", "1", "", http.StatusOK, ""},
+		{"admin sees every tenant explicitly", "1", "?tenant_id=all", http.StatusOK, ""},
+		{"admin filters one tenant", "1", "?tenant_id=8", http.StatusOK, "8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &filterRecordingAuditStore{}
+			h := NewAuditHandler(store)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/audit"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, tt.tenantID))
+			w := httptest.NewRecorder()
+			h.ListAuditEvents(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if store.filter != nil {
+					t.Error("Expected the store not to be queried")
+				}
+				return
+			}
+			if store.filter.TenantID != tt.expectedTenant {
+				t.Errorf("Expected tenant filter %q, got %q", tt.expectedTenant, store.filter.TenantID)
+			}
+		})
+	}
+}
+
+func TestAuditHandler_ListAuditEvents_Unauthenticated(t *testing.T) {
+	h := NewAuditHandler(&filterRecordingAuditStore{})
+
+	w := httptest.NewRecorder()
+	h.ListAuditEvents(w, httptest.NewRequest(http.MethodGet, "/v1/audit", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestParseAuditFilter(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	t.Run("defaults to the last 24 hours", func(t *testing.T) {
+		filter, err := parseAuditFilter(httptest.NewRequest(http.MethodGet, "/v1/audit", nil), now)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !filter.From.Equal(now.Add(-24*time.Hour)) || !filter.To.Equal(now) || filter.Limit != defaultAuditLimit {
+			t.Errorf("Unexpected default filter: %+v", filter)
+		}
+	})
+
+	t.Run("dates, action and limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/audit?from=2024-03-01&to=2024-03-02&action=payment.refund&limit=5000", nil)
+		filter, err := parseAuditFilter(req, now)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !filter.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected from: %v", filter.From)
+		}
+		if !filter.To.Equal(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)) {
+			t.Errorf("Expected to cover the whole day, got %v", filter.To)
+		}
+		if filter.Action != audit.ActionPaymentRefund || filter.Limit != maxAuditLimit {
+			t.Errorf("Unexpected action or limit: %+v", filter)
+		}
+	})
+
+	for _, query := range []string{"?from=yesterday", "?to=2024-13-01", "?from=2024-03-05&to=2024-03-01", "?limit=0"} {
+		t.Run("rejects "+query, func(t *testing.T) {
+			if _, err := parseAuditFilter(httptest.NewRequest(http.MethodGet, "/v1/audit"+query, nil), now); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
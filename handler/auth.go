@@ -2,12 +2,14 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/infra/auth"
 	"github.com/mstgnz/gopay/infra/middle"
@@ -38,10 +40,12 @@ type LoginRequest struct {
 
 // LoginResponse represents the login response structure
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Username  string    `json:"username"`
-	TenantID  string    `json:"tenant_id"`
+	Token            string     `json:"token"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	Username         string     `json:"username"`
+	TenantID         string     `json:"tenant_id"`
+	RefreshToken     string     `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
 }
 
 // ChangePasswordRequest represents the change password request structure
@@ -57,9 +61,19 @@ type CreateTenantRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
-// RefreshTokenRequest represents the refresh token request structure
+// RefreshTokenRequest represents the refresh token request structure. A refresh token from
+// login is exchanged for a new pair; a bare access token is refreshed as before.
 type RefreshTokenRequest struct {
-	Token string `json:"token" validate:"required"`
+	Token        string `json:"token,omitempty" validate:"required_without=RefreshToken"`
+	RefreshToken string `json:"refresh_token,omitempty" validate:"required_without=Token"`
+}
+
+// RevokeRequest selects what to revoke: the session of a refresh token, the sessions of a user
+// of the tenant, or all of the tenant's sessions
+type RevokeRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Username     string `json:"username,omitempty"`
+	All          bool   `json:"all,omitempty"`
 }
 
 // RegisterRequest represents the registration request structure
@@ -68,6 +82,12 @@ type RegisterRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// SessionResponse is an active session, flagged when it belongs to the calling token
+type SessionResponse struct {
+	auth.Session
+	Current bool `json:"current"`
+}
+
 // Login handles tenant login requests
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Parse the login request
@@ -87,10 +107,11 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	loginReq := auth.LoginRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Client:   sessionClient(r),
 	}
 
 	// Authenticate tenant
-	loginResp, err := h.tenantService.Login(loginReq)
+	loginResp, err := h.tenantService.Login(r.Context(), loginReq)
 	if err != nil {
 		switch err {
 		case auth.ErrInvalidCredentials:
@@ -140,23 +161,22 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token for the new user
+	// Generate JWT token for the new user. Its expiry comes from the signing
+	// service so the reported value can never outlive the token itself.
 	tenantID := fmt.Sprintf("%d", tenant.ID)
-	token, err := h.jwtService.GenerateToken(tenantID, tenant.Username)
+	tokens, err := h.jwtService.IssueTokens(r.Context(), tenantID, tenant.Username, sessionClient(r))
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, "Failed to generate authentication token", err)
 		return
 	}
 
-	// Expiry comes from the signing service so the reported value can never
-	// outlive the token itself.
-	expiresAt := time.Now().Add(h.jwtService.Expiry())
-
 	registerResp := LoginResponse{
-		Token:     token,
-		TenantID:  tenantID,
-		Username:  tenant.Username,
-		ExpiresAt: expiresAt,
+		Token:            tokens.Token,
+		TenantID:         tenantID,
+		Username:         tenant.Username,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
 	}
 
 	response.Success(w, http.StatusCreated, "Registration successful", registerResp)
@@ -173,8 +193,21 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now, we just return success since JWT tokens are stateless
-	// In a production system, you might want to maintain a blacklist of tokens
+	// Revoke the session so this token (and any refreshed from it) stops working.
+	// Tokens issued before session tracking have no session and simply expire.
+	claims := middle.GetTenantClaimsFromContext(r.Context())
+	if sessions := h.jwtService.Sessions(); sessions != nil && claims != nil && claims.ID != "" {
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid tenant ID", nil)
+			return
+		}
+		if err := sessions.Revoke(r.Context(), id, claims.ID, auth.SessionRevokedLogout); err != nil && !errors.Is(err, auth.ErrSessionNotFound) {
+			response.Error(w, http.StatusInternalServerError, "Logout failed", err)
+			return
+		}
+	}
+
 	responseData := map[string]string{
 		"message": "Logged out successfully",
 	}
@@ -235,7 +268,7 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Change password with current password verification
-		err = h.tenantService.ChangePassword(targetTenantID, req.CurrentPassword, req.NewPassword)
+		err = h.tenantService.ChangeUserPassword(r.Context(), targetTenantID, username, req.CurrentPassword, req.NewPassword)
 	} else if isAdmin {
 		// Admin is changing another user's password - no current password needed
 		err = h.tenantService.AdminChangePassword(targetTenantID, req.NewPassword)
@@ -338,6 +371,26 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.RefreshToken != "" {
+		tokens, err := h.jwtService.Refresh(r.Context(), req.RefreshToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrInvalidRefreshToken):
+				response.Error(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+			case errors.Is(err, auth.ErrRefreshTokenReused):
+				response.Error(w, http.StatusUnauthorized, "Refresh token was already used, session has been revoked", nil)
+			case errors.Is(err, auth.ErrSessionRevoked):
+				response.Error(w, http.StatusUnauthorized, "Session has been revoked", nil)
+			default:
+				response.Error(w, http.StatusInternalServerError, "Failed to refresh token", err)
+			}
+			return
+		}
+
+		response.Success(w, http.StatusOK, "Token refreshed successfully", tokens)
+		return
+	}
+
 	// Refresh token
 	newToken, err := h.jwtService.RefreshToken(req.Token)
 	if err != nil {
@@ -346,6 +399,8 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 			response.Error(w, http.StatusUnauthorized, "Token has expired", nil)
 		case auth.ErrInvalidToken:
 			response.Error(w, http.StatusUnauthorized, "Invalid token", nil)
+		case auth.ErrSessionRevoked:
+			response.Error(w, http.StatusUnauthorized, "Session has been revoked", nil)
 		default:
 			response.Error(w, http.StatusInternalServerError, "Failed to refresh token", err)
 		}
@@ -422,6 +477,8 @@ func (h *AuthHandler) ValidateToken(w http.ResponseWriter, r *http.Request) {
 			response.Error(w, http.StatusUnauthorized, "Invalid token claims", nil)
 		case auth.ErrMissingTenant:
 			response.Error(w, http.StatusUnauthorized, "Missing tenant information in token", nil)
+		case auth.ErrSessionRevoked:
+			response.Error(w, http.StatusUnauthorized, "Session has been revoked", nil)
 		default:
 			response.Error(w, http.StatusUnauthorized, "Token validation failed", nil)
 		}
@@ -447,3 +504,157 @@ func (h *AuthHandler) ValidateToken(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, http.StatusOK, "Token is valid", tokenInfo)
 }
+
+// ListSessions returns the current tenant's active sessions
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, tenantID, ok := h.sessionContext(w, r)
+	if !ok {
+		return
+	}
+
+	active, err := sessions.List(r.Context(), tenantID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list sessions", err)
+		return
+	}
+
+	currentID := ""
+	if claims := middle.GetTenantClaimsFromContext(r.Context()); claims != nil {
+		currentID = claims.ID
+	}
+
+	result := make([]SessionResponse, 0, len(active))
+	for _, session := range active {
+		result = append(result, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+
+	response.Success(w, http.StatusOK, "Sessions retrieved successfully", map[string]any{
+		"sessions":     result,
+		"max_sessions": sessions.MaxSessions(),
+	})
+}
+
+// RevokeSession revokes one of the current tenant's sessions, forcing it to log in again
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessions, tenantID, ok := h.sessionContext(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	if sessionID == "" {
+		response.Error(w, http.StatusBadRequest, "Session ID is required", nil)
+		return
+	}
+
+	if err := sessions.Revoke(r.Context(), tenantID, sessionID, auth.SessionRevokedByUser); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			response.Error(w, http.StatusNotFound, "Session not found", nil)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to revoke session", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Session revoked", map[string]string{"session_id": sessionID})
+}
+
+// Revoke revokes the session of a refresh token, the sessions of a user of the tenant, or all of
+// the tenant's sessions. Users can always log themselves out; the others need user management.
+func (h *AuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	sessions, tenantID, ok := h.sessionContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req RevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	username := middle.GetTenantUserFromContext(r.Context())
+	canManageUsers := func() bool {
+		role := middle.GetTenantRoleFromContext(r.Context())
+		if role != "" && !role.Allows(auth.PermissionManageUsers) {
+			response.Error(w, http.StatusForbidden, "Your role "+string(role)+" does not allow "+string(auth.PermissionManageUsers), nil)
+			return false
+		}
+		return true
+	}
+
+	var err error
+	switch {
+	case req.RefreshToken != "":
+		err = h.jwtService.RevokeRefreshToken(r.Context(), tenantID, req.RefreshToken)
+	case req.All:
+		if !canManageUsers() {
+			return
+		}
+		err = sessions.RevokeAll(r.Context(), tenantID, auth.SessionRevokedByUser)
+	case req.Username != "":
+		if req.Username != username && !canManageUsers() {
+			return
+		}
+		err = sessions.RevokeUser(r.Context(), tenantID, req.Username, auth.SessionRevokedByUser)
+	default:
+		response.Error(w, http.StatusBadRequest, "One of refresh_token, username or all is required", nil)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			response.Error(w, http.StatusNotFound, "Refresh token not found", nil)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to revoke sessions", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Sessions revoked", nil)
+}
+
+// sessionContext resolves the session service and the tenant whose sessions are managed, writing
+// the error response when either is missing. That is the authenticated tenant, or for admin
+// users who manage users the tenant_id query parameter.
+func (h *AuthHandler) sessionContext(w http.ResponseWriter, r *http.Request) (*auth.SessionService, int, bool) {
+	tenantIDStr := middle.GetTenantIDFromContext(r.Context())
+	if tenantIDStr == "" {
+		response.Error(w, http.StatusUnauthorized, "Invalid session", nil)
+		return nil, 0, false
+	}
+
+	if target := r.URL.Query().Get("tenant_id"); target != "" && target != tenantIDStr {
+		if tenantIDStr != "1" {
+			response.Error(w, http.StatusForbidden, "Only admin can manage other tenants' sessions", nil)
+			return nil, 0, false
+		}
+		if role := middle.GetTenantRoleFromContext(r.Context()); role != "" && !role.Allows(auth.PermissionManageUsers) {
+			response.Error(w, http.StatusForbidden, "Your role "+string(role)+" does not allow "+string(auth.PermissionManageUsers), nil)
+			return nil, 0, false
+		}
+		tenantIDStr = target
+		middle.SetAuditTargetTenant(r.Context(), target)
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tenant ID", nil)
+		return nil, 0, false
+	}
+
+	sessions := h.jwtService.Sessions()
+	if sessions == nil {
+		response.Error(w, http.StatusNotImplemented, "Session tracking is not enabled", nil)
+		return nil, 0, false
+	}
+
+	return sessions, tenantID, true
+}
+
+// sessionClient describes the caller of a login or registration request
+func sessionClient(r *http.Request) auth.SessionClient {
+	return auth.SessionClient{
+		IP:        middle.GetClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
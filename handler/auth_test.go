@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
 )
 
 func TestAuthHandler_Login_InvalidJSON(t *testing.T) {
@@ -106,6 +108,75 @@ func TestAuthHandler_Logout_MissingContext(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_ListSessions_MissingContext(t *testing.T) {
+	handler := NewAuthHandler(&auth.TenantService{}, &auth.JWTService{}, validator.New())
+
+	req := httptest.NewRequest("GET", "/auth/sessions", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListSessions(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_RevokeSession_TrackingDisabled(t *testing.T) {
+	handler := NewAuthHandler(&auth.TenantService{}, &auth.JWTService{}, validator.New())
+
+	req := httptest.NewRequest("DELETE", "/auth/sessions/abc", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, "1"))
+	w := httptest.NewRecorder()
+
+	handler.RevokeSession(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_ListSessions_OtherTenant(t *testing.T) {
+	handler := NewAuthHandler(&auth.TenantService{}, &auth.JWTService{}, validator.New())
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		role       auth.Role
+		statusCode int
+	}{
+		// Tenants manage their own sessions only
+		{"tenant", "7", auth.RoleOwner, http.StatusForbidden},
+		// Admin users who cannot manage users cannot log other tenants out
+		{"admin read-only", "1", auth.RoleReadOnly, http.StatusForbidden},
+		// Admin owners pass the checks and reach the (disabled) session tracking
+		{"admin owner", "1", auth.RoleOwner, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, call := range []struct {
+				method  string
+				path    string
+				handler http.HandlerFunc
+			}{
+				{"GET", "/auth/sessions?tenant_id=8", handler.ListSessions},
+				{"DELETE", "/auth/sessions/abc?tenant_id=8", handler.RevokeSession},
+			} {
+				req := httptest.NewRequest(call.method, call.path, nil)
+				ctx := context.WithValue(req.Context(), middle.TenantIDKey, tt.tenantID)
+				ctx = context.WithValue(ctx, middle.TenantRoleKey, tt.role)
+				w := httptest.NewRecorder()
+
+				call.handler(w, req.WithContext(ctx))
+
+				if w.Code != tt.statusCode {
+					t.Errorf("%s: expected status %d, got %d", call.method, tt.statusCode, w.Code)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkAuthHandler_Login(b *testing.B) {
 	tenantService := &auth.TenantService{}
 	jwtService := &auth.JWTService{}
@@ -66,6 +66,7 @@ type paySavedCardBody struct {
 	CallbackURL      string  `json:"callbackUrl,omitempty"`
 	ConversationID   string  `json:"conversationId,omitempty"`
 	SessionID        string  `json:"sessionId,omitempty"`
+	SubscriptionID   string  `json:"subscriptionId,omitempty"`
 }
 
 func environmentFromRequest(r *http.Request) string {
@@ -232,6 +233,7 @@ func (h *CardHandler) PayWithCard(w http.ResponseWriter, r *http.Request) {
 		CallbackURL:      body.CallbackURL,
 		ConversationID:   body.ConversationID,
 		SessionID:        body.SessionID,
+		SubscriptionID:   body.SubscriptionID,
 		ClientIP:         middle.GetClientIP(r),
 		ClientUserAgent:  r.Header.Get("User-Agent"),
 	}, body.Use3D)
@@ -239,7 +241,7 @@ func (h *CardHandler) PayWithCard(w http.ResponseWriter, r *http.Request) {
 		h.writeServiceError(w, "Payment failed", err)
 		return
 	}
-	response.Return(w, http.StatusOK, resp.Success, resp.Message, resp)
+	response.ReturnInUnit(w, http.StatusOK, resp.Success, resp.Message, resp, response.RequestedAmountUnit(r))
 }
 
 // writeServiceError maps card-service errors to appropriate HTTP status codes.
@@ -249,6 +251,8 @@ func (h *CardHandler) writeServiceError(w http.ResponseWriter, message string, e
 		response.Error(w, http.StatusBadRequest, "Provider does not support card storage", err)
 	case errors.Is(err, provider.ErrSavedCardNotFound):
 		response.Error(w, http.StatusNotFound, "Saved card not found", err)
+	case errors.Is(err, provider.ErrInvalidCardNumber):
+		response.Error(w, http.StatusBadRequest, "Invalid card number", err)
 	default:
 		response.Error(w, http.StatusInternalServerError, message, err)
 	}
@@ -8,8 +8,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/fingerprint"
 	"github.com/mstgnz/gopay/infra/logger"
 	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/infra/response"
@@ -21,6 +23,7 @@ type ConfigHandler struct {
 	providerConfig *config.ProviderConfig
 	paymentService *provider.PaymentService
 	validate       *validator.Validate
+	templates      config.ConfigTemplateStore
 }
 
 // NewConfigHandler creates a new config handler
@@ -32,6 +35,11 @@ func NewConfigHandler(providerConfig *config.ProviderConfig, paymentService *pro
 	}
 }
 
+// SetTemplateStore sets the store of the provider config templates used to onboard tenants
+func (h *ConfigHandler) SetTemplateStore(store config.ConfigTemplateStore) {
+	h.templates = store
+}
+
 // SetEnvRequest represents the request structure for setting environment variables
 type SetEnvRequest struct {
 	Provider    string `json:"provider"`
@@ -96,6 +104,27 @@ func (h *ConfigHandler) PostTenantConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// A description template is checked now rather than failing silently on every payment
+	if descriptionTemplate, ok := configMap[provider.DescriptionTemplateConfigKey]; ok && strings.TrimSpace(descriptionTemplate) != "" {
+		if _, err := provider.ParseDescriptionTemplate(descriptionTemplate); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid description template", err)
+			return
+		}
+	}
+	if value, ok := configMap[provider.InstallmentCreditOnlyConfigKey]; ok && strings.TrimSpace(value) != "" {
+		if _, err := strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid installmentCreditOnly value, expected true or false", err)
+			return
+		}
+	}
+	// A mistyped pin would reject every webhook of the provider
+	for _, pin := range fingerprint.Parse(configMap[provider.WebhookClientCertConfigKey]) {
+		if !fingerprint.Valid(pin) {
+			response.Error(w, http.StatusBadRequest, "Invalid webhookClientCertFingerprints value, expected SHA-256 fingerprints", nil)
+			return
+		}
+	}
+
 	// Convert tenantID to int for cache operations
 	tenantIDInt, err := strconv.Atoi(tenantID)
 	if err != nil {
@@ -207,6 +236,95 @@ func (h *ConfigHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, http.StatusOK, "Statistics retrieved", stats)
 }
 
+// configValidationBatchSize is the number of tenants whose configs are loaded and validated at once
+const configValidationBatchSize = 100
+
+// ConfigValidationResult describes a stored tenant provider config that failed validation.
+// It only names config keys; values are never included.
+type ConfigValidationResult struct {
+	TenantID    int      `json:"tenantId"`
+	Provider    string   `json:"provider"`
+	Environment string   `json:"environment"`
+	Error       string   `json:"error"`
+	MissingKeys []string `json:"missingKeys,omitempty"`
+}
+
+// ConfigValidationReport summarizes the validation of every stored tenant provider config
+type ConfigValidationReport struct {
+	Checked int                      `json:"checked"`
+	Valid   int                      `json:"valid"`
+	Invalid []ConfigValidationResult `json:"invalid"`
+}
+
+// ValidateAllTenantConfigs runs every stored tenant provider config through the provider's
+// ValidateConfig and reports the invalid or incomplete ones (admin only)
+func (h *ConfigHandler) ValidateAllTenantConfigs(w http.ResponseWriter, r *http.Request) {
+	tenantID := middle.GetTenantIDFromContext(r.Context())
+	if tenantID == "" {
+		response.Error(w, http.StatusUnauthorized, "Authentication required", nil)
+		return
+	}
+
+	// Only admin (tenant_id = "1") can inspect other tenants' configs
+	if tenantID != "1" {
+		response.Error(w, http.StatusForbidden, "Only administrators can validate all tenant configurations", nil)
+		return
+	}
+
+	report := &ConfigValidationReport{Invalid: []ConfigValidationResult{}}
+	err := h.providerConfig.ForEachTenantConfigBatch(configValidationBatchSize, func(configs []config.TenantProviderConfig) error {
+		report.addBatch(configs)
+		return nil
+	})
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to load tenant configurations", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Configuration validation report", report)
+}
+
+// addBatch validates a batch of stored configs and records the invalid ones
+func (report *ConfigValidationReport) addBatch(configs []config.TenantProviderConfig) {
+	for _, stored := range configs {
+		report.Checked++
+		if result, ok := validateStoredConfig(stored); !ok {
+			report.Invalid = append(report.Invalid, result)
+		} else {
+			report.Valid++
+		}
+	}
+}
+
+// validateStoredConfig validates one stored config with its provider and lists the required
+// keys that are missing or empty
+func validateStoredConfig(stored config.TenantProviderConfig) (ConfigValidationResult, bool) {
+	result := ConfigValidationResult{
+		TenantID:    stored.TenantID,
+		Provider:    stored.Provider,
+		Environment: stored.Environment,
+	}
+
+	providerFactory, err := provider.Get(stored.Provider)
+	if err != nil {
+		result.Error = "provider not found in registry"
+		return result, false
+	}
+	providerInstance := providerFactory()
+
+	if err := providerInstance.ValidateConfig(stored.Config); err != nil {
+		result.Error = err.Error()
+		for _, field := range providerInstance.GetRequiredConfig(stored.Environment) {
+			if field.Required && strings.TrimSpace(stored.Config[field.Key]) == "" {
+				result.MissingKeys = append(result.MissingKeys, field.Key)
+			}
+		}
+		return result, false
+	}
+
+	return result, true
+}
+
 // validateConfigWithProvider validates configuration using provider's own validation method
 func (h *ConfigHandler) validateConfigWithProvider(providerName string, config map[string]string) error {
 	// Get provider factory from registry
@@ -232,3 +350,246 @@ func (h *ConfigHandler) validateConfigWithProvider(providerName string, config m
 
 	return nil
 }
+
+// requireConfigAdmin answers the request and returns false unless it comes from the admin
+// (tenant_id = "1"), who alone manages config templates
+func requireConfigAdmin(w http.ResponseWriter, r *http.Request) bool {
+	tenantID := middle.GetTenantIDFromContext(r.Context())
+	if tenantID == "" {
+		response.Error(w, http.StatusUnauthorized, "Authentication required", nil)
+		return false
+	}
+	if tenantID != "1" {
+		response.Error(w, http.StatusForbidden, "Only administrators can manage config templates", nil)
+		return false
+	}
+	return true
+}
+
+// ConfigTemplateRequest is the request to create or replace a config template
+type ConfigTemplateRequest struct {
+	Name        string            `json:"name"`
+	Provider    string            `json:"provider"`
+	Environment string            `json:"environment"`
+	Config      map[string]string `json:"config"`
+}
+
+// SaveConfigTemplate creates or replaces a provider config template (admin only)
+func (h *ConfigHandler) SaveConfigTemplate(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigAdmin(w, r) {
+		return
+	}
+	if h.templates == nil {
+		response.Error(w, http.StatusNotImplemented, "Config templates are not available", nil)
+		return
+	}
+
+	var req ConfigTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+
+	template := config.ConfigTemplate{
+		Name:        strings.TrimSpace(req.Name),
+		Provider:    strings.ToLower(strings.TrimSpace(req.Provider)),
+		Environment: strings.ToLower(strings.TrimSpace(req.Environment)),
+		Config:      make(map[string]string),
+	}
+	if template.Name == "" || template.Provider == "" || template.Environment == "" {
+		response.Error(w, http.StatusBadRequest, "name, provider and environment are required", nil)
+		return
+	}
+	if template.Environment != "sandbox" && template.Environment != "production" {
+		response.Error(w, http.StatusBadRequest, "environment must be 'sandbox' or 'production'", nil)
+		return
+	}
+	if _, err := provider.Get(template.Provider); err != nil {
+		response.Error(w, http.StatusBadRequest, "Provider not found", err)
+		return
+	}
+	for key, value := range req.Config {
+		if key = strings.TrimSpace(key); key != "" && key != "environment" {
+			template.Config[key] = value
+		}
+	}
+
+	if err := h.templates.Save(r.Context(), template); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to save config template", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Config template saved", template)
+}
+
+// ListConfigTemplates returns every provider config template (admin only)
+func (h *ConfigHandler) ListConfigTemplates(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigAdmin(w, r) {
+		return
+	}
+	if h.templates == nil {
+		response.Error(w, http.StatusNotImplemented, "Config templates are not available", nil)
+		return
+	}
+
+	templates, err := h.templates.List(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list config templates", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Config templates retrieved", templates)
+}
+
+// DeleteConfigTemplate deletes a provider config template (admin only). Configs already
+// applied from it are kept.
+func (h *ConfigHandler) DeleteConfigTemplate(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigAdmin(w, r) {
+		return
+	}
+	if h.templates == nil {
+		response.Error(w, http.StatusNotImplemented, "Config templates are not available", nil)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.templates.Delete(r.Context(), name); err != nil {
+		if errors.Is(err, config.ErrConfigTemplateNotFound) {
+			response.Error(w, http.StatusNotFound, "Config template not found", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to delete config template", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Config template deleted", map[string]any{"name": name})
+}
+
+// ApplyTemplateRequest is the request to onboard a tenant with a config template. Configs
+// fill in or override the template's values, typically with the tenant's secrets.
+type ApplyTemplateRequest struct {
+	TenantID string `json:"tenantId"`
+	Template string `json:"template"`
+	Configs  []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"configs"`
+}
+
+// ApplyTemplateResult is the config a template produced for a tenant. It names the required
+// keys, usually the provider's secrets, that still need values; values are never included.
+type ApplyTemplateResult struct {
+	TenantID    string   `json:"tenantId"`
+	Template    string   `json:"template"`
+	Provider    string   `json:"provider"`
+	Environment string   `json:"environment"`
+	Complete    bool     `json:"complete"`
+	MissingKeys []string `json:"missingKeys"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// ApplyConfigTemplate saves a template's config, with the request's values, as a tenant's
+// provider config (admin only). A config that only lacks required values is saved and reported
+// as incomplete, so the secrets can be filled in later; any other invalid config is rejected.
+func (h *ConfigHandler) ApplyConfigTemplate(w http.ResponseWriter, r *http.Request) {
+	if !requireConfigAdmin(w, r) {
+		return
+	}
+	if h.templates == nil {
+		response.Error(w, http.StatusNotImplemented, "Config templates are not available", nil)
+		return
+	}
+
+	var req ApplyTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	req.TenantID = strings.TrimSpace(req.TenantID)
+	tenantIDInt, err := strconv.Atoi(req.TenantID)
+	if err != nil || tenantIDInt <= 0 {
+		response.Error(w, http.StatusBadRequest, "A valid tenantId is required", err)
+		return
+	}
+	middle.SetAuditTargetTenant(r.Context(), req.TenantID)
+	if strings.TrimSpace(req.Template) == "" {
+		response.Error(w, http.StatusBadRequest, "template is required", nil)
+		return
+	}
+
+	template, err := h.templates.Get(r.Context(), strings.TrimSpace(req.Template))
+	if err != nil {
+		if errors.Is(err, config.ErrConfigTemplateNotFound) {
+			response.Error(w, http.StatusNotFound, "Config template not found", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to load config template", err)
+		return
+	}
+
+	values := make(map[string]string, len(req.Configs))
+	for _, kv := range req.Configs {
+		values[kv.Key] = kv.Value
+	}
+	configMap, result, err := applyConfigTemplate(template, values)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("Invalid configuration: %v", err), err)
+		return
+	}
+	result.TenantID = req.TenantID
+
+	if err := h.providerConfig.SetTenantConfig(req.TenantID, template.Provider, configMap); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to save configuration", err)
+		return
+	}
+	provider.GetProviderCache().Delete(tenantIDInt, template.Provider, template.Environment)
+
+	message := "Config template applied"
+	if !result.Complete {
+		message = "Config template applied, required values are missing"
+	}
+	response.Success(w, http.StatusOK, message, result)
+}
+
+// applyConfigTemplate merges the template's config with values and validates the result with
+// the provider. Missing required keys are reported in the result; any other validation error
+// is returned.
+func applyConfigTemplate(template *config.ConfigTemplate, values map[string]string) (map[string]string, ApplyTemplateResult, error) {
+	result := ApplyTemplateResult{
+		Template:    template.Name,
+		Provider:    template.Provider,
+		Environment: template.Environment,
+		MissingKeys: []string{},
+	}
+
+	providerFactory, err := provider.Get(template.Provider)
+	if err != nil {
+		return nil, result, errors.New("provider not found in registry")
+	}
+	providerInstance := providerFactory()
+
+	configMap := make(map[string]string, len(template.Config)+len(values)+1)
+	for key, value := range template.Config {
+		configMap[key] = value
+	}
+	for key, value := range values {
+		if key = strings.TrimSpace(key); key != "" && key != "environment" && value != "" {
+			configMap[key] = value
+		}
+	}
+	configMap["environment"] = template.Environment
+
+	for _, field := range providerInstance.GetRequiredConfig(template.Environment) {
+		if field.Required && strings.TrimSpace(configMap[field.Key]) == "" {
+			result.MissingKeys = append(result.MissingKeys, field.Key)
+		}
+	}
+
+	if err := providerInstance.ValidateConfig(configMap); err != nil {
+		if len(result.MissingKeys) == 0 {
+			return nil, result, err
+		}
+		result.Error = err.Error()
+		return configMap, result, nil
+	}
+
+	result.Complete = len(result.MissingKeys) == 0
+	return configMap, result, nil
+}
@@ -1,7 +1,16 @@
 package handler
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/provider"
 )
 
 func TestConfigHandler_Basic(t *testing.T) {
@@ -28,6 +37,301 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
+// configReportTestProvider requires apiKey and secretKey, like most registered providers
+type configReportTestProvider struct {
+	provider.PaymentProvider
+}
+
+func (p *configReportTestProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{Key: "apiKey", Required: true, Type: "string"},
+		{Key: "secretKey", Required: true, Type: "string"},
+		{Key: "callbackPath", Required: false, Type: "string"},
+	}
+}
+
+func (p *configReportTestProvider) ValidateConfig(config map[string]string) error {
+	return provider.ValidateConfigFields("configreporttest", config, p.GetRequiredConfig(config["environment"]))
+}
+
+func TestConfigValidationReport_AddBatch(t *testing.T) {
+	provider.Register("configreporttest", func() provider.PaymentProvider { return &configReportTestProvider{} })
+
+	report := &ConfigValidationReport{Invalid: []ConfigValidationResult{}}
+	report.addBatch([]config.TenantProviderConfig{
+		{TenantID: 2, Provider: "configreporttest", Environment: "sandbox", Config: map[string]string{
+			"environment": "sandbox", "apiKey": "live-key-123", "secretKey": "top-secret-456",
+		}},
+		{TenantID: 2, Provider: "configreporttest", Environment: "production", Config: map[string]string{
+			"environment": "production", "apiKey": "live-key-123", "secretKey": " ",
+		}},
+		{TenantID: 3, Provider: "unregistered", Environment: "sandbox", Config: map[string]string{
+			"environment": "sandbox", "apiKey": "other-key-789",
+		}},
+	})
+	report.addBatch([]config.TenantProviderConfig{
+		{TenantID: 4, Provider: "configreporttest", Environment: "sandbox", Config: map[string]string{
+			"environment": "sandbox",
+		}},
+	})
+
+	if report.Checked != 4 || report.Valid != 1 || len(report.Invalid) != 3 {
+		t.Fatalf("Expected 4 checked, 1 valid and 3 invalid configs, got %+v", report)
+	}
+
+	incomplete := report.Invalid[0]
+	if incomplete.TenantID != 2 || incomplete.Environment != "production" || incomplete.Error == "" {
+		t.Errorf("Unexpected result for incomplete config: %+v", incomplete)
+	}
+	if len(incomplete.MissingKeys) != 1 || incomplete.MissingKeys[0] != "secretKey" {
+		t.Errorf("Expected secretKey to be missing, got %v", incomplete.MissingKeys)
+	}
+	if unregistered := report.Invalid[1]; unregistered.Provider != "unregistered" || unregistered.Error != "provider not found in registry" {
+		t.Errorf("Unexpected result for unregistered provider: %+v", unregistered)
+	}
+	if empty := report.Invalid[2]; len(empty.MissingKeys) != 2 {
+		t.Errorf("Expected apiKey and secretKey to be missing, got %v", empty.MissingKeys)
+	}
+
+	for _, result := range report.Invalid {
+		text := result.Error + strings.Join(result.MissingKeys, ",")
+		for _, secret := range []string{"live-key-123", "top-secret-456", "other-key-789"} {
+			if strings.Contains(text, secret) {
+				t.Errorf("Report leaked config value %q: %+v", secret, result)
+			}
+		}
+	}
+}
+
+func TestConfigHandler_ValidateAllTenantConfigs(t *testing.T) {
+	tests := []struct {
+		name           string
+		tenantID       string
+		expectedStatus int
+	}{
+		{"unauthenticated", "", http.StatusUnauthorized},
+		{"non-admin tenant", "2", http.StatusForbidden},
+		{"admin without storage", "1", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewConfigHandler(&config.ProviderConfig{}, nil, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/config/validate-all", nil)
+			if tt.tenantID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, tt.tenantID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ValidateAllTenantConfigs(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// configTemplateTestProvider requires an 8 character apiKey and a secretKey
+type configTemplateTestProvider struct {
+	provider.PaymentProvider
+}
+
+func (p *configTemplateTestProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{Key: "apiKey", Required: true, Type: "string", MinLength: 8},
+		{Key: "secretKey", Required: true, Type: "string"},
+		{Key: "installmentMode", Required: false, Type: "string"},
+	}
+}
+
+func (p *configTemplateTestProvider) ValidateConfig(config map[string]string) error {
+	return provider.ValidateConfigFields("configtemplatetest", config, p.GetRequiredConfig(config["environment"]))
+}
+
+// memoryConfigTemplateStore keeps config templates in memory
+type memoryConfigTemplateStore struct {
+	templates map[string]config.ConfigTemplate
+}
+
+func (s *memoryConfigTemplateStore) Save(ctx context.Context, template config.ConfigTemplate) error {
+	s.templates[template.Name] = template
+	return nil
+}
+
+func (s *memoryConfigTemplateStore) Get(ctx context.Context, name string) (*config.ConfigTemplate, error) {
+	template, ok := s.templates[name]
+	if !ok {
+		return nil, config.ErrConfigTemplateNotFound
+	}
+	return &template, nil
+}
+
+func (s *memoryConfigTemplateStore) List(ctx context.Context) ([]config.ConfigTemplate, error) {
+	templates := []config.ConfigTemplate{}
+	for _, template := range s.templates {
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+func (s *memoryConfigTemplateStore) Delete(ctx context.Context, name string) error {
+	if _, ok := s.templates[name]; !ok {
+		return config.ErrConfigTemplateNotFound
+	}
+	delete(s.templates, name)
+	return nil
+}
+
+func TestApplyConfigTemplate(t *testing.T) {
+	provider.Register("configtemplatetest", func() provider.PaymentProvider { return &configTemplateTestProvider{} })
+
+	template := &config.ConfigTemplate{
+		Name:        "marketplace",
+		Provider:    "configtemplatetest",
+		Environment: "production",
+		Config:      map[string]string{"installmentMode": "bank", "apiKey": ""},
+	}
+
+	t.Run("reports missing secrets", func(t *testing.T) {
+		configMap, result, err := applyConfigTemplate(template, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Complete || result.Error == "" {
+			t.Errorf("Expected an incomplete result with its validation error, got %+v", result)
+		}
+		if strings.Join(result.MissingKeys, ",") != "apiKey,secretKey" {
+			t.Errorf("Expected apiKey and secretKey to be missing, got %v", result.MissingKeys)
+		}
+		if configMap["installmentMode"] != "bank" || configMap["environment"] != "production" {
+			t.Errorf("Expected the template defaults and environment, got %v", configMap)
+		}
+	})
+
+	t.Run("fills in secrets", func(t *testing.T) {
+		configMap, result, err := applyConfigTemplate(template, map[string]string{
+			"apiKey":      "tenant-api-key",
+			"secretKey":   "tenant-secret",
+			"environment": "sandbox",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !result.Complete || len(result.MissingKeys) != 0 || result.Error != "" {
+			t.Errorf("Expected a complete result, got %+v", result)
+		}
+		if configMap["apiKey"] != "tenant-api-key" || configMap["installmentMode"] != "bank" || configMap["environment"] != "production" {
+			t.Errorf("Unexpected config %v", configMap)
+		}
+		if template.Config["apiKey"] != "" {
+			t.Errorf("Template config was modified: %v", template.Config)
+		}
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		_, _, err := applyConfigTemplate(template, map[string]string{"apiKey": "short", "secretKey": "tenant-secret"})
+		if err == nil {
+			t.Error("Expected a validation error for a short apiKey")
+		}
+	})
+
+	t.Run("unregistered provider", func(t *testing.T) {
+		_, _, err := applyConfigTemplate(&config.ConfigTemplate{Name: "x", Provider: "unregistered", Environment: "sandbox"}, nil)
+		if err == nil {
+			t.Error("Expected an error for an unregistered provider")
+		}
+	})
+}
+
+func TestConfigHandler_ConfigTemplates(t *testing.T) {
+	provider.Register("configtemplatetest", func() provider.PaymentProvider { return &configTemplateTestProvider{} })
+
+	store := &memoryConfigTemplateStore{templates: map[string]config.ConfigTemplate{}}
+	handler := NewConfigHandler(&config.ProviderConfig{}, nil, nil)
+	handler.SetTemplateStore(store)
+
+	request := func(method, target, tenantID, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if tenantID != "" {
+			req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, tenantID))
+		}
+		return req
+	}
+
+	saveTests := []struct {
+		name           string
+		tenantID       string
+		body           string
+		expectedStatus int
+	}{
+		{"unauthenticated", "", `{}`, http.StatusUnauthorized},
+		{"non-admin tenant", "2", `{}`, http.StatusForbidden},
+		{"missing name", "1", `{"provider":"configtemplatetest","environment":"sandbox"}`, http.StatusBadRequest},
+		{"invalid environment", "1", `{"name":"m","provider":"configtemplatetest","environment":"test"}`, http.StatusBadRequest},
+		{"unknown provider", "1", `{"name":"m","provider":"unregistered","environment":"sandbox"}`, http.StatusBadRequest},
+		{"saved", "1", `{"name":"marketplace","provider":"ConfigTemplateTest","environment":"sandbox","config":{"installmentMode":"bank"}}`, http.StatusOK},
+	}
+	for _, tt := range saveTests {
+		t.Run("save/"+tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SaveConfigTemplate(w, request(http.MethodPost, "/v1/config/templates", tt.tenantID, tt.body))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+	if saved := store.templates["marketplace"]; saved.Provider != "configtemplatetest" || saved.Config["installmentMode"] != "bank" {
+		t.Errorf("Unexpected saved template %+v", saved)
+	}
+
+	applyTests := []struct {
+		name           string
+		tenantID       string
+		body           string
+		expectedStatus int
+	}{
+		{"non-admin tenant", "2", `{"tenantId":"5","template":"marketplace"}`, http.StatusForbidden},
+		{"invalid tenant", "1", `{"tenantId":"abc","template":"marketplace"}`, http.StatusBadRequest},
+		{"missing template", "1", `{"tenantId":"5"}`, http.StatusBadRequest},
+		{"unknown template", "1", `{"tenantId":"5","template":"unknown"}`, http.StatusNotFound},
+		{"invalid value", "1", `{"tenantId":"5","template":"marketplace","configs":[{"key":"apiKey","value":"short"},{"key":"secretKey","value":"s"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range applyTests {
+		t.Run("apply/"+tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ApplyConfigTemplate(w, request(http.MethodPost, "/v1/config/apply-template", tt.tenantID, tt.body))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("without store", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewConfigHandler(&config.ProviderConfig{}, nil, nil).ApplyConfigTemplate(w, request(http.MethodPost, "/v1/config/apply-template", "1", `{}`))
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		for _, expectedStatus := range []int{http.StatusOK, http.StatusNotFound} {
+			req := request(http.MethodDelete, "/v1/config/templates/marketplace", "1", "")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("name", "marketplace")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.DeleteConfigTemplate(w, req)
+			if w.Code != expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", expectedStatus, w.Code, w.Body.String())
+			}
+		}
+	})
+}
+
 func BenchmarkConfigHandler(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
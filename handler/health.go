@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,6 +23,7 @@ type HealthHandler struct {
 	postgresLogger *postgres.Logger
 	paymentService *provider.PaymentService
 	providerConfig *config.ProviderConfig
+	probeClient    *http.Client
 	startTime      time.Time
 }
 
@@ -97,6 +99,9 @@ type ServiceHealth struct {
 	Error       string `json:"error,omitempty"`
 }
 
+// providerProbeTimeout bounds each provider request of the deep health probe
+const providerProbeTimeout = 5 * time.Second
+
 // NewHealthHandler creates a new health handler
 func NewHealthHandler(db *sql.DB, postgresLogger *postgres.Logger, paymentService *provider.PaymentService, providerConfig *config.ProviderConfig) *HealthHandler {
 	return &HealthHandler{
@@ -104,22 +109,26 @@ func NewHealthHandler(db *sql.DB, postgresLogger *postgres.Logger, paymentServic
 		postgresLogger: postgresLogger,
 		paymentService: paymentService,
 		providerConfig: providerConfig,
+		probeClient:    &http.Client{Timeout: providerProbeTimeout},
 		startTime:      time.Now(),
 	}
 }
 
-// CheckHealth performs comprehensive health checks
+// CheckHealth performs comprehensive health checks. With deep=true every provider's
+// HealthCheckEndpoint is requested as well.
 func (h *HealthHandler) CheckHealth(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
+	deep, _ := strconv.ParseBool(r.URL.Query().Get("deep"))
+
 	health := &HealthStatus{
 		Version:     "1.0.0",
 		Timestamp:   time.Now().UTC(),
 		Uptime:      time.Since(h.startTime).String(),
 		Environment: getEnvironment(),
 		Database:    h.checkDatabaseHealth(ctx),
-		Providers:   h.checkProvidersHealth(ctx),
+		Providers:   h.checkProvidersHealth(ctx, deep),
 		System:      h.checkSystemHealth(),
 		Services:    h.checkServicesHealth(ctx),
 	}
@@ -198,21 +207,21 @@ func (h *HealthHandler) checkDatabaseHealth(ctx context.Context) *DatabaseHealth
 }
 
 // checkProvidersHealth checks payment providers health
-func (h *HealthHandler) checkProvidersHealth(ctx context.Context) map[string]*ProviderHealth {
+func (h *HealthHandler) checkProvidersHealth(ctx context.Context, deep bool) map[string]*ProviderHealth {
 	providers := make(map[string]*ProviderHealth)
 
 	// Get available providers from registry
 	availableProviders := provider.GetAvailableProviders()
 
 	for _, providerName := range availableProviders {
-		providers[providerName] = h.checkSingleProviderHealth(ctx, providerName)
+		providers[providerName] = h.checkSingleProviderHealth(ctx, providerName, deep)
 	}
 
 	return providers
 }
 
 // checkSingleProviderHealth checks health of a single provider
-func (h *HealthHandler) checkSingleProviderHealth(ctx context.Context, providerName string) *ProviderHealth {
+func (h *HealthHandler) checkSingleProviderHealth(ctx context.Context, providerName string, deep bool) *ProviderHealth {
 	health := &ProviderHealth{
 		Configured: true,
 		Available:  true,
@@ -220,7 +229,7 @@ func (h *HealthHandler) checkSingleProviderHealth(ctx context.Context, providerN
 	}
 
 	// Check if provider is registered in the registry
-	_, err := provider.Get(providerName)
+	providerFactory, err := provider.Get(providerName)
 	if err != nil {
 		health.Status = "not_available"
 		health.Available = false
@@ -231,6 +240,15 @@ func (h *HealthHandler) checkSingleProviderHealth(ctx context.Context, providerN
 
 	// Since provider is registered, it's available
 	start := time.Now()
+	if deep {
+		if err := h.probeProvider(ctx, providerFactory().HealthCheckEndpoint()); err != nil {
+			health.Status = "degraded"
+			health.Available = false
+			health.Error = err.Error()
+			health.ResponseTime = fmt.Sprintf("%.0fms", float64(time.Since(start).Nanoseconds())/1e6)
+			return health
+		}
+	}
 	responseTime := time.Since(start)
 	health.ResponseTime = fmt.Sprintf("%.0fms", float64(responseTime.Nanoseconds())/1e6)
 	health.Status = "healthy"
@@ -252,6 +270,30 @@ func (h *HealthHandler) checkSingleProviderHealth(ctx context.Context, providerN
 	return health
 }
 
+// probeProvider sends a HEAD request to a provider's health check endpoint. Any HTTP response,
+// including 4xx for the missing credentials, shows the provider is reachable.
+func (h *HealthHandler) probeProvider(ctx context.Context, endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("provider declares no health check endpoint")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("invalid health check endpoint: %w", err)
+	}
+
+	resp, err := h.probeClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("provider unreachable: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("provider returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // checkSystemHealth checks system resource health
 func (h *HealthHandler) checkSystemHealth() *SystemHealth {
 	var memStats runtime.MemStats
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"context"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -157,3 +159,54 @@ func BenchmarkHealthCheck(b *testing.B) {
 		handler.CheckHealth(w, req)
 	}
 }
+
+// healthProbeTestProvider declares a health check endpoint on a local test server
+type healthProbeTestProvider struct {
+	provider.PaymentProvider
+	endpoint string
+}
+
+func (p *healthProbeTestProvider) HealthCheckEndpoint() string { return p.endpoint }
+
+func TestHealthHandler_DeepProbeUsesDeclaredEndpoint(t *testing.T) {
+	var probedMethod, probedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probedMethod, probedPath = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusUnauthorized) // reachable, credentials missing
+	}))
+	defer server.Close()
+
+	provider.Register("healthprobetest", func() provider.PaymentProvider {
+		return &healthProbeTestProvider{endpoint: server.URL + "/api/ping"}
+	})
+	handler := NewHealthHandler(nil, nil, nil, nil)
+
+	health := handler.checkSingleProviderHealth(context.Background(), "healthprobetest", false)
+	if health.Status != "healthy" || probedPath != "" {
+		t.Fatalf("Expected shallow check without a provider request, got status %s and path %q", health.Status, probedPath)
+	}
+
+	health = handler.checkSingleProviderHealth(context.Background(), "healthprobetest", true)
+	if health.Status != "healthy" || !health.Available {
+		t.Errorf("Expected healthy provider, got %+v", health)
+	}
+	if probedMethod != http.MethodHead || probedPath != "/api/ping" {
+		t.Errorf("Expected HEAD /api/ping, got %s %s", probedMethod, probedPath)
+	}
+}
+
+func TestHealthHandler_DeepProbeUnreachableProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	endpoint := server.URL + "/api/ping"
+	server.Close()
+
+	provider.Register("healthprobedown", func() provider.PaymentProvider {
+		return &healthProbeTestProvider{endpoint: endpoint}
+	})
+	handler := NewHealthHandler(nil, nil, nil, nil)
+
+	health := handler.checkSingleProviderHealth(context.Background(), "healthprobedown", true)
+	if health.Status != "degraded" || health.Available || health.Error == "" {
+		t.Errorf("Expected unreachable provider to be degraded, got %+v", health)
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+const (
+	// oidcStateCookie keeps the signed state of a login until the IdP redirects back
+	oidcStateCookie = "gopay_oidc_state"
+	oidcCookiePath  = "/v1/auth/oidc"
+
+	// oidcLoginPage receives the outcome of a login in its URL fragment, which browsers do not
+	// send to servers or in Referer headers
+	oidcLoginPage = "/login"
+)
+
+// OIDCHandler signs dashboard users in at the corporate IdP and issues them a GoPay JWT, like
+// a password login does. A nil service means OIDC is not configured.
+type OIDCHandler struct {
+	oidc          *auth.OIDCService
+	tenantService *auth.TenantService
+	roleService   *auth.RoleService
+	jwtService    *auth.JWTService
+}
+
+// NewOIDCHandler creates a new OIDC handler
+func NewOIDCHandler(oidc *auth.OIDCService, tenantService *auth.TenantService, roleService *auth.RoleService, jwtService *auth.JWTService) *OIDCHandler {
+	return &OIDCHandler{oidc: oidc, tenantService: tenantService, roleService: roleService, jwtService: jwtService}
+}
+
+// OIDCStatusResponse tells the login page whether to offer single sign-on
+type OIDCStatusResponse struct {
+	Enabled  bool   `json:"enabled"`
+	LoginURL string `json:"login_url,omitempty"`
+}
+
+// Status handles GET /v1/auth/oidc
+func (h *OIDCHandler) Status(w http.ResponseWriter, r *http.Request) {
+	status := OIDCStatusResponse{Enabled: h.oidc != nil}
+	if status.Enabled {
+		status.LoginURL = oidcCookiePath + "/login"
+	}
+	response.Success(w, http.StatusOK, "OIDC status", status)
+}
+
+// Login handles GET /v1/auth/oidc/login by redirecting to the IdP
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		response.Error(w, http.StatusNotFound, "OIDC login is not configured", nil)
+		return
+	}
+
+	authURL, state, err := h.oidc.Begin()
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to start OIDC login", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		// Lax lets the cookie ride along the top-level redirect back from the IdP
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles GET /v1/auth/oidc/callback, where the IdP redirects after the login. The
+// dashboard login page gets the GoPay JWT or the error in its URL fragment.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		response.Error(w, http.StatusNotFound, "OIDC login is not configured", nil)
+		return
+	}
+
+	// A state is used once, whatever the outcome
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r)})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		redirectOIDCError(w, r, "Single sign-on was cancelled or denied")
+		return
+	}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		redirectOIDCError(w, r, "Single sign-on session expired, please try again")
+		return
+	}
+
+	identity, err := h.oidc.Complete(r.Context(), query.Get("code"), query.Get("state"), cookie.Value)
+	switch {
+	case errors.Is(err, auth.ErrOIDCStateInvalid):
+		redirectOIDCError(w, r, "Single sign-on session expired, please try again")
+		return
+	case errors.Is(err, auth.ErrOIDCNoTenant):
+		redirectOIDCError(w, r, "Your account is not assigned to a GoPay tenant")
+		return
+	case errors.Is(err, auth.ErrOIDCNoRole):
+		redirectOIDCError(w, r, "Your account has no GoPay role")
+		return
+	case err != nil:
+		redirectOIDCError(w, r, "Single sign-on failed")
+		return
+	}
+
+	tenantID, _ := strconv.Atoi(identity.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		redirectOIDCError(w, r, "Your account is not assigned to a GoPay tenant")
+		return
+	}
+
+	// Users without a recorded role are owners, so the IdP's role must be in place first
+	if err := h.roleService.Sync(r.Context(), tenantID, identity.Username, identity.Role); err != nil {
+		redirectOIDCError(w, r, "Failed to assign your GoPay role")
+		return
+	}
+
+	token, err := h.jwtService.StartSession(r.Context(), identity.TenantID, identity.Username, sessionClient(r))
+	if err != nil {
+		redirectOIDCError(w, r, "Failed to generate authentication token")
+		return
+	}
+
+	fragment := url.Values{"token": {token}, "tenant_id": {identity.TenantID}, "username": {identity.Username}}
+	http.Redirect(w, r, oidcLoginPage+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func redirectOIDCError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, oidcLoginPage+"#"+url.Values{"oidc_error": {message}}.Encode(), http.StatusFound)
+}
+
+// isHTTPS reports whether the client reached us over HTTPS, also behind a TLS-terminating proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOIDCHandler_Disabled(t *testing.T) {
+	h := NewOIDCHandler(nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.Status(rec, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Data OIDCStatusResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Enabled || body.Data.LoginURL != "" {
+		t.Fatalf("unexpected status %+v", body.Data)
+	}
+
+	for name, handle := range map[string]http.HandlerFunc{"login": h.Login, "callback": h.Callback} {
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/"+name, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s code = %d, want %d", name, rec.Code, http.StatusNotFound)
+		}
+	}
+}
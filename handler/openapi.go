@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/openapi"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Query parameters shared by the operations below
+var (
+	environmentParam = openapi.Parameter{Name: "environment", Description: "sandbox (default) or production"}
+	amountUnitParam  = openapi.Parameter{Name: "amountUnit", Description: "minor to return amounts in minor units, like the X-Amount-Unit header"}
+)
+
+// OpenAPIOperations describes the request and response bodies of the handlers, keyed by
+// "METHOD /path" as routed. The OpenAPI document is generated from the routes; a route
+// without an entry here is documented from its path and handler name only. Keep an entry
+// next to a handler whose body or response type changes.
+func OpenAPIOperations() map[string]openapi.Operation {
+	return map[string]openapi.Operation{
+		// Authentication
+		"POST /v1/auth/login":    {Summary: "Log in", Request: LoginRequest{}, Response: auth.LoginResponse{}},
+		"POST /v1/auth/register": {Summary: "Register the first tenant", Request: RegisterRequest{}, Response: LoginResponse{}, Status: http.StatusCreated},
+		"POST /v1/auth/refresh":  {Summary: "Refresh a token", Request: RefreshTokenRequest{}, Response: auth.TokenPair{}},
+		"POST /v1/auth/revoke":   {Summary: "Revoke sessions", Request: RevokeRequest{}},
+		"GET /v1/auth/oidc":      {Summary: "Get whether single sign-on is enabled", Response: OIDCStatusResponse{}},
+		"GET /v1/auth/oidc/login": {
+			Summary: "Start a single sign-on login", Description: "Redirects to the OIDC identity provider.", Status: http.StatusFound,
+		},
+		"GET /v1/auth/oidc/callback": {
+			Summary:     "Complete a single sign-on login",
+			Description: "Redirects to /login with the GoPay token, or oidc_error, in the URL fragment.",
+			Status:      http.StatusFound,
+		},
+		"POST /v1/auth/create-tenant": {
+			Summary: "Create a tenant (admin)", Request: CreateTenantRequest{}, Status: http.StatusCreated,
+		},
+		"POST /v1/auth/change-password": {Summary: "Change a password", Request: ChangePasswordRequest{}},
+		"GET /v1/auth/sessions": {
+			Summary: "List active sessions", Description: "The admin lists another tenant's sessions with ?tenant_id=.", Response: []SessionResponse{},
+		},
+		"DELETE /v1/auth/sessions/{sessionID}": {
+			Summary: "Revoke a session", Description: "The admin revokes another tenant's session with ?tenant_id=.",
+		},
+		"POST /v1/auth/api-keys": {
+			Summary: "Create an API key", Request: CreateAPIKeyRequest{}, Response: CreatedAPIKeyResponse{}, Status: http.StatusCreated,
+		},
+		"GET /v1/auth/api-keys": {Summary: "List API keys", Response: []auth.APIKey{}},
+		"POST /v1/auth/api-keys/{keyID}/rotate": {
+			Summary: "Rotate an API key", Response: CreatedAPIKeyResponse{}, Status: http.StatusCreated,
+		},
+		"GET /v1/auth/roles":            {Summary: "List role assignments", Response: []auth.UserRole{}},
+		"PUT /v1/auth/roles/{username}": {Summary: "Assign a role to a user", Request: AssignRoleRequest{}, Response: auth.UserRole{}},
+
+		// Users of a tenant
+		"GET /v1/tenants/{tenantID}/users": {Summary: "List the users of a tenant", Response: []auth.TenantUser{}},
+		"POST /v1/tenants/{tenantID}/users": {
+			Summary: "Add a user to a tenant", Request: auth.CreateUserRequest{}, Response: auth.TenantUser{}, Status: http.StatusCreated,
+		},
+		"GET /v1/tenants/{tenantID}/users/{username}": {Summary: "Get a user of a tenant", Response: auth.TenantUser{}},
+		"PUT /v1/tenants/{tenantID}/users/{username}": {
+			Summary:     "Update a user of a tenant",
+			Description: "Changes the role or resets the password; a password reset logs the user out.",
+			Request:     auth.UpdateUserRequest{}, Response: auth.TenantUser{},
+		},
+
+		// Payments
+		"POST /v1/payments/{provider}": {
+			Summary:     "Create a payment",
+			Description: "With async=true the payment is queued and answered with 202 and its job.",
+			Request:     provider.PaymentRequest{},
+			Response:    provider.PaymentResponse{},
+			Query: []openapi.Parameter{environmentParam, amountUnitParam,
+				{Name: "async", Type: "boolean", Description: "Queue the payment and return its job"}},
+		},
+		"POST /v1/payments/{provider}/batch": {
+			Summary:  "Create a batch of payments",
+			Request:  batchPaymentRequest{},
+			Response: provider.BatchPaymentResponse{},
+			Query:    []openapi.Parameter{environmentParam, amountUnitParam},
+		},
+		"GET /v1/payments": {
+			Summary:  "List payments",
+			Response: provider.PaymentPage{},
+			Query: []openapi.Parameter{
+				{Name: "provider"}, {Name: "status"},
+				{Name: "from", Description: "RFC3339 or YYYY-MM-DD"}, {Name: "to", Description: "RFC3339 or YYYY-MM-DD"},
+				{Name: "minAmount", Type: "number"}, {Name: "maxAmount", Type: "number"}, {Name: "customerEmail"},
+				{Name: "limit", Type: "integer"}, {Name: "cursor", Description: "nextCursor of the previous page"},
+			},
+		},
+		"GET /v1/payments/{provider}/{paymentID}": {
+			Summary:  "Get the status of a payment",
+			Response: provider.PaymentResponse{},
+			Query:    []openapi.Parameter{environmentParam, amountUnitParam, {Name: "debug", Type: "boolean", Description: "Include the provider calls"}},
+		},
+		"DELETE /v1/payments/{provider}/{paymentID}": {
+			Summary: "Cancel a payment",
+			Request: struct {
+				Reason string `json:"reason,omitempty"`
+			}{},
+			Response: provider.PaymentResponse{},
+			Query:    []openapi.Parameter{environmentParam},
+		},
+		"POST /v1/payments/{provider}/{paymentID}/capture": {
+			Summary:  "Capture an authorized payment",
+			Request:  provider.CaptureRequest{},
+			Response: provider.PaymentResponse{},
+			Query:    []openapi.Parameter{environmentParam},
+		},
+		"POST /v1/payments/{provider}/refund": {
+			Summary:  "Refund a payment",
+			Request:  provider.RefundRequest{},
+			Response: provider.RefundResponse{},
+			Query:    []openapi.Parameter{environmentParam, amountUnitParam},
+		},
+		"POST /v1/payments/{provider}/installments": {
+			Summary:  "Get installment options",
+			Request:  provider.InstallmentInquireRequest{},
+			Response: provider.InstallmentInquireResponse{},
+			Query:    []openapi.Parameter{environmentParam},
+		},
+		"POST /v1/payments/{provider}/commission": {
+			Summary:  "Get commission rates",
+			Request:  provider.CommissionRequest{},
+			Response: provider.CommissionResponse{},
+			Query:    []openapi.Parameter{environmentParam},
+		},
+		"GET /v1/payments/{provider}/{paymentID}/events":       {Summary: "List lifecycle events of a payment", Response: []provider.PaymentEvent{}},
+		"GET /v1/payments/by-order/{merchantOrderID}/attempts": {Summary: "List attempts of an order", Response: []provider.PaymentAttempt{}},
+		"GET /v1/3ds/{provider}/enrollment/{bin}": {
+			Summary:  "Check 3D Secure enrollment of a card",
+			Response: provider.ThreeDSEnrollmentResponse{},
+			Query:    []openapi.Parameter{environmentParam},
+		},
+		"GET /v1/jobs/{jobID}": {Summary: "Get an async payment job", Response: provider.PaymentJob{}},
+
+		// Saved cards
+		"POST /v1/payments/{provider}/cards/otp/send":     {Summary: "Send a card registration OTP", Request: sendOTPBody{}},
+		"POST /v1/payments/{provider}/cards/otp/validate": {Summary: "Validate a card registration OTP", Request: validateOTPBody{}},
+		"POST /v1/payments/{provider}/cards/register":     {Summary: "Register a card", Request: registerCardBody{}, Response: provider.SavedCard{}},
+		"GET /v1/payments/{provider}/cards":               {Summary: "List saved cards", Response: []provider.SavedCard{}},
+		"POST /v1/payments/{provider}/cards/{cardId}/pay": {Summary: "Pay with a saved card", Request: paySavedCardBody{}, Response: provider.PaymentResponse{}},
+
+		// Subscriptions, payouts and payment links
+		"POST /v1/subscriptions": {
+			Summary: "Create a subscription", Request: createSubscriptionBody{}, Response: provider.Subscription{},
+			Status: http.StatusCreated, Query: []openapi.Parameter{environmentParam},
+		},
+		"GET /v1/subscriptions":                     {Summary: "List subscriptions", Response: []provider.Subscription{}},
+		"GET /v1/subscriptions/{subscriptionID}":    {Summary: "Get a subscription", Response: provider.Subscription{}},
+		"DELETE /v1/subscriptions/{subscriptionID}": {Summary: "Cancel a subscription", Response: provider.Subscription{}},
+		"POST /v1/payouts": {
+			Summary: "Create a payout", Request: createPayoutBody{}, Response: provider.Payout{},
+			Status: http.StatusCreated, Query: []openapi.Parameter{environmentParam},
+		},
+		"GET /v1/payouts":            {Summary: "List payouts", Response: []provider.Payout{}},
+		"GET /v1/payouts/{payoutID}": {Summary: "Get a payout", Response: provider.Payout{}},
+		"POST /v1/payment-links": {
+			Summary: "Create a payment link", Request: createPaymentLinkBody{}, Response: provider.PaymentLink{},
+			Status: http.StatusCreated, Query: []openapi.Parameter{environmentParam},
+		},
+		"GET /v1/payment-links":             {Summary: "List payment links", Response: []provider.PaymentLink{}},
+		"GET /v1/payment-links/{linkID}":    {Summary: "Get a payment link", Response: provider.PaymentLink{}},
+		"DELETE /v1/payment-links/{linkID}": {Summary: "Cancel a payment link", Response: provider.PaymentLink{}},
+
+		// Configuration
+		"POST /v1/config/tenant":         {Summary: "Set the provider configuration of the tenant", Request: SetEnvRequest{}},
+		"GET /v1/config/validate-all":    {Summary: "Validate every tenant configuration (admin)", Response: ConfigValidationReport{}},
+		"GET /v1/config/templates":       {Summary: "List config templates", Response: []config.ConfigTemplate{}},
+		"POST /v1/config/templates":      {Summary: "Save a config template", Request: ConfigTemplateRequest{}, Response: config.ConfigTemplate{}},
+		"POST /v1/config/apply-template": {Summary: "Apply a config template to a tenant", Request: ApplyTemplateRequest{}, Response: ApplyTemplateResult{}},
+
+		// Rate limits
+		"GET /v1/admin/tenants/{tenantID}/rate-limits": {Summary: "Get the rate limits of a tenant (admin)", Response: TenantRateLimitResponse{}},
+		"PUT /v1/admin/tenants/{tenantID}/rate-limits": {
+			Summary: "Set the rate limits of a tenant (admin)", Request: TenantRateLimitRequest{}, Response: TenantRateLimitResponse{},
+		},
+		"DELETE /v1/admin/tenants/{tenantID}/rate-limits": {Summary: "Reset the rate limits of a tenant to the defaults (admin)"},
+
+		// Providers
+		"GET /v1/providers/{provider}/currencies": {Summary: "List the currencies of a provider", Response: CurrenciesResponse{}},
+	}
+}
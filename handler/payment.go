@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,11 +22,18 @@ import (
 // PaymentServiceInterface defines the interface for payment operations
 type PaymentServiceInterface interface {
 	CreatePayment(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error)
+	CreatePaymentBatch(ctx context.Context, environment, providerName string, requests []provider.PaymentRequest, concurrency int) *provider.BatchPaymentResponse
 	GetPaymentStatus(ctx context.Context, environment, providerName string, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error)
 	CancelPayment(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error)
+	CapturePayment(ctx context.Context, environment, providerName string, request provider.CaptureRequest) (*provider.PaymentResponse, error)
 	RefundPayment(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error)
 	GetInstallmentCount(ctx context.Context, environment, providerName string, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error)
 	GetCommission(ctx context.Context, environment, providerName string, request provider.CommissionRequest) (provider.CommissionResponse, error)
+	Check3DSEnrollment(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error)
+	GetOrderAttempts(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error)
+	GetPaymentEvents(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error)
+	WatchPaymentEvents(ctx context.Context, providerName, paymentID string, afterID int64, pollInterval time.Duration) (<-chan provider.PaymentEvent, error)
+	ListPayments(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error)
 	Complete3DPayment(ctx context.Context, providerName, state string, data map[string]string) (*provider.PaymentResponse, error)
 	ValidateWebhook(ctx context.Context, environment, providerName string, data map[string]string, headers map[string]string) (bool, map[string]string, error)
 }
@@ -34,6 +42,9 @@ type PaymentServiceInterface interface {
 type PaymentHandler struct {
 	paymentService PaymentServiceInterface
 	validate       *validator.Validate
+	metadataLimits provider.MetadataLimits
+	batchLimits    provider.BatchPaymentLimits
+	paymentJobs    PaymentJobQueueInterface
 }
 
 // NewPaymentHandler creates a new payment handler
@@ -41,6 +52,8 @@ func NewPaymentHandler(paymentService PaymentServiceInterface, validate *validat
 	return &PaymentHandler{
 		paymentService: paymentService,
 		validate:       validate,
+		metadataLimits: provider.MetadataLimitsFromEnv(),
+		batchLimits:    provider.BatchPaymentLimitsFromEnv(),
 	}
 }
 
@@ -57,12 +70,19 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 	}
 	req.ClientIP = middle.GetClientIP(r)
 	req.ClientUserAgent = r.Header.Get("User-Agent")
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
 
 	// Validate the request
 	if err := h.validate.Struct(req); err != nil {
 		response.Error(w, http.StatusBadRequest, "Validation error", err)
 		return
 	}
+	if err := h.metadataLimits.Validate(req.Metadata); err != nil {
+		response.Error(w, http.StatusBadRequest, "Metadata too large", err)
+		return
+	}
 
 	// Get provider name from URL path parameter (or empty for default)
 	providerName := chi.URLParam(r, "provider")
@@ -72,15 +92,128 @@ func (h *PaymentHandler) ProcessPayment(w http.ResponseWriter, r *http.Request)
 		environment = "sandbox"
 	}
 
+	// In async mode the payment is processed by a worker and polled as a job
+	if r.URL.Query().Get("async") == "true" {
+		h.processPaymentAsync(w, r, environment, providerName, req)
+		return
+	}
+
 	// Process the payment
 	resp, err := h.paymentService.CreatePayment(ctx, environment, providerName, req)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Payment failed", err)
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, provider.ErrUnsupportedCurrency):
+			response.Error(w, http.StatusBadRequest, "Unsupported currency", err)
+		case errors.Is(err, provider.ErrInvalidCardNumber):
+			response.Error(w, http.StatusBadRequest, "Invalid card number", err)
+		case errors.Is(err, provider.ErrWalletPaymentInvalid):
+			response.Error(w, http.StatusBadRequest, "Invalid wallet payment", err)
+		case errors.Is(err, provider.ErrWalletPaymentUnsupported):
+			response.Error(w, http.StatusBadRequest, "Provider does not support this wallet", err)
+		case errors.Is(err, provider.ErrBNPLPaymentInvalid):
+			response.Error(w, http.StatusBadRequest, "Invalid BNPL payment", err)
+		case errors.Is(err, provider.ErrBNPLUnsupported):
+			response.Error(w, http.StatusBadRequest, "Provider does not support buy now pay later", err)
+		case errors.Is(err, provider.ErrPIXPaymentInvalid):
+			response.Error(w, http.StatusBadRequest, "Invalid PIX payment", err)
+		case errors.Is(err, provider.ErrPIXUnsupported):
+			response.Error(w, http.StatusBadRequest, "Provider does not support PIX", err)
+		case errors.Is(err, provider.ErrAutoCaptureDelayInvalid):
+			response.Error(w, http.StatusBadRequest, "Invalid auto-capture delay", err)
+		case errors.Is(err, provider.ErrCaptureUnsupported):
+			response.Error(w, http.StatusBadRequest, "Provider does not support authorize and capture", err)
+		case errors.Is(err, provider.ErrMetadataTooLarge):
+			response.Error(w, http.StatusBadRequest, "Metadata too large", err)
+		case errors.Is(err, provider.ErrSurchargeNotAllowed):
+			response.Error(w, http.StatusBadRequest, "Surcharge not allowed", err)
+		case errors.Is(err, provider.ErrInstallmentNotAllowed):
+			response.Error(w, http.StatusBadRequest, "Installments not allowed for this card", err)
+		case errors.Is(err, provider.ErrBasketTotalMismatch):
+			response.Error(w, http.StatusBadRequest, "Basket total does not match amount", err)
+		case errors.Is(err, provider.ErrProviderWeightsNotConfigured):
+			response.Error(w, http.StatusBadRequest, "Provider weights not configured", err)
+		case errors.Is(err, provider.ErrNoProviderAvailable):
+			response.Error(w, http.StatusServiceUnavailable, "No provider available", err)
+		case errors.Is(err, provider.ErrPaymentInProgress):
+			response.Error(w, http.StatusConflict, "Payment is already in progress", err)
+		case errors.Is(err, provider.ErrPaymentIdempotencyKeyReused):
+			response.Error(w, http.StatusUnprocessableEntity, "Idempotency key reused", err)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Payment failed", err)
+		}
 		return
 	}
 
+	if resp.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+
 	// Return response
-	response.Return(w, http.StatusOK, resp.Success, "Payment processed", resp)
+	response.ReturnInUnit(w, http.StatusOK, resp.Success, "Payment processed", resp, response.RequestedAmountUnit(r))
+}
+
+// batchPaymentRequest is the body of a batch payment request
+type batchPaymentRequest struct {
+	Payments []provider.PaymentRequest `json:"payments"`
+}
+
+// ProcessPaymentBatch handles batch payment requests. Every payment is validated before any is
+// processed; the batch is then processed with bounded parallelism and the result of each payment
+// is returned with a summary. Payments use their own idempotencyKey; the Idempotency-Key header
+// does not apply to a batch.
+func (h *PaymentHandler) ProcessPaymentBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	if err := h.batchLimits.Validate(len(req.Payments)); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid payment batch", err)
+		return
+	}
+
+	clientIP, userAgent := middle.GetClientIP(r), r.Header.Get("User-Agent")
+	for i := range req.Payments {
+		payment := &req.Payments[i]
+		payment.ClientIP = clientIP
+		payment.ClientUserAgent = userAgent
+		if err := h.validate.Struct(payment); err != nil {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Validation error in payment %d", i), err)
+			return
+		}
+		if err := h.metadataLimits.Validate(payment.Metadata); err != nil {
+			response.Error(w, http.StatusBadRequest, fmt.Sprintf("Metadata too large in payment %d", i), err)
+			return
+		}
+	}
+
+	// Each round of concurrent payments gets the time of a single payment
+	rounds := (len(req.Payments) + h.batchLimits.Concurrency - 1) / h.batchLimits.Concurrency
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(rounds)*30*time.Second)
+	defer cancel()
+
+	providerName := chi.URLParam(r, "provider")
+	environment := r.URL.Query().Get("environment")
+	if environment != "production" {
+		environment = "sandbox"
+	}
+
+	resp := h.paymentService.CreatePaymentBatch(ctx, environment, providerName, req.Payments, h.batchLimits.Concurrency)
+	response.ReturnInUnit(w, http.StatusOK, resp.Summary.Failed == 0, "Payment batch processed", resp, response.RequestedAmountUnit(r))
+}
+
+// writeProviderNotConfigured answers a request for a provider the tenant has not configured
+// with the config fields to set. It reports false for any other error.
+func writeProviderNotConfigured(w http.ResponseWriter, err error) bool {
+	var notConfigured *provider.ProviderNotConfiguredError
+	if !errors.As(err, &notConfigured) {
+		return false
+	}
+	response.ErrorWithData(w, http.StatusBadRequest, "Provider not configured", err, notConfigured)
+	return true
 }
 
 // GetPaymentStatus handles payment status requests
@@ -103,16 +236,23 @@ func (h *PaymentHandler) GetPaymentStatus(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get payment status
+	// debug=true adds the provider round-trip count and timing from the logs
+	includeProviderCalls, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+
 	resp, err := h.paymentService.GetPaymentStatus(ctx, environment, providerName, provider.GetPaymentStatusRequest{
-		PaymentID: paymentID,
+		PaymentID:            paymentID,
+		IncludeProviderCalls: includeProviderCalls,
 	})
 	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to get payment status", err)
 		return
 	}
 
 	// Return response
-	response.Return(w, http.StatusOK, resp.Success, resp.Message, resp)
+	response.ReturnInUnit(w, http.StatusOK, resp.Success, resp.Message, resp, response.RequestedAmountUnit(r))
 }
 
 // CancelPayment handles payment cancellation requests
@@ -149,14 +289,94 @@ func (h *PaymentHandler) CancelPayment(w http.ResponseWriter, r *http.Request) {
 		Reason:    req.Reason,
 	})
 	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
+		// The response carries the machine-readable reason in errorCode
+		switch {
+		case errors.Is(err, provider.ErrAlreadyCaptured), errors.Is(err, provider.ErrAlreadyCancelled):
+			response.ErrorWithData(w, http.StatusConflict, "Payment cannot be cancelled", err, resp)
+			return
+		case errors.Is(err, provider.ErrPaymentNotFound):
+			response.ErrorWithData(w, http.StatusNotFound, "Payment not found", err, resp)
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to cancel payment", err)
 		return
 	}
 
 	// Return response
-	response.Return(w, http.StatusOK, resp.Success, resp.Message, resp)
+	response.ReturnInUnit(w, http.StatusOK, resp.Success, resp.Message, resp, response.RequestedAmountUnit(r))
 }
 
+// CapturePayment captures a payment authorized with paymentType "auth". The body may give a
+// smaller amount to capture; without it the full authorized amount is captured.
+func (h *PaymentHandler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	providerName := chi.URLParam(r, "provider")
+	paymentID := chi.URLParam(r, "paymentID")
+	if paymentID == "" {
+		response.Error(w, http.StatusBadRequest, "Missing payment ID", nil)
+		return
+	}
+
+	environment := r.URL.Query().Get("environment")
+	if environment != "production" {
+		environment = "sandbox"
+	}
+
+	var req struct {
+		Amount   float64 `json:"amount" validate:"gte=0"`
+		Currency string  `json:"currency"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+			return
+		}
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation error", err)
+		return
+	}
+
+	resp, err := h.paymentService.CapturePayment(ctx, environment, providerName, provider.CaptureRequest{
+		PaymentID: paymentID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+	})
+	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
+		var exceeds *provider.CaptureExceedsAuthorizedError
+		switch {
+		case errors.Is(err, provider.ErrCaptureUnsupported):
+			response.Error(w, http.StatusBadRequest, "Provider does not support authorize and capture", err)
+			return
+		case errors.As(err, &exceeds):
+			response.ErrorWithData(w, http.StatusBadRequest, "Capture amount exceeds the authorized amount", err, exceeds)
+			return
+		case errors.Is(err, provider.ErrAlreadyCaptured), errors.Is(err, provider.ErrAlreadyCancelled):
+			response.ErrorWithData(w, http.StatusConflict, "Payment cannot be captured", err, resp)
+			return
+		case errors.Is(err, provider.ErrPaymentNotFound):
+			response.ErrorWithData(w, http.StatusNotFound, "Payment not found", err, resp)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to capture payment", err)
+		return
+	}
+
+	response.ReturnInUnit(w, http.StatusOK, resp.Success, resp.Message, resp, response.RequestedAmountUnit(r))
+}
+
+// IdempotentReplayedHeader is set on a payment or refund response that replays the stored
+// result of an earlier request with the same idempotency key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
 // RefundPayment handles payment refund requests
 func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
@@ -176,6 +396,10 @@ func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
 		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
 		return
 	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	middle.SetAuditResource(r.Context(), req.PaymentID)
 
 	// Validate the request
 	if err := h.validate.Struct(req); err != nil {
@@ -186,10 +410,28 @@ func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
 	// Process refund
 	resp, err := h.paymentService.RefundPayment(ctx, environment, providerName, req)
 	if err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to refund payment", err)
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, provider.ErrRefundInProgress):
+			response.Error(w, http.StatusConflict, "Refund is already in progress", err)
+		case errors.Is(err, provider.ErrIdempotencyKeyReused):
+			response.Error(w, http.StatusUnprocessableEntity, "Idempotency key reused", err)
+		case errors.Is(err, provider.ErrRefundExceedsRefundable), errors.Is(err, provider.ErrRefundAmountTooSmall):
+			response.Error(w, http.StatusBadRequest, "Invalid refund amount", err)
+		case errors.Is(err, provider.ErrRefundWindowClosed):
+			response.Error(w, http.StatusBadRequest, "Refund window closed", err)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to refund payment", err)
+		}
 		return
 	}
 
+	if resp.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+
 	// Return response
 	response.Return(w, http.StatusOK, resp.Success, resp.Message, resp)
 }
@@ -225,6 +467,9 @@ func (h *PaymentHandler) GetInstallments(w http.ResponseWriter, r *http.Request)
 	// Get installment count
 	resp, err := h.paymentService.GetInstallmentCount(ctx, environment, providerName, req)
 	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to get installment count", err)
 		return
 	}
@@ -258,6 +503,9 @@ func (h *PaymentHandler) GetCommission(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.paymentService.GetCommission(ctx, environment, providerName, req)
 	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
 		response.Error(w, http.StatusInternalServerError, "Failed to get commission", err)
 		return
 	}
@@ -265,6 +513,181 @@ func (h *PaymentHandler) GetCommission(w http.ResponseWriter, r *http.Request) {
 	response.Return(w, http.StatusOK, resp.Success, resp.Message, resp)
 }
 
+// Check3DSEnrollment handles 3DS enrollment pre-checks for a card BIN
+func (h *PaymentHandler) Check3DSEnrollment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Get provider and BIN from URL path parameters
+	providerName := chi.URLParam(r, "provider")
+	if providerName == "" {
+		response.Error(w, http.StatusBadRequest, "Provider parameter is required", nil)
+		return
+	}
+	bin := chi.URLParam(r, "bin")
+
+	environment := r.URL.Query().Get("environment")
+	if environment != "production" {
+		environment = "sandbox"
+	}
+
+	resp, err := h.paymentService.Check3DSEnrollment(ctx, environment, providerName, bin)
+	if err != nil {
+		if writeProviderNotConfigured(w, err) {
+			return
+		}
+		if errors.Is(err, provider.ErrInvalidBIN) {
+			response.Error(w, http.StatusBadRequest, "Invalid BIN", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to check 3DS enrollment", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "3DS enrollment checked", resp)
+}
+
+// GetOrderAttempts returns the payment attempts of a merchant order, oldest first. The order
+// is the referenceId (or conversationId) the payments were requested with.
+func (h *PaymentHandler) GetOrderAttempts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	merchantOrderID := strings.TrimSpace(chi.URLParam(r, "merchantOrderID"))
+	if merchantOrderID == "" {
+		response.Error(w, http.StatusBadRequest, "Missing merchant order ID", nil)
+		return
+	}
+
+	attempts, err := h.paymentService.GetOrderAttempts(ctx, merchantOrderID)
+	if err != nil {
+		if errors.Is(err, provider.ErrOrderAttemptsUnavailable) {
+			response.Error(w, http.StatusNotImplemented, "Order attempt history is not available", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get order attempts", err)
+		return
+	}
+	if len(attempts) == 0 {
+		response.Error(w, http.StatusNotFound, "No payment attempts found for this order", nil)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Order attempts retrieved", attempts)
+}
+
+// ListPayments lists the tenant's payments, newest first, from the payment logs.
+// Query params: provider, status, from and to (RFC3339 or YYYY-MM-DD), minAmount, maxAmount,
+// customerEmail, limit and cursor, the nextCursor of the previous page.
+func (h *PaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	filter, err := parsePaymentListFilter(r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	page, err := h.paymentService.ListPayments(ctx, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrPaymentListFilterInvalid):
+			response.Error(w, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, provider.ErrPaymentListUnavailable):
+			response.Error(w, http.StatusNotImplemented, "Payment listing is not available", err)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to list payments", err)
+		}
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Payments retrieved", page)
+}
+
+// parsePaymentListFilter reads the filters, page size and cursor of a payment listing
+func parsePaymentListFilter(r *http.Request) (provider.PaymentListFilter, error) {
+	query := r.URL.Query()
+	filter := provider.PaymentListFilter{
+		Provider:      strings.TrimSpace(query.Get("provider")),
+		Status:        strings.ToLower(strings.TrimSpace(query.Get("status"))),
+		CustomerEmail: strings.TrimSpace(query.Get("customerEmail")),
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := parseAuditTime(value, false)
+		if err != nil {
+			return filter, errors.New("invalid from parameter")
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := parseAuditTime(value, true)
+		if err != nil {
+			return filter, errors.New("invalid to parameter")
+		}
+		filter.To = to
+	}
+	amounts := []struct {
+		name   string
+		target *float64
+	}{{"minAmount", &filter.MinAmount}, {"maxAmount", &filter.MaxAmount}}
+	for _, amount := range amounts {
+		if value := query.Get(amount.name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 {
+				return filter, fmt.Errorf("invalid %s parameter", amount.name)
+			}
+			*amount.target = parsed
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("invalid limit parameter")
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := provider.DecodePaymentCursor(value)
+		if err != nil {
+			return filter, errors.New("invalid cursor parameter")
+		}
+		filter.After = cursor
+	}
+	return filter, nil
+}
+
+// GetPaymentEvents returns the lifecycle events of a payment, oldest first: creation, 3D Secure,
+// captures, refunds and cancellation, failed attempts included
+func (h *PaymentHandler) GetPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	providerName := strings.ToLower(chi.URLParam(r, "provider"))
+	paymentID := strings.TrimSpace(chi.URLParam(r, "paymentID"))
+	if providerName == "" || paymentID == "" {
+		response.Error(w, http.StatusBadRequest, "Missing provider or payment ID", nil)
+		return
+	}
+
+	events, err := h.paymentService.GetPaymentEvents(ctx, providerName, paymentID)
+	if err != nil {
+		if errors.Is(err, provider.ErrPaymentEventsUnavailable) {
+			response.Error(w, http.StatusNotImplemented, "Payment event history is not available", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get payment events", err)
+		return
+	}
+	if len(events) == 0 {
+		response.Error(w, http.StatusNotFound, "No events found for this payment", nil)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Payment events retrieved", events)
+}
+
 // Enhanced callback URL parsing and redirect logic
 func (h *PaymentHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
@@ -321,18 +744,23 @@ func (h *PaymentHandler) HandleCallback(w http.ResponseWriter, r *http.Request)
 	paymentResp, err := h.paymentService.Complete3DPayment(ctx, providerName, state, callbackData)
 
 	if err != nil {
+		errorCode, statusCode := "500", http.StatusInternalServerError
+		if errors.Is(err, provider.Err3DSessionExpired) {
+			errorCode, statusCode = provider.ErrorCode3DSessionExpired, http.StatusGone
+		}
+
 		// Check if response and RedirectURL are available
 		if paymentResp != nil && paymentResp.RedirectURL != "" {
 			h.postRedirect(w, paymentResp.RedirectURL, map[string]string{
 				"success":   "false",
 				"status":    "failed",
-				"errorCode": "500",
+				"errorCode": errorCode,
 				"message":   err.Error(),
 				"sessionId": paymentResp.SessionID,
 			})
 		} else {
 			// Fallback: return JSON error response if no redirect URL
-			response.Error(w, http.StatusInternalServerError, "Payment callback failed: "+err.Error(), nil)
+			response.Error(w, statusCode, "Payment callback failed: "+err.Error(), nil)
 		}
 		return
 	}
@@ -403,13 +831,40 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject webhooks from outside the provider's published source ranges before reading them.
+	// RemoteAddr is rewritten from spoofable proxy headers by the RealIP middleware, so the
+	// source is the connection's peer, or the client a trusted proxy forwarded for.
+	sourceIP := middle.TrustedClientIP(r)
+	if !provider.WebhookSourceAllowed(providerName, sourceIP) {
+		h.logWebhookError(providerName, "source_ip_not_allowed", fmt.Errorf("webhook from %s is not in the allowlist", sourceIP), nil)
+		response.Error(w, http.StatusForbidden, "Webhook source IP not allowed", nil)
+		return
+	}
+
 	environment := r.URL.Query().Get("environment")
 	if environment != "production" {
 		environment = "sandbox"
 	}
 
+	// A tenant can pin the client certificates of a provider's webhooks (mTLS). The tenant is
+	// taken from the tenantId the provider was given in the webhook URL.
+	if tenantID, err := strconv.Atoi(r.URL.Query().Get("tenantId")); err == nil {
+		pins, err := provider.WebhookClientCertPins(tenantID, providerName, environment)
+		if err != nil {
+			h.logWebhookError(providerName, "validation_failed", err, nil)
+			response.Error(w, http.StatusBadRequest, "Webhook validation failed", err)
+			return
+		}
+		if len(pins) > 0 && !middle.ClientCertificatePinned(r, pins) {
+			h.logWebhookError(providerName, "client_cert_not_pinned", errors.New("webhook without a pinned client certificate"), nil)
+			response.Error(w, http.StatusForbidden, "Webhook client certificate not allowed", nil)
+			return
+		}
+	}
+
 	// Parse webhook data based on content type
 	var webhookData map[string]string
+	var rawBody []byte
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.Contains(contentType, "application/x-www-form-urlencoded") {
@@ -426,11 +881,18 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	} else {
-		// Parse JSON data
-		if err := json.NewDecoder(r.Body).Decode(&webhookData); err != nil {
+		// Parse JSON data, keeping the body for providers that sign it as sent
+		var err error
+		if rawBody, err = io.ReadAll(r.Body); err != nil {
+			response.Error(w, http.StatusBadRequest, "Invalid JSON webhook data", err)
+			return
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(rawBody, &fields); err != nil {
 			response.Error(w, http.StatusBadRequest, "Invalid JSON webhook data", err)
 			return
 		}
+		webhookData = webhookFields(fields)
 	}
 
 	// Extract headers for validation
@@ -440,6 +902,9 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 			headers[key] = values[0]
 		}
 	}
+	if rawBody != nil {
+		headers[provider.WebhookRawBodyHeader] = string(rawBody)
+	}
 
 	// Validate webhook signature
 	isValid, paymentData, err := h.paymentService.ValidateWebhook(ctx, environment, providerName, webhookData, headers)
@@ -466,6 +931,20 @@ func (h *PaymentHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// webhookFields flattens a JSON webhook into strings. Numbers, booleans and objects are kept
+// as their JSON text (Craftgate sends eventTimestamp as a number), and null becomes empty.
+func webhookFields(fields map[string]json.RawMessage) map[string]string {
+	webhookData := make(map[string]string, len(fields))
+	for key, raw := range fields {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		webhookData[key] = value
+	}
+	return webhookData
+}
+
 // Async webhook processing for better performance
 func (h *PaymentHandler) processWebhookAsync(ctx context.Context, environment, providerName string, paymentData, rawWebhookData map[string]string) {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
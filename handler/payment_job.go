@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// PaymentJobWebhookHeader names the URL that receives an async payment's job once it is finished
+const PaymentJobWebhookHeader = "X-GoPay-Webhook-URL"
+
+// PaymentJobQueueInterface queues payments processed in the background, typically
+// *provider.PaymentJobQueue.
+type PaymentJobQueueInterface interface {
+	Submit(ctx context.Context, environment, providerName string, request provider.PaymentRequest, webhookURL string) (*provider.PaymentJob, error)
+	Get(ctx context.Context, id string) (*provider.PaymentJob, error)
+}
+
+// SetPaymentJobQueue enables the async mode of ProcessPayment
+func (h *PaymentHandler) SetPaymentJobQueue(jobs PaymentJobQueueInterface) {
+	h.paymentJobs = jobs
+}
+
+// processPaymentAsync queues a validated payment request and answers with its job
+func (h *PaymentHandler) processPaymentAsync(w http.ResponseWriter, r *http.Request, environment, providerName string, req provider.PaymentRequest) {
+	if h.paymentJobs == nil {
+		response.Error(w, http.StatusNotImplemented, "Async payments are not available", nil)
+		return
+	}
+
+	job, err := h.paymentJobs.Submit(r.Context(), environment, providerName, req, r.Header.Get(PaymentJobWebhookHeader))
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrPaymentJobInvalid):
+			response.Error(w, http.StatusBadRequest, "Invalid payment job", err)
+		case errors.Is(err, provider.ErrPaymentJobQueueFull):
+			response.Error(w, http.StatusServiceUnavailable, "Payment job queue is full", err)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to queue payment", err)
+		}
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	response.Success(w, http.StatusAccepted, "Payment queued", job)
+}
+
+// PaymentJobHandler handles the status of payments processed in async mode
+type PaymentJobHandler struct {
+	jobs PaymentJobQueueInterface
+}
+
+// NewPaymentJobHandler creates a new payment job handler
+func NewPaymentJobHandler(jobs PaymentJobQueueInterface) *PaymentJobHandler {
+	return &PaymentJobHandler{jobs: jobs}
+}
+
+// GetJob handles GET /jobs/{jobID}. The payment response is part of the job once it is completed.
+func (h *PaymentJobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "jobID")
+	if jobID == "" {
+		response.Error(w, http.StatusBadRequest, "Job ID is required", nil)
+		return
+	}
+
+	job, err := h.jobs.Get(r.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, provider.ErrPaymentJobNotFound) {
+			response.Error(w, http.StatusNotFound, "Payment job not found", err)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to get payment job", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payment job retrieved", job)
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// PaymentLinkServiceInterface defines the payment link operations the handler depends on.
+type PaymentLinkServiceInterface interface {
+	CreateLink(ctx context.Context, environment, providerName string, request provider.CreatePaymentLinkRequest) (*provider.PaymentLink, error)
+	GetLink(ctx context.Context, id string) (*provider.PaymentLink, error)
+	ListLinks(ctx context.Context) ([]provider.PaymentLink, error)
+	CancelLink(ctx context.Context, id string) (*provider.PaymentLink, error)
+	CheckoutLink(ctx context.Context, id string) (*provider.PaymentLink, error)
+	PayLink(ctx context.Context, id string, checkout provider.PaymentLinkCheckout) (*provider.PaymentLink, *provider.PaymentResponse, error)
+	CompleteLink(ctx context.Context, id, paymentID string) (*provider.PaymentLink, error)
+}
+
+// PaymentLinkHandler handles the payment link API and the hosted checkout page of the links.
+type PaymentLinkHandler struct {
+	linkService PaymentLinkServiceInterface
+	validate    *validator.Validate
+}
+
+// NewPaymentLinkHandler creates a new payment link handler.
+func NewPaymentLinkHandler(linkService PaymentLinkServiceInterface, validate *validator.Validate) *PaymentLinkHandler {
+	return &PaymentLinkHandler{linkService: linkService, validate: validate}
+}
+
+type createPaymentLinkBody struct {
+	Provider    string     `json:"provider" validate:"required"`
+	Amount      float64    `json:"amount" validate:"required,gt=0"`
+	Currency    string     `json:"currency" validate:"required"`
+	Description string     `json:"description,omitempty" validate:"max=255"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	WebhookURL  string     `json:"webhookUrl,omitempty" validate:"omitempty,url"`
+}
+
+// CreatePaymentLink handles POST /payment-links
+func (h *PaymentLinkHandler) CreatePaymentLink(w http.ResponseWriter, r *http.Request) {
+	var body createPaymentLinkBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	if err := h.validate.Struct(body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation error", err)
+		return
+	}
+
+	link, err := h.linkService.CreateLink(r.Context(), environmentFromRequest(r), strings.ToLower(body.Provider), provider.CreatePaymentLinkRequest{
+		Amount:      body.Amount,
+		Currency:    body.Currency,
+		Description: body.Description,
+		ExpiresAt:   body.ExpiresAt,
+		WebhookURL:  body.WebhookURL,
+	})
+	if err != nil {
+		h.writeServiceError(w, "Failed to create payment link", err)
+		return
+	}
+	middle.SetAuditResource(r.Context(), link.ID)
+	response.Success(w, http.StatusCreated, "Payment link created", link)
+}
+
+// ListPaymentLinks handles GET /payment-links
+func (h *PaymentLinkHandler) ListPaymentLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := h.linkService.ListLinks(r.Context())
+	if err != nil {
+		h.writeServiceError(w, "Failed to list payment links", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payment links retrieved", links)
+}
+
+// GetPaymentLink handles GET /payment-links/{linkID}
+func (h *PaymentLinkHandler) GetPaymentLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.linkService.GetLink(r.Context(), chi.URLParam(r, "linkID"))
+	if err != nil {
+		h.writeServiceError(w, "Failed to get payment link", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payment link retrieved", link)
+}
+
+// CancelPaymentLink handles DELETE /payment-links/{linkID}
+func (h *PaymentLinkHandler) CancelPaymentLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.linkService.CancelLink(r.Context(), chi.URLParam(r, "linkID"))
+	if err != nil {
+		h.writeServiceError(w, "Failed to cancel payment link", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payment link cancelled", link)
+}
+
+// writeServiceError maps payment-link-service errors to appropriate HTTP status codes.
+func (h *PaymentLinkHandler) writeServiceError(w http.ResponseWriter, message string, err error) {
+	if writeProviderNotConfigured(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, provider.ErrPaymentLinkInvalid):
+		response.Error(w, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, provider.ErrPaymentLinkNotFound):
+		response.Error(w, http.StatusNotFound, "Payment link not found", err)
+	case errors.Is(err, provider.ErrPaymentLinkUnavailable):
+		response.Error(w, http.StatusConflict, "Payment link is no longer active", err)
+	default:
+		response.Error(w, http.StatusInternalServerError, message, err)
+	}
+}
+
+// ShowCheckout handles GET /pay/{linkID}, the hosted checkout page of a link
+func (h *PaymentLinkHandler) ShowCheckout(w http.ResponseWriter, r *http.Request) {
+	link, err := h.linkService.CheckoutLink(r.Context(), chi.URLParam(r, "linkID"))
+	h.renderCheckout(w, link, err, "")
+}
+
+// SubmitCheckout handles POST /pay/{linkID}, the card form of the checkout page
+func (h *PaymentLinkHandler) SubmitCheckout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	linkID := chi.URLParam(r, "linkID")
+	if err := r.ParseForm(); err != nil {
+		link, lookupErr := h.linkService.CheckoutLink(ctx, linkID)
+		h.renderCheckout(w, link, lookupErr, "The form could not be read, please try again.")
+		return
+	}
+
+	link, resp, err := h.linkService.PayLink(ctx, linkID, provider.PaymentLinkCheckout{
+		Customer: provider.Customer{
+			Name:        strings.TrimSpace(r.PostFormValue("name")),
+			Surname:     strings.TrimSpace(r.PostFormValue("surname")),
+			Email:       strings.TrimSpace(r.PostFormValue("email")),
+			PhoneNumber: strings.TrimSpace(r.PostFormValue("phone")),
+		},
+		CardInfo: provider.CardInfo{
+			CardHolderName: strings.TrimSpace(r.PostFormValue("cardHolderName")),
+			CardNumber:     strings.ReplaceAll(r.PostFormValue("cardNumber"), " ", ""),
+			ExpireMonth:    strings.TrimSpace(r.PostFormValue("expireMonth")),
+			ExpireYear:     strings.TrimSpace(r.PostFormValue("expireYear")),
+			CVV:            strings.TrimSpace(r.PostFormValue("cvv")),
+		},
+		ClientIP:        middle.GetClientIP(r),
+		ClientUserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		if link == nil {
+			// Claimed by another attempt, paid or no longer active
+			link, err = h.linkService.CheckoutLink(ctx, linkID)
+			h.renderCheckout(w, link, err, "")
+			return
+		}
+		h.renderCheckout(w, link, nil, "The payment could not be completed, please try again.")
+		return
+	}
+
+	switch {
+	case resp == nil:
+		// Paid by an earlier attempt
+	case resp.HTML != "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(resp.HTML))
+		return
+	case resp.HostedCheckoutURL != "":
+		http.Redirect(w, r, resp.HostedCheckoutURL, http.StatusSeeOther)
+		return
+	case resp.RedirectURL != "" && link.Status == provider.PaymentLinkStatusProcessing:
+		http.Redirect(w, r, resp.RedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	message := ""
+	if link.Status == provider.PaymentLinkStatusActive {
+		message = "The payment was declined"
+		if resp != nil && resp.Message != "" {
+			message += ": " + resp.Message
+		}
+	}
+	h.renderCheckout(w, link, nil, message)
+}
+
+// CompleteCheckout handles /pay/{linkID}/complete, where the customer returns after 3D
+// secure or the provider's page
+func (h *PaymentLinkHandler) CompleteCheckout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	_ = r.ParseForm()
+	link, err := h.linkService.CompleteLink(ctx, chi.URLParam(r, "linkID"), r.FormValue("paymentId"))
+	message := ""
+	if err == nil && link.Status == provider.PaymentLinkStatusActive {
+		message = "The payment was not completed, please try again."
+	}
+	h.renderCheckout(w, link, err, message)
+}
+
+type checkoutPage struct {
+	Link    *provider.PaymentLink
+	Amount  string
+	State   string
+	Message string
+}
+
+func (h *PaymentLinkHandler) renderCheckout(w http.ResponseWriter, link *provider.PaymentLink, err error, message string) {
+	status := http.StatusOK
+	page := checkoutPage{Link: link, Message: message}
+	switch {
+	case errors.Is(err, provider.ErrPaymentLinkNotFound):
+		status, page.State = http.StatusNotFound, "not_found"
+	case err != nil && link == nil:
+		status, page.State, page.Message = http.StatusInternalServerError, "error", "Something went wrong, please try again later."
+	default:
+		page.State = link.Status
+		page.Amount = fmt.Sprintf("%.*f %s", provider.CurrencyExponent(link.Currency), link.Amount, link.Currency)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = checkoutTemplate.Execute(w, page)
+}
+
+var checkoutTemplate = template.Must(template.New("checkout").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Payment</title>
+    <style>
+        body { font-family: -apple-system, sans-serif; background: #f5f5f7; margin: 0; padding: 24px; }
+        main { max-width: 420px; margin: 0 auto; background: #fff; border-radius: 8px; padding: 24px; }
+        .amount { font-size: 28px; font-weight: 600; margin: 8px 0 16px; }
+        label { display: block; font-size: 13px; margin-top: 12px; }
+        input { width: 100%; box-sizing: border-box; padding: 8px; margin-top: 4px; }
+        .row { display: flex; gap: 8px; }
+        button { width: 100%; margin-top: 20px; padding: 12px; font-size: 16px; }
+        .message { color: #b00020; }
+    </style>
+</head>
+<body>
+<main>
+{{- if eq .State "not_found"}}
+    <h1>Payment link not found</h1>
+{{- else if eq .State "error"}}
+    <h1>Payment unavailable</h1>
+    <p class="message">{{.Message}}</p>
+{{- else}}
+    <p>{{.Link.Description}}</p>
+    <div class="amount">{{.Amount}}</div>
+    {{- if eq .State "paid"}}
+    <h1>Payment received</h1>
+    <p>Thank you, your payment has been completed.</p>
+    {{- else if eq .State "expired"}}
+    <h1>This payment link has expired</h1>
+    {{- else if eq .State "cancelled"}}
+    <h1>This payment link has been cancelled</h1>
+    {{- else if eq .State "processing"}}
+    <h1>Payment in progress</h1>
+    <p>A payment for this link is being processed. Please check back in a few minutes.</p>
+    {{- else}}
+    {{- if .Message}}<p class="message">{{.Message}}</p>{{end}}
+    <form method="POST" action="/pay/{{.Link.ID}}">
+        <div class="row">
+            <label>Name<input name="name" required autocomplete="given-name"></label>
+            <label>Surname<input name="surname" required autocomplete="family-name"></label>
+        </div>
+        <label>Email<input name="email" type="email" required autocomplete="email"></label>
+        <label>Phone<input name="phone" type="tel" autocomplete="tel"></label>
+        <label>Name on card<input name="cardHolderName" required autocomplete="cc-name"></label>
+        <label>Card number<input name="cardNumber" required inputmode="numeric" autocomplete="cc-number"></label>
+        <div class="row">
+            <label>Month<input name="expireMonth" required inputmode="numeric" placeholder="MM" autocomplete="cc-exp-month"></label>
+            <label>Year<input name="expireYear" required inputmode="numeric" placeholder="YYYY" autocomplete="cc-exp-year"></label>
+            <label>CVV<input name="cvv" required inputmode="numeric" autocomplete="cc-csc"></label>
+        </div>
+        <button type="submit">Pay {{.Amount}}</button>
+    </form>
+    {{- end}}
+{{- end}}
+</main>
+</body>
+</html>`))
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// paymentStreamPollInterval is how often a stream reads the events recorded by other instances
+	paymentStreamPollInterval = 2 * time.Second
+
+	// paymentStreamKeepAlive is the interval of the comments that keep idle streams open through proxies
+	paymentStreamKeepAlive = 15 * time.Second
+
+	// paymentStreamMaxDuration ends a stream before the request timeout. EventSource clients
+	// reconnect after paymentStreamRetry with Last-Event-ID and miss no event.
+	paymentStreamMaxDuration = 50 * time.Second
+	paymentStreamRetry       = time.Second
+)
+
+// StreamPaymentEvents handles GET /payments/{provider}/{paymentID}/stream. It pushes the
+// lifecycle events of a payment as Server-Sent Events, named after the event type, so a
+// frontend can react to 3D Secure completion without polling GetPaymentStatus. The events
+// after the Last-Event-ID header (or lastEventId query param) are sent first.
+func (h *PaymentHandler) StreamPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	providerName := strings.ToLower(chi.URLParam(r, "provider"))
+	paymentID := strings.TrimSpace(chi.URLParam(r, "paymentID"))
+	if providerName == "" || paymentID == "" {
+		response.Error(w, http.StatusBadRequest, "Missing provider or payment ID", nil)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			response.Error(w, http.StatusBadRequest, "Invalid last event ID", nil)
+			return
+		}
+		afterID = id
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.Error(w, http.StatusInternalServerError, "Streaming is not supported", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), paymentStreamDuration(r.Context()))
+	defer cancel()
+
+	events, err := h.paymentService.WatchPaymentEvents(ctx, providerName, paymentID, afterID, paymentStreamPollInterval)
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrPaymentEventsUnavailable):
+			response.Error(w, http.StatusNotImplemented, "Payment event history is not available", err)
+		case errors.Is(err, provider.ErrPaymentNotFound):
+			response.Error(w, http.StatusNotFound, "No events found for this payment", nil)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to stream payment events", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", paymentStreamRetry.Milliseconds())
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(paymentStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// paymentStreamDuration returns how long a stream may stay open, ending it a few seconds
+// before the request's deadline
+func paymentStreamDuration(ctx context.Context) time.Duration {
+	duration := paymentStreamMaxDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		if untilDeadline := time.Until(deadline) - 5*time.Second; untilDeadline < duration {
+			duration = max(untilDeadline, time.Second)
+		}
+	}
+	return duration
+}
@@ -5,12 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/provider"
@@ -21,11 +24,65 @@ type MockPaymentService struct {
 	CreatePaymentFunc       func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error)
 	GetPaymentStatusFunc    func(ctx context.Context, environment, providerName string, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error)
 	CancelPaymentFunc       func(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error)
+	CapturePaymentFunc      func(ctx context.Context, environment, providerName string, request provider.CaptureRequest) (*provider.PaymentResponse, error)
 	RefundPaymentFunc       func(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error)
 	Complete3DPaymentFunc   func(ctx context.Context, providerName, state string, data map[string]string) (*provider.PaymentResponse, error)
 	ValidateWebhookFunc     func(ctx context.Context, environment, providerName string, data map[string]string, headers map[string]string) (bool, map[string]string, error)
 	GetInstallmentCountFunc func(ctx context.Context, environment, providerName string, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error)
 	GetCommissionFunc       func(ctx context.Context, environment, providerName string, request provider.CommissionRequest) (provider.CommissionResponse, error)
+	Check3DSEnrollmentFunc  func(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error)
+	GetOrderAttemptsFunc    func(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error)
+	GetPaymentEventsFunc    func(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error)
+	ListPaymentsFunc        func(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error)
+	WatchPaymentEventsFunc  func(ctx context.Context, providerName, paymentID string, afterID int64, pollInterval time.Duration) (<-chan provider.PaymentEvent, error)
+}
+
+func (m *MockPaymentService) CreatePaymentBatch(ctx context.Context, environment, providerName string, requests []provider.PaymentRequest, concurrency int) *provider.BatchPaymentResponse {
+	resp := &provider.BatchPaymentResponse{Summary: provider.BatchPaymentSummary{Total: len(requests)}}
+	for i, request := range requests {
+		result := provider.BatchPaymentResult{Index: i}
+		payment, err := m.CreatePayment(ctx, environment, providerName, request)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Payment, result.Success = payment, payment.Success
+		}
+		if result.Success {
+			resp.Summary.Succeeded++
+		} else {
+			resp.Summary.Failed++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp
+}
+
+func (m *MockPaymentService) GetOrderAttempts(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error) {
+	if m.GetOrderAttemptsFunc != nil {
+		return m.GetOrderAttemptsFunc(ctx, merchantOrderID)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentService) GetPaymentEvents(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error) {
+	if m.GetPaymentEventsFunc != nil {
+		return m.GetPaymentEventsFunc(ctx, providerName, paymentID)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentService) WatchPaymentEvents(ctx context.Context, providerName, paymentID string, afterID int64, pollInterval time.Duration) (<-chan provider.PaymentEvent, error) {
+	if m.WatchPaymentEventsFunc != nil {
+		return m.WatchPaymentEventsFunc(ctx, providerName, paymentID, afterID, pollInterval)
+	}
+	return nil, provider.ErrPaymentEventsUnavailable
+}
+
+func (m *MockPaymentService) ListPayments(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error) {
+	if m.ListPaymentsFunc != nil {
+		return m.ListPaymentsFunc(ctx, filter)
+	}
+	return &provider.PaymentPage{Payments: []provider.PaymentSummary{}}, nil
 }
 
 func (m *MockPaymentService) CreatePayment(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
@@ -70,6 +127,19 @@ func (m *MockPaymentService) CancelPayment(ctx context.Context, environment, pro
 	}, nil
 }
 
+func (m *MockPaymentService) CapturePayment(ctx context.Context, environment, providerName string, request provider.CaptureRequest) (*provider.PaymentResponse, error) {
+	if m.CapturePaymentFunc != nil {
+		return m.CapturePaymentFunc(ctx, environment, providerName, request)
+	}
+	return &provider.PaymentResponse{
+		Success:   true,
+		PaymentID: request.PaymentID,
+		Status:    provider.StatusSuccessful,
+		Amount:    request.Amount,
+		Message:   "Payment captured",
+	}, nil
+}
+
 func (m *MockPaymentService) RefundPayment(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
 	if m.RefundPaymentFunc != nil {
 		return m.RefundPaymentFunc(ctx, environment, providerName, request)
@@ -122,6 +192,18 @@ func (m *MockPaymentService) GetCommission(ctx context.Context, environment, pro
 	return provider.CommissionResponse{}, nil
 }
 
+func (m *MockPaymentService) Check3DSEnrollment(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error) {
+	if m.Check3DSEnrollmentFunc != nil {
+		return m.Check3DSEnrollmentFunc(ctx, environment, providerName, bin)
+	}
+	return &provider.ThreeDSEnrollmentResponse{
+		BIN:       bin,
+		Provider:  providerName,
+		Status:    provider.ThreeDSEnrolled,
+		Supported: true,
+	}, nil
+}
+
 func TestNewPaymentHandler(t *testing.T) {
 	mockService := &MockPaymentService{}
 	validator := validator.New()
@@ -192,6 +274,20 @@ func TestPaymentHandler_ProcessPayment(t *testing.T) {
 				return nil, errors.New("payment service error")
 			},
 		},
+		{
+			name: "metadata over limit",
+			requestBody: provider.PaymentRequest{
+				Amount:   100.50,
+				Currency: "TRY",
+				Metadata: map[string]string{"note": strings.Repeat("x", 501)},
+			},
+			environment:    "sandbox",
+			provider:       "iyzico",
+			expectedStatus: 400,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+				return nil, errors.New("payment service must not be called")
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -226,6 +322,54 @@ func TestPaymentHandler_ProcessPayment(t *testing.T) {
 	}
 }
 
+func TestPaymentHandler_ProcessPayment_AmountUnit(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		header         string
+		expectedAmount float64
+		expectedUnit   string
+	}{
+		{"major units by default", "", "", 100.50, ""},
+		{"minor units by query", "&amountUnit=minor", "", 10050, "minor"},
+		{"minor units by header", "", "minor", 10050, "minor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockPaymentService{
+				CreatePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+					return &provider.PaymentResponse{Success: true, PaymentID: "pay_123", Amount: request.Amount, Currency: request.Currency}, nil
+				},
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			body, _ := json.Marshal(provider.PaymentRequest{Amount: 100.50, Currency: "TRY"})
+			req := httptest.NewRequest("POST", "/payments/iyzico?environment=sandbox"+tt.query, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set("X-Amount-Unit", tt.header)
+			}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "iyzico")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.ProcessPayment(w, req)
+
+			var resp struct {
+				Data provider.PaymentResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Data.Amount != tt.expectedAmount || resp.Data.AmountUnit != tt.expectedUnit {
+				t.Errorf("Expected amount %v (%q), got %v (%q)", tt.expectedAmount, tt.expectedUnit, resp.Data.Amount, resp.Data.AmountUnit)
+			}
+		})
+	}
+}
+
 func TestPaymentHandler_GetPaymentStatus(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -338,6 +482,36 @@ func TestPaymentHandler_CancelPayment(t *testing.T) {
 				return nil, errors.New("cancellation failed")
 			},
 		},
+		{
+			name:           "already captured",
+			paymentID:      "test-payment-123",
+			environment:    "sandbox",
+			provider:       "paycell",
+			expectedStatus: 409,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+				return &provider.PaymentResponse{ErrorCode: provider.ErrorCodeAlreadyCaptured}, fmt.Errorf("paycell: %w", provider.ErrAlreadyCaptured)
+			},
+		},
+		{
+			name:           "already cancelled",
+			paymentID:      "test-payment-123",
+			environment:    "sandbox",
+			provider:       "nkolay",
+			expectedStatus: 409,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+				return &provider.PaymentResponse{ErrorCode: provider.ErrorCodeAlreadyCancelled}, fmt.Errorf("nkolay: %w", provider.ErrAlreadyCancelled)
+			},
+		},
+		{
+			name:           "payment not found",
+			paymentID:      "test-payment-123",
+			environment:    "sandbox",
+			provider:       "ziraat",
+			expectedStatus: 404,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+				return &provider.PaymentResponse{ErrorCode: provider.ErrorCodePaymentNotFound}, fmt.Errorf("ziraat: %w", provider.ErrPaymentNotFound)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -366,6 +540,60 @@ func TestPaymentHandler_CancelPayment(t *testing.T) {
 	}
 }
 
+func TestPaymentHandler_CapturePayment(t *testing.T) {
+	tests := []struct {
+		name           string
+		paymentID      string
+		requestBody    string
+		expectedStatus int
+		expectedAmount float64
+		mockErr        error
+	}{
+		{name: "full capture without body", paymentID: "pi_123", expectedStatus: 200},
+		{name: "partial amount", paymentID: "pi_123", requestBody: `{"amount": 40.5}`, expectedStatus: 200, expectedAmount: 40.5},
+		{name: "missing payment ID", paymentID: "", expectedStatus: 400},
+		{name: "invalid body", paymentID: "pi_123", requestBody: `{"amount":`, expectedStatus: 400},
+		{name: "negative amount", paymentID: "pi_123", requestBody: `{"amount": -1}`, expectedStatus: 400},
+		{name: "provider without capture", paymentID: "pi_123", expectedStatus: 400, mockErr: provider.ErrCaptureUnsupported},
+		{name: "over-capture", paymentID: "pi_123", requestBody: `{"amount": 150}`, expectedStatus: 400, mockErr: &provider.CaptureExceedsAuthorizedError{PaymentID: "pi_123", Requested: 150, Remaining: 100, Currency: "USD"}},
+		{name: "already captured", paymentID: "pi_123", expectedStatus: 409, mockErr: fmt.Errorf("stripe: %w", provider.ErrAlreadyCaptured)},
+		{name: "payment not found", paymentID: "pi_123", expectedStatus: 404, mockErr: fmt.Errorf("stripe: %w", provider.ErrPaymentNotFound)},
+		{name: "service error", paymentID: "pi_123", expectedStatus: 500, mockErr: errors.New("capture failed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured *provider.CaptureRequest
+			mockService := &MockPaymentService{
+				CapturePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.CaptureRequest) (*provider.PaymentResponse, error) {
+					captured = &request
+					if tt.mockErr != nil {
+						return nil, tt.mockErr
+					}
+					return &provider.PaymentResponse{Success: true, PaymentID: request.PaymentID, Status: provider.StatusSuccessful}, nil
+				},
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			req := httptest.NewRequest("POST", "/payments/stripe/"+tt.paymentID+"/capture", strings.NewReader(tt.requestBody))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "stripe")
+			rctx.URLParams.Add("paymentID", tt.paymentID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.CapturePayment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == 200 && (captured == nil || captured.PaymentID != tt.paymentID || captured.Amount != tt.expectedAmount) {
+				t.Errorf("Expected capture of %s for %v, got %+v", tt.paymentID, tt.expectedAmount, captured)
+			}
+		})
+	}
+}
+
 func TestPaymentHandler_RefundPayment(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -410,6 +638,26 @@ func TestPaymentHandler_RefundPayment(t *testing.T) {
 				return nil, errors.New("refund failed")
 			},
 		},
+		{
+			name:           "refund already in progress",
+			requestBody:    provider.RefundRequest{PaymentID: "test", IdempotencyKey: "key-1"},
+			environment:    "sandbox",
+			provider:       "stripe",
+			expectedStatus: 409,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
+				return nil, provider.ErrRefundInProgress
+			},
+		},
+		{
+			name:           "idempotency key reused",
+			requestBody:    provider.RefundRequest{PaymentID: "test", IdempotencyKey: "key-1"},
+			environment:    "sandbox",
+			provider:       "stripe",
+			expectedStatus: 422,
+			mockFunc: func(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
+				return nil, provider.ErrIdempotencyKeyReused
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -444,6 +692,214 @@ func TestPaymentHandler_RefundPayment(t *testing.T) {
 	}
 }
 
+func TestPaymentHandler_RefundPayment_IdempotencyKeyHeader(t *testing.T) {
+	var received string
+	mockService := &MockPaymentService{
+		RefundPaymentFunc: func(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
+			received = request.IdempotencyKey
+			return &provider.RefundResponse{Success: true}, nil
+		},
+	}
+	handler := NewPaymentHandler(mockService, validator.New())
+
+	body, _ := json.Marshal(provider.RefundRequest{PaymentID: "test-payment-123"})
+	req := httptest.NewRequest("POST", "/payments/stripe/refund?environment=sandbox", bytes.NewBuffer(body))
+	req.Header.Set("Idempotency-Key", "header-key")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("provider", "stripe")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	handler.RefundPayment(httptest.NewRecorder(), req)
+
+	if received != "header-key" {
+		t.Errorf("Expected idempotency key from header, got %q", received)
+	}
+}
+
+func TestPaymentHandler_RefundPayment_ReplayedHeader(t *testing.T) {
+	for _, replayed := range []bool{false, true} {
+		mockService := &MockPaymentService{
+			RefundPaymentFunc: func(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
+				return &provider.RefundResponse{Success: true, RefundID: "re_1", Replayed: replayed}, nil
+			},
+		}
+		handler := NewPaymentHandler(mockService, validator.New())
+
+		body, _ := json.Marshal(provider.RefundRequest{PaymentID: "test-payment-123", IdempotencyKey: "refund-key"})
+		req := httptest.NewRequest("POST", "/payments/stripe/refund?environment=sandbox", bytes.NewBuffer(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", "stripe")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+
+		handler.RefundPayment(w, req)
+
+		if got := w.Header().Get(IdempotentReplayedHeader) == "true"; got != replayed {
+			t.Errorf("Expected %s header %v, got %q", IdempotentReplayedHeader, replayed, w.Header().Get(IdempotentReplayedHeader))
+		}
+	}
+}
+
+func TestPaymentHandler_ProcessPayment_Idempotency(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		replayed       bool
+		expectedStatus int
+	}{
+		{"first payment", nil, false, 200},
+		{"replayed payment", nil, true, 200},
+		{"in progress", provider.ErrPaymentInProgress, false, 409},
+		{"key reused", provider.ErrPaymentIdempotencyKeyReused, false, 422},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received string
+			mockService := &MockPaymentService{
+				CreatePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+					received = request.IdempotencyKey
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful, PaymentID: "pay_1", Replayed: tt.replayed}, nil
+				},
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			body, _ := json.Marshal(provider.PaymentRequest{Amount: 100, Currency: "TRY"})
+			req := httptest.NewRequest("POST", "/payments/iyzico?environment=sandbox", bytes.NewBuffer(body))
+			req.Header.Set("Idempotency-Key", "payment-key")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "iyzico")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.ProcessPayment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if received != "payment-key" {
+				t.Errorf("Expected idempotency key from header, got %q", received)
+			}
+			if got := w.Header().Get(IdempotentReplayedHeader) == "true"; got != tt.replayed {
+				t.Errorf("Expected %s header %v, got %q", IdempotentReplayedHeader, tt.replayed, w.Header().Get(IdempotentReplayedHeader))
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_Check3DSEnrollment(t *testing.T) {
+	tests := []struct {
+		name           string
+		provider       string
+		bin            string
+		expectedStatus int
+		mockFunc       func(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error)
+	}{
+		{
+			name:           "enrolled",
+			provider:       "iyzico",
+			bin:            "552879",
+			expectedStatus: 200,
+		},
+		{
+			name:           "missing provider",
+			provider:       "",
+			bin:            "552879",
+			expectedStatus: 400,
+		},
+		{
+			name:           "invalid BIN",
+			provider:       "iyzico",
+			bin:            "55287",
+			expectedStatus: 400,
+			mockFunc: func(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error) {
+				return nil, provider.ErrInvalidBIN
+			},
+		},
+		{
+			name:           "service error",
+			provider:       "iyzico",
+			bin:            "552879",
+			expectedStatus: 500,
+			mockFunc: func(ctx context.Context, environment, providerName, bin string) (*provider.ThreeDSEnrollmentResponse, error) {
+				return nil, errors.New("service error")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockPaymentService{
+				Check3DSEnrollmentFunc: tt.mockFunc,
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			req := httptest.NewRequest("GET", "/3ds/"+tt.provider+"/enrollment/"+tt.bin, nil)
+
+			// Add chi URL params
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", tt.provider)
+			rctx.URLParams.Add("bin", tt.bin)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.Check3DSEnrollment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_ProviderNotConfigured(t *testing.T) {
+	notConfigured := &provider.ProviderNotConfiguredError{
+		Provider:    "iyzico",
+		Environment: "sandbox",
+		MissingConfig: []provider.ConfigField{
+			{Key: "apiKey", Required: true, Type: "string"},
+			{Key: "secretKey", Required: true, Type: "string"},
+		},
+	}
+
+	mockService := &MockPaymentService{
+		GetPaymentStatusFunc: func(ctx context.Context, environment, providerName string, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+			return nil, fmt.Errorf("resolve provider: %w", notConfigured)
+		},
+	}
+	handler := NewPaymentHandler(mockService, validator.New())
+
+	req := httptest.NewRequest("GET", "/payments/iyzico/pay_123?environment=sandbox", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("provider", "iyzico")
+	rctx.URLParams.Add("paymentID", "pay_123")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	handler.GetPaymentStatus(w, req)
+
+	if w.Code != 400 {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Message string                              `json:"message"`
+		Data    provider.ProviderNotConfiguredError `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Message != "Provider not configured" || body.Data.Provider != "iyzico" || body.Data.Environment != "sandbox" {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+	if len(body.Data.MissingConfig) != 2 || body.Data.MissingConfig[0].Key != "apiKey" || body.Data.MissingConfig[1].Key != "secretKey" {
+		t.Errorf("Expected the missing config fields, got %+v", body.Data.MissingConfig)
+	}
+}
+
 func TestPaymentHandler_HandleCallback(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -489,6 +945,17 @@ func TestPaymentHandler_HandleCallback(t *testing.T) {
 				return nil, errors.New("3D completion failed")
 			},
 		},
+		{
+			name: "3D session expired",
+			queryParams: map[string]string{
+				"state": "test-encrypted-state",
+			},
+			expectedStatus: 410,
+			expectRedirect: false,
+			mockFunc: func(ctx context.Context, providerName, state string, data map[string]string) (*provider.PaymentResponse, error) {
+				return nil, fmt.Errorf("paycell: %w", provider.Err3DSessionExpired)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -647,6 +1114,96 @@ func TestPaymentHandler_HandleWebhook(t *testing.T) {
 	}
 }
 
+func TestPaymentHandler_HandleWebhook_SourceIP(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_IPS_IYZICO", "198.51.100.0/24")
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		expectedStatus int
+		validated      bool
+	}{
+		{name: "allowed source", remoteAddr: "198.51.100.10:443", expectedStatus: 200, validated: true},
+		{name: "disallowed source", remoteAddr: "203.0.113.7:443", expectedStatus: 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validated := false
+			mockService := &MockPaymentService{
+				ValidateWebhookFunc: func(ctx context.Context, environment, providerName string, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+					validated = true
+					return true, map[string]string{"paymentId": "test-123", "status": "success"}, nil
+				},
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			req := httptest.NewRequest("POST", "/webhooks/iyzico?environment=sandbox", strings.NewReader(`{"paymentId":"test-123","status":"success"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = tt.remoteAddr
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "iyzico")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.HandleWebhook(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			// The signature is still checked for allowed sources and never for rejected ones
+			if validated != tt.validated {
+				t.Errorf("Expected signature validation %v, got %v", tt.validated, validated)
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_HandleWebhook_SpoofedForwardedFor(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_IPS_IYZICO", "198.51.100.0/24")
+	t.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8")
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+	}{
+		{name: "spoofed header from untrusted peer", remoteAddr: "203.0.113.7:443", forwardedFor: "198.51.100.10", expectedStatus: 403},
+		{name: "spoofed hop before trusted proxy", remoteAddr: "10.0.0.2:443", forwardedFor: "198.51.100.10, 203.0.113.7", expectedStatus: 403},
+		{name: "forwarded by trusted proxy", remoteAddr: "10.0.0.2:443", forwardedFor: "203.0.113.7, 198.51.100.10", expectedStatus: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockPaymentService{
+				ValidateWebhookFunc: func(ctx context.Context, environment, providerName string, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+					return true, map[string]string{"paymentId": "test-123", "status": "success"}, nil
+				},
+			}
+			handler := NewPaymentHandler(mockService, validator.New())
+
+			router := chi.NewRouter()
+			router.Use(middle.PeerAddrMiddleware())
+			router.Use(middleware.RealIP)
+			router.Post("/webhooks/{provider}", handler.HandleWebhook)
+
+			req := httptest.NewRequest("POST", "/webhooks/iyzico?environment=sandbox", strings.NewReader(`{"paymentId":"test-123","status":"success"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			req.RemoteAddr = tt.remoteAddr
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
 func TestPaymentHandler_TenantSpecificProvider(t *testing.T) {
 	mockService := &MockPaymentService{
 		CreatePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
@@ -709,3 +1266,472 @@ func BenchmarkPaymentHandler_ProcessPayment(b *testing.B) {
 		handler.ProcessPayment(w, req)
 	}
 }
+
+func TestPaymentHandler_GetOrderAttempts(t *testing.T) {
+	attempts := []provider.PaymentAttempt{
+		{PaymentID: "pay_1", Provider: "iyzico", Status: "failed", DeclineReason: "Insufficient funds"},
+		{PaymentID: "pay_2", Provider: "iyzico", Status: "successful"},
+	}
+
+	tests := []struct {
+		name           string
+		orderID        string
+		mockFunc       func(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error)
+		expectedStatus int
+	}{
+		{
+			name:    "attempts of the order",
+			orderID: "ORDER-1",
+			mockFunc: func(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error) {
+				return attempts, nil
+			},
+			expectedStatus: 200,
+		},
+		{name: "unknown order", orderID: "ORDER-2", expectedStatus: 404},
+		{name: "missing order", orderID: " ", expectedStatus: 400},
+		{
+			name:    "history unavailable",
+			orderID: "ORDER-1",
+			mockFunc: func(ctx context.Context, merchantOrderID string) ([]provider.PaymentAttempt, error) {
+				return nil, provider.ErrOrderAttemptsUnavailable
+			},
+			expectedStatus: 501,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewPaymentHandler(&MockPaymentService{GetOrderAttemptsFunc: tt.mockFunc}, validator.New())
+
+			req := httptest.NewRequest("GET", "/payments/by-order/x/attempts", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("merchantOrderID", tt.orderID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.GetOrderAttempts(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != 200 {
+				return
+			}
+
+			var body struct {
+				Data []provider.PaymentAttempt `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(body.Data) != 2 || body.Data[0].PaymentID != "pay_1" || body.Data[0].DeclineReason != "Insufficient funds" {
+				t.Errorf("Expected both attempts in order, got %+v", body.Data)
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_GetPaymentEvents(t *testing.T) {
+	events := []provider.PaymentEvent{
+		{ID: 1, Provider: "iyzico", PaymentID: "pay_1", Type: provider.PaymentEventCreated, Success: true},
+		{ID: 2, Provider: "iyzico", PaymentID: "pay_1", Type: provider.PaymentEventRefunded, Success: true, Amount: 25},
+	}
+
+	tests := []struct {
+		name           string
+		paymentID      string
+		mockFunc       func(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error)
+		expectedStatus int
+	}{
+		{
+			name:      "events of the payment",
+			paymentID: "pay_1",
+			mockFunc: func(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error) {
+				if providerName != "iyzico" || paymentID != "pay_1" {
+					return nil, nil
+				}
+				return events, nil
+			},
+			expectedStatus: 200,
+		},
+		{name: "unknown payment", paymentID: "pay_2", expectedStatus: 404},
+		{name: "missing payment", paymentID: " ", expectedStatus: 400},
+		{
+			name:      "history unavailable",
+			paymentID: "pay_1",
+			mockFunc: func(ctx context.Context, providerName, paymentID string) ([]provider.PaymentEvent, error) {
+				return nil, provider.ErrPaymentEventsUnavailable
+			},
+			expectedStatus: 501,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewPaymentHandler(&MockPaymentService{GetPaymentEventsFunc: tt.mockFunc}, validator.New())
+
+			req := httptest.NewRequest("GET", "/payments/iyzico/x/events", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "IYZICO")
+			rctx.URLParams.Add("paymentID", tt.paymentID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.GetPaymentEvents(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != 200 {
+				return
+			}
+
+			var body struct {
+				Data []provider.PaymentEvent `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(body.Data) != 2 || body.Data[1].Type != provider.PaymentEventRefunded || body.Data[1].Amount != 25 {
+				t.Errorf("Expected both events in order, got %+v", body.Data)
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_ListPayments(t *testing.T) {
+	cursor := provider.EncodePaymentCursor(provider.PaymentCursor{RequestedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Provider: "iyzico", LogID: 7})
+
+	tests := []struct {
+		name           string
+		query          string
+		serviceErr     error
+		expectedStatus int
+		check          func(t *testing.T, filter provider.PaymentListFilter)
+	}{
+		{
+			name:           "all filters",
+			query:          "?provider=iyzico&status=FAILED&from=2026-03-01&to=2026-03-31&minAmount=10&maxAmount=500.5&customerEmail=john@example.com&limit=20&cursor=" + cursor,
+			expectedStatus: 200,
+			check: func(t *testing.T, filter provider.PaymentListFilter) {
+				if filter.Provider != "iyzico" || filter.Status != "failed" || filter.CustomerEmail != "john@example.com" {
+					t.Errorf("Unexpected filter: %+v", filter)
+				}
+				if filter.MinAmount != 10 || filter.MaxAmount != 500.5 || filter.Limit != 20 {
+					t.Errorf("Unexpected amounts or limit: %+v", filter)
+				}
+				if filter.To.Day() != 31 || filter.To.Hour() != 23 || filter.After == nil || filter.After.LogID != 7 {
+					t.Errorf("Expected the end of the day and the cursor, got %v and %+v", filter.To, filter.After)
+				}
+			},
+		},
+		{name: "no filters", expectedStatus: 200},
+		{name: "invalid amount", query: "?minAmount=abc", expectedStatus: 400},
+		{name: "invalid date", query: "?from=yesterday", expectedStatus: 400},
+		{name: "invalid cursor", query: "?cursor=not-a-cursor", expectedStatus: 400},
+		{name: "invalid range", serviceErr: provider.ErrPaymentListFilterInvalid, expectedStatus: 400},
+		{name: "listing unavailable", serviceErr: provider.ErrPaymentListUnavailable, expectedStatus: 501},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got provider.PaymentListFilter
+			handler := NewPaymentHandler(&MockPaymentService{ListPaymentsFunc: func(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error) {
+				got = filter
+				if tt.serviceErr != nil {
+					return nil, tt.serviceErr
+				}
+				return &provider.PaymentPage{Payments: []provider.PaymentSummary{{PaymentID: "pay_1", Provider: "iyzico"}}, NextCursor: "next"}, nil
+			}}, validator.New())
+
+			w := httptest.NewRecorder()
+			handler.ListPayments(w, httptest.NewRequest("GET", "/v1/payments"+tt.query, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
+
+func TestPaymentHandler_ProcessPaymentBatch(t *testing.T) {
+	payment := func(amount float64) provider.PaymentRequest {
+		return provider.PaymentRequest{Amount: amount, Currency: "TRY", ConversationID: "conv", CallbackURL: "https://example.com/callback"}
+	}
+	tooMany := make([]provider.PaymentRequest, 4)
+	for i := range tooMany {
+		tooMany[i] = payment(10)
+	}
+	invalid := payment(20)
+	invalid.Metadata = map[string]string{"note": strings.Repeat("x", 10_000)}
+
+	tests := []struct {
+		name            string
+		payments        []provider.PaymentRequest
+		expectedStatus  int
+		expectedSuccess bool
+	}{
+		{name: "all succeed", payments: []provider.PaymentRequest{payment(10), payment(20)}, expectedStatus: 200, expectedSuccess: true},
+		{name: "one fails", payments: []provider.PaymentRequest{payment(10), payment(13)}, expectedStatus: 200},
+		{name: "empty batch", expectedStatus: 400},
+		{name: "too many payments", payments: tooMany, expectedStatus: 400},
+		{name: "invalid payment", payments: []provider.PaymentRequest{payment(10), invalid}, expectedStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var processed int
+			handler := NewPaymentHandler(&MockPaymentService{CreatePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+				processed++
+				if request.Amount == 13 {
+					return nil, errors.New("provider unavailable")
+				}
+				return &provider.PaymentResponse{Success: true, PaymentID: "pay", Amount: request.Amount, Currency: request.Currency}, nil
+			}}, validator.New())
+			handler.batchLimits = provider.BatchPaymentLimits{MaxItems: 3, Concurrency: 2}
+
+			body, _ := json.Marshal(map[string]any{"payments": tt.payments})
+			req := httptest.NewRequest("POST", "/v1/payments/iyzico/batch", bytes.NewReader(body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "iyzico")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.ProcessPaymentBatch(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != 200 {
+				if processed != 0 {
+					t.Errorf("Expected no payment of a rejected batch to be processed, got %d", processed)
+				}
+				return
+			}
+
+			var resp struct {
+				Success bool                          `json:"success"`
+				Data    provider.BatchPaymentResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Success != tt.expectedSuccess || resp.Data.Summary.Total != len(tt.payments) || len(resp.Data.Results) != len(tt.payments) {
+				t.Errorf("Unexpected batch response: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// fakePaymentJobQueue keeps submitted jobs in memory, or fails with err
+type fakePaymentJobQueue struct {
+	jobs map[string]*provider.PaymentJob
+	err  error
+}
+
+func (f *fakePaymentJobQueue) Submit(ctx context.Context, environment, providerName string, request provider.PaymentRequest, webhookURL string) (*provider.PaymentJob, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	job := &provider.PaymentJob{ID: "pj1", Provider: providerName, Environment: environment, Status: provider.PaymentJobStatusQueued, WebhookURL: webhookURL}
+	f.jobs[job.ID] = job
+	return job, nil
+}
+
+func (f *fakePaymentJobQueue) Get(ctx context.Context, id string) (*provider.PaymentJob, error) {
+	if job, ok := f.jobs[id]; ok {
+		return job, nil
+	}
+	return nil, provider.ErrPaymentJobNotFound
+}
+
+func TestPaymentHandler_ProcessPayment_Async(t *testing.T) {
+	tests := []struct {
+		name           string
+		jobs           *fakePaymentJobQueue
+		expectedStatus int
+	}{
+		{name: "queued", jobs: &fakePaymentJobQueue{jobs: map[string]*provider.PaymentJob{}}, expectedStatus: 202},
+		{name: "queue full", jobs: &fakePaymentJobQueue{err: provider.ErrPaymentJobQueueFull}, expectedStatus: 503},
+		{name: "invalid webhook URL", jobs: &fakePaymentJobQueue{err: provider.ErrPaymentJobInvalid}, expectedStatus: 400},
+		{name: "async not available", expectedStatus: 501},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewPaymentHandler(&MockPaymentService{CreatePaymentFunc: func(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+				t.Error("Expected an async payment not to be processed in the request")
+				return nil, errors.New("unexpected")
+			}}, validator.New())
+			if tt.jobs != nil {
+				handler.SetPaymentJobQueue(tt.jobs)
+			}
+
+			body, _ := json.Marshal(provider.PaymentRequest{Amount: 100, Currency: "TRY"})
+			req := httptest.NewRequest("POST", "/v1/payments/iyzico?async=true&environment=production", bytes.NewReader(body))
+			req.Header.Set(PaymentJobWebhookHeader, "https://example.com/hooks")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", "iyzico")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			handler.ProcessPayment(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != 202 {
+				return
+			}
+			if w.Header().Get("Location") != "/v1/jobs/pj1" {
+				t.Errorf("Expected the job's location, got %q", w.Header().Get("Location"))
+			}
+			if job := tt.jobs.jobs["pj1"]; job.Environment != "production" || job.WebhookURL != "https://example.com/hooks" {
+				t.Errorf("Unexpected job: %+v", job)
+			}
+		})
+	}
+}
+
+func TestPaymentJobHandler_GetJob(t *testing.T) {
+	handler := NewPaymentJobHandler(&fakePaymentJobQueue{jobs: map[string]*provider.PaymentJob{
+		"pj1": {ID: "pj1", Status: provider.PaymentJobStatusCompleted, Result: &provider.PaymentResponse{Success: true, PaymentID: "pay_1"}},
+	}})
+
+	for jobID, expectedStatus := range map[string]int{"pj1": 200, "pj_missing": 404} {
+		req := httptest.NewRequest("GET", "/v1/jobs/"+jobID, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("jobID", jobID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		handler.GetJob(w, req)
+
+		if w.Code != expectedStatus {
+			t.Errorf("%s: expected status %d, got %d: %s", jobID, expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestPaymentHandler_HandleWebhook_RawBody(t *testing.T) {
+	body := `{"event":"payment.captured", "payload":{"payment":{"entity":{"id":"pay_1"}}}}`
+
+	var rawBody string
+	var passed bool
+	mockService := &MockPaymentService{
+		ValidateWebhookFunc: func(ctx context.Context, environment, providerName string, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+			rawBody, passed = headers[provider.WebhookRawBodyHeader]
+			return true, map[string]string{"paymentId": "pay_1", "status": "success"}, nil
+		},
+	}
+	handler := NewPaymentHandler(mockService, validator.New())
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		requestBody := body
+		if contentType != "application/json" {
+			requestBody = "paymentId=pay_1&status=success"
+		}
+		req := httptest.NewRequest("POST", "/webhooks/razorpay?environment=sandbox", strings.NewReader(requestBody))
+		req.Header.Set("Content-Type", contentType)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", "razorpay")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		rawBody, passed = "", false
+		w := httptest.NewRecorder()
+		handler.HandleWebhook(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("%s: expected status 200, got %d", contentType, w.Code)
+		}
+		// A JSON body is passed as sent, for providers that sign it
+		if contentType == "application/json" && (!passed || rawBody != body) {
+			t.Errorf("Expected the raw JSON body, got %q", rawBody)
+		}
+		if contentType != "application/json" && passed {
+			t.Errorf("Expected no raw body for a form webhook, got %q", rawBody)
+		}
+	}
+}
+
+func TestWebhookFields(t *testing.T) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(`{"eventType":"API_AUTH_PAYMENT","eventTimestamp":1661521221,"retry":false,"payload":{"id":1},"note":null}`), &fields); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"eventType":      "API_AUTH_PAYMENT",
+		"eventTimestamp": "1661521221",
+		"retry":          "false",
+		"payload":        `{"id":1}`,
+		"note":           "",
+	}
+	got := webhookFields(fields)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d fields, got %v", len(expected), got)
+	}
+	for key, value := range expected {
+		if got[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, got[key])
+		}
+	}
+}
+
+func TestPaymentHandler_StreamPaymentEvents(t *testing.T) {
+	var afterID int64
+	mockService := &MockPaymentService{
+		WatchPaymentEventsFunc: func(ctx context.Context, providerName, paymentID string, after int64, pollInterval time.Duration) (<-chan provider.PaymentEvent, error) {
+			if paymentID != "pay_1" {
+				return nil, provider.ErrPaymentNotFound
+			}
+			afterID = after
+			events := make(chan provider.PaymentEvent, 2)
+			events <- provider.PaymentEvent{ID: 3, Provider: providerName, PaymentID: paymentID, Type: provider.PaymentEvent3DCompleted, Success: true, Status: "successful"}
+			close(events)
+			return events, nil
+		},
+	}
+	handler := NewPaymentHandler(mockService, validator.New())
+
+	stream := func(paymentID, lastEventID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/payments/iyzico/x/stream", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", "IYZICO")
+		rctx.URLParams.Add("paymentID", paymentID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		handler.StreamPaymentEvents(w, req)
+		return w
+	}
+
+	w := stream("pay_1", "2")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if afterID != 2 {
+		t.Errorf("Expected the events after Last-Event-ID 2, got after %d", afterID)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "retry: 1000\n\n") || !strings.Contains(body, "id: 3\nevent: 3d_completed\ndata: {") || !strings.Contains(body, `"status":"successful"`) {
+		t.Errorf("Unexpected stream:\n%s", body)
+	}
+
+	if w := stream("pay_2", ""); w.Code != 404 {
+		t.Errorf("Expected 404 for a payment without events, got %d", w.Code)
+	}
+	if w := stream("pay_1", "abc"); w.Code != 400 {
+		t.Errorf("Expected 400 for an invalid Last-Event-ID, got %d", w.Code)
+	}
+	handler = NewPaymentHandler(&MockPaymentService{}, validator.New())
+	if w := stream("pay_1", ""); w.Code != 501 {
+		t.Errorf("Expected 501 without an event store, got %d", w.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// PayoutServiceInterface defines the payout operations the handler depends on.
+type PayoutServiceInterface interface {
+	CreatePayout(ctx context.Context, environment, providerName string, request provider.PayoutRequest) (*provider.Payout, error)
+	GetPayout(ctx context.Context, id string) (*provider.Payout, error)
+	ListPayouts(ctx context.Context) ([]provider.Payout, error)
+}
+
+// PayoutHandler handles payout (money transfer) requests.
+type PayoutHandler struct {
+	payoutService PayoutServiceInterface
+	validate      *validator.Validate
+}
+
+// NewPayoutHandler creates a new payout handler.
+func NewPayoutHandler(payoutService PayoutServiceInterface, validate *validator.Validate) *PayoutHandler {
+	return &PayoutHandler{payoutService: payoutService, validate: validate}
+}
+
+type createPayoutBody struct {
+	Provider    string                   `json:"provider" validate:"required"`
+	Amount      float64                  `json:"amount" validate:"required,gt=0"`
+	Currency    string                   `json:"currency" validate:"required"`
+	Recipient   provider.PayoutRecipient `json:"recipient"`
+	Description string                   `json:"description,omitempty" validate:"max=255"`
+}
+
+// CreatePayout handles POST /payouts
+func (h *PayoutHandler) CreatePayout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var body createPayoutBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	if err := h.validate.Struct(body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation error", err)
+		return
+	}
+
+	payout, err := h.payoutService.CreatePayout(ctx, environmentFromRequest(r), strings.ToLower(body.Provider), provider.PayoutRequest{
+		Amount:      body.Amount,
+		Currency:    body.Currency,
+		Recipient:   body.Recipient,
+		Description: body.Description,
+	})
+	if err != nil {
+		h.writeServiceError(w, "Failed to create payout", err)
+		return
+	}
+	middle.SetAuditResource(r.Context(), payout.ID)
+
+	// A payout the provider declined is still recorded, so the request itself succeeded
+	response.Return(w, http.StatusCreated, payout.Status != provider.PayoutStatusFailed, "Payout created", payout)
+}
+
+// ListPayouts handles GET /payouts
+func (h *PayoutHandler) ListPayouts(w http.ResponseWriter, r *http.Request) {
+	payouts, err := h.payoutService.ListPayouts(r.Context())
+	if err != nil {
+		h.writeServiceError(w, "Failed to list payouts", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payouts retrieved", payouts)
+}
+
+// GetPayout handles GET /payouts/{payoutID}
+func (h *PayoutHandler) GetPayout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	payout, err := h.payoutService.GetPayout(ctx, chi.URLParam(r, "payoutID"))
+	if err != nil {
+		h.writeServiceError(w, "Failed to get payout", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Payout retrieved", payout)
+}
+
+// writeServiceError maps payout-service errors to appropriate HTTP status codes.
+func (h *PayoutHandler) writeServiceError(w http.ResponseWriter, message string, err error) {
+	if writeProviderNotConfigured(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, provider.ErrPayoutInvalid):
+		response.Error(w, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, provider.ErrPayoutUnsupported):
+		response.Error(w, http.StatusBadRequest, "Provider does not support payouts", err)
+	case errors.Is(err, provider.ErrPayoutNotFound):
+		response.Error(w, http.StatusNotFound, "Payout not found", err)
+	default:
+		response.Error(w, http.StatusInternalServerError, message, err)
+	}
+}
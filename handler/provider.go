@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// ProviderHandler exposes static, tenant-independent information about registered providers
+type ProviderHandler struct {
+	registry *provider.ProviderRegistry
+}
+
+// NewProviderHandler creates a new provider handler
+func NewProviderHandler(registry *provider.ProviderRegistry) *ProviderHandler {
+	return &ProviderHandler{
+		registry: registry,
+	}
+}
+
+// CurrenciesResponse lists the currencies a provider accepts
+type CurrenciesResponse struct {
+	Provider   string   `json:"provider"`
+	Currencies []string `json:"currencies"`
+}
+
+// GetSupportedCurrencies returns the currencies accepted by a provider
+func (h *ProviderHandler) GetSupportedCurrencies(w http.ResponseWriter, r *http.Request) {
+	providerName := strings.ToLower(chi.URLParam(r, "provider"))
+
+	factory, err := h.registry.Get(providerName)
+	if err != nil {
+		response.Error(w, http.StatusNotFound, "Provider not found", err)
+		return
+	}
+
+	// SupportedCurrencies is static, so an uninitialized instance is enough
+	response.Success(w, http.StatusOK, "Supported currencies retrieved successfully", CurrenciesResponse{
+		Provider:   providerName,
+		Currencies: factory().SupportedCurrencies(),
+	})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/paycell"
+)
+
+func TestProviderHandler_GetSupportedCurrencies(t *testing.T) {
+	registry := provider.NewProviderRegistry()
+	registry.Register("paycell", paycell.NewProvider)
+	h := NewProviderHandler(registry)
+
+	tests := []struct {
+		name           string
+		provider       string
+		expectedStatus int
+		expected       []string
+	}{
+		{name: "paycell accepts only TRY", provider: "paycell", expectedStatus: http.StatusOK, expected: []string{"TRY"}},
+		{name: "unknown provider", provider: "unknown", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/providers/"+tt.provider+"/currencies", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("provider", tt.provider)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			h.GetSupportedCurrencies(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			var body struct {
+				Data CurrenciesResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(body.Data.Currencies) != len(tt.expected) {
+				t.Fatalf("Expected currencies %v, got %v", tt.expected, body.Data.Currencies)
+			}
+			for i, c := range tt.expected {
+				if body.Data.Currencies[i] != c {
+					t.Errorf("Expected currencies %v, got %v", tt.expected, body.Data.Currencies)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// RoleHandler assigns roles to the users of the authenticated tenant. Users without an
+// assigned role are owners.
+type RoleHandler struct {
+	roles    *auth.RoleService
+	validate *validator.Validate
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roles *auth.RoleService, validate *validator.Validate) *RoleHandler {
+	return &RoleHandler{roles: roles, validate: validate}
+}
+
+// AssignRoleRequest sets the role of a user
+type AssignRoleRequest struct {
+	Role auth.Role `json:"role" validate:"required"`
+}
+
+// ListRoles handles GET /v1/auth/roles
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roles.List(r.Context(), tenantID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list roles", err)
+		return
+	}
+	if roles == nil {
+		roles = []auth.UserRole{}
+	}
+	response.Success(w, http.StatusOK, "Roles retrieved successfully", roles)
+}
+
+// AssignRole handles PUT /v1/auth/roles/{username}
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := response.ReadJSON(w, r, &req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	actor := middle.GetTenantUserFromContext(r.Context())
+	role, err := h.roles.Assign(r.Context(), tenantID, actor, chi.URLParam(r, "username"), req.Role)
+	if err != nil {
+		writeRoleError(w, err, "Failed to assign role")
+		return
+	}
+	response.Success(w, http.StatusOK, "Role assigned", role)
+}
+
+// RemoveRole handles DELETE /v1/auth/roles/{username}. The user becomes an owner again.
+func (h *RoleHandler) RemoveRole(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := apiKeyTenant(w, r)
+	if !ok {
+		return
+	}
+
+	username := chi.URLParam(r, "username")
+	if err := h.roles.Remove(r.Context(), tenantID, middle.GetTenantUserFromContext(r.Context()), username); err != nil {
+		writeRoleError(w, err, "Failed to remove role")
+		return
+	}
+	response.Success(w, http.StatusOK, "Role removed", map[string]string{"username": username})
+}
+
+func writeRoleError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrOwnRoleChange):
+		response.Error(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, auth.ErrRoleNotFound):
+		response.Error(w, http.StatusNotFound, "Role assignment not found", nil)
+	default:
+		response.Error(w, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// SubscriptionServiceInterface defines the subscription operations the handler depends on.
+type SubscriptionServiceInterface interface {
+	CreateSubscription(ctx context.Context, environment, providerName string, request provider.CreateSubscriptionRequest) (*provider.Subscription, error)
+	CancelSubscription(ctx context.Context, id string) (*provider.Subscription, error)
+	GetSubscription(ctx context.Context, id string) (*provider.Subscription, error)
+	ListSubscriptions(ctx context.Context) ([]provider.Subscription, error)
+}
+
+// SubscriptionHandler handles recurring payment (subscription) requests.
+type SubscriptionHandler struct {
+	subscriptionService SubscriptionServiceInterface
+	validate            *validator.Validate
+}
+
+// NewSubscriptionHandler creates a new subscription handler.
+func NewSubscriptionHandler(subscriptionService SubscriptionServiceInterface, validate *validator.Validate) *SubscriptionHandler {
+	return &SubscriptionHandler{subscriptionService: subscriptionService, validate: validate}
+}
+
+type createSubscriptionBody struct {
+	Provider      string     `json:"provider" validate:"required"`
+	CardID        int        `json:"cardId" validate:"required,gt=0"`
+	Amount        float64    `json:"amount" validate:"required,gt=0"`
+	Currency      string     `json:"currency" validate:"required"`
+	Interval      string     `json:"interval" validate:"required"`
+	IntervalCount int        `json:"intervalCount,omitempty"`
+	StartAt       *time.Time `json:"startAt,omitempty"`
+}
+
+// CreateSubscription handles POST /subscriptions
+func (h *SubscriptionHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var body createSubscriptionBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	if err := h.validate.Struct(body); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation error", err)
+		return
+	}
+
+	subscription, err := h.subscriptionService.CreateSubscription(ctx, environmentFromRequest(r), strings.ToLower(body.Provider), provider.CreateSubscriptionRequest{
+		CardID:        body.CardID,
+		Amount:        body.Amount,
+		Currency:      body.Currency,
+		Interval:      body.Interval,
+		IntervalCount: body.IntervalCount,
+		StartAt:       body.StartAt,
+	})
+	if err != nil {
+		h.writeServiceError(w, "Failed to create subscription", err)
+		return
+	}
+	response.Success(w, http.StatusCreated, "Subscription created", subscription)
+}
+
+// ListSubscriptions handles GET /subscriptions
+func (h *SubscriptionHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.subscriptionService.ListSubscriptions(r.Context())
+	if err != nil {
+		h.writeServiceError(w, "Failed to list subscriptions", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Subscriptions retrieved", subscriptions)
+}
+
+// GetSubscription handles GET /subscriptions/{subscriptionID}
+func (h *SubscriptionHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.subscriptionService.GetSubscription(r.Context(), chi.URLParam(r, "subscriptionID"))
+	if err != nil {
+		h.writeServiceError(w, "Failed to get subscription", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Subscription retrieved", subscription)
+}
+
+// CancelSubscription handles DELETE /subscriptions/{subscriptionID}
+func (h *SubscriptionHandler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.subscriptionService.CancelSubscription(r.Context(), chi.URLParam(r, "subscriptionID"))
+	if err != nil {
+		h.writeServiceError(w, "Failed to cancel subscription", err)
+		return
+	}
+	response.Success(w, http.StatusOK, "Subscription cancelled", subscription)
+}
+
+// writeServiceError maps subscription-service errors to appropriate HTTP status codes.
+func (h *SubscriptionHandler) writeServiceError(w http.ResponseWriter, message string, err error) {
+	if writeProviderNotConfigured(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, provider.ErrSubscriptionInvalid):
+		response.Error(w, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, provider.ErrCardStorageUnsupported):
+		response.Error(w, http.StatusBadRequest, "Provider does not support card storage", err)
+	case errors.Is(err, provider.ErrSubscriptionNotFound):
+		response.Error(w, http.StatusNotFound, "Subscription not found", err)
+	case errors.Is(err, provider.ErrSavedCardNotFound):
+		response.Error(w, http.StatusNotFound, "Saved card not found", err)
+	default:
+		response.Error(w, http.StatusInternalServerError, message, err)
+	}
+}
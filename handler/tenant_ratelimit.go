@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/infra/response"
 )
@@ -10,15 +14,33 @@ import (
 // TenantRateLimitHandler handles tenant rate limiting operations
 type TenantRateLimitHandler struct {
 	rateLimiter *middle.TenantRateLimiter
+	validate    *validator.Validate
 }
 
 // NewTenantRateLimitHandler creates a new tenant rate limit handler
-func NewTenantRateLimitHandler(rateLimiter *middle.TenantRateLimiter) *TenantRateLimitHandler {
+func NewTenantRateLimitHandler(rateLimiter *middle.TenantRateLimiter, validate *validator.Validate) *TenantRateLimitHandler {
 	return &TenantRateLimitHandler{
 		rateLimiter: rateLimiter,
+		validate:    validate,
 	}
 }
 
+// TenantRateLimitRequest sets the per-minute limits of a tenant
+type TenantRateLimitRequest struct {
+	GlobalRate  int `json:"global_rate" validate:"required,min=1"`
+	PaymentRate int `json:"payment_rate" validate:"required,min=1"`
+	RefundRate  int `json:"refund_rate" validate:"required,min=1"`
+	StatusRate  int `json:"status_rate" validate:"required,min=1"`
+}
+
+// TenantRateLimitResponse is the limits applied to a tenant. Custom is false when they are the
+// environment defaults.
+type TenantRateLimitResponse struct {
+	TenantID string `json:"tenant_id"`
+	Custom   bool   `json:"custom"`
+	middle.TenantLimits
+}
+
 // GetTenantStats returns rate limiting statistics for the authenticated tenant
 func (h *TenantRateLimitHandler) GetTenantStats(w http.ResponseWriter, r *http.Request) {
 	// Get tenant ID from JWT context
@@ -37,3 +59,94 @@ func (h *TenantRateLimitHandler) GetTenantStats(w http.ResponseWriter, r *http.R
 		Data:    stats,
 	})
 }
+
+// GetTenantLimits returns the rate limits applied to a tenant (admin only)
+func (h *TenantRateLimitHandler) GetTenantLimits(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := rateLimitTenant(w, r)
+	if !ok {
+		return
+	}
+
+	limits, custom := h.rateLimiter.GetTenantLimits(tenantID)
+	response.Success(w, http.StatusOK, "Tenant rate limits retrieved successfully", TenantRateLimitResponse{
+		TenantID: tenantID, Custom: custom, TenantLimits: *limits,
+	})
+}
+
+// SetTenantLimits replaces the environment default rate limits of a tenant (admin only)
+func (h *TenantRateLimitHandler) SetTenantLimits(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := rateLimitTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req TenantRateLimitRequest
+	if err := response.ReadJSON(w, r, &req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request format", err)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation error", err)
+		return
+	}
+
+	limits := middle.TenantLimits{
+		GlobalRate:  req.GlobalRate,
+		PaymentRate: req.PaymentRate,
+		RefundRate:  req.RefundRate,
+		StatusRate:  req.StatusRate,
+	}
+	if err := h.rateLimiter.SetTenantLimits(r.Context(), tenantID, limits); err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to save tenant rate limits", err)
+		return
+	}
+
+	applied, custom := h.rateLimiter.GetTenantLimits(tenantID)
+	response.Success(w, http.StatusOK, "Tenant rate limits updated", TenantRateLimitResponse{
+		TenantID: tenantID, Custom: custom, TenantLimits: *applied,
+	})
+}
+
+// ResetTenantLimits removes the custom rate limits of a tenant, which gets the environment
+// defaults again (admin only)
+func (h *TenantRateLimitHandler) ResetTenantLimits(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := rateLimitTenant(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.rateLimiter.ResetTenantLimits(r.Context(), tenantID); err != nil {
+		if errors.Is(err, middle.ErrTenantLimitsNotFound) {
+			response.Error(w, http.StatusNotFound, "Tenant has no custom rate limits", nil)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, "Failed to reset tenant rate limits", err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Tenant rate limits reset to defaults", map[string]string{"tenant_id": tenantID})
+}
+
+// rateLimitTenant returns the tenant of the path, writing the error response unless the admin
+// makes the request
+func rateLimitTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currentTenantID := middle.GetTenantIDFromContext(r.Context())
+	if currentTenantID == "" {
+		response.Error(w, http.StatusUnauthorized, "Authentication required", nil)
+		return "", false
+	}
+	if currentTenantID != "1" {
+		response.Error(w, http.StatusForbidden, "Only administrators can manage tenant rate limits", nil)
+		return "", false
+	}
+
+	tenantID, err := strconv.Atoi(chi.URLParam(r, "tenantID"))
+	if err != nil || tenantID <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid tenant ID", nil)
+		return "", false
+	}
+	if tenantID != 1 {
+		middle.SetAuditTargetTenant(r.Context(), strconv.Itoa(tenantID))
+	}
+	return strconv.Itoa(tenantID), true
+}
@@ -1,7 +1,16 @@
 package handler
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
 )
 
 func TestTenantRateLimitHandler_Basic(t *testing.T) {
@@ -9,6 +18,71 @@ func TestTenantRateLimitHandler_Basic(t *testing.T) {
 	t.Log("Tenant rate limit handler test placeholder")
 }
 
+func TestTenantRateLimitHandler_AdminLimits(t *testing.T) {
+	h := NewTenantRateLimitHandler(middle.NewTenantRateLimiter(), validator.New())
+	limits := `{"global_rate":100,"payment_rate":80,"refund_rate":10,"status_rate":300}`
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		role       auth.Role
+		apiKey     bool
+		method     string
+		path       string
+		body       string
+		statusCode int
+	}{
+		{"unauthenticated", "", "", false, http.MethodGet, "/v1/admin/tenants/7/rate-limits", "", http.StatusUnauthorized},
+		{"not admin", "7", auth.RoleOwner, false, http.MethodGet, "/v1/admin/tenants/7/rate-limits", "", http.StatusForbidden},
+		{"read-only admin user", "1", auth.RoleReadOnly, false, http.MethodPut, "/v1/admin/tenants/7/rate-limits", limits, http.StatusForbidden},
+		{"read-only admin user reset", "1", auth.RoleReadOnly, false, http.MethodDelete, "/v1/admin/tenants/7/rate-limits", "", http.StatusForbidden},
+		{"admin API key", "1", auth.RoleOwner, true, http.MethodPut, "/v1/admin/tenants/7/rate-limits", limits, http.StatusForbidden},
+		{"invalid tenant", "1", auth.RoleOwner, false, http.MethodGet, "/v1/admin/tenants/abc/rate-limits", "", http.StatusBadRequest},
+		{"missing limit", "1", auth.RoleOwner, false, http.MethodPut, "/v1/admin/tenants/7/rate-limits", `{"global_rate":100}`, http.StatusBadRequest},
+		{"reset without limits", "1", auth.RoleOwner, false, http.MethodDelete, "/v1/admin/tenants/7/rate-limits", "", http.StatusNotFound},
+		{"set", "1", auth.RoleOwner, false, http.MethodPut, "/v1/admin/tenants/7/rate-limits", limits, http.StatusOK},
+		{"get", "1", auth.RoleOwner, false, http.MethodGet, "/v1/admin/tenants/7/rate-limits", "", http.StatusOK},
+		{"reset", "1", auth.RoleOwner, false, http.MethodDelete, "/v1/admin/tenants/7/rate-limits", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Mirrors the routes in cmd/main.go after authentication
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					ctx := context.WithValue(r.Context(), middle.TenantIDKey, tt.tenantID)
+					if tt.role != "" {
+						ctx = context.WithValue(ctx, middle.TenantRoleKey, tt.role)
+					}
+					if tt.apiKey {
+						ctx = context.WithValue(ctx, middle.TenantAPIKeyKey, &auth.APIKey{TenantID: 1})
+					}
+					next.ServeHTTP(w, r.WithContext(ctx))
+				})
+			})
+			r.Route("/v1/admin/tenants/{tenantID}/rate-limits", func(r chi.Router) {
+				r.Use(middle.JWTOnlyMiddleware())
+				r.Use(middle.RequirePermission(auth.PermissionManageUsers))
+				r.Get("/", h.GetTenantLimits)
+				r.Put("/", h.SetTenantLimits)
+				r.Delete("/", h.ResetTenantLimits)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+			if tt.name == "get" && !strings.Contains(rec.Body.String(), `"custom":true`) {
+				t.Errorf("Expected the set limits to be custom, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
 func TestTenantRateLimitValidation(t *testing.T) {
 	tests := []struct {
 		name     string
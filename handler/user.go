@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// UserHandler manages the users of a tenant, each logging in with their own credentials and
+// acting with their own role. Tenants manage their own users, the admin those of any tenant.
+type UserHandler struct {
+	users    *auth.UserService
+	validate *validator.Validate
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(users *auth.UserService, validate *validator.Validate) *UserHandler {
+	return &UserHandler{users: users, validate: validate}
+}
+
+// ListUsers handles GET /v1/tenants/{tenantID}/users
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := userTenant(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := h.users.List(r.Context(), tenantID)
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, "Failed to list users", err)
+		return
+	}
+	if users == nil {
+		users = []auth.TenantUser{}
+	}
+	response.Success(w, http.StatusOK, "Users retrieved successfully", users)
+}
+
+// CreateUser handles POST /v1/tenants/{tenantID}/users
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := userTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req auth.CreateUserRequest
+	if err := response.ReadJSON(w, r, &req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	middle.SetAuditResource(r.Context(), req.Username)
+	user, err := h.users.Create(r.Context(), tenantID, middle.GetTenantUserFromContext(r.Context()), req)
+	if err != nil {
+		writeUserError(w, err, "Failed to create user")
+		return
+	}
+	response.Success(w, http.StatusCreated, "User created", user)
+}
+
+// GetUser handles GET /v1/tenants/{tenantID}/users/{username}
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := userTenant(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(r.Context(), tenantID, chi.URLParam(r, "username"))
+	if err != nil {
+		writeUserError(w, err, "Failed to get user")
+		return
+	}
+	response.Success(w, http.StatusOK, "User retrieved successfully", user)
+}
+
+// UpdateUser handles PUT /v1/tenants/{tenantID}/users/{username}. Resetting the password logs
+// the user out.
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := userTenant(w, r)
+	if !ok {
+		return
+	}
+
+	var req auth.UpdateUserRequest
+	if err := response.ReadJSON(w, r, &req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(w, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	actor := middle.GetTenantUserFromContext(r.Context())
+	user, err := h.users.Update(r.Context(), tenantID, actor, chi.URLParam(r, "username"), req)
+	if err != nil {
+		writeUserError(w, err, "Failed to update user")
+		return
+	}
+	response.Success(w, http.StatusOK, "User updated", user)
+}
+
+// DeleteUser handles DELETE /v1/tenants/{tenantID}/users/{username}
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := userTenant(w, r)
+	if !ok {
+		return
+	}
+
+	username := chi.URLParam(r, "username")
+	if err := h.users.Delete(r.Context(), tenantID, middle.GetTenantUserFromContext(r.Context()), username); err != nil {
+		writeUserError(w, err, "Failed to delete user")
+		return
+	}
+	response.Success(w, http.StatusOK, "User deleted", map[string]string{"username": username})
+}
+
+// userTenant returns the tenant of the path, which must be the authenticated tenant unless the
+// admin makes the request
+func userTenant(w http.ResponseWriter, r *http.Request) (int, bool) {
+	currentTenantID := middle.GetTenantIDFromContext(r.Context())
+	if currentTenantID == "" {
+		response.Error(w, http.StatusUnauthorized, "Invalid or missing authentication", nil)
+		return 0, false
+	}
+
+	tenantID, err := strconv.Atoi(chi.URLParam(r, "tenantID"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, "Invalid tenant ID", nil)
+		return 0, false
+	}
+	if currentTenantID != strconv.Itoa(tenantID) {
+		if currentTenantID != "1" {
+			response.Error(w, http.StatusForbidden, "Access denied", nil)
+			return 0, false
+		}
+		middle.SetAuditTargetTenant(r.Context(), strconv.Itoa(tenantID))
+	}
+	return tenantID, true
+}
+
+func writeUserError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrOwnRoleChange), errors.Is(err, auth.ErrDeleteSelf):
+		response.Error(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, auth.ErrUserNotFound):
+		response.Error(w, http.StatusNotFound, "User not found", nil)
+	case errors.Is(err, auth.ErrUserAlreadyExists):
+		response.Error(w, http.StatusConflict, "Username already exists", nil)
+	default:
+		response.Error(w, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/middle"
+)
+
+func TestUserHandler_TenantAccess(t *testing.T) {
+	// The tenant is checked before the users are, so no service is needed
+	h := NewUserHandler(nil, validator.New())
+
+	tests := []struct {
+		name       string
+		tenantID   string
+		method     string
+		path       string
+		statusCode int
+	}{
+		{"unauthenticated", "", http.MethodGet, "/v1/tenants/7/users", http.StatusUnauthorized},
+		{"invalid tenant", "7", http.MethodGet, "/v1/tenants/abc/users", http.StatusBadRequest},
+		{"other tenant list", "8", http.MethodGet, "/v1/tenants/7/users", http.StatusForbidden},
+		{"other tenant create", "8", http.MethodPost, "/v1/tenants/7/users", http.StatusForbidden},
+		{"other tenant delete", "8", http.MethodDelete, "/v1/tenants/7/users/bob", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middle.TenantIDKey, tt.tenantID)))
+				})
+			})
+			r.Route("/v1/tenants/{tenantID}/users", func(r chi.Router) {
+				r.Get("/", h.ListUsers)
+				r.Post("/", h.CreateUser)
+				r.Delete("/{username}", h.DeleteUser)
+			})
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.statusCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.statusCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// WebhookReplayer re-sends outbound webhook deliveries, typically *provider.WebhookDispatcher.
+type WebhookReplayer interface {
+	Replay(ctx context.Context, id int64) (*provider.WebhookDelivery, error)
+}
+
+// WebhookDeliveryHandler handles the tenant's outbound webhook deliveries.
+type WebhookDeliveryHandler struct {
+	replayer WebhookReplayer
+}
+
+// NewWebhookDeliveryHandler creates a new webhook delivery handler.
+func NewWebhookDeliveryHandler(replayer WebhookReplayer) *WebhookDeliveryHandler {
+	return &WebhookDeliveryHandler{replayer: replayer}
+}
+
+// ReplayDelivery handles POST /webhooks/deliveries/{deliveryID}/replay. The delivery is sent
+// again with its original body and signature, also when it was dead-lettered.
+func (h *WebhookDeliveryHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Error(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	delivery, err := h.replayer.Replay(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrWebhookDeliveryNotFound):
+			response.Error(w, http.StatusNotFound, "Webhook delivery not found", err)
+		case errors.Is(err, provider.ErrWebhookDeliveryInProgress):
+			response.Error(w, http.StatusConflict, "Webhook delivery is being attempted", err)
+		default:
+			response.Error(w, http.StatusInternalServerError, "Failed to replay webhook delivery", err)
+		}
+		return
+	}
+	response.Success(w, http.StatusAccepted, "Webhook delivery queued for replay", delivery)
+}
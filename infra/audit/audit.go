@@ -0,0 +1,162 @@
+// Package audit records who initiated each mutating operation (payments, refunds, cancels,
+// saved cards and config changes). Audit events are kept apart from the payment logs, which
+// record provider traffic, and are meant for security investigations and compliance.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the audit trail
+const (
+	ActionPaymentCreate       = "payment.create"
+	ActionPaymentBatchCreate  = "payment.batch_create"
+	ActionPaymentCancel       = "payment.cancel"
+	ActionPaymentCapture      = "payment.capture"
+	ActionPaymentRefund       = "payment.refund"
+	ActionCardRegister        = "card.register"
+	ActionCardDelete          = "card.delete"
+	ActionCardPay             = "card.pay"
+	ActionSubscriptionCreate  = "subscription.create"
+	ActionSubscriptionCancel  = "subscription.cancel"
+	ActionPayoutCreate        = "payout.create"
+	ActionPaymentLinkCreate   = "payment_link.create"
+	ActionPaymentLinkCancel   = "payment_link.cancel"
+	ActionWebhookReplay       = "webhook.replay"
+	ActionConfigUpdate        = "config.update"
+	ActionConfigDelete        = "config.delete"
+	ActionConfigTemplateSave  = "config.template.save"
+	ActionConfigTemplateDel   = "config.template.delete"
+	ActionConfigTemplateApply = "config.template.apply"
+	ActionUserCreate          = "user.create"
+	ActionUserUpdate          = "user.update"
+	ActionUserDelete          = "user.delete"
+	ActionSessionRevoke       = "session.revoke"
+	ActionRateLimitUpdate     = "rate_limit.update"
+	ActionRateLimitReset      = "rate_limit.reset"
+)
+
+// Authentication methods an actor can use
+const (
+	AuthMethodJWT       = "jwt"
+	AuthMethodAnonymous = "anonymous"
+)
+
+// Actor is who initiated an operation
+type Actor struct {
+	TenantID   string `json:"tenantId"`
+	Username   string `json:"username,omitempty"`
+	AuthMethod string `json:"authMethod"`
+	// Impersonated is set when the actor operated on another tenant's data, e.g. the admin
+	// applying a config template to a tenant
+	Impersonated bool   `json:"impersonated"`
+	SourceIP     string `json:"sourceIp"`
+}
+
+// Event is a single audited operation
+type Event struct {
+	ID         int64  `json:"id"`
+	Action     string `json:"action"`
+	Actor      Actor  `json:"actor"`
+	TenantID   string `json:"tenantId"` // tenant whose data the operation changed
+	Provider   string `json:"provider,omitempty"`
+	ResourceID string `json:"resourceId,omitempty"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"statusCode"`
+	RequestID  string `json:"requestId,omitempty"`
+	// Success is whether the operation was accepted (2xx), not the outcome at the provider
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Filter selects audit events. An empty TenantID selects every tenant.
+type Filter struct {
+	TenantID string
+	Action   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// Store keeps audit events
+type Store interface {
+	Record(ctx context.Context, event Event) error
+	List(ctx context.Context, filter Filter) ([]Event, error)
+}
+
+// PostgresStore keeps audit events in the audit_events table
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store over the shared *sql.DB connection
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Record stores the event
+func (s *PostgresStore) Record(ctx context.Context, event Event) error {
+	actor, err := json.Marshal(event.Actor)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit actor: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_events (action, actor_tenant_id, actor, tenant_id, provider, resource_id, method, path, status_code, request_id, success)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = s.db.ExecContext(ctx, query, event.Action, event.Actor.TenantID, actor, event.TenantID,
+		event.Provider, event.ResourceID, event.Method, event.Path, event.StatusCode, event.RequestID, event.Success)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// List returns the events matching filter, newest first
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Event, error) {
+	conditions := []string{"created_at >= $1", "created_at <= $2"}
+	args := []any{filter.From, filter.To}
+	if filter.TenantID != "" {
+		args = append(args, filter.TenantID)
+		conditions = append(conditions, fmt.Sprintf("tenant_id = $%d", len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT id, action, actor, tenant_id, provider, resource_id, method, path, status_code, request_id, success, created_at
+		FROM audit_events
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var actor []byte
+		if err := rows.Scan(&event.ID, &event.Action, &actor, &event.TenantID, &event.Provider, &event.ResourceID,
+			&event.Method, &event.Path, &event.StatusCode, &event.RequestID, &event.Success, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := json.Unmarshal(actor, &event.Actor); err != nil {
+			return nil, fmt.Errorf("failed to decode audit actor: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
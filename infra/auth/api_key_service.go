@@ -0,0 +1,272 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so leaked keys are recognizable by secret scanners
+	APIKeyPrefix = "gpk_"
+
+	// apiKeyDisplayLength is the length of the key start kept to tell keys apart
+	apiKeyDisplayLength = 12
+
+	// apiKeyTouchInterval limits how often the last use of a key is written
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+)
+
+// APIKey is a long-lived credential of a tenant for server-to-server integrations. Only the
+// SHA-256 hash of the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	TenantID   int        `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyStore persists API keys. PostgresAPIKeyStore is the production implementation.
+type APIKeyStore interface {
+	// Create inserts a key with the hash of its secret
+	Create(ctx context.Context, key *APIKey, hash string) error
+
+	// GetByHash returns the unrevoked key of a hash, or ErrAPIKeyNotFound
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+
+	// List returns the tenant's unrevoked keys, oldest first
+	List(ctx context.Context, tenantID int) ([]APIKey, error)
+
+	// Revoke revokes an unrevoked key of the tenant and reports whether one was revoked
+	Revoke(ctx context.Context, tenantID int, id string, now time.Time) (bool, error)
+
+	// Touch records the last use of a key
+	Touch(ctx context.Context, id string, now time.Time) error
+}
+
+// APIKeyService issues and checks tenant API keys. Unlike JWTs they do not expire and survive
+// restarts; a key stays valid until it is revoked or rotated.
+type APIKeyService struct {
+	store APIKeyStore
+	now   func() time.Time
+}
+
+// NewAPIKeyService creates an API key service
+func NewAPIKeyService(store APIKeyStore) *APIKeyService {
+	return &APIKeyService{store: store, now: time.Now}
+}
+
+// Create issues a key for the tenant and returns it with its secret, which cannot be read again
+func (s *APIKeyService) Create(ctx context.Context, tenantID int, name string) (*APIKey, string, error) {
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &APIKey{
+		ID:        uuid.New().String(),
+		TenantID:  tenantID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    secret[:apiKeyDisplayLength],
+		CreatedAt: s.now(),
+	}
+	if err := s.store.Create(ctx, key, hashAPIKey(secret)); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Rotate replaces a key of the tenant with a new one of the same name. The old key stops
+// working at once.
+func (s *APIKeyService) Rotate(ctx context.Context, tenantID int, id string) (*APIKey, string, error) {
+	keys, err := s.store.List(ctx, tenantID)
+	if err != nil {
+		return nil, "", err
+	}
+	var old *APIKey
+	for i := range keys {
+		if keys[i].ID == id {
+			old = &keys[i]
+			break
+		}
+	}
+	if old == nil {
+		return nil, "", ErrAPIKeyNotFound
+	}
+
+	key, secret, err := s.Create(ctx, tenantID, old.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.Revoke(ctx, tenantID, id); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// List returns the tenant's unrevoked keys, oldest first
+func (s *APIKeyService) List(ctx context.Context, tenantID int) ([]APIKey, error) {
+	return s.store.List(ctx, tenantID)
+}
+
+// Revoke revokes a key of the tenant. Keys of other tenants are reported as ErrAPIKeyNotFound.
+func (s *APIKeyService) Revoke(ctx context.Context, tenantID int, id string) error {
+	revoked, err := s.store.Revoke(ctx, tenantID, id, s.now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the unrevoked key of a secret, or ErrInvalidAPIKey
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.store.GetByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	// The last use is informational, so a failed write does not fail the request
+	now := s.now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		_ = s.store.Touch(ctx, key.ID, now)
+	}
+	return key, nil
+}
+
+// newAPIKeySecret returns a new key: the prefix and 32 random bytes
+func newAPIKeySecret() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashAPIKey hashes a key for storage. Keys are random, so a fast hash without salt suffices
+// and lets a key be looked up by its hash.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostgresAPIKeyStore keeps API keys in the api_keys table.
+type PostgresAPIKeyStore struct {
+	db *sql.DB
+}
+
+// NewPostgresAPIKeyStore creates a store over the shared *sql.DB connection.
+func NewPostgresAPIKeyStore(db *sql.DB) *PostgresAPIKeyStore {
+	return &PostgresAPIKeyStore{db: db}
+}
+
+// Create inserts a key with the hash of its secret
+func (r *PostgresAPIKeyStore) Create(ctx context.Context, key *APIKey, hash string) error {
+	query := `
+		INSERT INTO api_keys (id, tenant_id, name, prefix, key_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := r.db.ExecContext(ctx, query, key.ID, key.TenantID, key.Name, key.Prefix, hash, key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns the unrevoked key of a hash
+func (r *PostgresAPIKeyStore) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	query := `
+		SELECT id, tenant_id, name, prefix, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// List returns the tenant's unrevoked keys, oldest first
+func (r *PostgresAPIKeyStore) List(ctx context.Context, tenantID int) ([]APIKey, error) {
+	query := `
+		SELECT id, tenant_id, name, prefix, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE tenant_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke revokes an unrevoked key of the tenant
+func (r *PostgresAPIKeyStore) Revoke(ctx context.Context, tenantID int, id string, now time.Time) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = $3 WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Touch records the last use of a key
+func (r *PostgresAPIKeyStore) Touch(ctx context.Context, id string, now time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, now); err != nil {
+		return fmt.Errorf("failed to touch API key: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row sessionScanner) (*APIKey, error) {
+	var key APIKey
+	if err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAPIKeyStore is an in-memory APIKeyStore for tests.
+type memoryAPIKeyStore struct {
+	mu      sync.Mutex
+	keys    map[string]*APIKey
+	hashes  map[string]string
+	touches int
+}
+
+func newMemoryAPIKeyStore() *memoryAPIKeyStore {
+	return &memoryAPIKeyStore{keys: make(map[string]*APIKey), hashes: make(map[string]string)}
+}
+
+func (m *memoryAPIKeyStore) Create(_ context.Context, key *APIKey, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *key
+	m.keys[key.ID] = &stored
+	m.hashes[hash] = key.ID
+	return nil
+}
+
+func (m *memoryAPIKeyStore) GetByHash(_ context.Context, hash string) (*APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[m.hashes[hash]]
+	if !ok || key.RevokedAt != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	stored := *key
+	return &stored, nil
+}
+
+func (m *memoryAPIKeyStore) List(_ context.Context, tenantID int) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []APIKey
+	for _, key := range m.keys {
+		if key.TenantID == tenantID && key.RevokedAt == nil {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *memoryAPIKeyStore) Revoke(_ context.Context, tenantID int, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok || key.TenantID != tenantID || key.RevokedAt != nil {
+		return false, nil
+	}
+	key.RevokedAt = &now
+	return true, nil
+}
+
+func (m *memoryAPIKeyStore) Touch(_ context.Context, id string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.touches++
+	m.keys[id].LastUsedAt = &now
+	return nil
+}
+
+func newTestAPIKeyService(store APIKeyStore, now *time.Time) *APIKeyService {
+	service := NewAPIKeyService(store)
+	service.now = func() time.Time { return *now }
+	return service
+}
+
+func TestAPIKeyService_CreateAndAuthenticate(t *testing.T) {
+	store := newMemoryAPIKeyStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestAPIKeyService(store, &now)
+	ctx := context.Background()
+
+	key, secret, err := service.Create(ctx, 7, " ci ")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, APIKeyPrefix) || len(secret) < 40 {
+		t.Errorf("Expected a prefixed random key, got %q", secret)
+	}
+	if key.Name != "ci" || key.Prefix != secret[:apiKeyDisplayLength] || key.TenantID != 7 {
+		t.Errorf("Unexpected key %+v", key)
+	}
+	for hash := range store.hashes {
+		if strings.Contains(hash, secret[len(APIKeyPrefix):]) {
+			t.Error("Expected the key to be stored hashed")
+		}
+	}
+
+	authenticated, err := service.Authenticate(ctx, secret)
+	if err != nil || authenticated.ID != key.ID {
+		t.Fatalf("Expected the key to authenticate, got %+v, %v", authenticated, err)
+	}
+
+	// The last use is written at most once a minute
+	now = now.Add(30 * time.Second)
+	_, _ = service.Authenticate(ctx, secret)
+	if store.touches != 1 {
+		t.Errorf("Expected one touch, got %d", store.touches)
+	}
+
+	for _, wrong := range []string{"", "not-a-key", secret + "x", APIKeyPrefix + "unknown"} {
+		if _, err := service.Authenticate(ctx, wrong); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Expected ErrInvalidAPIKey for %q, got %v", wrong, err)
+		}
+	}
+}
+
+func TestAPIKeyService_RotateAndRevoke(t *testing.T) {
+	store := newMemoryAPIKeyStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := newTestAPIKeyService(store, &now)
+	ctx := context.Background()
+
+	key, secret, _ := service.Create(ctx, 7, "billing")
+	now = now.Add(time.Hour)
+
+	// Another tenant's key is not found
+	if _, _, err := service.Rotate(ctx, 8, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound for another tenant, got %v", err)
+	}
+	if err := service.Revoke(ctx, 8, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound for another tenant, got %v", err)
+	}
+
+	rotated, rotatedSecret, err := service.Rotate(ctx, 7, key.ID)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if rotated.ID == key.ID || rotated.Name != "billing" || rotatedSecret == secret {
+		t.Errorf("Expected a new key of the same name, got %+v", rotated)
+	}
+	if _, err := service.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the rotated key to stop working, got %v", err)
+	}
+	if _, err := service.Authenticate(ctx, rotatedSecret); err != nil {
+		t.Errorf("Expected the new key to work, got %v", err)
+	}
+
+	if err := service.Revoke(ctx, 7, rotated.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if keys, _ := service.List(ctx, 7); len(keys) != 0 {
+		t.Errorf("Expected no keys left, got %v", keys)
+	}
+	if err := service.Revoke(ctx, 7, rotated.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected a revoked key to be not found, got %v", err)
+	}
+}
@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
 )
 
 var (
@@ -28,6 +31,18 @@ type JWTClaims struct {
 type JWTService struct {
 	secretKey []byte
 	expiry    time.Duration
+	sessions  *SessionService
+	keys      *SigningKeyRing
+	refresh   *RefreshTokenService
+}
+
+// TokenPair is an access token with the refresh token of its session, when refresh tokens are
+// enabled
+type TokenPair struct {
+	Token            string     `json:"token"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshToken     string     `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
 }
 
 // NewJWTService creates a new JWT service
@@ -49,8 +64,163 @@ func (s *JWTService) Expiry() time.Duration {
 	return s.expiry
 }
 
+// SetSessionService enables session tracking: tokens issued by StartSession carry a session
+// ID and stop validating once that session is revoked.
+func (s *JWTService) SetSessionService(sessions *SessionService) {
+	s.sessions = sessions
+}
+
+// SetSigningKeys signs tokens with the keys of a key ring instead of JWT_SECRET. Tokens carry
+// the ID of their key (the "kid" header); tokens signed with JWT_SECRET stop validating.
+func (s *JWTService) SetSigningKeys(keys *SigningKeyRing) {
+	s.keys = keys
+}
+
+// SetRefreshTokens issues a refresh token with each login, for clients to get new access
+// tokens without the password. Refresh tokens need session tracking, and sessions with one
+// last as long as it.
+func (s *JWTService) SetRefreshTokens(refresh *RefreshTokenService) {
+	s.refresh = refresh
+}
+
+// Sessions returns the session service, nil when session tracking is disabled
+func (s *JWTService) Sessions() *SessionService {
+	return s.sessions
+}
+
+// StartSession records a new session for the tenant user (evicting their oldest ones beyond the
+// limit) and returns a token bound to it. Without a session service it falls back to a
+// plain token.
+func (s *JWTService) StartSession(ctx context.Context, tenantID, username string, client SessionClient) (string, error) {
+	if s.sessions == nil {
+		return s.GenerateToken(tenantID, username)
+	}
+
+	token, _, err := s.startSession(ctx, tenantID, username, client, s.expiry)
+	return token, err
+}
+
+// IssueTokens starts a session like StartSession and returns its access token with a refresh
+// token, when refresh tokens are enabled
+func (s *JWTService) IssueTokens(ctx context.Context, tenantID, username string, client SessionClient) (*TokenPair, error) {
+	if s.sessions == nil || s.refresh == nil {
+		token, err := s.StartSession(ctx, tenantID, username, client)
+		if err != nil {
+			return nil, err
+		}
+		return &TokenPair{Token: token, ExpiresAt: time.Now().Add(s.expiry)}, nil
+	}
+
+	token, session, err := s.startSession(ctx, tenantID, username, client, s.sessionTTL())
+	if err != nil {
+		return nil, err
+	}
+	return s.tokenPair(ctx, token, session)
+}
+
+// Refresh exchanges a refresh token for a new access token and the next refresh token of the
+// session. A refresh token used twice has leaked, so its session is revoked.
+func (s *JWTService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if s.sessions == nil || s.refresh == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	used, err := s.refresh.Use(ctx, refreshToken)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := s.sessions.Revoke(ctx, used.TenantID, used.SessionID, SessionRevokedReuse); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, err
+		}
+		logger.Warn("Refresh token reused, session revoked", logger.LogContext{
+			TenantID: strconv.Itoa(used.TenantID),
+			Fields: map[string]any{
+				"session_id": used.SessionID,
+				"username":   used.Username,
+			},
+		})
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.Refresh(ctx, used.SessionID, s.sessionTTL()); err != nil {
+		return nil, err
+	}
+	token, err := s.signToken(strconv.Itoa(used.TenantID), used.Username, used.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokenPair(ctx, token, &Session{ID: used.SessionID, TenantID: used.TenantID, Username: used.Username})
+}
+
+// RevokeRefreshToken revokes the session of a refresh token of the tenant, so the refresh
+// token and the access tokens of its session stop working
+func (s *JWTService) RevokeRefreshToken(ctx context.Context, tenantID int, refreshToken string) error {
+	if s.sessions == nil || s.refresh == nil {
+		return ErrInvalidRefreshToken
+	}
+
+	token, err := s.refresh.Lookup(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	if token.TenantID != tenantID {
+		return ErrInvalidRefreshToken
+	}
+	if err := s.sessions.Revoke(ctx, tenantID, token.SessionID, SessionRevokedByUser); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return nil
+}
+
+// startSession records a session living ttl and signs a token bound to it
+func (s *JWTService) startSession(ctx context.Context, tenantID, username string, client SessionClient, ttl time.Duration) (string, *Session, error) {
+	id, err := strconv.Atoi(tenantID)
+	if err != nil {
+		return "", nil, ErrInvalidClaims
+	}
+
+	session, err := s.sessions.Start(ctx, id, username, client, ttl)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	token, err := s.signToken(tenantID, username, session.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// tokenPair adds a new refresh token of session to an access token
+func (s *JWTService) tokenPair(ctx context.Context, token string, session *Session) (*TokenPair, error) {
+	refreshToken, refreshExpiresAt, err := s.refresh.Issue(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		Token:            token,
+		ExpiresAt:        time.Now().Add(s.expiry),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: &refreshExpiresAt,
+	}, nil
+}
+
+// sessionTTL is how long sessions last: as long as their refresh tokens when they have one
+func (s *JWTService) sessionTTL() time.Duration {
+	if s.refresh != nil {
+		return max(s.expiry, s.refresh.TTL())
+	}
+	return s.expiry
+}
+
 // GenerateToken generates a new JWT token for a tenant
 func (s *JWTService) GenerateToken(tenantID, username string) (string, error) {
+	return s.signToken(tenantID, username, "")
+}
+
+// signToken signs a token, binding it to sessionID (the "jti" claim) when set
+func (s *JWTService) signToken(tenantID, username, sessionID string) (string, error) {
 	now := time.Now()
 
 	claims := JWTClaims{
@@ -62,11 +232,21 @@ func (s *JWTService) GenerateToken(tenantID, username string) (string, error) {
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiry)),
 			NotBefore: jwt.NewNumericDate(now),
+			ID:        sessionID,
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.secretKey)
+	secret := s.secretKey
+	if s.keys != nil {
+		key := s.keys.Current()
+		if key.ID == "" {
+			return "", fmt.Errorf("failed to sign token: %w", ErrSigningKeyNotFound)
+		}
+		token.Header["kid"] = key.ID
+		secret = key.Secret
+	}
+	tokenString, err := token.SignedString(secret)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -81,6 +261,10 @@ func (s *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		if s.keys != nil {
+			kid, _ := token.Header["kid"].(string)
+			return s.keys.Secret(context.Background(), kid)
+		}
 		return s.secretKey, nil
 	})
 
@@ -101,6 +285,16 @@ func (s *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, ErrMissingTenant
 	}
 
+	// Tokens without a session ID predate session tracking and expire on their own
+	if s.sessions != nil && claims.ID != "" {
+		if err := s.sessions.Check(context.Background(), claims.ID); err != nil {
+			if errors.Is(err, ErrSessionRevoked) {
+				return nil, ErrSessionRevoked
+			}
+			return nil, fmt.Errorf("failed to check session: %w", err)
+		}
+	}
+
 	return claims, nil
 }
 
@@ -111,8 +305,15 @@ func (s *JWTService) RefreshToken(tokenString string) (string, error) {
 		return "", err
 	}
 
+	// A refreshed token stays in the same session, so revoking the session revokes it too
+	if s.sessions != nil && claims.ID != "" {
+		if err := s.sessions.Refresh(context.Background(), claims.ID, s.sessionTTL()); err != nil {
+			return "", err
+		}
+	}
+
 	// Generate new token with updated expiry
-	return s.GenerateToken(claims.TenantID, claims.Username)
+	return s.signToken(claims.TenantID, claims.Username, claims.ID)
 }
 
 // ExtractTenantID extracts tenant ID from token without full validation
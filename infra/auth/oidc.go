@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/mstgnz/gopay/infra/config"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// adminTenantID is the tenant of administrators
+	adminTenantID = "1"
+
+	// oidcStateTTL limits how long a login at the IdP may take
+	oidcStateTTL = 10 * time.Minute
+)
+
+var (
+	ErrOIDCStateInvalid = errors.New("invalid or expired OIDC login state")
+	ErrOIDCTokenInvalid = errors.New("invalid OIDC ID token")
+	ErrOIDCNoTenant     = errors.New("OIDC identity has no tenant")
+	ErrOIDCNoRole       = errors.New("OIDC identity has no GoPay role")
+)
+
+// OIDCConfig configures dashboard login against an OpenID Connect IdP, like Keycloak or
+// Azure AD. Machine traffic is not affected; it keeps using JWTs and API keys.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// TenantClaim holds the numeric GoPay tenant ID of the user. Nested claims are written
+	// with dots, like "gopay.tenant_id".
+	TenantClaim string
+
+	// RoleClaim holds the roles of the user, like "roles" (Azure AD) or "realm_access.roles"
+	// (Keycloak)
+	RoleClaim string
+
+	// AdminRole makes a user an administrator, who signs in to the admin tenant whatever
+	// their tenant claim. Empty means no IdP user is an administrator.
+	AdminRole string
+
+	// RoleMapping maps IdP roles to the GoPay role of a user within their tenant. A user with
+	// several mapped roles gets the most privileged one.
+	RoleMapping map[string]Role
+
+	// DefaultRole is the role of users without a mapped role. Empty refuses them.
+	DefaultRole Role
+}
+
+// OIDCConfigFromEnv reads the OIDC_* environment variables
+func OIDCConfigFromEnv() OIDCConfig {
+	redirectURL := config.GetEnv("OIDC_REDIRECT_URL", "")
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(config.GetEnv("APP_URL", ""), "/") + "/v1/auth/oidc/callback"
+	}
+	return OIDCConfig{
+		IssuerURL:    config.GetEnv("OIDC_ISSUER_URL", ""),
+		ClientID:     config.GetEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.GetEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(strings.ReplaceAll(config.GetEnv("OIDC_SCOPES", "openid profile email"), ",", " ")),
+		TenantClaim:  config.GetEnv("OIDC_TENANT_CLAIM", "tenant_id"),
+		RoleClaim:    config.GetEnv("OIDC_ROLE_CLAIM", "roles"),
+		AdminRole:    config.GetEnv("OIDC_ADMIN_ROLE", ""),
+		RoleMapping:  parseOIDCRoleMapping(config.GetEnv("OIDC_ROLE_MAPPING", "")),
+		DefaultRole:  oidcDefaultRole(config.GetEnv("OIDC_DEFAULT_ROLE", string(RoleReadOnly))),
+	}
+}
+
+// parseOIDCRoleMapping parses comma separated idp-role=gopay-role pairs, like
+// "gopay-devs=developer,accounting=finance"
+func parseOIDCRoleMapping(value string) map[string]Role {
+	mapping := make(map[string]Role)
+	for _, pair := range strings.Split(value, ",") {
+		idpRole, role, ok := strings.Cut(pair, "=")
+		if idpRole = strings.TrimSpace(idpRole); ok && idpRole != "" {
+			mapping[idpRole] = Role(strings.TrimSpace(role))
+		}
+	}
+	return mapping
+}
+
+// oidcDefaultRole reads OIDC_DEFAULT_ROLE, where "none" refuses users without a mapped role
+func oidcDefaultRole(value string) Role {
+	if value = strings.TrimSpace(value); value == "none" {
+		return ""
+	}
+	return Role(value)
+}
+
+// oidcRolePrecedence orders the roles from the most privileged, to pick one of several
+var oidcRolePrecedence = []Role{RoleOwner, RoleDeveloper, RoleFinance, RoleReadOnly}
+
+// Enabled reports whether an IdP is configured
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// OIDCIdentity is a user signed in at the IdP, mapped to a GoPay tenant
+type OIDCIdentity struct {
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	TenantID string   `json:"tenant_id"`
+	Roles    []string `json:"roles"`
+	Role     Role     `json:"role"`
+	Admin    bool     `json:"admin"`
+}
+
+// OIDCService signs dashboard users in with the authorization code flow. The state, nonce and
+// PKCE verifier of a login travel in a signed value the caller keeps in a cookie, so any
+// instance can complete a login another one started.
+type OIDCService struct {
+	cfg      OIDCConfig
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	stateKey []byte
+	now      func() time.Time
+}
+
+// NewOIDCService discovers the IdP of cfg. stateKey signs login states and should be secret.
+func NewOIDCService(ctx context.Context, cfg OIDCConfig, stateKey []byte) (*OIDCService, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("OIDC issuer URL and client ID are required")
+	}
+	if len(stateKey) == 0 {
+		return nil, errors.New("OIDC state key is required")
+	}
+	for idpRole, role := range cfg.RoleMapping {
+		if !role.Valid() {
+			return nil, fmt.Errorf("OIDC role mapping of %q: %w", idpRole, ErrInvalidRole)
+		}
+	}
+	if cfg.DefaultRole != "" && !cfg.DefaultRole.Valid() {
+		return nil, fmt.Errorf("OIDC default role: %w", ErrInvalidRole)
+	}
+
+	idp, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC issuer: %w", err)
+	}
+
+	scopes := cfg.Scopes
+	if !slices.Contains(scopes, oidc.ScopeOpenID) {
+		scopes = append([]string{oidc.ScopeOpenID}, scopes...)
+	}
+
+	return &OIDCService{
+		cfg: cfg,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     idp.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: idp.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		stateKey: stateKey,
+		now:      time.Now,
+	}, nil
+}
+
+// oidcLoginState is what a callback needs of the login that started it
+type oidcLoginState struct {
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// Begin starts a login. It returns the IdP URL to redirect the user to and the signed state
+// to hand back to Complete.
+func (s *OIDCService) Begin() (authURL, state string, err error) {
+	login := oidcLoginState{
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: s.now().Add(oidcStateTTL).Unix(),
+	}
+	if login.State, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	if login.Nonce, err = randomToken(); err != nil {
+		return "", "", err
+	}
+
+	state, err = s.signState(login)
+	if err != nil {
+		return "", "", err
+	}
+	authURL = s.oauth.AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+	return authURL, state, nil
+}
+
+// Complete finishes a login: it checks the state returned by the IdP against the signed state
+// of Begin, exchanges the code and maps the verified ID token to a tenant
+func (s *OIDCService) Complete(ctx context.Context, code, returnedState, state string) (*OIDCIdentity, error) {
+	login, err := s.verifyState(state)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(returnedState), []byte(login.State)) {
+		return nil, ErrOIDCStateInvalid
+	}
+
+	token, err := s.oauth.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange OIDC code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrOIDCTokenInvalid)
+	}
+	idToken, err := s.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+	if !hmac.Equal([]byte(idToken.Nonce), []byte(login.Nonce)) {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCTokenInvalid)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+	return s.identityFromClaims(claims)
+}
+
+// identityFromClaims maps the claims of an ID token to a tenant and roles
+func (s *OIDCService) identityFromClaims(claims map[string]any) (*OIDCIdentity, error) {
+	identity := &OIDCIdentity{
+		Subject: claimString(claims, "sub"),
+		Roles:   claimStrings(lookupClaim(claims, s.cfg.RoleClaim)),
+	}
+	for _, name := range []string{"preferred_username", "email", "sub"} {
+		if identity.Username = claimString(claims, name); identity.Username != "" {
+			break
+		}
+	}
+
+	if s.cfg.AdminRole != "" && slices.Contains(identity.Roles, s.cfg.AdminRole) {
+		identity.Admin = true
+		identity.TenantID = adminTenantID
+		identity.Role = RoleOwner
+		return identity, nil
+	}
+
+	// Only the admin role grants the admin tenant, never a tenant claim
+	tenantID := claimString(claims, s.cfg.TenantClaim)
+	if id, err := strconv.Atoi(tenantID); err != nil || id <= 0 || tenantID == adminTenantID {
+		return nil, ErrOIDCNoTenant
+	}
+	identity.TenantID = tenantID
+
+	if identity.Role = s.roleOf(identity.Roles); identity.Role == "" {
+		return nil, ErrOIDCNoRole
+	}
+	return identity, nil
+}
+
+// roleOf maps the IdP roles of a user to the most privileged GoPay role they are mapped to,
+// or the default role when none is
+func (s *OIDCService) roleOf(idpRoles []string) Role {
+	for _, role := range oidcRolePrecedence {
+		for _, idpRole := range idpRoles {
+			if s.cfg.RoleMapping[idpRole] == role {
+				return role
+			}
+		}
+	}
+	return s.cfg.DefaultRole
+}
+
+// lookupClaim returns a claim by its dotted path
+func lookupClaim(claims map[string]any, path string) any {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// claimString returns a string or numeric claim as a string
+func claimString(claims map[string]any, path string) string {
+	switch value := lookupClaim(claims, path).(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	}
+	return ""
+}
+
+// claimStrings returns a list or space separated claim as strings
+func claimStrings(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func (s *OIDCService) signState(login oidcLoginState) (string, error) {
+	payload, err := json.Marshal(login)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.stateSignature(encoded), nil
+}
+
+func (s *OIDCService) verifyState(state string) (*oidcLoginState, error) {
+	encoded, signature, ok := strings.Cut(state, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.stateSignature(encoded))) {
+		return nil, ErrOIDCStateInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrOIDCStateInvalid
+	}
+	var login oidcLoginState
+	if err := json.Unmarshal(payload, &login); err != nil {
+		return nil, ErrOIDCStateInvalid
+	}
+	if s.now().Unix() > login.ExpiresAt {
+		return nil, ErrOIDCStateInvalid
+	}
+	return &login, nil
+}
+
+func (s *OIDCService) stateSignature(encoded string) string {
+	mac := hmac.New(sha256.New, s.stateKey)
+	mac.Write([]byte("oidc-state:" + encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken() (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate OIDC state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeIdP is an OpenID Connect provider that issues ID tokens with the claims of the test
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func newTestOIDCService(t *testing.T, idp *fakeIdP) *OIDCService {
+	t.Helper()
+	return newTestOIDCServiceWithDefaultRole(t, idp, RoleReadOnly)
+}
+
+func newTestOIDCServiceWithDefaultRole(t *testing.T, idp *fakeIdP, defaultRole Role) *OIDCService {
+	t.Helper()
+	service, err := NewOIDCService(context.Background(), OIDCConfig{
+		IssuerURL:   idp.server.URL,
+		ClientID:    "gopay",
+		RedirectURL: "https://gopay.example.com/v1/auth/oidc/callback",
+		TenantClaim: "tenant_id",
+		RoleClaim:   "realm_access.roles",
+		AdminRole:   "gopay-admin",
+		RoleMapping: map[string]Role{
+			"gopay-owner":     RoleOwner,
+			"gopay-developer": RoleDeveloper,
+			"gopay-finance":   RoleFinance,
+			"gopay-auditor":   RoleReadOnly,
+		},
+		DefaultRole: defaultRole,
+	}, []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewOIDCService: %v", err)
+	}
+	return service
+}
+
+// beginLogin starts a login and has the IdP issue its next ID token for the nonce of the login
+func beginLogin(t *testing.T, service *OIDCService, idp *fakeIdP, claims jwt.MapClaims) (returnedState, state string) {
+	t.Helper()
+	authURL, state, err := service.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "gopay" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	idp.claims = jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   "gopay",
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idp.claims[name] = value
+	}
+	return query.Get("state"), state
+}
+
+func TestOIDCService_Complete(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, idp)
+
+	returnedState, state := beginLogin(t, service, idp, jwt.MapClaims{"preferred_username": "jane", "tenant_id": 7})
+	identity, err := service.Complete(context.Background(), "good-code", returnedState, state)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if identity.TenantID != "7" || identity.Username != "jane" || identity.Admin || identity.Role != RoleReadOnly {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCService_CompleteRoleMapping(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, idp)
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  Role
+	}{
+		{"owner", []string{"gopay-owner"}, RoleOwner},
+		{"developer", []string{"offline_access", "gopay-developer"}, RoleDeveloper},
+		{"finance", []string{"gopay-finance"}, RoleFinance},
+		{"read-only", []string{"gopay-auditor"}, RoleReadOnly},
+		{"most privileged of several", []string{"gopay-auditor", "gopay-finance", "gopay-developer"}, RoleDeveloper},
+		{"unmapped roles get the default", []string{"offline_access"}, RoleReadOnly},
+		{"no roles get the default", nil, RoleReadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			returnedState, state := beginLogin(t, service, idp, jwt.MapClaims{
+				"preferred_username": "jane",
+				"tenant_id":          "7",
+				"realm_access":       map[string]any{"roles": tt.roles},
+			})
+			identity, err := service.Complete(context.Background(), "good-code", returnedState, state)
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if identity.Role != tt.want {
+				t.Fatalf("Role = %q, want %q", identity.Role, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCService_CompleteWithoutDefaultRole(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCServiceWithDefaultRole(t, idp, "")
+
+	returnedState, state := beginLogin(t, service, idp, jwt.MapClaims{
+		"tenant_id":    "7",
+		"realm_access": map[string]any{"roles": []string{"offline_access"}},
+	})
+	if _, err := service.Complete(context.Background(), "good-code", returnedState, state); !errors.Is(err, ErrOIDCNoRole) {
+		t.Fatalf("Complete error = %v, want %v", err, ErrOIDCNoRole)
+	}
+
+	returnedState, state = beginLogin(t, service, idp, jwt.MapClaims{
+		"tenant_id":    "7",
+		"realm_access": map[string]any{"roles": []string{"gopay-finance"}},
+	})
+	identity, err := service.Complete(context.Background(), "good-code", returnedState, state)
+	if err != nil || identity.Role != RoleFinance {
+		t.Fatalf("Complete = %+v, %v, want a finance identity", identity, err)
+	}
+}
+
+func TestOIDCConfigFromEnv_Roles(t *testing.T) {
+	t.Setenv("OIDC_ROLE_MAPPING", " gopay-devs = developer ,accounting=finance,,broken")
+	t.Setenv("OIDC_DEFAULT_ROLE", "none")
+
+	cfg := OIDCConfigFromEnv()
+	if len(cfg.RoleMapping) != 2 || cfg.RoleMapping["gopay-devs"] != RoleDeveloper || cfg.RoleMapping["accounting"] != RoleFinance {
+		t.Fatalf("unexpected role mapping %v", cfg.RoleMapping)
+	}
+	if cfg.DefaultRole != "" {
+		t.Fatalf("DefaultRole = %q, want none", cfg.DefaultRole)
+	}
+
+	t.Setenv("OIDC_DEFAULT_ROLE", "")
+	if cfg := OIDCConfigFromEnv(); cfg.DefaultRole != RoleReadOnly {
+		t.Fatalf("DefaultRole = %q, want %q", cfg.DefaultRole, RoleReadOnly)
+	}
+}
+
+func TestNewOIDCService_InvalidRoles(t *testing.T) {
+	idp := newFakeIdP(t)
+	for name, cfg := range map[string]OIDCConfig{
+		"mapping": {RoleMapping: map[string]Role{"gopay-admins": "admin"}, DefaultRole: RoleReadOnly},
+		"default": {DefaultRole: "viewer"},
+	} {
+		cfg.IssuerURL, cfg.ClientID = idp.server.URL, "gopay"
+		if _, err := NewOIDCService(context.Background(), cfg, []byte("secret")); !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("%s: NewOIDCService error = %v, want %v", name, err, ErrInvalidRole)
+		}
+	}
+}
+
+func TestOIDCService_CompleteAdminRole(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, idp)
+
+	returnedState, state := beginLogin(t, service, idp, jwt.MapClaims{
+		"email":        "admin@example.com",
+		"realm_access": map[string]any{"roles": []string{"offline_access", "gopay-admin"}},
+	})
+	identity, err := service.Complete(context.Background(), "good-code", returnedState, state)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if identity.TenantID != "1" || identity.Username != "admin@example.com" || !identity.Admin || identity.Role != RoleOwner {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCService_CompleteRejects(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, idp)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		tamper func(returnedState, state string) (string, string, string)
+		want   error
+	}{
+		{
+			name:   "no tenant claim",
+			claims: jwt.MapClaims{},
+			want:   ErrOIDCNoTenant,
+		},
+		{
+			name:   "admin tenant without admin role",
+			claims: jwt.MapClaims{"tenant_id": "1"},
+			want:   ErrOIDCNoTenant,
+		},
+		{
+			name:   "state of another login",
+			claims: jwt.MapClaims{"tenant_id": "7"},
+			tamper: func(_, state string) (string, string, string) { return "good-code", "other", state },
+			want:   ErrOIDCStateInvalid,
+		},
+		{
+			name:   "forged state",
+			claims: jwt.MapClaims{"tenant_id": "7"},
+			tamper: func(returnedState, state string) (string, string, string) {
+				return "good-code", returnedState, state + "x"
+			},
+			want: ErrOIDCStateInvalid,
+		},
+		{
+			name:   "wrong audience",
+			claims: jwt.MapClaims{"tenant_id": "7", "aud": "other-client"},
+			want:   ErrOIDCTokenInvalid,
+		},
+		{
+			name:   "replayed nonce",
+			claims: jwt.MapClaims{"tenant_id": "7", "nonce": "old"},
+			want:   ErrOIDCTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			returnedState, state := beginLogin(t, service, idp, tt.claims)
+			code := "good-code"
+			if tt.tamper != nil {
+				code, returnedState, state = tt.tamper(returnedState, state)
+			}
+			_, err := service.Complete(context.Background(), code, returnedState, state)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Complete error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCService_ExpiredState(t *testing.T) {
+	idp := newFakeIdP(t)
+	service := newTestOIDCService(t, idp)
+
+	returnedState, state := beginLogin(t, service, idp, jwt.MapClaims{"tenant_id": "7"})
+	service.now = func() time.Time { return time.Now().Add(oidcStateTTL + time.Minute) }
+	if _, err := service.Complete(context.Background(), "good-code", returnedState, state); !errors.Is(err, ErrOIDCStateInvalid) {
+		t.Fatalf("Complete error = %v, want %v", err, ErrOIDCStateInvalid)
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	// RefreshTokenPrefix marks GoPay refresh tokens
+	RefreshTokenPrefix = "gprt_"
+
+	// defaultRefreshTokenTTL is how long a refresh token can be exchanged
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// SessionRevokedReuse is the revoke reason of a session whose refresh token was used twice
+	SessionRevokedReuse = "token_reuse"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// RefreshToken is a stored refresh token. Only a hash of the token is kept. Each token is
+// exchanged once, for a new access token and the next refresh token of the same session.
+type RefreshToken struct {
+	ID        string
+	SessionID string
+	TenantID  int
+	Username  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// RefreshTokenStore persists refresh tokens. PostgresRefreshTokenStore is the production
+// implementation.
+type RefreshTokenStore interface {
+	// Create inserts a refresh token with the hash of its secret
+	Create(ctx context.Context, token *RefreshToken, hash string) error
+
+	// GetByHash returns the refresh token with a hash, or ErrInvalidRefreshToken
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+
+	// MarkUsed records that a token was exchanged and reports whether it was unused
+	MarkUsed(ctx context.Context, id string, now time.Time) (bool, error)
+
+	// DeleteExpired deletes the tokens that expired before a time
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// RefreshTokenService issues opaque refresh tokens bound to login sessions. They live
+// REFRESH_TOKEN_TTL (default 720h) and are rotated on use; presenting a used token again
+// means it leaked, so the whole session is revoked. Revoking the session revokes its refresh
+// tokens.
+type RefreshTokenService struct {
+	store RefreshTokenStore
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewRefreshTokenService creates a refresh token service
+func NewRefreshTokenService(store RefreshTokenStore) *RefreshTokenService {
+	return &RefreshTokenService{
+		store: store,
+		ttl:   durationEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+		now:   time.Now,
+	}
+}
+
+// TTL returns how long refresh tokens live
+func (s *RefreshTokenService) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue returns a new refresh token for a session and its expiry
+func (s *RefreshTokenService) Issue(ctx context.Context, session *Session) (string, time.Time, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	secret := RefreshTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	now := s.now()
+	token := &RefreshToken{
+		ID:        uuid.New().String(),
+		SessionID: session.ID,
+		TenantID:  session.TenantID,
+		Username:  session.Username,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.store.Create(ctx, token, hashAPIKey(secret)); err != nil {
+		return "", time.Time{}, err
+	}
+	return secret, token.ExpiresAt, nil
+}
+
+// Use exchanges a refresh token, which cannot be used again. It returns ErrRefreshTokenReused,
+// with the token, when it was used before.
+func (s *RefreshTokenService) Use(ctx context.Context, secret string) (*RefreshToken, error) {
+	token, err := s.Lookup(ctx, secret)
+	if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil {
+		return token, ErrRefreshTokenReused
+	}
+
+	unused, err := s.store.MarkUsed(ctx, token.ID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !unused {
+		return token, ErrRefreshTokenReused
+	}
+	return token, nil
+}
+
+// Lookup returns the unexpired refresh token of a secret without using it
+func (s *RefreshTokenService) Lookup(ctx context.Context, secret string) (*RefreshToken, error) {
+	token, err := s.store.GetByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	if !token.ExpiresAt.After(s.now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return token, nil
+}
+
+// Cleanup deletes the expired refresh tokens
+func (s *RefreshTokenService) Cleanup(ctx context.Context) error {
+	return s.store.DeleteExpired(ctx, s.now())
+}
+
+// PostgresRefreshTokenStore keeps refresh tokens in the refresh_tokens table.
+type PostgresRefreshTokenStore struct {
+	db *sql.DB
+}
+
+// NewPostgresRefreshTokenStore creates a store over the shared *sql.DB connection.
+func NewPostgresRefreshTokenStore(db *sql.DB) *PostgresRefreshTokenStore {
+	return &PostgresRefreshTokenStore{db: db}
+}
+
+// Create inserts a refresh token
+func (r *PostgresRefreshTokenStore) Create(ctx context.Context, token *RefreshToken, hash string) error {
+	query := `
+		INSERT INTO refresh_tokens (id, session_id, tenant_id, username, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID, token.SessionID, token.TenantID, token.Username, hash, token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns the refresh token with a hash
+func (r *PostgresRefreshTokenStore) GetByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	query := `
+		SELECT id, session_id, tenant_id, COALESCE(username, ''), created_at, expires_at, used_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`
+
+	var token RefreshToken
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID, &token.SessionID, &token.TenantID, &token.Username, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// MarkUsed records that a token was exchanged
+func (r *PostgresRefreshTokenStore) MarkUsed(ctx context.Context, id string, now time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DeleteExpired deletes the tokens that expired before a time
+func (r *PostgresRefreshTokenStore) DeleteExpired(ctx context.Context, before time.Time) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+		logger.Info("Expired refresh tokens deleted", logger.LogContext{
+			Fields: map[string]any{
+				"count": deleted,
+			},
+		})
+	}
+	return nil
+}
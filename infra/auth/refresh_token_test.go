@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRefreshTokenStore is an in-memory RefreshTokenStore for tests.
+type memoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken // by hash
+}
+
+func newMemoryRefreshTokenStore() *memoryRefreshTokenStore {
+	return &memoryRefreshTokenStore{tokens: make(map[string]RefreshToken)}
+}
+
+func (m *memoryRefreshTokenStore) Create(_ context.Context, token *RefreshToken, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[hash] = *token
+	return nil
+}
+
+func (m *memoryRefreshTokenStore) GetByHash(_ context.Context, hash string) (*RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[hash]
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	return &token, nil
+}
+
+func (m *memoryRefreshTokenStore) MarkUsed(_ context.Context, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, token := range m.tokens {
+		if token.ID == id && token.UsedAt == nil {
+			token.UsedAt = &now
+			m.tokens[hash] = token
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryRefreshTokenStore) DeleteExpired(_ context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, token := range m.tokens {
+		if token.ExpiresAt.Before(before) {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// newTestRefreshJWTService returns a JWT service with sessions and refresh tokens
+func newTestRefreshJWTService(t *testing.T) (*JWTService, *SessionService, *memoryRefreshTokenStore) {
+	t.Setenv("REFRESH_TOKEN_TTL", "48h")
+	sessions, _ := newTestSessionService(t, "5")
+	store := newMemoryRefreshTokenStore()
+	service := &JWTService{secretKey: []byte("test-secret"), expiry: time.Hour}
+	service.SetSessionService(sessions)
+	service.SetRefreshTokens(NewRefreshTokenService(store))
+	return service, sessions, store
+}
+
+func TestJWTService_RefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newTestRefreshJWTService(t)
+
+	first, err := service.IssueTokens(ctx, "7", "alice", SessionClient{})
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+	if !strings.HasPrefix(first.RefreshToken, RefreshTokenPrefix) || first.RefreshExpiresAt == nil {
+		t.Fatalf("Expected a refresh token with its expiry, got %+v", first)
+	}
+
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Expected the refresh token to be rotated")
+	}
+	firstClaims, _ := service.ValidateToken(first.Token)
+	secondClaims, err := service.ValidateToken(second.Token)
+	if err != nil || secondClaims.ID != firstClaims.ID || secondClaims.Username != "alice" {
+		t.Fatalf("Expected a token of alice's session, got %+v, %v", secondClaims, err)
+	}
+
+	// Using the first refresh token again means it leaked: the session is revoked
+	if _, err := service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := service.ValidateToken(second.Token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected the session's tokens to be rejected, got %v", err)
+	}
+	if _, err := service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected the rotated refresh token to stop working, got %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, "gprt_unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestJWTService_RevokeRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	service, sessions, _ := newTestRefreshJWTService(t)
+
+	tokens, err := service.IssueTokens(ctx, "7", "alice", SessionClient{})
+	if err != nil {
+		t.Fatalf("IssueTokens failed: %v", err)
+	}
+
+	if err := service.RevokeRefreshToken(ctx, 8, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected another tenant's refresh token to be rejected, got %v", err)
+	}
+	if err := service.RevokeRefreshToken(ctx, 7, tokens.RefreshToken); err != nil {
+		t.Fatalf("RevokeRefreshToken failed: %v", err)
+	}
+	if _, err := service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected the revoked refresh token to stop working, got %v", err)
+	}
+
+	// Revoking all of the tenant's sessions logs every user out
+	alice, _ := service.IssueTokens(ctx, "7", "alice", SessionClient{})
+	bob, _ := service.IssueTokens(ctx, "7", "bob", SessionClient{})
+	other, _ := service.IssueTokens(ctx, "8", "carol", SessionClient{})
+	if err := sessions.RevokeAll(ctx, 7, SessionRevokedByUser); err != nil {
+		t.Fatalf("RevokeAll failed: %v", err)
+	}
+	for _, pair := range []*TokenPair{alice, bob} {
+		if _, err := service.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("Expected the tenant's sessions to be revoked, got %v", err)
+		}
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Expected other tenants' sessions to stay active, got %v", err)
+	}
+}
+
+func TestRefreshTokenService_ExpiryAndCleanup(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("REFRESH_TOKEN_TTL", "1h")
+	store := newMemoryRefreshTokenStore()
+	service := NewRefreshTokenService(store)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	secret, _, err := service.Issue(ctx, &Session{ID: "s1", TenantID: 7, Username: "alice"})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	for hash := range store.tokens {
+		if strings.Contains(secret, hash) || hash == secret {
+			t.Fatal("Expected only a hash of the token to be stored")
+		}
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := service.Use(ctx, secret); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected an expired refresh token to be rejected, got %v", err)
+	}
+	if err := service.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if len(store.tokens) != 0 {
+		t.Errorf("Expected expired refresh tokens to be deleted, got %d", len(store.tokens))
+	}
+}
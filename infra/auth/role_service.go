@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Role is what a user may do within their tenant
+type Role string
+
+const (
+	// RoleOwner may do everything, including assigning roles. Users without an assigned role
+	// are owners, as every login was before roles existed.
+	RoleOwner Role = "owner"
+
+	// RoleDeveloper integrates GoPay: payments and provider configuration, but no refunds
+	RoleDeveloper Role = "developer"
+
+	// RoleFinance follows payments and refunds them, but does not create them
+	RoleFinance Role = "finance"
+
+	// RoleReadOnly only reads payments, configuration and analytics
+	RoleReadOnly Role = "read-only"
+)
+
+// Permission is an action a role allows
+type Permission string
+
+const (
+	PermissionPaymentsRead  Permission = "payments:read"
+	PermissionPaymentsWrite Permission = "payments:write"
+	PermissionRefunds       Permission = "refunds:write"
+	PermissionConfigRead    Permission = "config:read"
+	PermissionConfigWrite   Permission = "config:write"
+	PermissionAnalyticsRead Permission = "analytics:read"
+	PermissionManageUsers   Permission = "users:manage"
+)
+
+// rolePermissions lists the permissions of each role
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionPaymentsRead, PermissionPaymentsWrite, PermissionRefunds,
+		PermissionConfigRead, PermissionConfigWrite, PermissionAnalyticsRead, PermissionManageUsers,
+	},
+	RoleDeveloper: {PermissionPaymentsRead, PermissionPaymentsWrite, PermissionConfigRead, PermissionConfigWrite, PermissionAnalyticsRead},
+	RoleFinance:   {PermissionPaymentsRead, PermissionRefunds, PermissionAnalyticsRead},
+	RoleReadOnly:  {PermissionPaymentsRead, PermissionConfigRead, PermissionAnalyticsRead},
+}
+
+var (
+	ErrInvalidRole   = errors.New("invalid role, expected owner, developer, finance or read-only")
+	ErrRoleNotFound  = errors.New("role assignment not found")
+	ErrOwnRoleChange = errors.New("a user cannot change their own role")
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Allows reports whether the role has a permission
+func (r Role) Allows(permission Permission) bool {
+	return slices.Contains(rolePermissions[r], permission)
+}
+
+// UserRole is the role assigned to a user of a tenant
+type UserRole struct {
+	TenantID  int       `json:"tenant_id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RoleStore persists role assignments. PostgresRoleStore is the production implementation.
+type RoleStore interface {
+	// Get returns the assignment of a user, or ErrRoleNotFound
+	Get(ctx context.Context, tenantID int, username string) (*UserRole, error)
+
+	// Set assigns a role to a user, replacing their previous one
+	Set(ctx context.Context, role *UserRole) error
+
+	// List returns the tenant's assignments by username
+	List(ctx context.Context, tenantID int) ([]UserRole, error)
+
+	// Delete removes the assignment of a user and reports whether there was one
+	Delete(ctx context.Context, tenantID int, username string) (bool, error)
+}
+
+// RoleService assigns roles to the users of a tenant and resolves the role of a request
+type RoleService struct {
+	store RoleStore
+	now   func() time.Time
+}
+
+// NewRoleService creates a role service
+func NewRoleService(store RoleStore) *RoleService {
+	return &RoleService{store: store, now: time.Now}
+}
+
+// RoleOf returns the role of a user, RoleOwner when none is assigned
+func (s *RoleService) RoleOf(ctx context.Context, tenantID int, username string) (Role, error) {
+	assigned, err := s.store.Get(ctx, tenantID, username)
+	if errors.Is(err, ErrRoleNotFound) {
+		return RoleOwner, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return assigned.Role, nil
+}
+
+// Assign sets the role of a user of the tenant. actor, the user making the change, cannot
+// change their own role, so a tenant always keeps the owner who made the assignments.
+func (s *RoleService) Assign(ctx context.Context, tenantID int, actor, username string, role Role) (*UserRole, error) {
+	username = strings.TrimSpace(username)
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if username == actor {
+		return nil, ErrOwnRoleChange
+	}
+
+	assigned := &UserRole{TenantID: tenantID, Username: username, Role: role, UpdatedAt: s.now()}
+	if err := s.store.Set(ctx, assigned); err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+// Sync records the role an identity provider gives a user at sign-in, replacing the one
+// recorded at their previous sign-in. The IdP is the authority, so there is no actor.
+func (s *RoleService) Sync(ctx context.Context, tenantID int, username string, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	return s.store.Set(ctx, &UserRole{TenantID: tenantID, Username: username, Role: role, UpdatedAt: s.now()})
+}
+
+// List returns the tenant's role assignments. Users without one are owners.
+func (s *RoleService) List(ctx context.Context, tenantID int) ([]UserRole, error) {
+	return s.store.List(ctx, tenantID)
+}
+
+// Remove deletes the role assignment of a user, who becomes an owner again
+func (s *RoleService) Remove(ctx context.Context, tenantID int, actor, username string) error {
+	if username == actor {
+		return ErrOwnRoleChange
+	}
+	deleted, err := s.store.Delete(ctx, tenantID, username)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// PostgresRoleStore keeps role assignments in the tenant_user_roles table.
+type PostgresRoleStore struct {
+	db *sql.DB
+}
+
+// NewPostgresRoleStore creates a store over the shared *sql.DB connection.
+func NewPostgresRoleStore(db *sql.DB) *PostgresRoleStore {
+	return &PostgresRoleStore{db: db}
+}
+
+// Get returns the assignment of a user
+func (r *PostgresRoleStore) Get(ctx context.Context, tenantID int, username string) (*UserRole, error) {
+	query := `SELECT tenant_id, username, role, updated_at FROM tenant_user_roles WHERE tenant_id = $1 AND username = $2`
+
+	var role UserRole
+	err := r.db.QueryRowContext(ctx, query, tenantID, username).Scan(&role.TenantID, &role.Username, &role.Role, &role.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+// Set assigns a role to a user
+func (r *PostgresRoleStore) Set(ctx context.Context, role *UserRole) error {
+	query := `
+		INSERT INTO tenant_user_roles (tenant_id, username, role, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, username) DO UPDATE SET role = EXCLUDED.role, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.ExecContext(ctx, query, role.TenantID, role.Username, role.Role, role.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	return nil
+}
+
+// List returns the tenant's assignments by username
+func (r *PostgresRoleStore) List(ctx context.Context, tenantID int) ([]UserRole, error) {
+	query := `SELECT tenant_id, username, role, updated_at FROM tenant_user_roles WHERE tenant_id = $1 ORDER BY username`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []UserRole
+	for rows.Next() {
+		var role UserRole
+		if err := rows.Scan(&role.TenantID, &role.Username, &role.Role, &role.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// Delete removes the assignment of a user
+func (r *PostgresRoleStore) Delete(ctx context.Context, tenantID int, username string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tenant_user_roles WHERE tenant_id = $1 AND username = $2`, tenantID, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete role: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// memoryRoleStore is an in-memory RoleStore for tests.
+type memoryRoleStore struct {
+	roles map[string]UserRole
+}
+
+func newMemoryRoleStore() *memoryRoleStore {
+	return &memoryRoleStore{roles: make(map[string]UserRole)}
+}
+
+func roleKey(tenantID int, username string) string {
+	return fmt.Sprintf("%d/%s", tenantID, username)
+}
+
+func (m *memoryRoleStore) Get(_ context.Context, tenantID int, username string) (*UserRole, error) {
+	role, ok := m.roles[roleKey(tenantID, username)]
+	if !ok {
+		return nil, ErrRoleNotFound
+	}
+	return &role, nil
+}
+
+func (m *memoryRoleStore) Set(_ context.Context, role *UserRole) error {
+	m.roles[roleKey(role.TenantID, role.Username)] = *role
+	return nil
+}
+
+func (m *memoryRoleStore) List(_ context.Context, tenantID int) ([]UserRole, error) {
+	var roles []UserRole
+	for _, role := range m.roles {
+		if role.TenantID == tenantID {
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Username < roles[j].Username })
+	return roles, nil
+}
+
+func (m *memoryRoleStore) Delete(_ context.Context, tenantID int, username string) (bool, error) {
+	key := roleKey(tenantID, username)
+	_, ok := m.roles[key]
+	delete(m.roles, key)
+	return ok, nil
+}
+
+func TestRoleService(t *testing.T) {
+	ctx := context.Background()
+	service := NewRoleService(newMemoryRoleStore())
+
+	// Users without an assigned role are owners
+	if role, err := service.RoleOf(ctx, 7, "alice"); err != nil || role != RoleOwner {
+		t.Fatalf("Expected owner, got %q (%v)", role, err)
+	}
+
+	if _, err := service.Assign(ctx, 7, "alice", "bob", RoleFinance); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if role, _ := service.RoleOf(ctx, 7, "bob"); role != RoleFinance {
+		t.Errorf("Expected finance, got %q", role)
+	}
+	if role, _ := service.RoleOf(ctx, 8, "bob"); role != RoleOwner {
+		t.Errorf("Expected the role to stay within its tenant, got %q", role)
+	}
+
+	if _, err := service.Assign(ctx, 7, "alice", "bob", "admin"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := service.Assign(ctx, 7, "alice", "alice", RoleReadOnly); !errors.Is(err, ErrOwnRoleChange) {
+		t.Errorf("Expected ErrOwnRoleChange, got %v", err)
+	}
+
+	if err := service.Remove(ctx, 7, "alice", "bob"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if role, _ := service.RoleOf(ctx, 7, "bob"); role != RoleOwner {
+		t.Errorf("Expected owner after removal, got %q", role)
+	}
+	if err := service.Remove(ctx, 7, "alice", "bob"); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("Expected ErrRoleNotFound, got %v", err)
+	}
+}
+
+func TestRoleService_Sync(t *testing.T) {
+	ctx := context.Background()
+	service := NewRoleService(newMemoryRoleStore())
+
+	if err := service.Sync(ctx, 7, "jane@example.com", RoleFinance); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if role, err := service.RoleOf(ctx, 7, "jane@example.com"); err != nil || role != RoleFinance {
+		t.Fatalf("RoleOf = %q, %v, want %q", role, err, RoleFinance)
+	}
+
+	// The next sign-in replaces the role, also with a less privileged one
+	if err := service.Sync(ctx, 7, "jane@example.com", RoleReadOnly); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if role, _ := service.RoleOf(ctx, 7, "jane@example.com"); role != RoleReadOnly {
+		t.Fatalf("RoleOf = %q, want %q", role, RoleReadOnly)
+	}
+
+	if err := service.Sync(ctx, 7, "jane@example.com", "admin"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("Sync error = %v, want %v", err, ErrInvalidRole)
+	}
+}
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{RoleOwner, PermissionManageUsers, true},
+		{RoleDeveloper, PermissionConfigWrite, true},
+		{RoleDeveloper, PermissionRefunds, false},
+		{RoleFinance, PermissionRefunds, true},
+		{RoleFinance, PermissionPaymentsWrite, false},
+		{RoleReadOnly, PermissionAnalyticsRead, true},
+		{RoleReadOnly, PermissionConfigWrite, false},
+		{Role("unknown"), PermissionPaymentsRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.permission); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
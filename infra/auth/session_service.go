@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	// defaultMaxSessions is the number of concurrent sessions a tenant user may hold
+	defaultMaxSessions = 5
+
+	// Session revoke reasons
+	SessionRevokedByUser  = "revoked"
+	SessionRevokedLogout  = "logout"
+	SessionRevokedByLimit = "limit_exceeded"
+	SessionRevokedUser    = "user_changed"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been revoked")
+)
+
+// Session is one login of a tenant user. Tokens issued on login and on every refresh of that
+// login share the session ID (the JWT "jti" claim), so revoking the session revokes the
+// whole token family.
+type Session struct {
+	ID           string     `json:"id"`
+	TenantID     int        `json:"tenant_id"`
+	Username     string     `json:"username,omitempty"`
+	ClientIP     string     `json:"client_ip,omitempty"`
+	UserAgent    string     `json:"user_agent,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RefreshedAt  *time.Time `json:"refreshed_at,omitempty"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+}
+
+// Active reports whether the session is neither revoked nor expired at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+// SessionClient describes where a login came from
+type SessionClient struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionStore persists sessions. PostgresSessionStore is the production implementation.
+type SessionStore interface {
+	// Create inserts a new session
+	Create(ctx context.Context, session *Session) error
+
+	// Get returns a session by ID, or ErrSessionNotFound
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// ListActive returns the tenant's unrevoked, unexpired sessions, oldest first
+	ListActive(ctx context.Context, tenantID int, now time.Time) ([]Session, error)
+
+	// Revoke revokes an active session of the tenant and reports whether one was revoked
+	Revoke(ctx context.Context, tenantID int, id, reason string, now time.Time) (bool, error)
+
+	// RevokeUser revokes the active sessions of a user of the tenant and returns how many
+	RevokeUser(ctx context.Context, tenantID int, username, reason string, now time.Time) (int, error)
+
+	// Extend moves the expiry of a session after its token was refreshed
+	Extend(ctx context.Context, id string, expiresAt, now time.Time) error
+}
+
+// SessionService enforces the concurrent session limit per tenant user. When a login would
+// exceed the limit the oldest sessions are revoked, so a shared or stolen credential
+// shows up as the legitimate user being logged out.
+type SessionService struct {
+	store       SessionStore
+	maxSessions int
+	now         func() time.Time
+}
+
+// NewSessionService creates a session service. The limit is read from
+// MAX_SESSIONS_PER_TENANT (default 5, 0 disables the limit).
+func NewSessionService(store SessionStore) *SessionService {
+	maxSessions := config.GetIntEnv("MAX_SESSIONS_PER_TENANT", defaultMaxSessions)
+	if maxSessions < 0 {
+		maxSessions = defaultMaxSessions
+	}
+
+	return &SessionService{
+		store:       store,
+		maxSessions: maxSessions,
+		now:         time.Now,
+	}
+}
+
+// MaxSessions returns the concurrent session limit, 0 meaning unlimited
+func (s *SessionService) MaxSessions() int {
+	return s.maxSessions
+}
+
+// Start records a new session for a user of the tenant and evicts their oldest ones beyond
+// the limit
+func (s *SessionService) Start(ctx context.Context, tenantID int, username string, client SessionClient, ttl time.Duration) (*Session, error) {
+	now := s.now()
+	session := &Session{
+		ID:        uuid.New().String(),
+		TenantID:  tenantID,
+		Username:  username,
+		ClientIP:  client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := s.store.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	if s.maxSessions == 0 {
+		return session, nil
+	}
+
+	tenantSessions, err := s.store.ListActive(ctx, tenantID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users of the same tenant do not evict each other
+	var active []Session
+	for _, existing := range tenantSessions {
+		if existing.Username == username {
+			active = append(active, existing)
+		}
+	}
+
+	for i := 0; i < len(active)-s.maxSessions; i++ {
+		evicted := active[i]
+		if evicted.ID == session.ID {
+			continue
+		}
+		if _, err := s.store.Revoke(ctx, tenantID, evicted.ID, SessionRevokedByLimit, now); err != nil {
+			return nil, err
+		}
+
+		logger.Warn("Session limit exceeded, oldest session revoked", logger.LogContext{
+			TenantID: fmt.Sprintf("%d", tenantID),
+			Fields: map[string]any{
+				"username":        username,
+				"evicted_session": evicted.ID,
+				"evicted_ip":      evicted.ClientIP,
+				"new_session":     session.ID,
+				"new_ip":          client.IP,
+				"max_sessions":    s.maxSessions,
+			},
+		})
+	}
+
+	return session, nil
+}
+
+// Check returns ErrSessionRevoked unless the session is active
+func (s *SessionService) Check(ctx context.Context, id string) error {
+	session, err := s.store.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+
+	if !session.Active(s.now()) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// Refresh extends an active session after its token was refreshed
+func (s *SessionService) Refresh(ctx context.Context, id string, ttl time.Duration) error {
+	if err := s.Check(ctx, id); err != nil {
+		return err
+	}
+	now := s.now()
+	return s.store.Extend(ctx, id, now.Add(ttl), now)
+}
+
+// List returns the tenant's active sessions, oldest first
+func (s *SessionService) List(ctx context.Context, tenantID int) ([]Session, error) {
+	return s.store.ListActive(ctx, tenantID, s.now())
+}
+
+// Revoke revokes one of the tenant's sessions. Sessions of other tenants are reported as
+// ErrSessionNotFound.
+func (s *SessionService) Revoke(ctx context.Context, tenantID int, id, reason string) error {
+	revoked, err := s.store.Revoke(ctx, tenantID, id, reason, s.now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUser revokes the sessions of a user of the tenant, logging them out everywhere
+func (s *SessionService) RevokeUser(ctx context.Context, tenantID int, username, reason string) error {
+	_, err := s.store.RevokeUser(ctx, tenantID, username, reason, s.now())
+	return err
+}
+
+// RevokeAll revokes every active session of the tenant, logging all of its users out
+func (s *SessionService) RevokeAll(ctx context.Context, tenantID int, reason string) error {
+	sessions, err := s.List(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := s.Revoke(ctx, tenantID, session.ID, reason); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PostgresSessionStore keeps sessions in the auth_sessions table.
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresSessionStore creates a store over the shared *sql.DB connection.
+func NewPostgresSessionStore(db *sql.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db}
+}
+
+// Create inserts a new session
+func (r *PostgresSessionStore) Create(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO auth_sessions (id, tenant_id, username, client_ip, user_agent, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		session.ID, session.TenantID, session.Username, session.ClientIP, session.UserAgent, session.CreatedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// Get returns a session by ID
+func (r *PostgresSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(username, ''), COALESCE(client_ip, ''), COALESCE(user_agent, ''), created_at, refreshed_at, expires_at, revoked_at, COALESCE(revoke_reason, '')
+		FROM auth_sessions
+		WHERE id = $1`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// ListActive returns the tenant's active sessions, oldest first
+func (r *PostgresSessionStore) ListActive(ctx context.Context, tenantID int, now time.Time) ([]Session, error) {
+	query := `
+		SELECT id, tenant_id, COALESCE(username, ''), COALESCE(client_ip, ''), COALESCE(user_agent, ''), created_at, refreshed_at, expires_at, revoked_at, COALESCE(revoke_reason, '')
+		FROM auth_sessions
+		WHERE tenant_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke revokes an active session of the tenant
+func (r *PostgresSessionStore) Revoke(ctx context.Context, tenantID int, id, reason string, now time.Time) (bool, error) {
+	query := `
+		UPDATE auth_sessions SET revoked_at = $3, revoke_reason = $4
+		WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID, now, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeUser revokes the active sessions of a user of the tenant
+func (r *PostgresSessionStore) RevokeUser(ctx context.Context, tenantID int, username, reason string, now time.Time) (int, error) {
+	query := `
+		UPDATE auth_sessions SET revoked_at = $3, revoke_reason = $4
+		WHERE tenant_id = $1 AND username = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, tenantID, username, now, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// Extend moves the expiry of a session
+func (r *PostgresSessionStore) Extend(ctx context.Context, id string, expiresAt, now time.Time) error {
+	query := `UPDATE auth_sessions SET expires_at = $2, refreshed_at = $3 WHERE id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, id, expiresAt, now); err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return nil
+}
+
+type sessionScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row sessionScanner) (*Session, error) {
+	var session Session
+	err := row.Scan(&session.ID, &session.TenantID, &session.Username, &session.ClientIP, &session.UserAgent,
+		&session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt, &session.RevokedAt, &session.RevokeReason)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySessionStore is an in-memory SessionStore for tests.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (m *memorySessionStore) Create(_ context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *session
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *memorySessionStore) Get(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	stored := *session
+	return &stored, nil
+}
+
+func (m *memorySessionStore) ListActive(_ context.Context, tenantID int, now time.Time) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var active []Session
+	for _, session := range m.sessions {
+		if session.TenantID == tenantID && session.Active(now) {
+			active = append(active, *session)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	return active, nil
+}
+
+func (m *memorySessionStore) Revoke(_ context.Context, tenantID int, id, reason string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.TenantID != tenantID || session.RevokedAt != nil {
+		return false, nil
+	}
+	session.RevokedAt = &now
+	session.RevokeReason = reason
+	return true, nil
+}
+
+func (m *memorySessionStore) RevokeUser(_ context.Context, tenantID int, username, reason string, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revoked := 0
+	for _, session := range m.sessions {
+		if session.TenantID == tenantID && session.Username == username && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			session.RevokeReason = reason
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *memorySessionStore) Extend(_ context.Context, id string, expiresAt, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[id]; ok {
+		session.ExpiresAt = expiresAt
+		session.RefreshedAt = &now
+	}
+	return nil
+}
+
+// newTestSessionService returns a service whose clock advances one second per login, so
+// session ages are distinct
+func newTestSessionService(t *testing.T, maxSessions string) (*SessionService, *memorySessionStore) {
+	t.Setenv("MAX_SESSIONS_PER_TENANT", maxSessions)
+	store := newMemorySessionStore()
+	s := NewSessionService(store)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return s, store
+}
+
+func TestSessionService_EvictsOldestWhenLimitExceeded(t *testing.T) {
+	s, store := newTestSessionService(t, "2")
+	ctx := context.Background()
+
+	first, err := s.Start(ctx, 1, "admin", SessionClient{IP: "10.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	second, _ := s.Start(ctx, 1, "admin", SessionClient{IP: "10.0.0.2"}, time.Hour)
+	// Another tenant's sessions do not count against tenant 1
+	if _, err := s.Start(ctx, 2, "admin", SessionClient{IP: "10.0.0.9"}, time.Hour); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	third, _ := s.Start(ctx, 1, "admin", SessionClient{IP: "10.0.0.3"}, time.Hour)
+
+	if err := s.Check(ctx, first.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected oldest session to be revoked, got %v", err)
+	}
+	if reason := store.sessions[first.ID].RevokeReason; reason != SessionRevokedByLimit {
+		t.Errorf("Expected revoke reason %q, got %q", SessionRevokedByLimit, reason)
+	}
+	for _, session := range []*Session{second, third} {
+		if err := s.Check(ctx, session.ID); err != nil {
+			t.Errorf("Expected session %s to stay active, got %v", session.ClientIP, err)
+		}
+	}
+
+	active, _ := s.List(ctx, 1)
+	if len(active) != 2 || active[0].ID != second.ID || active[1].ID != third.ID {
+		t.Errorf("Expected the two newest sessions to be active, got %+v", active)
+	}
+
+	other, _ := s.List(ctx, 2)
+	if len(other) != 1 {
+		t.Errorf("Expected tenant 2 to keep its session, got %d", len(other))
+	}
+}
+
+func TestSessionService_ZeroMeansUnlimited(t *testing.T) {
+	s, _ := newTestSessionService(t, "0")
+	ctx := context.Background()
+
+	for range 10 {
+		if _, err := s.Start(ctx, 1, "admin", SessionClient{}, time.Hour); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	}
+
+	active, _ := s.List(ctx, 1)
+	if len(active) != 10 {
+		t.Errorf("Expected 10 active sessions without a limit, got %d", len(active))
+	}
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	s, _ := newTestSessionService(t, "5")
+	ctx := context.Background()
+
+	session, _ := s.Start(ctx, 1, "admin", SessionClient{}, time.Hour)
+
+	// Another tenant cannot revoke it
+	if err := s.Revoke(ctx, 2, session.ID, SessionRevokedByUser); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound for another tenant, got %v", err)
+	}
+	if err := s.Check(ctx, session.ID); err != nil {
+		t.Fatalf("Expected session to stay active, got %v", err)
+	}
+
+	if err := s.Revoke(ctx, 1, session.ID, SessionRevokedByUser); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if err := s.Check(ctx, session.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected ErrSessionRevoked after revoke, got %v", err)
+	}
+	if err := s.Refresh(ctx, session.ID, time.Hour); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected revoked session refresh to fail, got %v", err)
+	}
+	if err := s.Revoke(ctx, 1, session.ID, SessionRevokedByUser); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected second revoke to report ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionService_LimitAndRevocationPerUser(t *testing.T) {
+	s, store := newTestSessionService(t, "1")
+	ctx := context.Background()
+
+	alice, _ := s.Start(ctx, 1, "alice", SessionClient{}, time.Hour)
+	bob, _ := s.Start(ctx, 1, "bob", SessionClient{}, time.Hour)
+
+	// Users of the same tenant do not evict each other
+	for _, session := range []*Session{alice, bob} {
+		if err := s.Check(ctx, session.ID); err != nil {
+			t.Errorf("Expected session of %s to stay active, got %v", session.Username, err)
+		}
+	}
+
+	if err := s.RevokeUser(ctx, 1, "alice", SessionRevokedUser); err != nil {
+		t.Fatalf("RevokeUser failed: %v", err)
+	}
+	if err := s.Check(ctx, alice.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected alice's session to be revoked, got %v", err)
+	}
+	if reason := store.sessions[alice.ID].RevokeReason; reason != SessionRevokedUser {
+		t.Errorf("Expected revoke reason %q, got %q", SessionRevokedUser, reason)
+	}
+	if err := s.Check(ctx, bob.ID); err != nil {
+		t.Errorf("Expected bob's session to stay active, got %v", err)
+	}
+}
+
+func TestSessionService_CheckUnknownSession(t *testing.T) {
+	s, _ := newTestSessionService(t, "5")
+
+	if err := s.Check(context.Background(), "unknown"); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected unknown session to be rejected, got %v", err)
+	}
+}
+
+func TestJWTService_SessionTokens(t *testing.T) {
+	sessions, _ := newTestSessionService(t, "1")
+	jwtService := &JWTService{secretKey: []byte("test-secret"), expiry: time.Hour}
+	jwtService.SetSessionService(sessions)
+	ctx := context.Background()
+
+	token, err := jwtService.StartSession(ctx, "1", "admin", SessionClient{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	claims, err := jwtService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected token to be valid, got %v", err)
+	}
+	if claims.ID == "" {
+		t.Fatal("Expected token to carry a session ID")
+	}
+
+	// Refreshing keeps the session
+	refreshed, err := jwtService.RefreshToken(token)
+	if err != nil {
+		t.Fatalf("RefreshToken failed: %v", err)
+	}
+	refreshedClaims, err := jwtService.ValidateToken(refreshed)
+	if err != nil || refreshedClaims.ID != claims.ID {
+		t.Fatalf("Expected refreshed token in the same session, got %v (err %v)", refreshedClaims, err)
+	}
+
+	// A second login exceeds the limit of 1 and evicts the first session with all its tokens
+	if _, err := jwtService.StartSession(ctx, "1", "admin", SessionClient{IP: "10.0.0.2"}); err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	for _, tok := range []string{token, refreshed} {
+		if _, err := jwtService.ValidateToken(tok); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("Expected evicted session token to be rejected, got %v", err)
+		}
+	}
+	if _, err := jwtService.RefreshToken(refreshed); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected evicted session token refresh to fail, got %v", err)
+	}
+
+	// Tokens without a session predate session tracking and stay valid until they expire
+	legacy, _ := jwtService.GenerateToken("1", "admin")
+	if _, err := jwtService.ValidateToken(legacy); err != nil {
+		t.Errorf("Expected token without session to be valid, got %v", err)
+	}
+}
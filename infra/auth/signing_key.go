@@ -0,0 +1,392 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	// defaultKeyRotation is how long a signing key signs tokens before it is replaced
+	defaultKeyRotation = 30 * 24 * time.Hour
+
+	// defaultKeyGracePeriod is how long a replaced key still validates the tokens it signed
+	defaultKeyGracePeriod = 24 * time.Hour
+
+	// signingKeyReloadInterval limits reloads for tokens signed with an unknown key, which is
+	// how a key created by another instance shows up
+	signingKeyReloadInterval = 10 * time.Second
+
+	// signingKeySize is the length of the HMAC-SHA256 secrets
+	signingKeySize = 32
+
+	// minSigningKeySecretLength is the shortest JWT_SIGNING_KEY_SECRET accepted
+	minSigningKeySecretLength = 32
+)
+
+var (
+	ErrSigningKeyNotFound      = errors.New("signing key not found")
+	ErrSigningKeySecretInvalid = errors.New("invalid JWT_SIGNING_KEY_SECRET")
+)
+
+// SigningKey is a JWT signing secret. The current key signs tokens; replaced keys keep
+// validating the tokens they signed until their grace period ends.
+type SigningKey struct {
+	ID        string
+	Secret    []byte
+	CreatedAt time.Time
+	RetiredAt *time.Time
+}
+
+// SigningKeyStore persists signing keys. PostgresSigningKeyStore is the production
+// implementation.
+type SigningKeyStore interface {
+	// List returns the keys retired after since or not retired, newest first
+	List(ctx context.Context, since time.Time) ([]SigningKey, error)
+
+	// Rotate retires the current key and stores key as the new one, unless a key created after
+	// dueBefore is current, which means another instance has just rotated. It reports whether
+	// key was stored.
+	Rotate(ctx context.Context, key *SigningKey, dueBefore time.Time) (bool, error)
+
+	// DeleteRetired deletes the keys retired before a time
+	DeleteRetired(ctx context.Context, before time.Time) error
+}
+
+// SigningKeyRing signs tokens with a key kept in the database instead of JWT_SECRET, so
+// tokens survive restarts and are shared by every instance. The key is replaced every
+// JWT_KEY_ROTATION_INTERVAL (default 720h); replaced keys validate for JWT_KEY_GRACE_PERIOD
+// (default 24h, at least the token lifetime) so no one is logged out by a rotation.
+type SigningKeyRing struct {
+	store    SigningKeyStore
+	rotation time.Duration
+	grace    time.Duration
+	now      func() time.Time
+
+	mu         sync.RWMutex
+	keys       map[string]SigningKey
+	current    SigningKey
+	lastReload time.Time
+}
+
+// NewSigningKeyRing creates a key ring for tokens living tokenExpiry. Load it before use.
+func NewSigningKeyRing(store SigningKeyStore, tokenExpiry time.Duration) *SigningKeyRing {
+	rotation := durationEnv("JWT_KEY_ROTATION_INTERVAL", defaultKeyRotation)
+	grace := max(durationEnv("JWT_KEY_GRACE_PERIOD", defaultKeyGracePeriod), tokenExpiry)
+
+	return &SigningKeyRing{
+		store:    store,
+		rotation: rotation,
+		grace:    grace,
+		now:      time.Now,
+		keys:     make(map[string]SigningKey),
+	}
+}
+
+// Load reads the keys from the store, creating the first key or rotating an expired one
+func (r *SigningKeyRing) Load(ctx context.Context) error {
+	if err := r.reload(ctx); err != nil {
+		return err
+	}
+	return r.rotateIfDue(ctx)
+}
+
+// Start rotates the key when it is due and picks up keys rotated by other instances, until
+// ctx is cancelled
+func (r *SigningKeyRing) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil {
+				logger.Warn("Failed to rotate JWT signing key", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+		}
+	}
+}
+
+// Current returns the key that signs new tokens
+func (r *SigningKeyRing) Current() SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Secret returns the secret of a key that still validates tokens. Unknown keys trigger a
+// reload, rate limited, in case another instance created them.
+func (r *SigningKeyRing) Secret(ctx context.Context, id string) ([]byte, error) {
+	if secret, ok := r.validSecret(id); ok {
+		return secret, nil
+	}
+
+	r.mu.RLock()
+	recent := r.now().Sub(r.lastReload) < signingKeyReloadInterval
+	r.mu.RUnlock()
+	if recent {
+		return nil, ErrSigningKeyNotFound
+	}
+
+	if err := r.reload(ctx); err != nil {
+		return nil, err
+	}
+	if secret, ok := r.validSecret(id); ok {
+		return secret, nil
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+// validSecret returns the secret of a loaded key unless its grace period is over
+func (r *SigningKeyRing) validSecret(id string) ([]byte, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	if !ok || (key.RetiredAt != nil && r.now().After(key.RetiredAt.Add(r.grace))) {
+		return nil, false
+	}
+	return key.Secret, true
+}
+
+// reload replaces the loaded keys with those of the store that still validate tokens
+func (r *SigningKeyRing) reload(ctx context.Context) error {
+	now := r.now()
+	keys, err := r.store.List(ctx, now.Add(-r.grace))
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]SigningKey, len(keys))
+	var current SigningKey
+	for _, key := range keys {
+		loaded[key.ID] = key
+		if key.RetiredAt == nil && key.CreatedAt.After(current.CreatedAt) {
+			current = key
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = loaded
+	r.current = current
+	r.lastReload = now
+	return nil
+}
+
+// rotateIfDue replaces the current key when there is none or it is older than the rotation
+// interval, and deletes the keys past their grace period
+func (r *SigningKeyRing) rotateIfDue(ctx context.Context) error {
+	now := r.now()
+	if err := r.store.DeleteRetired(ctx, now.Add(-r.grace)); err != nil {
+		return err
+	}
+
+	dueBefore := now.Add(-r.rotation)
+	if current := r.Current(); current.ID != "" && current.CreatedAt.After(dueBefore) {
+		return nil
+	}
+
+	secret := make([]byte, signingKeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	key := &SigningKey{ID: uuid.New().String(), Secret: secret, CreatedAt: now}
+
+	rotated, err := r.store.Rotate(ctx, key, dueBefore)
+	if err != nil {
+		return err
+	}
+	if rotated {
+		logger.Info("JWT signing key rotated", logger.LogContext{
+			Fields: map[string]any{
+				"key_id": key.ID,
+			},
+		})
+	}
+
+	return r.reload(ctx)
+}
+
+// durationEnv reads a positive Go duration from an environment variable
+func durationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := strings.TrimSpace(config.GetEnv(key, ""))
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		logger.Warn("Ignoring invalid "+key, logger.LogContext{
+			Fields: map[string]any{
+				"value": value,
+			},
+		})
+		return defaultValue
+	}
+	return parsed
+}
+
+// SigningKeySecretFromEnv reads JWT_SIGNING_KEY_SECRET, the secret PostgresSigningKeyStore
+// encrypts signing keys with. Whoever knows it and can read the database can sign tokens, so it
+// is required, must be long and must not be shared with ENCRYPT_SECRET or JWT_SECRET, whose
+// defaults are public.
+func SigningKeySecretFromEnv() (string, error) {
+	secret := config.GetEnv("JWT_SIGNING_KEY_SECRET", "")
+	switch {
+	case secret == "":
+		return "", fmt.Errorf("%w: it is required with JWT_SIGNING_KEY_STORE=postgres", ErrSigningKeySecretInvalid)
+	case len(secret) < minSigningKeySecretLength:
+		return "", fmt.Errorf("%w: it must be at least %d characters", ErrSigningKeySecretInvalid, minSigningKeySecretLength)
+	case secret == config.GetEnv("ENCRYPT_SECRET", "") || secret == config.GetEnv("JWT_SECRET", ""):
+		return "", fmt.Errorf("%w: it must not be the same as ENCRYPT_SECRET or JWT_SECRET", ErrSigningKeySecretInvalid)
+	}
+	return secret, nil
+}
+
+// PostgresSigningKeyStore keeps signing keys in the jwt_signing_keys table, encrypted with
+// AES-GCM under a key derived from JWT_SIGNING_KEY_SECRET.
+type PostgresSigningKeyStore struct {
+	db  *sql.DB
+	key []byte
+}
+
+// NewPostgresSigningKeyStore creates a store over the shared *sql.DB connection, encrypting the
+// keys with secret, as read by SigningKeySecretFromEnv
+func NewPostgresSigningKeyStore(db *sql.DB, secret string) *PostgresSigningKeyStore {
+	key := sha256.Sum256([]byte(secret))
+	return &PostgresSigningKeyStore{db: db, key: key[:]}
+}
+
+// List returns the keys retired after since or not retired, newest first
+func (s *PostgresSigningKeyStore) List(ctx context.Context, since time.Time) ([]SigningKey, error) {
+	query := `
+		SELECT id, secret, created_at, retired_at
+		FROM jwt_signing_keys
+		WHERE retired_at IS NULL OR retired_at > $1
+		ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []SigningKey
+	for rows.Next() {
+		var (
+			key       SigningKey
+			encrypted string
+		)
+		if err := rows.Scan(&key.ID, &encrypted, &key.CreatedAt, &key.RetiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if key.Secret, err = s.decrypt(encrypted); err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", key.ID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Rotate retires the current key and stores key, unless another instance has just rotated.
+// The table lock serializes instances rotating at the same time.
+func (s *PostgresSigningKeyStore) Rotate(ctx context.Context, key *SigningKey, dueBefore time.Time) (bool, error) {
+	encrypted, err := s.encrypt(key.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE jwt_signing_keys IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return false, fmt.Errorf("failed to lock signing keys: %w", err)
+	}
+
+	var fresh bool
+	query := `SELECT EXISTS (SELECT 1 FROM jwt_signing_keys WHERE retired_at IS NULL AND created_at > $1)`
+	if err := tx.QueryRowContext(ctx, query, dueBefore).Scan(&fresh); err != nil {
+		return false, fmt.Errorf("failed to check signing keys: %w", err)
+	}
+	if fresh {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE jwt_signing_keys SET retired_at = $1 WHERE retired_at IS NULL`, key.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to retire signing key: %w", err)
+	}
+	query = `INSERT INTO jwt_signing_keys (id, secret, created_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, key.ID, encrypted, key.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit signing key: %w", err)
+	}
+	return true, nil
+}
+
+// DeleteRetired deletes the keys retired before a time
+func (s *PostgresSigningKeyStore) DeleteRetired(ctx context.Context, before time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM jwt_signing_keys WHERE retired_at < $1`, before); err != nil {
+		return fmt.Errorf("failed to delete signing keys: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresSigningKeyStore) encrypt(secret []byte) (string, error) {
+	gcm, err := s.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, secret, nil)), nil
+}
+
+func (s *PostgresSigningKeyStore) decrypt(encrypted string) ([]byte, error) {
+	combined, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+	gcm, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(combined) < gcm.NonceSize() {
+		return nil, errors.New("encrypted key too short")
+	}
+	return gcm.Open(nil, combined[:gcm.NonceSize()], combined[gcm.NonceSize():], nil)
+}
+
+func (s *PostgresSigningKeyStore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
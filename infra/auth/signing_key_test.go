@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySigningKeyStore is an in-memory SigningKeyStore for tests.
+type memorySigningKeyStore struct {
+	mu   sync.Mutex
+	keys []SigningKey
+}
+
+func (m *memorySigningKeyStore) List(_ context.Context, since time.Time) ([]SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []SigningKey
+	for _, key := range m.keys {
+		if key.RetiredAt == nil || key.RetiredAt.After(since) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *memorySigningKeyStore) Rotate(_ context.Context, key *SigningKey, dueBefore time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.keys {
+		if existing.RetiredAt == nil && existing.CreatedAt.After(dueBefore) {
+			return false, nil
+		}
+	}
+	for i := range m.keys {
+		if m.keys[i].RetiredAt == nil {
+			retiredAt := key.CreatedAt
+			m.keys[i].RetiredAt = &retiredAt
+		}
+	}
+	m.keys = append(m.keys, *key)
+	return true, nil
+}
+
+func (m *memorySigningKeyStore) DeleteRetired(_ context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.keys[:0]
+	for _, key := range m.keys {
+		if key.RetiredAt == nil || !key.RetiredAt.Before(before) {
+			kept = append(kept, key)
+		}
+	}
+	m.keys = kept
+	return nil
+}
+
+// newTestSigningKeyRing returns a ring over store whose clock is *now
+func newTestSigningKeyRing(t *testing.T, store SigningKeyStore, now *time.Time) *SigningKeyRing {
+	t.Setenv("JWT_KEY_ROTATION_INTERVAL", "24h")
+	t.Setenv("JWT_KEY_GRACE_PERIOD", "2h")
+	ring := NewSigningKeyRing(store, time.Hour)
+	ring.now = func() time.Time { return *now }
+	if err := ring.Load(context.Background()); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return ring
+}
+
+func TestSigningKeyRing_RotationAndGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &memorySigningKeyStore{}
+	ring := newTestSigningKeyRing(t, store, &now)
+
+	first := ring.Current()
+	if first.ID == "" || len(first.Secret) != signingKeySize {
+		t.Fatalf("Expected a key to be created on first load, got %+v", first)
+	}
+
+	// Loading before the rotation is due keeps the key
+	now = now.Add(23 * time.Hour)
+	if err := ring.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if ring.Current().ID != first.ID {
+		t.Fatal("Expected the key to be kept before rotation is due")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := ring.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	second := ring.Current()
+	if second.ID == first.ID {
+		t.Fatal("Expected the key to be rotated")
+	}
+
+	// The replaced key validates during the grace period only
+	if _, err := ring.Secret(ctx, first.ID); err != nil {
+		t.Errorf("Expected the replaced key to validate during the grace period, got %v", err)
+	}
+	now = now.Add(3 * time.Hour)
+	if _, err := ring.Secret(ctx, first.ID); !errors.Is(err, ErrSigningKeyNotFound) {
+		t.Errorf("Expected the replaced key to stop validating after the grace period, got %v", err)
+	}
+	if err := ring.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(store.keys) != 1 || store.keys[0].ID != second.ID {
+		t.Errorf("Expected keys past their grace period to be deleted, got %d keys", len(store.keys))
+	}
+}
+
+func TestSigningKeyRing_SharedBetweenInstances(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &memorySigningKeyStore{}
+	a := newTestSigningKeyRing(t, store, &now)
+
+	// A second instance, or a restart, uses the stored key instead of creating one
+	b := newTestSigningKeyRing(t, store, &now)
+	if a.Current().ID != b.Current().ID || len(store.keys) != 1 {
+		t.Fatalf("Expected instances to share the key, got %s and %s", a.Current().ID, b.Current().ID)
+	}
+
+	// When b rotates, a learns the new key from the first token signed with it
+	now = now.Add(25 * time.Hour)
+	if err := b.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	rotated := b.Current()
+	if err := a.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if a.Current().ID != rotated.ID || len(store.keys) != 2 {
+		t.Errorf("Expected a to pick up b's key instead of rotating again, got %d keys", len(store.keys))
+	}
+
+	if _, err := a.Secret(ctx, "unknown"); !errors.Is(err, ErrSigningKeyNotFound) {
+		t.Errorf("Expected ErrSigningKeyNotFound for an unknown key, got %v", err)
+	}
+}
+
+func TestJWTService_SigningKeys(t *testing.T) {
+	now := time.Now()
+	store := &memorySigningKeyStore{}
+
+	service := &JWTService{secretKey: []byte("test-secret"), expiry: time.Hour}
+	legacy, _ := service.GenerateToken("7", "alice")
+
+	service.SetSigningKeys(newTestSigningKeyRing(t, store, &now))
+	token, err := service.GenerateToken("7", "alice")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Errorf("Expected token to validate, got %v", err)
+	}
+	if _, err := service.ValidateToken(legacy); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected tokens signed with JWT_SECRET to be rejected, got %v", err)
+	}
+
+	// A restarted service validates the tokens signed before the restart
+	restarted := &JWTService{secretKey: []byte("another-secret"), expiry: time.Hour}
+	restarted.SetSigningKeys(newTestSigningKeyRing(t, store, &now))
+	if _, err := restarted.ValidateToken(token); err != nil {
+		t.Errorf("Expected token to survive a restart, got %v", err)
+	}
+}
+
+func TestPostgresSigningKeyStore_Encryption(t *testing.T) {
+	store := NewPostgresSigningKeyStore(nil, "encrypt-secret")
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	encrypted, err := store.encrypt(secret)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if bytes.Contains([]byte(encrypted), secret) {
+		t.Fatal("Expected the secret not to be stored in plain text")
+	}
+	decrypted, err := store.decrypt(encrypted)
+	if err != nil || !bytes.Equal(decrypted, secret) {
+		t.Errorf("Expected the secret back, got %q, %v", decrypted, err)
+	}
+
+	if _, err := NewPostgresSigningKeyStore(nil, "other-secret").decrypt(encrypted); err == nil {
+		t.Error("Expected decryption with another JWT_SIGNING_KEY_SECRET to fail")
+	}
+}
+
+func TestSigningKeySecretFromEnv(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef-signing"
+
+	tests := []struct {
+		name          string
+		signingSecret string
+		encryptSecret string
+		jwtSecret     string
+		wantErr       bool
+	}{
+		{name: "unset", encryptSecret: "an-encrypt-secret-that-is-long-enough", wantErr: true},
+		{name: "too short", signingSecret: "default-encrypt-key", wantErr: true},
+		{name: "shared with ENCRYPT_SECRET", signingSecret: secret, encryptSecret: secret, wantErr: true},
+		{name: "shared with JWT_SECRET", signingSecret: secret, jwtSecret: secret, wantErr: true},
+		{name: "dedicated", signingSecret: secret, encryptSecret: "encrypt-secret-key", jwtSecret: "your-jwt-secret-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SIGNING_KEY_SECRET", tt.signingSecret)
+			t.Setenv("ENCRYPT_SECRET", tt.encryptSecret)
+			t.Setenv("JWT_SECRET", tt.jwtSecret)
+
+			got, err := SigningKeySecretFromEnv()
+			if tt.wantErr {
+				if !errors.Is(err, ErrSigningKeySecretInvalid) {
+					t.Fatalf("SigningKeySecretFromEnv error = %v, want %v", err, ErrSigningKeySecretInvalid)
+				}
+				return
+			}
+			if err != nil || got != tt.signingSecret {
+				t.Fatalf("SigningKeySecretFromEnv = %q, %v, want the secret", got, err)
+			}
+		})
+	}
+}
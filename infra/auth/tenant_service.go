@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -31,16 +32,19 @@ type Tenant struct {
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=50"`
-	Password string `json:"password" validate:"required,min=6"`
+	Username string        `json:"username" validate:"required,min=3,max=50"`
+	Password string        `json:"password" validate:"required,min=6"`
+	Client   SessionClient `json:"-"` // Recorded with the session
 }
 
 // LoginResponse represents a login response
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	TenantID  string    `json:"tenant_id"`
-	Username  string    `json:"username"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Token            string     `json:"token"`
+	TenantID         string     `json:"tenant_id"`
+	Username         string     `json:"username"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshToken     string     `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
 }
 
 // CreateTenantRequest represents a tenant creation request
@@ -59,6 +63,7 @@ type RegisterRequest struct {
 type TenantService struct {
 	db         *conn.DB
 	jwtService *JWTService
+	users      *UserService
 }
 
 // NewTenantService creates a new tenant service
@@ -69,13 +74,19 @@ func NewTenantService(db *conn.DB, jwtService *JWTService) *TenantService {
 	}
 }
 
-// Login authenticates a tenant and returns a JWT token
-func (s *TenantService) Login(req LoginRequest) (*LoginResponse, error) {
+// SetUserService lets the users of a tenant log in with their own credentials
+func (s *TenantService) SetUserService(users *UserService) {
+	s.users = users
+}
+
+// Login authenticates a tenant, or a user of a tenant, and returns a JWT token bound to a new
+// session
+func (s *TenantService) Login(ctx context.Context, req LoginRequest) (*LoginResponse, error) {
 	// Get tenant by username
 	tenant, err := s.GetTenantByUsername(req.Username)
 	if err != nil {
 		if err == ErrTenantNotFound {
-			return nil, ErrInvalidCredentials
+			return s.loginUser(ctx, req)
 		}
 		return nil, err
 	}
@@ -98,24 +109,50 @@ func (s *TenantService) Login(req LoginRequest) (*LoginResponse, error) {
 		})
 	}
 
-	// Generate JWT token
+	// Generate JWT token. Its expiry must come from the signing service, not a
+	// local constant: a client that trusts a longer expires_at than the token
+	// actually has caches a dead token and 401s until its own cache lapses.
 	tenantID := fmt.Sprintf("%d", tenant.ID)
-	token, err := s.jwtService.GenerateToken(tenantID, tenant.Username)
+	tokens, err := s.jwtService.IssueTokens(ctx, tenantID, tenant.Username, req.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return newLoginResponse(tokens, tenantID, tenant.Username), nil
+}
+
+// loginUser authenticates a user of a tenant, who is logged in to their tenant under their
+// own username
+func (s *TenantService) loginUser(ctx context.Context, req LoginRequest) (*LoginResponse, error) {
+	if s.users == nil {
+		return nil, ErrInvalidCredentials
+	}
+	user, err := s.users.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	tenantID := fmt.Sprintf("%d", user.TenantID)
+	tokens, err := s.jwtService.IssueTokens(ctx, tenantID, user.Username, req.Client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	// Expiry must come from the signing service, not a local constant: a client
-	// that trusts a longer expires_at than the token actually has caches a dead
-	// token and 401s until its own cache lapses.
-	expiresAt := time.Now().Add(s.jwtService.Expiry())
+	return newLoginResponse(tokens, tenantID, user.Username), nil
+}
 
+func newLoginResponse(tokens *TokenPair, tenantID, username string) *LoginResponse {
 	return &LoginResponse{
-		Token:     token,
-		TenantID:  tenantID,
-		Username:  tenant.Username,
-		ExpiresAt: expiresAt,
-	}, nil
+		Token:            tokens.Token,
+		TenantID:         tenantID,
+		Username:         username,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
 }
 
 // CreateTenant creates a new tenant
@@ -131,6 +168,17 @@ func (s *TenantService) CreateTenant(req CreateTenantRequest) (*Tenant, error) {
 		}
 	}
 
+	// Usernames are shared with the users of tenants, as both log in with them
+	if s.users != nil {
+		taken, err := s.users.Exists(context.Background(), req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, ErrTenantAlreadyExists
+		}
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -263,6 +311,18 @@ func (s *TenantService) ChangePassword(tenantID int, oldPassword, newPassword st
 	return nil
 }
 
+// ChangeUserPassword changes the password of the logged in user: a user of the tenant, or the
+// tenant itself
+func (s *TenantService) ChangeUserPassword(ctx context.Context, tenantID int, username, oldPassword, newPassword string) error {
+	if s.users != nil {
+		err := s.users.ChangePassword(ctx, tenantID, username, oldPassword, newPassword)
+		if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+	}
+	return s.ChangePassword(tenantID, oldPassword, newPassword)
+}
+
 // AdminChangePassword changes the password for a tenant without requiring the old password
 // This method should only be used by administrators
 func (s *TenantService) AdminChangePassword(tenantID int, newPassword string) error {
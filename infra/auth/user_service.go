@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/logger"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("username already exists")
+	ErrDeleteSelf        = errors.New("a user cannot delete themselves")
+)
+
+// TenantUser is a user of a tenant with their own credentials and role, next to the tenant's
+// own login
+type TenantUser struct {
+	ID        int        `json:"id"`
+	TenantID  int        `json:"tenant_id"`
+	Username  string     `json:"username"`
+	Password  string     `json:"-"` // Never expose password in JSON
+	Role      Role       `json:"role"`
+	LastLogin *time.Time `json:"last_login,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateUserRequest adds a user to a tenant
+type CreateUserRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Password string `json:"password" validate:"required,min=6"`
+	Role     Role   `json:"role" validate:"required"`
+}
+
+// UpdateUserRequest changes the password or the role of a user. Empty fields are left as is.
+type UpdateUserRequest struct {
+	Password string `json:"password,omitempty" validate:"omitempty,min=6"`
+	Role     Role   `json:"role,omitempty"`
+}
+
+// UserStore persists tenant users. PostgresUserStore is the production implementation.
+type UserStore interface {
+	// Create inserts a user, or returns ErrUserAlreadyExists when a user or a tenant has the
+	// username
+	Create(ctx context.Context, user *TenantUser) error
+
+	// GetByUsername returns a user of any tenant, or ErrUserNotFound
+	GetByUsername(ctx context.Context, username string) (*TenantUser, error)
+
+	// List returns the tenant's users by username
+	List(ctx context.Context, tenantID int) ([]TenantUser, error)
+
+	// UpdatePassword replaces the password hash of a user and reports whether there was one
+	UpdatePassword(ctx context.Context, tenantID int, username, passwordHash string) (bool, error)
+
+	// UpdateLastLogin records a successful login of a user
+	UpdateLastLogin(ctx context.Context, id int, at time.Time) error
+
+	// Delete removes a user and reports whether there was one
+	Delete(ctx context.Context, tenantID int, username string) (bool, error)
+}
+
+// UserService manages the users of a tenant. Their roles are kept by the role service and
+// their sessions are revoked when they are removed or their password is reset.
+type UserService struct {
+	store    UserStore
+	roles    *RoleService
+	sessions *SessionService
+	now      func() time.Time
+}
+
+// NewUserService creates a user service
+func NewUserService(store UserStore, roles *RoleService) *UserService {
+	return &UserService{store: store, roles: roles, now: time.Now}
+}
+
+// SetSessionService lets the service log out users who are removed or whose password is reset
+func (s *UserService) SetSessionService(sessions *SessionService) {
+	s.sessions = sessions
+}
+
+// Create adds a user to the tenant with the role given by actor, the user making the change
+func (s *UserService) Create(ctx context.Context, tenantID int, actor string, req CreateUserRequest) (*TenantUser, error) {
+	if !req.Role.Valid() {
+		return nil, ErrInvalidRole
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &TenantUser{
+		TenantID:  tenantID,
+		Username:  strings.TrimSpace(req.Username),
+		Password:  string(hashedPassword),
+		CreatedAt: s.now(),
+	}
+	if err := s.store.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.roles.Assign(ctx, tenantID, actor, user.Username, req.Role)
+	if err != nil {
+		// Without a role the user would be an owner, so do not keep them
+		if _, deleteErr := s.store.Delete(ctx, tenantID, user.Username); deleteErr != nil {
+			return nil, errors.Join(err, deleteErr)
+		}
+		return nil, err
+	}
+	user.Role = assigned.Role
+	return user, nil
+}
+
+// Get returns a user of the tenant with their role
+func (s *UserService) Get(ctx context.Context, tenantID int, username string) (*TenantUser, error) {
+	user, err := s.store.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TenantID != tenantID {
+		return nil, ErrUserNotFound
+	}
+	if user.Role, err = s.roles.RoleOf(ctx, tenantID, user.Username); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// List returns the users of the tenant with their roles
+func (s *UserService) List(ctx context.Context, tenantID int) ([]TenantUser, error) {
+	users, err := s.store.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	assigned, err := s.roles.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]Role, len(assigned))
+	for _, role := range assigned {
+		roles[role.Username] = role.Role
+	}
+	for i := range users {
+		users[i].Role = RoleOwner
+		if role, ok := roles[users[i].Username]; ok {
+			users[i].Role = role
+		}
+	}
+	return users, nil
+}
+
+// Update changes the role or resets the password of a user of the tenant. A password reset
+// logs the user out.
+func (s *UserService) Update(ctx context.Context, tenantID int, actor, username string, req UpdateUserRequest) (*TenantUser, error) {
+	user, err := s.Get(ctx, tenantID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Role != "" {
+		assigned, err := s.roles.Assign(ctx, tenantID, actor, user.Username, req.Role)
+		if err != nil {
+			return nil, err
+		}
+		user.Role = assigned.Role
+	}
+
+	if req.Password != "" {
+		if err := s.setPassword(ctx, tenantID, user.Username, req.Password); err != nil {
+			return nil, err
+		}
+		if err := s.revokeSessions(ctx, tenantID, user.Username); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// Delete removes a user of the tenant, with their role, and logs them out
+func (s *UserService) Delete(ctx context.Context, tenantID int, actor, username string) error {
+	if username == actor {
+		return ErrDeleteSelf
+	}
+
+	deleted, err := s.store.Delete(ctx, tenantID, username)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+
+	// The role goes too, so the username does not stay behind as an owner
+	if _, err := s.roles.store.Delete(ctx, tenantID, username); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, tenantID, username)
+}
+
+// Authenticate checks the credentials of a user, returning ErrInvalidCredentials when they do
+// not match and ErrUserNotFound when no user has the username
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (*TenantUser, error) {
+	user, err := s.store.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := s.now()
+	if err := s.store.UpdateLastLogin(ctx, user.ID, now); err != nil {
+		// Log error but don't fail login
+		logger.Warn("Failed to update last login", logger.LogContext{
+			TenantID: fmt.Sprintf("%d", user.TenantID),
+			Fields: map[string]any{
+				"username": user.Username,
+				"error":    err.Error(),
+			},
+		})
+	}
+	user.LastLogin = &now
+	return user, nil
+}
+
+// ChangePassword changes the password of a user who knows their current one
+func (s *UserService) ChangePassword(ctx context.Context, tenantID int, username, oldPassword, newPassword string) error {
+	user, err := s.Get(ctx, tenantID, username)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return s.setPassword(ctx, tenantID, username, newPassword)
+}
+
+// Exists reports whether a user of any tenant has the username
+func (s *UserService) Exists(ctx context.Context, username string) (bool, error) {
+	_, err := s.store.GetByUsername(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *UserService) setPassword(ctx context.Context, tenantID int, username, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	updated, err := s.store.UpdatePassword(ctx, tenantID, username, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *UserService) revokeSessions(ctx context.Context, tenantID int, username string) error {
+	if s.sessions == nil {
+		return nil
+	}
+	return s.sessions.RevokeUser(ctx, tenantID, username, SessionRevokedUser)
+}
+
+// PostgresUserStore keeps tenant users in the tenant_users table.
+type PostgresUserStore struct {
+	db *sql.DB
+}
+
+// NewPostgresUserStore creates a store over the shared *sql.DB connection.
+func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+	return &PostgresUserStore{db: db}
+}
+
+// Create inserts a user unless a user or a tenant has the username
+func (r *PostgresUserStore) Create(ctx context.Context, user *TenantUser) error {
+	query := `
+		INSERT INTO tenant_users (tenant_id, username, password, created_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM tenants WHERE username = $2)
+		ON CONFLICT (username) DO NOTHING
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query, user.TenantID, user.Username, user.Password, user.CreatedAt).Scan(&user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserAlreadyExists
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetByUsername returns a user of any tenant
+func (r *PostgresUserStore) GetByUsername(ctx context.Context, username string) (*TenantUser, error) {
+	query := `SELECT id, tenant_id, username, password, last_login, created_at FROM tenant_users WHERE username = $1`
+
+	var user TenantUser
+	err := r.db.QueryRowContext(ctx, query, username).Scan(
+		&user.ID, &user.TenantID, &user.Username, &user.Password, &user.LastLogin, &user.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// List returns the tenant's users by username
+func (r *PostgresUserStore) List(ctx context.Context, tenantID int) ([]TenantUser, error) {
+	query := `SELECT id, tenant_id, username, last_login, created_at FROM tenant_users WHERE tenant_id = $1 ORDER BY username`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []TenantUser
+	for rows.Next() {
+		var user TenantUser
+		if err := rows.Scan(&user.ID, &user.TenantID, &user.Username, &user.LastLogin, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdatePassword replaces the password hash of a user
+func (r *PostgresUserStore) UpdatePassword(ctx context.Context, tenantID int, username, passwordHash string) (bool, error) {
+	query := `UPDATE tenant_users SET password = $3 WHERE tenant_id = $1 AND username = $2`
+
+	result, err := r.db.ExecContext(ctx, query, tenantID, username, passwordHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to update password: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateLastLogin records a successful login of a user
+func (r *PostgresUserStore) UpdateLastLogin(ctx context.Context, id int, at time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE tenant_users SET last_login = $2 WHERE id = $1`, id, at); err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a user
+func (r *PostgresUserStore) Delete(ctx context.Context, tenantID int, username string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tenant_users WHERE tenant_id = $1 AND username = $2`, tenantID, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
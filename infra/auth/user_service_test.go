@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memoryUserStore is an in-memory UserStore for tests.
+type memoryUserStore struct {
+	users  map[string]TenantUser
+	nextID int
+}
+
+func newMemoryUserStore() *memoryUserStore {
+	return &memoryUserStore{users: make(map[string]TenantUser)}
+}
+
+func (m *memoryUserStore) Create(_ context.Context, user *TenantUser) error {
+	if _, ok := m.users[user.Username]; ok {
+		return ErrUserAlreadyExists
+	}
+	m.nextID++
+	user.ID = m.nextID
+	m.users[user.Username] = *user
+	return nil
+}
+
+func (m *memoryUserStore) GetByUsername(_ context.Context, username string) (*TenantUser, error) {
+	user, ok := m.users[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (m *memoryUserStore) List(_ context.Context, tenantID int) ([]TenantUser, error) {
+	var users []TenantUser
+	for _, user := range m.users {
+		if user.TenantID == tenantID {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (m *memoryUserStore) UpdatePassword(_ context.Context, tenantID int, username, passwordHash string) (bool, error) {
+	user, ok := m.users[username]
+	if !ok || user.TenantID != tenantID {
+		return false, nil
+	}
+	user.Password = passwordHash
+	m.users[username] = user
+	return true, nil
+}
+
+func (m *memoryUserStore) UpdateLastLogin(_ context.Context, id int, at time.Time) error {
+	for username, user := range m.users {
+		if user.ID == id {
+			user.LastLogin = &at
+			m.users[username] = user
+		}
+	}
+	return nil
+}
+
+func (m *memoryUserStore) Delete(_ context.Context, tenantID int, username string) (bool, error) {
+	user, ok := m.users[username]
+	if !ok || user.TenantID != tenantID {
+		return false, nil
+	}
+	delete(m.users, username)
+	return true, nil
+}
+
+func newTestUserService(t *testing.T) (*UserService, *RoleService, *SessionService) {
+	roles := NewRoleService(newMemoryRoleStore())
+	sessions, _ := newTestSessionService(t, "5")
+	users := NewUserService(newMemoryUserStore(), roles)
+	users.SetSessionService(sessions)
+	return users, roles, sessions
+}
+
+func TestUserService_CreateAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	users, roles, _ := newTestUserService(t)
+
+	user, err := users.Create(ctx, 7, "owner", CreateUserRequest{Username: " bob ", Password: "secret123", Role: RoleFinance})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if user.Username != "bob" || user.TenantID != 7 || user.Role != RoleFinance {
+		t.Errorf("Unexpected user %+v", user)
+	}
+	if role, _ := roles.RoleOf(ctx, 7, "bob"); role != RoleFinance {
+		t.Errorf("Expected the role to be assigned, got %q", role)
+	}
+
+	if _, err := users.Create(ctx, 8, "other", CreateUserRequest{Username: "bob", Password: "secret123", Role: RoleOwner}); !errors.Is(err, ErrUserAlreadyExists) {
+		t.Errorf("Expected usernames to be unique across tenants, got %v", err)
+	}
+	if _, err := users.Create(ctx, 7, "owner", CreateUserRequest{Username: "carol", Password: "secret123", Role: "admin"}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+
+	authenticated, err := users.Authenticate(ctx, "bob", "secret123")
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if authenticated.TenantID != 7 || authenticated.LastLogin == nil {
+		t.Errorf("Expected bob of tenant 7 with a last login, got %+v", authenticated)
+	}
+	if _, err := users.Authenticate(ctx, "bob", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := users.Authenticate(ctx, "nobody", "secret123"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserService_OtherTenantsUsersAreHidden(t *testing.T) {
+	ctx := context.Background()
+	users, _, _ := newTestUserService(t)
+
+	if _, err := users.Create(ctx, 7, "owner", CreateUserRequest{Username: "bob", Password: "secret123", Role: RoleDeveloper}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := users.Get(ctx, 8, "bob"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected another tenant's user to be hidden, got %v", err)
+	}
+	if _, err := users.Update(ctx, 8, "other", "bob", UpdateUserRequest{Role: RoleOwner}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected another tenant's user not to be updated, got %v", err)
+	}
+	if err := users.Delete(ctx, 8, "other", "bob"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected another tenant's user not to be deleted, got %v", err)
+	}
+	if list, _ := users.List(ctx, 8); len(list) != 0 {
+		t.Errorf("Expected no users for tenant 8, got %+v", list)
+	}
+}
+
+func TestUserService_UpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	users, roles, sessions := newTestUserService(t)
+
+	if _, err := users.Create(ctx, 7, "owner", CreateUserRequest{Username: "bob", Password: "secret123", Role: RoleReadOnly}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	session, _ := sessions.Start(ctx, 7, "bob", SessionClient{}, time.Hour)
+
+	// Changing the role keeps bob logged in
+	updated, err := users.Update(ctx, 7, "owner", "bob", UpdateUserRequest{Role: RoleDeveloper})
+	if err != nil || updated.Role != RoleDeveloper {
+		t.Fatalf("Expected role developer, got %+v, %v", updated, err)
+	}
+	if err := sessions.Check(ctx, session.ID); err != nil {
+		t.Errorf("Expected session to stay active after a role change, got %v", err)
+	}
+
+	// Resetting the password logs bob out
+	if _, err := users.Update(ctx, 7, "owner", "bob", UpdateUserRequest{Password: "newsecret"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := sessions.Check(ctx, session.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected session to be revoked after a password reset, got %v", err)
+	}
+	if _, err := users.Authenticate(ctx, "bob", "newsecret"); err != nil {
+		t.Errorf("Expected the new password to work, got %v", err)
+	}
+	if err := users.ChangePassword(ctx, 7, "bob", "secret123", "another"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected the old password to be rejected, got %v", err)
+	}
+
+	if err := users.Delete(ctx, 7, "bob", "bob"); !errors.Is(err, ErrDeleteSelf) {
+		t.Errorf("Expected ErrDeleteSelf, got %v", err)
+	}
+
+	session, _ = sessions.Start(ctx, 7, "bob", SessionClient{}, time.Hour)
+	if err := users.Delete(ctx, 7, "owner", "bob"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := sessions.Check(ctx, session.ID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected session to be revoked after delete, got %v", err)
+	}
+	if assigned, _ := roles.List(ctx, 7); len(assigned) != 0 {
+		t.Errorf("Expected the role to be removed with the user, got %+v", assigned)
+	}
+	if err := users.Delete(ctx, 7, "owner", "bob"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound on second delete, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"database/sql"
 	"math/rand"
 	"os"
 	"strconv"
@@ -47,6 +48,20 @@ func App() *Config {
 	return instance
 }
 
+// UseDatabase makes App use a connection opened by the caller instead of connecting with the
+// DB_* environment, for GoPay embedded in another service. It must be called before App.
+func UseDatabase(db *sql.DB) *Config {
+	if instance == nil {
+		instance = &Config{
+			Validator:  validator.New(),
+			SecretKey:  GetEnv("JWT_SECRET", "default-secret-key"),
+			EncryptKey: GetEnv("ENCRYPT_SECRET", "default-encrypt-key"),
+		}
+	}
+	instance.DB = &conn.DB{DB: db}
+	return instance
+}
+
 // GetAppConfig returns the application configuration
 func GetAppConfig() *AppConfig {
 	if appConfigInstance == nil {
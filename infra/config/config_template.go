@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrConfigTemplateNotFound is returned for a template name that does not exist
+var ErrConfigTemplateNotFound = errors.New("config template not found")
+
+// ConfigTemplate is an admin-managed provider config applied to new tenants in one step. It
+// holds the provider's non-secret defaults; secrets are filled in per tenant.
+type ConfigTemplate struct {
+	Name        string            `json:"name"`
+	Provider    string            `json:"provider"`
+	Environment string            `json:"environment"`
+	Config      map[string]string `json:"config"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+// ConfigTemplateStore keeps config templates by name
+type ConfigTemplateStore interface {
+	Save(ctx context.Context, template ConfigTemplate) error
+	Get(ctx context.Context, name string) (*ConfigTemplate, error)
+	List(ctx context.Context) ([]ConfigTemplate, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// PostgresConfigTemplateStore keeps config templates in the config_templates table
+type PostgresConfigTemplateStore struct {
+	db *sql.DB
+}
+
+// NewPostgresConfigTemplateStore creates a store over the shared *sql.DB connection
+func NewPostgresConfigTemplateStore(db *sql.DB) *PostgresConfigTemplateStore {
+	return &PostgresConfigTemplateStore{db: db}
+}
+
+// Save stores the template, replacing a template with the same name
+func (s *PostgresConfigTemplateStore) Save(ctx context.Context, template ConfigTemplate) error {
+	config, err := json.Marshal(template.Config)
+	if err != nil {
+		return fmt.Errorf("failed to encode config template: %w", err)
+	}
+
+	query := `
+		INSERT INTO config_templates (name, provider, environment, config)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name)
+		DO UPDATE SET provider = EXCLUDED.provider, environment = EXCLUDED.environment, config = EXCLUDED.config, updated_at = now()`
+	if _, err := s.db.ExecContext(ctx, query, template.Name, strings.ToLower(template.Provider), template.Environment, config); err != nil {
+		return fmt.Errorf("failed to save config template: %w", err)
+	}
+	return nil
+}
+
+// Get returns the template with the given name
+func (s *PostgresConfigTemplateStore) Get(ctx context.Context, name string) (*ConfigTemplate, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT name, provider, environment, config, updated_at FROM config_templates
+		WHERE name = $1`, name)
+
+	template, err := scanConfigTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrConfigTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config template: %w", err)
+	}
+	return template, nil
+}
+
+// List returns every template ordered by name
+func (s *PostgresConfigTemplateStore) List(ctx context.Context) ([]ConfigTemplate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, provider, environment, config, updated_at FROM config_templates
+		ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []ConfigTemplate{}
+	for rows.Next() {
+		template, err := scanConfigTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan config template: %w", err)
+		}
+		templates = append(templates, *template)
+	}
+	return templates, rows.Err()
+}
+
+// Delete removes the template with the given name
+func (s *PostgresConfigTemplateStore) Delete(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM config_templates WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete config template: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrConfigTemplateNotFound
+	}
+	return nil
+}
+
+// scanConfigTemplate reads a config_templates row
+func scanConfigTemplate(row interface{ Scan(dest ...any) error }) (*ConfigTemplate, error) {
+	var template ConfigTemplate
+	var config []byte
+	if err := row.Scan(&template.Name, &template.Provider, &template.Environment, &config, &template.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(config, &template.Config); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
@@ -384,6 +384,79 @@ func (s *PostgresStorage) GetTenantsByProvider(providerName string) ([]string, e
 	return tenants, nil
 }
 
+// TenantProviderConfig is a tenant's stored configuration for one provider and environment
+type TenantProviderConfig struct {
+	TenantID    int
+	Provider    string
+	Environment string
+	Config      map[string]string // includes the "environment" key, like LoadTenantConfig
+}
+
+// LoadTenantConfigBatch loads the configurations of up to limit tenants whose ID is greater
+// than afterTenantID, ordered by tenant. It also returns the last tenant ID of the batch to
+// pass as afterTenantID for the next one, which equals afterTenantID when no tenants are left.
+func (s *PostgresStorage) LoadTenantConfigBatch(afterTenantID, limit int) ([]TenantProviderConfig, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := `
+		WITH batch AS (
+			SELECT DISTINCT tenant_id
+			FROM tenant_configs
+			WHERE tenant_id > $1
+			ORDER BY tenant_id
+			LIMIT $2
+		)
+		SELECT b.tenant_id, p.name as provider_name, tc.environment, tc.key, tc.value
+		FROM batch b
+		LEFT JOIN (tenant_configs tc JOIN providers p ON tc.provider_id = p.id AND p.active = true)
+			ON tc.tenant_id = b.tenant_id
+		ORDER BY b.tenant_id, p.name, tc.environment, tc.key
+	`
+
+	rows, err := s.db.Query(query, afterTenantID, limit)
+	if err != nil {
+		return nil, afterTenantID, fmt.Errorf("failed to query tenant config batch: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		configs      []TenantProviderConfig
+		lastTenantID = afterTenantID
+	)
+	for rows.Next() {
+		var tenantID int
+		var providerName, environment, key, value sql.NullString
+		if err := rows.Scan(&tenantID, &providerName, &environment, &key, &value); err != nil {
+			return nil, afterTenantID, fmt.Errorf("failed to scan row: %w", err)
+		}
+		lastTenantID = tenantID
+
+		// Tenants whose configs all belong to inactive providers still advance the batch
+		if !providerName.Valid {
+			continue
+		}
+
+		last := len(configs) - 1
+		if last < 0 || configs[last].TenantID != tenantID || configs[last].Provider != providerName.String || configs[last].Environment != environment.String {
+			configs = append(configs, TenantProviderConfig{
+				TenantID:    tenantID,
+				Provider:    providerName.String,
+				Environment: environment.String,
+				Config:      map[string]string{"environment": environment.String},
+			})
+			last++
+		}
+		configs[last].Config[key.String] = value.String
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, afterTenantID, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return configs, lastTenantID, nil
+}
+
 // Close cleanup method - does not close shared database connection
 func (s *PostgresStorage) Close() error {
 	// Clear provider IDs cache
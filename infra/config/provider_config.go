@@ -192,6 +192,34 @@ func (c *ProviderConfig) DeleteTenantConfig(tenantID, providerName string) error
 	return nil
 }
 
+// ForEachTenantConfigBatch calls fn with the stored configurations of batchSize tenants at a
+// time until every tenant has been visited or fn returns an error
+func (c *ProviderConfig) ForEachTenantConfigBatch(batchSize int, fn func([]TenantProviderConfig) error) error {
+	if c.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	afterTenantID := 0
+	for {
+		configs, lastTenantID, err := c.storage.LoadTenantConfigBatch(afterTenantID, batchSize)
+		if err != nil {
+			return err
+		}
+		if lastTenantID == afterTenantID {
+			return nil
+		}
+		if len(configs) > 0 {
+			if err := fn(configs); err != nil {
+				return err
+			}
+		}
+		afterTenantID = lastTenantID
+	}
+}
+
 // GetProviderIDByName returns the provider ID for a given provider name, or error if not found
 func (c *ProviderConfig) GetProviderIDByName(providerName string) (int, error) {
 	if c.storage == nil {
@@ -244,3 +244,16 @@ func TestProviderConfig_GetStats(t *testing.T) {
 	// PostgreSQL stats will depend on whether storage is available
 	assert.Contains(t, stats, "postgres")
 }
+
+func TestProviderConfig_ForEachTenantConfigBatch_NoStorage(t *testing.T) {
+	config := &ProviderConfig{configs: make(map[string]map[string]string)}
+
+	called := false
+	err := config.ForEachTenantConfigBatch(10, func([]TenantProviderConfig) error {
+		called = true
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.False(t, called)
+}
@@ -0,0 +1,36 @@
+// Package fingerprint parses and computes the SHA-256 fingerprints certificates are pinned by
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// Of returns the SHA-256 fingerprint of a certificate as lowercase hex
+func Of(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse parses comma separated SHA-256 fingerprints. They may be written as openssl prints
+// them ("AB:CD:..."), with or without colons and a "sha256:" prefix.
+func Parse(value string) []string {
+	var fingerprints []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entry = strings.TrimPrefix(entry, "sha256:")
+		entry = strings.ReplaceAll(entry, ":", "")
+		if entry != "" {
+			fingerprints = append(fingerprints, entry)
+		}
+	}
+	return fingerprints
+}
+
+// Valid reports whether a parsed fingerprint is a SHA-256 digest
+func Valid(fingerprint string) bool {
+	decoded, err := hex.DecodeString(fingerprint)
+	return err == nil && len(decoded) == sha256.Size
+}
@@ -0,0 +1,19 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	fingerprint := strings.Repeat("0f", 32)
+	colons := strings.ToUpper(strings.Join(strings.SplitAfter(fingerprint, "f")[:32], ":"))
+
+	got := Parse(" sha256:" + colons + " ,, " + fingerprint)
+	if len(got) != 2 || got[0] != fingerprint || got[1] != fingerprint {
+		t.Fatalf("unexpected fingerprints %v", got)
+	}
+	if !Valid(got[0]) || Valid("0f0f") || Valid(strings.Repeat("zz", 32)) {
+		t.Error("Valid accepted or rejected the wrong fingerprints")
+	}
+}
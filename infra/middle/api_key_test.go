@@ -0,0 +1,75 @@
+package middle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/auth"
+)
+
+// singleAPIKeyStore knows the key of one hash
+type singleAPIKeyStore struct {
+	auth.APIKeyStore
+	hash string
+	key  auth.APIKey
+}
+
+func (s *singleAPIKeyStore) Create(_ context.Context, key *auth.APIKey, hash string) error {
+	s.key, s.hash = *key, hash
+	return nil
+}
+
+func (s *singleAPIKeyStore) GetByHash(_ context.Context, hash string) (*auth.APIKey, error) {
+	if hash != s.hash {
+		return nil, auth.ErrAPIKeyNotFound
+	}
+	key := s.key
+	return &key, nil
+}
+
+func (s *singleAPIKeyStore) Touch(context.Context, string, time.Time) error { return nil }
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	apiKeys := auth.NewAPIKeyService(&singleAPIKeyStore{})
+	key, secret, err := apiKeys.Create(context.Background(), 42, "ci")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var tenantID, username string
+	var authenticated *auth.APIKey
+	handler := APIKeyAuthMiddleware(apiKeys)(JWTAuthMiddleware(&auth.JWTService{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID = GetTenantIDFromContext(r.Context())
+		username = GetTenantUserFromContext(r.Context())
+		authenticated = GetTenantAPIKeyFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	serve := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/payments", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(APIKeyHeader, secret); code != http.StatusNoContent {
+		t.Fatalf("Expected the API key to pass the JWT middleware, got %d", code)
+	}
+	if tenantID != "42" || username != "api-key:"+key.Prefix || authenticated == nil || authenticated.ID != key.ID {
+		t.Errorf("Unexpected context: tenant %q, user %q, key %+v", tenantID, username, authenticated)
+	}
+
+	if code := serve(APIKeyHeader, secret+"x"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong key, got %d", code)
+	}
+	// Without a key the JWT is required
+	if code := serve("", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", code)
+	}
+}
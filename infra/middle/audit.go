@@ -0,0 +1,164 @@
+package middle
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/mstgnz/gopay/infra/audit"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// auditedRoutes maps the method and route pattern of each mutating operation to its action.
+// Mutating routes not listed here (e.g. installment or commission queries sent with POST)
+// are not audited.
+var auditedRoutes = map[string]string{
+	"POST /v1/payments/{provider}":                     audit.ActionPaymentCreate,
+	"POST /v1/payments/{provider}/batch":               audit.ActionPaymentBatchCreate,
+	"DELETE /v1/payments/{provider}/{paymentID}":       audit.ActionPaymentCancel,
+	"POST /v1/payments/{provider}/{paymentID}/capture": audit.ActionPaymentCapture,
+	"POST /v1/payments/{provider}/refund":              audit.ActionPaymentRefund,
+	"POST /v1/payments/{provider}/cards/register":      audit.ActionCardRegister,
+	"DELETE /v1/payments/{provider}/cards/{cardId}":    audit.ActionCardDelete,
+	"POST /v1/payments/{provider}/cards/{cardId}/pay":  audit.ActionCardPay,
+	"POST /v1/subscriptions":                           audit.ActionSubscriptionCreate,
+	"DELETE /v1/subscriptions/{subscriptionID}":        audit.ActionSubscriptionCancel,
+	"POST /v1/payouts":                                 audit.ActionPayoutCreate,
+	"POST /v1/payment-links":                           audit.ActionPaymentLinkCreate,
+	"DELETE /v1/payment-links/{linkID}":                audit.ActionPaymentLinkCancel,
+	"POST /v1/webhooks/deliveries/{deliveryID}/replay": audit.ActionWebhookReplay,
+	"POST /v1/config/tenant":                           audit.ActionConfigUpdate,
+	"DELETE /v1/config/tenant":                         audit.ActionConfigDelete,
+	"POST /v1/config/templates":                        audit.ActionConfigTemplateSave,
+	"DELETE /v1/config/templates/{name}":               audit.ActionConfigTemplateDel,
+	"POST /v1/config/apply-template":                   audit.ActionConfigTemplateApply,
+	"POST /v1/tenants/{tenantID}/users":                audit.ActionUserCreate,
+	"PUT /v1/tenants/{tenantID}/users/{username}":      audit.ActionUserUpdate,
+	"DELETE /v1/tenants/{tenantID}/users/{username}":   audit.ActionUserDelete,
+	"DELETE /v1/auth/sessions/{sessionID}":             audit.ActionSessionRevoke,
+	"POST /v1/auth/revoke":                             audit.ActionSessionRevoke,
+	"PUT /v1/admin/tenants/{tenantID}/rate-limits":     audit.ActionRateLimitUpdate,
+	"DELETE /v1/admin/tenants/{tenantID}/rate-limits":  audit.ActionRateLimitReset,
+}
+
+// auditResourceParams are the URL params naming the resource an operation changed
+var auditResourceParams = []string{"paymentID", "cardId", "subscriptionID", "linkID", "deliveryID", "name", "username", "sessionID"}
+
+// auditTargetKey holds the *auditTarget of an audited request
+type auditTargetKey struct{}
+
+// auditTarget is what handlers tell the audit middleware about the data an operation changed,
+// when it cannot be read from the URL
+type auditTarget struct {
+	tenantID   string
+	resourceID string
+}
+
+// SetAuditTargetTenant records that the operation of the request changes tenantID's data. It
+// is called by handlers that let the admin act on another tenant, so the audit event is
+// attributed to that tenant and flagged as impersonated.
+func SetAuditTargetTenant(ctx context.Context, tenantID string) {
+	if target, ok := ctx.Value(auditTargetKey{}).(*auditTarget); ok {
+		target.tenantID = tenantID
+	}
+}
+
+// SetAuditResource records the resource the operation of the request changes, for operations
+// that take it in the body, such as the payment of a refund
+func SetAuditResource(ctx context.Context, resourceID string) {
+	if target, ok := ctx.Value(auditTargetKey{}).(*auditTarget); ok {
+		target.resourceID = resourceID
+	}
+}
+
+// auditStatusWriter captures the status code of an audited response
+type auditStatusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *auditStatusWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// AuditMiddleware records an audit event, with the actor who initiated it, for every mutating
+// operation in auditedRoutes. It must run after JWTAuthMiddleware. Events are recorded after
+// the response is written; a failure to record one is logged and does not fail the request.
+func AuditMiddleware(store audit.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target := &auditTarget{}
+			ctx := context.WithValue(r.Context(), auditTargetKey{}, target)
+			sw := &auditStatusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			routeCtx := chi.RouteContext(ctx)
+			if routeCtx == nil {
+				return
+			}
+			action, ok := auditedRoutes[r.Method+" "+routeCtx.RoutePattern()]
+			if !ok {
+				return
+			}
+
+			event := newAuditEvent(r.WithContext(ctx), action, target, sw.statusCode)
+			if err := store.Record(context.WithoutCancel(ctx), event); err != nil {
+				logger.Error("Failed to record audit event", err, logger.LogContext{
+					TenantID: event.TenantID,
+					Fields: map[string]any{
+						"action": action,
+						"path":   event.Path,
+					},
+				})
+			}
+		})
+	}
+}
+
+// newAuditEvent builds the audit event of a completed request
+func newAuditEvent(r *http.Request, action string, target *auditTarget, statusCode int) audit.Event {
+	actor := audit.Actor{
+		TenantID:   GetTenantIDFromContext(r.Context()),
+		Username:   GetTenantUserFromContext(r.Context()),
+		AuthMethod: audit.AuthMethodAnonymous,
+		SourceIP:   GetClientIP(r),
+	}
+	if GetTenantClaimsFromContext(r.Context()) != nil {
+		actor.AuthMethod = audit.AuthMethodJWT
+	}
+
+	tenantID := actor.TenantID
+	if target.tenantID != "" && target.tenantID != actor.TenantID {
+		tenantID = target.tenantID
+		actor.Impersonated = true
+	}
+
+	event := audit.Event{
+		Action:     action,
+		Actor:      actor,
+		TenantID:   tenantID,
+		Provider:   chi.URLParam(r, "provider"),
+		Method:     r.Method,
+		ResourceID: target.resourceID,
+		Path:       r.URL.Path,
+		StatusCode: statusCode,
+		RequestID:  middleware.GetReqID(r.Context()),
+		Success:    statusCode >= 200 && statusCode < 300,
+		CreatedAt:  time.Now(),
+	}
+	for _, param := range auditResourceParams {
+		if event.ResourceID != "" {
+			break
+		}
+		event.ResourceID = chi.URLParam(r, param)
+	}
+	return event
+}
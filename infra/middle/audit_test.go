@@ -0,0 +1,239 @@
+package middle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/audit"
+	"github.com/mstgnz/gopay/infra/auth"
+)
+
+type memoryAuditStore struct {
+	mu     sync.Mutex
+	events []audit.Event
+	err    error
+}
+
+func (s *memoryAuditStore) Record(ctx context.Context, event audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memoryAuditStore) List(ctx context.Context, filter audit.Filter) ([]audit.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events, nil
+}
+
+// newAuditTestRouter mounts the audited v1 routes behind a stand-in for JWTAuthMiddleware
+func newAuditTestRouter(store audit.Store, tenantID, username string) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), TenantIDKey, tenantID)
+				ctx = context.WithValue(ctx, TenantUserKey, username)
+				ctx = context.WithValue(ctx, TenantClaimsKey, &auth.JWTClaims{TenantID: tenantID, Username: username})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		r.Use(AuditMiddleware(store))
+
+		r.Route("/payments", func(r chi.Router) {
+			r.Post("/{provider}", ok)
+			r.Post("/{provider}/batch", ok)
+			r.Post("/{provider}/cards/register", ok)
+			r.Delete("/{provider}/cards/{cardId}", ok)
+			r.Post("/{provider}/cards/{cardId}/pay", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusPaymentRequired)
+			})
+			r.Get("/{provider}/{paymentID}", ok)
+			r.Delete("/{provider}/{paymentID}", ok)
+			r.Post("/{provider}/{paymentID}/capture", ok)
+			r.Post("/{provider}/refund", func(w http.ResponseWriter, r *http.Request) {
+				SetAuditResource(r.Context(), "pay_refund")
+				w.WriteHeader(http.StatusOK)
+			})
+			r.Post("/{provider}/installments", ok)
+		})
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.Post("/", ok)
+			r.Delete("/{subscriptionID}", ok)
+		})
+		r.Post("/payouts", func(w http.ResponseWriter, r *http.Request) {
+			SetAuditResource(r.Context(), "po123")
+			w.WriteHeader(http.StatusCreated)
+		})
+		r.Route("/payment-links", func(r chi.Router) {
+			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+				SetAuditResource(r.Context(), "pl123")
+				w.WriteHeader(http.StatusCreated)
+			})
+			r.Delete("/{linkID}", ok)
+		})
+		r.Post("/webhooks/deliveries/{deliveryID}/replay", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+		r.Route("/config", func(r chi.Router) {
+			r.Post("/tenant", ok)
+			r.Delete("/tenant", ok)
+			r.Post("/templates", ok)
+			r.Delete("/templates/{name}", ok)
+			r.Post("/apply-template", func(w http.ResponseWriter, r *http.Request) {
+				SetAuditTargetTenant(r.Context(), "42")
+				w.WriteHeader(http.StatusOK)
+			})
+		})
+		r.Route("/tenants/{tenantID}/users", func(r chi.Router) {
+			r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+				SetAuditResource(r.Context(), "bob")
+				w.WriteHeader(http.StatusCreated)
+			})
+			r.Put("/{username}", ok)
+			r.Delete("/{username}", ok)
+		})
+		r.Route("/auth", func(r chi.Router) {
+			r.Delete("/sessions/{sessionID}", func(w http.ResponseWriter, r *http.Request) {
+				if tenantID := r.URL.Query().Get("tenant_id"); tenantID != "" {
+					SetAuditTargetTenant(r.Context(), tenantID)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+			r.Post("/revoke", ok)
+		})
+		r.Route("/admin/tenants/{tenantID}/rate-limits", func(r chi.Router) {
+			r.Put("/", func(w http.ResponseWriter, r *http.Request) {
+				SetAuditTargetTenant(r.Context(), chi.URLParam(r, "tenantID"))
+				w.WriteHeader(http.StatusOK)
+			})
+			r.Delete("/", ok)
+		})
+	})
+	return r
+}
+
+func TestAuditMiddleware_RecordsActorForEachOperation(t *testing.T) {
+	tests := []struct {
+		method       string
+		path         string
+		action       string
+		tenantID     string
+		resourceID   string
+		statusCode   int
+		impersonated bool
+	}{
+		{http.MethodPost, "/v1/payments/iyzico", audit.ActionPaymentCreate, "7", "", http.StatusOK, false},
+		{http.MethodPost, "/v1/payments/iyzico/batch", audit.ActionPaymentBatchCreate, "7", "", http.StatusOK, false},
+		{http.MethodDelete, "/v1/payments/iyzico/pay_1", audit.ActionPaymentCancel, "7", "pay_1", http.StatusOK, false},
+		{http.MethodPost, "/v1/payments/stripe/pi_1/capture", audit.ActionPaymentCapture, "7", "pi_1", http.StatusOK, false},
+		{http.MethodPost, "/v1/payments/iyzico/refund", audit.ActionPaymentRefund, "7", "pay_refund", http.StatusOK, false},
+		{http.MethodPost, "/v1/payments/paycell/cards/register", audit.ActionCardRegister, "7", "", http.StatusOK, false},
+		{http.MethodDelete, "/v1/payments/paycell/cards/card_1", audit.ActionCardDelete, "7", "card_1", http.StatusOK, false},
+		{http.MethodPost, "/v1/payments/paycell/cards/card_1/pay", audit.ActionCardPay, "7", "card_1", http.StatusPaymentRequired, false},
+		{http.MethodPost, "/v1/subscriptions", audit.ActionSubscriptionCreate, "7", "", http.StatusOK, false},
+		{http.MethodDelete, "/v1/subscriptions/sub123", audit.ActionSubscriptionCancel, "7", "sub123", http.StatusOK, false},
+		{http.MethodPost, "/v1/payouts", audit.ActionPayoutCreate, "7", "po123", http.StatusCreated, false},
+		{http.MethodPost, "/v1/payment-links", audit.ActionPaymentLinkCreate, "7", "pl123", http.StatusCreated, false},
+		{http.MethodDelete, "/v1/payment-links/pl123", audit.ActionPaymentLinkCancel, "7", "pl123", http.StatusOK, false},
+		{http.MethodPost, "/v1/webhooks/deliveries/15/replay", audit.ActionWebhookReplay, "7", "15", http.StatusAccepted, false},
+		{http.MethodPost, "/v1/config/tenant", audit.ActionConfigUpdate, "7", "", http.StatusOK, false},
+		{http.MethodDelete, "/v1/config/tenant", audit.ActionConfigDelete, "7", "", http.StatusOK, false},
+		{http.MethodPost, "/v1/config/templates", audit.ActionConfigTemplateSave, "7", "", http.StatusOK, false},
+		{http.MethodDelete, "/v1/config/templates/iyzico-default", audit.ActionConfigTemplateDel, "7", "iyzico-default", http.StatusOK, false},
+		{http.MethodPost, "/v1/config/apply-template", audit.ActionConfigTemplateApply, "42", "", http.StatusOK, true},
+		{http.MethodPost, "/v1/tenants/7/users", audit.ActionUserCreate, "7", "bob", http.StatusCreated, false},
+		{http.MethodPut, "/v1/tenants/7/users/bob", audit.ActionUserUpdate, "7", "bob", http.StatusOK, false},
+		{http.MethodDelete, "/v1/tenants/7/users/bob", audit.ActionUserDelete, "7", "bob", http.StatusOK, false},
+		{http.MethodDelete, "/v1/auth/sessions/s1", audit.ActionSessionRevoke, "7", "s1", http.StatusOK, false},
+		{http.MethodDelete, "/v1/auth/sessions/s2?tenant_id=42", audit.ActionSessionRevoke, "42", "s2", http.StatusOK, true},
+		{http.MethodPost, "/v1/auth/revoke", audit.ActionSessionRevoke, "7", "", http.StatusOK, false},
+		{http.MethodPut, "/v1/admin/tenants/42/rate-limits", audit.ActionRateLimitUpdate, "42", "", http.StatusOK, true},
+		{http.MethodDelete, "/v1/admin/tenants/7/rate-limits", audit.ActionRateLimitReset, "7", "", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			store := &memoryAuditStore{}
+			router := newAuditTestRouter(store, "7", "ops@example.com")
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(store.events) != 1 {
+				t.Fatalf("Expected 1 audit event, got %d", len(store.events))
+			}
+			event := store.events[0]
+			if event.Action != tt.action {
+				t.Errorf("Expected action %s, got %s", tt.action, event.Action)
+			}
+			if event.Actor.TenantID != "7" || event.Actor.Username != "ops@example.com" {
+				t.Errorf("Expected actor 7/ops@example.com, got %s/%s", event.Actor.TenantID, event.Actor.Username)
+			}
+			if event.Actor.AuthMethod != audit.AuthMethodJWT {
+				t.Errorf("Expected auth method %s, got %s", audit.AuthMethodJWT, event.Actor.AuthMethod)
+			}
+			if event.Actor.SourceIP != "203.0.113.9" {
+				t.Errorf("Expected source IP 203.0.113.9, got %s", event.Actor.SourceIP)
+			}
+			if event.Actor.Impersonated != tt.impersonated {
+				t.Errorf("Expected impersonated %v, got %v", tt.impersonated, event.Actor.Impersonated)
+			}
+			if event.TenantID != tt.tenantID {
+				t.Errorf("Expected tenant %s, got %s", tt.tenantID, event.TenantID)
+			}
+			if event.ResourceID != tt.resourceID {
+				t.Errorf("Expected resource %q, got %q", tt.resourceID, event.ResourceID)
+			}
+			if event.StatusCode != tt.statusCode || event.Success != (tt.statusCode < 300) {
+				t.Errorf("Expected status %d, got %d (success %v)", tt.statusCode, event.StatusCode, event.Success)
+			}
+			// The path is recorded without the query
+			path, _, _ := strings.Cut(tt.path, "?")
+			if event.Method != tt.method || event.Path != path {
+				t.Errorf("Expected %s %s, got %s %s", tt.method, path, event.Method, event.Path)
+			}
+		})
+	}
+}
+
+func TestAuditMiddleware_SkipsReadOnlyRequests(t *testing.T) {
+	store := &memoryAuditStore{}
+	router := newAuditTestRouter(store, "7", "ops@example.com")
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/payments/iyzico/pay_1", nil),
+		httptest.NewRequest(http.MethodPost, "/v1/payments/iyzico/installments", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(store.events) != 0 {
+		t.Errorf("Expected no audit events, got %+v", store.events)
+	}
+}
+
+func TestAuditMiddleware_StoreErrorDoesNotFailRequest(t *testing.T) {
+	store := &memoryAuditStore{err: errors.New("db down")}
+	router := newAuditTestRouter(store, "7", "ops@example.com")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/payments/iyzico", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+}
@@ -7,21 +7,30 @@ import (
 
 	"github.com/mstgnz/gopay/infra/auth"
 	"github.com/mstgnz/gopay/infra/response"
+	"github.com/mstgnz/gopay/infra/tenantctx"
 )
 
 // TenantContextKey is the key for tenant information in request context
-type TenantContextKey string
+type TenantContextKey = tenantctx.Key
 
 const (
-	TenantIDKey     TenantContextKey = "tenant_id"
+	TenantIDKey     TenantContextKey = tenantctx.IDKey
 	TenantUserKey   TenantContextKey = "tenant_user"
 	TenantClaimsKey TenantContextKey = "tenant_claims"
+	TenantAPIKeyKey TenantContextKey = "tenant_api_key"
+	TenantRoleKey   TenantContextKey = "tenant_role"
 )
 
 // JWTAuthMiddleware validates JWT token authentication
 func JWTAuthMiddleware(jwtService *auth.JWTService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Requests authenticated by APIKeyAuthMiddleware need no token
+			if GetTenantAPIKeyFromContext(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Get Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
@@ -54,6 +63,8 @@ func JWTAuthMiddleware(jwtService *auth.JWTService) func(http.Handler) http.Hand
 					response.Error(w, http.StatusUnauthorized, "Invalid token claims", nil)
 				case auth.ErrMissingTenant:
 					response.Error(w, http.StatusUnauthorized, "Missing tenant information in token", nil)
+				case auth.ErrSessionRevoked:
+					response.Error(w, http.StatusUnauthorized, "Session has been revoked", nil)
 				default:
 					response.Error(w, http.StatusUnauthorized, "Token validation failed", nil)
 				}
@@ -73,10 +84,7 @@ func JWTAuthMiddleware(jwtService *auth.JWTService) func(http.Handler) http.Hand
 
 // GetTenantIDFromContext extracts tenant ID from request context
 func GetTenantIDFromContext(ctx context.Context) string {
-	if tenantID, ok := ctx.Value(TenantIDKey).(string); ok {
-		return tenantID
-	}
-	return ""
+	return tenantctx.ID(ctx)
 }
 
 // GetTenantUserFromContext extracts tenant username from request context
@@ -94,3 +102,11 @@ func GetTenantClaimsFromContext(ctx context.Context) *auth.JWTClaims {
 	}
 	return nil
 }
+
+// GetTenantAPIKeyFromContext extracts the API key a request was authenticated with
+func GetTenantAPIKeyFromContext(ctx context.Context) *auth.APIKey {
+	if key, ok := ctx.Value(TenantAPIKeyKey).(*auth.APIKey); ok {
+		return key
+	}
+	return nil
+}
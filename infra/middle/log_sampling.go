@@ -0,0 +1,101 @@
+package middle
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/postgres"
+)
+
+// logSampler decides whether the full payloads of a payment request are written to the
+// payment logs. Failures are always logged in full; successful requests are sampled at the
+// configured rate, and only their metadata is kept when they are not sampled.
+//
+// The rate is read from the first of these that is set, as a fraction between 0 and 1:
+//
+//	LOG_SAMPLE_RATE_TENANT_<ID>_<ENVIRONMENT>
+//	LOG_SAMPLE_RATE_TENANT_<ID>
+//	LOG_SAMPLE_RATE_<ENVIRONMENT>
+//	LOG_SAMPLE_RATE (default 1, every payload is logged)
+type logSampler struct {
+	random func() float64
+}
+
+func newLogSampler() *logSampler {
+	return &logSampler{random: rand.Float64}
+}
+
+// rate returns the sample rate of successful requests for a tenant and environment. The
+// environment is empty when the request does not name one, e.g. provider callbacks.
+func (s *logSampler) rate(tenantID int, environment string) float64 {
+	environment = strings.ToUpper(strings.TrimSpace(environment))
+	tenantKey := "LOG_SAMPLE_RATE_TENANT_" + strconv.Itoa(tenantID)
+
+	keys := make([]string, 0, 4)
+	if environment != "" {
+		keys = append(keys, tenantKey+"_"+environment)
+	}
+	keys = append(keys, tenantKey)
+	if environment != "" {
+		keys = append(keys, "LOG_SAMPLE_RATE_"+environment)
+	}
+	keys = append(keys, "LOG_SAMPLE_RATE")
+
+	for _, key := range keys {
+		value := strings.TrimSpace(config.GetEnv(key, ""))
+		if value == "" {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(value, 64); err == nil && rate >= 0 && rate <= 1 {
+			return rate
+		}
+	}
+	return 1
+}
+
+// keepPayload reports whether the request and response payloads are logged in full
+func (s *logSampler) keepPayload(tenantID int, environment string, failed bool) bool {
+	if failed {
+		return true
+	}
+
+	rate := s.rate(tenantID, environment)
+	if rate >= 1 {
+		return true
+	}
+	return rate > 0 && s.random() < rate
+}
+
+// isFailedPaymentResponse reports whether a logged request failed, either with an error
+// status or with a declined payment, which the API returns as 200 with success=false
+func isFailedPaymentResponse(statusCode int, responseData map[string]any) bool {
+	if statusCode >= 400 {
+		return true
+	}
+	success, ok := responseData["success"].(bool)
+	return ok && !success
+}
+
+// withoutPayloads replaces the request and response payloads of a log entry that was not
+// sampled with its metadata, so the request still shows up in the logs and stats
+func withoutPayloads(entry postgres.PaymentLog, statusCode int) postgres.PaymentLog {
+	entry.Request = map[string]any{
+		"payload_sampled": false,
+		"method":          entry.Method,
+		"endpoint":        entry.Endpoint,
+		"request_id":      entry.RequestID,
+		"client_ip":       entry.ClientIP,
+		"user_agent":      entry.UserAgent,
+	}
+	entry.Response = map[string]any{
+		"payload_sampled": false,
+		"status_code":     statusCode,
+		"processing_ms":   entry.ProcessingMs,
+	}
+	if entry.PaymentInfo != nil {
+		entry.Response["payment_info"] = entry.PaymentInfo
+	}
+	return entry
+}
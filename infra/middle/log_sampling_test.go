@@ -0,0 +1,142 @@
+package middle
+
+import (
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/postgres"
+)
+
+func TestLogSampler_Rate(t *testing.T) {
+	t.Setenv("LOG_SAMPLE_RATE", "0.5")
+	t.Setenv("LOG_SAMPLE_RATE_PRODUCTION", "0.1")
+	t.Setenv("LOG_SAMPLE_RATE_TENANT_7", "0.3")
+	t.Setenv("LOG_SAMPLE_RATE_TENANT_7_PRODUCTION", "0.05")
+	t.Setenv("LOG_SAMPLE_RATE_TENANT_8", "invalid")
+	t.Setenv("LOG_SAMPLE_RATE_TENANT_9", "1.5")
+
+	sampler := newLogSampler()
+	tests := []struct {
+		name        string
+		tenantID    int
+		environment string
+		expected    float64
+	}{
+		{"tenant and environment", 7, "production", 0.05},
+		{"tenant", 7, "sandbox", 0.3},
+		{"tenant without environment", 7, "", 0.3},
+		{"environment", 5, "production", 0.1},
+		{"global", 5, "sandbox", 0.5},
+		{"global without environment", 5, "", 0.5},
+		{"invalid tenant rate", 8, "sandbox", 0.5},
+		{"out of range tenant rate", 9, "production", 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampler.rate(tt.tenantID, tt.environment); got != tt.expected {
+				t.Errorf("Expected rate %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLogSampler_RateDefault(t *testing.T) {
+	if got := newLogSampler().rate(5, "production"); got != 1 {
+		t.Errorf("Expected every payload to be logged by default, got rate %v", got)
+	}
+}
+
+func TestLogSampler_KeepPayload(t *testing.T) {
+	t.Setenv("LOG_SAMPLE_RATE", "0.1")
+	t.Setenv("LOG_SAMPLE_RATE_TENANT_3", "0")
+
+	// Deterministic draws spread evenly over [0, 1)
+	const draws = 1000
+	next := 0
+	sampler := &logSampler{random: func() float64 {
+		value := float64(next%draws) / draws
+		next++
+		return value
+	}}
+
+	kept := 0
+	for range draws {
+		if sampler.keepPayload(5, "production", false) {
+			kept++
+		}
+	}
+	if kept != 100 {
+		t.Errorf("Expected 10%% of successful requests to keep payloads, got %d of %d", kept, draws)
+	}
+
+	for range draws {
+		if !sampler.keepPayload(5, "production", true) {
+			t.Fatal("Expected failed requests to always keep payloads")
+		}
+		if !sampler.keepPayload(3, "production", true) {
+			t.Fatal("Expected failed requests to keep payloads even at rate 0")
+		}
+		if sampler.keepPayload(3, "production", false) {
+			t.Fatal("Expected no payloads for successful requests at rate 0")
+		}
+	}
+}
+
+func TestIsFailedPaymentResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   int
+		responseData map[string]any
+		expected     bool
+	}{
+		{"successful payment", 200, map[string]any{"success": true}, false},
+		{"declined payment", 200, map[string]any{"success": false}, true},
+		{"validation error", 400, map[string]any{"success": false}, true},
+		{"server error without body", 500, map[string]any{}, true},
+		{"callback redirect", 302, map[string]any{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFailedPaymentResponse(tt.statusCode, tt.responseData); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithoutPayloads(t *testing.T) {
+	entry := postgres.PaymentLog{
+		TenantID:     5,
+		Provider:     "iyzico",
+		Method:       "POST",
+		Endpoint:     "/v1/payments/iyzico",
+		RequestID:    "req-1",
+		ClientIP:     "10.0.0.1",
+		Request:      map[string]any{"cardInfo": map[string]any{"cardNumber": "552879******0008"}},
+		Response:     map[string]any{"data": map[string]any{"paymentId": "pay-1"}},
+		PaymentInfo:  &postgres.PaymentInfo{PaymentID: "pay-1", Amount: 100, Currency: "TRY"},
+		ProcessingMs: 120,
+	}
+
+	got := withoutPayloads(entry, 200)
+
+	if _, ok := got.Request["cardInfo"]; ok {
+		t.Error("Expected the request payload to be dropped")
+	}
+	if _, ok := got.Response["data"]; ok {
+		t.Error("Expected the response payload to be dropped")
+	}
+	if got.Request["request_id"] != "req-1" || got.Request["endpoint"] != "/v1/payments/iyzico" || got.Request["payload_sampled"] != false {
+		t.Errorf("Expected request metadata, got %+v", got.Request)
+	}
+	if got.Response["status_code"] != 200 || got.Response["payment_info"] != entry.PaymentInfo {
+		t.Errorf("Expected response metadata, got %+v", got.Response)
+	}
+	if got.TenantID != 5 || got.Provider != "iyzico" || got.ProcessingMs != 120 {
+		t.Errorf("Expected log metadata to be kept, got %+v", got)
+	}
+	if _, ok := entry.Request["cardInfo"]; !ok {
+		t.Error("Expected the original entry to be left unchanged")
+	}
+}
@@ -42,8 +42,11 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
-// PaymentLoggingMiddleware creates a middleware for logging payment requests/responses
+// PaymentLoggingMiddleware creates a middleware for logging payment requests/responses.
+// Successful requests may be logged without their payloads, see logSampler.
 func PaymentLoggingMiddleware(postgresLogger *postgres.Logger) func(http.Handler) http.Handler {
+	sampler := newLogSampler()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip logging for non-payment endpoints
@@ -126,6 +129,11 @@ func PaymentLoggingMiddleware(postgresLogger *postgres.Logger) func(http.Handler
 				}
 			}
 
+			// Full payloads of successful requests are only kept for the sampled share
+			if !sampler.keepPayload(tenantIDInt, r.URL.Query().Get("environment"), isFailedPaymentResponse(rw.statusCode, responseData)) {
+				paymentLog = withoutPayloads(paymentLog, rw.statusCode)
+			}
+
 			// Log to PostgreSQL asynchronously to avoid blocking the response
 			go func() {
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -145,6 +153,11 @@ func PaymentLoggingMiddleware(postgresLogger *postgres.Logger) func(http.Handler
 
 // isPaymentEndpoint checks if the URL path is a payment-related endpoint
 func isPaymentEndpoint(path string) bool {
+	// Event streams are long-lived and not payment requests
+	if strings.HasSuffix(path, "/stream") {
+		return false
+	}
+
 	paymentPaths := []string{
 		"/v1/payments",
 		"/v1/callback",
@@ -0,0 +1,106 @@
+package middle
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/fingerprint"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// ServerTLSConfigFromEnv returns the TLS configuration of the HTTP server from TLS_CERT_FILE and
+// TLS_KEY_FILE, or nil when they are not set and the server runs plain HTTP behind a proxy.
+// Client certificates are requested but not required, so browsers and API clients connect as
+// before; the endpoints that need one check it. With TLS_CLIENT_CA_FILE they must be issued by
+// that CA, without it they are only checked against pinned fingerprints.
+func ServerTLSConfigFromEnv() (*tls.Config, error) {
+	certFile, keyFile := config.GetEnv("TLS_CERT_FILE", ""), config.GetEnv("TLS_KEY_FILE", "")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.RequestClientCert,
+	}
+	if caFile := config.GetEnv("TLS_CLIENT_CA_FILE", ""); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("client CA file has no certificates")
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// ClientCertificate returns the client certificate of a request, or nil. Behind a proxy that
+// terminates TLS, the certificate is read from the MTLS_CLIENT_CERT_HEADER header as URL
+// escaped PEM (nginx's $ssl_client_escaped_cert). Set it only when the proxy overwrites the
+// header, as clients could send it themselves otherwise.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0]
+	}
+
+	header := config.GetEnv("MTLS_CLIENT_CERT_HEADER", "")
+	if header == "" {
+		return nil
+	}
+	value := r.Header.Get(header)
+	if value == "" {
+		return nil
+	}
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// ClientCertificatePinned reports whether a request presents a client certificate with one of
+// the fingerprints
+func ClientCertificatePinned(r *http.Request, fingerprints []string) bool {
+	cert := ClientCertificate(r)
+	return cert != nil && slices.Contains(fingerprints, fingerprint.Of(cert))
+}
+
+// AdminClientCertMiddleware requires requests authenticated as the admin tenant to present a
+// client certificate pinned in ADMIN_CLIENT_CERT_FINGERPRINTS, so a stolen admin token is not
+// enough to reach admin endpoints. Without the variable it does nothing. It goes after the
+// authentication middleware.
+func AdminClientCertMiddleware() func(http.Handler) http.Handler {
+	fingerprints := fingerprint.Parse(config.GetEnv("ADMIN_CLIENT_CERT_FINGERPRINTS", ""))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(fingerprints) > 0 && GetTenantIDFromContext(r.Context()) == "1" && !ClientCertificatePinned(r, fingerprints) {
+				response.Error(w, http.StatusForbidden, "Admin requests require a pinned client certificate", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
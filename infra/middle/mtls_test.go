@@ -0,0 +1,104 @@
+package middle
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/fingerprint"
+)
+
+func newTestCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhooks.provider.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestClientCertificatePinned(t *testing.T) {
+	cert := newTestCertificate(t)
+	pins := []string{fingerprint.Of(cert)}
+
+	tlsRequest := httptest.NewRequest(http.MethodPost, "/v1/webhooks/stripe", nil)
+	tlsRequest.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if !ClientCertificatePinned(tlsRequest, pins) {
+		t.Error("Expected the TLS client certificate to be pinned")
+	}
+	if ClientCertificatePinned(tlsRequest, []string{strings.Repeat("00", 32)}) {
+		t.Error("Expected another pin to reject the certificate")
+	}
+
+	// The proxy header is trusted only when configured
+	escaped := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	proxied := httptest.NewRequest(http.MethodPost, "/v1/webhooks/stripe", nil)
+	proxied.Header.Set("X-Client-Cert", escaped)
+	if ClientCertificatePinned(proxied, pins) {
+		t.Error("Expected the header to be ignored without MTLS_CLIENT_CERT_HEADER")
+	}
+	t.Setenv("MTLS_CLIENT_CERT_HEADER", "X-Client-Cert")
+	if !ClientCertificatePinned(proxied, pins) {
+		t.Error("Expected the proxied client certificate to be pinned")
+	}
+}
+
+func TestAdminClientCertMiddleware(t *testing.T) {
+	cert := newTestCertificate(t)
+	t.Setenv("ADMIN_CLIENT_CERT_FINGERPRINTS", fingerprint.Of(cert))
+	handler := AdminClientCertMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name     string
+		tenantID string
+		cert     *x509.Certificate
+		want     int
+	}{
+		{"admin with pinned certificate", "1", cert, http.StatusNoContent},
+		{"admin without certificate", "1", nil, http.StatusForbidden},
+		{"admin with other certificate", "1", newTestCertificate(t), http.StatusForbidden},
+		{"tenant without certificate", "7", nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/config/validate-all", nil)
+			req = req.WithContext(context.WithValue(req.Context(), TenantIDKey, tt.tenantID))
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("code = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
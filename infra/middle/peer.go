@@ -0,0 +1,119 @@
+package middle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// peerAddrKey holds the address of the connection a request arrived on
+type peerAddrKey struct{}
+
+// PeerAddrMiddleware records the address of the connection a request arrived on. It goes
+// before chi's RealIP middleware, which replaces RemoteAddr with whatever the proxy headers
+// claim, so checks that must not trust those headers can still see the real peer.
+func PeerAddrMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerAddrKey{}, r.RemoteAddr)))
+		})
+	}
+}
+
+// PeerAddr returns the address of the connection a request arrived on, as recorded by
+// PeerAddrMiddleware, or RemoteAddr when the middleware did not run
+func PeerAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(peerAddrKey{}).(string); ok {
+		return addr
+	}
+	return r.RemoteAddr
+}
+
+// TrustedProxiesFromEnv reads TRUSTED_PROXY_CIDRS, the comma separated IPs and CIDR ranges of
+// the proxies in front of the server whose forwarding headers are believed. Invalid entries are
+// logged and skipped.
+func TrustedProxiesFromEnv() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(config.GetEnv("TRUSTED_PROXY_CIDRS", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			logger.Warn("Ignoring invalid trusted proxy entry", logger.LogContext{
+				Fields: map[string]any{
+					"env":   "TRUSTED_PROXY_CIDRS",
+					"value": entry,
+				},
+			})
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// TrustedClientIP returns the IP of the client of a request. Forwarding headers are only
+// honoured when the peer is a proxy in TRUSTED_PROXY_CIDRS: the client is then the right-most
+// X-Forwarded-For entry that is not a trusted proxy, or X-Real-IP. A client sending the headers
+// directly gets its own peer address.
+func TrustedClientIP(r *http.Request) string {
+	peer := PeerAddr(r)
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	trusted := TrustedProxiesFromEnv()
+	if !prefixesContain(trusted, peer) {
+		return peer
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if i == 0 || !prefixesContain(trusted, hop) {
+				return hop
+			}
+		}
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+	return peer
+}
+
+// parsePrefix parses a CIDR range or a single IP, which is a range of one address
+func parsePrefix(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// prefixesContain reports whether ip is in one of the prefixes
+func prefixesContain(prefixes []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
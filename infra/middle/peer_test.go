@@ -0,0 +1,62 @@
+package middle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestTrustedClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8, 2001:db8::1, not-a-cidr")
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		want         string
+	}{
+		{"direct client", "203.0.113.7:443", "", "", "203.0.113.7"},
+		{"spoofed forwarded for from untrusted peer", "203.0.113.7:443", "198.51.100.10", "", "203.0.113.7"},
+		{"spoofed real ip from untrusted peer", "203.0.113.7:443", "", "198.51.100.10", "203.0.113.7"},
+		{"client forwarded by trusted proxy", "10.0.0.2:443", "198.51.100.10", "", "198.51.100.10"},
+		{"right-most untrusted hop", "10.0.0.2:443", "198.51.100.10, 203.0.113.7, 10.1.1.1", "", "203.0.113.7"},
+		{"real ip from trusted proxy", "[2001:db8::1]:443", "", "198.51.100.10", "198.51.100.10"},
+		{"trusted proxy without headers", "10.0.0.2:443", "", "", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := PeerAddrMiddleware()(middleware.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = TrustedClientIP(r)
+			})))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/paytr", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("TrustedClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedClientIP_WithoutPeerAddrMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/paytr", nil)
+	req.RemoteAddr = "203.0.113.7:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.10")
+
+	if got := TrustedClientIP(req); got != "203.0.113.7" {
+		t.Errorf("TrustedClientIP() = %q, want the peer address", got)
+	}
+}
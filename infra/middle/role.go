@@ -0,0 +1,63 @@
+package middle
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// RoleMiddleware resolves the role of the authenticated user within their tenant, for
+// RequirePermission. It goes after the authentication middleware.
+func RoleMiddleware(roles *auth.RoleService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID, err := strconv.Atoi(GetTenantIDFromContext(r.Context()))
+			if err != nil {
+				response.Error(w, http.StatusUnauthorized, "Invalid tenant", nil)
+				return
+			}
+
+			role, err := roles.RoleOf(r.Context(), tenantID, GetTenantUserFromContext(r.Context()))
+			if err != nil {
+				response.Error(w, http.StatusInternalServerError, "Failed to resolve role", err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), TenantRoleKey, role)))
+		})
+	}
+}
+
+// GetTenantRoleFromContext returns the role resolved by RoleMiddleware, or "" without it
+func GetTenantRoleFromContext(ctx context.Context) auth.Role {
+	if role, ok := ctx.Value(TenantRoleKey).(auth.Role); ok {
+		return role
+	}
+	return ""
+}
+
+// RequirePermission rejects requests whose role lacks a permission with 403. Requests without
+// a resolved role, where RoleMiddleware is not installed, are let through.
+func RequirePermission(permission auth.Permission) func(http.Handler) http.Handler {
+	return RequireReadWritePermission(permission, permission)
+}
+
+// RequireReadWritePermission is RequirePermission with read for GET and HEAD requests and write
+// for the others
+func RequireReadWritePermission(read, write auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			permission := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				permission = read
+			}
+			if role := GetTenantRoleFromContext(r.Context()); role != "" && !role.Allows(permission) {
+				response.Error(w, http.StatusForbidden, "Your role "+string(role)+" does not allow "+string(permission), nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
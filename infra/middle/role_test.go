@@ -0,0 +1,63 @@
+package middle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/auth"
+)
+
+// fixedRoleStore assigns every user the same role
+type fixedRoleStore struct {
+	auth.RoleStore
+	role auth.Role
+}
+
+func (s fixedRoleStore) Get(_ context.Context, tenantID int, username string) (*auth.UserRole, error) {
+	return &auth.UserRole{TenantID: tenantID, Username: username, Role: s.role}, nil
+}
+
+func TestRequireReadWritePermission(t *testing.T) {
+	access := RequireReadWritePermission(auth.PermissionConfigRead, auth.PermissionConfigWrite)
+	tests := []struct {
+		name   string
+		role   auth.Role
+		method string
+		want   int
+	}{
+		{"read-only reads", auth.RoleReadOnly, http.MethodGet, http.StatusNoContent},
+		{"read-only writes", auth.RoleReadOnly, http.MethodPost, http.StatusForbidden},
+		{"developer writes", auth.RoleDeveloper, http.MethodDelete, http.StatusNoContent},
+		{"finance reads", auth.RoleFinance, http.MethodGet, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RoleMiddleware(auth.NewRoleService(fixedRoleStore{role: tt.role}))(access(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})))
+
+			req := httptest.NewRequest(tt.method, "/v1/config/tenant", nil)
+			ctx := context.WithValue(req.Context(), TenantIDKey, "7")
+			ctx = context.WithValue(ctx, TenantUserKey, "bob")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req.WithContext(ctx))
+			if rec.Code != tt.want {
+				t.Errorf("code = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirePermission_WithoutRole(t *testing.T) {
+	// Without RoleMiddleware, as in embedded use, no role is checked
+	handler := RequirePermission(auth.PermissionRefunds)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/payments/iyzico/refund", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("code = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
@@ -68,9 +68,11 @@ func RequestValidationMiddleware() func(http.Handler) http.Handler {
 			if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
 				contentType := r.Header.Get("Content-Type")
 
-				// Special case for callback endpoints (banks send form-urlencoded)
+				// Special case for callback endpoints (banks send form-urlencoded) and the
+				// payment link checkout pages (browser forms)
 				isCallbackEndpoint := strings.HasPrefix(r.URL.Path, "/v1/callback") ||
-					strings.HasPrefix(r.URL.Path, "/v1/webhooks")
+					strings.HasPrefix(r.URL.Path, "/v1/webhooks") ||
+					strings.HasPrefix(r.URL.Path, "/pay/")
 
 				if contentType != "" {
 					if isCallbackEndpoint {
@@ -1,6 +1,8 @@
 package middle
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
 	"github.com/mstgnz/gopay/infra/response"
 )
 
@@ -18,8 +21,12 @@ type TenantRateLimiter struct {
 	ips     map[string]*visitor      // ip -> bucket (for unauthenticated requests)
 	mu      sync.RWMutex
 	config  *TenantRateLimitConfig
+	store   TenantLimitStore // persists TenantOverrides, nil keeps them in memory
 }
 
+// ErrTenantLimitsNotFound is returned when resetting a tenant without custom limits
+var ErrTenantLimitsNotFound = errors.New("tenant has no custom rate limits")
+
 // tenantBucket holds rate limiting information for a specific tenant
 type tenantBucket struct {
 	actions    map[string]*actionBucket // action -> bucket
@@ -107,9 +114,88 @@ func loadTenantRateLimitConfig() *TenantRateLimitConfig {
 	cfg.DefaultStatusRate = config.GetIntEnv("TENANT_STATUS_RATE_LIMIT", 200)    // 200/min status checks per tenant
 	cfg.UnauthenticatedRate = config.GetIntEnv("UNAUTHENTICATED_RATE_LIMIT", 10) // 10/min per IP for unauthenticated
 
+	for _, tenantID := range strings.Split(config.GetEnv("PREMIUM_TENANTS", ""), ",") {
+		if tenantID = strings.TrimSpace(tenantID); tenantID != "" {
+			cfg.PremiumTenants[tenantID] = true
+		}
+	}
+
 	return cfg
 }
 
+// SetLimitStore persists the limits set with SetTenantLimits and loads the stored ones, which
+// replace the environment defaults for their tenants. They are reloaded with every cleanup, so
+// limits set on another instance apply within 5 minutes.
+func (trl *TenantRateLimiter) SetLimitStore(ctx context.Context, store TenantLimitStore) error {
+	trl.mu.Lock()
+	trl.store = store
+	trl.mu.Unlock()
+	return trl.loadTenantLimits(ctx, store)
+}
+
+// loadTenantLimits replaces the tenant overrides with the stored limits
+func (trl *TenantRateLimiter) loadTenantLimits(ctx context.Context, store TenantLimitStore) error {
+	limits, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	trl.config.TenantOverrides = limits
+	return nil
+}
+
+// GetTenantLimits returns the limits applied to a tenant and whether they were set for it
+func (trl *TenantRateLimiter) GetTenantLimits(tenantID string) (*TenantLimits, bool) {
+	trl.mu.RLock()
+	defer trl.mu.RUnlock()
+	_, custom := trl.config.TenantOverrides[tenantID]
+	return trl.getTenantLimits(tenantID), custom
+}
+
+// SetTenantLimits replaces the environment defaults of a tenant with its own limits
+func (trl *TenantRateLimiter) SetTenantLimits(ctx context.Context, tenantID string, limits TenantLimits) error {
+	trl.mu.RLock()
+	store := trl.store
+	trl.mu.RUnlock()
+
+	if store != nil {
+		if err := store.Save(ctx, tenantID, &limits); err != nil {
+			return err
+		}
+	}
+
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	trl.config.TenantOverrides[tenantID] = &limits
+	return nil
+}
+
+// ResetTenantLimits removes the limits set for a tenant, which gets the environment defaults again
+func (trl *TenantRateLimiter) ResetTenantLimits(ctx context.Context, tenantID string) error {
+	trl.mu.RLock()
+	store := trl.store
+	_, custom := trl.config.TenantOverrides[tenantID]
+	trl.mu.RUnlock()
+
+	if store != nil {
+		deleted, err := store.Delete(ctx, tenantID)
+		if err != nil {
+			return err
+		}
+		custom = custom || deleted
+	}
+	if !custom {
+		return ErrTenantLimitsNotFound
+	}
+
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	delete(trl.config.TenantOverrides, tenantID)
+	return nil
+}
+
 // Allow checks if the request is allowed for a specific tenant and action
 func (trl *TenantRateLimiter) Allow(tenantID string, action ActionType, clientIP string) (bool, *RateLimitInfo) {
 	trl.mu.Lock()
@@ -337,7 +423,19 @@ func (trl *TenantRateLimiter) cleanup() {
 			}
 		}
 
+		store := trl.store
 		trl.mu.Unlock()
+
+		// Pick up the limits changed on other instances
+		if store != nil {
+			if err := trl.loadTenantLimits(context.Background(), store); err != nil {
+				logger.Warn("Failed to reload tenant rate limits", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+		}
 	}
 }
 
@@ -502,6 +600,7 @@ func (trl *TenantRateLimiter) GetTenantRateLimitStats(tenantID string) map[strin
 
 		stats["tenant_id"] = tenantID
 		stats["is_premium"] = trl.config.PremiumTenants[tenantID]
+		_, stats["custom_limits"] = trl.config.TenantOverrides[tenantID]
 		stats["global_limit"] = limits.GlobalRate
 		stats["global_used"] = bucket.globalRate.count
 		stats["global_remaining"] = max(0, limits.GlobalRate-bucket.globalRate.count)
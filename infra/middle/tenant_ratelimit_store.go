@@ -0,0 +1,82 @@
+package middle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TenantLimitStore persists the rate limits the admin sets for tenants.
+// PostgresTenantLimitStore is the production implementation.
+type TenantLimitStore interface {
+	// List returns the limits of every tenant that has custom limits
+	List(ctx context.Context) (map[string]*TenantLimits, error)
+
+	// Save creates or replaces the limits of a tenant
+	Save(ctx context.Context, tenantID string, limits *TenantLimits) error
+
+	// Delete removes the limits of a tenant and reports whether it had any
+	Delete(ctx context.Context, tenantID string) (bool, error)
+}
+
+// PostgresTenantLimitStore keeps tenant rate limits in the tenant_rate_limits table.
+type PostgresTenantLimitStore struct {
+	db *sql.DB
+}
+
+// NewPostgresTenantLimitStore creates a store over the shared *sql.DB connection.
+func NewPostgresTenantLimitStore(db *sql.DB) *PostgresTenantLimitStore {
+	return &PostgresTenantLimitStore{db: db}
+}
+
+// List returns the limits of every tenant that has custom limits
+func (r *PostgresTenantLimitStore) List(ctx context.Context) (map[string]*TenantLimits, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT tenant_id, global_rate, payment_rate, refund_rate, status_rate FROM tenant_rate_limits`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant rate limits: %w", err)
+	}
+	defer rows.Close()
+
+	limits := make(map[string]*TenantLimits)
+	for rows.Next() {
+		var tenantID string
+		var l TenantLimits
+		if err := rows.Scan(&tenantID, &l.GlobalRate, &l.PaymentRate, &l.RefundRate, &l.StatusRate); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant rate limits: %w", err)
+		}
+		limits[tenantID] = &l
+	}
+	return limits, rows.Err()
+}
+
+// Save creates or replaces the limits of a tenant
+func (r *PostgresTenantLimitStore) Save(ctx context.Context, tenantID string, limits *TenantLimits) error {
+	query := `
+		INSERT INTO tenant_rate_limits (tenant_id, global_rate, payment_rate, refund_rate, status_rate, updated_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (tenant_id) DO UPDATE SET
+			global_rate = EXCLUDED.global_rate,
+			payment_rate = EXCLUDED.payment_rate,
+			refund_rate = EXCLUDED.refund_rate,
+			status_rate = EXCLUDED.status_rate,
+			updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.ExecContext(ctx, query, tenantID, limits.GlobalRate, limits.PaymentRate, limits.RefundRate, limits.StatusRate)
+	if err != nil {
+		return fmt.Errorf("failed to save tenant rate limits: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the limits of a tenant
+func (r *PostgresTenantLimitStore) Delete(ctx context.Context, tenantID string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM tenant_rate_limits WHERE tenant_id = $1`, tenantID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete tenant rate limits: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
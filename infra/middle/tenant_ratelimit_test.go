@@ -2,6 +2,7 @@ package middle
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -171,6 +172,89 @@ func TestTenantRateLimiter_PremiumTenants(t *testing.T) {
 	}
 }
 
+// memoryTenantLimitStore is an in-memory TenantLimitStore for tests.
+type memoryTenantLimitStore struct {
+	limits map[string]TenantLimits
+}
+
+func (m *memoryTenantLimitStore) List(_ context.Context) (map[string]*TenantLimits, error) {
+	limits := make(map[string]*TenantLimits)
+	for tenantID, l := range m.limits {
+		limits[tenantID] = &l
+	}
+	return limits, nil
+}
+
+func (m *memoryTenantLimitStore) Save(_ context.Context, tenantID string, limits *TenantLimits) error {
+	m.limits[tenantID] = *limits
+	return nil
+}
+
+func (m *memoryTenantLimitStore) Delete(_ context.Context, tenantID string) (bool, error) {
+	_, ok := m.limits[tenantID]
+	delete(m.limits, tenantID)
+	return ok, nil
+}
+
+func TestTenantRateLimiter_StoredTenantLimits(t *testing.T) {
+	ctx := context.Background()
+	rl := &TenantRateLimiter{
+		tenants: make(map[string]*tenantBucket),
+		ips:     make(map[string]*visitor),
+		config: &TenantRateLimitConfig{
+			DefaultGlobalRate:  10,
+			DefaultPaymentRate: 1,
+			DefaultRefundRate:  1,
+			DefaultStatusRate:  1,
+			DefaultWindow:      time.Minute,
+			TenantOverrides:    make(map[string]*TenantLimits),
+			PremiumTenants:     make(map[string]bool),
+			PremiumMultiplier:  2.0,
+		},
+	}
+	store := &memoryTenantLimitStore{limits: map[string]TenantLimits{
+		"7": {GlobalRate: 10, PaymentRate: 3, RefundRate: 1, StatusRate: 1},
+	}}
+
+	// Limits stored before a restart apply once the store is set
+	if err := rl.SetLimitStore(ctx, store); err != nil {
+		t.Fatalf("SetLimitStore failed: %v", err)
+	}
+	if limits, custom := rl.GetTenantLimits("7"); !custom || limits.PaymentRate != 3 {
+		t.Errorf("Expected the stored payment limit 3, got %+v (custom %v)", limits, custom)
+	}
+	for i := 0; i < 3; i++ {
+		if allowed, _ := rl.Allow("7", ActionPayment, "10.0.0.1"); !allowed {
+			t.Fatalf("Expected payment %d to be allowed", i+1)
+		}
+	}
+	if allowed, _ := rl.Allow("7", ActionPayment, "10.0.0.1"); allowed {
+		t.Error("Expected the fourth payment to be blocked")
+	}
+
+	// Set limits are persisted
+	if err := rl.SetTenantLimits(ctx, "8", TenantLimits{GlobalRate: 20, PaymentRate: 5, RefundRate: 2, StatusRate: 9}); err != nil {
+		t.Fatalf("SetTenantLimits failed: %v", err)
+	}
+	if store.limits["8"].StatusRate != 9 {
+		t.Errorf("Expected the limits to be stored, got %+v", store.limits["8"])
+	}
+
+	// Reset tenants get the defaults again
+	if err := rl.ResetTenantLimits(ctx, "7"); err != nil {
+		t.Fatalf("ResetTenantLimits failed: %v", err)
+	}
+	if limits, custom := rl.GetTenantLimits("7"); custom || limits.PaymentRate != 1 {
+		t.Errorf("Expected the default payment limit 1, got %+v (custom %v)", limits, custom)
+	}
+	if _, ok := store.limits["7"]; ok {
+		t.Error("Expected the limits to be deleted from the store")
+	}
+	if err := rl.ResetTenantLimits(ctx, "7"); !errors.Is(err, ErrTenantLimitsNotFound) {
+		t.Errorf("Expected ErrTenantLimitsNotFound, got %v", err)
+	}
+}
+
 func TestDetermineActionType(t *testing.T) {
 	tests := []struct {
 		path     string
@@ -0,0 +1,304 @@
+// Package openapi generates the OpenAPI document of the API from its router: every route is a
+// path, documented with the request and response types registered for it and named after its
+// handler otherwise, so the document cannot drift from the routes that are served.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// documentedMethods are the methods a route is documented for. Routes registered for every
+// method, like provider callbacks, are documented for these only.
+var documentedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// pathParamPattern matches the parameters of a chi pattern, with their optional regexp
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Operation describes an operation beyond what its route and handler tell
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Request is a value of the JSON request body's type
+	Request any
+	// Response is a value of the type of the response's data
+	Response any
+	// Status of a successful response, http.StatusOK by default
+	Status int
+	Query  []Parameter
+}
+
+// Parameter is a query parameter of an operation
+type Parameter struct {
+	Name        string
+	Description string
+	// Type is the JSON type of the parameter, string by default
+	Type     string
+	Required bool
+}
+
+// Route is a route of the router with its handler and middlewares
+type Route struct {
+	Method      string
+	Pattern     string
+	Handler     http.Handler
+	Middlewares []func(http.Handler) http.Handler
+}
+
+// Info describes the API in the document
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Server is a server of the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem is an operation of a path
+type PathItem struct {
+	Summary     string                    `json:"summary,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Tags        []string                  `json:"tags,omitempty"`
+	Parameters  []ParameterObject         `json:"parameters,omitempty"`
+	RequestBody *RequestBody              `json:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+	Security    []map[string][]string     `json:"security,omitempty"`
+}
+
+// ParameterObject is a path or query parameter
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the JSON body of a request
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// ResponseObject is a response of an operation
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas and security schemes referenced by the document
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is the JWT bearer authentication of the API
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Walk returns the routes of a router, with the middlewares of route groups, which chi
+// chains into their handlers
+func Walk(routes chi.Routes) ([]Route, error) {
+	var walked []Route
+	err := chi.Walk(routes, func(method, pattern string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route := Route{Method: method, Pattern: pattern, Handler: handler, Middlewares: middlewares}
+		for {
+			chain, ok := route.Handler.(*chi.ChainHandler)
+			if !ok {
+				break
+			}
+			route.Middlewares = append(route.Middlewares, chain.Middlewares...)
+			route.Handler = chain.Endpoint
+		}
+		walked = append(walked, route)
+		return nil
+	})
+	return walked, err
+}
+
+// Build generates the document of routes. Operations are keyed by "METHOD /pattern". Routes
+// behind authMiddleware, named by its function (for example "middle.JWTAuthMiddleware"),
+// require a bearer token.
+func Build(info Info, serverURL string, routes []Route, operations map[string]Operation, authMiddleware string) *Document {
+	schemas := newSchemaBuilder()
+	apiResponse := schemas.named(reflect.TypeOf(response.Response{}), "ApiResponse")
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]*PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{"BearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"}},
+		},
+	}
+	if serverURL != "" {
+		doc.Servers = []Server{{URL: serverURL}}
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(documentedMethods, route.Method) || strings.HasSuffix(route.Pattern, "*") {
+			continue
+		}
+		pattern := route.Pattern
+		if len(pattern) > 1 {
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		path := pathParamPattern.ReplaceAllString(pattern, "{$1}")
+
+		op := operations[route.Method+" "+path]
+		item := &PathItem{
+			Summary:     op.Summary,
+			Description: op.Description,
+			Tags:        []string{op.Tag},
+			Responses:   make(map[string]ResponseObject),
+		}
+		if item.Summary == "" {
+			item.Summary = summaryOf(route.Handler, route.Method, path)
+		}
+		if op.Tag == "" {
+			item.Tags = []string{tagOf(path)}
+		}
+
+		for _, match := range pathParamPattern.FindAllStringSubmatch(pattern, -1) {
+			item.Parameters = append(item.Parameters, ParameterObject{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, param := range op.Query {
+			paramType := param.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			item.Parameters = append(item.Parameters, ParameterObject{
+				Name:        param.Name,
+				In:          "query",
+				Description: param.Description,
+				Required:    param.Required,
+				Schema:      &Schema{Type: paramType},
+			})
+		}
+
+		if request := schemas.ref(op.Request); request != nil {
+			item.RequestBody = &RequestBody{Required: true, Content: jsonContent(request)}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := apiResponse
+		if data := schemas.ref(op.Response); data != nil {
+			success = &Schema{AllOf: []*Schema{apiResponse, {Type: "object", Properties: map[string]*Schema{"data": data}}}}
+		}
+		item.Responses[strconv.Itoa(status)] = ResponseObject{Description: http.StatusText(status), Content: jsonContent(success)}
+		item.Responses["default"] = ResponseObject{Description: "Error", Content: jsonContent(apiResponse)}
+
+		if hasMiddleware(route.Middlewares, authMiddleware) {
+			item.Security = []map[string][]string{{"BearerAuth": {}}}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*PathItem)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = item
+	}
+
+	doc.Components.Schemas = schemas.components
+	return doc
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// hasMiddleware reports whether a middleware was created by the named function
+func hasMiddleware(middlewares []func(http.Handler) http.Handler, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, middleware := range middlewares {
+		if strings.Contains(funcName(middleware), name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryOf names an operation after its handler method, "ProcessPayment" as "Process
+// payment", or after its route for anonymous handlers
+func summaryOf(handler http.Handler, method, path string) string {
+	name := funcName(handler)
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+	if name == "" || strings.HasPrefix(name, "func") {
+		return method + " " + path
+	}
+
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	words = append(words, strings.ToLower(string(runes[start:])))
+	words[0] = exportedName(words[0])
+	return strings.Join(words, " ")
+}
+
+// tagOf groups an operation by the first segment of its path after the version,
+// "/v1/payment-links/{linkID}" as "Payment links"
+func tagOf(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 1 && segments[0] == "v1" {
+		segments = segments[1:]
+	}
+	if segments[0] == "" {
+		return "General"
+	}
+	return exportedName(strings.ReplaceAll(segments[0], "-", " "))
+}
+
+// funcName returns the name of the function behind a handler or middleware
+func funcName(fn any) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return ""
+	}
+	if f := runtime.FuncForPC(value.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
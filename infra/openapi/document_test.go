@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type testItem struct {
+	Name string `json:"name" validate:"required,max=10"`
+}
+
+type testBase struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type testOrder struct {
+	testBase
+	Amount   float64           `json:"amount" validate:"required,gt=0"`
+	Items    []testItem        `json:"items,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Parent   *testOrder        `json:"parent,omitempty"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+type testHandler struct{}
+
+func (testHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {}
+func (testHandler) GetOrder(w http.ResponseWriter, r *http.Request)    {}
+
+func testAuthMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler { return next }
+}
+
+func testDocument(t *testing.T) *Document {
+	t.Helper()
+	h := testHandler{}
+	r := chi.NewRouter()
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
+	r.HandleFunc("/v1/callback/*", func(w http.ResponseWriter, r *http.Request) {})
+	r.Route("/v1/orders", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(testAuthMiddleware())
+			r.Post("/", h.CreateOrder)
+			r.Get("/{orderID:[a-z0-9]+}", h.GetOrder)
+		})
+	})
+
+	routes, err := Walk(r)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	operations := map[string]Operation{
+		"POST /v1/orders": {Request: testOrder{}, Response: testOrder{}, Status: http.StatusCreated,
+			Query: []Parameter{{Name: "dryRun", Type: "boolean"}}},
+	}
+	return Build(Info{Title: "Test", Version: "1.0.0"}, "http://localhost", routes, operations, "openapi.testAuthMiddleware")
+}
+
+func TestBuild_Paths(t *testing.T) {
+	doc := testDocument(t)
+
+	if _, ok := doc.Paths["/v1/callback/*"]; ok {
+		t.Error("Expected catch-all routes to be skipped")
+	}
+	if len(doc.Paths["/health"]) != 1 || doc.Paths["/health"]["get"].Security != nil {
+		t.Errorf("Expected a public GET /health, got %+v", doc.Paths["/health"])
+	}
+
+	create := doc.Paths["/v1/orders"]["post"]
+	if create == nil {
+		t.Fatalf("Expected POST /v1/orders without its trailing slash, got %v", doc.Paths)
+	}
+	if create.Summary != "Create order" || !slices.Equal(create.Tags, []string{"Orders"}) {
+		t.Errorf("Expected the summary and tag from the handler and path, got %q %v", create.Summary, create.Tags)
+	}
+	if create.Security == nil {
+		t.Error("Expected the route behind the auth middleware to require a bearer token")
+	}
+	if create.RequestBody == nil || create.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/TestOrder" {
+		t.Errorf("Expected the order request body, got %+v", create.RequestBody)
+	}
+	if _, ok := create.Responses["201"]; !ok {
+		t.Errorf("Expected a 201 response, got %v", create.Responses)
+	}
+	if len(create.Parameters) != 1 || create.Parameters[0].In != "query" || create.Parameters[0].Schema.Type != "boolean" {
+		t.Errorf("Expected the dryRun query parameter, got %+v", create.Parameters)
+	}
+
+	get := doc.Paths["/v1/orders/{orderID}"]["get"]
+	if get == nil {
+		t.Fatalf("Expected the path parameter without its regexp, got %v", doc.Paths)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "orderID" || get.Parameters[0].In != "path" || !get.Parameters[0].Required {
+		t.Errorf("Expected the orderID path parameter, got %+v", get.Parameters)
+	}
+	if get.RequestBody != nil {
+		t.Error("Expected no request body without a registered operation")
+	}
+	if get.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/ApiResponse" {
+		t.Errorf("Expected the plain API response, got %+v", get.Responses["200"])
+	}
+}
+
+func TestBuild_Schemas(t *testing.T) {
+	doc := testDocument(t)
+
+	order := doc.Components.Schemas["TestOrder"]
+	if order == nil {
+		t.Fatalf("Expected the TestOrder component, got %v", doc.Components.Schemas)
+	}
+	for _, name := range []string{"id", "createdAt", "amount", "items", "metadata", "parent"} {
+		if order.Properties[name] == nil {
+			t.Errorf("Expected property %q, got %v", name, order.Properties)
+		}
+	}
+	for _, name := range []string{"Secret", "internal", "testBase"} {
+		if order.Properties[name] != nil {
+			t.Errorf("Expected no property %q", name)
+		}
+	}
+	if !slices.Equal(order.Required, []string{"amount"}) {
+		t.Errorf("Expected amount to be required, got %v", order.Required)
+	}
+	if order.Properties["createdAt"].Format != "date-time" {
+		t.Errorf("Expected a date-time, got %+v", order.Properties["createdAt"])
+	}
+	if order.Properties["parent"].Ref != "#/components/schemas/TestOrder" {
+		t.Errorf("Expected the recursive field to reference its component, got %+v", order.Properties["parent"])
+	}
+	if items := order.Properties["items"]; items.Type != "array" || items.Items.Ref != "#/components/schemas/TestItem" {
+		t.Errorf("Expected an array of items, got %+v", items)
+	}
+	if metadata := order.Properties["metadata"]; metadata.Type != "object" || metadata.AdditionalProperties.Type != "string" {
+		t.Errorf("Expected a string map, got %+v", metadata)
+	}
+	if doc.Components.Schemas["ApiResponse"] == nil {
+		t.Error("Expected the ApiResponse component")
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("Expected the document to marshal: %v", err)
+	}
+}
+
+func TestSummaryOfAndTagOf(t *testing.T) {
+	if got := summaryOf(http.HandlerFunc(testHandler{}.GetOrder), http.MethodGet, "/v1/orders/{orderID}"); got != "Get order" {
+		t.Errorf("Expected \"Get order\", got %q", got)
+	}
+	if got := summaryOf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), http.MethodGet, "/health"); got != "GET /health" {
+		t.Errorf("Expected the route for an anonymous handler, got %q", got)
+	}
+	for path, want := range map[string]string{"/v1/payment-links/{linkID}": "Payment links", "/health": "Health", "/": "General"} {
+		if got := tagOf(path); got != want {
+			t.Errorf("tagOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
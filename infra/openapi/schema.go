@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is an OpenAPI schema object, the subset generated from Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaBuilder generates the schemas of Go types as encoding/json marshals them. Named
+// structs become components referenced by $ref; other types are inlined.
+type schemaBuilder struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// ref returns the schema of a value's type, nil for a nil value
+func (b *schemaBuilder) ref(value any) *Schema {
+	if value == nil {
+		return nil
+	}
+	return b.schemaOf(reflect.TypeOf(value))
+}
+
+// named registers the schema of a struct type under name and returns its reference
+func (b *schemaBuilder) named(t reflect.Type, name string) *Schema {
+	if existing, ok := b.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + existing}
+	}
+	b.names[t] = name
+	// The component is registered before its fields so recursive types end in a reference
+	schema := &Schema{}
+	b.components[name] = schema
+	*schema = b.structSchema(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			schema := b.structSchema(t)
+			return &schema
+		}
+		return b.named(t, b.componentName(t))
+	}
+	// Interfaces and anything else accept any value
+	return &Schema{}
+}
+
+// componentName names the component of a struct type after the type, with its package
+// in front when another package has a type of the same name
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	name := exportedName(t.Name())
+	if _, taken := b.components[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	return exportedName(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+}
+
+// structSchema returns the object schema of a struct's JSON fields. Fields validated as
+// required are required.
+func (b *schemaBuilder) structSchema(t reflect.Type) Schema {
+	schema := Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.addFields(&schema, t)
+	return schema
+}
+
+func (b *schemaBuilder) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Untagged embedded structs have their fields promoted
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = b.schemaOf(field.Type)
+		if validatedRequired(field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// validatedRequired reports whether a validate tag has the required rule
+func validatedRequired(tag string) bool {
+	for rule := range strings.SplitSeq(tag, ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -346,7 +346,8 @@ func (l *Logger) GetPaymentStats(ctx context.Context, tenantID int, provider str
 		SELECT 
 			COUNT(*) as total_requests,
 			COUNT(CASE WHEN response::text LIKE '%%"success":true%%' THEN 1 END) as success_count,
-			COUNT(CASE WHEN response::text LIKE '%%"success":false%%' THEN 1 END) as error_count,
+			COUNT(CASE WHEN response::text LIKE '%%"success":false%%' AND status IS DISTINCT FROM 'blocked' THEN 1 END) as error_count,
+			COUNT(CASE WHEN status = 'blocked' THEN 1 END) as blocked_count,
 			AVG(EXTRACT(EPOCH FROM (response_at - request_at)) * 1000) as avg_processing_ms
 		FROM %s
 		WHERE tenant_id = $1 
@@ -357,6 +358,7 @@ func (l *Logger) GetPaymentStats(ctx context.Context, tenantID int, provider str
 		TotalRequests   int      `json:"total_requests"`
 		SuccessCount    int      `json:"success_count"`
 		ErrorCount      int      `json:"error_count"`
+		BlockedCount    int      `json:"blocked_count"`
 		AvgProcessingMs *float64 `json:"avg_processing_ms"`
 	}
 
@@ -364,6 +366,7 @@ func (l *Logger) GetPaymentStats(ctx context.Context, tenantID int, provider str
 		&stats.TotalRequests,
 		&stats.SuccessCount,
 		&stats.ErrorCount,
+		&stats.BlockedCount,
 		&stats.AvgProcessingMs,
 	)
 	if err != nil {
@@ -374,6 +377,7 @@ func (l *Logger) GetPaymentStats(ctx context.Context, tenantID int, provider str
 		"total_requests": stats.TotalRequests,
 		"success_count":  stats.SuccessCount,
 		"error_count":    stats.ErrorCount,
+		"blocked_count":  stats.BlockedCount,
 		"success_rate":   0.0,
 	}
 
@@ -390,17 +394,39 @@ func (l *Logger) GetPaymentStats(ctx context.Context, tenantID int, provider str
 
 // getProviderTableName returns the PostgreSQL table name for a provider
 func (l *Logger) getProviderTableName(provider string) string {
+	return ProviderTableName(provider)
+}
+
+// ProviderTableName returns the gopay.sql table that holds the request logs of a provider.
+// Every registered provider needs an entry here and a table in gopay.sql.
+func ProviderTableName(provider string) string {
 	// Map provider names to table names
 	providerTables := map[string]string{
-		"iyzico":  "iyzico",
-		"ozanpay": "ozanpay",
-		"paycell": "paycell",
-		"stripe":  "stripe",
-		"papara":  "papara",
-		"nkolay":  "nkolay",
-		"paytr":   "paytr",
-		"payu":    "payu",
-		"shopier": "shopier",
+		"iyzico":      "iyzico",
+		"ozanpay":     "ozanpay",
+		"paycell":     "paycell",
+		"stripe":      "stripe",
+		"papara":      "papara",
+		"nkolay":      "nkolay",
+		"paytr":       "paytr",
+		"payu":        "payu",
+		"shopier":     "shopier",
+		"garanti":     "garanti",
+		"isbank":      "isbank",
+		"akbank":      "akbank",
+		"halkbank":    "halkbank",
+		"kuveytturk":  "kuveytturk",
+		"qnb":         "qnb",
+		"param":       "param",
+		"sipay":       "sipay",
+		"craftgate":   "craftgate",
+		"klarna":      "klarna",
+		"razorpay":    "razorpay",
+		"mercadopago": "mercadopago",
+		"paratika":    "paratika",
+		"tosla":       "tosla",
+		"payten":      "payten",
+		"ziraat":      "ziraat",
 	}
 
 	if tableName, exists := providerTables[strings.ToLower(provider)]; exists {
@@ -475,9 +501,11 @@ func sanitizeMap(data map[string]any) map[string]any {
 	//
 	// Do NOT add "token" here. cardToken is read back out of the log to complete 3D payments
 	// (provider/paycell/paycell.go:275 and :396); masking it would break payment completion.
+	// The encrypted paymentData of an Apple Pay wallet token and the tokenizationData of a
+	// Google Pay one are redacted instead.
 	sensitiveFields := []string{
 		"cardnumber", "card_number", "credit", "pan",
-		"cvv", "cvc",
+		"cvv", "cvc", "paymentdata", "tokenizationdata",
 		"applicationpwd", "password", "passwd", "pwd", "secret", "securecode",
 	}
 
@@ -745,7 +773,7 @@ func (l *Logger) GetPaymentTrendsMonthly(ctx context.Context, tenantID int, prov
 				DATE_TRUNC('day', request_at) as day,
 				COUNT(*) as total_payments,
 				COUNT(CASE WHEN response::text LIKE '%%"success":true%%' THEN 1 END) as successful_payments,
-				COUNT(CASE WHEN response::text LIKE '%%"success":false%%' THEN 1 END) as failed_payments,
+				COUNT(CASE WHEN response::text LIKE '%%"success":false%%' AND status IS DISTINCT FROM 'blocked' THEN 1 END) as failed_payments,
 				SUM(CASE WHEN amount IS NOT NULL THEN amount ELSE 0 END) as volume
 			FROM %s
 			WHERE tenant_id = $1 
@@ -881,7 +909,7 @@ func (l *Logger) GetPaymentTrends(ctx context.Context, tenantID int, provider st
 				DATE_TRUNC('hour', request_at) as hour,
 				COUNT(*) as total_payments,
 				COUNT(CASE WHEN response::text LIKE '%%"success":true%%' THEN 1 END) as successful_payments,
-				COUNT(CASE WHEN response::text LIKE '%%"success":false%%' THEN 1 END) as failed_payments,
+				COUNT(CASE WHEN response::text LIKE '%%"success":false%%' AND status IS DISTINCT FROM 'blocked' THEN 1 END) as failed_payments,
 				SUM(CASE WHEN amount IS NOT NULL THEN amount ELSE 0 END) as volume
 			FROM %s
 			WHERE tenant_id = $1 
@@ -965,19 +993,23 @@ func (l *Logger) GetPaymentTrends(ctx context.Context, tenantID int, provider st
 	}, nil
 }
 
-// GetAllRecentActivity retrieves recent payment activity from all provider tables
-func (l *Logger) GetAllRecentActivity(ctx context.Context, limit int) ([]map[string]any, error) {
+// GetAllRecentActivity retrieves recent payment activity from the tables of the given providers
+func (l *Logger) GetAllRecentActivity(ctx context.Context, providers []string, limit int) ([]map[string]any, error) {
 	// Validate limit parameter
 	if limit <= 0 || limit > 1000 {
 		return nil, fmt.Errorf("invalid limit parameter: must be between 1 and 1000")
 	}
 
-	// Get all provider tables that exist
-	providers := []string{"iyzico", "stripe", "ozanpay", "paycell", "papara", "nkolay", "paytr", "payu"}
 	var allActivities []map[string]any
+	queriedTables := make(map[string]bool, len(providers))
 
 	for _, provider := range providers {
+		// Providers without their own table share the fallback table, which is read once
 		tableName := l.getProviderTableName(provider)
+		if queriedTables[tableName] {
+			continue
+		}
+		queriedTables[tableName] = true
 
 		query := fmt.Sprintf(`
 			SELECT 
@@ -987,6 +1019,7 @@ func (l *Logger) GetAllRecentActivity(ctx context.Context, limit int) ([]map[str
 				amount,
 				currency,
 				CASE 
+					WHEN status = 'blocked' THEN 'blocked'
 					WHEN response::text LIKE '%%"success":true%%' OR status = 'success' THEN 'success'
 					WHEN response::text LIKE '%%"success":false%%' OR status = 'failed' THEN 'failed'
 					ELSE 'processing'
@@ -1080,9 +1113,74 @@ func (l *Logger) GetAllRecentActivity(ctx context.Context, limit int) ([]map[str
 	return allActivities, nil
 }
 
-// SearchPaymentByID searches for a specific payment by ID in a provider table
-func (l *Logger) SearchPaymentByID(ctx context.Context, tenantID int, provider, paymentID string) ([]map[string]any, error) {
-	tableName := l.getProviderTableName(provider)
+// paymentIdentifierFields lists every stored location a payment can be referenced by.
+// Support tickets quote whichever ID the customer or merchant happens to have, so the
+// search matches the GoPay payment ID, the provider transaction ID, the 3D session ID
+// and the merchant order/reference ID across the indexed columns and the logged payloads.
+var paymentIdentifierFields = []string{
+	"payment_id",
+	"transaction_id",
+	"request->>'paymentId'",
+	"request->>'referenceId'",
+	"request->>'conversationId'",
+	"request->>'sessionId'",
+	"response->>'paymentId'",
+	"response->>'transactionId'",
+	"response->>'orderId'",
+	"response->>'sessionId'",
+}
+
+// maxPaymentSearchResults caps how many rows a single payment search returns. Identifier
+// lookups only ever hit a handful of rows; metadata filters can match far more.
+const maxPaymentSearchResults = 100
+
+// PaymentSearchFilter narrows a payment search. At least one of PaymentID, Metadata or
+// SubscriptionID must be set; a row has to satisfy every filter that is set.
+type PaymentSearchFilter struct {
+	// PaymentID matches any of the identifiers in paymentIdentifierFields
+	PaymentID string
+	// Metadata matches payments whose request metadata contains every key/value pair
+	Metadata map[string]string
+	// SubscriptionID matches the charges of a subscription
+	SubscriptionID string
+}
+
+// buildPaymentSearchQuery builds the search for a provider table. The tenant filter is
+// always $1 and every user supplied value is bound as a parameter, so callers can neither
+// widen the search beyond a single tenant nor inject SQL through identifiers or metadata.
+func buildPaymentSearchQuery(tableName string, tenantID int, filter PaymentSearchFilter) (string, []any, error) {
+	args := []any{tenantID}
+	var conditions []string
+
+	if filter.PaymentID != "" {
+		args = append(args, filter.PaymentID)
+		placeholder := fmt.Sprintf("$%d", len(args))
+
+		identifierConditions := make([]string, len(paymentIdentifierFields))
+		for i, field := range paymentIdentifierFields {
+			identifierConditions[i] = field + " = " + placeholder
+		}
+		conditions = append(conditions, "("+strings.Join(identifierConditions, " OR ")+")")
+	}
+
+	if len(filter.Metadata) > 0 {
+		// Containment (@>) is served by the GIN index on request->'metadata'
+		metadataJSON, err := json.Marshal(filter.Metadata)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		args = append(args, string(metadataJSON))
+		conditions = append(conditions, fmt.Sprintf("request->'metadata' @> $%d::jsonb", len(args)))
+	}
+
+	if filter.SubscriptionID != "" {
+		args = append(args, filter.SubscriptionID)
+		conditions = append(conditions, fmt.Sprintf("request->>'subscriptionId' = $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, fmt.Errorf("payment identifier, metadata or subscription filter is required")
+	}
 
 	query := fmt.Sprintf(`
 		SELECT 
@@ -1092,6 +1190,7 @@ func (l *Logger) SearchPaymentByID(ctx context.Context, tenantID int, provider,
 			amount,
 			currency,
 			CASE 
+				WHEN status = 'blocked' THEN 'blocked'
 				WHEN response::text LIKE '%%"success":true%%' OR status = 'success' THEN 'success'
 				WHEN response::text LIKE '%%"success":false%%' OR status = 'failed' THEN 'failed'
 				ELSE 'processing'
@@ -1102,11 +1201,40 @@ func (l *Logger) SearchPaymentByID(ctx context.Context, tenantID int, provider,
 			response
 		FROM %s
 		WHERE tenant_id = $1 
-		AND payment_id = $2
+		AND %s
 		ORDER BY request_at DESC
-	`, tableName)
+		LIMIT %d
+	`, tableName, strings.Join(conditions, " AND "), maxPaymentSearchResults)
 
-	rows, err := l.db.QueryContext(ctx, query, tenantID, paymentID)
+	return query, args, nil
+}
+
+// SearchPaymentByID searches a provider table for a payment by any of its identifiers
+// (GoPay payment ID, provider transaction ID, 3D session ID or merchant order ID).
+// Results are always restricted to the given tenant.
+func (l *Logger) SearchPaymentByID(ctx context.Context, tenantID int, provider, paymentID string) ([]map[string]any, error) {
+	if strings.TrimSpace(paymentID) == "" {
+		return nil, fmt.Errorf("payment identifier is required")
+	}
+	return l.SearchPayments(ctx, tenantID, provider, PaymentSearchFilter{PaymentID: paymentID})
+}
+
+// SearchPayments searches a provider table by payment identifier, merchant metadata and/or
+// subscription. Results are always restricted to the given tenant.
+func (l *Logger) SearchPayments(ctx context.Context, tenantID int, provider string, filter PaymentSearchFilter) ([]map[string]any, error) {
+	if tenantID <= 0 {
+		return nil, fmt.Errorf("invalid tenant ID: %d", tenantID)
+	}
+
+	filter.PaymentID = strings.TrimSpace(filter.PaymentID)
+	filter.SubscriptionID = strings.TrimSpace(filter.SubscriptionID)
+	tableName := l.getProviderTableName(provider)
+	query, args, err := buildPaymentSearchQuery(tableName, tenantID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := l.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query payment: %w", err)
 	}
@@ -1176,14 +1304,13 @@ func (l *Logger) SearchPaymentByID(ctx context.Context, tenantID int, provider,
 	return payments, nil
 }
 
-// GetAllProvidersStats retrieves stats for all providers for a tenant
-func (l *Logger) GetAllProvidersStats(ctx context.Context, tenantID int, hours int) (map[string]map[string]any, error) {
+// GetAllProvidersStats retrieves stats of the given providers for a tenant
+func (l *Logger) GetAllProvidersStats(ctx context.Context, tenantID int, providers []string, hours int) (map[string]map[string]any, error) {
 	// Validate hours parameter (this will also be validated in GetPaymentStats but adding here for consistency)
 	if hours <= 0 || hours > 8760 { // Max 1 year (365*24 hours)
 		return nil, fmt.Errorf("invalid hours parameter: must be between 1 and 8760")
 	}
 
-	providers := []string{"iyzico", "stripe", "ozanpay", "paycell", "papara", "nkolay", "paytr", "payu"}
 	allStats := make(map[string]map[string]any)
 
 	for _, provider := range providers {
@@ -71,6 +71,53 @@ func TestSanitizeForLogRedactsCVVAndCredentials(t *testing.T) {
 	}
 }
 
+func TestSanitizeForLogRedactsWalletToken(t *testing.T) {
+	got := SanitizeForLog(map[string]any{
+		"walletPayment": map[string]any{
+			"type": "apple_pay",
+			"token": map[string]any{
+				"paymentData": map[string]any{
+					"version": "EC_v1",
+					"data":    "bNEYHlCp5bmAhy2yGHpVgYyqLIgrNZrDYg...",
+				},
+				"paymentMethod": map[string]any{"displayName": "Visa 0121", "network": "Visa"},
+			},
+		},
+	})
+
+	token := got["walletPayment"].(map[string]any)["token"].(map[string]any)
+	if token["paymentData"] != "***REDACTED***" {
+		t.Errorf("walletPayment.token.paymentData = %v, want ***REDACTED***", token["paymentData"])
+	}
+	if method := token["paymentMethod"].(map[string]any); method["network"] != "Visa" {
+		t.Errorf("paymentMethod was altered: %v", method)
+	}
+}
+
+func TestSanitizeForLogRedactsGooglePayToken(t *testing.T) {
+	got := SanitizeForLog(map[string]any{
+		"walletPayment": map[string]any{
+			"type": "google_pay",
+			"googlePay": map[string]any{
+				"environment": "TEST",
+				"paymentMethodData": map[string]any{
+					"type":             "CARD",
+					"info":             map[string]any{"cardNetwork": "VISA", "cardDetails": "1111"},
+					"tokenizationData": map[string]any{"type": "DIRECT", "token": `{"protocolVersion":"ECv2","signedMessage":"..."}`},
+				},
+			},
+		},
+	})
+
+	method := got["walletPayment"].(map[string]any)["googlePay"].(map[string]any)["paymentMethodData"].(map[string]any)
+	if method["tokenizationData"] != "***REDACTED***" {
+		t.Errorf("googlePay.paymentMethodData.tokenizationData = %v, want ***REDACTED***", method["tokenizationData"])
+	}
+	if info := method["info"].(map[string]any); info["cardNetwork"] != "VISA" {
+		t.Errorf("info was altered: %v", info)
+	}
+}
+
 // TestSanitizeForLogPreservesReplayedFields guards the fields that GetPaymentStatus and
 // Complete3DPayment read back out of the log. Masking any of these breaks live payments,
 // so this test is the regression fence for the sanitize patterns.
@@ -0,0 +1,338 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPaymentSearchQuery_MatchesEveryIdentifierType(t *testing.T) {
+	tests := []struct {
+		name       string
+		identifier string
+		fields     []string
+	}{
+		{name: "gopay payment id", identifier: "pay_123", fields: []string{"payment_id = $2", "request->>'paymentId' = $2", "response->>'paymentId' = $2"}},
+		{name: "provider transaction id", identifier: "66620260718154139842", fields: []string{"transaction_id = $2", "response->>'transactionId' = $2"}},
+		{name: "3d session id", identifier: "3ds_session_abc", fields: []string{"request->>'sessionId' = $2", "response->>'sessionId' = $2"}},
+		{name: "merchant order id", identifier: "ORDER-2024-001", fields: []string{"request->>'referenceId' = $2", "request->>'conversationId' = $2", "response->>'orderId' = $2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := buildPaymentSearchQuery("paycell", 7, PaymentSearchFilter{PaymentID: tt.identifier})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, field := range tt.fields {
+				if !strings.Contains(query, field) {
+					t.Errorf("search for %s does not match %q", tt.name, field)
+				}
+			}
+			if len(args) != 2 || args[0] != 7 || args[1] != tt.identifier {
+				t.Errorf("expected args [7 %q], got %v", tt.identifier, args)
+			}
+		})
+	}
+}
+
+func TestBuildPaymentSearchQuery_EnforcesTenantIsolation(t *testing.T) {
+	query, _, err := buildPaymentSearchQuery("iyzico", 3, PaymentSearchFilter{
+		PaymentID: "pay_123",
+		Metadata:  map[string]string{"campaign": "summer2024"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(query, "FROM iyzico") {
+		t.Fatalf("query does not target the provider table:\n%s", query)
+	}
+
+	// The identifier alternatives must be grouped so an OR can never escape the tenant filter.
+	where := query[strings.Index(query, "WHERE"):]
+	if !strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(where, "WHERE")), "tenant_id = $1") {
+		t.Fatalf("tenant filter must be the first condition:\n%s", where)
+	}
+	if !strings.Contains(where, "AND (payment_id = $2 OR ") {
+		t.Fatalf("identifier conditions must be parenthesised after the tenant filter:\n%s", where)
+	}
+	if strings.Count(where, "$1") != 1 {
+		t.Fatalf("tenant placeholder should appear exactly once:\n%s", where)
+	}
+}
+
+func TestBuildPaymentSearchQuery_MetadataFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   PaymentSearchFilter
+		clause   string
+		metadata map[string]string
+	}{
+		{
+			name:     "single key",
+			filter:   PaymentSearchFilter{Metadata: map[string]string{"campaign": "summer2024"}},
+			clause:   "request->'metadata' @> $2::jsonb",
+			metadata: map[string]string{"campaign": "summer2024"},
+		},
+		{
+			name:     "multiple keys must all match",
+			filter:   PaymentSearchFilter{Metadata: map[string]string{"campaign": "summer2024", "channel": "mobile"}},
+			clause:   "request->'metadata' @> $2::jsonb",
+			metadata: map[string]string{"campaign": "summer2024", "channel": "mobile"},
+		},
+		{
+			name:     "combined with identifier",
+			filter:   PaymentSearchFilter{PaymentID: "pay_123", Metadata: map[string]string{"campaign": "summer2024"}},
+			clause:   "request->'metadata' @> $3::jsonb",
+			metadata: map[string]string{"campaign": "summer2024"},
+		},
+		{
+			name:     "injection attempt stays in parameters",
+			filter:   PaymentSearchFilter{Metadata: map[string]string{"x' OR '1'='1": "'; DROP TABLE paycell; --"}},
+			clause:   "request->'metadata' @> $2::jsonb",
+			metadata: map[string]string{"x' OR '1'='1": "'; DROP TABLE paycell; --"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := buildPaymentSearchQuery("paycell", 1, tt.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(query, tt.clause) {
+				t.Fatalf("expected %q in query:\n%s", tt.clause, query)
+			}
+			if strings.Contains(query, "DROP TABLE") || strings.Contains(query, "summer2024") {
+				t.Fatalf("metadata values must never be interpolated into the query:\n%s", query)
+			}
+
+			var bound map[string]string
+			if err := json.Unmarshal([]byte(args[len(args)-1].(string)), &bound); err != nil {
+				t.Fatalf("metadata argument is not valid JSON: %v", err)
+			}
+			if len(bound) != len(tt.metadata) {
+				t.Fatalf("expected metadata %v, got %v", tt.metadata, bound)
+			}
+			for k, v := range tt.metadata {
+				if bound[k] != v {
+					t.Errorf("expected %s=%s in bound metadata, got %v", k, v, bound)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildPaymentSearchQuery_SubscriptionFilter(t *testing.T) {
+	query, args, err := buildPaymentSearchQuery("iyzico", 4, PaymentSearchFilter{SubscriptionID: "sub_123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(query, "AND request->>'subscriptionId' = $2") {
+		t.Fatalf("expected the subscription condition after the tenant filter:\n%s", query)
+	}
+	if len(args) != 2 || args[0] != 4 || args[1] != "sub_123" {
+		t.Errorf("expected args [4 sub_123], got %v", args)
+	}
+
+	// Narrowed to one charge of the subscription
+	query, args, err = buildPaymentSearchQuery("iyzico", 4, PaymentSearchFilter{PaymentID: "pay_1", SubscriptionID: "sub_123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(query, "request->>'subscriptionId' = $3") || len(args) != 3 {
+		t.Errorf("expected both filters, got args %v and query:\n%s", args, query)
+	}
+}
+
+func TestBuildPaymentSearchQuery_RequiresFilter(t *testing.T) {
+	if _, _, err := buildPaymentSearchQuery("paycell", 1, PaymentSearchFilter{}); err == nil {
+		t.Error("expected error when neither identifier nor metadata is set")
+	}
+}
+
+func TestSearchPaymentByID_RejectsInvalidInput(t *testing.T) {
+	l := &Logger{}
+
+	if _, err := l.SearchPaymentByID(context.Background(), 0, "paycell", "pay_123"); err == nil {
+		t.Error("expected error for missing tenant")
+	}
+	if _, err := l.SearchPaymentByID(context.Background(), 1, "paycell", "  "); err == nil {
+		t.Error("expected error for empty identifier")
+	}
+	if _, err := l.SearchPayments(context.Background(), 0, "paycell", PaymentSearchFilter{Metadata: map[string]string{"campaign": "summer2024"}}); err == nil {
+		t.Error("expected error for missing tenant on metadata search")
+	}
+}
+
+// searchLogRow is a row of a provider log table served by paymentLogTable
+type searchLogRow struct {
+	tenantID      int
+	paymentID     string
+	transactionID string
+	request       string
+	response      string
+}
+
+// paymentLogTable is a database/sql driver serving payment searches from rows in memory.
+// It evaluates the identifier conditions the query actually contains, so a search only
+// finds a payment by an identifier the SQL really matches on.
+type paymentLogTable struct {
+	table string
+	rows  []searchLogRow
+}
+
+var searchConditionPattern = regexp.MustCompile(`(payment_id|transaction_id|(request|response)->>'(\w+)') = \$2`)
+
+func (p *paymentLogTable) Connect(context.Context) (driver.Conn, error) { return p, nil }
+func (p *paymentLogTable) Driver() driver.Driver                        { return p }
+func (p *paymentLogTable) Open(string) (driver.Conn, error)             { return p, nil }
+func (p *paymentLogTable) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (p *paymentLogTable) Close() error { return nil }
+func (p *paymentLogTable) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (p *paymentLogTable) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "FROM "+p.table) {
+		return nil, fmt.Errorf("unexpected table in query:\n%s", query)
+	}
+	if !strings.Contains(query, "WHERE tenant_id = $1") || len(args) != 2 {
+		return nil, fmt.Errorf("expected a tenant and identifier search, got %v:\n%s", args, query)
+	}
+	conditions := searchConditionPattern.FindAllStringSubmatch(query, -1)
+	identifier := args[1].Value.(string)
+
+	result := &searchLogRows{}
+	for _, row := range p.rows {
+		if int64(row.tenantID) != args[0].Value.(int64) {
+			continue
+		}
+		for _, condition := range conditions {
+			if row.field(condition) == identifier {
+				result.rows = append(result.rows, row)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// field returns the value a search condition compares against the identifier
+func (r searchLogRow) field(condition []string) string {
+	switch condition[1] {
+	case "payment_id":
+		return r.paymentID
+	case "transaction_id":
+		return r.transactionID
+	}
+	payload := r.request
+	if condition[2] == "response" {
+		payload = r.response
+	}
+	var values map[string]any
+	_ = json.Unmarshal([]byte(payload), &values)
+	value, _ := values[condition[3]].(string)
+	return value
+}
+
+type searchLogRows struct {
+	rows []searchLogRow
+	next int
+}
+
+func (r *searchLogRows) Columns() []string {
+	return []string{"request_at", "tenant_id", "payment_id", "amount", "currency", "activity_status", "method", "endpoint", "request", "response"}
+}
+
+func (r *searchLogRows) Close() error { return nil }
+
+func (r *searchLogRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.next]
+	r.next++
+	copy(dest, []driver.Value{time.Now(), int64(row.tenantID), row.paymentID, 100.5, "TRY", "success", "POST", "/v1/payments/iyzico", row.request, row.response})
+	return nil
+}
+
+func TestSearchPaymentByID_FindsPaymentByEveryIdentifier(t *testing.T) {
+	table := &paymentLogTable{table: "iyzico", rows: []searchLogRow{
+		{
+			tenantID:      7,
+			paymentID:     "pay_123",
+			transactionID: "66620260718154139842",
+			request:       `{"amount":100.5,"conversationId":"conv-42","referenceId":"ORDER-2024-001"}`,
+			response:      `{"success":true,"paymentId":"pay_123","transactionId":"66620260718154139842"}`,
+		},
+		// Another tenant's payment with the same identifiers must never be returned
+		{
+			tenantID:      8,
+			paymentID:     "pay_123",
+			transactionID: "66620260718154139842",
+			request:       `{"conversationId":"conv-42","referenceId":"ORDER-2024-001"}`,
+		},
+		// A payment whose provider transaction ID was only logged in the response
+		{
+			tenantID: 7,
+			request:  `{"amount":50}`,
+			response: `{"success":false,"transactionId":"TX-RESPONSE-ONLY"}`,
+		},
+	}}
+	db := sql.OpenDB(table)
+	defer db.Close()
+	l := &Logger{db: db}
+
+	tests := []struct {
+		name       string
+		identifier string
+		found      int
+	}{
+		{name: "gopay payment id", identifier: "pay_123", found: 1},
+		{name: "conversation id", identifier: "conv-42", found: 1},
+		{name: "reference id", identifier: "ORDER-2024-001", found: 1},
+		{name: "provider transaction id", identifier: "66620260718154139842", found: 1},
+		{name: "provider transaction id in response", identifier: "TX-RESPONSE-ONLY", found: 1},
+		{name: "padded identifier", identifier: "  pay_123 ", found: 1},
+		{name: "no match", identifier: "pay_unknown", found: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, err := l.SearchPaymentByID(context.Background(), 7, "iyzico", tt.identifier)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(payments) != tt.found {
+				t.Fatalf("expected %d payments, got %d: %v", tt.found, len(payments), payments)
+			}
+			for _, payment := range payments {
+				if payment["tenant_id"] != 7 {
+					t.Errorf("expected only tenant 7's payments, got %v", payment)
+				}
+			}
+		})
+	}
+
+	payments, err := l.SearchPaymentByID(context.Background(), 7, "iyzico", "pay_123")
+	if err != nil || len(payments) != 1 {
+		t.Fatalf("expected one payment, got %v (%v)", payments, err)
+	}
+	if payments[0]["id"] != "pay_123" || payments[0]["status"] != "success" || payments[0]["amount"] != "₺100.50" {
+		t.Errorf("unexpected payment fields: %v", payments[0])
+	}
+}
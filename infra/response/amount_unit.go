@@ -0,0 +1,53 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AmountUnit is the unit amounts are returned in
+type AmountUnit string
+
+const (
+	// AmountUnitMajor returns amounts in major units, e.g. 100.50 (default)
+	AmountUnitMajor AmountUnit = "major"
+	// AmountUnitMinor returns amounts in minor units, e.g. 10050
+	AmountUnitMinor AmountUnit = "minor"
+)
+
+// AmountUnitHeader is the request header that selects the amount unit. It is echoed on the
+// response with the unit that was applied.
+const AmountUnitHeader = "X-Amount-Unit"
+
+// MinorUnitFormatter is implemented by response data that can express its amounts in minor
+// units
+type MinorUnitFormatter interface {
+	InMinorUnits() any
+}
+
+// RequestedAmountUnit returns the unit the client asked for with the amountUnit query
+// parameter or the X-Amount-Unit header. Anything else than "minor" means major units.
+func RequestedAmountUnit(r *http.Request) AmountUnit {
+	unit := r.URL.Query().Get("amountUnit")
+	if unit == "" {
+		unit = r.Header.Get(AmountUnitHeader)
+	}
+	if AmountUnit(strings.ToLower(strings.TrimSpace(unit))) == AmountUnitMinor {
+		return AmountUnitMinor
+	}
+	return AmountUnitMajor
+}
+
+// ReturnInUnit writes a response like Return, with the amounts of data in the given unit
+func ReturnInUnit(w http.ResponseWriter, statusCode int, success bool, message string, data any, unit AmountUnit) {
+	if unit == AmountUnitMinor {
+		if formatter, ok := data.(MinorUnitFormatter); ok {
+			data = formatter.InMinorUnits()
+		} else {
+			unit = AmountUnitMajor
+		}
+	}
+
+	w.Header().Set(AmountUnitHeader, string(unit))
+	Return(w, statusCode, success, message, data)
+}
@@ -0,0 +1,80 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPayment struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func (p testPayment) InMinorUnits() any {
+	return testPayment{Amount: p.Amount * 100, Currency: p.Currency}
+}
+
+func TestRequestedAmountUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		header   string
+		expected AmountUnit
+	}{
+		{"default", "/payments/iyzico", "", AmountUnitMajor},
+		{"query", "/payments/iyzico?amountUnit=minor", "", AmountUnitMinor},
+		{"header", "/payments/iyzico", "Minor", AmountUnitMinor},
+		{"query wins over header", "/payments/iyzico?amountUnit=major", "minor", AmountUnitMajor},
+		{"unknown unit", "/payments/iyzico?amountUnit=cents", "", AmountUnitMajor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set(AmountUnitHeader, tt.header)
+			}
+			if got := RequestedAmountUnit(r); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReturnInUnit(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           any
+		unit           AmountUnit
+		expectedAmount float64
+		expectedUnit   string
+	}{
+		{"major units", testPayment{Amount: 100.5, Currency: "TRY"}, AmountUnitMajor, 100.5, "major"},
+		{"minor units", testPayment{Amount: 100.5, Currency: "TRY"}, AmountUnitMinor, 10050, "minor"},
+		{"data without minor units", map[string]any{"amount": 100.5}, AmountUnitMinor, 100.5, "major"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ReturnInUnit(w, http.StatusOK, true, "Payment processed", tt.data, tt.unit)
+
+			var body struct {
+				Data struct {
+					Amount float64 `json:"amount"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Data.Amount != tt.expectedAmount {
+				t.Errorf("Expected amount %v, got %v", tt.expectedAmount, body.Data.Amount)
+			}
+			if got := w.Header().Get(AmountUnitHeader); got != tt.expectedUnit {
+				t.Errorf("Expected %s header %s, got %s", AmountUnitHeader, tt.expectedUnit, got)
+			}
+		})
+	}
+}
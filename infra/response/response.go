@@ -49,3 +49,19 @@ func Error(w http.ResponseWriter, statusCode int, message string, err error) {
 
 	_ = WriteJSON(w, statusCode, resp)
 }
+
+// ErrorWithData writes an error response with data that tells the client how to fix the request
+func ErrorWithData(w http.ResponseWriter, statusCode int, message string, err error, data any) {
+	resp := Response{
+		Code:    statusCode,
+		Success: false,
+		Message: message,
+		Data:    data,
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	_ = WriteJSON(w, statusCode, resp)
+}
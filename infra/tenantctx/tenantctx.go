@@ -0,0 +1,19 @@
+// Package tenantctx carries the tenant a payment operation is made for in its context. It has
+// no dependencies, so the payment library can be embedded without the HTTP server's.
+package tenantctx
+
+import "context"
+
+// Key is the type of the tenant keys of a context
+type Key string
+
+// IDKey holds the tenant ID as a decimal string
+const IDKey Key = "tenant_id"
+
+// ID returns the tenant ID of a context, or "" when it has none
+func ID(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(IDKey).(string); ok {
+		return tenantID
+	}
+	return ""
+}
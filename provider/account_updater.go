@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// PaymentResponse.ErrorCode values of a saved card charge declined because the card on file
+// is out of date. PaymentResponse.CardUpdateRequired is set along with them.
+const (
+	ErrorCodeCardExpired  = "card_expired"
+	ErrorCodeCardNotFound = "card_not_found"
+)
+
+// cardUpdateDeclineCodes translates the decline codes providers return for an expired or
+// unknown card. CARD_UPDATE_DECLINE_CODES_<PROVIDER> adds to them.
+var cardUpdateDeclineCodes = map[string]map[string]string{
+	"stripe": {
+		"expired_card":     ErrorCodeCardExpired,
+		"resource_missing": ErrorCodeCardNotFound,
+	},
+	"iyzico": {
+		"10054": ErrorCodeCardExpired,
+	},
+}
+
+// cardUpdateDeclines lists the wordings of the same declines, for providers that only
+// return a message or codes not in the table
+var cardUpdateDeclines = []struct {
+	code    string
+	phrases []string
+}{
+	{ErrorCodeCardExpired, []string{
+		"expired card",
+		"card expired",
+		"card has expired",
+		"süresi dolmuş",
+		"suresi dolmus",
+		"son kullanma tarihi geçmiş",
+	}},
+	{ErrorCodeCardNotFound, []string{
+		"card not found",
+		"no such payment_method",
+		"kart bulunamadı",
+		"kart bulunamadi",
+	}},
+}
+
+// CardUpdateDecline returns ErrorCodeCardExpired or ErrorCodeCardNotFound when a declined
+// saved card charge calls for the merchant's account updater or for collecting the card
+// again, or "" for any other decline. The provider's decline code is looked up first, in
+// CARD_UPDATE_DECLINE_CODES_<PROVIDER> (e.g. "54:card_expired,56:card_not_found") and then
+// in the built-in table, before the message is matched.
+func CardUpdateDecline(providerName, errorCode, message string) string {
+	if errorCode = strings.TrimSpace(errorCode); errorCode != "" {
+		if code, ok := configuredCardUpdateCodes(providerName)[errorCode]; ok {
+			return code
+		}
+		if code, ok := cardUpdateDeclineCodes[strings.ToLower(providerName)][errorCode]; ok {
+			return code
+		}
+	}
+
+	message = strings.ToLower(message)
+	for _, decline := range cardUpdateDeclines {
+		for _, phrase := range decline.phrases {
+			if strings.Contains(message, phrase) {
+				return decline.code
+			}
+		}
+	}
+	return ""
+}
+
+// configuredCardUpdateCodes reads CARD_UPDATE_DECLINE_CODES_<PROVIDER>. Pairs that do not
+// translate to one of the card update codes are skipped with a warning.
+func configuredCardUpdateCodes(providerName string) map[string]string {
+	key := "CARD_UPDATE_DECLINE_CODES_" + strings.ToUpper(providerName)
+	value := strings.TrimSpace(config.GetEnv(key, ""))
+	if value == "" {
+		return nil
+	}
+
+	codes := make(map[string]string)
+	for pair := range strings.SplitSeq(value, ",") {
+		declineCode, code, ok := strings.Cut(pair, ":")
+		declineCode, code = strings.TrimSpace(declineCode), strings.TrimSpace(code)
+		if !ok || declineCode == "" || (code != ErrorCodeCardExpired && code != ErrorCodeCardNotFound) {
+			logger.Warn("Ignoring invalid card update decline code", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"env":   key,
+					"value": pair,
+				},
+			})
+			continue
+		}
+		codes[declineCode] = code
+	}
+	return codes
+}
@@ -0,0 +1,48 @@
+package provider
+
+import "testing"
+
+func TestCardUpdateDecline(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		errorCode string
+		message   string
+		want      string
+	}{
+		{"stripe expired card", "stripe", "expired_card", "Your card has expired.", ErrorCodeCardExpired},
+		{"stripe detached payment method", "stripe", "resource_missing", "No such PaymentMethod: 'pm_123'", ErrorCodeCardNotFound},
+		{"iyzico expired card", "iyzico", "10054", "Son kullanma tarihi hatalı", ErrorCodeCardExpired},
+		{"message only", "paycell", "", "Kart bulunamadı", ErrorCodeCardNotFound},
+		{"turkish expiry message", "paycell", "1205", "Kartın süresi dolmuş", ErrorCodeCardExpired},
+		{"insufficient funds", "stripe", "card_declined", "Your card has insufficient funds.", ""},
+		{"code of another provider", "paycell", "10054", "Do not honour", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CardUpdateDecline(tt.provider, tt.errorCode, tt.message); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCardUpdateDecline_ConfiguredCodes(t *testing.T) {
+	t.Setenv("CARD_UPDATE_DECLINE_CODES_PAYCELL", "54:card_expired, 56:card_not_found,51:insufficient_funds,bad")
+
+	if got := CardUpdateDecline("paycell", "54", "Declined"); got != ErrorCodeCardExpired {
+		t.Errorf("expected the configured expired code, got %q", got)
+	}
+	if got := CardUpdateDecline("paycell", "56", "Declined"); got != ErrorCodeCardNotFound {
+		t.Errorf("expected the configured not found code, got %q", got)
+	}
+	if got := CardUpdateDecline("paycell", "51", "Declined"); got != "" {
+		t.Errorf("expected a translation to another code to be ignored, got %q", got)
+	}
+
+	// Configured codes take precedence over the built-in table
+	t.Setenv("CARD_UPDATE_DECLINE_CODES_STRIPE", "expired_card:card_not_found")
+	if got := CardUpdateDecline("stripe", "expired_card", ""); got != ErrorCodeCardNotFound {
+		t.Errorf("expected the configured translation to win, got %q", got)
+	}
+}
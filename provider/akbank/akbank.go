@@ -5,11 +5,16 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,17 +27,30 @@ const (
 	apiSandboxPaymentAPIURL    = "https://apipre.akbank.com/api/v1/payment/virtualpos/transaction/process"
 	apiProductionPaymentAPIURL = "https://api.akbank.com/api/v1/payment/virtualpos/transaction/process"
 
+	// 3D Secure payment gateway
+	threeDSandboxURL    = "https://virtualpospaymentgatewaypre.akbank.com/securepay"
+	threeDProductionURL = "https://virtualpospaymentgateway.akbank.com/securepay"
+
+	// 3D_PAY: Akbank completes the sale itself after the 3D challenge
+	paymentModel3DPay = "3D_PAY"
+
 	// Transaction Codes
 	txnCodeSale   = "1000" // Direct sale
 	txnCode3D     = "3000" // 3D Secure sale
 	txnCodeCancel = "2000" // Cancel/void
 	txnCodeRefund = "2100" // Refund
 
-	// Currency Codes
-	currencyCodeTRY = 949
+	// Default currency, sent as its ISO 4217 numeric code
+	defaultCurrency = "TRY"
 
 	// Default version
 	apiVersion = "1.00"
+
+	// Response code of an approved transaction or 3D callback
+	approvedCode = "VPS-0000"
+
+	// Card program Akbank installments run on, keyed in installment inquiries
+	installmentProgram = "AXESS"
 )
 
 // AkbankProvider implements the provider.PaymentProvider interface for Akbank
@@ -40,7 +58,9 @@ type AkbankProvider struct {
 	merchantSafeId string
 	terminalSafeId string
 	secretKey      string
+	installments   []int
 	baseURL        string
+	threeDPostURL  string
 	gopayBaseURL   string
 	isProduction   bool
 	httpClient     *provider.ProviderHTTPClient
@@ -82,6 +102,14 @@ func (p *AkbankProvider) GetRequiredConfig(environment string) []provider.Config
 			MinLength:   50,
 			MaxLength:   200,
 		},
+		{
+			Key:         "installments",
+			Required:    false,
+			Type:        "string",
+			Description: "Installment counts enabled on the terminal, comma separated (checked by Akbank when empty)",
+			Example:     "2,3,6,9,12",
+			Pattern:     `^[0-9]{1,2}(,[0-9]{1,2})*$`,
+		},
 		{
 			Key:         "environment",
 			Required:    true,
@@ -96,7 +124,26 @@ func (p *AkbankProvider) GetRequiredConfig(environment string) []provider.Config
 // ValidateConfig validates the provided configuration against Akbank requirements
 func (p *AkbankProvider) ValidateConfig(config map[string]string) error {
 	requiredFields := p.GetRequiredConfig(config["environment"])
-	return provider.ValidateConfigFields("akbank", config, requiredFields)
+	if err := provider.ValidateConfigFields("akbank", config, requiredFields); err != nil {
+		return err
+	}
+	_, err := parseInstallments(config["installments"])
+	return err
+}
+
+// SupportedCurrencies returns the currencies accepted by Akbank.
+// Akbank merchants are set up for TRY only (sent as ISO 4217 numeric 949).
+func (p *AkbankProvider) SupportedCurrencies() []string {
+	return []string{"TRY"}
+}
+
+// HealthCheckEndpoint returns the Akbank transaction API. Akbank exposes a single endpoint
+// and the transaction type is part of the request body, so a bodiless probe changes nothing.
+func (p *AkbankProvider) HealthCheckEndpoint() string {
+	if p.baseURL == "" {
+		return apiSandboxPaymentAPIURL
+	}
+	return p.baseURL
 }
 
 // Initialize sets up the Akbank payment provider with authentication credentials
@@ -109,23 +156,47 @@ func (p *AkbankProvider) Initialize(conf map[string]string) error {
 		return errors.New("akbank: merchantSafeId, terminalSafeId and secretKey are required")
 	}
 
+	installments, err := parseInstallments(conf["installments"])
+	if err != nil {
+		return err
+	}
+	p.installments = installments
+
 	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
 
 	p.isProduction = conf["environment"] == "production"
 	if p.isProduction {
 		p.baseURL = apiProductionPaymentAPIURL
+		p.threeDPostURL = threeDProductionURL
 	} else {
 		p.baseURL = apiSandboxPaymentAPIURL
+		p.threeDPostURL = threeDSandboxURL
 	}
 
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("akbank"))
 
 	return nil
 }
 
-// GetInstallmentCount returns the installment count for a payment
+// GetInstallmentCount returns the installment counts enabled on the terminal. The transaction
+// API has no installment inquiry and prices installments by the merchant agreement, so the
+// counts come from the installments config and carry no commission.
 func (p *AkbankProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
-	return provider.InstallmentInquireResponse{}, nil
+	options := []provider.InstallmentInfo{{Installment: 1}}
+	for _, count := range p.installments {
+		options = append(options, provider.InstallmentInfo{Installment: count})
+	}
+
+	message := "Installment options retrieved successfully"
+	if len(p.installments) == 0 {
+		message = "No installment counts are configured for this terminal"
+	}
+
+	return provider.InstallmentInquireResponse{
+		Amount:       request.Amount,
+		Message:      message,
+		Installments: map[string][]provider.InstallmentInfo{installmentProgram: options},
+	}, nil
 }
 
 // GetCommission returns the commission for a payment
@@ -137,27 +208,154 @@ func (p *AkbankProvider) GetCommission(ctx context.Context, request provider.Com
 func (p *AkbankProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("akbank: invalid payment request: %w", err)
+		return nil, fmt.Errorf("akbank: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
-	return p.processPayment(ctx, request, false)
+	return p.processPayment(ctx, request)
 }
 
-// Create3DPayment starts a 3D secure payment process
+// Create3DPayment starts a 3D_PAY payment: the customer posts the card to the Akbank payment
+// gateway, which completes the sale after the challenge and posts the result to GoPay
 func (p *AkbankProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("akbank: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("akbank: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	orderId := p.generateOrderId()
+
+	// Create callback state
+	state := provider.CallbackState{
+		TenantID:         int(request.TenantID),
+		PaymentID:        orderId,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "akbank",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "akbank", state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
 	}
 
-	return p.processPayment(ctx, request, true)
+	formParams := p.build3DFormParams(request, orderId, gopayCallbackURL, currencyCode)
+	formParams["hash"] = p.calculate3DFormHash(formParams)
+
+	// Refunds and cancels read the order ID from the log
+	if p.logID > 0 {
+		_ = provider.AddProviderRequestToClientRequest("akbank", "providerRequest", map[string]any{
+			"order": map[string]any{"orderId": orderId},
+		}, p.logID)
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:    true,
+		Status:     provider.StatusPending,
+		PaymentID:  orderId,
+		OrderID:    orderId,
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		HTML:       p.generate3DSecureHTML(formParams),
+		Message:    "3D Secure authentication required",
+		SystemTime: &now,
+	}, nil
 }
 
-// Complete3DPayment completes a 3D secure payment after user authentication
+// Complete3DPayment reads the result of a 3D_PAY payment Akbank posted back
 func (p *AkbankProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
-	// For Akbank, 3D completion is handled differently
-	// This would need implementation based on Akbank's 3D secure flow
-	return nil, errors.New("akbank: 3D completion not yet implemented")
+	if len(data) == 0 {
+		return nil, errors.New("akbank: no callback data received")
+	}
+
+	if err := p.verifyResponseHash(data); err != nil {
+		return nil, err
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("akbank", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	response := callbackResponse(callbackState, data)
+
+	if !response.Success && provider.Is3DSessionExpiredMessage(data["responseMessage"], data["mdErrorMessage"]) {
+		response.ErrorCode = provider.ErrorCode3DSessionExpired
+		return response, fmt.Errorf("akbank: %w", provider.Err3DSessionExpired)
+	}
+
+	// The customer left the bank page; the card was never declined
+	if !response.Success && provider.Is3DSChallengeCancelledMessage(data["responseMessage"], data["mdErrorMessage"]) {
+		provider.MarkThreeDSCancelled(response)
+	}
+
+	return response, nil
+}
+
+// callbackResponse maps a verified 3D_PAY callback. The sale went through when the
+// cardholder was authenticated (mdStatus 1) or the attempt was accepted (2, 3, 4) and Akbank
+// approved the provision.
+func callbackResponse(callbackState *provider.CallbackState, data map[string]string) *provider.PaymentResponse {
+	mdStatus := data["mdStatus"]
+	responseCode := data["responseCode"]
+	authenticated := mdStatus == "1" || mdStatus == "2" || mdStatus == "3" || mdStatus == "4"
+	success := authenticated && responseCode == approvedCode
+
+	paymentID := data["orderId"]
+	if paymentID == "" {
+		paymentID = callbackState.PaymentID
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          success,
+		PaymentID:        paymentID,
+		OrderID:          paymentID,
+		TransactionID:    data["rrn"],
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	if success {
+		response.Status = provider.StatusSuccessful
+		response.Message = "3D payment completed successfully"
+		return response
+	}
+
+	response.Status = provider.StatusFailed
+	switch {
+	case responseCode != "":
+		response.ErrorCode = responseCode
+	case mdStatus != "":
+		response.ErrorCode = "mdStatus_" + mdStatus
+	}
+	switch {
+	case data["responseMessage"] != "":
+		response.Message = data["responseMessage"]
+	case data["mdErrorMessage"] != "":
+		response.Message = data["mdErrorMessage"]
+	default:
+		response.Message = "3D payment failed"
+	}
+	return response
 }
 
 // GetPaymentStatus retrieves the current status of a payment
@@ -204,6 +402,11 @@ func (p *AkbankProvider) RefundPayment(ctx context.Context, request provider.Ref
 		return nil, fmt.Errorf("failed to get order ID: %s %w", request.PaymentID, err)
 	}
 
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare refund request
 	akbankReq := p.buildBaseRequest(txnCodeRefund)
 	akbankReq["order"] = map[string]any{
@@ -211,7 +414,7 @@ func (p *AkbankProvider) RefundPayment(ctx context.Context, request provider.Ref
 	}
 	akbankReq["transaction"] = map[string]any{
 		"amount":       int(request.RefundAmount * 100), // Convert to kuruş
-		"currencyCode": currencyCodeTRY,
+		"currencyCode": currencyCode,
 	}
 
 	resp, err := p.sendRequest(ctx, akbankReq)
@@ -220,7 +423,8 @@ func (p *AkbankProvider) RefundPayment(ctx context.Context, request provider.Ref
 	}
 
 	now := time.Now()
-	success := resp["respCode"] == "0000" || resp["respCode"] == "00"
+	respCode, respText := responseResult(resp)
+	success := responseApproved(respCode)
 
 	refundResp := &provider.RefundResponse{
 		Success:      success,
@@ -238,16 +442,18 @@ func (p *AkbankProvider) RefundPayment(ctx context.Context, request provider.Ref
 		}
 	} else {
 		refundResp.Status = "failed"
-		refundResp.ErrorCode = fmt.Sprintf("%v", resp["respCode"])
-		refundResp.Message = fmt.Sprintf("%v", resp["respText"])
+		refundResp.ErrorCode = respCode
+		refundResp.Message = respText
 	}
 
 	return refundResp, nil
 }
 
-// ValidateWebhook validates an incoming webhook notification
+// ValidateWebhook validates a payment result Akbank posted, which is signed like a 3D callback
 func (p *AkbankProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
-	// Akbank webhook validation would go here
+	if err := p.verifyResponseHash(data); err != nil {
+		return false, nil, err
+	}
 	return true, data, nil
 }
 
@@ -285,19 +491,22 @@ func (p *AkbankProvider) validatePaymentRequest(request provider.PaymentRequest,
 		return errors.New("callback URL is required for 3D secure payments")
 	}
 
+	if request.InstallmentCount > 1 && len(p.installments) > 0 && !slices.Contains(p.installments, request.InstallmentCount) {
+		return fmt.Errorf("installment count %d is not enabled for this terminal", request.InstallmentCount)
+	}
+
 	return nil
 }
 
 // processPayment handles the main payment processing logic
-func (p *AkbankProvider) processPayment(ctx context.Context, request provider.PaymentRequest, is3D bool) (*provider.PaymentResponse, error) {
-	// Determine transaction code
-	txnCode := txnCodeSale
-	if is3D {
-		txnCode = txnCode3D
+func (p *AkbankProvider) processPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build payment request
-	akbankReq := p.buildBaseRequest(txnCode)
+	akbankReq := p.buildBaseRequest(txnCodeSale)
 
 	// Add card information
 	expireDate := request.CardInfo.ExpireMonth + request.CardInfo.ExpireYear[len(request.CardInfo.ExpireYear)-2:]
@@ -322,7 +531,7 @@ func (p *AkbankProvider) processPayment(ctx context.Context, request provider.Pa
 
 	akbankReq["transaction"] = map[string]any{
 		"amount":       int(request.Amount * 100), // Convert to kuruş
-		"currencyCode": currencyCodeTRY,
+		"currencyCode": currencyCode,
 		"motoInd":      0,
 		"installCount": installCount,
 	}
@@ -350,6 +559,18 @@ func (p *AkbankProvider) processPayment(ctx context.Context, request provider.Pa
 	return p.sendPaymentRequest(ctx, akbankReq)
 }
 
+// currencyCode returns the ISO 4217 numeric code Akbank expects, using TRY when currency is empty
+func (p *AkbankProvider) currencyCode(currency string) (int, error) {
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	code, err := provider.CurrencyCode(currency, provider.CurrencyFormatNumeric)
+	if err != nil {
+		return 0, fmt.Errorf("akbank: %w", err)
+	}
+	return strconv.Atoi(code)
+}
+
 // buildBaseRequest builds the base request structure for Akbank
 func (p *AkbankProvider) buildBaseRequest(txnCode string) map[string]any {
 	return map[string]any{
@@ -373,7 +594,7 @@ func (p *AkbankProvider) sendPaymentRequest(ctx context.Context, requestData map
 	}
 
 	// Add provider request to client request
-	if reqMap, err := provider.StructToMap(requestData); err == nil {
+	if reqMap, err := provider.StructToMap(requestData); err == nil && p.logID > 0 {
 		_ = provider.AddProviderRequestToClientRequest("akbank", "providerRequest", reqMap, p.logID)
 	}
 
@@ -384,8 +605,8 @@ func (p *AkbankProvider) sendPaymentRequest(ctx context.Context, requestData map
 	}
 
 	// Check response code
-	respCode, _ := resp["respCode"].(string)
-	success := respCode == "0000" || respCode == "00"
+	respCode, respText := responseResult(resp)
+	success := responseApproved(respCode)
 	paymentResp.Success = success
 
 	if success {
@@ -412,9 +633,8 @@ func (p *AkbankProvider) sendPaymentRequest(ctx context.Context, requestData map
 	} else {
 		paymentResp.Status = provider.StatusFailed
 		paymentResp.ErrorCode = respCode
-		if respText, ok := resp["respText"].(string); ok {
-			paymentResp.Message = respText
-		} else {
+		paymentResp.Message = respText
+		if paymentResp.Message == "" {
 			paymentResp.Message = "Payment failed"
 		}
 	}
@@ -422,6 +642,25 @@ func (p *AkbankProvider) sendPaymentRequest(ctx context.Context, requestData map
 	return paymentResp, nil
 }
 
+// responseResult returns the response code and message of an API response, which the JSON API
+// sends as respCode and respText or, like 3D callbacks, as responseCode and responseMessage
+func responseResult(resp map[string]any) (string, string) {
+	code, _ := resp["respCode"].(string)
+	text, _ := resp["respText"].(string)
+	if code == "" {
+		code, _ = resp["responseCode"].(string)
+	}
+	if text == "" {
+		text, _ = resp["responseMessage"].(string)
+	}
+	return code, text
+}
+
+// responseApproved reports whether an API response code approves the transaction
+func responseApproved(code string) bool {
+	return code == approvedCode || code == "0000" || code == "00"
+}
+
 // sendRequest sends a request to Akbank API
 func (p *AkbankProvider) sendRequest(ctx context.Context, requestData map[string]any) (map[string]any, error) {
 	// Convert request data to JSON
@@ -466,6 +705,133 @@ func (p *AkbankProvider) generateAuthHash(data string) string {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
+// threeDHashFields are the 3D form fields signed by its hash, in order. subMerchantId and
+// b2bIdentityNumber are not sent and hash as empty values.
+var threeDHashFields = []string{
+	"paymentModel", "txnCode", "merchantSafeId", "terminalSafeId", "orderId", "lang", "amount",
+	"ccbRewardAmount", "pcbRewardAmount", "xcbRewardAmount", "currencyCode", "installCount",
+	"okUrl", "failUrl", "emailAddress", "subMerchantId", "creditCard", "expiredDate", "cvv",
+	"randomNumber", "requestDateTime", "b2bIdentityNumber",
+}
+
+// build3DFormParams builds the form posted to the 3D gateway, without its hash
+func (p *AkbankProvider) build3DFormParams(request provider.PaymentRequest, orderId, callbackURL string, currencyCode int) map[string]string {
+	installCount := 1
+	if request.InstallmentCount > 1 {
+		installCount = request.InstallmentCount
+	}
+
+	expireYear := request.CardInfo.ExpireYear
+	if len(expireYear) > 2 {
+		expireYear = expireYear[len(expireYear)-2:]
+	}
+
+	// The secret key only goes into the hash
+	return map[string]string{
+		"paymentModel":    paymentModel3DPay,
+		"txnCode":         txnCode3D,
+		"merchantSafeId":  p.merchantSafeId,
+		"terminalSafeId":  p.terminalSafeId,
+		"orderId":         orderId,
+		"lang":            "TR",
+		"amount":          strconv.FormatFloat(request.Amount, 'f', 2, 64),
+		"ccbRewardAmount": "0.00",
+		"pcbRewardAmount": "0.00",
+		"xcbRewardAmount": "0.00",
+		"currencyCode":    strconv.Itoa(currencyCode),
+		"installCount":    strconv.Itoa(installCount),
+		"okUrl":           callbackURL,
+		"failUrl":         callbackURL,
+		"emailAddress":    request.Customer.Email,
+		"creditCard":      provider.NormalizePAN(request.CardInfo.CardNumber),
+		"expiredDate":     request.CardInfo.ExpireMonth + expireYear,
+		"cvv":             request.CardInfo.CVV,
+		"randomNumber":    p.generateRandomNumber(128),
+		"requestDateTime": p.generateRequestDateTime(),
+	}
+}
+
+// calculate3DFormHash signs the 3D form fields in threeDHashFields order
+func (p *AkbankProvider) calculate3DFormHash(params map[string]string) string {
+	var hashVal strings.Builder
+	for _, field := range threeDHashFields {
+		hashVal.WriteString(params[field])
+	}
+	return p.generateAuthHash(hashVal.String())
+}
+
+// verifyResponseHash checks the hash of a form Akbank posted: the values of the fields
+// listed in hashParams, joined with "+", are concatenated and signed with the secret key
+func (p *AkbankProvider) verifyResponseHash(data map[string]string) error {
+	hash := data["hash"]
+	if hash == "" || data["hashParams"] == "" {
+		return errors.New("akbank: missing hash in callback")
+	}
+
+	var hashVal strings.Builder
+	for _, field := range strings.Split(data["hashParams"], "+") {
+		hashVal.WriteString(data[field])
+	}
+
+	if subtle.ConstantTimeCompare([]byte(p.generateAuthHash(hashVal.String())), []byte(hash)) != 1 {
+		return errors.New("akbank: invalid callback hash")
+	}
+	return nil
+}
+
+// generate3DSecureHTML generates HTML form for 3D Secure authentication
+func (p *AkbankProvider) generate3DSecureHTML(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var formFields strings.Builder
+	for _, key := range keys {
+		formFields.WriteString(fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, key, html.EscapeString(params[key])))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<title>3D Secure Authentication</title>
+	<meta charset="utf-8">
+	<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+</head>
+<body onload="document.threeDForm.submit();">
+	<div style="text-align: center; margin-top: 50px;">
+		<p>Ödeme işleminiz 3D güvenlik sayfasına yönlendiriliyor...</p>
+		<p>Payment is being redirected to 3D secure page...</p>
+	</div>
+	<form name="threeDForm" method="POST" action="%s">
+		%s
+	</form>
+</body>
+</html>`, p.threeDPostURL, formFields.String())
+}
+
+// parseInstallments parses the installments config, a comma separated list of installment
+// counts between 2 and 12
+func parseInstallments(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var installments []int
+	for _, part := range strings.Split(value, ",") {
+		count, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || count < 2 || count > provider.MaxCommissionInstallmentCount {
+			return nil, fmt.Errorf("akbank: invalid installment count %q in installments", part)
+		}
+		if !slices.Contains(installments, count) {
+			installments = append(installments, count)
+		}
+	}
+	slices.Sort(installments)
+	return installments, nil
+}
+
 // generateRequestDateTime generates request datetime in Akbank format
 func (p *AkbankProvider) generateRequestDateTime() string {
 	now := time.Now()
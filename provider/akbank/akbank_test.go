@@ -1,6 +1,13 @@
 package akbank
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/mstgnz/gopay/provider"
@@ -308,6 +315,36 @@ func TestGenerateOrderId(t *testing.T) {
 	}
 }
 
+func TestCurrencyCode(t *testing.T) {
+	p := &AkbankProvider{}
+
+	tests := []struct {
+		currency    string
+		expected    int
+		expectError bool
+	}{
+		{"TRY", 949, false},
+		{"", 949, false},
+		{"try", 949, false},
+		{"949", 949, false},
+		{"USD", 840, false},
+		{"XYZ", 0, true},
+	}
+
+	for _, tt := range tests {
+		code, err := p.currencyCode(tt.currency)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("currencyCode(%q) expected error", tt.currency)
+			}
+			continue
+		}
+		if err != nil || code != tt.expected {
+			t.Errorf("currencyCode(%q) = %d, %v; want %d", tt.currency, code, err, tt.expected)
+		}
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
 		(len(s) > 0 && len(substr) > 0 && indexOf(s, substr) >= 0))
@@ -321,3 +358,266 @@ func indexOf(s, substr string) int {
 	}
 	return -1
 }
+
+func TestAkbankProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &AkbankProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxPaymentAPIURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionPaymentAPIURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionPaymentAPIURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestParseInstallments(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []int
+		wantErr  bool
+	}{
+		{"", nil, false},
+		{"3", []int{3}, false},
+		{"12, 2,6,6", []int{2, 6, 12}, false},
+		{"1,3", nil, true},
+		{"13", nil, true},
+		{"3,x", nil, true},
+	}
+
+	for _, tt := range tests {
+		installments, err := parseInstallments(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseInstallments(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(installments, tt.expected) {
+			t.Errorf("parseInstallments(%q) = %v, want %v", tt.value, installments, tt.expected)
+		}
+	}
+
+	config := map[string]string{
+		"merchantSafeId": testMerchantSafeId,
+		"terminalSafeId": testTerminalSafeId,
+		"secretKey":      testSecretKey,
+		"environment":    "sandbox",
+		"installments":   "2,15",
+	}
+	if err := NewProvider().ValidateConfig(config); err == nil {
+		t.Error("ValidateConfig() expected an error for an invalid installment count")
+	}
+}
+
+func TestGetInstallmentCount(t *testing.T) {
+	p := &AkbankProvider{}
+	response, err := p.GetInstallmentCount(context.Background(), provider.InstallmentInquireRequest{Amount: 100})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount() error = %v", err)
+	}
+	if options := response.Installments[installmentProgram]; len(options) != 1 || options[0].Installment != 1 {
+		t.Errorf("Expected a single payment without configured installments, got %+v", response.Installments)
+	}
+
+	p.installments = []int{3, 6}
+	response, err = p.GetInstallmentCount(context.Background(), provider.InstallmentInquireRequest{Amount: 100})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount() error = %v", err)
+	}
+	var counts []int
+	for _, option := range response.Installments[installmentProgram] {
+		counts = append(counts, option.Installment)
+	}
+	if !slices.Equal(counts, []int{1, 3, 6}) || response.Amount != 100 {
+		t.Errorf("Unexpected installment options %+v", response)
+	}
+
+	request := provider.PaymentRequest{
+		TenantID:         1,
+		Amount:           100,
+		Currency:         "TRY",
+		InstallmentCount: 9,
+		Customer:         provider.Customer{Email: "test@test.com"},
+		CardInfo:         testCard,
+	}
+	if err := p.validatePaymentRequest(request, false); err == nil {
+		t.Error("Expected an error for an installment count the terminal does not offer")
+	}
+	request.InstallmentCount = 6
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		t.Errorf("Expected an offered installment count to be valid, got %v", err)
+	}
+}
+
+func TestBuild3DFormParams(t *testing.T) {
+	p := getTestProvider(t)
+
+	request := provider.PaymentRequest{
+		TenantID:         1,
+		Amount:           100.5,
+		Currency:         "TRY",
+		InstallmentCount: 1,
+		Customer:         provider.Customer{Email: "test@test.com"},
+		CardInfo:         provider.CardInfo{CardNumber: "4355 0843 5508 4358", ExpireMonth: "12", ExpireYear: "2026", CVV: "000"},
+	}
+
+	params := p.build3DFormParams(request, "ORDER1", "https://gopay.example.com/callback/akbank", 949)
+	expected := map[string]string{
+		"paymentModel":   paymentModel3DPay,
+		"txnCode":        txnCode3D,
+		"merchantSafeId": testMerchantSafeId,
+		"terminalSafeId": testTerminalSafeId,
+		"orderId":        "ORDER1",
+		"amount":         "100.50",
+		"currencyCode":   "949",
+		"installCount":   "1",
+		"okUrl":          "https://gopay.example.com/callback/akbank",
+		"failUrl":        "https://gopay.example.com/callback/akbank",
+		"creditCard":     "4355084355084358",
+		"expiredDate":    "1226",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, params[key])
+		}
+	}
+	if _, ok := params["secretKey"]; ok {
+		t.Error("The secret key must not be posted")
+	}
+
+	var hashVal strings.Builder
+	for _, field := range threeDHashFields {
+		hashVal.WriteString(params[field])
+	}
+	if p.calculate3DFormHash(params) != p.generateAuthHash(hashVal.String()) {
+		t.Error("Expected the form hash to sign the fields in order")
+	}
+
+	form := p.generate3DSecureHTML(params)
+	if !strings.Contains(form, `action="`+threeDSandboxURL+`"`) {
+		t.Error("Expected the form to post to the 3D gateway")
+	}
+}
+
+// signedCallback returns callback data signed over the fields listed in hashParams
+func signedCallback(p *AkbankProvider, data map[string]string) map[string]string {
+	data["hashParams"] = "txnCode+responseCode+responseMessage+mdStatus+orderId+rrn+randomNumber"
+	var hashVal strings.Builder
+	for _, field := range strings.Split(data["hashParams"], "+") {
+		hashVal.WriteString(data[field])
+	}
+	data["hash"] = p.generateAuthHash(hashVal.String())
+	return data
+}
+
+func TestComplete3DPayment(t *testing.T) {
+	p := getTestProvider(t)
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "ORDER1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "akbank",
+	}
+
+	approved := func() map[string]string {
+		return map[string]string{
+			"txnCode":         txnCode3D,
+			"responseCode":    approvedCode,
+			"responseMessage": "BAŞARILI",
+			"mdStatus":        "1",
+			"orderId":         "ORDER1",
+			"rrn":             "401508123456",
+			"randomNumber":    "abc",
+		}
+	}
+
+	ctx := context.Background()
+	// GoPay's own state query parameter is merged into the callback data but not signed
+	callback := signedCallback(p, approved())
+	callback["state"] = "abc123"
+	response, err := p.Complete3DPayment(ctx, callbackState, callback)
+	if err != nil {
+		t.Fatalf("Complete3DPayment() error = %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "ORDER1" ||
+		response.TransactionID != "401508123456" || response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+
+	declined := approved()
+	declined["responseCode"] = "VPS-1005"
+	declined["responseMessage"] = "Yetersiz bakiye"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, declined))
+	if err != nil {
+		t.Fatalf("Complete3DPayment() error = %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "VPS-1005" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	notAuthenticated := approved()
+	notAuthenticated["mdStatus"] = "0"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, notAuthenticated))
+	if err != nil || response.Success {
+		t.Errorf("Expected a failed payment for mdStatus 0, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, approved()); err == nil || !strings.Contains(err.Error(), "missing hash") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	tampered := signedCallback(p, declined)
+	tampered["responseCode"] = approvedCode
+	if _, err := p.Complete3DPayment(ctx, callbackState, tampered); err == nil || !strings.Contains(err.Error(), "invalid callback hash") {
+		t.Errorf("Expected a tampered callback to be rejected, got %v", err)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	p := getTestProvider(t)
+
+	data := signedCallback(p, map[string]string{"txnCode": txnCode3D, "responseCode": approvedCode, "orderId": "ORDER1"})
+	valid, result, err := p.ValidateWebhook(context.Background(), data, nil)
+	if err != nil || !valid || result["orderId"] != "ORDER1" {
+		t.Errorf("Expected a signed notification to be valid, got %v %v %v", valid, result, err)
+	}
+
+	data["orderId"] = "ORDER2"
+	if valid, _, err := p.ValidateWebhook(context.Background(), data, nil); err == nil || valid {
+		t.Error("Expected a tampered notification to be rejected")
+	}
+}
+
+func TestCreatePayment(t *testing.T) {
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Errorf("Expected a JSON request, got %s", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"responseCode":"VPS-0000","responseMessage":"BAŞARILI","transactionId":"T1","order":{"orderId":"ORDER1"}}`)
+	}))
+	defer server.Close()
+
+	p := getTestProvider(t)
+	p.baseURL = server.URL
+
+	response, err := p.CreatePayment(context.Background(), provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100,
+		Currency: "TRY",
+		Customer: provider.Customer{Email: "test@test.com"},
+		CardInfo: testCard,
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if sent["txnCode"] != txnCodeSale {
+		t.Errorf("Expected a sale, got %v", sent["txnCode"])
+	}
+	if !response.Success || response.PaymentID != "T1" || response.OrderID != "ORDER1" {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// ApplePaySigningWindow is how far the signing time of an Apple Pay token may be from now.
+// Apple signs the token when the customer authorizes the payment, so an older token is
+// treated as a replay.
+const ApplePaySigningWindow = 5 * time.Minute
+
+var (
+	// Apple marks the certificates of its Apple Pay signing chain with these extensions
+	oidApplePayLeaf         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 29}
+	oidApplePayIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 14}
+
+	oidCMSSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidCMSMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidCMSSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+)
+
+// cmsContentInfo, cmsSignedData, cmsSignerInfo and cmsAttribute are the parts of the
+// PKCS #7 (CMS) detached signature of an Apple Pay token needed to verify it
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo asn1.RawValue
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// LoadApplePayRoots reads the Apple Root CA - G3 certificate, PEM or DER, from the file in
+// APPLE_PAY_ROOT_CA_FILE. Apple Pay tokens are only accepted once it is configured.
+func LoadApplePayRoots() (*x509.CertPool, error) {
+	path := strings.TrimSpace(config.GetEnv("APPLE_PAY_ROOT_CA_FILE", ""))
+	if path == "" {
+		return nil, errors.New("APPLE_PAY_ROOT_CA_FILE must point to the Apple Root CA - G3 certificate")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apple pay root certificate: %w", err)
+	}
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+	root, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apple pay root certificate: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return roots, nil
+}
+
+// verifyApplePaySignature checks the detached PKCS #7 signature of an EC_v1 token: it must be
+// made by an Apple Pay leaf certificate chaining through the Apple Pay intermediate to roots,
+// cover the token's payment data, and have been made within ApplePaySigningWindow of now
+func verifyApplePaySignature(paymentData ApplePayPaymentData, roots *x509.CertPool, now time.Time) error {
+	if roots == nil {
+		return errors.New("apple pay root certificate is not configured")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(paymentData.Signature)
+	if err != nil || len(raw) == 0 {
+		return fmt.Errorf("%w: token is not signed", ErrWalletPaymentInvalid)
+	}
+	var contentInfo cmsContentInfo
+	if rest, err := asn1.Unmarshal(raw, &contentInfo); err != nil || len(rest) > 0 || !contentInfo.ContentType.Equal(oidCMSSignedData) {
+		return fmt.Errorf("%w: malformed token signature", ErrWalletPaymentInvalid)
+	}
+	var signedData cmsSignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil || len(signedData.SignerInfos) != 1 {
+		return fmt.Errorf("%w: malformed token signature", ErrWalletPaymentInvalid)
+	}
+	signer := signedData.SignerInfos[0]
+
+	certificates, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token signing certificates", ErrWalletPaymentInvalid)
+	}
+	var leaf *x509.Certificate
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates {
+		switch {
+		case hasExtension(certificate, oidApplePayLeaf):
+			leaf = certificate
+		case hasExtension(certificate, oidApplePayIntermediate):
+			intermediates.AddCert(certificate)
+		}
+	}
+	if leaf == nil {
+		return fmt.Errorf("%w: token was not signed by an Apple Pay certificate", ErrWalletPaymentInvalid)
+	}
+
+	// The signed attributes carry the digest of the payment data and the signing time
+	var digest []byte
+	var signingTime time.Time
+	attributes := signer.SignedAttrs.Bytes
+	for len(attributes) > 0 {
+		var attribute cmsAttribute
+		if attributes, err = asn1.Unmarshal(attributes, &attribute); err != nil {
+			return fmt.Errorf("%w: malformed token signature attributes", ErrWalletPaymentInvalid)
+		}
+		switch {
+		case attribute.Type.Equal(oidCMSMessageDigest):
+			_, err = asn1.Unmarshal(attribute.Values.Bytes, &digest)
+		case attribute.Type.Equal(oidCMSSigningTime):
+			_, err = asn1.Unmarshal(attribute.Values.Bytes, &signingTime)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: malformed token signature attributes", ErrWalletPaymentInvalid)
+		}
+	}
+	if signingTime.IsZero() {
+		return fmt.Errorf("%w: token signature has no signing time", ErrWalletPaymentInvalid)
+	}
+	if age := now.Sub(signingTime); age > ApplePaySigningWindow || age < -ApplePaySigningWindow {
+		return fmt.Errorf("%w: token was signed at %s, outside the signing window", ErrWalletPaymentInvalid, signingTime.UTC().Format(time.RFC3339))
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signingTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: token signing certificate is not trusted: %v", ErrWalletPaymentInvalid, err)
+	}
+	if !slices.ContainsFunc(chains, func(chain []*x509.Certificate) bool {
+		return len(chain) == 3 && hasExtension(chain[1], oidApplePayIntermediate)
+	}) {
+		return fmt.Errorf("%w: token signing certificate was not issued by the Apple Pay intermediate", ErrWalletPaymentInvalid)
+	}
+
+	content, err := applePaySignedContent(paymentData)
+	if err != nil {
+		return err
+	}
+	expected := sha256.Sum256(content)
+	if subtle.ConstantTimeCompare(digest, expected[:]) != 1 {
+		return fmt.Errorf("%w: token signature does not cover its payment data", ErrWalletPaymentInvalid)
+	}
+
+	// The signature is made over the DER of the signed attributes as a SET
+	signedAttrs := bytes.Clone(signer.SignedAttrs.FullBytes)
+	signedAttrs[0] = 0x31
+	if err := leaf.CheckSignature(x509.ECDSAWithSHA256, signedAttrs, signer.Signature); err != nil {
+		return fmt.Errorf("%w: invalid token signature", ErrWalletPaymentInvalid)
+	}
+	return nil
+}
+
+// applePaySignedContent is the data an EC_v1 token signature covers: the ephemeral public
+// key, the encrypted data, the transaction ID and the application data, if any
+func applePaySignedContent(paymentData ApplePayPaymentData) ([]byte, error) {
+	ephemeralPublicKey, err := base64.StdEncoding.DecodeString(paymentData.Header.EphemeralPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed ephemeral public key", ErrWalletPaymentInvalid)
+	}
+	data, err := base64.StdEncoding.DecodeString(paymentData.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed payment data", ErrWalletPaymentInvalid)
+	}
+	transactionID, err := hex.DecodeString(paymentData.Header.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed transaction ID", ErrWalletPaymentInvalid)
+	}
+	applicationData, err := hex.DecodeString(paymentData.Header.ApplicationData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed application data", ErrWalletPaymentInvalid)
+	}
+	return slices.Concat(ephemeralPublicKey, data, transactionID, applicationData), nil
+}
+
+// hasExtension reports whether the certificate carries the extension
+func hasExtension(certificate *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	return slices.ContainsFunc(certificate.Extensions, func(extension pkix.Extension) bool {
+		return extension.Id.Equal(oid)
+	})
+}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// AsyncPaymentProvider is an OPTIONAL capability interface implemented by hosted providers whose
+// payments are only final when their webhook arrives (Papara, PayTR). CreatePayment gives their
+// payments a GoPay payment ID up front in PaymentRequest.ID, which the provider sends as its
+// order reference, so the client has an ID to poll before the webhook.
+type AsyncPaymentProvider interface {
+	// WebhookPaymentReference returns the GoPay payment ID the provider echoed back and the
+	// provider's own payment ID from validated webhook data. Either may be empty.
+	WebhookPaymentReference(data map[string]string) (paymentID, providerPaymentID string)
+}
+
+// PaymentReference links the GoPay payment ID of an async payment to the provider's own ID
+type PaymentReference struct {
+	TenantID          int
+	Provider          string
+	PaymentID         string
+	ProviderPaymentID string
+}
+
+// PaymentReferenceStore keeps the payment references of async payments. Lookups return
+// sql.ErrNoRows for an unknown ID.
+type PaymentReferenceStore interface {
+	Save(ctx context.Context, reference PaymentReference) error
+	ProviderPaymentID(ctx context.Context, tenantID int, providerName, paymentID string) (string, error)
+	PaymentID(ctx context.Context, tenantID int, providerName, providerPaymentID string) (string, error)
+}
+
+// NewPaymentID generates a GoPay payment ID. It is alphanumeric, as PayTR's merchant_oid must be.
+func NewPaymentID() string {
+	return "gp" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// PostgresPaymentReferenceStore keeps payment references in the payment_references table
+type PostgresPaymentReferenceStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPaymentReferenceStore creates a store over the shared *sql.DB connection
+func NewPostgresPaymentReferenceStore(db *sql.DB) *PostgresPaymentReferenceStore {
+	return &PostgresPaymentReferenceStore{db: db}
+}
+
+// Save stores the reference, replacing the provider ID of a payment saved before
+func (r *PostgresPaymentReferenceStore) Save(ctx context.Context, reference PaymentReference) error {
+	query := `
+		INSERT INTO payment_references (tenant_id, provider, payment_id, provider_payment_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, provider, payment_id)
+		DO UPDATE SET provider_payment_id = EXCLUDED.provider_payment_id, updated_at = now()`
+	if _, err := r.db.ExecContext(ctx, query, reference.TenantID, strings.ToLower(reference.Provider), reference.PaymentID, reference.ProviderPaymentID); err != nil {
+		return fmt.Errorf("failed to save payment reference: %w", err)
+	}
+	return nil
+}
+
+// ProviderPaymentID returns the provider's ID of a GoPay payment ID
+func (r *PostgresPaymentReferenceStore) ProviderPaymentID(ctx context.Context, tenantID int, providerName, paymentID string) (string, error) {
+	var providerPaymentID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT provider_payment_id FROM payment_references
+		WHERE tenant_id = $1 AND provider = $2 AND payment_id = $3`,
+		tenantID, strings.ToLower(providerName), paymentID,
+	).Scan(&providerPaymentID)
+	return providerPaymentID, err
+}
+
+// PaymentID returns the GoPay payment ID of a provider's ID
+func (r *PostgresPaymentReferenceStore) PaymentID(ctx context.Context, tenantID int, providerName, providerPaymentID string) (string, error) {
+	var paymentID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT payment_id FROM payment_references
+		WHERE tenant_id = $1 AND provider = $2 AND provider_payment_id = $3`,
+		tenantID, strings.ToLower(providerName), providerPaymentID,
+	).Scan(&paymentID)
+	return paymentID, err
+}
+
+// linkAsyncPayment answers an async payment with its GoPay payment ID. The provider's own ID,
+// when it returned a different one, is saved to reach the payment at the provider later; it
+// stays in the response when it cannot be saved, as it would be lost otherwise.
+func (s *PaymentService) linkAsyncPayment(ctx context.Context, tenantID int, providerName, paymentID string, response *PaymentResponse) {
+	if response.PaymentID == "" || response.PaymentID == paymentID {
+		response.PaymentID = paymentID
+		return
+	}
+	if s.paymentReferences == nil {
+		return
+	}
+
+	err := s.paymentReferences.Save(ctx, PaymentReference{
+		TenantID:          tenantID,
+		Provider:          providerName,
+		PaymentID:         paymentID,
+		ProviderPaymentID: response.PaymentID,
+	})
+	if err != nil {
+		logger.Warn("Failed to save payment reference", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"payment_id":          paymentID,
+				"provider_payment_id": response.PaymentID,
+				"error":               err.Error(),
+			},
+		})
+		return
+	}
+	response.PaymentID = paymentID
+}
+
+// providerPaymentID returns the ID the provider knows a payment by. Payments without a saved
+// reference, including those of other providers, keep their ID.
+func (s *PaymentService) providerPaymentID(ctx context.Context, tenantID int, providerName string, provider PaymentProvider, paymentID string) string {
+	if _, ok := provider.(AsyncPaymentProvider); !ok || s.paymentReferences == nil || paymentID == "" {
+		return paymentID
+	}
+
+	providerPaymentID, err := s.paymentReferences.ProviderPaymentID(ctx, tenantID, providerName, paymentID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Failed to look up payment reference", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"payment_id": paymentID,
+					"error":      err.Error(),
+				},
+			})
+		}
+		return paymentID
+	}
+	return providerPaymentID
+}
+
+// reconcileWebhook rewrites the paymentId of an async provider's webhook to the GoPay payment
+// ID and keeps the provider's ID in providerPaymentId. A reference saved at creation wins over
+// the one in the webhook; otherwise the webhook's reference is saved for later requests.
+func (s *PaymentService) reconcileWebhook(ctx context.Context, tenantID int, providerName string, async AsyncPaymentProvider, result map[string]string) {
+	paymentID, providerPaymentID := async.WebhookPaymentReference(result)
+	if providerPaymentID == "" {
+		return
+	}
+
+	if s.paymentReferences != nil {
+		known, err := s.paymentReferences.PaymentID(ctx, tenantID, providerName, providerPaymentID)
+		switch {
+		case err == nil:
+			paymentID = known
+		case errors.Is(err, sql.ErrNoRows) && paymentID != "" && paymentID != providerPaymentID:
+			err = s.paymentReferences.Save(ctx, PaymentReference{
+				TenantID:          tenantID,
+				Provider:          providerName,
+				PaymentID:         paymentID,
+				ProviderPaymentID: providerPaymentID,
+			})
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Failed to reconcile webhook payment reference", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"payment_id":          paymentID,
+					"provider_payment_id": providerPaymentID,
+					"error":               err.Error(),
+				},
+			})
+		}
+	}
+
+	if paymentID == "" {
+		paymentID = providerPaymentID
+	}
+	result["paymentId"] = paymentID
+	result["providerPaymentId"] = providerPaymentID
+}
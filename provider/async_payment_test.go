@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryPaymentReferenceStore keeps payment references in a slice
+type memoryPaymentReferenceStore struct {
+	references []PaymentReference
+}
+
+func (m *memoryPaymentReferenceStore) Save(_ context.Context, reference PaymentReference) error {
+	m.references = append(m.references, reference)
+	return nil
+}
+
+func (m *memoryPaymentReferenceStore) ProviderPaymentID(_ context.Context, tenantID int, providerName, paymentID string) (string, error) {
+	for _, r := range m.references {
+		if r.TenantID == tenantID && r.Provider == providerName && r.PaymentID == paymentID {
+			return r.ProviderPaymentID, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+func (m *memoryPaymentReferenceStore) PaymentID(_ context.Context, tenantID int, providerName, providerPaymentID string) (string, error) {
+	for _, r := range m.references {
+		if r.TenantID == tenantID && r.Provider == providerName && r.ProviderPaymentID == providerPaymentID {
+			return r.PaymentID, nil
+		}
+	}
+	return "", sql.ErrNoRows
+}
+
+// asyncTestProvider is a hosted provider that answers with its own payment ID and reports the
+// order reference in its webhook
+type asyncTestProvider struct {
+	PaymentProvider
+	reference       string
+	statusRequested string
+}
+
+func (p *asyncTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *asyncTestProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.reference = request.ID
+	return &PaymentResponse{Success: true, Status: StatusPending, PaymentID: "prov_1", Currency: request.Currency}, nil
+}
+
+func (p *asyncTestProvider) GetPaymentStatus(_ context.Context, request GetPaymentStatusRequest) (*PaymentResponse, error) {
+	p.statusRequested = request.PaymentID
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: request.PaymentID}, nil
+}
+
+func (p *asyncTestProvider) ValidateWebhook(_ context.Context, data, _ map[string]string) (bool, map[string]string, error) {
+	result := make(map[string]string, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	return true, result, nil
+}
+
+func (p *asyncTestProvider) WebhookPaymentReference(data map[string]string) (string, string) {
+	return data["reference"], data["id"]
+}
+
+func TestPaymentService_AsyncPaymentID(t *testing.T) {
+	const tenantID, providerName = 9119, "asynctest"
+
+	fake := &asyncTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := &memoryPaymentReferenceStore{}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentReferenceStore(store)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9119")
+
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	paymentID := resp.PaymentID
+	if paymentID == "" || paymentID == "prov_1" || paymentID != fake.reference {
+		t.Fatalf("expected the GoPay payment ID sent as the reference, got %q (reference %q)", paymentID, fake.reference)
+	}
+
+	// The client polls with the GoPay ID, the provider is asked for its own
+	status, err := service.GetPaymentStatus(ctx, "sandbox", providerName, GetPaymentStatusRequest{PaymentID: paymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if fake.statusRequested != "prov_1" || status.PaymentID != paymentID {
+		t.Errorf("expected a status lookup of prov_1 answered as %s, got %q answered as %q", paymentID, fake.statusRequested, status.PaymentID)
+	}
+
+	// The webhook is reconciled to the GoPay ID
+	valid, result, err := service.ValidateWebhook(ctx, "sandbox", providerName, map[string]string{"id": "prov_1", "status": "completed"}, nil)
+	if err != nil || !valid {
+		t.Fatalf("ValidateWebhook failed: %v", err)
+	}
+	if result["paymentId"] != paymentID || result["providerPaymentId"] != "prov_1" {
+		t.Errorf("expected the webhook reconciled to %s, got %v", paymentID, result)
+	}
+}
+
+func TestPaymentService_AsyncPaymentID_WebhookSavesReference(t *testing.T) {
+	const tenantID, providerName = 9119, "asyncwebhooktest"
+
+	fake := &asyncTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := &memoryPaymentReferenceStore{}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentReferenceStore(store)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9119")
+
+	// A payment whose reference was not saved at creation is linked by its webhook
+	_, result, err := service.ValidateWebhook(ctx, "sandbox", providerName, map[string]string{"id": "prov_2", "reference": "gp_order_2"}, nil)
+	if err != nil {
+		t.Fatalf("ValidateWebhook failed: %v", err)
+	}
+	if result["paymentId"] != "gp_order_2" {
+		t.Errorf("expected the webhook's reference as payment ID, got %v", result)
+	}
+	if got, err := store.ProviderPaymentID(ctx, tenantID, providerName, "gp_order_2"); err != nil || got != "prov_2" {
+		t.Errorf("expected the reference saved, got %q, %v", got, err)
+	}
+
+	if _, err := service.GetPaymentStatus(ctx, "sandbox", providerName, GetPaymentStatusRequest{PaymentID: "gp_order_2"}); err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if fake.statusRequested != "prov_2" {
+		t.Errorf("expected a status lookup of prov_2, got %q", fake.statusRequested)
+	}
+}
+
+func TestNewPaymentID(t *testing.T) {
+	id := NewPaymentID()
+	if len(id) != 34 || id == NewPaymentID() {
+		t.Errorf("expected a unique 34 character ID, got %q", id)
+	}
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'z') {
+			t.Fatalf("expected an alphanumeric ID, got %q", id)
+		}
+	}
+}
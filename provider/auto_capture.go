@@ -0,0 +1,313 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	// defaultAutoCaptureMaxDelay keeps captures inside the usual 7-day card authorization window
+	defaultAutoCaptureMaxDelay = 7 * 24 * time.Hour
+
+	autoCaptureBatchSize   = 50
+	autoCaptureMaxAttempts = 3
+	autoCaptureRetryDelay  = 5 * time.Minute
+
+	AutoCaptureStatusPending   = "pending"
+	AutoCaptureStatusCapturing = "capturing"
+	AutoCaptureStatusCaptured  = "captured"
+	AutoCaptureStatusCancelled = "cancelled"
+	AutoCaptureStatusFailed    = "failed"
+)
+
+// ErrAutoCaptureDelayInvalid is returned when AutoCaptureAfter cannot be parsed or is out of range.
+var ErrAutoCaptureDelayInvalid = errors.New("invalid auto-capture delay")
+
+// AutoCaptureJob is a capture scheduled for an authorized payment.
+type AutoCaptureJob struct {
+	ID          int64     `json:"id"`
+	TenantID    int       `json:"tenantId"`
+	Provider    string    `json:"provider"`
+	Environment string    `json:"environment"`
+	PaymentID   string    `json:"paymentId"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	CaptureAt   time.Time `json:"captureAt"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// AutoCaptureStore persists scheduled captures so they survive restarts.
+type AutoCaptureStore interface {
+	// Schedule stores a pending job and sets its ID
+	Schedule(ctx context.Context, job *AutoCaptureJob) error
+
+	// Cancel cancels the pending job for a payment. It reports false when there was nothing
+	// left to cancel (already captured, in flight or never scheduled).
+	Cancel(ctx context.Context, tenantID int, providerName, paymentID string) (bool, error)
+
+	// ClaimDue marks up to limit pending jobs due at now as capturing and returns them
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]AutoCaptureJob, error)
+
+	// Finish records the final status of a claimed job
+	Finish(ctx context.Context, id int64, status, lastError string) error
+
+	// Retry puts a claimed job back to pending for another attempt at captureAt
+	Retry(ctx context.Context, id int64, captureAt time.Time, lastError string) error
+}
+
+// CaptureFunc captures a scheduled payment, typically PaymentService.CaptureScheduledPayment.
+type CaptureFunc func(ctx context.Context, job AutoCaptureJob) error
+
+// AutoCaptureScheduler captures authorized payments once their AutoCaptureAfter delay elapses.
+type AutoCaptureScheduler struct {
+	store    AutoCaptureStore
+	capture  CaptureFunc
+	maxDelay time.Duration
+	now      func() time.Time
+}
+
+// NewAutoCaptureScheduler creates a scheduler. The maximum delay a payment may request is
+// read from AUTO_CAPTURE_MAX_DELAY (Go duration, default 168h).
+func NewAutoCaptureScheduler(store AutoCaptureStore, capture CaptureFunc) *AutoCaptureScheduler {
+	maxDelay := defaultAutoCaptureMaxDelay
+	if value := strings.TrimSpace(config.GetEnv("AUTO_CAPTURE_MAX_DELAY", "")); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			maxDelay = parsed
+		} else {
+			logger.Warn("Ignoring invalid AUTO_CAPTURE_MAX_DELAY", logger.LogContext{
+				Fields: map[string]any{
+					"value": value,
+				},
+			})
+		}
+	}
+
+	return &AutoCaptureScheduler{
+		store:    store,
+		capture:  capture,
+		maxDelay: maxDelay,
+		now:      time.Now,
+	}
+}
+
+// MaxDelay returns the longest AutoCaptureAfter a payment may request
+func (s *AutoCaptureScheduler) MaxDelay() time.Duration {
+	return s.maxDelay
+}
+
+// ParseDelay validates an AutoCaptureAfter value against the configured maximum
+func (s *AutoCaptureScheduler) ParseDelay(value string) (time.Duration, error) {
+	delay, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrAutoCaptureDelayInvalid, err)
+	}
+	if delay <= 0 {
+		return 0, fmt.Errorf("%w: must be positive", ErrAutoCaptureDelayInvalid)
+	}
+	if delay > s.maxDelay {
+		return 0, fmt.Errorf("%w: must not exceed %s", ErrAutoCaptureDelayInvalid, s.maxDelay)
+	}
+	return delay, nil
+}
+
+// Schedule stores a capture for an authorized payment and returns when it will fire
+func (s *AutoCaptureScheduler) Schedule(ctx context.Context, job AutoCaptureJob, delay time.Duration) (time.Time, error) {
+	if delay <= 0 || delay > s.maxDelay {
+		return time.Time{}, fmt.Errorf("%w: must be between 0 and %s", ErrAutoCaptureDelayInvalid, s.maxDelay)
+	}
+	if job.TenantID <= 0 || job.Provider == "" || job.PaymentID == "" {
+		return time.Time{}, errors.New("tenant, provider and payment ID are required to schedule a capture")
+	}
+
+	job.CaptureAt = s.now().Add(delay)
+	job.Status = AutoCaptureStatusPending
+	if err := s.store.Schedule(ctx, &job); err != nil {
+		return time.Time{}, err
+	}
+	return job.CaptureAt, nil
+}
+
+// Cancel stops a pending capture. It reports whether a pending capture was cancelled.
+func (s *AutoCaptureScheduler) Cancel(ctx context.Context, tenantID int, providerName, paymentID string) (bool, error) {
+	return s.store.Cancel(ctx, tenantID, providerName, paymentID)
+}
+
+// RunDue captures every job whose delay has elapsed and returns how many were captured
+func (s *AutoCaptureScheduler) RunDue(ctx context.Context) (int, error) {
+	jobs, err := s.store.ClaimDue(ctx, s.now(), autoCaptureBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	captured := 0
+	for _, job := range jobs {
+		captureErr := s.capture(ctx, job)
+		if captureErr == nil {
+			captured++
+			if err := s.store.Finish(ctx, job.ID, AutoCaptureStatusCaptured, ""); err != nil {
+				logger.Warn("Failed to mark auto-capture as captured", logger.LogContext{
+					Provider: job.Provider,
+					Fields: map[string]any{
+						"job_id":     job.ID,
+						"payment_id": job.PaymentID,
+						"error":      err.Error(),
+					},
+				})
+			}
+			continue
+		}
+
+		logger.Warn("Auto-capture failed", logger.LogContext{
+			TenantID: fmt.Sprintf("%d", job.TenantID),
+			Provider: job.Provider,
+			Fields: map[string]any{
+				"job_id":     job.ID,
+				"payment_id": job.PaymentID,
+				"attempts":   job.Attempts,
+				"error":      captureErr.Error(),
+			},
+		})
+
+		if job.Attempts >= autoCaptureMaxAttempts {
+			err = s.store.Finish(ctx, job.ID, AutoCaptureStatusFailed, captureErr.Error())
+		} else {
+			err = s.store.Retry(ctx, job.ID, s.now().Add(autoCaptureRetryDelay), captureErr.Error())
+		}
+		if err != nil {
+			logger.Warn("Failed to update auto-capture job", logger.LogContext{
+				Provider: job.Provider,
+				Fields: map[string]any{
+					"job_id": job.ID,
+					"error":  err.Error(),
+				},
+			})
+		}
+	}
+
+	return captured, nil
+}
+
+// Start runs due captures every interval until ctx is done
+func (s *AutoCaptureScheduler) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			if _, err := s.RunDue(runCtx); err != nil {
+				logger.Warn("Failed to run auto-captures", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+			cancel()
+		}
+	}
+}
+
+// PostgresAutoCaptureStore keeps scheduled captures in the auto_captures table.
+type PostgresAutoCaptureStore struct {
+	db *sql.DB
+}
+
+// NewPostgresAutoCaptureStore creates a store over the shared *sql.DB connection.
+func NewPostgresAutoCaptureStore(db *sql.DB) *PostgresAutoCaptureStore {
+	return &PostgresAutoCaptureStore{db: db}
+}
+
+// Schedule inserts a pending job
+func (r *PostgresAutoCaptureStore) Schedule(ctx context.Context, job *AutoCaptureJob) error {
+	query := `
+		INSERT INTO auto_captures (tenant_id, provider, environment, payment_id, amount, currency, capture_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		job.TenantID, job.Provider, job.Environment, job.PaymentID, job.Amount, job.Currency, job.CaptureAt, AutoCaptureStatusPending,
+	).Scan(&job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to schedule auto-capture: %w", err)
+	}
+	return nil
+}
+
+// Cancel cancels the pending job for a payment, scoped to the tenant
+func (r *PostgresAutoCaptureStore) Cancel(ctx context.Context, tenantID int, providerName, paymentID string) (bool, error) {
+	query := `
+		UPDATE auto_captures SET status = $4, updated_at = now()
+		WHERE tenant_id = $1 AND provider = $2 AND payment_id = $3 AND status = $5`
+
+	result, err := r.db.ExecContext(ctx, query, tenantID, providerName, paymentID, AutoCaptureStatusCancelled, AutoCaptureStatusPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel auto-capture: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ClaimDue claims due jobs. SKIP LOCKED lets several GoPay instances poll the same table
+// without capturing a payment twice.
+func (r *PostgresAutoCaptureStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]AutoCaptureJob, error) {
+	query := `
+		UPDATE auto_captures SET status = $3, attempts = attempts + 1, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM auto_captures
+			WHERE status = $2 AND capture_at <= $1
+			ORDER BY capture_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, tenant_id, provider, environment, payment_id, amount, currency, capture_at, status, attempts, COALESCE(last_error, '')`
+
+	rows, err := r.db.QueryContext(ctx, query, now, AutoCaptureStatusPending, AutoCaptureStatusCapturing, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim auto-captures: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []AutoCaptureJob
+	for rows.Next() {
+		var job AutoCaptureJob
+		if err := rows.Scan(&job.ID, &job.TenantID, &job.Provider, &job.Environment, &job.PaymentID,
+			&job.Amount, &job.Currency, &job.CaptureAt, &job.Status, &job.Attempts, &job.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan auto-capture: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Finish records the final status of a claimed job
+func (r *PostgresAutoCaptureStore) Finish(ctx context.Context, id int64, status, lastError string) error {
+	query := `UPDATE auto_captures SET status = $2, last_error = $3, updated_at = now() WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, status, nullString(lastError)); err != nil {
+		return fmt.Errorf("failed to finish auto-capture: %w", err)
+	}
+	return nil
+}
+
+// Retry puts a claimed job back to pending
+func (r *PostgresAutoCaptureStore) Retry(ctx context.Context, id int64, captureAt time.Time, lastError string) error {
+	query := `UPDATE auto_captures SET status = $2, capture_at = $3, last_error = $4, updated_at = now() WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, AutoCaptureStatusPending, captureAt, nullString(lastError)); err != nil {
+		return fmt.Errorf("failed to reschedule auto-capture: %w", err)
+	}
+	return nil
+}
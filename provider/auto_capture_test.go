@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAutoCaptureStore is an in-memory AutoCaptureStore for scheduler tests.
+type memoryAutoCaptureStore struct {
+	mu   sync.Mutex
+	jobs map[int64]*AutoCaptureJob
+	next int64
+}
+
+func newMemoryAutoCaptureStore() *memoryAutoCaptureStore {
+	return &memoryAutoCaptureStore{jobs: make(map[int64]*AutoCaptureJob)}
+}
+
+func (m *memoryAutoCaptureStore) Schedule(_ context.Context, job *AutoCaptureJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next++
+	job.ID = m.next
+	stored := *job
+	m.jobs[job.ID] = &stored
+	return nil
+}
+
+func (m *memoryAutoCaptureStore) Cancel(_ context.Context, tenantID int, providerName, paymentID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.TenantID == tenantID && job.Provider == providerName && job.PaymentID == paymentID && job.Status == AutoCaptureStatusPending {
+			job.Status = AutoCaptureStatusCancelled
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryAutoCaptureStore) ClaimDue(_ context.Context, now time.Time, limit int) ([]AutoCaptureJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []AutoCaptureJob
+	for _, job := range m.jobs {
+		if len(due) >= limit {
+			break
+		}
+		if job.Status == AutoCaptureStatusPending && !job.CaptureAt.After(now) {
+			job.Status = AutoCaptureStatusCapturing
+			job.Attempts++
+			due = append(due, *job)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryAutoCaptureStore) Finish(_ context.Context, id int64, status, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = status
+	m.jobs[id].LastError = lastError
+	return nil
+}
+
+func (m *memoryAutoCaptureStore) Retry(_ context.Context, id int64, captureAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[id].Status = AutoCaptureStatusPending
+	m.jobs[id].CaptureAt = captureAt
+	m.jobs[id].LastError = lastError
+	return nil
+}
+
+func (m *memoryAutoCaptureStore) status(id int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id].Status
+}
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+func newFakeClock() *fakeClock               { return &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)} }
+func testJob(paymentID string) AutoCaptureJob {
+	return AutoCaptureJob{TenantID: 1, Provider: "stripe", Environment: "sandbox", PaymentID: paymentID, Amount: 100, Currency: "USD"}
+}
+func newTestScheduler(store AutoCaptureStore, clock *fakeClock, capture CaptureFunc) *AutoCaptureScheduler {
+	s := NewAutoCaptureScheduler(store, capture)
+	s.now = clock.Now
+	return s
+}
+
+func TestAutoCaptureScheduler_FiresAfterDelay(t *testing.T) {
+	store := newMemoryAutoCaptureStore()
+	clock := newFakeClock()
+	var captured []string
+	s := newTestScheduler(store, clock, func(_ context.Context, job AutoCaptureJob) error {
+		captured = append(captured, job.PaymentID)
+		return nil
+	})
+
+	captureAt, err := s.Schedule(context.Background(), testJob("pi_123"), 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if !captureAt.Equal(clock.now.Add(2 * time.Hour)) {
+		t.Errorf("Expected capture at %v, got %v", clock.now.Add(2*time.Hour), captureAt)
+	}
+
+	clock.Advance(time.Hour)
+	if n, _ := s.RunDue(context.Background()); n != 0 || len(captured) != 0 {
+		t.Fatalf("Capture must not fire before the delay, captured %v", captured)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := s.RunDue(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 capture, got %d (err %v)", n, err)
+	}
+	if len(captured) != 1 || captured[0] != "pi_123" {
+		t.Errorf("Expected pi_123 to be captured, got %v", captured)
+	}
+	if status := store.status(1); status != AutoCaptureStatusCaptured {
+		t.Errorf("Expected job status %q, got %q", AutoCaptureStatusCaptured, status)
+	}
+
+	// A captured job never fires twice
+	clock.Advance(time.Hour)
+	if n, _ := s.RunDue(context.Background()); n != 0 {
+		t.Errorf("Captured job fired again")
+	}
+}
+
+func TestAutoCaptureScheduler_CancelBeforeFiring(t *testing.T) {
+	store := newMemoryAutoCaptureStore()
+	clock := newFakeClock()
+	fired := false
+	s := newTestScheduler(store, clock, func(_ context.Context, _ AutoCaptureJob) error {
+		fired = true
+		return nil
+	})
+
+	if _, err := s.Schedule(context.Background(), testJob("pi_456"), 30*time.Minute); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	cancelled, err := s.Cancel(context.Background(), 1, "stripe", "pi_456")
+	if err != nil || !cancelled {
+		t.Fatalf("Expected pending capture to be cancelled, got %v (err %v)", cancelled, err)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := s.RunDue(context.Background()); err != nil {
+		t.Fatalf("RunDue failed: %v", err)
+	}
+	if fired {
+		t.Error("Cancelled capture must not fire")
+	}
+
+	// Cancelling again has nothing left to cancel
+	if cancelled, _ := s.Cancel(context.Background(), 1, "stripe", "pi_456"); cancelled {
+		t.Error("Expected second cancel to report nothing cancelled")
+	}
+}
+
+func TestAutoCaptureScheduler_CancelIsTenantScoped(t *testing.T) {
+	store := newMemoryAutoCaptureStore()
+	s := newTestScheduler(store, newFakeClock(), func(context.Context, AutoCaptureJob) error { return nil })
+
+	if _, err := s.Schedule(context.Background(), testJob("pi_789"), time.Minute); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if cancelled, _ := s.Cancel(context.Background(), 2, "stripe", "pi_789"); cancelled {
+		t.Error("Another tenant must not cancel the capture")
+	}
+}
+
+func TestAutoCaptureScheduler_RetriesThenFails(t *testing.T) {
+	store := newMemoryAutoCaptureStore()
+	clock := newFakeClock()
+	attempts := 0
+	s := newTestScheduler(store, clock, func(context.Context, AutoCaptureJob) error {
+		attempts++
+		return errors.New("provider unavailable")
+	})
+
+	if _, err := s.Schedule(context.Background(), testJob("pi_retry"), time.Minute); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	for range autoCaptureMaxAttempts {
+		clock.Advance(autoCaptureRetryDelay)
+		if _, err := s.RunDue(context.Background()); err != nil {
+			t.Fatalf("RunDue failed: %v", err)
+		}
+	}
+
+	if attempts != autoCaptureMaxAttempts {
+		t.Errorf("Expected %d attempts, got %d", autoCaptureMaxAttempts, attempts)
+	}
+	if status := store.status(1); status != AutoCaptureStatusFailed {
+		t.Errorf("Expected job status %q, got %q", AutoCaptureStatusFailed, status)
+	}
+}
+
+func TestAutoCaptureScheduler_MaxDelay(t *testing.T) {
+	t.Setenv("AUTO_CAPTURE_MAX_DELAY", "24h")
+	s := newTestScheduler(newMemoryAutoCaptureStore(), newFakeClock(), func(context.Context, AutoCaptureJob) error { return nil })
+
+	if s.MaxDelay() != 24*time.Hour {
+		t.Fatalf("Expected max delay 24h, got %v", s.MaxDelay())
+	}
+
+	tests := []struct {
+		value       string
+		expectError bool
+	}{
+		{"2h", false},
+		{"24h", false},
+		{"25h", true},
+		{"0s", true},
+		{"-1h", true},
+		{"tomorrow", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := s.ParseDelay(tt.value)
+			if tt.expectError {
+				if !errors.Is(err, ErrAutoCaptureDelayInvalid) {
+					t.Errorf("Expected ErrAutoCaptureDelayInvalid, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	if _, err := s.Schedule(context.Background(), testJob("pi_long"), 48*time.Hour); !errors.Is(err, ErrAutoCaptureDelayInvalid) {
+		t.Errorf("Expected delay over the maximum to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// ErrBasketTotalMismatch is returned when the basket items of a payment do not add up to its
+// amount. It points at an integration bug that would otherwise charge the wrong amount.
+var ErrBasketTotalMismatch = errors.New("basket total does not match amount")
+
+// BasketTotaler is an OPTIONAL capability interface implemented by providers that send basket
+// items. CreatePayment checks the basket against the payment amount with it; baskets of other
+// providers are not checked, their item prices may mean something else.
+type BasketTotaler interface {
+	// BasketTotal returns what the items add up to the way the provider reads their prices
+	BasketTotal(items []Item) float64
+}
+
+// BasketToleranceFromEnv reads BASKET_TOTAL_TOLERANCE, the difference between the basket total
+// and the amount that is still accepted, in major units. A provider specific value, e.g.
+// BASKET_TOTAL_TOLERANCE_IYZICO, takes precedence over the global one. The default is 0.
+func BasketToleranceFromEnv(providerName string) float64 {
+	for _, name := range []string{"BASKET_TOTAL_TOLERANCE_" + strings.ToUpper(providerName), "BASKET_TOTAL_TOLERANCE"} {
+		value := strings.TrimSpace(config.GetEnv(name, ""))
+		if value == "" {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return 0
+}
+
+// checkBasketTotal fails when the payment has basket items, the provider totals them and the
+// total is further from the amount than the tolerance
+func checkBasketTotal(provider PaymentProvider, providerName string, request PaymentRequest) error {
+	totaler, ok := provider.(BasketTotaler)
+	if !ok || len(request.Items) == 0 {
+		return nil
+	}
+
+	// Compared in cents so float sums like 0.1+0.2 match an amount of 0.30
+	cents := func(value float64) int64 { return int64(math.Round(value * 100)) }
+	total := totaler.BasketTotal(request.Items)
+	difference := cents(total) - cents(request.Amount)
+	if difference < 0 {
+		difference = -difference
+	}
+	if difference > cents(BasketToleranceFromEnv(providerName)) {
+		return fmt.Errorf("%w: items add up to %.2f, the amount is %.2f", ErrBasketTotalMismatch, total, request.Amount)
+	}
+	return nil
+}
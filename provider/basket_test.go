@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// basketProvider totals baskets as unit price times quantity
+type basketProvider struct {
+	amountRecordingProvider
+}
+
+func (p *basketProvider) BasketTotal(items []Item) float64 {
+	var total float64
+	for _, item := range items {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
+
+func TestPaymentService_CreatePayment_BasketTotal(t *testing.T) {
+	const tenantID, providerName = 9118, "baskettest"
+
+	fake := &basketProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9118")
+
+	request := riskRequest()
+	request.Amount = 0.6
+	request.Items = []Item{
+		{ID: "1", Name: "Pen", Price: 0.1, Quantity: 3},
+		{ID: "2", Name: "Eraser", Price: 0.3, Quantity: 1},
+	}
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Fatalf("expected a matching basket to be charged, got %v", err)
+	}
+
+	fake.charged = 0
+	request.Items[1].Quantity = 2
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); !errors.Is(err, ErrBasketTotalMismatch) {
+		t.Errorf("expected ErrBasketTotalMismatch, got %v", err)
+	}
+	if fake.charged != 0 {
+		t.Errorf("expected a mismatched basket not to reach the provider, charged %v", fake.charged)
+	}
+
+	t.Setenv("BASKET_TOTAL_TOLERANCE_BASKETTEST", "0.30")
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Errorf("expected a difference within the tolerance to be charged, got %v", err)
+	}
+
+	// Payments without a basket are not checked
+	request.Items = nil
+	request.Amount = 42
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Errorf("expected a payment without items to be charged, got %v", err)
+	}
+}
+
+func TestBasketToleranceFromEnv(t *testing.T) {
+	if got := BasketToleranceFromEnv("iyzico"); got != 0 {
+		t.Errorf("expected no tolerance by default, got %v", got)
+	}
+
+	t.Setenv("BASKET_TOTAL_TOLERANCE", "0.01")
+	t.Setenv("BASKET_TOTAL_TOLERANCE_OZANPAY", "0.05")
+	if got := BasketToleranceFromEnv("iyzico"); got != 0.01 {
+		t.Errorf("expected the global tolerance, got %v", got)
+	}
+	if got := BasketToleranceFromEnv("ozanpay"); got != 0.05 {
+		t.Errorf("expected the provider tolerance, got %v", got)
+	}
+
+	t.Setenv("BASKET_TOTAL_TOLERANCE_OZANPAY", "-1")
+	if got := BasketToleranceFromEnv("ozanpay"); got != 0.01 {
+		t.Errorf("expected a negative tolerance to be ignored, got %v", got)
+	}
+}
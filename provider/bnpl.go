@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Buy-now-pay-later payments are not made with a card: the customer is sent to the provider's
+// hosted page, picks a plan there and returns through the GoPay callback like after a 3D
+// challenge. The provider's Complete3DPayment places the order. A "sale" BNPL payment is
+// captured when the order is placed; an "auth" one is captured later with CapturePayment.
+
+// PaymentMethodTypeBNPL is the PaymentRequest.PaymentMethod, and PaymentMethodDetails.Type,
+// of buy-now-pay-later payments
+const PaymentMethodTypeBNPL = "bnpl"
+
+var (
+	// ErrBNPLPaymentInvalid is returned for a BNPL payment that cannot be made as sent
+	ErrBNPLPaymentInvalid = errors.New("invalid bnpl payment")
+	// ErrBNPLUnsupported is returned when a BNPL payment is sent to a provider that does not
+	// implement BNPLProvider
+	ErrBNPLUnsupported = errors.New("provider does not support buy now pay later")
+)
+
+// BNPLProvider is an OPTIONAL capability interface implemented by buy-now-pay-later providers.
+// Callers type-assert on it, like CaptureProvider.
+type BNPLProvider interface {
+	// CreateBNPLPayment starts a BNPL session and returns the provider's page in
+	// HostedCheckoutURL. The page returns to a GoPay callback URL created with
+	// CreateShortCallbackURL, which completes the payment with Complete3DPayment.
+	CreateBNPLPayment(ctx context.Context, request PaymentRequest) (*PaymentResponse, error)
+}
+
+// checkBNPLPayment checks a BNPL payment before it reaches provider
+func checkBNPLPayment(provider PaymentProvider, request PaymentRequest) error {
+	if !request.CardInfo.IsEmpty() || request.WalletPayment != nil {
+		return fmt.Errorf("%w: the customer picks the plan on the provider's page, send no cardInfo or walletPayment", ErrBNPLPaymentInvalid)
+	}
+	if request.CallbackURL == "" {
+		return fmt.Errorf("%w: callbackUrl is required", ErrBNPLPaymentInvalid)
+	}
+	if request.AutoCaptureAfter != "" {
+		return fmt.Errorf("%w: autoCaptureAfter is not supported, capture the payment with its order", ErrBNPLPaymentInvalid)
+	}
+
+	if _, ok := provider.(BNPLProvider); !ok {
+		return ErrBNPLUnsupported
+	}
+	if _, ok := provider.(CaptureProvider); !ok && request.PaymentType == PaymentTypeAuth {
+		return ErrCaptureUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// bnplTestProvider records BNPL sessions on top of captureTestProvider
+type bnplTestProvider struct {
+	captureTestProvider
+	sessions []PaymentRequest
+}
+
+func (p *bnplTestProvider) CreateBNPLPayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.sessions = append(p.sessions, request)
+	return &PaymentResponse{Success: true, Status: StatusPending, PaymentID: "bnpl_1", HostedCheckoutURL: "https://pay.example.com/hpp/1"}, nil
+}
+
+func bnplRequest() PaymentRequest {
+	request := riskRequest()
+	request.CardInfo = CardInfo{}
+	request.PaymentMethod = PaymentMethodTypeBNPL
+	request.CallbackURL = "https://merchant.example.com/return"
+	return request
+}
+
+func TestPaymentService_CreatePayment_BNPL(t *testing.T) {
+	const tenantID, providerName = 9151, "bnpltest"
+
+	fake := &bnplTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9151")
+
+	request := bnplRequest()
+	request.PaymentType = PaymentTypeAuth
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if len(fake.sessions) != 1 || fake.authorized != 0 || fake.sales != 0 {
+		t.Fatalf("Expected a BNPL session only, got %d sessions / %d authorizations / %d sales", len(fake.sessions), fake.authorized, fake.sales)
+	}
+	if fake.sessions[0].PaymentType != PaymentTypeAuth || fake.sessions[0].LogID == 0 {
+		t.Errorf("Expected the authorization request to reach the provider, got %+v", fake.sessions[0])
+	}
+	if resp.NextAction == nil || resp.NextAction.Type != NextActionRedirect || resp.NextAction.URL != "https://pay.example.com/hpp/1" {
+		t.Errorf("Expected a redirect to the provider's page, got %+v", resp.NextAction)
+	}
+}
+
+func TestPaymentService_CreatePayment_BNPLRejected(t *testing.T) {
+	const tenantID = 9152
+
+	GetProviderCache().Set(tenantID, "bnpltest", "sandbox", &bnplTestProvider{})
+	GetProviderCache().Set(tenantID, "cardonly", "sandbox", &captureTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "bnpltest", "sandbox")
+		GetProviderCache().Delete(tenantID, "cardonly", "sandbox")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9152")
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "cardonly", bnplRequest()); !errors.Is(err, ErrBNPLUnsupported) {
+		t.Errorf("Expected ErrBNPLUnsupported for a card provider, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*PaymentRequest)
+	}{
+		{"with card", func(r *PaymentRequest) { r.CardInfo = CardInfo{CardNumber: "5528790000000008"} }},
+		{"with wallet", func(r *PaymentRequest) { r.WalletPayment = &WalletPayment{Type: WalletTypeApplePay} }},
+		{"without callback", func(r *PaymentRequest) { r.CallbackURL = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := bnplRequest()
+			tt.modify(&request)
+			if _, err := service.CreatePayment(ctx, "sandbox", "bnpltest", request); !errors.Is(err, ErrBNPLPaymentInvalid) {
+				t.Errorf("Expected ErrBNPLPaymentInvalid, got %v", err)
+			}
+		})
+	}
+}
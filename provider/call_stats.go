@@ -0,0 +1,111 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// statusEndpoint is the log endpoint of GetPaymentStatus; status queries are not part of the
+// payment's own provider calls
+const statusEndpoint = "/payment/status"
+
+// ProviderCallStats summarizes the provider round-trips logged for a payment
+type ProviderCallStats struct {
+	// Operations is the number of logged gopay operations (payment, 3D completion, refund...)
+	Operations int `json:"operations"`
+	// RoundTrips is the number of provider requests recorded in those operations, e.g.
+	// Paycell's token, 3D session and provision calls
+	RoundTrips int `json:"roundTrips"`
+	// TotalProcessingMs is the summed processing time of the operations, which is dominated
+	// by the provider round-trips
+	TotalProcessingMs int64 `json:"totalProcessingMs"`
+}
+
+// PaymentCallLog is one logged operation of a payment
+type PaymentCallLog struct {
+	Endpoint     string
+	Request      map[string]any
+	ProcessingMs int64
+}
+
+// PaymentCallLogStore reads the logged operations of a payment
+type PaymentCallLogStore interface {
+	ListPaymentCallLogs(ctx context.Context, tenantID int, providerName, paymentID string) ([]PaymentCallLog, error)
+}
+
+// PostgresPaymentCallLogStore reads the provider log tables written by DBPaymentLogger.
+type PostgresPaymentCallLogStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPaymentCallLogStore creates a store over the shared *sql.DB connection.
+func NewPostgresPaymentCallLogStore(db *sql.DB) *PostgresPaymentCallLogStore {
+	return &PostgresPaymentCallLogStore{db: db}
+}
+
+// ListPaymentCallLogs returns the payment's log rows in the order they were written
+func (r *PostgresPaymentCallLogStore) ListPaymentCallLogs(ctx context.Context, tenantID int, providerName, paymentID string) ([]PaymentCallLog, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM providers WHERE name = $1)`, providerName).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check provider: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("invalid provider name: %s", providerName)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COALESCE(endpoint, ''), request, COALESCE(processing_ms, 0)
+		FROM %s
+		WHERE tenant_id = $1 AND payment_id = $2
+		ORDER BY id`, providerName)
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []PaymentCallLog
+	for rows.Next() {
+		var (
+			entry PaymentCallLog
+			raw   []byte
+		)
+		if err := rows.Scan(&entry.Endpoint, &raw, &entry.ProcessingMs); err != nil {
+			return nil, fmt.Errorf("failed to scan payment log: %w", err)
+		}
+		if raw != nil {
+			if err := json.Unmarshal(raw, &entry.Request); err != nil {
+				return nil, fmt.Errorf("failed to decode logged request: %w", err)
+			}
+		}
+		logs = append(logs, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment logs: %w", err)
+	}
+	return logs, nil
+}
+
+// aggregateProviderCalls counts the provider requests logged with AddProviderRequestToClientRequest.
+// Their keys end in "Request" (providerRequest, getThreeDSessionRequest...); repeated calls
+// under the same key within one operation overwrite each other and count once.
+func aggregateProviderCalls(logs []PaymentCallLog) ProviderCallStats {
+	var stats ProviderCallStats
+	for _, entry := range logs {
+		if entry.Endpoint == statusEndpoint {
+			continue
+		}
+		stats.Operations++
+		stats.TotalProcessingMs += entry.ProcessingMs
+		for key := range entry.Request {
+			if len(key) > len("Request") && strings.HasSuffix(key, "Request") {
+				stats.RoundTrips++
+			}
+		}
+	}
+	return stats
+}
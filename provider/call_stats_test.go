@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// paycell3DPaymentLogs are the log rows of a Paycell 3D payment followed by a status query
+func paycell3DPaymentLogs() []PaymentCallLog {
+	return []PaymentCallLog{
+		{
+			Endpoint: "/payment/3d",
+			Request: map[string]any{
+				"amount":                  100.5,
+				"cardTokenRequest":        map[string]any{"header": map[string]any{}},
+				"cardTokenResponse":       map[string]any{"cardToken": "***"},
+				"getThreeDSessionRequest": map[string]any{"amount": "10050"},
+				"cardToken":               "***",
+				"msisdn":                  "905551234567",
+			},
+			ProcessingMs: 640,
+		},
+		{
+			Endpoint: "/payment/3d/complete",
+			Request: map[string]any{
+				"paymentId":                      "pay_123",
+				"getThreeDSessionResultRequest":  map[string]any{},
+				"getThreeDSessionResultResponse": map[string]any{},
+				"providerProvisionRequest":       map[string]any{"referenceNumber": "ref"},
+				"providerProvisionResponse":      map[string]any{},
+				"providerInquireRequest":         map[string]any{},
+				"providerInquireResponse":        map[string]any{},
+			},
+			ProcessingMs: 1210,
+		},
+		{
+			Endpoint:     statusEndpoint,
+			Request:      map[string]any{"paymentId": "pay_123", "providerInquireRequest": map[string]any{}},
+			ProcessingMs: 300,
+		},
+	}
+}
+
+func TestAggregateProviderCalls(t *testing.T) {
+	stats := aggregateProviderCalls(paycell3DPaymentLogs())
+
+	expected := ProviderCallStats{Operations: 2, RoundTrips: 5, TotalProcessingMs: 1850}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+
+	if stats := aggregateProviderCalls(nil); stats != (ProviderCallStats{}) {
+		t.Errorf("Expected empty stats without logs, got %+v", stats)
+	}
+}
+
+type memoryPaymentCallLogStore struct {
+	logs []PaymentCallLog
+	err  error
+}
+
+func (s *memoryPaymentCallLogStore) ListPaymentCallLogs(context.Context, int, string, string) ([]PaymentCallLog, error) {
+	return s.logs, s.err
+}
+
+type statusTestProvider struct {
+	PaymentProvider
+}
+
+func (p *statusTestProvider) GetPaymentStatus(_ context.Context, request GetPaymentStatusRequest) (*PaymentResponse, error) {
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: request.PaymentID}, nil
+}
+
+func TestPaymentService_GetPaymentStatus_ProviderCalls(t *testing.T) {
+	const tenantID, providerName = 9106, "callstatstest"
+	GetProviderCache().Set(tenantID, providerName, "sandbox", &statusTestProvider{})
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9106")
+	store := &memoryPaymentCallLogStore{logs: paycell3DPaymentLogs()}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentCallLogStore(store)
+
+	resp, err := service.GetPaymentStatus(ctx, "sandbox", providerName, GetPaymentStatusRequest{PaymentID: "pay_123"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if resp.ProviderCalls != nil {
+		t.Errorf("Expected no provider calls without the debug flag, got %+v", resp.ProviderCalls)
+	}
+
+	resp, err = service.GetPaymentStatus(ctx, "sandbox", providerName, GetPaymentStatusRequest{PaymentID: "pay_123", IncludeProviderCalls: true})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if resp.ProviderCalls == nil || resp.ProviderCalls.RoundTrips != 5 || resp.ProviderCalls.TotalProcessingMs != 1850 {
+		t.Errorf("Expected 5 round-trips in 1850ms, got %+v", resp.ProviderCalls)
+	}
+
+	store.err = errors.New("database unavailable")
+	resp, err = service.GetPaymentStatus(ctx, "sandbox", providerName, GetPaymentStatusRequest{PaymentID: "pay_123", IncludeProviderCalls: true})
+	if err != nil || resp.ProviderCalls != nil {
+		t.Errorf("Expected status without provider calls when the logs cannot be read, got %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,344 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// CallbackStateTTL is how long a 3D payment's callback state can be used
+const CallbackStateTTL = 30 * time.Minute
+
+var (
+	// ErrCallbackStateNotFound is returned for an unknown callback state ID
+	ErrCallbackStateNotFound = errors.New("callback state not found")
+	// ErrCallbackStateExpired is returned for a callback state older than CallbackStateTTL
+	ErrCallbackStateExpired = errors.New("callback state expired")
+	// ErrCallbackStateUsed is returned when a callback state is used a second time
+	ErrCallbackStateUsed = errors.New("callback state already used")
+)
+
+// CallbackStateStore keeps the state of 3D payments between the redirect to the bank and the
+// provider's callback. IDs are integers, which is how HandleCallbackState tells them apart
+// from the legacy encrypted states.
+type CallbackStateStore interface {
+	// Create stores the state for CallbackStateTTL and returns its ID
+	Create(ctx context.Context, state CallbackState) (string, error)
+
+	// Get returns the state and marks it as used, so a callback cannot be replayed
+	Get(ctx context.Context, stateID string) (*CallbackState, error)
+
+	// Update sets the payment ID of a state, for providers that only return the payment's
+	// reference after the state was created
+	Update(ctx context.Context, stateID, paymentID string) error
+
+	// Cleanup removes expired states
+	Cleanup(ctx context.Context) error
+}
+
+var (
+	callbackStateStoreMu sync.RWMutex
+	callbackStateStore   CallbackStateStore
+)
+
+// SetCallbackStateStore sets the store used by CreateShortCallbackURL and HandleCallbackState
+func SetCallbackStateStore(store CallbackStateStore) {
+	callbackStateStoreMu.Lock()
+	defer callbackStateStoreMu.Unlock()
+	callbackStateStore = store
+}
+
+// getCallbackStateStore returns the configured store, or the PostgreSQL store over the
+// shared connection when none was set
+func getCallbackStateStore() (CallbackStateStore, error) {
+	callbackStateStoreMu.RLock()
+	store := callbackStateStore
+	callbackStateStoreMu.RUnlock()
+	if store != nil {
+		return store, nil
+	}
+
+	db := config.App().DB
+	if db == nil || db.DB == nil {
+		return nil, errors.New("database connection not available")
+	}
+	return NewPostgresCallbackStateStore(db.DB), nil
+}
+
+// NewCallbackStateStore creates the store selected by CALLBACK_STATE_STORE: "postgres"
+// (default) or "memory". The memory store keeps states in the process, so it only suits
+// single-instance deployments where the callback reaches the instance that started the payment.
+func NewCallbackStateStore(db *sql.DB) (CallbackStateStore, error) {
+	switch backend := strings.ToLower(strings.TrimSpace(config.GetEnv("CALLBACK_STATE_STORE", "postgres"))); backend {
+	case "postgres", "":
+		return NewPostgresCallbackStateStore(db), nil
+	case "memory":
+		return NewMemoryCallbackStateStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown CALLBACK_STATE_STORE %q, expected postgres or memory", backend)
+	}
+}
+
+// PostgresCallbackStateStore keeps callback states in the callbacks table.
+type PostgresCallbackStateStore struct {
+	db *sql.DB
+}
+
+// NewPostgresCallbackStateStore creates a store over the shared *sql.DB connection.
+func NewPostgresCallbackStateStore(db *sql.DB) *PostgresCallbackStateStore {
+	return &PostgresCallbackStateStore{db: db}
+}
+
+// Create inserts the state and returns the generated row ID
+func (r *PostgresCallbackStateStore) Create(ctx context.Context, state CallbackState) (string, error) {
+	// Validate tenant ID
+	if state.TenantID <= 0 {
+		return "", fmt.Errorf("invalid tenant ID: %d", state.TenantID)
+	}
+
+	// Serialize state data
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	expiresAt := time.Now().Add(CallbackStateTTL)
+
+	// Prepare nullable fields
+	var originalCallback sql.NullString
+	if state.OriginalCallback != "" {
+		originalCallback = sql.NullString{String: state.OriginalCallback, Valid: true}
+	}
+
+	var currency sql.NullString
+	if state.Currency != "" {
+		currency = sql.NullString{String: state.Currency, Valid: true}
+	}
+
+	var conversationID sql.NullString
+	if state.ConversationID != "" {
+		conversationID = sql.NullString{String: state.ConversationID, Valid: true}
+	}
+
+	var logID sql.NullInt64
+	if state.LogID > 0 {
+		logID = sql.NullInt64{Int64: state.LogID, Valid: true}
+	}
+
+	var installment sql.NullInt32
+	if state.Installment > 0 {
+		installment = sql.NullInt32{Int32: int32(state.Installment), Valid: true}
+	}
+
+	var sessionID sql.NullString
+	if state.SessionID != "" {
+		sessionID = sql.NullString{String: state.SessionID, Valid: true}
+	}
+
+	// Insert into database and get auto-generated ID
+	query := `
+		INSERT INTO callbacks (
+			tenant_id, provider, payment_id, original_callback, 
+			amount, currency, conversation_id, log_id, environment, 
+			client_ip, installment, session_id, state_data, expires_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`
+
+	var stateID int
+	err = r.db.QueryRowContext(ctx, query,
+		state.TenantID, state.Provider, state.PaymentID, originalCallback,
+		state.Amount, currency, conversationID, logID, state.Environment,
+		state.ClientIP, installment, sessionID, string(stateData), expiresAt,
+	).Scan(&stateID)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to store callback state (tenant_id: %d): %w", state.TenantID, err)
+	}
+
+	return fmt.Sprintf("%d", stateID), nil
+}
+
+// Get reads the state and marks its row as used
+func (r *PostgresCallbackStateStore) Get(ctx context.Context, stateID string) (*CallbackState, error) {
+	// Convert string ID to integer
+	id, err := strconv.Atoi(stateID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid callback state ID format: %w", err)
+	}
+
+	var stateData string
+	var used bool
+	var expiresAt time.Time
+	var sessionID sql.NullString
+
+	query := `
+		SELECT state_data, used, expires_at, session_id
+		FROM callbacks 
+		WHERE id = $1
+	`
+
+	err = r.db.QueryRowContext(ctx, query, id).Scan(&stateData, &used, &expiresAt, &sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCallbackStateNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve callback state: %w", err)
+	}
+
+	// Check if expired
+	if time.Now().After(expiresAt) {
+		return nil, ErrCallbackStateExpired
+	}
+
+	// Check if already used (optional security measure)
+	if used {
+		return nil, ErrCallbackStateUsed
+	}
+
+	// Mark as used (optional - prevents replay attacks)
+	_, err = r.db.ExecContext(ctx, "UPDATE callbacks SET used = true WHERE id = $1", id)
+	if err != nil {
+		// Log error but don't fail the callback
+		fmt.Printf("Warning: failed to mark callback state as used: %v\n", err)
+	}
+
+	// Deserialize state data
+	var state CallbackState
+	if err := json.Unmarshal([]byte(stateData), &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+
+	state.SessionID = sessionID.String
+
+	return &state, nil
+}
+
+// Update sets the payment ID column and the paymentId of the stored state
+func (r *PostgresCallbackStateStore) Update(ctx context.Context, stateID, paymentID string) error {
+	query := `
+		UPDATE callbacks 
+		SET payment_id = $1,
+		    state_data = jsonb_set(state_data::jsonb, '{paymentId}', to_jsonb($2::text))
+		WHERE id = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, paymentID, paymentID, stateID)
+	if err != nil {
+		return fmt.Errorf("failed to update callback state: %w", err)
+	}
+
+	return nil
+}
+
+// Cleanup deletes expired rows
+func (r *PostgresCallbackStateStore) Cleanup(ctx context.Context) error {
+	query := "DELETE FROM callbacks WHERE expires_at < NOW()"
+	_, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup expired callback states: %w", err)
+	}
+
+	return nil
+}
+
+// MemoryCallbackStateStore keeps callback states in the process. States are lost on restart,
+// which at worst fails the 3D payments in flight.
+type MemoryCallbackStateStore struct {
+	mu     sync.Mutex
+	states map[string]*memoryCallbackState
+	nextID int64
+	now    func() time.Time
+}
+
+type memoryCallbackState struct {
+	state     CallbackState
+	used      bool
+	expiresAt time.Time
+}
+
+// NewMemoryCallbackStateStore creates an empty in-memory store. IDs continue from the start
+// time in microseconds, so a callback for a state from before a restart is not mistaken for
+// a new state with the same ID.
+func NewMemoryCallbackStateStore() *MemoryCallbackStateStore {
+	return &MemoryCallbackStateStore{
+		states: make(map[string]*memoryCallbackState),
+		nextID: time.Now().UnixMicro(),
+		now:    time.Now,
+	}
+}
+
+// Create stores a copy of the state
+func (m *MemoryCallbackStateStore) Create(_ context.Context, state CallbackState) (string, error) {
+	if state.TenantID <= 0 {
+		return "", fmt.Errorf("invalid tenant ID: %d", state.TenantID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	stateID := strconv.FormatInt(m.nextID, 10)
+	m.states[stateID] = &memoryCallbackState{state: state, expiresAt: m.now().Add(CallbackStateTTL)}
+	return stateID, nil
+}
+
+// Get returns a copy of the state and marks it as used
+func (m *MemoryCallbackStateStore) Get(_ context.Context, stateID string) (*CallbackState, error) {
+	if _, err := strconv.ParseInt(stateID, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid callback state ID format: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.states[stateID]
+	if !ok {
+		return nil, ErrCallbackStateNotFound
+	}
+	if m.now().After(stored.expiresAt) {
+		return nil, ErrCallbackStateExpired
+	}
+	if stored.used {
+		return nil, ErrCallbackStateUsed
+	}
+
+	stored.used = true
+	state := stored.state
+	return &state, nil
+}
+
+// Update sets the payment ID of the state
+func (m *MemoryCallbackStateStore) Update(_ context.Context, stateID, paymentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.states[stateID]
+	if !ok {
+		return fmt.Errorf("failed to update callback state: %w", ErrCallbackStateNotFound)
+	}
+	stored.state.PaymentID = paymentID
+	return nil
+}
+
+// Cleanup drops expired states
+func (m *MemoryCallbackStateStore) Cleanup(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for stateID, stored := range m.states {
+		if now.After(stored.expiresAt) {
+			delete(m.states, stateID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func callbackTestState(tenantID int) CallbackState {
+	return CallbackState{
+		TenantID:         tenantID,
+		Provider:         "iyzico",
+		PaymentID:        "conv-1",
+		OriginalCallback: "https://merchant.example.com/callback",
+		Amount:           150.5,
+		Currency:         "TRY",
+		ConversationID:   "conv-1",
+		LogID:            42,
+		Environment:      "sandbox",
+		Timestamp:        time.Now(),
+		Installment:      3,
+		SessionID:        "session-1",
+	}
+}
+
+// testCallbackStateStore runs the behaviour every CallbackStateStore has to provide
+func testCallbackStateStore(t *testing.T, store CallbackStateStore, tenantID int) {
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		stateID, err := store.Create(ctx, callbackTestState(tenantID))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := strconv.Atoi(stateID); err != nil {
+			t.Fatalf("Expected an integer state ID, got %q", stateID)
+		}
+
+		state, err := store.Get(ctx, stateID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if state.TenantID != tenantID || state.Provider != "iyzico" || state.Amount != 150.5 ||
+			state.OriginalCallback != "https://merchant.example.com/callback" || state.Installment != 3 || state.SessionID != "session-1" {
+			t.Errorf("Unexpected state: %+v", state)
+		}
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		stateID, err := store.Create(ctx, callbackTestState(tenantID))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if _, err := store.Get(ctx, stateID); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if _, err := store.Get(ctx, stateID); !errors.Is(err, ErrCallbackStateUsed) {
+			t.Errorf("Expected ErrCallbackStateUsed, got %v", err)
+		}
+	})
+
+	t.Run("update payment ID", func(t *testing.T) {
+		stateID, err := store.Create(ctx, callbackTestState(tenantID))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := store.Update(ctx, stateID, "ref-123"); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		state, err := store.Get(ctx, stateID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if state.PaymentID != "ref-123" {
+			t.Errorf("Expected payment ID ref-123, got %s", state.PaymentID)
+		}
+	})
+
+	t.Run("unknown and invalid IDs", func(t *testing.T) {
+		if _, err := store.Get(ctx, "999999999"); !errors.Is(err, ErrCallbackStateNotFound) {
+			t.Errorf("Expected ErrCallbackStateNotFound, got %v", err)
+		}
+		if _, err := store.Get(ctx, "not-a-number"); err == nil {
+			t.Error("Expected an error for a non-numeric ID")
+		}
+	})
+
+	t.Run("invalid tenant", func(t *testing.T) {
+		if _, err := store.Create(ctx, callbackTestState(0)); err == nil {
+			t.Error("Expected an error for a missing tenant ID")
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		if err := store.Cleanup(ctx); err != nil {
+			t.Errorf("Cleanup failed: %v", err)
+		}
+	})
+}
+
+func TestMemoryCallbackStateStore(t *testing.T) {
+	testCallbackStateStore(t, NewMemoryCallbackStateStore(), 1)
+}
+
+func TestMemoryCallbackStateStore_Expiry(t *testing.T) {
+	store := NewMemoryCallbackStateStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	expiring, _ := store.Create(ctx, callbackTestState(1))
+	now = now.Add(CallbackStateTTL / 2)
+	fresh, _ := store.Create(ctx, callbackTestState(1))
+
+	now = now.Add(CallbackStateTTL/2 + time.Second)
+	if _, err := store.Get(ctx, expiring); !errors.Is(err, ErrCallbackStateExpired) {
+		t.Errorf("Expected ErrCallbackStateExpired, got %v", err)
+	}
+
+	if err := store.Cleanup(ctx); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := store.Get(ctx, expiring); !errors.Is(err, ErrCallbackStateNotFound) {
+		t.Errorf("Expected the expired state to be removed, got %v", err)
+	}
+	if _, err := store.Get(ctx, fresh); err != nil {
+		t.Errorf("Expected the fresh state to be kept, got %v", err)
+	}
+}
+
+func TestMemoryCallbackStateStore_IDsAreNotReusedAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	before, _ := NewMemoryCallbackStateStore().Create(ctx, callbackTestState(1))
+	time.Sleep(time.Millisecond)
+	after, _ := NewMemoryCallbackStateStore().Create(ctx, callbackTestState(1))
+
+	beforeID, _ := strconv.ParseInt(before, 10, 64)
+	afterID, _ := strconv.ParseInt(after, 10, 64)
+	if afterID <= beforeID {
+		t.Errorf("Expected a restarted store to continue after %d, got %d", beforeID, afterID)
+	}
+}
+
+// TestPostgresCallbackStateStore runs against the database in CALLBACK_STATE_TEST_DSN, a
+// database with the gopay.sql schema. CALLBACK_STATE_TEST_TENANT_ID (default 1) must exist.
+func TestPostgresCallbackStateStore(t *testing.T) {
+	dsn := os.Getenv("CALLBACK_STATE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("CALLBACK_STATE_TEST_DSN not set; skipping PostgreSQL callback state store test")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	tenantID := 1
+	if value := os.Getenv("CALLBACK_STATE_TEST_TENANT_ID"); value != "" {
+		if tenantID, err = strconv.Atoi(value); err != nil {
+			t.Fatalf("Invalid CALLBACK_STATE_TEST_TENANT_ID: %v", err)
+		}
+	}
+
+	testCallbackStateStore(t, NewPostgresCallbackStateStore(db), tenantID)
+}
+
+func TestNewCallbackStateStore(t *testing.T) {
+	t.Setenv("CALLBACK_STATE_STORE", "")
+	if store, err := NewCallbackStateStore(nil); err != nil {
+		t.Fatalf("NewCallbackStateStore failed: %v", err)
+	} else if _, ok := store.(*PostgresCallbackStateStore); !ok {
+		t.Errorf("Expected the PostgreSQL store by default, got %T", store)
+	}
+
+	t.Setenv("CALLBACK_STATE_STORE", "Memory")
+	if store, err := NewCallbackStateStore(nil); err != nil {
+		t.Fatalf("NewCallbackStateStore failed: %v", err)
+	} else if _, ok := store.(*MemoryCallbackStateStore); !ok {
+		t.Errorf("Expected the memory store, got %T", store)
+	}
+
+	t.Setenv("CALLBACK_STATE_STORE", "redis")
+	if _, err := NewCallbackStateStore(nil); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+func TestHandleCallbackState_UsesConfiguredStore(t *testing.T) {
+	store := NewMemoryCallbackStateStore()
+	SetCallbackStateStore(store)
+	t.Cleanup(func() { SetCallbackStateStore(nil) })
+
+	callbackURL, err := CreateShortCallbackURL(context.Background(), "https://gopay.example.com", "iyzico", callbackTestState(1))
+	if err != nil {
+		t.Fatalf("CreateShortCallbackURL failed: %v", err)
+	}
+
+	var stateID string
+	for id := range store.states {
+		stateID = id
+	}
+	if callbackURL != "https://gopay.example.com/v1/callback/iyzico?state="+stateID {
+		t.Errorf("Unexpected callback URL %s", callbackURL)
+	}
+
+	state, err := HandleCallbackState(context.Background(), stateID)
+	if err != nil {
+		t.Fatalf("HandleCallbackState failed: %v", err)
+	}
+	if state.ConversationID != "conv-1" {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+)
+
+// PaymentResponse.ErrorCode values of a cancel the provider refused for a known reason
+const (
+	ErrorCodeAlreadyCaptured  = "already_captured"
+	ErrorCodeAlreadyCancelled = "already_cancelled"
+	ErrorCodePaymentNotFound  = "payment_not_found"
+)
+
+var (
+	// ErrAlreadyCaptured is returned by CancelPayment when the payment was already settled.
+	// It can no longer be voided and has to be refunded instead.
+	ErrAlreadyCaptured = errors.New("payment is already captured, refund it instead")
+
+	// ErrAlreadyCancelled is returned by CancelPayment when the payment was already cancelled
+	ErrAlreadyCancelled = errors.New("payment is already cancelled")
+
+	// ErrPaymentNotFound is returned by CancelPayment when the provider has no such payment
+	ErrPaymentNotFound = errors.New("payment not found")
+)
+
+// cancelFailures lists the wordings providers use for each reason a cancel is refused.
+// Already cancelled comes first, since its messages often say the payment was not found
+// among the cancellable ones.
+var cancelFailures = []struct {
+	err     error
+	code    string
+	phrases []string
+}{
+	{ErrAlreadyCancelled, ErrorCodeAlreadyCancelled, []string{
+		"already cancelled",
+		"already canceled",
+		"already reversed",
+		"already voided",
+		"iptal edilmiş",
+		"iptal edilmis",
+		"zaten iptal",
+		"daha önce iptal",
+	}},
+	{ErrAlreadyCaptured, ErrorCodeAlreadyCaptured, []string{
+		"already captured",
+		"already settled",
+		"batch closed",
+		"end of day",
+		"gün sonu",
+		"günsonu",
+	}},
+	{ErrPaymentNotFound, ErrorCodePaymentNotFound, []string{
+		"not found",
+		"no such transaction",
+		"bulunamadı",
+		"bulunamadi",
+	}},
+}
+
+// CancelFailure returns the PaymentResponse.ErrorCode and error of a cancel the provider
+// refused with one of messages, or "" and nil when the reason is not recognized
+func CancelFailure(messages ...string) (string, error) {
+	for _, failure := range cancelFailures {
+		for _, message := range messages {
+			message = strings.ToLower(message)
+			for _, phrase := range failure.phrases {
+				if strings.Contains(message, phrase) {
+					return failure.code, failure.err
+				}
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCancelFailure(t *testing.T) {
+	tests := []struct {
+		message string
+		code    string
+		err     error
+	}{
+		{"Transaction already settled", ErrorCodeAlreadyCaptured, ErrAlreadyCaptured},
+		{"Gün sonu yapılmış işlem iptal edilemez", ErrorCodeAlreadyCaptured, ErrAlreadyCaptured},
+		{"Payment already cancelled", ErrorCodeAlreadyCancelled, ErrAlreadyCancelled},
+		{"İşlem daha önce iptal edilmiş", ErrorCodeAlreadyCancelled, ErrAlreadyCancelled},
+		{"Already reversed, original transaction not found", ErrorCodeAlreadyCancelled, ErrAlreadyCancelled},
+		{"Original transaction not found", ErrorCodePaymentNotFound, ErrPaymentNotFound},
+		{"İşlem bulunamadı", ErrorCodePaymentNotFound, ErrPaymentNotFound},
+		{"Insufficient funds", "", nil},
+		{"", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			code, err := CancelFailure(tt.message)
+			if code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, code)
+			}
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
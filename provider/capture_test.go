@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// captureTestProvider records authorize and capture calls
+type captureTestProvider struct {
+	PaymentProvider
+	sales      int
+	authorized int
+	captured   []CaptureRequest
+	captureErr error
+}
+
+func (p *captureTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *captureTestProvider) CreatePayment(context.Context, PaymentRequest) (*PaymentResponse, error) {
+	p.sales++
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_sale"}, nil
+}
+
+func (p *captureTestProvider) AuthorizePayment(context.Context, PaymentRequest) (*PaymentResponse, error) {
+	p.authorized++
+	return &PaymentResponse{Success: true, Status: StatusPending, PaymentID: "pay_auth"}, nil
+}
+
+func (p *captureTestProvider) CapturePayment(_ context.Context, request CaptureRequest) (*PaymentResponse, error) {
+	p.captured = append(p.captured, request)
+	if p.captureErr != nil {
+		return nil, p.captureErr
+	}
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: request.PaymentID, Amount: request.Amount}, nil
+}
+
+func TestPaymentService_AuthorizeThenCapture(t *testing.T) {
+	const tenantID, providerName = 9124, "capturetest"
+
+	fake := &captureTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &recordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9124")
+
+	request := riskRequest()
+	request.PaymentType = PaymentTypeAuth
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if fake.authorized != 1 || fake.sales != 0 {
+		t.Fatalf("Expected an authorization only, got %d authorizations / %d sales", fake.authorized, fake.sales)
+	}
+
+	resp, err = service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: resp.PaymentID, Amount: 60, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if len(fake.captured) != 1 || fake.captured[0].PaymentID != "pay_auth" || fake.captured[0].Amount != 60 {
+		t.Fatalf("Expected capture of pay_auth for 60, got %+v", fake.captured)
+	}
+	if !resp.Success || resp.Status != StatusSuccessful {
+		t.Errorf("Expected successful capture, got %+v", resp)
+	}
+	if n := len(paymentLogger.responses); n != 2 || paymentLogger.responses[1] != resp {
+		t.Errorf("Expected the capture response to be logged, got %d responses", n)
+	}
+}
+
+func TestPaymentService_CapturePayment_CancelsAutoCapture(t *testing.T) {
+	const tenantID, providerName = 9125, "capturetest"
+
+	fake := &captureTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := newMemoryAutoCaptureStore()
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetAutoCaptureScheduler(NewAutoCaptureScheduler(store, func(context.Context, AutoCaptureJob) error { return nil }))
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9125")
+
+	request := riskRequest()
+	request.AutoCaptureAfter = "2h"
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if _, err := service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth"}); err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if status := store.status(1); status != AutoCaptureStatusCancelled {
+		t.Errorf("Expected the scheduled capture to be cancelled, got %q", status)
+	}
+	if n, _ := service.autoCapture.RunDue(context.Background()); n != 0 {
+		t.Errorf("Manually captured payment was auto-captured again")
+	}
+}
+
+func TestPaymentService_CapturePayment_FailureKeepsAutoCapture(t *testing.T) {
+	const tenantID, providerName = 9127, "capturetest"
+
+	fake := &captureTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := newMemoryAutoCaptureStore()
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetAutoCaptureScheduler(NewAutoCaptureScheduler(store, func(context.Context, AutoCaptureJob) error { return nil }))
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9127")
+
+	request := riskRequest()
+	request.AutoCaptureAfter = "2h"
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	fake.captureErr = errors.New("provider unavailable")
+	if _, err := service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth"}); err == nil {
+		t.Fatal("Expected the failed capture to return an error")
+	}
+	if status := store.status(1); status != AutoCaptureStatusPending {
+		t.Errorf("Expected the scheduled capture to stay pending after a failed capture, got %q", status)
+	}
+}
+
+func TestPaymentService_Authorize_Unsupported(t *testing.T) {
+	const tenantID = 9126
+
+	GetProviderCache().Set(tenantID, "captureless", "sandbox", &riskTestProvider{})
+	GetProviderCache().Set(tenantID, "capturetest", "sandbox", &captureTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "captureless", "sandbox")
+		GetProviderCache().Delete(tenantID, "capturetest", "sandbox")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9126")
+
+	request := riskRequest()
+	request.PaymentType = PaymentTypeAuth
+	if _, err := service.CreatePayment(ctx, "sandbox", "captureless", request); !errors.Is(err, ErrCaptureUnsupported) {
+		t.Errorf("Expected ErrCaptureUnsupported for a provider without capture, got %v", err)
+	}
+	if _, err := service.CapturePayment(ctx, "sandbox", "captureless", CaptureRequest{PaymentID: "pay_1"}); !errors.Is(err, ErrCaptureUnsupported) {
+		t.Errorf("Expected ErrCaptureUnsupported on capture, got %v", err)
+	}
+
+	request.Use3D = true
+	if _, err := service.CreatePayment(ctx, "sandbox", "capturetest", request); !errors.Is(err, ErrCaptureUnsupported) {
+		t.Errorf("Expected ErrCaptureUnsupported for a 3D authorization, got %v", err)
+	}
+}
+
+// capturableLogger knows the authorized amount of one payment and subtracts the captures
+// it logs, like DBPaymentLogger does from the log table
+type capturableLogger struct {
+	recordingPaymentLogger
+	authorized float64
+	captured   []CaptureRequest
+}
+
+func (l *capturableLogger) LogRequest(ctx context.Context, tenantID int, providerName, method, endpoint string, request any, userAgent, clientIP string) (int64, error) {
+	if capture, ok := request.(CaptureRequest); ok {
+		l.captured = append(l.captured, capture)
+	}
+	return l.recordingPaymentLogger.LogRequest(ctx, tenantID, providerName, method, endpoint, request, userAgent, clientIP)
+}
+
+func (l *capturableLogger) CapturableAmount(context.Context, int, string, string) (float64, string, error) {
+	remaining := l.authorized
+	for _, capture := range l.captured {
+		remaining -= capture.Amount
+	}
+	return remaining, "TRY", nil
+}
+
+func TestPaymentService_PartialCapture(t *testing.T) {
+	const tenantID, providerName = 9127, "capturetest"
+
+	fake := &captureTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &capturableLogger{authorized: 100}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9127")
+
+	resp, err := service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth", Amount: 60})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if resp.Capture == nil || resp.Capture.Captured != 60 || resp.Capture.Remaining != 40 {
+		t.Fatalf("Expected 60 captured and 40 remaining, got %+v", resp.Capture)
+	}
+	if fake.captured[0].Currency != "TRY" {
+		t.Errorf("Expected the payment currency from the logs, got %q", fake.captured[0].Currency)
+	}
+
+	_, err = service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth", Amount: 40.01})
+	var exceeds *CaptureExceedsAuthorizedError
+	if !errors.As(err, &exceeds) || !errors.Is(err, ErrCaptureExceedsAuthorized) {
+		t.Fatalf("Expected CaptureExceedsAuthorizedError, got %v", err)
+	}
+	if exceeds.Requested != 40.01 || exceeds.Remaining != 40 || exceeds.Currency != "TRY" {
+		t.Errorf("Unexpected over-capture details %+v", exceeds)
+	}
+	if len(fake.captured) != 1 {
+		t.Fatalf("Over-capture must not reach the provider, got %d captures", len(fake.captured))
+	}
+
+	// No amount captures the rest of the authorization, and the log records it
+	resp, err = service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if fake.captured[1].Amount != 40 || paymentLogger.captured[1].Amount != 40 {
+		t.Errorf("Expected the remaining 40 to be captured and logged, got %+v", fake.captured[1])
+	}
+	if resp.Capture == nil || resp.Capture.Remaining != 0 {
+		t.Errorf("Expected nothing remaining, got %+v", resp.Capture)
+	}
+
+	if _, err := service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: "pay_auth"}); !errors.Is(err, ErrAlreadyCaptured) {
+		t.Errorf("Expected ErrAlreadyCaptured once fully captured, got %v", err)
+	}
+}
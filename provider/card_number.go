@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Card numbers are 12 to 19 digits (ISO/IEC 7812)
+const (
+	minPANLength = 12
+	maxPANLength = 19
+)
+
+// ErrInvalidCardNumber is returned when a card number is not 12 to 19 digits or fails the
+// Luhn check
+var ErrInvalidCardNumber = errors.New("invalid card number")
+
+// panSeparators are the characters customers type between card number groups
+var panSeparators = strings.NewReplacer(" ", "", "-", "")
+
+// NormalizePAN strips the spaces and dashes of a formatted card number, e.g.
+// "5528 7900-0000 0008" to "5528790000000008". It does not validate the result.
+func NormalizePAN(cardNumber string) string {
+	return panSeparators.Replace(strings.TrimSpace(cardNumber))
+}
+
+// SanitizePAN normalizes a card number and checks its length and Luhn check digit, so a
+// mistyped number is rejected before it is sent to a provider. The payment service applies
+// it to every card payment, and providers get the number without separators.
+func SanitizePAN(cardNumber string) (string, error) {
+	pan := NormalizePAN(cardNumber)
+	if len(pan) < minPANLength || len(pan) > maxPANLength {
+		return "", fmt.Errorf("%w: must be %d to %d digits", ErrInvalidCardNumber, minPANLength, maxPANLength)
+	}
+	for _, c := range pan {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("%w: must contain only digits, spaces and dashes", ErrInvalidCardNumber)
+		}
+	}
+	if !luhnValid(pan) {
+		return "", fmt.Errorf("%w: check digit does not match", ErrInvalidCardNumber)
+	}
+	return pan, nil
+}
+
+// luhnValid reports whether a string of digits passes the Luhn check
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestSanitizePAN(t *testing.T) {
+	tests := []struct {
+		name       string
+		cardNumber string
+		want       string
+	}{
+		{"plain", "5528790000000008", "5528790000000008"},
+		{"spaces", "5528 7900 0000 0008", "5528790000000008"},
+		{"dashes", "4111-1111-1111-1111", "4111111111111111"},
+		{"mixed with padding", " 4242 4242-4242 4242 ", "4242424242424242"},
+		{"amex length", "3782 822463 10005", "378282246310005"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizePAN(tt.cardNumber)
+			if err != nil {
+				t.Fatalf("SanitizePAN failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitizePAN_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"luhn mismatch": "5528790000000009",
+		"transposed":    "4111111111111121",
+		"too short":     "42424242424",
+		"too long":      "42424242424242424242",
+		"letters":       "4242x42424242424",
+		"empty":         "",
+	}
+	for name, cardNumber := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := SanitizePAN(cardNumber); !errors.Is(err, ErrInvalidCardNumber) {
+				t.Errorf("expected ErrInvalidCardNumber, got %v", err)
+			}
+		})
+	}
+}
+
+// cardRecordingProvider remembers the card number it was sent
+type cardRecordingProvider struct {
+	PaymentProvider
+	cardNumber string
+}
+
+func (p *cardRecordingProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *cardRecordingProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.cardNumber = request.CardInfo.CardNumber
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, Amount: request.Amount, Currency: request.Currency}, nil
+}
+
+func TestPaymentService_CreatePayment_SanitizesCardNumber(t *testing.T) {
+	const tenantID, providerName = 9114, "cardnumbertest"
+
+	fake := &cardRecordingProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &recordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9114")
+
+	request := riskRequest()
+	request.CardInfo.CardNumber = "5528 7900-0000 0008"
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if fake.cardNumber != "5528790000000008" {
+		t.Errorf("expected the provider to get the card number without separators, got %q", fake.cardNumber)
+	}
+
+	fake.cardNumber = ""
+	request.CardInfo.CardNumber = "5528 7900 0000 0009"
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); !errors.Is(err, ErrInvalidCardNumber) {
+		t.Errorf("expected ErrInvalidCardNumber, got %v", err)
+	}
+	if fake.cardNumber != "" || paymentLogger.requests != 1 {
+		t.Errorf("expected the invalid card to be rejected before it is logged or sent, got %d logged requests", paymentLogger.requests)
+	}
+}
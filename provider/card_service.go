@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
 	"time"
 
@@ -117,6 +118,9 @@ func (s *CardService) RegisterCard(ctx context.Context, environment, providerNam
 	if err != nil {
 		return nil, nil, err
 	}
+	if request.Card.CardNumber, err = SanitizePAN(request.Card.CardNumber); err != nil {
+		return nil, nil, err
+	}
 	cs, err := getCardStorageProvider(tenantID, providerName, environment)
 	if err != nil {
 		return nil, nil, err
@@ -203,6 +207,37 @@ func (s *CardService) PaySavedCard(ctx context.Context, environment, providerNam
 	if err != nil {
 		return nil, err
 	}
+	return s.paySavedCard(ctx, tenantID, environment, providerName, cardRowID, request, use3D)
+}
+
+// ChargeStoredCard charges a saved card without 3D secure for the subscription scheduler. It
+// runs outside of a request, so the tenant is passed in instead of read from the JWT context.
+func (s *CardService) ChargeStoredCard(ctx context.Context, tenantID int, environment, providerName string, cardRowID int, request SavedCardPaymentRequest) (*PaymentResponse, error) {
+	return s.paySavedCard(ctx, tenantID, environment, providerName, cardRowID, request, false)
+}
+
+// SubscribableCard returns the tenant's saved card when it was saved with providerName in
+// environment, and the provider still supports card storage
+func (s *CardService) SubscribableCard(ctx context.Context, tenantID int, environment, providerName string, cardRowID int) (*SavedCard, error) {
+	card, err := s.repo.GetByID(ctx, tenantID, cardRowID)
+	if err != nil {
+		return nil, err
+	}
+	providerID, err := s.providerConfig.GetProviderIDByName(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if card.ProviderID != providerID || card.Environment != environment {
+		return nil, ErrSavedCardNotFound
+	}
+	if _, err := getCardStorageProvider(tenantID, providerName, environment); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// paySavedCard charges a saved card of the tenant by GoPay row id
+func (s *CardService) paySavedCard(ctx context.Context, tenantID int, environment, providerName string, cardRowID int, request SavedCardPaymentRequest, use3D bool) (*PaymentResponse, error) {
 	// Tenant-scoped lookup is the IDOR/BOLA guard: a tenant can only charge its own card.
 	card, err := s.repo.GetByID(ctx, tenantID, cardRowID)
 	if err != nil {
@@ -234,9 +269,47 @@ func (s *CardService) PaySavedCard(ctx context.Context, environment, providerNam
 	} else {
 		resp, err = cs.PayWithSavedCard(ctx, request)
 	}
+	if errors.Is(err, ErrResponseUnparseable) {
+		resp, err = unknownStateResponse(providerName, "", request.Amount, request.Currency, err), nil
+	}
 	if resp != nil {
 		resp.SessionID = request.SessionID
+		resp.SubscriptionID = request.SubscriptionID
+		resp.Outcome = ResolveOutcome(resp)
+		resp.ChallengeForm = challengeFormOf(resp)
+		resp.NextAction = ResolveNextAction(resp)
+		if !resp.Success {
+			if code := CardUpdateDecline(providerName, resp.ErrorCode, resp.Message); code != "" {
+				resp.ErrorCode = code
+				resp.CardUpdateRequired = true
+			}
+		}
 	}
 	s.finishLog(ctx, logID, start, resp, err)
+	s.recordCardFailure(ctx, card, resp)
 	return resp, err
 }
+
+// recordCardFailure stores a card update decline against the saved card, and clears it once
+// the card is charged again
+func (s *CardService) recordCardFailure(ctx context.Context, card *SavedCard, resp *PaymentResponse) {
+	var reason string
+	switch {
+	case resp == nil:
+		return
+	case resp.CardUpdateRequired:
+		reason = resp.ErrorCode
+	case !resp.Success || card.LastFailureReason == "":
+		return
+	}
+
+	if err := s.repo.SetFailureReason(ctx, card.TenantID, card.ID, reason); err != nil {
+		logger.Warn("Failed to update saved card failure reason", logger.LogContext{
+			TenantID: strconv.Itoa(card.TenantID),
+			Fields: map[string]any{
+				"card_id": card.ID,
+				"error":   err.Error(),
+			},
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitCooldown         = 30 * time.Second
+)
+
+// CircuitBreaker tracks provider errors per tenant, provider and environment. After
+// PROVIDER_CIRCUIT_FAILURE_THRESHOLD consecutive errors (default 5) the circuit opens for
+// PROVIDER_CIRCUIT_COOLDOWN (Go duration, default 30s). Once the cooldown has passed the
+// next payment is let through: a success closes the circuit, an error opens it again.
+//
+// Only errors count, a declined payment is a working provider. State is kept in memory, so
+// with several instances every instance has its own circuits.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*circuitState
+	now      func() time.Time
+}
+
+type circuitState struct {
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker creates the breaker from the PROVIDER_CIRCUIT_* environment variables
+func NewCircuitBreaker() *CircuitBreaker {
+	b := &CircuitBreaker{
+		threshold: config.GetIntEnv("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", defaultCircuitFailureThreshold),
+		cooldown:  defaultCircuitCooldown,
+		circuits:  make(map[string]*circuitState),
+		now:       time.Now,
+	}
+	if b.threshold <= 0 {
+		b.threshold = defaultCircuitFailureThreshold
+	}
+
+	if value := config.GetEnv("PROVIDER_CIRCUIT_COOLDOWN", ""); value != "" {
+		if cooldown, err := time.ParseDuration(value); err == nil && cooldown > 0 {
+			b.cooldown = cooldown
+		} else {
+			logger.Warn("Ignoring invalid PROVIDER_CIRCUIT_COOLDOWN", logger.LogContext{
+				Fields: map[string]any{"value": value},
+			})
+		}
+	}
+	return b
+}
+
+func circuitKey(tenantID int, providerName, environment string) string {
+	return fmt.Sprintf("%d:%s:%s", tenantID, providerName, environment)
+}
+
+// Open reports whether payments to the provider are currently held back
+func (b *CircuitBreaker) Open(tenantID int, providerName, environment string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.circuits[circuitKey(tenantID, providerName, environment)]
+	if !ok || state.failures < b.threshold {
+		return false
+	}
+	return b.now().Before(state.openedAt.Add(b.cooldown))
+}
+
+// RecordSuccess closes the provider's circuit
+func (b *CircuitBreaker) RecordSuccess(tenantID int, providerName, environment string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, circuitKey(tenantID, providerName, environment))
+}
+
+// RecordFailure counts a provider error and opens the circuit once the threshold is reached
+func (b *CircuitBreaker) RecordFailure(tenantID int, providerName, environment string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := circuitKey(tenantID, providerName, environment)
+	state, ok := b.circuits[key]
+	if !ok {
+		state = &circuitState{}
+		b.circuits[key] = state
+	}
+	state.failures++
+	if state.failures >= b.threshold {
+		state.openedAt = b.now()
+	}
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestCircuitBreaker(t *testing.T, threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	t.Setenv("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", "")
+	t.Setenv("PROVIDER_CIRCUIT_COOLDOWN", "")
+	b := NewCircuitBreaker()
+	b.threshold = threshold
+	b.cooldown = cooldown
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestNewCircuitBreaker_Config(t *testing.T) {
+	t.Setenv("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", "")
+	t.Setenv("PROVIDER_CIRCUIT_COOLDOWN", "")
+	b := NewCircuitBreaker()
+	if b.threshold != defaultCircuitFailureThreshold || b.cooldown != defaultCircuitCooldown {
+		t.Errorf("Expected defaults, got threshold=%d cooldown=%s", b.threshold, b.cooldown)
+	}
+
+	t.Setenv("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", "3")
+	t.Setenv("PROVIDER_CIRCUIT_COOLDOWN", "1m")
+	b = NewCircuitBreaker()
+	if b.threshold != 3 || b.cooldown != time.Minute {
+		t.Errorf("Expected threshold=3 cooldown=1m, got threshold=%d cooldown=%s", b.threshold, b.cooldown)
+	}
+
+	t.Setenv("PROVIDER_CIRCUIT_FAILURE_THRESHOLD", "0")
+	t.Setenv("PROVIDER_CIRCUIT_COOLDOWN", "soon")
+	b = NewCircuitBreaker()
+	if b.threshold != defaultCircuitFailureThreshold || b.cooldown != defaultCircuitCooldown {
+		t.Errorf("Expected defaults for invalid values, got threshold=%d cooldown=%s", b.threshold, b.cooldown)
+	}
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b, now := newTestCircuitBreaker(t, 3, time.Minute)
+
+	b.RecordFailure(1, "iyzico", "sandbox")
+	b.RecordFailure(1, "iyzico", "sandbox")
+	if b.Open(1, "iyzico", "sandbox") {
+		t.Fatal("Expected the circuit to stay closed below the threshold")
+	}
+
+	b.RecordFailure(1, "iyzico", "sandbox")
+	if !b.Open(1, "iyzico", "sandbox") {
+		t.Fatal("Expected the circuit to open at the threshold")
+	}
+	if b.Open(2, "iyzico", "sandbox") || b.Open(1, "paycell", "sandbox") || b.Open(1, "iyzico", "production") {
+		t.Error("Expected other tenants, providers and environments to be unaffected")
+	}
+
+	// After the cooldown one payment is let through, and another failure opens the circuit again
+	*now = now.Add(time.Minute)
+	if b.Open(1, "iyzico", "sandbox") {
+		t.Fatal("Expected the circuit to let a payment through after the cooldown")
+	}
+	b.RecordFailure(1, "iyzico", "sandbox")
+	if !b.Open(1, "iyzico", "sandbox") {
+		t.Fatal("Expected a failure after the cooldown to open the circuit again")
+	}
+
+	*now = now.Add(time.Minute)
+	b.RecordSuccess(1, "iyzico", "sandbox")
+	b.RecordFailure(1, "iyzico", "sandbox")
+	if b.Open(1, "iyzico", "sandbox") {
+		t.Error("Expected a success to reset the failure count")
+	}
+}
+
+func TestCircuitBreaker_Nil(t *testing.T) {
+	var b *CircuitBreaker
+	b.RecordFailure(1, "iyzico", "sandbox")
+	b.RecordSuccess(1, "iyzico", "sandbox")
+	if b.Open(1, "iyzico", "sandbox") {
+		t.Error("Expected a nil breaker to never open")
+	}
+}
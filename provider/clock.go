@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// ClockOffset returns the configured clock offset for a provider, read from the
+// <PROVIDER>_CLOCK_OFFSET environment variable as a Go duration (e.g. "1500ms", "-2s").
+//
+// Some providers sign requests with a local timestamp and reject them when it drifts
+// from their own clock. The offset is added to time.Now() wherever such a timestamp is
+// generated, so the drift can be corrected without touching the host clock.
+// A missing or unparsable value yields zero.
+func ClockOffset(providerName string) time.Duration {
+	key := strings.ToUpper(providerName) + "_CLOCK_OFFSET"
+	value := strings.TrimSpace(config.GetEnv(key, ""))
+	if value == "" {
+		return 0
+	}
+
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warn("Ignoring invalid provider clock offset", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"env":   key,
+				"value": value,
+				"error": err.Error(),
+			},
+		})
+		return 0
+	}
+	return offset
+}
+
+// TurkeyTimeZone is the zone Turkish providers report their timestamps in. Turkey has
+// stayed on UTC+3 without daylight saving since 2016.
+var TurkeyTimeZone = time.FixedZone("TRT", 3*60*60)
+
+// ParseProviderTime parses a timestamp reported by a provider using the first matching
+// layout. Layouts without a zone are read in loc. Empty or unparsable values yield nil, so
+// a provider changing its format never fails a payment.
+func ParseProviderTime(value string, loc *time.Location, layouts ...string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
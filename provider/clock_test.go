@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockOffset(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "unset", value: "", expected: 0},
+		{name: "positive", value: "1500ms", expected: 1500 * time.Millisecond},
+		{name: "negative", value: "-2s", expected: -2 * time.Second},
+		{name: "invalid", value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ZIRAAT_CLOCK_OFFSET", tt.value)
+
+			if got := ClockOffset("ziraat"); got != tt.expected {
+				t.Errorf("ClockOffset() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseProviderTime(t *testing.T) {
+	const layout = "2006-01-02 15:04:05"
+
+	got := ParseProviderTime("2024-01-15 10:30:00", TurkeyTimeZone, "20060102", layout)
+	if got == nil || !got.Equal(time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected Turkey time to be parsed with the matching layout, got %v", got)
+	}
+
+	// A zone in the value wins over loc
+	got = ParseProviderTime("2024-01-15T10:30:00Z", TurkeyTimeZone, time.RFC3339)
+	if got == nil || !got.Equal(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected RFC3339 value to keep its zone, got %v", got)
+	}
+
+	for _, value := range []string{"", "   ", "not a time"} {
+		if got := ParseProviderTime(value, TurkeyTimeZone, layout); got != nil {
+			t.Errorf("Expected nil for %q, got %v", value, got)
+		}
+	}
+}
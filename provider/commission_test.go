@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestCommissionRequest_InstallmentCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  CommissionRequest
+		expected []int
+	}{
+		{"single count", CommissionRequest{InstallmentCount: 3}, []int{3}},
+		{"range", CommissionRequest{InstallmentCount: 2, MaxInstallmentCount: 6}, []int{2, 3, 4, 5, 6}},
+		{"max equals count", CommissionRequest{InstallmentCount: 4, MaxInstallmentCount: 4}, []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.request.InstallmentCounts(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPaymentService_GetCommission_InvalidMaxInstallmentCount(t *testing.T) {
+	const tenantID, providerName = 9108, "commissiontest"
+	GetProviderCache().Set(tenantID, providerName, "sandbox", &riskTestProvider{})
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9108")
+
+	for _, maxInstallmentCount := range []int{2, MaxCommissionInstallmentCount + 1} {
+		request := CommissionRequest{BinValue: "552879", InstallmentCount: 3, MaxInstallmentCount: maxInstallmentCount, Amount: 100}
+		if _, err := service.GetCommission(ctx, "sandbox", providerName, request); err == nil {
+			t.Errorf("Expected error for max installment count %d", maxInstallmentCount)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package craftgate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// This file implements the optional provider.CardStorageProvider capability for Craftgate's
+// stored cards. Craftgate groups the cards of a customer under a cardUserKey and needs both
+// keys to charge a card, so the provider card id GoPay saves is "cardUserKey:cardToken".
+// Craftgate does not verify the customer with an OTP; the referenceNumber of a register or
+// list request carries the cardUserKey instead.
+
+// Ensure CraftgateProvider satisfies the optional capability interface.
+var _ provider.CardStorageProvider = (*CraftgateProvider)(nil)
+
+// errNoOTP is returned by the OTP calls, which Craftgate's card storage does not use
+var errNoOTP = errors.New("craftgate: card storage does not use OTP verification")
+
+// SendCardOTP is not used by Craftgate
+func (p *CraftgateProvider) SendCardOTP(ctx context.Context, request provider.CardOTPSendRequest) (*provider.CardOTPSendResponse, error) {
+	return nil, errNoOTP
+}
+
+// ValidateCardOTP is not used by Craftgate
+func (p *CraftgateProvider) ValidateCardOTP(ctx context.Context, request provider.CardOTPValidateRequest) (*provider.CardOTPValidateResponse, error) {
+	return nil, errNoOTP
+}
+
+// RegisterCard stores a card. A referenceNumber adds it to the cards of that cardUserKey;
+// otherwise Craftgate creates a new cardUserKey.
+func (p *CraftgateProvider) RegisterCard(ctx context.Context, request provider.RegisterCardRequest) (*provider.RegisterCardResponse, error) {
+	if request.Card.CardNumber == "" || request.Card.ExpireMonth == "" || request.Card.ExpireYear == "" {
+		return nil, errors.New("craftgate: card number and expiration month and year are required")
+	}
+
+	storeReq := storeCardRequest{
+		CardUserKey:    request.ReferenceNumber,
+		CardAlias:      request.Alias,
+		CardHolderName: strings.TrimSpace(request.Card.CardHolderName),
+		CardNumber:     provider.NormalizePAN(request.Card.CardNumber),
+		ExpireYear:     fullYear(request.Card.ExpireYear),
+		ExpireMonth:    request.Card.ExpireMonth,
+	}
+
+	var result storedCard
+	errs, err := p.send(ctx, http.MethodPost, endpointStoredCards, storeReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs != nil {
+		return &provider.RegisterCardResponse{
+			Success:          false,
+			ErrorCode:        errs.ErrorCode,
+			Message:          errs.ErrorDescription,
+			ProviderResponse: errs,
+		}, nil
+	}
+
+	return &provider.RegisterCardResponse{
+		Success:          true,
+		ProviderCardID:   providerCardID(result.CardUserKey, result.CardToken),
+		MaskedCardNo:     result.masked(),
+		CardBrand:        result.CardAssociation,
+		CardType:         result.CardType,
+		Message:          "Card stored",
+		ProviderResponse: result,
+	}, nil
+}
+
+// ListProviderCards lists the stored cards of the cardUserKey in referenceNumber
+func (p *CraftgateProvider) ListProviderCards(ctx context.Context, request provider.ListCardsRequest) (*provider.ListCardsResponse, error) {
+	if request.ReferenceNumber == "" {
+		return nil, errors.New("craftgate: referenceNumber must be the cardUserKey of the cards")
+	}
+
+	var result storedCardList
+	endpoint := endpointStoredCards + "?" + url.Values{"cardUserKey": {request.ReferenceNumber}}.Encode()
+	errs, err := p.send(ctx, http.MethodGet, endpoint, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs != nil {
+		return &provider.ListCardsResponse{
+			Success:          false,
+			ErrorCode:        errs.ErrorCode,
+			Message:          errs.ErrorDescription,
+			ProviderResponse: errs,
+		}, nil
+	}
+
+	cards := make([]provider.ProviderCard, 0, len(result.Items))
+	for _, item := range result.Items {
+		cards = append(cards, provider.ProviderCard{
+			ProviderCardID: providerCardID(item.CardUserKey, item.CardToken),
+			MaskedCardNo:   item.masked(),
+			CardBrand:      item.CardAssociation,
+			CardType:       item.CardType,
+			Alias:          item.CardAlias,
+		})
+	}
+
+	return &provider.ListCardsResponse{
+		Success:          true,
+		Cards:            cards,
+		ProviderResponse: result,
+	}, nil
+}
+
+// DeleteProviderCard deletes a stored card
+func (p *CraftgateProvider) DeleteProviderCard(ctx context.Context, request provider.DeleteCardRequest) (*provider.DeleteCardResponse, error) {
+	cardUserKey, cardToken, err := splitProviderCardID(request.ProviderCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := p.send(ctx, http.MethodPost, endpointDeleteStoredCard, card{CardUserKey: cardUserKey, CardToken: cardToken}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs != nil {
+		return &provider.DeleteCardResponse{
+			Success:          false,
+			ErrorCode:        errs.ErrorCode,
+			Message:          errs.ErrorDescription,
+			ProviderResponse: errs,
+		}, nil
+	}
+	return &provider.DeleteCardResponse{Success: true, Message: "Card deleted"}, nil
+}
+
+// PayWithSavedCard charges a stored card without 3D secure
+func (p *CraftgateProvider) PayWithSavedCard(ctx context.Context, request provider.SavedCardPaymentRequest) (*provider.PaymentResponse, error) {
+	paymentReq, err := p.buildSavedCardPaymentRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodPost, endpointPayment, paymentReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(&result, errs)
+	response.Amount = request.Amount
+	response.Currency = request.Currency
+	return response, nil
+}
+
+// Create3DPaymentWithSavedCard starts a 3D payment with a stored card. Completion reuses
+// Complete3DPayment.
+func (p *CraftgateProvider) Create3DPaymentWithSavedCard(ctx context.Context, request provider.SavedCardPaymentRequest) (*provider.PaymentResponse, error) {
+	if request.CallbackURL == "" {
+		return nil, errors.New("craftgate: callback URL is required for 3D secure payments")
+	}
+
+	paymentReq, err := p.buildSavedCardPaymentRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.init3DS(ctx, paymentReq, provider.CallbackState{
+		TenantID:         request.TenantID,
+		PaymentID:        paymentReq.ConversationID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "craftgate",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	})
+}
+
+// buildSavedCardPaymentRequest builds the payment of a stored card
+func (p *CraftgateProvider) buildSavedCardPaymentRequest(request provider.SavedCardPaymentRequest) (*paymentRequest, error) {
+	if request.Amount <= 0 {
+		return nil, errors.New("craftgate: amount must be greater than 0")
+	}
+
+	cardUserKey, cardToken, err := splitProviderCardID(request.ProviderCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	conversationID := request.ConversationID
+	if conversationID == "" {
+		conversationID = p.generateConversationId()
+	}
+
+	paymentReq := buildPaymentRequest(request.Amount, request.Currency, request.InstallmentCount, conversationID, nil, "")
+	paymentReq.Card = &card{CardUserKey: cardUserKey, CardToken: cardToken}
+	return paymentReq, nil
+}
+
+// providerCardID returns the provider card id GoPay saves for a stored card
+func providerCardID(cardUserKey, cardToken string) string {
+	return cardUserKey + ":" + cardToken
+}
+
+// splitProviderCardID returns the cardUserKey and cardToken of a provider card id
+func splitProviderCardID(id string) (string, string, error) {
+	cardUserKey, cardToken, ok := strings.Cut(id, ":")
+	if !ok || cardUserKey == "" || cardToken == "" {
+		return "", "", fmt.Errorf("craftgate: invalid provider card id %q", id)
+	}
+	return cardUserKey, cardToken, nil
+}
+
+// storeCardRequest is the request of a card to store
+type storeCardRequest struct {
+	CardUserKey    string `json:"cardUserKey,omitempty"`
+	CardAlias      string `json:"cardAlias,omitempty"`
+	CardHolderName string `json:"cardHolderName,omitempty"`
+	CardNumber     string `json:"cardNumber"`
+	ExpireYear     string `json:"expireYear"`
+	ExpireMonth    string `json:"expireMonth"`
+}
+
+// storedCard is a card stored at Craftgate
+type storedCard struct {
+	CardUserKey     string `json:"cardUserKey"`
+	CardToken       string `json:"cardToken"`
+	CardAlias       string `json:"cardAlias"`
+	BinNumber       string `json:"binNumber"`
+	LastFourDigits  string `json:"lastFourDigits"`
+	CardHolderName  string `json:"cardHolderName"`
+	ExpireYear      string `json:"expireYear"`
+	ExpireMonth     string `json:"expireMonth"`
+	CardType        string `json:"cardType"`
+	CardAssociation string `json:"cardAssociation"`
+	CardBrand       string `json:"cardBrand"`
+	CardBankName    string `json:"cardBankName"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+// masked returns the card number with its middle digits masked
+func (c storedCard) masked() string {
+	if c.BinNumber == "" && c.LastFourDigits == "" {
+		return ""
+	}
+	return c.BinNumber + strings.Repeat("*", max(16-len(c.BinNumber)-len(c.LastFourDigits), 0)) + c.LastFourDigits
+}
+
+// storedCardList is a page of stored cards
+type storedCardList struct {
+	Page      int          `json:"page"`
+	Size      int          `json:"size"`
+	TotalSize int          `json:"totalSize"`
+	Items     []storedCard `json:"items"`
+}
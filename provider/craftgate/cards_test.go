@@ -0,0 +1,125 @@
+package craftgate
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+func storedCardData(cardToken string) map[string]any {
+	return map[string]any{
+		"cardUserKey":     "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b",
+		"cardToken":       cardToken,
+		"cardAlias":       "My card",
+		"binNumber":       "552879",
+		"lastFourDigits":  "0008",
+		"cardHolderName":  "John Doe",
+		"expireYear":      "2030",
+		"expireMonth":     "12",
+		"cardType":        "CREDIT_CARD",
+		"cardAssociation": "MASTER_CARD",
+		"cardBrand":       "Paraf",
+		"cardBankName":    "Halkbank",
+		"createdAt":       "2024-01-15T10:30:00",
+	}
+}
+
+func TestCraftgateProvider_RegisterCard(t *testing.T) {
+	var sent map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointStoredCards || r.Method != http.MethodPost {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		sent = body
+		return http.StatusOK, map[string]any{"data": storedCardData("tok-1")}
+	})
+
+	response, err := p.RegisterCard(context.Background(), provider.RegisterCardRequest{
+		MSISDN:          "5551234567",
+		Alias:           "My card",
+		ReferenceNumber: "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b",
+		Card:            provider.CardInfo{CardHolderName: "John Doe", CardNumber: "5528790000000008", ExpireMonth: "12", ExpireYear: "30"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterCard failed: %v", err)
+	}
+	if sent["cardUserKey"] != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b" || sent["expireYear"] != "2030" || sent["cardAlias"] != "My card" {
+		t.Errorf("Unexpected store card request %v", sent)
+	}
+	if !response.Success || response.ProviderCardID != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b:tok-1" || response.MaskedCardNo != "552879******0008" || response.CardBrand != "MASTER_CARD" {
+		t.Errorf("Unexpected register response: %+v", response)
+	}
+}
+
+func TestCraftgateProvider_ListAndDeleteCards(t *testing.T) {
+	var deleted map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == endpointStoredCards:
+			if r.URL.Query().Get("cardUserKey") != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b" {
+				t.Errorf("Unexpected list query %s", r.URL.RawQuery)
+			}
+			return http.StatusOK, map[string]any{"data": map[string]any{"page": 0, "size": 10, "totalSize": 2, "items": []any{storedCardData("tok-1"), storedCardData("tok-2")}}}
+		case r.URL.Path == endpointDeleteStoredCard:
+			deleted = body
+			return http.StatusOK, nil
+		}
+		t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+	ctx := context.Background()
+
+	list, err := p.ListProviderCards(ctx, provider.ListCardsRequest{ReferenceNumber: "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b"})
+	if err != nil {
+		t.Fatalf("ListProviderCards failed: %v", err)
+	}
+	if !list.Success || len(list.Cards) != 2 || list.Cards[1].ProviderCardID != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b:tok-2" || list.Cards[0].Alias != "My card" {
+		t.Errorf("Unexpected card list: %+v", list)
+	}
+	if _, err := p.ListProviderCards(ctx, provider.ListCardsRequest{MSISDN: "5551234567"}); err == nil {
+		t.Error("Expected an error without a cardUserKey")
+	}
+
+	response, err := p.DeleteProviderCard(ctx, provider.DeleteCardRequest{ProviderCardID: "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b:tok-1"})
+	if err != nil {
+		t.Fatalf("DeleteProviderCard failed: %v", err)
+	}
+	if !response.Success || deleted["cardUserKey"] != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b" || deleted["cardToken"] != "tok-1" {
+		t.Errorf("Unexpected delete %+v of %v", response, deleted)
+	}
+	if _, err := p.DeleteProviderCard(ctx, provider.DeleteCardRequest{ProviderCardID: "tok-1"}); err == nil {
+		t.Error("Expected an error for a provider card id without cardUserKey")
+	}
+}
+
+func TestCraftgateProvider_PayWithSavedCard(t *testing.T) {
+	var sent map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		sent = body
+		return http.StatusOK, map[string]any{"data": paymentData(paymentStatusSuccess, nil)}
+	})
+
+	response, err := p.PayWithSavedCard(context.Background(), provider.SavedCardPaymentRequest{
+		ProviderCardID: "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b:tok-1",
+		Amount:         100.50,
+		Currency:       "TRY",
+		ConversationID: "sub_1-2",
+	})
+	if err != nil {
+		t.Fatalf("PayWithSavedCard failed: %v", err)
+	}
+
+	card := sent["card"].(map[string]any)
+	if len(card) != 2 || card["cardUserKey"] != "de050ef7-3f5e-4a2c-9d9c-5f2d4a0fdc0b" || card["cardToken"] != "tok-1" || sent["conversationId"] != "sub_1-2" {
+		t.Errorf("Unexpected stored card payment %v", sent)
+	}
+	if !response.Success || response.PaymentID != "101" {
+		t.Errorf("Unexpected payment response: %+v", response)
+	}
+
+	if _, err := p.SendCardOTP(context.Background(), provider.CardOTPSendRequest{MSISDN: "5551234567"}); err == nil {
+		t.Error("Expected OTP to be unsupported")
+	}
+}
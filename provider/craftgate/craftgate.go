@@ -0,0 +1,767 @@
+package craftgate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API URLs
+	apiSandboxURL    = "https://sandbox-api.craftgate.io"
+	apiProductionURL = "https://api.craftgate.io"
+
+	// API Endpoints
+	endpointPayment          = "/payment/v1/card-payments"
+	endpointInit3DS          = "/payment/v1/card-payments/3ds-init"
+	endpointComplete3DS      = "/payment/v1/card-payments/3ds-complete"
+	endpointRefund           = "/payment/v1/refunds"
+	endpointStoredCards      = "/payment/v1/cards"
+	endpointDeleteStoredCard = "/payment/v1/cards/delete"
+
+	// Payment statuses
+	paymentStatusSuccess         = "SUCCESS"
+	paymentStatusFailure         = "FAILURE"
+	paymentStatusInitThreeDS     = "INIT_THREEDS"
+	paymentStatusCallbackThreeDS = "CALLBACK_THREEDS"
+
+	// Refund statuses of a payment
+	refundStatusPartial = "PARTIAL_REFUNDED"
+	refundStatusFull    = "FULLY_REFUNDED"
+
+	// Default currency
+	defaultCurrency = "TRY"
+
+	// webhookSignatureHeader carries the HMAC of a webhook
+	webhookSignatureHeader = "X-Cg-Signature-V1"
+)
+
+// CraftgateProvider implements the provider.PaymentProvider interface for Craftgate
+type CraftgateProvider struct {
+	apiKey       string
+	secretKey    string
+	webhookKey   string
+	baseURL      string
+	gopayBaseURL string
+	isProduction bool
+	httpClient   *provider.ProviderHTTPClient
+}
+
+// NewProvider creates a new Craftgate payment provider
+func NewProvider() provider.PaymentProvider {
+	return &CraftgateProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Craftgate
+func (p *CraftgateProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "apiKey",
+			Required:    true,
+			Type:        "string",
+			Description: "Craftgate API key from the merchant panel",
+			Example:     "sandbox-YEhueLgomBjqsnvBlWVVuFsVhlvJlMHE",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "secretKey",
+			Required:    true,
+			Type:        "string",
+			Description: "Craftgate secret key from the merchant panel",
+			Example:     "sandbox-tBdcdKVGmGupzfaWcULcwDLMoglZZvTz",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "webhookKey",
+			Required:    false,
+			Type:        "string",
+			Description: "Merchant webhook key that signs Craftgate webhooks (required to accept webhooks)",
+			Example:     "merchant-webhook-key",
+			MaxLength:   100,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Craftgate requirements
+func (p *CraftgateProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("craftgate", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies accepted by Craftgate
+func (p *CraftgateProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR", "GBP"}
+}
+
+// HealthCheckEndpoint returns the card payment endpoint, which rejects unsigned requests
+func (p *CraftgateProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointPayment
+}
+
+// Initialize sets up the Craftgate payment provider with authentication credentials
+func (p *CraftgateProvider) Initialize(conf map[string]string) error {
+	p.apiKey = conf["apiKey"]
+	p.secretKey = conf["secretKey"]
+	p.webhookKey = conf["webhookKey"]
+
+	if p.apiKey == "" || p.secretKey == "" {
+		return errors.New("craftgate: apiKey and secretKey are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiSandboxURL
+	if p.isProduction {
+		p.baseURL = apiProductionURL
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("craftgate"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *CraftgateProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *CraftgateProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment makes a non-3D card payment
+func (p *CraftgateProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		return nil, fmt.Errorf("craftgate: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	paymentReq := buildPaymentRequest(request.Amount, request.Currency, request.InstallmentCount, p.generateConversationId(), request.Items, request.Description)
+	paymentReq.Card = cardOf(request)
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodPost, endpointPayment, paymentReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(&result, errs)
+	response.Amount = request.Amount
+	response.Currency = request.Currency
+	return response, nil
+}
+
+// Create3DPayment starts a 3D payment with 3ds-init. Craftgate returns the bank's page and
+// posts the result of the challenge to GoPay.
+func (p *CraftgateProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, true); err != nil {
+		return nil, fmt.Errorf("craftgate: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	conversationID := p.generateConversationId()
+	paymentReq := buildPaymentRequest(request.Amount, request.Currency, request.InstallmentCount, conversationID, request.Items, request.Description)
+	paymentReq.Card = cardOf(request)
+
+	return p.init3DS(ctx, paymentReq, provider.CallbackState{
+		TenantID:         int(request.TenantID),
+		PaymentID:        conversationID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "craftgate",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	})
+}
+
+// init3DS sends a 3ds-init with a GoPay callback for state. The state carries the payment's
+// conversationId, which Complete3DPayment checks the completed payment against.
+func (p *CraftgateProvider) init3DS(ctx context.Context, paymentReq *paymentRequest, state provider.CallbackState) (*provider.PaymentResponse, error) {
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "craftgate", state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+	paymentReq.CallbackURL = gopayCallbackURL
+
+	var result init3DSResponse
+	errs, err := p.send(ctx, http.MethodPost, endpointInit3DS, paymentReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        strconv.FormatInt(result.PaymentID, 10),
+		Amount:           state.Amount,
+		Currency:         state.Currency,
+		SystemTime:       &now,
+		ProviderResponse: result,
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.ErrorCode
+		response.Message = errs.ErrorDescription
+		return response, nil
+	}
+
+	html, err := base64.StdEncoding.DecodeString(result.HTMLContent)
+	if err != nil || len(html) == 0 {
+		return nil, errors.New("craftgate: 3ds-init returned no HTML content")
+	}
+
+	response.Success = true
+	response.Status = provider.StatusPending
+	response.HTML = string(html)
+	response.Message = "3D Secure authentication required"
+	return response, nil
+}
+
+// Complete3DPayment completes a 3D payment after the callback. Craftgate's own answer to
+// 3ds-complete decides the result, so the callback only supplies the paymentId.
+func (p *CraftgateProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("craftgate: no callback data received")
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("craftgate", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	paymentID, err := strconv.ParseInt(data["paymentId"], 10, 64)
+	if err != nil {
+		return nil, errors.New("craftgate: missing paymentId in callback")
+	}
+	if conversationID := data["conversationId"]; conversationID != "" && conversationID != callbackState.PaymentID {
+		return nil, errors.New("craftgate: callback does not belong to the payment")
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        data["paymentId"],
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	// The bank did not authenticate the cardholder, so there is nothing to complete
+	if data["status"] != paymentStatusSuccess {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = data["status"]
+		response.Message = "3D authentication failed"
+		if data["errorMessage"] != "" {
+			response.Message = data["errorMessage"]
+		}
+		if provider.Is3DSessionExpiredMessage(data["errorMessage"]) {
+			response.ErrorCode = provider.ErrorCode3DSessionExpired
+			return response, fmt.Errorf("craftgate: %w", provider.Err3DSessionExpired)
+		}
+		if provider.Is3DSChallengeCancelledMessage(data["errorMessage"]) {
+			provider.MarkThreeDSCancelled(response)
+		}
+		return response, nil
+	}
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodPost, endpointComplete3DS, map[string]int64{"paymentId": paymentID}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if errs == nil && (result.ConversationID != callbackState.PaymentID || !sameAmount(result.PaidPrice, callbackState.Amount)) {
+		return nil, errors.New("craftgate: completed payment does not match the callback state")
+	}
+
+	completed := paymentResponse(&result, errs)
+	completed.PaymentID = response.PaymentID
+	completed.Amount = callbackState.Amount
+	completed.Currency = callbackState.Currency
+	completed.RedirectURL = callbackState.OriginalCallback
+	if completed.Success {
+		completed.Message = "3D payment completed successfully"
+	}
+	return completed, nil
+}
+
+// GetPaymentStatus retrieves the current status of a payment
+func (p *CraftgateProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("craftgate: paymentID is required")
+	}
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodGet, endpointPayment+"/"+url.PathEscape(request.PaymentID), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(&result, errs)
+	response.PaymentID = request.PaymentID
+	if errs != nil {
+		if code, err := provider.CancelFailure(errs.ErrorDescription); errors.Is(err, provider.ErrPaymentNotFound) {
+			response.ErrorCode = code
+			return response, fmt.Errorf("craftgate: %w", err)
+		}
+		return response, nil
+	}
+
+	response.Amount = result.PaidPrice
+	response.Currency = result.Currency
+	switch result.RefundStatus {
+	case refundStatusFull, refundStatusPartial:
+		if result.PaymentStatus == paymentStatusSuccess {
+			response.Status = provider.StatusRefunded
+		}
+	}
+	return response, nil
+}
+
+// CancelPayment is not supported by Craftgate: a refund on the day of the payment cancels it
+func (p *CraftgateProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("craftgate: cancel is not supported, use a refund")
+}
+
+// RefundPayment issues a full or partial refund for a payment
+func (p *CraftgateProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("craftgate: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("craftgate: refund amount must be greater than 0")
+	}
+
+	paymentID, err := strconv.ParseInt(request.PaymentID, 10, 64)
+	if err != nil {
+		return nil, errors.New("craftgate: paymentID must be a Craftgate payment id")
+	}
+
+	refundReq := refundRequest{
+		PaymentID:             paymentID,
+		RefundAmount:          request.RefundAmount,
+		RefundDestinationType: "PROVIDER",
+		ConversationID:        p.generateConversationId(),
+	}
+
+	var result refund
+	errs, err := p.send(ctx, http.MethodPost, endpointRefund, refundReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	success := errs == nil && result.Status == paymentStatusSuccess
+
+	refundResp := &provider.RefundResponse{
+		Success:      success,
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+		RawResponse:  result,
+	}
+
+	switch {
+	case success:
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+		refundResp.RefundID = strconv.FormatInt(result.ID, 10)
+	case errs != nil:
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = errs.ErrorCode
+		refundResp.Message = errs.ErrorDescription
+	default:
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = result.Status
+		refundResp.Message = "Refund failed"
+	}
+
+	return refundResp, nil
+}
+
+// ValidateWebhook checks the X-CG-SIGNATURE-V1 of a webhook, Base64(HMAC-SHA256(webhookKey,
+// eventType + eventTimestamp + status + payloadId)). The payloadId of a payment event is the
+// Craftgate payment id.
+func (p *CraftgateProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	if p.webhookKey == "" {
+		return false, nil, errors.New("craftgate: webhookKey is not configured")
+	}
+
+	signature := headers[webhookSignatureHeader]
+	if signature == "" {
+		return false, nil, errors.New("craftgate: missing signature header")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(p.webhookSignature(data)), []byte(signature)) != 1 {
+		return false, nil, errors.New("craftgate: invalid signature")
+	}
+
+	return true, map[string]string{
+		"paymentId": data["payloadId"],
+		"status":    strings.ToLower(data["status"]),
+		"eventType": data["eventType"],
+	}, nil
+}
+
+// webhookSignature calculates the signature of a webhook
+func (p *CraftgateProvider) webhookSignature(data map[string]string) string {
+	mac := hmac.New(sha256.New, []byte(p.webhookKey))
+	mac.Write([]byte(data["eventType"] + data["eventTimestamp"] + data["status"] + data["payloadId"]))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validatePaymentRequest validates the payment request
+func (p *CraftgateProvider) validatePaymentRequest(request provider.PaymentRequest, is3D bool) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("CVV is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiration month and year are required")
+	}
+
+	if is3D && request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// send signs and sends a request, decoding the data of a successful response into result.
+// A request Craftgate rejects returns its errors instead of an error.
+func (p *CraftgateProvider) send(ctx context.Context, method, endpoint string, body any, result any) (*apiError, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("craftgate: failed to encode request: %w", err)
+		}
+	}
+
+	randomKey, err := randomString()
+	if err != nil {
+		return nil, fmt.Errorf("craftgate: %w", err)
+	}
+
+	httpReq := &provider.HTTPRequest{
+		Method:   method,
+		Endpoint: endpoint,
+		Headers: map[string]string{
+			"Content-Type":   "application/json",
+			"x-api-key":      p.apiKey,
+			"x-rnd-key":      randomKey,
+			"x-auth-version": "v1",
+			"x-signature":    p.signature(p.baseURL+endpoint, randomKey, string(payload)),
+		},
+	}
+	if payload != nil {
+		httpReq.Body = payload
+	}
+
+	resp, err := p.httpClient.SendRaw(ctx, httpReq)
+	if err != nil && resp == nil {
+		return nil, fmt.Errorf("craftgate: request failed: %w", err)
+	}
+
+	if err == nil && len(resp.Body) == 0 {
+		return nil, nil
+	}
+
+	var envelope response
+	if parseErr := p.httpClient.ParseJSONResponse(resp, &envelope); parseErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("craftgate: request failed: %w", err)
+		}
+		return nil, fmt.Errorf("craftgate: %w", parseErr)
+	}
+	if envelope.Errors != nil {
+		return envelope.Errors, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("craftgate: request failed: %w", err)
+	}
+
+	if result != nil && len(envelope.Data) > 0 {
+		if err := p.httpClient.ParseJSONResponse(&provider.HTTPResponse{Body: envelope.Data}, result); err != nil {
+			return nil, fmt.Errorf("craftgate: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+// signature calculates x-signature, Base64(SHA256(url + apiKey + secretKey + randomKey + body))
+func (p *CraftgateProvider) signature(requestURL, randomKey, body string) string {
+	if decoded, err := url.QueryUnescape(requestURL); err == nil {
+		requestURL = decoded
+	}
+	sum := sha256.Sum256([]byte(requestURL + p.apiKey + p.secretKey + randomKey + body))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// paymentResponse maps a payment, or the errors Craftgate rejected it with
+func paymentResponse(result *payment, errs *apiError) *provider.PaymentResponse {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		SystemTime:       &now,
+		ProviderResponse: result,
+	}
+
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.ErrorCode
+		response.Message = errs.ErrorDescription
+		response.ProviderResponse = errs
+		return response
+	}
+
+	response.PaymentID = strconv.FormatInt(result.ID, 10)
+	response.OrderID = result.ConversationID
+	response.ProviderTime = provider.ParseProviderTime(result.CreatedDate, provider.TurkeyTimeZone, "2006-01-02T15:04:05", "2006-01-02T15:04:05.999999999")
+
+	switch result.PaymentStatus {
+	case paymentStatusSuccess:
+		response.Success = true
+		response.Status = provider.StatusSuccessful
+		response.Message = "Payment successful"
+	case paymentStatusInitThreeDS, paymentStatusCallbackThreeDS:
+		response.Status = provider.StatusPending
+		response.Message = "3D Secure authentication pending"
+	case paymentStatusFailure:
+		response.Status = provider.StatusFailed
+		response.Message = "Payment failed"
+	default:
+		response.Status = provider.StatusUnknown
+	}
+
+	if result.PaymentError != nil && !response.Success {
+		response.ErrorCode = result.PaymentError.ErrorCode
+		response.Message = result.PaymentError.ErrorDescription
+	}
+	return response
+}
+
+// buildPaymentRequest builds a payment without its card. Craftgate requires the items to add up
+// to the price, so a payment without matching items is sent as a single item.
+func buildPaymentRequest(amount float64, currency string, installment int, conversationID string, items []provider.Item, description string) *paymentRequest {
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	if description == "" {
+		description = "Payment"
+	}
+
+	var basket []paymentItem
+	var sum float64
+	for _, i := range items {
+		price := i.Price * float64(max(i.Quantity, 1))
+		basket = append(basket, paymentItem{Name: i.Name, Price: price, ExternalID: i.ID})
+		sum += price
+	}
+	if len(basket) == 0 || !sameAmount(sum, amount) {
+		basket = []paymentItem{{Name: description, Price: amount, ExternalID: conversationID}}
+	}
+
+	return &paymentRequest{
+		Price:          amount,
+		PaidPrice:      amount,
+		WalletPrice:    0,
+		Installment:    max(installment, 1),
+		Currency:       currency,
+		ConversationID: conversationID,
+		PaymentGroup:   "PRODUCT",
+		PaymentPhase:   "AUTH",
+		Items:          basket,
+	}
+}
+
+// cardOf returns the card of a payment request
+func cardOf(request provider.PaymentRequest) *card {
+	holder := strings.TrimSpace(request.CardInfo.CardHolderName)
+	if holder == "" {
+		holder = strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+	}
+	return &card{
+		CardHolderName: holder,
+		CardNumber:     provider.NormalizePAN(request.CardInfo.CardNumber),
+		ExpireYear:     fullYear(request.CardInfo.ExpireYear),
+		ExpireMonth:    request.CardInfo.ExpireMonth,
+		Cvc:            request.CardInfo.CVV,
+	}
+}
+
+// sameAmount reports whether two amounts are equal to the cent
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
+// fullYear returns a card expiry year with four digits
+func fullYear(year string) string {
+	if len(year) == 2 {
+		return "20" + year
+	}
+	return year
+}
+
+// randomString returns a random x-rnd-key
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generateConversationId generates a unique conversation ID for a request
+func (p *CraftgateProvider) generateConversationId() string {
+	suffix, err := randomString()
+	if err != nil {
+		return fmt.Sprintf("GP%d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("GP%d%s", time.Now().Unix(), strings.ToUpper(suffix[:8]))
+}
+
+// response is the envelope of every Craftgate response
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors *apiError       `json:"errors"`
+}
+
+// apiError is the error of a rejected request
+type apiError struct {
+	ErrorCode        string `json:"errorCode"`
+	ErrorDescription string `json:"errorDescription"`
+	ErrorGroup       string `json:"errorGroup"`
+}
+
+// card is the card of a payment: the card details, or a stored card's cardUserKey and cardToken
+type card struct {
+	CardHolderName string `json:"cardHolderName,omitempty"`
+	CardNumber     string `json:"cardNumber,omitempty"`
+	ExpireYear     string `json:"expireYear,omitempty"`
+	ExpireMonth    string `json:"expireMonth,omitempty"`
+	Cvc            string `json:"cvc,omitempty"`
+	CardUserKey    string `json:"cardUserKey,omitempty"`
+	CardToken      string `json:"cardToken,omitempty"`
+}
+
+// paymentItem is a basket item of a payment
+type paymentItem struct {
+	Name       string  `json:"name"`
+	Price      float64 `json:"price"`
+	ExternalID string  `json:"externalId,omitempty"`
+}
+
+// paymentRequest is the request of a card payment and of 3ds-init
+type paymentRequest struct {
+	Price          float64       `json:"price"`
+	PaidPrice      float64       `json:"paidPrice"`
+	WalletPrice    float64       `json:"walletPrice"`
+	Installment    int           `json:"installment"`
+	Currency       string        `json:"currency"`
+	ConversationID string        `json:"conversationId"`
+	PaymentGroup   string        `json:"paymentGroup"`
+	PaymentPhase   string        `json:"paymentPhase"`
+	CallbackURL    string        `json:"callbackUrl,omitempty"`
+	Card           *card         `json:"card"`
+	Items          []paymentItem `json:"items"`
+}
+
+// payment is a Craftgate card payment
+type payment struct {
+	ID              int64     `json:"id"`
+	CreatedDate     string    `json:"createdDate"`
+	Price           float64   `json:"price"`
+	PaidPrice       float64   `json:"paidPrice"`
+	Currency        string    `json:"currency"`
+	Installment     int       `json:"installment"`
+	ConversationID  string    `json:"conversationId"`
+	PaymentType     string    `json:"paymentType"`
+	PaymentStatus   string    `json:"paymentStatus"`
+	PaymentPhase    string    `json:"paymentPhase"`
+	IsThreeDS       bool      `json:"isThreeDS"`
+	BinNumber       string    `json:"binNumber"`
+	LastFourDigits  string    `json:"lastFourDigits"`
+	CardType        string    `json:"cardType"`
+	CardAssociation string    `json:"cardAssociation"`
+	CardBrand       string    `json:"cardBrand"`
+	CardUserKey     string    `json:"cardUserKey"`
+	CardToken       string    `json:"cardToken"`
+	RefundablePrice float64   `json:"refundablePrice"`
+	RefundStatus    string    `json:"refundStatus"`
+	PaymentError    *apiError `json:"paymentError"`
+}
+
+// init3DSResponse is the response of 3ds-init; HTMLContent is Base64 encoded
+type init3DSResponse struct {
+	HTMLContent   string `json:"htmlContent"`
+	PaymentID     int64  `json:"paymentId"`
+	PaymentStatus string `json:"paymentStatus"`
+}
+
+// refundRequest is the request of a payment refund
+type refundRequest struct {
+	PaymentID             int64   `json:"paymentId"`
+	RefundAmount          float64 `json:"refundAmount"`
+	RefundDestinationType string  `json:"refundDestinationType"`
+	ConversationID        string  `json:"conversationId"`
+}
+
+// refund is a payment refund
+type refund struct {
+	ID             int64   `json:"id"`
+	CreatedDate    string  `json:"createdDate"`
+	Status         string  `json:"status"`
+	RefundPrice    float64 `json:"refundPrice"`
+	PaymentID      int64   `json:"paymentId"`
+	ConversationID string  `json:"conversationId"`
+}
@@ -0,0 +1,96 @@
+package craftgate
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test card for the Craftgate sandbox
+var testCard = provider.CardInfo{CardHolderName: "Haluk Demir", CardNumber: "5258640000000001", ExpireMonth: "07", ExpireYear: "2044", CVV: "000"}
+
+// setupRealTestProvider returns a sandbox provider from CRAFTGATE_API_KEY and
+// CRAFTGATE_SECRET_KEY, skipping the test when they are not set
+func setupRealTestProvider(t *testing.T) *CraftgateProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"apiKey":      os.Getenv("CRAFTGATE_API_KEY"),
+		"secretKey":   os.Getenv("CRAFTGATE_SECRET_KEY"),
+		"environment": "sandbox",
+	}
+	if config["apiKey"] == "" || config["secretKey"] == "" {
+		t.Skip("craftgate sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*CraftgateProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestCraftgateProvider_RealAPI_PaymentStatusRefund makes a non-3D payment, queries it and refunds it
+func TestCraftgateProvider_RealAPI_PaymentStatusRefund(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	payment, err := p.CreatePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		Customer: provider.Customer{Name: "Haluk", Surname: "Demir", Email: "test@craftgate.example.com"},
+		CardInfo: testCard,
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v status=%s paymentID=%s message=%s", payment.Success, payment.Status, payment.PaymentID, payment.Message)
+	if !payment.Success {
+		t.Skipf("Sandbox declined the payment: %s %s", payment.ErrorCode, payment.Message)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: payment.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s message=%s", status.Status, status.Message)
+
+	refund, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: payment.PaymentID, RefundAmount: 1.00, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	t.Logf("Refund: success=%v status=%s message=%s", refund.Success, refund.Status, refund.Message)
+}
+
+// TestCraftgateProvider_RealAPI_StoredCard stores a card, charges it and deletes it
+func TestCraftgateProvider_RealAPI_StoredCard(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	registered, err := p.RegisterCard(ctx, provider.RegisterCardRequest{Alias: "gopay test", Card: testCard})
+	if err != nil {
+		t.Fatalf("RegisterCard failed: %v", err)
+	}
+	t.Logf("Register: success=%v card=%s masked=%s message=%s", registered.Success, registered.ProviderCardID, registered.MaskedCardNo, registered.Message)
+	if !registered.Success {
+		t.Skipf("Sandbox did not store the card: %s %s", registered.ErrorCode, registered.Message)
+	}
+
+	payment, err := p.PayWithSavedCard(ctx, provider.SavedCardPaymentRequest{ProviderCardID: registered.ProviderCardID, Amount: 1.00, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("PayWithSavedCard failed: %v", err)
+	}
+	t.Logf("Payment: success=%v status=%s paymentID=%s", payment.Success, payment.Status, payment.PaymentID)
+
+	deleted, err := p.DeleteProviderCard(ctx, provider.DeleteCardRequest{ProviderCardID: registered.ProviderCardID})
+	if err != nil {
+		t.Fatalf("DeleteProviderCard failed: %v", err)
+	}
+	t.Logf("Delete: success=%v message=%s", deleted.Success, deleted.Message)
+}
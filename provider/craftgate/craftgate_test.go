@@ -0,0 +1,420 @@
+package craftgate
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"apiKey":      "sandbox-YEhueLgomBjqsnvBlWVVuFsVhlvJlMHE",
+		"secretKey":   "sandbox-tBdcdKVGmGupzfaWcULcwDLMoglZZvTz",
+		"webhookKey":  "merchant-webhook-key",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	craftgateProvider, ok := p.(*CraftgateProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a CraftgateProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if craftgateProvider.httpClient != nil {
+		t.Error("CraftgateProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := craftgateProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if craftgateProvider.httpClient == nil {
+		t.Error("CraftgateProvider should have a non-nil HTTP client after Initialize()")
+	}
+
+	if _, ok := p.(provider.CardStorageProvider); !ok {
+		t.Error("CraftgateProvider should implement provider.CardStorageProvider")
+	}
+}
+
+func TestCraftgateProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+		baseURL     string
+	}{
+		{"sandbox", testConfig("sandbox"), false, apiSandboxURL},
+		{"production", testConfig("production"), false, apiProductionURL},
+		{"without webhookKey", without("webhookKey"), false, apiSandboxURL},
+		{"missing apiKey", without("apiKey"), true, ""},
+		{"missing secretKey", without("secretKey"), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CraftgateProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL {
+				t.Errorf("Expected %s, got %s", tt.baseURL, p.baseURL)
+			}
+		})
+	}
+}
+
+func TestCraftgateProvider_GetRequiredConfig(t *testing.T) {
+	p := &CraftgateProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	required := map[string]bool{"apiKey": true, "secretKey": true, "webhookKey": false, "environment": true}
+	if len(fields) != len(required) {
+		t.Fatalf("Expected %d config fields, got %d", len(required), len(fields))
+	}
+	for _, field := range fields {
+		if expected, ok := required[field.Key]; !ok || field.Required != expected {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestCraftgateProvider_Signature(t *testing.T) {
+	p := NewProvider().(*CraftgateProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("https://sandbox-api.craftgate.io/payment/v1/cards?cardUserKey=a b" + p.apiKey + p.secretKey + "rnd" + `{"x":1}`))
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+	if got := p.signature("https://sandbox-api.craftgate.io/payment/v1/cards?cardUserKey=a+b", "rnd", `{"x":1}`); got != expected {
+		t.Errorf("Expected signature %s over the decoded URL, got %s", expected, got)
+	}
+}
+
+// newTestProvider returns a provider whose API answers each request with the envelope handler
+// returns, after checking its signature
+func newTestProvider(t *testing.T, handler func(r *http.Request, body map[string]any) (int, any)) *CraftgateProvider {
+	t.Helper()
+	p := providertest.Initialize[*CraftgateProvider](t, NewProvider, testConfig("sandbox"))
+
+	api := providertest.JSON(handler)
+	server := providertest.Server(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if r.Header.Get("x-api-key") != p.apiKey || r.Header.Get("x-auth-version") != "v1" {
+			t.Errorf("Missing authentication headers on %s", r.URL)
+		}
+		if expected := p.signature(p.baseURL+r.URL.RequestURI(), r.Header.Get("x-rnd-key"), string(raw)); r.Header.Get("x-signature") != expected {
+			t.Errorf("Invalid signature on %s", r.URL)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		api(w, r)
+	})
+
+	p.baseURL = server.URL
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("craftgate"))
+	return p
+}
+
+func paymentData(status string, paymentError any) map[string]any {
+	return map[string]any{
+		"id":              101,
+		"createdDate":     "2024-01-15T10:30:00",
+		"price":           100.5,
+		"paidPrice":       100.5,
+		"currency":        "TRY",
+		"installment":     1,
+		"conversationId":  "GP1",
+		"paymentType":     "CARD_PAYMENT",
+		"paymentStatus":   status,
+		"paymentPhase":    "AUTH",
+		"isThreeDS":       false,
+		"binNumber":       "552879",
+		"lastFourDigits":  "0008",
+		"cardType":        "CREDIT_CARD",
+		"cardAssociation": "MASTER_CARD",
+		"cardBrand":       "Paraf",
+		"refundablePrice": 100.5,
+		"refundStatus":    "NO_REFUND",
+		"paymentError":    paymentError,
+	}
+}
+
+func testPaymentRequest() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      100.50,
+		Currency:    "TRY",
+		CallbackURL: "https://example.com/callback",
+		Customer:    provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:    provider.CardInfo{CardNumber: "5528 7900 0000 0008", ExpireMonth: "12", ExpireYear: "30", CVV: "123"},
+	}
+}
+
+func TestCraftgateProvider_CreatePayment(t *testing.T) {
+	var sent map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPayment {
+			t.Errorf("Unexpected endpoint %s", r.URL.Path)
+		}
+		sent = body
+		card := body["card"].(map[string]any)
+		if card["cardNumber"] == "4111111111111129" {
+			return http.StatusBadRequest, map[string]any{"errors": map[string]any{"errorCode": "10051", "errorDescription": "Kart limiti yetersiz", "errorGroup": "NOT_SUFFICIENT_FUNDS"}}
+		}
+		return http.StatusOK, map[string]any{"data": paymentData(paymentStatusSuccess, nil)}
+	})
+	ctx := context.Background()
+
+	request := testPaymentRequest()
+	request.Items = []provider.Item{{ID: "1", Name: "Book", Price: 50.25, Quantity: 2}}
+	response, err := p.CreatePayment(ctx, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "101" || response.ProviderTime == nil {
+		t.Errorf("Unexpected payment response: %+v", response)
+	}
+
+	card := sent["card"].(map[string]any)
+	items := sent["items"].([]any)
+	if card["cardNumber"] != "5528790000000008" || card["expireYear"] != "2030" || card["cardHolderName"] != "John Doe" {
+		t.Errorf("Unexpected card %v", card)
+	}
+	if sent["price"] != 100.5 || sent["paidPrice"] != 100.5 || len(items) != 1 || items[0].(map[string]any)["price"] != 100.5 {
+		t.Errorf("Unexpected payment request %v", sent)
+	}
+
+	declined := testPaymentRequest()
+	declined.CardInfo.CardNumber = "4111111111111129"
+	response, err = p.CreatePayment(ctx, declined)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "10051" || response.Message != "Kart limiti yetersiz" {
+		t.Errorf("Unexpected declined payment response: %+v", response)
+	}
+}
+
+func TestCraftgateProvider_Complete3DPayment(t *testing.T) {
+	var completed map[string]any
+	paidPrice := 100.5
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointComplete3DS {
+			t.Errorf("Unexpected endpoint %s", r.URL.Path)
+		}
+		completed = body
+		data := paymentData(paymentStatusSuccess, nil)
+		data["paidPrice"] = paidPrice
+		data["isThreeDS"] = true
+		return http.StatusOK, map[string]any{"data": data}
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "craftgate",
+		Environment:      "sandbox",
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"status": "SUCCESS", "completeStatus": "WAITING", "paymentId": "101", "conversationId": "GP1"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if completed["paymentId"] != float64(101) {
+		t.Errorf("Unexpected 3ds-complete request %v", completed)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "101" || response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Unexpected response for a completed payment: %+v", response)
+	}
+
+	// A failed challenge is not completed
+	completed = nil
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"status": "FAILURE", "paymentId": "101", "conversationId": "GP1", "errorMessage": "Kart doğrulanamadı"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if completed != nil || response.Success || response.Status != provider.StatusFailed || response.Message != "Kart doğrulanamadı" {
+		t.Errorf("Unexpected response for a failed challenge: %+v", response)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"status": "SUCCESS", "paymentId": "101", "conversationId": "GP2"}); err == nil {
+		t.Error("Expected a callback of another payment to be rejected")
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"status": "SUCCESS"}); err == nil {
+		t.Error("Expected an error without paymentId")
+	}
+
+	paidPrice = 1
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"status": "SUCCESS", "paymentId": "101", "conversationId": "GP1"}); err == nil {
+		t.Error("Expected a completed payment of another amount to be rejected")
+	}
+}
+
+func TestCraftgateProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expected GET, got %s", r.Method)
+		}
+		switch strings.TrimPrefix(r.URL.Path, endpointPayment+"/") {
+		case "102":
+			data := paymentData(paymentStatusSuccess, nil)
+			data["refundStatus"] = refundStatusPartial
+			return http.StatusOK, map[string]any{"data": data}
+		case "103":
+			return http.StatusOK, map[string]any{"data": paymentData(paymentStatusFailure, map[string]any{"errorCode": "10005", "errorDescription": "İşlem onaylanmadı", "errorGroup": "NOT_APPROVED"})}
+		case "999":
+			return http.StatusNotFound, map[string]any{"errors": map[string]any{"errorCode": "5010", "errorDescription": "Order not found", "errorGroup": "NOT_FOUND"}}
+		}
+		return http.StatusOK, map[string]any{"data": paymentData(paymentStatusSuccess, nil)}
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "101"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusSuccessful || response.Amount != 100.5 {
+		t.Errorf("Unexpected status: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "102"}); err != nil || response.Status != provider.StatusRefunded {
+		t.Errorf("Expected a refunded payment, got %+v (%v)", response, err)
+	}
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "103"}); err != nil || response.Status != provider.StatusFailed || response.ErrorCode != "10005" {
+		t.Errorf("Expected a failed payment, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "999"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown payment, got %+v (%v)", response, err)
+	}
+}
+
+func TestCraftgateProvider_RefundPayment(t *testing.T) {
+	var sent map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		sent = body
+		if body["refundAmount"] == 999.99 {
+			return http.StatusUnprocessableEntity, map[string]any{"errors": map[string]any{"errorCode": "5016", "errorDescription": "İade tutarı ödeme tutarını aşıyor", "errorGroup": "INVALID_REFUND"}}
+		}
+		return http.StatusOK, map[string]any{"data": map[string]any{"id": 55, "createdDate": "2024-01-15T11:00:00", "status": "SUCCESS", "refundPrice": 25, "paymentId": 101, "conversationId": body["conversationId"]}}
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "101", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if sent["paymentId"] != float64(101) || sent["refundAmount"] != float64(25) || sent["refundDestinationType"] != "PROVIDER" {
+		t.Errorf("Unexpected refund request %v", sent)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "55" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "101", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "5016" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25}); err == nil {
+		t.Error("Expected an error for a payment ID that is not a Craftgate payment id")
+	}
+}
+
+func TestCraftgateProvider_ValidateWebhook(t *testing.T) {
+	p := NewProvider().(*CraftgateProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	data := map[string]string{
+		"eventType":      "API_AUTH_PAYMENT",
+		"eventTime":      "2024-01-15T10:30:00.395655",
+		"eventTimestamp": "1705303800",
+		"status":         "SUCCESS",
+		"payloadId":      "101",
+	}
+	mac := hmac.New(sha256.New, []byte("merchant-webhook-key"))
+	mac.Write([]byte("API_AUTH_PAYMENT1705303800SUCCESS101"))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	valid, result, err := p.ValidateWebhook(context.Background(), data, map[string]string{"X-Cg-Signature-V1": signature})
+	if err != nil || !valid {
+		t.Fatalf("Expected a valid webhook, got %v (%v)", valid, err)
+	}
+	if result["paymentId"] != "101" || result["status"] != "success" || result["eventType"] != "API_AUTH_PAYMENT" {
+		t.Errorf("Unexpected webhook result %v", result)
+	}
+
+	tampered := map[string]string{}
+	for key, value := range data {
+		tampered[key] = value
+	}
+	tampered["status"] = "FAILURE"
+	if valid, _, err := p.ValidateWebhook(context.Background(), tampered, map[string]string{"X-Cg-Signature-V1": signature}); valid || err == nil {
+		t.Error("Expected a tampered webhook to be rejected")
+	}
+	if valid, _, err := p.ValidateWebhook(context.Background(), data, map[string]string{}); valid || err == nil {
+		t.Error("Expected a webhook without signature to be rejected")
+	}
+
+	p.webhookKey = ""
+	if valid, _, err := p.ValidateWebhook(context.Background(), data, map[string]string{"X-Cg-Signature-V1": signature}); valid || err == nil {
+		t.Error("Expected webhooks to be rejected without a webhookKey")
+	}
+}
+
+func TestCraftgateProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &CraftgateProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointPayment {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointPayment {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
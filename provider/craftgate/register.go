@@ -0,0 +1,8 @@
+package craftgate
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Craftgate provider with the global registry
+	provider.Register("craftgate", NewProvider)
+}
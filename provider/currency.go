@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CurrencyFormat selects the ISO 4217 representation returned by CurrencyCode
+type CurrencyFormat string
+
+const (
+	// CurrencyFormatAlpha is the three-letter code, e.g. "TRY"
+	CurrencyFormatAlpha CurrencyFormat = "alpha"
+	// CurrencyFormatNumeric is the three-digit code, e.g. "949"
+	CurrencyFormatNumeric CurrencyFormat = "numeric"
+)
+
+// ErrUnknownCurrency is returned by CurrencyCode for codes missing from the mapping
+var ErrUnknownCurrency = errors.New("unknown currency code")
+
+// currencyNumericCodes maps ISO 4217 alpha codes to their numeric codes
+var currencyNumericCodes = map[string]string{
+	"AED": "784",
+	"ARS": "032",
+	"AUD": "036",
+	"AZN": "944",
+	"BGN": "975",
+	"BOB": "068",
+	"BRL": "986",
+	"CAD": "124",
+	"CHF": "756",
+	"CLP": "152",
+	"CNY": "156",
+	"COP": "170",
+	"CZK": "203",
+	"DKK": "208",
+	"EUR": "978",
+	"GBP": "826",
+	"GEL": "981",
+	"HUF": "348",
+	"INR": "356",
+	"IRR": "364",
+	"JPY": "392",
+	"KWD": "414",
+	"KZT": "398",
+	"MXN": "484",
+	"NOK": "578",
+	"PEN": "604",
+	"PLN": "985",
+	"PYG": "600",
+	"QAR": "634",
+	"RON": "946",
+	"RUB": "643",
+	"SAR": "682",
+	"SEK": "752",
+	"TRY": "949",
+	"UAH": "980",
+	"USD": "840",
+	"UYU": "858",
+}
+
+// currencyAlphaCodes is the reverse of currencyNumericCodes
+var currencyAlphaCodes = func() map[string]string {
+	codes := make(map[string]string, len(currencyNumericCodes))
+	for alpha, numeric := range currencyNumericCodes {
+		codes[numeric] = alpha
+	}
+	return codes
+}()
+
+// CurrencyCode converts an ISO 4217 currency code, given either as alpha ("try", "TRY") or
+// numeric ("949"), to the requested format. "TL" is accepted as an alias of TRY since
+// several Turkish providers use it.
+func CurrencyCode(code string, format CurrencyFormat) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "TL" {
+		code = "TRY"
+	}
+
+	alpha := code
+	if _, ok := currencyNumericCodes[code]; !ok {
+		if len(code) > 0 && len(code) < 3 && strings.Trim(code, "0123456789") == "" {
+			code = strings.Repeat("0", 3-len(code)) + code
+		}
+		var found bool
+		if alpha, found = currencyAlphaCodes[code]; !found {
+			return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+		}
+	}
+
+	switch format {
+	case CurrencyFormatAlpha:
+		return alpha, nil
+	case CurrencyFormatNumeric:
+		return currencyNumericCodes[alpha], nil
+	default:
+		return "", fmt.Errorf("unsupported currency format %q", format)
+	}
+}
+
+// currencyExponents lists the currencies whose minor unit is not 1/100 of the major unit
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"CLP": 0,
+	"PYG": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"JOD": 3,
+	"TND": 3,
+}
+
+// CurrencyExponent returns the number of decimals of the currency's minor unit, 2 unless the
+// currency is known to differ
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// ToMinorUnits converts a major unit amount (100.50 TRY) to minor units (10050)
+func ToMinorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(CurrencyExponent(currency))))
+}
+
+// FromMinorUnits converts a minor unit amount (10050) to major units (100.50 TRY)
+func FromMinorUnits(amount int64, currency string) float64 {
+	return float64(amount) / math.Pow10(CurrencyExponent(currency))
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCurrencyCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		format   CurrencyFormat
+		expected string
+	}{
+		{"alpha to numeric", "TRY", CurrencyFormatNumeric, "949"},
+		{"lowercase alpha", "usd", CurrencyFormatNumeric, "840"},
+		{"surrounding spaces", " EUR ", CurrencyFormatNumeric, "978"},
+		{"numeric to alpha", "949", CurrencyFormatAlpha, "TRY"},
+		{"numeric to numeric", "826", CurrencyFormatNumeric, "826"},
+		{"alpha to alpha", "gbp", CurrencyFormatAlpha, "GBP"},
+		{"leading zero numeric", "036", CurrencyFormatAlpha, "AUD"},
+		{"unpadded numeric", "36", CurrencyFormatAlpha, "AUD"},
+		{"TL alias", "TL", CurrencyFormatNumeric, "949"},
+		{"latin american alpha", "BRL", CurrencyFormatNumeric, "986"},
+		{"latin american numeric", "32", CurrencyFormatAlpha, "ARS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CurrencyCode(tt.code, tt.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("CurrencyCode(%q, %s) = %q, want %q", tt.code, tt.format, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCurrencyCode_RoundTrip(t *testing.T) {
+	for alpha, numeric := range currencyNumericCodes {
+		gotNumeric, err := CurrencyCode(alpha, CurrencyFormatNumeric)
+		if err != nil || gotNumeric != numeric {
+			t.Errorf("CurrencyCode(%q) = %q, %v; want %q", alpha, gotNumeric, err, numeric)
+		}
+		gotAlpha, err := CurrencyCode(numeric, CurrencyFormatAlpha)
+		if err != nil || gotAlpha != alpha {
+			t.Errorf("CurrencyCode(%q) = %q, %v; want %q", numeric, gotAlpha, err, alpha)
+		}
+	}
+	if len(currencyAlphaCodes) != len(currencyNumericCodes) {
+		t.Error("Numeric currency codes must be unique")
+	}
+}
+
+func TestCurrencyCode_Errors(t *testing.T) {
+	for _, code := range []string{"", "XYZ", "000", "1234", "TRYY"} {
+		if _, err := CurrencyCode(code, CurrencyFormatNumeric); !errors.Is(err, ErrUnknownCurrency) {
+			t.Errorf("CurrencyCode(%q) expected ErrUnknownCurrency, got %v", code, err)
+		}
+	}
+
+	if _, err := CurrencyCode("TRY", CurrencyFormat("symbol")); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestToMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		expected int64
+	}{
+		{100.50, "TRY", 10050},
+		{0.29, "usd", 29},
+		{19.99, "EUR", 1999},
+		{1500, "JPY", 1500},
+		{25000, "CLP", 25000},
+		{1.234, "KWD", 1234},
+		{10, "", 1000},
+	}
+
+	for _, tt := range tests {
+		if got := ToMinorUnits(tt.amount, tt.currency); got != tt.expected {
+			t.Errorf("ToMinorUnits(%v, %q) = %d, want %d", tt.amount, tt.currency, got, tt.expected)
+		}
+	}
+}
+
+func TestPaymentResponse_InMinorUnits(t *testing.T) {
+	resp := &PaymentResponse{Success: true, PaymentID: "pay_123", Amount: 100.50, Currency: "TRY"}
+
+	converted, ok := resp.InMinorUnits().(*PaymentResponse)
+	if !ok {
+		t.Fatalf("Expected a *PaymentResponse, got %T", resp.InMinorUnits())
+	}
+	if converted.Amount != 10050 || converted.AmountUnit != "minor" || converted.PaymentID != "pay_123" {
+		t.Errorf("Unexpected minor unit response: %+v", converted)
+	}
+	if resp.Amount != 100.50 || resp.AmountUnit != "" {
+		t.Errorf("Expected the original response to keep major units, got %+v", resp)
+	}
+}
+
+func TestPaymentResponse_InMinorUnits_Settlement(t *testing.T) {
+	resp := &PaymentResponse{Amount: 100, Currency: "EUR", SettlementCurrency: "JPY", SettlementAmount: 15900}
+
+	converted := resp.InMinorUnits().(*PaymentResponse)
+	if converted.Amount != 10000 || converted.SettlementAmount != 15900 {
+		t.Errorf("Expected each amount in its own currency's minor units, got %+v", converted)
+	}
+}
+
+func TestFromMinorUnits(t *testing.T) {
+	if got := FromMinorUnits(10842, "USD"); got != 108.42 {
+		t.Errorf("FromMinorUnits(10842, USD) = %v, want 108.42", got)
+	}
+	if got := FromMinorUnits(15900, "JPY"); got != 15900 {
+		t.Errorf("FromMinorUnits(15900, JPY) = %v, want 15900", got)
+	}
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// DescriptionTemplateConfigKey is the tenant config key of the payment description template,
+// set per provider and environment like the provider credentials
+const DescriptionTemplateConfigKey = "descriptionTemplate"
+
+// Limits of description templates. Providers cap the order note at a few hundred
+// characters, and a template looping over items must not grow without bound.
+const (
+	MaxDescriptionTemplateLength = 1024
+	MaxDescriptionLength         = 255
+)
+
+// ErrInvalidDescriptionTemplate is returned when a description template does not parse,
+// refers to unknown fields or renders more than MaxDescriptionLength bytes
+var ErrInvalidDescriptionTemplate = errors.New("invalid description template")
+
+var errDescriptionTooLong = fmt.Errorf("description exceeds %d bytes", MaxDescriptionLength)
+
+// DescriptionTemplateData is what a description template is executed with: the payment
+// request fields, e.g. {{.Amount}} or {{.Customer.Name}}, and OrderID, which is the
+// request's ReferenceID, or its ConversationID when no reference is set.
+//
+//	ACME Order{{with .OrderID}} #{{.}}{{end}}
+type DescriptionTemplateData struct {
+	PaymentRequest
+	OrderID string
+}
+
+// descriptionTemplates holds the parsed templates of loaded providers by cache key
+var descriptionTemplates sync.Map
+
+// ParseDescriptionTemplate parses a description template and checks that it renders within
+// MaxDescriptionLength for a sample request. It is called when the template is saved, so a
+// broken template is rejected before it reaches payments.
+func ParseDescriptionTemplate(text string) (*template.Template, error) {
+	if len(text) > MaxDescriptionTemplateLength {
+		return nil, fmt.Errorf("%w: longer than %d bytes", ErrInvalidDescriptionTemplate, MaxDescriptionTemplateLength)
+	}
+
+	tmpl, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptionTemplate, err)
+	}
+
+	sample := PaymentRequest{
+		ReferenceID: "ORDER-123",
+		Amount:      100,
+		Currency:    "TRY",
+		Description: "Sample payment",
+		Customer:    Customer{Name: "John", Surname: "Doe"},
+		Items:       []Item{{ID: "1", Name: "Item", Price: 100, Quantity: 1}},
+	}
+	if _, err := executeDescriptionTemplate(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptionTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// RenderDescription returns the description sent to the provider. Without a template, or
+// when the template fails or renders nothing, it falls back to the request's Description.
+func RenderDescription(tmpl *template.Template, request PaymentRequest) string {
+	if tmpl == nil {
+		return request.Description
+	}
+
+	description, err := executeDescriptionTemplate(tmpl, request)
+	if err != nil {
+		logger.Warn("Failed to render payment description, using the request description", logger.LogContext{
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return request.Description
+	}
+	if description == "" {
+		return request.Description
+	}
+	return description
+}
+
+// executeDescriptionTemplate renders a template, stopping once it exceeds MaxDescriptionLength
+func executeDescriptionTemplate(tmpl *template.Template, request PaymentRequest) (string, error) {
+	orderID := request.ReferenceID
+	if orderID == "" {
+		orderID = request.ConversationID
+	}
+
+	out := &limitedBuffer{limit: MaxDescriptionLength}
+	if err := tmpl.Execute(out, DescriptionTemplateData{PaymentRequest: request, OrderID: orderID}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// setDescriptionTemplate keeps the template a provider was loaded with. An empty or invalid
+// template removes it, so payments fall back to the request's Description.
+func setDescriptionTemplate(tenantID int, providerName, environment, text string) {
+	key := generateCacheKey(tenantID, providerName, environment)
+	if strings.TrimSpace(text) == "" {
+		descriptionTemplates.Delete(key)
+		return
+	}
+
+	tmpl, err := ParseDescriptionTemplate(text)
+	if err != nil {
+		logger.Warn("Ignoring invalid description template", logger.LogContext{
+			TenantID: fmt.Sprint(tenantID),
+			Provider: providerName,
+			Fields: map[string]any{
+				"environment": environment,
+				"error":       err.Error(),
+			},
+		})
+		descriptionTemplates.Delete(key)
+		return
+	}
+	descriptionTemplates.Store(key, tmpl)
+}
+
+// descriptionTemplateFor returns the template of a tenant's provider, or nil when none is set
+func descriptionTemplateFor(tenantID int, providerName, environment string) *template.Template {
+	if tmpl, ok := descriptionTemplates.Load(generateCacheKey(tenantID, providerName, environment)); ok {
+		return tmpl.(*template.Template)
+	}
+	return nil
+}
+
+// limitedBuffer is a bytes.Buffer that fails writes past limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errDescriptionTooLong
+	}
+	return b.Buffer.Write(p)
+}
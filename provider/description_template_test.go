@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestRenderDescription(t *testing.T) {
+	tmpl, err := ParseDescriptionTemplate(`ACME Order{{with .OrderID}} #{{.}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseDescriptionTemplate failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		request PaymentRequest
+		want    string
+	}{
+		{"with order ID", PaymentRequest{ReferenceID: "1001", Description: "Basket"}, "ACME Order #1001"},
+		{"conversation ID as order ID", PaymentRequest{ConversationID: "conv-7"}, "ACME Order #conv-7"},
+		{"without order ID", PaymentRequest{Description: "Basket"}, "ACME Order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderDescription(tmpl, tt.request); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := RenderDescription(nil, PaymentRequest{Description: "Basket"}); got != "Basket" {
+		t.Errorf("expected the request description without a template, got %q", got)
+	}
+
+	empty, err := ParseDescriptionTemplate(`{{.OrderID}}`)
+	if err != nil {
+		t.Fatalf("ParseDescriptionTemplate failed: %v", err)
+	}
+	if got := RenderDescription(empty, PaymentRequest{Description: "Basket"}); got != "Basket" {
+		t.Errorf("expected the request description when the template renders nothing, got %q", got)
+	}
+}
+
+func TestRenderDescription_OversizedOutput(t *testing.T) {
+	tmpl, err := ParseDescriptionTemplate(`Order {{.OrderID}}: {{.Description}}`)
+	if err != nil {
+		t.Fatalf("ParseDescriptionTemplate failed: %v", err)
+	}
+
+	request := PaymentRequest{ReferenceID: "1001", Description: strings.Repeat("x", MaxDescriptionLength)}
+	if got := RenderDescription(tmpl, request); got != request.Description {
+		t.Errorf("expected an oversized description to fall back to the request description, got %d bytes", len(got))
+	}
+}
+
+func TestParseDescriptionTemplate_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"syntax error":   `Order {{.OrderID`,
+		"unknown field":  `Order {{.Missing}}`,
+		"oversized":      `{{range .Items}}` + strings.Repeat("x", MaxDescriptionLength+1) + `{{end}}`,
+		"long template":  strings.Repeat("x", MaxDescriptionTemplateLength+1),
+		"unknown helper": `{{lower .OrderID}}`,
+	}
+	for name, text := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseDescriptionTemplate(text); !errors.Is(err, ErrInvalidDescriptionTemplate) {
+				t.Errorf("expected ErrInvalidDescriptionTemplate, got %v", err)
+			}
+		})
+	}
+}
+
+// descriptionRecordingProvider remembers the description it was sent
+type descriptionRecordingProvider struct {
+	PaymentProvider
+	description string
+}
+
+func (p *descriptionRecordingProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *descriptionRecordingProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.description = request.Description
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, Amount: request.Amount, Currency: request.Currency}, nil
+}
+
+func TestPaymentService_CreatePayment_DescriptionTemplate(t *testing.T) {
+	const tenantID, providerName = 9112, "descriptiontest"
+
+	fake := &descriptionRecordingProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	setDescriptionTemplate(tenantID, providerName, "sandbox", `ACME Order #{{.OrderID}}`)
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, providerName, "sandbox")
+		setDescriptionTemplate(tenantID, providerName, "sandbox", "")
+	})
+
+	paymentLogger := &requestRecordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9112")
+
+	request := riskRequest()
+	request.ReferenceID = "1001"
+	request.Description = "Basket"
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if fake.description != "ACME Order #1001" {
+		t.Errorf("expected the templated description, got %q", fake.description)
+	}
+	if logged, ok := paymentLogger.request.(PaymentRequest); !ok || logged.Description != "Basket" {
+		t.Errorf("expected the log to keep the request description, got %+v", paymentLogger.request)
+	}
+}
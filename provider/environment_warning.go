@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	// WarningSandboxLargeAmount flags a sandbox payment above SANDBOX_WARNING_AMOUNT
+	WarningSandboxLargeAmount = "sandbox_large_amount"
+	// WarningProductionTestCard flags a production payment made with a published test card
+	WarningProductionTestCard = "production_test_card"
+	// WarningLiveModeMismatch flags a transaction the provider processed in the other mode
+	// than the configured environment, e.g. sandbox credentials set for production
+	WarningLiveModeMismatch = "live_mode_mismatch"
+)
+
+const defaultSandboxWarningAmount = 1000
+
+// PaymentWarning points out a likely environment misconfiguration. It does not change the
+// result of the payment.
+type PaymentWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// knownTestCards are test card numbers published by the supported providers. They never
+// move real money, so seeing one in production means sandbox data reached a live setup.
+var knownTestCards = map[string]bool{
+	// Stripe
+	"4242424242424242": true,
+	"4000056655665556": true,
+	"5555555555554444": true,
+	"4000002500003155": true,
+	// Iyzico
+	"5528790000000008": true,
+	"4543590000000006": true,
+	"5890040000000016": true,
+	// Paycell
+	"4355084355084358": true,
+	"5571135571135575": true,
+	"4546711234567894": true,
+	// Nestpay based banks (Akbank, Ziraat, Payten)
+	"4508034508034509": true,
+	"5406675406675403": true,
+	// Generic
+	"4111111111111111": true,
+}
+
+// IsKnownTestCard reports whether the card number is a published provider test card
+func IsKnownTestCard(cardNumber string) bool {
+	return knownTestCards[strings.ReplaceAll(strings.TrimSpace(cardNumber), " ", "")]
+}
+
+// sandboxWarningAmount reads SANDBOX_WARNING_AMOUNT; 0 disables the sandbox warning
+func sandboxWarningAmount() float64 {
+	value := strings.TrimSpace(config.GetEnv("SANDBOX_WARNING_AMOUNT", ""))
+	if value == "" {
+		return defaultSandboxWarningAmount
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		logger.Warn("Ignoring invalid SANDBOX_WARNING_AMOUNT", logger.LogContext{
+			Fields: map[string]any{
+				"value": value,
+			},
+		})
+		return defaultSandboxWarningAmount
+	}
+	return amount
+}
+
+// environmentWarnings returns the warnings for a payment request: a large amount in
+// sandbox, where no real money moves, or a test card in production.
+func environmentWarnings(environment string, request PaymentRequest, sandboxThreshold float64) []PaymentWarning {
+	var warnings []PaymentWarning
+
+	if environment == "production" {
+		if IsKnownTestCard(request.CardInfo.CardNumber) {
+			warnings = append(warnings, PaymentWarning{
+				Code:    WarningProductionTestCard,
+				Message: "a provider test card was used in production; check that the client is not sending sandbox data",
+			})
+		}
+		return warnings
+	}
+
+	if sandboxThreshold > 0 && request.Amount > sandboxThreshold {
+		warnings = append(warnings, PaymentWarning{
+			Code:    WarningSandboxLargeAmount,
+			Message: fmt.Sprintf("this is a sandbox transaction of %.2f %s, no real money moved; use the production environment for live payments", request.Amount, request.Currency),
+		})
+	}
+	return warnings
+}
+
+// applyLiveMode completes the live mode of a response with the environment when the provider
+// did not report one, and warns when the provider's differs from the environment
+func applyLiveMode(environment string, response *PaymentResponse) {
+	live := environment == "production"
+	if response.LiveMode == nil {
+		response.LiveMode = &live
+		return
+	}
+	if *response.LiveMode == live {
+		return
+	}
+
+	mode := "test"
+	if *response.LiveMode {
+		mode = "live"
+	}
+	response.Warnings = append(response.Warnings, PaymentWarning{
+		Code:    WarningLiveModeMismatch,
+		Message: fmt.Sprintf("the provider processed this %s transaction in %s mode; check the provider credentials configured for %s", environment, mode, environment),
+	})
+}
@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestEnvironmentWarnings(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		amount      float64
+		cardNumber  string
+		threshold   float64
+		expected    string
+	}{
+		{"small sandbox payment", "sandbox", 100, "5528790000000008", 1000, ""},
+		{"sandbox at threshold", "sandbox", 1000, "5528790000000008", 1000, ""},
+		{"large sandbox payment", "sandbox", 1000.01, "5528790000000008", 1000, WarningSandboxLargeAmount},
+		{"sandbox warning disabled", "sandbox", 50000, "5528790000000008", 0, ""},
+		{"production real card", "production", 50000, "5105105105105100", 1000, ""},
+		{"production test card", "production", 10, "5528790000000008", 1000, WarningProductionTestCard},
+		{"production test card with spaces", "production", 10, "4242 4242 4242 4242", 1000, WarningProductionTestCard},
+		{"production without card", "production", 10, "", 1000, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := PaymentRequest{
+				Amount:   tt.amount,
+				Currency: "TRY",
+				CardInfo: CardInfo{CardNumber: tt.cardNumber},
+			}
+
+			warnings := environmentWarnings(tt.environment, request, tt.threshold)
+			if tt.expected == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Expected no warnings, got %+v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || warnings[0].Code != tt.expected || warnings[0].Message == "" {
+				t.Errorf("Expected %s warning, got %+v", tt.expected, warnings)
+			}
+		})
+	}
+}
+
+func TestSandboxWarningAmount(t *testing.T) {
+	tests := map[string]float64{
+		"":        defaultSandboxWarningAmount,
+		"250.5":   250.5,
+		"0":       0,
+		"-1":      defaultSandboxWarningAmount,
+		"invalid": defaultSandboxWarningAmount,
+	}
+
+	for value, expected := range tests {
+		t.Setenv("SANDBOX_WARNING_AMOUNT", value)
+		if got := sandboxWarningAmount(); got != expected {
+			t.Errorf("SANDBOX_WARNING_AMOUNT=%q: expected %v, got %v", value, expected, got)
+		}
+	}
+}
+
+func TestPaymentService_CreatePayment_EnvironmentWarnings(t *testing.T) {
+	const tenantID, providerName = 9103, "warningtest"
+	t.Setenv("SANDBOX_WARNING_AMOUNT", "500")
+
+	for _, environment := range []string{"sandbox", "production"} {
+		GetProviderCache().Set(tenantID, providerName, environment, &riskTestProvider{})
+		t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, environment) })
+	}
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9103")
+
+	request := riskRequest()
+	request.Amount = 750
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !resp.Success || len(resp.Warnings) != 1 || resp.Warnings[0].Code != WarningSandboxLargeAmount {
+		t.Errorf("Expected successful sandbox payment with %s warning, got %+v", WarningSandboxLargeAmount, resp)
+	}
+
+	resp, err = service.CreatePayment(ctx, "production", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0].Code != WarningProductionTestCard {
+		t.Errorf("Expected %s warning, got %+v", WarningProductionTestCard, resp.Warnings)
+	}
+}
+
+func TestApplyLiveMode(t *testing.T) {
+	live, test := true, false
+	tests := []struct {
+		name        string
+		environment string
+		reported    *bool
+		expected    bool
+		mismatch    bool
+	}{
+		{"sandbox without provider mode", "sandbox", nil, false, false},
+		{"production without provider mode", "production", nil, true, false},
+		{"provider test mode in sandbox", "sandbox", &test, false, false},
+		{"provider live mode in production", "production", &live, true, false},
+		{"provider live mode in sandbox", "sandbox", &live, true, true},
+		{"provider test mode in production", "production", &test, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &PaymentResponse{LiveMode: tt.reported}
+			applyLiveMode(tt.environment, response)
+
+			if response.LiveMode == nil || *response.LiveMode != tt.expected {
+				t.Errorf("Expected liveMode %v, got %v", tt.expected, response.LiveMode)
+			}
+			mismatch := len(response.Warnings) == 1 && response.Warnings[0].Code == WarningLiveModeMismatch
+			if mismatch != tt.mismatch || (!tt.mismatch && len(response.Warnings) != 0) {
+				t.Errorf("Expected mismatch warning %v, got %+v", tt.mismatch, response.Warnings)
+			}
+		})
+	}
+}
+
+// liveModeTestProvider reports the mode it processed payments in, like Stripe's livemode
+type liveModeTestProvider struct {
+	riskTestProvider
+	liveMode *bool
+}
+
+func (p *liveModeTestProvider) CreatePayment(context.Context, PaymentRequest) (*PaymentResponse, error) {
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, LiveMode: p.liveMode}, nil
+}
+
+func TestPaymentService_CreatePayment_LiveMode(t *testing.T) {
+	const tenantID, providerName = 9121, "livemodetest"
+	live := true
+	fake := &liveModeTestProvider{}
+	for _, environment := range []string{"sandbox", "production"} {
+		GetProviderCache().Set(tenantID, providerName, environment, fake)
+		t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, environment) })
+	}
+
+	paymentLogger := &recordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9121")
+
+	resp, err := service.CreatePayment(ctx, "production", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if resp.LiveMode == nil || !*resp.LiveMode {
+		t.Errorf("Expected a production payment to be live, got %v", resp.LiveMode)
+	}
+
+	// Sandbox credentials pointing at a live account
+	fake.liveMode = &live
+	resp, err = service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if resp.LiveMode == nil || !*resp.LiveMode {
+		t.Errorf("Expected the provider's live mode to win, got %v", resp.LiveMode)
+	}
+	if len(resp.Warnings) != 1 || resp.Warnings[0].Code != WarningLiveModeMismatch {
+		t.Errorf("Expected %s warning, got %+v", WarningLiveModeMismatch, resp.Warnings)
+	}
+
+	// The live mode is stored with the logged response
+	logged, ok := paymentLogger.responses[len(paymentLogger.responses)-1].(*PaymentResponse)
+	if !ok || logged.LiveMode == nil || !*logged.LiveMode {
+		t.Errorf("Expected the logged response to carry liveMode, got %+v", paymentLogger.responses)
+	}
+}
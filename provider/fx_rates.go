@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// FXRateSource gives the exchange rates used to present volumes of several currencies in one.
+// The rates are indicative, so amounts converted with them are estimates.
+type FXRateSource interface {
+	// Rate returns the value of one unit of from in to, and false when it is not known
+	Rate(from, to string) (float64, bool)
+}
+
+// StaticFXRates are exchange rates against a common base: each currency's value in the base
+// currency, which itself has the rate 1
+type StaticFXRates map[string]float64
+
+// Rate returns the value of one unit of from in to
+func (r StaticFXRates) Rate(from, to string) (float64, bool) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, true
+	}
+	fromRate, ok := r[from]
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := r[to]
+	if !ok {
+		return 0, false
+	}
+	return fromRate / toRate, true
+}
+
+// ParseFXRates parses rates written as "TRY=1,USD=32.5,EUR=35.1"
+func ParseFXRates(value string) (StaticFXRates, error) {
+	rates := StaticFXRates{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		currency, rateValue, ok := strings.Cut(entry, "=")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if !ok || len(currency) != 3 {
+			return nil, fmt.Errorf("invalid FX rate %q, expected CUR=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid FX rate for %s: %q", currency, rateValue)
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+// FXRatesFromEnv returns the rates in ANALYTICS_FX_RATES, or nil when it is not set
+func FXRatesFromEnv() (StaticFXRates, error) {
+	value := strings.TrimSpace(config.GetEnv("ANALYTICS_FX_RATES", ""))
+	if value == "" {
+		return nil, nil
+	}
+	rates, err := ParseFXRates(value)
+	if err != nil {
+		return nil, fmt.Errorf("ANALYTICS_FX_RATES: %w", err)
+	}
+	return rates, nil
+}
+
+// ReportingCurrencyFor returns the currency a tenant's analytics are presented in, read from
+// REPORTING_CURRENCY_TENANT_<ID> or else REPORTING_CURRENCY. It is empty when neither is set.
+func ReportingCurrencyFor(tenantID int) string {
+	for _, key := range []string{"REPORTING_CURRENCY_TENANT_" + strconv.Itoa(tenantID), "REPORTING_CURRENCY"} {
+		if value := strings.ToUpper(strings.TrimSpace(config.GetEnv(key, ""))); value != "" {
+			return value
+		}
+	}
+	return ""
+}
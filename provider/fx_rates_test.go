@@ -0,0 +1,67 @@
+package provider
+
+import "testing"
+
+func TestParseFXRates(t *testing.T) {
+	rates, err := ParseFXRates(" try=1, USD=32.5 ,EUR=35,")
+	if err != nil {
+		t.Fatalf("ParseFXRates failed: %v", err)
+	}
+	if len(rates) != 3 || rates["TRY"] != 1 || rates["USD"] != 32.5 || rates["EUR"] != 35 {
+		t.Errorf("Unexpected rates %v", rates)
+	}
+
+	for _, value := range []string{"USD", "USD=abc", "USD=0", "USD=-1", "DOLLAR=1"} {
+		if _, err := ParseFXRates(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestStaticFXRates_Rate(t *testing.T) {
+	rates := StaticFXRates{"TRY": 1, "USD": 32, "EUR": 36}
+
+	tests := []struct {
+		from, to string
+		expected float64
+		ok       bool
+	}{
+		{"USD", "TRY", 32, true},
+		{"TRY", "USD", 1.0 / 32, true},
+		{"eur", "usd", 36.0 / 32, true},
+		{"GBP", "GBP", 1, true},
+		{"GBP", "TRY", 0, false},
+		{"TRY", "GBP", 0, false},
+	}
+
+	for _, tt := range tests {
+		rate, ok := rates.Rate(tt.from, tt.to)
+		if ok != tt.ok || rate != tt.expected {
+			t.Errorf("Rate(%s, %s) = %v, %v; want %v, %v", tt.from, tt.to, rate, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestFXRatesFromEnv(t *testing.T) {
+	t.Setenv("ANALYTICS_FX_RATES", "")
+	if rates, err := FXRatesFromEnv(); rates != nil || err != nil {
+		t.Errorf("Expected no rates when unset, got %v, %v", rates, err)
+	}
+
+	t.Setenv("ANALYTICS_FX_RATES", "TRY=1,USD=x")
+	if _, err := FXRatesFromEnv(); err == nil {
+		t.Error("Expected an error for an invalid rate")
+	}
+}
+
+func TestReportingCurrencyFor(t *testing.T) {
+	t.Setenv("REPORTING_CURRENCY", "try")
+	t.Setenv("REPORTING_CURRENCY_TENANT_5", " usd ")
+
+	if got := ReportingCurrencyFor(5); got != "USD" {
+		t.Errorf("Expected the tenant preference USD, got %q", got)
+	}
+	if got := ReportingCurrencyFor(6); got != "TRY" {
+		t.Errorf("Expected the global preference TRY, got %q", got)
+	}
+}
@@ -0,0 +1,76 @@
+package garanti
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test cards for the Garanti BBVA sandbox
+var testCards = []provider.CardInfo{
+	{CardNumber: "5406697543211173", ExpireMonth: "03", ExpireYear: "2030", CVV: "465"},
+	{CardNumber: "4282209027132016", ExpireMonth: "05", ExpireYear: "2030", CVV: "358"},
+}
+
+// setupRealTestProvider returns a sandbox provider from GARANTI_MERCHANT_ID,
+// GARANTI_TERMINAL_ID, GARANTI_PROVISION_PASSWORD and GARANTI_STORE_KEY, skipping the test
+// when they are not set
+func setupRealTestProvider(t *testing.T) *GarantiProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"merchantId":        os.Getenv("GARANTI_MERCHANT_ID"),
+		"terminalId":        os.Getenv("GARANTI_TERMINAL_ID"),
+		"provisionPassword": os.Getenv("GARANTI_PROVISION_PASSWORD"),
+		"storeKey":          os.Getenv("GARANTI_STORE_KEY"),
+		"environment":       "sandbox",
+	}
+	if config["merchantId"] == "" || config["terminalId"] == "" || config["provisionPassword"] == "" {
+		t.Skip("garanti sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*GarantiProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestGarantiProvider_RealAPI_SaleStatusRefund makes a non-3D sale, inquires it and refunds it
+func TestGarantiProvider_RealAPI_SaleStatusRefund(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	sale, err := p.CreatePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@garanti.example.com"},
+		CardInfo: testCards[0],
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Sale: success=%v status=%s paymentID=%s message=%s", sale.Success, sale.Status, sale.PaymentID, sale.Message)
+	if !sale.Success {
+		t.Skipf("Sandbox declined the sale: %s %s", sale.ErrorCode, sale.Message)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s amount=%.2f", status.Status, status.Amount)
+
+	refund, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: sale.PaymentID, RefundAmount: 1.00, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	t.Logf("Refund: success=%v status=%s message=%s", refund.Success, refund.Status, refund.Message)
+}
@@ -0,0 +1,636 @@
+package garanti
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"merchantId":        "7000679",
+		"terminalId":        "30691298",
+		"provisionPassword": "123qweASD/",
+		"storeKey":          "12345678",
+		"environment":       environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	garantiProvider, ok := p.(*GarantiProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a GarantiProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if garantiProvider.httpClient != nil {
+		t.Error("GarantiProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := garantiProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if garantiProvider.httpClient == nil {
+		t.Error("GarantiProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestGarantiProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+	}{
+		{"valid configuration", testConfig("sandbox"), false},
+		{"production environment", testConfig("production"), false},
+		{"missing merchantId", without("merchantId"), true},
+		{"missing terminalId", without("terminalId"), true},
+		{"missing provisionPassword", without("provisionPassword"), true},
+		{"missing storeKey", without("storeKey"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProvider().(*GarantiProvider)
+			err := p.Initialize(tt.config)
+
+			if tt.expectError {
+				if err == nil || !strings.Contains(err.Error(), "merchantId, terminalId, provisionPassword and storeKey are required") {
+					t.Fatalf("Expected a missing configuration error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if tt.config["environment"] == "production" {
+				if !p.isProduction || p.baseURL != apiProductionURL || p.threeDPostURL != api3DProductionURL || p.mode() != "PROD" {
+					t.Error("Production environment not set correctly")
+				}
+			} else {
+				if p.isProduction || p.baseURL != apiSandboxURL || p.threeDPostURL != api3DSandboxURL || p.mode() != "TEST" {
+					t.Error("Sandbox environment not set correctly")
+				}
+			}
+		})
+	}
+}
+
+func TestGarantiProvider_GetRequiredConfig(t *testing.T) {
+	p := NewProvider().(*GarantiProvider)
+
+	fields := p.GetRequiredConfig("sandbox")
+	if len(fields) != 5 {
+		t.Fatalf("Expected 5 config fields, got %d", len(fields))
+	}
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected the test configuration to be valid, got %v", err)
+	}
+
+	config := testConfig("sandbox")
+	config["terminalId"] = "TERM01"
+	if err := p.ValidateConfig(config); err == nil {
+		t.Error("Expected a non-numeric terminalId to be rejected")
+	}
+}
+
+func TestGarantiProvider_ValidatePaymentRequest(t *testing.T) {
+	p := &GarantiProvider{}
+
+	validRequest := provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.50,
+		Currency: "TRY",
+		Customer: provider.Customer{
+			Name:    "John",
+			Surname: "Doe",
+			Email:   "john@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "5406697543211173",
+			ExpireMonth: "03",
+			ExpireYear:  "2030",
+			CVV:         "465",
+		},
+		CallbackURL: "https://example.com/callback",
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(*provider.PaymentRequest)
+		is3D     bool
+		errorMsg string
+	}{
+		{"valid 3D request", func(r *provider.PaymentRequest) {}, true, ""},
+		{"valid non-3D request without callback", func(r *provider.PaymentRequest) { r.CallbackURL = "" }, false, ""},
+		{"invalid amount", func(r *provider.PaymentRequest) { r.Amount = 0 }, true, "amount must be greater than 0"},
+		{"missing currency", func(r *provider.PaymentRequest) { r.Currency = "" }, true, "currency is required"},
+		{"missing customer email", func(r *provider.PaymentRequest) { r.Customer.Email = "" }, true, "customer email is required"},
+		{"missing card number", func(r *provider.PaymentRequest) { r.CardInfo.CardNumber = "" }, true, "card number is required"},
+		{"missing CVV", func(r *provider.PaymentRequest) { r.CardInfo.CVV = "" }, true, "CVV is required"},
+		{"missing expiration year", func(r *provider.PaymentRequest) { r.CardInfo.ExpireYear = "" }, true, "card expiration month and year are required"},
+		{"missing callback URL for 3D", func(r *provider.PaymentRequest) { r.CallbackURL = "" }, true, "callback URL is required for 3D secure payments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := validRequest
+			tt.mutate(&request)
+			err := p.validatePaymentRequest(request, tt.is3D)
+
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error message to contain '%s', got %v", tt.errorMsg, err)
+			}
+		})
+	}
+
+	// A rejected request never reaches the gate, so the payment can be retried once fixed
+	server := newTestProvider(t, func(request gvpsRequest) string {
+		t.Error("Expected an invalid request not to be sent")
+		return ""
+	})
+	request := validRequest
+	request.CardInfo.CardNumber = ""
+	if _, err := server.CreatePayment(context.Background(), request); !errors.Is(err, provider.ErrInvalidPaymentRequest) {
+		t.Errorf("Expected ErrInvalidPaymentRequest, got %v", err)
+	}
+}
+
+func TestGarantiProvider_Hashes(t *testing.T) {
+	p := &GarantiProvider{terminalID: "30691298", provisionPassword: "123qweASD/", storeKey: "12345678"}
+
+	// The terminal ID is padded to nine digits for the security data only
+	passwordSum := sha1.Sum([]byte("123qweASD/" + "030691298"))
+	securityData := strings.ToUpper(hex.EncodeToString(passwordSum[:]))
+	if got := p.securityData(); got != securityData {
+		t.Fatalf("Expected security data %s, got %s", securityData, got)
+	}
+
+	upperSHA512 := func(value string) string {
+		sum := sha512.Sum512([]byte(value))
+		return strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+
+	params := map[string]string{
+		"terminalid":          "30691298",
+		"orderid":             "GP20240115103000ABCD",
+		"txnamount":           "10050",
+		"txncurrencycode":     "949",
+		"successurl":          "https://gopay.example.com/v1/callback/garanti?state=1",
+		"errorurl":            "https://gopay.example.com/v1/callback/garanti?state=1",
+		"txntype":             "sales",
+		"txninstallmentcount": "",
+	}
+	expected3D := upperSHA512("30691298" + "GP20240115103000ABCD" + "10050" + "949" +
+		"https://gopay.example.com/v1/callback/garanti?state=1" + "https://gopay.example.com/v1/callback/garanti?state=1" +
+		"sales" + "" + "12345678" + securityData)
+	if got := p.calculate3DHash(params); got != expected3D {
+		t.Errorf("Expected 3D hash %s, got %s", expected3D, got)
+	}
+
+	expectedXML := upperSHA512("GP20240115103000ABCD" + "30691298" + "5406697543211173" + "10050" + "949" + securityData)
+	if got := p.calculateXMLHash("GP20240115103000ABCD", "5406697543211173", "10050", "949"); got != expectedXML {
+		t.Errorf("Expected XML hash %s, got %s", expectedXML, got)
+	}
+}
+
+func TestGarantiProvider_Build3DFormParams(t *testing.T) {
+	p := &GarantiProvider{merchantID: "7000679", terminalID: "30691298"}
+
+	request := provider.PaymentRequest{
+		Amount:   100.50,
+		Currency: "TRY",
+		Customer: provider.Customer{
+			Name:    "John",
+			Surname: "Doe",
+			Email:   "john@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "5406 6975 4321 1173",
+			ExpireMonth: "03",
+			ExpireYear:  "2030",
+			CVV:         "465",
+		},
+		ClientIP:         "10.0.0.1",
+		InstallmentCount: 3,
+	}
+
+	params := p.build3DFormParams(request, "GP1", "https://example.com/callback?state=1", "949")
+
+	expected := map[string]string{
+		"mode":                  "TEST",
+		"apiversion":            "512",
+		"secure3dsecuritylevel": "3D_PAY",
+		"terminalprovuserid":    "PROVAUT",
+		"terminalmerchantid":    "7000679",
+		"terminalid":            "30691298",
+		"orderid":               "GP1",
+		"successurl":            "https://example.com/callback?state=1",
+		"errorurl":              "https://example.com/callback?state=1",
+		"txnamount":             "10050",
+		"txncurrencycode":       "949",
+		"txntype":               "sales",
+		"txninstallmentcount":   "3",
+		"customeripaddress":     "10.0.0.1",
+		"cardnumber":            "5406697543211173",
+		"cardexpiredateyear":    "30",
+		"cardholdername":        "John Doe",
+	}
+	for field, value := range expected {
+		if params[field] != value {
+			t.Errorf("Expected %s=%q, got %q", field, value, params[field])
+		}
+	}
+
+	// The password and store key only go into the hash
+	for _, value := range params {
+		if strings.Contains(value, "123qweASD/") || strings.Contains(value, "12345678") {
+			t.Errorf("Credentials must not be sent in the form: %v", params)
+		}
+	}
+}
+
+func TestGarantiProvider_Generate3DSecureHTML_ChallengeForm(t *testing.T) {
+	p := &GarantiProvider{merchantID: "7000679", terminalID: "30691298", threeDPostURL: api3DSandboxURL}
+	request := provider.PaymentRequest{
+		Amount:   100.50,
+		Currency: "TRY",
+		CardInfo: provider.CardInfo{
+			CardNumber:     "5406697543211173",
+			ExpireMonth:    "03",
+			ExpireYear:     "2030",
+			CVV:            "465",
+			CardHolderName: `John "Johnny" Doe`,
+		},
+	}
+	params := p.build3DFormParams(request, "GP1", "https://example.com/callback?state=1", "949")
+	params["secure3dhash"] = p.calculate3DHash(params)
+
+	form, ok := provider.ParseChallengeForm(p.generate3DSecureHTML(params))
+	if !ok {
+		t.Fatal("Expected a challenge form in the 3D HTML")
+	}
+	if form.URL != api3DSandboxURL || form.Method != "POST" {
+		t.Errorf("Expected POST to %s, got %s %s", api3DSandboxURL, form.Method, form.URL)
+	}
+	if len(form.Fields) != len(params) {
+		t.Errorf("Expected %d fields, got %d", len(params), len(form.Fields))
+	}
+	for name, value := range params {
+		if form.Fields[name] != value {
+			t.Errorf("Expected field %s=%q, got %q", name, value, form.Fields[name])
+		}
+	}
+}
+
+// signedCallback returns 3D callback data hashed with storeKey
+func signedCallback(storeKey string, data map[string]string) map[string]string {
+	data["hashparams"] = "clientid:oid:authcode:procreturncode:response:mdstatus:cavv:eci:md:rnd:"
+	var hashVal strings.Builder
+	for _, name := range strings.Split(data["hashparams"], ":") {
+		hashVal.WriteString(data[name])
+	}
+	sum := sha512.Sum512([]byte(hashVal.String() + storeKey))
+	data["hash"] = strings.ToUpper(hex.EncodeToString(sum[:]))
+	return data
+}
+
+func TestGarantiProvider_Complete3DPayment(t *testing.T) {
+	p := NewProvider().(*GarantiProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "garanti",
+		Environment:      "sandbox",
+	}
+
+	approved := func() map[string]string {
+		return map[string]string{
+			"clientid":       "30691298",
+			"oid":            "GP1",
+			"authcode":       "304919",
+			"procreturncode": "00",
+			"response":       "Approved",
+			"mdstatus":       "1",
+			"hostrefnum":     "018711539490",
+			"rnd":            "1705307400",
+		}
+	}
+
+	ctx := context.Background()
+	response, err := p.Complete3DPayment(ctx, callbackState, signedCallback("12345678", approved()))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "018711539490" {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+	if response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Expected redirect to %s, got %s", callbackState.OriginalCallback, response.RedirectURL)
+	}
+
+	declined := approved()
+	declined["procreturncode"] = "51"
+	declined["response"] = "Declined"
+	declined["errmsg"] = "Yetersiz bakiye"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback("12345678", declined))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	notAuthenticated := approved()
+	notAuthenticated["mdstatus"] = "0"
+	notAuthenticated["procreturncode"] = ""
+	notAuthenticated["mderrormessage"] = "Not authenticated"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback("12345678", notAuthenticated))
+	if err != nil || response.Success || response.ErrorCode != "mdstatus_0" {
+		t.Errorf("Expected a failed payment for mdstatus 0, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, approved()); err == nil || !strings.Contains(err.Error(), "missing hash") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState, signedCallback("another store key", approved())); err == nil || !strings.Contains(err.Error(), "invalid callback hash") {
+		t.Errorf("Expected an invalid hash error, got %v", err)
+	}
+	tampered := signedCallback("12345678", declined)
+	tampered["procreturncode"] = "00"
+	if _, err := p.Complete3DPayment(ctx, callbackState, tampered); err == nil {
+		t.Error("Expected a tampered callback to be rejected")
+	}
+}
+
+// newTestProvider returns a provider whose XML API is handler
+func newTestProvider(t *testing.T, handler func(request gvpsRequest) string) *GarantiProvider {
+	t.Helper()
+	server := providertest.Server(t, providertest.XML(func(_ *http.Request, body []byte) string {
+		var request gvpsRequest
+		if err := xml.Unmarshal(body, &request); err != nil {
+			t.Errorf("Expected an XML request, got %s: %v", body, err)
+		}
+		return handler(request)
+	}))
+
+	p := providertest.Initialize[*GarantiProvider](t, NewProvider, testConfig("sandbox"))
+	p.baseURL = server.URL
+	return p
+}
+
+const approvedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<GVPSResponse>
+	<Mode>TEST</Mode>
+	<Order><OrderID>%s</OrderID></Order>
+	<Transaction>
+		<Response><Source>HOST</Source><Code>00</Code><ReasonCode>00</ReasonCode><Message>Approved</Message></Response>
+		<RetrefNum>018711539490</RetrefNum>
+		<AuthCode>304919</AuthCode>
+		<ProvDate>20240115 10:30:00</ProvDate>
+	</Transaction>
+</GVPSResponse>`
+
+func TestGarantiProvider_CreatePayment(t *testing.T) {
+	var sent gvpsRequest
+	p := newTestProvider(t, func(request gvpsRequest) string {
+		sent = request
+		return strings.Replace(approvedXML, "%s", request.Order.OrderID, 1)
+	})
+
+	response, err := p.CreatePayment(context.Background(), provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.50,
+		Currency: "TRY",
+		Customer: provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo: provider.CardInfo{CardNumber: "5406697543211173", ExpireMonth: "03", ExpireYear: "2030", CVV: "465"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if sent.Transaction.Type != txnTypeSale || sent.Transaction.Amount != "10050" || sent.Transaction.CurrencyCode != "949" ||
+		sent.Card.ExpireDate != "0330" || sent.Terminal.ProvUserID != provisionUserID || sent.Mode != "TEST" || sent.Version != "512" {
+		t.Errorf("Unexpected sale request: %+v", sent)
+	}
+	if sent.Terminal.HashData != p.calculateXMLHash(sent.Order.OrderID, "5406697543211173", "10050", "949") {
+		t.Error("Expected the sale to be hashed with the card number")
+	}
+
+	expectedTime := time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC)
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != sent.Order.OrderID ||
+		response.TransactionID != "018711539490" || response.ProviderTime == nil || !response.ProviderTime.Equal(expectedTime) {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+}
+
+func TestGarantiProvider_RefundPayment(t *testing.T) {
+	var sent gvpsRequest
+	p := newTestProvider(t, func(request gvpsRequest) string {
+		sent = request
+		if request.Transaction.Amount == "99999" {
+			return `<GVPSResponse><Transaction><Response><Code>99</Code><Message>Declined</Message><ErrorMsg>Iade tutari satis tutarini asiyor</ErrorMsg></Response></Transaction></GVPSResponse>`
+		}
+		return strings.Replace(approvedXML, "%s", request.Order.OrderID, 1)
+	})
+
+	response, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if sent.Transaction.Type != txnTypeRefund || sent.Terminal.ProvUserID != refundUserID || sent.Order.OrderID != "GP1" || sent.Transaction.Amount != "2500" {
+		t.Errorf("Unexpected refund request: %+v", sent)
+	}
+	if !response.Success || response.RefundID != "018711539490" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "99" || response.Message != "Iade tutari satis tutarini asiyor" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+
+	if _, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error for a refund without amount")
+	}
+}
+
+func TestGarantiProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(request gvpsRequest) string {
+		if request.Transaction.Type != txnTypeStatus {
+			t.Errorf("Expected an order inquiry, got %s", request.Transaction.Type)
+		}
+		switch request.Order.OrderID {
+		case "GP-VOIDED":
+			return `<GVPSResponse><Order><OrderID>GP-VOIDED</OrderID><OrderInqResult><Status>VOID</Status><AuthAmount>10050</AuthAmount></OrderInqResult></Order><Transaction><Response><Code>00</Code></Response></Transaction></GVPSResponse>`
+		case "GP-MISSING":
+			return `<GVPSResponse><Transaction><Response><Code>92</Code><Message>Declined</Message><ErrorMsg>Order not found</ErrorMsg></Response></Transaction></GVPSResponse>`
+		}
+		return `<GVPSResponse><Order><OrderID>GP1</OrderID><OrderInqResult><Status>APPROVED</Status><AuthAmount>10050</AuthAmount><RetrefNum>018711539490</RetrefNum><ProvDate>20240115 10:30:00</ProvDate></OrderInqResult></Order><Transaction><Response><Code>00</Code></Response></Transaction></GVPSResponse>`
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusSuccessful || response.Amount != 100.50 || response.TransactionID != "018711539490" {
+		t.Errorf("Unexpected status of an approved order: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-VOIDED"}); err != nil || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a voided order to be cancelled, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-MISSING"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestGarantiProvider_CurrencyCode(t *testing.T) {
+	p := &GarantiProvider{}
+
+	tests := []struct {
+		currency    string
+		expected    string
+		expectError bool
+	}{
+		{"TRY", "949", false},
+		{"", "949", false},
+		{"USD", "840", false},
+		{"EUR", "978", false},
+		{"XYZ", "", true},
+	}
+
+	for _, tt := range tests {
+		code, err := p.currencyCode(tt.currency)
+		if tt.expectError {
+			if err == nil || !strings.HasPrefix(err.Error(), "garanti:") {
+				t.Errorf("currencyCode(%q) expected garanti error, got %v", tt.currency, err)
+			}
+			continue
+		}
+		if err != nil || code != tt.expected {
+			t.Errorf("currencyCode(%q) = %s, %v; want %s", tt.currency, code, err, tt.expected)
+		}
+	}
+}
+
+func TestCallbackSessionExpired(t *testing.T) {
+	tests := []struct {
+		data     map[string]string
+		expected bool
+	}{
+		{map[string]string{"mdstatus": "0", "mderrormessage": "3D Secure session timed out"}, true},
+		{map[string]string{"errmsg": "Oturum süresi doldu"}, true},
+		{map[string]string{"procreturncode": "54", "errmsg": "Kart süresi dolmuş"}, false},
+		{map[string]string{"response": "Declined"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := callbackSessionExpired(tt.data); got != tt.expected {
+			t.Errorf("callbackSessionExpired(%v): expected %v, got %v", tt.data, tt.expected, got)
+		}
+	}
+}
+
+func TestCallbackChallengeCancelled(t *testing.T) {
+	tests := []struct {
+		data     map[string]string
+		expected bool
+	}{
+		{map[string]string{"mdstatus": "0", "mderrormessage": "Authentication cancelled by user"}, true},
+		{map[string]string{"errmsg": "İşlem kullanıcı tarafından iptal edildi"}, true},
+		{map[string]string{"mdstatus": "0", "mderrormessage": "Not authenticated"}, false},
+		{map[string]string{"errmsg": "Yetersiz bakiye"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := callbackChallengeCancelled(tt.data); got != tt.expected {
+			t.Errorf("callbackChallengeCancelled(%v): expected %v, got %v", tt.data, tt.expected, got)
+		}
+	}
+}
+
+func TestGarantiProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &GarantiProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestCancelResponse(t *testing.T) {
+	failed := func(code, text string) *provider.PaymentResponse {
+		return &provider.PaymentResponse{Status: provider.StatusFailed, ErrorCode: code, Message: text}
+	}
+
+	tests := []struct {
+		name      string
+		response  *provider.PaymentResponse
+		err       error
+		errorCode string
+	}{
+		{"cancelled", &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful}, nil, ""},
+		{"already captured", failed("99", "Gün sonu yapılmış işlem iptal edilemez"), provider.ErrAlreadyCaptured, provider.ErrorCodeAlreadyCaptured},
+		{"already cancelled", failed("99", "Transaction already voided"), provider.ErrAlreadyCancelled, provider.ErrorCodeAlreadyCancelled},
+		{"not found", failed("99", "Order not found"), provider.ErrPaymentNotFound, provider.ErrorCodePaymentNotFound},
+		{"other failure", failed("05", "Do not honour"), nil, "05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := cancelResponse(tt.response)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if response.ErrorCode != tt.errorCode {
+				t.Errorf("expected error code %q, got %q", tt.errorCode, response.ErrorCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package garanti
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Garanti BBVA provider with the global registry
+	provider.Register("garanti", NewProvider)
+}
@@ -0,0 +1,853 @@
+package halkbank
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// XML API Endpoints
+	apiSandboxURL    = "https://entegrasyon.asseco-see.com.tr/fim/api"
+	apiProductionURL = "https://sanalpos.halkbank.com.tr/fim/api"
+
+	// 3D Post URL
+	api3DSandboxURL    = "https://entegrasyon.asseco-see.com.tr/fim/est3Dgate"
+	api3DProductionURL = "https://sanalpos.halkbank.com.tr/fim/est3Dgate"
+
+	// Transaction Types
+	txnTypeSale   = "Auth"   // Direct sale
+	txnTypeCancel = "Void"   // Cancel/void
+	txnTypeRefund = "Credit" // Refund
+
+	// 3D_PAY: the bank completes the sale itself after the 3D challenge
+	storeType3DPay = "3D_PAY"
+
+	// hashAlgorithm selects the SHA-512 storekey hash of every form field
+	hashAlgorithm = "ver3"
+
+	// Default currency, sent as its ISO 4217 numeric code
+	defaultCurrency = "TRY"
+
+	// Response code of an approved transaction
+	approvedCode = "00"
+
+	// Timestamp layout of EXTRA.TRXDATE on API responses and 3D callbacks, in Turkey time
+	trxDateLayout = "20060102 15:04:05"
+)
+
+// HalkbankProvider implements the provider.PaymentProvider interface for Halkbank
+type HalkbankProvider struct {
+	clientID      string
+	apiUsername   string
+	apiPassword   string
+	storeKey      string
+	baseURL       string
+	threeDPostURL string
+	gopayBaseURL  string
+	isProduction  bool
+	httpClient    *provider.ProviderHTTPClient
+}
+
+// NewProvider creates a new Halkbank payment provider
+func NewProvider() provider.PaymentProvider {
+	return &HalkbankProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Halkbank
+func (p *HalkbankProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "clientId",
+			Required:    true,
+			Type:        "string",
+			Description: "Halkbank Client ID (Mağaza No)",
+			Example:     "500300000",
+			Pattern:     "^[0-9]{1,20}$",
+		},
+		{
+			Key:         "apiUsername",
+			Required:    true,
+			Type:        "string",
+			Description: "API user created in the merchant panel",
+			Example:     "HALKBANKAPI",
+			MinLength:   3,
+			MaxLength:   50,
+		},
+		{
+			Key:         "apiPassword",
+			Required:    true,
+			Type:        "string",
+			Description: "Password of the API user",
+			Example:     "HALKBANK07",
+			MinLength:   3,
+			MaxLength:   50,
+		},
+		{
+			Key:         "storeKey",
+			Required:    true,
+			Type:        "string",
+			Description: "3D Secure store key, set in the merchant panel",
+			Example:     "HALK1234",
+			MinLength:   3,
+			MaxLength:   100,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Halkbank requirements
+func (p *HalkbankProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("halkbank", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies accepted by Halkbank, sent as ISO 4217
+// numeric codes. Foreign currencies need a store opened for them.
+func (p *HalkbankProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the Halkbank XML API. The transaction is part of the XML
+// body, so a bodiless probe changes nothing.
+func (p *HalkbankProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL
+}
+
+// Initialize sets up the Halkbank payment provider with authentication credentials
+func (p *HalkbankProvider) Initialize(conf map[string]string) error {
+	p.clientID = conf["clientId"]
+	p.apiUsername = conf["apiUsername"]
+	p.apiPassword = conf["apiPassword"]
+	p.storeKey = conf["storeKey"]
+
+	if p.clientID == "" || p.apiUsername == "" || p.apiPassword == "" || p.storeKey == "" {
+		return errors.New("halkbank: clientId, apiUsername, apiPassword and storeKey are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiSandboxURL
+	p.threeDPostURL = api3DSandboxURL
+	if p.isProduction {
+		p.baseURL = apiProductionURL
+		p.threeDPostURL = api3DProductionURL
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("halkbank"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *HalkbankProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *HalkbankProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment makes a non-3D sale through the XML API
+func (p *HalkbankProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		return nil, fmt.Errorf("halkbank: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := p.generateOrderId()
+	cc5Req := p.buildBaseRequest(txnTypeSale, orderID)
+	cc5Req.Total = formatAmount(request.Amount)
+	cc5Req.Currency = currencyCode
+	cc5Req.Number = provider.NormalizePAN(request.CardInfo.CardNumber)
+	cc5Req.Expires = request.CardInfo.ExpireMonth + "/" + lastTwo(request.CardInfo.ExpireYear)
+	cc5Req.Cvv2Val = request.CardInfo.CVV
+	cc5Req.Taksit = installmentCount(request.InstallmentCount)
+	cc5Req.Email = request.Customer.Email
+	cc5Req.IPAddress = customerIP(request)
+
+	resp, err := p.sendRequest(ctx, cc5Req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(resp)
+	response.PaymentID = orderID
+	response.Amount = request.Amount
+	response.Currency = request.Currency
+	return response, nil
+}
+
+// Create3DPayment starts a 3D_PAY payment: the customer posts the card to the Halkbank
+// 3D gate, which completes the sale after the challenge and posts the result to GoPay
+func (p *HalkbankProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, true); err != nil {
+		return nil, fmt.Errorf("halkbank: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := p.generateOrderId()
+
+	// Create callback state
+	state := provider.CallbackState{
+		TenantID:         int(request.TenantID),
+		PaymentID:        orderID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "halkbank",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "halkbank", state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	formParams := p.build3DFormParams(request, orderID, gopayCallbackURL, currencyCode)
+	formParams["hash"] = p.calculateHashV3(formParams)
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:    true,
+		Status:     provider.StatusPending,
+		PaymentID:  orderID,
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		HTML:       p.generate3DSecureHTML(formParams),
+		Message:    "3D Secure authentication required",
+		SystemTime: &now,
+	}, nil
+}
+
+// Complete3DPayment reads the result of a 3D_PAY payment the bank posted back
+func (p *HalkbankProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("halkbank: no callback data received")
+	}
+
+	if err := p.verifyCallbackHash(data); err != nil {
+		return nil, err
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("halkbank", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	response := callbackResponse(callbackState, data)
+
+	if !response.Success && callbackSessionExpired(data) {
+		response.ErrorCode = provider.ErrorCode3DSessionExpired
+		return response, fmt.Errorf("halkbank: %w", provider.Err3DSessionExpired)
+	}
+
+	// The customer left the bank page; the card was never declined
+	if !response.Success && callbackChallengeCancelled(data) {
+		provider.MarkThreeDSCancelled(response)
+	}
+
+	return response, nil
+}
+
+// callbackResponse maps a verified 3D_PAY callback. The sale went through when the
+// cardholder was authenticated (mdStatus 1) or the attempt was accepted (2, 3, 4) and the
+// bank approved the provision.
+func callbackResponse(callbackState *provider.CallbackState, data map[string]string) *provider.PaymentResponse {
+	mdStatus := data["mdStatus"]
+	procReturnCode := data["ProcReturnCode"]
+	authenticated := mdStatus == "1" || mdStatus == "2" || mdStatus == "3" || mdStatus == "4"
+	success := authenticated && data["Response"] == "Approved" && procReturnCode == approvedCode
+
+	paymentID := data["oid"]
+	if paymentID == "" {
+		paymentID = callbackState.PaymentID
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          success,
+		PaymentID:        paymentID,
+		TransactionID:    data["TransId"],
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderTime:     provider.ParseProviderTime(data["EXTRA.TRXDATE"], provider.TurkeyTimeZone, trxDateLayout),
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	if success {
+		response.Status = provider.StatusSuccessful
+		response.Message = "3D payment completed successfully"
+		return response
+	}
+
+	response.Status = provider.StatusFailed
+	switch {
+	case procReturnCode != "":
+		response.ErrorCode = procReturnCode
+	case mdStatus != "":
+		response.ErrorCode = "mdStatus_" + mdStatus
+	}
+	switch {
+	case data["ErrMsg"] != "":
+		response.Message = data["ErrMsg"]
+	case data["mdErrorMsg"] != "":
+		response.Message = data["mdErrorMsg"]
+	default:
+		response.Message = "3D payment failed"
+	}
+	return response
+}
+
+// callbackSessionExpired reports whether a failed callback was caused by the 3D session
+// timing out rather than by the bank declining the card
+func callbackSessionExpired(data map[string]string) bool {
+	return provider.Is3DSessionExpiredMessage(data["ErrMsg"], data["mdErrorMsg"], data["Response"])
+}
+
+// callbackChallengeCancelled reports whether a failed callback was caused by the customer
+// cancelling the 3D challenge
+func callbackChallengeCancelled(data map[string]string) bool {
+	return provider.Is3DSChallengeCancelledMessage(data["ErrMsg"], data["mdErrorMsg"], data["Response"])
+}
+
+// GetPaymentStatus retrieves the current status of a payment with an ORDERSTATUS query
+func (p *HalkbankProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("halkbank: paymentID is required")
+	}
+
+	cc5Req := p.buildBaseRequest("", request.PaymentID)
+	cc5Req.Extra = &cc5RequestExtra{OrderStatus: "QUERY"}
+
+	resp, err := p.sendRequest(ctx, cc5Req)
+	if err != nil {
+		return nil, err
+	}
+	return statusResponse(request.PaymentID, resp)
+}
+
+// statusResponse maps an ORDERSTATUS query, returning provider.ErrPaymentNotFound for an
+// unknown order
+func statusResponse(paymentID string, resp *cc5Response) (*provider.PaymentResponse, error) {
+	extra := resp.Extra.values()
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        paymentID,
+		TransactionID:    extra["TRANS_ID"],
+		SystemTime:       &now,
+		ProviderResponse: resp,
+	}
+
+	if resp.ProcReturnCode != approvedCode || extra["TRANS_STAT"] == "" {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = resp.ProcReturnCode
+		response.Message = resp.ErrMsg
+		if code, err := provider.CancelFailure(resp.ErrMsg); errors.Is(err, provider.ErrPaymentNotFound) {
+			response.ErrorCode = code
+			return response, fmt.Errorf("halkbank: %w", err)
+		}
+		return response, nil
+	}
+
+	response.Success = true
+	if amount, err := strconv.ParseFloat(extra["CAPTURE_AMT"], 64); err == nil {
+		// CAPTURE_AMT is in minor units
+		response.Amount = amount / 100
+	}
+
+	// TRANS_STAT is the state of the order's last transaction
+	switch extra["TRANS_STAT"] {
+	case "C", "S":
+		response.Status = provider.StatusSuccessful
+		if extra["CHARGE_TYPE_CD"] == "C" {
+			response.Status = provider.StatusRefunded
+		}
+	case "A":
+		response.Status = provider.StatusAuthorized
+	case "PN":
+		response.Status = provider.StatusPending
+	case "V", "CNCL":
+		response.Status = provider.StatusCancelled
+	case "D", "ERR":
+		response.Success = false
+		response.Status = provider.StatusFailed
+	default:
+		response.Status = provider.StatusUnknown
+	}
+	response.Message = extra["TRANS_STAT"]
+
+	return response, nil
+}
+
+// CancelPayment voids a payment before end of day
+func (p *HalkbankProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("halkbank: paymentID is required")
+	}
+
+	resp, err := p.sendRequest(ctx, p.buildBaseRequest(txnTypeCancel, request.PaymentID))
+	if err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(resp)
+	response.PaymentID = request.PaymentID
+	return cancelResponse(response)
+}
+
+// cancelResponse returns provider.ErrAlreadyCaptured, ErrAlreadyCancelled or
+// ErrPaymentNotFound for a refused void when Halkbank's ErrMsg tells the reason
+func cancelResponse(response *provider.PaymentResponse) (*provider.PaymentResponse, error) {
+	if response.Success {
+		response.Status = provider.StatusCancelled
+		response.Message = "Payment cancelled"
+		return response, nil
+	}
+
+	if code, err := provider.CancelFailure(response.Message); err != nil {
+		response.ErrorCode = code
+		return response, fmt.Errorf("halkbank: %w", err)
+	}
+	return response, nil
+}
+
+// RefundPayment issues a full or partial refund for a settled payment
+func (p *HalkbankProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("halkbank: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("halkbank: refund amount must be greater than 0")
+	}
+
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	cc5Req := p.buildBaseRequest(txnTypeRefund, request.PaymentID)
+	cc5Req.Total = formatAmount(request.RefundAmount)
+	cc5Req.Currency = currencyCode
+
+	resp, err := p.sendRequest(ctx, cc5Req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	success := resp.Response == "Approved" && resp.ProcReturnCode == approvedCode
+
+	refundResp := &provider.RefundResponse{
+		Success:      success,
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+		RawResponse:  resp,
+	}
+
+	if success {
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+		refundResp.RefundID = resp.TransID
+	} else {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = resp.ProcReturnCode
+		refundResp.Message = resp.ErrMsg
+	}
+
+	return refundResp, nil
+}
+
+// ValidateWebhook validates an incoming webhook notification
+func (p *HalkbankProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	// Halkbank posts payment results only to the 3D callback
+	return true, data, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *HalkbankProvider) validatePaymentRequest(request provider.PaymentRequest, is3D bool) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Currency == "" {
+		return errors.New("currency is required")
+	}
+
+	if request.Customer.Email == "" {
+		return errors.New("customer email is required")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("CVV is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiration month and year are required")
+	}
+
+	if is3D && request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// build3DFormParams builds the form posted to the 3D gate, without its hash
+func (p *HalkbankProvider) build3DFormParams(request provider.PaymentRequest, orderID, callbackURL, currencyCode string) map[string]string {
+	cardNumber := provider.NormalizePAN(request.CardInfo.CardNumber)
+
+	// Card type: 1 = Visa, 2 = MasterCard
+	cardType := "1"
+	if strings.HasPrefix(cardNumber, "5") || strings.HasPrefix(cardNumber, "2") {
+		cardType = "2"
+	}
+
+	customerName := strings.TrimSpace(request.CardInfo.CardHolderName)
+	if customerName == "" {
+		customerName = fmt.Sprintf("%s %s", request.Customer.Name, request.Customer.Surname)
+	}
+
+	// The store key and API password only go into the hash
+	return map[string]string{
+		"clientid":                        p.clientID,
+		"oid":                             orderID,
+		"amount":                          formatAmount(request.Amount),
+		"currency":                        currencyCode,
+		"okUrl":                           callbackURL,
+		"failUrl":                         callbackURL,
+		"callbackUrl":                     callbackURL,
+		"TranType":                        txnTypeSale,
+		"Instalment":                      installmentCount(request.InstallmentCount),
+		"rnd":                             strconv.FormatInt(time.Now().UnixNano(), 10),
+		"storetype":                       storeType3DPay,
+		"hashAlgorithm":                   hashAlgorithm,
+		"lang":                            "tr",
+		"pan":                             cardNumber,
+		"cv2":                             request.CardInfo.CVV,
+		"Ecom_Payment_Card_ExpDate_Year":  lastTwo(request.CardInfo.ExpireYear),
+		"Ecom_Payment_Card_ExpDate_Month": request.CardInfo.ExpireMonth,
+		"cardType":                        cardType,
+		"BillToName":                      customerName,
+		"email":                           request.Customer.Email,
+	}
+}
+
+// calculateHashV3 calculates the ver3 hash of form fields: the values sorted by field name
+// (case-insensitive) with \ and | escaped, joined with |, followed by the escaped store key,
+// SHA-512 and base64 encoded. The hash itself, encoding and GoPay's own callback state are
+// not part of it.
+func (p *HalkbankProvider) calculateHashV3(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		switch strings.ToLower(key) {
+		case "hash", "encoding", "state":
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.ToUpper(keys[i]) < strings.ToUpper(keys[j])
+	})
+
+	var hashVal strings.Builder
+	for _, key := range keys {
+		hashVal.WriteString(escapeHashValue(params[key]))
+		hashVal.WriteString("|")
+	}
+	hashVal.WriteString(escapeHashValue(p.storeKey))
+
+	sum := sha512.Sum512([]byte(hashVal.String()))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// escapeHashValue escapes \ and | in a ver3 hash value
+func escapeHashValue(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, "\\", "\\\\"), "|", "\\|")
+}
+
+// verifyCallbackHash checks the ver3 HASH of a 3D callback over every field the bank posted
+func (p *HalkbankProvider) verifyCallbackHash(data map[string]string) error {
+	hash := data["HASH"]
+	if hash == "" {
+		hash = data["hash"]
+	}
+	if hash == "" {
+		return errors.New("halkbank: missing HASH in callback")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(p.calculateHashV3(data)), []byte(hash)) != 1 {
+		return errors.New("halkbank: invalid callback hash")
+	}
+	return nil
+}
+
+// generate3DSecureHTML generates HTML form for 3D Secure authentication
+func (p *HalkbankProvider) generate3DSecureHTML(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var formFields strings.Builder
+	for _, key := range keys {
+		formFields.WriteString(fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, key, html.EscapeString(params[key])))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<title>3D Secure Authentication</title>
+	<meta charset="utf-8">
+	<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+</head>
+<body onload="document.threeDForm.submit();">
+	<div style="text-align: center; margin-top: 50px;">
+		<p>Ödeme işleminiz 3D güvenlik sayfasına yönlendiriliyor...</p>
+		<p>Payment is being redirected to 3D secure page...</p>
+	</div>
+	<form name="threeDForm" method="POST" action="%s">
+		%s
+	</form>
+</body>
+</html>`, p.threeDPostURL, formFields.String())
+}
+
+// currencyCode returns the ISO 4217 numeric code Halkbank expects, using TRY when
+// currency is empty
+func (p *HalkbankProvider) currencyCode(currency string) (string, error) {
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	code, err := provider.CurrencyCode(currency, provider.CurrencyFormatNumeric)
+	if err != nil {
+		return "", fmt.Errorf("halkbank: %w", err)
+	}
+	return code, nil
+}
+
+// formatAmount returns amount with two decimals and a dot, as NestPay expects it
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// installmentCount returns the installment count field, empty for a single payment
+func installmentCount(count int) string {
+	if count > 1 {
+		return strconv.Itoa(count)
+	}
+	return ""
+}
+
+// lastTwo returns the last two digits of a card expiry year
+func lastTwo(year string) string {
+	if len(year) > 2 {
+		return year[len(year)-2:]
+	}
+	return year
+}
+
+// customerIP returns the IP address of the customer
+func customerIP(request provider.PaymentRequest) string {
+	if request.Customer.IPAddress != "" {
+		return request.Customer.IPAddress
+	}
+	if request.ClientIP != "" {
+		return request.ClientIP
+	}
+	return "127.0.0.1"
+}
+
+// cc5Request is the XML request of the NestPay API
+type cc5Request struct {
+	XMLName   xml.Name         `xml:"CC5Request"`
+	Name      string           `xml:"Name"`
+	Password  string           `xml:"Password"`
+	ClientID  string           `xml:"ClientId"`
+	Type      string           `xml:"Type,omitempty"`
+	OrderID   string           `xml:"OrderId"`
+	Total     string           `xml:"Total,omitempty"`
+	Currency  string           `xml:"Currency,omitempty"`
+	Number    string           `xml:"Number,omitempty"`
+	Expires   string           `xml:"Expires,omitempty"`
+	Cvv2Val   string           `xml:"Cvv2Val,omitempty"`
+	Taksit    string           `xml:"Taksit,omitempty"`
+	Email     string           `xml:"Email,omitempty"`
+	IPAddress string           `xml:"IPAddress,omitempty"`
+	Extra     *cc5RequestExtra `xml:"Extra,omitempty"`
+}
+
+type cc5RequestExtra struct {
+	OrderStatus string `xml:"ORDERSTATUS,omitempty"`
+}
+
+// cc5Response is the XML response of the NestPay API
+type cc5Response struct {
+	XMLName        xml.Name `xml:"CC5Response" json:"-"`
+	OrderID        string   `xml:"OrderId" json:"orderId"`
+	GroupID        string   `xml:"GroupId" json:"groupId"`
+	Response       string   `xml:"Response" json:"response"`
+	AuthCode       string   `xml:"AuthCode" json:"authCode"`
+	HostRefNum     string   `xml:"HostRefNum" json:"hostRefNum"`
+	ProcReturnCode string   `xml:"ProcReturnCode" json:"procReturnCode"`
+	TransID        string   `xml:"TransId" json:"transId"`
+	ErrMsg         string   `xml:"ErrMsg" json:"errMsg"`
+	Extra          cc5Extra `xml:"Extra" json:"extra"`
+}
+
+// cc5Extra holds the EXTRA fields of a response, which vary by transaction type
+type cc5Extra struct {
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any" json:"-"`
+}
+
+// values returns the EXTRA fields by name
+func (e cc5Extra) values() map[string]string {
+	values := make(map[string]string, len(e.Fields))
+	for _, field := range e.Fields {
+		values[field.XMLName.Local] = strings.TrimSpace(field.Value)
+	}
+	return values
+}
+
+// MarshalJSON logs the EXTRA fields as an object
+func (e cc5Extra) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.values())
+}
+
+// buildBaseRequest builds an XML request of txnType for orderID
+func (p *HalkbankProvider) buildBaseRequest(txnType, orderID string) *cc5Request {
+	return &cc5Request{
+		Name:     p.apiUsername,
+		Password: p.apiPassword,
+		ClientID: p.clientID,
+		Type:     txnType,
+		OrderID:  orderID,
+	}
+}
+
+// paymentResponse maps the result of a sale or void
+func paymentResponse(resp *cc5Response) *provider.PaymentResponse {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          resp.Response == "Approved" && resp.ProcReturnCode == approvedCode,
+		TransactionID:    resp.TransID,
+		SystemTime:       &now,
+		ProviderTime:     provider.ParseProviderTime(resp.Extra.values()["TRXDATE"], provider.TurkeyTimeZone, trxDateLayout),
+		ProviderResponse: resp,
+	}
+
+	if response.Success {
+		response.Status = provider.StatusSuccessful
+		response.Message = "Payment successful"
+	} else {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = resp.ProcReturnCode
+		response.Message = resp.ErrMsg
+		if response.Message == "" {
+			response.Message = "Payment failed"
+		}
+	}
+	return response
+}
+
+// sendRequest posts an XML request to the NestPay API
+func (p *HalkbankProvider) sendRequest(ctx context.Context, request *cc5Request) (*cc5Response, error) {
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq := &provider.HTTPRequest{
+		Method:   "POST",
+		Endpoint: p.baseURL,
+		Body:     xml.Header + string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/xml; charset=utf-8",
+			"Accept":       "application/xml",
+		},
+	}
+
+	resp, err := p.httpClient.SendRaw(ctx, httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	// NestPay answers in ISO-8859-9
+	var responseData cc5Response
+	decoder := xml.NewDecoder(bytes.NewReader(resp.Body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&responseData); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &responseData, nil
+}
+
+// generateOrderId generates a unique order ID
+func (p *HalkbankProvider) generateOrderId() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return "GP" + time.Now().Format("20060102150405") + strings.ToUpper(hex.EncodeToString(suffix))
+}
@@ -0,0 +1,494 @@
+package halkbank
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"clientId":    "500300000",
+		"apiUsername": "HALKBANKAPI",
+		"apiPassword": "HALKBANK07",
+		"storeKey":    "HALK1234",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	halkbankProvider, ok := p.(*HalkbankProvider)
+	if !ok {
+		t.Fatal("NewProvider should return an HalkbankProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if halkbankProvider.httpClient != nil {
+		t.Error("HalkbankProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := halkbankProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if halkbankProvider.httpClient == nil {
+		t.Error("HalkbankProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestHalkbankProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name          string
+		config        map[string]string
+		expectError   bool
+		baseURL       string
+		threeDPostURL string
+	}{
+		{"sandbox", testConfig("sandbox"), false, apiSandboxURL, api3DSandboxURL},
+		{"production", testConfig("production"), false, apiProductionURL, api3DProductionURL},
+		{"missing clientId", without("clientId"), true, "", ""},
+		{"missing apiUsername", without("apiUsername"), true, "", ""},
+		{"missing apiPassword", without("apiPassword"), true, "", ""},
+		{"missing storeKey", without("storeKey"), true, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &HalkbankProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL || p.threeDPostURL != tt.threeDPostURL {
+				t.Errorf("Expected %s and %s, got %s and %s", tt.baseURL, tt.threeDPostURL, p.baseURL, p.threeDPostURL)
+			}
+		})
+	}
+}
+
+func TestHalkbankProvider_GetRequiredConfig(t *testing.T) {
+	p := &HalkbankProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	expected := map[string]bool{"clientId": true, "apiUsername": true, "apiPassword": true, "storeKey": true, "environment": true}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d config fields, got %d", len(expected), len(fields))
+	}
+	for _, field := range fields {
+		if !expected[field.Key] || !field.Required {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestHalkbankProvider_ValidatePaymentRequest(t *testing.T) {
+	p := &HalkbankProvider{}
+
+	valid := func() provider.PaymentRequest {
+		return provider.PaymentRequest{
+			TenantID:    1,
+			Amount:      100.50,
+			Currency:    "TRY",
+			CallbackURL: "https://example.com/callback",
+			Customer:    provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+			CardInfo:    provider.CardInfo{CardNumber: "4508034508034509", ExpireMonth: "12", ExpireYear: "2030", CVV: "000"},
+		}
+	}
+
+	if err := p.validatePaymentRequest(valid(), true); err != nil {
+		t.Errorf("Expected a valid request, got %v", err)
+	}
+
+	noCallback := valid()
+	noCallback.CallbackURL = ""
+	if err := p.validatePaymentRequest(noCallback, false); err != nil {
+		t.Errorf("Expected a non-3D request without callback to be valid, got %v", err)
+	}
+	if err := p.validatePaymentRequest(noCallback, true); err == nil {
+		t.Error("Expected an error for a 3D request without callback")
+	}
+
+	noAmount := valid()
+	noAmount.Amount = 0
+	if err := p.validatePaymentRequest(noAmount, false); err == nil {
+		t.Error("Expected an error for a zero amount")
+	}
+
+	noCVV := valid()
+	noCVV.CardInfo.CVV = ""
+	if err := p.validatePaymentRequest(noCVV, false); err == nil {
+		t.Error("Expected an error for a missing CVV")
+	}
+}
+
+func TestHalkbankProvider_CalculateHashV3(t *testing.T) {
+	p := &HalkbankProvider{storeKey: "HALK|1234"}
+
+	params := map[string]string{
+		"oid":      "GP1",
+		"amount":   "100.50",
+		"BillTo":   `a\b`,
+		"clientid": "500300000",
+		"hash":     "ignored",
+		"encoding": "utf-8",
+		"state":    "ignored",
+	}
+
+	// Sorted case-insensitively: amount, BillTo, clientid, oid
+	sum := sha512.Sum512([]byte(`100.50|a\\b|500300000|GP1|HALK\|1234`))
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+	if got := p.calculateHashV3(params); got != expected {
+		t.Errorf("Expected hash %s, got %s", expected, got)
+	}
+}
+
+func TestHalkbankProvider_Build3DFormParams(t *testing.T) {
+	p := NewProvider().(*HalkbankProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	request := provider.PaymentRequest{
+		TenantID:         1,
+		Amount:           100.50,
+		Currency:         "TRY",
+		InstallmentCount: 3,
+		Customer:         provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:         provider.CardInfo{CardNumber: "5406 6754 0667 5403", ExpireMonth: "12", ExpireYear: "2030", CVV: "000"},
+	}
+
+	params := p.build3DFormParams(request, "GP1", "https://gopay.example.com/callback/halkbank", "949")
+	expected := map[string]string{
+		"clientid":                        "500300000",
+		"oid":                             "GP1",
+		"amount":                          "100.50",
+		"currency":                        "949",
+		"okUrl":                           "https://gopay.example.com/callback/halkbank",
+		"failUrl":                         "https://gopay.example.com/callback/halkbank",
+		"TranType":                        txnTypeSale,
+		"Instalment":                      "3",
+		"storetype":                       storeType3DPay,
+		"hashAlgorithm":                   "ver3",
+		"pan":                             "5406675406675403",
+		"Ecom_Payment_Card_ExpDate_Year":  "30",
+		"Ecom_Payment_Card_ExpDate_Month": "12",
+		"cardType":                        "2",
+		"BillToName":                      "John Doe",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, params[key])
+		}
+	}
+	if _, ok := params["storeKey"]; ok {
+		t.Error("The store key must not be posted")
+	}
+
+	request.InstallmentCount = 1
+	if params := p.build3DFormParams(request, "GP1", "https://gopay.example.com/callback/halkbank", "949"); params["Instalment"] != "" {
+		t.Errorf("Expected an empty installment for a single payment, got %q", params["Instalment"])
+	}
+}
+
+func TestHalkbankProvider_Generate3DSecureHTML(t *testing.T) {
+	p := &HalkbankProvider{threeDPostURL: api3DSandboxURL}
+
+	form := p.generate3DSecureHTML(map[string]string{"oid": "GP1", "BillToName": `O"Brien <x>`})
+	if !strings.Contains(form, `action="`+api3DSandboxURL+`"`) {
+		t.Error("Expected the form to post to the 3D gate")
+	}
+	if !strings.Contains(form, `name="BillToName" value="O&#34;Brien &lt;x&gt;"`) {
+		t.Errorf("Expected escaped form values, got %s", form)
+	}
+}
+
+// signedCallback returns 3D callback data with its ver3 HASH
+func signedCallback(p *HalkbankProvider, data map[string]string) map[string]string {
+	data["HASH"] = p.calculateHashV3(data)
+	return data
+}
+
+func TestHalkbankProvider_Complete3DPayment(t *testing.T) {
+	p := NewProvider().(*HalkbankProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "halkbank",
+		Environment:      "sandbox",
+	}
+
+	approved := func() map[string]string {
+		return map[string]string{
+			"clientid":       "500300000",
+			"oid":            "GP1",
+			"AuthCode":       "P12345",
+			"Response":       "Approved",
+			"ProcReturnCode": "00",
+			"mdStatus":       "1",
+			"TransId":        "24015KdmG15338",
+			"EXTRA.TRXDATE":  "20240115 10:30:00",
+			"hashAlgorithm":  "ver3",
+		}
+	}
+
+	ctx := context.Background()
+	// GoPay's own state query parameter is merged into the callback data but not hashed by the bank
+	callback := signedCallback(p, approved())
+	callback["state"] = "abc123"
+	response, err := p.Complete3DPayment(ctx, callbackState, callback)
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	expectedTime := time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC)
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "24015KdmG15338" ||
+		response.ProviderTime == nil || !response.ProviderTime.Equal(expectedTime) {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+	if response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Expected redirect to %s, got %s", callbackState.OriginalCallback, response.RedirectURL)
+	}
+
+	declined := approved()
+	declined["Response"] = "Declined"
+	declined["ProcReturnCode"] = "51"
+	declined["ErrMsg"] = "Yetersiz bakiye"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, declined))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	notAuthenticated := approved()
+	notAuthenticated["mdStatus"] = "0"
+	notAuthenticated["Response"] = ""
+	notAuthenticated["ProcReturnCode"] = ""
+	notAuthenticated["mdErrorMsg"] = "Not authenticated"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, notAuthenticated))
+	if err != nil || response.Success || response.ErrorCode != "mdStatus_0" || response.Message != "Not authenticated" {
+		t.Errorf("Expected a failed payment for mdStatus 0, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, approved()); err == nil || !strings.Contains(err.Error(), "missing HASH") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	other := &HalkbankProvider{storeKey: "another store key"}
+	if _, err := p.Complete3DPayment(ctx, callbackState, signedCallback(other, approved())); err == nil || !strings.Contains(err.Error(), "invalid callback hash") {
+		t.Errorf("Expected an invalid hash error, got %v", err)
+	}
+	tampered := signedCallback(p, declined)
+	tampered["ProcReturnCode"] = "00"
+	tampered["Response"] = "Approved"
+	if _, err := p.Complete3DPayment(ctx, callbackState, tampered); err == nil {
+		t.Error("Expected a tampered callback to be rejected")
+	}
+}
+
+// newTestProvider returns a provider whose XML API is handler
+func newTestProvider(t *testing.T, handler func(request cc5Request) string) *HalkbankProvider {
+	t.Helper()
+	server := providertest.Server(t, providertest.XML(func(_ *http.Request, body []byte) string {
+		var request cc5Request
+		if err := xml.Unmarshal(body, &request); err != nil {
+			t.Errorf("Expected an XML request, got %s: %v", body, err)
+		}
+		return handler(request)
+	}))
+
+	p := providertest.Initialize[*HalkbankProvider](t, NewProvider, testConfig("sandbox"))
+	p.baseURL = server.URL
+	return p
+}
+
+const approvedXML = `<?xml version="1.0" encoding="ISO-8859-9"?>
+<CC5Response>
+	<OrderId>%s</OrderId>
+	<GroupId>%s</GroupId>
+	<Response>Approved</Response>
+	<AuthCode>P12345</AuthCode>
+	<HostRefNum>401508123456</HostRefNum>
+	<ProcReturnCode>00</ProcReturnCode>
+	<TransId>24015KdmG15338</TransId>
+	<ErrMsg></ErrMsg>
+	<Extra><SETTLEID>2286</SETTLEID><TRXDATE>20240115 10:30:00</TRXDATE><ERRORCODE></ERRORCODE></Extra>
+</CC5Response>`
+
+func TestHalkbankProvider_CreatePayment(t *testing.T) {
+	var sent cc5Request
+	p := newTestProvider(t, func(request cc5Request) string {
+		sent = request
+		return strings.ReplaceAll(approvedXML, "%s", request.OrderID)
+	})
+
+	response, err := p.CreatePayment(context.Background(), provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.50,
+		Currency: "TRY",
+		Customer: provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo: provider.CardInfo{CardNumber: "4508034508034509", ExpireMonth: "12", ExpireYear: "2030", CVV: "000"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if sent.Type != txnTypeSale || sent.Total != "100.50" || sent.Currency != "949" || sent.Expires != "12/30" ||
+		sent.Name != "HALKBANKAPI" || sent.Password != "HALKBANK07" || sent.ClientID != "500300000" || sent.Taksit != "" {
+		t.Errorf("Unexpected sale request: %+v", sent)
+	}
+
+	expectedTime := time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC)
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != sent.OrderID ||
+		response.TransactionID != "24015KdmG15338" || response.ProviderTime == nil || !response.ProviderTime.Equal(expectedTime) {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+}
+
+func TestHalkbankProvider_CancelPayment(t *testing.T) {
+	var sent cc5Request
+	p := newTestProvider(t, func(request cc5Request) string {
+		sent = request
+		if request.OrderID == "GP-SETTLED" {
+			return `<CC5Response><Response>Error</Response><ProcReturnCode>99</ProcReturnCode><ErrMsg>Gün sonu yapılmış işlem iptal edilemez</ErrMsg></CC5Response>`
+		}
+		return strings.ReplaceAll(approvedXML, "%s", request.OrderID)
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	if sent.Type != txnTypeCancel || sent.OrderID != "GP1" || sent.Total != "" {
+		t.Errorf("Unexpected void request: %+v", sent)
+	}
+	if !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Unexpected void response: %+v", response)
+	}
+
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP-SETTLED"}); !errors.Is(err, provider.ErrAlreadyCaptured) {
+		t.Errorf("Expected ErrAlreadyCaptured for a settled payment, got %v", err)
+	}
+}
+
+func TestHalkbankProvider_RefundPayment(t *testing.T) {
+	var sent cc5Request
+	p := newTestProvider(t, func(request cc5Request) string {
+		sent = request
+		if request.Total == "999.99" {
+			return `<CC5Response><Response>Declined</Response><ProcReturnCode>99</ProcReturnCode><ErrMsg>Iade tutari satis tutarini asiyor</ErrMsg></CC5Response>`
+		}
+		return strings.ReplaceAll(approvedXML, "%s", request.OrderID)
+	})
+
+	response, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if sent.Type != txnTypeRefund || sent.OrderID != "GP1" || sent.Total != "25.00" || sent.Currency != "949" {
+		t.Errorf("Unexpected refund request: %+v", sent)
+	}
+	if !response.Success || response.RefundID != "24015KdmG15338" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "99" || response.Message != "Iade tutari satis tutarini asiyor" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+
+	if _, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error for a refund without amount")
+	}
+}
+
+func TestHalkbankProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(request cc5Request) string {
+		if request.Extra == nil || request.Extra.OrderStatus != "QUERY" || request.Type != "" {
+			t.Errorf("Expected an order status query, got %+v", request)
+		}
+		switch request.OrderID {
+		case "GP-VOIDED":
+			return `<CC5Response><OrderId>GP-VOIDED</OrderId><ProcReturnCode>00</ProcReturnCode><Extra><TRANS_STAT>V</TRANS_STAT><CHARGE_TYPE_CD>S</CHARGE_TYPE_CD></Extra></CC5Response>`
+		case "GP-REFUNDED":
+			return `<CC5Response><OrderId>GP-REFUNDED</OrderId><ProcReturnCode>00</ProcReturnCode><Extra><TRANS_STAT>C</TRANS_STAT><CHARGE_TYPE_CD>C</CHARGE_TYPE_CD></Extra></CC5Response>`
+		case "GP-MISSING":
+			return `<CC5Response><ProcReturnCode>99</ProcReturnCode><ErrMsg>Order not found</ErrMsg><Extra></Extra></CC5Response>`
+		}
+		return `<CC5Response><OrderId>GP1</OrderId><ProcReturnCode>00</ProcReturnCode><Response>Approved</Response>` +
+			`<Extra><TRANS_STAT>C</TRANS_STAT><CHARGE_TYPE_CD>S</CHARGE_TYPE_CD><CAPTURE_AMT>10050</CAPTURE_AMT><TRANS_ID>24015KdmG15338</TRANS_ID></Extra></CC5Response>`
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusSuccessful || response.Amount != 100.50 || response.TransactionID != "24015KdmG15338" {
+		t.Errorf("Unexpected status of an approved order: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-VOIDED"}); err != nil || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a voided order to be cancelled, got %+v (%v)", response, err)
+	}
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-REFUNDED"}); err != nil || response.Status != provider.StatusRefunded {
+		t.Errorf("Expected a credited order to be refunded, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-MISSING"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestHalkbankProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &HalkbankProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
@@ -0,0 +1,8 @@
+package halkbank
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Halkbank provider with the global registry
+	provider.Register("halkbank", NewProvider)
+}
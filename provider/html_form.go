@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxHTMLUnescapePasses bounds the unescaping of HTML that was JSON-encoded more than once
+const maxHTMLUnescapePasses = 4
+
+var (
+	// htmlFormTag matches an opening form tag in any case, not the word "form" in a message
+	htmlFormTag = regexp.MustCompile(`(?i)<form[\s>/]`)
+
+	// unicodeEscape matches \u003c style escapes that JSON encoders use for <, > and &
+	unicodeEscape = regexp.MustCompile(`\\u[0-9a-fA-F]{4}`)
+
+	// unquotedFormSubmit matches onload=document.forms["form"].submit() without quotes around
+	// the attribute value, which breaks at the first quote once the escaping is removed
+	unquotedFormSubmit = regexp.MustCompile(`onload=document\.forms\[["'](\w+)["']\]\.submit\(\)`)
+
+	whitespaceRun  = regexp.MustCompile(`\s+`)
+	spaceAroundTag = regexp.MustCompile(`>\s+<`)
+
+	// jsonEscapes undoes escaping left in a string by a provider that encoded it twice. Literal
+	// line breaks and tabs become spaces so attributes on separate lines stay apart.
+	jsonEscapes = strings.NewReplacer(
+		`\\"`, `"`,
+		`\"`, `"`,
+		`\/`, `/`,
+		`\r`, " ",
+		`\n`, " ",
+		`\t`, " ",
+		`\\`, `\`,
+	)
+)
+
+// ExtractAndCleanHTMLForm returns the HTML form in a JSON response value, such as Nkolay's
+// BANK_REQUEST_MESSAGE, ready to be rendered by the client. The value may be JSON-encoded
+// again, carry escaped quotes, slashes, line breaks, \u003c escapes or HTML entities. It
+// returns false when the value is not a string or holds no <form> tag.
+func ExtractAndCleanHTMLForm(jsonValue any) (string, bool) {
+	var raw string
+	switch value := jsonValue.(type) {
+	case string:
+		raw = value
+	case []byte:
+		raw = string(value)
+	case json.RawMessage:
+		raw = string(value)
+	default:
+		return "", false
+	}
+
+	cleaned := unescapeHTMLPayload(raw)
+	if !htmlFormTag.MatchString(cleaned) {
+		return "", false
+	}
+
+	cleaned = whitespaceRun.ReplaceAllString(cleaned, " ")
+	cleaned = spaceAroundTag.ReplaceAllString(cleaned, "><")
+	cleaned = unquotedFormSubmit.ReplaceAllString(cleaned, `onload="document.forms['$1'].submit()"`)
+	return strings.TrimSpace(cleaned), true
+}
+
+// unescapeHTMLPayload removes one layer of escaping per pass until the value stops changing
+func unescapeHTMLPayload(value string) string {
+	for range maxHTMLUnescapePasses {
+		previous := value
+		value = strings.TrimSpace(value)
+
+		// The whole value is a JSON string literal: "\"<form ...>\""
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			var inner string
+			if err := json.Unmarshal([]byte(value), &inner); err == nil {
+				value = inner
+			}
+		}
+
+		value = unicodeEscape.ReplaceAllStringFunc(value, func(escape string) string {
+			r, err := strconv.ParseUint(escape[2:], 16, 32)
+			if err != nil {
+				return escape
+			}
+			return string(rune(r))
+		})
+		value = jsonEscapes.Replace(value)
+
+		// Entity encoded markup (&lt;form&gt;) is only decoded when there is no real markup,
+		// so entities inside attribute values of a real form are left alone
+		if !strings.Contains(value, "<") && strings.Contains(value, "&lt;") {
+			value = html.UnescapeString(value)
+		}
+
+		if value == previous {
+			break
+		}
+	}
+	return value
+}
+
+// ChallengeForm is a 3D challenge form as data, for clients such as single-page checkouts
+// that post the form themselves instead of rendering PaymentResponse.HTML
+type ChallengeForm struct {
+	URL    string            `json:"url"`
+	Method string            `json:"method"`
+	Fields map[string]string `json:"fields"`
+}
+
+// ParseChallengeForm returns the action, method and fields of the first form in an HTML
+// document. It returns false when there is no form or the form has no action, e.g. for
+// PayTR's iframe.
+func ParseChallengeForm(document string) (*ChallengeForm, bool) {
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(document))
+	var form *ChallengeForm
+	textareaName := ""
+
+	for {
+		switch tokenizer.Next() {
+		case nethtml.ErrorToken:
+			// The document ended without closing the form, as browsers also accept
+			return validChallengeForm(form)
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			token := tokenizer.Token()
+			attrs := tokenAttrs(token)
+			switch {
+			case token.DataAtom == atom.Form && form == nil:
+				method := strings.ToUpper(strings.TrimSpace(attrs["method"]))
+				if method == "" {
+					method = http.MethodGet
+				}
+				form = &ChallengeForm{URL: strings.TrimSpace(attrs["action"]), Method: method, Fields: make(map[string]string)}
+			case form == nil:
+			case token.DataAtom == atom.Input && attrs["name"] != "":
+				// Buttons are only sent when clicked, and the forms are submitted by script
+				switch strings.ToLower(attrs["type"]) {
+				case "submit", "button", "image", "reset":
+				default:
+					form.Fields[attrs["name"]] = attrs["value"]
+				}
+			case token.DataAtom == atom.Textarea && attrs["name"] != "":
+				textareaName = attrs["name"]
+				form.Fields[textareaName] = ""
+			}
+		case nethtml.TextToken:
+			if textareaName != "" {
+				form.Fields[textareaName] += string(tokenizer.Text())
+			}
+		case nethtml.EndTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Textarea:
+				textareaName = ""
+			case atom.Form:
+				if form != nil {
+					return validChallengeForm(form)
+				}
+			}
+		}
+	}
+}
+
+func validChallengeForm(form *ChallengeForm) (*ChallengeForm, bool) {
+	if form == nil || form.URL == "" {
+		return nil, false
+	}
+	return form, true
+}
+
+// tokenAttrs returns the attributes of a tag, unescaped, with lower-case keys
+func tokenAttrs(token nethtml.Token) map[string]string {
+	attrs := make(map[string]string, len(token.Attr))
+	for _, attr := range token.Attr {
+		attrs[strings.ToLower(attr.Key)] = attr.Val
+	}
+	return attrs
+}
+
+// challengeFormOf returns the challenge form in the HTML of a response, or nil without one
+func challengeFormOf(resp *PaymentResponse) *ChallengeForm {
+	if resp.HTML == "" {
+		return nil
+	}
+	form, _ := ParseChallengeForm(resp.HTML)
+	return form
+}
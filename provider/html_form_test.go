@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestExtractAndCleanHTMLForm(t *testing.T) {
+	const expected = `<form name="form" action="https://bank.example/3d" method="post"><input type="hidden" name="PaReq" value="abc"></form>`
+
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+		found    bool
+	}{
+		{
+			name:     "plain html",
+			value:    expected,
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:     "escaped quotes and slashes",
+			value:    `<form name=\"form\" action=\"https:\/\/bank.example\/3d\" method=\"post\"><input type=\"hidden\" name=\"PaReq\" value=\"abc\"><\/form>`,
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:     "literal and escaped line breaks",
+			value:    "<form name=\"form\" action=\"https://bank.example/3d\"\r\n method=\"post\">\\r\\n\\t<input type=\"hidden\"\n name=\"PaReq\" value=\"abc\">\n</form>\n",
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:     "unicode escapes",
+			value:    `\u003cform name=\"form\" action=\"https://bank.example/3d\" method=\"post\"\u003e\u003cinput type=\"hidden\" name=\"PaReq\" value=\"abc\"\u003e\u003c/form\u003e`,
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:     "html entities",
+			value:    `&lt;form name=&quot;form&quot; action=&quot;https://bank.example/3d&quot; method=&quot;post&quot;&gt;&lt;input type=&quot;hidden&quot; name=&quot;PaReq&quot; value=&quot;abc&quot;&gt;&lt;/form&gt;`,
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:     "upper case tag",
+			value:    `<FORM action="https://bank.example/3d"></FORM>`,
+			expected: `<FORM action="https://bank.example/3d"></FORM>`,
+			found:    true,
+		},
+		{
+			name:     "unquoted onload submit",
+			value:    `<body onload=document.forms[\"form\"].submit()><form name=\"form\"></form></body>`,
+			expected: `<body onload="document.forms['form'].submit()"><form name="form"></form></body>`,
+			found:    true,
+		},
+		{
+			name:     "raw bytes",
+			value:    []byte(expected),
+			expected: expected,
+			found:    true,
+		},
+		{
+			name:  "message mentioning a form",
+			value: "Invalid form data, please check the card information",
+		},
+		{
+			name:  "formatted text that is not a tag",
+			value: "<formatted>notice</formatted>",
+		},
+		{
+			name:  "empty string",
+			value: "",
+		},
+		{
+			name:  "not a string",
+			value: float64(2),
+		},
+		{
+			name:  "nil",
+			value: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := ExtractAndCleanHTMLForm(tt.value)
+			if found != tt.found {
+				t.Fatalf("Expected found=%v, got %v (%q)", tt.found, found, got)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExtractAndCleanHTMLForm_DoubleEncoded(t *testing.T) {
+	const form = `<form action="https://bank.example/3d" method="post"></form>`
+
+	// The provider JSON-encoded the form before putting it into its JSON response
+	once, _ := json.Marshal(form)
+	twice, _ := json.Marshal(string(once))
+
+	var field string
+	if err := json.Unmarshal(twice, &field); err != nil {
+		t.Fatalf("Failed to decode field: %v", err)
+	}
+
+	got, found := ExtractAndCleanHTMLForm(field)
+	if !found || got != form {
+		t.Errorf("Expected %q, got %q (found=%v)", form, got, found)
+	}
+}
+
+func TestParseChallengeForm(t *testing.T) {
+	const document = `<!DOCTYPE html><html><body onload="document.threeDForm.submit();">
+<form name="threeDForm" action="https://bank.example/3d?lang=tr&amp;v=2" method="post">
+	<input type="hidden" name="PaReq" value="eJxVUl1v&#43;gjA=">
+	<INPUT TYPE="hidden" NAME="TermUrl" VALUE="https://gopay.example/callback?state=abc&status=SUCCESS" />
+	<input type="hidden" name="MD" value="">
+	<textarea name="note">line one</textarea>
+	<input type="submit" name="go" value="Continue">
+	<input type="hidden" value="no name">
+</form>
+<form action="https://other.example"><input name="ignored" value="1"></form>
+</body></html>`
+
+	form, ok := ParseChallengeForm(document)
+	if !ok {
+		t.Fatal("Expected a challenge form")
+	}
+	if form.URL != "https://bank.example/3d?lang=tr&v=2" || form.Method != "POST" {
+		t.Errorf("Expected POST to the unescaped action, got %s %s", form.Method, form.URL)
+	}
+
+	want := map[string]string{
+		"PaReq":   "eJxVUl1v+gjA=",
+		"TermUrl": "https://gopay.example/callback?state=abc&status=SUCCESS",
+		"MD":      "",
+		"note":    "line one",
+	}
+	if len(form.Fields) != len(want) {
+		t.Errorf("Expected fields %v, got %v", want, form.Fields)
+	}
+	for name, value := range want {
+		if got, ok := form.Fields[name]; !ok || got != value {
+			t.Errorf("Expected field %s=%q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestParseChallengeForm_NoForm(t *testing.T) {
+	tests := map[string]string{
+		"iframe":              `<iframe src="https://www.paytr.com/odeme/guvenli/token"></iframe>`,
+		"form without action": `<form method="post"><input name="a" value="1"></form>`,
+		"plain text":          "Invalid form data",
+		"empty":               "",
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			if form, ok := ParseChallengeForm(document); ok {
+				t.Errorf("Expected no challenge form, got %+v", form)
+			}
+		})
+	}
+}
+
+func TestParseChallengeForm_DefaultMethodAndCleanedForm(t *testing.T) {
+	// Nkolay's BANK_REQUEST_MESSAGE after ExtractAndCleanHTMLForm
+	cleaned, ok := ExtractAndCleanHTMLForm(`<form name=\"form\" action=\"https:\/\/bank.example\/3d\"><input type=\"hidden\" name=\"PaReq\" value=\"abc\"><\/form>`)
+	if !ok {
+		t.Fatal("Expected a cleaned form")
+	}
+
+	form, ok := ParseChallengeForm(cleaned)
+	if !ok {
+		t.Fatal("Expected a challenge form")
+	}
+	if form.URL != "https://bank.example/3d" || form.Method != "GET" || form.Fields["PaReq"] != "abc" {
+		t.Errorf("Unexpected challenge form %+v", form)
+	}
+}
+
+// htmlFormProvider answers every payment with a 3D form
+type htmlFormProvider struct {
+	PaymentProvider
+}
+
+func (p *htmlFormProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *htmlFormProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	return &PaymentResponse{
+		Success:  true,
+		Status:   StatusPending,
+		Amount:   request.Amount,
+		Currency: request.Currency,
+		HTML:     `<form action="https://bank.example/3d" method="post"><input type="hidden" name="PaReq" value="abc"></form>`,
+	}, nil
+}
+
+func TestPaymentService_CreatePayment_ChallengeForm(t *testing.T) {
+	const tenantID, providerName = 9115, "challengeformtest"
+
+	GetProviderCache().Set(tenantID, providerName, "sandbox", &htmlFormProvider{})
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9115")
+
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if resp.HTML == "" || resp.ChallengeForm == nil {
+		t.Fatalf("Expected both the HTML and the challenge form, got %+v", resp)
+	}
+	if resp.ChallengeForm.URL != "https://bank.example/3d" || resp.ChallengeForm.Fields["PaReq"] != "abc" {
+		t.Errorf("Unexpected challenge form %+v", resp.ChallengeForm)
+	}
+}
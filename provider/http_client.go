@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -12,6 +13,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
 )
 
 // HTTPClientConfig represents configuration for HTTP client
@@ -20,6 +23,7 @@ type HTTPClientConfig struct {
 	Timeout            time.Duration
 	InsecureSkipVerify bool
 	DefaultHeaders     map[string]string
+	ProviderName       string // selects the provider's ResponseParsingFor mode
 }
 
 // HTTPRequest represents a standardized HTTP request
@@ -101,6 +105,7 @@ func (c *ProviderHTTPClient) sendRequest(ctx context.Context, req *HTTPRequest,
 	// Prepare request body
 	var body io.Reader
 	var actualContentType string
+	var err error
 
 	// Check if we should use multipart/form-data (when FormData is set and no explicit content-type)
 	if len(req.FormData) > 0 && contentType == "application/x-www-form-urlencoded" {
@@ -179,6 +184,13 @@ func (c *ProviderHTTPClient) sendRequest(ctx context.Context, req *HTTPRequest,
 		}
 	}
 
+	gzipped := body != nil && GzipRequestsFor(c.config.ProviderName)
+	if gzipped {
+		if body, err = gzipBody(body); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, req.Method, fullURL, body)
 	if err != nil {
@@ -201,6 +213,9 @@ func (c *ProviderHTTPClient) sendRequest(ctx context.Context, req *HTTPRequest,
 	} else if contentType != "" {
 		httpReq.Header.Set("Content-Type", contentType)
 	}
+	if gzipped {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
 
 	// Send request
 	resp, err := c.client.Do(httpReq)
@@ -210,7 +225,7 @@ func (c *ProviderHTTPClient) sendRequest(ctx context.Context, req *HTTPRequest,
 	defer resp.Body.Close()
 
 	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := readResponseBody(resp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
@@ -231,6 +246,43 @@ func (c *ProviderHTTPClient) sendRequest(ctx context.Context, req *HTTPRequest,
 	return response, nil
 }
 
+// GzipRequestsFor reports whether request bodies to a provider are gzip compressed, read from
+// PROVIDER_GZIP_REQUESTS_<PROVIDER>. It is off by default since not every provider accepts a
+// Content-Encoding on requests.
+func GzipRequestsFor(providerName string) bool {
+	providerName = strings.ToUpper(strings.TrimSpace(providerName))
+	return providerName != "" && config.GetBoolEnv("PROVIDER_GZIP_REQUESTS_"+providerName, false)
+}
+
+// gzipBody compresses a request body
+func gzipBody(body io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := io.Copy(writer, body); err != nil {
+		return nil, fmt.Errorf("failed to gzip request body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to gzip request body: %w", err)
+	}
+	return &buf, nil
+}
+
+// readResponseBody reads a response body, decompressing it when it is gzip encoded. The
+// transport already does so when it asked for gzip itself; this covers providers that
+// compress unasked and requests that set Accept-Encoding explicitly.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	if resp.Uncompressed || !strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip") {
+		return io.ReadAll(resp.Body)
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip response: %w", err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
 func joinURL(base, endpoint string) string {
 	if strings.HasSuffix(base, "/") && strings.HasPrefix(endpoint, "/") {
 		return base + endpoint[1:]
@@ -281,9 +333,21 @@ func (c *ProviderHTTPClient) buildURL(endpoint string, queryParams map[string]st
 	return fullURL
 }
 
-// ParseJSONResponse parses the response body as JSON into the target interface
+// ParseJSONResponse parses the response body as JSON into the target interface, strictly or
+// leniently depending on the provider's ResponseParsingFor mode. A 2xx response that cannot
+// be parsed fails with ErrResponseUnparseable.
 func (c *ProviderHTTPClient) ParseJSONResponse(response *HTTPResponse, target any) error {
-	return json.Unmarshal(response.Body, target)
+	err := parseJSONResponse(c.config.ProviderName, ResponseParsingFor(c.config.ProviderName), response.Body, target)
+	if err != nil && response.StatusCode >= 200 && response.StatusCode < 300 {
+		return fmt.Errorf("%w: %w", ErrResponseUnparseable, err)
+	}
+	return err
+}
+
+// ForProvider sets the name of the provider the client talks to and returns the config
+func (c *HTTPClientConfig) ForProvider(name string) *HTTPClientConfig {
+	c.ProviderName = name
+	return c
 }
 
 // CreateHTTPClientConfig creates a standard HTTP client configuration for providers
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// gzipEchoServer decompresses gzip request bodies and answers with the body it got, gzip
+// compressed whether or not the client asked for it
+func gzipEchoServer(t *testing.T, encodings *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*encodings = append(*encodings, r.Header.Get("Content-Encoding"))
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("request body is not gzip: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = reader
+		}
+		received, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, _ = writer.Write(received)
+		_ = writer.Close()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(buf.Bytes())
+	}))
+}
+
+func TestProviderHTTPClient_GzipRoundTrip(t *testing.T) {
+	var encodings []string
+	server := gzipEchoServer(t, &encodings)
+	defer server.Close()
+
+	t.Setenv("PROVIDER_GZIP_REQUESTS_IYZICO", "true")
+	client := NewProviderHTTPClient(CreateHTTPClientConfig(server.URL, false).ForProvider("iyzico"))
+
+	basket := map[string]any{"items": []map[string]any{{"id": "1", "name": "Item", "price": 100}}}
+	resp, err := client.SendJSON(context.Background(), &HTTPRequest{
+		Method:   http.MethodPost,
+		Endpoint: "/payment",
+		Headers:  map[string]string{"Accept-Encoding": "gzip"},
+		Body:     basket,
+	})
+	if err != nil {
+		t.Fatalf("SendJSON failed: %v", err)
+	}
+
+	if len(encodings) != 1 || encodings[0] != "gzip" {
+		t.Errorf("expected a gzip request body, got Content-Encoding %v", encodings)
+	}
+	want, _ := json.Marshal(basket)
+	if string(resp.Body) != string(want) {
+		t.Errorf("expected the decompressed echo %s, got %q", want, resp.Body)
+	}
+}
+
+func TestProviderHTTPClient_GzipOffByDefault(t *testing.T) {
+	var encodings []string
+	server := gzipEchoServer(t, &encodings)
+	defer server.Close()
+
+	client := NewProviderHTTPClient(CreateHTTPClientConfig(server.URL, false).ForProvider("iyzico"))
+	resp, err := client.SendForm(context.Background(), &HTTPRequest{
+		Method:   http.MethodPost,
+		Endpoint: "/payment",
+		FormData: map[string]string{"amount": "100.00"},
+	})
+	if err != nil {
+		t.Fatalf("SendForm failed: %v", err)
+	}
+
+	if len(encodings) != 1 || encodings[0] != "" {
+		t.Errorf("expected an uncompressed request body, got Content-Encoding %v", encodings)
+	}
+	if string(resp.Body) != "amount=100.00" {
+		t.Errorf("expected the gzip response to be decoded transparently, got %q", resp.Body)
+	}
+}
+
+func TestGzipRequestsFor(t *testing.T) {
+	t.Setenv("PROVIDER_GZIP_REQUESTS_PAYTR", "true")
+	if !GzipRequestsFor("paytr") {
+		t.Error("expected gzip requests for paytr")
+	}
+	if GzipRequestsFor("iyzico") || GzipRequestsFor("") {
+		t.Error("expected gzip requests to be off for other providers")
+	}
+}
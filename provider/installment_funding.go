@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// InstallmentCreditOnlyConfigKey is the tenant config key that restricts installments to
+// credit cards, set per provider and environment like the provider credentials. "true"
+// enables it; it is off by default.
+const InstallmentCreditOnlyConfigKey = "installmentCreditOnly"
+
+// ErrInstallmentNotAllowed is returned for an installment payment with a debit or prepaid
+// card when the tenant restricts installments to credit cards. Providers reject these with
+// opaque errors, after a round-trip.
+var ErrInstallmentNotAllowed = errors.New("installments are only allowed for credit cards")
+
+// installmentCreditOnly holds the cache keys of loaded providers that restrict installments
+var installmentCreditOnly sync.Map
+
+// setInstallmentCreditOnly records the tenant's InstallmentCreditOnlyConfigKey value of a
+// loaded provider. An invalid value is logged and leaves the check off.
+func setInstallmentCreditOnly(tenantID int, providerName, environment, value string) {
+	key := generateCacheKey(tenantID, providerName, environment)
+	if strings.TrimSpace(value) == "" {
+		installmentCreditOnly.Delete(key)
+		return
+	}
+
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warn("Ignoring invalid installment credit only setting", logger.LogContext{
+			TenantID: fmt.Sprint(tenantID),
+			Provider: providerName,
+			Fields: map[string]any{
+				"environment": environment,
+				"value":       value,
+			},
+		})
+	}
+	if !enabled {
+		installmentCreditOnly.Delete(key)
+		return
+	}
+	installmentCreditOnly.Store(key, true)
+}
+
+// installmentCreditOnlyFor reports whether a tenant's provider restricts installments
+func installmentCreditOnlyFor(tenantID int, providerName, environment string) bool {
+	_, ok := installmentCreditOnly.Load(generateCacheKey(tenantID, providerName, environment))
+	return ok
+}
+
+// checkInstallmentFunding rejects an installment payment whose card the provider's BIN lookup
+// reports as debit or prepaid, when the tenant restricts installments to credit cards. Cards
+// the lookup cannot tell, and failed lookups, are left to the provider. The BIN info is
+// returned so the payment's details do not look it up again.
+func (s *PaymentService) checkInstallmentFunding(ctx context.Context, provider PaymentProvider, tenantID int, providerName, environment string, request PaymentRequest) (*CardBinInfo, error) {
+	if request.InstallmentCount <= 1 || !installmentCreditOnlyFor(tenantID, providerName, environment) {
+		return nil, nil
+	}
+	lookup, ok := provider.(CardBinInfoProvider)
+	bin := cardBIN(request.CardInfo.CardNumber)
+	if !ok || bin == "" {
+		return nil, nil
+	}
+
+	info, err := lookup.GetCardBinInfo(ctx, bin)
+	if err != nil || info == nil {
+		if err != nil {
+			logger.Warn("Failed to look up card BIN for installment check", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"error": err.Error(),
+				},
+			})
+		}
+		return nil, nil
+	}
+
+	switch funding := NormalizeFundingType(info.CardType); funding {
+	case FundingDebit, FundingPrepaid:
+		return info, fmt.Errorf("%w: %d installments requested with a %s card", ErrInstallmentNotAllowed, request.InstallmentCount, funding)
+	}
+	return info, nil
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestPaymentService_CreatePayment_InstallmentCreditOnly(t *testing.T) {
+	const tenantID, providerName = 9120, "installmenttest"
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9120")
+	debit := &CardBinInfo{CardType: "Debit Card", CardAssociation: "VISA"}
+	credit := &CardBinInfo{CardType: "Kredi Kartı", CardAssociation: "MASTER"}
+
+	tests := []struct {
+		name         string
+		enabled      string
+		info         *CardBinInfo
+		lookupErr    error
+		installments int
+		wantErr      bool
+	}{
+		{name: "debit card with installments", enabled: "true", info: debit, installments: 3, wantErr: true},
+		{name: "prepaid card with installments", enabled: "true", info: &CardBinInfo{CardType: "PREPAID"}, installments: 6, wantErr: true},
+		{name: "credit card with installments", enabled: "true", info: credit, installments: 3},
+		{name: "debit card without installments", enabled: "true", info: debit, installments: 1},
+		{name: "unknown funding type", enabled: "true", info: &CardBinInfo{CardType: "CHARGE"}, installments: 3},
+		{name: "failed lookup", enabled: "true", lookupErr: errors.New("bin service down"), installments: 3},
+		{name: "not enforced", enabled: "", info: debit, installments: 3},
+		{name: "turned off", enabled: "false", info: debit, installments: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &binInfoTestProvider{info: tt.info, err: tt.lookupErr}
+			GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+			setInstallmentCreditOnly(tenantID, providerName, "sandbox", tt.enabled)
+			t.Cleanup(func() {
+				GetProviderCache().Delete(tenantID, providerName, "sandbox")
+				setInstallmentCreditOnly(tenantID, providerName, "sandbox", "")
+			})
+
+			service := NewPaymentService(&recordingPaymentLogger{})
+			request := riskRequest()
+			request.Amount = 1500
+			request.InstallmentCount = tt.installments
+			resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInstallmentNotAllowed) {
+					t.Fatalf("expected ErrInstallmentNotAllowed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the payment to go through, got %v", err)
+			}
+			// A failed lookup is tried again for the payment details
+			if tt.lookupErr == nil && len(fake.lookups) > 1 {
+				t.Errorf("expected the BIN to be looked up once, got %v", fake.lookups)
+			}
+			if tt.info != nil && (resp.PaymentMethodDetails == nil || resp.PaymentMethodDetails.FundingType != NormalizeFundingType(tt.info.CardType)) {
+				t.Errorf("expected the funding type in the payment details, got %+v", resp.PaymentMethodDetails)
+			}
+		})
+	}
+}
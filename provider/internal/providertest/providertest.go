@@ -0,0 +1,56 @@
+// Package providertest holds the fixtures shared by the provider packages' tests: a test
+// server standing in for a provider API and the handlers adapting its JSON and XML bodies.
+package providertest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Initialize creates a provider with newProvider and initializes it with config
+func Initialize[P provider.PaymentProvider](t testing.TB, newProvider func() provider.PaymentProvider, config map[string]string) P {
+	t.Helper()
+	p := newProvider().(P)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// Server starts a server answering the provider's API with handler, closed when the test ends
+func Server(t testing.TB, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// JSON answers a JSON API: handler gets the decoded request body and returns the status and
+// the value to encode as the response, which is left empty when nil
+func JSON(handler func(r *http.Request, body map[string]any) (int, any)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		status, result := handler(r, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if result != nil {
+			_ = json.NewEncoder(w).Encode(result)
+		}
+	}
+}
+
+// XML answers an XML API: handler gets the raw request body and returns the response document
+func XML(handler func(r *http.Request, body []byte) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, handler(r, body))
+	}
+}
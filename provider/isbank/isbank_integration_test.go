@@ -0,0 +1,76 @@
+package isbank
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test cards for the Asseco test environment
+var testCards = []provider.CardInfo{
+	{CardNumber: "4508034508034509", ExpireMonth: "12", ExpireYear: "2030", CVV: "000"},
+	{CardNumber: "5406675406675403", ExpireMonth: "12", ExpireYear: "2030", CVV: "000"},
+}
+
+// setupRealTestProvider returns a sandbox provider from ISBANK_CLIENT_ID,
+// ISBANK_API_USERNAME, ISBANK_API_PASSWORD and ISBANK_STORE_KEY, skipping the test when they
+// are not set
+func setupRealTestProvider(t *testing.T) *IsbankProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"clientId":    os.Getenv("ISBANK_CLIENT_ID"),
+		"apiUsername": os.Getenv("ISBANK_API_USERNAME"),
+		"apiPassword": os.Getenv("ISBANK_API_PASSWORD"),
+		"storeKey":    os.Getenv("ISBANK_STORE_KEY"),
+		"environment": "sandbox",
+	}
+	if config["clientId"] == "" || config["apiUsername"] == "" || config["apiPassword"] == "" || config["storeKey"] == "" {
+		t.Skip("isbank sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*IsbankProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestIsbankProvider_RealAPI_SaleStatusCancel makes a non-3D sale, queries it and voids it
+func TestIsbankProvider_RealAPI_SaleStatusCancel(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	sale, err := p.CreatePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@isbank.example.com"},
+		CardInfo: testCards[0],
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Sale: success=%v status=%s paymentID=%s message=%s", sale.Success, sale.Status, sale.PaymentID, sale.Message)
+	if !sale.Success {
+		t.Skipf("Sandbox declined the sale: %s %s", sale.ErrorCode, sale.Message)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s amount=%.2f", status.Status, status.Amount)
+
+	cancel, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	t.Logf("Cancel: success=%v status=%s message=%s", cancel.Success, cancel.Status, cancel.Message)
+}
@@ -0,0 +1,8 @@
+package isbank
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register İş Bankası provider with the global registry
+	provider.Register("isbank", NewProvider)
+}
@@ -2,9 +2,19 @@ package iyzico
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -776,9 +786,9 @@ func TestIyzicoProvider_GetRequiredConfig(t *testing.T) {
 		environment string
 		expected    int
 	}{
-		{"sandbox environment", "sandbox", 3},
-		{"production environment", "production", 3},
-		{"test environment", "test", 3},
+		{"sandbox environment", "sandbox", 5},
+		{"production environment", "production", 5},
+		{"test environment", "test", 5},
 	}
 
 	for _, tt := range tests {
@@ -788,14 +798,14 @@ func TestIyzicoProvider_GetRequiredConfig(t *testing.T) {
 				t.Errorf("GetRequiredConfig() returned %d fields, want %d", len(result), tt.expected)
 			}
 
-			// Check required fields
-			expectedFields := []string{"apiKey", "secretKey", "environment"}
+			// Check required fields; the Apple Pay fields are optional
+			expectedFields := []string{"apiKey", "secretKey", "environment", "applePayMerchantId", "applePayPrivateKey"}
 			for i, field := range result {
 				if field.Key != expectedFields[i] {
 					t.Errorf("Expected field %s, got %s", expectedFields[i], field.Key)
 				}
-				if !field.Required {
-					t.Errorf("Field %s should be required", field.Key)
+				if field.Required != (i < 3) {
+					t.Errorf("Field %s has Required %v", field.Key, field.Required)
 				}
 				if field.Type != "string" {
 					t.Errorf("Field %s should be string type", field.Key)
@@ -900,3 +910,260 @@ func TestIyzicoProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestIyzicoProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &IyzicoProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointHealthCheck {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointHealthCheck {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestIyzicoProvider_Check3DSEnrollment(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    map[string]any
+		expected    provider.ThreeDSEnrollmentStatus
+		expectError bool
+	}{
+		{
+			name: "3DS forced",
+			response: map[string]any{
+				"status":             statusSuccess,
+				"installmentDetails": []any{map[string]any{"binNumber": "552879", "force3ds": 1}},
+			},
+			expected: provider.ThreeDSEnrolled,
+		},
+		{
+			name: "3DS not forced",
+			response: map[string]any{
+				"status":             statusSuccess,
+				"installmentDetails": []any{map[string]any{"binNumber": "552879", "force3ds": 0}},
+			},
+			expected: provider.ThreeDSEnrollmentUnknown,
+		},
+		{
+			name:     "no installment details",
+			response: map[string]any{"status": statusSuccess},
+			expected: provider.ThreeDSEnrollmentUnknown,
+		},
+		{
+			name:        "inquiry failed",
+			response:    map[string]any{"status": statusFailure, "errorMessage": "Geçersiz bin numarası"},
+			expected:    provider.ThreeDSEnrollmentUnknown,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != endpointInstallment {
+					t.Errorf("Expected request to %s, got %s", endpointInstallment, r.URL.Path)
+				}
+				_ = json.NewDecoder(r.Body).Decode(&requested)
+				_ = json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+
+			iyzicoProvider := &IyzicoProvider{
+				apiKey:    "test-key",
+				secretKey: "test-secret",
+				baseURL:   server.URL,
+				httpClient: provider.NewProviderHTTPClient(&provider.HTTPClientConfig{
+					BaseURL: server.URL,
+					Timeout: 5 * time.Second,
+				}),
+			}
+
+			status, err := iyzicoProvider.Check3DSEnrollment(context.Background(), "552879")
+			if tt.expectError != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.expectError, err)
+			}
+			if status != tt.expected {
+				t.Errorf("Expected status %s, got %s", tt.expected, status)
+			}
+			if requested["binNumber"] != "552879" {
+				t.Errorf("Expected binNumber 552879 in request, got %v", requested["binNumber"])
+			}
+		})
+	}
+}
+
+func TestIyzicoProvider_GetCardBinInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != endpointBinCheck {
+			t.Errorf("Expected request to %s, got %s", endpointBinCheck, r.URL.Path)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":          statusSuccess,
+			"binNumber":       "552879",
+			"cardType":        "CREDIT_CARD",
+			"cardAssociation": "MASTER_CARD",
+			"cardFamily":      "Paraf",
+			"bankName":        "Halkbank",
+			"bankCode":        12,
+		})
+	}))
+	defer server.Close()
+
+	iyzicoProvider := &IyzicoProvider{
+		apiKey:    "test-key",
+		secretKey: "test-secret",
+		baseURL:   server.URL,
+		httpClient: provider.NewProviderHTTPClient(&provider.HTTPClientConfig{
+			BaseURL: server.URL,
+			Timeout: 5 * time.Second,
+		}),
+	}
+
+	info, err := iyzicoProvider.GetCardBinInfo(context.Background(), "552879")
+	if err != nil {
+		t.Fatalf("GetCardBinInfo failed: %v", err)
+	}
+	expected := provider.CardBinInfo{BIN: "552879", CardType: "CREDIT_CARD", CardAssociation: "MASTER_CARD", BankName: "Halkbank"}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+}
+
+func TestCardBinInfoFromResponse(t *testing.T) {
+	// Payment responses carry the card fields without the bank name
+	info := cardBinInfoFromResponse(map[string]any{
+		"status":          statusSuccess,
+		"paymentId":       "12345",
+		"binNumber":       "979203",
+		"cardType":        "DEBIT_CARD",
+		"cardAssociation": "TROY",
+	})
+	expected := provider.CardBinInfo{BIN: "979203", CardType: "DEBIT_CARD", CardAssociation: "TROY"}
+	if info == nil || *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+
+	if info := cardBinInfoFromResponse(map[string]any{"status": statusFailure}); info != nil {
+		t.Errorf("Expected nil for a response without card fields, got %+v", info)
+	}
+}
+
+func TestThreeDSVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		resp         map[string]any
+		callbackData map[string]string
+		expected     string
+	}{
+		{
+			name:     "auth response",
+			resp:     map[string]any{"status": "success", "threeDSVersion": "2.2.0"},
+			expected: "2.2.0",
+		},
+		{
+			name:         "auth response wins over callback",
+			resp:         map[string]any{"threeDsVersion": "2.1.0"},
+			callbackData: map[string]string{"threeDSVersion": "1.0.2"},
+			expected:     "2.1.0",
+		},
+		{
+			name:         "callback data",
+			resp:         map[string]any{"status": "success"},
+			callbackData: map[string]string{"mdStatus": "1", "threeDSVersion": "1.0.2"},
+			expected:     "1.0.2",
+		},
+		{
+			name:         "not reported",
+			resp:         map[string]any{"status": "success"},
+			callbackData: map[string]string{"mdStatus": "1"},
+			expected:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := threeDSVersion(tt.resp, tt.callbackData); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIyzicoProvider_BasketTotal(t *testing.T) {
+	p := NewProvider().(*IyzicoProvider)
+
+	// Iyzico prices are line totals, the quantity is not sent
+	items := []provider.Item{
+		{ID: "1", Name: "Shoes", Price: 80, Quantity: 2},
+		{ID: "2", Name: "Socks", Price: 20.5, Quantity: 1},
+	}
+	if got := p.BasketTotal(items); got != 100.5 {
+		t.Errorf("expected 100.5, got %v", got)
+	}
+}
+
+func TestIyzicoProvider_ApplePayConfig(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	p := &IyzicoProvider{}
+	if err := p.Initialize(map[string]string{"apiKey": "test-api-key", "secretKey": "test-secret-key", "environment": "sandbox"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if len(p.SupportedWallets()) != 0 {
+		t.Errorf("Expected no wallets without an Apple Pay key, got %v", p.SupportedWallets())
+	}
+
+	if err := p.Initialize(map[string]string{"apiKey": "test-api-key", "secretKey": "test-secret-key", "applePayMerchantId": "merchant.com.example"}); err == nil {
+		t.Error("Expected an error for a merchant ID without a key")
+	}
+
+	conf := map[string]string{"apiKey": "test-api-key", "secretKey": "test-secret-key", "applePayMerchantId": "merchant.com.example", "applePayPrivateKey": pemKey}
+	t.Setenv("APPLE_PAY_ROOT_CA_FILE", "")
+	if err := p.Initialize(conf); err == nil {
+		t.Error("Expected an error without the Apple root certificate to verify tokens with")
+	}
+
+	// Any self-signed certificate stands in for Apple Root CA - G3
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Test Root CA - G3"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	rootDER, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	rootPath := filepath.Join(t.TempDir(), "AppleRootCA-G3.pem")
+	if err := os.WriteFile(rootPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APPLE_PAY_ROOT_CA_FILE", rootPath)
+	if err := p.Initialize(conf); err != nil {
+		t.Fatalf("Initialize with Apple Pay failed: %v", err)
+	}
+	if wallets := p.SupportedWallets(); len(wallets) != 1 || wallets[0] != provider.WalletTypeApplePay {
+		t.Errorf("Expected Apple Pay to be supported, got %v", wallets)
+	}
+
+	// An unsigned token is refused before anything is sent
+	request := provider.PaymentRequest{WalletPayment: &provider.WalletPayment{
+		Type:  provider.WalletTypeApplePay,
+		Token: &provider.ApplePayToken{PaymentData: provider.ApplePayPaymentData{Version: "EC_v1", Data: "ZGF0YQ==", Header: provider.ApplePayPaymentHeader{EphemeralPublicKey: "a2V5", PublicKeyHash: "aGFzaA=="}}},
+	}}
+	if err := p.applyWalletPayment(&request); !errors.Is(err, provider.ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid, got %v", err)
+	}
+}
+
+func TestIyzicoProvider_MapToIyzicoPaymentRequest_WithoutCVV(t *testing.T) {
+	p := &IyzicoProvider{}
+	request := provider.PaymentRequest{
+		Amount:   100,
+		Currency: "TRY",
+		Customer: provider.Customer{Name: "Ada", Surname: "Lovelace", Email: "ada@example.com"},
+		CardInfo: provider.CardInfo{CardHolderName: "Ada Lovelace", CardNumber: "4817499999990121", ExpireMonth: "12", ExpireYear: "2028"},
+	}
+
+	card := p.mapToIyzicoPaymentRequest(request, false)["paymentCard"].(map[string]any)
+	if _, ok := card["cvc"]; ok {
+		t.Errorf("Expected no cvc for a device card, got %v", card["cvc"])
+	}
+}
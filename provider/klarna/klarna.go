@@ -0,0 +1,941 @@
+package klarna
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API Endpoints
+	endpointSessions       = "/payments/v1/sessions"
+	endpointHPPSessions    = "/hpp/v1/sessions"
+	endpointAuthorizations = "/payments/v1/authorizations"
+	endpointOrders         = "/ordermanagement/v1/orders"
+
+	// Order statuses of the order management API
+	orderStatusAuthorized   = "AUTHORIZED"
+	orderStatusPartCaptured = "PART_CAPTURED"
+	orderStatusCaptured     = "CAPTURED"
+	orderStatusCancelled    = "CANCELLED"
+	orderStatusExpired      = "EXPIRED"
+	orderStatusClosed       = "CLOSED"
+
+	// Fraud statuses of an order
+	fraudStatusAccepted = "ACCEPTED"
+	fraudStatusPending  = "PENDING"
+	fraudStatusRejected = "REJECTED"
+
+	// Results the hosted payment page returns with, set on its merchant URLs
+	hppResultSuccess = "success"
+	hppResultCancel  = "cancel"
+	hppResultBack    = "back"
+	hppResultFailure = "failure"
+	hppResultError   = "error"
+
+	// errorCodeNoSuchOrder is the error code of an unknown order
+	errorCodeNoSuchOrder = "NO_SUCH_ORDER"
+)
+
+// regionURLs are the playground and production API URLs of each Klarna region
+var regionURLs = map[string]struct{ playground, production string }{
+	"eu": {"https://api.playground.klarna.com", "https://api.klarna.com"},
+	"na": {"https://api-na.playground.klarna.com", "https://api-na.klarna.com"},
+	"oc": {"https://api-oc.playground.klarna.com", "https://api-oc.klarna.com"},
+}
+
+// KlarnaProvider implements the provider.PaymentProvider interface for Klarna
+type KlarnaProvider struct {
+	username     string
+	password     string
+	baseURL      string
+	gopayBaseURL string
+	isProduction bool
+	httpClient   *provider.ProviderHTTPClient
+}
+
+// Ensure KlarnaProvider satisfies the optional capability interfaces.
+var (
+	_ provider.BNPLProvider    = (*KlarnaProvider)(nil)
+	_ provider.CaptureProvider = (*KlarnaProvider)(nil)
+)
+
+// NewProvider creates a new Klarna payment provider
+func NewProvider() provider.PaymentProvider {
+	return &KlarnaProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Klarna
+func (p *KlarnaProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "username",
+			Required:    true,
+			Type:        "string",
+			Description: "Klarna API username (UID) from the merchant portal",
+			Example:     "PK12345_1a2b3c4d5e6f",
+			MinLength:   5,
+			MaxLength:   100,
+		},
+		{
+			Key:         "password",
+			Required:    true,
+			Type:        "string",
+			Description: "Klarna API password from the merchant portal",
+			Example:     "klarna_test_api_abc123",
+			MinLength:   5,
+			MaxLength:   200,
+		},
+		{
+			Key:         "region",
+			Required:    false,
+			Type:        "string",
+			Description: "Klarna region of the merchant account: eu, na or oc (defaults to eu)",
+			Example:     "eu",
+			Pattern:     "^(eu|na|oc)$",
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Klarna requirements
+func (p *KlarnaProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("klarna", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies of the markets Klarna sells in
+func (p *KlarnaProvider) SupportedCurrencies() []string {
+	return []string{"EUR", "USD", "GBP", "SEK", "NOK", "DKK", "CHF", "PLN", "CZK", "RON", "AUD", "NZD", "CAD"}
+}
+
+// HealthCheckEndpoint returns the payment session endpoint, which rejects unauthenticated requests
+func (p *KlarnaProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = regionURLs["eu"].playground
+	}
+	return baseURL + endpointSessions
+}
+
+// Initialize sets up the Klarna payment provider with authentication credentials
+func (p *KlarnaProvider) Initialize(conf map[string]string) error {
+	p.username = conf["username"]
+	p.password = conf["password"]
+
+	if p.username == "" || p.password == "" {
+		return errors.New("klarna: username and password are required")
+	}
+
+	region := conf["region"]
+	if region == "" {
+		region = "eu"
+	}
+	urls, ok := regionURLs[region]
+	if !ok {
+		return fmt.Errorf("klarna: unknown region %q", region)
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = urls.playground
+	if p.isProduction {
+		p.baseURL = urls.production
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("klarna"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *KlarnaProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *KlarnaProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// errCardPayment is returned for card payments, which Klarna does not take
+var errCardPayment = errors.New("klarna: card payments are not supported, use paymentMethod bnpl")
+
+// CreatePayment is not supported by Klarna, whose payments are BNPL payments
+func (p *KlarnaProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errCardPayment
+}
+
+// Create3DPayment is not supported by Klarna, whose payments are BNPL payments
+func (p *KlarnaProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errCardPayment
+}
+
+// AuthorizePayment is not supported for cards; a BNPL payment with paymentType auth is
+// authorized on Klarna's page and captured later
+func (p *KlarnaProvider) AuthorizePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errCardPayment
+}
+
+// CreateBNPLPayment creates a payment session and a hosted payment page for it. The page
+// returns to the GoPay callback with the authorization token of the payment, whose order
+// Complete3DPayment places.
+func (p *KlarnaProvider) CreateBNPLPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request); err != nil {
+		return nil, fmt.Errorf("klarna: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	reference := request.ID
+	if reference == "" {
+		reference = provider.NewPaymentID()
+	}
+
+	order := buildOrder(request, reference)
+	var created session
+	errs, _, err := p.send(ctx, http.MethodPost, endpointSessions, order, &created)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "klarna", provider.CallbackState{
+		TenantID:         request.TenantID,
+		PaymentID:        reference,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		ConversationID:   created.SessionID,
+		LogID:            request.LogID,
+		Provider:         "klarna",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		SessionID:        request.SessionID,
+		PaymentType:      request.PaymentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	hppReq := hppSessionRequest{
+		PaymentSessionURL: p.baseURL + endpointSessions + "/" + created.SessionID,
+		MerchantURLs: hppMerchantURLs{
+			Success: gopayCallbackURL + "&result=" + hppResultSuccess + "&sid={{session_id}}&authorization_token={{authorization_token}}",
+			Cancel:  gopayCallbackURL + "&result=" + hppResultCancel,
+			Back:    gopayCallbackURL + "&result=" + hppResultBack,
+			Failure: gopayCallbackURL + "&result=" + hppResultFailure,
+			Error:   gopayCallbackURL + "&result=" + hppResultError,
+		},
+		Options: hppOptions{PlaceOrderMode: "NONE"},
+	}
+
+	var hpp hppSession
+	errs, _, err = p.send(ctx, http.MethodPost, endpointHPPSessions, hppReq, &hpp)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:           true,
+		Status:            provider.StatusPending,
+		Message:           "Redirect the customer to Klarna",
+		PaymentID:         reference,
+		TransactionID:     created.SessionID,
+		Amount:            request.Amount,
+		Currency:          request.Currency,
+		HostedCheckoutURL: hpp.RedirectURL,
+		RedirectURL:       hpp.RedirectURL,
+		SystemTime:        &now,
+		ProviderResponse:  hpp,
+	}, nil
+}
+
+// Complete3DPayment completes a BNPL payment when the hosted payment page returns. A
+// successful page places the order of the payment session with its authorization token.
+func (p *KlarnaProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("klarna: no callback data received")
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("klarna", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        callbackState.PaymentID,
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	switch data["result"] {
+	case hppResultSuccess:
+	case hppResultCancel, hppResultBack:
+		response.Status = provider.StatusCancelled
+		response.ErrorCode = strings.ToUpper(data["result"])
+		response.Message = "Payment cancelled by the customer"
+		return response, nil
+	default:
+		response.Status = provider.StatusFailed
+		response.ErrorCode = strings.ToUpper(data["result"])
+		response.Message = "Klarna did not authorize the payment"
+		return response, nil
+	}
+
+	authorizationToken := data["authorization_token"]
+	if authorizationToken == "" || authorizationToken == "{{authorization_token}}" {
+		return nil, errors.New("klarna: missing authorization_token in callback")
+	}
+
+	// The order must repeat the authorized session, which is read back from Klarna
+	var order orderRequest
+	errs, _, err := p.send(ctx, http.MethodGet, endpointSessions+"/"+url.PathEscape(callbackState.ConversationID), nil, &order)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.ErrorCode
+		response.Message = errs.message()
+		return response, nil
+	}
+	if order.MerchantReference1 != callbackState.PaymentID || order.OrderAmount != provider.ToMinorUnits(callbackState.Amount, callbackState.Currency) {
+		return nil, errors.New("klarna: payment session does not match the callback state")
+	}
+
+	order.AutoCapture = callbackState.PaymentType != provider.PaymentTypeAuth
+	order.MerchantURLs = &orderMerchantURLs{Notification: p.webhookURL(callbackState.TenantID)}
+
+	var placed placedOrder
+	errs, _, err = p.send(ctx, http.MethodPost, endpointAuthorizations+"/"+url.PathEscape(authorizationToken)+"/order", order, &placed)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.ErrorCode
+		response.Message = errs.message()
+		return response, nil
+	}
+
+	response.PaymentID = placed.OrderID
+	response.OrderID = callbackState.PaymentID
+	response.ProviderResponse = placed
+	response.PaymentMethodDetails = &provider.PaymentMethodDetails{Type: provider.PaymentMethodTypeBNPL, Network: "klarna"}
+
+	switch placed.FraudStatus {
+	case fraudStatusAccepted:
+		response.Success = true
+		response.Status = provider.StatusSuccessful
+		response.Message = "Order placed"
+		if !order.AutoCapture {
+			response.Status = provider.StatusAuthorized
+			response.Message = "Order authorized, capture it to charge the customer"
+		}
+	case fraudStatusPending:
+		response.Success = true
+		response.Status = provider.StatusPending
+		response.Message = "Order placed, Klarna is reviewing it"
+	default:
+		response.Status = provider.StatusFailed
+		response.ErrorCode = placed.FraudStatus
+		response.Message = "Klarna rejected the order"
+	}
+	return response, nil
+}
+
+// GetPaymentStatus retrieves the current status of an order
+func (p *KlarnaProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("klarna: paymentID is required")
+	}
+
+	result, errs, err := p.getOrder(ctx, request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:  request.PaymentID,
+		SystemTime: &now,
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.ErrorCode
+		response.Message = errs.message()
+		response.ProviderResponse = errs
+		if errs.ErrorCode == errorCodeNoSuchOrder {
+			response.ErrorCode = provider.ErrorCodePaymentNotFound
+			return response, fmt.Errorf("klarna: %w", provider.ErrPaymentNotFound)
+		}
+		return response, nil
+	}
+
+	response.Status = result.status()
+	response.Success = response.Status == provider.StatusSuccessful || response.Status == provider.StatusAuthorized
+	response.Message = result.Status
+	response.OrderID = result.MerchantReference1
+	response.Amount = provider.FromMinorUnits(result.OrderAmount, result.PurchaseCurrency)
+	response.Currency = result.PurchaseCurrency
+	response.ProviderTime = provider.ParseProviderTime(result.CreatedAt, time.UTC, time.RFC3339Nano)
+	response.PaymentMethodDetails = &provider.PaymentMethodDetails{Type: provider.PaymentMethodTypeBNPL, Network: "klarna"}
+	response.ProviderResponse = result
+	return response, nil
+}
+
+// CancelPayment cancels an order that has not been captured yet
+func (p *KlarnaProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("klarna: paymentID is required for cancel")
+	}
+
+	errs, _, err := p.send(ctx, http.MethodPost, p.orderEndpoint(request.PaymentID)+"/cancel", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:  request.PaymentID,
+		SystemTime: &now,
+	}
+	if errs == nil {
+		response.Success = true
+		response.Status = provider.StatusCancelled
+		response.Message = "Order cancelled"
+		return response, nil
+	}
+
+	response.Status = provider.StatusFailed
+	response.ErrorCode = errs.ErrorCode
+	response.Message = errs.message()
+	response.ProviderResponse = errs
+
+	// Klarna refuses to cancel an order it no longer holds without saying why, so the order
+	// tells whether it was captured or cancelled already
+	var failure error
+	if errs.ErrorCode == errorCodeNoSuchOrder {
+		response.ErrorCode, failure = provider.ErrorCodePaymentNotFound, provider.ErrPaymentNotFound
+	} else if order, orderErrs, orderErr := p.getOrder(ctx, request.PaymentID); orderErr == nil && orderErrs == nil {
+		switch order.Status {
+		case orderStatusCaptured, orderStatusPartCaptured, orderStatusClosed:
+			response.ErrorCode, failure = provider.ErrorCodeAlreadyCaptured, provider.ErrAlreadyCaptured
+		case orderStatusCancelled, orderStatusExpired:
+			response.ErrorCode, failure = provider.ErrorCodeAlreadyCancelled, provider.ErrAlreadyCancelled
+		}
+	}
+	if failure != nil {
+		return response, fmt.Errorf("klarna: %w", failure)
+	}
+	return response, nil
+}
+
+// CapturePayment captures an authorized order, in full or in part. Zero captures what is
+// left of the authorization.
+func (p *KlarnaProvider) CapturePayment(ctx context.Context, request provider.CaptureRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("klarna: paymentID is required for capture")
+	}
+
+	amount := provider.ToMinorUnits(request.Amount, request.Currency)
+	currency := request.Currency
+	if amount <= 0 || currency == "" {
+		order, errs, err := p.getOrder(ctx, request.PaymentID)
+		if err != nil {
+			return nil, err
+		}
+		if errs != nil {
+			return failedResponse(request.PaymentID, request.Amount, request.Currency, errs), nil
+		}
+		currency = order.PurchaseCurrency
+		amount = provider.ToMinorUnits(request.Amount, currency)
+		if amount <= 0 {
+			amount = order.RemainingAuthorizedAmount
+		}
+	}
+
+	errs, headers, err := p.send(ctx, http.MethodPost, p.orderEndpoint(request.PaymentID)+"/captures", captureRequest{CapturedAmount: amount}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	captured := provider.FromMinorUnits(amount, currency)
+	if errs != nil {
+		return failedResponse(request.PaymentID, captured, currency, errs), nil
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:       true,
+		Status:        provider.StatusSuccessful,
+		Message:       "Order captured",
+		PaymentID:     request.PaymentID,
+		TransactionID: headers.Get("Capture-Id"),
+		Amount:        captured,
+		Currency:      currency,
+		SystemTime:    &now,
+	}, nil
+}
+
+// RefundPayment refunds a captured order, in full or in part
+func (p *KlarnaProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("klarna: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("klarna: refund amount must be greater than 0")
+	}
+
+	refundReq := refundRequest{
+		RefundedAmount: provider.ToMinorUnits(request.RefundAmount, request.Currency),
+		Description:    request.Description,
+	}
+	errs, headers, err := p.send(ctx, http.MethodPost, p.orderEndpoint(request.PaymentID)+"/refunds", refundReq, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refundResp := &provider.RefundResponse{
+		Success:      errs == nil,
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+	}
+
+	if errs != nil {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = errs.ErrorCode
+		refundResp.Message = errs.message()
+		refundResp.RawResponse = errs
+		return refundResp, nil
+	}
+
+	refundResp.Status = "success"
+	refundResp.Message = "Refund successful"
+	refundResp.RefundID = headers.Get("Refund-Id")
+	return refundResp, nil
+}
+
+// ValidateWebhook handles Klarna's order notifications. They are not signed, so the order
+// they name is read back from Klarna and its own status is reported.
+func (p *KlarnaProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	orderID := data["order_id"]
+	if orderID == "" {
+		return false, nil, errors.New("klarna: missing order_id in notification")
+	}
+
+	order, errs, err := p.getOrder(ctx, orderID)
+	if err != nil {
+		return false, nil, err
+	}
+	if errs != nil {
+		return false, nil, fmt.Errorf("klarna: unknown order %s: %s", orderID, errs.ErrorCode)
+	}
+
+	status := "pending"
+	switch order.status() {
+	case provider.StatusSuccessful, provider.StatusAuthorized:
+		status = "success"
+	case provider.StatusFailed, provider.StatusCancelled:
+		status = "failed"
+	case provider.StatusRefunded:
+		status = "refunded"
+	}
+
+	return true, map[string]string{
+		"paymentId": orderID,
+		"status":    status,
+		"eventType": data["event_type"],
+	}, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *KlarnaProvider) validatePaymentRequest(request provider.PaymentRequest) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Currency == "" {
+		return errors.New("currency is required")
+	}
+
+	if request.CallbackURL == "" {
+		return errors.New("callback URL is required")
+	}
+
+	if purchaseCountry(request) == "" {
+		return errors.New("customer address country is required")
+	}
+
+	return nil
+}
+
+// getOrder reads an order from the order management API
+func (p *KlarnaProvider) getOrder(ctx context.Context, orderID string) (*order, *apiError, error) {
+	var result order
+	errs, _, err := p.send(ctx, http.MethodGet, p.orderEndpoint(orderID), nil, &result)
+	if err != nil || errs != nil {
+		return nil, errs, err
+	}
+	return &result, nil, nil
+}
+
+// orderEndpoint returns the endpoint of an order
+func (p *KlarnaProvider) orderEndpoint(orderID string) string {
+	return endpointOrders + "/" + url.PathEscape(orderID)
+}
+
+// webhookURL returns the GoPay webhook URL Klarna notifies of an order's fraud decision
+func (p *KlarnaProvider) webhookURL(tenantID int) string {
+	notificationURL := fmt.Sprintf("%s/v1/webhooks/klarna", p.gopayBaseURL)
+	if tenantID != 0 {
+		notificationURL += fmt.Sprintf("?tenantId=%d", tenantID)
+	}
+	return notificationURL
+}
+
+// send sends an authenticated request, decoding a successful response into result. A request
+// Klarna rejects returns its error instead of an error.
+func (p *KlarnaProvider) send(ctx context.Context, method, endpoint string, body any, result any) (*apiError, http.Header, error) {
+	httpReq := &provider.HTTPRequest{
+		Method:   method,
+		Endpoint: endpoint,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(p.username+":"+p.password)),
+		},
+	}
+	if body != nil {
+		httpReq.Body = body
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, httpReq)
+	if err != nil && resp == nil {
+		return nil, nil, fmt.Errorf("klarna: request failed: %w", err)
+	}
+
+	if err != nil {
+		var errs apiError
+		if parseErr := p.httpClient.ParseJSONResponse(resp, &errs); parseErr != nil || errs.ErrorCode == "" {
+			return nil, resp.Headers, fmt.Errorf("klarna: request failed: %w", err)
+		}
+		return &errs, resp.Headers, nil
+	}
+
+	if result != nil && len(resp.Body) > 0 {
+		if err := p.httpClient.ParseJSONResponse(resp, result); err != nil {
+			return nil, resp.Headers, fmt.Errorf("klarna: %w", err)
+		}
+	}
+	return nil, resp.Headers, nil
+}
+
+// failedResponse maps a request Klarna rejected
+func failedResponse(paymentID string, amount float64, currency string, errs *apiError) *provider.PaymentResponse {
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Status:           provider.StatusFailed,
+		ErrorCode:        errs.ErrorCode,
+		Message:          errs.message(),
+		PaymentID:        paymentID,
+		Amount:           amount,
+		Currency:         currency,
+		SystemTime:       &now,
+		ProviderResponse: errs,
+	}
+}
+
+// buildOrder builds the payment session of a payment. Klarna requires the order lines to add
+// up to the order amount, so a payment without matching items is sent as a single line.
+func buildOrder(request provider.PaymentRequest, reference string) *orderRequest {
+	currency := request.Currency
+	amount := provider.ToMinorUnits(request.Amount, currency)
+
+	var lines []orderLine
+	var sum int64
+	for _, item := range request.Items {
+		quantity := int64(max(item.Quantity, 1))
+		unitPrice := provider.ToMinorUnits(item.Price, currency)
+		lines = append(lines, orderLine{
+			Type:        "physical",
+			Reference:   item.ID,
+			Name:        item.Name,
+			Quantity:    quantity,
+			UnitPrice:   unitPrice,
+			TotalAmount: unitPrice * quantity,
+		})
+		sum += unitPrice * quantity
+	}
+	if len(lines) == 0 || sum != amount {
+		name := request.Description
+		if name == "" {
+			name = "Payment"
+		}
+		lines = []orderLine{{Type: "physical", Reference: reference, Name: name, Quantity: 1, UnitPrice: amount, TotalAmount: amount}}
+	}
+
+	country := purchaseCountry(request)
+	order := &orderRequest{
+		PurchaseCountry:    country,
+		PurchaseCurrency:   currency,
+		Locale:             locale(request.Locale, country),
+		OrderAmount:        amount,
+		OrderLines:         lines,
+		MerchantReference1: reference,
+		Intent:             "buy",
+	}
+
+	customer := request.Customer
+	if customer.Name != "" || customer.Email != "" {
+		billing := &address{
+			GivenName:  customer.Name,
+			FamilyName: customer.Surname,
+			Email:      customer.Email,
+			Phone:      customer.PhoneNumber,
+			Country:    country,
+		}
+		if customer.Address != nil {
+			billing.StreetAddress = customer.Address.Address
+			billing.PostalCode = customer.Address.ZipCode
+			billing.City = customer.Address.City
+		}
+		order.BillingAddress = billing
+	}
+	return order
+}
+
+// purchaseCountry returns the country the customer buys from: their address country, or
+// the country of their connection
+func purchaseCountry(request provider.PaymentRequest) string {
+	if request.Customer.Address != nil && len(request.Customer.Address.Country) == 2 {
+		return strings.ToUpper(request.Customer.Address.Country)
+	}
+	if len(request.ClientCountry) == 2 {
+		return strings.ToUpper(request.ClientCountry)
+	}
+	return ""
+}
+
+// locale returns the RFC 1766 locale of the payment page, English in the purchase country
+// unless the request names a full locale
+func locale(requested, country string) string {
+	if strings.Contains(requested, "-") {
+		return requested
+	}
+	if requested == "" {
+		requested = "en"
+	}
+	return strings.ToLower(requested) + "-" + country
+}
+
+// apiError is the body of a request Klarna rejected
+type apiError struct {
+	ErrorCode     string   `json:"error_code"`
+	ErrorMessages []string `json:"error_messages"`
+	CorrelationID string   `json:"correlation_id"`
+}
+
+// message returns the error messages of the error
+func (e *apiError) message() string {
+	if len(e.ErrorMessages) == 0 {
+		return e.ErrorCode
+	}
+	return strings.Join(e.ErrorMessages, "; ")
+}
+
+// orderRequest is a payment session, and the order placed for it
+type orderRequest struct {
+	PurchaseCountry    string             `json:"purchase_country"`
+	PurchaseCurrency   string             `json:"purchase_currency"`
+	Locale             string             `json:"locale"`
+	OrderAmount        int64              `json:"order_amount"`
+	OrderTaxAmount     int64              `json:"order_tax_amount"`
+	OrderLines         []orderLine        `json:"order_lines"`
+	MerchantReference1 string             `json:"merchant_reference1,omitempty"`
+	BillingAddress     *address           `json:"billing_address,omitempty"`
+	Intent             string             `json:"intent,omitempty"`
+	Status             string             `json:"status,omitempty"`
+	ExpiresAt          string             `json:"expires_at,omitempty"`
+	AutoCapture        bool               `json:"auto_capture,omitempty"`
+	MerchantURLs       *orderMerchantURLs `json:"merchant_urls,omitempty"`
+}
+
+// orderLine is a line of an order, in minor units
+type orderLine struct {
+	Type           string `json:"type,omitempty"`
+	Reference      string `json:"reference,omitempty"`
+	Name           string `json:"name"`
+	Quantity       int64  `json:"quantity"`
+	UnitPrice      int64  `json:"unit_price"`
+	TaxRate        int64  `json:"tax_rate"`
+	TotalAmount    int64  `json:"total_amount"`
+	TotalTaxAmount int64  `json:"total_tax_amount"`
+}
+
+// address is the billing address of an order
+type address struct {
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Phone         string `json:"phone,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	City          string `json:"city,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// orderMerchantURLs are the URLs Klarna calls about a placed order
+type orderMerchantURLs struct {
+	Notification string `json:"notification,omitempty"`
+}
+
+// session is a created payment session
+type session struct {
+	SessionID               string                  `json:"session_id"`
+	ClientToken             string                  `json:"client_token"`
+	PaymentMethodCategories []paymentMethodCategory `json:"payment_method_categories"`
+}
+
+// paymentMethodCategory is a way to pay Klarna offers for a session, e.g. pay_later
+type paymentMethodCategory struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+}
+
+// hppSessionRequest creates a hosted payment page for a payment session
+type hppSessionRequest struct {
+	PaymentSessionURL string          `json:"payment_session_url"`
+	MerchantURLs      hppMerchantURLs `json:"merchant_urls"`
+	Options           hppOptions      `json:"options"`
+}
+
+// hppMerchantURLs are the URLs the hosted payment page returns to
+type hppMerchantURLs struct {
+	Success string `json:"success"`
+	Cancel  string `json:"cancel"`
+	Back    string `json:"back"`
+	Failure string `json:"failure"`
+	Error   string `json:"error"`
+}
+
+// hppOptions are the options of a hosted payment page. With place_order_mode NONE the
+// merchant places the order with the authorization token.
+type hppOptions struct {
+	PlaceOrderMode string `json:"place_order_mode"`
+}
+
+// hppSession is a created hosted payment page
+type hppSession struct {
+	SessionID       string `json:"session_id"`
+	RedirectURL     string `json:"redirect_url"`
+	SessionURL      string `json:"session_url"`
+	QRCodeURL       string `json:"qr_code_url"`
+	DistributionURL string `json:"distribution_url"`
+	ExpiresAt       string `json:"expires_at"`
+}
+
+// placedOrder is an order placed with an authorization token
+type placedOrder struct {
+	OrderID                 string                   `json:"order_id"`
+	RedirectURL             string                   `json:"redirect_url"`
+	FraudStatus             string                   `json:"fraud_status"`
+	AuthorizedPaymentMethod *authorizedPaymentMethod `json:"authorized_payment_method"`
+}
+
+// authorizedPaymentMethod is the plan the customer picked
+type authorizedPaymentMethod struct {
+	Type                 string `json:"type"`
+	NumberOfInstallments int    `json:"number_of_installments"`
+	NumberOfDays         int    `json:"number_of_days"`
+}
+
+// order is an order of the order management API, amounts in minor units
+type order struct {
+	OrderID                   string `json:"order_id"`
+	Status                    string `json:"status"`
+	FraudStatus               string `json:"fraud_status"`
+	OrderAmount               int64  `json:"order_amount"`
+	OriginalOrderAmount       int64  `json:"original_order_amount"`
+	CapturedAmount            int64  `json:"captured_amount"`
+	RefundedAmount            int64  `json:"refunded_amount"`
+	RemainingAuthorizedAmount int64  `json:"remaining_authorized_amount"`
+	PurchaseCurrency          string `json:"purchase_currency"`
+	PurchaseCountry           string `json:"purchase_country"`
+	MerchantReference1        string `json:"merchant_reference1"`
+	CreatedAt                 string `json:"created_at"`
+	ExpiresAt                 string `json:"expires_at"`
+}
+
+// status maps the order and fraud status of an order
+func (o *order) status() provider.PaymentStatus {
+	switch o.FraudStatus {
+	case fraudStatusPending:
+		return provider.StatusPending
+	case fraudStatusRejected:
+		return provider.StatusFailed
+	}
+
+	switch o.Status {
+	case orderStatusAuthorized:
+		return provider.StatusAuthorized
+	case orderStatusPartCaptured, orderStatusCaptured, orderStatusClosed:
+		if o.RefundedAmount > 0 {
+			return provider.StatusRefunded
+		}
+		if o.CapturedAmount == 0 {
+			return provider.StatusCancelled
+		}
+		return provider.StatusSuccessful
+	case orderStatusCancelled, orderStatusExpired:
+		return provider.StatusCancelled
+	}
+	return provider.StatusUnknown
+}
+
+// captureRequest captures an amount of an order
+type captureRequest struct {
+	CapturedAmount int64  `json:"captured_amount"`
+	Description    string `json:"description,omitempty"`
+}
+
+// refundRequest refunds an amount of an order
+type refundRequest struct {
+	RefundedAmount int64  `json:"refunded_amount"`
+	Description    string `json:"description,omitempty"`
+}
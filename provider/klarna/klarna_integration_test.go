@@ -0,0 +1,72 @@
+package klarna
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// setupRealTestProvider returns a playground provider from KLARNA_USERNAME and
+// KLARNA_PASSWORD, skipping the test when they are not set
+func setupRealTestProvider(t *testing.T) *KlarnaProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"username":    os.Getenv("KLARNA_USERNAME"),
+		"password":    os.Getenv("KLARNA_PASSWORD"),
+		"region":      os.Getenv("KLARNA_REGION"),
+		"environment": "sandbox",
+	}
+	if config["username"] == "" || config["password"] == "" {
+		t.Skip("klarna playground credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*KlarnaProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestKlarnaProvider_RealAPI_CreateBNPLPayment creates a payment session and its hosted
+// payment page. Completing it needs a customer on the page, so it stops at the redirect.
+func TestKlarnaProvider_RealAPI_CreateBNPLPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	response, err := p.CreateBNPLPayment(context.Background(), provider.PaymentRequest{
+		TenantID:      1,
+		Amount:        10.00,
+		Currency:      "EUR",
+		CallbackURL:   "https://example.com/return",
+		PaymentMethod: provider.PaymentMethodTypeBNPL,
+		Customer: provider.Customer{
+			Name:    "Testperson-de",
+			Surname: "Approved",
+			Email:   "customer@email.de",
+			Address: &provider.Address{Country: "DE", City: "Berlin", Address: "Hellersbergstraße 14", ZipCode: "41460"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateBNPLPayment failed: %v", err)
+	}
+	t.Logf("Session: success=%v paymentID=%s session=%s page=%s message=%s", response.Success, response.PaymentID, response.TransactionID, response.HostedCheckoutURL, response.Message)
+	if response.Success && response.HostedCheckoutURL == "" {
+		t.Error("Expected a hosted payment page URL")
+	}
+}
+
+// TestKlarnaProvider_RealAPI_UnknownOrder queries an order that does not exist
+func TestKlarnaProvider_RealAPI_UnknownOrder(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "00000000-0000-0000-0000-000000000000"})
+	t.Logf("Status: %+v (%v)", response, err)
+}
@@ -0,0 +1,488 @@
+package klarna
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"username":    "PK12345_1a2b3c4d5e6f",
+		"password":    "klarna_test_api_abc123",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	klarnaProvider, ok := p.(*KlarnaProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a KlarnaProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if klarnaProvider.httpClient != nil {
+		t.Error("KlarnaProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := klarnaProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if klarnaProvider.httpClient == nil {
+		t.Error("KlarnaProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestKlarnaProvider_Initialize(t *testing.T) {
+	with := func(key, value string) map[string]string {
+		config := testConfig("sandbox")
+		config[key] = value
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+		baseURL     string
+	}{
+		{"sandbox", testConfig("sandbox"), false, "https://api.playground.klarna.com"},
+		{"production", testConfig("production"), false, "https://api.klarna.com"},
+		{"north america", with("region", "na"), false, "https://api-na.playground.klarna.com"},
+		{"unknown region", with("region", "apac"), true, ""},
+		{"missing username", with("username", ""), true, ""},
+		{"missing password", with("password", ""), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &KlarnaProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL {
+				t.Errorf("Expected %s, got %s", tt.baseURL, p.baseURL)
+			}
+		})
+	}
+}
+
+func TestKlarnaProvider_GetRequiredConfig(t *testing.T) {
+	p := &KlarnaProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	required := map[string]bool{"username": true, "password": true, "region": false, "environment": true}
+	if len(fields) != len(required) {
+		t.Fatalf("Expected %d config fields, got %d", len(required), len(fields))
+	}
+	for _, field := range fields {
+		if expected, ok := required[field.Key]; !ok || field.Required != expected {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestBuildOrder(t *testing.T) {
+	request := bnplRequest()
+	request.Items = []provider.Item{
+		{ID: "1", Name: "Shoes", Price: 40, Quantity: 2},
+		{ID: "2", Name: "Socks", Price: 20.5, Quantity: 1},
+	}
+
+	order := buildOrder(request, "gp1")
+	if order.PurchaseCountry != "DE" || order.PurchaseCurrency != "EUR" || order.Locale != "en-DE" || order.OrderAmount != 10050 {
+		t.Errorf("Unexpected order %+v", order)
+	}
+	if len(order.OrderLines) != 2 || order.OrderLines[0].UnitPrice != 4000 || order.OrderLines[0].TotalAmount != 8000 {
+		t.Errorf("Expected the items as order lines, got %+v", order.OrderLines)
+	}
+	if order.BillingAddress == nil || order.BillingAddress.GivenName != "Max" || order.BillingAddress.PostalCode != "10115" {
+		t.Errorf("Unexpected billing address %+v", order.BillingAddress)
+	}
+
+	request.Items = request.Items[:1]
+	request.Locale = "de-DE"
+	order = buildOrder(request, "gp1")
+	if len(order.OrderLines) != 1 || order.OrderLines[0].Reference != "gp1" || order.OrderLines[0].TotalAmount != 10050 || order.Locale != "de-DE" {
+		t.Errorf("Expected a single line for items that do not add up, got %+v", order)
+	}
+}
+
+// newTestProvider returns a provider whose API is answered by handler, after checking the
+// request is authenticated
+func newTestProvider(t *testing.T, handler func(r *http.Request, body map[string]any) (int, any)) *KlarnaProvider {
+	t.Helper()
+	p := providertest.Initialize[*KlarnaProvider](t, NewProvider, testConfig("sandbox"))
+
+	api := providertest.JSON(handler)
+	server := providertest.Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != p.username || password != p.password {
+			t.Errorf("Unauthenticated request to %s", r.URL)
+		}
+		switch strings.TrimPrefix(r.URL.Path, endpointOrders+"/order-1") {
+		case "/captures":
+			w.Header().Set("Capture-Id", "capture-1")
+		case "/refunds":
+			w.Header().Set("Refund-Id", "refund-1")
+		}
+		api(w, r)
+	})
+
+	p.baseURL = server.URL
+	p.gopayBaseURL = "https://gopay.example.com"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("klarna"))
+	return p
+}
+
+func bnplRequest() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:      1,
+		Amount:        100.50,
+		Currency:      "EUR",
+		CallbackURL:   "https://merchant.example.com/return",
+		PaymentMethod: provider.PaymentMethodTypeBNPL,
+		Customer: provider.Customer{
+			Name:    "Max",
+			Surname: "Mustermann",
+			Email:   "max@example.com",
+			Address: &provider.Address{Country: "de", City: "Berlin", Address: "Unter den Linden 1", ZipCode: "10115"},
+		},
+	}
+}
+
+func orderData(status string) map[string]any {
+	return map[string]any{
+		"order_id":                    "order-1",
+		"status":                      status,
+		"fraud_status":                fraudStatusAccepted,
+		"order_amount":                10050,
+		"original_order_amount":       10050,
+		"captured_amount":             0,
+		"refunded_amount":             0,
+		"remaining_authorized_amount": 10050,
+		"purchase_currency":           "EUR",
+		"purchase_country":            "DE",
+		"merchant_reference1":         "gp1",
+		"created_at":                  "2024-01-15T10:30:00.000Z",
+		"expires_at":                  "2024-02-12T10:30:00.000Z",
+	}
+}
+
+func TestKlarnaProvider_CreateBNPLPayment(t *testing.T) {
+	store := provider.NewMemoryCallbackStateStore()
+	provider.SetCallbackStateStore(store)
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	var hppReq map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointSessions:
+			if body["order_amount"] != float64(10050) || body["purchase_country"] != "DE" || body["merchant_reference1"] == "" {
+				t.Errorf("Unexpected session request %v", body)
+			}
+			return http.StatusOK, map[string]any{"session_id": "session-1", "client_token": "token", "payment_method_categories": []any{map[string]any{"identifier": "pay_later", "name": "Pay later"}}}
+		case endpointHPPSessions:
+			hppReq = body
+			return http.StatusOK, map[string]any{"session_id": "hpp-1", "redirect_url": "https://pay.playground.klarna.com/eu/hpp/payments/hpp-1"}
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+
+	request := bnplRequest()
+	request.PaymentType = provider.PaymentTypeAuth
+	response, err := p.CreateBNPLPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreateBNPLPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusPending || response.HostedCheckoutURL != "https://pay.playground.klarna.com/eu/hpp/payments/hpp-1" || response.TransactionID != "session-1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	if hppReq["payment_session_url"] != p.baseURL+endpointSessions+"/session-1" {
+		t.Errorf("Unexpected payment session URL %v", hppReq["payment_session_url"])
+	}
+	success, err := url.Parse(hppReq["merchant_urls"].(map[string]any)["success"].(string))
+	if err != nil || !strings.HasPrefix(success.String(), "https://gopay.example.com/v1/callback/klarna?state=") || success.Query().Get("authorization_token") != "{{authorization_token}}" {
+		t.Fatalf("Unexpected success URL %v", success)
+	}
+
+	state, err := store.Get(context.Background(), success.Query().Get("state"))
+	if err != nil {
+		t.Fatalf("Callback state not stored: %v", err)
+	}
+	if state.PaymentID != response.PaymentID || state.ConversationID != "session-1" || state.PaymentType != provider.PaymentTypeAuth || state.OriginalCallback != request.CallbackURL {
+		t.Errorf("Unexpected callback state %+v", state)
+	}
+
+	request.Customer.Address = nil
+	if _, err := p.CreateBNPLPayment(context.Background(), request); err == nil {
+		t.Error("Expected an error without a purchase country")
+	}
+}
+
+func TestKlarnaProvider_Complete3DPayment(t *testing.T) {
+	var placed map[string]any
+	fraudStatus := fraudStatusAccepted
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointSessions + "/session-1":
+			order := buildOrder(bnplRequest(), "gp1")
+			order.Status = "complete"
+			return http.StatusOK, order
+		case endpointAuthorizations + "/auth-token/order":
+			placed = body
+			return http.StatusOK, map[string]any{"order_id": "order-1", "redirect_url": "https://example.com", "fraud_status": fraudStatus, "authorized_payment_method": map[string]any{"type": "invoice", "number_of_installments": 0, "number_of_days": 30}}
+		case endpointAuthorizations + "/expired/order":
+			return http.StatusBadRequest, map[string]any{"error_code": "INVALID_AUTHORIZATION_TOKEN", "error_messages": []string{"Authorization token expired"}, "correlation_id": "c1"}
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+
+	callbackState := func(paymentType string) *provider.CallbackState {
+		return &provider.CallbackState{
+			TenantID:         7,
+			PaymentID:        "gp1",
+			OriginalCallback: "https://merchant.example.com/return",
+			Amount:           100.50,
+			Currency:         "EUR",
+			ConversationID:   "session-1",
+			Provider:         "klarna",
+			Environment:      "sandbox",
+			PaymentType:      paymentType,
+		}
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState(""), map[string]string{"result": "success", "sid": "hpp-1", "authorization_token": "auth-token"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if placed["auto_capture"] != true || placed["merchant_urls"].(map[string]any)["notification"] != "https://gopay.example.com/v1/webhooks/klarna?tenantId=7" {
+		t.Errorf("Unexpected order %v", placed)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "order-1" || response.OrderID != "gp1" || response.RedirectURL != "https://merchant.example.com/return" {
+		t.Errorf("Unexpected response for a placed order: %+v", response)
+	}
+	if response.PaymentMethodDetails == nil || response.PaymentMethodDetails.Type != provider.PaymentMethodTypeBNPL {
+		t.Errorf("Expected BNPL payment method details, got %+v", response.PaymentMethodDetails)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState(provider.PaymentTypeAuth), map[string]string{"result": "success", "authorization_token": "auth-token"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if _, ok := placed["auto_capture"]; ok || response.Status != provider.StatusAuthorized || !response.Success {
+		t.Errorf("Expected an authorized order without auto capture, got %+v for %v", response, placed)
+	}
+
+	fraudStatus = fraudStatusRejected
+	if response, err := p.Complete3DPayment(ctx, callbackState(""), map[string]string{"result": "success", "authorization_token": "auth-token"}); err != nil || response.Success || response.Status != provider.StatusFailed {
+		t.Errorf("Expected a rejected order to fail, got %+v (%v)", response, err)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState(""), map[string]string{"result": "success", "authorization_token": "expired"})
+	if err != nil || response.Success || response.ErrorCode != "INVALID_AUTHORIZATION_TOKEN" || response.Message != "Authorization token expired" {
+		t.Errorf("Expected Klarna's error, got %+v (%v)", response, err)
+	}
+
+	placed = nil
+	response, err = p.Complete3DPayment(ctx, callbackState(""), map[string]string{"result": "cancel"})
+	if err != nil || placed != nil || response.Status != provider.StatusCancelled || response.PaymentID != "gp1" {
+		t.Errorf("Expected a cancelled payment without an order, got %+v (%v)", response, err)
+	}
+
+	other := callbackState("")
+	other.Amount = 1
+	if _, err := p.Complete3DPayment(ctx, other, map[string]string{"result": "success", "authorization_token": "auth-token"}); err == nil {
+		t.Error("Expected a session of another amount to be rejected")
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState(""), map[string]string{"result": "success", "authorization_token": "{{authorization_token}}"}); err == nil {
+		t.Error("Expected an error without an authorization token")
+	}
+}
+
+func TestKlarnaProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch strings.TrimPrefix(r.URL.Path, endpointOrders+"/") {
+		case "order-1":
+			return http.StatusOK, orderData(orderStatusAuthorized)
+		case "order-2":
+			data := orderData(orderStatusCaptured)
+			data["captured_amount"] = 10050
+			data["refunded_amount"] = 1000
+			return http.StatusOK, data
+		}
+		return http.StatusNotFound, map[string]any{"error_code": errorCodeNoSuchOrder, "error_messages": []string{"Order not found"}, "correlation_id": "c1"}
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "order-1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusAuthorized || response.Amount != 100.50 || response.Currency != "EUR" || response.OrderID != "gp1" || response.ProviderTime == nil {
+		t.Errorf("Unexpected status: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "order-2"}); err != nil || response.Status != provider.StatusRefunded {
+		t.Errorf("Expected a refunded order, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "order-9"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestKlarnaProvider_CaptureAndRefund(t *testing.T) {
+	var captured, refunded map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointOrders + "/order-1":
+			data := orderData(orderStatusPartCaptured)
+			data["remaining_authorized_amount"] = 5050
+			return http.StatusOK, data
+		case endpointOrders + "/order-1/captures":
+			captured = body
+			return http.StatusCreated, nil
+		case endpointOrders + "/order-1/refunds":
+			refunded = body
+			if body["refunded_amount"] == float64(99999) {
+				return http.StatusForbidden, map[string]any{"error_code": "REFUND_NOT_ALLOWED", "error_messages": []string{"Refunded amount exceeds captured amount"}, "correlation_id": "c1"}
+			}
+			return http.StatusCreated, nil
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+	ctx := context.Background()
+
+	response, err := p.CapturePayment(ctx, provider.CaptureRequest{PaymentID: "order-1", Amount: 50, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if captured["captured_amount"] != float64(5000) || !response.Success || response.TransactionID != "capture-1" || response.Amount != 50 {
+		t.Errorf("Unexpected capture %+v of %v", response, captured)
+	}
+
+	// Without an amount the rest of the authorization is captured
+	response, err = p.CapturePayment(ctx, provider.CaptureRequest{PaymentID: "order-1"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if captured["captured_amount"] != float64(5050) || response.Amount != 50.50 || response.Currency != "EUR" {
+		t.Errorf("Expected the remaining authorization to be captured, got %+v of %v", response, captured)
+	}
+
+	refund, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "order-1", RefundAmount: 10, Currency: "EUR", Description: "Returned socks"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refunded["refunded_amount"] != float64(1000) || refunded["description"] != "Returned socks" || !refund.Success || refund.RefundID != "refund-1" {
+		t.Errorf("Unexpected refund %+v of %v", refund, refunded)
+	}
+
+	refund, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "order-1", RefundAmount: 999.99, Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund.Success || refund.ErrorCode != "REFUND_NOT_ALLOWED" {
+		t.Errorf("Unexpected declined refund: %+v", refund)
+	}
+
+	if _, err := p.AuthorizePayment(ctx, bnplRequest()); err == nil {
+		t.Error("Expected card authorizations to be unsupported")
+	}
+}
+
+func TestKlarnaProvider_CancelPayment(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointOrders + "/order-1/cancel":
+			return http.StatusNoContent, nil
+		case endpointOrders + "/order-2/cancel":
+			return http.StatusForbidden, map[string]any{"error_code": "NOT_ALLOWED", "error_messages": []string{"Cancel not allowed"}, "correlation_id": "c1"}
+		case endpointOrders + "/order-2":
+			data := orderData(orderStatusCaptured)
+			data["captured_amount"] = 10050
+			return http.StatusOK, data
+		}
+		return http.StatusNotFound, map[string]any{"error_code": errorCodeNoSuchOrder, "error_messages": []string{"Order not found"}, "correlation_id": "c1"}
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "order-1"})
+	if err != nil || !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a cancelled order, got %+v (%v)", response, err)
+	}
+
+	response, err = p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "order-2"})
+	if !errors.Is(err, provider.ErrAlreadyCaptured) || response.ErrorCode != provider.ErrorCodeAlreadyCaptured {
+		t.Errorf("Expected ErrAlreadyCaptured for a captured order, got %+v (%v)", response, err)
+	}
+
+	response, err = p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "order-9"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestKlarnaProvider_ValidateWebhook(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path == endpointOrders+"/order-1" {
+			return http.StatusOK, orderData(orderStatusAuthorized)
+		}
+		return http.StatusNotFound, map[string]any{"error_code": errorCodeNoSuchOrder, "error_messages": []string{"Order not found"}, "correlation_id": "c1"}
+	})
+	ctx := context.Background()
+
+	valid, result, err := p.ValidateWebhook(ctx, map[string]string{"order_id": "order-1", "event_type": "FRAUD_RISK_ACCEPTED"}, nil)
+	if err != nil || !valid {
+		t.Fatalf("Expected a valid notification, got %v (%v)", valid, err)
+	}
+	if result["paymentId"] != "order-1" || result["status"] != "success" || result["eventType"] != "FRAUD_RISK_ACCEPTED" {
+		t.Errorf("Unexpected webhook result %v", result)
+	}
+
+	if valid, _, err := p.ValidateWebhook(ctx, map[string]string{"order_id": "order-9", "event_type": "FRAUD_RISK_ACCEPTED"}, nil); valid || err == nil {
+		t.Error("Expected a notification of an unknown order to be rejected")
+	}
+	if valid, _, err := p.ValidateWebhook(ctx, map[string]string{}, nil); valid || err == nil {
+		t.Error("Expected a notification without order_id to be rejected")
+	}
+}
+
+func TestKlarnaProvider_CardPaymentsUnsupported(t *testing.T) {
+	p := &KlarnaProvider{}
+	if _, err := p.CreatePayment(context.Background(), bnplRequest()); err == nil {
+		t.Error("Expected card payments to be unsupported")
+	}
+	if _, err := p.Create3DPayment(context.Background(), bnplRequest()); err == nil {
+		t.Error("Expected 3D card payments to be unsupported")
+	}
+}
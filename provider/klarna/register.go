@@ -0,0 +1,8 @@
+package klarna
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Klarna provider with the global registry
+	provider.Register("klarna", NewProvider)
+}
@@ -0,0 +1,922 @@
+package kuveytturk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// 3D model endpoints
+	payGateSandboxURL          = "https://boatest.kuveytturk.com.tr/boa.virtualpos.services/Home/ThreeDModelPayGate"
+	payGateProductionURL       = "https://sanalpos.kuveytturk.com.tr/ServiceGateWay/Home/ThreeDModelPayGate"
+	provisionGateSandboxURL    = "https://boatest.kuveytturk.com.tr/boa.virtualpos.services/Home/ThreeDModelProvisionGate"
+	provisionGateProductionURL = "https://sanalpos.kuveytturk.com.tr/ServiceGateWay/Home/ThreeDModelProvisionGate"
+
+	// SOAP service for cancels and refunds
+	soapSandboxURL    = "https://boatest.kuveytturk.com.tr/BOA.Integration.WCFService/BOA.Integration.VirtualPos/VirtualPosService.svc/Basic"
+	soapProductionURL = "https://boa.kuveytturk.com.tr/BOA.Integration.WCFService/BOA.Integration.VirtualPos/VirtualPosService.svc/Basic"
+	soapNamespace     = "http://boa.net/BOA.Integration.VirtualPos/Service"
+	soapActionPrefix  = soapNamespace + "/IVirtualPosService/"
+
+	// API versions of the 3D model and the SOAP service
+	apiVersion     = "TDV2.0.0"
+	soapAPIVersion = "1.0.0"
+
+	// Transaction types
+	txnTypeSale           = "Sale"
+	txnTypeCancel         = "SaleReversal"
+	txnTypeRefund         = "DrawBack"
+	txnTypePartialRefund  = "PartialDrawback"
+	transactionSecurity3D = 3
+
+	// Default currency, sent as its zero padded ISO 4217 numeric code
+	defaultCurrency = "TRY"
+
+	// Response code of an approved authentication or provision
+	approvedCode = "00"
+
+	// provisionLogKey holds the provision the refund and cancel requests refer to
+	provisionLogKey = "provisionResponse"
+)
+
+// KuveytTurkProvider implements the provider.PaymentProvider interface for Kuveyt Türk
+type KuveytTurkProvider struct {
+	merchantID       string
+	customerID       string
+	username         string
+	password         string
+	payGateURL       string
+	provisionGateURL string
+	soapURL          string
+	gopayBaseURL     string
+	isProduction     bool
+	httpClient       *provider.ProviderHTTPClient
+}
+
+// NewProvider creates a new Kuveyt Türk payment provider
+func NewProvider() provider.PaymentProvider {
+	return &KuveytTurkProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Kuveyt Türk
+func (p *KuveytTurkProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "merchantId",
+			Required:    true,
+			Type:        "string",
+			Description: "Kuveyt Türk Merchant ID (Mağaza No)",
+			Example:     "496",
+			Pattern:     "^[0-9]{1,10}$",
+		},
+		{
+			Key:         "customerId",
+			Required:    true,
+			Type:        "string",
+			Description: "Kuveyt Türk Customer ID (Müşteri No) of the merchant",
+			Example:     "400235",
+			Pattern:     "^[0-9]{1,12}$",
+		},
+		{
+			Key:         "username",
+			Required:    true,
+			Type:        "string",
+			Description: "API user created in the merchant panel",
+			Example:     "apitest",
+			MinLength:   3,
+			MaxLength:   50,
+		},
+		{
+			Key:         "password",
+			Required:    true,
+			Type:        "string",
+			Description: "Password of the API user",
+			Example:     "api123",
+			MinLength:   3,
+			MaxLength:   50,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Kuveyt Türk requirements
+func (p *KuveytTurkProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("kuveytturk", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies accepted by Kuveyt Türk
+func (p *KuveytTurkProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the Kuveyt Türk SOAP service, whose bodiless GET serves the
+// service description
+func (p *KuveytTurkProvider) HealthCheckEndpoint() string {
+	if p.soapURL == "" {
+		return soapSandboxURL
+	}
+	return p.soapURL
+}
+
+// Initialize sets up the Kuveyt Türk payment provider with authentication credentials
+func (p *KuveytTurkProvider) Initialize(conf map[string]string) error {
+	p.merchantID = conf["merchantId"]
+	p.customerID = conf["customerId"]
+	p.username = conf["username"]
+	p.password = conf["password"]
+
+	if p.merchantID == "" || p.customerID == "" || p.username == "" || p.password == "" {
+		return errors.New("kuveytturk: merchantId, customerId, username and password are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.payGateURL = payGateSandboxURL
+	p.provisionGateURL = provisionGateSandboxURL
+	p.soapURL = soapSandboxURL
+	if p.isProduction {
+		p.payGateURL = payGateProductionURL
+		p.provisionGateURL = provisionGateProductionURL
+		p.soapURL = soapProductionURL
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.soapURL, p.isProduction).ForProvider("kuveytturk"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *KuveytTurkProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *KuveytTurkProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment is not offered: Kuveyt Türk merchants take card payments with the 3D model
+func (p *KuveytTurkProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("kuveytturk: non-3D payments are not supported, use 3D payments")
+}
+
+// Create3DPayment sends the card to the 3D model pay gate and returns the bank's
+// authentication page. The bank posts the authentication result to GoPay, which completes
+// the sale in Complete3DPayment.
+func (p *KuveytTurkProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request); err != nil {
+		return nil, fmt.Errorf("kuveytturk: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := p.generateOrderId()
+
+	// Create callback state
+	state := provider.CallbackState{
+		TenantID:         int(request.TenantID),
+		PaymentID:        orderID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "kuveytturk",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "kuveytturk", state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	message := p.build3DMessage(request, orderID, gopayCallbackURL, currencyCode)
+	body, err := p.postXML(ctx, p.payGateURL, "", message)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := payGateResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	response.PaymentID = orderID
+	response.Amount = request.Amount
+	response.Currency = request.Currency
+	return response, nil
+}
+
+// payGateResponse maps the pay gate's answer: the bank's authentication page, or a
+// VPosTransactionResponseContract when the card was refused before authentication
+func payGateResponse(body []byte) (*provider.PaymentResponse, error) {
+	now := time.Now()
+	var contract vposResponse
+	if err := decodeXML(body, &contract); err == nil && contract.ResponseCode != "" {
+		return &provider.PaymentResponse{
+			Success:          false,
+			Status:           provider.StatusFailed,
+			ErrorCode:        contract.ResponseCode,
+			Message:          contract.ResponseMessage,
+			SystemTime:       &now,
+			ProviderResponse: contract,
+		}, nil
+	}
+
+	if !bytes.Contains(bytes.ToLower(body), []byte("<form")) {
+		return nil, errors.New("kuveytturk: unexpected pay gate response")
+	}
+
+	return &provider.PaymentResponse{
+		Success:    true,
+		Status:     provider.StatusPending,
+		HTML:       string(body),
+		Message:    "3D Secure authentication required",
+		SystemTime: &now,
+	}, nil
+}
+
+// Complete3DPayment reads the authentication result the bank posted and, when the
+// cardholder was authenticated, completes the sale on the provision gate with its MD
+func (p *KuveytTurkProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	authentication, err := parseAuthenticationResponse(data["AuthenticationResponse"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(authentication); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("kuveytturk", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	if authentication.MerchantOrderID != callbackState.PaymentID {
+		return nil, fmt.Errorf("kuveytturk: callback is for order %s, expected %s", authentication.MerchantOrderID, callbackState.PaymentID)
+	}
+
+	if authentication.ResponseCode != approvedCode {
+		response := resultResponse(callbackState, authentication)
+		if provider.Is3DSessionExpiredMessage(authentication.ResponseMessage) {
+			response.ErrorCode = provider.ErrorCode3DSessionExpired
+			return response, fmt.Errorf("kuveytturk: %w", provider.Err3DSessionExpired)
+		}
+		// The customer left the bank page; the card was never declined
+		if provider.Is3DSChallengeCancelledMessage(authentication.ResponseMessage) {
+			provider.MarkThreeDSCancelled(response)
+		}
+		return response, nil
+	}
+
+	currencyCode, err := p.currencyCode(callbackState.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provision is signed with our password, so the bank only approves an MD it issued
+	message := p.buildProvisionMessage(callbackState, authentication.MD, currencyCode)
+	body, err := p.postXML(ctx, p.provisionGateURL, "", message)
+	if err != nil {
+		return nil, err
+	}
+
+	var provision vposResponse
+	if err := decodeXML(body, &provision); err != nil {
+		return nil, fmt.Errorf("kuveytturk: failed to parse provision response: %w", err)
+	}
+
+	// Refunds and cancels refer to the provision
+	if callbackState.LogID > 0 && provision.ResponseCode == approvedCode {
+		_ = provider.AddProviderRequestToClientRequest("kuveytturk", provisionLogKey, map[string]any{
+			"orderId":         provision.OrderID,
+			"rrn":             provision.RRN,
+			"stan":            provision.Stan,
+			"provisionNumber": provision.ProvisionNumber,
+			"amount":          provision.VPosMessage.Amount,
+		}, callbackState.LogID)
+	}
+
+	return resultResponse(callbackState, &provision), nil
+}
+
+// parseAuthenticationResponse decodes the AuthenticationResponse field of a 3D callback, an
+// XML VPosTransactionResponseContract the bank URL encodes
+func parseAuthenticationResponse(value string) (*vposResponse, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, errors.New("kuveytturk: missing AuthenticationResponse in callback")
+	}
+	if !strings.HasPrefix(value, "<") {
+		unescaped, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("kuveytturk: invalid AuthenticationResponse: %w", err)
+		}
+		value = unescaped
+	}
+
+	var response vposResponse
+	if err := decodeXML([]byte(value), &response); err != nil {
+		return nil, fmt.Errorf("kuveytturk: invalid AuthenticationResponse: %w", err)
+	}
+	return &response, nil
+}
+
+// resultResponse maps an authentication or provision result
+func resultResponse(callbackState *provider.CallbackState, result *vposResponse) *provider.PaymentResponse {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          result.ResponseCode == approvedCode,
+		PaymentID:        callbackState.PaymentID,
+		TransactionID:    result.OrderID,
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderTime:     provider.ParseProviderTime(result.TransactionTime, provider.TurkeyTimeZone, "2006-01-02T15:04:05"),
+		ProviderResponse: result,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	if response.Success {
+		response.Status = provider.StatusSuccessful
+		response.Message = "3D payment completed successfully"
+		return response
+	}
+
+	response.Status = provider.StatusFailed
+	response.ErrorCode = result.ResponseCode
+	response.Message = result.ResponseMessage
+	if response.Message == "" {
+		response.Message = "3D payment failed"
+	}
+	return response
+}
+
+// GetPaymentStatus is not offered by the Kuveyt Türk integration
+func (p *KuveytTurkProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("kuveytturk: payment status inquiry is not supported")
+}
+
+// CancelPayment reverses a sale before end of day
+func (p *KuveytTurkProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("kuveytturk: paymentID is required")
+	}
+
+	original, err := p.lookupProvision(request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.cancel(ctx, request.PaymentID, original)
+}
+
+// cancel reverses the provision original of paymentID
+func (p *KuveytTurkProvider) cancel(ctx context.Context, paymentID string, original provisionDetails) (*provider.PaymentResponse, error) {
+	result, err := p.sendSOAP(ctx, txnTypeCancel, p.buildSOAPRequest(txnTypeCancel, paymentID, original, original.Amount))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          result.approved(),
+		PaymentID:        paymentID,
+		SystemTime:       &now,
+		ProviderResponse: result,
+	}
+	if response.Success {
+		response.Status = provider.StatusCancelled
+		response.Message = "Payment cancelled"
+		return response, nil
+	}
+
+	response.Status = provider.StatusFailed
+	response.ErrorCode, response.Message = result.failure()
+	if code, err := provider.CancelFailure(response.Message); err != nil {
+		response.ErrorCode = code
+		return response, fmt.Errorf("kuveytturk: %w", err)
+	}
+	return response, nil
+}
+
+// RefundPayment refunds a settled sale, in full with DrawBack or in part with PartialDrawback
+func (p *KuveytTurkProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("kuveytturk: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("kuveytturk: refund amount must be greater than 0")
+	}
+
+	original, err := p.lookupProvision(request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.refund(ctx, request, original)
+}
+
+// refund refunds the provision original
+func (p *KuveytTurkProvider) refund(ctx context.Context, request provider.RefundRequest, original provisionDetails) (*provider.RefundResponse, error) {
+	currency := request.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	amount := provider.ToMinorUnits(request.RefundAmount, currency)
+
+	txnType := txnTypeRefund
+	if amount < original.Amount {
+		txnType = txnTypePartialRefund
+	}
+
+	result, err := p.sendSOAP(ctx, txnType, p.buildSOAPRequest(txnType, request.PaymentID, original, amount))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refundResp := &provider.RefundResponse{
+		Success:      result.approved(),
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+		RawResponse:  result,
+	}
+
+	if refundResp.Success {
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+		refundResp.RefundID = result.Value.RRN
+	} else {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode, refundResp.Message = result.failure()
+	}
+
+	return refundResp, nil
+}
+
+// ValidateWebhook validates an incoming webhook notification
+func (p *KuveytTurkProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	// Kuveyt Türk posts payment results only to the 3D callback
+	return true, data, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *KuveytTurkProvider) validatePaymentRequest(request provider.PaymentRequest) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Currency == "" {
+		return errors.New("currency is required")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("CVV is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiration month and year are required")
+	}
+
+	if request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// vposMessage is the KuveytTurkVPosMessage of the 3D model pay and provision gates
+type vposMessage struct {
+	XMLName             xml.Name            `xml:"KuveytTurkVPosMessage"`
+	APIVersion          string              `xml:"APIVersion"`
+	OkURL               string              `xml:"OkUrl,omitempty"`
+	FailURL             string              `xml:"FailUrl,omitempty"`
+	HashData            string              `xml:"HashData"`
+	MerchantID          string              `xml:"MerchantId"`
+	CustomerID          string              `xml:"CustomerId"`
+	UserName            string              `xml:"UserName"`
+	CardNumber          string              `xml:"CardNumber,omitempty"`
+	CardExpireDateYear  string              `xml:"CardExpireDateYear,omitempty"`
+	CardExpireDateMonth string              `xml:"CardExpireDateMonth,omitempty"`
+	CardCVV2            string              `xml:"CardCVV2,omitempty"`
+	CardHolderName      string              `xml:"CardHolderName,omitempty"`
+	CardType            string              `xml:"CardType,omitempty"`
+	BatchID             int                 `xml:"BatchID"`
+	TransactionType     string              `xml:"TransactionType"`
+	InstallmentCount    int                 `xml:"InstallmentCount"`
+	Amount              int64               `xml:"Amount"`
+	DisplayAmount       int64               `xml:"DisplayAmount"`
+	CurrencyCode        string              `xml:"CurrencyCode"`
+	MerchantOrderID     string              `xml:"MerchantOrderId"`
+	TransactionSecurity int                 `xml:"TransactionSecurity"`
+	DeviceData          *vposDeviceData     `xml:"DeviceData,omitempty"`
+	CardHolderData      *vposCardHolderData `xml:"CardHolderData,omitempty"`
+	AdditionalData      *vposAdditionalData `xml:"KuveytTurkVPosAdditionalData,omitempty"`
+}
+
+type vposDeviceData struct {
+	DeviceChannel string `xml:"DeviceChannel"`
+	ClientIP      string `xml:"ClientIP"`
+}
+
+type vposCardHolderData struct {
+	BillAddrCity     string `xml:"BillAddrCity,omitempty"`
+	BillAddrCountry  string `xml:"BillAddrCountry,omitempty"`
+	BillAddrLine1    string `xml:"BillAddrLine1,omitempty"`
+	BillAddrPostCode string `xml:"BillAddrPostCode,omitempty"`
+	Email            string `xml:"Email,omitempty"`
+}
+
+type vposAdditionalData struct {
+	AdditionalData struct {
+		Key  string `xml:"Key"`
+		Data string `xml:"Data"`
+	} `xml:"AdditionalData"`
+}
+
+// vposResponse is the VPosTransactionResponseContract of an authentication or provision
+type vposResponse struct {
+	XMLName         xml.Name `xml:"VPosTransactionResponseContract" json:"-"`
+	ResponseCode    string   `xml:"ResponseCode" json:"responseCode"`
+	ResponseMessage string   `xml:"ResponseMessage" json:"responseMessage"`
+	MD              string   `xml:"MD" json:"md"`
+	MerchantOrderID string   `xml:"MerchantOrderId" json:"merchantOrderId"`
+	OrderID         string   `xml:"OrderId" json:"orderId"`
+	ProvisionNumber string   `xml:"ProvisionNumber" json:"provisionNumber"`
+	RRN             string   `xml:"RRN" json:"rrn"`
+	Stan            string   `xml:"Stan" json:"stan"`
+	TransactionTime string   `xml:"TransactionTime" json:"transactionTime"`
+	VPosMessage     struct {
+		Amount int64 `xml:"Amount" json:"amount"`
+	} `xml:"VPosMessage" json:"vPosMessage"`
+}
+
+// build3DMessage builds the pay gate request of a 3D payment
+func (p *KuveytTurkProvider) build3DMessage(request provider.PaymentRequest, orderID, callbackURL, currencyCode string) *vposMessage {
+	amount := provider.ToMinorUnits(request.Amount, request.Currency)
+	cardNumber := provider.NormalizePAN(request.CardInfo.CardNumber)
+
+	cardHolderName := strings.TrimSpace(request.CardInfo.CardHolderName)
+	if cardHolderName == "" {
+		cardHolderName = strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+	}
+
+	expireYear := request.CardInfo.ExpireYear
+	if len(expireYear) > 2 {
+		expireYear = expireYear[len(expireYear)-2:]
+	}
+
+	clientIP := request.Customer.IPAddress
+	if clientIP == "" {
+		clientIP = request.ClientIP
+	}
+
+	message := &vposMessage{
+		APIVersion:          apiVersion,
+		OkURL:               callbackURL,
+		FailURL:             callbackURL,
+		MerchantID:          p.merchantID,
+		CustomerID:          p.customerID,
+		UserName:            p.username,
+		CardNumber:          cardNumber,
+		CardExpireDateYear:  expireYear,
+		CardExpireDateMonth: request.CardInfo.ExpireMonth,
+		CardCVV2:            request.CardInfo.CVV,
+		CardHolderName:      cardHolderName,
+		CardType:            cardType(cardNumber),
+		TransactionType:     txnTypeSale,
+		InstallmentCount:    installmentCount(request.InstallmentCount),
+		Amount:              amount,
+		DisplayAmount:       amount,
+		CurrencyCode:        currencyCode,
+		MerchantOrderID:     orderID,
+		TransactionSecurity: transactionSecurity3D,
+		DeviceData:          &vposDeviceData{DeviceChannel: "02", ClientIP: clientIP},
+		CardHolderData:      &vposCardHolderData{Email: request.Customer.Email},
+	}
+	if address := request.Customer.Address; address != nil {
+		message.CardHolderData.BillAddrCity = address.City
+		message.CardHolderData.BillAddrLine1 = address.Address
+		message.CardHolderData.BillAddrPostCode = address.ZipCode
+	}
+	message.HashData = p.calculateHash(orderID, amount, callbackURL, callbackURL)
+	return message
+}
+
+// buildProvisionMessage builds the provision gate request completing an authenticated payment
+func (p *KuveytTurkProvider) buildProvisionMessage(callbackState *provider.CallbackState, md, currencyCode string) *vposMessage {
+	amount := provider.ToMinorUnits(callbackState.Amount, callbackState.Currency)
+	message := &vposMessage{
+		APIVersion:          apiVersion,
+		MerchantID:          p.merchantID,
+		CustomerID:          p.customerID,
+		UserName:            p.username,
+		TransactionType:     txnTypeSale,
+		InstallmentCount:    installmentCount(callbackState.Installment),
+		Amount:              amount,
+		DisplayAmount:       amount,
+		CurrencyCode:        currencyCode,
+		MerchantOrderID:     callbackState.PaymentID,
+		TransactionSecurity: transactionSecurity3D,
+		AdditionalData:      &vposAdditionalData{},
+	}
+	message.AdditionalData.AdditionalData.Key = "MD"
+	message.AdditionalData.AdditionalData.Data = md
+	message.HashData = p.calculateHash(callbackState.PaymentID, amount, "", "")
+	return message
+}
+
+// hashedPassword returns Base64(SHA1(password))
+func (p *KuveytTurkProvider) hashedPassword() string {
+	sum := sha1.Sum([]byte(p.password))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// calculateHash returns the HashData of a request:
+// Base64(SHA1(MerchantId + MerchantOrderId + Amount + OkUrl + FailUrl + UserName + HashedPassword)).
+// Only the pay gate request has the OkUrl and FailUrl.
+func (p *KuveytTurkProvider) calculateHash(orderID string, amount int64, okURL, failURL string) string {
+	hashVal := p.merchantID + orderID + strconv.FormatInt(amount, 10) + okURL + failURL + p.username + p.hashedPassword()
+	sum := sha1.Sum([]byte(hashVal))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// provisionDetails identifies an approved provision for cancels and refunds
+type provisionDetails struct {
+	OrderID         string
+	RRN             string
+	Stan            string
+	ProvisionNumber string
+	Amount          int64
+}
+
+// lookupProvision reads the provision of paymentID from the request log
+func (p *KuveytTurkProvider) lookupProvision(paymentID string) (provisionDetails, error) {
+	values := make(map[string]string, 5)
+	for _, key := range []string{"orderId", "rrn", "stan", "provisionNumber", "amount"} {
+		value, err := provider.GetProviderNestedRequestValueFromLog("kuveytturk", paymentID, provisionLogKey, key)
+		if err != nil {
+			return provisionDetails{}, fmt.Errorf("kuveytturk: failed to get provision %s of %s: %w", key, paymentID, err)
+		}
+		values[key] = value
+	}
+
+	amount, err := strconv.ParseInt(values["amount"], 10, 64)
+	if err != nil {
+		return provisionDetails{}, fmt.Errorf("kuveytturk: invalid provision amount of %s: %w", paymentID, err)
+	}
+
+	return provisionDetails{
+		OrderID:         values["orderId"],
+		RRN:             values["rrn"],
+		Stan:            values["stan"],
+		ProvisionNumber: values["provisionNumber"],
+		Amount:          amount,
+	}, nil
+}
+
+// soapEnvelope wraps a SOAP service request
+type soapEnvelope struct {
+	XMLName xml.Name `xml:"soapenv:Envelope"`
+	SoapEnv string   `xml:"xmlns:soapenv,attr"`
+	Ser     string   `xml:"xmlns:ser,attr"`
+	Body    struct {
+		Operation soapOperation
+	} `xml:"soapenv:Body"`
+}
+
+type soapOperation struct {
+	XMLName xml.Name
+	Request *soapRequest `xml:"ser:request"`
+}
+
+// soapRequest is the request of SaleReversal, DrawBack and PartialDrawback
+type soapRequest struct {
+	IsFromExternalNetwork bool            `xml:"ser:IsFromExternalNetwork"`
+	BusinessKey           int             `xml:"ser:BusinessKey"`
+	ResourceID            int             `xml:"ser:ResourceId"`
+	ActionID              int             `xml:"ser:ActionId"`
+	LanguageID            int             `xml:"ser:LanguageId"`
+	CustomerID            string          `xml:"ser:CustomerId"`
+	MailOrTelephoneOrder  bool            `xml:"ser:MailOrTelephoneOrder"`
+	Amount                int64           `xml:"ser:Amount"`
+	MerchantID            string          `xml:"ser:MerchantId"`
+	OrderID               string          `xml:"ser:OrderId"`
+	RRN                   string          `xml:"ser:RRN"`
+	Stan                  string          `xml:"ser:Stan"`
+	ProvisionNumber       string          `xml:"ser:ProvisionNumber"`
+	VPosMessage           soapVPosMessage `xml:"ser:VPosMessage"`
+}
+
+type soapVPosMessage struct {
+	APIVersion          string `xml:"ser:APIVersion"`
+	HashData            string `xml:"ser:HashData"`
+	MerchantID          string `xml:"ser:MerchantId"`
+	CustomerID          string `xml:"ser:CustomerId"`
+	UserName            string `xml:"ser:UserName"`
+	TransactionType     string `xml:"ser:TransactionType"`
+	InstallmentCount    int    `xml:"ser:InstallmentCount"`
+	Amount              int64  `xml:"ser:Amount"`
+	CancelAmount        int64  `xml:"ser:CancelAmount"`
+	MerchantOrderID     string `xml:"ser:MerchantOrderId"`
+	CurrencyCode        string `xml:"ser:CurrencyCode"`
+	TransactionSecurity int    `xml:"ser:TransactionSecurity"`
+}
+
+// soapResult is the result of a SOAP service operation
+type soapResult struct {
+	Success bool `xml:"Success" json:"success"`
+	Results []struct {
+		ErrorCode    string `xml:"ErrorCode" json:"errorCode"`
+		ErrorMessage string `xml:"ErrorMessage" json:"errorMessage"`
+	} `xml:"Results>Result" json:"results"`
+	Value struct {
+		ResponseCode    string `xml:"ResponseCode" json:"responseCode"`
+		ResponseMessage string `xml:"ResponseMessage" json:"responseMessage"`
+		RRN             string `xml:"RRN" json:"rrn"`
+	} `xml:"Value" json:"value"`
+}
+
+// approved reports whether the operation went through
+func (r *soapResult) approved() bool {
+	return r.Success && (r.Value.ResponseCode == "" || r.Value.ResponseCode == approvedCode)
+}
+
+// failure returns the error code and message of a refused operation
+func (r *soapResult) failure() (string, string) {
+	if r.Value.ResponseCode != "" && r.Value.ResponseCode != approvedCode {
+		return r.Value.ResponseCode, r.Value.ResponseMessage
+	}
+	for _, result := range r.Results {
+		if result.ErrorCode != "" || result.ErrorMessage != "" {
+			return result.ErrorCode, result.ErrorMessage
+		}
+	}
+	return "", "Operation failed"
+}
+
+// buildSOAPRequest builds a cancel or refund of amount minor units of the provision original
+func (p *KuveytTurkProvider) buildSOAPRequest(txnType, paymentID string, original provisionDetails, amount int64) *soapRequest {
+	return &soapRequest{
+		IsFromExternalNetwork: true,
+		CustomerID:            p.customerID,
+		MailOrTelephoneOrder:  true,
+		Amount:                amount,
+		MerchantID:            p.merchantID,
+		OrderID:               original.OrderID,
+		RRN:                   original.RRN,
+		Stan:                  original.Stan,
+		ProvisionNumber:       original.ProvisionNumber,
+		VPosMessage: soapVPosMessage{
+			APIVersion:          soapAPIVersion,
+			HashData:            p.calculateHash(paymentID, amount, "", ""),
+			MerchantID:          p.merchantID,
+			CustomerID:          p.customerID,
+			UserName:            p.username,
+			TransactionType:     txnType,
+			Amount:              amount,
+			CancelAmount:        amount,
+			MerchantOrderID:     paymentID,
+			CurrencyCode:        "0949",
+			TransactionSecurity: 1,
+		},
+	}
+}
+
+// sendSOAP calls operation of the SOAP service
+func (p *KuveytTurkProvider) sendSOAP(ctx context.Context, operation string, request *soapRequest) (*soapResult, error) {
+	envelope := soapEnvelope{SoapEnv: "http://schemas.xmlsoap.org/soap/envelope/", Ser: soapNamespace}
+	envelope.Body.Operation = soapOperation{XMLName: xml.Name{Local: "ser:" + operation}, Request: request}
+
+	body, err := p.postXML(ctx, p.soapURL, soapActionPrefix+operation, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	// The result element is named after the operation, e.g. DrawBackResult
+	var response struct {
+		Body struct {
+			Response struct {
+				Result soapResult `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := decodeXML(body, &response); err != nil {
+		return nil, fmt.Errorf("kuveytturk: failed to parse %s response: %w", operation, err)
+	}
+	return &response.Body.Response.Result, nil
+}
+
+// postXML posts an XML document, with a SOAPAction header for the SOAP service, and returns
+// the response body
+func (p *KuveytTurkProvider) postXML(ctx context.Context, endpoint, soapAction string, document any) ([]byte, error) {
+	body, err := xml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	headers := map[string]string{"Content-Type": "application/xml; charset=utf-8"}
+	if soapAction != "" {
+		headers["Content-Type"] = "text/xml; charset=utf-8"
+		headers["SOAPAction"] = soapAction
+	}
+
+	resp, err := p.httpClient.SendRaw(ctx, &provider.HTTPRequest{
+		Method:   "POST",
+		Endpoint: endpoint,
+		Body:     xml.Header + string(body),
+		Headers:  headers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// decodeXML decodes an XML document, which Kuveyt Türk may send in ISO-8859-9
+func decodeXML(body []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+// currencyCode returns the zero padded ISO 4217 numeric code Kuveyt Türk expects, using TRY
+// when currency is empty
+func (p *KuveytTurkProvider) currencyCode(currency string) (string, error) {
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	code, err := provider.CurrencyCode(currency, provider.CurrencyFormatNumeric)
+	if err != nil {
+		return "", fmt.Errorf("kuveytturk: %w", err)
+	}
+	return "0" + code, nil
+}
+
+// cardType returns the card scheme name of a card number
+func cardType(cardNumber string) string {
+	switch {
+	case strings.HasPrefix(cardNumber, "4"):
+		return "Visa"
+	case strings.HasPrefix(cardNumber, "5"), strings.HasPrefix(cardNumber, "2"):
+		return "MasterCard"
+	case strings.HasPrefix(cardNumber, "9"):
+		return "Troy"
+	}
+	return ""
+}
+
+// installmentCount returns the installment count field, 0 for a single payment
+func installmentCount(count int) int {
+	if count > 1 {
+		return count
+	}
+	return 0
+}
+
+// generateOrderId generates a unique order ID
+func (p *KuveytTurkProvider) generateOrderId() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return "GP" + time.Now().Format("20060102150405") + strings.ToUpper(hex.EncodeToString(suffix))
+}
@@ -0,0 +1,70 @@
+package kuveytturk
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test card for the Kuveyt Türk test environment
+var testCard = provider.CardInfo{CardNumber: "5188961939192544", ExpireMonth: "06", ExpireYear: "2025", CVV: "929", CardHolderName: "Test User"}
+
+// setupRealTestProvider returns a sandbox provider from KUVEYTTURK_MERCHANT_ID,
+// KUVEYTTURK_CUSTOMER_ID, KUVEYTTURK_USERNAME and KUVEYTTURK_PASSWORD, skipping the test when
+// they are not set
+func setupRealTestProvider(t *testing.T) *KuveytTurkProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"merchantId":  os.Getenv("KUVEYTTURK_MERCHANT_ID"),
+		"customerId":  os.Getenv("KUVEYTTURK_CUSTOMER_ID"),
+		"username":    os.Getenv("KUVEYTTURK_USERNAME"),
+		"password":    os.Getenv("KUVEYTTURK_PASSWORD"),
+		"environment": "sandbox",
+	}
+	if config["merchantId"] == "" || config["customerId"] == "" || config["username"] == "" || config["password"] == "" {
+		t.Skip("kuveytturk sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*KuveytTurkProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestKuveytTurkProvider_RealAPI_PayGate sends a 3D payment to the sandbox pay gate and
+// checks that it answers with an authentication page
+func TestKuveytTurkProvider_RealAPI_PayGate(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	orderID := p.generateOrderId()
+	message := p.build3DMessage(provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@kuveytturk.example.com"},
+		CardInfo: testCard,
+	}, orderID, "https://gopay.example.com/callback", "0949")
+
+	body, err := p.postXML(context.Background(), p.payGateURL, "", message)
+	if err != nil {
+		t.Fatalf("Pay gate request failed: %v", err)
+	}
+
+	response, err := payGateResponse(body)
+	if err != nil {
+		t.Fatalf("Unexpected pay gate response: %v", err)
+	}
+	t.Logf("Pay gate: success=%v status=%s code=%s message=%s", response.Success, response.Status, response.ErrorCode, response.Message)
+	if response.Success && !strings.Contains(strings.ToLower(response.HTML), "<form") {
+		t.Error("Expected an authentication form")
+	}
+}
@@ -0,0 +1,364 @@
+package kuveytturk
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"merchantId":  "496",
+		"customerId":  "400235",
+		"username":    "apitest",
+		"password":    "api123",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	kuveytProvider, ok := p.(*KuveytTurkProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a KuveytTurkProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if kuveytProvider.httpClient != nil {
+		t.Error("KuveytTurkProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := kuveytProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if kuveytProvider.httpClient == nil {
+		t.Error("KuveytTurkProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestKuveytTurkProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+		payGateURL  string
+		soapURL     string
+	}{
+		{"sandbox", testConfig("sandbox"), false, payGateSandboxURL, soapSandboxURL},
+		{"production", testConfig("production"), false, payGateProductionURL, soapProductionURL},
+		{"missing merchantId", without("merchantId"), true, "", ""},
+		{"missing customerId", without("customerId"), true, "", ""},
+		{"missing username", without("username"), true, "", ""},
+		{"missing password", without("password"), true, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &KuveytTurkProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.payGateURL != tt.payGateURL || p.soapURL != tt.soapURL {
+				t.Errorf("Expected %s and %s, got %s and %s", tt.payGateURL, tt.soapURL, p.payGateURL, p.soapURL)
+			}
+		})
+	}
+}
+
+func TestKuveytTurkProvider_GetRequiredConfig(t *testing.T) {
+	p := &KuveytTurkProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	expected := map[string]bool{"merchantId": true, "customerId": true, "username": true, "password": true, "environment": true}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d config fields, got %d", len(expected), len(fields))
+	}
+	for _, field := range fields {
+		if !expected[field.Key] || !field.Required {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestKuveytTurkProvider_CalculateHash(t *testing.T) {
+	p := &KuveytTurkProvider{merchantID: "496", username: "apitest", password: "api123"}
+
+	sha1Base64 := func(s string) string {
+		sum := sha1.Sum([]byte(s))
+		return base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	if got := p.hashedPassword(); got != sha1Base64("api123") {
+		t.Errorf("Unexpected hashed password %s", got)
+	}
+
+	expected := sha1Base64("496GP1" + "10050" + "https://gopay.example.com/cb" + "https://gopay.example.com/cb" + "apitest" + sha1Base64("api123"))
+	if got := p.calculateHash("GP1", 10050, "https://gopay.example.com/cb", "https://gopay.example.com/cb"); got != expected {
+		t.Errorf("Expected pay gate hash %s, got %s", expected, got)
+	}
+
+	expected = sha1Base64("496GP1" + "10050" + "apitest" + sha1Base64("api123"))
+	if got := p.calculateHash("GP1", 10050, "", ""); got != expected {
+		t.Errorf("Expected provision hash %s, got %s", expected, got)
+	}
+}
+
+func TestKuveytTurkProvider_Build3DMessage(t *testing.T) {
+	p := NewProvider().(*KuveytTurkProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	message := p.build3DMessage(provider.PaymentRequest{
+		TenantID:         1,
+		Amount:           100.50,
+		Currency:         "TRY",
+		InstallmentCount: 1,
+		ClientIP:         "10.0.0.1",
+		Customer:         provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:         provider.CardInfo{CardNumber: "5188 9619 3919 2544", ExpireMonth: "06", ExpireYear: "2030", CVV: "929"},
+	}, "GP1", "https://gopay.example.com/cb", "0949")
+
+	if message.APIVersion != apiVersion || message.MerchantID != "496" || message.CustomerID != "400235" || message.UserName != "apitest" {
+		t.Errorf("Unexpected merchant fields: %+v", message)
+	}
+	if message.CardNumber != "5188961939192544" || message.CardExpireDateYear != "30" || message.CardExpireDateMonth != "06" ||
+		message.CardHolderName != "John Doe" || message.CardType != "MasterCard" {
+		t.Errorf("Unexpected card fields: %+v", message)
+	}
+	if message.Amount != 10050 || message.DisplayAmount != 10050 || message.CurrencyCode != "0949" || message.InstallmentCount != 0 ||
+		message.TransactionType != txnTypeSale || message.TransactionSecurity != transactionSecurity3D {
+		t.Errorf("Unexpected transaction fields: %+v", message)
+	}
+	if message.OkURL != "https://gopay.example.com/cb" || message.FailURL != message.OkURL || message.DeviceData.ClientIP != "10.0.0.1" {
+		t.Errorf("Unexpected callback fields: %+v", message)
+	}
+	if message.HashData != p.calculateHash("GP1", 10050, message.OkURL, message.FailURL) {
+		t.Errorf("Unexpected hash %s", message.HashData)
+	}
+
+	body, err := xml.Marshal(message)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	if !strings.HasPrefix(string(body), "<KuveytTurkVPosMessage>") || strings.Contains(string(body), "KuveytTurkVPosAdditionalData") {
+		t.Errorf("Unexpected message XML: %s", body)
+	}
+}
+
+func TestPayGateResponse(t *testing.T) {
+	response, err := payGateResponse([]byte(`<html><body><form action="https://acs.example.com" method="post"></form></body></html>`))
+	if err != nil {
+		t.Fatalf("payGateResponse failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusPending || !strings.Contains(response.HTML, "acs.example.com") {
+		t.Errorf("Unexpected response for an authentication page: %+v", response)
+	}
+
+	response, err = payGateResponse([]byte(`<?xml version="1.0" encoding="ISO-8859-9"?><VPosTransactionResponseContract><ResponseCode>MetaDataNotFound</ResponseCode><ResponseMessage>Kart bilgileri hatali</ResponseMessage></VPosTransactionResponseContract>`))
+	if err != nil {
+		t.Fatalf("payGateResponse failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "MetaDataNotFound" || response.Message != "Kart bilgileri hatali" {
+		t.Errorf("Unexpected response for a refused card: %+v", response)
+	}
+
+	if _, err := payGateResponse([]byte("Service Unavailable")); err == nil {
+		t.Error("Expected an error for an unexpected response")
+	}
+}
+
+// newTestProvider returns a provider whose gates and SOAP service are handler
+func newTestProvider(t *testing.T, handler func(r *http.Request, body string) string) *KuveytTurkProvider {
+	t.Helper()
+	server := providertest.Server(t, providertest.XML(func(r *http.Request, body []byte) string {
+		return handler(r, string(body))
+	}))
+
+	p := providertest.Initialize[*KuveytTurkProvider](t, NewProvider, testConfig("sandbox"))
+	p.payGateURL = server.URL + "/pay"
+	p.provisionGateURL = server.URL + "/provision"
+	p.soapURL = server.URL + "/soap"
+	return p
+}
+
+const authenticationXML = `<?xml version="1.0" encoding="utf-8"?><VPosTransactionResponseContract><ResponseCode>%code%</ResponseCode><ResponseMessage>%message%</ResponseMessage><MD>MD123</MD><MerchantOrderId>GP1</MerchantOrderId><OrderId>0</OrderId></VPosTransactionResponseContract>`
+
+func authenticationResponse(code, message string) string {
+	return url.QueryEscape(strings.NewReplacer("%code%", code, "%message%", message).Replace(authenticationXML))
+}
+
+func TestKuveytTurkProvider_Complete3DPayment(t *testing.T) {
+	var provisioned vposMessage
+	p := newTestProvider(t, func(r *http.Request, body string) string {
+		if r.URL.Path != "/provision" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		if err := xml.Unmarshal([]byte(body), &provisioned); err != nil {
+			t.Errorf("Expected a KuveytTurkVPosMessage, got %s: %v", body, err)
+		}
+		return `<?xml version="1.0" encoding="ISO-8859-9"?><VPosTransactionResponseContract><ResponseCode>00</ResponseCode><ResponseMessage>OTORIZASYON VERILDI</ResponseMessage><MerchantOrderId>GP1</MerchantOrderId><OrderId>148290</OrderId><ProvisionNumber>945931</ProvisionNumber><RRN>402315148290</RRN><Stan>148290</Stan><TransactionTime>2024-01-15T10:30:00</TransactionTime><VPosMessage><Amount>10050</Amount></VPosMessage></VPosTransactionResponseContract>`
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "kuveytturk",
+		Environment:      "sandbox",
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"AuthenticationResponse": authenticationResponse("00", "Kart doğrulandı."), "state": "abc123"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if provisioned.AdditionalData == nil || provisioned.AdditionalData.AdditionalData.Key != "MD" || provisioned.AdditionalData.AdditionalData.Data != "MD123" ||
+		provisioned.Amount != 10050 || provisioned.MerchantOrderID != "GP1" || provisioned.HashData != p.calculateHash("GP1", 10050, "", "") {
+		t.Errorf("Unexpected provision request: %+v", provisioned)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "148290" ||
+		response.ProviderTime == nil || response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Unexpected response for an approved payment: %+v", response)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"AuthenticationResponse": authenticationResponse("51", "Yetersiz bakiye")})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a failed authentication: %+v", response)
+	}
+
+	other := *callbackState
+	other.PaymentID = "GP2"
+	if _, err := p.Complete3DPayment(ctx, &other, map[string]string{"AuthenticationResponse": authenticationResponse("00", "")}); err == nil {
+		t.Error("Expected a callback for another order to be rejected")
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{}); err == nil || !strings.Contains(err.Error(), "missing AuthenticationResponse") {
+		t.Errorf("Expected a missing AuthenticationResponse error, got %v", err)
+	}
+}
+
+const soapResponseXML = `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><%op%Response xmlns="http://boa.net/BOA.Integration.VirtualPos/Service"><%op%Result xmlns:a="http://schemas.datacontract.org/2004/07/BOA.Types.VirtualPos" xmlns:i="http://www.w3.org/2001/XMLSchema-instance"><Results/><Success>true</Success><Value><RRN>402315148291</RRN><ResponseCode>00</ResponseCode><ResponseMessage>OTORIZASYON VERILDI</ResponseMessage></Value></%op%Result></%op%Response></s:Body></s:Envelope>`
+
+var testProvision = provisionDetails{OrderID: "148290", RRN: "402315148290", Stan: "148290", ProvisionNumber: "945931", Amount: 10050}
+
+func TestKuveytTurkProvider_Refund(t *testing.T) {
+	var action, body string
+	p := newTestProvider(t, func(r *http.Request, requestBody string) string {
+		action, body = r.Header.Get("SOAPAction"), requestBody
+		op := strings.TrimPrefix(action, soapActionPrefix)
+		return strings.ReplaceAll(soapResponseXML, "%op%", op)
+	})
+	ctx := context.Background()
+
+	response, err := p.refund(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 100.50, Currency: "TRY"}, testProvision)
+	if err != nil {
+		t.Fatalf("refund failed: %v", err)
+	}
+	if action != soapActionPrefix+txnTypeRefund || !strings.Contains(body, "<ser:DrawBack>") || !strings.Contains(body, "<ser:RRN>402315148290</ser:RRN>") ||
+		!strings.Contains(body, "<ser:Amount>10050</ser:Amount>") {
+		t.Errorf("Unexpected full refund request %s: %s", action, body)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "402315148291" {
+		t.Errorf("Unexpected full refund response: %+v", response)
+	}
+
+	response, err = p.refund(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 20, Currency: "TRY"}, testProvision)
+	if err != nil {
+		t.Fatalf("refund failed: %v", err)
+	}
+	if action != soapActionPrefix+txnTypePartialRefund || !strings.Contains(body, "<ser:CancelAmount>2000</ser:CancelAmount>") {
+		t.Errorf("Unexpected partial refund request %s: %s", action, body)
+	}
+	if !response.Success {
+		t.Errorf("Unexpected partial refund response: %+v", response)
+	}
+}
+
+func TestKuveytTurkProvider_Cancel(t *testing.T) {
+	var action string
+	p := newTestProvider(t, func(r *http.Request, body string) string {
+		action = r.Header.Get("SOAPAction")
+		if strings.Contains(body, "<ser:MerchantOrderId>GP-SETTLED</ser:MerchantOrderId>") {
+			return `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><SaleReversalResponse><SaleReversalResult><Results><Result><ErrorCode>MB</ErrorCode><ErrorMessage>Gün sonu yapılmış işlem iptal edilemez</ErrorMessage></Result></Results><Success>false</Success></SaleReversalResult></SaleReversalResponse></s:Body></s:Envelope>`
+		}
+		return strings.ReplaceAll(soapResponseXML, "%op%", txnTypeCancel)
+	})
+	ctx := context.Background()
+
+	response, err := p.cancel(ctx, "GP1", testProvision)
+	if err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if action != soapActionPrefix+txnTypeCancel {
+		t.Errorf("Unexpected SOAPAction %s", action)
+	}
+	if !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Unexpected cancel response: %+v", response)
+	}
+
+	if _, err := p.cancel(ctx, "GP-SETTLED", testProvision); !errors.Is(err, provider.ErrAlreadyCaptured) {
+		t.Errorf("Expected ErrAlreadyCaptured for a settled payment, got %v", err)
+	}
+}
+
+func TestKuveytTurkProvider_Unsupported(t *testing.T) {
+	p := &KuveytTurkProvider{}
+	ctx := context.Background()
+
+	if _, err := p.CreatePayment(ctx, provider.PaymentRequest{}); err == nil {
+		t.Error("Expected non-3D payments to be unsupported")
+	}
+	if _, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected status inquiries to be unsupported")
+	}
+}
+
+func TestKuveytTurkProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &KuveytTurkProvider{}
+	if p.HealthCheckEndpoint() != soapSandboxURL {
+		t.Errorf("Expected %s before Initialize, got %s", soapSandboxURL, p.HealthCheckEndpoint())
+	}
+	if err := p.Initialize(testConfig("production")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if p.HealthCheckEndpoint() != soapProductionURL {
+		t.Errorf("Expected %s, got %s", soapProductionURL, p.HealthCheckEndpoint())
+	}
+}
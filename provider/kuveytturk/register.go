@@ -0,0 +1,8 @@
+package kuveytturk
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Kuveyt Türk provider with the global registry
+	provider.Register("kuveytturk", NewProvider)
+}
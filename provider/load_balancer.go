@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// BalancedProviderName is the provider name that lets PaymentService pick the provider of a
+// payment from the tenant's configured weights, e.g. POST /v1/payments/balanced
+const BalancedProviderName = "balanced"
+
+var (
+	// ErrProviderWeightsNotConfigured is returned for balanced payments of a tenant without
+	// provider weights
+	ErrProviderWeightsNotConfigured = errors.New("provider weights are not configured for this tenant")
+	// ErrNoProviderAvailable is returned when the circuits of all weighted providers are open
+	ErrNoProviderAvailable = errors.New("no weighted provider is available")
+)
+
+// ProviderWeight is the share of balanced payments sent to a provider
+type ProviderWeight struct {
+	Provider string
+	Weight   int
+}
+
+// ParseProviderWeights parses a weight list such as "iyzico:3,paycell:1". Providers with
+// weight 0 are left out.
+func ParseProviderWeights(value string) ([]ProviderWeight, error) {
+	var weights []ProviderWeight
+	seen := make(map[string]bool)
+
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, rawWeight, ok := strings.Cut(entry, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid provider weight %q, expected provider:weight", entry)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight for provider %s: %q", name, rawWeight)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("provider %s is weighted more than once", name)
+		}
+		seen[name] = true
+
+		if weight > 0 {
+			weights = append(weights, ProviderWeight{Provider: name, Weight: weight})
+		}
+	}
+	return weights, nil
+}
+
+// ProviderWeightsFromEnv returns the provider weights of a tenant, read from
+// PROVIDER_WEIGHTS_TENANT_<ID>_<ENVIRONMENT> or else PROVIDER_WEIGHTS_TENANT_<ID>
+func ProviderWeightsFromEnv(tenantID int, environment string) ([]ProviderWeight, error) {
+	tenantKey := "PROVIDER_WEIGHTS_TENANT_" + strconv.Itoa(tenantID)
+	for _, key := range []string{tenantKey + "_" + strings.ToUpper(environment), tenantKey} {
+		if value := strings.TrimSpace(config.GetEnv(key, "")); value != "" {
+			weights, err := ParseProviderWeights(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			return weights, nil
+		}
+	}
+	return nil, nil
+}
+
+// ProviderBalancer spreads balanced payments over a tenant's providers with smooth weighted
+// round-robin: with weights iyzico:3,paycell:1 every 4 payments send 3 to iyzico and 1 to
+// paycell, interleaved rather than in bursts. Providers with an open circuit are skipped and
+// the rest share their volume by weight.
+type ProviderBalancer struct {
+	breaker *CircuitBreaker
+	weights func(tenantID int, environment string) ([]ProviderWeight, error)
+
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+// NewProviderBalancer creates a balancer over the weights in the environment
+func NewProviderBalancer(breaker *CircuitBreaker) *ProviderBalancer {
+	return &ProviderBalancer{
+		breaker: breaker,
+		weights: ProviderWeightsFromEnv,
+		current: make(map[string]map[string]int),
+	}
+}
+
+// Select picks the provider of the tenant's next balanced payment
+func (b *ProviderBalancer) Select(tenantID int, environment string) (string, error) {
+	weights, err := b.weights(tenantID, environment)
+	if err != nil {
+		return "", err
+	}
+	if len(weights) == 0 {
+		return "", ErrProviderWeightsNotConfigured
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := fmt.Sprintf("%d:%s", tenantID, environment)
+	current, ok := b.current[key]
+	if !ok {
+		current = make(map[string]int)
+		b.current[key] = current
+	}
+
+	selected, total := "", 0
+	for _, weight := range weights {
+		if b.breaker.Open(tenantID, weight.Provider, environment) {
+			continue
+		}
+		current[weight.Provider] += weight.Weight
+		total += weight.Weight
+		if selected == "" || current[weight.Provider] > current[selected] {
+			selected = weight.Provider
+		}
+	}
+	if selected == "" {
+		return "", ErrNoProviderAvailable
+	}
+
+	current[selected] -= total
+	return selected, nil
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func newTestProviderBalancer(breaker *CircuitBreaker, weights ...ProviderWeight) *ProviderBalancer {
+	b := NewProviderBalancer(breaker)
+	b.weights = func(int, string) ([]ProviderWeight, error) { return weights, nil }
+	return b
+}
+
+func TestParseProviderWeights(t *testing.T) {
+	weights, err := ParseProviderWeights(" iyzico:3, Paycell:1 ,stripe:0,")
+	if err != nil {
+		t.Fatalf("ParseProviderWeights failed: %v", err)
+	}
+	expected := []ProviderWeight{{"iyzico", 3}, {"paycell", 1}}
+	if len(weights) != len(expected) || weights[0] != expected[0] || weights[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, weights)
+	}
+
+	for _, value := range []string{"iyzico", "iyzico:x", "iyzico:-1", ":2", "iyzico:1,iyzico:2"} {
+		if _, err := ParseProviderWeights(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestProviderWeightsFromEnv(t *testing.T) {
+	t.Setenv("PROVIDER_WEIGHTS_TENANT_7", "iyzico:1,paycell:1")
+	t.Setenv("PROVIDER_WEIGHTS_TENANT_7_PRODUCTION", "iyzico:9,paycell:1")
+
+	weights, err := ProviderWeightsFromEnv(7, "production")
+	if err != nil || len(weights) != 2 || weights[0].Weight != 9 {
+		t.Errorf("Expected the production weights, got %+v (%v)", weights, err)
+	}
+	weights, err = ProviderWeightsFromEnv(7, "sandbox")
+	if err != nil || len(weights) != 2 || weights[0].Weight != 1 {
+		t.Errorf("Expected the tenant weights, got %+v (%v)", weights, err)
+	}
+	if weights, err := ProviderWeightsFromEnv(8, "sandbox"); err != nil || weights != nil {
+		t.Errorf("Expected no weights for an unconfigured tenant, got %+v (%v)", weights, err)
+	}
+}
+
+func TestProviderBalancer_DistributionMatchesWeights(t *testing.T) {
+	b := newTestProviderBalancer(nil, ProviderWeight{"iyzico", 5}, ProviderWeight{"paycell", 3}, ProviderWeight{"stripe", 2})
+
+	const payments = 10_000
+	counts := make(map[string]int)
+	for range payments {
+		name, err := b.Select(1, "sandbox")
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		counts[name]++
+	}
+
+	expected := map[string]int{"iyzico": 5000, "paycell": 3000, "stripe": 2000}
+	for name, want := range expected {
+		if counts[name] != want {
+			t.Errorf("Expected %d payments for %s, got %d", want, name, counts[name])
+		}
+	}
+}
+
+func TestProviderBalancer_Interleaves(t *testing.T) {
+	b := newTestProviderBalancer(nil, ProviderWeight{"iyzico", 3}, ProviderWeight{"paycell", 1})
+
+	var sequence []string
+	for range 8 {
+		name, _ := b.Select(1, "sandbox")
+		sequence = append(sequence, name)
+	}
+
+	expected := []string{"iyzico", "iyzico", "paycell", "iyzico", "iyzico", "iyzico", "paycell", "iyzico"}
+	for i := range expected {
+		if sequence[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, sequence)
+		}
+	}
+}
+
+func TestProviderBalancer_SkipsOpenCircuits(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker(t, 1, time.Minute)
+	b := newTestProviderBalancer(breaker, ProviderWeight{"iyzico", 2}, ProviderWeight{"paycell", 1}, ProviderWeight{"stripe", 1})
+
+	breaker.RecordFailure(1, "iyzico", "sandbox")
+
+	counts := make(map[string]int)
+	for range 1000 {
+		name, err := b.Select(1, "sandbox")
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		counts[name]++
+	}
+	if counts["iyzico"] != 0 || counts["paycell"] != 500 || counts["stripe"] != 500 {
+		t.Errorf("Expected iyzico to be skipped and the rest to share by weight, got %v", counts)
+	}
+
+	// Another tenant's open circuit does not matter
+	if name, _ := b.Select(2, "sandbox"); name != "iyzico" {
+		t.Errorf("Expected iyzico for another tenant, got %s", name)
+	}
+
+	breaker.RecordFailure(1, "paycell", "sandbox")
+	breaker.RecordFailure(1, "stripe", "sandbox")
+	if _, err := b.Select(1, "sandbox"); !errors.Is(err, ErrNoProviderAvailable) {
+		t.Errorf("Expected ErrNoProviderAvailable, got %v", err)
+	}
+}
+
+func TestProviderBalancer_NotConfigured(t *testing.T) {
+	b := newTestProviderBalancer(nil)
+	if _, err := b.Select(1, "sandbox"); !errors.Is(err, ErrProviderWeightsNotConfigured) {
+		t.Errorf("Expected ErrProviderWeightsNotConfigured, got %v", err)
+	}
+}
+
+func TestPaymentService_CreatePayment_Balanced(t *testing.T) {
+	const tenantID = 9110
+	t.Setenv("PROVIDER_WEIGHTS_TENANT_9110", "balancea:3,balanceb:1")
+
+	providers := map[string]*riskTestProvider{"balancea": {}, "balanceb": {}}
+	for name, fake := range providers {
+		GetProviderCache().Set(tenantID, name, "sandbox", fake)
+		t.Cleanup(func() { GetProviderCache().Delete(tenantID, name, "sandbox") })
+	}
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9110")
+
+	for range 8 {
+		resp, err := service.CreatePayment(ctx, "sandbox", BalancedProviderName, riskRequest())
+		if err != nil {
+			t.Fatalf("CreatePayment failed: %v", err)
+		}
+		if _, ok := providers[resp.Provider]; !ok {
+			t.Fatalf("Expected the picked provider in the response, got %q", resp.Provider)
+		}
+	}
+	if providers["balancea"].calls != 6 || providers["balanceb"].calls != 2 {
+		t.Errorf("Expected 6/2 payments, got %d/%d", providers["balancea"].calls, providers["balanceb"].calls)
+	}
+
+	// Payments to a named provider do not report it back
+	resp, err := service.CreatePayment(ctx, "sandbox", "balancea", riskRequest())
+	if err != nil || resp.Provider != "" {
+		t.Errorf("Expected no provider in a direct payment response, got %q (%v)", resp.Provider, err)
+	}
+}
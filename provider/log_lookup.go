@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// Defaults for LOG_LOOKUP_RETRIES and LOG_LOOKUP_RETRY_DELAY_MS. With doubling backoff the
+// default waits at most 50+100+200ms before giving up.
+const (
+	defaultLogLookupRetries      = 3
+	defaultLogLookupRetryDelayMs = 50
+	maxLogLookupRetries          = 10
+)
+
+// errLogRowNotVisible marks a lookup that found no log row for the payment, which may just
+// not be visible yet. The message keeps the "key <name> not found" wording callers log.
+var errLogRowNotVisible = errors.New("not found")
+
+// logLookupRetryPolicy bounds how long a request log lookup waits for a just-written row
+// to become visible, e.g. on a lagging read replica
+type logLookupRetryPolicy struct {
+	retries int
+	delay   time.Duration
+}
+
+// logLookupRetryPolicyFromEnv reads LOG_LOOKUP_RETRIES (0 disables retrying, capped at 10)
+// and LOG_LOOKUP_RETRY_DELAY_MS, the delay before the first retry
+func logLookupRetryPolicyFromEnv() logLookupRetryPolicy {
+	retries := config.GetIntEnv("LOG_LOOKUP_RETRIES", defaultLogLookupRetries)
+	if retries < 0 {
+		retries = defaultLogLookupRetries
+	}
+	retries = min(retries, maxLogLookupRetries)
+
+	delayMs := config.GetIntEnv("LOG_LOOKUP_RETRY_DELAY_MS", defaultLogLookupRetryDelayMs)
+	if delayMs <= 0 {
+		delayMs = defaultLogLookupRetryDelayMs
+	}
+
+	return logLookupRetryPolicy{retries: retries, delay: time.Duration(delayMs) * time.Millisecond}
+}
+
+// do runs lookup and, while it reports errLogRowNotVisible, retries with a doubling delay.
+// Any other error is returned immediately.
+func (p logLookupRetryPolicy) do(lookup func() (string, error)) (string, error) {
+	delay := p.delay
+	for attempt := 0; ; attempt++ {
+		value, err := lookup()
+		if err == nil || !errors.Is(err, errLogRowNotVisible) || attempt >= p.retries {
+			return value, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// delayedLogRow simulates a log row that only becomes visible to reads after some attempts
+type delayedLogRow struct {
+	visibleAfter int
+	calls        int
+}
+
+func (d *delayedLogRow) lookup() (string, error) {
+	d.calls++
+	if d.calls <= d.visibleAfter {
+		return "", fmt.Errorf("key cardToken %w", errLogRowNotVisible)
+	}
+	return "card-token-123", nil
+}
+
+func TestLogLookupRetryPolicy_DelayedVisibility(t *testing.T) {
+	policy := logLookupRetryPolicy{retries: 3, delay: time.Millisecond}
+
+	row := &delayedLogRow{visibleAfter: 2}
+	value, err := policy.do(row.lookup)
+	if err != nil || value != "card-token-123" {
+		t.Fatalf("Expected the row to be found after retrying, got %q, %v", value, err)
+	}
+	if row.calls != 3 {
+		t.Errorf("Expected 3 lookups, got %d", row.calls)
+	}
+
+	row = &delayedLogRow{visibleAfter: 10}
+	_, err = policy.do(row.lookup)
+	if !errors.Is(err, errLogRowNotVisible) || err.Error() != "key cardToken not found" {
+		t.Errorf("Expected not found error once retries are exhausted, got %v", err)
+	}
+	if row.calls != 4 {
+		t.Errorf("Expected 1 lookup and 3 retries, got %d", row.calls)
+	}
+}
+
+func TestLogLookupRetryPolicy_OtherErrorsAreNotRetried(t *testing.T) {
+	policy := logLookupRetryPolicy{retries: 3, delay: time.Millisecond}
+
+	calls := 0
+	_, err := policy.do(func() (string, error) {
+		calls++
+		return "", errors.New("key savedCardId not found")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected a single lookup for a missing key, got %d lookups, err %v", calls, err)
+	}
+
+	row := &delayedLogRow{visibleAfter: 1}
+	if _, err := (logLookupRetryPolicy{}).do(row.lookup); err == nil || row.calls != 1 {
+		t.Errorf("Expected no retry when retries are disabled, got %d lookups, err %v", row.calls, err)
+	}
+}
+
+func TestLogLookupRetryPolicyFromEnv(t *testing.T) {
+	tests := []struct {
+		retries, delay  string
+		expectedRetries int
+		expectedDelay   time.Duration
+	}{
+		{"", "", defaultLogLookupRetries, defaultLogLookupRetryDelayMs * time.Millisecond},
+		{"5", "20", 5, 20 * time.Millisecond},
+		{"0", "20", 0, 20 * time.Millisecond},
+		{"100", "0", maxLogLookupRetries, defaultLogLookupRetryDelayMs * time.Millisecond},
+		{"-1", "invalid", defaultLogLookupRetries, defaultLogLookupRetryDelayMs * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("LOG_LOOKUP_RETRIES", tt.retries)
+		t.Setenv("LOG_LOOKUP_RETRY_DELAY_MS", tt.delay)
+
+		policy := logLookupRetryPolicyFromEnv()
+		if policy.retries != tt.expectedRetries || policy.delay != tt.expectedDelay {
+			t.Errorf("LOG_LOOKUP_RETRIES=%q LOG_LOOKUP_RETRY_DELAY_MS=%q: expected %d/%v, got %d/%v",
+				tt.retries, tt.delay, tt.expectedRetries, tt.expectedDelay, policy.retries, policy.delay)
+		}
+	}
+}
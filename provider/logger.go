@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"strconv"
 	"strings"
 	"sync"
@@ -236,6 +237,94 @@ func (l *DBPaymentLogger) LogError(ctx context.Context, logID int64, errorCode,
 	return l.logResponse(ctx, logID, errorResponse, processingMs, errorCode)
 }
 
+// RefundableAmount returns the amount of a payment less its successful refunds, read from
+// the provider's log table
+func (l *DBPaymentLogger) RefundableAmount(ctx context.Context, tenantID int, providerName, paymentID string) (float64, string, error) {
+	tableName, err := l.getActualProviderName(providerName)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid provider name: %w", err)
+	}
+
+	var amount float64
+	var currency sql.NullString
+	query := fmt.Sprintf(`
+		SELECT amount, currency FROM %s
+		WHERE tenant_id = $1 AND payment_id = $2 AND endpoint IN ('/payment', '/payment/3d') AND amount > 0
+		ORDER BY id DESC
+		LIMIT 1
+	`, tableName)
+	if err := l.db.QueryRowContext(ctx, query, tenantID, paymentID).Scan(&amount, &currency); err != nil {
+		return 0, "", err
+	}
+
+	var refunded float64
+	query = fmt.Sprintf(`
+		SELECT COALESCE(SUM((request->>'refundAmount')::numeric), 0) FROM %s
+		WHERE tenant_id = $1 AND endpoint = '/payment/refund' AND request->>'paymentId' = $2
+		AND (response->>'success')::boolean IS TRUE
+	`, tableName)
+	if err := l.db.QueryRowContext(ctx, query, tenantID, paymentID).Scan(&refunded); err != nil {
+		return 0, "", fmt.Errorf("failed to sum refunds: %w", err)
+	}
+
+	return amount - refunded, currency.String, nil
+}
+
+// CapturableAmount returns the authorized amount of a payment less its successful captures,
+// read from the provider's log table
+func (l *DBPaymentLogger) CapturableAmount(ctx context.Context, tenantID int, providerName, paymentID string) (float64, string, error) {
+	tableName, err := l.getActualProviderName(providerName)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid provider name: %w", err)
+	}
+
+	var amount float64
+	var currency sql.NullString
+	query := fmt.Sprintf(`
+		SELECT amount, currency FROM %s
+		WHERE tenant_id = $1 AND payment_id = $2 AND endpoint = '/payment' AND amount > 0
+		ORDER BY id DESC
+		LIMIT 1
+	`, tableName)
+	if err := l.db.QueryRowContext(ctx, query, tenantID, paymentID).Scan(&amount, &currency); err != nil {
+		return 0, "", err
+	}
+
+	// Captures log the amount they took; only captures made before partial capture tracking
+	// may carry none, and those took the whole authorization
+	var captured float64
+	query = fmt.Sprintf(`
+		SELECT COALESCE(SUM(COALESCE(NULLIF((request->>'amount')::numeric, 0), $3)), 0) FROM %s
+		WHERE tenant_id = $1 AND endpoint = '/payment/capture' AND request->>'paymentId' = $2
+		AND (response->>'success')::boolean IS TRUE
+	`, tableName)
+	if err := l.db.QueryRowContext(ctx, query, tenantID, paymentID, amount).Scan(&captured); err != nil {
+		return 0, "", fmt.Errorf("failed to sum captures: %w", err)
+	}
+
+	return amount - captured, currency.String, nil
+}
+
+// PaymentTime returns when a payment was first requested, for the refund window check
+func (l *DBPaymentLogger) PaymentTime(ctx context.Context, tenantID int, providerName, paymentID string) (time.Time, error) {
+	tableName, err := l.getActualProviderName(providerName)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid provider name: %w", err)
+	}
+
+	var requestAt time.Time
+	query := fmt.Sprintf(`
+		SELECT request_at FROM %s
+		WHERE tenant_id = $1 AND payment_id = $2 AND endpoint IN ('/payment', '/payment/3d') AND request_at IS NOT NULL
+		ORDER BY id ASC
+		LIMIT 1
+	`, tableName)
+	if err := l.db.QueryRowContext(ctx, query, tenantID, paymentID).Scan(&requestAt); err != nil {
+		return time.Time{}, err
+	}
+	return requestAt, nil
+}
+
 // getActualProviderName extracts the actual provider name from providers table
 func (l *DBPaymentLogger) getActualProviderName(providerName string) (string, error) {
 	query := `
@@ -305,8 +394,55 @@ func GetProvider(tenantID int, providerName, environment string) (PaymentProvide
 	return provider, nil
 }
 
-// loadProviderFromDB loads provider configuration from database and initializes it
+// loadProviderFromDB loads provider configuration from database, or from the tenant config
+// source when one is set, and initializes it
 func loadProviderFromDB(tenantID int, providerName, environment string) (PaymentProvider, error) {
+	// Get provider factory from registry
+	providerFactory, err := Get(providerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider factory for %s: %w", providerName, err)
+	}
+
+	var configs map[string]string
+	if source := getTenantConfigSource(); source != nil {
+		sourced, err := source.TenantConfig(tenantID, providerName, environment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tenant config: %w", err)
+		}
+		// The source's map is the caller's, so the environment is added to a copy
+		configs = maps.Clone(sourced)
+	} else if configs, err = queryTenantConfig(tenantID, providerName, environment); err != nil {
+		return nil, err
+	}
+	foundRows := len(configs) > 0
+	if configs == nil {
+		configs = make(map[string]string)
+	}
+
+	// Add environment to configs map (critical fix!)
+	configs["environment"] = environment
+
+	// Create and initialize provider. A tenant without config rows gets every required field
+	// listed, so the merchant knows what to set.
+	provider := providerFactory()
+	if err := checkProviderConfigured(provider, providerName, environment, configs); err != nil {
+		return nil, err
+	}
+	if !foundRows {
+		return nil, &ProviderNotConfiguredError{Provider: providerName, Environment: environment, MissingConfig: provider.GetRequiredConfig(environment)}
+	}
+	if err := provider.Initialize(configs); err != nil {
+		return nil, fmt.Errorf("failed to initialize provider %s: %w", providerName, err)
+	}
+	setDescriptionTemplate(tenantID, providerName, environment, configs[DescriptionTemplateConfigKey])
+	setInstallmentCreditOnly(tenantID, providerName, environment, configs[InstallmentCreditOnlyConfigKey])
+	setWebhookClientCertPins(tenantID, providerName, environment, configs[WebhookClientCertConfigKey])
+
+	return provider, nil
+}
+
+// queryTenantConfig reads the configuration of a tenant's provider from tenant_configs
+func queryTenantConfig(tenantID int, providerName, environment string) (map[string]string, error) {
 	query := `
 		SELECT tc.tenant_id, p.name as provider_name, tc.environment, tc.key, tc.value 
 		FROM tenant_configs tc
@@ -322,10 +458,7 @@ func loadProviderFromDB(tenantID int, providerName, environment string) (Payment
 	defer rows.Close()
 
 	configs := make(map[string]string)
-	var foundRows bool
-
 	for rows.Next() {
-		foundRows = true
 		var tenantID int
 		var providerName, environment, key, value string
 		if err := rows.Scan(&tenantID, &providerName, &environment, &key, &value); err != nil {
@@ -338,27 +471,7 @@ func loadProviderFromDB(tenantID int, providerName, environment string) (Payment
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
-
-	if !foundRows {
-		return nil, fmt.Errorf("no configuration found for tenant: %d, provider: %s, environment: %s", tenantID, providerName, environment)
-	}
-
-	// Add environment to configs map (critical fix!)
-	configs["environment"] = environment
-
-	// Get provider factory from registry
-	providerFactory, err := Get(providerName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get provider factory for %s: %w", providerName, err)
-	}
-
-	// Create and initialize provider
-	provider := providerFactory()
-	if err := provider.Initialize(configs); err != nil {
-		return nil, fmt.Errorf("failed to initialize provider %s: %w", providerName, err)
-	}
-
-	return provider, nil
+	return configs, nil
 }
 
 // StructToMap converts any struct or value to map[string]any via JSON marshaling
@@ -404,7 +517,17 @@ func AddProviderRequestToClientRequest(providerName, keyName string, providerReq
 	return nil
 }
 
+// GetProviderRequestFromLogWithPaymentID returns the first value stored under key anywhere in
+// the request logs of paymentID. Callers often read right after the row was written, so when
+// no log row is visible yet (e.g. read replica lag) the lookup is retried briefly, see
+// logLookupRetryPolicyFromEnv.
 func GetProviderRequestFromLogWithPaymentID(providerName string, paymentID string, key string) (string, error) {
+	return logLookupRetryPolicyFromEnv().do(func() (string, error) {
+		return getProviderRequestFromLogWithPaymentID(providerName, paymentID, key)
+	})
+}
+
+func getProviderRequestFromLogWithPaymentID(providerName string, paymentID string, key string) (string, error) {
 	query := fmt.Sprintf(`
 		WITH RECURSIVE json_tree AS (
 			SELECT key, value
@@ -428,6 +551,12 @@ func GetProviderRequestFromLogWithPaymentID(providerName string, paymentID strin
 	err := config.App().DB.QueryRow(query, paymentID, key).Scan(&result)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// Only a missing log row is worth retrying; a row without the key stays that way
+			var exists bool
+			existsQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE payment_id = $1)`, providerName)
+			if err := config.App().DB.QueryRow(existsQuery, paymentID).Scan(&exists); err == nil && !exists {
+				return "", fmt.Errorf("key %s %w", key, errLogRowNotVisible)
+			}
 			return "", fmt.Errorf("key %s not found", key)
 		}
 		return "", fmt.Errorf("failed to find key in JSON: %w", err)
@@ -0,0 +1,1116 @@
+package mercadopago
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API URL, the same for test and production credentials
+	apiURL = "https://api.mercadopago.com"
+
+	// API Endpoints
+	endpointCardTokens     = "/v1/card_tokens"
+	endpointPayments       = "/v1/payments"
+	endpointPaymentMethods = "/v1/payment_methods"
+	endpointPreferences    = "/checkout/preferences"
+
+	// Headers of a signed webhook
+	signatureHeader = "X-Signature"
+	requestIDHeader = "X-Request-Id"
+
+	// Payment statuses
+	statusApproved    = "approved"
+	statusAuthorized  = "authorized"
+	statusPending     = "pending"
+	statusInProcess   = "in_process"
+	statusInMediation = "in_mediation"
+	statusRejected    = "rejected"
+	statusCancelled   = "cancelled"
+	statusRefunded    = "refunded"
+	statusChargedBack = "charged_back"
+
+	// Results Checkout Pro returns with, set on its back URLs
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultPending = "pending"
+
+	// errorNotFound is the error of an unknown payment
+	errorNotFound = "not_found"
+)
+
+// countryCurrencies are the currencies of the countries Mercado Pago operates in. An account
+// belongs to one country and only takes payments in its currency.
+var countryCurrencies = map[string]string{
+	"AR": "ARS",
+	"BR": "BRL",
+	"CL": "CLP",
+	"CO": "COP",
+	"MX": "MXN",
+	"PE": "PEN",
+	"UY": "UYU",
+}
+
+// MercadoPagoProvider implements the provider.PaymentProvider interface for Mercado Pago
+type MercadoPagoProvider struct {
+	accessToken   string
+	webhookSecret string
+	currency      string
+	baseURL       string
+	gopayBaseURL  string
+	isProduction  bool
+	httpClient    *provider.ProviderHTTPClient
+
+	// methods caches the card payment methods of the account, which BIN lookups match against
+	methodsMu sync.Mutex
+	methods   []paymentMethod
+}
+
+// Ensure MercadoPagoProvider satisfies the optional capability interfaces.
+var (
+	_ provider.PIXProvider         = (*MercadoPagoProvider)(nil)
+	_ provider.CardBinInfoProvider = (*MercadoPagoProvider)(nil)
+)
+
+// NewProvider creates a new Mercado Pago payment provider
+func NewProvider() provider.PaymentProvider {
+	return &MercadoPagoProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Mercado Pago
+func (p *MercadoPagoProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "accessToken",
+			Required:    true,
+			Type:        "string",
+			Description: "Mercado Pago access token (TEST-... for test credentials, APP_USR-... for production)",
+			Example:     "TEST-1234567890123456-011512-abcdef1234567890abcdef1234567890-123456789",
+			MinLength:   20,
+			MaxLength:   200,
+		},
+		{
+			Key:         "webhookSecret",
+			Required:    false,
+			Type:        "string",
+			Description: "Secret signature of the webhooks set up in the Mercado Pago dashboard; unsigned notifications are rejected when it is set",
+			Example:     "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4",
+			MaxLength:   100,
+		},
+		{
+			Key:         "country",
+			Required:    false,
+			Type:        "string",
+			Description: "Country of the account (AR, BR, CL, CO, MX, PE or UY), which limits payments to its currency",
+			Example:     "BR",
+			Pattern:     "^(AR|BR|CL|CO|MX|PE|UY)$",
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Mercado Pago requirements
+func (p *MercadoPagoProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("mercadopago", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currency of the account's country, or the currencies of all
+// the countries Mercado Pago operates in when the country is not configured
+func (p *MercadoPagoProvider) SupportedCurrencies() []string {
+	if p.currency != "" {
+		return []string{p.currency}
+	}
+	return []string{"ARS", "BRL", "CLP", "COP", "MXN", "PEN", "UYU"}
+}
+
+// HealthCheckEndpoint returns the payment methods endpoint, which rejects unauthenticated requests
+func (p *MercadoPagoProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiURL
+	}
+	return baseURL + endpointPaymentMethods
+}
+
+// Initialize sets up the Mercado Pago payment provider with authentication credentials
+func (p *MercadoPagoProvider) Initialize(conf map[string]string) error {
+	p.accessToken = conf["accessToken"]
+	p.webhookSecret = conf["webhookSecret"]
+
+	if p.accessToken == "" {
+		return errors.New("mercadopago: accessToken is required")
+	}
+
+	p.currency = ""
+	if country := strings.ToUpper(conf["country"]); country != "" {
+		currency, ok := countryCurrencies[country]
+		if !ok {
+			return fmt.Errorf("mercadopago: unknown country %q", conf["country"])
+		}
+		p.currency = currency
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	// Test and production credentials use the same API; the access token decides the mode
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiURL
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("mercadopago"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *MercadoPagoProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *MercadoPagoProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment charges a card: the card is tokenized and paid with the payment method its
+// BIN belongs to. Payments are made in binary mode, so they are approved or rejected at once.
+func (p *MercadoPagoProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		return nil, fmt.Errorf("mercadopago: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	reference := request.ID
+	if reference == "" {
+		reference = provider.NewPaymentID()
+	}
+
+	cardNumber := provider.NormalizePAN(request.CardInfo.CardNumber)
+	binInfo, err := p.GetCardBinInfo(ctx, cardNumber[:6])
+	if err != nil {
+		return nil, err
+	}
+	if binInfo == nil {
+		return nil, errors.New("mercadopago: the card is not accepted by the account")
+	}
+
+	expireYear := request.CardInfo.ExpireYear
+	if len(expireYear) == 2 {
+		expireYear = "20" + expireYear
+	}
+	month, _ := strconv.Atoi(request.CardInfo.ExpireMonth)
+	year, _ := strconv.Atoi(expireYear)
+	holderName := request.CardInfo.CardHolderName
+	if holderName == "" {
+		holderName = strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+	}
+
+	var token cardToken
+	errs, err := p.send(ctx, http.MethodPost, endpointCardTokens, cardTokenRequest{
+		CardNumber:      cardNumber,
+		ExpirationMonth: month,
+		ExpirationYear:  year,
+		SecurityCode:    request.CardInfo.CVV,
+		Cardholder:      cardholder{Name: holderName},
+	}, &token, "")
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	paymentReq := p.paymentRequest(request, reference)
+	paymentReq.Token = token.ID
+	paymentReq.PaymentMethodID = binInfo.CardAssociation
+	paymentReq.Installments = max(request.InstallmentCount, 1)
+	paymentReq.BinaryMode = true
+
+	var result payment
+	errs, err = p.send(ctx, http.MethodPost, endpointPayments, paymentReq, &result, reference)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	return paymentResponse(&result), nil
+}
+
+// Create3DPayment creates a Checkout Pro preference: the customer pays on Mercado Pago's page,
+// which authenticates the card, and returns through the GoPay callback
+func (p *MercadoPagoProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, true); err != nil {
+		return nil, fmt.Errorf("mercadopago: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	reference := request.ID
+	if reference == "" {
+		reference = provider.NewPaymentID()
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "mercadopago", provider.CallbackState{
+		TenantID:         request.TenantID,
+		PaymentID:        reference,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "mercadopago",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		SessionID:        request.SessionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	title := request.Description
+	if title == "" {
+		title = "Payment"
+	}
+	preferenceReq := preferenceRequest{
+		Items: []preferenceItem{{
+			ID:         reference,
+			Title:      title,
+			Quantity:   1,
+			UnitPrice:  amount(request.Amount, request.Currency),
+			CurrencyID: request.Currency,
+		}},
+		Payer: &payer{
+			Email:     request.Customer.Email,
+			FirstName: request.Customer.Name,
+			LastName:  request.Customer.Surname,
+		},
+		BackURLs: backURLs{
+			Success: gopayCallbackURL + "&result=" + resultSuccess,
+			Failure: gopayCallbackURL + "&result=" + resultFailure,
+			Pending: gopayCallbackURL + "&result=" + resultPending,
+		},
+		AutoReturn:        "approved",
+		ExternalReference: reference,
+		NotificationURL:   p.webhookURL(request.TenantID),
+	}
+	if request.InstallmentCount > 1 {
+		preferenceReq.PaymentMethods = &preferencePaymentMethods{Installments: request.InstallmentCount}
+	}
+
+	var created preference
+	errs, err := p.send(ctx, http.MethodPost, endpointPreferences, preferenceReq, &created, "")
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	checkoutURL := created.InitPoint
+	if !p.isProduction && created.SandboxInitPoint != "" {
+		checkoutURL = created.SandboxInitPoint
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:           true,
+		Status:            provider.StatusPending,
+		Message:           "Redirect the customer to Mercado Pago",
+		PaymentID:         reference,
+		TransactionID:     created.ID,
+		Amount:            request.Amount,
+		Currency:          request.Currency,
+		HostedCheckoutURL: checkoutURL,
+		RedirectURL:       checkoutURL,
+		SystemTime:        &now,
+		ProviderResponse:  created,
+	}, nil
+}
+
+// CreatePIXPayment creates a pending PIX payment, whose code the customer pays from their
+// bank app. Mercado Pago reports the payment with a webhook once it is paid.
+func (p *MercadoPagoProvider) CreatePIXPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if request.Amount <= 0 {
+		return nil, errors.New("mercadopago: amount must be greater than 0")
+	}
+	if request.Customer.Email == "" {
+		return nil, errors.New("mercadopago: customer email is required")
+	}
+
+	reference := request.ID
+	if reference == "" {
+		reference = provider.NewPaymentID()
+	}
+
+	paymentReq := p.paymentRequest(request, reference)
+	paymentReq.PaymentMethodID = provider.PaymentMethodTypePIX
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodPost, endpointPayments, paymentReq, &result, reference)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	response := paymentResponse(&result)
+	if result.PointOfInteraction != nil && result.PointOfInteraction.TransactionData != nil {
+		data := result.PointOfInteraction.TransactionData
+		response.PIX = &provider.PIXCode{
+			QRCode:      data.QRCode,
+			QRCodeImage: data.QRCodeBase64,
+			TicketURL:   data.TicketURL,
+			ExpiresAt:   provider.ParseProviderTime(result.DateOfExpiration, time.UTC, time.RFC3339Nano),
+		}
+	}
+	return response, nil
+}
+
+// Complete3DPayment completes a Checkout Pro payment when the page returns. The payment is
+// read back and checked against the preference it was made for.
+func (p *MercadoPagoProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("mercadopago: no callback data received")
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("mercadopago", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        callbackState.PaymentID,
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	paymentID := data["payment_id"]
+	if paymentID == "" {
+		paymentID = data["collection_id"]
+	}
+	if paymentID == "" || paymentID == "null" {
+		// The customer left the page without paying
+		response.Status = provider.StatusCancelled
+		response.ErrorCode = strings.ToUpper(data["result"])
+		response.Message = "Payment cancelled by the customer"
+		if data["result"] == resultFailure {
+			response.Status = provider.StatusFailed
+			response.Message = "Mercado Pago did not approve the payment"
+		}
+		return response, nil
+	}
+
+	result, errs, err := p.getPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.code()
+		response.Message = errs.Message
+		return response, nil
+	}
+	if result.ExternalReference != callbackState.PaymentID || provider.ToMinorUnits(result.TransactionAmount, result.CurrencyID) != provider.ToMinorUnits(callbackState.Amount, callbackState.Currency) {
+		return nil, errors.New("mercadopago: payment does not match the callback state")
+	}
+
+	response = paymentResponse(result)
+	response.RedirectURL = callbackState.OriginalCallback
+	return response, nil
+}
+
+// GetPaymentStatus retrieves the current status of a payment
+func (p *MercadoPagoProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("mercadopago: paymentID is required")
+	}
+
+	result, errs, err := p.getPayment(ctx, request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		now := time.Now()
+		response := &provider.PaymentResponse{
+			Status:           provider.StatusFailed,
+			ErrorCode:        errs.code(),
+			Message:          errs.Message,
+			PaymentID:        request.PaymentID,
+			SystemTime:       &now,
+			ProviderResponse: errs,
+		}
+		if errs.notFound() {
+			response.ErrorCode = provider.ErrorCodePaymentNotFound
+			return response, fmt.Errorf("mercadopago: %w", provider.ErrPaymentNotFound)
+		}
+		return response, nil
+	}
+
+	return paymentResponse(result), nil
+}
+
+// CancelPayment cancels a payment that is not final yet, e.g. an unpaid PIX payment
+func (p *MercadoPagoProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("mercadopago: paymentID is required for cancel")
+	}
+
+	var result payment
+	errs, err := p.send(ctx, http.MethodPut, p.paymentEndpoint(request.PaymentID), cancelRequest{Status: statusCancelled}, &result, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if errs == nil {
+		response := paymentResponse(&result)
+		response.Success = result.Status == statusCancelled
+		response.Message = "Payment cancelled"
+		return response, nil
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Status:           provider.StatusFailed,
+		ErrorCode:        errs.code(),
+		Message:          errs.Message,
+		PaymentID:        request.PaymentID,
+		SystemTime:       &now,
+		ProviderResponse: errs,
+	}
+
+	// Mercado Pago refuses to cancel a final payment without saying why, so the payment
+	// tells whether it was approved or cancelled already
+	var failure error
+	if errs.notFound() {
+		response.ErrorCode, failure = provider.ErrorCodePaymentNotFound, provider.ErrPaymentNotFound
+	} else if current, currentErrs, currentErr := p.getPayment(ctx, request.PaymentID); currentErr == nil && currentErrs == nil {
+		switch current.Status {
+		case statusApproved, statusRefunded, statusChargedBack:
+			response.ErrorCode, failure = provider.ErrorCodeAlreadyCaptured, provider.ErrAlreadyCaptured
+		case statusCancelled:
+			response.ErrorCode, failure = provider.ErrorCodeAlreadyCancelled, provider.ErrAlreadyCancelled
+		}
+	}
+	if failure != nil {
+		return response, fmt.Errorf("mercadopago: %w", failure)
+	}
+	return response, nil
+}
+
+// RefundPayment refunds an approved payment, in full or in part
+func (p *MercadoPagoProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("mercadopago: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("mercadopago: refund amount must be greater than 0")
+	}
+
+	// Mercado Pago requires an idempotency key on refunds
+	idempotencyKey := request.IdempotencyKey
+	if idempotencyKey == "" {
+		idempotencyKey = uuid.NewString()
+	}
+
+	var result refund
+	errs, err := p.send(ctx, http.MethodPost, p.paymentEndpoint(request.PaymentID)+"/refunds", refundRequest{Amount: amount(request.RefundAmount, request.Currency)}, &result, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refundResp := &provider.RefundResponse{
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+	}
+
+	if errs != nil {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = errs.code()
+		refundResp.Message = errs.Message
+		refundResp.RawResponse = errs
+		return refundResp, nil
+	}
+
+	refundResp.RefundID = strconv.FormatInt(result.ID, 10)
+	refundResp.RawResponse = result
+	switch result.Status {
+	case statusApproved:
+		refundResp.Success = true
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+	case statusRejected, statusCancelled:
+		refundResp.Status = "failed"
+		refundResp.Message = "Refund rejected"
+	default:
+		refundResp.Success = true
+		refundResp.Status = "pending"
+		refundResp.Message = "Refund accepted, Mercado Pago is processing it"
+	}
+	return refundResp, nil
+}
+
+// ValidateWebhook handles Mercado Pago's payment notifications: webhooks, signed in the
+// x-signature header when a secret is set up, and IPN notifications, which are not signed.
+// The payment they name is read back from Mercado Pago and its own status is reported.
+func (p *MercadoPagoProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	var paymentID, topic string
+	if data["type"] != "" {
+		// Webhook: {"type": "payment", "action": "payment.updated", "data": {"id": "123"}}
+		var resource struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(data["data"]), &resource); err != nil {
+			return false, nil, fmt.Errorf("mercadopago: invalid webhook data: %w", err)
+		}
+		paymentID, topic = strings.Trim(string(resource.ID), `"`), data["type"]
+	} else {
+		// IPN: {"topic": "payment", "resource": "123"}, the resource possibly as a URL
+		resource := data["resource"]
+		paymentID, topic = resource[strings.LastIndex(resource, "/")+1:], data["topic"]
+	}
+	if topic != "payment" || paymentID == "" {
+		return false, nil, fmt.Errorf("mercadopago: unsupported notification %q", topic)
+	}
+
+	if p.webhookSecret != "" {
+		if err := p.verifySignature(paymentID, headers); err != nil {
+			return false, nil, err
+		}
+	}
+
+	result, errs, err := p.getPayment(ctx, paymentID)
+	if err != nil {
+		return false, nil, err
+	}
+	if errs != nil {
+		return false, nil, fmt.Errorf("mercadopago: unknown payment %s: %s", paymentID, errs.code())
+	}
+
+	status := "pending"
+	switch result.status() {
+	case provider.StatusSuccessful, provider.StatusAuthorized:
+		status = "success"
+	case provider.StatusFailed, provider.StatusCancelled:
+		status = "failed"
+	case provider.StatusRefunded:
+		status = "refunded"
+	}
+
+	return true, map[string]string{
+		"paymentId": paymentID,
+		"status":    status,
+		"eventType": data["action"],
+	}, nil
+}
+
+// verifySignature verifies the x-signature header of a webhook, "ts=<unix time>,v1=<hex
+// HMAC-SHA256>" over the manifest "id:<data.id>;request-id:<x-request-id>;ts:<ts>;"
+func (p *MercadoPagoProvider) verifySignature(paymentID string, headers map[string]string) error {
+	var ts, v1 string
+	for _, part := range strings.Split(headers[signatureHeader], ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "ts":
+			ts = value
+		case "v1":
+			v1 = value
+		}
+	}
+	if ts == "" || v1 == "" {
+		return errors.New("mercadopago: missing signature header")
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte("id:" + strings.ToLower(paymentID) + ";request-id:" + headers[requestIDHeader] + ";ts:" + ts + ";"))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(v1)) != 1 {
+		return errors.New("mercadopago: invalid signature")
+	}
+	return nil
+}
+
+// GetCardBinInfo looks up the payment method of a card BIN among the card payment methods of
+// the account. CardAssociation is Mercado Pago's payment method ID, e.g. "master".
+func (p *MercadoPagoProvider) GetCardBinInfo(ctx context.Context, bin string) (*provider.CardBinInfo, error) {
+	methods, err := p.cardPaymentMethods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range methods {
+		if method.matches(bin) {
+			return &provider.CardBinInfo{
+				BIN:             bin,
+				CardType:        method.PaymentTypeID,
+				CardAssociation: method.ID,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// cardPaymentMethods returns the active card payment methods of the account, which are read
+// once and kept
+func (p *MercadoPagoProvider) cardPaymentMethods(ctx context.Context) ([]paymentMethod, error) {
+	p.methodsMu.Lock()
+	defer p.methodsMu.Unlock()
+	if p.methods != nil {
+		return p.methods, nil
+	}
+
+	var all []paymentMethod
+	errs, err := p.send(ctx, http.MethodGet, endpointPaymentMethods, nil, &all, "")
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("mercadopago: failed to get payment methods: %s", errs.Message)
+	}
+
+	methods := []paymentMethod{}
+	for _, method := range all {
+		if method.Status == "active" && strings.HasSuffix(method.PaymentTypeID, "_card") {
+			methods = append(methods, method)
+		}
+	}
+	p.methods = methods
+	return methods, nil
+}
+
+// validatePaymentRequest validates the payment request. A 3D payment is made on Checkout
+// Pro, which collects the card itself.
+func (p *MercadoPagoProvider) validatePaymentRequest(request provider.PaymentRequest, is3D bool) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Currency == "" {
+		return errors.New("currency is required")
+	}
+
+	if p.currency != "" && !strings.EqualFold(request.Currency, p.currency) {
+		return fmt.Errorf("the account only takes payments in %s", p.currency)
+	}
+
+	if request.Customer.Email == "" {
+		return errors.New("customer email is required")
+	}
+
+	if is3D {
+		if !request.CardInfo.IsEmpty() {
+			return errors.New("3D payments are made on Mercado Pago's checkout page, send no card details")
+		}
+		if request.CallbackURL == "" {
+			return errors.New("callback URL is required for 3D secure payments")
+		}
+		return nil
+	}
+
+	if len(provider.NormalizePAN(request.CardInfo.CardNumber)) < 12 {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiry month and year are required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("card CVV is required")
+	}
+
+	return nil
+}
+
+// paymentRequest builds the payment of a request, without its payment method
+func (p *MercadoPagoProvider) paymentRequest(request provider.PaymentRequest, reference string) *paymentRequest {
+	description := request.Description
+	if description == "" {
+		description = "Payment"
+	}
+	return &paymentRequest{
+		TransactionAmount: amount(request.Amount, request.Currency),
+		Description:       description,
+		Payer: &payer{
+			Email:     request.Customer.Email,
+			FirstName: request.Customer.Name,
+			LastName:  request.Customer.Surname,
+		},
+		ExternalReference: reference,
+		NotificationURL:   p.webhookURL(request.TenantID),
+	}
+}
+
+// getPayment reads a payment
+func (p *MercadoPagoProvider) getPayment(ctx context.Context, paymentID string) (*payment, *apiError, error) {
+	var result payment
+	errs, err := p.send(ctx, http.MethodGet, p.paymentEndpoint(paymentID), nil, &result, "")
+	if err != nil || errs != nil {
+		return nil, errs, err
+	}
+	return &result, nil, nil
+}
+
+// paymentEndpoint returns the endpoint of a payment
+func (p *MercadoPagoProvider) paymentEndpoint(paymentID string) string {
+	return endpointPayments + "/" + url.PathEscape(paymentID)
+}
+
+// webhookURL returns the GoPay webhook URL Mercado Pago notifies of a payment
+func (p *MercadoPagoProvider) webhookURL(tenantID int) string {
+	notificationURL := fmt.Sprintf("%s/v1/webhooks/mercadopago", p.gopayBaseURL)
+	if tenantID != 0 {
+		notificationURL += fmt.Sprintf("?tenantId=%d", tenantID)
+	}
+	return notificationURL
+}
+
+// send sends an authenticated request, decoding a successful response into result. A request
+// Mercado Pago rejects returns its error instead of an error. Payments and refunds are sent
+// with an idempotency key.
+func (p *MercadoPagoProvider) send(ctx context.Context, method, endpoint string, body any, result any, idempotencyKey string) (*apiError, error) {
+	httpReq := &provider.HTTPRequest{
+		Method:   method,
+		Endpoint: endpoint,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Bearer " + p.accessToken,
+		},
+	}
+	if idempotencyKey != "" {
+		httpReq.Headers["X-Idempotency-Key"] = idempotencyKey
+	}
+	if body != nil {
+		httpReq.Body = body
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, httpReq)
+	if err != nil && resp == nil {
+		return nil, fmt.Errorf("mercadopago: request failed: %w", err)
+	}
+
+	if err != nil {
+		var errs apiError
+		if parseErr := p.httpClient.ParseJSONResponse(resp, &errs); parseErr != nil || (errs.Error == "" && errs.Message == "") {
+			return nil, fmt.Errorf("mercadopago: request failed: %w", err)
+		}
+		return &errs, nil
+	}
+
+	if result != nil && len(resp.Body) > 0 {
+		if err := p.httpClient.ParseJSONResponse(resp, result); err != nil {
+			return nil, fmt.Errorf("mercadopago: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+// paymentResponse maps a payment
+func paymentResponse(result *payment) *provider.PaymentResponse {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Status:           result.status(),
+		Message:          result.StatusDetail,
+		PaymentID:        strconv.FormatInt(result.ID, 10),
+		TransactionID:    strconv.FormatInt(result.ID, 10),
+		OrderID:          result.ExternalReference,
+		Amount:           result.TransactionAmount,
+		Currency:         result.CurrencyID,
+		SystemTime:       &now,
+		ProviderTime:     provider.ParseProviderTime(result.DateCreated, time.UTC, time.RFC3339Nano),
+		ProviderResponse: result,
+	}
+
+	switch response.Status {
+	case provider.StatusSuccessful, provider.StatusAuthorized, provider.StatusPending:
+		response.Success = true
+	case provider.StatusFailed:
+		response.ErrorCode = result.StatusDetail
+	}
+
+	if result.PaymentMethodID == provider.PaymentMethodTypePIX {
+		response.PaymentMethodDetails = &provider.PaymentMethodDetails{Type: provider.PaymentMethodTypePIX, Network: "pix"}
+	} else if strings.HasSuffix(result.PaymentTypeID, "_card") {
+		response.PaymentMethodDetails = &provider.PaymentMethodDetails{
+			Type:        provider.PaymentMethodTypeCard,
+			Brand:       provider.NormalizeCardBrand(result.PaymentMethodID),
+			FundingType: provider.NormalizeFundingType(result.PaymentTypeID),
+		}
+	}
+	return response
+}
+
+// failedResponse maps a request Mercado Pago rejected
+func failedResponse(paymentID string, amount float64, currency string, errs *apiError) *provider.PaymentResponse {
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Status:           provider.StatusFailed,
+		ErrorCode:        errs.code(),
+		Message:          errs.Message,
+		PaymentID:        paymentID,
+		Amount:           amount,
+		Currency:         currency,
+		SystemTime:       &now,
+		ProviderResponse: errs,
+	}
+}
+
+// amount rounds an amount to the minor unit of its currency; Mercado Pago takes amounts in
+// major units, and whole ones in CLP
+func amount(value float64, currency string) float64 {
+	return provider.FromMinorUnits(provider.ToMinorUnits(value, currency), currency)
+}
+
+// apiError is the body of a request Mercado Pago rejected
+type apiError struct {
+	Message string       `json:"message"`
+	Error   string       `json:"error"`
+	Status  int          `json:"status"`
+	Cause   []errorCause `json:"cause"`
+}
+
+// errorCause is a reason of an error. Its code is a number or a string depending on the API.
+type errorCause struct {
+	Code        any    `json:"code"`
+	Description string `json:"description"`
+}
+
+// code returns the code of the first cause of the error, or the error itself
+func (e *apiError) code() string {
+	if len(e.Cause) > 0 && e.Cause[0].Code != nil {
+		return fmt.Sprint(e.Cause[0].Code)
+	}
+	return e.Error
+}
+
+// notFound reports whether the error is about an unknown payment
+func (e *apiError) notFound() bool {
+	return e.Error == errorNotFound || e.Status == http.StatusNotFound
+}
+
+// cardTokenRequest tokenizes a card
+type cardTokenRequest struct {
+	CardNumber      string     `json:"card_number"`
+	ExpirationMonth int        `json:"expiration_month"`
+	ExpirationYear  int        `json:"expiration_year"`
+	SecurityCode    string     `json:"security_code"`
+	Cardholder      cardholder `json:"cardholder"`
+}
+
+// cardholder is the holder of a card
+type cardholder struct {
+	Name string `json:"name"`
+}
+
+// cardToken is a tokenized card
+type cardToken struct {
+	ID             string `json:"id"`
+	FirstSixDigits string `json:"first_six_digits"`
+	LastFourDigits string `json:"last_four_digits"`
+	Status         string `json:"status"`
+}
+
+// paymentRequest creates a payment, in major units
+type paymentRequest struct {
+	TransactionAmount float64 `json:"transaction_amount"`
+	Token             string  `json:"token,omitempty"`
+	Description       string  `json:"description"`
+	Installments      int     `json:"installments,omitempty"`
+	PaymentMethodID   string  `json:"payment_method_id"`
+	Payer             *payer  `json:"payer"`
+	ExternalReference string  `json:"external_reference"`
+	NotificationURL   string  `json:"notification_url,omitempty"`
+	BinaryMode        bool    `json:"binary_mode,omitempty"`
+}
+
+// payer is the customer of a payment
+type payer struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// cancelRequest updates the status of a payment to cancelled
+type cancelRequest struct {
+	Status string `json:"status"`
+}
+
+// payment is a payment, amounts in major units
+type payment struct {
+	ID                        int64               `json:"id"`
+	Status                    string              `json:"status"`
+	StatusDetail              string              `json:"status_detail"`
+	TransactionAmount         float64             `json:"transaction_amount"`
+	TransactionAmountRefunded float64             `json:"transaction_amount_refunded"`
+	CurrencyID                string              `json:"currency_id"`
+	PaymentMethodID           string              `json:"payment_method_id"`
+	PaymentTypeID             string              `json:"payment_type_id"`
+	ExternalReference         string              `json:"external_reference"`
+	Installments              int                 `json:"installments"`
+	DateCreated               string              `json:"date_created"`
+	DateApproved              string              `json:"date_approved"`
+	DateOfExpiration          string              `json:"date_of_expiration"`
+	LiveMode                  bool                `json:"live_mode"`
+	PointOfInteraction        *pointOfInteraction `json:"point_of_interaction"`
+}
+
+// status maps the status of a payment
+func (p *payment) status() provider.PaymentStatus {
+	switch p.Status {
+	case statusApproved:
+		if p.TransactionAmountRefunded > 0 {
+			return provider.StatusRefunded
+		}
+		return provider.StatusSuccessful
+	case statusAuthorized:
+		return provider.StatusAuthorized
+	case statusPending, statusInProcess, statusInMediation:
+		return provider.StatusPending
+	case statusRejected:
+		return provider.StatusFailed
+	case statusCancelled:
+		return provider.StatusCancelled
+	case statusRefunded, statusChargedBack:
+		return provider.StatusRefunded
+	}
+	return provider.StatusUnknown
+}
+
+// pointOfInteraction carries the code of a PIX payment
+type pointOfInteraction struct {
+	Type            string           `json:"type"`
+	TransactionData *transactionData `json:"transaction_data"`
+}
+
+// transactionData is the code of a PIX payment
+type transactionData struct {
+	QRCode       string `json:"qr_code"`
+	QRCodeBase64 string `json:"qr_code_base64"`
+	TicketURL    string `json:"ticket_url"`
+}
+
+// preferenceRequest creates a Checkout Pro preference
+type preferenceRequest struct {
+	Items             []preferenceItem          `json:"items"`
+	Payer             *payer                    `json:"payer,omitempty"`
+	BackURLs          backURLs                  `json:"back_urls"`
+	AutoReturn        string                    `json:"auto_return"`
+	ExternalReference string                    `json:"external_reference"`
+	NotificationURL   string                    `json:"notification_url,omitempty"`
+	PaymentMethods    *preferencePaymentMethods `json:"payment_methods,omitempty"`
+}
+
+// preferenceItem is an item of a preference, in major units
+type preferenceItem struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Quantity   int     `json:"quantity"`
+	UnitPrice  float64 `json:"unit_price"`
+	CurrencyID string  `json:"currency_id"`
+}
+
+// backURLs are the URLs Checkout Pro returns to
+type backURLs struct {
+	Success string `json:"success"`
+	Failure string `json:"failure"`
+	Pending string `json:"pending"`
+}
+
+// preferencePaymentMethods limits the payment methods of a preference
+type preferencePaymentMethods struct {
+	Installments int `json:"installments,omitempty"`
+}
+
+// preference is a created Checkout Pro preference
+type preference struct {
+	ID               string `json:"id"`
+	InitPoint        string `json:"init_point"`
+	SandboxInitPoint string `json:"sandbox_init_point"`
+}
+
+// refundRequest refunds a payment, in major units
+type refundRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+// refund is a refund of a payment
+type refund struct {
+	ID          int64   `json:"id"`
+	PaymentID   int64   `json:"payment_id"`
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status"`
+	DateCreated string  `json:"date_created"`
+}
+
+// paymentMethod is a payment method of the account
+type paymentMethod struct {
+	ID            string                  `json:"id"`
+	Name          string                  `json:"name"`
+	PaymentTypeID string                  `json:"payment_type_id"`
+	Status        string                  `json:"status"`
+	Settings      []paymentMethodSettings `json:"settings"`
+}
+
+// paymentMethodSettings are the card numbers a payment method takes
+type paymentMethodSettings struct {
+	Bin struct {
+		Pattern          string `json:"pattern"`
+		ExclusionPattern string `json:"exclusion_pattern"`
+	} `json:"bin"`
+}
+
+// matches reports whether a BIN belongs to the payment method
+func (m *paymentMethod) matches(bin string) bool {
+	for _, settings := range m.Settings {
+		if matchPattern(settings.Bin.Pattern, bin) && (settings.Bin.ExclusionPattern == "" || !matchPattern(settings.Bin.ExclusionPattern, bin)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches a BIN against a pattern of the payment methods API; invalid patterns
+// match nothing
+func matchPattern(pattern, bin string) bool {
+	if pattern == "" {
+		return false
+	}
+	re, err := regexp.Compile(pattern)
+	return err == nil && re.MatchString(bin)
+}
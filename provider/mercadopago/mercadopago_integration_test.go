@@ -0,0 +1,90 @@
+package mercadopago
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// setupRealTestProvider returns a provider with the test access token from
+// MERCADOPAGO_ACCESS_TOKEN, skipping the test when it is not set
+func setupRealTestProvider(t *testing.T) *MercadoPagoProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"accessToken": os.Getenv("MERCADOPAGO_ACCESS_TOKEN"),
+		"country":     "BR",
+		"environment": "sandbox",
+	}
+	if config["accessToken"] == "" {
+		t.Skip("mercadopago test access token not set; skipping real API test")
+	}
+
+	p := NewProvider().(*MercadoPagoProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestMercadoPagoProvider_RealAPI_CreatePayment pays with a test card, which the "APRO"
+// holder name approves
+func TestMercadoPagoProvider_RealAPI_CreatePayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.CreatePayment(context.Background(), provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.00,
+		Currency: "BRL",
+		Customer: provider.Customer{
+			Name:    "Test",
+			Surname: "User",
+			Email:   "test_user_123@testuser.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardHolderName: "APRO",
+			CardNumber:     "5031433215406351",
+			ExpireMonth:    "11",
+			ExpireYear:     "2030",
+			CVV:            "123",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v status=%s payment=%s message=%s", response.Success, response.Status, response.PaymentID, response.Message)
+}
+
+// TestMercadoPagoProvider_RealAPI_CreatePIXPayment creates a PIX payment, which stays pending
+func TestMercadoPagoProvider_RealAPI_CreatePIXPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.CreatePIXPayment(context.Background(), provider.PaymentRequest{
+		TenantID:      1,
+		Amount:        10.00,
+		Currency:      "BRL",
+		PaymentMethod: provider.PaymentMethodTypePIX,
+		Customer: provider.Customer{
+			Name:    "Test",
+			Surname: "User",
+			Email:   "test_user_123@testuser.com",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePIXPayment failed: %v", err)
+	}
+	t.Logf("PIX payment: success=%v status=%s payment=%s code=%+v", response.Success, response.Status, response.PaymentID, response.PIX)
+}
+
+// TestMercadoPagoProvider_RealAPI_UnknownPayment queries a payment that does not exist
+func TestMercadoPagoProvider_RealAPI_UnknownPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "1"})
+	t.Logf("Status: %+v (%v)", response, err)
+}
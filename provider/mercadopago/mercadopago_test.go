@@ -0,0 +1,606 @@
+package mercadopago
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"accessToken":   "TEST-1234567890123456-011512-abcdef1234567890abcdef1234567890-123456789",
+		"webhookSecret": "webhook_secret_123",
+		"country":       "BR",
+		"environment":   environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	mercadoPagoProvider, ok := p.(*MercadoPagoProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a MercadoPagoProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if mercadoPagoProvider.httpClient != nil {
+		t.Error("MercadoPagoProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := mercadoPagoProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if mercadoPagoProvider.httpClient == nil {
+		t.Error("MercadoPagoProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestMercadoPagoProvider_Initialize(t *testing.T) {
+	with := func(key, value string) map[string]string {
+		config := testConfig("sandbox")
+		config[key] = value
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		currencies  []string
+		expectError bool
+	}{
+		{"sandbox", testConfig("sandbox"), []string{"BRL"}, false},
+		{"production", testConfig("production"), []string{"BRL"}, false},
+		{"lowercase country", with("country", "mx"), []string{"MXN"}, false},
+		{"without country", with("country", ""), []string{"ARS", "BRL", "CLP", "COP", "MXN", "PEN", "UYU"}, false},
+		{"unknown country", with("country", "US"), nil, true},
+		{"missing access token", with("accessToken", ""), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &MercadoPagoProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := strings.Join(p.SupportedCurrencies(), ","); got != strings.Join(tt.currencies, ",") {
+				t.Errorf("Expected currencies %v, got %s", tt.currencies, got)
+			}
+		})
+	}
+}
+
+func TestMercadoPagoProvider_GetRequiredConfig(t *testing.T) {
+	p := &MercadoPagoProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	required := map[string]bool{"accessToken": true, "webhookSecret": false, "country": false, "environment": true}
+	if len(fields) != len(required) {
+		t.Fatalf("Expected %d config fields, got %d", len(required), len(fields))
+	}
+	for _, field := range fields {
+		if expected, ok := required[field.Key]; !ok || field.Required != expected {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+// newTestProvider returns a provider whose API is answered by handler, after checking the
+// request is authenticated. The card payment methods of the account are answered for it.
+func newTestProvider(t *testing.T, handler func(r *http.Request, body map[string]any) (int, any)) *MercadoPagoProvider {
+	t.Helper()
+	p := providertest.Initialize[*MercadoPagoProvider](t, NewProvider, testConfig("sandbox"))
+
+	server := providertest.Server(t, providertest.JSON(func(r *http.Request, body map[string]any) (int, any) {
+		if r.Header.Get("Authorization") != "Bearer "+p.accessToken {
+			t.Errorf("Unauthenticated request to %s", r.URL)
+		}
+		if r.URL.Path == endpointPaymentMethods {
+			return http.StatusOK, paymentMethodsData()
+		}
+		return handler(r, body)
+	}))
+
+	p.baseURL = server.URL
+	p.gopayBaseURL = "https://gopay.example.com"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("mercadopago"))
+	return p
+}
+
+func paymentMethodsData() []map[string]any {
+	method := func(id, paymentType, status, pattern, exclusion string) map[string]any {
+		return map[string]any{
+			"id":              id,
+			"name":            id,
+			"payment_type_id": paymentType,
+			"status":          status,
+			"settings":        []map[string]any{{"bin": map[string]any{"pattern": pattern, "exclusion_pattern": exclusion}}},
+		}
+	}
+	return []map[string]any{
+		method("visa", "credit_card", "active", "^4", "^(487017)"),
+		method("master", "credit_card", "active", "^(5|2[2-7])", "^(502121|506699)"),
+		method("debmaster", "debit_card", "active", "^(502121)", ""),
+		method("elo", "credit_card", "inactive", "^(506699)", ""),
+		method("pix", "bank_transfer", "active", "", ""),
+	}
+}
+
+func paymentRequestFixture() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID: 1,
+		ID:       "gp1",
+		Amount:   150.75,
+		Currency: "BRL",
+		Customer: provider.Customer{
+			Name:    "João",
+			Surname: "Silva",
+			Email:   "joao.silva@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardHolderName: "APRO",
+			CardNumber:     "5031 4332 1540 6351",
+			ExpireMonth:    "11",
+			ExpireYear:     "30",
+			CVV:            "123",
+		},
+	}
+}
+
+func paymentData(status string) map[string]any {
+	return map[string]any{
+		"id":                          int64(1234567890),
+		"status":                      status,
+		"status_detail":               "accredited",
+		"transaction_amount":          150.75,
+		"transaction_amount_refunded": 0,
+		"currency_id":                 "BRL",
+		"payment_method_id":           "master",
+		"payment_type_id":             "credit_card",
+		"external_reference":          "gp1",
+		"installments":                1,
+		"date_created":                "2024-01-15T10:20:30.000-03:00",
+		"date_approved":               "2024-01-15T10:20:31.000-03:00",
+		"live_mode":                   false,
+	}
+}
+
+func apiErrorData(status int, code, message string) map[string]any {
+	return map[string]any{"message": message, "error": code, "status": status, "cause": []map[string]any{{"code": 2006, "description": message}}}
+}
+
+func TestMercadoPagoProvider_GetCardBinInfo(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		bin  string
+		want string
+	}{
+		{"503143", "master"},
+		{"450995", "visa"},
+		{"487017", ""},
+		{"502121", "debmaster"},
+		{"506699", ""},
+		{"300000", ""},
+	}
+	for _, tt := range tests {
+		info, err := p.GetCardBinInfo(ctx, tt.bin)
+		if err != nil {
+			t.Fatalf("GetCardBinInfo failed: %v", err)
+		}
+		if tt.want == "" {
+			if info != nil {
+				t.Errorf("%s: expected no payment method, got %+v", tt.bin, info)
+			}
+			continue
+		}
+		if info == nil || info.CardAssociation != tt.want || info.BIN != tt.bin {
+			t.Errorf("%s: expected %s, got %+v", tt.bin, tt.want, info)
+		}
+	}
+}
+
+func TestMercadoPagoProvider_CreatePayment(t *testing.T) {
+	var tokenReq, paymentReq map[string]any
+	var idempotencyKey string
+	paymentStatus := statusApproved
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointCardTokens:
+			tokenReq = body
+			return http.StatusCreated, map[string]any{"id": "tok_1", "first_six_digits": "503143", "last_four_digits": "6351", "status": "active"}
+		case endpointPayments:
+			paymentReq, idempotencyKey = body, r.Header.Get("X-Idempotency-Key")
+			return http.StatusCreated, paymentData(paymentStatus)
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+	ctx := context.Background()
+
+	response, err := p.CreatePayment(ctx, paymentRequestFixture())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if tokenReq["card_number"] != "5031433215406351" || tokenReq["expiration_year"] != float64(2030) || tokenReq["expiration_month"] != float64(11) || tokenReq["cardholder"].(map[string]any)["name"] != "APRO" {
+		t.Errorf("Unexpected card token request %v", tokenReq)
+	}
+	if paymentReq["token"] != "tok_1" || paymentReq["payment_method_id"] != "master" || paymentReq["transaction_amount"] != 150.75 || paymentReq["external_reference"] != "gp1" || paymentReq["binary_mode"] != true || paymentReq["installments"] != float64(1) {
+		t.Errorf("Unexpected payment request %v", paymentReq)
+	}
+	if paymentReq["notification_url"] != "https://gopay.example.com/v1/webhooks/mercadopago?tenantId=1" || idempotencyKey != "gp1" {
+		t.Errorf("Unexpected notification URL %v or idempotency key %q", paymentReq["notification_url"], idempotencyKey)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "1234567890" || response.OrderID != "gp1" || response.ProviderTime == nil {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.PaymentMethodDetails == nil || response.PaymentMethodDetails.Brand != "mastercard" || response.PaymentMethodDetails.FundingType != "credit" {
+		t.Errorf("Unexpected payment method details: %+v", response.PaymentMethodDetails)
+	}
+
+	paymentStatus = statusRejected
+	response, err = p.CreatePayment(ctx, paymentRequestFixture())
+	if err != nil || response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "accredited" {
+		t.Errorf("Expected a rejected payment, got %+v (%v)", response, err)
+	}
+
+	request := paymentRequestFixture()
+	request.CardInfo.CardNumber = "3000000000000004"
+	if _, err := p.CreatePayment(ctx, request); err == nil {
+		t.Error("Expected a card the account does not accept to be rejected")
+	}
+
+	request = paymentRequestFixture()
+	request.Currency = "ARS"
+	if _, err := p.CreatePayment(ctx, request); err == nil {
+		t.Error("Expected a payment in another currency than the account's to be rejected")
+	}
+}
+
+func TestMercadoPagoProvider_Create3DPayment(t *testing.T) {
+	store := provider.NewMemoryCallbackStateStore()
+	provider.SetCallbackStateStore(store)
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	var preferenceReq map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPreferences {
+			t.Errorf("Unexpected request %s", r.URL.Path)
+			return http.StatusNotFound, nil
+		}
+		preferenceReq = body
+		return http.StatusCreated, map[string]any{"id": "pref_1", "init_point": "https://www.mercadopago.com.br/checkout/v1/redirect?pref_id=pref_1", "sandbox_init_point": "https://sandbox.mercadopago.com.br/checkout/v1/redirect?pref_id=pref_1"}
+	})
+
+	request := paymentRequestFixture()
+	request.CardInfo = provider.CardInfo{}
+	request.CallbackURL = "https://merchant.example.com/return"
+	request.InstallmentCount = 3
+	response, err := p.Create3DPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+
+	item := preferenceReq["items"].([]any)[0].(map[string]any)
+	if item["unit_price"] != 150.75 || item["currency_id"] != "BRL" || item["quantity"] != float64(1) || preferenceReq["external_reference"] != "gp1" || preferenceReq["auto_return"] != "approved" {
+		t.Errorf("Unexpected preference request %v", preferenceReq)
+	}
+	if preferenceReq["payment_methods"].(map[string]any)["installments"] != float64(3) {
+		t.Errorf("Expected the installments to be limited, got %v", preferenceReq["payment_methods"])
+	}
+
+	backURLs := preferenceReq["back_urls"].(map[string]any)
+	success, err := url.Parse(backURLs["success"].(string))
+	if err != nil || success.Query().Get("result") != resultSuccess || !strings.HasPrefix(backURLs["success"].(string), "https://gopay.example.com/") {
+		t.Errorf("Unexpected back URLs %v", backURLs)
+	}
+	if !response.Success || response.Status != provider.StatusPending || response.PaymentID != "gp1" || response.HostedCheckoutURL != "https://sandbox.mercadopago.com.br/checkout/v1/redirect?pref_id=pref_1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	request = paymentRequestFixture()
+	request.CallbackURL = "https://merchant.example.com/return"
+	if _, err := p.Create3DPayment(context.Background(), request); err == nil {
+		t.Error("Expected card details to be rejected, Checkout Pro collects them")
+	}
+}
+
+func TestMercadoPagoProvider_CreatePIXPayment(t *testing.T) {
+	var paymentReq map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		paymentReq = body
+		payment := paymentData(statusPending)
+		payment["status_detail"] = "pending_waiting_transfer"
+		payment["payment_method_id"] = "pix"
+		payment["payment_type_id"] = "bank_transfer"
+		payment["date_of_expiration"] = "2024-01-16T10:20:30.000-03:00"
+		payment["point_of_interaction"] = map[string]any{
+			"type": "OPENPLATFORM",
+			"transaction_data": map[string]any{
+				"qr_code":        "00020126580014br.gov.bcb.pix0136123e4567",
+				"qr_code_base64": "iVBORw0KGgo=",
+				"ticket_url":     "https://www.mercadopago.com.br/payments/1234567890/ticket",
+			},
+		}
+		return http.StatusCreated, payment
+	})
+
+	request := paymentRequestFixture()
+	request.CardInfo = provider.CardInfo{}
+	request.PaymentMethod = provider.PaymentMethodTypePIX
+	response, err := p.CreatePIXPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("CreatePIXPayment failed: %v", err)
+	}
+	if paymentReq["payment_method_id"] != "pix" || paymentReq["token"] != nil || paymentReq["payer"].(map[string]any)["email"] != "joao.silva@example.com" {
+		t.Errorf("Unexpected payment request %v", paymentReq)
+	}
+	if !response.Success || response.Status != provider.StatusPending || response.PaymentID != "1234567890" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.PIX == nil || response.PIX.QRCode != "00020126580014br.gov.bcb.pix0136123e4567" || response.PIX.QRCodeImage != "iVBORw0KGgo=" || response.PIX.ExpiresAt == nil {
+		t.Errorf("Unexpected PIX code: %+v", response.PIX)
+	}
+	if response.PaymentMethodDetails == nil || response.PaymentMethodDetails.Type != provider.PaymentMethodTypePIX {
+		t.Errorf("Unexpected payment method details: %+v", response.PaymentMethodDetails)
+	}
+}
+
+func TestMercadoPagoProvider_Complete3DPayment(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path == endpointPayments+"/1234567890" {
+			return http.StatusOK, paymentData(statusApproved)
+		}
+		return http.StatusNotFound, apiErrorData(http.StatusNotFound, errorNotFound, "Payment not found")
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "gp1",
+		OriginalCallback: "https://merchant.example.com/return",
+		Amount:           150.75,
+		Currency:         "BRL",
+		Provider:         "mercadopago",
+		Environment:      "sandbox",
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"result": resultSuccess, "payment_id": "1234567890", "collection_id": "1234567890", "status": "approved", "external_reference": "gp1"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "1234567890" || response.OrderID != "gp1" || response.RedirectURL != "https://merchant.example.com/return" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	other := *callbackState
+	other.PaymentID = "gp2"
+	if _, err := p.Complete3DPayment(ctx, &other, map[string]string{"payment_id": "1234567890"}); err == nil {
+		t.Error("Expected a payment for another preference to be rejected")
+	}
+	other = *callbackState
+	other.Amount = 1
+	if _, err := p.Complete3DPayment(ctx, &other, map[string]string{"payment_id": "1234567890"}); err == nil {
+		t.Error("Expected a payment of another amount to be rejected")
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"result": resultPending, "payment_id": "null"})
+	if err != nil || response.Status != provider.StatusCancelled || response.PaymentID != "gp1" {
+		t.Errorf("Expected a cancelled payment, got %+v (%v)", response, err)
+	}
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"result": resultFailure})
+	if err != nil || response.Status != provider.StatusFailed {
+		t.Errorf("Expected a failed payment, got %+v (%v)", response, err)
+	}
+}
+
+func TestMercadoPagoProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path == endpointPayments+"/1234567890" {
+			payment := paymentData(statusApproved)
+			payment["transaction_amount_refunded"] = 50
+			return http.StatusOK, payment
+		}
+		return http.StatusNotFound, apiErrorData(http.StatusNotFound, errorNotFound, "Payment not found")
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "1234567890"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusRefunded || response.Amount != 150.75 || response.OrderID != "gp1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "999"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound, got %+v (%v)", response, err)
+	}
+}
+
+func TestMercadoPagoProvider_CancelPayment(t *testing.T) {
+	current := statusPending
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPayments+"/1234567890" {
+			return http.StatusNotFound, apiErrorData(http.StatusNotFound, errorNotFound, "Payment not found")
+		}
+		if r.Method == http.MethodGet {
+			return http.StatusOK, paymentData(current)
+		}
+		if body["status"] != statusCancelled {
+			t.Errorf("Unexpected cancel request %v", body)
+		}
+		if current != statusPending {
+			return http.StatusBadRequest, apiErrorData(http.StatusBadRequest, "bad_request", "Invalid payment status")
+		}
+		return http.StatusOK, paymentData(statusCancelled)
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "1234567890"})
+	if err != nil || !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a cancelled payment, got %+v (%v)", response, err)
+	}
+
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{statusApproved, provider.ErrAlreadyCaptured},
+		{statusCancelled, provider.ErrAlreadyCancelled},
+	}
+	for _, tt := range tests {
+		current = tt.status
+		response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "1234567890"})
+		if !errors.Is(err, tt.want) || response.Success {
+			t.Errorf("%s: expected %v, got %+v (%v)", tt.status, tt.want, response, err)
+		}
+	}
+
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "999"}); !errors.Is(err, provider.ErrPaymentNotFound) {
+		t.Errorf("Expected ErrPaymentNotFound, got %v", err)
+	}
+}
+
+func TestMercadoPagoProvider_RefundPayment(t *testing.T) {
+	var refundReq map[string]any
+	var idempotencyKey string
+	refundStatus := statusApproved
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPayments+"/1234567890/refunds" {
+			return http.StatusBadRequest, apiErrorData(http.StatusBadRequest, "bad_request", "Payment not allowed to refund")
+		}
+		refundReq, idempotencyKey = body, r.Header.Get("X-Idempotency-Key")
+		return http.StatusCreated, map[string]any{"id": int64(987654), "payment_id": int64(1234567890), "amount": body["amount"], "status": refundStatus, "date_created": "2024-01-16T10:20:30.000-03:00"}
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "1234567890", RefundAmount: 50.255, Currency: "BRL", IdempotencyKey: "refund-1"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refundReq["amount"] != 50.26 || idempotencyKey != "refund-1" {
+		t.Errorf("Unexpected refund request %v with idempotency key %q", refundReq, idempotencyKey)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "987654" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	refundStatus = statusInProcess
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "1234567890", RefundAmount: 10, Currency: "BRL"})
+	if err != nil || !response.Success || response.Status != "pending" || idempotencyKey == "" {
+		t.Errorf("Expected a pending refund with a generated idempotency key, got %+v (%v)", response, err)
+	}
+
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "999", RefundAmount: 10, Currency: "BRL"})
+	if err != nil || response.Success || response.ErrorCode != "2006" {
+		t.Errorf("Expected Mercado Pago's error, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "1234567890"}); err == nil {
+		t.Error("Expected an error without a refund amount")
+	}
+}
+
+func TestMercadoPagoProvider_ValidateWebhook(t *testing.T) {
+	status := statusApproved
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPayments+"/1234567890" {
+			return http.StatusNotFound, apiErrorData(http.StatusNotFound, errorNotFound, "Payment not found")
+		}
+		return http.StatusOK, paymentData(status)
+	})
+
+	sign := func(id, requestID, ts string) string {
+		mac := hmac.New(sha256.New, []byte("webhook_secret_123"))
+		mac.Write([]byte("id:" + id + ";request-id:" + requestID + ";ts:" + ts + ";"))
+		return "ts=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	webhook := func(id string) (map[string]string, map[string]string) {
+		return map[string]string{
+			"id":        "12345",
+			"type":      "payment",
+			"action":    "payment.updated",
+			"live_mode": "false",
+			"data":      `{"id":"` + id + `"}`,
+		}, map[string]string{
+			signatureHeader: sign(id, "req-1", "1704908010"),
+			requestIDHeader: "req-1",
+		}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{statusApproved, "success"},
+		{statusRejected, "failed"},
+		{statusInProcess, "pending"},
+		{statusRefunded, "refunded"},
+	}
+	for _, tt := range tests {
+		status = tt.status
+		data, headers := webhook("1234567890")
+		valid, result, err := p.ValidateWebhook(ctx, data, headers)
+		if err != nil || !valid || result["paymentId"] != "1234567890" || result["status"] != tt.want || result["eventType"] != "payment.updated" {
+			t.Errorf("%s: unexpected result %v (%v)", tt.status, result, err)
+		}
+	}
+
+	data, headers := webhook("1234567890")
+	headers[requestIDHeader] = "req-2"
+	if valid, _, err := p.ValidateWebhook(ctx, data, headers); err == nil || valid {
+		t.Error("Expected a webhook with a wrong signature to be rejected")
+	}
+
+	data, headers = webhook("999")
+	if valid, _, err := p.ValidateWebhook(ctx, data, headers); err == nil || valid {
+		t.Error("Expected a webhook for an unknown payment to be rejected")
+	}
+
+	ipn := map[string]string{"topic": "payment", "resource": "https://api.mercadolibre.com/collections/notifications/1234567890"}
+	if valid, _, err := p.ValidateWebhook(ctx, ipn, map[string]string{}); err == nil || valid {
+		t.Error("Expected an unsigned notification to be rejected with a webhook secret")
+	}
+
+	p.webhookSecret = ""
+	status = statusApproved
+	valid, result, err := p.ValidateWebhook(ctx, ipn, map[string]string{})
+	if err != nil || !valid || result["paymentId"] != "1234567890" || result["status"] != "success" {
+		t.Errorf("Expected the IPN notification's payment, got %v (%v)", result, err)
+	}
+
+	if _, _, err := p.ValidateWebhook(ctx, map[string]string{"topic": "merchant_order", "resource": "1"}, map[string]string{}); err == nil {
+		t.Error("Expected other notifications to be rejected")
+	}
+}
@@ -0,0 +1,8 @@
+package mercadopago
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Mercado Pago provider with the global registry
+	provider.Register("mercadopago", NewProvider)
+}
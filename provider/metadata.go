@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// Default metadata limits. Keys and values fit Stripe's own metadata limits (50 keys,
+// 40 character keys, 500 character values).
+const (
+	defaultMetadataMaxKeys        = 20
+	defaultMetadataMaxKeyLength   = 40
+	defaultMetadataMaxValueLength = 500
+	defaultMetadataMaxTotalSize   = 8 * 1024
+)
+
+// ErrMetadataTooLarge is returned when PaymentRequest.Metadata exceeds MetadataLimits.
+var ErrMetadataTooLarge = errors.New("metadata exceeds limits")
+
+// MetadataLimits bounds PaymentRequest.Metadata before it is stored with the request log.
+type MetadataLimits struct {
+	MaxKeys        int
+	MaxKeyLength   int // characters
+	MaxValueLength int // characters
+	MaxTotalSize   int // bytes of all keys and values
+}
+
+// MetadataLimitsFromEnv reads METADATA_MAX_KEYS, METADATA_MAX_KEY_LENGTH,
+// METADATA_MAX_VALUE_LENGTH and METADATA_MAX_TOTAL_SIZE, using the defaults for unset or
+// non-positive values.
+func MetadataLimitsFromEnv() MetadataLimits {
+	limit := func(key string, fallback int) int {
+		if value := config.GetIntEnv(key, fallback); value > 0 {
+			return value
+		}
+		return fallback
+	}
+
+	return MetadataLimits{
+		MaxKeys:        limit("METADATA_MAX_KEYS", defaultMetadataMaxKeys),
+		MaxKeyLength:   limit("METADATA_MAX_KEY_LENGTH", defaultMetadataMaxKeyLength),
+		MaxValueLength: limit("METADATA_MAX_VALUE_LENGTH", defaultMetadataMaxValueLength),
+		MaxTotalSize:   limit("METADATA_MAX_TOTAL_SIZE", defaultMetadataMaxTotalSize),
+	}
+}
+
+// Validate returns an error wrapping ErrMetadataTooLarge that names the first limit the
+// metadata exceeds. Empty keys are rejected as well.
+func (l MetadataLimits) Validate(metadata map[string]string) error {
+	if len(metadata) > l.MaxKeys {
+		return fmt.Errorf("%w: %d keys, at most %d allowed", ErrMetadataTooLarge, len(metadata), l.MaxKeys)
+	}
+
+	totalSize := 0
+	for key, value := range metadata {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%w: keys must not be empty", ErrMetadataTooLarge)
+		}
+		if n := utf8.RuneCountInString(key); n > l.MaxKeyLength {
+			return fmt.Errorf("%w: key %q is %d characters, at most %d allowed", ErrMetadataTooLarge, key, n, l.MaxKeyLength)
+		}
+		if n := utf8.RuneCountInString(value); n > l.MaxValueLength {
+			return fmt.Errorf("%w: value of %q is %d characters, at most %d allowed", ErrMetadataTooLarge, key, n, l.MaxValueLength)
+		}
+		totalSize += len(key) + len(value)
+	}
+
+	if totalSize > l.MaxTotalSize {
+		return fmt.Errorf("%w: %d bytes in total, at most %d allowed", ErrMetadataTooLarge, totalSize, l.MaxTotalSize)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestMetadataLimits_Validate(t *testing.T) {
+	limits := MetadataLimits{MaxKeys: 3, MaxKeyLength: 10, MaxValueLength: 20, MaxTotalSize: 40}
+
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		expectError bool
+	}{
+		{"nil metadata", nil, false},
+		{"within limits", map[string]string{"campaign": "summer2024", "channel": "web"}, false},
+		{"key at limit", map[string]string{strings.Repeat("k", 10): "v"}, false},
+		{"multibyte value counts characters", map[string]string{"city": strings.Repeat("ş", 15)}, false},
+		{"too many keys", map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}, true},
+		{"key too long", map[string]string{strings.Repeat("k", 11): "v"}, true},
+		{"value too long", map[string]string{"note": strings.Repeat("v", 21)}, true},
+		{"total size exceeded", map[string]string{"first": strings.Repeat("a", 15), "second": strings.Repeat("b", 15), "third": "c"}, true},
+		{"empty key", map[string]string{" ": "value"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.Validate(tt.metadata)
+			if tt.expectError {
+				if !errors.Is(err, ErrMetadataTooLarge) {
+					t.Errorf("Expected ErrMetadataTooLarge, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestMetadataLimitsFromEnv(t *testing.T) {
+	t.Setenv("METADATA_MAX_KEYS", "5")
+	t.Setenv("METADATA_MAX_KEY_LENGTH", "")
+	t.Setenv("METADATA_MAX_VALUE_LENGTH", "-1")
+	t.Setenv("METADATA_MAX_TOTAL_SIZE", "1024")
+
+	expected := MetadataLimits{
+		MaxKeys:        5,
+		MaxKeyLength:   defaultMetadataMaxKeyLength,
+		MaxValueLength: defaultMetadataMaxValueLength,
+		MaxTotalSize:   1024,
+	}
+	if limits := MetadataLimitsFromEnv(); limits != expected {
+		t.Errorf("Expected %+v, got %+v", expected, limits)
+	}
+}
+
+func TestPaymentService_CreatePayment_RejectsOversizedMetadata(t *testing.T) {
+	t.Setenv("METADATA_MAX_KEYS", "2")
+	paymentLogger := &recordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+
+	request := riskRequest()
+	request.Metadata = map[string]string{}
+	for i := range 3 {
+		request.Metadata[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	if _, err := service.CreatePayment(t.Context(), "sandbox", "iyzico", request); !errors.Is(err, ErrMetadataTooLarge) {
+		t.Fatalf("Expected ErrMetadataTooLarge, got %v", err)
+	}
+	if paymentLogger.requests != 0 {
+		t.Error("Oversized metadata must not be stored")
+	}
+}
+
+func TestPaymentService_CreatePayment_SubscriptionID(t *testing.T) {
+	const tenantID, providerName = 9117, "subscriptiontest"
+
+	GetProviderCache().Set(tenantID, providerName, "sandbox", &amountRecordingProvider{})
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &requestRecordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9117")
+
+	request := riskRequest()
+	request.SubscriptionID = "sub_123"
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if resp.SubscriptionID != "sub_123" {
+		t.Errorf("expected the charge to reference its subscription, got %q", resp.SubscriptionID)
+	}
+	// The search endpoint finds the charges of a subscription by the logged request
+	if logged, ok := paymentLogger.request.(PaymentRequest); !ok || logged.SubscriptionID != "sub_123" {
+		t.Errorf("expected the subscription in the request log, got %+v", paymentLogger.request)
+	}
+}
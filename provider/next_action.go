@@ -0,0 +1,50 @@
+package provider
+
+// NextActionType is what a client does with a payment response. SPA and mobile clients switch
+// on it instead of checking Success, RedirectURL and HTML themselves.
+type NextActionType string
+
+const (
+	// NextActionRedirect sends the customer to NextAction.URL: a 3D page or a hosted checkout
+	NextActionRedirect NextActionType = "redirect"
+	// NextActionRenderHTML renders NextAction.HTML, an auto-submitting 3D form or an iframe
+	NextActionRenderHTML NextActionType = "render_html"
+	// NextActionPollStatus waits for the payment to be final, e.g. approval in a wallet app,
+	// by polling the status of NextAction.PaymentID
+	NextActionPollStatus NextActionType = "poll_status"
+	// NextActionComplete means the payment succeeded; nothing left to do
+	NextActionComplete NextActionType = "complete"
+	// NextActionNone means the payment failed; nothing can be done with it
+	NextActionNone NextActionType = "none"
+)
+
+// NextAction is the single instruction a client follows after CreatePayment/Create3DPayment,
+// with the data of its type
+type NextAction struct {
+	Type NextActionType `json:"type"`
+	// URL is set for redirect
+	URL string `json:"url,omitempty"`
+	// HTML is set for render_html; Form is its parsed form, for clients that post it themselves
+	HTML string         `json:"html,omitempty"`
+	Form *ChallengeForm `json:"form,omitempty"`
+	// PaymentID is set for poll_status
+	PaymentID string `json:"paymentId,omitempty"`
+}
+
+// ResolveNextAction derives the NextAction of a CreatePayment/Create3DPayment response from
+// its Outcome, so both always agree. The response's ChallengeForm is used as the form.
+func ResolveNextAction(resp *PaymentResponse) *NextAction {
+	switch ResolveOutcome(resp) {
+	case OutcomeCompleted:
+		return &NextAction{Type: NextActionComplete}
+	case OutcomeRequiresHostedCheckout:
+		return &NextAction{Type: NextActionRedirect, URL: resp.HostedCheckoutURL}
+	case OutcomeRequiresRedirect:
+		return &NextAction{Type: NextActionRedirect, URL: resp.RedirectURL}
+	case OutcomeRequiresHTMLForm:
+		return &NextAction{Type: NextActionRenderHTML, HTML: resp.HTML, Form: resp.ChallengeForm}
+	case OutcomeRequiresAction:
+		return &NextAction{Type: NextActionPollStatus, PaymentID: resp.PaymentID}
+	}
+	return &NextAction{Type: NextActionNone}
+}
@@ -0,0 +1,72 @@
+package provider
+
+import "testing"
+
+// TestResolveNextAction_ProviderMatrix mirrors the CreatePayment / Create3DPayment response
+// shapes of each provider, like TestResolveOutcome_ProviderMatrix, and checks the action a
+// client gets for each.
+func TestResolveNextAction_ProviderMatrix(t *testing.T) {
+	const html = `<form id="form" method="post" action="https://bank.example/3d"></form>`
+	const iframe = `<iframe src="https://www.paytr.com/odeme/guvenlik/t"></iframe>`
+
+	tests := []struct {
+		provider string
+		flow     string
+		resp     *PaymentResponse
+		expected NextAction
+	}{
+		{"akbank", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, NextAction{Type: NextActionComplete}},
+		{"akbank", "direct declined", &PaymentResponse{Success: false, Status: StatusFailed}, NextAction{Type: NextActionNone}},
+		{"akbank", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"ziraat", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"payten", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"iyzico", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful, RedirectURL: "https://merchant.example/callback"}, NextAction{Type: NextActionComplete}},
+		{"iyzico", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html, RedirectURL: "https://merchant.example/callback"}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"nkolay", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"ozanpay", "3d", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://ozan.example/3d"}, NextAction{Type: NextActionRedirect, URL: "https://ozan.example/3d"}},
+		{"ozanpay", "error", &PaymentResponse{Success: false, Status: StatusPending, ErrorCode: "E1"}, NextAction{Type: NextActionNone}},
+		{"payu", "3d", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://payu.example/3d"}, NextAction{Type: NextActionRedirect, URL: "https://payu.example/3d"}},
+		{"papara", "payment url", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://papara.example/pay", HostedCheckoutURL: "https://papara.example/pay"}, NextAction{Type: NextActionRedirect, URL: "https://papara.example/pay"}},
+		{"papara", "awaiting approval", &PaymentResponse{Success: true, Status: StatusPending, PaymentID: "pp_1"}, NextAction{Type: NextActionPollStatus, PaymentID: "pp_1"}},
+		{"paycell", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, NextAction{Type: NextActionRenderHTML, HTML: html}},
+		{"paycell", "3d session failed", &PaymentResponse{Success: false, Status: StatusFailed, HTML: html}, NextAction{Type: NextActionNone}},
+		{"paytr", "iframe", &PaymentResponse{Success: true, Status: StatusPending, HTML: iframe, RedirectURL: "https://www.paytr.com/odeme/guvenlik/t"}, NextAction{Type: NextActionRenderHTML, HTML: iframe}},
+		{"paytr", "hosted checkout", &PaymentResponse{Success: true, Status: StatusPending, HostedCheckoutURL: "https://www.paytr.com/odeme/guvenlik/t"}, NextAction{Type: NextActionRedirect, URL: "https://www.paytr.com/odeme/guvenlik/t"}},
+		{"stripe", "succeeded", &PaymentResponse{Success: true, Status: StatusSuccessful}, NextAction{Type: NextActionComplete}},
+		{"stripe", "requires_action with redirect", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://hooks.stripe.com/3d"}, NextAction{Type: NextActionRedirect, URL: "https://hooks.stripe.com/3d"}},
+		{"stripe", "processing", &PaymentResponse{Success: true, Status: StatusProcessing, PaymentID: "pi_1"}, NextAction{Type: NextActionPollStatus, PaymentID: "pi_1"}},
+		{"stripe", "canceled", &PaymentResponse{Success: false, Status: StatusCancelled}, NextAction{Type: NextActionNone}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.flow, func(t *testing.T) {
+			got := ResolveNextAction(tt.resp)
+			if got.Type != tt.expected.Type || got.URL != tt.expected.URL || got.HTML != tt.expected.HTML || got.PaymentID != tt.expected.PaymentID {
+				t.Errorf("ResolveNextAction() = %+v, want %+v", *got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveNextAction_Form(t *testing.T) {
+	resp := &PaymentResponse{
+		Success: true,
+		Status:  StatusPending,
+		HTML:    `<form method="post" action="https://bank.example/3d"><input type="hidden" name="PaReq" value="abc"></form>`,
+	}
+	resp.ChallengeForm = challengeFormOf(resp)
+
+	action := ResolveNextAction(resp)
+	if action.Type != NextActionRenderHTML {
+		t.Fatalf("Type = %q, want %q", action.Type, NextActionRenderHTML)
+	}
+	if action.Form == nil || action.Form != resp.ChallengeForm {
+		t.Errorf("Form = %+v, want the response's challenge form", action.Form)
+	}
+}
+
+func TestResolveNextAction_Nil(t *testing.T) {
+	if got := ResolveNextAction(nil); got.Type != NextActionNone {
+		t.Errorf("ResolveNextAction(nil).Type = %q, want %q", got.Type, NextActionNone)
+	}
+}
@@ -9,8 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -42,6 +42,9 @@ const (
 
 	// Default Values
 	defaultCurrency = "TRY"
+
+	// Installment rate group applied to cards without a bank specific program
+	installmentGroupOthers = "OTHERS"
 )
 
 // NkolayProvider implements the provider.PaymentProvider interface for Nkolay
@@ -118,6 +121,20 @@ func (p *NkolayProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("nkolay", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by Nkolay
+func (p *NkolayProvider) SupportedCurrencies() []string {
+	return []string{"TRY"}
+}
+
+// HealthCheckEndpoint returns the Nkolay merchant installment information endpoint
+func (p *NkolayProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointPaymentInstallments
+}
+
 // Initialize sets up the Nkolay payment provider with authentication credentials
 func (p *NkolayProvider) Initialize(conf map[string]string) error {
 	// For real API, use provided credentials. For testing, use test values
@@ -154,7 +171,7 @@ func (p *NkolayProvider) Initialize(conf map[string]string) error {
 		p.baseURL = apiSandboxURL
 	}
 
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("nkolay"))
 
 	return nil
 }
@@ -260,16 +277,67 @@ func (p *NkolayProvider) GetInstallmentCount(ctx context.Context, request provid
 	return response, nil
 }
 
-// GetCommission returns the commission for a payment
+// GetCommission returns the installment surcharge of the requested installment count, or of
+// every count up to MaxInstallmentCount, from the merchant's installment rates
 func (p *NkolayProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
-	return provider.CommissionResponse{}, nil
+	installments, err := p.GetInstallmentCount(ctx, provider.InstallmentInquireRequest{
+		Amount: request.Amount,
+	})
+	if err != nil {
+		return provider.CommissionResponse{}, err
+	}
+
+	breakdown, err := commissionBreakdown(installments.Installments[installmentGroupOthers], request.Amount, request.InstallmentCounts())
+	if err != nil {
+		return provider.CommissionResponse{}, err
+	}
+
+	first := breakdown[0]
+	response := provider.CommissionResponse{
+		Success:          true,
+		Message:          "Commission retrieved successfully",
+		NetAmount:        first.NetAmount,
+		GrossAmount:      first.GrossAmount,
+		CommissionRate:   first.CommissionRate,
+		CommissionAmount: first.CommissionAmount,
+	}
+	if request.MaxInstallmentCount > 0 {
+		response.Installments = breakdown
+	}
+	return response, nil
+}
+
+// commissionBreakdown applies the installment rates to amount. Nkolay adds the rate on top of
+// the amount the customer pays (see processPayment), so the merchant nets the original amount.
+func commissionBreakdown(rates []provider.InstallmentInfo, amount float64, counts []int) ([]provider.InstallmentCommission, error) {
+	rateByCount := make(map[int]float64, len(rates))
+	for _, rate := range rates {
+		rateByCount[rate.Installment] = rate.Commission
+	}
+
+	breakdown := make([]provider.InstallmentCommission, 0, len(counts))
+	for _, count := range counts {
+		rate, ok := rateByCount[count]
+		if !ok {
+			return nil, fmt.Errorf("nkolay: no commission rate for %d installments", count)
+		}
+		commission := math.Round(amount*rate) / 100
+		breakdown = append(breakdown, provider.InstallmentCommission{
+			InstallmentCount: count,
+			NetAmount:        amount,
+			GrossAmount:      amount + commission,
+			CommissionRate:   rate,
+			CommissionAmount: commission,
+		})
+	}
+	return breakdown, nil
 }
 
 // CreatePayment makes a non-3D payment request
 func (p *NkolayProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("nkolay: invalid payment request: %w", err)
+		return nil, fmt.Errorf("nkolay: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, false)
@@ -279,7 +347,7 @@ func (p *NkolayProvider) CreatePayment(ctx context.Context, request provider.Pay
 func (p *NkolayProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("nkolay: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("nkolay: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
@@ -398,8 +466,15 @@ func (p *NkolayProvider) CancelPayment(ctx context.Context, request provider.Can
 		return nil, fmt.Errorf("nkolay: failed to cancel payment: %w", err)
 	}
 
-	return &provider.PaymentResponse{
-		PaymentID:  request.PaymentID,
+	return parseCancelResponse(request.PaymentID, responseBody)
+}
+
+// parseCancelResponse maps the cancel response. A refused cancel returns
+// provider.ErrAlreadyCaptured, ErrAlreadyCancelled or ErrPaymentNotFound when Nkolay's
+// message tells the reason.
+func parseCancelResponse(paymentID string, responseBody []byte) (*provider.PaymentResponse, error) {
+	response := &provider.PaymentResponse{
+		PaymentID:  paymentID,
 		Success:    strings.Contains(string(responseBody), "SUCCESS"),
 		Status:     provider.StatusCancelled,
 		Message:    "Payment cancellation processed",
@@ -407,7 +482,31 @@ func (p *NkolayProvider) CancelPayment(ctx context.Context, request provider.Can
 		ProviderResponse: map[string]any{
 			"raw_response": string(responseBody),
 		},
-	}, nil
+	}
+	if response.Success {
+		return response, nil
+	}
+
+	response.Status = provider.StatusFailed
+	response.Message = strings.TrimSpace(string(responseBody))
+	var jsonResponse map[string]any
+	if err := json.Unmarshal(responseBody, &jsonResponse); err == nil {
+		for _, key := range []string{"ERROR_MESSAGE", "RESPONSE_DATA", "MESSAGE"} {
+			if message, ok := jsonResponse[key].(string); ok && message != "" {
+				response.Message = message
+				break
+			}
+		}
+		if code, ok := jsonResponse["RESPONSE_CODE"]; ok && code != nil {
+			response.ErrorCode = fmt.Sprintf("%v", code)
+		}
+	}
+
+	if code, err := provider.CancelFailure(response.Message); err != nil {
+		response.ErrorCode = code
+		return response, fmt.Errorf("nkolay: %w", err)
+	}
+	return response, nil
 }
 
 // RefundPayment issues a refund for a payment
@@ -544,7 +643,7 @@ func (p *NkolayProvider) processPayment(ctx context.Context, request provider.Pa
 		}
 		// ana tutarı + ( ana tutar * komisyon oranı /100)
 		// find installment count in installmentCount.Installments["OTHERS"]
-		for _, installment := range installmentCount.Installments["OTHERS"] {
+		for _, installment := range installmentCount.Installments[installmentGroupOthers] {
 			if installment.Installment == request.InstallmentCount {
 				request.Amount = request.Amount + (request.Amount * installment.Commission / 100)
 				break
@@ -702,23 +801,20 @@ func (p *NkolayProvider) parsePaymentResponse(responseBody []byte, paymentID str
 		}
 
 		// Check for 3D Secure HTML form in BANK_REQUEST_MESSAGE
-		if bankRequestMessage, ok := jsonResponse["BANK_REQUEST_MESSAGE"].(string); ok && bankRequestMessage != "" && strings.Contains(bankRequestMessage, "form") {
+		if form, ok := provider.ExtractAndCleanHTMLForm(jsonResponse["BANK_REQUEST_MESSAGE"]); ok {
 			response.Success = true
 			response.Status = provider.StatusPending
-			response.Message = bankRequestMessage
-
-			// Clean HTML for client use
-			cleanHTML := p.cleanHTMLForClient(bankRequestMessage)
-			response.HTML = cleanHTML
+			response.Message = fmt.Sprintf("%v", jsonResponse["BANK_REQUEST_MESSAGE"])
+			response.HTML = form
 
 			return response, nil
 		}
 
 		// Also check HTML_STRING as fallback
-		if htmlStr, ok := htmlString.(string); ok && htmlStr != "" && strings.Contains(htmlStr, "form") {
+		if form, ok := provider.ExtractAndCleanHTMLForm(htmlString); ok {
 			response.Success = true
 			response.Status = provider.StatusPending
-			response.HTML = htmlStr
+			response.HTML = form
 
 			return response, nil
 		}
@@ -841,33 +937,3 @@ func (p *NkolayProvider) formatDateForNkolay(systemTime string) (string, error)
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
-
-// cleanHTMLForClient cleans HTML by removing escape characters and formatting properly
-func (p *NkolayProvider) cleanHTMLForClient(htmlStr string) string {
-	// Remove common escape characters
-	cleanHTML := strings.ReplaceAll(htmlStr, "\\r", "")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\\n", "")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\\t", "")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\r", "")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\n", "")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\t", "")
-
-	// Remove JSON escape characters
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\\\"", "\"")
-	cleanHTML = strings.ReplaceAll(cleanHTML, "\\/", "/")
-
-	// Fix JavaScript onload attribute quotation issue
-	// Replace: onload=document.forms["form"].submit()
-	// With: onload="document.forms['form'].submit()"
-	cleanHTML = strings.ReplaceAll(cleanHTML, `onload=document.forms["form"].submit()`, `onload="document.forms['form'].submit()"`)
-
-	// Remove extra spaces between tags and attributes
-	onloadRegex := regexp.MustCompile(`>\s*<`)
-	cleanHTML = onloadRegex.ReplaceAllString(cleanHTML, "><")
-
-	// Clean script tag formatting
-	cleanHTML = strings.ReplaceAll(cleanHTML, ">    var ", "> var ")
-	cleanHTML = strings.ReplaceAll(cleanHTML, ";    ", "; ")
-
-	return cleanHTML
-}
@@ -2,6 +2,7 @@ package nkolay
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -665,3 +666,157 @@ func TestNkolayProvider_ValidateConfig(t *testing.T) {
 }
 
 // Test removed due to type import issues - integration tests cover this functionality
+
+func TestNkolayProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &NkolayProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointPaymentInstallments {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointPaymentInstallments {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestNkolayProvider_GetCommission(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != endpointPaymentInstallments {
+			t.Errorf("Expected path %s, got %s", endpointPaymentInstallments, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"COMMISSION_LIST":[
+			{"CODE":"PARAF","DATA":[{"INSTALLMENT":2,"MERCHANT_COMMISSION_RATE":9.9}]},
+			{"CODE":"OTHERS","DATA":[
+				{"INSTALLMENT":1,"MERCHANT_COMMISSION_RATE":0},
+				{"INSTALLMENT":2,"MERCHANT_COMMISSION_RATE":2.5},
+				{"INSTALLMENT":3,"MERCHANT_COMMISSION_RATE":3.75}
+			]}
+		]}`))
+	}))
+	defer server.Close()
+
+	nkolayProvider := &NkolayProvider{
+		sx:        testSx,
+		secretKey: testSecretKey,
+		baseURL:   server.URL,
+		httpClient: provider.NewProviderHTTPClient(&provider.HTTPClientConfig{
+			BaseURL: server.URL,
+			Timeout: 5 * time.Second,
+		}),
+	}
+
+	request := provider.CommissionRequest{
+		BinValue:            "552879",
+		InstallmentCount:    1,
+		MaxInstallmentCount: 3,
+		Amount:              200,
+	}
+	response, err := nkolayProvider.GetCommission(context.Background(), request)
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+
+	expected := []provider.InstallmentCommission{
+		{InstallmentCount: 1, NetAmount: 200, GrossAmount: 200, CommissionRate: 0, CommissionAmount: 0},
+		{InstallmentCount: 2, NetAmount: 200, GrossAmount: 205, CommissionRate: 2.5, CommissionAmount: 5},
+		{InstallmentCount: 3, NetAmount: 200, GrossAmount: 207.5, CommissionRate: 3.75, CommissionAmount: 7.5},
+	}
+	if !response.Success || len(response.Installments) != len(expected) {
+		t.Fatalf("Expected successful breakdown of %d counts, got %+v", len(expected), response)
+	}
+	for i, installment := range response.Installments {
+		if installment != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], installment)
+		}
+	}
+
+	request.InstallmentCount = 3
+	request.MaxInstallmentCount = 0
+	response, err = nkolayProvider.GetCommission(context.Background(), request)
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+	if response.CommissionAmount != 7.5 || response.GrossAmount != 207.5 || response.Installments != nil {
+		t.Errorf("Expected single count commission of 7.5 without breakdown, got %+v", response)
+	}
+
+	request.InstallmentCount = 6
+	if _, err := nkolayProvider.GetCommission(context.Background(), request); err == nil {
+		t.Error("Expected error for an installment count without a rate")
+	}
+}
+
+func TestNkolayProvider_ParsePaymentResponse_HTMLForm(t *testing.T) {
+	p := &NkolayProvider{}
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedHTML string
+		expected3D   bool
+	}{
+		{
+			name:         "escaped bank form",
+			body:         `{"RESPONSE_CODE":2,"BANK_REQUEST_MESSAGE":"<html><body onload=document.forms[\\\"form\\\"].submit()>\\r\\n<form name=\\\"form\\\" action=\\\"https:\\/\\/bank.example\\/3d\\\" method=\\\"post\\\">\\r\\n<\\/form><\\/body><\\/html>"}`,
+			expectedHTML: `<html><body onload="document.forms['form'].submit()"><form name="form" action="https://bank.example/3d" method="post"></form></body></html>`,
+			expected3D:   true,
+		},
+		{
+			name:         "form in HTML_STRING",
+			body:         `{"RESPONSE_CODE":2,"HTML_STRING":"<FORM action=\"https://bank.example/3d\"></FORM>"}`,
+			expectedHTML: `<FORM action="https://bank.example/3d"></FORM>`,
+			expected3D:   true,
+		},
+		{
+			name: "error message mentioning a form",
+			body: `{"RESPONSE_CODE":4,"BANK_REQUEST_MESSAGE":"Invalid form data","ERROR_MESSAGE":"Invalid form data"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := p.parsePaymentResponse([]byte(tt.body), "payment-1", 100, "")
+			if err != nil {
+				t.Fatalf("parsePaymentResponse failed: %v", err)
+			}
+			if got3D := response.Status == provider.StatusPending && response.HTML != ""; got3D != tt.expected3D {
+				t.Fatalf("Expected 3D form %v, got %+v", tt.expected3D, response)
+			}
+			if response.HTML != tt.expectedHTML {
+				t.Errorf("Expected HTML\n%s\ngot\n%s", tt.expectedHTML, response.HTML)
+			}
+		})
+	}
+}
+
+func TestParseCancelResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		err       error
+		errorCode string
+		status    provider.PaymentStatus
+	}{
+		{"cancelled", `{"RESPONSE_CODE":2,"RESPONSE_DATA":"SUCCESS"}`, nil, "", provider.StatusCancelled},
+		{"already captured", `{"RESPONSE_CODE":0,"ERROR_MESSAGE":"Gün sonu yapılmış işlem iptal edilemez"}`, provider.ErrAlreadyCaptured, provider.ErrorCodeAlreadyCaptured, provider.StatusFailed},
+		{"already cancelled", `{"RESPONSE_CODE":0,"ERROR_MESSAGE":"Transaction already cancelled"}`, provider.ErrAlreadyCancelled, provider.ErrorCodeAlreadyCancelled, provider.StatusFailed},
+		{"not found", "İşlem bulunamadı", provider.ErrPaymentNotFound, provider.ErrorCodePaymentNotFound, provider.StatusFailed},
+		{"other failure", `{"RESPONSE_CODE":0,"ERROR_MESSAGE":"Hash mismatch"}`, nil, "0", provider.StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := parseCancelResponse("ref-1", []byte(tt.body))
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if response.ErrorCode != tt.errorCode {
+				t.Errorf("expected error code %q, got %q", tt.errorCode, response.ErrorCode)
+			}
+			if response.Status != tt.status {
+				t.Errorf("expected status %s, got %s", tt.status, response.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrProviderNotConfigured is returned when a tenant uses a provider it has not configured for
+// the environment. errors.As with *ProviderNotConfiguredError gives the fields to set.
+var ErrProviderNotConfigured = errors.New("provider is not configured")
+
+// ProviderNotConfiguredError lists the configuration a tenant has to set, through
+// POST /v1/config/tenant, before it can use the provider in the environment
+type ProviderNotConfiguredError struct {
+	Provider      string        `json:"provider"`
+	Environment   string        `json:"environment"`
+	MissingConfig []ConfigField `json:"missingConfig"`
+}
+
+func (e *ProviderNotConfiguredError) Error() string {
+	keys := make([]string, len(e.MissingConfig))
+	for i, field := range e.MissingConfig {
+		keys[i] = field.Key
+	}
+	return fmt.Sprintf("provider %s is not configured for %s, missing config: %s", e.Provider, e.Environment, strings.Join(keys, ", "))
+}
+
+// Is makes errors.Is(err, ErrProviderNotConfigured) match
+func (e *ProviderNotConfiguredError) Is(target error) bool {
+	return target == ErrProviderNotConfigured
+}
+
+// checkProviderConfigured returns a ProviderNotConfiguredError listing the required fields of
+// the provider that are missing or empty in the tenant's configs
+func checkProviderConfigured(provider PaymentProvider, providerName, environment string, configs map[string]string) error {
+	var missing []ConfigField
+	for _, field := range provider.GetRequiredConfig(environment) {
+		if field.Required && strings.TrimSpace(configs[field.Key]) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return &ProviderNotConfiguredError{
+		Provider:      providerName,
+		Environment:   environment,
+		MissingConfig: missing,
+	}
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type configTestProvider struct {
+	PaymentProvider
+}
+
+func (p *configTestProvider) GetRequiredConfig(environment string) []ConfigField {
+	return []ConfigField{
+		{Key: "apiKey", Required: true, Type: "string", Description: "API key", Example: "api_123"},
+		{Key: "secretKey", Required: true, Type: "string", Description: "Secret key", Example: "secret_123"},
+		{Key: "webhookSecret", Required: false, Type: "string"},
+		{Key: "environment", Required: true, Type: "string"},
+	}
+}
+
+func TestCheckProviderConfigured(t *testing.T) {
+	tests := []struct {
+		name     string
+		configs  map[string]string
+		expected []string
+	}{
+		{
+			name:     "no config",
+			configs:  map[string]string{"environment": "sandbox"},
+			expected: []string{"apiKey", "secretKey"},
+		},
+		{
+			name:     "blank value",
+			configs:  map[string]string{"apiKey": "api_123", "secretKey": " ", "environment": "sandbox"},
+			expected: []string{"secretKey"},
+		},
+		{
+			name:    "configured",
+			configs: map[string]string{"apiKey": "api_123", "secretKey": "secret_123", "environment": "sandbox"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkProviderConfigured(&configTestProvider{}, "testpay", "sandbox", tt.configs)
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrProviderNotConfigured) {
+				t.Fatalf("Expected ErrProviderNotConfigured, got %v", err)
+			}
+			var notConfigured *ProviderNotConfiguredError
+			if !errors.As(fmt.Errorf("wrapped: %w", err), &notConfigured) {
+				t.Fatal("Expected a ProviderNotConfiguredError")
+			}
+			if notConfigured.Provider != "testpay" || notConfigured.Environment != "sandbox" {
+				t.Errorf("Unexpected provider/environment: %+v", notConfigured)
+			}
+
+			keys := make([]string, len(notConfigured.MissingConfig))
+			for i, field := range notConfigured.MissingConfig {
+				keys[i] = field.Key
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected missing %v, got %v", tt.expected, keys)
+			}
+			if notConfigured.MissingConfig[0].Description == "" {
+				t.Error("Expected the field guidance to be kept")
+			}
+			if !strings.Contains(err.Error(), strings.Join(tt.expected, ", ")) {
+				t.Errorf("Expected the missing keys in the message, got %q", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrOrderAttemptsUnavailable is returned when the payment logger cannot list the attempts of
+// an order
+var ErrOrderAttemptsUnavailable = errors.New("order attempt history is not available")
+
+// maxOrderAttempts caps the attempts listed per provider for one order
+const maxOrderAttempts = 100
+
+// PaymentAttempt is one payment request made for a merchant order
+type PaymentAttempt struct {
+	PaymentID     string    `json:"paymentId,omitempty"`
+	Provider      string    `json:"provider"`
+	Status        string    `json:"status"`
+	Amount        float64   `json:"amount,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	DeclineReason string    `json:"declineReason,omitempty"`
+	Use3D         bool      `json:"use3D"`
+	RequestedAt   time.Time `json:"requestedAt"`
+}
+
+// OrderAttemptLookup is an OPTIONAL capability of a PaymentLogger that lists the payment
+// attempts of a merchant order, matched by the referenceId or conversationId of the request
+type OrderAttemptLookup interface {
+	OrderAttempts(ctx context.Context, tenantID int, merchantOrderID string) ([]PaymentAttempt, error)
+}
+
+// GetOrderAttempts returns the payment attempts of one of the tenant's merchant orders across
+// all providers, oldest first
+func (s *PaymentService) GetOrderAttempts(ctx context.Context, merchantOrderID string) ([]PaymentAttempt, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := s.logger.(OrderAttemptLookup)
+	if !ok {
+		return nil, ErrOrderAttemptsUnavailable
+	}
+
+	attempts, err := lookup.OrderAttempts(ctx, tenantID, merchantOrderID)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(attempts, func(a, b PaymentAttempt) int {
+		return a.RequestedAt.Compare(b.RequestedAt)
+	})
+	return attempts, nil
+}
+
+// orderAttemptsQuery selects the payment requests of a merchant order from a provider table.
+// The tenant is $1 and the order $2; the order alternatives are grouped so they cannot widen
+// the search beyond the tenant.
+func orderAttemptsQuery(tableName string) string {
+	return fmt.Sprintf(`
+		SELECT request_at, payment_id, amount, currency, status, error_code,
+		       response->>'message', endpoint
+		FROM %s
+		WHERE tenant_id = $1
+		AND endpoint IN ('/payment', '/payment/3d')
+		AND (request->>'referenceId' = $2 OR request->>'conversationId' = $2)
+		ORDER BY request_at ASC
+		LIMIT %d
+	`, tableName, maxOrderAttempts)
+}
+
+// OrderAttempts reads the payment requests of a merchant order from every active provider's
+// log table
+func (l *DBPaymentLogger) OrderAttempts(ctx context.Context, tenantID int, merchantOrderID string) ([]PaymentAttempt, error) {
+	rows, err := l.db.QueryContext(ctx, `SELECT name FROM providers WHERE active = true`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	var providers []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		providers = append(providers, name)
+	}
+	rows.Close()
+
+	attempts := []PaymentAttempt{}
+	for _, providerName := range providers {
+		found, err := l.orderAttemptsOf(ctx, providerName, tenantID, merchantOrderID)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, found...)
+	}
+	return attempts, nil
+}
+
+// orderAttemptsOf reads the attempts of an order from one provider's log table
+func (l *DBPaymentLogger) orderAttemptsOf(ctx context.Context, providerName string, tenantID int, merchantOrderID string) ([]PaymentAttempt, error) {
+	rows, err := l.db.QueryContext(ctx, orderAttemptsQuery(providerName), tenantID, merchantOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s attempts: %w", providerName, err)
+	}
+	defer rows.Close()
+
+	var attempts []PaymentAttempt
+	for rows.Next() {
+		var (
+			attempt                                                   PaymentAttempt
+			paymentID, currency, status, errorCode, message, endpoint sql.NullString
+			amount                                                    sql.NullFloat64
+		)
+		if err := rows.Scan(&attempt.RequestedAt, &paymentID, &amount, &currency, &status, &errorCode, &message, &endpoint); err != nil {
+			return nil, fmt.Errorf("failed to scan %s attempt: %w", providerName, err)
+		}
+
+		attempt.PaymentID = paymentID.String
+		attempt.Provider = providerName
+		attempt.Amount = amount.Float64
+		attempt.Currency = currency.String
+		attempt.ErrorCode = errorCode.String
+		attempt.Use3D = endpoint.String == "/payment/3d"
+		attempt.Status = attemptStatus(status.String, errorCode.String)
+		if attempt.Status == string(StatusFailed) {
+			attempt.DeclineReason = strings.TrimSpace(message.String)
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// attemptStatus is the logged status of an attempt. Attempts logged with an error carry no
+// status and count as failed; attempts without a response yet are still processing.
+func attemptStatus(status, errorCode string) string {
+	switch {
+	case status != "":
+		return status
+	case errorCode != "":
+		return string(StatusFailed)
+	}
+	return string(StatusProcessing)
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// orderAttemptLogger is a payment logger that keeps the attempts of orders per tenant
+type orderAttemptLogger struct {
+	recordingPaymentLogger
+	attempts map[int]map[string][]PaymentAttempt
+}
+
+func (l *orderAttemptLogger) OrderAttempts(_ context.Context, tenantID int, merchantOrderID string) ([]PaymentAttempt, error) {
+	return append([]PaymentAttempt(nil), l.attempts[tenantID][merchantOrderID]...), nil
+}
+
+func TestPaymentService_GetOrderAttempts(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	paymentLogger := &orderAttemptLogger{attempts: map[int]map[string][]PaymentAttempt{
+		// Attempts come per provider table, not in the order they were made
+		9121: {"ORDER-1": {
+			{PaymentID: "iyz_2", Provider: "iyzico", Status: "successful", RequestedAt: start.Add(10 * time.Minute)},
+			{PaymentID: "iyz_1", Provider: "iyzico", Status: "failed", DeclineReason: "Insufficient funds", RequestedAt: start},
+			{PaymentID: "pi_1", Provider: "stripe", Status: "failed", ErrorCode: "card_declined", RequestedAt: start.Add(5 * time.Minute)},
+		}},
+		9122: {"ORDER-1": {
+			{PaymentID: "other_tenant", Provider: "iyzico", Status: "successful", RequestedAt: start},
+		}},
+	}}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9121")
+
+	attempts, err := service.GetOrderAttempts(ctx, "ORDER-1")
+	if err != nil {
+		t.Fatalf("GetOrderAttempts failed: %v", err)
+	}
+
+	var ids []string
+	for _, attempt := range attempts {
+		ids = append(ids, attempt.PaymentID)
+	}
+	if strings.Join(ids, ",") != "iyz_1,pi_1,iyz_2" {
+		t.Errorf("expected the tenant's attempts oldest first, got %v", ids)
+	}
+
+	if attempts, err := service.GetOrderAttempts(ctx, "ORDER-2"); err != nil || len(attempts) != 0 {
+		t.Errorf("expected no attempts for an unknown order, got %v, %v", attempts, err)
+	}
+
+	if _, err := NewPaymentService(&recordingPaymentLogger{}).GetOrderAttempts(ctx, "ORDER-1"); !errors.Is(err, ErrOrderAttemptsUnavailable) {
+		t.Errorf("expected ErrOrderAttemptsUnavailable without a lookup, got %v", err)
+	}
+}
+
+func TestOrderAttemptsQuery(t *testing.T) {
+	query := orderAttemptsQuery("paycell")
+
+	if !strings.Contains(query, "FROM paycell") {
+		t.Fatalf("query does not target the provider table:\n%s", query)
+	}
+	where := strings.TrimSpace(query[strings.Index(query, "WHERE")+len("WHERE"):])
+	if !strings.HasPrefix(where, "tenant_id = $1") {
+		t.Errorf("tenant filter must be the first condition:\n%s", where)
+	}
+	if !strings.Contains(where, "AND (request->>'referenceId' = $2 OR request->>'conversationId' = $2)") {
+		t.Errorf("order alternatives must be grouped:\n%s", where)
+	}
+	if !strings.Contains(query, "ORDER BY request_at ASC") {
+		t.Errorf("expected attempts in chronological order:\n%s", query)
+	}
+}
+
+func TestAttemptStatus(t *testing.T) {
+	if got := attemptStatus("successful", ""); got != "successful" {
+		t.Errorf("expected the logged status, got %q", got)
+	}
+	if got := attemptStatus("", "PROVIDER_ERROR"); got != string(StatusFailed) {
+		t.Errorf("expected an error to count as failed, got %q", got)
+	}
+	if got := attemptStatus("", ""); got != string(StatusProcessing) {
+		t.Errorf("expected an attempt without response to be processing, got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package provider
+
+import "testing"
+
+// TestResolveOutcome_ProviderMatrix mirrors the response shapes each provider builds in
+// CreatePayment / Create3DPayment, so a change in how a provider maps its response that
+// would confuse clients shows up here.
+func TestResolveOutcome_ProviderMatrix(t *testing.T) {
+	const html = `<form id="form" method="post" action="https://bank.example/3d"></form>`
+
+	tests := []struct {
+		provider string
+		flow     string
+		resp     *PaymentResponse
+		expected PaymentOutcome
+	}{
+		// akbank / ziraat / payten: direct sale or auto-submitting 3D form
+		{"akbank", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"akbank", "direct declined", &PaymentResponse{Success: false, Status: StatusFailed}, OutcomeFailed},
+		{"akbank", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, OutcomeRequiresHTMLForm},
+		{"ziraat", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"ziraat", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, OutcomeRequiresHTMLForm},
+		{"payten", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"payten", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, OutcomeRequiresHTMLForm},
+
+		// iyzico always echoes the merchant callback in RedirectURL
+		{"iyzico", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful, RedirectURL: "https://merchant.example/callback"}, OutcomeCompleted},
+		{"iyzico", "direct declined", &PaymentResponse{Success: false, Status: StatusFailed, RedirectURL: "https://merchant.example/callback"}, OutcomeFailed},
+		{"iyzico", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html, RedirectURL: "https://merchant.example/callback"}, OutcomeRequiresHTMLForm},
+
+		// nkolay returns the bank form in BANK_REQUEST_MESSAGE / HTML_STRING
+		{"nkolay", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"nkolay", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, OutcomeRequiresHTMLForm},
+		{"nkolay", "3d declined", &PaymentResponse{Success: false, Status: StatusFailed}, OutcomeFailed},
+
+		// ozanpay / payu redirect to a provider hosted 3D page
+		{"ozanpay", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"ozanpay", "3d", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://ozan.example/3d"}, OutcomeRequiresRedirect},
+		{"ozanpay", "error", &PaymentResponse{Success: false, Status: StatusPending, ErrorCode: "E1"}, OutcomeFailed},
+		{"payu", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"payu", "3d", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://payu.example/3d"}, OutcomeRequiresRedirect},
+
+		// papara is a wallet: payment URL, or pending approval in the app
+		{"papara", "payment url", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://papara.example/pay", HostedCheckoutURL: "https://papara.example/pay"}, OutcomeRequiresHostedCheckout},
+		{"papara", "awaiting approval", &PaymentResponse{Success: true, Status: StatusPending}, OutcomeRequiresAction},
+		{"papara", "cancelled", &PaymentResponse{Success: false, Status: StatusCancelled}, OutcomeFailed},
+
+		// paycell posts a generated 3D form
+		{"paycell", "direct success", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"paycell", "3d", &PaymentResponse{Success: true, Status: StatusPending, HTML: html}, OutcomeRequiresHTMLForm},
+		{"paycell", "3d session failed", &PaymentResponse{Success: false, Status: StatusFailed, HTML: html}, OutcomeFailed},
+
+		// paytr returns both the iframe and its source URL
+		{"paytr", "iframe", &PaymentResponse{Success: true, Status: StatusPending, HTML: `<iframe src="https://www.paytr.com/odeme/guvenlik/t"></iframe>`, RedirectURL: "https://www.paytr.com/odeme/guvenlik/t"}, OutcomeRequiresHTMLForm},
+		{"paytr", "hosted checkout", &PaymentResponse{Success: true, Status: StatusPending, HostedCheckoutURL: "https://www.paytr.com/odeme/guvenlik/t"}, OutcomeRequiresHostedCheckout},
+		{"paytr", "token error", &PaymentResponse{Success: false, Status: StatusFailed}, OutcomeFailed},
+
+		// stripe maps PaymentIntent statuses
+		{"stripe", "succeeded", &PaymentResponse{Success: true, Status: StatusSuccessful}, OutcomeCompleted},
+		{"stripe", "requires_action with redirect", &PaymentResponse{Success: true, Status: StatusPending, RedirectURL: "https://hooks.stripe.com/3d"}, OutcomeRequiresRedirect},
+		{"stripe", "processing", &PaymentResponse{Success: true, Status: StatusProcessing}, OutcomeRequiresAction},
+		{"stripe", "canceled", &PaymentResponse{Success: false, Status: StatusCancelled}, OutcomeFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.flow, func(t *testing.T) {
+			if got := ResolveOutcome(tt.resp); got != tt.expected {
+				t.Errorf("ResolveOutcome() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveOutcome_Nil(t *testing.T) {
+	if got := ResolveOutcome(nil); got != OutcomeFailed {
+		t.Errorf("ResolveOutcome(nil) = %q, want %q", got, OutcomeFailed)
+	}
+}
+
+func TestCardInfo_IsEmpty(t *testing.T) {
+	if !(CardInfo{}).IsEmpty() {
+		t.Error("Expected zero CardInfo to be empty")
+	}
+	if (CardInfo{CardNumber: "4111111111111111"}).IsEmpty() {
+		t.Error("Expected CardInfo with a card number not to be empty")
+	}
+}
@@ -101,6 +101,20 @@ func (p *OzanPayProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("ozanpay", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by OzanPay
+func (p *OzanPayProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR", "GBP"}
+}
+
+// HealthCheckEndpoint returns the OzanPay status inquiry endpoint
+func (p *OzanPayProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointStatus
+}
+
 // Initialize sets up the OzanPay payment provider with authentication credentials
 func (p *OzanPayProvider) Initialize(conf map[string]string) error {
 	p.apiKey = conf["apiKey"]
@@ -121,7 +135,7 @@ func (p *OzanPayProvider) Initialize(conf map[string]string) error {
 	}
 
 	// Initialize HTTP client
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("ozanpay"))
 
 	return nil
 }
@@ -136,11 +150,20 @@ func (p *OzanPayProvider) GetCommission(ctx context.Context, request provider.Co
 	return provider.CommissionResponse{}, nil
 }
 
+// BasketTotal adds up the basket items as OzanPay reads them, unit price times quantity
+func (p *OzanPayProvider) BasketTotal(items []provider.Item) float64 {
+	var total float64
+	for _, item := range items {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
+
 // CreatePayment makes a non-3D payment request
 func (p *OzanPayProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("ozanpay: invalid payment request: %w", err)
+		return nil, fmt.Errorf("ozanpay: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, false)
@@ -150,7 +173,7 @@ func (p *OzanPayProvider) CreatePayment(ctx context.Context, request provider.Pa
 func (p *OzanPayProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("ozanpay: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("ozanpay: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
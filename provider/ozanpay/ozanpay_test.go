@@ -1010,3 +1010,27 @@ func TestOzanPayProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestOzanPayProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &OzanPayProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointStatus {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointStatus {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestOzanPayProvider_BasketTotal(t *testing.T) {
+	p := NewProvider().(*OzanPayProvider)
+
+	items := []provider.Item{
+		{Name: "Shoes", Price: 40, Quantity: 2},
+		{Name: "Socks", Price: 10.25, Quantity: 2},
+	}
+	if got := p.BasketTotal(items); got != 100.5 {
+		t.Errorf("expected 100.5, got %v", got)
+	}
+}
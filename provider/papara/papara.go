@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,6 +27,12 @@ const (
 	endpointRefund        = "/api/v1/refunds"
 	endpointAccount       = "/api/v1/account"
 
+	// Mass payment endpoints send money out of the merchant balance, one per recipient type
+	endpointMassPayment            = "/masspayment"
+	endpointMassPaymentIBAN        = "/masspayment/iban"
+	endpointMassPaymentPhoneNumber = "/masspayment/phonenumber"
+	endpointMassPaymentEmail       = "/masspayment/email"
+
 	// Papara Status Codes
 	statusPending   = "PENDING"
 	statusCompleted = "COMPLETED"
@@ -78,6 +85,20 @@ func (p *PaparaProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("papara", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by Papara
+func (p *PaparaProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the Papara account information endpoint
+func (p *PaparaProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointAccount
+}
+
 // Initialize sets up the Papara payment provider with authentication credentials
 func (p *PaparaProvider) Initialize(conf map[string]string) error {
 	p.apiKey = conf["apiKey"]
@@ -96,7 +117,7 @@ func (p *PaparaProvider) Initialize(conf map[string]string) error {
 	}
 
 	// Initialize HTTP client
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("papara"))
 
 	return nil
 }
@@ -115,7 +136,7 @@ func (p *PaparaProvider) GetCommission(ctx context.Context, request provider.Com
 func (p *PaparaProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("papara: invalid payment request: %w", err)
+		return nil, fmt.Errorf("papara: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, false)
@@ -125,7 +146,7 @@ func (p *PaparaProvider) CreatePayment(ctx context.Context, request provider.Pay
 func (p *PaparaProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("papara: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("papara: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
@@ -274,6 +295,11 @@ func (p *PaparaProvider) ValidateWebhook(ctx context.Context, data, headers map[
 	return true, result, nil
 }
 
+// WebhookPaymentReference returns the referenceId sent with the payment and Papara's payment ID
+func (p *PaparaProvider) WebhookPaymentReference(data map[string]string) (string, string) {
+	return data["referenceId"], data["id"]
+}
+
 // validatePaymentRequest validates the payment request
 func (p *PaparaProvider) validatePaymentRequest(request provider.PaymentRequest, is3D bool) error {
 	if request.TenantID == 0 {
@@ -322,9 +348,16 @@ func (p *PaparaProvider) processPayment(ctx context.Context, request provider.Pa
 
 // mapToPaparaRequest maps a generic payment request to Papara-specific format
 func (p *PaparaProvider) mapToPaparaRequest(request provider.PaymentRequest, _ bool) map[string]any {
+	// The GoPay payment ID is the reference unless the merchant sent its own; Papara
+	// echoes it in the webhook
+	referenceID := request.ReferenceID
+	if referenceID == "" {
+		referenceID = request.ID
+	}
+
 	paparaReq := map[string]any{
 		"amount":           request.Amount,
-		"referenceId":      request.ReferenceID,
+		"referenceId":      referenceID,
 		"orderDescription": request.Description,
 		"currency":         request.Currency,
 	}
@@ -394,7 +427,9 @@ func (p *PaparaProvider) mapToPaymentResponse(paparaResp PaparaResponse) *provid
 			resp.Status = provider.StatusPending
 		}
 
+		// Papara collects the payment on its own page; RedirectURL is kept for older clients
 		if paparaResp.Data.PaymentURL != "" {
+			resp.HostedCheckoutURL = paparaResp.Data.PaymentURL
 			resp.RedirectURL = paparaResp.Data.PaymentURL
 		}
 	} else {
@@ -485,6 +520,121 @@ type PaparaError struct {
 	Message string `json:"message"`
 }
 
+// Ensure PaparaProvider satisfies the optional capability interface.
+var _ provider.PayoutProvider = (*PaparaProvider)(nil)
+
+// CreatePayout sends money from the merchant balance with Papara's mass payment API. The
+// GoPay payout ID is sent as massPaymentId, which Papara rejects when it is used twice.
+func (p *PaparaProvider) CreatePayout(ctx context.Context, request provider.PayoutRequest) (*provider.PayoutResponse, error) {
+	p.logID = request.LogID
+	endpoint, body, err := p.mapToMassPaymentRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := p.doPaparaRequest(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("papara: request failed: %w", err)
+	}
+
+	var paparaResp PaparaMassPaymentResponse
+	if err := json.Unmarshal(respBody, &paparaResp); err != nil {
+		return nil, fmt.Errorf("papara: failed to parse response: %w", err)
+	}
+	return p.mapToPayoutResponse(paparaResp), nil
+}
+
+// GetPayoutStatus returns a mass payment by Papara's ID
+func (p *PaparaProvider) GetPayoutStatus(ctx context.Context, providerPayoutID string) (*provider.PayoutResponse, error) {
+	if providerPayoutID == "" {
+		return nil, errors.New("papara: payout ID is required")
+	}
+
+	respBody, err := p.doPaparaRequest(ctx, "GET", endpointMassPayment+"?id="+url.QueryEscape(providerPayoutID), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("papara: request failed: %w", err)
+	}
+
+	var paparaResp PaparaMassPaymentResponse
+	if err := json.Unmarshal(respBody, &paparaResp); err != nil {
+		return nil, fmt.Errorf("papara: failed to parse response: %w", err)
+	}
+	return p.mapToPayoutResponse(paparaResp), nil
+}
+
+// mapToMassPaymentRequest picks the mass payment endpoint of the recipient type
+func (p *PaparaProvider) mapToMassPaymentRequest(request provider.PayoutRequest) (string, map[string]any, error) {
+	body := map[string]any{
+		"amount":        request.Amount,
+		"currency":      request.Currency,
+		"massPaymentId": request.ID,
+	}
+	if request.Description != "" {
+		body["description"] = request.Description
+	}
+	if request.Recipient.NationalID != "" {
+		body["turkishNationalId"] = request.Recipient.NationalID
+	}
+
+	recipient := request.Recipient
+	switch {
+	case recipient.AccountNumber != "":
+		body["accountNumber"] = recipient.AccountNumber
+		return endpointMassPayment, body, nil
+	case recipient.IBAN != "":
+		if recipient.Name == "" {
+			return "", nil, errors.New("papara: recipient name is required for IBAN payouts")
+		}
+		body["iban"] = recipient.IBAN
+		body["accountName"] = recipient.Name
+		return endpointMassPaymentIBAN, body, nil
+	case recipient.PhoneNumber != "":
+		body["phoneNumber"] = recipient.PhoneNumber
+		return endpointMassPaymentPhoneNumber, body, nil
+	case recipient.Email != "":
+		body["email"] = recipient.Email
+		return endpointMassPaymentEmail, body, nil
+	}
+	return "", nil, errors.New("papara: payout recipient is required")
+}
+
+// mapToPayoutResponse maps a mass payment response. Papara makes mass payments right away,
+// so an accepted one is completed.
+func (p *PaparaProvider) mapToPayoutResponse(paparaResp PaparaMassPaymentResponse) *provider.PayoutResponse {
+	resp := &provider.PayoutResponse{
+		Success:          paparaResp.Succeeded,
+		ProviderPayoutID: paparaResp.Data.ID,
+		Fee:              paparaResp.Data.Fee,
+		ProviderResponse: paparaResp,
+	}
+	if paparaResp.Succeeded {
+		resp.Status = provider.PayoutStatusCompleted
+	} else {
+		resp.Status = provider.PayoutStatusFailed
+		resp.Message = paparaResp.Error.Message
+		resp.ErrorCode = paparaResp.Error.Code
+	}
+	return resp
+}
+
+// PaparaMassPaymentResponse is Papara's answer to a mass payment
+type PaparaMassPaymentResponse struct {
+	Succeeded bool                  `json:"succeeded"`
+	Data      PaparaMassPaymentData `json:"data,omitempty"`
+	Error     PaparaError           `json:"error,omitempty"`
+}
+
+// PaparaMassPaymentData is a mass payment made from the merchant balance
+type PaparaMassPaymentData struct {
+	ID               string  `json:"id"`
+	MassPaymentID    string  `json:"massPaymentId"`
+	Amount           float64 `json:"amount"`
+	Fee              float64 `json:"fee"`
+	ResultingBalance float64 `json:"resultingBalance"`
+	Description      string  `json:"description,omitempty"`
+	CreatedAt        string  `json:"createdAt,omitempty"`
+}
+
 // ValidationResponse Papara kullanıcı doğrulama response'u için örnek struct
 // (Gerekirse gerçek Papara API response'una göre güncellenebilir)
 type ValidationResponse struct {
@@ -205,6 +205,29 @@ func TestPaparaProvider_mapToPaparaRequest(t *testing.T) {
 	}
 }
 
+func TestPaparaProvider_mapToPaparaRequest_PaymentIDReference(t *testing.T) {
+	p := &PaparaProvider{gopayBaseURL: "http://localhost:9999"}
+
+	request := provider.PaymentRequest{ID: "gp123", Amount: 10, Currency: "TRY"}
+	if result := p.mapToPaparaRequest(request, false); result["referenceId"] != "gp123" {
+		t.Errorf("Expected the payment ID as referenceId, got %v", result["referenceId"])
+	}
+
+	request.ReferenceID = "order-7"
+	if result := p.mapToPaparaRequest(request, false); result["referenceId"] != "order-7" {
+		t.Errorf("Expected the merchant's referenceId, got %v", result["referenceId"])
+	}
+}
+
+func TestPaparaProvider_WebhookPaymentReference(t *testing.T) {
+	p := &PaparaProvider{}
+
+	paymentID, providerPaymentID := p.WebhookPaymentReference(map[string]string{"id": "pap-1", "referenceId": "gp123", "status": "1"})
+	if paymentID != "gp123" || providerPaymentID != "pap-1" {
+		t.Errorf("Expected gp123 and pap-1, got %q and %q", paymentID, providerPaymentID)
+	}
+}
+
 func TestPaparaProvider_mapToPaymentResponse(t *testing.T) {
 	p := &PaparaProvider{}
 
@@ -307,6 +330,31 @@ func TestPaparaProvider_mapToPaymentResponse(t *testing.T) {
 	}
 }
 
+func TestPaparaProvider_mapToPaymentResponse_HostedCheckout(t *testing.T) {
+	p := &PaparaProvider{}
+
+	result := p.mapToPaymentResponse(PaparaResponse{
+		Succeeded: true,
+		Data: PaparaData{
+			ID:         "payment-123",
+			Amount:     100.50,
+			Currency:   "TRY",
+			Status:     statusPending,
+			PaymentURL: "https://merchant.test.papara.com/pay/payment-123",
+		},
+	})
+
+	if result.HostedCheckoutURL != "https://merchant.test.papara.com/pay/payment-123" {
+		t.Errorf("Expected hosted checkout URL to be the Papara payment URL, got %s", result.HostedCheckoutURL)
+	}
+	if result.RedirectURL != result.HostedCheckoutURL {
+		t.Errorf("Expected redirectUrl to mirror hosted checkout URL, got %s", result.RedirectURL)
+	}
+	if outcome := provider.ResolveOutcome(result); outcome != provider.OutcomeRequiresHostedCheckout {
+		t.Errorf("Expected outcome %s, got %s", provider.OutcomeRequiresHostedCheckout, outcome)
+	}
+}
+
 func TestPaparaProvider_generateWebhookSignature(t *testing.T) {
 	p := &PaparaProvider{
 		apiKey: "test-api-key",
@@ -467,3 +515,82 @@ func TestPaparaProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestPaparaProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &PaparaProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointAccount {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointAccount {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestPaparaProvider_mapToMassPaymentRequest(t *testing.T) {
+	p := &PaparaProvider{}
+
+	tests := []struct {
+		name      string
+		recipient provider.PayoutRecipient
+		endpoint  string
+		field     string
+		wantErr   bool
+	}{
+		{"papara account", provider.PayoutRecipient{AccountNumber: "1234567890"}, endpointMassPayment, "accountNumber", false},
+		{"iban", provider.PayoutRecipient{Name: "Ada Lovelace", IBAN: "TR330006100519786457841326"}, endpointMassPaymentIBAN, "iban", false},
+		{"iban without name", provider.PayoutRecipient{IBAN: "TR330006100519786457841326"}, "", "", true},
+		{"phone number", provider.PayoutRecipient{PhoneNumber: "+905551234567"}, endpointMassPaymentPhoneNumber, "phoneNumber", false},
+		{"email", provider.PayoutRecipient{Email: "ada@example.com"}, endpointMassPaymentEmail, "email", false},
+		{"no recipient", provider.PayoutRecipient{}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, body, err := p.mapToMassPaymentRequest(provider.PayoutRequest{
+				ID:        "po123",
+				Amount:    250,
+				Currency:  "TRY",
+				Recipient: tt.recipient,
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if endpoint != tt.endpoint {
+				t.Errorf("Expected endpoint %s, got %s", tt.endpoint, endpoint)
+			}
+			if body[tt.field] == nil || body[tt.field] == "" {
+				t.Errorf("Expected %s in body, got %v", tt.field, body)
+			}
+			if body["massPaymentId"] != "po123" || body["amount"] != 250.0 {
+				t.Errorf("Expected massPaymentId po123 and amount 250, got %v", body)
+			}
+		})
+	}
+}
+
+func TestPaparaProvider_mapToPayoutResponse(t *testing.T) {
+	p := &PaparaProvider{}
+
+	result := p.mapToPayoutResponse(PaparaMassPaymentResponse{
+		Succeeded: true,
+		Data:      PaparaMassPaymentData{ID: "mp-1", MassPaymentID: "po123", Amount: 250, Fee: 1.5},
+	})
+	if !result.Success || result.Status != provider.PayoutStatusCompleted || result.ProviderPayoutID != "mp-1" || result.Fee != 1.5 {
+		t.Errorf("Expected completed payout mp-1 with fee 1.5, got %+v", result)
+	}
+
+	result = p.mapToPayoutResponse(PaparaMassPaymentResponse{
+		Error: PaparaError{Code: "100", Message: "Insufficient balance"},
+	})
+	if result.Success || result.Status != provider.PayoutStatusFailed || result.ErrorCode != "100" || result.Message != "Insufficient balance" {
+		t.Errorf("Expected failed payout with the Papara error, got %+v", result)
+	}
+}
@@ -0,0 +1,86 @@
+package param
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test card for the TurkPos test environment
+var testCard = provider.CardInfo{CardNumber: "4022774022774026", ExpireMonth: "12", ExpireYear: "2026", CVV: "000"}
+
+// setupRealTestProvider returns a sandbox provider from PARAM_CLIENT_CODE,
+// PARAM_CLIENT_USERNAME, PARAM_CLIENT_PASSWORD and PARAM_GUID, skipping the test when they
+// are not set
+func setupRealTestProvider(t *testing.T) *ParamProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"clientCode":     os.Getenv("PARAM_CLIENT_CODE"),
+		"clientUsername": os.Getenv("PARAM_CLIENT_USERNAME"),
+		"clientPassword": os.Getenv("PARAM_CLIENT_PASSWORD"),
+		"guid":           os.Getenv("PARAM_GUID"),
+		"environment":    "sandbox",
+	}
+	if config["clientCode"] == "" || config["clientUsername"] == "" || config["clientPassword"] == "" || config["guid"] == "" {
+		t.Skip("param sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*ParamProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestParamProvider_RealAPI_InstallmentCount lists the installment rates of the test account
+func TestParamProvider_RealAPI_InstallmentCount(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetInstallmentCount(context.Background(), provider.InstallmentInquireRequest{Amount: 100})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount failed: %v", err)
+	}
+	for program, installments := range response.Installments {
+		t.Logf("%s: %d installment options", program, len(installments))
+	}
+}
+
+// TestParamProvider_RealAPI_PreAuthCapture holds an amount on the test card and captures it
+func TestParamProvider_RealAPI_PreAuthCapture(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	auth, err := p.AuthorizePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@param.example.com", PhoneNumber: "5551234567"},
+		CardInfo: testCard,
+	})
+	if err != nil {
+		t.Fatalf("AuthorizePayment failed: %v", err)
+	}
+	t.Logf("Authorize: success=%v status=%s paymentID=%s message=%s", auth.Success, auth.Status, auth.PaymentID, auth.Message)
+	if !auth.Success {
+		t.Skipf("Sandbox declined the pre-authorization: %s %s", auth.ErrorCode, auth.Message)
+	}
+
+	capture, err := p.CapturePayment(ctx, provider.CaptureRequest{PaymentID: auth.PaymentID, Amount: 1.00, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	t.Logf("Capture: success=%v status=%s message=%s", capture.Success, capture.Status, capture.Message)
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: auth.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s amount=%.2f", status.Status, status.Amount)
+}
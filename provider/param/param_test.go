@@ -0,0 +1,549 @@
+package param
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"clientCode":     "10738",
+		"clientUsername": "Test",
+		"clientPassword": "Test",
+		"guid":           "0c13d406-873b-403b-9c09-a5766840d98c",
+		"environment":    environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	paramProvider, ok := p.(*ParamProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a ParamProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if paramProvider.httpClient != nil {
+		t.Error("ParamProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := paramProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if paramProvider.httpClient == nil {
+		t.Error("ParamProvider should have a non-nil HTTP client after Initialize()")
+	}
+
+	if _, ok := p.(provider.CaptureProvider); !ok {
+		t.Error("ParamProvider should implement provider.CaptureProvider")
+	}
+}
+
+func TestParamProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+		baseURL     string
+	}{
+		{"sandbox", testConfig("sandbox"), false, apiSandboxURL},
+		{"production", testConfig("production"), false, apiProductionURL},
+		{"missing clientCode", without("clientCode"), true, ""},
+		{"missing clientUsername", without("clientUsername"), true, ""},
+		{"missing clientPassword", without("clientPassword"), true, ""},
+		{"missing guid", without("guid"), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ParamProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL {
+				t.Errorf("Expected %s, got %s", tt.baseURL, p.baseURL)
+			}
+		})
+	}
+}
+
+func TestParamProvider_GetRequiredConfig(t *testing.T) {
+	p := &ParamProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	expected := map[string]bool{"clientCode": true, "clientUsername": true, "clientPassword": true, "guid": true, "environment": true}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d config fields, got %d", len(expected), len(fields))
+	}
+	for _, field := range fields {
+		if !expected[field.Key] || !field.Required {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+	invalid := testConfig("sandbox")
+	invalid["guid"] = "not-a-guid"
+	if err := p.ValidateConfig(invalid); err == nil {
+		t.Error("Expected an error for an invalid guid")
+	}
+}
+
+func TestAmounts(t *testing.T) {
+	if got := formatAmount(100.5); got != "100,50" {
+		t.Errorf("Expected 100,50, got %s", got)
+	}
+	if got := parseAmount("1.234,5"); got != 0 {
+		t.Errorf("Expected grouped amounts to be rejected, got %v", got)
+	}
+	for value, expected := range map[string]float64{"100,50": 100.50, "2.35": 2.35, "-2": -2, "": 0} {
+		if got := parseAmount(value); got != expected {
+			t.Errorf("parseAmount(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+}
+
+func sha1Base64Of(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestParamProvider_BuildPaymentRequest(t *testing.T) {
+	p := NewProvider().(*ParamProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	payment := p.buildPaymentRequest(provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.50,
+		Currency: "TRY",
+		ClientIP: "10.0.0.1",
+		Customer: provider.Customer{Name: "John", Surname: "Doe", PhoneNumber: "5551234567"},
+		CardInfo: provider.CardInfo{CardNumber: "4546 7112 3456 7894", ExpireMonth: "12", ExpireYear: "26", CVV: "000"},
+	}, "GP1", "https://gopay.example.com/cb", "1029")
+
+	if payment.KKNo != "4546711234567894" || payment.KKSKYil != "2026" || payment.KKSahibi != "John Doe" || payment.KKSahibiGSM != "5551234567" {
+		t.Errorf("Unexpected card fields: %+v", payment.cardFields)
+	}
+	if payment.SanalPOSID != "1029" || payment.Taksit != 1 || payment.IslemTutar != "100,50" || payment.ToplamTutar != "100,50" || payment.IPAdr != "10.0.0.1" {
+		t.Errorf("Unexpected payment fields: %+v", payment)
+	}
+
+	expected := sha1Base64Of("10738" + "0c13d406-873b-403b-9c09-a5766840d98c" + "1" + "100,50" + "100,50" + "GP1" + "https://gopay.example.com/cb" + "https://gopay.example.com/cb")
+	if payment.IslemHash != expected {
+		t.Errorf("Expected hash %s, got %s", expected, payment.IslemHash)
+	}
+
+	// SHA2B64 hashes ISO-8859-9 bytes
+	if got, want := p.sha2b64("Ş"), sha1Base64Of("\xde"); got != want {
+		t.Errorf("Expected ISO-8859-9 hash %s, got %s", want, got)
+	}
+}
+
+// newTestProvider returns a provider whose SOAP service answers each operation with the
+// result handler returns
+func newTestProvider(t *testing.T, handler func(operation, body string) string) *ParamProvider {
+	t.Helper()
+	server := providertest.Server(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		operation := strings.TrimPrefix(strings.Trim(r.Header.Get("SOAPAction"), `"`), soapNamespace)
+		if !strings.Contains(string(body), "<"+operation+` xmlns="https://turkpos.com.tr/">`) {
+			t.Errorf("Expected a %s request, got %s", operation, body)
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		_, _ = io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<`+operation+`Response xmlns="https://turkpos.com.tr/"><`+operation+`Result>`+handler(operation, string(body))+
+			`</`+operation+`Result></`+operation+`Response></soap:Body></soap:Envelope>`)
+	})
+
+	p := providertest.Initialize[*ParamProvider](t, NewProvider, testConfig("sandbox"))
+	p.baseURL = server.URL
+	return p
+}
+
+const binResultXML = `<Sonuc>1</Sonuc><Sonuc_Str>Başarılı</Sonuc_Str><DT_Bilgi><diffgr:diffgram xmlns:diffgr="urn:schemas-microsoft-com:xml-diffgram-v1"><NewDataSet xmlns=""><Temp diffgr:id="Temp1"><BIN>454671</BIN><SanalPOS_ID>1029</SanalPOS_ID><Kart_Banka>Akbank</Kart_Banka></Temp></NewDataSet></diffgr:diffgram></DT_Bilgi>`
+
+func testPaymentRequest() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      100.50,
+		Currency:    "TRY",
+		CallbackURL: "https://example.com/callback",
+		Customer:    provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:    provider.CardInfo{CardNumber: "4546711234567894", ExpireMonth: "12", ExpireYear: "2026", CVV: "000"},
+	}
+}
+
+func TestParamProvider_CreatePayment(t *testing.T) {
+	ucdURL := nonSecure
+	var operations []string
+	p := newTestProvider(t, func(operation, body string) string {
+		operations = append(operations, operation)
+		if operation == opBINLookup {
+			if !strings.Contains(body, "<BIN>454671</BIN>") {
+				t.Errorf("Expected the card BIN, got %s", body)
+			}
+			return binResultXML
+		}
+		if !strings.Contains(body, "<SanalPOS_ID>1029</SanalPOS_ID>") || !strings.Contains(body, "<Islem_Tutar>100,50</Islem_Tutar>") {
+			t.Errorf("Unexpected payment request %s", body)
+		}
+		return `<Islem_ID>5001234</Islem_ID><UCD_URL>` + ucdURL + `</UCD_URL><Sonuc>1</Sonuc><Sonuc_Str>İşlem Başarılı</Sonuc_Str><Banka_Sonuc_Kod>0</Banka_Sonuc_Kod><Komisyon_Oran>1.75</Komisyon_Oran>`
+	})
+
+	response, err := p.CreatePayment(context.Background(), testPaymentRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if strings.Join(operations, ",") != opBINLookup+","+opPayment {
+		t.Errorf("Unexpected operations %v", operations)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.TransactionID != "5001234" || !strings.HasPrefix(response.PaymentID, "GP") {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+
+	// An account that requires 3D answers with the bank's page instead
+	ucdURL = "https://test-dmz.param.com.tr/3D/Default.aspx?id=1"
+	response, err = p.CreatePayment(context.Background(), testPaymentRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed {
+		t.Errorf("Expected a failed sale for a 3D account, got %+v", response)
+	}
+}
+
+func TestParamProvider_Pay(t *testing.T) {
+	p := newTestProvider(t, func(operation, body string) string {
+		if operation == opBINLookup {
+			return binResultXML
+		}
+		return `<Islem_ID>0</Islem_ID><UCD_URL></UCD_URL><Sonuc>-1</Sonuc><Sonuc_Str>Kart numarası hatalı</Sonuc_Str><Banka_Sonuc_Kod>-1</Banka_Sonuc_Kod>`
+	})
+
+	result, err := p.pay(context.Background(), testPaymentRequest(), "GP1", "https://gopay.example.com/cb")
+	if err != nil {
+		t.Fatalf("pay failed: %v", err)
+	}
+	response := paymentResponse(result)
+	if response.Success || response.ErrorCode != "-1" || response.Message != "Kart numarası hatalı" {
+		t.Errorf("Unexpected declined payment response: %+v", response)
+	}
+}
+
+// signedCallback returns 3D callback data with its TURKPOS_RETVAL_Hash
+func signedCallback(p *ParamProvider, data map[string]string) map[string]string {
+	data["TURKPOS_RETVAL_Hash"] = p.calculateCallbackHash(data)
+	return data
+}
+
+func TestParamProvider_Complete3DPayment(t *testing.T) {
+	p := NewProvider().(*ParamProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "param",
+		Environment:      "sandbox",
+	}
+
+	approved := func() map[string]string {
+		return map[string]string{
+			"TURKPOS_RETVAL_Sonuc":           "1",
+			"TURKPOS_RETVAL_Sonuc_Str":       "İşlem Başarılı",
+			"TURKPOS_RETVAL_GUID":            "0c13d406-873b-403b-9c09-a5766840d98c",
+			"TURKPOS_RETVAL_Islem_Tarih":     "15.01.2024 10:30:00",
+			"TURKPOS_RETVAL_Dekont_ID":       "3008123456",
+			"TURKPOS_RETVAL_Tahsilat_Tutari": "100,50",
+			"TURKPOS_RETVAL_Odeme_Tutari":    "98,74",
+			"TURKPOS_RETVAL_Siparis_ID":      "GP1",
+			"TURKPOS_RETVAL_Islem_ID":        "5001234",
+			"TURKPOS_RETVAL_Banka_Sonuc_Kod": "0",
+		}
+	}
+
+	expectedHash := sha1Base64Of("10738" + "0c13d406-873b-403b-9c09-a5766840d98c" + "3008123456" + "100,50" + "GP1" + "5001234")
+	if got := p.calculateCallbackHash(approved()); got != expectedHash {
+		t.Errorf("Expected callback hash %s, got %s", expectedHash, got)
+	}
+
+	ctx := context.Background()
+	callback := signedCallback(p, approved())
+	callback["state"] = "abc123"
+	response, err := p.Complete3DPayment(ctx, callbackState, callback)
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	expectedTime := time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC)
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "3008123456" ||
+		response.ProviderTime == nil || !response.ProviderTime.Equal(expectedTime) || response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+
+	declined := approved()
+	declined["TURKPOS_RETVAL_Sonuc"] = "-1"
+	declined["TURKPOS_RETVAL_Dekont_ID"] = "0"
+	declined["TURKPOS_RETVAL_Sonuc_Str"] = "Yetersiz bakiye"
+	declined["TURKPOS_RETVAL_Banka_Sonuc_Kod"] = "51"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, declined))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, approved()); err == nil || !strings.Contains(err.Error(), "missing TURKPOS_RETVAL_Hash") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	tampered := signedCallback(p, approved())
+	tampered["TURKPOS_RETVAL_Tahsilat_Tutari"] = "1,00"
+	if _, err := p.Complete3DPayment(ctx, callbackState, tampered); err == nil || !strings.Contains(err.Error(), "invalid callback hash") {
+		t.Errorf("Expected a tampered callback to be rejected, got %v", err)
+	}
+}
+
+func TestParamProvider_AuthorizeAndCapture(t *testing.T) {
+	var captured string
+	p := newTestProvider(t, func(operation, body string) string {
+		switch operation {
+		case opPreAuth:
+			if !strings.Contains(body, "<Islem_Guvenlik_Tip>NS</Islem_Guvenlik_Tip>") {
+				t.Errorf("Expected a non-3D pre-authorization, got %s", body)
+			}
+			return `<Islem_ID>5001235</Islem_ID><UCD_HTML>NONSECURE</UCD_HTML><Sonuc>1</Sonuc><Sonuc_Str>İşlem Başarılı</Sonuc_Str>`
+		case opPreAuthCapture:
+			captured = body
+			return `<Sonuc>1</Sonuc><Sonuc_Str>İşlem Başarılı</Sonuc_Str><Dekont_ID>3008123457</Dekont_ID>`
+		}
+		t.Errorf("Unexpected operation %s", operation)
+		return ""
+	})
+	ctx := context.Background()
+
+	response, err := p.AuthorizePayment(ctx, testPaymentRequest())
+	if err != nil {
+		t.Fatalf("AuthorizePayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusAuthorized || response.TransactionID != "5001235" {
+		t.Errorf("Unexpected authorize response: %+v", response)
+	}
+
+	response, err = p.CapturePayment(ctx, provider.CaptureRequest{PaymentID: response.PaymentID, Amount: 80, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+	if !strings.Contains(captured, "<Prov_Tutar>80,00</Prov_Tutar>") || !strings.Contains(captured, "<Siparis_ID>GP") {
+		t.Errorf("Unexpected capture request %s", captured)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.TransactionID != "3008123457" {
+		t.Errorf("Unexpected capture response: %+v", response)
+	}
+
+	if _, err := p.CapturePayment(ctx, provider.CaptureRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error for a capture without amount")
+	}
+}
+
+func inquiryXML(durum, total, refunded string) string {
+	return `<Sonuc>1</Sonuc><Sonuc_Str>Başarılı</Sonuc_Str><DT_Bilgi><Odeme_Sonuc>1</Odeme_Sonuc><Odeme_Sonuc_Aciklama>İşlem Başarılı</Odeme_Sonuc_Aciklama>` +
+		`<Dekont_ID>3008123456</Dekont_ID><Siparis_ID>GP1</Siparis_ID><Islem_ID>5001234</Islem_ID><Durum>` + durum + `</Durum><Tarih>15.01.2024 10:30:00</Tarih>` +
+		`<Toplam_Tutar>` + total + `</Toplam_Tutar><Toplam_Iade_Tutar>` + refunded + `</Toplam_Iade_Tutar></DT_Bilgi>`
+}
+
+func TestParamProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(operation, body string) string {
+		switch {
+		case strings.Contains(body, "<Siparis_ID>GP-CANCELLED</Siparis_ID>"):
+			return inquiryXML("CANCEL", "100,50", "0")
+		case strings.Contains(body, "<Siparis_ID>GP-REFUNDED</Siparis_ID>"):
+			return inquiryXML("SUCCESS", "100,50", "25,00")
+		case strings.Contains(body, "<Siparis_ID>GP-MISSING</Siparis_ID>"):
+			return `<Sonuc>-1</Sonuc><Sonuc_Str>Order not found</Sonuc_Str>`
+		}
+		return inquiryXML("SUCCESS", "100,50", "0")
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusSuccessful || response.Amount != 100.50 || response.TransactionID != "3008123456" {
+		t.Errorf("Unexpected status of an approved order: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-CANCELLED"}); err != nil || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a cancelled order, got %+v (%v)", response, err)
+	}
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-REFUNDED"}); err != nil || response.Status != provider.StatusRefunded {
+		t.Errorf("Expected a refunded order, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-MISSING"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestParamProvider_CancelPayment(t *testing.T) {
+	var cancelled string
+	p := newTestProvider(t, func(operation, body string) string {
+		if operation == opInquiry {
+			return inquiryXML("SUCCESS", "100,50", "0")
+		}
+		cancelled = body
+		if strings.Contains(body, "<Siparis_ID>GP-SETTLED</Siparis_ID>") {
+			return `<Sonuc>-1</Sonuc><Sonuc_Str>Gün sonu yapılmış işlem iptal edilemez</Sonuc_Str>`
+		}
+		return `<Sonuc>1</Sonuc><Sonuc_Str>İşlem Başarılı</Sonuc_Str>`
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	if !strings.Contains(cancelled, "<Durum>IPTAL</Durum>") || !strings.Contains(cancelled, "<Tutar>100,50</Tutar>") {
+		t.Errorf("Unexpected cancel request %s", cancelled)
+	}
+	if !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Unexpected cancel response: %+v", response)
+	}
+
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP-SETTLED"}); !errors.Is(err, provider.ErrAlreadyCaptured) {
+		t.Errorf("Expected ErrAlreadyCaptured for a settled payment, got %v", err)
+	}
+}
+
+func TestParamProvider_RefundPayment(t *testing.T) {
+	var refunded string
+	p := newTestProvider(t, func(operation, body string) string {
+		refunded = body
+		if strings.Contains(body, "<Tutar>999,99</Tutar>") {
+			return `<Sonuc>-1</Sonuc><Sonuc_Str>İade tutarı satış tutarını aşıyor</Sonuc_Str>`
+		}
+		return `<Sonuc>1</Sonuc><Sonuc_Str>İşlem Başarılı</Sonuc_Str>`
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if !strings.Contains(refunded, "<Durum>IADE</Durum>") || !strings.Contains(refunded, "<Tutar>25,00</Tutar>") || !strings.Contains(refunded, "<Siparis_ID>GP1</Siparis_ID>") {
+		t.Errorf("Unexpected refund request %s", refunded)
+	}
+	if !response.Success || response.Status != "success" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "-1" || response.Message != "İade tutarı satış tutarını aşıyor" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+}
+
+const commissionListXML = `<Sonuc>1</Sonuc><Sonuc_Str>Başarılı</Sonuc_Str><DT_Bilgi><diffgr:diffgram xmlns:diffgr="urn:schemas-microsoft-com:xml-diffgram-v1"><NewDataSet xmlns="">` +
+	`<DT_Ozel_Oranlar_SK diffgr:id="DT1"><SanalPOS_ID>1029</SanalPOS_ID><Kredi_Karti_Banka>Axess</Kredi_Karti_Banka><MO_01>1.75</MO_01><MO_02>3,50</MO_02><MO_03>4.25</MO_03><MO_04>-2</MO_04></DT_Ozel_Oranlar_SK>` +
+	`<DT_Ozel_Oranlar_SK diffgr:id="DT2"><SanalPOS_ID>1018</SanalPOS_ID><Kredi_Karti_Banka>Bonus</Kredi_Karti_Banka><MO_01>1.95</MO_01><MO_02>-1</MO_02></DT_Ozel_Oranlar_SK>` +
+	`</NewDataSet></diffgr:diffgram></DT_Bilgi>`
+
+func TestParamProvider_GetInstallmentCount(t *testing.T) {
+	p := newTestProvider(t, func(operation, body string) string {
+		return commissionListXML
+	})
+
+	response, err := p.GetInstallmentCount(context.Background(), provider.InstallmentInquireRequest{Amount: 100})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount failed: %v", err)
+	}
+
+	axess := response.Installments["Axess"]
+	if len(axess) != 3 || axess[0] != (provider.InstallmentInfo{Installment: 1, Commission: 1.75}) || axess[1].Commission != 3.50 || axess[2].Installment != 3 {
+		t.Errorf("Unexpected Axess installments: %+v", axess)
+	}
+	if bonus := response.Installments["Bonus"]; len(bonus) != 1 || bonus[0].Installment != 1 {
+		t.Errorf("Unexpected Bonus installments: %+v", bonus)
+	}
+}
+
+func TestParamProvider_GetCommission(t *testing.T) {
+	p := newTestProvider(t, func(operation, body string) string {
+		if operation == opBINLookup {
+			return binResultXML
+		}
+		return commissionListXML
+	})
+	ctx := context.Background()
+
+	response, err := p.GetCommission(ctx, provider.CommissionRequest{BinValue: "454671", InstallmentCount: 2, MaxInstallmentCount: 3, Amount: 200, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+	if !response.Success || response.CommissionRate != 3.50 || response.CommissionAmount != 7 || response.NetAmount != 193 || response.GrossAmount != 200 {
+		t.Errorf("Unexpected commission: %+v", response)
+	}
+	if len(response.Installments) != 2 || response.Installments[1].CommissionRate != 4.25 {
+		t.Errorf("Unexpected commission breakdown: %+v", response.Installments)
+	}
+
+	if _, err := p.GetCommission(ctx, provider.CommissionRequest{BinValue: "454671", InstallmentCount: 4, Amount: 200}); err == nil {
+		t.Error("Expected an error for an installment count the card program does not offer")
+	}
+	if _, err := p.GetCommission(ctx, provider.CommissionRequest{Amount: 200}); err == nil {
+		t.Error("Expected an error without binValue")
+	}
+}
+
+func TestParamProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &ParamProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
@@ -0,0 +1,8 @@
+package param
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Param provider with the global registry
+	provider.Register("param", NewProvider)
+}
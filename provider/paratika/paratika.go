@@ -0,0 +1,923 @@
+package paratika
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API URLs
+	apiSandboxURL    = "https://entegrasyon.paratika.com.tr/paratika/api/v2"
+	apiProductionURL = "https://vpos.paratika.com.tr/paratika/api/v2"
+
+	// endpointSale3D is where the customer posts the card of a payment session
+	endpointSale3D = "/post/sale3d/"
+
+	// Actions
+	actionSessionToken       = "SESSIONTOKEN"
+	actionQueryTransaction   = "QUERYTRANSACTION"
+	actionQueryPaymentSystem = "QUERYPAYMENTSYSTEMS"
+	actionVoid               = "VOID"
+	actionRefund             = "REFUND"
+
+	// sessionTypePayment opens a session for a single payment
+	sessionTypePayment = "PAYMENTSESSION"
+
+	// responseCodeSuccess is the responseCode of a successful request
+	responseCodeSuccess = "00"
+
+	// Transaction types and statuses of QUERYTRANSACTION
+	transactionTypeSale   = "SALE"
+	transactionTypeVoid   = "VOID"
+	transactionTypeRefund = "REFUND"
+	transactionApproved   = "AP"
+
+	// Default currency
+	defaultCurrency = "TRY"
+
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+// ParatikaProvider implements the provider.PaymentProvider interface for Paratika, Asseco's
+// payment gateway. Its MSU API takes form posts with an ACTION and answers with JSON.
+type ParatikaProvider struct {
+	merchant         string
+	merchantUser     string
+	merchantPassword string
+	baseURL          string
+	gopayBaseURL     string
+	isProduction     bool
+	httpClient       *provider.ProviderHTTPClient
+}
+
+// NewProvider creates a new Paratika payment provider
+func NewProvider() provider.PaymentProvider {
+	return &ParatikaProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Paratika
+func (p *ParatikaProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "merchant",
+			Required:    true,
+			Type:        "string",
+			Description: "Paratika merchant ID",
+			Example:     "10000000",
+			MinLength:   3,
+			MaxLength:   50,
+		},
+		{
+			Key:         "merchantUser",
+			Required:    true,
+			Type:        "string",
+			Description: "API user of the merchant from the Paratika merchant panel",
+			Example:     "api@merchant.com",
+			MinLength:   3,
+			MaxLength:   100,
+		},
+		{
+			Key:         "merchantPassword",
+			Required:    true,
+			Type:        "string",
+			Description: "Password of the API user",
+			Example:     "Pluto321!",
+			MinLength:   5,
+			MaxLength:   100,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Paratika requirements
+func (p *ParatikaProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("paratika", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies accepted by Paratika
+func (p *ParatikaProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR", "GBP"}
+}
+
+// HealthCheckEndpoint returns the Paratika API. The ACTION parameter selects the operation,
+// so a request without one changes nothing.
+func (p *ParatikaProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL
+}
+
+// Initialize sets up the Paratika payment provider with authentication credentials
+func (p *ParatikaProvider) Initialize(conf map[string]string) error {
+	p.merchant = conf["merchant"]
+	p.merchantUser = conf["merchantUser"]
+	p.merchantPassword = conf["merchantPassword"]
+
+	if p.merchant == "" || p.merchantUser == "" || p.merchantPassword == "" {
+		return errors.New("paratika: merchant, merchantUser and merchantPassword are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiSandboxURL
+	if p.isProduction {
+		p.baseURL = apiProductionURL
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("paratika"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment options of the merchant's payment systems, by
+// payment system name, with their commission rates. A card number limits them to the
+// payment system of its BIN.
+func (p *ParatikaProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	bin := provider.NormalizePAN(request.CardNumber)
+	if len(bin) > 6 {
+		bin = bin[:6]
+	}
+
+	systems, err := p.paymentSystems(ctx, bin, request.Amount)
+	if err != nil {
+		return provider.InstallmentInquireResponse{}, err
+	}
+
+	response := provider.InstallmentInquireResponse{
+		Amount:       request.Amount,
+		Message:      "Installment options retrieved successfully",
+		Installments: make(map[string][]provider.InstallmentInfo, len(systems)),
+	}
+	for _, system := range systems {
+		if options := system.installments(); len(options) > 0 {
+			response.Installments[system.Name] = options
+		}
+	}
+	return response, nil
+}
+
+// GetCommission returns the commission of the requested installment count, or of every count
+// up to MaxInstallmentCount, for the payment system of BinValue. Paratika deducts the
+// commission from the merchant, so the customer pays the amount and the merchant nets the rest.
+func (p *ParatikaProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	if len(request.BinValue) < 6 {
+		return provider.CommissionResponse{}, errors.New("paratika: binValue is required")
+	}
+
+	systems, err := p.paymentSystems(ctx, request.BinValue[:6], request.Amount)
+	if err != nil {
+		return provider.CommissionResponse{}, err
+	}
+	if len(systems) == 0 {
+		return provider.CommissionResponse{}, fmt.Errorf("paratika: no payment system for BIN %s", request.BinValue[:6])
+	}
+
+	breakdown, err := commissionBreakdown(systems[0].installments(), request.Amount, request.InstallmentCounts())
+	if err != nil {
+		return provider.CommissionResponse{}, err
+	}
+
+	first := breakdown[0]
+	response := provider.CommissionResponse{
+		Success:          true,
+		Message:          "Commission retrieved successfully",
+		NetAmount:        first.NetAmount,
+		GrossAmount:      first.GrossAmount,
+		CommissionRate:   first.CommissionRate,
+		CommissionAmount: first.CommissionAmount,
+	}
+	if request.MaxInstallmentCount > 0 {
+		response.Installments = breakdown
+	}
+	return response, nil
+}
+
+// commissionBreakdown applies the commission rates of a payment system to amount
+func commissionBreakdown(rates []provider.InstallmentInfo, amount float64, counts []int) ([]provider.InstallmentCommission, error) {
+	rateByCount := make(map[int]float64, len(rates))
+	for _, rate := range rates {
+		rateByCount[rate.Installment] = rate.Commission
+	}
+
+	breakdown := make([]provider.InstallmentCommission, 0, len(counts))
+	for _, count := range counts {
+		rate, ok := rateByCount[max(count, 1)]
+		if !ok {
+			return nil, fmt.Errorf("paratika: no commission rate for %d installments", count)
+		}
+		commission := math.Round(amount*rate) / 100
+		breakdown = append(breakdown, provider.InstallmentCommission{
+			InstallmentCount: count,
+			NetAmount:        amount - commission,
+			GrossAmount:      amount,
+			CommissionRate:   rate,
+			CommissionAmount: commission,
+		})
+	}
+	return breakdown, nil
+}
+
+// paymentSystems queries the merchant's payment systems, or the one of bin when it is set
+func (p *ParatikaProvider) paymentSystems(ctx context.Context, bin string, amount float64) ([]paymentSystem, error) {
+	params := map[string]string{"ACTION": actionQueryPaymentSystem}
+	if bin != "" {
+		params["BIN"] = bin
+	}
+	if amount > 0 {
+		params["AMOUNT"] = formatAmount(amount)
+	}
+
+	var resp paymentSystemsResponse
+	if err := p.send(ctx, params, &resp); err != nil {
+		return nil, err
+	}
+	if resp.ResponseCode != responseCodeSuccess {
+		return nil, fmt.Errorf("paratika: failed to query payment systems: %s", resp.message())
+	}
+	return resp.PaymentSystems, nil
+}
+
+// CreatePayment is not supported: Paratika payments are made through a payment session,
+// whose card page authenticates the card with 3D Secure
+func (p *ParatikaProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("paratika: non-3D payments are not supported, use 3D payments")
+}
+
+// Create3DPayment opens a payment session with SESSIONTOKEN and returns a form that posts the
+// card to the session's sale3d page. Paratika runs the 3D challenge, completes the sale and
+// posts the result to GoPay.
+func (p *ParatikaProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request); err != nil {
+		return nil, fmt.Errorf("paratika: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	merchantPaymentID := request.ID
+	if merchantPaymentID == "" {
+		merchantPaymentID = provider.NewPaymentID()
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "paratika", provider.CallbackState{
+		TenantID:         request.TenantID,
+		PaymentID:        merchantPaymentID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "paratika",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	params, err := p.buildSessionParams(request, merchantPaymentID, gopayCallbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var session sessionResponse
+	if err := p.send(ctx, params, &session); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if session.ResponseCode != responseCodeSuccess || session.SessionToken == "" {
+		return &provider.PaymentResponse{
+			Status:           provider.StatusFailed,
+			ErrorCode:        session.code(),
+			Message:          session.message(),
+			PaymentID:        merchantPaymentID,
+			Amount:           request.Amount,
+			Currency:         request.Currency,
+			SystemTime:       &now,
+			ProviderResponse: session,
+		}, nil
+	}
+
+	return &provider.PaymentResponse{
+		Success:    true,
+		Status:     provider.StatusPending,
+		PaymentID:  merchantPaymentID,
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		HTML:       p.generate3DSecureHTML(session.SessionToken, cardFormParams(request)),
+		Message:    "3D Secure authentication required",
+		SystemTime: &now,
+	}, nil
+}
+
+// Complete3DPayment completes a 3D payment Paratika posted back. The post is not signed, so
+// the sale is read back with QUERYTRANSACTION and its result is reported.
+func (p *ParatikaProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("paratika: no callback data received")
+	}
+
+	if id := data["merchantPaymentId"]; id != "" && id != callbackState.PaymentID {
+		return nil, errors.New("paratika: callback does not match the payment")
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("paratika", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        callbackState.PaymentID,
+		TransactionID:    data["pgTranId"],
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	query, err := p.queryTransactions(ctx, callbackState.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	sale := query.sale()
+	if sale == nil || sale.TransactionStatus != transactionApproved {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = data["errorCode"]
+		if response.ErrorCode == "" {
+			response.ErrorCode = data["responseCode"]
+		}
+		response.Message = firstNonEmpty(data["errorMsg"], data["responseMsg"], "3D payment failed")
+
+		if provider.Is3DSessionExpiredMessage(data["errorMsg"], data["responseMsg"]) {
+			response.ErrorCode = provider.ErrorCode3DSessionExpired
+			return response, fmt.Errorf("paratika: %w", provider.Err3DSessionExpired)
+		}
+		// The customer left the bank page; the card was never declined
+		if provider.Is3DSChallengeCancelledMessage(data["errorMsg"], data["responseMsg"]) {
+			provider.MarkThreeDSCancelled(response)
+		}
+		return response, nil
+	}
+
+	if provider.ToMinorUnits(sale.amount(), callbackState.Currency) != provider.ToMinorUnits(callbackState.Amount, callbackState.Currency) {
+		return nil, errors.New("paratika: sale does not match the payment amount")
+	}
+
+	response.Success = true
+	response.Status = provider.StatusSuccessful
+	response.TransactionID = sale.PgTranID
+	response.Message = "3D payment completed successfully"
+	response.ProviderTime = provider.ParseProviderTime(sale.PgTranDate, provider.TurkeyTimeZone, timeLayout)
+	return response, nil
+}
+
+// GetPaymentStatus retrieves the current status of a payment from its transactions
+func (p *ParatikaProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("paratika: paymentID is required")
+	}
+
+	query, err := p.queryTransactions(ctx, request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        request.PaymentID,
+		SystemTime:       &now,
+		ProviderResponse: query,
+	}
+
+	sale := query.sale()
+	if sale == nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = provider.ErrorCodePaymentNotFound
+		response.Message = query.message()
+		return response, fmt.Errorf("paratika: %w", provider.ErrPaymentNotFound)
+	}
+
+	response.TransactionID = sale.PgTranID
+	response.Amount = sale.amount()
+	response.Currency = sale.Currency
+	response.ProviderTime = provider.ParseProviderTime(sale.PgTranDate, provider.TurkeyTimeZone, timeLayout)
+	response.Status = query.status()
+	response.Success = response.Status != provider.StatusFailed
+	response.Message = sale.TransactionStatus
+	return response, nil
+}
+
+// CancelPayment voids the sale of a payment, which Paratika allows until end of day
+func (p *ParatikaProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("paratika: paymentID is required for cancel")
+	}
+
+	query, err := p.queryTransactions(ctx, request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Status:           provider.StatusFailed,
+		PaymentID:        request.PaymentID,
+		SystemTime:       &now,
+		ProviderResponse: query,
+	}
+
+	var failure error
+	switch sale := query.sale(); {
+	case sale == nil || sale.TransactionStatus != transactionApproved:
+		response.ErrorCode, failure = provider.ErrorCodePaymentNotFound, provider.ErrPaymentNotFound
+	case query.status() == provider.StatusCancelled:
+		response.ErrorCode, failure = provider.ErrorCodeAlreadyCancelled, provider.ErrAlreadyCancelled
+	case query.status() == provider.StatusRefunded:
+		response.ErrorCode, failure = provider.ErrorCodeAlreadyCaptured, provider.ErrAlreadyCaptured
+	}
+	if failure != nil {
+		response.Message = failure.Error()
+		return response, fmt.Errorf("paratika: %w", failure)
+	}
+
+	sale := query.sale()
+	var resp transactionResponse
+	if err := p.send(ctx, map[string]string{"ACTION": actionVoid, "PGTRANID": sale.PgTranID}, &resp); err != nil {
+		return nil, err
+	}
+
+	response.TransactionID = sale.PgTranID
+	response.Amount = sale.amount()
+	response.Currency = sale.Currency
+	response.ProviderResponse = resp
+	if resp.ResponseCode != responseCodeSuccess {
+		response.ErrorCode = resp.code()
+		response.Message = resp.message()
+		// A sale settled at end of day can no longer be voided, only refunded
+		if code, err := provider.CancelFailure(resp.ErrorMsg, resp.ResponseMsg); err != nil {
+			response.ErrorCode = code
+			return response, fmt.Errorf("paratika: %w", err)
+		}
+		return response, nil
+	}
+
+	response.Success = true
+	response.Status = provider.StatusCancelled
+	response.Message = "Payment cancelled"
+	return response, nil
+}
+
+// RefundPayment refunds the sale of a payment, in full or in part
+func (p *ParatikaProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("paratika: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("paratika: refund amount must be greater than 0")
+	}
+
+	query, err := p.queryTransactions(ctx, request.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	sale := query.sale()
+	if sale == nil || sale.TransactionStatus != transactionApproved {
+		return nil, fmt.Errorf("paratika: %w", provider.ErrPaymentNotFound)
+	}
+
+	currency := request.Currency
+	if currency == "" {
+		currency = sale.Currency
+	}
+
+	var resp transactionResponse
+	if err := p.send(ctx, map[string]string{
+		"ACTION":   actionRefund,
+		"PGTRANID": sale.PgTranID,
+		"AMOUNT":   formatAmount(request.RefundAmount),
+		"CURRENCY": currencyCode(currency),
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	success := resp.ResponseCode == responseCodeSuccess
+
+	refundResp := &provider.RefundResponse{
+		Success:      success,
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+		RawResponse:  resp,
+	}
+
+	if success {
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+		refundResp.RefundID = resp.PgTranID
+	} else {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = resp.code()
+		refundResp.Message = resp.message()
+	}
+
+	return refundResp, nil
+}
+
+// ValidateWebhook validates an incoming webhook notification
+func (p *ParatikaProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	// Paratika posts payment results to the 3D callback; it sends no webhooks
+	return true, data, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *ParatikaProvider) validatePaymentRequest(request provider.PaymentRequest) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Customer.Email == "" {
+		return errors.New("customer email is required")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("CVV is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiration month and year are required")
+	}
+
+	if request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// buildSessionParams builds the SESSIONTOKEN request of a payment. Paratika requires the
+// order items, which must add up to the amount.
+func (p *ParatikaProvider) buildSessionParams(request provider.PaymentRequest, merchantPaymentID, callbackURL string) (map[string]string, error) {
+	orderItems, err := json.Marshal(items(request))
+	if err != nil {
+		return nil, fmt.Errorf("paratika: failed to encode order items: %w", err)
+	}
+
+	customerID := request.Customer.ID
+	if customerID == "" {
+		customerID = request.Customer.Email
+	}
+
+	params := map[string]string{
+		"ACTION":            actionSessionToken,
+		"SESSIONTYPE":       sessionTypePayment,
+		"MERCHANTPAYMENTID": merchantPaymentID,
+		"AMOUNT":            formatAmount(request.Amount),
+		"CURRENCY":          currencyCode(request.Currency),
+		"RETURNURL":         callbackURL,
+		"CUSTOMER":          customerID,
+		"CUSTOMERNAME":      strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname),
+		"CUSTOMEREMAIL":     request.Customer.Email,
+		"ORDERITEMS":        string(orderItems),
+	}
+	if request.Customer.PhoneNumber != "" {
+		params["CUSTOMERPHONE"] = request.Customer.PhoneNumber
+	}
+	if request.ClientIP != "" {
+		params["CUSTOMERIP"] = request.ClientIP
+	}
+	if request.ClientUserAgent != "" {
+		params["CUSTOMERUSERAGENT"] = request.ClientUserAgent
+	}
+	return params, nil
+}
+
+// cardFormParams returns the card fields the customer posts to sale3d
+func cardFormParams(request provider.PaymentRequest) map[string]string {
+	params := map[string]string{
+		"cardOwner":   cardHolderName(request),
+		"pan":         provider.NormalizePAN(request.CardInfo.CardNumber),
+		"expiryMonth": request.CardInfo.ExpireMonth,
+		"expiryYear":  fullYear(request.CardInfo.ExpireYear),
+		"cvv":         request.CardInfo.CVV,
+	}
+	if request.InstallmentCount > 1 {
+		params["installmentCount"] = strconv.Itoa(request.InstallmentCount)
+	}
+	return params
+}
+
+// generate3DSecureHTML generates HTML form for 3D Secure authentication
+func (p *ParatikaProvider) generate3DSecureHTML(sessionToken string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var formFields strings.Builder
+	for _, key := range keys {
+		formFields.WriteString(fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, key, html.EscapeString(params[key])))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<title>3D Secure Authentication</title>
+	<meta charset="utf-8">
+	<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+</head>
+<body onload="document.threeDForm.submit();">
+	<div style="text-align: center; margin-top: 50px;">
+		<p>Ödeme işleminiz 3D güvenlik sayfasına yönlendiriliyor...</p>
+		<p>Payment is being redirected to 3D secure page...</p>
+	</div>
+	<form name="threeDForm" method="POST" action="%s">
+		%s
+	</form>
+</body>
+</html>`, html.EscapeString(p.baseURL+endpointSale3D+sessionToken), formFields.String())
+}
+
+// queryTransactions reads the transactions of a payment
+func (p *ParatikaProvider) queryTransactions(ctx context.Context, merchantPaymentID string) (*queryResponse, error) {
+	var resp queryResponse
+	if err := p.send(ctx, map[string]string{"ACTION": actionQueryTransaction, "MERCHANTPAYMENTID": merchantPaymentID}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// send posts an action with the merchant's credentials and decodes the response into result
+func (p *ParatikaProvider) send(ctx context.Context, params map[string]string, result any) error {
+	form := map[string]string{
+		"MERCHANT":         p.merchant,
+		"MERCHANTUSER":     p.merchantUser,
+		"MERCHANTPASSWORD": p.merchantPassword,
+	}
+	for key, value := range params {
+		form[key] = value
+	}
+
+	httpReq := &provider.HTTPRequest{
+		Method:   http.MethodPost,
+		Endpoint: p.baseURL,
+		Body:     form,
+		Headers: map[string]string{
+			"Accept": "application/json",
+		},
+	}
+
+	resp, err := p.httpClient.SendForm(ctx, httpReq)
+	if err != nil && resp == nil {
+		return fmt.Errorf("paratika: request failed: %w", err)
+	}
+
+	if parseErr := p.httpClient.ParseJSONResponse(resp, result); parseErr != nil {
+		if err != nil {
+			return fmt.Errorf("paratika: request failed: %w", err)
+		}
+		return fmt.Errorf("paratika: %w", parseErr)
+	}
+	return nil
+}
+
+// currencyCode returns the ISO 4217 alpha code Paratika expects, using TRY when currency is
+// empty or unknown
+func currencyCode(currency string) string {
+	if code, err := provider.CurrencyCode(currency, provider.CurrencyFormatAlpha); err == nil {
+		return code
+	}
+	return defaultCurrency
+}
+
+// formatAmount returns amount with two decimals and a dot
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// fullYear returns a card expiry year with four digits
+func fullYear(year string) string {
+	if len(year) == 2 {
+		return "20" + year
+	}
+	return year
+}
+
+// cardHolderName returns the name on the card, falling back to the customer's name
+func cardHolderName(request provider.PaymentRequest) string {
+	if name := strings.TrimSpace(request.CardInfo.CardHolderName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+}
+
+// items returns the order items of the payment. A payment without items, or whose items do
+// not add up to the amount, is sent as a single item.
+func items(request provider.PaymentRequest) []orderItem {
+	description := request.Description
+	if description == "" {
+		description = "Payment"
+	}
+
+	var basket []orderItem
+	var sum float64
+	for index, i := range request.Items {
+		quantity := max(i.Quantity, 1)
+		code := i.ID
+		if code == "" {
+			code = strconv.Itoa(index + 1)
+		}
+		basket = append(basket, orderItem{ProductCode: code, Name: i.Name, Description: i.Description, Quantity: quantity, Amount: formatAmount(i.Price)})
+		sum += i.Price * float64(quantity)
+	}
+
+	if len(basket) == 0 || formatAmount(sum) != formatAmount(request.Amount) {
+		return []orderItem{{ProductCode: "1", Name: description, Description: description, Quantity: 1, Amount: formatAmount(request.Amount)}}
+	}
+	return basket
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// orderItem is an item of ORDERITEMS
+type orderItem struct {
+	ProductCode string `json:"productCode"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	Amount      string `json:"amount"`
+}
+
+// apiResponse is the result every action answers with
+type apiResponse struct {
+	ResponseCode string `json:"responseCode"`
+	ResponseMsg  string `json:"responseMsg"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorMsg     string `json:"errorMsg"`
+}
+
+// code returns the error code of a failed request
+func (r *apiResponse) code() string {
+	return firstNonEmpty(r.ErrorCode, r.ResponseCode)
+}
+
+// message returns the message of a failed request
+func (r *apiResponse) message() string {
+	return firstNonEmpty(r.ErrorMsg, r.ResponseMsg)
+}
+
+// sessionResponse is the response of SESSIONTOKEN
+type sessionResponse struct {
+	apiResponse
+	SessionToken string `json:"sessionToken"`
+}
+
+// transactionResponse is the response of VOID and REFUND
+type transactionResponse struct {
+	apiResponse
+	PgTranID    string `json:"pgTranId"`
+	PgTranRefID string `json:"pgTranRefId"`
+}
+
+// queryResponse is the response of QUERYTRANSACTION
+type queryResponse struct {
+	apiResponse
+	TransactionCount int           `json:"transactionCount"`
+	TransactionList  []transaction `json:"transactionList"`
+}
+
+// transaction is a transaction of a payment
+type transaction struct {
+	PgTranID          string      `json:"pgTranId"`
+	TransactionType   string      `json:"transactionType"`
+	TransactionStatus string      `json:"transactionStatus"`
+	Amount            json.Number `json:"amount"`
+	Currency          string      `json:"currency"`
+	InstallmentCount  int         `json:"installmentCount"`
+	PgTranDate        string      `json:"pgTranDate"`
+}
+
+// amount returns the amount of the transaction
+func (t *transaction) amount() float64 {
+	amount, _ := t.Amount.Float64()
+	return amount
+}
+
+// sale returns the sale of the payment, preferring an approved one over declined attempts
+func (r *queryResponse) sale() *transaction {
+	var sale *transaction
+	for i := range r.TransactionList {
+		t := &r.TransactionList[i]
+		if t.TransactionType != transactionTypeSale {
+			continue
+		}
+		if t.TransactionStatus == transactionApproved {
+			return t
+		}
+		sale = t
+	}
+	return sale
+}
+
+// status maps the transactions of a payment: an approved sale is successful until it is
+// voided or refunded
+func (r *queryResponse) status() provider.PaymentStatus {
+	sale := r.sale()
+	if sale == nil || sale.TransactionStatus != transactionApproved {
+		return provider.StatusFailed
+	}
+
+	for _, t := range r.TransactionList {
+		if t.TransactionStatus != transactionApproved {
+			continue
+		}
+		switch t.TransactionType {
+		case transactionTypeVoid:
+			return provider.StatusCancelled
+		case transactionTypeRefund:
+			return provider.StatusRefunded
+		}
+	}
+	return provider.StatusSuccessful
+}
+
+// paymentSystemsResponse is the response of QUERYPAYMENTSYSTEMS
+type paymentSystemsResponse struct {
+	apiResponse
+	PaymentSystems []paymentSystem `json:"paymentSystems"`
+}
+
+// paymentSystem is a POS of the merchant, e.g. a bank's card program
+type paymentSystem struct {
+	ID              json.Number   `json:"id"`
+	Name            string        `json:"name"`
+	Type            string        `json:"type"`
+	InstallmentList []installment `json:"installmentList"`
+}
+
+// installment is an installment option of a payment system, with the merchant's commission
+// rate in percent
+type installment struct {
+	Count          int         `json:"count"`
+	CommissionRate json.Number `json:"commissionRate"`
+}
+
+// installments maps the installment options of a payment system
+func (s *paymentSystem) installments() []provider.InstallmentInfo {
+	options := make([]provider.InstallmentInfo, 0, len(s.InstallmentList))
+	for _, option := range s.InstallmentList {
+		rate, err := option.CommissionRate.Float64()
+		if err != nil || option.Count < 1 {
+			continue
+		}
+		options = append(options, provider.InstallmentInfo{Installment: option.Count, Commission: rate})
+	}
+	return options
+}
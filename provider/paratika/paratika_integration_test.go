@@ -0,0 +1,88 @@
+package paratika
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// setupRealTestProvider returns a provider with the test merchant from PARATIKA_MERCHANT,
+// PARATIKA_MERCHANT_USER and PARATIKA_MERCHANT_PASSWORD, skipping the test when they are not set
+func setupRealTestProvider(t *testing.T) *ParatikaProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"merchant":         os.Getenv("PARATIKA_MERCHANT"),
+		"merchantUser":     os.Getenv("PARATIKA_MERCHANT_USER"),
+		"merchantPassword": os.Getenv("PARATIKA_MERCHANT_PASSWORD"),
+		"environment":      "sandbox",
+	}
+	if config["merchant"] == "" || config["merchantUser"] == "" || config["merchantPassword"] == "" {
+		t.Skip("paratika test merchant not set; skipping real API test")
+	}
+
+	p := NewProvider().(*ParatikaProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestParatikaProvider_RealAPI_Create3DPayment opens a payment session. Completing it needs
+// the customer on the 3D page, so it stops at the form.
+func TestParatikaProvider_RealAPI_Create3DPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	response, err := p.Create3DPayment(context.Background(), provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      100.00,
+		Currency:    "TRY",
+		CallbackURL: "https://example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:    "Ahmet",
+			Surname: "Yılmaz",
+			Email:   "ahmet@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "5406675406675403",
+			ExpireMonth: "12",
+			ExpireYear:  "2030",
+			CVV:         "000",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v payment=%s message=%s", response.Success, response.PaymentID, response.Message)
+	if response.Success && response.HTML == "" {
+		t.Error("Expected a 3D form")
+	}
+}
+
+// TestParatikaProvider_RealAPI_GetInstallmentCount lists the installment options of the merchant
+func TestParatikaProvider_RealAPI_GetInstallmentCount(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetInstallmentCount(context.Background(), provider.InstallmentInquireRequest{Amount: 1000})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount failed: %v", err)
+	}
+	t.Logf("Installments: %+v", response.Installments)
+}
+
+// TestParatikaProvider_RealAPI_UnknownPayment queries a payment that does not exist
+func TestParatikaProvider_RealAPI_UnknownPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "GP-UNKNOWN"})
+	t.Logf("Status: %+v (%v)", response, err)
+}
@@ -0,0 +1,437 @@
+package paratika
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"merchant":         "10000000",
+		"merchantUser":     "api@merchant.com",
+		"merchantPassword": "Pluto321!",
+		"environment":      environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	paratikaProvider, ok := p.(*ParatikaProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a ParatikaProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if paratikaProvider.httpClient != nil {
+		t.Error("ParatikaProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := paratikaProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if paratikaProvider.httpClient == nil {
+		t.Error("ParatikaProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestParatikaProvider_Initialize(t *testing.T) {
+	with := func(key, value string) map[string]string {
+		config := testConfig("sandbox")
+		config[key] = value
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		baseURL     string
+		expectError bool
+	}{
+		{"sandbox", testConfig("sandbox"), apiSandboxURL, false},
+		{"production", testConfig("production"), apiProductionURL, false},
+		{"missing merchant", with("merchant", ""), "", true},
+		{"missing merchant user", with("merchantUser", ""), "", true},
+		{"missing merchant password", with("merchantPassword", ""), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ParatikaProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL {
+				t.Errorf("Expected base URL %s, got %s", tt.baseURL, p.baseURL)
+			}
+		})
+	}
+}
+
+// newTestProvider returns a provider whose API is answered by handler, after checking the
+// request carries the merchant's credentials
+func newTestProvider(t *testing.T, handler func(form map[string]string) any) *ParatikaProvider {
+	t.Helper()
+	p := providertest.Initialize[*ParatikaProvider](t, NewProvider, testConfig("sandbox"))
+
+	server := providertest.Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Invalid form: %v", err)
+		}
+		form := make(map[string]string, len(r.PostForm))
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		if form["MERCHANT"] != p.merchant || form["MERCHANTUSER"] != p.merchantUser || form["MERCHANTPASSWORD"] != p.merchantPassword {
+			t.Errorf("Unauthenticated %s request", form["ACTION"])
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(handler(form))
+	})
+
+	p.baseURL = server.URL
+	p.gopayBaseURL = "https://gopay.example.com"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("paratika"))
+	return p
+}
+
+func paymentRequestFixture() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		ID:          "GP1",
+		Amount:      150.75,
+		Currency:    "TRY",
+		CallbackURL: "https://merchant.example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:        "Ahmet",
+			Surname:     "Yılmaz",
+			Email:       "ahmet@example.com",
+			PhoneNumber: "5551234567",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "5406 6754 0667 5403",
+			ExpireMonth: "12",
+			ExpireYear:  "30",
+			CVV:         "000",
+		},
+	}
+}
+
+func transactionData(kind, status, amount string) map[string]any {
+	return map[string]any{
+		"pgTranId":          "PG-" + kind,
+		"transactionType":   kind,
+		"transactionStatus": status,
+		"amount":            amount,
+		"currency":          "TRY",
+		"installmentCount":  1,
+		"pgTranDate":        "2024-01-15 10:20:30",
+	}
+}
+
+func queryData(transactions ...map[string]any) map[string]any {
+	return map[string]any{"responseCode": "00", "responseMsg": "Approved", "transactionCount": len(transactions), "transactionList": transactions}
+}
+
+func TestParatikaProvider_Create3DPayment(t *testing.T) {
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	var session map[string]string
+	p := newTestProvider(t, func(form map[string]string) any {
+		session = form
+		return map[string]any{"responseCode": "00", "responseMsg": "Approved", "sessionToken": "TOKEN1"}
+	})
+
+	request := paymentRequestFixture()
+	request.InstallmentCount = 3
+	response, err := p.Create3DPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+
+	if session["ACTION"] != actionSessionToken || session["SESSIONTYPE"] != sessionTypePayment || session["MERCHANTPAYMENTID"] != "GP1" || session["AMOUNT"] != "150.75" || session["CURRENCY"] != "TRY" {
+		t.Errorf("Unexpected session request %v", session)
+	}
+	if !strings.HasPrefix(session["RETURNURL"], "https://gopay.example.com/") || session["CUSTOMEREMAIL"] != "ahmet@example.com" {
+		t.Errorf("Unexpected session request %v", session)
+	}
+	var items []orderItem
+	if err := json.Unmarshal([]byte(session["ORDERITEMS"]), &items); err != nil || len(items) != 1 || items[0].Amount != "150.75" {
+		t.Errorf("Unexpected order items %s", session["ORDERITEMS"])
+	}
+
+	if !response.Success || response.Status != provider.StatusPending || response.PaymentID != "GP1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	for _, want := range []string{p.baseURL + endpointSale3D + "TOKEN1", `name="pan" value="5406675406675403"`, `name="expiryYear" value="2030"`, `name="installmentCount" value="3"`} {
+		if !strings.Contains(response.HTML, want) {
+			t.Errorf("Expected the form to contain %s", want)
+		}
+	}
+}
+
+func TestParatikaProvider_Create3DPayment_Rejected(t *testing.T) {
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	p := newTestProvider(t, func(form map[string]string) any {
+		return map[string]any{"responseCode": "99", "responseMsg": "Declined", "errorCode": "ERR10010", "errorMsg": "Missing parameter"}
+	})
+
+	response, err := p.Create3DPayment(context.Background(), paymentRequestFixture())
+	if err != nil || response.Success || response.ErrorCode != "ERR10010" || response.Message != "Missing parameter" {
+		t.Errorf("Expected Paratika's error, got %+v (%v)", response, err)
+	}
+}
+
+func TestParatikaProvider_Complete3DPayment(t *testing.T) {
+	query := queryData(transactionData(transactionTypeSale, transactionApproved, "150.75"))
+	p := newTestProvider(t, func(form map[string]string) any {
+		if form["ACTION"] != actionQueryTransaction || form["MERCHANTPAYMENTID"] != "GP1" {
+			t.Errorf("Unexpected request %v", form)
+		}
+		return query
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://merchant.example.com/return",
+		Amount:           150.75,
+		Currency:         "TRY",
+		Provider:         "paratika",
+		Environment:      "sandbox",
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"responseCode": "00", "responseMsg": "Approved", "merchantPaymentId": "GP1", "pgTranId": "PG-SALE"})
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.TransactionID != "PG-SALE" || response.ProviderTime == nil || response.RedirectURL != "https://merchant.example.com/return" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// A forged approval is not trusted: the declined sale is reported
+	query = queryData(transactionData(transactionTypeSale, "DE", "150.75"))
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"responseCode": "00", "merchantPaymentId": "GP1"})
+	if err != nil || response.Success || response.Status != provider.StatusFailed {
+		t.Errorf("Expected a failed payment, got %+v (%v)", response, err)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"responseCode": "99", "errorCode": "ERR30001", "errorMsg": "Insufficient funds", "merchantPaymentId": "GP1"})
+	if err != nil || response.ErrorCode != "ERR30001" || response.Message != "Insufficient funds" {
+		t.Errorf("Expected the callback's error, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"responseCode": "00", "merchantPaymentId": "GP2"}); err == nil {
+		t.Error("Expected a callback for another payment to be rejected")
+	}
+
+	query = queryData(transactionData(transactionTypeSale, transactionApproved, "1.00"))
+	if _, err := p.Complete3DPayment(ctx, callbackState, map[string]string{"responseCode": "00", "merchantPaymentId": "GP1"}); err == nil {
+		t.Error("Expected a sale of another amount to be rejected")
+	}
+}
+
+func TestParatikaProvider_GetPaymentStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []map[string]any
+		want         provider.PaymentStatus
+	}{
+		{"approved", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75")}, provider.StatusSuccessful},
+		{"declined", []map[string]any{transactionData(transactionTypeSale, "DE", "150.75")}, provider.StatusFailed},
+		{"voided", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75"), transactionData(transactionTypeVoid, transactionApproved, "150.75")}, provider.StatusCancelled},
+		{"refunded", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75"), transactionData(transactionTypeRefund, transactionApproved, "50.00")}, provider.StatusRefunded},
+		{"declined refund", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75"), transactionData(transactionTypeRefund, "DE", "50.00")}, provider.StatusSuccessful},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(form map[string]string) any { return queryData(tt.transactions...) })
+			response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+			if err != nil || response.Status != tt.want || response.Amount != 150.75 || response.TransactionID != "PG-SALE" {
+				t.Errorf("Unexpected response: %+v (%v)", response, err)
+			}
+		})
+	}
+
+	p := newTestProvider(t, func(form map[string]string) any { return queryData() })
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "GP2"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound, got %+v (%v)", response, err)
+	}
+}
+
+func TestParatikaProvider_CancelPayment(t *testing.T) {
+	transactions := []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75")}
+	var void map[string]string
+	voidResult := map[string]any{"responseCode": "00", "responseMsg": "Approved", "pgTranId": "PG-VOID"}
+	p := newTestProvider(t, func(form map[string]string) any {
+		if form["ACTION"] == actionVoid {
+			void = form
+			return voidResult
+		}
+		return queryData(transactions...)
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+	if err != nil || !response.Success || response.Status != provider.StatusCancelled || void["PGTRANID"] != "PG-SALE" {
+		t.Errorf("Expected the sale to be voided, got %+v (%v) with %v", response, err, void)
+	}
+
+	voidResult = map[string]any{"responseCode": "99", "responseMsg": "Declined", "errorCode": "ERR20001", "errorMsg": "Transaction not found"}
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"}); !errors.Is(err, provider.ErrPaymentNotFound) {
+		t.Errorf("Expected Paratika's refusal to be recognized, got %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		transactions []map[string]any
+		want         error
+	}{
+		{"voided", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75"), transactionData(transactionTypeVoid, transactionApproved, "150.75")}, provider.ErrAlreadyCancelled},
+		{"refunded", []map[string]any{transactionData(transactionTypeSale, transactionApproved, "150.75"), transactionData(transactionTypeRefund, transactionApproved, "150.75")}, provider.ErrAlreadyCaptured},
+		{"unknown", nil, provider.ErrPaymentNotFound},
+	}
+	for _, tt := range tests {
+		transactions, void = tt.transactions, nil
+		response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+		if !errors.Is(err, tt.want) || response.Success || void != nil {
+			t.Errorf("%s: expected %v without a void, got %+v (%v)", tt.name, tt.want, response, err)
+		}
+	}
+}
+
+func TestParatikaProvider_RefundPayment(t *testing.T) {
+	var refund map[string]string
+	refundResult := map[string]any{"responseCode": "00", "responseMsg": "Approved", "pgTranId": "PG-REFUND", "pgTranRefId": "REF1"}
+	p := newTestProvider(t, func(form map[string]string) any {
+		if form["ACTION"] == actionRefund {
+			refund = form
+			return refundResult
+		}
+		if form["MERCHANTPAYMENTID"] != "GP1" {
+			return queryData()
+		}
+		return queryData(transactionData(transactionTypeSale, transactionApproved, "150.75"))
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 50, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund["PGTRANID"] != "PG-SALE" || refund["AMOUNT"] != "50.00" || refund["CURRENCY"] != "TRY" {
+		t.Errorf("Unexpected refund request %v", refund)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "PG-REFUND" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	refundResult = map[string]any{"responseCode": "99", "responseMsg": "Declined", "errorCode": "ERR20005", "errorMsg": "Refund amount exceeds the sale"}
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 500})
+	if err != nil || response.Success || response.ErrorCode != "ERR20005" {
+		t.Errorf("Expected Paratika's error, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP2", RefundAmount: 50}); !errors.Is(err, provider.ErrPaymentNotFound) {
+		t.Errorf("Expected ErrPaymentNotFound, got %v", err)
+	}
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error without a refund amount")
+	}
+}
+
+func paymentSystemsData(form map[string]string) any {
+	system := func(id, name string, rates ...string) map[string]any {
+		installments := make([]map[string]any, 0, len(rates))
+		for i, rate := range rates {
+			installments = append(installments, map[string]any{"count": i + 1, "commissionRate": rate})
+		}
+		return map[string]any{"id": id, "name": name, "type": "CREDITCARD", "installmentList": installments}
+	}
+
+	systems := []map[string]any{system("1", "Bonus", "1.80", "3.20", "4.50"), system("2", "World", "1.90", "3.40")}
+	if form["BIN"] == "540667" {
+		systems = systems[1:]
+	}
+	return map[string]any{"responseCode": "00", "responseMsg": "Approved", "paymentSystems": systems}
+}
+
+func TestParatikaProvider_GetInstallmentCount(t *testing.T) {
+	var query map[string]string
+	p := newTestProvider(t, func(form map[string]string) any {
+		query = form
+		return paymentSystemsData(form)
+	})
+	ctx := context.Background()
+
+	response, err := p.GetInstallmentCount(ctx, provider.InstallmentInquireRequest{Amount: 1000})
+	if err != nil {
+		t.Fatalf("GetInstallmentCount failed: %v", err)
+	}
+	if query["ACTION"] != actionQueryPaymentSystem || query["AMOUNT"] != "1000.00" || query["BIN"] != "" {
+		t.Errorf("Unexpected request %v", query)
+	}
+	if len(response.Installments) != 2 || len(response.Installments["Bonus"]) != 3 || response.Installments["Bonus"][1] != (provider.InstallmentInfo{Installment: 2, Commission: 3.2}) {
+		t.Errorf("Unexpected installments %v", response.Installments)
+	}
+
+	response, err = p.GetInstallmentCount(ctx, provider.InstallmentInquireRequest{CardNumber: "5406675406675403", Amount: 1000})
+	if err != nil || query["BIN"] != "540667" || len(response.Installments) != 1 || len(response.Installments["World"]) != 2 {
+		t.Errorf("Expected the card's payment system, got %v (%v)", response.Installments, err)
+	}
+}
+
+func TestParatikaProvider_GetCommission(t *testing.T) {
+	p := newTestProvider(t, paymentSystemsData)
+	ctx := context.Background()
+
+	response, err := p.GetCommission(ctx, provider.CommissionRequest{BinValue: "540667", InstallmentCount: 1, MaxInstallmentCount: 2, Amount: 1000, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+	if !response.Success || response.CommissionRate != 1.9 || response.CommissionAmount != 19 || response.NetAmount != 981 || response.GrossAmount != 1000 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if len(response.Installments) != 2 || response.Installments[1].CommissionAmount != 34 {
+		t.Errorf("Unexpected breakdown: %+v", response.Installments)
+	}
+
+	if _, err := p.GetCommission(ctx, provider.CommissionRequest{BinValue: "540667", InstallmentCount: 3, Amount: 1000}); err == nil {
+		t.Error("Expected an error for an installment count without a rate")
+	}
+	if _, err := p.GetCommission(ctx, provider.CommissionRequest{Amount: 1000}); err == nil {
+		t.Error("Expected an error without a BIN")
+	}
+}
+
+func TestParatikaProvider_CreatePayment_Unsupported(t *testing.T) {
+	p := NewProvider().(*ParatikaProvider)
+	if _, err := p.CreatePayment(context.Background(), paymentRequestFixture()); err == nil {
+		t.Error("Expected non-3D payments to be unsupported")
+	}
+}
@@ -0,0 +1,8 @@
+package paratika
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Paratika provider with the global registry
+	provider.Register("paratika", NewProvider)
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrCaptureExceedsAuthorized is returned when a capture asks for more than what is left of
+// the authorized amount. errors.As with *CaptureExceedsAuthorizedError gives the amounts.
+var ErrCaptureExceedsAuthorized = errors.New("capture amount exceeds the remaining authorized amount")
+
+// CaptureExceedsAuthorizedError tells how much of an authorization is left to capture
+type CaptureExceedsAuthorizedError struct {
+	PaymentID string  `json:"paymentId"`
+	Requested float64 `json:"requestedAmount"`
+	Remaining float64 `json:"remainingAmount"`
+	Currency  string  `json:"currency"`
+}
+
+func (e *CaptureExceedsAuthorizedError) Error() string {
+	return fmt.Sprintf("%v %s requested, %v %s left to capture of payment %s", e.Requested, e.Currency, e.Remaining, e.Currency, e.PaymentID)
+}
+
+// Is makes errors.Is(err, ErrCaptureExceedsAuthorized) match
+func (e *CaptureExceedsAuthorizedError) Is(target error) bool {
+	return target == ErrCaptureExceedsAuthorized
+}
+
+// CapturableAmountLookup is an OPTIONAL capability of a PaymentLogger that knows how much of
+// an authorization is left to capture. CapturePayment checks capture amounts against it.
+type CapturableAmountLookup interface {
+	// CapturableAmount returns the authorized amount minus its successful captures, and the
+	// payment currency. It returns sql.ErrNoRows when the payment is not in the logs.
+	CapturableAmount(ctx context.Context, tenantID int, providerName, paymentID string) (float64, string, error)
+}
+
+// captureAmount is the amount of a capture along with what was left of the authorization
+// before it, when the payment logger can tell it
+type captureAmount struct {
+	amount         float64
+	currency       string
+	remaining      float64
+	remainingKnown bool
+}
+
+// resolveCaptureAmount looks up what is left of the authorization. A capture without an
+// amount captures all of it, so the logged request records the amount actually captured.
+func (s *PaymentService) resolveCaptureAmount(ctx context.Context, tenantID int, providerName string, request CaptureRequest) (captureAmount, error) {
+	resolved := captureAmount{amount: request.Amount, currency: request.Currency}
+	lookup, ok := s.logger.(CapturableAmountLookup)
+	if !ok {
+		return resolved, nil
+	}
+
+	remaining, currency, err := lookup.CapturableAmount(ctx, tenantID, providerName, request.PaymentID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return resolved, nil
+	case err != nil:
+		return captureAmount{}, fmt.Errorf("failed to get capturable amount: %w", err)
+	}
+	resolved.remaining, resolved.remainingKnown = remaining, true
+	if resolved.currency == "" {
+		resolved.currency = currency
+	}
+
+	remainingMinor := ToMinorUnits(remaining, resolved.currency)
+	if remainingMinor <= 0 {
+		return captureAmount{}, fmt.Errorf("%w: nothing left to capture of payment %s", ErrAlreadyCaptured, request.PaymentID)
+	}
+	if resolved.amount == 0 {
+		resolved.amount = remaining
+	}
+	if ToMinorUnits(resolved.amount, resolved.currency) > remainingMinor {
+		return captureAmount{}, &CaptureExceedsAuthorizedError{
+			PaymentID: request.PaymentID,
+			Requested: resolved.amount,
+			Remaining: remaining,
+			Currency:  resolved.currency,
+		}
+	}
+	return resolved, nil
+}
+
+// applyTo sets the captured and remaining authorized amounts on a successful capture response
+func (a captureAmount) applyTo(response *PaymentResponse) {
+	if !a.remainingKnown || response == nil || !response.Success {
+		return
+	}
+	remaining := FromMinorUnits(ToMinorUnits(a.remaining, a.currency)-ToMinorUnits(a.amount, a.currency), a.currency)
+	response.Capture = &CaptureBalance{Captured: a.amount, Remaining: remaining}
+}
+
+// CaptureBalance is set on capture responses and splits the authorization into what this
+// capture took and what is left to capture
+type CaptureBalance struct {
+	Captured  float64 `json:"capturedAmount"`
+	Remaining float64 `json:"remainingAmount"`
+}
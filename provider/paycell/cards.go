@@ -497,6 +497,7 @@ func (p *PaycellProvider) Create3DPaymentWithSavedCard(ctx context.Context, requ
 		HTML:             p.generate3DSecureHTML(threeDSession.ThreeDSessionId, gopayCallbackURL),
 		Message:          threeDSession.ResponseHeader.ResponseDescription,
 		SystemTime:       &now,
+		ProviderTime:     threeDSession.ResponseHeader.ProviderTime(),
 		ProviderResponse: threeDSession,
 	}, nil
 }
@@ -611,6 +612,7 @@ func (p *PaycellProvider) provisionAllWithCardId(ctx context.Context, request pr
 		Message:          paycellResp.ResponseHeader.ResponseDescription,
 		ErrorCode:        paycellResp.ResponseHeader.ResponseCode,
 		SystemTime:       &now,
+		ProviderTime:     paycellResp.ResponseHeader.ProviderTime(),
 		ProviderResponse: paycellResp,
 	}, nil
 }
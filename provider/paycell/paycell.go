@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
 	"github.com/mstgnz/gopay/provider"
 )
 
@@ -130,6 +131,7 @@ type PaycellProvider struct {
 	logID                   int64
 	phoneNumber             string
 	clientIP                string
+	clockOffset             time.Duration // added to time.Now() for transactionDateTime (PAYCELL_CLOCK_OFFSET)
 	httpClient              *provider.ProviderHTTPClient
 	paymentManagementClient *provider.ProviderHTTPClient
 }
@@ -203,6 +205,21 @@ func (p *PaycellProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("paycell", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by Paycell.
+// Paycell only settles in Turkish Lira.
+func (p *PaycellProvider) SupportedCurrencies() []string {
+	return []string{"TRY"}
+}
+
+// HealthCheckEndpoint returns the Paycell card BIN inquiry endpoint
+func (p *PaycellProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointGetCardBinInformation
+}
+
 // Initialize sets up the Paycell payment provider with authentication credentials
 func (p *PaycellProvider) Initialize(conf map[string]string) error {
 	p.username = conf["username"]
@@ -216,6 +233,7 @@ func (p *PaycellProvider) Initialize(conf map[string]string) error {
 	}
 
 	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+	p.clockOffset = provider.ClockOffset("paycell")
 
 	p.isProduction = conf["environment"] == "production"
 	if p.isProduction {
@@ -227,8 +245,8 @@ func (p *PaycellProvider) Initialize(conf map[string]string) error {
 	}
 
 	// Initialize HTTP clients
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
-	p.paymentManagementClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.paymentManagementURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("paycell"))
+	p.paymentManagementClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.paymentManagementURL, p.isProduction).ForProvider("paycell"))
 
 	return nil
 }
@@ -243,7 +261,7 @@ func (p *PaycellProvider) CreatePayment(ctx context.Context, request provider.Pa
 	p.clientIP = request.ClientIP
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("paycell: invalid payment request: %w", err)
+		return nil, fmt.Errorf("paycell: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, false)
@@ -254,7 +272,7 @@ func (p *PaycellProvider) Create3DPayment(ctx context.Context, request provider.
 	p.clientIP = request.ClientIP
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("paycell: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("paycell: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
@@ -307,6 +325,7 @@ func (p *PaycellProvider) Complete3DPayment(ctx context.Context, callbackState *
 		Amount:           callbackState.Amount,
 		Currency:         callbackState.Currency,
 		SystemTime:       &now,
+		ProviderTime:     threeDSessionResp.ThreeDOperationResult.ResponseHeader.ProviderTime(),
 		ProviderResponse: threeDSessionResp,
 	}
 
@@ -323,11 +342,23 @@ func (p *PaycellProvider) Complete3DPayment(ctx context.Context, callbackState *
 		response.ErrorCode = threeDSessionResp.ThreeDOperationResult.ThreeDResult
 	}
 
-	if response.Success {
+	// An expired session cannot be provisioned; send the client back to start over
+	if threeDSessionResp.SessionExpired() {
+		response.ErrorCode = provider.ErrorCode3DSessionExpired
+		response.RedirectURL = callbackState.OriginalCallback
+		return response, fmt.Errorf("paycell: %w", provider.Err3DSessionExpired)
+	}
 
+	// The customer left the bank page; nothing is provisioned and the card was never declined
+	if threeDSessionResp.ChallengeCancelled() {
+		provider.MarkThreeDSCancelled(response)
+	}
+
+	if response.Success {
+		var provision *provider.PaymentResponse
 		if savedCardID != "" {
 			// Saved-card 3D completion: provision with cardId (no cardToken).
-			_, err = p.provisionAllWithCardId(ctx, provider.SavedCardPaymentRequest{
+			provision, err = p.provisionAllWithCardId(ctx, provider.SavedCardPaymentRequest{
 				Amount:           callbackState.Amount,
 				Currency:         callbackState.Currency,
 				MSISDN:           msisdn,
@@ -344,17 +375,20 @@ func (p *PaycellProvider) Complete3DPayment(ctx context.Context, callbackState *
 				},
 				InstallmentCount: callbackState.Installment,
 			}
-			_, err = p.provisionAll(ctx, request, cardToken, callbackState.PaymentID)
+			provision, err = p.provisionAll(ctx, request, cardToken, callbackState.PaymentID)
 		}
 		if err != nil {
 			return nil, err
 		}
 
-		response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{
-			PaymentID: callbackState.PaymentID,
+		var refreshErr error
+		response, refreshErr = settleProvisionedPayment(provision, func() (*provider.PaymentResponse, error) {
+			return p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{
+				PaymentID: callbackState.PaymentID,
+			})
 		})
-		if err != nil {
-			return nil, err
+		if refreshErr != nil {
+			p.logStatusRefreshFailure(callbackState.PaymentID, refreshErr)
 		}
 	}
 
@@ -463,6 +497,7 @@ func (p *PaycellProvider) GetPaymentStatus(ctx context.Context, request provider
 		PaymentID:        request.PaymentID,
 		TransactionID:    inquireResp.ResponseHeader.TransactionID,
 		SystemTime:       &now,
+		ProviderTime:     inquireResp.ResponseHeader.ProviderTime(),
 		Currency:         "TRY",
 		ProviderResponse: inquireResp,
 	}
@@ -599,6 +634,13 @@ func (p *PaycellProvider) CancelPayment(ctx context.Context, request provider.Ca
 		return nil, fmt.Errorf("failed to unmarshal reverse response: %w. Response body: %s", err, resp.RawBody)
 	}
 
+	return cancelResponse(request.PaymentID, reverseResp)
+}
+
+// cancelResponse maps a reverse response. A refused reverse returns
+// provider.ErrAlreadyCaptured, ErrAlreadyCancelled or ErrPaymentNotFound when Paycell's
+// description tells the reason.
+func cancelResponse(paymentID string, reverseResp PaycellReverseResponse) (*provider.PaymentResponse, error) {
 	success := reverseResp.ResponseHeader.ResponseCode == "0"
 	var status provider.PaymentStatus
 	if success {
@@ -610,9 +652,10 @@ func (p *PaycellProvider) CancelPayment(ctx context.Context, request provider.Ca
 	now := time.Now()
 	response := &provider.PaymentResponse{
 		Success:          success,
-		PaymentID:        request.PaymentID,
+		PaymentID:        paymentID,
 		TransactionID:    reverseResp.ResponseHeader.TransactionID,
 		SystemTime:       &now,
+		ProviderTime:     reverseResp.ResponseHeader.ProviderTime(),
 		Currency:         "TRY",
 		Status:           status,
 		ProviderResponse: reverseResp,
@@ -626,6 +669,11 @@ func (p *PaycellProvider) CancelPayment(ctx context.Context, request provider.Ca
 		response.Status = provider.StatusFailed
 		response.Message = reverseResp.ResponseHeader.ResponseDescription
 		response.ErrorCode = reverseResp.ResponseHeader.ResponseCode
+
+		if code, err := provider.CancelFailure(response.Message); err != nil {
+			response.ErrorCode = code
+			return response, fmt.Errorf("paycell: %w", err)
+		}
 	}
 
 	return response, nil
@@ -736,7 +784,39 @@ func (p *PaycellProvider) ValidateWebhook(ctx context.Context, data, headers map
 	return true, data, nil
 }
 
+// GetCommission returns the prepaid commission of the requested installment count, or of every
+// count up to MaxInstallmentCount with one getPrepaidCommission call per count
 func (p *PaycellProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	var response provider.CommissionResponse
+	for _, installmentCount := range request.InstallmentCounts() {
+		commission, header, err := p.prepaidCommission(ctx, request, installmentCount)
+		if err != nil {
+			return provider.CommissionResponse{}, err
+		}
+
+		if header.ResponseCode != "0" {
+			return provider.CommissionResponse{Message: header.ResponseDescription}, nil
+		}
+		response.Message = header.ResponseDescription
+
+		if len(response.Installments) == 0 {
+			response.Success = true
+			response.NetAmount = commission.NetAmount
+			response.GrossAmount = commission.GrossAmount
+			response.CommissionRate = commission.CommissionRate
+			response.CommissionAmount = commission.CommissionAmount
+		}
+		response.Installments = append(response.Installments, commission)
+	}
+
+	if request.MaxInstallmentCount == 0 {
+		response.Installments = nil
+	}
+	return response, nil
+}
+
+// prepaidCommission queries getPrepaidCommission for a single installment count
+func (p *PaycellProvider) prepaidCommission(ctx context.Context, request provider.CommissionRequest, installmentCount int) (provider.InstallmentCommission, PaycellResponseHeader, error) {
 	// Prepare request header
 	transactionID := p.generateTransactionID()
 	transactionDateTime := p.generateTransactionDateTime()
@@ -747,7 +827,7 @@ func (p *PaycellProvider) GetCommission(ctx context.Context, request provider.Co
 
 	commissionReq := map[string]any{
 		"binValue":         request.BinValue,
-		"installmentCount": strconv.Itoa(request.InstallmentCount),
+		"installmentCount": strconv.Itoa(installmentCount),
 		"merchantCode":     p.merchantID,
 		"amount":           int(request.Amount * 100),
 		"requestHeader": PaycellRequestHeader{
@@ -768,7 +848,7 @@ func (p *PaycellProvider) GetCommission(ctx context.Context, request provider.Co
 
 	resp, err := p.httpClient.SendJSON(ctx, httpReq)
 	if err != nil {
-		return provider.CommissionResponse{}, err
+		return provider.InstallmentCommission{}, PaycellResponseHeader{}, err
 	}
 
 	// Parse response
@@ -780,26 +860,81 @@ func (p *PaycellProvider) GetCommission(ctx context.Context, request provider.Co
 		ResponseHeader   PaycellResponseHeader `json:"responseHeader"`
 	}
 	if err := p.httpClient.ParseJSONResponse(resp, &paycellResp); err != nil {
-		return provider.CommissionResponse{}, err
+		return provider.InstallmentCommission{}, PaycellResponseHeader{}, err
 	}
 
-	// Map to provider.CommissionResponse
+	// Map to provider.InstallmentCommission
 	commissionAmount, _ := strconv.ParseFloat(paycellResp.CommissionAmount, 64)
 	commissionRate, _ := strconv.ParseFloat(paycellResp.CommissionRate, 64)
 	grossAmount, _ := strconv.ParseFloat(paycellResp.GrossAmount, 64)
 	netAmount, _ := strconv.ParseFloat(paycellResp.NetAmount, 64)
 
-	respCode := paycellResp.ResponseHeader.ResponseCode
-	respDesc := paycellResp.ResponseHeader.ResponseDescription
-
-	return provider.CommissionResponse{
-		Success:          respCode == "0",
-		Message:          respDesc,
+	return provider.InstallmentCommission{
+		InstallmentCount: installmentCount,
 		NetAmount:        netAmount,
 		GrossAmount:      grossAmount,
 		CommissionRate:   commissionRate,
 		CommissionAmount: commissionAmount,
-	}, nil
+	}, paycellResp.ResponseHeader, nil
+}
+
+// GetCardBinInfo looks up the card BIN through getCardBinInformation
+func (p *PaycellProvider) GetCardBinInfo(ctx context.Context, bin string) (*provider.CardBinInfo, error) {
+	if p.clientIP == "" {
+		p.clientIP = "127.0.0.1"
+	}
+
+	binReq := map[string]any{
+		"binValue": bin,
+		"requestHeader": PaycellRequestHeader{
+			ApplicationName:     p.username,
+			ApplicationPwd:      p.password,
+			ClientIPAddress:     p.clientIP,
+			TransactionDateTime: p.generateTransactionDateTime(),
+			TransactionID:       p.generateTransactionID(),
+		},
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, &provider.HTTPRequest{
+		Method:   "POST",
+		Endpoint: endpointGetCardBinInformation,
+		Body:     binReq,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var binResp PaycellCardBinInformationResponse
+	if err := p.httpClient.ParseJSONResponse(resp, &binResp); err != nil {
+		return nil, err
+	}
+	return binResp.CardBinInfo()
+}
+
+// settleProvisionedPayment returns the refreshed status of a provisioned payment. The card may
+// already be charged once the provision went through, so when the refresh fails the provision
+// result is returned instead, along with the refresh error for logging.
+func settleProvisionedPayment(provision *provider.PaymentResponse, refresh func() (*provider.PaymentResponse, error)) (*provider.PaymentResponse, error) {
+	status, err := refresh()
+	if err != nil {
+		return provision, err
+	}
+	return status, nil
+}
+
+// logStatusRefreshFailure records a status refresh that failed after a provision, both in the
+// system log and in the payment's request log
+func (p *PaycellProvider) logStatusRefreshFailure(paymentID string, err error) {
+	logger.Warn("Failed to refresh payment status after provision", logger.LogContext{
+		Provider: "paycell",
+		Fields: map[string]any{
+			"payment_id": paymentID,
+			"error":      err.Error(),
+		},
+	})
+	_ = provider.AddProviderRequestToClientRequest("paycell", "statusRefreshError", map[string]any{
+		"error": err.Error(),
+	}, p.logID)
 }
 
 func (p *PaycellProvider) threeDSessionResult(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*PaycellGetThreeDSessionResultResponse, error) {
@@ -1088,6 +1223,7 @@ func (p *PaycellProvider) provisionAll(ctx context.Context, request provider.Pay
 		Message:          paycellResp.ResponseHeader.ResponseDescription,
 		ErrorCode:        paycellResp.ResponseHeader.ResponseCode,
 		SystemTime:       &now,
+		ProviderTime:     paycellResp.ResponseHeader.ProviderTime(),
 		ProviderResponse: paycellResp,
 	}, nil
 }
@@ -1141,6 +1277,7 @@ func (p *PaycellProvider) provision3D(ctx context.Context, request provider.Paym
 		HTML:             p.generate3DSecureHTML(threeDSession.ThreeDSessionId, gopayCallbackURL),
 		Message:          threeDSession.ResponseHeader.ResponseDescription,
 		SystemTime:       &now,
+		ProviderTime:     threeDSession.ResponseHeader.ProviderTime(),
 		ProviderResponse: threeDSession,
 	}, nil
 }
@@ -1220,7 +1357,8 @@ func (p *PaycellProvider) generateTransactionID() string {
 
 // generateTransactionDateTime creates transaction datetime in Paycell format (YYYYMMddHHmmssSSS - 17 chars)
 func (p *PaycellProvider) generateTransactionDateTime() string {
-	now := time.Now()
+	// Paycell hashes transactionDateTime and rejects requests skewed from its clock.
+	now := time.Now().Add(p.clockOffset)
 	return now.Format("20060102150405") + fmt.Sprintf("%03d", now.Nanosecond()/1000000)
 }
 
@@ -1291,6 +1429,38 @@ type PaycellRequestHeader struct {
 	TransactionID       string `json:"transactionId"`
 }
 
+// PaycellCardBinInformationResponse is the getCardBinInformation response
+type PaycellCardBinInformationResponse struct {
+	CardBinInformations []struct {
+		BankCode         string `json:"bankCode"`
+		BankName         string `json:"bankName"`
+		BinValue         string `json:"binValue"`
+		CardBrand        string `json:"cardBrand"`
+		CardType         string `json:"cardType"`
+		CardOrganization string `json:"cardOrganization"`
+	} `json:"cardBinInformations"`
+	ResponseHeader PaycellResponseHeader `json:"responseHeader"`
+}
+
+// CardBinInfo returns the first BIN record. Paycell only knows cards issued in Turkey.
+func (r *PaycellCardBinInformationResponse) CardBinInfo() (*provider.CardBinInfo, error) {
+	if r.ResponseHeader.ResponseCode != responseCodeSuccess {
+		return nil, fmt.Errorf("paycell: BIN lookup failed: %s", r.ResponseHeader.ResponseDescription)
+	}
+	if len(r.CardBinInformations) == 0 {
+		return nil, errors.New("paycell: BIN not found")
+	}
+
+	info := r.CardBinInformations[0]
+	return &provider.CardBinInfo{
+		BIN:             info.BinValue,
+		CardType:        info.CardType,
+		CardAssociation: info.CardOrganization,
+		BankName:        info.BankName,
+		Country:         "TR",
+	}, nil
+}
+
 type PaycellResponseHeader struct {
 	TransactionID       string `json:"transactionId"`
 	ResponseDateTime    string `json:"responseDateTime"`
@@ -1298,6 +1468,16 @@ type PaycellResponseHeader struct {
 	ResponseDescription string `json:"responseDescription"`
 }
 
+// ProviderTime parses responseDateTime, which Paycell sends in the same yyyyMMddHHmmssSSS
+// Turkey-time format as transactionDateTime
+func (h PaycellResponseHeader) ProviderTime() *time.Time {
+	value := h.ResponseDateTime
+	if len(value) == 17 {
+		value = value[:14] + "." + value[14:]
+	}
+	return provider.ParseProviderTime(value, provider.TurkeyTimeZone, "20060102150405.000", "20060102150405")
+}
+
 // PaycellGetCardTokenSecureRequest represents getCardTokenSecure request
 type PaycellGetCardTokenSecureRequest struct {
 	Header          PaycellRequestHeader `json:"header"`
@@ -1456,6 +1636,31 @@ type PaycellThreeDOperationResult struct {
 	ResponseHeader          PaycellResponseHeader `json:"responseHeader"`
 }
 
+// SessionExpired reports whether the 3D session timed out before the customer completed
+// authentication
+func (r *PaycellGetThreeDSessionResultResponse) SessionExpired() bool {
+	if r.ThreeDOperationResult.ThreeDResult == "0" {
+		return false
+	}
+	return provider.Is3DSessionExpiredMessage(
+		r.MdErrorMessage,
+		r.ThreeDOperationResult.ThreeDResultDescription,
+		r.ThreeDOperationResult.ResponseHeader.ResponseDescription,
+	)
+}
+
+// ChallengeCancelled reports whether the customer cancelled the 3D challenge
+func (r *PaycellGetThreeDSessionResultResponse) ChallengeCancelled() bool {
+	if r.ThreeDOperationResult.ThreeDResult == "0" {
+		return false
+	}
+	return provider.Is3DSChallengeCancelledMessage(
+		r.MdErrorMessage,
+		r.ThreeDOperationResult.ThreeDResultDescription,
+		r.ThreeDOperationResult.ResponseHeader.ResponseDescription,
+	)
+}
+
 // PaycellReverseResponse represents the response from reverse endpoint
 type PaycellReverseResponse struct {
 	ReconciliationDate     string                `json:"reconciliationDate"`
@@ -5,10 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mstgnz/gopay/provider"
 )
@@ -390,6 +392,175 @@ func TestPaycellProvider_GenerateSignature(t *testing.T) {
 	}
 }
 
+func TestPaycellProvider_GenerateTransactionDateTime_AppliesClockOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+	}{
+		{name: "no offset", offset: 0},
+		{name: "provider clock ahead", offset: 2 * time.Hour},
+		{name: "provider clock behind", offset: -90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PaycellProvider{clockOffset: tt.offset}
+
+			result := p.generateTransactionDateTime()
+			if len(result) != 17 {
+				t.Fatalf("Expected 17 character transactionDateTime, got %q", result)
+			}
+
+			generated, err := time.ParseInLocation("20060102150405", result[:14], time.Local)
+			if err != nil {
+				t.Fatalf("Failed to parse transactionDateTime %q: %v", result, err)
+			}
+
+			drift := generated.Sub(time.Now().Add(tt.offset))
+			if drift < -2*time.Second || drift > 2*time.Second {
+				t.Errorf("Expected transactionDateTime shifted by %v, drift was %v", tt.offset, drift)
+			}
+		})
+	}
+}
+
+func TestPaycellResponseHeader_ProviderTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected *time.Time
+	}{
+		{"with milliseconds", "20240115103000123", ptrTime(time.Date(2024, 1, 15, 7, 30, 0, 123_000_000, time.UTC))},
+		{"without milliseconds", "20240115103000", ptrTime(time.Date(2024, 1, 15, 7, 30, 0, 0, time.UTC))},
+		{"empty", "", nil},
+		{"invalid", "2024-01-15", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PaycellResponseHeader{ResponseDateTime: tt.value}.ProviderTime()
+			if tt.expected == nil {
+				if got != nil {
+					t.Errorf("Expected nil, got %v", got)
+				}
+				return
+			}
+			if got == nil || !got.Equal(*tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
+
+func TestPaycellGetThreeDSessionResultResponse_SessionExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{
+			name:     "authenticated",
+			body:     `{"mdStatus":"1","threeDOperationResult":{"threeDResult":"0","threeDResultDescription":"3D dogrulama basarili"}}`,
+			expected: false,
+		},
+		{
+			name:     "session expired",
+			body:     `{"mdStatus":"0","mdErrorMessage":"3D session expired","threeDOperationResult":{"threeDResult":"1","threeDResultDescription":"Failed"}}`,
+			expected: true,
+		},
+		{
+			name:     "timeout in result description",
+			body:     `{"threeDOperationResult":{"threeDResult":"2","threeDResultDescription":"Islem zaman aşımına uğradı"}}`,
+			expected: true,
+		},
+		{
+			name:     "timeout in response header",
+			body:     `{"threeDOperationResult":{"threeDResult":"1","responseHeader":{"responseCode":"1","responseDescription":"ThreeD session timeout"}}}`,
+			expected: true,
+		},
+		{
+			name:     "expired card is a decline",
+			body:     `{"mdStatus":"0","mdErrorMessage":"Expired card","threeDOperationResult":{"threeDResult":"1"}}`,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp PaycellGetThreeDSessionResultResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if got := resp.SessionExpired(); got != tt.expected {
+				t.Errorf("Expected SessionExpired() = %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPaycellGetThreeDSessionResultResponse_ChallengeCancelled(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{
+			name:     "authenticated",
+			body:     `{"mdStatus":"1","threeDOperationResult":{"threeDResult":"0","threeDResultDescription":"3D dogrulama basarili"}}`,
+			expected: false,
+		},
+		{
+			name:     "cancelled on the bank page",
+			body:     `{"mdStatus":"0","mdErrorMessage":"Cancelled by cardholder","threeDOperationResult":{"threeDResult":"1","threeDResultDescription":"Failed"}}`,
+			expected: true,
+		},
+		{
+			name:     "cancellation in result description",
+			body:     `{"threeDOperationResult":{"threeDResult":"2","threeDResultDescription":"Islem kullanici tarafindan iptal edildi"}}`,
+			expected: true,
+		},
+		{
+			name:     "failed authentication",
+			body:     `{"mdStatus":"0","mdErrorMessage":"Not authenticated","threeDOperationResult":{"threeDResult":"1"}}`,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp PaycellGetThreeDSessionResultResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if got := resp.ChallengeCancelled(); got != tt.expected {
+				t.Errorf("Expected ChallengeCancelled() = %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPaycellProvider_Initialize_ReadsClockOffset(t *testing.T) {
+	t.Setenv("PAYCELL_CLOCK_OFFSET", "-1500ms")
+
+	p := NewProvider().(*PaycellProvider)
+	err := p.Initialize(map[string]string{
+		"username":    "test_user",
+		"password":    "test_pass",
+		"merchantId":  "test_merchant",
+		"secureCode":  "test_secure",
+		"environment": "sandbox",
+	})
+	if err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if p.clockOffset != -1500*time.Millisecond {
+		t.Errorf("Expected clock offset -1.5s, got %v", p.clockOffset)
+	}
+}
+
 func TestPaycellProvider_CreatePayment(t *testing.T) {
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -622,6 +793,24 @@ func TestPaycellProvider_GetRequiredConfig(t *testing.T) {
 	}
 }
 
+func TestPaycellProvider_SupportedCurrencies(t *testing.T) {
+	p := NewProvider()
+
+	currencies := p.SupportedCurrencies()
+	if len(currencies) != 1 || currencies[0] != "TRY" {
+		t.Fatalf("Expected paycell to support only TRY, got %v", currencies)
+	}
+
+	if !provider.IsCurrencySupported(p, "try") {
+		t.Error("Expected TRY to be supported regardless of case")
+	}
+	for _, currency := range []string{"USD", "EUR", "GBP"} {
+		if provider.IsCurrencySupported(p, currency) {
+			t.Errorf("Expected %s to be rejected by paycell", currency)
+		}
+	}
+}
+
 func TestPaycellProvider_ValidateConfig(t *testing.T) {
 	provider := NewProvider().(*PaycellProvider)
 
@@ -789,3 +978,243 @@ func TestPaycellProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestPaycellProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &PaycellProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointGetCardBinInformation {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointGetCardBinInformation {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestPaycellProvider_GetCommission_InstallmentBreakdown(t *testing.T) {
+	rates := map[string]string{"1": "1.50", "2": "2.50", "3": "3.50"}
+	var requested []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != endpointGetPrepaidCommission {
+			t.Errorf("Expected path %s, got %s", endpointGetPrepaidCommission, r.URL.Path)
+		}
+
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		installmentCount, _ := body["installmentCount"].(string)
+		requested = append(requested, installmentCount)
+
+		responseCode := responseCodeSuccess
+		if installmentCount == "4" {
+			responseCode = "1001"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"commissionRate":   rates[installmentCount],
+			"commissionAmount": rates[installmentCount],
+			"grossAmount":      "100.00",
+			"netAmount":        "98.00",
+			"responseHeader": map[string]string{
+				"responseCode":        responseCode,
+				"responseDescription": "Islem basarili",
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider().(*PaycellProvider)
+	p.httpClient = provider.NewProviderHTTPClient(&provider.HTTPClientConfig{
+		BaseURL: server.URL,
+		Timeout: 5 * time.Second,
+	})
+
+	request := provider.CommissionRequest{
+		BinValue:            "552879",
+		InstallmentCount:    1,
+		MaxInstallmentCount: 3,
+		Amount:              100,
+	}
+	response, err := p.GetCommission(context.Background(), request)
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+
+	if strings.Join(requested, ",") != "1,2,3" {
+		t.Errorf("Expected one request per installment count 1-3, got %v", requested)
+	}
+	if !response.Success || response.CommissionRate != 1.5 {
+		t.Errorf("Expected successful response with the first count's rate, got %+v", response)
+	}
+	if len(response.Installments) != 3 {
+		t.Fatalf("Expected 3 installment commissions, got %+v", response.Installments)
+	}
+	for i, installment := range response.Installments {
+		expectedRate := 1.5 + float64(i)
+		if installment.InstallmentCount != i+1 || installment.CommissionRate != expectedRate {
+			t.Errorf("Installment %d: expected rate %v, got %+v", i+1, expectedRate, installment)
+		}
+	}
+
+	// A single installment count keeps the old response shape
+	request.MaxInstallmentCount = 0
+	response, err = p.GetCommission(context.Background(), request)
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+	if !response.Success || response.Installments != nil {
+		t.Errorf("Expected no breakdown without maxInstallmentCount, got %+v", response)
+	}
+
+	// A failing count fails the whole breakdown
+	request.MaxInstallmentCount = 4
+	response, err = p.GetCommission(context.Background(), request)
+	if err != nil {
+		t.Fatalf("GetCommission failed: %v", err)
+	}
+	if response.Success || response.Installments != nil {
+		t.Errorf("Expected failed response without breakdown, got %+v", response)
+	}
+}
+
+func TestPaycellCardBinInformationResponse_CardBinInfo(t *testing.T) {
+	var resp PaycellCardBinInformationResponse
+	body := `{
+		"cardBinInformations": [
+			{"bankCode": "46", "bankName": "AKBANK", "binValue": "435508", "cardBrand": "AXESS", "cardType": "Credit Card", "cardOrganization": "VISA"}
+		],
+		"responseHeader": {"responseCode": "0", "responseDescription": "Success"}
+	}`
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	info, err := resp.CardBinInfo()
+	if err != nil {
+		t.Fatalf("CardBinInfo failed: %v", err)
+	}
+	expected := provider.CardBinInfo{BIN: "435508", CardType: "Credit Card", CardAssociation: "VISA", BankName: "AKBANK", Country: "TR"}
+	if *info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *info)
+	}
+
+	details := provider.PaymentMethodDetailsFromBinInfo(info)
+	if details.Brand != "visa" || details.FundingType != provider.FundingCredit || details.IssuerCountry != "TR" {
+		t.Errorf("Unexpected payment method details %+v", details)
+	}
+
+	resp.CardBinInformations = nil
+	if _, err := resp.CardBinInfo(); err == nil {
+		t.Error("Expected error for an unknown BIN")
+	}
+
+	resp.ResponseHeader.ResponseCode = "2001"
+	if _, err := resp.CardBinInfo(); err == nil {
+		t.Error("Expected error for a failed lookup")
+	}
+}
+
+func TestSettleProvisionedPayment(t *testing.T) {
+	provision := &provider.PaymentResponse{
+		Success:       true,
+		Status:        provider.StatusSuccessful,
+		TransactionID: "txn-provision",
+		Message:       "Islem basarili",
+	}
+
+	t.Run("status refresh fails after successful provision", func(t *testing.T) {
+		refreshCalls := 0
+		response, err := settleProvisionedPayment(provision, func() (*provider.PaymentResponse, error) {
+			refreshCalls++
+			return nil, errors.New("failed to send inquire request: timeout")
+		})
+
+		if err == nil {
+			t.Error("Expected the refresh error to be returned for logging")
+		}
+		if refreshCalls != 1 {
+			t.Errorf("Expected one refresh, got %d", refreshCalls)
+		}
+		if response == nil || !response.Success || response.Status != provider.StatusSuccessful || response.TransactionID != "txn-provision" {
+			t.Errorf("Expected the successful provision result, got %+v", response)
+		}
+	})
+
+	t.Run("status refresh succeeds", func(t *testing.T) {
+		refreshed := &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful, Amount: 100.5, Message: "Approved"}
+		response, err := settleProvisionedPayment(provision, func() (*provider.PaymentResponse, error) {
+			return refreshed, nil
+		})
+
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if response != refreshed {
+			t.Errorf("Expected the refreshed status, got %+v", response)
+		}
+	})
+
+	t.Run("status refresh fails after declined provision", func(t *testing.T) {
+		declined := &provider.PaymentResponse{Success: false, Status: provider.StatusFailed, ErrorCode: "4001"}
+		response, err := settleProvisionedPayment(declined, func() (*provider.PaymentResponse, error) {
+			return nil, errors.New("failed to get reference number")
+		})
+
+		if err == nil {
+			t.Error("Expected the refresh error to be returned for logging")
+		}
+		if response.Success || response.ErrorCode != "4001" {
+			t.Errorf("Expected the declined provision result, got %+v", response)
+		}
+	})
+}
+
+func TestPaycellProvider_Generate3DSecureHTML_ChallengeForm(t *testing.T) {
+	p := &PaycellProvider{paymentManagementURL: paymentManagementSandboxURL}
+	callbackURL := "https://gopay.example/v1/callback/paycell?state=abc&tenant=1"
+
+	form, ok := provider.ParseChallengeForm(p.generate3DSecureHTML("session-123", callbackURL))
+	if !ok {
+		t.Fatal("Expected a challenge form in the 3D HTML")
+	}
+	if form.URL != paymentManagementSandboxURL+endpointThreeDSecure || form.Method != "POST" {
+		t.Errorf("Unexpected form target %s %s", form.Method, form.URL)
+	}
+	want := map[string]string{"threeDSessionId": "session-123", "callbackurl": callbackURL}
+	if len(form.Fields) != len(want) || form.Fields["threeDSessionId"] != want["threeDSessionId"] || form.Fields["callbackurl"] != want["callbackurl"] {
+		t.Errorf("Expected fields %v, got %v", want, form.Fields)
+	}
+}
+
+func TestCancelResponse(t *testing.T) {
+	reverse := func(code, description string) PaycellReverseResponse {
+		return PaycellReverseResponse{ResponseHeader: PaycellResponseHeader{ResponseCode: code, ResponseDescription: description}}
+	}
+
+	tests := []struct {
+		name      string
+		response  PaycellReverseResponse
+		err       error
+		errorCode string
+	}{
+		{"cancelled", reverse("0", "Success"), nil, ""},
+		{"already captured", reverse("1052", "Transaction already settled, refund instead"), provider.ErrAlreadyCaptured, provider.ErrorCodeAlreadyCaptured},
+		{"already cancelled", reverse("1051", "İşlem daha önce iptal edilmiş"), provider.ErrAlreadyCancelled, provider.ErrorCodeAlreadyCancelled},
+		{"not found", reverse("1050", "Original transaction not found"), provider.ErrPaymentNotFound, provider.ErrorCodePaymentNotFound},
+		{"other failure", reverse("9999", "System error"), nil, "9999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := cancelResponse("pay-1", tt.response)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if response.ErrorCode != tt.errorCode {
+				t.Errorf("expected error code %q, got %q", tt.errorCode, response.ErrorCode)
+			}
+			if tt.err != nil && response.Status != provider.StatusFailed {
+				t.Errorf("expected failed status, got %s", response.Status)
+			}
+		})
+	}
+}
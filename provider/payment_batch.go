@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// Default batch payment limits, used when the env vars are unset or not positive
+const (
+	defaultBatchPaymentMaxItems    = 100
+	defaultBatchPaymentConcurrency = 5
+)
+
+// ErrBatchPaymentInvalid is returned for an empty batch or one with too many payments
+var ErrBatchPaymentInvalid = errors.New("invalid payment batch")
+
+// BatchPaymentLimits bound the payments of one batch request
+type BatchPaymentLimits struct {
+	MaxItems    int // payments in one batch
+	Concurrency int // payments of a batch processed at the same time
+}
+
+// BatchPaymentLimitsFromEnv reads BATCH_PAYMENT_MAX_ITEMS and BATCH_PAYMENT_CONCURRENCY, using
+// the defaults for unset or non-positive values.
+func BatchPaymentLimitsFromEnv() BatchPaymentLimits {
+	limit := func(key string, fallback int) int {
+		if value := config.GetIntEnv(key, fallback); value > 0 {
+			return value
+		}
+		return fallback
+	}
+
+	return BatchPaymentLimits{
+		MaxItems:    limit("BATCH_PAYMENT_MAX_ITEMS", defaultBatchPaymentMaxItems),
+		Concurrency: limit("BATCH_PAYMENT_CONCURRENCY", defaultBatchPaymentConcurrency),
+	}
+}
+
+// Validate returns an error wrapping ErrBatchPaymentInvalid when a batch of count payments is
+// empty or larger than MaxItems
+func (l BatchPaymentLimits) Validate(count int) error {
+	if count == 0 {
+		return fmt.Errorf("%w: no payments", ErrBatchPaymentInvalid)
+	}
+	if count > l.MaxItems {
+		return fmt.Errorf("%w: %d payments, at most %d allowed", ErrBatchPaymentInvalid, count, l.MaxItems)
+	}
+	return nil
+}
+
+// BatchPaymentResult is the outcome of one payment of a batch. Error is set when the payment
+// could not be processed; a payment the provider declined has a Payment with Success false.
+type BatchPaymentResult struct {
+	Index   int              `json:"index"`
+	Success bool             `json:"success"`
+	Payment *PaymentResponse `json:"payment,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// BatchPaymentSummary counts the outcomes of a batch
+type BatchPaymentSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// BatchPaymentResponse holds the results of a batch in the order of its payments
+type BatchPaymentResponse struct {
+	Results []BatchPaymentResult `json:"results"`
+	Summary BatchPaymentSummary  `json:"summary"`
+}
+
+// InMinorUnits returns a copy of the response with the amounts of its payments in minor units
+func (r *BatchPaymentResponse) InMinorUnits() any {
+	converted := &BatchPaymentResponse{Results: make([]BatchPaymentResult, len(r.Results)), Summary: r.Summary}
+	for i, result := range r.Results {
+		if result.Payment != nil {
+			result.Payment = result.Payment.InMinorUnits().(*PaymentResponse)
+		}
+		converted.Results[i] = result
+	}
+	return converted
+}
+
+// CreatePaymentBatch processes the payments of a batch through CreatePayment, at most
+// concurrency at a time. A payment that fails does not stop the others; payments not yet
+// started when ctx is done fail with its error.
+func (s *PaymentService) CreatePaymentBatch(ctx context.Context, environment, providerName string, requests []PaymentRequest, concurrency int) *BatchPaymentResponse {
+	if concurrency <= 0 {
+		concurrency = defaultBatchPaymentConcurrency
+	}
+
+	results := make([]BatchPaymentResult, len(requests))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		results[i].Index = i
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Error = ctx.Err().Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *BatchPaymentResult, request PaymentRequest) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			resp, err := s.CreatePayment(ctx, environment, providerName, request)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.Payment = resp
+			result.Success = resp.Success
+		}(&results[i], request)
+	}
+	wg.Wait()
+
+	summary := BatchPaymentSummary{Total: len(results)}
+	for _, result := range results {
+		if result.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+	}
+	return &BatchPaymentResponse{Results: results, Summary: summary}
+}
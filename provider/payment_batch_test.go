@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// lockedPaymentLogger is a recordingPaymentLogger safe for the concurrent payments of a batch
+type lockedPaymentLogger struct {
+	mu sync.Mutex
+	recordingPaymentLogger
+}
+
+func (l *lockedPaymentLogger) LogRequest(ctx context.Context, tenantID int, providerName, method, endpoint string, request any, userAgent, clientIP string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.recordingPaymentLogger.LogRequest(ctx, tenantID, providerName, method, endpoint, request, userAgent, clientIP)
+}
+
+func (l *lockedPaymentLogger) LogResponse(ctx context.Context, logID int64, response any, processingMs int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.recordingPaymentLogger.LogResponse(ctx, logID, response, processingMs)
+}
+
+// batchTestProvider declines payments of 7, fails payments of 13 and records how many payments
+// it processed at the same time
+type batchTestProvider struct {
+	PaymentProvider
+	inFlight, maxInFlight atomic.Int32
+}
+
+func (p *batchTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *batchTestProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	current := p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	for {
+		seen := p.maxInFlight.Load()
+		if current <= seen || p.maxInFlight.CompareAndSwap(seen, current) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	switch request.Amount {
+	case 7:
+		return &PaymentResponse{Success: false, Status: StatusFailed, PaymentID: "declined", ErrorCode: "51"}, nil
+	case 13:
+		return nil, errors.New("provider unavailable")
+	}
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay", Amount: request.Amount, Currency: request.Currency}, nil
+}
+
+func TestPaymentService_CreatePaymentBatch(t *testing.T) {
+	const tenantID, providerName = 9146, "batchtest"
+
+	fake := &batchTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&lockedPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9146")
+
+	amounts := []float64{10, 7, 20, 13, 30, 40, 50, 60}
+	requests := make([]PaymentRequest, len(amounts))
+	for i, amount := range amounts {
+		requests[i] = riskRequest()
+		requests[i].Amount = amount
+	}
+
+	resp := service.CreatePaymentBatch(ctx, "sandbox", providerName, requests, 3)
+
+	if resp.Summary != (BatchPaymentSummary{Total: 8, Succeeded: 6, Failed: 2}) {
+		t.Errorf("Unexpected summary: %+v", resp.Summary)
+	}
+	if got := fake.maxInFlight.Load(); got > 3 || got < 2 {
+		t.Errorf("Expected up to 3 payments at a time, got %d", got)
+	}
+	for i, result := range resp.Results {
+		if result.Index != i {
+			t.Errorf("Result %d: expected it in request order, got index %d", i, result.Index)
+		}
+	}
+	if declined := resp.Results[1]; declined.Success || declined.Payment == nil || declined.Payment.ErrorCode != "51" || declined.Error != "" {
+		t.Errorf("Expected the declined payment's response, got %+v", declined)
+	}
+	if failed := resp.Results[3]; failed.Success || failed.Payment != nil || failed.Error == "" {
+		t.Errorf("Expected the failed payment's error, got %+v", failed)
+	}
+	if ok := resp.Results[7]; !ok.Success || ok.Payment.Amount != 60 {
+		t.Errorf("Expected the last payment to succeed, got %+v", ok)
+	}
+}
+
+func TestPaymentService_CreatePaymentBatch_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tenantctx.IDKey, "9146"))
+	cancel()
+
+	resp := NewPaymentService(&lockedPaymentLogger{}).CreatePaymentBatch(ctx, "sandbox", "batchtest", []PaymentRequest{riskRequest(), riskRequest()}, 1)
+	if resp.Summary.Failed != 2 {
+		t.Errorf("Expected every payment to fail once the batch is cancelled, got %+v", resp)
+	}
+}
+
+func TestBatchPaymentLimits_Validate(t *testing.T) {
+	limits := BatchPaymentLimits{MaxItems: 3, Concurrency: 2}
+	for _, count := range []int{0, 4} {
+		if err := limits.Validate(count); !errors.Is(err, ErrBatchPaymentInvalid) {
+			t.Errorf("Expected a batch of %d to be rejected, got %v", count, err)
+		}
+	}
+	if err := limits.Validate(3); err != nil {
+		t.Errorf("Expected a full batch to be accepted, got %v", err)
+	}
+}
+
+func TestBatchPaymentLimitsFromEnv(t *testing.T) {
+	t.Setenv("BATCH_PAYMENT_MAX_ITEMS", "20")
+	t.Setenv("BATCH_PAYMENT_CONCURRENCY", "-1")
+
+	limits := BatchPaymentLimitsFromEnv()
+	if limits.MaxItems != 20 || limits.Concurrency != defaultBatchPaymentConcurrency {
+		t.Errorf("Unexpected limits: %+v", limits)
+	}
+}
@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// Payment lifecycle event types
+const (
+	PaymentEventCreated     = "created"
+	PaymentEvent3DInitiated = "3d_initiated"
+	PaymentEvent3DCompleted = "3d_completed"
+	PaymentEventCaptured    = "captured"
+	PaymentEventRefunded    = "refunded"
+	PaymentEventCancelled   = "cancelled"
+)
+
+// maxPaymentEvents caps the events listed for one payment
+const maxPaymentEvents = 500
+
+// ErrPaymentEventsUnavailable is returned when no payment event store is configured
+var ErrPaymentEventsUnavailable = errors.New("payment event history is not available")
+
+// PaymentEvent is one step in the life of a payment. Events are only appended, so the list of
+// a payment is its full history, including attempts that failed.
+type PaymentEvent struct {
+	ID          int64     `json:"id"`
+	TenantID    int       `json:"-"`
+	Provider    string    `json:"provider"`
+	Environment string    `json:"environment"`
+	PaymentID   string    `json:"paymentId"`
+	Type        string    `json:"type"`
+	Success     bool      `json:"success"`
+	Status      string    `json:"status,omitempty"`
+	Amount      float64   `json:"amount,omitempty"`
+	Currency    string    `json:"currency,omitempty"`
+	ReferenceID string    `json:"referenceId,omitempty"`
+	ErrorCode   string    `json:"errorCode,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// PaymentEventStore keeps the append-only event stream of payments
+type PaymentEventStore interface {
+	// Append stores an event and sets its ID and CreatedAt
+	Append(ctx context.Context, event *PaymentEvent) error
+
+	// List returns the events of a tenant's payment, oldest first
+	List(ctx context.Context, tenantID int, providerName, paymentID string) ([]PaymentEvent, error)
+}
+
+// SetPaymentEventStore records the lifecycle events of payments and enables GetPaymentEvents
+func (s *PaymentService) SetPaymentEventStore(store PaymentEventStore) {
+	s.paymentEvents = store
+}
+
+// GetPaymentEvents returns the lifecycle events of one of the tenant's payments, oldest first
+func (s *PaymentService) GetPaymentEvents(ctx context.Context, providerName, paymentID string) ([]PaymentEvent, error) {
+	if s.paymentEvents == nil {
+		return nil, ErrPaymentEventsUnavailable
+	}
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.paymentEvents.List(ctx, tenantID, providerName, paymentID)
+}
+
+// recordPaymentEvent appends event when a store is configured and sends it to the watchers of
+// the payment. The outcome is taken from the operation's error, or from its response when the
+// provider answered. A failure to record is logged and does not fail the operation.
+func (s *PaymentService) recordPaymentEvent(ctx context.Context, event PaymentEvent, opErr error) {
+	if s.paymentEvents == nil || event.PaymentID == "" {
+		return
+	}
+	if opErr != nil {
+		event.Success = false
+		event.Message = opErr.Error()
+	}
+
+	if err := s.paymentEvents.Append(ctx, &event); err != nil {
+		logger.Warn("Failed to record payment event", logger.LogContext{
+			TenantID: strconv.Itoa(event.TenantID),
+			Provider: event.Provider,
+			Fields: map[string]any{
+				"payment_id": event.PaymentID,
+				"event":      event.Type,
+				"error":      err.Error(),
+			},
+		})
+		return
+	}
+	s.eventBroker.publish(event)
+}
+
+// paymentEventOf builds the event of a payment operation from its response. The event is
+// recorded under paymentID, the ID the client knows the payment by, or under the response's
+// payment ID when paymentID is empty.
+func paymentEventOf(eventType string, tenantID int, providerName, environment, paymentID string, response *PaymentResponse) PaymentEvent {
+	event := PaymentEvent{
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		PaymentID:   paymentID,
+		Type:        eventType,
+	}
+	if response != nil {
+		if event.PaymentID == "" {
+			event.PaymentID = response.PaymentID
+		}
+		event.Success = response.Success
+		event.Status = string(response.Status)
+		event.Amount = response.Amount
+		event.Currency = response.Currency
+		event.ReferenceID = response.TransactionID
+		event.ErrorCode = response.ErrorCode
+		event.Message = response.Message
+	}
+	return event
+}
+
+// refundEventOf builds the refunded event of a refund from its response
+func refundEventOf(tenantID int, providerName, environment string, request RefundRequest, response *RefundResponse) PaymentEvent {
+	event := PaymentEvent{
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		PaymentID:   request.PaymentID,
+		Type:        PaymentEventRefunded,
+		Amount:      request.RefundAmount,
+		Currency:    request.Currency,
+	}
+	if response != nil {
+		event.Success = response.Success
+		event.Status = response.Status
+		if response.RefundAmount > 0 {
+			event.Amount = response.RefundAmount
+		}
+		event.ReferenceID = response.RefundID
+		event.ErrorCode = response.ErrorCode
+		event.Message = response.Message
+	}
+	return event
+}
+
+// PostgresPaymentEventStore keeps payment events in the payment_events table.
+type PostgresPaymentEventStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPaymentEventStore creates a store over the shared *sql.DB connection.
+func NewPostgresPaymentEventStore(db *sql.DB) *PostgresPaymentEventStore {
+	return &PostgresPaymentEventStore{db: db}
+}
+
+// Append inserts an event
+func (r *PostgresPaymentEventStore) Append(ctx context.Context, event *PaymentEvent) error {
+	query := `
+		INSERT INTO payment_events (tenant_id, provider, environment, payment_id, event_type, success, status,
+			amount, currency, reference_id, error_code, message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		event.TenantID, event.Provider, event.Environment, event.PaymentID, event.Type, event.Success, nullString(event.Status),
+		event.Amount, nullString(event.Currency), nullString(event.ReferenceID), nullString(event.ErrorCode), nullString(event.Message),
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to append payment event: %w", err)
+	}
+	return nil
+}
+
+// List returns the events of a payment, scoped to the tenant
+func (r *PostgresPaymentEventStore) List(ctx context.Context, tenantID int, providerName, paymentID string) ([]PaymentEvent, error) {
+	query := fmt.Sprintf(`
+		SELECT id, tenant_id, provider, environment, payment_id, event_type, success, COALESCE(status, ''),
+			COALESCE(amount, 0), COALESCE(currency, ''), COALESCE(reference_id, ''), COALESCE(error_code, ''),
+			COALESCE(message, ''), created_at
+		FROM payment_events
+		WHERE tenant_id = $1 AND provider = $2 AND payment_id = $3
+		ORDER BY created_at, id
+		LIMIT %d`, maxPaymentEvents)
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, providerName, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []PaymentEvent{}
+	for rows.Next() {
+		var event PaymentEvent
+		if err := rows.Scan(&event.ID, &event.TenantID, &event.Provider, &event.Environment, &event.PaymentID, &event.Type,
+			&event.Success, &event.Status, &event.Amount, &event.Currency, &event.ReferenceID, &event.ErrorCode,
+			&event.Message, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan payment event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
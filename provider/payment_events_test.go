@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryPaymentEventStore is an in-memory PaymentEventStore for tests
+type memoryPaymentEventStore struct {
+	mu     sync.Mutex
+	events []PaymentEvent
+}
+
+func (m *memoryPaymentEventStore) Append(_ context.Context, event *PaymentEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = int64(len(m.events) + 1)
+	event.CreatedAt = time.Now()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memoryPaymentEventStore) List(_ context.Context, tenantID int, providerName, paymentID string) ([]PaymentEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := []PaymentEvent{}
+	for _, event := range m.events {
+		if event.TenantID == tenantID && event.Provider == providerName && event.PaymentID == paymentID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// eventTestProvider authorizes, captures, refunds and cancels; refunds fail when refundErr is set
+type eventTestProvider struct {
+	captureTestProvider
+	refundErr error
+}
+
+func (p *eventTestProvider) RefundPayment(_ context.Context, request RefundRequest) (*RefundResponse, error) {
+	if p.refundErr != nil {
+		return nil, p.refundErr
+	}
+	return &RefundResponse{Success: true, RefundID: "ref_1", PaymentID: request.PaymentID, RefundAmount: request.RefundAmount, Status: "refunded"}, nil
+}
+
+func (p *eventTestProvider) CancelPayment(_ context.Context, request CancelRequest) (*PaymentResponse, error) {
+	return &PaymentResponse{Success: true, Status: StatusCancelled, PaymentID: request.PaymentID}, nil
+}
+
+func TestPaymentService_RecordsPaymentEvents(t *testing.T) {
+	const tenantID, providerName = 9142, "eventtest"
+
+	fake := &eventTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := &memoryPaymentEventStore{}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentEventStore(store)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9142")
+
+	request := riskRequest()
+	request.PaymentType = PaymentTypeAuth
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if _, err := service.CapturePayment(ctx, "sandbox", providerName, CaptureRequest{PaymentID: resp.PaymentID, Amount: 100, Currency: "TRY"}); err != nil {
+		t.Fatalf("CapturePayment failed: %v", err)
+	}
+
+	fake.refundErr = errors.New("provider unavailable")
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: resp.PaymentID, RefundAmount: 40, Currency: "TRY"}); err == nil {
+		t.Fatal("Expected the refund to fail")
+	}
+	fake.refundErr = nil
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: resp.PaymentID, RefundAmount: 40, Currency: "TRY"}); err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if _, err := service.CancelPayment(ctx, "sandbox", providerName, CancelRequest{PaymentID: resp.PaymentID}); err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+
+	events, err := service.GetPaymentEvents(ctx, providerName, "pay_auth")
+	if err != nil {
+		t.Fatalf("GetPaymentEvents failed: %v", err)
+	}
+
+	expected := []struct {
+		eventType string
+		success   bool
+	}{
+		{PaymentEventCreated, true},
+		{PaymentEventCaptured, true},
+		{PaymentEventRefunded, false},
+		{PaymentEventRefunded, true},
+		{PaymentEventCancelled, true},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %+v", len(expected), events)
+	}
+	for i, want := range expected {
+		if events[i].Type != want.eventType || events[i].Success != want.success {
+			t.Errorf("Event %d: expected %s (success %v), got %s (success %v)", i, want.eventType, want.success, events[i].Type, events[i].Success)
+		}
+	}
+	if events[2].Message != "provider unavailable" || events[3].Amount != 40 || events[3].ReferenceID != "ref_1" {
+		t.Errorf("Expected the refund details in the events, got %+v and %+v", events[2], events[3])
+	}
+
+	// Another tenant does not see the payment's history
+	otherTenant := context.WithValue(context.Background(), tenantctx.IDKey, "9143")
+	if others, err := service.GetPaymentEvents(otherTenant, providerName, "pay_auth"); err != nil || len(others) != 0 {
+		t.Errorf("Expected no events for another tenant, got %+v (%v)", others, err)
+	}
+}
+
+func TestPaymentService_GetPaymentEvents_Unavailable(t *testing.T) {
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9142")
+	if _, err := service.GetPaymentEvents(ctx, "iyzico", "pay_1"); !errors.Is(err, ErrPaymentEventsUnavailable) {
+		t.Errorf("Expected ErrPaymentEventsUnavailable without a store, got %v", err)
+	}
+}
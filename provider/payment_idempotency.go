@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// defaultPaymentIdempotencyTTL is how long a payment idempotency key is kept when
+// PAYMENT_IDEMPOTENCY_TTL_HOURS is not set
+const defaultPaymentIdempotencyTTL = 24 * time.Hour
+
+var (
+	// ErrPaymentInProgress is returned when a payment with the same idempotency key is still
+	// being processed
+	ErrPaymentInProgress = errors.New("a payment with this idempotency key is already in progress")
+
+	// ErrPaymentIdempotencyKeyReused is returned when an idempotency key is sent again with a
+	// different payment
+	ErrPaymentIdempotencyKeyReused = errors.New("idempotency key was already used for a different payment")
+)
+
+// PaymentIdempotencyKey identifies a payment submission of a tenant
+type PaymentIdempotencyKey struct {
+	TenantID int
+	Key      string
+}
+
+// PaymentIdempotencyStore remembers payments by idempotency key so a retried submission
+// returns the first result instead of charging twice. Keys expire after a TTL, after which
+// the key can be used for a new payment.
+type PaymentIdempotencyStore interface {
+	// Claim reserves the key for a payment with the given fingerprint. When the key was
+	// claimed before and has not expired it returns claimed=false along with the stored
+	// response, which is nil while the first payment is still in flight, and the fingerprint
+	// it was claimed with.
+	Claim(ctx context.Context, key PaymentIdempotencyKey, fingerprint string) (claimed bool, prior *PaymentResponse, priorFingerprint string, err error)
+
+	// Complete stores the response of a claimed payment
+	Complete(ctx context.Context, key PaymentIdempotencyKey, response *PaymentResponse) error
+
+	// Release drops a claim whose payment was rejected before it reached the provider, so
+	// the client may retry with the same key
+	Release(ctx context.Context, key PaymentIdempotencyKey) error
+
+	// Cleanup removes expired keys
+	Cleanup(ctx context.Context) error
+}
+
+// PaymentIdempotencyTTL reads PAYMENT_IDEMPOTENCY_TTL_HOURS, 24 hours by default
+func PaymentIdempotencyTTL() time.Duration {
+	hours := config.GetIntEnv("PAYMENT_IDEMPOTENCY_TTL_HOURS", 0)
+	if hours <= 0 {
+		return defaultPaymentIdempotencyTTL
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// paymentFingerprint identifies what a payment request charges, so a key sent again with
+// another payment is told apart from a retry. Card details are left out: a retry may not
+// resend them, and the fingerprint must not be a lookup for card numbers.
+func paymentFingerprint(providerName, environment string, request PaymentRequest) string {
+	fields := []string{
+		providerName,
+		environment,
+		strings.ToUpper(request.Currency),
+		strconv.FormatInt(ToMinorUnits(request.Amount, request.Currency), 10),
+		strconv.FormatBool(request.Use3D),
+		strconv.Itoa(request.InstallmentCount),
+		request.ID,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// PostgresPaymentIdempotencyStore keeps idempotency keys in the payment_idempotency_keys table.
+type PostgresPaymentIdempotencyStore struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+// NewPostgresPaymentIdempotencyStore creates a store over the shared *sql.DB connection whose
+// keys expire after ttl
+func NewPostgresPaymentIdempotencyStore(db *sql.DB, ttl time.Duration) *PostgresPaymentIdempotencyStore {
+	return &PostgresPaymentIdempotencyStore{db: db, ttl: ttl}
+}
+
+// Claim inserts the key, taking over an expired one, or returns the existing row when it is
+// still valid. The primary key on (tenant_id, idempotency_key) makes concurrent claims
+// race-free.
+func (r *PostgresPaymentIdempotencyStore) Claim(ctx context.Context, key PaymentIdempotencyKey, fingerprint string) (bool, *PaymentResponse, string, error) {
+	query := `
+		INSERT INTO payment_idempotency_keys (tenant_id, idempotency_key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 second')
+		ON CONFLICT (tenant_id, idempotency_key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, response = NULL, created_at = now(),
+			completed_at = NULL, expires_at = EXCLUDED.expires_at
+		WHERE payment_idempotency_keys.expires_at < now()`
+
+	result, err := r.db.ExecContext(ctx, query, key.TenantID, key.Key, fingerprint, int64(r.ttl.Seconds()))
+	if err != nil {
+		return false, nil, "", fmt.Errorf("failed to claim payment idempotency key: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return false, nil, "", err
+	} else if affected > 0 {
+		return true, nil, "", nil
+	}
+
+	var (
+		priorFingerprint string
+		raw              []byte
+	)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT fingerprint, response FROM payment_idempotency_keys
+		WHERE tenant_id = $1 AND idempotency_key = $2`,
+		key.TenantID, key.Key,
+	).Scan(&priorFingerprint, &raw)
+	if err != nil {
+		return false, nil, "", fmt.Errorf("failed to get payment idempotency key: %w", err)
+	}
+
+	if raw == nil {
+		return false, nil, priorFingerprint, nil
+	}
+	var prior PaymentResponse
+	if err := json.Unmarshal(raw, &prior); err != nil {
+		return false, nil, "", fmt.Errorf("failed to decode stored payment response: %w", err)
+	}
+	return false, &prior, priorFingerprint, nil
+}
+
+// Complete stores the response of a claimed payment
+func (r *PostgresPaymentIdempotencyStore) Complete(ctx context.Context, key PaymentIdempotencyKey, response *PaymentResponse) error {
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode payment response: %w", err)
+	}
+
+	query := `
+		UPDATE payment_idempotency_keys SET response = $3, completed_at = now()
+		WHERE tenant_id = $1 AND idempotency_key = $2`
+	if _, err := r.db.ExecContext(ctx, query, key.TenantID, key.Key, raw); err != nil {
+		return fmt.Errorf("failed to store payment response: %w", err)
+	}
+	return nil
+}
+
+// Release deletes a claim that has no response yet
+func (r *PostgresPaymentIdempotencyStore) Release(ctx context.Context, key PaymentIdempotencyKey) error {
+	query := `
+		DELETE FROM payment_idempotency_keys
+		WHERE tenant_id = $1 AND idempotency_key = $2 AND response IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, key.TenantID, key.Key); err != nil {
+		return fmt.Errorf("failed to release payment idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Cleanup deletes expired keys
+func (r *PostgresPaymentIdempotencyStore) Cleanup(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM payment_idempotency_keys WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("failed to clean up payment idempotency keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+type storedPayment struct {
+	fingerprint string
+	response    *PaymentResponse
+}
+
+// memoryPaymentIdempotencyStore is an in-memory PaymentIdempotencyStore for tests. Keys do
+// not expire unless expire is called.
+type memoryPaymentIdempotencyStore struct {
+	mu       sync.Mutex
+	payments map[PaymentIdempotencyKey]*storedPayment
+}
+
+func newMemoryPaymentIdempotencyStore() *memoryPaymentIdempotencyStore {
+	return &memoryPaymentIdempotencyStore{payments: make(map[PaymentIdempotencyKey]*storedPayment)}
+}
+
+func (m *memoryPaymentIdempotencyStore) Claim(_ context.Context, key PaymentIdempotencyKey, fingerprint string) (bool, *PaymentResponse, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.payments[key]; ok {
+		return false, stored.response, stored.fingerprint, nil
+	}
+	m.payments[key] = &storedPayment{fingerprint: fingerprint}
+	return true, nil, "", nil
+}
+
+func (m *memoryPaymentIdempotencyStore) Complete(_ context.Context, key PaymentIdempotencyKey, response *PaymentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payments[key].response = response
+	return nil
+}
+
+func (m *memoryPaymentIdempotencyStore) Release(_ context.Context, key PaymentIdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.payments[key]; ok && stored.response == nil {
+		delete(m.payments, key)
+	}
+	return nil
+}
+
+func (m *memoryPaymentIdempotencyStore) Cleanup(context.Context) error {
+	return nil
+}
+
+// expire drops a key the way the TTL does
+func (m *memoryPaymentIdempotencyStore) expire(key PaymentIdempotencyKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.payments, key)
+}
+
+// idempotencyTestProvider counts payments and hands out a new payment ID each time
+type idempotencyTestProvider struct {
+	PaymentProvider
+	payments int
+	err      error
+}
+
+func (p *idempotencyTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *idempotencyTestProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.payments++
+	return &PaymentResponse{
+		Success:   true,
+		Status:    StatusSuccessful,
+		PaymentID: fmt.Sprintf("pay_%d", p.payments),
+		Amount:    request.Amount,
+		Currency:  request.Currency,
+	}, nil
+}
+
+func newPaymentIdempotencyTestService(t *testing.T, tenantID int) (*PaymentService, *idempotencyTestProvider, *memoryPaymentIdempotencyStore, context.Context) {
+	const providerName = "idempotencytest"
+	fake := &idempotencyTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := newMemoryPaymentIdempotencyStore()
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentIdempotencyStore(store)
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, strconv.Itoa(tenantID))
+	return service, fake, store, ctx
+}
+
+func idempotentPaymentRequest() PaymentRequest {
+	return PaymentRequest{
+		Amount:         100,
+		Currency:       "TRY",
+		IdempotencyKey: "payment-key-1",
+		CardInfo:       CardInfo{CardNumber: "4111111111111111", ExpireMonth: "12", ExpireYear: "2030", CVV: "123"},
+	}
+}
+
+func TestPaymentService_CreatePayment_DuplicateReturnsSameResult(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+
+	first, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	second, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+	if err != nil {
+		t.Fatalf("Duplicate CreatePayment failed: %v", err)
+	}
+
+	if fake.payments != 1 {
+		t.Errorf("Expected a single payment at the provider, got %d", fake.payments)
+	}
+	if second.PaymentID != first.PaymentID || second.Amount != first.Amount {
+		t.Errorf("Expected the first payment to be returned, got %+v vs %+v", second, first)
+	}
+	if first.Replayed || !second.Replayed {
+		t.Errorf("Expected only the duplicate to be marked replayed, got %v and %v", first.Replayed, second.Replayed)
+	}
+
+	// A retry does not have to send the card again
+	retry := request
+	retry.CardInfo.CVV = ""
+	if resp, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", retry); err != nil || resp.PaymentID != first.PaymentID {
+		t.Errorf("Expected the first payment for a retry without the CVV, got %+v (%v)", resp, err)
+	}
+}
+
+func TestPaymentService_CreatePayment_WithoutKeyIsNotDeduplicated(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+	request.IdempotencyKey = ""
+
+	for range 2 {
+		if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); err != nil {
+			t.Fatalf("CreatePayment failed: %v", err)
+		}
+	}
+	if fake.payments != 2 {
+		t.Errorf("Expected payments without a key to reach the provider, got %d", fake.payments)
+	}
+}
+
+func TestPaymentService_CreatePayment_KeyReusedForDifferentPayment(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	other := request
+	other.Amount = 200
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", other); !errors.Is(err, ErrPaymentIdempotencyKeyReused) {
+		t.Errorf("Expected ErrPaymentIdempotencyKeyReused for another amount, got %v", err)
+	}
+	if fake.payments != 1 {
+		t.Errorf("Expected a single payment at the provider, got %d", fake.payments)
+	}
+}
+
+func TestPaymentService_CreatePayment_InProgress(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+	key := PaymentIdempotencyKey{TenantID: 9105, Key: request.IdempotencyKey}
+	if _, _, _, err := service.paymentIdempotency.Claim(ctx, key, paymentFingerprint("idempotencytest", "sandbox", request)); err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); !errors.Is(err, ErrPaymentInProgress) {
+		t.Errorf("Expected ErrPaymentInProgress, got %v", err)
+	}
+	if fake.payments != 0 {
+		t.Errorf("Expected no payment while the first is in flight, got %d", fake.payments)
+	}
+}
+
+func TestPaymentService_CreatePayment_ProviderTimeoutIsNotRetried(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+
+	// The provider may have charged before the request timed out
+	fake.err = fmt.Errorf("failed to send request: %w", context.DeadlineExceeded)
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); err == nil {
+		t.Fatal("Expected provider error")
+	}
+
+	fake.err = nil
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("Expected ErrPaymentInProgress for a retry after a timeout, got %v", err)
+	}
+	if fake.payments != 0 {
+		t.Errorf("Expected the retry not to reach the provider, got %d payments", fake.payments)
+	}
+}
+
+func TestPaymentService_CreatePayment_UnknownStateIsReplayed(t *testing.T) {
+	service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+
+	fake.err = fmt.Errorf("%w: unexpected content type", ErrResponseUnparseable)
+	first, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+	if err != nil || first.Status != StatusUnknown {
+		t.Fatalf("Expected an unknown state response, got %+v (%v)", first, err)
+	}
+
+	fake.err = nil
+	second, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+	if err != nil || !second.Replayed || second.Status != StatusUnknown {
+		t.Errorf("Expected the unknown state to be replayed, got %+v (%v)", second, err)
+	}
+	if fake.payments != 0 {
+		t.Errorf("Expected the retry not to reach the provider, got %d payments", fake.payments)
+	}
+}
+
+func TestPaymentService_CreatePayment_RejectedBeforeProviderCanBeRetried(t *testing.T) {
+	for name, rejection := range map[string]error{
+		"provider validation": fmt.Errorf("idempotencytest: invalid payment request: %w", InvalidRequest(errors.New("card holder name is required"))),
+		"wallet token":        fmt.Errorf("%w: token was created for another merchant", ErrWalletPaymentInvalid),
+	} {
+		t.Run(name, func(t *testing.T) {
+			service, fake, _, ctx := newPaymentIdempotencyTestService(t, 9105)
+			request := idempotentPaymentRequest()
+
+			fake.err = rejection
+			if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); !errors.Is(err, rejection) {
+				t.Fatalf("Expected the provider's rejection, got %v", err)
+			}
+
+			fake.err = nil
+			resp, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+			if err != nil || !resp.Success || resp.Replayed {
+				t.Fatalf("Expected retry with the same key to pay, got %+v (err %v)", resp, err)
+			}
+			if fake.payments != 1 {
+				t.Errorf("Expected one successful payment, got %d", fake.payments)
+			}
+		})
+	}
+}
+
+func TestInvalidRequest(t *testing.T) {
+	cause := errors.New("card number is required")
+	err := fmt.Errorf("iyzico: invalid 3D payment request: %w", InvalidRequest(cause))
+
+	if !errors.Is(err, ErrInvalidPaymentRequest) || !errors.Is(err, cause) {
+		t.Errorf("Expected both ErrInvalidPaymentRequest and the cause, got %v", err)
+	}
+	if err.Error() != "iyzico: invalid 3D payment request: card number is required" {
+		t.Errorf("Expected the message to be kept, got %q", err.Error())
+	}
+}
+
+func TestPaymentService_CreatePayment_ExpiredKeyPaysAgain(t *testing.T) {
+	service, fake, store, ctx := newPaymentIdempotencyTestService(t, 9105)
+	request := idempotentPaymentRequest()
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	store.expire(PaymentIdempotencyKey{TenantID: 9105, Key: request.IdempotencyKey})
+
+	resp, err := service.CreatePayment(ctx, "sandbox", "idempotencytest", request)
+	if err != nil || resp.Replayed || fake.payments != 2 {
+		t.Errorf("Expected an expired key to make a new payment, got %+v (%v) after %d payments", resp, err, fake.payments)
+	}
+}
+
+func TestPaymentFingerprint(t *testing.T) {
+	request := idempotentPaymentRequest()
+	base := paymentFingerprint("iyzico", "sandbox", request)
+
+	withCard := request
+	withCard.CardInfo = CardInfo{CardNumber: "5528790000000008"}
+	if paymentFingerprint("iyzico", "sandbox", withCard) != base {
+		t.Error("Expected the card to be left out of the fingerprint")
+	}
+
+	threeD := request
+	threeD.Use3D = true
+	for name, fingerprint := range map[string]string{
+		"provider":    paymentFingerprint("paytr", "sandbox", request),
+		"environment": paymentFingerprint("iyzico", "production", request),
+		"3D":          paymentFingerprint("iyzico", "sandbox", threeD),
+	} {
+		if fingerprint == base {
+			t.Errorf("Expected another %s to change the fingerprint", name)
+		}
+	}
+}
+
+func TestPaymentIdempotencyTTL(t *testing.T) {
+	t.Setenv("PAYMENT_IDEMPOTENCY_TTL_HOURS", "")
+	if ttl := PaymentIdempotencyTTL(); ttl != defaultPaymentIdempotencyTTL {
+		t.Errorf("Expected the default TTL, got %v", ttl)
+	}
+
+	t.Setenv("PAYMENT_IDEMPOTENCY_TTL_HOURS", "48")
+	if ttl := PaymentIdempotencyTTL(); ttl.Hours() != 48 {
+		t.Errorf("Expected 48 hours, got %v", ttl)
+	}
+}
@@ -0,0 +1,427 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+const (
+	defaultPaymentJobWorkers   = 4
+	defaultPaymentJobQueueSize = 1000
+
+	// paymentJobTimeout bounds the processing of one job, like a synchronous payment request
+	paymentJobTimeout = 30 * time.Second
+
+	// paymentJobStaleAfter is the age after which an unfinished job is taken as lost with the
+	// memory of the instance that queued it
+	paymentJobStaleAfter = time.Hour
+
+	PaymentJobStatusQueued     = "queued"
+	PaymentJobStatusProcessing = "processing"
+	PaymentJobStatusCompleted  = "completed"
+	PaymentJobStatusFailed     = "failed"
+
+	// Outbound webhook events of payment jobs
+	PaymentJobEventCompleted = "payment_job.completed"
+	PaymentJobEventFailed    = "payment_job.failed"
+)
+
+var (
+	// ErrPaymentJobNotFound is returned when a job does not exist for the tenant
+	ErrPaymentJobNotFound = errors.New("payment job not found")
+
+	// ErrPaymentJobQueueFull is returned when the queue cannot take another job
+	ErrPaymentJobQueueFull = errors.New("payment job queue is full")
+
+	// ErrPaymentJobInvalid is returned for an invalid job webhook URL
+	ErrPaymentJobInvalid = errors.New("invalid payment job")
+)
+
+// PaymentJob is a payment processed in the background. A completed job holds the provider's
+// response, which may still be a declined payment; a failed job could not be processed.
+type PaymentJob struct {
+	ID          string           `json:"id"`
+	TenantID    int              `json:"-"`
+	Provider    string           `json:"provider"`
+	Environment string           `json:"environment"`
+	Status      string           `json:"status"`
+	Result      *PaymentResponse `json:"result,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	WebhookURL  string           `json:"webhookUrl,omitempty"`
+	// WebhookSecret signs the job's webhooks. It is only returned when the job is submitted.
+	WebhookSecret string     `json:"webhookSecret,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+}
+
+// PaymentJobEvent is the body of a payment job webhook
+type PaymentJobEvent struct {
+	Type      string     `json:"type"`
+	Data      PaymentJob `json:"data"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// PaymentJobStore keeps the state of payment jobs. Payment requests are not stored: they hold
+// card data and only live in the queue's memory until a worker takes them.
+type PaymentJobStore interface {
+	// Create stores a queued job
+	Create(ctx context.Context, job *PaymentJob) error
+
+	// MarkProcessing records that a worker took the job
+	MarkProcessing(ctx context.Context, id string, startedAt time.Time) error
+
+	// Finish records the job's final status, result or error and CompletedAt
+	Finish(ctx context.Context, job *PaymentJob) error
+
+	// Get returns a job of the tenant, or ErrPaymentJobNotFound
+	Get(ctx context.Context, tenantID int, id string) (*PaymentJob, error)
+
+	// FailUnfinished fails the queued and processing jobs created before createdBefore, whose
+	// requests were lost with the memory of the instance that queued them, and returns how
+	// many there were
+	FailUnfinished(ctx context.Context, reason string, createdBefore, now time.Time) (int, error)
+}
+
+// queuedPaymentJob is a job waiting for a worker, with the request it processes
+type queuedPaymentJob struct {
+	job     PaymentJob
+	request PaymentRequest
+}
+
+// PaymentJobQueue processes payments submitted in async mode with a pool of workers. The
+// outcome of a job is stored for polling and, when the job has a webhook URL, posted through
+// the WebhookDispatcher.
+type PaymentJobQueue struct {
+	store         PaymentJobStore
+	createPayment func(ctx context.Context, environment, providerName string, request PaymentRequest) (*PaymentResponse, error)
+	dispatcher    *WebhookDispatcher
+	queue         chan queuedPaymentJob
+	workers       int
+	now           func() time.Time
+}
+
+// NewPaymentJobQueue creates a queue whose jobs are processed by createPayment, usually
+// PaymentService.CreatePayment. The number of workers is read from PAYMENT_JOB_WORKERS
+// (default 4) and the number of jobs that can wait from PAYMENT_JOB_QUEUE_SIZE (default 1000).
+// dispatcher may be nil, in which case no webhooks are sent.
+func NewPaymentJobQueue(store PaymentJobStore, createPayment func(ctx context.Context, environment, providerName string, request PaymentRequest) (*PaymentResponse, error), dispatcher *WebhookDispatcher) *PaymentJobQueue {
+	workers := config.GetIntEnv("PAYMENT_JOB_WORKERS", defaultPaymentJobWorkers)
+	if workers <= 0 {
+		workers = defaultPaymentJobWorkers
+	}
+	queueSize := config.GetIntEnv("PAYMENT_JOB_QUEUE_SIZE", defaultPaymentJobQueueSize)
+	if queueSize <= 0 {
+		queueSize = defaultPaymentJobQueueSize
+	}
+
+	return &PaymentJobQueue{
+		store:         store,
+		createPayment: createPayment,
+		dispatcher:    dispatcher,
+		queue:         make(chan queuedPaymentJob, queueSize),
+		workers:       workers,
+		now:           time.Now,
+	}
+}
+
+// Submit queues a payment of the tenant in ctx and returns the queued job. A webhookURL, when
+// set, receives the job once it is finished, signed with the returned WebhookSecret.
+func (q *PaymentJobQueue) Submit(ctx context.Context, environment, providerName string, request PaymentRequest, webhookURL string) (*PaymentJob, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job := PaymentJob{
+		ID:          "pj" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		Status:      PaymentJobStatusQueued,
+		CreatedAt:   q.now(),
+	}
+	if webhookURL != "" {
+		if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("%w: webhook URL must be an http(s) URL", ErrPaymentJobInvalid)
+		}
+		secret := make([]byte, 24)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		job.WebhookURL, job.WebhookSecret = webhookURL, "whsec_"+hex.EncodeToString(secret)
+	}
+
+	// Refuse the job before storing it when no worker could take it
+	if len(q.queue) == cap(q.queue) {
+		return nil, ErrPaymentJobQueueFull
+	}
+	if err := q.store.Create(ctx, &job); err != nil {
+		return nil, err
+	}
+	select {
+	case q.queue <- queuedPaymentJob{job: job, request: request}:
+	default:
+		// Filled up since the check: the client gets the error, not a webhook
+		job.WebhookURL = ""
+		q.finish(context.WithoutCancel(ctx), job, nil, ErrPaymentJobQueueFull)
+		return nil, ErrPaymentJobQueueFull
+	}
+	return &job, nil
+}
+
+// Get returns a job of the tenant in ctx. The webhook secret is not returned.
+func (q *PaymentJobQueue) Get(ctx context.Context, id string) (*PaymentJob, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	job, err := q.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	job.WebhookSecret = ""
+	return job, nil
+}
+
+// Start runs the workers until ctx is done. Jobs still queued then are lost with the process;
+// every interval, jobs left unfinished for paymentJobStaleAfter are failed so their clients
+// stop polling. Instances sharing the table do not fail each other's recent jobs.
+func (q *PaymentJobQueue) Start(ctx context.Context, interval time.Duration) {
+	for range q.workers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item := <-q.queue:
+					q.process(ctx, item)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		q.failStale(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// failStale fails the jobs left unfinished for paymentJobStaleAfter
+func (q *PaymentJobQueue) failStale(ctx context.Context) {
+	now := q.now()
+	failed, err := q.store.FailUnfinished(ctx, "payment job was interrupted, submit the payment again", now.Add(-paymentJobStaleAfter), now)
+	if err != nil {
+		logger.Warn("Failed to fail stale payment jobs", logger.LogContext{
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+	} else if failed > 0 {
+		logger.Info(fmt.Sprintf("Failed %d interrupted payment jobs", failed))
+	}
+}
+
+// process runs one job as a payment of its tenant and records its outcome
+func (q *PaymentJobQueue) process(ctx context.Context, item queuedPaymentJob) {
+	job := item.job
+	startedAt := q.now()
+	job.Status, job.StartedAt = PaymentJobStatusProcessing, &startedAt
+	if err := q.store.MarkProcessing(ctx, job.ID, startedAt); err != nil {
+		logger.Warn("Failed to mark payment job as processing", logger.LogContext{
+			TenantID: strconv.Itoa(job.TenantID),
+			Provider: job.Provider,
+			Fields: map[string]any{
+				"job_id": job.ID,
+				"error":  err.Error(),
+			},
+		})
+	}
+
+	// The job runs detached from the worker's shutdown, so a payment sent to the provider is
+	// recorded
+	paymentCtx, cancel := context.WithTimeout(context.WithValue(context.WithoutCancel(ctx), tenantctx.IDKey, strconv.Itoa(job.TenantID)), paymentJobTimeout)
+	defer cancel()
+	resp, err := q.createPayment(paymentCtx, job.Environment, job.Provider, item.request)
+	q.finish(paymentCtx, job, resp, err)
+}
+
+// finish stores the outcome of a job and queues its webhook
+func (q *PaymentJobQueue) finish(ctx context.Context, job PaymentJob, resp *PaymentResponse, opErr error) {
+	completedAt := q.now()
+	job.CompletedAt = &completedAt
+	event := PaymentJobEventCompleted
+	if opErr != nil {
+		job.Status, job.Error = PaymentJobStatusFailed, opErr.Error()
+		event = PaymentJobEventFailed
+	} else {
+		job.Status, job.Result = PaymentJobStatusCompleted, resp
+	}
+
+	if err := q.store.Finish(ctx, &job); err != nil {
+		logger.Warn("Failed to record payment job outcome", logger.LogContext{
+			TenantID: strconv.Itoa(job.TenantID),
+			Provider: job.Provider,
+			Fields: map[string]any{
+				"job_id": job.ID,
+				"status": job.Status,
+				"error":  err.Error(),
+			},
+		})
+	}
+
+	if q.dispatcher == nil || job.WebhookURL == "" {
+		return
+	}
+	delivery, err := paymentJobWebhookDelivery(job, event)
+	if err == nil {
+		err = q.dispatcher.Enqueue(ctx, delivery)
+	}
+	if err != nil {
+		logger.Warn("Failed to queue payment job webhook", logger.LogContext{
+			TenantID: strconv.Itoa(job.TenantID),
+			Provider: job.Provider,
+			Fields: map[string]any{
+				"job_id": job.ID,
+				"event":  event,
+				"error":  err.Error(),
+			},
+		})
+	}
+}
+
+// paymentJobWebhookDelivery builds the signed delivery of event for job. It is signed the way
+// payment link webhooks are; the webhook secret is not part of the body.
+func paymentJobWebhookDelivery(job PaymentJob, event string) (WebhookDelivery, error) {
+	secret := job.WebhookSecret
+	job.WebhookSecret = ""
+	body, err := json.Marshal(PaymentJobEvent{Type: event, Data: job, CreatedAt: time.Now()})
+	if err != nil {
+		return WebhookDelivery{}, fmt.Errorf("failed to encode payment job event: %w", err)
+	}
+	return WebhookDelivery{
+		TenantID:  job.TenantID,
+		Event:     event,
+		URL:       job.WebhookURL,
+		Body:      body,
+		Signature: SignPaymentLinkWebhook(secret, body),
+	}, nil
+}
+
+// PostgresPaymentJobStore keeps payment jobs in the payment_jobs table.
+type PostgresPaymentJobStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPaymentJobStore creates a store over the shared *sql.DB connection.
+func NewPostgresPaymentJobStore(db *sql.DB) *PostgresPaymentJobStore {
+	return &PostgresPaymentJobStore{db: db}
+}
+
+// Create inserts a queued job
+func (r *PostgresPaymentJobStore) Create(ctx context.Context, job *PaymentJob) error {
+	query := `
+		INSERT INTO payment_jobs (id, tenant_id, provider, environment, status, webhook_url, webhook_secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	if _, err := r.db.ExecContext(ctx, query, job.ID, job.TenantID, job.Provider, job.Environment, job.Status,
+		nullString(job.WebhookURL), nullString(job.WebhookSecret), job.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create payment job: %w", err)
+	}
+	return nil
+}
+
+// MarkProcessing moves a queued job to processing
+func (r *PostgresPaymentJobStore) MarkProcessing(ctx context.Context, id string, startedAt time.Time) error {
+	query := `UPDATE payment_jobs SET status = $2, started_at = $3 WHERE id = $1 AND status = $4`
+	if _, err := r.db.ExecContext(ctx, query, id, PaymentJobStatusProcessing, startedAt, PaymentJobStatusQueued); err != nil {
+		return fmt.Errorf("failed to mark payment job as processing: %w", err)
+	}
+	return nil
+}
+
+// Finish records the outcome of a job
+func (r *PostgresPaymentJobStore) Finish(ctx context.Context, job *PaymentJob) error {
+	var result any
+	if job.Result != nil {
+		raw, err := json.Marshal(job.Result)
+		if err != nil {
+			return fmt.Errorf("failed to encode payment job result: %w", err)
+		}
+		result = raw
+	}
+
+	query := `UPDATE payment_jobs SET status = $2, result = $3, error = $4, completed_at = $5 WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, job.ID, job.Status, result, nullString(job.Error), job.CompletedAt); err != nil {
+		return fmt.Errorf("failed to finish payment job: %w", err)
+	}
+	return nil
+}
+
+// Get returns a job, scoped to the tenant
+func (r *PostgresPaymentJobStore) Get(ctx context.Context, tenantID int, id string) (*PaymentJob, error) {
+	query := `
+		SELECT id, tenant_id, provider, environment, status, result, COALESCE(error, ''), COALESCE(webhook_url, ''),
+			created_at, started_at, completed_at
+		FROM payment_jobs
+		WHERE id = $1 AND tenant_id = $2`
+
+	var (
+		job                    PaymentJob
+		result                 []byte
+		startedAt, completedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, id, tenantID).Scan(&job.ID, &job.TenantID, &job.Provider, &job.Environment,
+		&job.Status, &result, &job.Error, &job.WebhookURL, &job.CreatedAt, &startedAt, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment job: %w", err)
+	}
+
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &job.Result); err != nil {
+			return nil, fmt.Errorf("failed to decode payment job result: %w", err)
+		}
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return &job, nil
+}
+
+// FailUnfinished fails the queued or processing jobs created before createdBefore
+func (r *PostgresPaymentJobStore) FailUnfinished(ctx context.Context, reason string, createdBefore, now time.Time) (int, error) {
+	query := `UPDATE payment_jobs SET status = $1, error = $2, completed_at = $3 WHERE status IN ($4, $5) AND created_at < $6`
+	result, err := r.db.ExecContext(ctx, query, PaymentJobStatusFailed, reason, now, PaymentJobStatusQueued, PaymentJobStatusProcessing, createdBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail unfinished payment jobs: %w", err)
+	}
+	failed, err := result.RowsAffected()
+	return int(failed), err
+}
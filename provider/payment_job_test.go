@@ -0,0 +1,212 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryPaymentJobStore is an in-memory PaymentJobStore for tests
+type memoryPaymentJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]PaymentJob
+}
+
+func newMemoryPaymentJobStore() *memoryPaymentJobStore {
+	return &memoryPaymentJobStore{jobs: make(map[string]PaymentJob)}
+}
+
+func (m *memoryPaymentJobStore) Create(_ context.Context, job *PaymentJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+func (m *memoryPaymentJobStore) MarkProcessing(_ context.Context, id string, startedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.Status, job.StartedAt = PaymentJobStatusProcessing, &startedAt
+	m.jobs[id] = job
+	return nil
+}
+
+func (m *memoryPaymentJobStore) Finish(_ context.Context, job *PaymentJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.jobs[job.ID]
+	stored.Status, stored.Result, stored.Error, stored.CompletedAt = job.Status, job.Result, job.Error, job.CompletedAt
+	m.jobs[job.ID] = stored
+	return nil
+}
+
+func (m *memoryPaymentJobStore) Get(_ context.Context, tenantID int, id string) (*PaymentJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok || job.TenantID != tenantID {
+		return nil, ErrPaymentJobNotFound
+	}
+	return &job, nil
+}
+
+func (m *memoryPaymentJobStore) FailUnfinished(_ context.Context, reason string, createdBefore, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failed := 0
+	for id, job := range m.jobs {
+		if (job.Status == PaymentJobStatusQueued || job.Status == PaymentJobStatusProcessing) && job.CreatedAt.Before(createdBefore) {
+			job.Status, job.Error, job.CompletedAt = PaymentJobStatusFailed, reason, &now
+			m.jobs[id] = job
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+func newTestPaymentJobQueue(store PaymentJobStore, createPayment func(context.Context, string, string, PaymentRequest) (*PaymentResponse, error), dispatcher *WebhookDispatcher, queueSize int) *PaymentJobQueue {
+	return &PaymentJobQueue{
+		store:         store,
+		createPayment: createPayment,
+		dispatcher:    dispatcher,
+		queue:         make(chan queuedPaymentJob, queueSize),
+		workers:       2,
+		now:           time.Now,
+	}
+}
+
+// waitForPaymentJob polls the job until it is finished
+func waitForPaymentJob(t *testing.T, queue *PaymentJobQueue, ctx context.Context, id string) *PaymentJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := queue.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if job.Status == PaymentJobStatusCompleted || job.Status == PaymentJobStatusFailed {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Payment job %s was not finished", id)
+	return nil
+}
+
+func TestPaymentJobQueue_ProcessesAndNotifies(t *testing.T) {
+	type received struct {
+		body      []byte
+		signature string
+	}
+	webhooks := make(chan received, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		webhooks <- received{body: body, signature: r.Header.Get(PaymentLinkSignatureHeader)}
+	}))
+	defer server.Close()
+	dispatcher, _, _ := newTestWebhookDispatcher(server.Client(), 3)
+
+	queue := newTestPaymentJobQueue(newMemoryPaymentJobStore(), func(ctx context.Context, environment, providerName string, request PaymentRequest) (*PaymentResponse, error) {
+		// Workers run the payment as the tenant that submitted it
+		if tenantID, err := getTenantIDFromContext(ctx); err != nil || tenantID != 9147 {
+			return nil, errors.New("payment ran without its tenant")
+		}
+		if request.Amount == 13 {
+			return nil, errors.New("provider unavailable")
+		}
+		return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_1", Amount: request.Amount, Currency: request.Currency}, nil
+	}, dispatcher, 10)
+
+	runCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go queue.Start(runCtx, time.Hour)
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9147")
+	request := riskRequest()
+	submitted, err := queue.Submit(ctx, "sandbox", "iyzico", request, server.URL+"/hooks")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if submitted.Status != PaymentJobStatusQueued || submitted.WebhookSecret == "" {
+		t.Fatalf("Expected a queued job with its webhook secret, got %+v", submitted)
+	}
+
+	job := waitForPaymentJob(t, queue, ctx, submitted.ID)
+	if job.Status != PaymentJobStatusCompleted || job.Result == nil || job.Result.PaymentID != "pay_1" || job.CompletedAt == nil {
+		t.Errorf("Expected the completed job with its payment, got %+v", job)
+	}
+	if job.WebhookSecret != "" {
+		t.Error("Expected the webhook secret not to be returned when polling")
+	}
+
+	select {
+	case webhook := <-webhooks:
+		if webhook.signature != SignPaymentLinkWebhook(submitted.WebhookSecret, webhook.body) {
+			t.Error("Expected the webhook to be signed with the job's secret")
+		}
+		var event PaymentJobEvent
+		if err := json.Unmarshal(webhook.body, &event); err != nil || event.Type != PaymentJobEventCompleted || event.Data.ID != submitted.ID || event.Data.WebhookSecret != "" {
+			t.Errorf("Unexpected webhook body: %s", webhook.body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the job's webhook")
+	}
+
+	// A payment that cannot be processed fails its job
+	request.Amount = 13
+	submitted, err = queue.Submit(ctx, "sandbox", "iyzico", request, "")
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job := waitForPaymentJob(t, queue, ctx, submitted.ID); job.Status != PaymentJobStatusFailed || job.Error != "provider unavailable" {
+		t.Errorf("Expected the failed job with its error, got %+v", job)
+	}
+
+	// Another tenant does not see the job
+	otherTenant := context.WithValue(context.Background(), tenantctx.IDKey, "9148")
+	if _, err := queue.Get(otherTenant, submitted.ID); !errors.Is(err, ErrPaymentJobNotFound) {
+		t.Errorf("Expected ErrPaymentJobNotFound for another tenant, got %v", err)
+	}
+}
+
+func TestPaymentJobQueue_Submit_Rejected(t *testing.T) {
+	queue := newTestPaymentJobQueue(newMemoryPaymentJobStore(), nil, nil, 1)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9147")
+
+	if _, err := queue.Submit(ctx, "sandbox", "iyzico", riskRequest(), "ftp://example.com"); !errors.Is(err, ErrPaymentJobInvalid) {
+		t.Errorf("Expected ErrPaymentJobInvalid for a non-http webhook URL, got %v", err)
+	}
+	if _, err := queue.Submit(ctx, "sandbox", "iyzico", riskRequest(), ""); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	// Without workers the queue is full after one job
+	if _, err := queue.Submit(ctx, "sandbox", "iyzico", riskRequest(), ""); !errors.Is(err, ErrPaymentJobQueueFull) {
+		t.Errorf("Expected ErrPaymentJobQueueFull, got %v", err)
+	}
+}
+
+func TestPaymentJobQueue_FailsStaleJobs(t *testing.T) {
+	store := newMemoryPaymentJobStore()
+	now := time.Now()
+	store.jobs["pj_lost"] = PaymentJob{ID: "pj_lost", TenantID: 9147, Status: PaymentJobStatusProcessing, CreatedAt: now.Add(-2 * time.Hour)}
+	store.jobs["pj_recent"] = PaymentJob{ID: "pj_recent", TenantID: 9147, Status: PaymentJobStatusQueued, CreatedAt: now.Add(-time.Minute)}
+
+	queue := newTestPaymentJobQueue(store, nil, nil, 1)
+	queue.failStale(context.Background())
+
+	if job := store.jobs["pj_lost"]; job.Status != PaymentJobStatusFailed || job.Error == "" {
+		t.Errorf("Expected the stale job to fail, got %+v", job)
+	}
+	if job := store.jobs["pj_recent"]; job.Status != PaymentJobStatusQueued {
+		t.Errorf("Expected a recent job, possibly queued by another instance, to be left alone, got %+v", job)
+	}
+}
@@ -0,0 +1,626 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// Payment links are shareable URLs of a GoPay-hosted checkout page. The customer enters the
+// card on the page and the payment is made through the provider the tenant chose for the link.
+// A link is paid at most once: a payment attempt claims it, and a claimed link cannot be paid
+// again until the attempt fails or its lease runs out.
+
+const (
+	PaymentLinkStatusActive = "active"
+	// PaymentLinkStatusProcessing is a link with a payment attempt in progress, e.g. in 3D
+	PaymentLinkStatusProcessing = "processing"
+	PaymentLinkStatusPaid       = "paid"
+	PaymentLinkStatusExpired    = "expired"
+	PaymentLinkStatusCancelled  = "cancelled"
+
+	// Events sent to the link's webhook URL
+	PaymentLinkEventPaid          = "payment_link.paid"
+	PaymentLinkEventPaymentFailed = "payment_link.payment_failed"
+	PaymentLinkEventExpired       = "payment_link.expired"
+	PaymentLinkEventCancelled     = "payment_link.cancelled"
+
+	// DefaultPaymentLinkTTL is how long a link can be paid when it is created without expiresAt
+	DefaultPaymentLinkTTL = 24 * time.Hour
+	// MaxPaymentLinkTTL is the latest expiry a link can be created with
+	MaxPaymentLinkTTL = 30 * 24 * time.Hour
+
+	// paymentLinkClaimLease is how long a payment attempt holds a link. It covers a 3D
+	// challenge; an abandoned attempt frees the link once it runs out.
+	paymentLinkClaimLease = 15 * time.Minute
+	paymentLinkBatchSize  = 100
+)
+
+var (
+	// ErrPaymentLinkNotFound is returned for a link that does not exist (for the tenant)
+	ErrPaymentLinkNotFound = errors.New("payment link not found")
+	// ErrPaymentLinkInvalid is returned for a link request that cannot be created
+	ErrPaymentLinkInvalid = errors.New("invalid payment link")
+	// ErrPaymentLinkUnavailable is returned when a link is paid, expired, cancelled or has a
+	// payment attempt in progress
+	ErrPaymentLinkUnavailable = errors.New("payment link cannot be paid")
+)
+
+// PaymentLink is a shareable, expiring and single-use request for a payment
+type PaymentLink struct {
+	ID          string  `json:"id"`
+	TenantID    int     `json:"tenantId"`
+	Provider    string  `json:"provider"`
+	Environment string  `json:"environment"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Description string  `json:"description,omitempty"`
+	Status      string  `json:"status"`
+	// URL is the checkout page to share with the customer
+	URL        string `json:"url"`
+	PaymentID  string `json:"paymentId,omitempty"`
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	// WebhookSecret signs the link's webhooks. It is only returned when the link is created.
+	WebhookSecret string     `json:"webhookSecret,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	PaidAt        *time.Time `json:"paidAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// CreatePaymentLinkRequest creates a link. It expires after DefaultPaymentLinkTTL unless
+// ExpiresAt is set.
+type CreatePaymentLinkRequest struct {
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Description string     `json:"description,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	WebhookURL  string     `json:"webhookUrl,omitempty"`
+}
+
+// PaymentLinkCheckout is what the customer enters on the checkout page
+type PaymentLinkCheckout struct {
+	Customer        Customer
+	CardInfo        CardInfo
+	ClientIP        string
+	ClientUserAgent string
+}
+
+// PaymentLinkStore persists payment links
+type PaymentLinkStore interface {
+	Create(ctx context.Context, link *PaymentLink) error
+
+	// Get returns a link of the tenant; a tenantID of 0 returns the link of any tenant, for
+	// the public checkout page
+	Get(ctx context.Context, tenantID int, id string) (*PaymentLink, error)
+	List(ctx context.Context, tenantID int) ([]PaymentLink, error)
+
+	// Claim moves an unexpired active link, or a processing link whose lease ran out, to
+	// processing until leaseUntil and returns it. It returns ErrPaymentLinkUnavailable when
+	// the link cannot be claimed.
+	Claim(ctx context.Context, id string, now, leaseUntil time.Time) (*PaymentLink, error)
+
+	// Attempted records the payment of a claimed link that is waiting for the customer
+	Attempted(ctx context.Context, id, paymentID string) error
+
+	// Paid marks a link paid by paymentID
+	Paid(ctx context.Context, id, paymentID string, paidAt time.Time) error
+
+	// Release makes a link payable again after a failed attempt
+	Release(ctx context.Context, id, lastError string) error
+
+	// Cancel cancels an active link of the tenant. It returns ErrPaymentLinkUnavailable when
+	// the link cannot be cancelled any more.
+	Cancel(ctx context.Context, tenantID int, id string) error
+
+	// ExpireDue marks up to limit active links that expired at now as expired and returns them
+	ExpireDue(ctx context.Context, now time.Time, limit int) ([]PaymentLink, error)
+}
+
+// PaymentLinkPayer makes and checks link payments; PaymentService implements it
+type PaymentLinkPayer interface {
+	CreatePayment(ctx context.Context, environment, providerName string, request PaymentRequest) (*PaymentResponse, error)
+	GetPaymentStatus(ctx context.Context, environment, providerName string, request GetPaymentStatusRequest) (*PaymentResponse, error)
+}
+
+// PaymentLinkService creates payment links and pays them from the checkout page
+type PaymentLinkService struct {
+	store    PaymentLinkStore
+	payer    PaymentLinkPayer
+	notifier PaymentLinkNotifier
+	baseURL  string
+	now      func() time.Time
+}
+
+// NewPaymentLinkService creates a payment link service. Link URLs are built from APP_URL.
+func NewPaymentLinkService(store PaymentLinkStore, payer PaymentLinkPayer, notifier PaymentLinkNotifier) *PaymentLinkService {
+	return &PaymentLinkService{
+		store:    store,
+		payer:    payer,
+		notifier: notifier,
+		baseURL:  strings.TrimRight(config.GetEnv("APP_URL", "http://localhost:9999"), "/"),
+		now:      time.Now,
+	}
+}
+
+// CreateLink creates a payment link paid through providerName in environment
+func (s *PaymentLinkService) CreateLink(ctx context.Context, environment, providerName string, request CreatePaymentLinkRequest) (*PaymentLink, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := GetProvider(tenantID, providerName, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrPaymentLinkInvalid)
+	}
+	currency := strings.ToUpper(strings.TrimSpace(request.Currency))
+	if !IsCurrencySupported(provider, currency) {
+		return nil, fmt.Errorf("%w: currency %s is not supported by %s", ErrPaymentLinkInvalid, currency, providerName)
+	}
+
+	now := s.now()
+	expiresAt := now.Add(DefaultPaymentLinkTTL)
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(MaxPaymentLinkTTL)) {
+		return nil, fmt.Errorf("%w: expiresAt must be in the next %d days", ErrPaymentLinkInvalid, int(MaxPaymentLinkTTL.Hours()/24))
+	}
+
+	link := &PaymentLink{
+		ID:          "pl" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		Amount:      request.Amount,
+		Currency:    currency,
+		Description: request.Description,
+		Status:      PaymentLinkStatusActive,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   now,
+	}
+	if request.WebhookURL != "" {
+		if u, err := url.Parse(request.WebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("%w: webhookUrl must be an http(s) URL", ErrPaymentLinkInvalid)
+		}
+		secret := make([]byte, 24)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		link.WebhookURL, link.WebhookSecret = request.WebhookURL, "whsec_"+hex.EncodeToString(secret)
+	}
+
+	if err := s.store.Create(ctx, link); err != nil {
+		return nil, err
+	}
+	link.URL = s.linkURL(link.ID)
+	return link, nil
+}
+
+// GetLink returns one of the tenant's links
+func (s *PaymentLinkService) GetLink(ctx context.Context, id string) (*PaymentLink, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.present(link), nil
+}
+
+// ListLinks returns the tenant's links
+func (s *PaymentLinkService) ListLinks(ctx context.Context) ([]PaymentLink, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.store.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		s.present(&links[i])
+	}
+	return links, nil
+}
+
+// CancelLink cancels one of the tenant's active links
+func (s *PaymentLinkService) CancelLink(ctx context.Context, id string) (*PaymentLink, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Cancel(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	link, err := s.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(ctx, link, PaymentLinkEventCancelled)
+	return s.present(link), nil
+}
+
+// CheckoutLink returns a link for the public checkout page
+func (s *PaymentLinkService) CheckoutLink(ctx context.Context, id string) (*PaymentLink, error) {
+	link, err := s.store.Get(ctx, 0, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.present(link), nil
+}
+
+// PayLink pays a link with what the customer entered on the checkout page. The returned
+// response is nil when the link turned out to be paid by an earlier attempt.
+func (s *PaymentLinkService) PayLink(ctx context.Context, id string, checkout PaymentLinkCheckout) (*PaymentLink, *PaymentResponse, error) {
+	now := s.now()
+	link, err := s.store.Claim(ctx, id, now, now.Add(paymentLinkClaimLease))
+	if err != nil {
+		return nil, nil, err
+	}
+	tenantCtx := context.WithValue(ctx, tenantctx.IDKey, strconv.Itoa(link.TenantID))
+
+	// An abandoned attempt may still have been paid, e.g. on a hosted checkout page
+	if link.PaymentID != "" {
+		if paid, err := s.settle(tenantCtx, link, link.PaymentID); err != nil || paid {
+			return s.present(link), nil, err
+		}
+	}
+
+	customer := checkout.Customer
+	customer.IPAddress = checkout.ClientIP
+	response, err := s.payer.CreatePayment(tenantCtx, link.Environment, link.Provider, PaymentRequest{
+		Amount:          link.Amount,
+		Currency:        link.Currency,
+		Description:     link.Description,
+		Customer:        customer,
+		CardInfo:        checkout.CardInfo,
+		CallbackURL:     s.linkURL(link.ID) + "/complete",
+		Use3D:           true,
+		ConversationID:  link.ID,
+		ClientIP:        checkout.ClientIP,
+		ClientUserAgent: checkout.ClientUserAgent,
+		PaymentLinkID:   link.ID,
+	})
+	if err == nil && response == nil {
+		err = errors.New("provider returned no response")
+	}
+	if err != nil {
+		s.fail(ctx, link, err.Error())
+		return s.present(link), nil, err
+	}
+
+	switch {
+	case response.Status == StatusSuccessful:
+		s.paid(ctx, link, response.PaymentID)
+	case response.Outcome == OutcomeFailed:
+		s.fail(ctx, link, response.Message)
+	default:
+		// Waiting for 3D, the hosted checkout page or the provider; CompleteLink settles it
+		link.PaymentID = response.PaymentID
+		if err := s.store.Attempted(ctx, link.ID, response.PaymentID); err != nil {
+			s.warn(link, "Failed to record payment link attempt", err)
+		}
+	}
+	return s.present(link), response, nil
+}
+
+// CompleteLink settles a link's payment when the customer returns from 3D or the provider's
+// page. The payment status is asked from the provider, the redirect is not trusted.
+func (s *PaymentLinkService) CompleteLink(ctx context.Context, id, paymentID string) (*PaymentLink, error) {
+	link, err := s.store.Get(ctx, 0, id)
+	if err != nil {
+		return nil, err
+	}
+	if link.Status != PaymentLinkStatusProcessing {
+		return s.present(link), nil
+	}
+	// Only the attempt that claimed the link can complete it
+	if link.PaymentID != "" {
+		paymentID = link.PaymentID
+	}
+	if paymentID == "" {
+		return s.present(link), nil
+	}
+
+	tenantCtx := context.WithValue(ctx, tenantctx.IDKey, strconv.Itoa(link.TenantID))
+	if _, err := s.settle(tenantCtx, link, paymentID); err != nil {
+		return nil, err
+	}
+	return s.present(link), nil
+}
+
+// settle looks up the payment of a processing link and marks the link paid or payable again
+// when the payment is final. It reports whether the link is paid.
+func (s *PaymentLinkService) settle(ctx context.Context, link *PaymentLink, paymentID string) (bool, error) {
+	status, err := s.payer.GetPaymentStatus(ctx, link.Environment, link.Provider, GetPaymentStatusRequest{PaymentID: paymentID})
+	if err != nil {
+		return false, fmt.Errorf("failed to get payment status: %w", err)
+	}
+	switch status.Status {
+	case StatusSuccessful:
+		s.paid(ctx, link, paymentID)
+		return true, nil
+	case StatusFailed, StatusCancelled:
+		s.fail(ctx, link, status.Message)
+	}
+	return false, nil
+}
+
+func (s *PaymentLinkService) paid(ctx context.Context, link *PaymentLink, paymentID string) {
+	now := s.now()
+	link.Status, link.PaymentID, link.PaidAt, link.LastError = PaymentLinkStatusPaid, paymentID, &now, ""
+	if err := s.store.Paid(ctx, link.ID, paymentID, now); err != nil {
+		s.warn(link, "Failed to mark payment link paid", err)
+	}
+	s.notify(ctx, link, PaymentLinkEventPaid)
+}
+
+func (s *PaymentLinkService) fail(ctx context.Context, link *PaymentLink, lastError string) {
+	if lastError == "" {
+		lastError = "payment failed"
+	}
+	link.Status, link.PaymentID, link.LastError = PaymentLinkStatusActive, "", lastError
+	if err := s.store.Release(ctx, link.ID, lastError); err != nil {
+		s.warn(link, "Failed to release payment link", err)
+	}
+	s.notify(ctx, link, PaymentLinkEventPaymentFailed)
+}
+
+// RunExpiry expires the links that passed their expiry and returns how many it expired
+func (s *PaymentLinkService) RunExpiry(ctx context.Context) (int, error) {
+	links, err := s.store.ExpireDue(ctx, s.now(), paymentLinkBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range links {
+		s.notify(ctx, &links[i], PaymentLinkEventExpired)
+	}
+	return len(links), nil
+}
+
+// Start expires due links every interval until ctx is done
+func (s *PaymentLinkService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			if _, err := s.RunExpiry(runCtx); err != nil {
+				logger.Warn("Failed to expire payment links", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *PaymentLinkService) notify(ctx context.Context, link *PaymentLink, event string) {
+	if s.notifier != nil && link.WebhookURL != "" {
+		s.notifier.Notify(ctx, *link, event)
+	}
+}
+
+// present sets the link URL, hides the webhook secret and reports an unpaid link past its
+// expiry as expired before the expiry job gets to it
+func (s *PaymentLinkService) present(link *PaymentLink) *PaymentLink {
+	link.URL = s.linkURL(link.ID)
+	link.WebhookSecret = ""
+	if link.Status == PaymentLinkStatusActive && !s.now().Before(link.ExpiresAt) {
+		link.Status = PaymentLinkStatusExpired
+	}
+	return link
+}
+
+func (s *PaymentLinkService) linkURL(id string) string {
+	return s.baseURL + "/pay/" + id
+}
+
+func (s *PaymentLinkService) warn(link *PaymentLink, message string, err error) {
+	logger.Warn(message, logger.LogContext{
+		TenantID: strconv.Itoa(link.TenantID),
+		Provider: link.Provider,
+		Fields: map[string]any{
+			"payment_link_id": link.ID,
+			"error":           err.Error(),
+		},
+	})
+}
+
+// PostgresPaymentLinkStore keeps payment links in the payment_links table
+type PostgresPaymentLinkStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPaymentLinkStore creates a store over the shared *sql.DB connection
+func NewPostgresPaymentLinkStore(db *sql.DB) *PostgresPaymentLinkStore {
+	return &PostgresPaymentLinkStore{db: db}
+}
+
+const paymentLinkColumns = `id, tenant_id, provider, environment, amount, currency, COALESCE(description, ''), status,
+		COALESCE(payment_id, ''), COALESCE(webhook_url, ''), COALESCE(webhook_secret, ''), COALESCE(last_error, ''),
+		expires_at, paid_at, created_at`
+
+// Create inserts a link
+func (r *PostgresPaymentLinkStore) Create(ctx context.Context, link *PaymentLink) error {
+	query := `
+		INSERT INTO payment_links (id, tenant_id, provider, environment, amount, currency, description, status, webhook_url, webhook_secret, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := r.db.ExecContext(ctx, query, link.ID, link.TenantID, link.Provider, link.Environment, link.Amount, link.Currency,
+		nullString(link.Description), link.Status, nullString(link.WebhookURL), nullString(link.WebhookSecret), link.ExpiresAt, link.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payment link: %w", err)
+	}
+	return nil
+}
+
+// Get returns a link, scoped to the tenant unless tenantID is 0
+func (r *PostgresPaymentLinkStore) Get(ctx context.Context, tenantID int, id string) (*PaymentLink, error) {
+	query := `SELECT ` + paymentLinkColumns + ` FROM payment_links WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	link, err := scanPaymentLink(r.db.QueryRowContext(ctx, query, id, tenantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment link: %w", err)
+	}
+	return link, nil
+}
+
+// List returns the tenant's links, newest first
+func (r *PostgresPaymentLinkStore) List(ctx context.Context, tenantID int) ([]PaymentLink, error) {
+	query := `SELECT ` + paymentLinkColumns + ` FROM payment_links WHERE tenant_id = $1 ORDER BY created_at DESC`
+	return r.query(ctx, query, tenantID)
+}
+
+// Claim claims a link for a payment attempt. The conditional update lets only one of
+// concurrent attempts through.
+func (r *PostgresPaymentLinkStore) Claim(ctx context.Context, id string, now, leaseUntil time.Time) (*PaymentLink, error) {
+	query := `
+		UPDATE payment_links SET status = $2, claimed_until = $4, updated_at = now()
+		WHERE id = $1 AND expires_at > $3
+		AND (status = $5 OR (status = $2 AND claimed_until <= $3))
+		RETURNING ` + paymentLinkColumns
+
+	link, err := scanPaymentLink(r.db.QueryRowContext(ctx, query, id, PaymentLinkStatusProcessing, now, leaseUntil, PaymentLinkStatusActive))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Tell a missing link from one that cannot be paid
+		if _, getErr := r.Get(ctx, 0, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrPaymentLinkUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim payment link: %w", err)
+	}
+	return link, nil
+}
+
+// Attempted records the payment of a claimed link
+func (r *PostgresPaymentLinkStore) Attempted(ctx context.Context, id, paymentID string) error {
+	query := `UPDATE payment_links SET payment_id = $2, updated_at = now() WHERE id = $1 AND status = $3`
+	if _, err := r.db.ExecContext(ctx, query, id, nullString(paymentID), PaymentLinkStatusProcessing); err != nil {
+		return fmt.Errorf("failed to record payment link attempt: %w", err)
+	}
+	return nil
+}
+
+// Paid marks a link paid
+func (r *PostgresPaymentLinkStore) Paid(ctx context.Context, id, paymentID string, paidAt time.Time) error {
+	query := `
+		UPDATE payment_links
+		SET status = $2, payment_id = $3, paid_at = $4, claimed_until = NULL, last_error = NULL, updated_at = now()
+		WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, PaymentLinkStatusPaid, nullString(paymentID), paidAt); err != nil {
+		return fmt.Errorf("failed to mark payment link paid: %w", err)
+	}
+	return nil
+}
+
+// Release makes a processing link payable again
+func (r *PostgresPaymentLinkStore) Release(ctx context.Context, id, lastError string) error {
+	query := `
+		UPDATE payment_links
+		SET status = $2, payment_id = NULL, claimed_until = NULL, last_error = $3, updated_at = now()
+		WHERE id = $1 AND status = $4`
+	if _, err := r.db.ExecContext(ctx, query, id, PaymentLinkStatusActive, nullString(lastError), PaymentLinkStatusProcessing); err != nil {
+		return fmt.Errorf("failed to release payment link: %w", err)
+	}
+	return nil
+}
+
+// Cancel cancels an active link, scoped to the tenant
+func (r *PostgresPaymentLinkStore) Cancel(ctx context.Context, tenantID int, id string) error {
+	query := `
+		UPDATE payment_links SET status = $3, updated_at = now()
+		WHERE id = $1 AND tenant_id = $2 AND status = $4`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID, PaymentLinkStatusCancelled, PaymentLinkStatusActive)
+	if err != nil {
+		return fmt.Errorf("failed to cancel payment link: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		if _, getErr := r.Get(ctx, tenantID, id); getErr != nil {
+			return getErr
+		}
+		return ErrPaymentLinkUnavailable
+	}
+	return nil
+}
+
+// ExpireDue expires active links past their expiry
+func (r *PostgresPaymentLinkStore) ExpireDue(ctx context.Context, now time.Time, limit int) ([]PaymentLink, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM payment_links
+			WHERE status = $2 AND expires_at <= $1
+			ORDER BY expires_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE payment_links l SET status = $3, updated_at = now()
+		FROM due WHERE l.id = due.id
+		RETURNING l.id, l.tenant_id, l.provider, l.environment, l.amount, l.currency, COALESCE(l.description, ''), l.status,
+			COALESCE(l.payment_id, ''), COALESCE(l.webhook_url, ''), COALESCE(l.webhook_secret, ''), COALESCE(l.last_error, ''),
+			l.expires_at, l.paid_at, l.created_at`
+
+	return r.query(ctx, query, now, PaymentLinkStatusActive, PaymentLinkStatusExpired, limit)
+}
+
+func (r *PostgresPaymentLinkStore) query(ctx context.Context, query string, args ...any) ([]PaymentLink, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []PaymentLink{}
+	for rows.Next() {
+		link, err := scanPaymentLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment link: %w", err)
+		}
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+func scanPaymentLink(row rowScanner) (*PaymentLink, error) {
+	var l PaymentLink
+	var paidAt sql.NullTime
+	err := row.Scan(&l.ID, &l.TenantID, &l.Provider, &l.Environment, &l.Amount, &l.Currency, &l.Description, &l.Status,
+		&l.PaymentID, &l.WebhookURL, &l.WebhookSecret, &l.LastError, &l.ExpiresAt, &paidAt, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if paidAt.Valid {
+		l.PaidAt = &paidAt.Time
+	}
+	return &l, nil
+}
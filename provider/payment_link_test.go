@@ -0,0 +1,393 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryPaymentLinkStore is an in-memory PaymentLinkStore with the claim rules of the
+// Postgres store
+type memoryPaymentLinkStore struct {
+	mu           sync.Mutex
+	links        map[string]PaymentLink
+	claimedUntil map[string]time.Time
+}
+
+func newMemoryPaymentLinkStore() *memoryPaymentLinkStore {
+	return &memoryPaymentLinkStore{links: make(map[string]PaymentLink), claimedUntil: make(map[string]time.Time)}
+}
+
+func (m *memoryPaymentLinkStore) Create(_ context.Context, link *PaymentLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[link.ID] = *link
+	return nil
+}
+
+func (m *memoryPaymentLinkStore) Get(_ context.Context, tenantID int, id string) (*PaymentLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[id]
+	if !ok || (tenantID != 0 && link.TenantID != tenantID) {
+		return nil, ErrPaymentLinkNotFound
+	}
+	return &link, nil
+}
+
+func (m *memoryPaymentLinkStore) List(_ context.Context, tenantID int) ([]PaymentLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	links := []PaymentLink{}
+	for _, link := range m.links {
+		if link.TenantID == tenantID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (m *memoryPaymentLinkStore) Claim(_ context.Context, id string, now, leaseUntil time.Time) (*PaymentLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[id]
+	if !ok {
+		return nil, ErrPaymentLinkNotFound
+	}
+	leaseExpired := link.Status == PaymentLinkStatusProcessing && !m.claimedUntil[id].After(now)
+	if !link.ExpiresAt.After(now) || (link.Status != PaymentLinkStatusActive && !leaseExpired) {
+		return nil, ErrPaymentLinkUnavailable
+	}
+	link.Status = PaymentLinkStatusProcessing
+	m.links[id], m.claimedUntil[id] = link, leaseUntil
+	return &link, nil
+}
+
+func (m *memoryPaymentLinkStore) Attempted(_ context.Context, id, paymentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link := m.links[id]
+	link.PaymentID = paymentID
+	m.links[id] = link
+	return nil
+}
+
+func (m *memoryPaymentLinkStore) Paid(_ context.Context, id, paymentID string, paidAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link := m.links[id]
+	link.Status, link.PaymentID, link.PaidAt, link.LastError = PaymentLinkStatusPaid, paymentID, &paidAt, ""
+	m.links[id] = link
+	return nil
+}
+
+func (m *memoryPaymentLinkStore) Release(_ context.Context, id, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link := m.links[id]
+	link.Status, link.PaymentID, link.LastError = PaymentLinkStatusActive, "", lastError
+	m.links[id] = link
+	return nil
+}
+
+func (m *memoryPaymentLinkStore) Cancel(_ context.Context, tenantID int, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	link, ok := m.links[id]
+	if !ok || link.TenantID != tenantID {
+		return ErrPaymentLinkNotFound
+	}
+	if link.Status != PaymentLinkStatusActive {
+		return ErrPaymentLinkUnavailable
+	}
+	link.Status = PaymentLinkStatusCancelled
+	m.links[id] = link
+	return nil
+}
+
+func (m *memoryPaymentLinkStore) ExpireDue(_ context.Context, now time.Time, limit int) ([]PaymentLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expired := []PaymentLink{}
+	for id, link := range m.links {
+		if len(expired) < limit && link.Status == PaymentLinkStatusActive && !link.ExpiresAt.After(now) {
+			link.Status = PaymentLinkStatusExpired
+			m.links[id] = link
+			expired = append(expired, link)
+		}
+	}
+	return expired, nil
+}
+
+// linkTestPayer answers link payments with a fixed response and status
+type linkTestPayer struct {
+	response *PaymentResponse
+	err      error
+	status   *PaymentResponse
+	requests []PaymentRequest
+}
+
+func (p *linkTestPayer) CreatePayment(_ context.Context, _, _ string, request PaymentRequest) (*PaymentResponse, error) {
+	p.requests = append(p.requests, request)
+	return p.response, p.err
+}
+
+func (p *linkTestPayer) GetPaymentStatus(context.Context, string, string, GetPaymentStatusRequest) (*PaymentResponse, error) {
+	return p.status, nil
+}
+
+// recordingLinkNotifier records the events sent for payment links
+type recordingLinkNotifier struct {
+	events []string
+	links  []PaymentLink
+}
+
+func (n *recordingLinkNotifier) Notify(_ context.Context, link PaymentLink, event string) {
+	n.events = append(n.events, event)
+	n.links = append(n.links, link)
+}
+
+func newPaymentLinkTestService(t *testing.T, tenantID int, payer PaymentLinkPayer) (*PaymentLinkService, *memoryPaymentLinkStore, *recordingLinkNotifier, context.Context) {
+	t.Helper()
+	GetProviderCache().Set(tenantID, "linktest", "sandbox", &riskTestProvider{})
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, "linktest", "sandbox") })
+
+	store := newMemoryPaymentLinkStore()
+	notifier := &recordingLinkNotifier{}
+	service := NewPaymentLinkService(store, payer, notifier)
+	service.baseURL = "https://pay.example.com"
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, strconv.Itoa(tenantID))
+	return service, store, notifier, ctx
+}
+
+func linkCheckout() PaymentLinkCheckout {
+	return PaymentLinkCheckout{
+		Customer: Customer{Name: "Ada", Surname: "Lovelace", Email: "ada@example.com"},
+		CardInfo: CardInfo{CardHolderName: "Ada Lovelace", CardNumber: "4111111111111111", ExpireMonth: "12", ExpireYear: "2030", CVV: "123"},
+		ClientIP: "203.0.113.9",
+	}
+}
+
+func TestPaymentLinkService_CreateLink(t *testing.T) {
+	service, store, _, ctx := newPaymentLinkTestService(t, 9132, &linkTestPayer{})
+
+	link, err := service.CreateLink(ctx, "sandbox", "linktest", CreatePaymentLinkRequest{Amount: 150, Currency: "try", Description: "Invoice 42", WebhookURL: "https://merchant.example.com/hooks"})
+	if err != nil {
+		t.Fatalf("CreateLink failed: %v", err)
+	}
+	if link.Status != PaymentLinkStatusActive || link.Currency != "TRY" || link.URL != "https://pay.example.com/pay/"+link.ID {
+		t.Errorf("Unexpected link: %+v", link)
+	}
+	if link.WebhookSecret == "" {
+		t.Error("Expected the webhook secret to be returned on creation")
+	}
+	if got := link.ExpiresAt.Sub(link.CreatedAt); got != DefaultPaymentLinkTTL {
+		t.Errorf("Expected the default TTL, got %s", got)
+	}
+
+	fetched, err := service.GetLink(ctx, link.ID)
+	if err != nil {
+		t.Fatalf("GetLink failed: %v", err)
+	}
+	if fetched.WebhookSecret != "" {
+		t.Error("Expected the webhook secret to be hidden after creation")
+	}
+	if stored, _ := store.Get(ctx, 9132, link.ID); stored.WebhookSecret != link.WebhookSecret {
+		t.Error("Expected the webhook secret to be stored")
+	}
+
+	otherTenant := context.WithValue(context.Background(), tenantctx.IDKey, "9133")
+	if _, err := service.GetLink(otherTenant, link.ID); !errors.Is(err, ErrPaymentLinkNotFound) {
+		t.Errorf("Expected ErrPaymentLinkNotFound for another tenant, got %v", err)
+	}
+
+	past, far := time.Now().Add(-time.Minute), time.Now().Add(MaxPaymentLinkTTL+time.Hour)
+	invalid := map[string]CreatePaymentLinkRequest{
+		"no amount":       {Currency: "TRY"},
+		"unsupported cur": {Amount: 10, Currency: "GBP"},
+		"expired":         {Amount: 10, Currency: "TRY", ExpiresAt: &past},
+		"too far":         {Amount: 10, Currency: "TRY", ExpiresAt: &far},
+		"bad webhook":     {Amount: 10, Currency: "TRY", WebhookURL: "ftp://merchant.example.com"},
+	}
+	for name, request := range invalid {
+		if _, err := service.CreateLink(ctx, "sandbox", "linktest", request); !errors.Is(err, ErrPaymentLinkInvalid) {
+			t.Errorf("%s: expected ErrPaymentLinkInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestPaymentLinkService_PayLink_SingleUse(t *testing.T) {
+	payer := &linkTestPayer{response: &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_1"}}
+	service, _, notifier, ctx := newPaymentLinkTestService(t, 9134, payer)
+
+	link, err := service.CreateLink(ctx, "sandbox", "linktest", CreatePaymentLinkRequest{Amount: 150, Currency: "TRY", WebhookURL: "https://merchant.example.com/hooks"})
+	if err != nil {
+		t.Fatalf("CreateLink failed: %v", err)
+	}
+
+	paid, response, err := service.PayLink(context.Background(), link.ID, linkCheckout())
+	if err != nil {
+		t.Fatalf("PayLink failed: %v", err)
+	}
+	if paid.Status != PaymentLinkStatusPaid || paid.PaymentID != "pay_1" || paid.PaidAt == nil || response == nil {
+		t.Errorf("Expected a paid link, got %+v", paid)
+	}
+	request := payer.requests[0]
+	if request.Amount != 150 || request.PaymentLinkID != link.ID || !request.Use3D || request.CallbackURL != link.URL+"/complete" {
+		t.Errorf("Unexpected payment request: %+v", request)
+	}
+	if len(notifier.events) != 1 || notifier.events[0] != PaymentLinkEventPaid || notifier.links[0].WebhookSecret == "" {
+		t.Errorf("Expected a signed paid event, got %v", notifier.events)
+	}
+
+	if _, _, err := service.PayLink(context.Background(), link.ID, linkCheckout()); !errors.Is(err, ErrPaymentLinkUnavailable) {
+		t.Errorf("Expected ErrPaymentLinkUnavailable for a paid link, got %v", err)
+	}
+	if len(payer.requests) != 1 {
+		t.Errorf("Expected a single payment, got %d", len(payer.requests))
+	}
+	if _, err := service.CancelLink(ctx, link.ID); !errors.Is(err, ErrPaymentLinkUnavailable) {
+		t.Errorf("Expected a paid link not to be cancellable, got %v", err)
+	}
+}
+
+func TestPaymentLinkService_PayLink_FailedPaymentReleasesLink(t *testing.T) {
+	payer := &linkTestPayer{response: &PaymentResponse{Status: StatusFailed, Outcome: OutcomeFailed, Message: "Insufficient funds"}}
+	service, _, notifier, ctx := newPaymentLinkTestService(t, 9135, payer)
+
+	link, err := service.CreateLink(ctx, "sandbox", "linktest", CreatePaymentLinkRequest{Amount: 150, Currency: "TRY", WebhookURL: "https://merchant.example.com/hooks"})
+	if err != nil {
+		t.Fatalf("CreateLink failed: %v", err)
+	}
+
+	failed, _, err := service.PayLink(context.Background(), link.ID, linkCheckout())
+	if err != nil {
+		t.Fatalf("PayLink failed: %v", err)
+	}
+	if failed.Status != PaymentLinkStatusActive || failed.LastError != "Insufficient funds" {
+		t.Errorf("Expected the link to be payable again, got %+v", failed)
+	}
+	if len(notifier.events) != 1 || notifier.events[0] != PaymentLinkEventPaymentFailed {
+		t.Errorf("Expected a payment_failed event, got %v", notifier.events)
+	}
+
+	payer.response = &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_2"}
+	if paid, _, err := service.PayLink(context.Background(), link.ID, linkCheckout()); err != nil || paid.Status != PaymentLinkStatusPaid {
+		t.Errorf("Expected the retry to pay the link, got %+v, %v", paid, err)
+	}
+}
+
+func TestPaymentLinkService_CompleteLink_After3D(t *testing.T) {
+	payer := &linkTestPayer{
+		response: &PaymentResponse{Status: StatusPending, PaymentID: "pay_3d", HTML: "<form></form>"},
+		status:   &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_3d"},
+	}
+	service, _, notifier, ctx := newPaymentLinkTestService(t, 9136, payer)
+
+	link, err := service.CreateLink(ctx, "sandbox", "linktest", CreatePaymentLinkRequest{Amount: 150, Currency: "TRY", WebhookURL: "https://merchant.example.com/hooks"})
+	if err != nil {
+		t.Fatalf("CreateLink failed: %v", err)
+	}
+
+	pending, response, err := service.PayLink(context.Background(), link.ID, linkCheckout())
+	if err != nil {
+		t.Fatalf("PayLink failed: %v", err)
+	}
+	if pending.Status != PaymentLinkStatusProcessing || response.HTML == "" {
+		t.Fatalf("Expected the link to wait for 3D, got %+v", pending)
+	}
+
+	// The link is held while the customer is in 3D
+	if _, _, err := service.PayLink(context.Background(), link.ID, linkCheckout()); !errors.Is(err, ErrPaymentLinkUnavailable) {
+		t.Errorf("Expected ErrPaymentLinkUnavailable during 3D, got %v", err)
+	}
+
+	// A forged return with another payment ID is checked against the attempt's own payment
+	completed, err := service.CompleteLink(context.Background(), link.ID, "pay_forged")
+	if err != nil {
+		t.Fatalf("CompleteLink failed: %v", err)
+	}
+	if completed.Status != PaymentLinkStatusPaid || completed.PaymentID != "pay_3d" {
+		t.Errorf("Expected the link to be paid by pay_3d, got %+v", completed)
+	}
+	if len(notifier.events) != 1 || notifier.events[0] != PaymentLinkEventPaid {
+		t.Errorf("Expected a paid event, got %v", notifier.events)
+	}
+}
+
+func TestPaymentLinkService_RunExpiry(t *testing.T) {
+	service, store, notifier, ctx := newPaymentLinkTestService(t, 9137, &linkTestPayer{})
+
+	link, err := service.CreateLink(ctx, "sandbox", "linktest", CreatePaymentLinkRequest{Amount: 150, Currency: "TRY", WebhookURL: "https://merchant.example.com/hooks"})
+	if err != nil {
+		t.Fatalf("CreateLink failed: %v", err)
+	}
+
+	service.now = func() time.Time { return link.ExpiresAt.Add(time.Second) }
+	if checkout, _ := service.CheckoutLink(context.Background(), link.ID); checkout.Status != PaymentLinkStatusExpired {
+		t.Errorf("Expected an unpaid link past its expiry to show as expired, got %s", checkout.Status)
+	}
+	if _, _, err := service.PayLink(context.Background(), link.ID, linkCheckout()); !errors.Is(err, ErrPaymentLinkUnavailable) {
+		t.Errorf("Expected ErrPaymentLinkUnavailable for an expired link, got %v", err)
+	}
+
+	expired, err := service.RunExpiry(context.Background())
+	if err != nil || expired != 1 {
+		t.Fatalf("Expected 1 expired link, got %d, %v", expired, err)
+	}
+	if stored, _ := store.Get(ctx, 9137, link.ID); stored.Status != PaymentLinkStatusExpired {
+		t.Errorf("Expected the stored link to be expired, got %s", stored.Status)
+	}
+	if len(notifier.events) != 1 || notifier.events[0] != PaymentLinkEventExpired {
+		t.Errorf("Expected an expired event, got %v", notifier.events)
+	}
+}
+
+func TestWebhookPaymentLinkNotifier_SignsAndQueues(t *testing.T) {
+	var mu sync.Mutex
+	var body []byte
+	var signature, event string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(r.Body)
+		signature, event = r.Header.Get(PaymentLinkSignatureHeader), r.Header.Get("X-GoPay-Event")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher, store, _ := newTestWebhookDispatcher(server.Client(), 3)
+	notifier := NewWebhookPaymentLinkNotifier(dispatcher)
+	link := PaymentLink{ID: "pl1", TenantID: 9138, Status: PaymentLinkStatusPaid, WebhookURL: server.URL, WebhookSecret: "whsec_test"}
+	notifier.Notify(context.Background(), link, PaymentLinkEventPaid)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.get(1).Status != WebhookDeliveryStatusDelivered && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stored := store.get(1); stored.Status != WebhookDeliveryStatusDelivered || stored.TenantID != 9138 {
+		t.Fatalf("Expected the queued delivery to be delivered, got %+v", stored)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if event != PaymentLinkEventPaid || signature != SignPaymentLinkWebhook("whsec_test", body) {
+		t.Errorf("Unexpected event %q or signature %q", event, signature)
+	}
+	var payload PaymentLinkEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Invalid webhook body: %v", err)
+	}
+	if payload.Type != PaymentLinkEventPaid || payload.Data.ID != "pl1" || payload.Data.WebhookSecret != "" {
+		t.Errorf("Unexpected webhook payload: %+v", payload)
+	}
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// PaymentLinkSignatureHeader carries the hex HMAC-SHA256 of a payment link webhook body,
+// keyed with the link's webhook secret
+const PaymentLinkSignatureHeader = "X-GoPay-Signature"
+
+// PaymentLinkEvent is the body of a payment link webhook
+type PaymentLinkEvent struct {
+	Type      string      `json:"type"`
+	Data      PaymentLink `json:"data"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// PaymentLinkNotifier tells the merchant about payment link status changes
+type PaymentLinkNotifier interface {
+	// Notify sends event for link. It must not block the caller on the merchant's server.
+	Notify(ctx context.Context, link PaymentLink, event string)
+}
+
+// WebhookPaymentLinkNotifier posts payment link events to the link's webhook URL through a
+// WebhookDispatcher, which retries failed deliveries and dead-letters those that keep failing
+type WebhookPaymentLinkNotifier struct {
+	dispatcher *WebhookDispatcher
+}
+
+// NewWebhookPaymentLinkNotifier creates a notifier that delivers through dispatcher
+func NewWebhookPaymentLinkNotifier(dispatcher *WebhookDispatcher) *WebhookPaymentLinkNotifier {
+	return &WebhookPaymentLinkNotifier{dispatcher: dispatcher}
+}
+
+// Notify queues the event for delivery; the first attempt is made in the background
+func (n *WebhookPaymentLinkNotifier) Notify(ctx context.Context, link PaymentLink, event string) {
+	delivery, err := paymentLinkWebhookDelivery(link, event)
+	if err == nil {
+		err = n.dispatcher.Enqueue(context.WithoutCancel(ctx), delivery)
+	}
+	if err != nil {
+		logger.Warn("Failed to queue payment link webhook", logger.LogContext{
+			TenantID: strconv.Itoa(link.TenantID),
+			Provider: link.Provider,
+			Fields: map[string]any{
+				"payment_link_id": link.ID,
+				"event":           event,
+				"error":           err.Error(),
+			},
+		})
+	}
+}
+
+// paymentLinkWebhookDelivery builds the signed delivery of event for link. The webhook secret
+// signs the body but is not part of it.
+func paymentLinkWebhookDelivery(link PaymentLink, event string) (WebhookDelivery, error) {
+	secret := link.WebhookSecret
+	link.WebhookSecret = ""
+	body, err := json.Marshal(PaymentLinkEvent{Type: event, Data: link, CreatedAt: time.Now()})
+	if err != nil {
+		return WebhookDelivery{}, fmt.Errorf("failed to encode payment link event: %w", err)
+	}
+	return WebhookDelivery{
+		TenantID:  link.TenantID,
+		Event:     event,
+		URL:       link.WebhookURL,
+		Body:      body,
+		Signature: SignPaymentLinkWebhook(secret, body),
+	}, nil
+}
+
+// SignPaymentLinkWebhook returns the signature of a payment link webhook body
+func SignPaymentLinkWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,293 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPaymentListLimit is the page size of a payment listing without a limit
+	DefaultPaymentListLimit = 50
+
+	// MaxPaymentListLimit caps the page size of a payment listing
+	MaxPaymentListLimit = 200
+
+	paymentCursorTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+var (
+	// ErrPaymentListUnavailable is returned when the payment logger cannot list payments
+	ErrPaymentListUnavailable = errors.New("payment listing is not available")
+
+	// ErrPaymentListFilterInvalid is returned for a malformed cursor or an empty range
+	ErrPaymentListFilterInvalid = errors.New("invalid payment list filter")
+)
+
+// PaymentListFilter selects the payments of a listing. Zero values do not filter.
+type PaymentListFilter struct {
+	Provider      string
+	Status        string
+	From          time.Time
+	To            time.Time
+	MinAmount     float64
+	MaxAmount     float64
+	CustomerEmail string
+	// After continues a listing after the last payment of the previous page
+	After *PaymentCursor
+	Limit int
+}
+
+// PaymentCursor is the position of a payment in a listing, newest first. Payments requested
+// at the same time are ordered by provider and log ID so the order is total.
+type PaymentCursor struct {
+	RequestedAt time.Time `json:"t"`
+	Provider    string    `json:"p"`
+	LogID       int64     `json:"i"`
+}
+
+// PaymentSummary is a payment in a listing
+type PaymentSummary struct {
+	PaymentID     string    `json:"paymentId,omitempty"`
+	Provider      string    `json:"provider"`
+	Status        string    `json:"status"`
+	Amount        float64   `json:"amount,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+	CustomerEmail string    `json:"customerEmail,omitempty"`
+	ErrorCode     string    `json:"errorCode,omitempty"`
+	Use3D         bool      `json:"use3D"`
+	RequestedAt   time.Time `json:"requestedAt"`
+	LogID         int64     `json:"-"`
+}
+
+// PaymentPage is one page of a payment listing. NextCursor is empty on the last page.
+type PaymentPage struct {
+	Payments   []PaymentSummary `json:"payments"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// PaymentListLookup is an OPTIONAL capability of a PaymentLogger that lists the payments of a
+// tenant from the payment logs. It returns at most filter.Limit payments after filter.After,
+// newest first.
+type PaymentListLookup interface {
+	ListPayments(ctx context.Context, tenantID int, filter PaymentListFilter) ([]PaymentSummary, error)
+}
+
+// EncodePaymentCursor returns the opaque cursor of a payment's position
+func EncodePaymentCursor(cursor PaymentCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodePaymentCursor reads a cursor returned as NextCursor
+func DecodePaymentCursor(value string) (*PaymentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrPaymentListFilterInvalid)
+	}
+	var cursor PaymentCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.RequestedAt.IsZero() || cursor.Provider == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrPaymentListFilterInvalid)
+	}
+	return &cursor, nil
+}
+
+// paymentListBefore reports whether a comes before b in a listing, newest first
+func paymentListBefore(a, b PaymentCursor) bool {
+	if !a.RequestedAt.Equal(b.RequestedAt) {
+		return a.RequestedAt.After(b.RequestedAt)
+	}
+	if a.Provider != b.Provider {
+		return a.Provider > b.Provider
+	}
+	return a.LogID > b.LogID
+}
+
+func (p PaymentSummary) cursor() PaymentCursor {
+	return PaymentCursor{RequestedAt: p.RequestedAt, Provider: p.Provider, LogID: p.LogID}
+}
+
+// comparePaymentSummaries orders payments newest first
+func comparePaymentSummaries(a, b PaymentSummary) int {
+	switch {
+	case paymentListBefore(a.cursor(), b.cursor()):
+		return -1
+	case paymentListBefore(b.cursor(), a.cursor()):
+		return 1
+	}
+	return 0
+}
+
+// ListPayments returns a page of the tenant's payments, newest first
+func (s *PaymentService) ListPayments(ctx context.Context, filter PaymentListFilter) (*PaymentPage, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lookup, ok := s.logger.(PaymentListLookup)
+	if !ok {
+		return nil, ErrPaymentListUnavailable
+	}
+
+	switch {
+	case !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To):
+		return nil, fmt.Errorf("%w: from must be before to", ErrPaymentListFilterInvalid)
+	case filter.MinAmount < 0 || filter.MaxAmount < 0:
+		return nil, fmt.Errorf("%w: amounts must not be negative", ErrPaymentListFilterInvalid)
+	case filter.MaxAmount > 0 && filter.MinAmount > filter.MaxAmount:
+		return nil, fmt.Errorf("%w: minAmount must not exceed maxAmount", ErrPaymentListFilterInvalid)
+	}
+	filter.Provider = strings.ToLower(strings.TrimSpace(filter.Provider))
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPaymentListLimit
+	}
+	limit := min(filter.Limit, MaxPaymentListLimit)
+
+	// One more than the page tells whether there is a next page
+	filter.Limit = limit + 1
+	payments, err := lookup.ListPayments(ctx, tenantID, filter)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(payments, comparePaymentSummaries)
+
+	page := &PaymentPage{Payments: payments}
+	if len(payments) > limit {
+		page.Payments = payments[:limit]
+		page.NextCursor = EncodePaymentCursor(page.Payments[limit-1].cursor())
+	}
+	if page.Payments == nil {
+		page.Payments = []PaymentSummary{}
+	}
+	return page, nil
+}
+
+// paymentListQuery selects a page of payment requests from a provider table, newest first.
+// The tenant is $1; the other conditions are added as arguments in order.
+func paymentListQuery(tableName string, tenantID int, filter PaymentListFilter) (string, []any) {
+	args := []any{tenantID}
+	conditions := []string{"tenant_id = $1", "endpoint IN ('/payment', '/payment/3d')"}
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	// The status is matched the way attemptStatus reports it for rows logged without one
+	if filter.Status != "" {
+		add(`COALESCE(NULLIF(status, ''), CASE WHEN COALESCE(error_code, '') <> '' THEN 'failed' ELSE 'processing' END) = $%d`, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		add("request_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("request_at <= $%d", filter.To)
+	}
+	if filter.MinAmount > 0 {
+		add("amount >= $%d", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		add("amount <= $%d", filter.MaxAmount)
+	}
+	if filter.CustomerEmail != "" {
+		add("lower(request->'customer'->>'email') = lower($%d)", filter.CustomerEmail)
+	}
+
+	// Within the table the provider is fixed, so the cursor reduces to the time and log ID. The
+	// time is passed as the wall clock it was read as, since request_at has no time zone.
+	if after := filter.After; after != nil {
+		requestedAt := after.RequestedAt.Format(paymentCursorTimeLayout)
+		switch {
+		case tableName < after.Provider:
+			add("request_at <= $%d::timestamp", requestedAt)
+		case tableName > after.Provider:
+			add("request_at < $%d::timestamp", requestedAt)
+		default:
+			args = append(args, requestedAt, after.LogID)
+			conditions = append(conditions, fmt.Sprintf("(request_at, id) < ($%d::timestamp, $%d)", len(args)-1, len(args)))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, request_at, payment_id, amount, currency, status, error_code, endpoint,
+		       request->'customer'->>'email'
+		FROM %s
+		WHERE %s
+		ORDER BY request_at DESC, id DESC
+		LIMIT %d
+	`, tableName, strings.Join(conditions, " AND "), filter.Limit)
+	return query, args
+}
+
+// ListPayments reads a page of payments from the log table of every active provider, or of
+// filter.Provider only. Each table returns up to filter.Limit payments and the newest are kept.
+func (l *DBPaymentLogger) ListPayments(ctx context.Context, tenantID int, filter PaymentListFilter) ([]PaymentSummary, error) {
+	// Only active providers have a log table, so the provider filter goes through the same list
+	rows, err := l.db.QueryContext(ctx, `SELECT name FROM providers WHERE active = true AND ($1 = '' OR name = $1)`, filter.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	var providers []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan provider: %w", err)
+		}
+		providers = append(providers, name)
+	}
+	rows.Close()
+
+	payments := []PaymentSummary{}
+	for _, providerName := range providers {
+		found, err := l.listPaymentsOf(ctx, providerName, tenantID, filter)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, found...)
+	}
+
+	slices.SortFunc(payments, comparePaymentSummaries)
+	if len(payments) > filter.Limit {
+		payments = payments[:filter.Limit]
+	}
+	return payments, nil
+}
+
+// listPaymentsOf reads a page of payments from one provider's log table
+func (l *DBPaymentLogger) listPaymentsOf(ctx context.Context, providerName string, tenantID int, filter PaymentListFilter) ([]PaymentSummary, error) {
+	query, args := paymentListQuery(providerName, tenantID, filter)
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s payments: %w", providerName, err)
+	}
+	defer rows.Close()
+
+	var payments []PaymentSummary
+	for rows.Next() {
+		var (
+			payment                                                 PaymentSummary
+			paymentID, currency, status, errorCode, endpoint, email sql.NullString
+			amount                                                  sql.NullFloat64
+		)
+		if err := rows.Scan(&payment.LogID, &payment.RequestedAt, &paymentID, &amount, &currency, &status, &errorCode, &endpoint, &email); err != nil {
+			return nil, fmt.Errorf("failed to scan %s payment: %w", providerName, err)
+		}
+
+		payment.PaymentID = paymentID.String
+		payment.Provider = providerName
+		payment.Amount = amount.Float64
+		payment.Currency = currency.String
+		payment.ErrorCode = errorCode.String
+		payment.CustomerEmail = email.String
+		payment.Use3D = endpoint.String == "/payment/3d"
+		payment.Status = attemptStatus(status.String, errorCode.String)
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
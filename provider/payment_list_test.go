@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// paymentListLogger is a payment logger that lists the payments of tenants the way the
+// provider tables do: filtered by provider, after the cursor and up to the limit, in no order
+type paymentListLogger struct {
+	recordingPaymentLogger
+	payments map[int][]PaymentSummary
+}
+
+func (l *paymentListLogger) ListPayments(_ context.Context, tenantID int, filter PaymentListFilter) ([]PaymentSummary, error) {
+	var found []PaymentSummary
+	for _, payment := range l.payments[tenantID] {
+		if filter.Provider != "" && payment.Provider != filter.Provider {
+			continue
+		}
+		if filter.After != nil && !paymentListBefore(*filter.After, payment.cursor()) {
+			continue
+		}
+		found = append(found, payment)
+	}
+	// Keep the newest up to the limit, returned in reverse to check the service sorts them
+	if len(found) > 0 {
+		sorted := append([]PaymentSummary(nil), found...)
+		for i := range sorted {
+			for j := i + 1; j < len(sorted); j++ {
+				if comparePaymentSummaries(sorted[j], sorted[i]) < 0 {
+					sorted[i], sorted[j] = sorted[j], sorted[i]
+				}
+			}
+		}
+		found = sorted[:min(len(sorted), filter.Limit)]
+		for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+			found[i], found[j] = found[j], found[i]
+		}
+	}
+	return found, nil
+}
+
+func TestPaymentService_ListPayments_Pages(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	paymentLogger := &paymentListLogger{payments: map[int][]PaymentSummary{
+		9144: {
+			{PaymentID: "iyz_1", Provider: "iyzico", RequestedAt: start, LogID: 1},
+			{PaymentID: "iyz_2", Provider: "iyzico", RequestedAt: start.Add(2 * time.Minute), LogID: 2},
+			// Requested at the same time as iyz_2: ordered by provider, then log ID
+			{PaymentID: "pi_1", Provider: "stripe", RequestedAt: start.Add(2 * time.Minute), LogID: 1},
+			{PaymentID: "pi_2", Provider: "stripe", RequestedAt: start.Add(5 * time.Minute), LogID: 2},
+			{PaymentID: "iyz_3", Provider: "iyzico", RequestedAt: start.Add(2 * time.Minute), LogID: 3},
+		},
+		9145: {
+			{PaymentID: "other_tenant", Provider: "iyzico", RequestedAt: start, LogID: 9},
+		},
+	}}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9144")
+
+	var ids []string
+	filter := PaymentListFilter{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Listing did not end")
+		}
+		page, err := service.ListPayments(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListPayments failed: %v", err)
+		}
+		if len(page.Payments) > 2 {
+			t.Fatalf("Expected at most 2 payments per page, got %d", len(page.Payments))
+		}
+		for _, payment := range page.Payments {
+			ids = append(ids, payment.PaymentID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if filter.After, err = DecodePaymentCursor(page.NextCursor); err != nil {
+			t.Fatalf("Invalid next cursor: %v", err)
+		}
+	}
+
+	if got := strings.Join(ids, ","); got != "pi_2,pi_1,iyz_3,iyz_2,iyz_1" {
+		t.Errorf("Expected every payment of the tenant once, newest first, got %s", got)
+	}
+
+	page, err := service.ListPayments(ctx, PaymentListFilter{Provider: " IYZICO "})
+	if err != nil || len(page.Payments) != 3 || page.NextCursor != "" {
+		t.Errorf("Expected the 3 iyzico payments on one page, got %+v (%v)", page, err)
+	}
+}
+
+func TestPaymentService_ListPayments_Invalid(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9144")
+	service := NewPaymentService(&paymentListLogger{})
+	now := time.Now()
+
+	for name, filter := range map[string]PaymentListFilter{
+		"reversed range":   {From: now, To: now.Add(-time.Hour)},
+		"negative amount":  {MinAmount: -1},
+		"reversed amounts": {MinAmount: 100, MaxAmount: 10},
+	} {
+		if _, err := service.ListPayments(ctx, filter); !errors.Is(err, ErrPaymentListFilterInvalid) {
+			t.Errorf("%s: expected ErrPaymentListFilterInvalid, got %v", name, err)
+		}
+	}
+
+	if _, err := NewPaymentService(&recordingPaymentLogger{}).ListPayments(ctx, PaymentListFilter{}); !errors.Is(err, ErrPaymentListUnavailable) {
+		t.Errorf("Expected ErrPaymentListUnavailable without a lookup, got %v", err)
+	}
+	if _, err := DecodePaymentCursor("not-a-cursor"); !errors.Is(err, ErrPaymentListFilterInvalid) {
+		t.Errorf("Expected a malformed cursor to be rejected, got %v", err)
+	}
+}
+
+func TestPaymentListQuery(t *testing.T) {
+	after := &PaymentCursor{RequestedAt: time.Date(2026, 3, 1, 12, 0, 0, 500000000, time.UTC), Provider: "iyzico", LogID: 42}
+	filter := PaymentListFilter{Status: "failed", MinAmount: 10, CustomerEmail: "John@Example.com", After: after, Limit: 51}
+
+	tests := []struct {
+		table  string
+		cursor string
+		args   []any
+	}{
+		{"iyzico", "(request_at, id) < ($5::timestamp, $6)", []any{9144, "failed", 10.0, "John@Example.com", "2026-03-01 12:00:00.5", int64(42)}},
+		{"akbank", "request_at <= $5::timestamp", []any{9144, "failed", 10.0, "John@Example.com", "2026-03-01 12:00:00.5"}},
+		{"stripe", "request_at < $5::timestamp", []any{9144, "failed", 10.0, "John@Example.com", "2026-03-01 12:00:00.5"}},
+	}
+	for _, tt := range tests {
+		query, args := paymentListQuery(tt.table, 9144, filter)
+		if !strings.Contains(query, "FROM "+tt.table) || !strings.Contains(query, tt.cursor) || !strings.Contains(query, "LIMIT 51") {
+			t.Errorf("%s: unexpected query %s", tt.table, query)
+		}
+		if !strings.Contains(query, "tenant_id = $1") || !strings.Contains(query, "lower(request->'customer'->>'email') = lower($4)") {
+			t.Errorf("%s: expected tenant and email conditions, got %s", tt.table, query)
+		}
+		if len(args) != len(tt.args) {
+			t.Fatalf("%s: expected args %v, got %v", tt.table, tt.args, args)
+		}
+		for i := range args {
+			if args[i] != tt.args[i] {
+				t.Errorf("%s: arg %d: expected %v, got %v", tt.table, i+1, tt.args[i], args[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"strings"
+)
+
+// PaymentMethodTypeCard is the PaymentMethodDetails.Type of card payments
+const PaymentMethodTypeCard = "card"
+
+// Card funding types of PaymentMethodDetails.FundingType
+const (
+	FundingCredit  = "credit"
+	FundingDebit   = "debit"
+	FundingPrepaid = "prepaid"
+)
+
+// PaymentMethodDetails describes the payment method a payment was made with, in the same
+// terms for every provider. Fields the provider and the BIN lookup could not tell are empty.
+type PaymentMethodDetails struct {
+	Type          string `json:"type"`
+	Brand         string `json:"brand,omitempty"`         // visa, mastercard, amex, troy
+	FundingType   string `json:"fundingType,omitempty"`   // credit, debit, prepaid
+	Network       string `json:"network,omitempty"`       // network the payment was routed on
+	IssuerCountry string `json:"issuerCountry,omitempty"` // ISO 3166-1 alpha-2
+	Issuer        string `json:"issuer,omitempty"`        // issuing bank
+}
+
+// CardBinInfo is a provider's BIN lookup result in the provider's own wording, e.g.
+// "CREDIT_CARD" or "Kredi Kartı" for CardType
+type CardBinInfo struct {
+	BIN             string
+	CardType        string
+	CardAssociation string
+	Network         string
+	BankName        string
+	Country         string
+}
+
+// CardBinInfoProvider is an OPTIONAL capability interface implemented by providers that can
+// look up a card BIN. PaymentService uses it to complete the PaymentMethodDetails of a payment.
+type CardBinInfoProvider interface {
+	GetCardBinInfo(ctx context.Context, bin string) (*CardBinInfo, error)
+}
+
+// PaymentMethodDetailsFromBinInfo maps a BIN lookup result to PaymentMethodDetails. The
+// network defaults to the card brand when the provider does not report it separately.
+func PaymentMethodDetailsFromBinInfo(info *CardBinInfo) *PaymentMethodDetails {
+	if info == nil {
+		return nil
+	}
+
+	details := &PaymentMethodDetails{
+		Type:          PaymentMethodTypeCard,
+		Brand:         NormalizeCardBrand(info.CardAssociation),
+		FundingType:   NormalizeFundingType(info.CardType),
+		Network:       NormalizeCardBrand(info.Network),
+		IssuerCountry: strings.ToUpper(strings.TrimSpace(info.Country)),
+		Issuer:        strings.TrimSpace(info.BankName),
+	}
+	if details.Network == "" {
+		details.Network = details.Brand
+	}
+	return details
+}
+
+// cardBrands maps provider card association names, upper-cased without separators
+var cardBrands = map[string]string{
+	"VISA":            "visa",
+	"MASTER":          "mastercard",
+	"MASTERCARD":      "mastercard",
+	"MAESTRO":         "maestro",
+	"AMEX":            "amex",
+	"AMERICANEXPRESS": "amex",
+	"TROY":            "troy",
+	"DISCOVER":        "discover",
+	"DINERS":          "diners",
+	"DINERSCLUB":      "diners",
+	"JCB":             "jcb",
+	"UNIONPAY":        "unionpay",
+}
+
+// NormalizeCardBrand maps a provider card association ("MASTER_CARD", "Visa", "American
+// Express") to a lower-case brand. Unknown names are returned lower-cased.
+func NormalizeCardBrand(association string) string {
+	key := strings.NewReplacer("_", "", " ", "", "-", "").Replace(strings.ToUpper(strings.TrimSpace(association)))
+	if brand, ok := cardBrands[key]; ok {
+		return brand
+	}
+	return strings.ToLower(strings.TrimSpace(association))
+}
+
+// NormalizeFundingType maps a provider card type ("CREDIT_CARD", "Debit Card", "Banka
+// Kartı") to FundingCredit, FundingDebit or FundingPrepaid, or "" when it is not recognized.
+func NormalizeFundingType(cardType string) string {
+	cardType = strings.ToLower(cardType)
+	switch {
+	case strings.Contains(cardType, "prepaid"), strings.Contains(cardType, "ön ödemeli"):
+		return FundingPrepaid
+	case strings.Contains(cardType, "debit"), strings.Contains(cardType, "banka"):
+		return FundingDebit
+	case strings.Contains(cardType, "credit"), strings.Contains(cardType, "kredi"):
+		return FundingCredit
+	}
+	return ""
+}
+
+// complete reports whether the brand and funding type are known. The BIN lookup costs the
+// payment another provider round-trip, so it is skipped for the remaining fields.
+func (d *PaymentMethodDetails) complete() bool {
+	return d != nil && d.Brand != "" && d.FundingType != ""
+}
+
+// withFallback fills the empty fields of d from fallback. What the provider reported for the
+// payment wins over the BIN lookup.
+func (d *PaymentMethodDetails) withFallback(fallback *PaymentMethodDetails) *PaymentMethodDetails {
+	if d == nil {
+		return fallback
+	}
+	if fallback == nil {
+		return d
+	}
+
+	merged := *d
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&merged.Type, fallback.Type)
+	fill(&merged.Brand, fallback.Brand)
+	fill(&merged.FundingType, fallback.FundingType)
+	fill(&merged.Network, fallback.Network)
+	fill(&merged.IssuerCountry, fallback.IssuerCountry)
+	fill(&merged.Issuer, fallback.Issuer)
+	return &merged
+}
+
+// cardBIN returns the first 6 digits of a card number, or "" when it is not a card number
+func cardBIN(cardNumber string) string {
+	digits := NormalizePAN(cardNumber)
+	if len(digits) < 12 || !binPattern.MatchString(digits[:6]) {
+		return ""
+	}
+	return digits[:6]
+}
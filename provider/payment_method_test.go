@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestPaymentMethodDetailsFromBinInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     *CardBinInfo
+		expected *PaymentMethodDetails
+	}{
+		{
+			name:     "nil info",
+			info:     nil,
+			expected: nil,
+		},
+		{
+			name: "iyzico credit card",
+			info: &CardBinInfo{BIN: "552879", CardType: "CREDIT_CARD", CardAssociation: "MASTER_CARD", BankName: "Halkbank"},
+			expected: &PaymentMethodDetails{
+				Type: PaymentMethodTypeCard, Brand: "mastercard", FundingType: FundingCredit, Network: "mastercard", Issuer: "Halkbank",
+			},
+		},
+		{
+			name: "paycell debit card",
+			info: &CardBinInfo{BIN: "435508", CardType: "Debit Card", CardAssociation: "VISA", BankName: " AKBANK ", Country: "tr"},
+			expected: &PaymentMethodDetails{
+				Type: PaymentMethodTypeCard, Brand: "visa", FundingType: FundingDebit, Network: "visa", IssuerCountry: "TR", Issuer: "AKBANK",
+			},
+		},
+		{
+			name: "turkish prepaid troy card",
+			info: &CardBinInfo{CardType: "Ön Ödemeli Kart", CardAssociation: "TROY"},
+			expected: &PaymentMethodDetails{
+				Type: PaymentMethodTypeCard, Brand: "troy", FundingType: FundingPrepaid, Network: "troy",
+			},
+		},
+		{
+			name: "co-badged card routed on another network",
+			info: &CardBinInfo{CardType: "credit", CardAssociation: "American Express", Network: "Discover", Country: "US"},
+			expected: &PaymentMethodDetails{
+				Type: PaymentMethodTypeCard, Brand: "amex", FundingType: FundingCredit, Network: "discover", IssuerCountry: "US",
+			},
+		},
+		{
+			name: "unknown card type",
+			info: &CardBinInfo{CardType: "CHARGE", CardAssociation: "Elo"},
+			expected: &PaymentMethodDetails{
+				Type: PaymentMethodTypeCard, Brand: "elo", Network: "elo",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PaymentMethodDetailsFromBinInfo(tt.info)
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPaymentMethodDetails_WithFallback(t *testing.T) {
+	reported := &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "visa", Network: "visa"}
+	lookup := &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "mastercard", FundingType: FundingDebit, IssuerCountry: "TR", Issuer: "AKBANK"}
+
+	merged := reported.withFallback(lookup)
+	expected := PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "visa", FundingType: FundingDebit, Network: "visa", IssuerCountry: "TR", Issuer: "AKBANK"}
+	if *merged != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *merged)
+	}
+	if reported.FundingType != "" {
+		t.Error("Expected the reported details to be left unchanged")
+	}
+
+	var none *PaymentMethodDetails
+	if none.withFallback(lookup) != lookup || reported.withFallback(nil) != reported {
+		t.Error("Expected the non-nil side when the other is nil")
+	}
+}
+
+func TestCardBIN(t *testing.T) {
+	tests := map[string]string{
+		"5528790000000008":    "552879",
+		"4242 4242 4242 4242": "424242",
+		"4242-4242-4242-4242": "424242",
+		"":                    "",
+		"552879":              "",
+		"5528abcd00000008":    "",
+	}
+
+	for cardNumber, expected := range tests {
+		if got := cardBIN(cardNumber); got != expected {
+			t.Errorf("cardBIN(%q): expected %q, got %q", cardNumber, expected, got)
+		}
+	}
+}
+
+// binInfoTestProvider reports partial card details with the payment and answers BIN lookups
+type binInfoTestProvider struct {
+	riskTestProvider
+	reported *PaymentMethodDetails
+	info     *CardBinInfo
+	err      error
+	lookups  []string
+}
+
+func (p *binInfoTestProvider) CreatePayment(context.Context, PaymentRequest) (*PaymentResponse, error) {
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentMethodDetails: p.reported}, nil
+}
+
+func (p *binInfoTestProvider) GetCardBinInfo(_ context.Context, bin string) (*CardBinInfo, error) {
+	p.lookups = append(p.lookups, bin)
+	return p.info, p.err
+}
+
+func TestPaymentService_CreatePayment_PaymentMethodDetails(t *testing.T) {
+	const tenantID, providerName = 9109, "bininfotest"
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9109")
+	info := &CardBinInfo{CardType: "Credit Card", CardAssociation: "MASTER", BankName: "Garanti", Country: "TR"}
+
+	tests := []struct {
+		name            string
+		reported        *PaymentMethodDetails
+		lookupErr       error
+		expected        *PaymentMethodDetails
+		expectedLookups int
+	}{
+		{
+			name:            "details from BIN lookup",
+			expected:        &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "mastercard", FundingType: FundingCredit, Network: "mastercard", IssuerCountry: "TR", Issuer: "Garanti"},
+			expectedLookups: 1,
+		},
+		{
+			name:            "provider response completed by BIN lookup",
+			reported:        &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "mastercard", Network: "maestro"},
+			expected:        &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "mastercard", FundingType: FundingCredit, Network: "maestro", IssuerCountry: "TR", Issuer: "Garanti"},
+			expectedLookups: 1,
+		},
+		{
+			name:     "provider response without lookup",
+			reported: &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "visa", FundingType: FundingDebit},
+			expected: &PaymentMethodDetails{Type: PaymentMethodTypeCard, Brand: "visa", FundingType: FundingDebit},
+		},
+		{
+			name:            "failed lookup",
+			lookupErr:       errors.New("bin service down"),
+			expected:        nil,
+			expectedLookups: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &binInfoTestProvider{reported: tt.reported, info: info, err: tt.lookupErr}
+			GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+			t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+			service := NewPaymentService(&recordingPaymentLogger{})
+			resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+			if err != nil {
+				t.Fatalf("CreatePayment failed: %v", err)
+			}
+
+			got := resp.PaymentMethodDetails
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+			if len(fake.lookups) != tt.expectedLookups {
+				t.Errorf("Expected %d BIN lookups, got %v", tt.expectedLookups, fake.lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// paymentEventBufferSize is the number of events a watcher may fall behind by. Events dropped
+// for a slow watcher are picked up by its next poll of the event store.
+const paymentEventBufferSize = 16
+
+// paymentEventKey identifies the events of one payment
+type paymentEventKey struct {
+	tenantID  int
+	provider  string
+	paymentID string
+}
+
+// paymentEventBroker fans the events recorded by this instance out to the watchers of their
+// payment
+type paymentEventBroker struct {
+	mu       sync.Mutex
+	watchers map[paymentEventKey]map[chan PaymentEvent]struct{}
+}
+
+func newPaymentEventBroker() *paymentEventBroker {
+	return &paymentEventBroker{watchers: make(map[paymentEventKey]map[chan PaymentEvent]struct{})}
+}
+
+// subscribe returns a channel of the events of a payment and the func that closes it
+func (b *paymentEventBroker) subscribe(key paymentEventKey) (chan PaymentEvent, func()) {
+	ch := make(chan PaymentEvent, paymentEventBufferSize)
+
+	b.mu.Lock()
+	if b.watchers[key] == nil {
+		b.watchers[key] = make(map[chan PaymentEvent]struct{})
+	}
+	b.watchers[key][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.watchers[key], ch)
+		if len(b.watchers[key]) == 0 {
+			delete(b.watchers, key)
+		}
+	}
+}
+
+// publish sends an event to the watchers of its payment without blocking
+func (b *paymentEventBroker) publish(event PaymentEvent) {
+	if b == nil {
+		return
+	}
+	key := paymentEventKey{tenantID: event.TenantID, provider: event.Provider, paymentID: event.PaymentID}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.watchers[key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// WatchPaymentEvents streams the lifecycle events of one of the tenant's payments: the events
+// recorded after afterID first, then new ones as they are recorded, until ctx is done and the
+// channel is closed. Events recorded by this instance are sent at once; the event store is
+// polled every pollInterval for the ones recorded by other instances, such as a 3D Secure
+// callback received elsewhere. Returns ErrPaymentNotFound for a payment without events.
+func (s *PaymentService) WatchPaymentEvents(ctx context.Context, providerName, paymentID string, afterID int64, pollInterval time.Duration) (<-chan PaymentEvent, error) {
+	if s.paymentEvents == nil {
+		return nil, ErrPaymentEventsUnavailable
+	}
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscribe before listing so no event falls between the two
+	live, unsubscribe := s.eventBroker.subscribe(paymentEventKey{tenantID: tenantID, provider: providerName, paymentID: paymentID})
+	recorded, err := s.paymentEvents.List(ctx, tenantID, providerName, paymentID)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	if len(recorded) == 0 {
+		unsubscribe()
+		return nil, ErrPaymentNotFound
+	}
+
+	events := make(chan PaymentEvent)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+
+		sent := make(map[int64]bool)
+		send := func(event PaymentEvent) bool {
+			if event.ID <= afterID || sent[event.ID] {
+				return true
+			}
+			select {
+			case events <- event:
+				sent[event.ID] = true
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, event := range recorded {
+			if !send(event) {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-live:
+				if !send(event) {
+					return
+				}
+			case <-ticker.C:
+				polled, err := s.paymentEvents.List(ctx, tenantID, providerName, paymentID)
+				if err != nil {
+					continue
+				}
+				for _, event := range polled {
+					if !send(event) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// nextPaymentEvent receives the next event of a watch
+func nextPaymentEvent(t *testing.T, events <-chan PaymentEvent) PaymentEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Expected an event, the watch ended")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event")
+	}
+	return PaymentEvent{}
+}
+
+func TestPaymentService_WatchPaymentEvents(t *testing.T) {
+	store := &memoryPaymentEventStore{}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentEventStore(store)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tenantctx.IDKey, "9143"))
+	defer cancel()
+	_ = store.Append(ctx, &PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEventCreated})
+	_ = store.Append(ctx, &PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEvent3DInitiated})
+
+	// A poll interval long enough that only events recorded by this instance arrive at once
+	events, err := service.WatchPaymentEvents(ctx, "iyzico", "pay_1", 1, time.Hour)
+	if err != nil {
+		t.Fatalf("WatchPaymentEvents failed: %v", err)
+	}
+	if event := nextPaymentEvent(t, events); event.ID != 2 || event.Type != PaymentEvent3DInitiated {
+		t.Errorf("Expected the events after the last event ID first, got %+v", event)
+	}
+
+	// Events of other payments are not sent
+	service.recordPaymentEvent(ctx, PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_2", Type: PaymentEventCreated}, nil)
+	service.recordPaymentEvent(ctx, PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEvent3DCompleted, Success: true}, nil)
+	if event := nextPaymentEvent(t, events); event.Type != PaymentEvent3DCompleted || event.PaymentID != "pay_1" {
+		t.Errorf("Expected the recorded 3D completion, got %+v", event)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected no more events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the watch to end with its context")
+	}
+}
+
+func TestPaymentService_WatchPaymentEvents_PollsOtherInstances(t *testing.T) {
+	store := &memoryPaymentEventStore{}
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetPaymentEventStore(store)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tenantctx.IDKey, "9143"))
+	defer cancel()
+	_ = store.Append(ctx, &PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEvent3DInitiated})
+
+	events, err := service.WatchPaymentEvents(ctx, "iyzico", "pay_1", 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchPaymentEvents failed: %v", err)
+	}
+	nextPaymentEvent(t, events)
+
+	// An event recorded by another instance reaches the store only
+	_ = store.Append(ctx, &PaymentEvent{TenantID: 9143, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEvent3DCompleted})
+	if event := nextPaymentEvent(t, events); event.Type != PaymentEvent3DCompleted {
+		t.Errorf("Expected the polled 3D completion, got %+v", event)
+	}
+}
+
+func TestPaymentService_WatchPaymentEvents_Unknown(t *testing.T) {
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9143")
+
+	if _, err := service.WatchPaymentEvents(ctx, "iyzico", "pay_1", 0, time.Second); !errors.Is(err, ErrPaymentEventsUnavailable) {
+		t.Errorf("Expected ErrPaymentEventsUnavailable without a store, got %v", err)
+	}
+
+	store := &memoryPaymentEventStore{}
+	service.SetPaymentEventStore(store)
+	_ = store.Append(ctx, &PaymentEvent{TenantID: 9144, Provider: "iyzico", PaymentID: "pay_1", Type: PaymentEventCreated})
+	// Another tenant's payment is not found
+	if _, err := service.WatchPaymentEvents(ctx, "iyzico", "pay_1", 0, time.Second); !errors.Is(err, ErrPaymentNotFound) {
+		t.Errorf("Expected ErrPaymentNotFound, got %v", err)
+	}
+	if len(service.eventBroker.watchers) != 0 {
+		t.Error("Expected the failed watch to unsubscribe")
+	}
+}
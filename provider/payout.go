@@ -0,0 +1,419 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// Payouts send money from the merchant's balance at a provider to a bank account or wallet.
+// Only some providers can make them, through the OPTIONAL PayoutProvider capability; the core
+// PaymentProvider interface is not extended.
+
+const (
+	PayoutStatusPending    = "pending"
+	PayoutStatusProcessing = "processing"
+	PayoutStatusCompleted  = "completed"
+	PayoutStatusFailed     = "failed"
+)
+
+var (
+	// ErrPayoutUnsupported is returned when a payout is requested from a provider that does
+	// not implement PayoutProvider
+	ErrPayoutUnsupported = errors.New("provider does not support payouts")
+	// ErrPayoutNotFound is returned for a payout that does not exist for the tenant
+	ErrPayoutNotFound = errors.New("payout not found")
+	// ErrPayoutInvalid is returned for a payout request that cannot be sent
+	ErrPayoutInvalid = errors.New("invalid payout")
+)
+
+// PayoutRecipient is where a payout is sent. Exactly one of IBAN, AccountNumber, PhoneNumber
+// and Email must be set; providers tell which of them they accept.
+type PayoutRecipient struct {
+	Name string `json:"name,omitempty"`
+	IBAN string `json:"iban,omitempty"`
+	// AccountNumber is a wallet account at the provider, e.g. a Papara number
+	AccountNumber string `json:"accountNumber,omitempty"`
+	PhoneNumber   string `json:"phoneNumber,omitempty"`
+	Email         string `json:"email,omitempty"`
+	// NationalID makes the provider check that the account belongs to this person
+	NationalID string `json:"nationalId,omitempty"`
+}
+
+// destinations returns how many destinations the recipient sets
+func (r PayoutRecipient) destinations() int {
+	count := 0
+	for _, value := range []string{r.IBAN, r.AccountNumber, r.PhoneNumber, r.Email} {
+		if strings.TrimSpace(value) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// PayoutRequest is a payout as sent to the provider. ID is GoPay's payout ID; providers send
+// it as their reference so a retried request is not paid out twice.
+type PayoutRequest struct {
+	ID          string          `json:"id"`
+	Amount      float64         `json:"amount"`
+	Currency    string          `json:"currency"`
+	Recipient   PayoutRecipient `json:"recipient"`
+	Description string          `json:"description,omitempty"`
+	LogID       int64           `json:"logId,omitempty"`
+}
+
+// PayoutResponse is the provider's answer to a payout or payout status request
+type PayoutResponse struct {
+	Success          bool    `json:"success"`
+	Status           string  `json:"status"`
+	ProviderPayoutID string  `json:"providerPayoutId,omitempty"`
+	Fee              float64 `json:"fee,omitempty"`
+	Message          string  `json:"message,omitempty"`
+	ErrorCode        string  `json:"errorCode,omitempty"`
+	ProviderResponse any     `json:"providerResponse,omitempty"`
+}
+
+// PayoutProvider is an OPTIONAL capability interface implemented only by providers that can
+// send money out of the merchant's balance. Callers type-assert for it.
+type PayoutProvider interface {
+	// CreatePayout sends a payout
+	CreatePayout(ctx context.Context, request PayoutRequest) (*PayoutResponse, error)
+
+	// GetPayoutStatus returns the current status of a payout by the provider's payout ID
+	GetPayoutStatus(ctx context.Context, providerPayoutID string) (*PayoutResponse, error)
+}
+
+// Payout is a payout as tracked by GoPay
+type Payout struct {
+	ID               string          `json:"id"`
+	TenantID         int             `json:"tenantId"`
+	Provider         string          `json:"provider"`
+	Environment      string          `json:"environment"`
+	Amount           float64         `json:"amount"`
+	Currency         string          `json:"currency"`
+	Recipient        PayoutRecipient `json:"recipient"`
+	Description      string          `json:"description,omitempty"`
+	Status           string          `json:"status"`
+	ProviderPayoutID string          `json:"providerPayoutId,omitempty"`
+	Fee              float64         `json:"fee,omitempty"`
+	ErrorCode        string          `json:"errorCode,omitempty"`
+	LastError        string          `json:"lastError,omitempty"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        *time.Time      `json:"updatedAt,omitempty"`
+}
+
+// final tells whether the payout status can no longer change
+func (p *Payout) final() bool {
+	return p.Status == PayoutStatusCompleted || p.Status == PayoutStatusFailed
+}
+
+// PayoutStore persists payouts and their status
+type PayoutStore interface {
+	Create(ctx context.Context, payout *Payout) error
+	Get(ctx context.Context, tenantID int, id string) (*Payout, error)
+	List(ctx context.Context, tenantID int) ([]Payout, error)
+
+	// Update records the payout's status and provider details
+	Update(ctx context.Context, payout *Payout) error
+}
+
+// PayoutService sends payouts through providers and tracks their status
+type PayoutService struct {
+	store  PayoutStore
+	logger PaymentLogger
+	now    func() time.Time
+}
+
+// NewPayoutService creates a payout service
+func NewPayoutService(store PayoutStore, logger PaymentLogger) *PayoutService {
+	return &PayoutService{store: store, logger: logger, now: time.Now}
+}
+
+// CreatePayout records a payout and sends it through the provider. The payout is stored
+// before it is sent, so it can be looked up whatever the provider answers.
+func (s *PayoutService) CreatePayout(ctx context.Context, environment, providerName string, request PayoutRequest) (*Payout, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := GetProvider(tenantID, providerName, environment)
+	if err != nil {
+		return nil, err
+	}
+	payer, ok := provider.(PayoutProvider)
+	if !ok {
+		return nil, ErrPayoutUnsupported
+	}
+
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrPayoutInvalid)
+	}
+	request.Currency = strings.ToUpper(strings.TrimSpace(request.Currency))
+	if !IsCurrencySupported(provider, request.Currency) {
+		return nil, fmt.Errorf("%w: currency %s is not supported by %s", ErrPayoutInvalid, request.Currency, providerName)
+	}
+	if request.Recipient.destinations() != 1 {
+		return nil, fmt.Errorf("%w: recipient must have exactly one of iban, accountNumber, phoneNumber or email", ErrPayoutInvalid)
+	}
+
+	payout := &Payout{
+		ID:          "po" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		Amount:      request.Amount,
+		Currency:    request.Currency,
+		Recipient:   request.Recipient,
+		Description: request.Description,
+		Status:      PayoutStatusPending,
+		CreatedAt:   s.now(),
+	}
+	if err := s.store.Create(ctx, payout); err != nil {
+		return nil, err
+	}
+	request.ID = payout.ID
+
+	startTime := time.Now()
+	logID, err := s.logger.LogRequest(ctx, tenantID, providerName, "POST", "/payout", request, "", "")
+	if err != nil {
+		logger.Warn("Failed to log payout request", logger.LogContext{
+			TenantID: strconv.Itoa(tenantID),
+			Provider: providerName,
+			Fields: map[string]any{
+				"payout_id": payout.ID,
+				"error":     err.Error(),
+			},
+		})
+	}
+	request.LogID = logID
+
+	response, err := payer.CreatePayout(ctx, request)
+	s.logResult(ctx, payout, logID, response, err, time.Since(startTime).Milliseconds())
+
+	switch {
+	case errors.Is(err, ErrResponseUnparseable):
+		// The payout may have been made; its status stays unknown until the provider is asked
+		payout.Status, payout.LastError = PayoutStatusProcessing, err.Error()
+	case err != nil:
+		payout.Status, payout.LastError = PayoutStatusFailed, err.Error()
+	default:
+		applyPayoutResponse(payout, response)
+	}
+	if err := s.update(ctx, payout); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// GetPayout returns one of the tenant's payouts. A payout that is not final yet is refreshed
+// from the provider first.
+func (s *PayoutService) GetPayout(ctx context.Context, id string) (*Payout, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payout, err := s.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if payout.final() || payout.ProviderPayoutID == "" {
+		return payout, nil
+	}
+
+	provider, err := GetProvider(tenantID, payout.Provider, payout.Environment)
+	if err != nil {
+		return nil, err
+	}
+	payer, ok := provider.(PayoutProvider)
+	if !ok {
+		return payout, nil
+	}
+
+	response, err := payer.GetPayoutStatus(ctx, payout.ProviderPayoutID)
+	if err != nil {
+		// The stored status is still the best answer
+		logger.Warn("Failed to refresh payout status", logger.LogContext{
+			TenantID: strconv.Itoa(tenantID),
+			Provider: payout.Provider,
+			Fields: map[string]any{
+				"payout_id": payout.ID,
+				"error":     err.Error(),
+			},
+		})
+		return payout, nil
+	}
+	applyPayoutResponse(payout, response)
+	if err := s.update(ctx, payout); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// ListPayouts returns the tenant's payouts
+func (s *PayoutService) ListPayouts(ctx context.Context) ([]Payout, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, tenantID)
+}
+
+// applyPayoutResponse copies the provider's answer onto the payout
+func applyPayoutResponse(payout *Payout, response *PayoutResponse) {
+	if response == nil {
+		return
+	}
+	if response.ProviderPayoutID != "" {
+		payout.ProviderPayoutID = response.ProviderPayoutID
+	}
+	if response.Fee > 0 {
+		payout.Fee = response.Fee
+	}
+	payout.Status = response.Status
+	if payout.Status == "" {
+		payout.Status = PayoutStatusFailed
+		if response.Success {
+			payout.Status = PayoutStatusProcessing
+		}
+	}
+	payout.ErrorCode, payout.LastError = response.ErrorCode, response.Message
+	if response.Success {
+		payout.ErrorCode, payout.LastError = "", ""
+	}
+}
+
+func (s *PayoutService) update(ctx context.Context, payout *Payout) error {
+	now := s.now()
+	payout.UpdatedAt = &now
+	return s.store.Update(ctx, payout)
+}
+
+// logResult logs the provider's answer to a payout request
+func (s *PayoutService) logResult(ctx context.Context, payout *Payout, logID int64, response *PayoutResponse, err error, processingMs int64) {
+	if logID <= 0 {
+		return
+	}
+	var logErr error
+	if err != nil {
+		logErr = s.logger.LogError(ctx, logID, "PAYOUT_ERROR", err.Error(), processingMs)
+	} else {
+		logErr = s.logger.LogResponse(ctx, logID, response, processingMs)
+	}
+	if logErr != nil {
+		logger.Warn("Failed to log payout response", logger.LogContext{
+			TenantID: strconv.Itoa(payout.TenantID),
+			Provider: payout.Provider,
+			Fields: map[string]any{
+				"log_id":    logID,
+				"payout_id": payout.ID,
+				"error":     logErr.Error(),
+			},
+		})
+	}
+}
+
+// PostgresPayoutStore keeps payouts in the payouts table
+type PostgresPayoutStore struct {
+	db *sql.DB
+}
+
+// NewPostgresPayoutStore creates a store over the shared *sql.DB connection
+func NewPostgresPayoutStore(db *sql.DB) *PostgresPayoutStore {
+	return &PostgresPayoutStore{db: db}
+}
+
+const payoutColumns = `id, tenant_id, provider, environment, amount, currency, recipient, COALESCE(description, ''),
+		status, COALESCE(provider_payout_id, ''), fee, COALESCE(error_code, ''), COALESCE(last_error, ''),
+		created_at, updated_at`
+
+// Create inserts a payout
+func (r *PostgresPayoutStore) Create(ctx context.Context, payout *Payout) error {
+	recipient, err := json.Marshal(payout.Recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encode payout recipient: %w", err)
+	}
+
+	query := `
+		INSERT INTO payouts (id, tenant_id, provider, environment, amount, currency, recipient, description, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err = r.db.ExecContext(ctx, query, payout.ID, payout.TenantID, payout.Provider, payout.Environment, payout.Amount,
+		payout.Currency, recipient, nullString(payout.Description), payout.Status, payout.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payout: %w", err)
+	}
+	return nil
+}
+
+// Get returns a payout, scoped to the tenant
+func (r *PostgresPayoutStore) Get(ctx context.Context, tenantID int, id string) (*Payout, error) {
+	query := `SELECT ` + payoutColumns + ` FROM payouts WHERE id = $1 AND tenant_id = $2`
+	payout, err := scanPayout(r.db.QueryRowContext(ctx, query, id, tenantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payout: %w", err)
+	}
+	return payout, nil
+}
+
+// List returns the tenant's payouts, newest first
+func (r *PostgresPayoutStore) List(ctx context.Context, tenantID int) ([]Payout, error) {
+	query := `SELECT ` + payoutColumns + ` FROM payouts WHERE tenant_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := []Payout{}
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payout: %w", err)
+		}
+		payouts = append(payouts, *payout)
+	}
+	return payouts, rows.Err()
+}
+
+// Update records a payout's status and provider details
+func (r *PostgresPayoutStore) Update(ctx context.Context, payout *Payout) error {
+	query := `
+		UPDATE payouts
+		SET status = $2, provider_payout_id = $3, fee = $4, error_code = $5, last_error = $6, updated_at = $7
+		WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, payout.ID, payout.Status, nullString(payout.ProviderPayoutID), payout.Fee,
+		nullString(payout.ErrorCode), nullString(payout.LastError), payout.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update payout: %w", err)
+	}
+	return nil
+}
+
+func scanPayout(row rowScanner) (*Payout, error) {
+	var p Payout
+	var recipient []byte
+	var updatedAt sql.NullTime
+	err := row.Scan(&p.ID, &p.TenantID, &p.Provider, &p.Environment, &p.Amount, &p.Currency, &recipient, &p.Description,
+		&p.Status, &p.ProviderPayoutID, &p.Fee, &p.ErrorCode, &p.LastError, &p.CreatedAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(recipient, &p.Recipient); err != nil {
+		return nil, fmt.Errorf("failed to decode payout recipient: %w", err)
+	}
+	if updatedAt.Valid {
+		p.UpdatedAt = &updatedAt.Time
+	}
+	return &p, nil
+}
@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryPayoutStore is an in-memory PayoutStore for payout service tests.
+type memoryPayoutStore struct {
+	mu      sync.Mutex
+	payouts map[string]Payout
+}
+
+func newMemoryPayoutStore() *memoryPayoutStore {
+	return &memoryPayoutStore{payouts: make(map[string]Payout)}
+}
+
+func (m *memoryPayoutStore) Create(_ context.Context, payout *Payout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payouts[payout.ID] = *payout
+	return nil
+}
+
+func (m *memoryPayoutStore) Get(_ context.Context, tenantID int, id string) (*Payout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payout, ok := m.payouts[id]
+	if !ok || payout.TenantID != tenantID {
+		return nil, ErrPayoutNotFound
+	}
+	return &payout, nil
+}
+
+func (m *memoryPayoutStore) List(_ context.Context, tenantID int) ([]Payout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	payouts := []Payout{}
+	for _, payout := range m.payouts {
+		if payout.TenantID == tenantID {
+			payouts = append(payouts, payout)
+		}
+	}
+	return payouts, nil
+}
+
+func (m *memoryPayoutStore) Update(_ context.Context, payout *Payout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payouts[payout.ID] = *payout
+	return nil
+}
+
+// payoutTestProvider answers payouts with a fixed response and reports status on request
+type payoutTestProvider struct {
+	PaymentProvider
+	response *PayoutResponse
+	err      error
+	status   *PayoutResponse
+	requests []PayoutRequest
+}
+
+func (p *payoutTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *payoutTestProvider) CreatePayout(_ context.Context, request PayoutRequest) (*PayoutResponse, error) {
+	p.requests = append(p.requests, request)
+	return p.response, p.err
+}
+
+func (p *payoutTestProvider) GetPayoutStatus(context.Context, string) (*PayoutResponse, error) {
+	return p.status, nil
+}
+
+func payoutRequest() PayoutRequest {
+	return PayoutRequest{Amount: 250, Currency: "try", Recipient: PayoutRecipient{Name: "Ada Lovelace", IBAN: "TR330006100519786457841326"}}
+}
+
+func TestPayoutService_CreatePayout(t *testing.T) {
+	const tenantID, providerName = 9128, "payouttest"
+
+	tests := []struct {
+		name           string
+		response       *PayoutResponse
+		err            error
+		expectedStatus string
+	}{
+		{"completed", &PayoutResponse{Success: true, Status: PayoutStatusCompleted, ProviderPayoutID: "mp_1", Fee: 1.5}, nil, PayoutStatusCompleted},
+		{"declined", &PayoutResponse{Success: false, Status: PayoutStatusFailed, ErrorCode: "insufficient_balance", Message: "Insufficient balance"}, nil, PayoutStatusFailed},
+		{"request failed", nil, errors.New("connection refused"), PayoutStatusFailed},
+		{"unparseable response", nil, ErrResponseUnparseable, PayoutStatusProcessing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &payoutTestProvider{response: tt.response, err: tt.err}
+			GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+			t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+			store := newMemoryPayoutStore()
+			paymentLogger := &recordingPaymentLogger{}
+			service := NewPayoutService(store, paymentLogger)
+			ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9128")
+
+			payout, err := service.CreatePayout(ctx, "sandbox", providerName, payoutRequest())
+			if err != nil {
+				t.Fatalf("CreatePayout failed: %v", err)
+			}
+			if payout.Status != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, payout.Status)
+			}
+			if len(fake.requests) != 1 || fake.requests[0].ID != payout.ID || fake.requests[0].Currency != "TRY" {
+				t.Fatalf("Expected the payout to be sent with its ID, got %+v", fake.requests)
+			}
+
+			stored, err := store.Get(ctx, tenantID, payout.ID)
+			if err != nil {
+				t.Fatalf("Payout was not stored: %v", err)
+			}
+			if stored.Status != tt.expectedStatus || stored.UpdatedAt == nil {
+				t.Errorf("Expected stored status %s, got %+v", tt.expectedStatus, stored)
+			}
+			if tt.response != nil && (stored.ProviderPayoutID != tt.response.ProviderPayoutID || stored.ErrorCode != tt.response.ErrorCode) {
+				t.Errorf("Expected provider details to be stored, got %+v", stored)
+			}
+			if paymentLogger.requests != 1 {
+				t.Errorf("Expected the payout request to be logged, got %d", paymentLogger.requests)
+			}
+		})
+	}
+}
+
+func TestPayoutService_CreatePayout_Rejected(t *testing.T) {
+	const tenantID = 9129
+
+	GetProviderCache().Set(tenantID, "payouttest", "sandbox", &payoutTestProvider{})
+	GetProviderCache().Set(tenantID, "nopayouts", "sandbox", &riskTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "payouttest", "sandbox")
+		GetProviderCache().Delete(tenantID, "nopayouts", "sandbox")
+	})
+
+	service := NewPayoutService(newMemoryPayoutStore(), &recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9129")
+
+	if _, err := service.CreatePayout(ctx, "sandbox", "nopayouts", payoutRequest()); !errors.Is(err, ErrPayoutUnsupported) {
+		t.Errorf("Expected ErrPayoutUnsupported, got %v", err)
+	}
+
+	invalid := map[string]func(*PayoutRequest){
+		"no amount":         func(r *PayoutRequest) { r.Amount = 0 },
+		"unsupported cur":   func(r *PayoutRequest) { r.Currency = "GBP" },
+		"no destination":    func(r *PayoutRequest) { r.Recipient.IBAN = "" },
+		"two destinations":  func(r *PayoutRequest) { r.Recipient.Email = "ada@example.com" },
+		"blank destination": func(r *PayoutRequest) { r.Recipient.IBAN = "  " },
+	}
+	for name, mutate := range invalid {
+		request := payoutRequest()
+		mutate(&request)
+		if _, err := service.CreatePayout(ctx, "sandbox", "payouttest", request); !errors.Is(err, ErrPayoutInvalid) {
+			t.Errorf("%s: expected ErrPayoutInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestPayoutService_GetPayout_RefreshesPendingStatus(t *testing.T) {
+	const tenantID, providerName = 9130, "payouttest"
+
+	fake := &payoutTestProvider{
+		response: &PayoutResponse{Success: true, Status: PayoutStatusProcessing, ProviderPayoutID: "tr_1"},
+		status:   &PayoutResponse{Success: true, Status: PayoutStatusCompleted, ProviderPayoutID: "tr_1", Fee: 2},
+	}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	store := newMemoryPayoutStore()
+	service := NewPayoutService(store, &recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9130")
+
+	payout, err := service.CreatePayout(ctx, "sandbox", providerName, payoutRequest())
+	if err != nil {
+		t.Fatalf("CreatePayout failed: %v", err)
+	}
+	if payout.Status != PayoutStatusProcessing {
+		t.Fatalf("Expected processing payout, got %s", payout.Status)
+	}
+
+	payout, err = service.GetPayout(ctx, payout.ID)
+	if err != nil {
+		t.Fatalf("GetPayout failed: %v", err)
+	}
+	if payout.Status != PayoutStatusCompleted || payout.Fee != 2 {
+		t.Errorf("Expected refreshed completed payout with fee 2, got %+v", payout)
+	}
+	if stored, _ := store.Get(ctx, tenantID, payout.ID); stored.Status != PayoutStatusCompleted {
+		t.Errorf("Expected the refreshed status to be stored, got %s", stored.Status)
+	}
+
+	// Payouts are tenant-scoped
+	otherTenant := context.WithValue(context.Background(), tenantctx.IDKey, "9131")
+	if _, err := service.GetPayout(otherTenant, payout.ID); !errors.Is(err, ErrPayoutNotFound) {
+		t.Errorf("Expected ErrPayoutNotFound for another tenant, got %v", err)
+	}
+}
@@ -35,8 +35,8 @@ const (
 	sessionTypePayment = "PAYMENTSESSION"
 	sessionTypeQuery   = "QUERYOPERATIONSESSION"
 
-	// Currency Codes
-	currencyCodeTRY = "TRY"
+	// Default currency, sent as its ISO 4217 alpha code
+	defaultCurrency = "TRY"
 )
 
 // PaytenProvider implements the provider.PaymentProvider interface for Payten
@@ -114,6 +114,22 @@ func (p *PaytenProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("payten", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by Payten.
+// The CURRENCY parameter is always sent as TRY.
+func (p *PaytenProvider) SupportedCurrencies() []string {
+	return []string{"TRY"}
+}
+
+// HealthCheckEndpoint returns the Payten API. The ACTION parameter selects the operation,
+// so a request without one changes nothing.
+func (p *PaytenProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL
+}
+
 // Initialize sets up the Payten payment provider with authentication credentials
 func (p *PaytenProvider) Initialize(conf map[string]string) error {
 	p.merchant = conf["merchant"]
@@ -135,7 +151,7 @@ func (p *PaytenProvider) Initialize(conf map[string]string) error {
 
 	p.threeDGatewayURL = api3DGatewayURL
 
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("payten"))
 
 	return nil
 }
@@ -154,7 +170,7 @@ func (p *PaytenProvider) GetCommission(ctx context.Context, request provider.Com
 func (p *PaytenProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("payten: invalid payment request: %w", err)
+		return nil, fmt.Errorf("payten: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processDirectPostNon3D(ctx, request)
@@ -164,7 +180,7 @@ func (p *PaytenProvider) CreatePayment(ctx context.Context, request provider.Pay
 func (p *PaytenProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("payten: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("payten: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processDirectPost3D(ctx, request)
@@ -279,7 +295,7 @@ func (p *PaytenProvider) RefundPayment(ctx context.Context, request provider.Ref
 	}
 
 	// Build refund request
-	formData := p.buildRefundRequest(originalOrderId, request.RefundAmount)
+	formData := p.buildRefundRequest(originalOrderId, request.RefundAmount, request.Currency)
 
 	// Send request
 	resp, err := p.sendMultipartRequest(ctx, formData)
@@ -494,7 +510,7 @@ func (p *PaytenProvider) getSessionToken(ctx context.Context, request provider.P
 		"SESSIONTYPE":       sessionTypePayment,
 		"MERCHANTPAYMENTID": merchantPaymentID,
 		"AMOUNT":            amountStr,
-		"CURRENCY":          currencyCodeTRY,
+		"CURRENCY":          p.currencyCode(request.Currency),
 		"RETURNURL":         gopayCallbackURL,
 		"EXTRA[SaveCard]":   "NO",
 	}
@@ -618,7 +634,7 @@ func (p *PaytenProvider) buildSaleRequest(request provider.PaymentRequest, merch
 		"MERCHANTPASSWORD":                p.merchantPassword,
 		"MERCHANTPAYMENTID":               merchantPaymentID,
 		"AMOUNT":                          amountStr,
-		"CURRENCY":                        currencyCodeTRY,
+		"CURRENCY":                        p.currencyCode(request.Currency),
 		"CUSTOMEREMAIL":                   request.Customer.Email,
 		"CUSTOMERNAME":                    customerName,
 		"CUSTOMERPHONE":                   request.Customer.PhoneNumber,
@@ -690,7 +706,7 @@ func (p *PaytenProvider) buildVoidRequest(merchantPaymentID string) map[string]s
 }
 
 // buildRefundRequest builds form parameters for REFUND action
-func (p *PaytenProvider) buildRefundRequest(merchantPaymentID string, refundAmount float64) map[string]string {
+func (p *PaytenProvider) buildRefundRequest(merchantPaymentID string, refundAmount float64, currency string) map[string]string {
 	amountStr := fmt.Sprintf("%.2f", refundAmount)
 	return map[string]string{
 		"ACTION":            actionRefund,
@@ -699,8 +715,17 @@ func (p *PaytenProvider) buildRefundRequest(merchantPaymentID string, refundAmou
 		"MERCHANTPASSWORD":  p.merchantPassword,
 		"MERCHANTPAYMENTID": merchantPaymentID,
 		"AMOUNT":            amountStr,
-		"CURRENCY":          currencyCodeTRY,
+		"CURRENCY":          p.currencyCode(currency),
+	}
+}
+
+// currencyCode returns the ISO 4217 alpha code Payten expects, using TRY when currency is
+// empty or unknown
+func (p *PaytenProvider) currencyCode(currency string) string {
+	if code, err := provider.CurrencyCode(currency, provider.CurrencyFormatAlpha); err == nil {
+		return code
 	}
+	return defaultCurrency
 }
 
 // calculateHash calculates SHA512 hash for Payten form (ver3 format)
@@ -1 +1,46 @@
 package payten
+
+import "testing"
+
+func TestPaytenProvider_CurrencyCode(t *testing.T) {
+	p := &PaytenProvider{}
+
+	tests := map[string]string{
+		"TRY": "TRY",
+		"try": "TRY",
+		"949": "TRY",
+		"":    "TRY",
+		"XYZ": "TRY",
+		"840": "USD",
+	}
+
+	for currency, expected := range tests {
+		if code := p.currencyCode(currency); code != expected {
+			t.Errorf("currencyCode(%q) = %q, want %q", currency, code, expected)
+		}
+	}
+}
+
+func TestPaytenProvider_BuildRefundRequestCurrency(t *testing.T) {
+	p := &PaytenProvider{}
+
+	params := p.buildRefundRequest("order123", 10.5, "")
+	if params["CURRENCY"] != "TRY" {
+		t.Errorf("Expected CURRENCY 'TRY', got '%s'", params["CURRENCY"])
+	}
+	if params["AMOUNT"] != "10.50" {
+		t.Errorf("Expected AMOUNT '10.50', got '%s'", params["AMOUNT"])
+	}
+}
+
+func TestPaytenProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &PaytenProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
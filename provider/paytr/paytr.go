@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,6 +28,9 @@ const (
 	endpointInstallmentRate = "/odeme/api/installment-rates"
 	endpointBINQuery        = "/odeme/api/bin-detail"
 
+	// checkoutURLFormat is PayTR's payment page for an iFrame token
+	checkoutURLFormat = "https://www.paytr.com/odeme/guvenlik/%s"
+
 	// Default Values
 	defaultCurrency = "TL"
 	defaultLang     = "tr"
@@ -98,6 +100,17 @@ func (p *PayTRProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("paytr", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by PayTR.
+// Matches the codes getCurrency maps to PayTR values (TRY is sent as TL).
+func (p *PayTRProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the PayTR installment rates endpoint
+func (p *PayTRProvider) HealthCheckEndpoint() string {
+	return apiProductionURL + endpointInstallmentRate
+}
+
 // Initialize sets up the PayTR payment provider with authentication credentials
 func (p *PayTRProvider) Initialize(conf map[string]string) error {
 	p.merchantID = conf["merchantId"]
@@ -115,7 +128,7 @@ func (p *PayTRProvider) Initialize(conf map[string]string) error {
 	p.baseURL = apiProductionURL
 
 	// Initialize HTTP client
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("paytr"))
 
 	return nil
 }
@@ -134,7 +147,12 @@ func (p *PayTRProvider) GetCommission(ctx context.Context, request provider.Comm
 func (p *PayTRProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("paytr: invalid payment request: %w", err)
+		return nil, fmt.Errorf("paytr: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	// Without card details the customer pays on PayTR's hosted page
+	if request.CardInfo.IsEmpty() {
+		return p.processHostedPayment(ctx, request)
 	}
 
 	return p.processDirectPayment(ctx, request, false)
@@ -144,7 +162,7 @@ func (p *PayTRProvider) CreatePayment(ctx context.Context, request provider.Paym
 func (p *PayTRProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("paytr: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("paytr: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processIFramePayment(ctx, request)
@@ -282,6 +300,12 @@ func (p *PayTRProvider) ValidateWebhook(ctx context.Context, data, headers map[s
 	return true, result, nil
 }
 
+// WebhookPaymentReference returns the merchant_oid of the payment twice: PayTR knows the
+// payment by the GoPay payment ID it was created with
+func (p *PayTRProvider) WebhookPaymentReference(data map[string]string) (string, string) {
+	return data["paymentId"], data["paymentId"]
+}
+
 // processDirectPayment handles direct payment (Non-3D)
 func (p *PayTRProvider) processDirectPayment(ctx context.Context, request provider.PaymentRequest, _ bool) (*provider.PaymentResponse, error) {
 	// PayTR Direct API requires different implementation
@@ -289,8 +313,29 @@ func (p *PayTRProvider) processDirectPayment(ctx context.Context, request provid
 	return p.processIFramePayment(ctx, request)
 }
 
+// processHostedPayment creates a PayTR payment page for the customer. The payment is
+// completed by PayTR's callback (merchant_ok_url / merchant_fail_url) and webhook.
+func (p *PayTRProvider) processHostedPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	response, merchantOid, err := p.requestIFrameToken(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.mapToHostedResponse(response, merchantOid), nil
+}
+
 // processIFramePayment handles iFrame payment (with 3D secure support)
 func (p *PayTRProvider) processIFramePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	response, merchantOid, err := p.requestIFrameToken(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.mapToIFrameResponse(response, merchantOid), nil
+}
+
+// requestIFrameToken gets a payment token from PayTR, used by both the iFrame and the hosted page
+func (p *PayTRProvider) requestIFrameToken(ctx context.Context, request provider.PaymentRequest) (map[string]any, string, error) {
 	// Convert amount to PayTR format (multiply by 100 for kuruş)
 	amountInKurus := int64(request.Amount * 100)
 
@@ -337,7 +382,7 @@ func (p *PayTRProvider) processIFramePayment(ctx context.Context, request provid
 	// Get iFrame token
 	response, err := p.sendRequest(ctx, endpointIFrameToken, data)
 	if err != nil {
-		return nil, fmt.Errorf("paytr: failed to get iframe token: %w", err)
+		return nil, "", fmt.Errorf("paytr: failed to get iframe token: %w", err)
 	}
 
 	// add provider request to client request
@@ -345,7 +390,7 @@ func (p *PayTRProvider) processIFramePayment(ctx context.Context, request provid
 		_ = provider.AddProviderRequestToClientRequest("paytr", "providerRequest", reqMap, p.logID)
 	}
 
-	return p.mapToIFrameResponse(response, merchantOid), nil
+	return response, merchantOid, nil
 }
 
 // validatePaymentRequest validates the payment request
@@ -380,17 +425,11 @@ func (p *PayTRProvider) validatePaymentRequest(request provider.PaymentRequest,
 // Helper methods
 
 func (p *PayTRProvider) getCurrency(currency string) string {
-	if currency == "" {
-		return defaultCurrency
-	}
-	// PayTR supports TL, USD, EUR
-	switch strings.ToUpper(currency) {
-	case "TRY", "TL":
-		return "TL"
-	case "USD":
-		return "USD"
-	case "EUR":
-		return "EUR"
+	// PayTR supports TL, USD, EUR; TRY (alpha or numeric 949) is sent as TL
+	code, _ := provider.CurrencyCode(currency, provider.CurrencyFormatAlpha)
+	switch code {
+	case "USD", "EUR":
+		return code
 	default:
 		return defaultCurrency
 	}
@@ -486,8 +525,8 @@ func (p *PayTRProvider) mapToIFrameResponse(response map[string]any, merchantOid
 				// PayTR returns a token that should be used to display the iframe
 				paymentResp.Success = true
 				paymentResp.Status = provider.StatusPending
-				paymentResp.HTML = fmt.Sprintf(`<iframe src="https://www.paytr.com/odeme/guvenlik/%s" id="paytriframe" frameborder="0" scrolling="no" style="width: 100%%; height: 400px;"></iframe>`, token)
-				paymentResp.RedirectURL = fmt.Sprintf("https://www.paytr.com/odeme/guvenlik/%s", token)
+				paymentResp.RedirectURL = fmt.Sprintf(checkoutURLFormat, token)
+				paymentResp.HTML = fmt.Sprintf(`<iframe src="%s" id="paytriframe" frameborder="0" scrolling="no" style="width: 100%%; height: 400px;"></iframe>`, paymentResp.RedirectURL)
 			}
 		} else {
 			paymentResp.Success = false
@@ -501,6 +540,18 @@ func (p *PayTRProvider) mapToIFrameResponse(response map[string]any, merchantOid
 	return paymentResp
 }
 
+// mapToHostedResponse returns the PayTR payment page as HostedCheckoutURL instead of an iframe
+func (p *PayTRProvider) mapToHostedResponse(response map[string]any, merchantOid string) *provider.PaymentResponse {
+	paymentResp := p.mapToIFrameResponse(response, merchantOid)
+	if paymentResp.Success {
+		paymentResp.HostedCheckoutURL = paymentResp.RedirectURL
+		paymentResp.RedirectURL = ""
+		paymentResp.HTML = ""
+	}
+
+	return paymentResp
+}
+
 func (p *PayTRProvider) mapToPaymentResponse(response map[string]any, paymentID string) *provider.PaymentResponse {
 	now := time.Now()
 	paymentResp := &provider.PaymentResponse{
@@ -249,6 +249,16 @@ func TestPayTRProvider_GetCurrency(t *testing.T) {
 			currency: "usd",
 			expected: "USD",
 		},
+		{
+			name:     "Numeric TRY",
+			currency: "949",
+			expected: "TL",
+		},
+		{
+			name:     "Numeric EUR",
+			currency: "978",
+			expected: "EUR",
+		},
 	}
 
 	for _, tt := range tests {
@@ -675,6 +685,41 @@ func TestPayTRProvider_MapToIFrameResponse(t *testing.T) {
 	}
 }
 
+func TestPayTRProvider_MapToHostedResponse(t *testing.T) {
+	p := &PayTRProvider{}
+
+	t.Run("Successful token returns hosted checkout URL", func(t *testing.T) {
+		result := p.mapToHostedResponse(map[string]any{"status": "success", "token": "hosted-token-123"}, "order123")
+
+		if !result.Success || result.Status != provider.StatusPending {
+			t.Fatalf("Expected pending success, got success=%v status=%v", result.Success, result.Status)
+		}
+		if result.HostedCheckoutURL != "https://www.paytr.com/odeme/guvenlik/hosted-token-123" {
+			t.Errorf("Unexpected hosted checkout URL %s", result.HostedCheckoutURL)
+		}
+		if result.HTML != "" || result.RedirectURL != "" {
+			t.Errorf("Expected no iframe or redirect for hosted checkout, got html=%q redirect=%q", result.HTML, result.RedirectURL)
+		}
+		if outcome := provider.ResolveOutcome(result); outcome != provider.OutcomeRequiresHostedCheckout {
+			t.Errorf("Expected outcome %s, got %s", provider.OutcomeRequiresHostedCheckout, outcome)
+		}
+	})
+
+	t.Run("Token error returns no hosted checkout URL", func(t *testing.T) {
+		result := p.mapToHostedResponse(map[string]any{"status": "failed", "reason": "Invalid parameters"}, "order123")
+
+		if result.Success || result.Status != provider.StatusFailed {
+			t.Fatalf("Expected failure, got success=%v status=%v", result.Success, result.Status)
+		}
+		if result.HostedCheckoutURL != "" {
+			t.Errorf("Expected empty hosted checkout URL, got %s", result.HostedCheckoutURL)
+		}
+		if result.Message != "Invalid parameters" {
+			t.Errorf("Expected reason as message, got %s", result.Message)
+		}
+	})
+}
+
 func TestPayTRProvider_MapToPaymentResponse(t *testing.T) {
 	p := &PayTRProvider{}
 
@@ -940,3 +985,10 @@ func TestPayTRProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestPayTRProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &PayTRProvider{}
+	if got := p.HealthCheckEndpoint(); got != "https://www.paytr.com/odeme/api/installment-rates" {
+		t.Errorf("Expected installment rates endpoint, got %s", got)
+	}
+}
@@ -94,6 +94,20 @@ func (p *PayUProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("payu", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by PayU
+func (p *PayUProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the PayU payment status endpoint without a payment ID
+func (p *PayUProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + fmt.Sprintf(endpointPaymentStatus, "")
+}
+
 // Initialize sets up the PayU Turkey payment provider with authentication credentials
 func (p *PayUProvider) Initialize(conf map[string]string) error {
 	p.merchantID = conf["merchantId"]
@@ -113,7 +127,7 @@ func (p *PayUProvider) Initialize(conf map[string]string) error {
 	}
 
 	// Initialize HTTP client
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("payu"))
 
 	return nil
 }
@@ -132,7 +146,7 @@ func (p *PayUProvider) GetCommission(ctx context.Context, request provider.Commi
 func (p *PayUProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("payu: invalid payment request: %w", err)
+		return nil, fmt.Errorf("payu: invalid payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, false)
@@ -142,7 +156,7 @@ func (p *PayUProvider) CreatePayment(ctx context.Context, request provider.Payme
 func (p *PayUProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("payu: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("payu: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
@@ -914,3 +914,15 @@ func TestPayUProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestPayUProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &PayUProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+"/api/payment/" {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+"/api/payment/" {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PIX is Brazil's instant payment system. A PIX payment is not made with a card: the provider
+// returns a code the customer pays from their bank app, by scanning its QR image or pasting
+// its text. The payment stays pending until the provider reports it paid, or the code expires.
+
+// PaymentMethodTypePIX is the PaymentRequest.PaymentMethod, and PaymentMethodDetails.Type,
+// of PIX payments
+const PaymentMethodTypePIX = "pix"
+
+var (
+	// ErrPIXPaymentInvalid is returned for a PIX payment that cannot be made as sent
+	ErrPIXPaymentInvalid = errors.New("invalid pix payment")
+	// ErrPIXUnsupported is returned when a PIX payment is sent to a provider that does not
+	// implement PIXProvider
+	ErrPIXUnsupported = errors.New("provider does not support pix")
+)
+
+// PIXProvider is an OPTIONAL capability interface implemented by providers that take PIX
+// payments. Callers type-assert on it, like BNPLProvider.
+type PIXProvider interface {
+	// CreatePIXPayment creates a pending PIX payment and returns its code in
+	// PaymentResponse.PIX. The client shows the code and polls the payment's status.
+	CreatePIXPayment(ctx context.Context, request PaymentRequest) (*PaymentResponse, error)
+}
+
+// PIXCode is the code of a pending PIX payment
+type PIXCode struct {
+	// QRCode is the copy-and-paste text of the code, which its QR image encodes
+	QRCode string `json:"qrCode"`
+	// QRCodeImage is the QR image as a base64 encoded PNG
+	QRCodeImage string `json:"qrCodeImage,omitempty"`
+	// TicketURL is the provider's page showing the code, for clients that do not render it
+	TicketURL string     `json:"ticketUrl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// checkPIXPayment checks a PIX payment before it reaches provider
+func checkPIXPayment(provider PaymentProvider, request PaymentRequest) error {
+	if !request.CardInfo.IsEmpty() || request.WalletPayment != nil {
+		return fmt.Errorf("%w: the customer pays the code from their bank app, send no cardInfo or walletPayment", ErrPIXPaymentInvalid)
+	}
+	if !strings.EqualFold(request.Currency, "BRL") {
+		return fmt.Errorf("%w: pix payments are made in BRL", ErrPIXPaymentInvalid)
+	}
+	if request.Use3D {
+		return fmt.Errorf("%w: use3D is not supported", ErrPIXPaymentInvalid)
+	}
+	if request.PaymentType == PaymentTypeAuth || request.AutoCaptureAfter != "" {
+		return fmt.Errorf("%w: pix payments settle when paid and cannot be authorized", ErrPIXPaymentInvalid)
+	}
+	if request.InstallmentCount > 1 {
+		return fmt.Errorf("%w: installments are not supported", ErrPIXPaymentInvalid)
+	}
+
+	if _, ok := provider.(PIXProvider); !ok {
+		return ErrPIXUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// pixTestProvider records PIX payments on top of captureTestProvider
+type pixTestProvider struct {
+	captureTestProvider
+	payments []PaymentRequest
+}
+
+func (p *pixTestProvider) SupportedCurrencies() []string { return []string{"BRL"} }
+
+func (p *pixTestProvider) CreatePIXPayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.payments = append(p.payments, request)
+	return &PaymentResponse{Success: true, Status: StatusPending, PaymentID: "pix_1", PIX: &PIXCode{QRCode: "00020126580014br.gov.bcb.pix"}}, nil
+}
+
+func pixRequest() PaymentRequest {
+	request := riskRequest()
+	request.Currency = "BRL"
+	request.CardInfo = CardInfo{}
+	request.PaymentMethod = PaymentMethodTypePIX
+	return request
+}
+
+func TestPaymentService_CreatePayment_PIX(t *testing.T) {
+	const tenantID, providerName = 9161, "pixtest"
+
+	fake := &pixTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9161")
+
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, pixRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if len(fake.payments) != 1 || fake.authorized != 0 || fake.sales != 0 {
+		t.Fatalf("Expected a PIX payment only, got %d PIX payments / %d authorizations / %d sales", len(fake.payments), fake.authorized, fake.sales)
+	}
+	if fake.payments[0].LogID == 0 {
+		t.Errorf("Expected the logged request to reach the provider, got %+v", fake.payments[0])
+	}
+	// The client shows the code and waits for the payment
+	if resp.NextAction == nil || resp.NextAction.Type != NextActionPollStatus || resp.NextAction.PaymentID != "pix_1" || resp.PIX == nil {
+		t.Errorf("Expected to poll the PIX payment, got %+v", resp.NextAction)
+	}
+}
+
+func TestPaymentService_CreatePayment_PIXRejected(t *testing.T) {
+	const tenantID = 9162
+
+	GetProviderCache().Set(tenantID, "pixtest", "sandbox", &pixTestProvider{})
+	GetProviderCache().Set(tenantID, "cardonly", "sandbox", &bnplTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "pixtest", "sandbox")
+		GetProviderCache().Delete(tenantID, "cardonly", "sandbox")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9162")
+
+	request := pixRequest()
+	request.Currency = "TRY"
+	if _, err := service.CreatePayment(ctx, "sandbox", "cardonly", request); !errors.Is(err, ErrPIXPaymentInvalid) {
+		t.Errorf("Expected ErrPIXPaymentInvalid for a payment in TRY, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*PaymentRequest)
+	}{
+		{"with card", func(r *PaymentRequest) { r.CardInfo = CardInfo{CardNumber: "5528790000000008"} }},
+		{"with wallet", func(r *PaymentRequest) { r.WalletPayment = &WalletPayment{Type: WalletTypeApplePay} }},
+		{"with 3D", func(r *PaymentRequest) { r.Use3D = true }},
+		{"authorization", func(r *PaymentRequest) { r.PaymentType = PaymentTypeAuth }},
+		{"with installments", func(r *PaymentRequest) { r.Amount, r.InstallmentCount = 5000, 3 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := pixRequest()
+			tt.modify(&request)
+			if _, err := service.CreatePayment(ctx, "sandbox", "pixtest", request); !errors.Is(err, ErrPIXPaymentInvalid) {
+				t.Errorf("Expected ErrPIXPaymentInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// ErrPluginUnavailable is returned when a plugin process cannot be started or exits during a call
+var ErrPluginUnavailable = errors.New("provider plugin is unavailable")
+
+const (
+	// callTimeout bounds the calls of the PaymentProvider methods that take no context
+	callTimeout = 10 * time.Second
+	// closeTimeout is how long Close waits for the plugin to exit before killing it
+	closeTimeout = 5 * time.Second
+)
+
+// Plugin is a provider plugin executable. Its process is started on first use and shared by
+// every provider created from it; it is started again when it exits.
+type Plugin struct {
+	name string
+	path string
+
+	mu     sync.Mutex
+	client *rpc.Client
+	cmd    *exec.Cmd
+	exited chan struct{}
+	// closing is set by Close for the running process, whose exit is not worth a warning
+	closing *atomic.Bool
+	// generation counts the processes started, so providers know when to initialize again
+	generation int
+}
+
+// New creates the plugin of the executable at path, serving the provider called name
+func New(name, path string) *Plugin {
+	return &Plugin{name: name, path: path}
+}
+
+// Name returns the provider name the plugin serves
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Factory returns a provider.ProviderFactory creating providers served by the plugin
+func (p *Plugin) Factory() provider.ProviderFactory {
+	return func() provider.PaymentProvider {
+		return &Provider{plugin: p}
+	}
+}
+
+// connect returns the client of the running plugin process, starting one when there is none
+func (p *Plugin) connect() (*rpc.Client, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil {
+		select {
+		case <-p.exited:
+			_ = p.client.Close()
+			p.client = nil
+		default:
+			return p.client, p.generation, nil
+		}
+	}
+
+	cmd := exec.Command(p.path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", ErrPluginUnavailable, p.name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", ErrPluginUnavailable, p.name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", ErrPluginUnavailable, p.name, err)
+	}
+
+	exited := make(chan struct{})
+	closing := new(atomic.Bool)
+	go func() {
+		err := cmd.Wait()
+		close(exited)
+		if closing.Load() {
+			return
+		}
+		logger.Warn("Provider plugin exited", logger.LogContext{
+			Provider: p.name,
+			Fields: map[string]any{
+				"path":  p.path,
+				"error": fmt.Sprint(err),
+			},
+		})
+	}()
+
+	p.cmd = cmd
+	p.exited = exited
+	p.closing = closing
+	p.client = jsonrpc.NewClient(stdioConn{reader: stdout, writer: stdin})
+	p.generation++
+
+	logger.Info("Started provider plugin", logger.LogContext{
+		Provider: p.name,
+		Fields: map[string]any{
+			"path": p.path,
+			"pid":  cmd.Process.Pid,
+		},
+	})
+	return p.client, p.generation, nil
+}
+
+// call calls a method of the plugin's Provider service and waits for it or for ctx
+func (p *Plugin) call(ctx context.Context, method string, args, reply any) error {
+	client, _, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(ServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if errors.Is(call.Error, rpc.ErrShutdown) || errors.Is(call.Error, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %s: %v", ErrPluginUnavailable, p.name, call.Error)
+	}
+	return call.Error
+}
+
+// Close stops the plugin process. Closing its stdin ends Serve; a plugin that does not exit
+// in time is killed.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client == nil {
+		return nil
+	}
+	p.closing.Store(true)
+	err := p.client.Close()
+	p.client = nil
+
+	select {
+	case <-p.exited:
+	case <-time.After(closeTimeout):
+		if killErr := p.cmd.Process.Kill(); killErr != nil {
+			return killErr
+		}
+		<-p.exited
+	}
+	return err
+}
+
+// RegisterFromEnv registers the plugins listed in PROVIDER_PLUGINS, comma separated
+// name=path pairs, with the registry. A plugin cannot replace a built-in provider. The
+// returned plugins should be closed on shutdown.
+func RegisterFromEnv(registry *provider.ProviderRegistry) ([]*Plugin, error) {
+	plugins, err := parsePlugins(config.GetEnv("PROVIDER_PLUGINS", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, plugin := range plugins {
+		if _, err := registry.Get(plugin.name); err == nil {
+			return nil, fmt.Errorf("provider plugin %s: a provider with that name is already registered", plugin.name)
+		}
+		if _, err := os.Stat(plugin.path); err != nil {
+			return nil, fmt.Errorf("provider plugin %s: %w", plugin.name, err)
+		}
+	}
+	for _, plugin := range plugins {
+		registry.Register(plugin.name, plugin.Factory())
+	}
+	return plugins, nil
+}
+
+// parsePlugins parses the name=path pairs of PROVIDER_PLUGINS
+func parsePlugins(value string) ([]*Plugin, error) {
+	var plugins []*Plugin
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, path, ok := strings.Cut(pair, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		path = strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid provider plugin %q, expected name=path", pair)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("provider plugin %s is listed twice", name)
+		}
+		seen[name] = true
+		plugins = append(plugins, New(name, path))
+	}
+	return plugins, nil
+}
+
+var _ provider.PaymentProvider = (*Provider)(nil)
+
+// Provider is a provider.PaymentProvider served by a plugin. It remembers its config to
+// initialize the plugin again after a restart.
+type Provider struct {
+	plugin *Plugin
+
+	mu         sync.Mutex
+	config     map[string]string
+	instance   string
+	generation int
+}
+
+// prepare returns the Call of a request made with ctx, initializing the provider in a
+// restarted plugin first
+func (p *Provider) prepare(ctx context.Context) (Call, error) {
+	var call Call
+	if deadline, ok := ctx.Deadline(); ok {
+		call.Deadline = deadline
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config == nil {
+		return call, nil
+	}
+
+	_, generation, err := p.plugin.connect()
+	if err != nil {
+		return call, err
+	}
+	if generation != p.generation {
+		var reply InitializeReply
+		if err := p.plugin.call(ctx, "Initialize", InitializeArgs{Config: p.config}, &reply); err != nil {
+			return call, err
+		}
+		p.instance = reply.Instance
+		p.generation = generation
+	}
+	call.Instance = p.instance
+	return call, nil
+}
+
+// warn logs a failed call of a method that cannot return its error
+func (p *Provider) warn(method string, err error) {
+	logger.Warn("Provider plugin call failed", logger.LogContext{
+		Provider: p.plugin.name,
+		Fields: map[string]any{
+			"method": method,
+			"error":  err.Error(),
+		},
+	})
+}
+
+func (p *Provider) Initialize(conf map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	p.mu.Lock()
+	p.config = maps.Clone(conf)
+	p.generation = 0
+	p.mu.Unlock()
+
+	if _, err := p.prepare(ctx); err != nil {
+		p.mu.Lock()
+		p.config = nil
+		p.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (p *Provider) GetRequiredConfig(environment string) []provider.ConfigField {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var fields []provider.ConfigField
+	if err := p.plugin.call(ctx, "GetRequiredConfig", EnvironmentArgs{Environment: environment}, &fields); err != nil {
+		p.warn("GetRequiredConfig", err)
+		return nil
+	}
+	return fields
+}
+
+func (p *Provider) ValidateConfig(conf map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	return p.plugin.call(ctx, "ValidateConfig", ConfigArgs{Config: conf}, &Empty{})
+}
+
+func (p *Provider) SupportedCurrencies() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var currencies []string
+	call, err := p.prepare(ctx)
+	if err == nil {
+		err = p.plugin.call(ctx, "SupportedCurrencies", call, &currencies)
+	}
+	if err != nil {
+		p.warn("SupportedCurrencies", err)
+		return nil
+	}
+	return currencies
+}
+
+func (p *Provider) HealthCheckEndpoint() string {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var endpoint string
+	call, err := p.prepare(ctx)
+	if err == nil {
+		err = p.plugin.call(ctx, "HealthCheckEndpoint", call, &endpoint)
+	}
+	if err != nil {
+		p.warn("HealthCheckEndpoint", err)
+		return ""
+	}
+	return endpoint
+}
+
+func (p *Provider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	var response provider.InstallmentInquireResponse
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return response, err
+	}
+	err = p.plugin.call(ctx, "GetInstallmentCount", InstallmentArgs{Call: call, Request: request}, &response)
+	return response, err
+}
+
+func (p *Provider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.payment(ctx, "CreatePayment", PaymentArgs{Call: call, Request: request})
+}
+
+func (p *Provider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.payment(ctx, "Create3DPayment", PaymentArgs{Call: call, Request: request})
+}
+
+func (p *Provider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.payment(ctx, "Complete3DPayment", Complete3DArgs{Call: call, State: callbackState, Data: data})
+}
+
+func (p *Provider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.payment(ctx, "GetPaymentStatus", StatusArgs{Call: call, Request: request})
+}
+
+func (p *Provider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.payment(ctx, "CancelPayment", CancelArgs{Call: call, Request: request})
+}
+
+// payment makes a call answered with a PaymentResponse
+func (p *Provider) payment(ctx context.Context, method string, args any) (*provider.PaymentResponse, error) {
+	var response provider.PaymentResponse
+	if err := p.plugin.call(ctx, method, args, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (p *Provider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response provider.RefundResponse
+	if err := p.plugin.call(ctx, "RefundPayment", RefundArgs{Call: call, Request: request}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (p *Provider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	var response provider.CommissionResponse
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return response, err
+	}
+	err = p.plugin.call(ctx, "GetCommission", CommissionArgs{Call: call, Request: request}, &response)
+	return response, err
+}
+
+func (p *Provider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	call, err := p.prepare(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	var reply WebhookReply
+	if err := p.plugin.call(ctx, "ValidateWebhook", WebhookArgs{Call: call, Data: data, Headers: headers}, &reply); err != nil {
+		return false, nil, err
+	}
+	return reply.Valid, reply.Data, nil
+}
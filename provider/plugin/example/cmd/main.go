@@ -0,0 +1,11 @@
+// Command example serves the sample provider as a GoPay provider plugin
+package main
+
+import (
+	"github.com/mstgnz/gopay/provider/plugin"
+	"github.com/mstgnz/gopay/provider/plugin/example"
+)
+
+func main() {
+	plugin.Serve(example.NewProvider)
+}
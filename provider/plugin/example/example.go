@@ -0,0 +1,195 @@
+// Package example is a sample provider plugin. It approves payments up to a configured limit
+// and keeps them in memory, which is enough to try the plugin contract end to end. Its
+// executable is in the cmd directory:
+//
+//	go build -o /opt/gopay/plugins/example ./provider/plugin/example/cmd
+//	PROVIDER_PLUGINS=example=/opt/gopay/plugins/example
+package example
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// SignatureHeader is the header the sample provider signs its webhooks with
+const SignatureHeader = "X-Example-Signature"
+
+// ErrPaymentNotFound is returned for a payment the provider did not make
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// Provider is the sample provider
+type Provider struct {
+	apiKey       string
+	declineAbove float64
+
+	mu       sync.Mutex
+	sequence int
+	payments map[string]*provider.PaymentResponse
+}
+
+// NewProvider creates the sample provider
+func NewProvider() provider.PaymentProvider {
+	return &Provider{payments: make(map[string]*provider.PaymentResponse)}
+}
+
+func (p *Provider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "apiKey",
+			Required:    true,
+			Type:        "string",
+			Description: "API key, also the webhook signature",
+			Example:     "example-api-key",
+		},
+		{
+			Key:         "declineAbove",
+			Required:    false,
+			Type:        "number",
+			Description: "Payments above this amount are declined, 0 for no limit",
+			Example:     "1000",
+		},
+	}
+}
+
+func (p *Provider) ValidateConfig(config map[string]string) error {
+	if config["apiKey"] == "" {
+		return errors.New("apiKey is required")
+	}
+	if value := config["declineAbove"]; value != "" {
+		if limit, err := strconv.ParseFloat(value, 64); err != nil || limit < 0 {
+			return fmt.Errorf("invalid declineAbove %q", value)
+		}
+	}
+	return nil
+}
+
+func (p *Provider) Initialize(config map[string]string) error {
+	if err := p.ValidateConfig(config); err != nil {
+		return err
+	}
+	p.apiKey = config["apiKey"]
+	p.declineAbove, _ = strconv.ParseFloat(config["declineAbove"], 64)
+	return nil
+}
+
+func (p *Provider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+func (p *Provider) HealthCheckEndpoint() string {
+	return ""
+}
+
+func (p *Provider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{Amount: request.Amount, Message: "Only single payments are offered"}, nil
+}
+
+func (p *Provider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{
+		Success:     true,
+		NetAmount:   request.Amount,
+		GrossAmount: request.Amount,
+	}, nil
+}
+
+func (p *Provider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		SystemTime: &now,
+	}
+
+	if p.declineAbove > 0 && request.Amount > p.declineAbove {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = "LIMIT_EXCEEDED"
+		response.Message = fmt.Sprintf("Amount is above the limit of %.2f", p.declineAbove)
+		return response, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sequence++
+	response.Success = true
+	response.Status = provider.StatusSuccessful
+	response.Message = "Payment successful"
+	response.PaymentID = fmt.Sprintf("ex_%d", p.sequence)
+	response.TransactionID = response.PaymentID
+	p.payments[response.PaymentID] = response
+	return response, nil
+}
+
+func (p *Provider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("3D payments are not supported")
+}
+
+func (p *Provider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	return nil, errors.New("3D payments are not supported")
+}
+
+func (p *Provider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[request.PaymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	response := *payment
+	return &response, nil
+}
+
+func (p *Provider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[request.PaymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	payment.Status = provider.StatusCancelled
+	payment.Message = "Payment cancelled"
+	response := *payment
+	return &response, nil
+}
+
+func (p *Provider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[request.PaymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	amount := request.RefundAmount
+	if amount == 0 {
+		amount = payment.Amount
+	}
+	if amount == payment.Amount {
+		payment.Status = provider.StatusRefunded
+	}
+
+	now := time.Now()
+	return &provider.RefundResponse{
+		Success:      true,
+		RefundID:     "rf_" + payment.PaymentID,
+		PaymentID:    payment.PaymentID,
+		Status:       "success",
+		RefundAmount: amount,
+		SystemTime:   &now,
+	}, nil
+}
+
+// ValidateWebhook accepts webhooks signed with the API key
+func (p *Provider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	if headers[SignatureHeader] != p.apiKey {
+		return false, nil, nil
+	}
+	return true, data, nil
+}
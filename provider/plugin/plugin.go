@@ -0,0 +1,119 @@
+// Package plugin runs payment providers as separate processes, so integrators can add
+// proprietary providers without forking GoPay.
+//
+// A plugin is an executable that serves one provider.PaymentProvider implementation with
+// Serve. GoPay starts it on first use and talks JSON-RPC 1.0 (net/rpc/jsonrpc) with it over
+// the plugin's stdin and stdout; anything the plugin prints goes to GoPay's stderr. The
+// contract is the "Provider" RPC service described by the argument and reply types of this
+// package, so a plugin can also be written in another language.
+//
+// A Go plugin only needs a main function:
+//
+//	func main() {
+//	    plugin.Serve(acme.NewProvider)
+//	}
+//
+// GoPay registers plugins listed in PROVIDER_PLUGINS as name=path pairs:
+//
+//	PROVIDER_PLUGINS=acme=/opt/gopay/plugins/acme
+//
+// Like a built-in provider, a plugin provider needs a row in the providers table, a log table
+// named after it and tenant configs. See example for a sample plugin.
+package plugin
+
+import (
+	"time"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// ServiceName is the name of the RPC service a plugin serves
+const ServiceName = "Provider"
+
+// Call identifies the provider instance a call is for and carries the caller's deadline
+type Call struct {
+	// Instance is the ID Initialize returned; calls made before Initialize leave it empty
+	Instance string `json:"instance,omitempty"`
+	// Deadline is the deadline of the caller's context, zero when it has none
+	Deadline time.Time `json:"deadline,omitempty"`
+}
+
+// Empty is the argument and reply of calls that have none
+type Empty struct{}
+
+// InitializeArgs are the arguments of Provider.Initialize
+type InitializeArgs struct {
+	Config map[string]string `json:"config"`
+}
+
+// InitializeReply is the reply of Provider.Initialize. Instances are identified by their
+// config, so initializing the same config again returns the same instance.
+type InitializeReply struct {
+	Instance string `json:"instance"`
+}
+
+// EnvironmentArgs are the arguments of Provider.GetRequiredConfig
+type EnvironmentArgs struct {
+	Environment string `json:"environment"`
+}
+
+// ConfigArgs are the arguments of Provider.ValidateConfig
+type ConfigArgs struct {
+	Config map[string]string `json:"config"`
+}
+
+// InstallmentArgs are the arguments of Provider.GetInstallmentCount
+type InstallmentArgs struct {
+	Call
+	Request provider.InstallmentInquireRequest `json:"request"`
+}
+
+// PaymentArgs are the arguments of Provider.CreatePayment and Provider.Create3DPayment
+type PaymentArgs struct {
+	Call
+	Request provider.PaymentRequest `json:"request"`
+}
+
+// Complete3DArgs are the arguments of Provider.Complete3DPayment
+type Complete3DArgs struct {
+	Call
+	State *provider.CallbackState `json:"state"`
+	Data  map[string]string       `json:"data"`
+}
+
+// StatusArgs are the arguments of Provider.GetPaymentStatus
+type StatusArgs struct {
+	Call
+	Request provider.GetPaymentStatusRequest `json:"request"`
+}
+
+// CancelArgs are the arguments of Provider.CancelPayment
+type CancelArgs struct {
+	Call
+	Request provider.CancelRequest `json:"request"`
+}
+
+// RefundArgs are the arguments of Provider.RefundPayment
+type RefundArgs struct {
+	Call
+	Request provider.RefundRequest `json:"request"`
+}
+
+// CommissionArgs are the arguments of Provider.GetCommission
+type CommissionArgs struct {
+	Call
+	Request provider.CommissionRequest `json:"request"`
+}
+
+// WebhookArgs are the arguments of Provider.ValidateWebhook
+type WebhookArgs struct {
+	Call
+	Data    map[string]string `json:"data"`
+	Headers map[string]string `json:"headers"`
+}
+
+// WebhookReply is the reply of Provider.ValidateWebhook
+type WebhookReply struct {
+	Valid bool              `json:"valid"`
+	Data  map[string]string `json:"data"`
+}
@@ -0,0 +1,209 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/plugin/example"
+)
+
+// The test binary doubles as the sample plugin when started with GOPAY_TEST_PLUGIN=1
+func TestMain(m *testing.M) {
+	if os.Getenv("GOPAY_TEST_PLUGIN") == "1" {
+		Serve(example.NewProvider)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestPlugin returns the sample plugin, stopped when the test ends
+func newTestPlugin(t *testing.T) *Plugin {
+	t.Helper()
+	t.Setenv("GOPAY_TEST_PLUGIN", "1")
+
+	p := New("example", os.Args[0])
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func TestPluginProvider(t *testing.T) {
+	p := newTestPlugin(t).Factory()()
+	ctx := context.Background()
+
+	fields := p.GetRequiredConfig("sandbox")
+	if len(fields) != 2 || fields[0].Key != "apiKey" || !fields[0].Required {
+		t.Fatalf("Expected the plugin's config fields, got %+v", fields)
+	}
+	if err := p.Initialize(map[string]string{"declineAbove": "abc"}); err == nil || !strings.Contains(err.Error(), "apiKey is required") {
+		t.Fatalf("Expected the plugin's config error, got %v", err)
+	}
+	if err := p.Initialize(map[string]string{"apiKey": "secret", "declineAbove": "500"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if !provider.IsCurrencySupported(p, "EUR") || provider.IsCurrencySupported(p, "GBP") {
+		t.Errorf("Expected the plugin's currencies, got %v", p.SupportedCurrencies())
+	}
+
+	request := provider.PaymentRequest{Amount: 100, Currency: "TRY", CardInfo: provider.CardInfo{CardNumber: "5528790000000008"}}
+	payment, err := p.CreatePayment(ctx, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !payment.Success || payment.Status != provider.StatusSuccessful || payment.PaymentID == "" || payment.Amount != 100 {
+		t.Fatalf("Expected a successful payment, got %+v", payment)
+	}
+
+	request.Amount = 750
+	declined, err := p.CreatePayment(ctx, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if declined.Success || declined.ErrorCode != "LIMIT_EXCEEDED" {
+		t.Errorf("Expected a decline above the configured limit, got %+v", declined)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: payment.PaymentID})
+	if err != nil || status.Status != provider.StatusSuccessful {
+		t.Errorf("Expected the payment's status, got %+v, %v", status, err)
+	}
+	refund, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: payment.PaymentID, RefundAmount: 40})
+	if err != nil || !refund.Success || refund.RefundAmount != 40 {
+		t.Errorf("Expected a partial refund, got %+v, %v", refund, err)
+	}
+	cancelled, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: payment.PaymentID})
+	if err != nil || cancelled.Status != provider.StatusCancelled {
+		t.Errorf("Expected a cancelled payment, got %+v, %v", cancelled, err)
+	}
+
+	// Provider errors come back with their message
+	if _, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "ex_unknown"}); err == nil || err.Error() != example.ErrPaymentNotFound.Error() {
+		t.Errorf("Expected %q, got %v", example.ErrPaymentNotFound, err)
+	}
+	if _, err := p.Create3DPayment(ctx, request); err == nil {
+		t.Error("Expected the plugin's 3D error")
+	}
+
+	valid, data, err := p.ValidateWebhook(ctx, map[string]string{"paymentId": payment.PaymentID}, map[string]string{example.SignatureHeader: "secret"})
+	if err != nil || !valid || data["paymentId"] != payment.PaymentID {
+		t.Errorf("Expected a valid webhook, got %v, %v, %v", valid, data, err)
+	}
+	if valid, _, _ := p.ValidateWebhook(ctx, nil, map[string]string{example.SignatureHeader: "forged"}); valid {
+		t.Error("Expected a forged webhook to be invalid")
+	}
+}
+
+func TestPluginProvider_SharedProcess(t *testing.T) {
+	plugin := newTestPlugin(t)
+	ctx := context.Background()
+
+	// Providers of different tenants share the process but not their config
+	limited, unlimited := plugin.Factory()(), plugin.Factory()()
+	if err := limited.Initialize(map[string]string{"apiKey": "a", "declineAbove": "50"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := unlimited.Initialize(map[string]string{"apiKey": "b"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	request := provider.PaymentRequest{Amount: 100, Currency: "TRY"}
+	if resp, err := limited.CreatePayment(ctx, request); err != nil || resp.Success {
+		t.Errorf("Expected the limited provider to decline, got %+v, %v", resp, err)
+	}
+	if resp, err := unlimited.CreatePayment(ctx, request); err != nil || !resp.Success {
+		t.Errorf("Expected the unlimited provider to approve, got %+v, %v", resp, err)
+	}
+	if plugin.generation != 1 {
+		t.Errorf("Expected one plugin process, got %d", plugin.generation)
+	}
+}
+
+func TestPluginProvider_RestartedPlugin(t *testing.T) {
+	plugin := newTestPlugin(t)
+	p := plugin.Factory()()
+	ctx := context.Background()
+
+	if err := p.Initialize(map[string]string{"apiKey": "secret", "declineAbove": "50"}); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	plugin.mu.Lock()
+	_ = plugin.cmd.Process.Kill()
+	exited := plugin.exited
+	plugin.mu.Unlock()
+	<-exited
+
+	// The provider is initialized with its config again in the new process
+	resp, err := p.CreatePayment(ctx, provider.PaymentRequest{Amount: 100, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("CreatePayment after restart failed: %v", err)
+	}
+	if resp.Success || resp.ErrorCode != "LIMIT_EXCEEDED" {
+		t.Errorf("Expected the configured limit to still apply, got %+v", resp)
+	}
+	if plugin.generation != 2 {
+		t.Errorf("Expected the plugin to be started again, got generation %d", plugin.generation)
+	}
+}
+
+func TestPluginProvider_MissingExecutable(t *testing.T) {
+	p := New("missing", filepath.Join(t.TempDir(), "missing")).Factory()()
+	if err := p.Initialize(map[string]string{"apiKey": "secret"}); !errors.Is(err, ErrPluginUnavailable) {
+		t.Errorf("Expected ErrPluginUnavailable, got %v", err)
+	}
+}
+
+func TestParsePlugins(t *testing.T) {
+	plugins, err := parsePlugins(" Acme=/opt/plugins/acme , other=/opt/plugins/other,")
+	if err != nil {
+		t.Fatalf("parsePlugins failed: %v", err)
+	}
+	if len(plugins) != 2 || plugins[0].name != "acme" || plugins[0].path != "/opt/plugins/acme" || plugins[1].name != "other" {
+		t.Errorf("Unexpected plugins %+v", plugins)
+	}
+
+	for _, value := range []string{"acme", "=/opt/plugins/acme", "acme=", "acme=/a,ACME=/b"} {
+		if _, err := parsePlugins(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestRegisterFromEnv(t *testing.T) {
+	registry := provider.NewProviderRegistry()
+	registry.Register("iyzico", func() provider.PaymentProvider { return nil })
+
+	t.Setenv("PROVIDER_PLUGINS", "acme="+os.Args[0])
+	plugins, err := RegisterFromEnv(registry)
+	if err != nil {
+		t.Fatalf("RegisterFromEnv failed: %v", err)
+	}
+	if len(plugins) != 1 {
+		t.Fatalf("Expected one plugin, got %d", len(plugins))
+	}
+	factory, err := registry.Get("acme")
+	if err != nil {
+		t.Fatalf("Expected the plugin to be registered: %v", err)
+	}
+	if _, ok := factory().(*Provider); !ok {
+		t.Errorf("Expected the factory to create plugin providers")
+	}
+
+	// A built-in provider cannot be replaced by a plugin
+	t.Setenv("PROVIDER_PLUGINS", "iyzico="+os.Args[0])
+	if _, err := RegisterFromEnv(registry); err == nil {
+		t.Error("Expected a registered provider name to be rejected")
+	}
+
+	t.Setenv("PROVIDER_PLUGINS", "missing="+filepath.Join(t.TempDir(), "missing"))
+	if _, err := RegisterFromEnv(registry); err == nil {
+		t.Error("Expected a missing executable to be rejected")
+	}
+	if _, err := registry.Get("missing"); err == nil {
+		t.Error("Expected a rejected plugin not to be registered")
+	}
+}
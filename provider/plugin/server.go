@@ -0,0 +1,265 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Serve serves the provider made by factory to GoPay over stdin and stdout until GoPay closes
+// the connection. The provider's own output on stdout is moved to stderr, where it cannot
+// corrupt the RPC stream.
+func Serve(factory provider.ProviderFactory) {
+	conn := stdioConn{reader: os.Stdin, writer: os.Stdout}
+	os.Stdout = os.Stderr
+	ServeConn(conn, factory)
+}
+
+// ServeConn serves the provider made by factory on conn until it is closed
+func ServeConn(conn io.ReadWriteCloser, factory provider.ProviderFactory) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, newProviderServer(factory)); err != nil {
+		// Only happens when the service methods do not match net/rpc's rules
+		panic(err)
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// stdioConn joins the plugin's stdin and stdout into one connection
+type stdioConn struct {
+	reader io.ReadCloser
+	writer io.WriteCloser
+}
+
+func (c stdioConn) Read(p []byte) (int, error)  { return c.reader.Read(p) }
+func (c stdioConn) Write(p []byte) (int, error) { return c.writer.Write(p) }
+
+func (c stdioConn) Close() error {
+	readErr := c.reader.Close()
+	if err := c.writer.Close(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+// providerServer is the Provider RPC service. It keeps one initialized provider per config and
+// an uninitialized one for the calls made before Initialize.
+type providerServer struct {
+	factory   provider.ProviderFactory
+	bare      provider.PaymentProvider
+	mu        sync.RWMutex
+	instances map[string]provider.PaymentProvider
+}
+
+func newProviderServer(factory provider.ProviderFactory) *providerServer {
+	return &providerServer{
+		factory:   factory,
+		bare:      factory(),
+		instances: make(map[string]provider.PaymentProvider),
+	}
+}
+
+// instance returns the provider of a call
+func (s *providerServer) instance(call Call) (provider.PaymentProvider, error) {
+	if call.Instance == "" {
+		return s.bare, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.instances[call.Instance]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider instance %q", call.Instance)
+	}
+	return p, nil
+}
+
+// context returns the context of a call, bounded by the caller's deadline
+func (call Call) context() (context.Context, context.CancelFunc) {
+	if call.Deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), call.Deadline)
+}
+
+// instanceID identifies an instance by its config. encoding/json sorts map keys, so equal
+// configs always get the same ID.
+func instanceID(config map[string]string) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+func (s *providerServer) Initialize(args InitializeArgs, reply *InitializeReply) error {
+	id, err := instanceID(args.Config)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.instances[id]; !ok {
+		p := s.factory()
+		if err := p.Initialize(args.Config); err != nil {
+			return err
+		}
+		s.instances[id] = p
+	}
+	reply.Instance = id
+	return nil
+}
+
+func (s *providerServer) GetRequiredConfig(args EnvironmentArgs, reply *[]provider.ConfigField) error {
+	*reply = s.bare.GetRequiredConfig(args.Environment)
+	return nil
+}
+
+func (s *providerServer) ValidateConfig(args ConfigArgs, _ *Empty) error {
+	return s.bare.ValidateConfig(args.Config)
+}
+
+func (s *providerServer) SupportedCurrencies(args Call, reply *[]string) error {
+	p, err := s.instance(args)
+	if err != nil {
+		return err
+	}
+	*reply = p.SupportedCurrencies()
+	return nil
+}
+
+func (s *providerServer) HealthCheckEndpoint(args Call, reply *string) error {
+	p, err := s.instance(args)
+	if err != nil {
+		return err
+	}
+	*reply = p.HealthCheckEndpoint()
+	return nil
+}
+
+func (s *providerServer) GetInstallmentCount(args InstallmentArgs, reply *provider.InstallmentInquireResponse) error {
+	p, err := s.instance(args.Call)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := args.context()
+	defer cancel()
+
+	response, err := p.GetInstallmentCount(ctx, args.Request)
+	if err != nil {
+		return err
+	}
+	*reply = response
+	return nil
+}
+
+func (s *providerServer) CreatePayment(args PaymentArgs, reply *provider.PaymentResponse) error {
+	return s.payment(args.Call, reply, func(ctx context.Context, p provider.PaymentProvider) (*provider.PaymentResponse, error) {
+		return p.CreatePayment(ctx, args.Request)
+	})
+}
+
+func (s *providerServer) Create3DPayment(args PaymentArgs, reply *provider.PaymentResponse) error {
+	return s.payment(args.Call, reply, func(ctx context.Context, p provider.PaymentProvider) (*provider.PaymentResponse, error) {
+		return p.Create3DPayment(ctx, args.Request)
+	})
+}
+
+func (s *providerServer) Complete3DPayment(args Complete3DArgs, reply *provider.PaymentResponse) error {
+	return s.payment(args.Call, reply, func(ctx context.Context, p provider.PaymentProvider) (*provider.PaymentResponse, error) {
+		return p.Complete3DPayment(ctx, args.State, args.Data)
+	})
+}
+
+func (s *providerServer) GetPaymentStatus(args StatusArgs, reply *provider.PaymentResponse) error {
+	return s.payment(args.Call, reply, func(ctx context.Context, p provider.PaymentProvider) (*provider.PaymentResponse, error) {
+		return p.GetPaymentStatus(ctx, args.Request)
+	})
+}
+
+func (s *providerServer) CancelPayment(args CancelArgs, reply *provider.PaymentResponse) error {
+	return s.payment(args.Call, reply, func(ctx context.Context, p provider.PaymentProvider) (*provider.PaymentResponse, error) {
+		return p.CancelPayment(ctx, args.Request)
+	})
+}
+
+// payment runs a call answered with a PaymentResponse
+func (s *providerServer) payment(call Call, reply *provider.PaymentResponse, do func(context.Context, provider.PaymentProvider) (*provider.PaymentResponse, error)) error {
+	p, err := s.instance(call)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := call.context()
+	defer cancel()
+
+	response, err := do(ctx, p)
+	if err != nil {
+		return err
+	}
+	if response != nil {
+		*reply = *response
+	}
+	return nil
+}
+
+func (s *providerServer) RefundPayment(args RefundArgs, reply *provider.RefundResponse) error {
+	p, err := s.instance(args.Call)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := args.context()
+	defer cancel()
+
+	response, err := p.RefundPayment(ctx, args.Request)
+	if err != nil {
+		return err
+	}
+	if response != nil {
+		*reply = *response
+	}
+	return nil
+}
+
+func (s *providerServer) GetCommission(args CommissionArgs, reply *provider.CommissionResponse) error {
+	p, err := s.instance(args.Call)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := args.context()
+	defer cancel()
+
+	response, err := p.GetCommission(ctx, args.Request)
+	if err != nil {
+		return err
+	}
+	*reply = response
+	return nil
+}
+
+func (s *providerServer) ValidateWebhook(args WebhookArgs, reply *WebhookReply) error {
+	p, err := s.instance(args.Call)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := args.context()
+	defer cancel()
+
+	valid, data, err := p.ValidateWebhook(ctx, args.Data, args.Headers)
+	if err != nil {
+		return err
+	}
+	reply.Valid = valid
+	reply.Data = data
+	return nil
+}
@@ -6,13 +6,13 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mstgnz/gopay/infra/config"
@@ -28,6 +28,30 @@ const (
 	StatusFailed     PaymentStatus = "failed"
 	StatusCancelled  PaymentStatus = "cancelled"
 	StatusRefunded   PaymentStatus = "refunded"
+	StatusAuthorized PaymentStatus = "authorized" // funds held, waiting for capture
+	StatusBlocked    PaymentStatus = "blocked"    // stopped by a RiskEvaluator, never sent to the provider
+	StatusUnknown    PaymentStatus = "unknown"    // provider response could not be parsed, check the status
+)
+
+// PaymentOutcome tells the client what to do next with a payment response, so it does not
+// have to infer it from Success, RedirectURL and HTML.
+type PaymentOutcome string
+
+const (
+	// OutcomeCompleted means the payment is final and successful; nothing left to do
+	OutcomeCompleted PaymentOutcome = "completed"
+	// OutcomeRequiresRedirect means the customer must be redirected to RedirectURL
+	OutcomeRequiresRedirect PaymentOutcome = "requires_redirect"
+	// OutcomeRequiresHTMLForm means HTML must be rendered (auto-submitting 3D form or iframe)
+	OutcomeRequiresHTMLForm PaymentOutcome = "requires_html_form"
+	// OutcomeRequiresHostedCheckout means the customer must be sent to HostedCheckoutURL,
+	// the provider's own payment page; the callback/webhook completes the payment
+	OutcomeRequiresHostedCheckout PaymentOutcome = "requires_hosted_checkout"
+	// OutcomeRequiresAction means the payment is pending without a redirect or form,
+	// e.g. awaiting customer approval in a wallet app or a provider webhook
+	OutcomeRequiresAction PaymentOutcome = "requires_action"
+	// OutcomeFailed means the payment was declined, cancelled or errored
+	OutcomeFailed PaymentOutcome = "failed"
 )
 
 // Address represents a physical address
@@ -71,6 +95,12 @@ type CardInfo struct {
 	CVV            string `json:"cvv"`
 }
 
+// IsEmpty reports whether no card details were provided. Hosted checkout providers
+// collect the card on their own page in that case.
+func (c CardInfo) IsEmpty() bool {
+	return c == CardInfo{}
+}
+
 // Item represents a product or service item in the payment
 type Item struct {
 	ID          string  `json:"id"`
@@ -101,28 +131,200 @@ type PaymentRequest struct {
 	Locale           string   `json:"locale,omitempty"`
 	ClientIP         string   `json:"clientIp"`
 	ClientUserAgent  string   `json:"clientUserAgent,omitempty"`
+	ClientCountry    string   `json:"clientCountry,omitempty"` // ISO alpha-2 country of the customer's connection
 	Environment      string   `json:"environment,omitempty"`
 	TenantID         int      `json:"tenantId,omitempty"`
 	SessionID        string   `json:"sessionId,omitempty"`
+	// PaymentType is "auth" to authorize the payment only, holding the funds until it is
+	// captured with PaymentService.CapturePayment; the default "sale" captures right away.
+	// Authorization requires a provider implementing CaptureProvider and is limited to non-3D
+	// payments.
+	PaymentType string `json:"paymentType,omitempty" validate:"omitempty,oneof=sale auth"`
+	// AutoCaptureAfter authorizes the payment only and captures it after this delay
+	// (Go duration, e.g. "2h"), unless the payment is cancelled first. Requires a provider
+	// implementing CaptureProvider and is limited to non-3D payments.
+	AutoCaptureAfter string `json:"autoCaptureAfter,omitempty"`
+	// Metadata holds merchant-defined attributes (e.g. campaign=summer2024). It is stored
+	// with the request log and can be used to filter payments in the search endpoint.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Surcharge is a card fee the merchant adds on top of Amount where it is legal. The
+	// provider is charged Amount + Surcharge; see SurchargeRules for the caps.
+	Surcharge float64 `json:"surcharge,omitempty" validate:"omitempty,gte=0"`
+	// SubscriptionID links a subscription charge to its subscription. It is stored with the
+	// request log, so all charges of a subscription can be searched for.
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// PaymentLinkID links a payment made from a payment link's checkout page to the link
+	PaymentLinkID string `json:"paymentLinkId,omitempty"`
+	// WalletPayment pays with a device wallet token (Apple Pay) instead of CardInfo. It
+	// requires a provider implementing WalletPaymentProvider and is limited to non-3D payments.
+	WalletPayment *WalletPayment `json:"walletPayment,omitempty"`
+	// Recurring asks the provider to charge the card again on its own schedule after this
+	// payment. It is passed through to providers that run recurring plans themselves (Sipay)
+	// and ignored by the others; GoPay subscriptions do not need it.
+	Recurring *RecurringPlan `json:"recurring,omitempty"`
+	// PaymentMethod is "bnpl" to pay in installments with a buy-now-pay-later provider
+	// (Klarna) on its own page instead of CardInfo, or "pix" to pay a PIX code in BRL
+	// (MercadoPago); the default "card" charges the card. BNPL and PIX require a provider
+	// implementing BNPLProvider or PIXProvider.
+	PaymentMethod string `json:"paymentMethod,omitempty" validate:"omitempty,oneof=card bnpl pix"`
+	// IdempotencyKey makes retries of the same payment safe: a repeated key returns the
+	// first result instead of charging again, until the key expires
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// RecurringPlan is a recurring payment plan run by the provider
+type RecurringPlan struct {
+	// Count is the number of payments of the plan, including the first one
+	Count int `json:"count" validate:"gte=2"`
+	// Interval is day, week, month or year, as for subscriptions
+	Interval string `json:"interval" validate:"oneof=day week month year"`
+	// IntervalCount is the number of intervals between two payments, 1 when not set
+	IntervalCount int `json:"intervalCount,omitempty" validate:"omitempty,gte=1"`
+	// WebhookKey names the merchant webhook the provider notifies of each recurring payment
+	WebhookKey string `json:"webhookKey,omitempty"`
 }
 
 // PaymentResponse contains the result of a payment request
 type PaymentResponse struct {
-	Success          bool          `json:"success"`
-	Status           PaymentStatus `json:"status"`
-	Message          string        `json:"message,omitempty"`
-	ErrorCode        string        `json:"errorCode,omitempty"`
-	TransactionID    string        `json:"transactionId,omitempty"`
-	PaymentID        string        `json:"paymentId,omitempty"`
-	OrderID          string        `json:"orderId,omitempty"`
-	Amount           float64       `json:"amount,omitempty"`
-	Currency         string        `json:"currency"`
-	RedirectURL      string        `json:"redirectUrl,omitempty"`
-	HTML             string        `json:"html,omitempty"`
-	SystemTime       *time.Time    `json:"systemTime,omitempty"`
-	FraudStatus      int           `json:"fraudStatus,omitempty"`
-	ProviderResponse any           `json:"providerResponse,omitempty"`
-	SessionID        string        `json:"sessionId,omitempty"`
+	Success       bool          `json:"success"`
+	Status        PaymentStatus `json:"status"`
+	Message       string        `json:"message,omitempty"`
+	ErrorCode     string        `json:"errorCode,omitempty"`
+	TransactionID string        `json:"transactionId,omitempty"`
+	PaymentID     string        `json:"paymentId,omitempty"`
+	OrderID       string        `json:"orderId,omitempty"`
+	Amount        float64       `json:"amount,omitempty"`
+	Currency      string        `json:"currency"`
+	RedirectURL   string        `json:"redirectUrl,omitempty"`
+	HTML          string        `json:"html,omitempty"`
+	// ChallengeForm is the form in HTML as data, for clients that post it themselves
+	ChallengeForm *ChallengeForm `json:"challengeForm,omitempty"`
+	SystemTime    *time.Time     `json:"systemTime,omitempty"`
+	// ProviderTime is the time the provider reported for the response, when it sends one.
+	// Compare it with SystemTime to detect clock drift.
+	ProviderTime     *time.Time     `json:"providerTime,omitempty"`
+	FraudStatus      int            `json:"fraudStatus,omitempty"`
+	ProviderResponse any            `json:"providerResponse,omitempty"`
+	SessionID        string         `json:"sessionId,omitempty"`
+	Outcome          PaymentOutcome `json:"outcome,omitempty"`
+	// NextAction is set by CreatePayment and tells the client what to do with the response
+	NextAction    *NextAction `json:"nextAction,omitempty"`
+	AutoCaptureAt *time.Time  `json:"autoCaptureAt,omitempty"`
+	// HostedCheckoutURL is the provider's own payment page, returned by hosted checkout
+	// providers (Papara, PayTR) when the request carries no card details
+	HostedCheckoutURL string `json:"hostedCheckoutUrl,omitempty"`
+	// PIX is the code a pending PIX payment is paid with
+	PIX *PIXCode `json:"pix,omitempty"`
+	// Block is set when Status is StatusBlocked and tells which internal rule stopped the payment
+	Block *PaymentBlock `json:"block,omitempty"`
+	// Warnings point out a likely sandbox/production mix-up
+	Warnings []PaymentWarning `json:"warnings,omitempty"`
+	// LiveMode tells whether the transaction moved real money. Providers that report it set it
+	// from their response; otherwise it follows the configured environment.
+	LiveMode *bool `json:"liveMode,omitempty"`
+	// ProviderCalls is only set by GetPaymentStatus when IncludeProviderCalls is requested
+	ProviderCalls *ProviderCallStats `json:"providerCalls,omitempty"`
+	// PaymentMethodDetails describes the card used, from the provider response and the
+	// provider's BIN lookup
+	PaymentMethodDetails *PaymentMethodDetails `json:"paymentMethodDetails,omitempty"`
+	// ThreeDS is set by Complete3DPayment and tells which 3DS version authenticated the payment
+	ThreeDS *ThreeDSResult `json:"threeDS,omitempty"`
+	// Provider is set for balanced payments and names the provider that was picked, which
+	// status, cancel and refund requests of the payment have to use
+	Provider string `json:"provider,omitempty"`
+	// AmountUnit is "minor" when Amount was converted to minor units on the client's request
+	AmountUnit string `json:"amountUnit,omitempty"`
+	// Surcharge is set for surcharged payments and splits the charged Amount into the amount
+	// of the goods and the surcharge
+	Surcharge *SurchargeBreakdown `json:"surcharge,omitempty"`
+	// SettlementCurrency and SettlementAmount are set when the provider settles the payment
+	// in another currency than Currency, e.g. from Stripe's balance transaction. They may
+	// only be known once the payment settled, so GetPaymentStatus fills them in later.
+	SettlementCurrency string  `json:"settlementCurrency,omitempty"`
+	SettlementAmount   float64 `json:"settlementAmount,omitempty"`
+	// SubscriptionID is the subscription a subscription charge belongs to
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// PaymentLinkID is the payment link a checkout page payment belongs to
+	PaymentLinkID string `json:"paymentLinkId,omitempty"`
+	// CardUpdateRequired is set on a declined saved card charge when the card on file has
+	// expired or is unknown to the provider (ErrorCode card_expired or card_not_found). The
+	// merchant should run its account updater or collect the card again.
+	CardUpdateRequired bool `json:"cardUpdateRequired,omitempty"`
+	// Capture is set by CapturePayment when the authorized amount is known from the payment
+	// logs, and tells how much of the authorization is left for further captures
+	Capture *CaptureBalance `json:"capture,omitempty"`
+	// Replayed is set when the result is the stored result of an earlier payment with the
+	// same idempotency key, not a new payment at the provider
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// InMinorUnits returns a copy of the response with Amount in the currency's minor units
+func (r *PaymentResponse) InMinorUnits() any {
+	converted := *r
+	converted.Amount = float64(ToMinorUnits(r.Amount, r.Currency))
+	converted.AmountUnit = "minor"
+	if r.Surcharge != nil {
+		converted.Surcharge = &SurchargeBreakdown{
+			Amount:    float64(ToMinorUnits(r.Surcharge.Amount, r.Currency)),
+			Surcharge: float64(ToMinorUnits(r.Surcharge.Surcharge, r.Currency)),
+			Total:     float64(ToMinorUnits(r.Surcharge.Total, r.Currency)),
+		}
+	}
+	if r.Capture != nil {
+		converted.Capture = &CaptureBalance{
+			Captured:  float64(ToMinorUnits(r.Capture.Captured, r.Currency)),
+			Remaining: float64(ToMinorUnits(r.Capture.Remaining, r.Currency)),
+		}
+	}
+	if r.SettlementCurrency != "" {
+		converted.SettlementAmount = float64(ToMinorUnits(r.SettlementAmount, r.SettlementCurrency))
+	}
+	return &converted
+}
+
+// ResolveOutcome derives the PaymentOutcome of a CreatePayment/Create3DPayment response.
+//
+// HTML takes precedence over RedirectURL: some providers (iyzico) put the merchant's own
+// callback URL in RedirectURL next to the 3D form, and PayTR returns both the iframe and
+// its source URL. A successful status is completed even if RedirectURL is set for the
+// same reason.
+// HostedCheckoutURL wins over both, since Papara still mirrors it in RedirectURL for
+// older clients.
+func ResolveOutcome(resp *PaymentResponse) PaymentOutcome {
+	if resp == nil {
+		return OutcomeFailed
+	}
+
+	switch resp.Status {
+	case StatusFailed, StatusCancelled, StatusBlocked:
+		return OutcomeFailed
+	case StatusUnknown:
+		// The charge may have gone through; the client checks the status instead of failing
+		return OutcomeRequiresAction
+	case StatusSuccessful, StatusAuthorized:
+		if resp.Success {
+			return OutcomeCompleted
+		}
+		return OutcomeFailed
+	}
+
+	if !resp.Success {
+		return OutcomeFailed
+	}
+
+	switch {
+	case resp.HostedCheckoutURL != "":
+		return OutcomeRequiresHostedCheckout
+	case resp.HTML != "":
+		return OutcomeRequiresHTMLForm
+	case resp.RedirectURL != "":
+		return OutcomeRequiresRedirect
+	case resp.Status == "":
+		// Providers that do not report a status on success have completed the payment
+		return OutcomeCompleted
+	default:
+		return OutcomeRequiresAction
+	}
 }
 
 // RefundRequest contains information to request a refund
@@ -134,6 +336,9 @@ type RefundRequest struct {
 	Currency       string  `json:"currency,omitempty"`
 	ConversationID string  `json:"conversationId,omitempty"`
 	LogID          int64   `json:"logId,omitempty"`
+	// IdempotencyKey makes retries of the same refund safe: a repeated key for the payment
+	// returns the first result instead of refunding again
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 // CancelRequest contains information to request a cancel
@@ -151,6 +356,9 @@ type GetPaymentStatusRequest struct {
 	PaymentID      string `json:"paymentId"`
 	ConversationID string `json:"conversationId,omitempty"`
 	LogID          int64  `json:"logId,omitempty"`
+	// IncludeProviderCalls adds the payment's provider round-trip count and timing from the
+	// logs to the response (debug)
+	IncludeProviderCalls bool `json:"includeProviderCalls,omitempty"`
 }
 
 // RefundResponse contains the result of a refund request
@@ -164,6 +372,9 @@ type RefundResponse struct {
 	ErrorCode    string     `json:"errorCode,omitempty"`
 	SystemTime   *time.Time `json:"systemTime,omitempty"`
 	RawResponse  any        `json:"rawResponse,omitempty"`
+	// Replayed is set when the result is the stored result of an earlier refund with the
+	// same idempotency key, not a new refund at the provider
+	Replayed bool `json:"replayed,omitempty"`
 }
 
 // CallbackState represents encrypted state data for secure callbacks across all providers
@@ -181,6 +392,8 @@ type CallbackState struct {
 	Timestamp        time.Time `json:"timestamp"`
 	ClientIP         string    `json:"clientIp"`
 	SessionID        string    `json:"sessionId"`
+	// PaymentType of a BNPL payment, whose order is captured when placed unless it is "auth"
+	PaymentType string `json:"paymentType,omitempty"`
 }
 
 // InquireRequest contains information to request an installment count
@@ -204,12 +417,27 @@ type InstallmentInquireResponse struct {
 	Installments map[string][]InstallmentInfo `json:"installments"`
 }
 
+// MaxCommissionInstallmentCount is the highest installment count a commission breakdown covers
+const MaxCommissionInstallmentCount = 12
+
 type CommissionRequest struct {
-	BinValue         string  `json:"binValue"`
-	InstallmentCount int     `json:"installmentCount"`
-	Amount           float64 `json:"amount"`
-	Currency         string  `json:"currency"`
-	LogID            int64   `json:"logId,omitempty"`
+	BinValue         string `json:"binValue"`
+	InstallmentCount int    `json:"installmentCount"`
+	// MaxInstallmentCount asks for the commission of every installment count from
+	// InstallmentCount up to MaxInstallmentCount, returned in CommissionResponse.Installments
+	MaxInstallmentCount int     `json:"maxInstallmentCount,omitempty"`
+	Amount              float64 `json:"amount"`
+	Currency            string  `json:"currency"`
+	LogID               int64   `json:"logId,omitempty"`
+}
+
+// InstallmentCounts returns the installment counts the request asks commission for
+func (r CommissionRequest) InstallmentCounts() []int {
+	counts := []int{r.InstallmentCount}
+	for count := r.InstallmentCount + 1; count <= r.MaxInstallmentCount; count++ {
+		counts = append(counts, count)
+	}
+	return counts
 }
 
 type CommissionResponse struct {
@@ -219,6 +447,18 @@ type CommissionResponse struct {
 	GrossAmount      float64 `json:"grossAmount"`
 	CommissionRate   float64 `json:"commissionRate"`
 	CommissionAmount float64 `json:"commissionAmount"`
+	// Installments is the per installment count breakdown when MaxInstallmentCount was set.
+	// The top level fields hold the commission of the first count.
+	Installments []InstallmentCommission `json:"installments,omitempty"`
+}
+
+// InstallmentCommission is the commission of one installment count in a breakdown
+type InstallmentCommission struct {
+	InstallmentCount int     `json:"installmentCount"`
+	NetAmount        float64 `json:"netAmount"`
+	GrossAmount      float64 `json:"grossAmount"`
+	CommissionRate   float64 `json:"commissionRate"`
+	CommissionAmount float64 `json:"commissionAmount"`
 }
 
 var callbackEncryptor *CallbackEncryptor
@@ -331,156 +571,32 @@ func (e *CallbackEncryptor) deriveEncryptionKey() []byte {
 	return hash[:]
 }
 
-// StoreCallbackState stores callback state in database and returns short ID
+// StoreCallbackState stores callback state in the configured CallbackStateStore and returns
+// its short ID
 func StoreCallbackState(ctx context.Context, state CallbackState) (string, error) {
-	db := config.App().DB
-	if db == nil {
-		return "", errors.New("database connection not available")
-	}
-
-	// Validate tenant ID
-	if state.TenantID <= 0 {
-		return "", fmt.Errorf("invalid tenant ID: %d", state.TenantID)
-	}
-
-	// Serialize state data
-	stateData, err := json.Marshal(state)
+	store, err := getCallbackStateStore()
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal state: %w", err)
-	}
-
-	// Set expiration (30 minutes from now)
-	expiresAt := time.Now().Add(30 * time.Minute)
-
-	// Prepare nullable fields
-	var originalCallback sql.NullString
-	if state.OriginalCallback != "" {
-		originalCallback = sql.NullString{String: state.OriginalCallback, Valid: true}
-	}
-
-	var currency sql.NullString
-	if state.Currency != "" {
-		currency = sql.NullString{String: state.Currency, Valid: true}
+		return "", err
 	}
-
-	var conversationID sql.NullString
-	if state.ConversationID != "" {
-		conversationID = sql.NullString{String: state.ConversationID, Valid: true}
-	}
-
-	var logID sql.NullInt64
-	if state.LogID > 0 {
-		logID = sql.NullInt64{Int64: state.LogID, Valid: true}
-	}
-
-	var installment sql.NullInt32
-	if state.Installment > 0 {
-		installment = sql.NullInt32{Int32: int32(state.Installment), Valid: true}
-	}
-
-	var sessionID sql.NullString
-	if state.SessionID != "" {
-		sessionID = sql.NullString{String: state.SessionID, Valid: true}
-	}
-
-	// Insert into database and get auto-generated ID
-	query := `
-		INSERT INTO callbacks (
-			tenant_id, provider, payment_id, original_callback, 
-			amount, currency, conversation_id, log_id, environment, 
-			client_ip, installment, session_id, state_data, expires_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-		RETURNING id
-	`
-
-	var stateID int
-	err = db.QueryRowContext(ctx, query,
-		state.TenantID, state.Provider, state.PaymentID, originalCallback,
-		state.Amount, currency, conversationID, logID, state.Environment,
-		state.ClientIP, installment, sessionID, string(stateData), expiresAt,
-	).Scan(&stateID)
-
-	if err != nil {
-		return "", fmt.Errorf("failed to store callback state (tenant_id: %d): %w", state.TenantID, err)
-	}
-
-	return fmt.Sprintf("%d", stateID), nil
+	return store.Create(ctx, state)
 }
 
-// RetrieveCallbackState retrieves callback state from database using ID
+// RetrieveCallbackState retrieves callback state by ID and marks it as used
 func RetrieveCallbackState(ctx context.Context, stateID string) (*CallbackState, error) {
-	db := config.App().DB
-	if db == nil {
-		return nil, errors.New("database connection not available")
-	}
-
-	// Convert string ID to integer
-	id, err := strconv.Atoi(stateID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid callback state ID format: %w", err)
-	}
-
-	var stateData string
-	var used bool
-	var expiresAt time.Time
-	var sessionID sql.NullString
-
-	query := `
-		SELECT state_data, used, expires_at, session_id
-		FROM callbacks 
-		WHERE id = $1
-	`
-
-	err = db.QueryRowContext(ctx, query, id).Scan(&stateData, &used, &expiresAt, &sessionID)
+	store, err := getCallbackStateStore()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("callback state not found")
-		}
-		return nil, fmt.Errorf("failed to retrieve callback state: %w", err)
-	}
-
-	// Check if expired
-	if time.Now().After(expiresAt) {
-		return nil, errors.New("callback state expired")
+		return nil, err
 	}
-
-	// Check if already used (optional security measure)
-	if used {
-		return nil, errors.New("callback state already used")
-	}
-
-	// Mark as used (optional - prevents replay attacks)
-	_, err = db.ExecContext(ctx, "UPDATE callbacks SET used = true WHERE id = $1", id)
-	if err != nil {
-		// Log error but don't fail the callback
-		fmt.Printf("Warning: failed to mark callback state as used: %v\n", err)
-	}
-
-	// Deserialize state data
-	var state CallbackState
-	if err := json.Unmarshal([]byte(stateData), &state); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
-	}
-
-	state.SessionID = sessionID.String
-
-	return &state, nil
+	return store.Get(ctx, stateID)
 }
 
-// CleanupExpiredCallbackStates removes expired callback states from database
+// CleanupExpiredCallbackStates removes expired callback states
 func CleanupExpiredCallbackStates(ctx context.Context) error {
-	db := config.App().DB
-	if db == nil {
-		return errors.New("database connection not available")
-	}
-
-	query := "DELETE FROM callbacks WHERE expires_at < NOW()"
-	_, err := db.ExecContext(ctx, query)
+	store, err := getCallbackStateStore()
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired callback states: %w", err)
+		return err
 	}
-
-	return nil
+	return store.Cleanup(ctx)
 }
 
 // CreateShortCallbackURL creates a callback URL with short database-stored state ID
@@ -523,26 +639,13 @@ func HandleCallbackState(ctx context.Context, state string) (*CallbackState, err
 	return encryptor.DecryptCallbackState(state)
 }
 
-// UpdateCallbackState updates callback state in database
+// UpdateCallbackState sets the payment ID of a stored callback state
 func UpdateCallbackState(ctx context.Context, stateID string, referenceCode string) error {
-	db := config.App().DB
-	if db == nil {
-		return errors.New("database connection not available")
-	}
-
-	query := `
-		UPDATE callbacks 
-		SET payment_id = $1,
-		    state_data = jsonb_set(state_data::jsonb, '{paymentId}', to_jsonb($2::text))
-		WHERE id = $3
-	`
-
-	_, err := db.ExecContext(ctx, query, referenceCode, referenceCode, stateID)
+	store, err := getCallbackStateStore()
 	if err != nil {
-		return fmt.Errorf("failed to update callback state: %w", err)
+		return err
 	}
-
-	return nil
+	return store.Update(ctx, stateID, referenceCode)
 }
 
 // PaymentProvider defines the interface that all payment gateways must implement
@@ -559,6 +662,13 @@ type PaymentProvider interface {
 	// ValidateConfig validates the provided configuration against provider requirements
 	ValidateConfig(config map[string]string) error
 
+	// SupportedCurrencies returns the ISO 4217 alpha codes this provider accepts
+	SupportedCurrencies() []string
+
+	// HealthCheckEndpoint returns the URL of a lightweight, non-mutating provider endpoint for
+	// the configured environment (sandbox before Initialize). The deep health probe requests it.
+	HealthCheckEndpoint() string
+
 	// CreatePayment makes a non-3D payment request
 	CreatePayment(ctx context.Context, request PaymentRequest) (*PaymentResponse, error)
 
@@ -586,6 +696,72 @@ type PaymentProvider interface {
 // ProviderFactory is a function type that creates a new PaymentProvider
 type ProviderFactory func() PaymentProvider
 
+// WebhookRawBodyHeader is the key under which the webhook handler passes the raw JSON body to
+// ValidateWebhook in the headers map, for providers that sign the body itself (Razorpay).
+// Request header keys are canonicalized, so a client cannot send a header with this key.
+const WebhookRawBodyHeader = "rawBody"
+
+// ErrUnsupportedCurrency is returned when a payment is requested in a currency the
+// provider does not accept (see PaymentProvider.SupportedCurrencies).
+var ErrUnsupportedCurrency = errors.New("currency is not supported by provider")
+
+// ErrInvalidPaymentRequest is wrapped by InvalidRequest around the errors of a provider's own
+// request validation, which runs before anything is sent to the provider
+var ErrInvalidPaymentRequest = errors.New("invalid payment request")
+
+// invalidRequestError is a validation error of a provider that is also ErrInvalidPaymentRequest
+type invalidRequestError struct {
+	err error
+}
+
+func (e *invalidRequestError) Error() string { return e.err.Error() }
+
+func (e *invalidRequestError) Unwrap() []error { return []error{ErrInvalidPaymentRequest, e.err} }
+
+// InvalidRequest marks err, returned by a provider's request validation before it calls its
+// API, as ErrInvalidPaymentRequest. The error message is left as it is.
+func InvalidRequest(err error) error {
+	return &invalidRequestError{err: err}
+}
+
+// IsCurrencySupported reports whether the provider accepts the given ISO 4217 currency code.
+func IsCurrencySupported(p PaymentProvider, currency string) bool {
+	for _, supported := range p.SupportedCurrencies() {
+		if strings.EqualFold(supported, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// Payment types of a PaymentRequest
+const (
+	PaymentTypeSale = "sale"
+	PaymentTypeAuth = "auth"
+)
+
+// CaptureRequest captures funds previously held by AuthorizePayment.
+type CaptureRequest struct {
+	PaymentID string  `json:"paymentId"`
+	Amount    float64 `json:"amount,omitempty"` // zero captures what is left of the authorized amount
+	Currency  string  `json:"currency,omitempty"`
+	LogID     int64   `json:"logId,omitempty"`
+}
+
+// ErrCaptureUnsupported is returned when authorize/capture is requested from a provider
+// that does not implement the optional CaptureProvider capability.
+var ErrCaptureUnsupported = errors.New("provider does not support authorize and capture")
+
+// CaptureProvider is an OPTIONAL capability interface implemented by providers that can
+// hold funds now and capture them later. Callers type-assert on it, like CardStorageProvider.
+type CaptureProvider interface {
+	// AuthorizePayment holds the payment amount without capturing it (non-3D)
+	AuthorizePayment(ctx context.Context, request PaymentRequest) (*PaymentResponse, error)
+
+	// CapturePayment captures a previously authorized payment
+	CapturePayment(ctx context.Context, request CaptureRequest) (*PaymentResponse, error)
+}
+
 // ErrCardStorageUnsupported is returned when a card-storage operation is requested
 // from a provider that does not implement the optional CardStorageProvider capability.
 var ErrCardStorageUnsupported = errors.New("provider does not support card storage")
@@ -740,4 +916,5 @@ type SavedCardPaymentRequest struct {
 	ClientUserAgent  string  `json:"clientUserAgent,omitempty"`
 	ConversationID   string  `json:"conversationId,omitempty"`
 	SessionID        string  `json:"sessionId,omitempty"`
+	SubscriptionID   string  `json:"subscriptionId,omitempty"`
 }
@@ -0,0 +1,76 @@
+package qnb
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test cards for the PayFor test environment
+var testCards = []provider.CardInfo{
+	{CardNumber: "4155650100416111", ExpireMonth: "01", ExpireYear: "2030", CVV: "715"},
+	{CardNumber: "5400617030400233", ExpireMonth: "01", ExpireYear: "2030", CVV: "000"},
+}
+
+// setupRealTestProvider returns a sandbox provider from QNB_MERCHANT_ID,
+// QNB_USER_CODE, QNB_USER_PASSWORD and QNB_MERCHANT_PASSWORD, skipping the test when they
+// are not set
+func setupRealTestProvider(t *testing.T) *QNBProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"merchantId":       os.Getenv("QNB_MERCHANT_ID"),
+		"userCode":         os.Getenv("QNB_USER_CODE"),
+		"userPassword":     os.Getenv("QNB_USER_PASSWORD"),
+		"merchantPassword": os.Getenv("QNB_MERCHANT_PASSWORD"),
+		"environment":      "sandbox",
+	}
+	if config["merchantId"] == "" || config["userCode"] == "" || config["userPassword"] == "" || config["merchantPassword"] == "" {
+		t.Skip("qnb sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*QNBProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestQNBProvider_RealAPI_SaleStatusCancel makes a non-3D sale, queries it and voids it
+func TestQNBProvider_RealAPI_SaleStatusCancel(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	sale, err := p.CreatePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@qnb.example.com"},
+		CardInfo: testCards[0],
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Sale: success=%v status=%s paymentID=%s message=%s", sale.Success, sale.Status, sale.PaymentID, sale.Message)
+	if !sale.Success {
+		t.Skipf("Sandbox declined the sale: %s %s", sale.ErrorCode, sale.Message)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s amount=%.2f", status.Status, status.Amount)
+
+	cancel, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	t.Logf("Cancel: success=%v status=%s message=%s", cancel.Success, cancel.Status, cancel.Message)
+}
@@ -0,0 +1,468 @@
+package qnb
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"merchantId":       "085300000009704",
+		"userCode":         "QNB_API_KULLANICI",
+		"userPassword":     "UcBN0",
+		"merchantPassword": "12345678",
+		"environment":      environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	qnbProvider, ok := p.(*QNBProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a QNBProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if qnbProvider.httpClient != nil {
+		t.Error("QNBProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := qnbProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if qnbProvider.httpClient == nil {
+		t.Error("QNBProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestQNBProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name          string
+		config        map[string]string
+		expectError   bool
+		baseURL       string
+		threeDPostURL string
+	}{
+		{"sandbox", testConfig("sandbox"), false, apiSandboxURL, api3DSandboxURL},
+		{"production", testConfig("production"), false, apiProductionURL, api3DProductionURL},
+		{"missing merchantId", without("merchantId"), true, "", ""},
+		{"missing userCode", without("userCode"), true, "", ""},
+		{"missing userPassword", without("userPassword"), true, "", ""},
+		{"missing merchantPassword", without("merchantPassword"), true, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &QNBProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL || p.threeDPostURL != tt.threeDPostURL {
+				t.Errorf("Expected %s and %s, got %s and %s", tt.baseURL, tt.threeDPostURL, p.baseURL, p.threeDPostURL)
+			}
+		})
+	}
+}
+
+func TestQNBProvider_GetRequiredConfig(t *testing.T) {
+	p := &QNBProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	expected := map[string]bool{"merchantId": true, "userCode": true, "userPassword": true, "merchantPassword": true, "environment": true}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d config fields, got %d", len(expected), len(fields))
+	}
+	for _, field := range fields {
+		if !expected[field.Key] || !field.Required {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestQNBProvider_ValidatePaymentRequest(t *testing.T) {
+	p := &QNBProvider{}
+
+	valid := func() provider.PaymentRequest {
+		return provider.PaymentRequest{
+			TenantID:    1,
+			Amount:      100.50,
+			Currency:    "TRY",
+			CallbackURL: "https://example.com/callback",
+			Customer:    provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+			CardInfo:    provider.CardInfo{CardNumber: "4155650100416111", ExpireMonth: "01", ExpireYear: "2030", CVV: "715"},
+		}
+	}
+
+	if err := p.validatePaymentRequest(valid(), true); err != nil {
+		t.Errorf("Expected a valid request, got %v", err)
+	}
+
+	noCallback := valid()
+	noCallback.CallbackURL = ""
+	if err := p.validatePaymentRequest(noCallback, false); err != nil {
+		t.Errorf("Expected a non-3D request without callback to be valid, got %v", err)
+	}
+	if err := p.validatePaymentRequest(noCallback, true); err == nil {
+		t.Error("Expected an error for a 3D request without callback")
+	}
+
+	noCVV := valid()
+	noCVV.CardInfo.CVV = ""
+	if err := p.validatePaymentRequest(noCVV, false); err == nil {
+		t.Error("Expected an error for a missing CVV")
+	}
+}
+
+func sha1Base64Of(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestQNBProvider_Build3DFormParams(t *testing.T) {
+	p := NewProvider().(*QNBProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	request := provider.PaymentRequest{
+		TenantID:         1,
+		Amount:           100.50,
+		Currency:         "TRY",
+		InstallmentCount: 3,
+		Customer:         provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:         provider.CardInfo{CardNumber: "4155 6501 0041 6111", ExpireMonth: "01", ExpireYear: "2030", CVV: "715"},
+	}
+
+	params := p.build3DFormParams(request, "GP1", "https://gopay.example.com/callback/qnb", "949")
+	expected := map[string]string{
+		"MbrId":            "5",
+		"MerchantID":       "085300000009704",
+		"UserCode":         "QNB_API_KULLANICI",
+		"SecureType":       secureType3DPay,
+		"TxnType":          txnTypeSale,
+		"OrderId":          "GP1",
+		"PurchAmount":      "100.50",
+		"Currency":         "949",
+		"InstallmentCount": "3",
+		"OkUrl":            "https://gopay.example.com/callback/qnb",
+		"FailUrl":          "https://gopay.example.com/callback/qnb",
+		"Pan":              "4155650100416111",
+		"Expiry":           "0130",
+		"CardHolderName":   "John Doe",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, params[key])
+		}
+	}
+	for _, value := range params {
+		if value == "12345678" {
+			t.Error("The merchant password must not be posted")
+		}
+	}
+
+	hash := sha1Base64Of("5" + "GP1" + "100.50" + "https://gopay.example.com/callback/qnb" + "https://gopay.example.com/callback/qnb" +
+		"Auth" + "3" + params["Rnd"] + "12345678")
+	if got := p.calculate3DFormHash(params); got != hash {
+		t.Errorf("Expected form hash %s, got %s", hash, got)
+	}
+
+	request.InstallmentCount = 1
+	if params := p.build3DFormParams(request, "GP1", "https://gopay.example.com/callback/qnb", "949"); params["InstallmentCount"] != "0" {
+		t.Errorf("Expected installment 0 for a single payment, got %q", params["InstallmentCount"])
+	}
+}
+
+func TestQNBProvider_Generate3DSecureHTML(t *testing.T) {
+	p := &QNBProvider{threeDPostURL: api3DSandboxURL}
+
+	form := p.generate3DSecureHTML(map[string]string{"OrderId": "GP1", "CardHolderName": `O"Brien <x>`})
+	if !strings.Contains(form, `action="`+api3DSandboxURL+`"`) {
+		t.Error("Expected the form to post to the 3D gate")
+	}
+	if !strings.Contains(form, `name="CardHolderName" value="O&#34;Brien &lt;x&gt;"`) {
+		t.Errorf("Expected escaped form values, got %s", form)
+	}
+}
+
+// signedCallback returns 3D callback data with its ResponseHash
+func signedCallback(p *QNBProvider, data map[string]string) map[string]string {
+	data["ResponseHash"] = p.calculateCallbackHash(data)
+	return data
+}
+
+func TestQNBProvider_Complete3DPayment(t *testing.T) {
+	p := NewProvider().(*QNBProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "qnb",
+		Environment:      "sandbox",
+	}
+
+	approved := func() map[string]string {
+		return map[string]string{
+			"OrderId":        "GP1",
+			"AuthCode":       "S12345",
+			"ProcReturnCode": "00",
+			"3DStatus":       "1",
+			"ResponseRnd":    "PF638412345678901234",
+			"TransId":        "24015QNB0001",
+			"TxnResult":      "Success",
+		}
+	}
+
+	expectedHash := sha1Base64Of("085300000009704" + "12345678" + "GP1" + "S12345" + "00" + "1" + "PF638412345678901234" + "QNB_API_KULLANICI")
+	if got := p.calculateCallbackHash(approved()); got != expectedHash {
+		t.Errorf("Expected callback hash %s, got %s", expectedHash, got)
+	}
+
+	ctx := context.Background()
+	callback := signedCallback(p, approved())
+	callback["state"] = "abc123"
+	response, err := p.Complete3DPayment(ctx, callbackState, callback)
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "24015QNB0001" {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+	if response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Expected redirect to %s, got %s", callbackState.OriginalCallback, response.RedirectURL)
+	}
+
+	declined := approved()
+	declined["ProcReturnCode"] = "51"
+	declined["AuthCode"] = ""
+	declined["ErrMsg"] = "Yetersiz bakiye"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, declined))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	notAuthenticated := approved()
+	notAuthenticated["3DStatus"] = "0"
+	notAuthenticated["ProcReturnCode"] = ""
+	notAuthenticated["ErrorMessage"] = "Not authenticated"
+	response, err = p.Complete3DPayment(ctx, callbackState, signedCallback(p, notAuthenticated))
+	if err != nil || response.Success || response.ErrorCode != "3DStatus_0" || response.Message != "Not authenticated" {
+		t.Errorf("Expected a failed payment for 3DStatus 0, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, approved()); err == nil || !strings.Contains(err.Error(), "missing ResponseHash") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	tampered := signedCallback(p, declined)
+	tampered["ProcReturnCode"] = "00"
+	if _, err := p.Complete3DPayment(ctx, callbackState, tampered); err == nil || !strings.Contains(err.Error(), "invalid callback hash") {
+		t.Errorf("Expected a tampered callback to be rejected, got %v", err)
+	}
+}
+
+// newTestProvider returns a provider whose XML gate is handler
+func newTestProvider(t *testing.T, handler func(request payforRequest) string) *QNBProvider {
+	t.Helper()
+	server := providertest.Server(t, providertest.XML(func(_ *http.Request, body []byte) string {
+		var request payforRequest
+		if err := xml.Unmarshal(body, &request); err != nil {
+			t.Errorf("Expected an XML request, got %s: %v", body, err)
+		}
+		return handler(request)
+	}))
+
+	p := providertest.Initialize[*QNBProvider](t, NewProvider, testConfig("sandbox"))
+	p.baseURL = server.URL
+	return p
+}
+
+const approvedXML = `<?xml version="1.0" encoding="utf-8"?>
+<PayforResponse>
+	<OrderId>%s</OrderId>
+	<AuthCode>S12345</AuthCode>
+	<ProcReturnCode>00</ProcReturnCode>
+	<TransId>24015QNB0001</TransId>
+	<HostRefNum>401508123456</HostRefNum>
+	<TxnResult>Success</TxnResult>
+	<ErrMsg></ErrMsg>
+</PayforResponse>`
+
+func TestQNBProvider_CreatePayment(t *testing.T) {
+	var sent payforRequest
+	p := newTestProvider(t, func(request payforRequest) string {
+		sent = request
+		return strings.ReplaceAll(approvedXML, "%s", request.OrderID)
+	})
+
+	response, err := p.CreatePayment(context.Background(), provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100.50,
+		Currency: "TRY",
+		Customer: provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo: provider.CardInfo{CardNumber: "4155650100416111", ExpireMonth: "01", ExpireYear: "2030", CVV: "715"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if sent.MbrID != "5" || sent.SecureType != secureTypeNonSecure || sent.TxnType != txnTypeSale || sent.PurchAmount != "100.50" ||
+		sent.Currency != "949" || sent.Expiry != "0130" || sent.UserCode != "QNB_API_KULLANICI" || sent.UserPass != "UcBN0" ||
+		sent.MerchantID != "085300000009704" || sent.InstallmentCount != "0" {
+		t.Errorf("Unexpected sale request: %+v", sent)
+	}
+
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != sent.OrderID || response.TransactionID != "24015QNB0001" {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+}
+
+func TestQNBProvider_CancelPayment(t *testing.T) {
+	var sent payforRequest
+	p := newTestProvider(t, func(request payforRequest) string {
+		sent = request
+		if request.OrgOrderID == "GP-SETTLED" {
+			return `<PayforResponse><ProcReturnCode>V014</ProcReturnCode><ErrMsg>Gün sonu yapılmış işlem iptal edilemez</ErrMsg></PayforResponse>`
+		}
+		return strings.ReplaceAll(approvedXML, "%s", request.OrgOrderID)
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	if sent.TxnType != txnTypeCancel || sent.OrgOrderID != "GP1" || sent.PurchAmount != "" {
+		t.Errorf("Unexpected void request: %+v", sent)
+	}
+	if !response.Success || response.Status != provider.StatusCancelled {
+		t.Errorf("Unexpected void response: %+v", response)
+	}
+
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP-SETTLED"}); !errors.Is(err, provider.ErrAlreadyCaptured) {
+		t.Errorf("Expected ErrAlreadyCaptured for a settled payment, got %v", err)
+	}
+}
+
+func TestQNBProvider_RefundPayment(t *testing.T) {
+	var sent payforRequest
+	p := newTestProvider(t, func(request payforRequest) string {
+		sent = request
+		if request.PurchAmount == "999.99" {
+			return `<PayforResponse><ProcReturnCode>V013</ProcReturnCode><ErrMsg>Iade tutari satis tutarini asiyor</ErrMsg></PayforResponse>`
+		}
+		return strings.ReplaceAll(approvedXML, "%s", request.OrgOrderID)
+	})
+
+	response, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if sent.TxnType != txnTypeRefund || sent.OrgOrderID != "GP1" || sent.PurchAmount != "25.00" || sent.Currency != "949" {
+		t.Errorf("Unexpected refund request: %+v", sent)
+	}
+	if !response.Success || response.RefundID != "24015QNB0001" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "V013" || response.Message != "Iade tutari satis tutarini asiyor" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+
+	if _, err := p.RefundPayment(context.Background(), provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error for a refund without amount")
+	}
+}
+
+func TestQNBProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(request payforRequest) string {
+		if request.TxnType != txnTypeInquiry || request.SecureType != secureTypeInquiry {
+			t.Errorf("Expected an order inquiry, got %+v", request)
+		}
+		switch request.OrgOrderID {
+		case "GP-VOIDED":
+			return `<PayforResponse><OrderId>GP-VOIDED</OrderId><ProcReturnCode>00</ProcReturnCode><PurchAmount>100.50</PurchAmount><VoidDate>20240115</VoidDate><RefundedAmount>0</RefundedAmount></PayforResponse>`
+		case "GP-REFUNDED":
+			return `<PayforResponse><OrderId>GP-REFUNDED</OrderId><ProcReturnCode>00</ProcReturnCode><PurchAmount>100.50</PurchAmount><VoidDate></VoidDate><RefundedAmount>25.00</RefundedAmount></PayforResponse>`
+		case "GP-MISSING":
+			return `<PayforResponse><ProcReturnCode>99</ProcReturnCode><ErrMsg>Order not found</ErrMsg></PayforResponse>`
+		}
+		return `<PayforResponse><OrderId>GP1</OrderId><ProcReturnCode>00</ProcReturnCode><TransId>24015QNB0001</TransId><PurchAmount>100.50</PurchAmount><VoidDate></VoidDate><RefundedAmount>0</RefundedAmount><TxnResult>Success</TxnResult></PayforResponse>`
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusSuccessful || response.Amount != 100.50 || response.TransactionID != "24015QNB0001" {
+		t.Errorf("Unexpected status of an approved order: %+v", response)
+	}
+
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-VOIDED"}); err != nil || response.Status != provider.StatusCancelled {
+		t.Errorf("Expected a voided order to be cancelled, got %+v (%v)", response, err)
+	}
+	if response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-REFUNDED"}); err != nil || response.Status != provider.StatusRefunded {
+		t.Errorf("Expected a refunded order to be refunded, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-MISSING"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown order, got %+v (%v)", response, err)
+	}
+}
+
+func TestQNBProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &QNBProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
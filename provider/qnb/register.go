@@ -0,0 +1,8 @@
+package qnb
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register QNB Finansbank provider with the global registry
+	provider.Register("qnb", NewProvider)
+}
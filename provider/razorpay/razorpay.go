@@ -0,0 +1,833 @@
+package razorpay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API URL, the same for test and live keys
+	apiURL = "https://api.razorpay.com"
+
+	// API Endpoints
+	endpointOrders         = "/v1/orders"
+	endpointPayments       = "/v1/payments"
+	endpointCreatePayment  = "/v1/payments/create/json"
+	webhookSignatureHeader = "X-Razorpay-Signature"
+
+	// Payment statuses
+	paymentStatusCreated    = "created"
+	paymentStatusAuthorized = "authorized"
+	paymentStatusCaptured   = "captured"
+	paymentStatusRefunded   = "refunded"
+	paymentStatusFailed     = "failed"
+
+	// Order statuses
+	orderStatusCreated   = "created"
+	orderStatusAttempted = "attempted"
+	orderStatusPaid      = "paid"
+
+	// Refund statuses
+	refundStatusPending   = "pending"
+	refundStatusProcessed = "processed"
+	refundStatusFailed    = "failed"
+
+	// errorNotFound is the description of an error about an unknown payment or order
+	errorNotFound = "does not exist"
+
+	// maxReceiptLength is the longest receipt an order takes
+	maxReceiptLength = 40
+)
+
+// RazorpayProvider implements the provider.PaymentProvider interface for Razorpay
+type RazorpayProvider struct {
+	keyID         string
+	keySecret     string
+	webhookSecret string
+	baseURL       string
+	gopayBaseURL  string
+	isProduction  bool
+	httpClient    *provider.ProviderHTTPClient
+}
+
+// NewProvider creates a new Razorpay payment provider
+func NewProvider() provider.PaymentProvider {
+	return &RazorpayProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Razorpay
+func (p *RazorpayProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "keyId",
+			Required:    true,
+			Type:        "string",
+			Description: "Razorpay API key ID (rzp_test_... or rzp_live_...)",
+			Example:     "rzp_test_1DP5mmOlF5G5ag",
+			MinLength:   10,
+			MaxLength:   50,
+		},
+		{
+			Key:         "keySecret",
+			Required:    true,
+			Type:        "string",
+			Description: "Razorpay API key secret",
+			Example:     "thisissecretkey1234",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "webhookSecret",
+			Required:    false,
+			Type:        "string",
+			Description: "Secret of the webhook set up in the Razorpay dashboard, required to accept webhooks",
+			Example:     "webhook_secret_123",
+			MaxLength:   100,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Razorpay requirements
+func (p *RazorpayProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("razorpay", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies Razorpay settles card payments in
+func (p *RazorpayProvider) SupportedCurrencies() []string {
+	return []string{"INR", "USD", "EUR", "GBP", "SGD", "AED"}
+}
+
+// HealthCheckEndpoint returns the orders endpoint, which rejects unauthenticated requests
+func (p *RazorpayProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiURL
+	}
+	return baseURL + endpointOrders
+}
+
+// Initialize sets up the Razorpay payment provider with authentication credentials
+func (p *RazorpayProvider) Initialize(conf map[string]string) error {
+	p.keyID = conf["keyId"]
+	p.keySecret = conf["keySecret"]
+	p.webhookSecret = conf["webhookSecret"]
+
+	if p.keyID == "" || p.keySecret == "" {
+		return errors.New("razorpay: keyId and keySecret are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	// Test and live keys use the same API; the key decides the mode
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiURL
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("razorpay"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *RazorpayProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *RazorpayProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment is not supported: card payments in India must be authenticated with 3D Secure
+func (p *RazorpayProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("razorpay: non-3D payments are not supported, use 3D payments")
+}
+
+// Create3DPayment creates an order for the payment and submits the card against it. The
+// customer is redirected to authenticate the card, after which Razorpay posts the payment
+// to the GoPay callback.
+func (p *RazorpayProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request); err != nil {
+		return nil, fmt.Errorf("razorpay: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	reference := request.ID
+	if reference == "" {
+		reference = provider.NewPaymentID()
+	}
+	amount := provider.ToMinorUnits(request.Amount, request.Currency)
+
+	var created order
+	errs, err := p.send(ctx, http.MethodPost, endpointOrders, orderRequest{
+		Amount:   amount,
+		Currency: request.Currency,
+		Receipt:  receipt(reference),
+		Notes:    map[string]string{"gopay_reference": reference},
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return failedResponse(reference, request.Amount, request.Currency, errs), nil
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "razorpay", provider.CallbackState{
+		TenantID:         request.TenantID,
+		PaymentID:        created.ID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		ConversationID:   reference,
+		LogID:            request.LogID,
+		Provider:         "razorpay",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		SessionID:        request.SessionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	holderName := request.CardInfo.CardHolderName
+	if holderName == "" {
+		holderName = strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+	}
+	paymentReq := paymentRequest{
+		Amount:      amount,
+		Currency:    request.Currency,
+		OrderID:     created.ID,
+		Email:       request.Customer.Email,
+		Contact:     request.Customer.PhoneNumber,
+		Method:      "card",
+		Description: request.Description,
+		IP:          clientIP(request),
+		UserAgent:   request.ClientUserAgent,
+		CallbackURL: gopayCallbackURL,
+		Card: card{
+			Number:      request.CardInfo.CardNumber,
+			Name:        holderName,
+			ExpiryMonth: request.CardInfo.ExpireMonth,
+			ExpiryYear:  request.CardInfo.ExpireYear[len(request.CardInfo.ExpireYear)-2:],
+			CVV:         request.CardInfo.CVV,
+		},
+	}
+
+	var submitted paymentCreated
+	errs, err = p.send(ctx, http.MethodPost, endpointCreatePayment, paymentReq, &submitted)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		response := failedResponse(created.ID, request.Amount, request.Currency, errs)
+		response.OrderID = reference
+		return response, nil
+	}
+
+	redirectURL := submitted.redirectURL()
+	if redirectURL == "" {
+		return nil, errors.New("razorpay: no redirect to authenticate the card")
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:          true,
+		Status:           provider.StatusPending,
+		Message:          "3D Secure authentication required",
+		PaymentID:        created.ID,
+		OrderID:          reference,
+		TransactionID:    submitted.PaymentID,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		RedirectURL:      redirectURL,
+		SystemTime:       &now,
+		ProviderResponse: submitted,
+	}, nil
+}
+
+// Complete3DPayment completes a payment when Razorpay posts it to the callback. The
+// signature of the order and payment IDs is verified and the payment is read back; an
+// authorized payment is captured, as GoPay payments are sales.
+func (p *RazorpayProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("razorpay: no callback data received")
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("razorpay", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        callbackState.PaymentID,
+		OrderID:          callbackState.ConversationID,
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	paymentID := data["razorpay_payment_id"]
+	if paymentID == "" {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = data["error[code]"]
+		response.Message = data["error[description]"]
+		if response.Message == "" {
+			response.Message = "3D Secure authentication failed"
+		}
+		return response, nil
+	}
+
+	if data["razorpay_order_id"] != callbackState.PaymentID {
+		return nil, errors.New("razorpay: callback order does not match the callback state")
+	}
+	if subtle.ConstantTimeCompare([]byte(p.paymentSignature(callbackState.PaymentID, paymentID)), []byte(data["razorpay_signature"])) != 1 {
+		return nil, errors.New("razorpay: invalid callback signature")
+	}
+
+	result, errs, err := p.getPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.Error.Code
+		response.Message = errs.Error.Description
+		return response, nil
+	}
+	if result.OrderID != callbackState.PaymentID || result.Amount != provider.ToMinorUnits(callbackState.Amount, callbackState.Currency) {
+		return nil, errors.New("razorpay: payment does not match the callback state")
+	}
+
+	if result.Status == paymentStatusAuthorized {
+		var captured payment
+		errs, err = p.send(ctx, http.MethodPost, p.paymentEndpoint(paymentID)+"/capture", captureRequest{Amount: result.Amount, Currency: result.Currency}, &captured)
+		if err != nil {
+			return nil, err
+		}
+		if errs != nil {
+			response.PaymentID = paymentID
+			response.Status = provider.StatusFailed
+			response.ErrorCode = errs.Error.Code
+			response.Message = errs.Error.Description
+			return response, nil
+		}
+		result = &captured
+	}
+
+	response.PaymentID = paymentID
+	response.TransactionID = paymentID
+	response.Status = result.status()
+	response.Success = response.Status == provider.StatusSuccessful
+	response.Message = result.message()
+	response.ErrorCode = result.ErrorCode
+	response.ProviderTime = result.createdAt()
+	response.ProviderResponse = result
+	return response, nil
+}
+
+// GetPaymentStatus retrieves the current status of a payment. An order ID, which a pending
+// payment is known by, reports the status of the order.
+func (p *RazorpayProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("razorpay: paymentID is required")
+	}
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:  request.PaymentID,
+		SystemTime: &now,
+	}
+
+	var errs *apiError
+	var err error
+	if strings.HasPrefix(request.PaymentID, "order_") {
+		var result order
+		errs, err = p.send(ctx, http.MethodGet, endpointOrders+"/"+url.PathEscape(request.PaymentID), nil, &result)
+		if err == nil && errs == nil {
+			response.Status = result.status()
+			response.Success = response.Status == provider.StatusSuccessful
+			response.Message = result.Status
+			response.Amount = provider.FromMinorUnits(result.Amount, result.Currency)
+			response.Currency = result.Currency
+			response.ProviderResponse = result
+		}
+	} else {
+		var result *payment
+		result, errs, err = p.getPayment(ctx, request.PaymentID)
+		if err == nil && errs == nil {
+			response.Status = result.status()
+			response.Success = response.Status == provider.StatusSuccessful
+			response.Message = result.message()
+			response.ErrorCode = result.ErrorCode
+			response.OrderID = result.OrderID
+			response.Amount = provider.FromMinorUnits(result.Amount, result.Currency)
+			response.Currency = result.Currency
+			response.ProviderTime = result.createdAt()
+			response.ProviderResponse = result
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if errs != nil {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = errs.Error.Code
+		response.Message = errs.Error.Description
+		response.ProviderResponse = errs
+		if strings.Contains(errs.Error.Description, errorNotFound) {
+			response.ErrorCode = provider.ErrorCodePaymentNotFound
+			return response, fmt.Errorf("razorpay: %w", provider.ErrPaymentNotFound)
+		}
+	}
+	return response, nil
+}
+
+// CancelPayment is not supported: Razorpay payments are captured when they complete and are
+// refunded instead
+func (p *RazorpayProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("razorpay: payments cannot be cancelled, refund them instead")
+}
+
+// RefundPayment refunds a captured payment, in full or in part
+func (p *RazorpayProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("razorpay: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("razorpay: refund amount must be greater than 0")
+	}
+
+	refundReq := refundRequest{
+		Amount: provider.ToMinorUnits(request.RefundAmount, request.Currency),
+		Speed:  "normal",
+	}
+	if request.Reason != "" {
+		refundReq.Notes = map[string]string{"reason": request.Reason}
+	}
+
+	var result refund
+	errs, err := p.send(ctx, http.MethodPost, p.paymentEndpoint(request.PaymentID)+"/refund", refundReq, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refundResp := &provider.RefundResponse{
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+	}
+
+	if errs != nil {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = errs.Error.Code
+		refundResp.Message = errs.Error.Description
+		refundResp.RawResponse = errs
+		return refundResp, nil
+	}
+
+	refundResp.RefundID = result.ID
+	refundResp.RawResponse = result
+	switch result.Status {
+	case refundStatusProcessed:
+		refundResp.Success = true
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+	case refundStatusFailed:
+		refundResp.Status = "failed"
+		refundResp.Message = "Refund failed"
+	default:
+		refundResp.Success = true
+		refundResp.Status = refundStatusPending
+		refundResp.Message = "Refund accepted, Razorpay is processing it"
+	}
+	return refundResp, nil
+}
+
+// ValidateWebhook validates a Razorpay webhook, signed with the webhook secret over its raw
+// body, and reports the payment it is about
+func (p *RazorpayProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	if p.webhookSecret == "" {
+		return false, nil, errors.New("razorpay: webhookSecret is not configured")
+	}
+
+	signature := headers[webhookSignatureHeader]
+	if signature == "" {
+		return false, nil, errors.New("razorpay: missing signature header")
+	}
+
+	body, ok := headers[provider.WebhookRawBodyHeader]
+	if !ok {
+		return false, nil, errors.New("razorpay: webhook body is not available")
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write([]byte(body))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature)) != 1 {
+		return false, nil, errors.New("razorpay: invalid signature")
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(data["payload"]), &payload); err != nil {
+		return false, nil, fmt.Errorf("razorpay: invalid webhook payload: %w", err)
+	}
+
+	event := data["event"]
+	if payload.Payment == nil || payload.Payment.Entity.ID == "" {
+		return false, nil, fmt.Errorf("razorpay: no payment in %s webhook", event)
+	}
+
+	status := "pending"
+	switch {
+	case strings.HasPrefix(event, "refund."):
+		status = "refunded"
+	case payload.Payment.Entity.Status == paymentStatusCaptured || event == "order.paid":
+		status = "success"
+	case payload.Payment.Entity.Status == paymentStatusFailed:
+		status = "failed"
+	case payload.Payment.Entity.Status == paymentStatusRefunded:
+		status = "refunded"
+	}
+
+	return true, map[string]string{
+		"paymentId": payload.Payment.Entity.ID,
+		"status":    status,
+		"eventType": event,
+	}, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *RazorpayProvider) validatePaymentRequest(request provider.PaymentRequest) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.Currency == "" {
+		return errors.New("currency is required")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || len(request.CardInfo.ExpireYear) < 2 {
+		return errors.New("card expiry month and year are required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("card CVV is required")
+	}
+
+	if request.Customer.Email == "" || request.Customer.PhoneNumber == "" {
+		return errors.New("customer email and phone number are required")
+	}
+
+	if request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// paymentSignature returns the signature Razorpay sends with a completed payment: the hex
+// HMAC-SHA256 of "orderID|paymentID" with the key secret
+func (p *RazorpayProvider) paymentSignature(orderID, paymentID string) string {
+	mac := hmac.New(sha256.New, []byte(p.keySecret))
+	mac.Write([]byte(orderID + "|" + paymentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// getPayment reads a payment
+func (p *RazorpayProvider) getPayment(ctx context.Context, paymentID string) (*payment, *apiError, error) {
+	var result payment
+	errs, err := p.send(ctx, http.MethodGet, p.paymentEndpoint(paymentID), nil, &result)
+	if err != nil || errs != nil {
+		return nil, errs, err
+	}
+	return &result, nil, nil
+}
+
+// paymentEndpoint returns the endpoint of a payment
+func (p *RazorpayProvider) paymentEndpoint(paymentID string) string {
+	return endpointPayments + "/" + url.PathEscape(paymentID)
+}
+
+// send sends an authenticated request, decoding a successful response into result. A request
+// Razorpay rejects returns its error instead of an error.
+func (p *RazorpayProvider) send(ctx context.Context, method, endpoint string, body any, result any) (*apiError, error) {
+	httpReq := &provider.HTTPRequest{
+		Method:   method,
+		Endpoint: endpoint,
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(p.keyID+":"+p.keySecret)),
+		},
+	}
+	if body != nil {
+		httpReq.Body = body
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, httpReq)
+	if err != nil && resp == nil {
+		return nil, fmt.Errorf("razorpay: request failed: %w", err)
+	}
+
+	if err != nil {
+		var errs apiError
+		if parseErr := p.httpClient.ParseJSONResponse(resp, &errs); parseErr != nil || errs.Error.Code == "" {
+			return nil, fmt.Errorf("razorpay: request failed: %w", err)
+		}
+		return &errs, nil
+	}
+
+	if result != nil && len(resp.Body) > 0 {
+		if err := p.httpClient.ParseJSONResponse(resp, result); err != nil {
+			return nil, fmt.Errorf("razorpay: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+// failedResponse maps a request Razorpay rejected
+func failedResponse(paymentID string, amount float64, currency string, errs *apiError) *provider.PaymentResponse {
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Status:           provider.StatusFailed,
+		ErrorCode:        errs.Error.Code,
+		Message:          errs.Error.Description,
+		PaymentID:        paymentID,
+		Amount:           amount,
+		Currency:         currency,
+		SystemTime:       &now,
+		ProviderResponse: errs,
+	}
+}
+
+// receipt returns the receipt of an order, which Razorpay limits to 40 characters
+func receipt(reference string) string {
+	if len(reference) > maxReceiptLength {
+		return reference[:maxReceiptLength]
+	}
+	return reference
+}
+
+// clientIP returns the IP of the customer's connection
+func clientIP(request provider.PaymentRequest) string {
+	if request.ClientIP != "" {
+		return request.ClientIP
+	}
+	return request.Customer.IPAddress
+}
+
+// apiError is the body of a request Razorpay rejected
+type apiError struct {
+	Error struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Source      string `json:"source"`
+		Step        string `json:"step"`
+		Reason      string `json:"reason"`
+		Field       string `json:"field,omitempty"`
+	} `json:"error"`
+}
+
+// orderRequest creates an order, in minor units
+type orderRequest struct {
+	Amount   int64             `json:"amount"`
+	Currency string            `json:"currency"`
+	Receipt  string            `json:"receipt"`
+	Notes    map[string]string `json:"notes,omitempty"`
+}
+
+// order is an order, amounts in minor units
+type order struct {
+	ID         string `json:"id"`
+	Entity     string `json:"entity"`
+	Amount     int64  `json:"amount"`
+	AmountPaid int64  `json:"amount_paid"`
+	AmountDue  int64  `json:"amount_due"`
+	Currency   string `json:"currency"`
+	Receipt    string `json:"receipt"`
+	Status     string `json:"status"`
+	Attempts   int    `json:"attempts"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+// status maps the status of an order
+func (o *order) status() provider.PaymentStatus {
+	switch o.Status {
+	case orderStatusPaid:
+		return provider.StatusSuccessful
+	case orderStatusCreated, orderStatusAttempted:
+		return provider.StatusPending
+	}
+	return provider.StatusUnknown
+}
+
+// paymentRequest submits a card against an order (S2S JSON API)
+type paymentRequest struct {
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	OrderID     string `json:"order_id"`
+	Email       string `json:"email"`
+	Contact     string `json:"contact"`
+	Method      string `json:"method"`
+	Card        card   `json:"card"`
+	Description string `json:"description,omitempty"`
+	IP          string `json:"ip,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
+	CallbackURL string `json:"callback_url"`
+}
+
+// card is the card of a payment
+type card struct {
+	Number      string `json:"number"`
+	Name        string `json:"name"`
+	ExpiryMonth string `json:"expiry_month"`
+	ExpiryYear  string `json:"expiry_year"`
+	CVV         string `json:"cvv"`
+}
+
+// paymentCreated is a submitted payment and the actions that complete it
+type paymentCreated struct {
+	PaymentID string       `json:"razorpay_payment_id"`
+	Next      []nextAction `json:"next"`
+}
+
+// nextAction is an action that completes a submitted payment
+type nextAction struct {
+	Action string `json:"action"`
+	URL    string `json:"url"`
+}
+
+// redirectURL returns the URL that authenticates the card
+func (c *paymentCreated) redirectURL() string {
+	for _, next := range c.Next {
+		if next.Action == "redirect" {
+			return next.URL
+		}
+	}
+	return ""
+}
+
+// payment is a payment, amounts in minor units
+type payment struct {
+	ID               string `json:"id"`
+	Entity           string `json:"entity"`
+	Amount           int64  `json:"amount"`
+	Currency         string `json:"currency"`
+	Status           string `json:"status"`
+	OrderID          string `json:"order_id"`
+	Method           string `json:"method"`
+	AmountRefunded   int64  `json:"amount_refunded"`
+	RefundStatus     string `json:"refund_status"`
+	Captured         bool   `json:"captured"`
+	Email            string `json:"email"`
+	Contact          string `json:"contact"`
+	ErrorCode        string `json:"error_code"`
+	ErrorDescription string `json:"error_description"`
+	ErrorReason      string `json:"error_reason"`
+	CreatedAt        int64  `json:"created_at"`
+}
+
+// status maps the status of a payment
+func (p *payment) status() provider.PaymentStatus {
+	switch p.Status {
+	case paymentStatusCaptured:
+		if p.AmountRefunded > 0 {
+			return provider.StatusRefunded
+		}
+		return provider.StatusSuccessful
+	case paymentStatusAuthorized:
+		return provider.StatusAuthorized
+	case paymentStatusRefunded:
+		return provider.StatusRefunded
+	case paymentStatusFailed:
+		return provider.StatusFailed
+	case paymentStatusCreated:
+		return provider.StatusPending
+	}
+	return provider.StatusUnknown
+}
+
+// message describes the status of a payment
+func (p *payment) message() string {
+	if p.ErrorDescription != "" {
+		return p.ErrorDescription
+	}
+	return p.Status
+}
+
+// createdAt returns the creation time of a payment
+func (p *payment) createdAt() *time.Time {
+	if p.CreatedAt == 0 {
+		return nil
+	}
+	created := time.Unix(p.CreatedAt, 0).UTC()
+	return &created
+}
+
+// captureRequest captures an authorized payment, in minor units
+type captureRequest struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// refundRequest refunds a payment, in minor units
+type refundRequest struct {
+	Amount int64             `json:"amount"`
+	Speed  string            `json:"speed"`
+	Notes  map[string]string `json:"notes,omitempty"`
+}
+
+// refund is a refund of a payment
+type refund struct {
+	ID        string `json:"id"`
+	Entity    string `json:"entity"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	PaymentID string `json:"payment_id"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// webhookPayload is the payload of a webhook, with the entities it is about
+type webhookPayload struct {
+	Payment *struct {
+		Entity payment `json:"entity"`
+	} `json:"payment"`
+}
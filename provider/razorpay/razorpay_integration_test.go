@@ -0,0 +1,79 @@
+package razorpay
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// setupRealTestProvider returns a provider with test keys from RAZORPAY_KEY_ID and
+// RAZORPAY_KEY_SECRET, skipping the test when they are not set
+func setupRealTestProvider(t *testing.T) *RazorpayProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"keyId":       os.Getenv("RAZORPAY_KEY_ID"),
+		"keySecret":   os.Getenv("RAZORPAY_KEY_SECRET"),
+		"environment": "sandbox",
+	}
+	if config["keyId"] == "" || config["keySecret"] == "" {
+		t.Skip("razorpay test keys not set; skipping real API test")
+	}
+
+	p := NewProvider().(*RazorpayProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestRazorpayProvider_RealAPI_Create3DPayment creates an order and submits a test card.
+// Completing it needs the customer on the authentication page, so it stops at the redirect.
+// The S2S card API has to be enabled on the account.
+func TestRazorpayProvider_RealAPI_Create3DPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	response, err := p.Create3DPayment(context.Background(), provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      100.00,
+		Currency:    "INR",
+		CallbackURL: "https://example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:        "Gaurav",
+			Surname:     "Kumar",
+			Email:       "gaurav.kumar@example.com",
+			PhoneNumber: "9000090000",
+		},
+		CardInfo: provider.CardInfo{
+			CardHolderName: "Gaurav Kumar",
+			CardNumber:     "4111111111111111",
+			ExpireMonth:    "12",
+			ExpireYear:     "2030",
+			CVV:            "123",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v order=%s payment=%s redirect=%s message=%s", response.Success, response.PaymentID, response.TransactionID, response.RedirectURL, response.Message)
+	if response.Success && response.RedirectURL == "" {
+		t.Error("Expected an authentication URL")
+	}
+}
+
+// TestRazorpayProvider_RealAPI_UnknownPayment queries a payment that does not exist
+func TestRazorpayProvider_RealAPI_UnknownPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "pay_00000000000000"})
+	t.Logf("Status: %+v (%v)", response, err)
+}
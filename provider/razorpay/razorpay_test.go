@@ -0,0 +1,479 @@
+package razorpay
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"keyId":         "rzp_test_1DP5mmOlF5G5ag",
+		"keySecret":     "thisissecretkey1234",
+		"webhookSecret": "webhook_secret_123",
+		"environment":   environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	razorpayProvider, ok := p.(*RazorpayProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a RazorpayProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if razorpayProvider.httpClient != nil {
+		t.Error("RazorpayProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := razorpayProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if razorpayProvider.httpClient == nil {
+		t.Error("RazorpayProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestRazorpayProvider_Initialize(t *testing.T) {
+	with := func(key, value string) map[string]string {
+		config := testConfig("sandbox")
+		config[key] = value
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+	}{
+		{"sandbox", testConfig("sandbox"), false},
+		{"production", testConfig("production"), false},
+		{"without webhook secret", with("webhookSecret", ""), false},
+		{"missing key id", with("keyId", ""), true},
+		{"missing key secret", with("keySecret", ""), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &RazorpayProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != apiURL {
+				t.Errorf("Expected %s, got %s", apiURL, p.baseURL)
+			}
+		})
+	}
+}
+
+func TestRazorpayProvider_GetRequiredConfig(t *testing.T) {
+	p := &RazorpayProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	required := map[string]bool{"keyId": true, "keySecret": true, "webhookSecret": false, "environment": true}
+	if len(fields) != len(required) {
+		t.Fatalf("Expected %d config fields, got %d", len(required), len(fields))
+	}
+	for _, field := range fields {
+		if expected, ok := required[field.Key]; !ok || field.Required != expected {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+// newTestProvider returns a provider whose API is answered by handler, after checking the
+// request is authenticated
+func newTestProvider(t *testing.T, handler func(r *http.Request, body map[string]any) (int, any)) *RazorpayProvider {
+	t.Helper()
+	p := providertest.Initialize[*RazorpayProvider](t, NewProvider, testConfig("sandbox"))
+
+	api := providertest.JSON(handler)
+	server := providertest.Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != p.keyID || password != p.keySecret {
+			t.Errorf("Unauthenticated request to %s", r.URL)
+		}
+		api(w, r)
+	})
+
+	p.baseURL = server.URL
+	p.gopayBaseURL = "https://gopay.example.com"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("razorpay"))
+	return p
+}
+
+func paymentRequestFixture() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      500.50,
+		Currency:    "INR",
+		CallbackURL: "https://merchant.example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:        "Gaurav",
+			Surname:     "Kumar",
+			Email:       "gaurav.kumar@example.com",
+			PhoneNumber: "9000090000",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "4111111111111111",
+			ExpireMonth: "12",
+			ExpireYear:  "2030",
+			CVV:         "123",
+		},
+	}
+}
+
+func paymentData(status string) map[string]any {
+	return map[string]any{
+		"id":                "pay_1",
+		"entity":            "payment",
+		"amount":            50050,
+		"currency":          "INR",
+		"status":            status,
+		"order_id":          "order_1",
+		"method":            "card",
+		"amount_refunded":   0,
+		"refund_status":     nil,
+		"captured":          status == paymentStatusCaptured,
+		"email":             "gaurav.kumar@example.com",
+		"contact":           "+919000090000",
+		"error_code":        nil,
+		"error_description": nil,
+		"error_reason":      nil,
+		"created_at":        1700000000,
+	}
+}
+
+func apiErrorData(code, description string) map[string]any {
+	return map[string]any{"error": map[string]any{"code": code, "description": description, "source": "business", "step": "payment_initiation", "reason": "input_validation_failed"}}
+}
+
+func TestRazorpayProvider_Create3DPayment(t *testing.T) {
+	store := provider.NewMemoryCallbackStateStore()
+	provider.SetCallbackStateStore(store)
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	var paymentReq map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointOrders:
+			if body["amount"] != float64(50050) || body["currency"] != "INR" || body["receipt"] == "" {
+				t.Errorf("Unexpected order request %v", body)
+			}
+			return http.StatusOK, map[string]any{"id": "order_1", "entity": "order", "amount": 50050, "amount_paid": 0, "amount_due": 50050, "currency": "INR", "receipt": body["receipt"], "status": orderStatusCreated, "attempts": 0, "created_at": 1700000000}
+		case endpointCreatePayment:
+			paymentReq = body
+			return http.StatusOK, map[string]any{"razorpay_payment_id": "pay_1", "next": []any{
+				map[string]any{"action": "otp_generate", "url": "https://api.razorpay.com/v1/payments/pay_1/otp_generate"},
+				map[string]any{"action": "redirect", "url": "https://api.razorpay.com/v1/payments/pay_1/authenticate"},
+			}}
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+
+	request := paymentRequestFixture()
+	response, err := p.Create3DPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusPending || response.PaymentID != "order_1" || response.TransactionID != "pay_1" || response.RedirectURL != "https://api.razorpay.com/v1/payments/pay_1/authenticate" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	card := paymentReq["card"].(map[string]any)
+	if paymentReq["order_id"] != "order_1" || paymentReq["contact"] != "9000090000" || card["expiry_year"] != "30" || card["name"] != "Gaurav Kumar" {
+		t.Errorf("Unexpected payment request %v", paymentReq)
+	}
+	callback, err := url.Parse(paymentReq["callback_url"].(string))
+	if err != nil || !strings.HasPrefix(callback.String(), "https://gopay.example.com/v1/callback/razorpay?state=") {
+		t.Fatalf("Unexpected callback URL %v", callback)
+	}
+
+	state, err := store.Get(context.Background(), callback.Query().Get("state"))
+	if err != nil {
+		t.Fatalf("Callback state not stored: %v", err)
+	}
+	if state.PaymentID != "order_1" || state.ConversationID != response.OrderID || state.OriginalCallback != request.CallbackURL {
+		t.Errorf("Unexpected callback state %+v", state)
+	}
+
+	request.Customer.PhoneNumber = ""
+	if _, err := p.Create3DPayment(context.Background(), request); err == nil {
+		t.Error("Expected an error without a phone number")
+	}
+}
+
+func TestRazorpayProvider_Create3DPayment_Rejected(t *testing.T) {
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path == endpointOrders {
+			return http.StatusOK, map[string]any{"id": "order_1", "entity": "order", "amount": 50050, "currency": "INR", "status": orderStatusCreated}
+		}
+		return http.StatusBadRequest, apiErrorData("BAD_REQUEST_ERROR", "Your payment didn't go through as it was declined by the bank.")
+	})
+
+	response, err := p.Create3DPayment(context.Background(), paymentRequestFixture())
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "BAD_REQUEST_ERROR" || response.PaymentID != "order_1" {
+		t.Errorf("Expected Razorpay's error, got %+v", response)
+	}
+}
+
+func TestRazorpayProvider_Complete3DPayment(t *testing.T) {
+	status := paymentStatusAuthorized
+	var captured map[string]any
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointPayments + "/pay_1":
+			return http.StatusOK, paymentData(status)
+		case endpointPayments + "/pay_1/capture":
+			captured = body
+			return http.StatusOK, paymentData(paymentStatusCaptured)
+		}
+		t.Errorf("Unexpected request %s", r.URL.Path)
+		return http.StatusNotFound, nil
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         7,
+		PaymentID:        "order_1",
+		OriginalCallback: "https://merchant.example.com/return",
+		Amount:           500.50,
+		Currency:         "INR",
+		ConversationID:   "gp1",
+		Provider:         "razorpay",
+		Environment:      "sandbox",
+	}
+	callback := func(orderID string) map[string]string {
+		return map[string]string{
+			"razorpay_payment_id": "pay_1",
+			"razorpay_order_id":   orderID,
+			"razorpay_signature":  p.paymentSignature(orderID, "pay_1"),
+		}
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, callback("order_1"))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if captured["amount"] != float64(50050) || captured["currency"] != "INR" {
+		t.Errorf("Expected the authorized payment to be captured, got %v", captured)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "pay_1" || response.OrderID != "gp1" || response.RedirectURL != "https://merchant.example.com/return" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	captured = nil
+	status = paymentStatusFailed
+	response, err = p.Complete3DPayment(ctx, callbackState, callback("order_1"))
+	if err != nil || captured != nil || response.Success || response.Status != provider.StatusFailed {
+		t.Errorf("Expected a failed payment, got %+v (%v)", response, err)
+	}
+
+	forged := callback("order_1")
+	forged["razorpay_signature"] = "forged"
+	if _, err := p.Complete3DPayment(ctx, callbackState, forged); err == nil {
+		t.Error("Expected a callback with a wrong signature to be rejected")
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState, callback("order_2")); err == nil {
+		t.Error("Expected a callback for another order to be rejected")
+	}
+
+	other := *callbackState
+	other.Amount = 1
+	status = paymentStatusCaptured
+	if _, err := p.Complete3DPayment(ctx, &other, callback("order_1")); err == nil {
+		t.Error("Expected a payment of another amount to be rejected")
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, map[string]string{"error[code]": "BAD_REQUEST_ERROR", "error[description]": "Payment failed", "error[metadata]": `{"payment_id":"pay_1","order_id":"order_1"}`})
+	if err != nil || response.Status != provider.StatusFailed || response.ErrorCode != "BAD_REQUEST_ERROR" || response.PaymentID != "order_1" {
+		t.Errorf("Expected the callback's error, got %+v (%v)", response, err)
+	}
+}
+
+func TestRazorpayProvider_GetPaymentStatus(t *testing.T) {
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		switch r.URL.Path {
+		case endpointPayments + "/pay_1":
+			payment := paymentData(paymentStatusCaptured)
+			payment["amount_refunded"] = 10000
+			return http.StatusOK, payment
+		case endpointOrders + "/order_1":
+			return http.StatusOK, map[string]any{"id": "order_1", "entity": "order", "amount": 50050, "amount_paid": 0, "amount_due": 50050, "currency": "INR", "status": orderStatusAttempted}
+		}
+		return http.StatusBadRequest, apiErrorData("BAD_REQUEST_ERROR", "The id provided does not exist")
+	})
+	ctx := context.Background()
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "pay_1"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if response.Status != provider.StatusRefunded || response.Amount != 500.50 || response.OrderID != "order_1" || response.ProviderTime == nil {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "order_1"})
+	if err != nil || response.Status != provider.StatusPending {
+		t.Errorf("Expected a pending order, got %+v (%v)", response, err)
+	}
+
+	response, err = p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "pay_unknown"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound, got %+v (%v)", response, err)
+	}
+}
+
+func TestRazorpayProvider_RefundPayment(t *testing.T) {
+	var refundReq map[string]any
+	refundStatus := refundStatusProcessed
+	p := newTestProvider(t, func(r *http.Request, body map[string]any) (int, any) {
+		if r.URL.Path != endpointPayments+"/pay_1/refund" {
+			return http.StatusBadRequest, apiErrorData("BAD_REQUEST_ERROR", "The payment has been fully refunded already")
+		}
+		refundReq = body
+		return http.StatusOK, map[string]any{"id": "rfnd_1", "entity": "refund", "amount": body["amount"], "currency": "INR", "payment_id": "pay_1", "status": refundStatus, "created_at": 1700000000}
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "pay_1", RefundAmount: 100, Currency: "INR", Reason: "returned"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refundReq["amount"] != float64(10000) || refundReq["notes"].(map[string]any)["reason"] != "returned" {
+		t.Errorf("Unexpected refund request %v", refundReq)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "rfnd_1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	refundStatus = refundStatusPending
+	if response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "pay_1", RefundAmount: 100, Currency: "INR"}); err != nil || !response.Success || response.Status != "pending" {
+		t.Errorf("Expected a pending refund, got %+v (%v)", response, err)
+	}
+
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "pay_2", RefundAmount: 100, Currency: "INR"})
+	if err != nil || response.Success || response.ErrorCode != "BAD_REQUEST_ERROR" {
+		t.Errorf("Expected Razorpay's error, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "pay_1"}); err == nil {
+		t.Error("Expected an error without a refund amount")
+	}
+}
+
+func TestRazorpayProvider_ValidateWebhook(t *testing.T) {
+	p := NewProvider().(*RazorpayProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	webhook := func(event string, payment map[string]any) (map[string]string, map[string]string) {
+		body, _ := json.Marshal(map[string]any{
+			"entity":     "event",
+			"account_id": "acc_1",
+			"event":      event,
+			"contains":   []string{"payment"},
+			"payload":    map[string]any{"payment": map[string]any{"entity": payment}},
+			"created_at": 1700000000,
+		})
+		mac := hmac.New(sha256.New, []byte("webhook_secret_123"))
+		mac.Write(body)
+
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(body, &fields)
+		data := make(map[string]string)
+		for key, raw := range fields {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				value = string(raw)
+			}
+			data[key] = value
+		}
+		return data, map[string]string{
+			webhookSignatureHeader:        hex.EncodeToString(mac.Sum(nil)),
+			provider.WebhookRawBodyHeader: string(body),
+		}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		event  string
+		status string
+		want   string
+	}{
+		{"payment.captured", paymentStatusCaptured, "success"},
+		{"payment.failed", paymentStatusFailed, "failed"},
+		{"payment.authorized", paymentStatusAuthorized, "pending"},
+		{"refund.processed", paymentStatusCaptured, "refunded"},
+	}
+	for _, tt := range tests {
+		data, headers := webhook(tt.event, paymentData(tt.status))
+		valid, result, err := p.ValidateWebhook(ctx, data, headers)
+		if err != nil || !valid || result["paymentId"] != "pay_1" || result["status"] != tt.want || result["eventType"] != tt.event {
+			t.Errorf("%s: unexpected result %v (%v)", tt.event, result, err)
+		}
+	}
+
+	data, headers := webhook("payment.captured", paymentData(paymentStatusCaptured))
+	headers[provider.WebhookRawBodyHeader] = strings.Replace(headers[provider.WebhookRawBodyHeader], "50050", "1", 1)
+	if valid, _, err := p.ValidateWebhook(ctx, data, headers); err == nil || valid {
+		t.Error("Expected a tampered body to be rejected")
+	}
+
+	data, headers = webhook("payment.captured", paymentData(paymentStatusCaptured))
+	delete(headers, provider.WebhookRawBodyHeader)
+	if valid, _, err := p.ValidateWebhook(ctx, data, headers); err == nil || valid {
+		t.Error("Expected a webhook without its body to be rejected")
+	}
+
+	p.webhookSecret = ""
+	data, headers = webhook("payment.captured", paymentData(paymentStatusCaptured))
+	if _, _, err := p.ValidateWebhook(ctx, data, headers); err == nil {
+		t.Error("Expected an error without a webhook secret")
+	}
+}
+
+func TestRazorpayProvider_Unsupported(t *testing.T) {
+	p := NewProvider().(*RazorpayProvider)
+	if _, err := p.CreatePayment(context.Background(), paymentRequestFixture()); err == nil {
+		t.Error("Expected non-3D payments to be unsupported")
+	}
+	if _, err := p.CancelPayment(context.Background(), provider.CancelRequest{PaymentID: "pay_1"}); err == nil {
+		t.Error("Expected cancels to be unsupported")
+	}
+}
@@ -0,0 +1,8 @@
+package razorpay
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Razorpay provider with the global registry
+	provider.Register("razorpay", NewProvider)
+}
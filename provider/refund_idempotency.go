@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrRefundInProgress is returned when a refund with the same idempotency key is still
+	// being processed
+	ErrRefundInProgress = errors.New("a refund with this idempotency key is already in progress")
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a
+	// different refund amount
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different refund")
+)
+
+// RefundIdempotencyKey identifies a refund submission. The same key may be used for
+// different payments.
+type RefundIdempotencyKey struct {
+	TenantID  int
+	PaymentID string
+	Key       string
+}
+
+// RefundIdempotencyStore remembers refunds by idempotency key so a repeated submission
+// returns the first result instead of refunding twice.
+type RefundIdempotencyStore interface {
+	// Claim reserves the key for a refund of amount. When the key was claimed before it
+	// returns claimed=false along with the stored response, which is nil while the first
+	// refund is still in flight, and the amount it was claimed with.
+	Claim(ctx context.Context, key RefundIdempotencyKey, amount float64) (claimed bool, prior *RefundResponse, priorAmount float64, err error)
+
+	// Complete stores the response of a claimed refund
+	Complete(ctx context.Context, key RefundIdempotencyKey, response *RefundResponse) error
+
+	// Release drops a claim whose refund failed without a provider response, so the
+	// client may retry with the same key
+	Release(ctx context.Context, key RefundIdempotencyKey) error
+}
+
+// PostgresRefundIdempotencyStore keeps idempotency keys in the refund_idempotency_keys table.
+type PostgresRefundIdempotencyStore struct {
+	db *sql.DB
+}
+
+// NewPostgresRefundIdempotencyStore creates a store over the shared *sql.DB connection.
+func NewPostgresRefundIdempotencyStore(db *sql.DB) *PostgresRefundIdempotencyStore {
+	return &PostgresRefundIdempotencyStore{db: db}
+}
+
+// Claim inserts the key, or returns the existing row when it is already taken. The primary
+// key on (tenant_id, payment_id, idempotency_key) makes concurrent claims race-free.
+func (r *PostgresRefundIdempotencyStore) Claim(ctx context.Context, key RefundIdempotencyKey, amount float64) (bool, *RefundResponse, float64, error) {
+	query := `
+		INSERT INTO refund_idempotency_keys (tenant_id, payment_id, idempotency_key, refund_amount)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, key.TenantID, key.PaymentID, key.Key, amount)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("failed to claim refund idempotency key: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return false, nil, 0, err
+	} else if affected > 0 {
+		return true, nil, 0, nil
+	}
+
+	var (
+		priorAmount float64
+		raw         []byte
+	)
+	err = r.db.QueryRowContext(ctx, `
+		SELECT refund_amount, response FROM refund_idempotency_keys
+		WHERE tenant_id = $1 AND payment_id = $2 AND idempotency_key = $3`,
+		key.TenantID, key.PaymentID, key.Key,
+	).Scan(&priorAmount, &raw)
+	if err != nil {
+		return false, nil, 0, fmt.Errorf("failed to get refund idempotency key: %w", err)
+	}
+
+	if raw == nil {
+		return false, nil, priorAmount, nil
+	}
+	var prior RefundResponse
+	if err := json.Unmarshal(raw, &prior); err != nil {
+		return false, nil, 0, fmt.Errorf("failed to decode stored refund response: %w", err)
+	}
+	return false, &prior, priorAmount, nil
+}
+
+// Complete stores the response of a claimed refund
+func (r *PostgresRefundIdempotencyStore) Complete(ctx context.Context, key RefundIdempotencyKey, response *RefundResponse) error {
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode refund response: %w", err)
+	}
+
+	query := `
+		UPDATE refund_idempotency_keys SET response = $4, completed_at = now()
+		WHERE tenant_id = $1 AND payment_id = $2 AND idempotency_key = $3`
+	if _, err := r.db.ExecContext(ctx, query, key.TenantID, key.PaymentID, key.Key, raw); err != nil {
+		return fmt.Errorf("failed to store refund response: %w", err)
+	}
+	return nil
+}
+
+// Release deletes a claim that has no response yet
+func (r *PostgresRefundIdempotencyStore) Release(ctx context.Context, key RefundIdempotencyKey) error {
+	query := `
+		DELETE FROM refund_idempotency_keys
+		WHERE tenant_id = $1 AND payment_id = $2 AND idempotency_key = $3 AND response IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, key.TenantID, key.PaymentID, key.Key); err != nil {
+		return fmt.Errorf("failed to release refund idempotency key: %w", err)
+	}
+	return nil
+}
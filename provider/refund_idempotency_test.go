@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+type storedRefund struct {
+	amount   float64
+	response *RefundResponse
+}
+
+// memoryRefundIdempotencyStore is an in-memory RefundIdempotencyStore for tests.
+type memoryRefundIdempotencyStore struct {
+	mu      sync.Mutex
+	refunds map[RefundIdempotencyKey]*storedRefund
+}
+
+func newMemoryRefundIdempotencyStore() *memoryRefundIdempotencyStore {
+	return &memoryRefundIdempotencyStore{refunds: make(map[RefundIdempotencyKey]*storedRefund)}
+}
+
+func (m *memoryRefundIdempotencyStore) Claim(_ context.Context, key RefundIdempotencyKey, amount float64) (bool, *RefundResponse, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.refunds[key]; ok {
+		return false, stored.response, stored.amount, nil
+	}
+	m.refunds[key] = &storedRefund{amount: amount}
+	return true, nil, 0, nil
+}
+
+func (m *memoryRefundIdempotencyStore) Complete(_ context.Context, key RefundIdempotencyKey, response *RefundResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refunds[key].response = response
+	return nil
+}
+
+func (m *memoryRefundIdempotencyStore) Release(_ context.Context, key RefundIdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.refunds[key]; ok && stored.response == nil {
+		delete(m.refunds, key)
+	}
+	return nil
+}
+
+// refundTestProvider counts refunds and hands out a new refund ID each time
+type refundTestProvider struct {
+	PaymentProvider
+	refunds int
+	err     error
+}
+
+func (p *refundTestProvider) RefundPayment(_ context.Context, request RefundRequest) (*RefundResponse, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.refunds++
+	return &RefundResponse{
+		Success:      true,
+		RefundID:     fmt.Sprintf("re_%d", p.refunds),
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		Status:       "succeeded",
+	}, nil
+}
+
+func newRefundTestService(t *testing.T, tenantID int) (*PaymentService, *refundTestProvider, context.Context) {
+	const providerName = "refundtest"
+	fake := &refundTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.SetRefundIdempotencyStore(newMemoryRefundIdempotencyStore())
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, strconv.Itoa(tenantID))
+	return service, fake, ctx
+}
+
+func TestPaymentService_RefundPayment_DuplicateReturnsSameResult(t *testing.T) {
+	service, fake, ctx := newRefundTestService(t, 9104)
+	request := RefundRequest{PaymentID: "pay_1", RefundAmount: 25.5, IdempotencyKey: "refund-key-1"}
+
+	first, err := service.RefundPayment(ctx, "sandbox", "refundtest", request)
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	second, err := service.RefundPayment(ctx, "sandbox", "refundtest", request)
+	if err != nil {
+		t.Fatalf("Duplicate RefundPayment failed: %v", err)
+	}
+
+	if fake.refunds != 1 {
+		t.Errorf("Expected a single refund at the provider, got %d", fake.refunds)
+	}
+	if second.RefundID != first.RefundID || second.RefundAmount != first.RefundAmount {
+		t.Errorf("Expected the first refund to be returned, got %+v vs %+v", second, first)
+	}
+	if first.Replayed || !second.Replayed {
+		t.Errorf("Expected only the duplicate to be marked replayed, got %v and %v", first.Replayed, second.Replayed)
+	}
+
+	// The same key on another payment is a separate refund
+	other := request
+	other.PaymentID = "pay_2"
+	if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", other); err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if fake.refunds != 2 {
+		t.Errorf("Expected another payment to be refunded, got %d refunds", fake.refunds)
+	}
+}
+
+func TestPaymentService_RefundPayment_WithoutKeyIsNotDeduplicated(t *testing.T) {
+	service, fake, ctx := newRefundTestService(t, 9104)
+	request := RefundRequest{PaymentID: "pay_1", RefundAmount: 10}
+
+	for range 2 {
+		if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", request); err != nil {
+			t.Fatalf("RefundPayment failed: %v", err)
+		}
+	}
+	if fake.refunds != 2 {
+		t.Errorf("Expected refunds without a key to reach the provider, got %d", fake.refunds)
+	}
+}
+
+func TestPaymentService_RefundPayment_KeyReusedForDifferentAmount(t *testing.T) {
+	service, fake, ctx := newRefundTestService(t, 9104)
+	request := RefundRequest{PaymentID: "pay_1", RefundAmount: 10, IdempotencyKey: "refund-key-1"}
+
+	if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", request); err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	request.RefundAmount = 20
+	if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", request); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("Expected ErrIdempotencyKeyReused, got %v", err)
+	}
+	if fake.refunds != 1 {
+		t.Errorf("Expected a single refund at the provider, got %d", fake.refunds)
+	}
+}
+
+func TestPaymentService_RefundPayment_InProgress(t *testing.T) {
+	service, fake, ctx := newRefundTestService(t, 9104)
+	key := RefundIdempotencyKey{TenantID: 9104, PaymentID: "pay_1", Key: "refund-key-1"}
+	if _, _, _, err := service.refundIdempotency.Claim(ctx, key, 10); err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+
+	request := RefundRequest{PaymentID: "pay_1", RefundAmount: 10, IdempotencyKey: "refund-key-1"}
+	if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", request); !errors.Is(err, ErrRefundInProgress) {
+		t.Errorf("Expected ErrRefundInProgress, got %v", err)
+	}
+	if fake.refunds != 0 {
+		t.Errorf("Expected no refund while the first is in flight, got %d", fake.refunds)
+	}
+}
+
+func TestPaymentService_RefundPayment_FailedRefundCanBeRetried(t *testing.T) {
+	service, fake, ctx := newRefundTestService(t, 9104)
+	request := RefundRequest{PaymentID: "pay_1", RefundAmount: 10, IdempotencyKey: "refund-key-1"}
+
+	fake.err = errors.New("provider unavailable")
+	if _, err := service.RefundPayment(ctx, "sandbox", "refundtest", request); err == nil {
+		t.Fatal("Expected provider error")
+	}
+
+	fake.err = nil
+	resp, err := service.RefundPayment(ctx, "sandbox", "refundtest", request)
+	if err != nil || !resp.Success {
+		t.Fatalf("Expected retry with the same key to refund, got %+v (err %v)", resp, err)
+	}
+	if fake.refunds != 1 {
+		t.Errorf("Expected one successful refund, got %d", fake.refunds)
+	}
+}
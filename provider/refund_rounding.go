@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// RefundRounding is how RefundPayment rounds a refund amount to the currency's minor unit.
+// Splitting a payment (100 / 3) or taking commission off an installment payment leaves
+// sub-cent amounts that providers reject.
+type RefundRounding string
+
+const (
+	// RefundRoundingNearest rounds half away from zero, 33.335 to 33.34 (default)
+	RefundRoundingNearest RefundRounding = "nearest"
+	// RefundRoundingDown rounds toward zero, so a refund never exceeds the amount asked for
+	RefundRoundingDown RefundRounding = "down"
+	// RefundRoundingOff sends the amount as requested
+	RefundRoundingOff RefundRounding = "off"
+)
+
+var (
+	// ErrRefundExceedsRefundable is returned when the rounded refund amount is more than
+	// what is left to refund of the payment
+	ErrRefundExceedsRefundable = errors.New("refund amount exceeds the refundable amount")
+
+	// ErrRefundAmountTooSmall is returned when the refund amount rounds to zero
+	ErrRefundAmountTooSmall = errors.New("refund amount is smaller than the currency's minor unit")
+)
+
+// RefundableAmountLookup is an OPTIONAL capability of a PaymentLogger that knows how much of
+// a payment is left to refund. RefundPayment checks rounded refund amounts against it.
+type RefundableAmountLookup interface {
+	// RefundableAmount returns the payment amount minus its successful refunds, and the
+	// payment currency. It returns sql.ErrNoRows when the payment is not in the logs.
+	RefundableAmount(ctx context.Context, tenantID int, providerName, paymentID string) (float64, string, error)
+}
+
+// RefundRoundingFromEnv reads REFUND_ROUNDING: nearest (default), down or off
+func RefundRoundingFromEnv() RefundRounding {
+	switch mode := RefundRounding(strings.ToLower(strings.TrimSpace(config.GetEnv("REFUND_ROUNDING", "")))); mode {
+	case RefundRoundingDown, RefundRoundingOff:
+		return mode
+	}
+	return RefundRoundingNearest
+}
+
+// RoundToMinorUnit rounds a major unit amount to the minor unit of its currency, e.g. to
+// cents for TRY and to whole yen for JPY
+func RoundToMinorUnit(amount float64, currency string, mode RefundRounding) float64 {
+	switch mode {
+	case RefundRoundingOff:
+		return amount
+	case RefundRoundingDown:
+		// The epsilon keeps amounts like 0.29, stored as 0.28999..., at 29 cents
+		scale := math.Pow10(CurrencyExponent(currency))
+		return math.Trunc(amount*scale+math.Copysign(1e-6, amount)) / scale
+	default:
+		return FromMinorUnits(ToMinorUnits(amount, currency), currency)
+	}
+}
+
+// refundAmount is a refund amount rounded to the minor unit of its currency, along with
+// what is left to refund of the payment when the payment logger can tell it
+type refundAmount struct {
+	amount         float64
+	currency       string
+	remaining      float64
+	remainingKnown bool
+}
+
+// roundRefundAmount rounds the refund amount of a request to the minor unit of the payment
+// currency, looking the currency up when the request does not carry it
+func (s *PaymentService) roundRefundAmount(ctx context.Context, tenantID int, providerName string, request RefundRequest) (refundAmount, error) {
+	rounded := refundAmount{currency: request.Currency}
+	if lookup, ok := s.logger.(RefundableAmountLookup); ok {
+		remaining, currency, err := lookup.RefundableAmount(ctx, tenantID, providerName, request.PaymentID)
+		switch {
+		case err == nil:
+			rounded.remaining, rounded.remainingKnown = remaining, true
+			if rounded.currency == "" {
+				rounded.currency = currency
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			return refundAmount{}, fmt.Errorf("failed to get refundable amount: %w", err)
+		}
+	}
+
+	rounded.amount = RoundToMinorUnit(request.RefundAmount, rounded.currency, RefundRoundingFromEnv())
+	if ToMinorUnits(rounded.amount, rounded.currency) <= 0 {
+		return refundAmount{}, fmt.Errorf("%w: %v %s", ErrRefundAmountTooSmall, request.RefundAmount, rounded.currency)
+	}
+	return rounded, nil
+}
+
+// checkRefundable fails when the rounded amount is more than what is left to refund
+func (a refundAmount) checkRefundable() error {
+	if a.remainingKnown && ToMinorUnits(a.amount, a.currency) > ToMinorUnits(a.remaining, a.currency) {
+		return fmt.Errorf("%w: %v %s requested, %v %s left", ErrRefundExceedsRefundable, a.amount, a.currency, a.remaining, a.currency)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestRoundToMinorUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		mode     RefundRounding
+		want     float64
+	}{
+		{"third of a payment", 100.0 / 3, "TRY", RefundRoundingNearest, 33.33},
+		{"half cent rounds up", 33.335, "USD", RefundRoundingNearest, 33.34},
+		{"down", 66.6666, "TRY", RefundRoundingDown, 66.66},
+		{"down keeps exact cents", 0.29, "EUR", RefundRoundingDown, 0.29},
+		{"off", 100.0 / 3, "TRY", RefundRoundingOff, 100.0 / 3},
+		{"zero-decimal currency", 1234.5, "JPY", RefundRoundingNearest, 1235},
+		{"zero-decimal currency down", 1234.5, "JPY", RefundRoundingDown, 1234},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RoundToMinorUnit(tt.amount, tt.currency, tt.mode); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRefundRoundingFromEnv(t *testing.T) {
+	if got := RefundRoundingFromEnv(); got != RefundRoundingNearest {
+		t.Errorf("expected nearest by default, got %s", got)
+	}
+	t.Setenv("REFUND_ROUNDING", "DOWN")
+	if got := RefundRoundingFromEnv(); got != RefundRoundingDown {
+		t.Errorf("expected down, got %s", got)
+	}
+	t.Setenv("REFUND_ROUNDING", "sideways")
+	if got := RefundRoundingFromEnv(); got != RefundRoundingNearest {
+		t.Errorf("expected an invalid setting to fall back to nearest, got %s", got)
+	}
+}
+
+// refundableLogger is a payment logger that knows what is left to refund of one payment
+type refundableLogger struct {
+	recordingPaymentLogger
+	remaining float64
+	currency  string
+}
+
+func (l *refundableLogger) RefundableAmount(_ context.Context, _ int, _, paymentID string) (float64, string, error) {
+	if paymentID != "pay_1" {
+		return 0, "", sql.ErrNoRows
+	}
+	return l.remaining, l.currency, nil
+}
+
+func TestPaymentService_RefundPayment_Rounding(t *testing.T) {
+	const tenantID, providerName = 9113, "refundtest"
+
+	fake := &refundTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &refundableLogger{remaining: 100, currency: "TRY"}
+	service := NewPaymentService(paymentLogger)
+	service.SetRefundIdempotencyStore(newMemoryRefundIdempotencyStore())
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9113")
+
+	resp, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1", RefundAmount: 100.0 / 3, IdempotencyKey: "third"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if resp.RefundAmount != 33.33 {
+		t.Errorf("expected the provider to get 33.33, got %v", resp.RefundAmount)
+	}
+
+	// The retry matches the first refund even though the remaining amount has changed
+	paymentLogger.remaining = 66.67
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1", RefundAmount: 33.333, IdempotencyKey: "third"}); err != nil {
+		t.Fatalf("expected the retry to return the first refund, got %v", err)
+	}
+	if fake.refunds != 1 {
+		t.Errorf("expected one refund at the provider, got %d", fake.refunds)
+	}
+
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1", RefundAmount: 66.68}); !errors.Is(err, ErrRefundExceedsRefundable) {
+		t.Errorf("expected ErrRefundExceedsRefundable, got %v", err)
+	}
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1", RefundAmount: 0.004}); !errors.Is(err, ErrRefundAmountTooSmall) {
+		t.Errorf("expected ErrRefundAmountTooSmall, got %v", err)
+	}
+
+	// Payments missing from the logs are refunded without the check
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_2", RefundAmount: 500, Currency: "TRY"}); err != nil {
+		t.Errorf("expected an unknown payment to be refunded, got %v", err)
+	}
+	if fake.refunds != 2 {
+		t.Errorf("expected two refunds at the provider, got %d", fake.refunds)
+	}
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestRefundWindow(t *testing.T) {
+	if got := RefundWindow("stripe"); got != 180*24*time.Hour {
+		t.Errorf("expected Stripe's documented window, got %s", got)
+	}
+	if got := RefundWindow("papara"); got != 0 {
+		t.Errorf("expected no window for a provider without one, got %s", got)
+	}
+
+	t.Setenv("REFUND_MAX_AGE_DAYS_STRIPE", "90")
+	if got := RefundWindow("stripe"); got != 90*24*time.Hour {
+		t.Errorf("expected the configured window, got %s", got)
+	}
+	t.Setenv("REFUND_MAX_AGE_DAYS_STRIPE", "0")
+	if got := RefundWindow("stripe"); got != 0 {
+		t.Errorf("expected 0 to turn the check off, got %s", got)
+	}
+}
+
+// paymentTimeLogger is a payment logger that knows when one payment was made
+type paymentTimeLogger struct {
+	recordingPaymentLogger
+	paidAt time.Time
+}
+
+func (l *paymentTimeLogger) PaymentTime(_ context.Context, _ int, _, paymentID string) (time.Time, error) {
+	if paymentID != "pay_1" {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return l.paidAt, nil
+}
+
+func TestPaymentService_RefundPayment_RefundWindow(t *testing.T) {
+	const tenantID, providerName = 9116, "refundtest"
+	t.Setenv("REFUND_MAX_AGE_DAYS_REFUNDTEST", "30")
+
+	fake := &refundTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &paymentTimeLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9116")
+	window := 30 * 24 * time.Hour
+
+	paymentLogger.paidAt = time.Now().Add(-window + time.Minute)
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1"}); err != nil {
+		t.Fatalf("expected a refund just inside the window, got %v", err)
+	}
+
+	paymentLogger.paidAt = time.Now().Add(-window - time.Minute)
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_1"}); !errors.Is(err, ErrRefundWindowClosed) {
+		t.Errorf("expected ErrRefundWindowClosed just outside the window, got %v", err)
+	}
+	if fake.refunds != 1 {
+		t.Errorf("expected the late refund not to reach the provider, got %d refunds", fake.refunds)
+	}
+
+	// Payments missing from the logs are left to the provider
+	if _, err := service.RefundPayment(ctx, "sandbox", providerName, RefundRequest{PaymentID: "pay_2"}); err != nil {
+		t.Errorf("expected an unknown payment to be refunded, got %v", err)
+	}
+}
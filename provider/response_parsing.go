@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// ErrResponseUnparseable is returned for a successful provider response whose body cannot be
+// parsed. Unlike a network failure the provider may have carried out the request, so the state
+// of the payment is unknown until its status is checked.
+var ErrResponseUnparseable = errors.New("provider response could not be parsed")
+
+// ErrorCodePaymentStateUnknown is the error code of a payment answered with ErrResponseUnparseable
+const ErrorCodePaymentStateUnknown = "PAYMENT_STATE_UNKNOWN"
+
+// ResponseParsing is how strictly ParseJSONResponse decodes a provider response
+type ResponseParsing string
+
+const (
+	// ResponseParsingStrict fails on fields the target does not declare and on values of
+	// the wrong type, so drift in a provider's API is caught
+	ResponseParsingStrict ResponseParsing = "strict"
+
+	// ResponseParsingLenient decodes what it can and logs a warning for unexpected fields
+	// and types, so a minor change on the provider side does not break payments
+	ResponseParsingLenient ResponseParsing = "lenient"
+)
+
+// ResponseParsingFor returns the parsing mode of a provider, read from the first of these
+// that is set to strict or lenient:
+//
+//	PROVIDER_RESPONSE_PARSING_<PROVIDER>
+//	PROVIDER_RESPONSE_PARSING
+//
+// It defaults to lenient, and to strict in test binaries.
+func ResponseParsingFor(providerName string) ResponseParsing {
+	keys := []string{"PROVIDER_RESPONSE_PARSING"}
+	if providerName = strings.ToUpper(strings.TrimSpace(providerName)); providerName != "" {
+		keys = append([]string{"PROVIDER_RESPONSE_PARSING_" + providerName}, keys...)
+	}
+
+	for _, key := range keys {
+		switch mode := ResponseParsing(strings.ToLower(strings.TrimSpace(config.GetEnv(key, "")))); mode {
+		case ResponseParsingStrict, ResponseParsingLenient:
+			return mode
+		}
+	}
+
+	if testing.Testing() {
+		return ResponseParsingStrict
+	}
+	return ResponseParsingLenient
+}
+
+// parseJSONResponse decodes body into target in the given mode
+func parseJSONResponse(providerName string, mode ResponseParsing, body []byte, target any) error {
+	err := decodeStrict(body, target)
+	if err == nil {
+		return nil
+	}
+	if mode == ResponseParsingStrict {
+		return fmt.Errorf("strict parsing of %s response: %w", providerName, err)
+	}
+	if !isResponseDriftError(err) {
+		return err
+	}
+
+	logger.Warn("Provider response does not match the expected format", logger.LogContext{
+		Provider: providerName,
+		Fields: map[string]any{
+			"error": err.Error(),
+		},
+	})
+
+	// Unmarshal skips the fields with the wrong type and fills in the rest
+	if err := json.Unmarshal(body, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeStrict decodes body into target, rejecting fields the target does not declare
+func decodeStrict(body []byte, target any) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(target)
+}
+
+// isResponseDriftError reports whether a decoding error comes from an unexpected field or
+// type rather than from a body that is not JSON at all
+func isResponseDriftError(err error) bool {
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &typeErr) || strings.HasPrefix(err.Error(), "json: unknown field")
+}
+
+// unknownStateResponse answers a payment whose provider response could not be parsed. The
+// charge may have succeeded, so it is reported with status unknown, for the merchant to check
+// with GetPaymentStatus, rather than as a failure.
+func unknownStateResponse(providerName, paymentID string, amount float64, currency string, err error) *PaymentResponse {
+	logger.Warn("Payment state unknown, provider response could not be parsed", logger.LogContext{
+		Provider: providerName,
+		Fields: map[string]any{
+			"payment_id": paymentID,
+			"error":      err.Error(),
+		},
+	})
+
+	now := time.Now()
+	response := &PaymentResponse{
+		Success:    false,
+		Status:     StatusUnknown,
+		ErrorCode:  ErrorCodePaymentStateUnknown,
+		Message:    "Payment state is indeterminate, check the payment status before retrying",
+		PaymentID:  paymentID,
+		Amount:     amount,
+		Currency:   currency,
+		SystemTime: &now,
+	}
+	response.Outcome = ResolveOutcome(response)
+	response.NextAction = ResolveNextAction(response)
+	return response
+}
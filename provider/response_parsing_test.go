@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// driftedResponse has a field the target does not declare and a code of the wrong type
+const driftedResponse = `{"status":"success","paymentId":"pay-1","newField":"added by the provider","code":42}`
+
+type parsingTarget struct {
+	Status    string `json:"status"`
+	PaymentID string `json:"paymentId"`
+	Code      string `json:"code"`
+}
+
+func TestResponseParsingFor(t *testing.T) {
+	if got := ResponseParsingFor("iyzico"); got != ResponseParsingStrict {
+		t.Errorf("expected strict parsing by default in tests, got %s", got)
+	}
+
+	t.Setenv("PROVIDER_RESPONSE_PARSING", "lenient")
+	if got := ResponseParsingFor("iyzico"); got != ResponseParsingLenient {
+		t.Errorf("expected the global setting, got %s", got)
+	}
+
+	t.Setenv("PROVIDER_RESPONSE_PARSING_IYZICO", "STRICT")
+	if got := ResponseParsingFor("iyzico"); got != ResponseParsingStrict {
+		t.Errorf("expected the provider setting to win, got %s", got)
+	}
+	if got := ResponseParsingFor("paytr"); got != ResponseParsingLenient {
+		t.Errorf("expected other providers to keep the global setting, got %s", got)
+	}
+
+	t.Setenv("PROVIDER_RESPONSE_PARSING_IYZICO", "sometimes")
+	if got := ResponseParsingFor("iyzico"); got != ResponseParsingLenient {
+		t.Errorf("expected an invalid provider setting to be ignored, got %s", got)
+	}
+}
+
+func TestParseJSONResponse_Strict(t *testing.T) {
+	t.Setenv("PROVIDER_RESPONSE_PARSING_IYZICO", "strict")
+	client := NewProviderHTTPClient(CreateHTTPClientConfig("https://example.com", false).ForProvider("iyzico"))
+
+	var target parsingTarget
+	if err := client.ParseJSONResponse(&HTTPResponse{Body: []byte(driftedResponse)}, &target); err == nil {
+		t.Fatal("expected strict parsing to fail on an unexpected field")
+	}
+
+	var clean parsingTarget
+	body := []byte(`{"status":"success","paymentId":"pay-1","code":"00"}`)
+	if err := client.ParseJSONResponse(&HTTPResponse{Body: body}, &clean); err != nil {
+		t.Fatalf("expected a matching response to parse, got %v", err)
+	}
+	if clean.Code != "00" {
+		t.Errorf("expected code 00, got %q", clean.Code)
+	}
+}
+
+func TestParseJSONResponse_Lenient(t *testing.T) {
+	t.Setenv("PROVIDER_RESPONSE_PARSING_IYZICO", "lenient")
+	client := NewProviderHTTPClient(CreateHTTPClientConfig("https://example.com", false).ForProvider("iyzico"))
+
+	var target parsingTarget
+	if err := client.ParseJSONResponse(&HTTPResponse{Body: []byte(driftedResponse)}, &target); err != nil {
+		t.Fatalf("expected lenient parsing to succeed, got %v", err)
+	}
+	if target.Status != "success" || target.PaymentID != "pay-1" {
+		t.Errorf("expected the known fields to be parsed, got %+v", target)
+	}
+	if target.Code != "" {
+		t.Errorf("expected the mistyped field to be skipped, got %q", target.Code)
+	}
+
+	if err := client.ParseJSONResponse(&HTTPResponse{Body: []byte("<html>error</html>")}, &target); err == nil {
+		t.Error("expected a body that is not JSON to fail in lenient mode too")
+	}
+}
+
+// htmlErrorServer answers every request with a 200 and an HTML error page, as providers'
+// gateways do when something in front of the API fails
+func htmlErrorServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><body>Service temporarily unavailable</body></html>"))
+	}))
+}
+
+func TestParseJSONResponse_Unparseable(t *testing.T) {
+	server := htmlErrorServer()
+	defer server.Close()
+	client := NewProviderHTTPClient(CreateHTTPClientConfig(server.URL, false).ForProvider("iyzico"))
+
+	resp, err := client.SendJSON(context.Background(), &HTTPRequest{Method: http.MethodPost, Endpoint: "/payment"})
+	if err != nil {
+		t.Fatalf("SendJSON failed: %v", err)
+	}
+	var target parsingTarget
+	if err := client.ParseJSONResponse(resp, &target); !errors.Is(err, ErrResponseUnparseable) {
+		t.Errorf("expected ErrResponseUnparseable for an unparseable 200, got %v", err)
+	}
+
+	// An error status says the request was not carried out
+	err = client.ParseJSONResponse(&HTTPResponse{StatusCode: http.StatusBadGateway, Body: []byte("<html>bad gateway</html>")}, &target)
+	if err == nil || errors.Is(err, ErrResponseUnparseable) {
+		t.Errorf("expected a plain parse error for a 502, got %v", err)
+	}
+}
+
+// unparseableTestProvider calls a server answering with HTML and wraps the parse error like
+// the providers do
+type unparseableTestProvider struct {
+	PaymentProvider
+	client *ProviderHTTPClient
+}
+
+func (p *unparseableTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *unparseableTestProvider) CreatePayment(ctx context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	resp, err := p.client.SendJSON(ctx, &HTTPRequest{Method: http.MethodPost, Endpoint: "/payment", Body: request})
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	var responseData map[string]any
+	if err := p.client.ParseJSONResponse(resp, &responseData); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &PaymentResponse{Success: true, Status: StatusSuccessful}, nil
+}
+
+func TestPaymentService_CreatePayment_UnparseableResponse(t *testing.T) {
+	server := htmlErrorServer()
+	defer server.Close()
+
+	const tenantID, providerName = 9123, "unparseabletest"
+	fake := &unparseableTestProvider{client: NewProviderHTTPClient(CreateHTTPClientConfig(server.URL, false).ForProvider(providerName))}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &recordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9123")
+
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("expected an unknown state response instead of an error, got %v", err)
+	}
+	if resp.Success || resp.Status != StatusUnknown || resp.ErrorCode != ErrorCodePaymentStateUnknown {
+		t.Errorf("expected status %s with code %s, got %+v", StatusUnknown, ErrorCodePaymentStateUnknown, resp)
+	}
+	if resp.Outcome != OutcomeRequiresAction || resp.NextAction == nil || resp.NextAction.Type != NextActionPollStatus {
+		t.Errorf("expected the client to be told to check the status, got outcome %q and %+v", resp.Outcome, resp.NextAction)
+	}
+	if resp.Amount != 100 || resp.Currency != "TRY" {
+		t.Errorf("expected the requested amount, got %.2f %s", resp.Amount, resp.Currency)
+	}
+
+	logged, ok := paymentLogger.responses[len(paymentLogger.responses)-1].(*PaymentResponse)
+	if !ok || logged.Status != StatusUnknown {
+		t.Errorf("expected the unknown state to be logged, got %+v", paymentLogger.responses)
+	}
+}
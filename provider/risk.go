@@ -0,0 +1,191 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// BlockReason names the internal rule that stopped a payment before it reached the provider
+type BlockReason string
+
+const (
+	// BlockReasonAmountLimitExceeded means the amount is above RISK_MAX_AMOUNT
+	BlockReasonAmountLimitExceeded BlockReason = "amount_limit_exceeded"
+	// BlockReasonVelocityExceeded means the same card/customer paid too often within the window
+	BlockReasonVelocityExceeded BlockReason = "velocity_exceeded"
+	// BlockReasonCountryMismatch means the billing country differs from the client's country
+	BlockReasonCountryMismatch BlockReason = "country_mismatch"
+)
+
+const defaultRiskVelocityWindow = time.Hour
+
+// PaymentBlock explains why a payment was blocked before reaching the provider, so
+// merchants can tell internal blocks from provider declines
+type PaymentBlock struct {
+	Reason  BlockReason `json:"reason"`
+	Message string      `json:"message"`
+}
+
+// RiskEvaluator decides whether a payment may be sent to the provider. Evaluate returns nil
+// to let the payment through.
+type RiskEvaluator interface {
+	Evaluate(ctx context.Context, providerName string, request PaymentRequest) *PaymentBlock
+}
+
+// RuleRiskEvaluator is the built-in RiskEvaluator. Every rule is off unless configured:
+//
+//   - RISK_MAX_AMOUNT: largest amount accepted per payment
+//   - RISK_COUNTRY_CHECK: block when the billing country and clientCountry (both ISO
+//     alpha-2) differ
+//   - RISK_VELOCITY_LIMIT / RISK_VELOCITY_WINDOW: payments allowed per card (or customer
+//     email, or client IP) and tenant within the window (Go duration, default 1h)
+//
+// Velocity is counted in memory, so with several instances the limit applies per instance.
+type RuleRiskEvaluator struct {
+	maxAmount      float64
+	countryCheck   bool
+	velocityLimit  int
+	velocityWindow time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRuleRiskEvaluator creates the built-in evaluator from the RISK_* environment variables
+func NewRuleRiskEvaluator() *RuleRiskEvaluator {
+	e := &RuleRiskEvaluator{
+		countryCheck:   config.GetBoolEnv("RISK_COUNTRY_CHECK", false),
+		velocityLimit:  config.GetIntEnv("RISK_VELOCITY_LIMIT", 0),
+		velocityWindow: defaultRiskVelocityWindow,
+		attempts:       make(map[string][]time.Time),
+		now:            time.Now,
+	}
+
+	if value := strings.TrimSpace(config.GetEnv("RISK_MAX_AMOUNT", "")); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 {
+			e.maxAmount = parsed
+		} else {
+			logger.Warn("Ignoring invalid RISK_MAX_AMOUNT", logger.LogContext{
+				Fields: map[string]any{
+					"value": value,
+				},
+			})
+		}
+	}
+
+	if value := strings.TrimSpace(config.GetEnv("RISK_VELOCITY_WINDOW", "")); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			e.velocityWindow = parsed
+		} else {
+			logger.Warn("Ignoring invalid RISK_VELOCITY_WINDOW", logger.LogContext{
+				Fields: map[string]any{
+					"value": value,
+				},
+			})
+		}
+	}
+
+	return e
+}
+
+// Enabled reports whether any rule is configured
+func (e *RuleRiskEvaluator) Enabled() bool {
+	return e.maxAmount > 0 || e.countryCheck || e.velocityLimit > 0
+}
+
+// Evaluate applies the configured rules in order: amount, country, velocity. Velocity runs
+// last so payments blocked by another rule do not count as attempts.
+func (e *RuleRiskEvaluator) Evaluate(_ context.Context, _ string, request PaymentRequest) *PaymentBlock {
+	if e.maxAmount > 0 && request.Amount > e.maxAmount {
+		return &PaymentBlock{
+			Reason:  BlockReasonAmountLimitExceeded,
+			Message: fmt.Sprintf("amount %.2f exceeds the limit of %.2f", request.Amount, e.maxAmount),
+		}
+	}
+
+	if e.countryCheck && request.Customer.Address != nil {
+		billing := strings.ToUpper(strings.TrimSpace(request.Customer.Address.Country))
+		client := strings.ToUpper(strings.TrimSpace(request.ClientCountry))
+		// Only ISO alpha-2 codes are compared; country names cannot be matched reliably
+		if len(billing) == 2 && len(client) == 2 && billing != client {
+			return &PaymentBlock{
+				Reason:  BlockReasonCountryMismatch,
+				Message: fmt.Sprintf("billing country %s does not match client country %s", billing, client),
+			}
+		}
+	}
+
+	if e.velocityLimit > 0 {
+		if key := velocityKey(request); key != "" && !e.allowAttempt(key) {
+			return &PaymentBlock{
+				Reason:  BlockReasonVelocityExceeded,
+				Message: fmt.Sprintf("more than %d payments within %s", e.velocityLimit, e.velocityWindow),
+			}
+		}
+	}
+
+	return nil
+}
+
+// allowAttempt records an attempt for key unless the window is already full
+func (e *RuleRiskEvaluator) allowAttempt(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	cutoff := now.Add(-e.velocityWindow)
+
+	// Drop keys that went quiet, once per window
+	if now.Sub(e.lastSweep) > e.velocityWindow {
+		for k, times := range e.attempts {
+			if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+				delete(e.attempts, k)
+			}
+		}
+		e.lastSweep = now
+	}
+
+	recent := e.attempts[key][:0]
+	for _, at := range e.attempts[key] {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) >= e.velocityLimit {
+		e.attempts[key] = recent
+		return false
+	}
+
+	e.attempts[key] = append(recent, now)
+	return true
+}
+
+// velocityKey identifies who is paying: the card when present (hashed, never stored in
+// clear), otherwise the customer email or client IP
+func velocityKey(request PaymentRequest) string {
+	var subject string
+	switch {
+	case request.CardInfo.CardNumber != "":
+		sum := sha256.Sum256([]byte(request.CardInfo.CardNumber))
+		subject = "card:" + hex.EncodeToString(sum[:])
+	case request.Customer.Email != "":
+		subject = "email:" + strings.ToLower(request.Customer.Email)
+	case request.ClientIP != "":
+		subject = "ip:" + request.ClientIP
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%d|%s", request.TenantID, subject)
+}
@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func newTestRiskEvaluator(t *testing.T, env map[string]string) *RuleRiskEvaluator {
+	for _, key := range []string{"RISK_MAX_AMOUNT", "RISK_COUNTRY_CHECK", "RISK_VELOCITY_LIMIT", "RISK_VELOCITY_WINDOW"} {
+		t.Setenv(key, env[key])
+	}
+	return NewRuleRiskEvaluator()
+}
+
+func riskRequest() PaymentRequest {
+	return PaymentRequest{
+		TenantID: 1,
+		Amount:   100,
+		Currency: "TRY",
+		Customer: Customer{
+			Email:   "john@example.com",
+			Address: &Address{Country: "TR"},
+		},
+		CardInfo:      CardInfo{CardNumber: "5528790000000008"},
+		ClientCountry: "TR",
+		ClientIP:      "203.0.113.10",
+	}
+}
+
+func TestRuleRiskEvaluator_DisabledByDefault(t *testing.T) {
+	e := newTestRiskEvaluator(t, nil)
+
+	if e.Enabled() {
+		t.Fatal("Expected no rules without configuration")
+	}
+	request := riskRequest()
+	request.Amount = 1_000_000
+	request.ClientCountry = "DE"
+	if block := e.Evaluate(context.Background(), "iyzico", request); block != nil {
+		t.Errorf("Expected payment to pass, got %+v", block)
+	}
+}
+
+func TestRuleRiskEvaluator_AmountLimitExceeded(t *testing.T) {
+	e := newTestRiskEvaluator(t, map[string]string{"RISK_MAX_AMOUNT": "500"})
+
+	request := riskRequest()
+	request.Amount = 500
+	if block := e.Evaluate(context.Background(), "iyzico", request); block != nil {
+		t.Fatalf("Expected amount at the limit to pass, got %+v", block)
+	}
+
+	request.Amount = 500.01
+	block := e.Evaluate(context.Background(), "iyzico", request)
+	if block == nil || block.Reason != BlockReasonAmountLimitExceeded {
+		t.Fatalf("Expected %s, got %+v", BlockReasonAmountLimitExceeded, block)
+	}
+	if block.Message == "" {
+		t.Error("Expected a block message")
+	}
+}
+
+func TestRuleRiskEvaluator_CountryMismatch(t *testing.T) {
+	e := newTestRiskEvaluator(t, map[string]string{"RISK_COUNTRY_CHECK": "true"})
+
+	tests := []struct {
+		name          string
+		billing       string
+		clientCountry string
+		blocked       bool
+	}{
+		{"same country", "TR", "TR", false},
+		{"case insensitive", "tr", "TR", false},
+		{"different country", "TR", "DE", true},
+		{"unknown client country", "TR", "", false},
+		{"country name is not compared", "Turkey", "DE", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := riskRequest()
+			request.Customer.Address.Country = tt.billing
+			request.ClientCountry = tt.clientCountry
+
+			block := e.Evaluate(context.Background(), "iyzico", request)
+			if tt.blocked {
+				if block == nil || block.Reason != BlockReasonCountryMismatch {
+					t.Errorf("Expected %s, got %+v", BlockReasonCountryMismatch, block)
+				}
+			} else if block != nil {
+				t.Errorf("Expected payment to pass, got %+v", block)
+			}
+		})
+	}
+}
+
+func TestRuleRiskEvaluator_VelocityExceeded(t *testing.T) {
+	e := newTestRiskEvaluator(t, map[string]string{"RISK_VELOCITY_LIMIT": "2", "RISK_VELOCITY_WINDOW": "10m"})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range 2 {
+		if block := e.Evaluate(ctx, "iyzico", riskRequest()); block != nil {
+			t.Fatalf("Payment %d should pass, got %+v", i+1, block)
+		}
+	}
+
+	block := e.Evaluate(ctx, "iyzico", riskRequest())
+	if block == nil || block.Reason != BlockReasonVelocityExceeded {
+		t.Fatalf("Expected %s, got %+v", BlockReasonVelocityExceeded, block)
+	}
+
+	// Another card and another tenant have their own counters
+	otherCard := riskRequest()
+	otherCard.CardInfo.CardNumber = "4111111111111111"
+	if block := e.Evaluate(ctx, "iyzico", otherCard); block != nil {
+		t.Errorf("Expected another card to pass, got %+v", block)
+	}
+	otherTenant := riskRequest()
+	otherTenant.TenantID = 2
+	if block := e.Evaluate(ctx, "iyzico", otherTenant); block != nil {
+		t.Errorf("Expected another tenant to pass, got %+v", block)
+	}
+
+	// Once the window slides past the first attempts the card may pay again
+	now = now.Add(11 * time.Minute)
+	if block := e.Evaluate(ctx, "iyzico", riskRequest()); block != nil {
+		t.Errorf("Expected payment after the window to pass, got %+v", block)
+	}
+}
+
+func TestRuleRiskEvaluator_BlockedPaymentsDoNotCountTowardsVelocity(t *testing.T) {
+	e := newTestRiskEvaluator(t, map[string]string{"RISK_MAX_AMOUNT": "500", "RISK_VELOCITY_LIMIT": "1"})
+	ctx := context.Background()
+
+	tooLarge := riskRequest()
+	tooLarge.Amount = 1000
+	if block := e.Evaluate(ctx, "iyzico", tooLarge); block == nil || block.Reason != BlockReasonAmountLimitExceeded {
+		t.Fatalf("Expected %s, got %+v", BlockReasonAmountLimitExceeded, block)
+	}
+
+	if block := e.Evaluate(ctx, "iyzico", riskRequest()); block != nil {
+		t.Errorf("Expected first allowed payment to pass, got %+v", block)
+	}
+}
+
+// riskTestProvider fails the test if a blocked payment reaches it
+type riskTestProvider struct {
+	PaymentProvider
+	calls int
+}
+
+func (p *riskTestProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *riskTestProvider) CreatePayment(context.Context, PaymentRequest) (*PaymentResponse, error) {
+	p.calls++
+	return &PaymentResponse{Success: true, Status: StatusSuccessful}, nil
+}
+
+type recordingPaymentLogger struct {
+	requests  int
+	responses []any
+}
+
+func (l *recordingPaymentLogger) LogRequest(context.Context, int, string, string, string, any, string, string) (int64, error) {
+	l.requests++
+	return int64(l.requests), nil
+}
+
+func (l *recordingPaymentLogger) LogResponse(_ context.Context, _ int64, response any, _ int64) error {
+	l.responses = append(l.responses, response)
+	return nil
+}
+
+func (l *recordingPaymentLogger) LogError(context.Context, int64, string, string, int64) error {
+	return nil
+}
+
+type staticRiskEvaluator struct{ block *PaymentBlock }
+
+func (e staticRiskEvaluator) Evaluate(context.Context, string, PaymentRequest) *PaymentBlock {
+	return e.block
+}
+
+func TestPaymentService_CreatePayment_BlockedBeforeProvider(t *testing.T) {
+	const tenantID, providerName = 9101, "risktest"
+
+	for _, reason := range []BlockReason{BlockReasonAmountLimitExceeded, BlockReasonVelocityExceeded, BlockReasonCountryMismatch} {
+		t.Run(string(reason), func(t *testing.T) {
+			fake := &riskTestProvider{}
+			GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+			t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+			paymentLogger := &recordingPaymentLogger{}
+			service := NewPaymentService(paymentLogger)
+			service.AddRiskEvaluator(staticRiskEvaluator{})
+			service.AddRiskEvaluator(staticRiskEvaluator{block: &PaymentBlock{Reason: reason, Message: "blocked by test"}})
+
+			ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9101")
+			resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+			if err != nil {
+				t.Fatalf("Expected blocked response without error, got %v", err)
+			}
+
+			if fake.calls != 0 {
+				t.Error("Blocked payment must not reach the provider")
+			}
+			if resp.Success || resp.Status != StatusBlocked || resp.Outcome != OutcomeFailed {
+				t.Errorf("Expected failed blocked response, got success=%v status=%s outcome=%s", resp.Success, resp.Status, resp.Outcome)
+			}
+			if resp.Block == nil || resp.Block.Reason != reason || resp.ErrorCode != string(reason) {
+				t.Errorf("Expected block reason %s, got %+v (errorCode %s)", reason, resp.Block, resp.ErrorCode)
+			}
+
+			if paymentLogger.requests != 1 || len(paymentLogger.responses) != 1 {
+				t.Fatalf("Expected blocked payment to be logged once, got %d requests / %d responses", paymentLogger.requests, len(paymentLogger.responses))
+			}
+			if logged, ok := paymentLogger.responses[0].(*PaymentResponse); !ok || logged.Status != StatusBlocked {
+				t.Errorf("Expected logged response with status blocked, got %+v", paymentLogger.responses[0])
+			}
+		})
+	}
+}
+
+func TestPaymentService_CreatePayment_AllowedPaymentReachesProvider(t *testing.T) {
+	const tenantID, providerName = 9102, "risktest"
+
+	fake := &riskTestProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	service.AddRiskEvaluator(staticRiskEvaluator{})
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9102")
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, riskRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if fake.calls != 1 || !resp.Success || resp.Block != nil {
+		t.Errorf("Expected payment to reach the provider, got calls=%d resp=%+v", fake.calls, resp)
+	}
+}
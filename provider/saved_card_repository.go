@@ -27,6 +27,10 @@ type SavedCard struct {
 	IsActive       bool       `json:"isActive"`
 	CreatedAt      time.Time  `json:"createdAt"`
 	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+	// LastFailureReason is card_expired or card_not_found after a charge that calls for an
+	// account update, until the card is charged successfully again
+	LastFailureReason string     `json:"lastFailureReason,omitempty"`
+	LastFailureAt     *time.Time `json:"lastFailureAt,omitempty"`
 }
 
 // SavedCardRepository persists saved cards. Every query is scoped by tenant_id so a tenant can
@@ -82,7 +86,8 @@ func (r *SavedCardRepository) GetByID(ctx context.Context, tenantID, id int) (*S
 	query := `
 		SELECT id, tenant_id, provider_id, environment, msisdn, provider_card_id,
 		       COALESCE(masked_card_no, ''), COALESCE(card_brand, ''), COALESCE(card_type, ''),
-		       COALESCE(alias, ''), is_active, created_at, updated_at
+		       COALESCE(alias, ''), is_active, created_at, updated_at,
+		       COALESCE(last_failure_reason, ''), last_failure_at
 		FROM saved_cards
 		WHERE id = $1 AND tenant_id = $2 AND is_active`
 
@@ -94,7 +99,8 @@ func (r *SavedCardRepository) ListByMsisdn(ctx context.Context, tenantID, provid
 	query := `
 		SELECT id, tenant_id, provider_id, environment, msisdn, provider_card_id,
 		       COALESCE(masked_card_no, ''), COALESCE(card_brand, ''), COALESCE(card_type, ''),
-		       COALESCE(alias, ''), is_active, created_at, updated_at
+		       COALESCE(alias, ''), is_active, created_at, updated_at,
+		       COALESCE(last_failure_reason, ''), last_failure_at
 		FROM saved_cards
 		WHERE tenant_id = $1 AND provider_id = $2 AND environment = $3 AND msisdn = $4 AND is_active
 		ORDER BY id DESC`
@@ -137,6 +143,20 @@ func (r *SavedCardRepository) SoftDeleteByID(ctx context.Context, tenantID, id i
 	return nil
 }
 
+// SetFailureReason stores why the last charge of a saved card failed, scoped to the tenant.
+// An empty reason clears it after a successful charge.
+func (r *SavedCardRepository) SetFailureReason(ctx context.Context, tenantID, id int, reason string) error {
+	query := `
+		UPDATE saved_cards
+		SET last_failure_reason = $3::varchar,
+		    last_failure_at = CASE WHEN $3::varchar IS NULL THEN NULL ELSE now() END
+		WHERE id = $1 AND tenant_id = $2 AND is_active`
+	if _, err := r.db.ExecContext(ctx, query, id, tenantID, nullString(reason)); err != nil {
+		return fmt.Errorf("failed to update saved card failure reason: %w", err)
+	}
+	return nil
+}
+
 // rowScanner is satisfied by both *sql.Row and *sql.Rows.
 type rowScanner interface {
 	Scan(dest ...any) error
@@ -144,10 +164,11 @@ type rowScanner interface {
 
 func (r *SavedCardRepository) scanOne(row rowScanner) (*SavedCard, error) {
 	var c SavedCard
-	var updatedAt sql.NullTime
+	var updatedAt, lastFailureAt sql.NullTime
 	err := row.Scan(
 		&c.ID, &c.TenantID, &c.ProviderID, &c.Environment, &c.MSISDN, &c.ProviderCardID,
 		&c.MaskedCardNo, &c.CardBrand, &c.CardType, &c.Alias, &c.IsActive, &c.CreatedAt, &updatedAt,
+		&c.LastFailureReason, &lastFailureAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -158,6 +179,9 @@ func (r *SavedCardRepository) scanOne(row rowScanner) (*SavedCard, error) {
 	if updatedAt.Valid {
 		c.UpdatedAt = &updatedAt.Time
 	}
+	if lastFailureAt.Valid {
+		c.LastFailureAt = &lastFailureAt.Time
+	}
 	return &c, nil
 }
 
@@ -4,16 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
 	"github.com/mstgnz/gopay/infra/logger"
-	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/tenantctx"
 )
 
 // getTenantIDFromContext extracts and validates tenant ID from context
 func getTenantIDFromContext(ctx context.Context) (int, error) {
-	tenantIDStr, ok := ctx.Value(middle.TenantIDKey).(string)
+	tenantIDStr, ok := ctx.Value(tenantctx.IDKey).(string)
 	if !ok || tenantIDStr == "" {
 		return 0, fmt.Errorf("tenant ID not found in context")
 	}
@@ -28,17 +29,67 @@ func getTenantIDFromContext(ctx context.Context) (int, error) {
 
 // PaymentService manages payment operations through various providers
 type PaymentService struct {
-	logger PaymentLogger
+	logger               PaymentLogger
+	autoCapture          *AutoCaptureScheduler
+	riskEvaluators       []RiskEvaluator
+	sandboxWarningAmount float64
+	refundIdempotency    RefundIdempotencyStore
+	paymentIdempotency   PaymentIdempotencyStore
+	metadataLimits       MetadataLimits
+	callLogs             PaymentCallLogStore
+	circuitBreaker       *CircuitBreaker
+	balancer             *ProviderBalancer
+	paymentReferences    PaymentReferenceStore
+	paymentEvents        PaymentEventStore
+	eventBroker          *paymentEventBroker
 }
 
 // NewPaymentService creates a new payment service
 func NewPaymentService(logger PaymentLogger) *PaymentService {
+	circuitBreaker := NewCircuitBreaker()
 	return &PaymentService{
-		logger: logger,
+		logger:               logger,
+		sandboxWarningAmount: sandboxWarningAmount(),
+		metadataLimits:       MetadataLimitsFromEnv(),
+		circuitBreaker:       circuitBreaker,
+		balancer:             NewProviderBalancer(circuitBreaker),
+		eventBroker:          newPaymentEventBroker(),
 	}
 }
 
-// CreatePayment processes a payment using the specified provider
+// SetAutoCaptureScheduler enables AutoCaptureAfter on payment requests
+func (s *PaymentService) SetAutoCaptureScheduler(scheduler *AutoCaptureScheduler) {
+	s.autoCapture = scheduler
+}
+
+// SetRefundIdempotencyStore enables IdempotencyKey on refund requests
+func (s *PaymentService) SetRefundIdempotencyStore(store RefundIdempotencyStore) {
+	s.refundIdempotency = store
+}
+
+// SetPaymentIdempotencyStore enables IdempotencyKey on payment requests
+func (s *PaymentService) SetPaymentIdempotencyStore(store PaymentIdempotencyStore) {
+	s.paymentIdempotency = store
+}
+
+// SetPaymentReferenceStore lets async providers' payments be reached by their GoPay payment ID
+func (s *PaymentService) SetPaymentReferenceStore(store PaymentReferenceStore) {
+	s.paymentReferences = store
+}
+
+// SetPaymentCallLogStore enables IncludeProviderCalls on payment status requests
+func (s *PaymentService) SetPaymentCallLogStore(store PaymentCallLogStore) {
+	s.callLogs = store
+}
+
+// AddRiskEvaluator adds a check that runs before every payment reaches its provider.
+// Evaluators run in the order they were added; the first block wins.
+func (s *PaymentService) AddRiskEvaluator(evaluator RiskEvaluator) {
+	s.riskEvaluators = append(s.riskEvaluators, evaluator)
+}
+
+// CreatePayment processes a payment using the specified provider. With BalancedProviderName
+// the provider is picked from the tenant's provider weights and returned in the response.
 func (s *PaymentService) CreatePayment(ctx context.Context, environment, providerName string, request PaymentRequest) (*PaymentResponse, error) {
 
 	// check amount is greater than 1000 for installment payments
@@ -46,6 +97,36 @@ func (s *PaymentService) CreatePayment(ctx context.Context, environment, provide
 		return nil, errors.New("amount must be greater than 1000 for installment payments")
 	}
 
+	// Metadata is stored with the request log, so it is bounded before anything is written
+	if err := s.metadataLimits.Validate(request.Metadata); err != nil {
+		return nil, err
+	}
+
+	// Formatted card numbers reach every provider without separators, and mistyped ones not at all
+	if request.CardInfo.CardNumber != "" {
+		pan, err := SanitizePAN(request.CardInfo.CardNumber)
+		if err != nil {
+			return nil, err
+		}
+		request.CardInfo.CardNumber = pan
+	}
+
+	// Auto-capture authorizes now and captures later, which only the non-3D flow supports
+	var autoCaptureDelay time.Duration
+	if request.AutoCaptureAfter != "" {
+		if s.autoCapture == nil {
+			return nil, errors.New("auto-capture is not enabled")
+		}
+		if request.Use3D {
+			return nil, fmt.Errorf("%w: not supported for 3D payments", ErrAutoCaptureDelayInvalid)
+		}
+		delay, err := s.autoCapture.ParseDelay(request.AutoCaptureAfter)
+		if err != nil {
+			return nil, err
+		}
+		autoCaptureDelay = delay
+	}
+
 	tenantID, err := getTenantIDFromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -53,18 +134,127 @@ func (s *PaymentService) CreatePayment(ctx context.Context, environment, provide
 
 	request.TenantID = tenantID
 	request.Environment = environment
+
+	// A retry is recognized by the provider it was requested for, before balancing picks one
+	fingerprint := paymentFingerprint(providerName, environment, request)
+
+	balanced := providerName == BalancedProviderName
+	if balanced {
+		if s.balancer == nil {
+			return nil, ErrProviderWeightsNotConfigured
+		}
+		if providerName, err = s.balancer.Select(tenantID, environment); err != nil {
+			return nil, err
+		}
+	}
+
 	provider, err := GetProvider(tenantID, providerName, environment)
 	if err != nil {
 		return nil, err
 	}
 
+	if request.Currency != "" && !IsCurrencySupported(provider, request.Currency) {
+		return nil, fmt.Errorf("%w: %s does not accept %s", ErrUnsupportedCurrency, providerName, request.Currency)
+	}
+
+	bnpl := request.PaymentMethod == PaymentMethodTypeBNPL
+	if bnpl {
+		if err := checkBNPLPayment(provider, request); err != nil {
+			return nil, err
+		}
+	}
+
+	pix := request.PaymentMethod == PaymentMethodTypePIX
+	if pix {
+		if err := checkPIXPayment(provider, request); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.WalletPayment != nil {
+		if err := checkWalletPayment(provider, request); err != nil {
+			return nil, err
+		}
+	}
+
+	// Async providers only have a final payment ID with the webhook, so GoPay issues one now
+	_, async := provider.(AsyncPaymentProvider)
+	if async && request.ID == "" {
+		request.ID = NewPaymentID()
+	}
+
+	// The basket is checked against the amount of the goods, before any surcharge
+	if err := checkBasketTotal(provider, providerName, request); err != nil {
+		return nil, err
+	}
+
+	binInfo, err := s.checkInstallmentFunding(ctx, provider, tenantID, providerName, environment, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var surcharge *SurchargeBreakdown
+	if request.Surcharge != 0 {
+		if err := SurchargeRulesFromEnv(providerName).Validate(request.Amount, request.Surcharge); err != nil {
+			return nil, err
+		}
+		surcharge = NewSurchargeBreakdown(request.Amount, request.Surcharge, request.Currency)
+	}
+
+	// Authorizing holds the funds until a capture, manual or scheduled with AutoCaptureAfter
+	// BNPL payments are authorized on the provider's page instead, when their order is placed
+	var capturer CaptureProvider
+	if !bnpl && (request.PaymentType == PaymentTypeAuth || autoCaptureDelay > 0) {
+		if request.Use3D {
+			return nil, fmt.Errorf("%w: authorization is not supported for 3D payments", ErrCaptureUnsupported)
+		}
+		var ok bool
+		if capturer, ok = provider.(CaptureProvider); !ok {
+			return nil, ErrCaptureUnsupported
+		}
+	}
+
 	// Determine method and endpoint
 	method := "POST"
 	endpoint := "/payment"
-	if request.Use3D {
+	if request.Use3D || bnpl {
+		// BNPL payments are completed through the callback like 3D payments
 		endpoint = "/payment/3d"
 	}
 
+	// The provider charges the surcharged total and gets the tenant's templated description,
+	// while the request log keeps the request as it was sent
+	charged := request
+	if surcharge != nil {
+		charged.Amount = surcharge.Total
+	}
+	charged.Description = RenderDescription(descriptionTemplateFor(tenantID, providerName, environment), request)
+
+	if block := s.evaluateRisk(ctx, providerName, charged); block != nil {
+		return s.blockPayment(ctx, providerName, method, endpoint, request, block), nil
+	}
+
+	var idempotencyKey PaymentIdempotencyKey
+	if request.IdempotencyKey != "" && s.paymentIdempotency != nil {
+		idempotencyKey = PaymentIdempotencyKey{TenantID: tenantID, Key: request.IdempotencyKey}
+		claimed, prior, priorFingerprint, err := s.paymentIdempotency.Claim(ctx, idempotencyKey, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			switch {
+			case priorFingerprint != fingerprint:
+				return nil, ErrPaymentIdempotencyKeyReused
+			case prior == nil:
+				return nil, ErrPaymentInProgress
+			default:
+				replayed := *prior
+				replayed.Replayed = true
+				return &replayed, nil
+			}
+		}
+	}
+
 	// Log request to database
 	startTime := time.Now()
 	logID, err := s.logger.LogRequest(ctx, tenantID, providerName, method, endpoint, request, request.ClientUserAgent, request.ClientIP)
@@ -79,18 +269,68 @@ func (s *PaymentService) CreatePayment(ctx context.Context, environment, provide
 	}
 	// required to add provider request to client request
 	request.LogID = logID
+	charged.LogID = logID
 
 	// Process payment
 	var response *PaymentResponse
-	if request.Use3D {
-		response, err = provider.Create3DPayment(ctx, request)
+	switch {
+	case bnpl:
+		response, err = provider.(BNPLProvider).CreateBNPLPayment(ctx, charged)
+	case pix:
+		response, err = provider.(PIXProvider).CreatePIXPayment(ctx, charged)
+	case capturer != nil:
+		response, err = capturer.AuthorizePayment(ctx, charged)
+	case request.Use3D:
+		response, err = provider.Create3DPayment(ctx, charged)
+	default:
+		response, err = provider.CreatePayment(ctx, charged)
+	}
+
+	if err != nil {
+		s.circuitBreaker.RecordFailure(tenantID, providerName, environment)
 	} else {
-		response, err = provider.CreatePayment(ctx, request)
+		s.circuitBreaker.RecordSuccess(tenantID, providerName, environment)
+	}
+	if errors.Is(err, ErrResponseUnparseable) {
+		response, err = unknownStateResponse(providerName, request.ID, charged.Amount, request.Currency, err), nil
+	}
+
+	if autoCaptureDelay > 0 && err == nil && response != nil && response.Success && response.PaymentID != "" {
+		s.scheduleAutoCapture(ctx, tenantID, providerName, environment, response, autoCaptureDelay)
 	}
 
-	// Preserve session ID in response
+	// Preserve session ID in response and tell the client what to do next
 	if response != nil {
 		response.SessionID = request.SessionID
+		response.SubscriptionID = request.SubscriptionID
+		response.PaymentLinkID = request.PaymentLinkID
+		response.Outcome = ResolveOutcome(response)
+		response.ChallengeForm = challengeFormOf(response)
+		response.NextAction = ResolveNextAction(response)
+		response.Warnings = environmentWarnings(environment, request, s.sandboxWarningAmount)
+		applyLiveMode(environment, response)
+		response.PaymentMethodDetails = s.paymentMethodDetails(ctx, provider, providerName, request.CardInfo, response.PaymentMethodDetails, binInfo)
+		if balanced {
+			response.Provider = providerName
+		}
+		response.Surcharge = surcharge
+		if async {
+			s.linkAsyncPayment(ctx, tenantID, providerName, request.ID, response)
+		}
+	}
+
+	if idempotencyKey.Key != "" {
+		s.finishPaymentIdempotency(ctx, providerName, idempotencyKey, response, err)
+	}
+
+	// Payments are followed by the ID they were answered with, GoPay's own for async providers
+	eventPaymentID := request.ID
+	if response != nil && response.PaymentID != "" {
+		eventPaymentID = response.PaymentID
+	}
+	s.recordPaymentEvent(ctx, paymentEventOf(PaymentEventCreated, tenantID, providerName, environment, eventPaymentID, response), err)
+	if err == nil && response != nil && request.Use3D && (response.RedirectURL != "" || response.HTML != "") {
+		s.recordPaymentEvent(ctx, paymentEventOf(PaymentEvent3DInitiated, tenantID, providerName, environment, eventPaymentID, response), nil)
 	}
 
 	// Calculate processing time
@@ -126,6 +366,94 @@ func (s *PaymentService) CreatePayment(ctx context.Context, environment, provide
 	return response, err
 }
 
+// paymentMethodDetails completes the details the provider reported for the payment with its
+// BIN lookup of the card, or with binInfo when the card was looked up before the payment. A
+// failed lookup leaves the details as they are.
+func (s *PaymentService) paymentMethodDetails(ctx context.Context, provider PaymentProvider, providerName string, card CardInfo, reported *PaymentMethodDetails, binInfo *CardBinInfo) *PaymentMethodDetails {
+	lookup, ok := provider.(CardBinInfoProvider)
+	bin := cardBIN(card.CardNumber)
+	if !ok || bin == "" || reported.complete() {
+		return reported
+	}
+	if binInfo != nil {
+		return reported.withFallback(PaymentMethodDetailsFromBinInfo(binInfo))
+	}
+
+	info, err := lookup.GetCardBinInfo(ctx, bin)
+	if err != nil {
+		logger.Warn("Failed to look up card BIN", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return reported
+	}
+	return reported.withFallback(PaymentMethodDetailsFromBinInfo(info))
+}
+
+// evaluateRisk returns the first block raised by the configured risk evaluators
+func (s *PaymentService) evaluateRisk(ctx context.Context, providerName string, request PaymentRequest) *PaymentBlock {
+	for _, evaluator := range s.riskEvaluators {
+		if block := evaluator.Evaluate(ctx, providerName, request); block != nil {
+			return block
+		}
+	}
+	return nil
+}
+
+// blockPayment records a payment stopped by a risk evaluator. It is logged like any other
+// payment but with status "blocked", so analytics can keep it apart from provider failures.
+func (s *PaymentService) blockPayment(ctx context.Context, providerName, method, endpoint string, request PaymentRequest, block *PaymentBlock) *PaymentResponse {
+	now := time.Now()
+	response := &PaymentResponse{
+		Success:    false,
+		Status:     StatusBlocked,
+		Message:    block.Message,
+		ErrorCode:  string(block.Reason),
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		SystemTime: &now,
+		SessionID:  request.SessionID,
+		Block:      block,
+	}
+	response.Outcome = ResolveOutcome(response)
+	response.NextAction = ResolveNextAction(response)
+	applyLiveMode(request.Environment, response)
+
+	logger.Warn("Payment blocked before reaching provider", logger.LogContext{
+		TenantID: strconv.Itoa(request.TenantID),
+		Provider: providerName,
+		Fields: map[string]any{
+			"reason":  block.Reason,
+			"message": block.Message,
+		},
+	})
+
+	logID, err := s.logger.LogRequest(ctx, request.TenantID, providerName, method, endpoint, request, request.ClientUserAgent, request.ClientIP)
+	if err != nil {
+		logger.Warn("Failed to log blocked payment request", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return response
+	}
+
+	if err := s.logger.LogResponse(ctx, logID, response, 0); err != nil {
+		logger.Warn("Failed to log blocked payment response", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"log_id": logID,
+				"error":  err.Error(),
+			},
+		})
+	}
+
+	return response
+}
+
 // Complete3DPayment completes a 3D secure payment after user authentication
 func (s *PaymentService) Complete3DPayment(ctx context.Context, providerName, state string, data map[string]string) (*PaymentResponse, error) {
 	callbackState, err := HandleCallbackState(ctx, state)
@@ -157,12 +485,19 @@ func (s *PaymentService) Complete3DPayment(ctx context.Context, providerName, st
 	callbackState.LogID = logID
 
 	response, err := provider.Complete3DPayment(ctx, callbackState, data)
+	if errors.Is(err, ErrResponseUnparseable) {
+		response, err = unknownStateResponse(providerName, callbackState.PaymentID, callbackState.Amount, callbackState.Currency, err), nil
+		response.RedirectURL = callbackState.OriginalCallback
+	}
 
 	// Restore session ID from callback state
 	if response != nil {
 		response.SessionID = callbackState.SessionID
+		applyLiveMode(callbackState.Environment, response)
 	}
 
+	s.recordPaymentEvent(ctx, paymentEventOf(PaymentEvent3DCompleted, callbackState.TenantID, providerName, callbackState.Environment, callbackState.PaymentID, response), err)
+
 	processingMs := time.Since(startTime).Milliseconds()
 
 	if logID > 0 {
@@ -218,7 +553,15 @@ func (s *PaymentService) GetPaymentStatus(ctx context.Context, environment, prov
 	}
 
 	request.LogID = logID
-	response, err := provider.GetPaymentStatus(ctx, request)
+	providerRequest := request
+	providerRequest.PaymentID = s.providerPaymentID(ctx, tenantID, providerName, provider, request.PaymentID)
+	response, err := provider.GetPaymentStatus(ctx, providerRequest)
+	if response != nil {
+		if providerRequest.PaymentID != request.PaymentID {
+			response.PaymentID = request.PaymentID
+		}
+		applyLiveMode(environment, response)
+	}
 
 	processingMs := time.Since(startTime).Milliseconds()
 
@@ -248,9 +591,32 @@ func (s *PaymentService) GetPaymentStatus(ctx context.Context, environment, prov
 		}
 	}
 
+	if err == nil && response != nil && request.IncludeProviderCalls && s.callLogs != nil {
+		response.ProviderCalls = s.providerCallStats(ctx, tenantID, providerName, request.PaymentID)
+	}
+
 	return response, err
 }
 
+// providerCallStats aggregates the payment's logged provider calls. A failed lookup leaves
+// the stats out rather than failing the status request.
+func (s *PaymentService) providerCallStats(ctx context.Context, tenantID int, providerName, paymentID string) *ProviderCallStats {
+	logs, err := s.callLogs.ListPaymentCallLogs(ctx, tenantID, providerName, paymentID)
+	if err != nil {
+		logger.Warn("Failed to load provider call logs", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"payment_id": paymentID,
+				"error":      err.Error(),
+			},
+		})
+		return nil
+	}
+
+	stats := aggregateProviderCalls(logs)
+	return &stats
+}
+
 // CancelPayment cancels a payment
 func (s *PaymentService) CancelPayment(ctx context.Context, environment, providerName string, request CancelRequest) (*PaymentResponse, error) {
 	tenantID, err := getTenantIDFromContext(ctx)
@@ -276,7 +642,30 @@ func (s *PaymentService) CancelPayment(ctx context.Context, environment, provide
 	}
 
 	request.LogID = logID
-	response, err := provider.CancelPayment(ctx, request)
+
+	// Stop a scheduled auto-capture before voiding so it cannot fire in between
+	if s.autoCapture != nil {
+		if _, cancelErr := s.autoCapture.Cancel(ctx, tenantID, providerName, request.PaymentID); cancelErr != nil {
+			logger.Warn("Failed to cancel scheduled auto-capture", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"payment_id": request.PaymentID,
+					"error":      cancelErr.Error(),
+				},
+			})
+		}
+	}
+
+	providerRequest := request
+	providerRequest.PaymentID = s.providerPaymentID(ctx, tenantID, providerName, provider, request.PaymentID)
+	response, err := provider.CancelPayment(ctx, providerRequest)
+	if response != nil {
+		if providerRequest.PaymentID != request.PaymentID {
+			response.PaymentID = request.PaymentID
+		}
+		applyLiveMode(environment, response)
+	}
+	s.recordPaymentEvent(ctx, paymentEventOf(PaymentEventCancelled, tenantID, providerName, environment, request.PaymentID, response), err)
 
 	processingMs := time.Since(startTime).Milliseconds()
 
@@ -309,6 +698,96 @@ func (s *PaymentService) CancelPayment(ctx context.Context, environment, provide
 	return response, err
 }
 
+// CapturePayment captures a payment authorized with paymentType "auth". Once the provider
+// confirms the capture, a pending auto-capture of the payment is cancelled so it is not
+// captured twice.
+func (s *PaymentService) CapturePayment(ctx context.Context, environment, providerName string, request CaptureRequest) (*PaymentResponse, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := GetProvider(tenantID, providerName, environment)
+	if err != nil {
+		return nil, err
+	}
+	capturer, ok := provider.(CaptureProvider)
+	if !ok {
+		return nil, ErrCaptureUnsupported
+	}
+
+	amount, err := s.resolveCaptureAmount(ctx, tenantID, providerName, request)
+	if err != nil {
+		return nil, err
+	}
+	request.Amount, request.Currency = amount.amount, amount.currency
+
+	startTime := time.Now()
+	logID, err := s.logger.LogRequest(ctx, tenantID, providerName, "POST", "/payment/capture", request, "", "")
+	if err != nil {
+		logger.Warn("Failed to log capture request", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"payment_id": request.PaymentID,
+				"error":      err.Error(),
+			},
+		})
+	}
+
+	request.LogID = logID
+
+	providerRequest := request
+	providerRequest.PaymentID = s.providerPaymentID(ctx, tenantID, providerName, provider, request.PaymentID)
+	response, err := capturer.CapturePayment(ctx, providerRequest)
+	if errors.Is(err, ErrResponseUnparseable) {
+		response, err = unknownStateResponse(providerName, request.PaymentID, request.Amount, request.Currency, err), nil
+	}
+	if response != nil {
+		if providerRequest.PaymentID != request.PaymentID {
+			response.PaymentID = request.PaymentID
+		}
+		applyLiveMode(environment, response)
+		if err == nil {
+			amount.applyTo(response)
+		}
+	}
+	// A failed or unconfirmed capture leaves the payment authorized, so its auto-capture stays scheduled
+	if s.autoCapture != nil && err == nil && response != nil && response.Success {
+		if _, cancelErr := s.autoCapture.Cancel(ctx, tenantID, providerName, request.PaymentID); cancelErr != nil {
+			logger.Warn("Failed to cancel scheduled auto-capture", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"payment_id": request.PaymentID,
+					"error":      cancelErr.Error(),
+				},
+			})
+		}
+	}
+	s.recordPaymentEvent(ctx, paymentEventOf(PaymentEventCaptured, tenantID, providerName, environment, request.PaymentID, response), err)
+
+	processingMs := time.Since(startTime).Milliseconds()
+
+	if logID > 0 {
+		var logErr error
+		if err != nil {
+			logErr = s.logger.LogError(ctx, logID, "CAPTURE_ERROR", err.Error(), processingMs)
+		} else {
+			logErr = s.logger.LogResponse(ctx, logID, response, processingMs)
+		}
+		if logErr != nil {
+			logger.Warn("Failed to log capture response", logger.LogContext{
+				Provider: providerName,
+				Fields: map[string]any{
+					"log_id":     logID,
+					"payment_id": request.PaymentID,
+					"error":      logErr.Error(),
+				},
+			})
+		}
+	}
+
+	return response, err
+}
+
 // RefundPayment issues a refund for a payment
 func (s *PaymentService) RefundPayment(ctx context.Context, environment, providerName string, request RefundRequest) (*RefundResponse, error) {
 	tenantID, err := getTenantIDFromContext(ctx)
@@ -320,6 +799,49 @@ func (s *PaymentService) RefundPayment(ctx context.Context, environment, provide
 		return nil, err
 	}
 
+	// Rounded before the idempotency claim, so a retry with the unrounded amount matches
+	var rounded refundAmount
+	if request.RefundAmount > 0 {
+		if rounded, err = s.roundRefundAmount(ctx, tenantID, providerName, request); err != nil {
+			return nil, err
+		}
+		request.RefundAmount = rounded.amount
+	}
+
+	var idempotencyKey RefundIdempotencyKey
+	if request.IdempotencyKey != "" && s.refundIdempotency != nil {
+		idempotencyKey = RefundIdempotencyKey{TenantID: tenantID, PaymentID: request.PaymentID, Key: request.IdempotencyKey}
+		claimed, prior, priorAmount, err := s.refundIdempotency.Claim(ctx, idempotencyKey, request.RefundAmount)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			switch {
+			case math.Round(priorAmount*100) != math.Round(request.RefundAmount*100):
+				return nil, ErrIdempotencyKeyReused
+			case prior == nil:
+				return nil, ErrRefundInProgress
+			default:
+				replayed := *prior
+				replayed.Replayed = true
+				return &replayed, nil
+			}
+		}
+	}
+
+	// Checked after the claim: a retried refund has already been subtracted, or may have been
+	// made just before the window closed, and returns its first result above
+	err = rounded.checkRefundable()
+	if err == nil {
+		err = s.checkRefundWindow(ctx, tenantID, providerName, request.PaymentID)
+	}
+	if err != nil {
+		if idempotencyKey.Key != "" {
+			s.finishRefundIdempotency(ctx, providerName, idempotencyKey, nil, err)
+		}
+		return nil, err
+	}
+
 	startTime := time.Now()
 	logID, err := s.logger.LogRequest(ctx, tenantID, providerName, "POST", "/payment/refund", request, "", "")
 	if err != nil {
@@ -333,7 +855,17 @@ func (s *PaymentService) RefundPayment(ctx context.Context, environment, provide
 	}
 
 	request.LogID = logID
-	response, err := provider.RefundPayment(ctx, request)
+	providerRequest := request
+	providerRequest.PaymentID = s.providerPaymentID(ctx, tenantID, providerName, provider, request.PaymentID)
+	response, err := provider.RefundPayment(ctx, providerRequest)
+	if response != nil && providerRequest.PaymentID != request.PaymentID {
+		response.PaymentID = request.PaymentID
+	}
+	s.recordPaymentEvent(ctx, refundEventOf(tenantID, providerName, environment, request, response), err)
+
+	if idempotencyKey.Key != "" {
+		s.finishRefundIdempotency(ctx, providerName, idempotencyKey, response, err)
+	}
 
 	processingMs := time.Since(startTime).Milliseconds()
 
@@ -366,6 +898,65 @@ func (s *PaymentService) RefundPayment(ctx context.Context, environment, provide
 	return response, err
 }
 
+// finishRefundIdempotency stores the refund result under its idempotency key. Refunds that
+// failed without a response release the key so the client can retry.
+func (s *PaymentService) finishRefundIdempotency(ctx context.Context, providerName string, key RefundIdempotencyKey, response *RefundResponse, refundErr error) {
+	var err error
+	if refundErr != nil || response == nil {
+		err = s.refundIdempotency.Release(ctx, key)
+	} else {
+		err = s.refundIdempotency.Complete(ctx, key, response)
+	}
+
+	if err != nil {
+		logger.Warn("Failed to update refund idempotency key", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"payment_id": key.PaymentID,
+				"error":      err.Error(),
+			},
+		})
+	}
+}
+
+// finishPaymentIdempotency stores the response of a claimed payment. The claim is only
+// released when the provider rejected the payment before calling its API, so the corrected
+// request can be retried with the same key. Any other failure may have charged the customer:
+// the claim is kept without a response and retries get ErrPaymentInProgress until it expires.
+func (s *PaymentService) finishPaymentIdempotency(ctx context.Context, providerName string, key PaymentIdempotencyKey, response *PaymentResponse, paymentErr error) {
+	var err error
+	switch {
+	case paymentErr != nil && paymentNotAttempted(paymentErr):
+		err = s.paymentIdempotency.Release(ctx, key)
+	case paymentErr == nil && response != nil:
+		err = s.paymentIdempotency.Complete(ctx, key, response)
+	default:
+		logger.Warn("Payment outcome is unknown, keeping its idempotency key in progress", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"idempotency_key": key.Key,
+			},
+		})
+	}
+
+	if err != nil {
+		logger.Warn("Failed to update payment idempotency key", logger.LogContext{
+			Provider: providerName,
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+	}
+}
+
+// paymentNotAttempted reports whether a provider failed a payment before calling its API:
+// its own request validation, or a wallet token it could not use
+func paymentNotAttempted(err error) bool {
+	return errors.Is(err, ErrInvalidPaymentRequest) ||
+		errors.Is(err, ErrWalletPaymentInvalid) ||
+		errors.Is(err, ErrWalletPaymentUnsupported)
+}
+
 func (s *PaymentService) GetInstallmentCount(ctx context.Context, environment, providerName string, request InstallmentInquireRequest) (InstallmentInquireResponse, error) {
 	tenantID, err := getTenantIDFromContext(ctx)
 	if err != nil {
@@ -419,6 +1010,43 @@ func (s *PaymentService) GetInstallmentCount(ctx context.Context, environment, p
 	return response, err
 }
 
+// Check3DSEnrollment reports whether a card BIN is enrolled in 3D Secure, so the merchant can
+// decide on the payment flow before starting it. Providers without the capability report
+// ThreeDSEnrollmentUnknown.
+func (s *PaymentService) Check3DSEnrollment(ctx context.Context, environment, providerName, bin string) (*ThreeDSEnrollmentResponse, error) {
+	if !binPattern.MatchString(bin) {
+		return nil, ErrInvalidBIN
+	}
+
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := GetProvider(tenantID, providerName, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ThreeDSEnrollmentResponse{
+		BIN:      bin,
+		Provider: providerName,
+		Status:   ThreeDSEnrollmentUnknown,
+	}
+
+	checker, ok := provider.(ThreeDSEnrollmentProvider)
+	if !ok {
+		return response, nil
+	}
+	response.Supported = true
+
+	status, err := checker.Check3DSEnrollment(ctx, bin)
+	if err != nil {
+		return nil, err
+	}
+	response.Status = status
+	return response, nil
+}
+
 func (s *PaymentService) GetCommission(ctx context.Context, environment, providerName string, request CommissionRequest) (CommissionResponse, error) {
 	tenantID, err := getTenantIDFromContext(ctx)
 	if err != nil {
@@ -437,6 +1065,10 @@ func (s *PaymentService) GetCommission(ctx context.Context, environment, provide
 		return CommissionResponse{}, errors.New("installment count is required")
 	}
 
+	if request.MaxInstallmentCount != 0 && (request.MaxInstallmentCount < request.InstallmentCount || request.MaxInstallmentCount > MaxCommissionInstallmentCount) {
+		return CommissionResponse{}, fmt.Errorf("max installment count must be between installment count and %d", MaxCommissionInstallmentCount)
+	}
+
 	if request.Amount == 0 {
 		return CommissionResponse{}, errors.New("amount is required")
 	}
@@ -511,6 +1143,9 @@ func (s *PaymentService) ValidateWebhook(ctx context.Context, environment, provi
 	}
 
 	valid, result, err := provider.ValidateWebhook(ctx, data, headers)
+	if async, ok := provider.(AsyncPaymentProvider); ok && valid && err == nil && result != nil {
+		s.reconcileWebhook(ctx, tenantID, providerName, async, result)
+	}
 
 	processingMs := time.Since(startTime).Milliseconds()
 
@@ -545,3 +1180,91 @@ func (s *PaymentService) ValidateWebhook(ctx context.Context, environment, provi
 
 	return valid, result, err
 }
+
+// scheduleAutoCapture schedules the capture of an authorized payment. A scheduling failure
+// leaves the payment authorized; AutoCaptureAt stays empty so the client can tell.
+func (s *PaymentService) scheduleAutoCapture(ctx context.Context, tenantID int, providerName, environment string, response *PaymentResponse, delay time.Duration) {
+	captureAt, err := s.autoCapture.Schedule(ctx, AutoCaptureJob{
+		TenantID:    tenantID,
+		Provider:    providerName,
+		Environment: environment,
+		PaymentID:   response.PaymentID,
+		Amount:      response.Amount,
+		Currency:    response.Currency,
+	}, delay)
+	if err != nil {
+		logger.Warn("Failed to schedule auto-capture", logger.LogContext{
+			TenantID: strconv.Itoa(tenantID),
+			Provider: providerName,
+			Fields: map[string]any{
+				"payment_id": response.PaymentID,
+				"error":      err.Error(),
+			},
+		})
+		return
+	}
+	response.AutoCaptureAt = &captureAt
+}
+
+// CaptureScheduledPayment captures a payment for the auto-capture scheduler. It runs outside
+// of a request, so the tenant comes from the job instead of the JWT context.
+func (s *PaymentService) CaptureScheduledPayment(ctx context.Context, job AutoCaptureJob) error {
+	provider, err := GetProvider(job.TenantID, job.Provider, job.Environment)
+	if err != nil {
+		return err
+	}
+	capturer, ok := provider.(CaptureProvider)
+	if !ok {
+		return ErrCaptureUnsupported
+	}
+
+	request := CaptureRequest{
+		PaymentID: job.PaymentID,
+		Amount:    job.Amount,
+		Currency:  job.Currency,
+	}
+
+	startTime := time.Now()
+	logID, err := s.logger.LogRequest(ctx, job.TenantID, job.Provider, "POST", "/payment/capture", request, "", "")
+	if err != nil {
+		logger.Warn("Failed to log capture request", logger.LogContext{
+			Provider: job.Provider,
+			Fields: map[string]any{
+				"payment_id": job.PaymentID,
+				"error":      err.Error(),
+			},
+		})
+	}
+	request.LogID = logID
+
+	response, err := capturer.CapturePayment(ctx, request)
+	if err == nil && (response == nil || !response.Success) {
+		err = fmt.Errorf("capture was not successful for payment %s", job.PaymentID)
+		if response != nil && response.Message != "" {
+			err = fmt.Errorf("capture was not successful for payment %s: %s", job.PaymentID, response.Message)
+		}
+	}
+	s.recordPaymentEvent(ctx, paymentEventOf(PaymentEventCaptured, job.TenantID, job.Provider, job.Environment, job.PaymentID, response), err)
+
+	processingMs := time.Since(startTime).Milliseconds()
+	if logID > 0 {
+		var logErr error
+		if err != nil {
+			logErr = s.logger.LogError(ctx, logID, "CAPTURE_ERROR", err.Error(), processingMs)
+		} else {
+			logErr = s.logger.LogResponse(ctx, logID, response, processingMs)
+		}
+		if logErr != nil {
+			logger.Warn("Failed to log capture response", logger.LogContext{
+				Provider: job.Provider,
+				Fields: map[string]any{
+					"log_id":     logID,
+					"payment_id": job.PaymentID,
+					"error":      logErr.Error(),
+				},
+			})
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,8 @@
+package sipay
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Sipay provider with the global registry
+	provider.Register("sipay", NewProvider)
+}
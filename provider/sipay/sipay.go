@@ -0,0 +1,938 @@
+package sipay
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/provider"
+)
+
+const (
+	// API URLs
+	apiSandboxURL    = "https://provisioning.sipay.com.tr/ccpayment"
+	apiProductionURL = "https://app.sipay.com.tr/ccpayment"
+
+	// API Endpoints
+	endpointToken       = "/api/token"
+	endpointPay2D       = "/api/paySmart2D"
+	endpointPay3D       = "/api/paySmart3D"
+	endpointRefund      = "/api/refund"
+	endpointCheckStatus = "/api/checkstatus"
+
+	// statusCodeSuccess is the status_code of a successful request
+	statusCodeSuccess = 100
+
+	// Default currency
+	defaultCurrency = "TRY"
+
+	// orderTypeRecurring sets up a recurring plan for the card
+	orderTypeRecurring = "1"
+
+	// tokenRefreshMargin renews a token before Sipay expires it
+	tokenRefreshMargin = time.Minute
+	// tokenLifetime is assumed when Sipay does not report when a token expires
+	tokenLifetime = time.Hour
+
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+// recurringCycles are Sipay's recurring_payment_cycle codes of the subscription intervals
+var recurringCycles = map[string]string{
+	provider.SubscriptionIntervalDay:   "D",
+	provider.SubscriptionIntervalWeek:  "W",
+	provider.SubscriptionIntervalMonth: "M",
+	provider.SubscriptionIntervalYear:  "Y",
+}
+
+// SipayProvider implements the provider.PaymentProvider interface for Sipay
+type SipayProvider struct {
+	appID        string
+	appSecret    string
+	merchantKey  string
+	baseURL      string
+	gopayBaseURL string
+	isProduction bool
+	httpClient   *provider.ProviderHTTPClient
+
+	// The bearer token is shared by all requests until it expires
+	tokenMu        sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
+}
+
+// NewProvider creates a new Sipay payment provider
+func NewProvider() provider.PaymentProvider {
+	return &SipayProvider{}
+}
+
+// GetRequiredConfig returns the configuration fields required for Sipay
+func (p *SipayProvider) GetRequiredConfig(environment string) []provider.ConfigField {
+	return []provider.ConfigField{
+		{
+			Key:         "appId",
+			Required:    true,
+			Type:        "string",
+			Description: "Application key (App ID) from the Sipay merchant panel",
+			Example:     "6d4a7e9374a76c15260fcc75e315b0b9",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "appSecret",
+			Required:    true,
+			Type:        "string",
+			Description: "Application secret (App Secret) from the Sipay merchant panel",
+			Example:     "b46a67571aa1e7ef5641dc3fa6f1712a",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "merchantKey",
+			Required:    true,
+			Type:        "string",
+			Description: "Merchant key from the Sipay merchant panel",
+			Example:     "$2y$10$HmRgYosneqcwHj.UH7upGuyCZqpQ1ITgSMj9Vvxn.t6f.Vdf2SQFO",
+			MinLength:   10,
+			MaxLength:   100,
+		},
+		{
+			Key:         "environment",
+			Required:    true,
+			Type:        "string",
+			Description: "Environment setting (sandbox or production)",
+			Example:     "sandbox",
+			Pattern:     "^(sandbox|production)$",
+		},
+	}
+}
+
+// ValidateConfig validates the provided configuration against Sipay requirements
+func (p *SipayProvider) ValidateConfig(config map[string]string) error {
+	requiredFields := p.GetRequiredConfig(config["environment"])
+	return provider.ValidateConfigFields("sipay", config, requiredFields)
+}
+
+// SupportedCurrencies returns the currencies accepted by Sipay
+func (p *SipayProvider) SupportedCurrencies() []string {
+	return []string{"TRY", "USD", "EUR"}
+}
+
+// HealthCheckEndpoint returns the token endpoint, which answers without a bearer token
+func (p *SipayProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL + endpointToken
+}
+
+// Initialize sets up the Sipay payment provider with authentication credentials
+func (p *SipayProvider) Initialize(conf map[string]string) error {
+	p.appID = conf["appId"]
+	p.appSecret = conf["appSecret"]
+	p.merchantKey = conf["merchantKey"]
+
+	if p.appID == "" || p.appSecret == "" || p.merchantKey == "" {
+		return errors.New("sipay: appId, appSecret and merchantKey are required")
+	}
+
+	p.gopayBaseURL = config.GetEnv("APP_URL", "http://localhost:9999")
+
+	p.isProduction = conf["environment"] == "production"
+	p.baseURL = apiSandboxURL
+	if p.isProduction {
+		p.baseURL = apiProductionURL
+	}
+
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("sipay"))
+
+	return nil
+}
+
+// GetInstallmentCount returns the installment count for a payment
+func (p *SipayProvider) GetInstallmentCount(ctx context.Context, request provider.InstallmentInquireRequest) (provider.InstallmentInquireResponse, error) {
+	return provider.InstallmentInquireResponse{}, nil
+}
+
+// GetCommission returns the commission for a payment
+func (p *SipayProvider) GetCommission(ctx context.Context, request provider.CommissionRequest) (provider.CommissionResponse, error) {
+	return provider.CommissionResponse{}, nil
+}
+
+// CreatePayment makes a non-3D sale with paySmart2D. The sale goes to the POS Sipay picks
+// for the card; GoPay does not query Sipay's POS list first.
+func (p *SipayProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		return nil, fmt.Errorf("sipay: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	invoiceID := p.generateInvoiceId()
+	params, err := p.buildPaymentParams(request, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp saleResponse
+	if err := p.send(ctx, endpointPay2D, params, &resp); err != nil {
+		return nil, err
+	}
+
+	response := paymentResponse(&resp)
+	response.PaymentID = invoiceID
+	response.Amount = request.Amount
+	response.Currency = request.Currency
+	return response, nil
+}
+
+// Create3DPayment starts a 3D payment: the customer posts the card to paySmart3D, which
+// completes the sale after the challenge and posts the result to GoPay
+func (p *SipayProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	if err := p.validatePaymentRequest(request, true); err != nil {
+		return nil, fmt.Errorf("sipay: invalid 3D payment request: %w", provider.InvalidRequest(err))
+	}
+
+	invoiceID := p.generateInvoiceId()
+
+	// Create callback state
+	state := provider.CallbackState{
+		TenantID:         int(request.TenantID),
+		PaymentID:        invoiceID,
+		OriginalCallback: request.CallbackURL,
+		Amount:           request.Amount,
+		Currency:         request.Currency,
+		LogID:            request.LogID,
+		Provider:         "sipay",
+		Environment:      request.Environment,
+		Timestamp:        time.Now(),
+		ClientIP:         request.ClientIP,
+		Installment:      request.InstallmentCount,
+		SessionID:        request.SessionID,
+	}
+
+	// Create short callback URL (state will be stored in DB)
+	gopayCallbackURL, err := provider.CreateShortCallbackURL(ctx, p.gopayBaseURL, "sipay", state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callback URL: %w", err)
+	}
+
+	formParams, err := p.build3DFormParams(request, invoiceID, gopayCallbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &provider.PaymentResponse{
+		Success:    true,
+		Status:     provider.StatusPending,
+		PaymentID:  invoiceID,
+		Amount:     request.Amount,
+		Currency:   request.Currency,
+		HTML:       p.generate3DSecureHTML(formParams),
+		Message:    "3D Secure authentication required",
+		SystemTime: &now,
+	}, nil
+}
+
+// Complete3DPayment reads the result of a 3D payment Sipay posted back
+func (p *SipayProvider) Complete3DPayment(ctx context.Context, callbackState *provider.CallbackState, data map[string]string) (*provider.PaymentResponse, error) {
+	if len(data) == 0 {
+		return nil, errors.New("sipay: no callback data received")
+	}
+
+	if err := p.verifyCallbackHash(callbackState, data); err != nil {
+		return nil, err
+	}
+
+	// Log callback data for tracking
+	if callbackState.LogID > 0 {
+		if reqMap, err := provider.StructToMap(data); err == nil {
+			_ = provider.AddProviderRequestToClientRequest("sipay", "callbackData", reqMap, callbackState.LogID)
+		}
+	}
+
+	response := callbackResponse(callbackState, data)
+
+	if !response.Success && provider.Is3DSessionExpiredMessage(data["error"], data["status_description"]) {
+		response.ErrorCode = provider.ErrorCode3DSessionExpired
+		return response, fmt.Errorf("sipay: %w", provider.Err3DSessionExpired)
+	}
+
+	// The customer left the bank page; the card was never declined
+	if !response.Success && provider.Is3DSChallengeCancelledMessage(data["error"], data["status_description"]) {
+		provider.MarkThreeDSCancelled(response)
+	}
+
+	return response, nil
+}
+
+// callbackResponse maps a verified 3D callback. The sale went through when both
+// sipay_status and payment_status are 1.
+func callbackResponse(callbackState *provider.CallbackState, data map[string]string) *provider.PaymentResponse {
+	success := data["sipay_status"] == "1" && data["payment_status"] == "1"
+
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		Success:          success,
+		PaymentID:        callbackState.PaymentID,
+		TransactionID:    data["order_no"],
+		OrderID:          data["order_id"],
+		Amount:           callbackState.Amount,
+		Currency:         callbackState.Currency,
+		SystemTime:       &now,
+		ProviderResponse: data,
+		RedirectURL:      callbackState.OriginalCallback,
+	}
+
+	if success {
+		response.Status = provider.StatusSuccessful
+		response.Message = "3D payment completed successfully"
+		return response
+	}
+
+	response.Status = provider.StatusFailed
+	switch {
+	case data["error_code"] != "" && data["error_code"] != strconv.Itoa(statusCodeSuccess):
+		response.ErrorCode = data["error_code"]
+	case data["status_code"] != "":
+		response.ErrorCode = data["status_code"]
+	}
+	switch {
+	case data["error"] != "":
+		response.Message = data["error"]
+	case data["status_description"] != "":
+		response.Message = data["status_description"]
+	default:
+		response.Message = "3D payment failed"
+	}
+	return response
+}
+
+// GetPaymentStatus retrieves the current status of a payment with checkstatus
+func (p *SipayProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("sipay: paymentID is required")
+	}
+
+	hashKey, err := p.generateHashKey(request.PaymentID, p.merchantKey)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{
+		"invoice_id":             request.PaymentID,
+		"merchant_key":           p.merchantKey,
+		"include_pending_status": true,
+		"hash_key":               hashKey,
+	}
+
+	var resp checkStatusResponse
+	if err := p.send(ctx, endpointCheckStatus, params, &resp); err != nil {
+		return nil, err
+	}
+	return statusResponse(request.PaymentID, &resp)
+}
+
+// statusResponse maps a checkstatus response, returning provider.ErrPaymentNotFound for an
+// unknown invoice
+func statusResponse(paymentID string, resp *checkStatusResponse) (*provider.PaymentResponse, error) {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		PaymentID:        paymentID,
+		TransactionID:    resp.TransactionID,
+		OrderID:          resp.OrderID,
+		SystemTime:       &now,
+		ProviderResponse: resp,
+	}
+
+	if resp.StatusCode != statusCodeSuccess {
+		response.Status = provider.StatusFailed
+		response.ErrorCode = strconv.Itoa(resp.StatusCode)
+		response.Message = resp.StatusDescription
+		if code, err := provider.CancelFailure(resp.StatusDescription); errors.Is(err, provider.ErrPaymentNotFound) {
+			response.ErrorCode = code
+			return response, fmt.Errorf("sipay: %w", err)
+		}
+		return response, nil
+	}
+
+	response.Success = true
+	response.Message = resp.StatusDescription
+	status := strings.ToLower(resp.TransactionStatus)
+	switch {
+	case strings.Contains(status, "refund"):
+		response.Status = provider.StatusRefunded
+	case strings.HasPrefix(status, "cancel"):
+		response.Status = provider.StatusCancelled
+	case status == "completed":
+		response.Status = provider.StatusSuccessful
+	case status == "pending":
+		response.Status = provider.StatusPending
+	case status == "failed":
+		response.Status = provider.StatusFailed
+		response.Success = false
+		response.Message = resp.Reason
+	default:
+		response.Status = provider.StatusUnknown
+	}
+
+	return response, nil
+}
+
+// CancelPayment is not supported by Sipay: sales are reversed with a refund, which Sipay
+// turns into a void before end of day
+func (p *SipayProvider) CancelPayment(ctx context.Context, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	return nil, errors.New("sipay: cancel is not supported, use a refund of the full amount")
+}
+
+// RefundPayment issues a full or partial refund for a payment
+func (p *SipayProvider) RefundPayment(ctx context.Context, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	if request.PaymentID == "" {
+		return nil, errors.New("sipay: paymentID is required for refund")
+	}
+
+	if request.RefundAmount <= 0 {
+		return nil, errors.New("sipay: refund amount must be greater than 0")
+	}
+
+	amount := formatAmount(request.RefundAmount)
+	hashKey, err := p.generateHashKey(amount, request.PaymentID, p.merchantKey)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{
+		"invoice_id":   request.PaymentID,
+		"amount":       amount,
+		"app_id":       p.appID,
+		"app_secret":   p.appSecret,
+		"merchant_key": p.merchantKey,
+		"hash_key":     hashKey,
+	}
+
+	var resp refundResponse
+	if err := p.send(ctx, endpointRefund, params, &resp); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	success := resp.StatusCode == statusCodeSuccess
+
+	refundResp := &provider.RefundResponse{
+		Success:      success,
+		PaymentID:    request.PaymentID,
+		RefundAmount: request.RefundAmount,
+		SystemTime:   &now,
+		RawResponse:  resp,
+	}
+
+	if success {
+		refundResp.Status = "success"
+		refundResp.Message = "Refund successful"
+		refundResp.RefundID = resp.OrderNo
+	} else {
+		refundResp.Status = "failed"
+		refundResp.ErrorCode = strconv.Itoa(resp.StatusCode)
+		refundResp.Message = resp.StatusDescription
+	}
+
+	return refundResp, nil
+}
+
+// ValidateWebhook validates an incoming webhook notification
+func (p *SipayProvider) ValidateWebhook(ctx context.Context, data map[string]string, headers map[string]string) (bool, map[string]string, error) {
+	// Sipay posts payment results to the 3D callback; sale webhooks are not configured
+	return true, data, nil
+}
+
+// validatePaymentRequest validates the payment request
+func (p *SipayProvider) validatePaymentRequest(request provider.PaymentRequest, is3D bool) error {
+	if request.TenantID == 0 {
+		return errors.New("tenantID is required")
+	}
+
+	if request.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if request.CardInfo.CardNumber == "" {
+		return errors.New("card number is required")
+	}
+
+	if request.CardInfo.CVV == "" {
+		return errors.New("CVV is required")
+	}
+
+	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+		return errors.New("card expiration month and year are required")
+	}
+
+	if recurring := request.Recurring; recurring != nil {
+		if recurring.Count < 2 {
+			return errors.New("recurring payment count must be at least 2")
+		}
+		if _, ok := recurringCycles[strings.ToLower(recurring.Interval)]; !ok {
+			return errors.New("recurring interval must be day, week, month or year")
+		}
+	}
+
+	if is3D && request.CallbackURL == "" {
+		return errors.New("callback URL is required for 3D secure payments")
+	}
+
+	return nil
+}
+
+// buildPaymentParams builds the fields of a paySmart2D or paySmart3D sale, with its hash_key
+func (p *SipayProvider) buildPaymentParams(request provider.PaymentRequest, invoiceID string) (map[string]any, error) {
+	currency := request.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	total := formatAmount(request.Amount)
+	installments := installmentCount(request.InstallmentCount)
+
+	hashKey, err := p.generateHashKey(total, installments, currency, p.merchantKey, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{
+		"cc_holder_name":      cardHolderName(request),
+		"cc_no":               provider.NormalizePAN(request.CardInfo.CardNumber),
+		"expiry_month":        request.CardInfo.ExpireMonth,
+		"expiry_year":         fullYear(request.CardInfo.ExpireYear),
+		"cvv":                 request.CardInfo.CVV,
+		"currency_code":       currency,
+		"installments_number": installments,
+		"invoice_id":          invoiceID,
+		"invoice_description": invoiceDescription(request),
+		"total":               total,
+		"merchant_key":        p.merchantKey,
+		"items":               items(request),
+		"name":                request.Customer.Name,
+		"surname":             request.Customer.Surname,
+		"bill_email":          request.Customer.Email,
+		"bill_phone":          request.Customer.PhoneNumber,
+		"ip":                  request.ClientIP,
+		"hash_key":            hashKey,
+	}
+
+	// A recurring plan is passed through as is; Sipay charges the card on its schedule
+	if recurring := request.Recurring; recurring != nil {
+		params["order_type"] = orderTypeRecurring
+		params["recurring_payment_number"] = strconv.Itoa(recurring.Count)
+		params["recurring_payment_cycle"] = recurringCycles[strings.ToLower(recurring.Interval)]
+		params["recurring_payment_interval"] = strconv.Itoa(max(recurring.IntervalCount, 1))
+		if recurring.WebhookKey != "" {
+			params["recurring_web_hook_key"] = recurring.WebhookKey
+		}
+	}
+
+	return params, nil
+}
+
+// build3DFormParams builds the form posted to paySmart3D. The form carries items as JSON.
+func (p *SipayProvider) build3DFormParams(request provider.PaymentRequest, invoiceID, callbackURL string) (map[string]string, error) {
+	params, err := p.buildPaymentParams(request, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	params["return_url"] = callbackURL
+	params["cancel_url"] = callbackURL
+	params["response_method"] = http.MethodPost
+
+	formParams := make(map[string]string, len(params))
+	for key, value := range params {
+		switch v := value.(type) {
+		case string:
+			formParams[key] = v
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("sipay: failed to encode %s: %w", key, err)
+			}
+			formParams[key] = string(encoded)
+		}
+	}
+	return formParams, nil
+}
+
+// generate3DSecureHTML generates HTML form for 3D Secure authentication
+func (p *SipayProvider) generate3DSecureHTML(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var formFields strings.Builder
+	for _, key := range keys {
+		formFields.WriteString(fmt.Sprintf(`<input type="hidden" name="%s" value="%s" />`, key, html.EscapeString(params[key])))
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+	<title>3D Secure Authentication</title>
+	<meta charset="utf-8">
+	<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+</head>
+<body onload="document.threeDForm.submit();">
+	<div style="text-align: center; margin-top: 50px;">
+		<p>Ödeme işleminiz 3D güvenlik sayfasına yönlendiriliyor...</p>
+		<p>Payment is being redirected to 3D secure page...</p>
+	</div>
+	<form name="threeDForm" method="POST" action="%s">
+		%s
+	</form>
+</body>
+</html>`, p.baseURL+endpointPay3D, formFields.String())
+}
+
+// verifyCallbackHash decrypts the hash_key of a 3D callback, which carries
+// status|total|invoice_id|order_id|currency_code, and checks that it belongs to the payment
+func (p *SipayProvider) verifyCallbackHash(callbackState *provider.CallbackState, data map[string]string) error {
+	hashKey := data["hash_key"]
+	if hashKey == "" {
+		return errors.New("sipay: missing hash_key in callback")
+	}
+
+	decrypted, err := p.decryptHashKey(hashKey)
+	if err != nil {
+		return fmt.Errorf("sipay: invalid callback hash: %w", err)
+	}
+
+	fields := strings.Split(decrypted, "|")
+	if len(fields) < 5 {
+		return errors.New("sipay: invalid callback hash")
+	}
+	if fields[2] != callbackState.PaymentID || (data["invoice_id"] != "" && data["invoice_id"] != fields[2]) {
+		return errors.New("sipay: callback hash does not match the payment")
+	}
+	if total, err := strconv.ParseFloat(fields[1], 64); err != nil || formatAmount(total) != formatAmount(callbackState.Amount) {
+		return errors.New("sipay: callback hash does not match the payment amount")
+	}
+	return nil
+}
+
+// generateHashKey encrypts the values joined with | the way Sipay's hash_key expects:
+// AES-256-CBC with the key SHA256(SHA1(appSecret) + salt) and a random IV and salt, sent as
+// iv:salt:ciphertext with / replaced by __
+func (p *SipayProvider) generateHashKey(values ...string) (string, error) {
+	iv, err := randomHex(16)
+	if err != nil {
+		return "", fmt.Errorf("sipay: %w", err)
+	}
+	salt, err := randomHex(4)
+	if err != nil {
+		return "", fmt.Errorf("sipay: %w", err)
+	}
+
+	block, err := aes.NewCipher(p.hashKeyKey(salt))
+	if err != nil {
+		return "", fmt.Errorf("sipay: %w", err)
+	}
+
+	plain := pkcs7Pad([]byte(strings.Join(values, "|")), aes.BlockSize)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, []byte(iv)).CryptBlocks(encrypted, plain)
+
+	bundle := iv + ":" + salt + ":" + base64.StdEncoding.EncodeToString(encrypted)
+	return strings.ReplaceAll(bundle, "/", "__"), nil
+}
+
+// decryptHashKey reverses generateHashKey
+func (p *SipayProvider) decryptHashKey(hashKey string) (string, error) {
+	parts := strings.SplitN(strings.ReplaceAll(hashKey, "__", "/"), ":", 3)
+	if len(parts) != 3 || len(parts[0]) != aes.BlockSize {
+		return "", errors.New("malformed hash_key")
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return "", errors.New("malformed hash_key")
+	}
+
+	block, err := aes.NewCipher(p.hashKeyKey(parts[1]))
+	if err != nil {
+		return "", err
+	}
+
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, []byte(parts[0])).CryptBlocks(plain, encrypted)
+	return pkcs7Unpad(plain, aes.BlockSize)
+}
+
+// hashKeyKey returns the AES key of a hash_key: the first 32 characters of the hex
+// SHA256(SHA1(appSecret) + salt), as PHP's openssl_encrypt truncates it
+func (p *SipayProvider) hashKeyKey(salt string) []byte {
+	password := sha1.Sum([]byte(p.appSecret))
+	key := sha256.Sum256([]byte(hex.EncodeToString(password[:]) + salt))
+	return []byte(hex.EncodeToString(key[:])[:32])
+}
+
+// pkcs7Pad pads data to a multiple of blockSize
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	for range padding {
+		data = append(data, byte(padding))
+	}
+	return data
+}
+
+// pkcs7Unpad removes the padding of a decrypted block
+func pkcs7Unpad(data []byte, blockSize int) (string, error) {
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > blockSize || padding > len(data) {
+		return "", errors.New("invalid padding")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return "", errors.New("invalid padding")
+		}
+	}
+	return string(data[:len(data)-padding]), nil
+}
+
+// randomHex returns n random hex characters
+func randomHex(n int) (string, error) {
+	b := make([]byte, (n+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b)[:n], nil
+}
+
+// accessToken returns the bearer token, requesting a new one when it is missing or about to
+// expire
+func (p *SipayProvider) accessToken(ctx context.Context) (string, error) {
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
+
+	if p.token != "" && time.Now().Add(tokenRefreshMargin).Before(p.tokenExpiresAt) {
+		return p.token, nil
+	}
+
+	httpReq := &provider.HTTPRequest{
+		Method:   http.MethodPost,
+		Endpoint: endpointToken,
+		Body: map[string]string{
+			"app_id":     p.appID,
+			"app_secret": p.appSecret,
+		},
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, httpReq)
+	if err != nil {
+		return "", fmt.Errorf("sipay: token request failed: %w", err)
+	}
+
+	var tokenResp tokenResponse
+	if err := p.httpClient.ParseJSONResponse(resp, &tokenResp); err != nil {
+		return "", fmt.Errorf("sipay: invalid token response: %w", err)
+	}
+	if tokenResp.StatusCode != statusCodeSuccess || tokenResp.Data.Token == "" {
+		return "", fmt.Errorf("sipay: token request failed: %s", tokenResp.StatusDescription)
+	}
+
+	p.token = tokenResp.Data.Token
+	p.tokenExpiresAt = time.Now().Add(tokenLifetime)
+	if expiresAt := provider.ParseProviderTime(tokenResp.Data.ExpiresAt, provider.TurkeyTimeZone, timeLayout); expiresAt != nil {
+		p.tokenExpiresAt = *expiresAt
+	}
+	return p.token, nil
+}
+
+// send posts params to endpoint with the bearer token and decodes the response into result
+func (p *SipayProvider) send(ctx context.Context, endpoint string, params map[string]any, result any) error {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	httpReq := &provider.HTTPRequest{
+		Method:   http.MethodPost,
+		Endpoint: endpoint,
+		Headers:  map[string]string{"Authorization": "Bearer " + token},
+		Body:     params,
+	}
+
+	resp, err := p.httpClient.SendJSON(ctx, httpReq)
+	if err != nil {
+		return fmt.Errorf("sipay: request failed: %w", err)
+	}
+
+	if err := p.httpClient.ParseJSONResponse(resp, result); err != nil {
+		return fmt.Errorf("sipay: %w", err)
+	}
+	return nil
+}
+
+// paymentResponse maps a paySmart2D response. The sale went through when the request
+// succeeded and both sipay_status and payment_status are 1.
+func paymentResponse(resp *saleResponse) *provider.PaymentResponse {
+	now := time.Now()
+	response := &provider.PaymentResponse{
+		TransactionID:    resp.Data.OrderNo,
+		OrderID:          resp.Data.OrderID,
+		SystemTime:       &now,
+		ProviderResponse: resp,
+	}
+
+	if resp.StatusCode == statusCodeSuccess && resp.Data.SipayStatus == 1 && resp.Data.PaymentStatus == 1 {
+		response.Success = true
+		response.Status = provider.StatusSuccessful
+		response.Message = "Payment successful"
+		return response
+	}
+
+	response.Status = provider.StatusFailed
+	response.ErrorCode = strconv.Itoa(resp.StatusCode)
+	if code := resp.Data.ErrorCode.String(); code != "" && code != strconv.Itoa(statusCodeSuccess) {
+		response.ErrorCode = code
+	}
+	response.Message = resp.StatusDescription
+	if resp.Data.Error != "" {
+		response.Message = resp.Data.Error
+	}
+	return response
+}
+
+// formatAmount returns amount with two decimals and a dot
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// installmentCount returns installments_number, 1 for a single payment
+func installmentCount(count int) string {
+	return strconv.Itoa(max(count, 1))
+}
+
+// fullYear returns a card expiry year with four digits
+func fullYear(year string) string {
+	if len(year) == 2 {
+		return "20" + year
+	}
+	return year
+}
+
+// cardHolderName returns the name on the card, falling back to the customer's name
+func cardHolderName(request provider.PaymentRequest) string {
+	if name := strings.TrimSpace(request.CardInfo.CardHolderName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(request.Customer.Name + " " + request.Customer.Surname)
+}
+
+// invoiceDescription returns the description of the payment
+func invoiceDescription(request provider.PaymentRequest) string {
+	if request.Description != "" {
+		return request.Description
+	}
+	return "Payment"
+}
+
+// items returns the basket of the payment. Sipay requires the items to add up to the
+// total, so a payment without items is sent as a single item.
+func items(request provider.PaymentRequest) []item {
+	var basket []item
+	var sum float64
+	for _, i := range request.Items {
+		quantity := max(i.Quantity, 1)
+		basket = append(basket, item{Name: i.Name, Price: formatAmount(i.Price), Quantity: quantity, Description: i.Description})
+		sum += i.Price * float64(quantity)
+	}
+
+	if len(basket) == 0 || formatAmount(sum) != formatAmount(request.Amount) {
+		return []item{{Name: invoiceDescription(request), Price: formatAmount(request.Amount), Quantity: 1, Description: invoiceDescription(request)}}
+	}
+	return basket
+}
+
+// generateInvoiceId generates a unique invoice ID, which is the GoPay payment ID
+func (p *SipayProvider) generateInvoiceId() string {
+	suffix, err := randomHex(8)
+	if err != nil {
+		suffix = strconv.FormatInt(time.Now().UnixNano()%100000000, 16)
+	}
+	return fmt.Sprintf("GP%d%s", time.Now().Unix(), strings.ToUpper(suffix))
+}
+
+// item is a basket item of a sale
+type item struct {
+	Name        string `json:"name"`
+	Price       string `json:"price"`
+	Quantity    int    `json:"quantity"`
+	Description string `json:"description"`
+}
+
+// tokenResponse is the response of the token endpoint
+type tokenResponse struct {
+	StatusCode        int    `json:"status_code"`
+	StatusDescription string `json:"status_description"`
+	Data              struct {
+		Token     string `json:"token"`
+		IsThreeD  int    `json:"is_3d"`
+		ExpiresAt string `json:"expires_at"`
+	} `json:"data"`
+}
+
+// saleResponse is the response of paySmart2D
+type saleResponse struct {
+	StatusCode        int    `json:"status_code"`
+	StatusDescription string `json:"status_description"`
+	Data              struct {
+		SipayStatus        int         `json:"sipay_status"`
+		OrderNo            string      `json:"order_no"`
+		OrderID            string      `json:"order_id"`
+		InvoiceID          string      `json:"invoice_id"`
+		SipayPaymentMethod int         `json:"sipay_payment_method"`
+		CreditCardNo       string      `json:"credit_card_no"`
+		TransactionType    string      `json:"transaction_type"`
+		PaymentStatus      int         `json:"payment_status"`
+		PaymentMethod      int         `json:"payment_method"`
+		ErrorCode          json.Number `json:"error_code"`
+		Error              string      `json:"error"`
+		AuthCode           string      `json:"auth_code"`
+		HashKey            string      `json:"hash_key"`
+	} `json:"data"`
+}
+
+// refundResponse is the response of the refund endpoint
+type refundResponse struct {
+	StatusCode        int    `json:"status_code"`
+	StatusDescription string `json:"status_description"`
+	OrderNo           string `json:"order_no"`
+	InvoiceID         string `json:"invoice_id"`
+	RefNo             string `json:"ref_no"`
+}
+
+// checkStatusResponse is the response of checkstatus
+type checkStatusResponse struct {
+	StatusCode        int    `json:"status_code"`
+	StatusDescription string `json:"status_description"`
+	TransactionStatus string `json:"transaction_status"`
+	OrderID           string `json:"order_id"`
+	TransactionID     string `json:"transaction_id"`
+	Message           string `json:"message"`
+	Reason            string `json:"reason"`
+	SaleWebHookKey    string `json:"sale_web_hook_key"`
+	RecurringID       string `json:"recurring_id"`
+}
@@ -0,0 +1,71 @@
+package sipay
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Test card for the Sipay provisioning environment
+var testCard = provider.CardInfo{CardNumber: "4508034508034509", ExpireMonth: "12", ExpireYear: "2026", CVV: "000"}
+
+// setupRealTestProvider returns a sandbox provider from SIPAY_APP_ID, SIPAY_APP_SECRET and
+// SIPAY_MERCHANT_KEY, skipping the test when they are not set
+func setupRealTestProvider(t *testing.T) *SipayProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"appId":       os.Getenv("SIPAY_APP_ID"),
+		"appSecret":   os.Getenv("SIPAY_APP_SECRET"),
+		"merchantKey": os.Getenv("SIPAY_MERCHANT_KEY"),
+		"environment": "sandbox",
+	}
+	if config["appId"] == "" || config["appSecret"] == "" || config["merchantKey"] == "" {
+		t.Skip("sipay sandbox credentials not set; skipping real API test")
+	}
+
+	p := NewProvider().(*SipayProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+// TestSipayProvider_RealAPI_SaleStatusRefund makes a non-3D sale, queries it and refunds it
+func TestSipayProvider_RealAPI_SaleStatusRefund(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	sale, err := p.CreatePayment(ctx, provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   1.00,
+		Currency: "TRY",
+		ClientIP: "127.0.0.1",
+		Customer: provider.Customer{Name: "Test", Surname: "User", Email: "test@sipay.example.com"},
+		CardInfo: testCard,
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Sale: success=%v status=%s paymentID=%s message=%s", sale.Success, sale.Status, sale.PaymentID, sale.Message)
+	if !sale.Success {
+		t.Skipf("Sandbox declined the sale: %s %s", sale.ErrorCode, sale.Message)
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: sale.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	t.Logf("Status: %s message=%s", status.Status, status.Message)
+
+	refund, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: sale.PaymentID, RefundAmount: 1.00, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	t.Logf("Refund: success=%v status=%s message=%s", refund.Success, refund.Status, refund.Message)
+}
@@ -0,0 +1,506 @@
+package sipay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"appId":       "6d4a7e9374a76c15260fcc75e315b0b9",
+		"appSecret":   "b46a67571aa1e7ef5641dc3fa6f1712a",
+		"merchantKey": "$2y$10$HmRgYosneqcwHj.UH7upGuyCZqpQ1ITgSMj9Vvxn.t6f.Vdf2SQFO",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	sipayProvider, ok := p.(*SipayProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a SipayProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if sipayProvider.httpClient != nil {
+		t.Error("SipayProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := sipayProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if sipayProvider.httpClient == nil {
+		t.Error("SipayProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestSipayProvider_Initialize(t *testing.T) {
+	without := func(key string) map[string]string {
+		config := testConfig("sandbox")
+		delete(config, key)
+		return config
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectError bool
+		baseURL     string
+	}{
+		{"sandbox", testConfig("sandbox"), false, apiSandboxURL},
+		{"production", testConfig("production"), false, apiProductionURL},
+		{"missing appId", without("appId"), true, ""},
+		{"missing appSecret", without("appSecret"), true, ""},
+		{"missing merchantKey", without("merchantKey"), true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SipayProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL {
+				t.Errorf("Expected %s, got %s", tt.baseURL, p.baseURL)
+			}
+		})
+	}
+}
+
+func TestSipayProvider_GetRequiredConfig(t *testing.T) {
+	p := &SipayProvider{}
+	fields := p.GetRequiredConfig("sandbox")
+
+	expected := map[string]bool{"appId": true, "appSecret": true, "merchantKey": true, "environment": true}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d config fields, got %d", len(expected), len(fields))
+	}
+	for _, field := range fields {
+		if !expected[field.Key] || !field.Required {
+			t.Errorf("Unexpected config field %+v", field)
+		}
+	}
+
+	if err := p.ValidateConfig(testConfig("sandbox")); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestSipayProvider_HashKey(t *testing.T) {
+	p := NewProvider().(*SipayProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	hashKey, err := p.generateHashKey("100.50", "1", "TRY", p.merchantKey, "GP1")
+	if err != nil {
+		t.Fatalf("generateHashKey failed: %v", err)
+	}
+	if strings.Contains(hashKey, "/") || len(strings.Split(hashKey, ":")) != 3 {
+		t.Errorf("Unexpected hash_key format %s", hashKey)
+	}
+
+	decrypted, err := p.decryptHashKey(hashKey)
+	if err != nil {
+		t.Fatalf("decryptHashKey failed: %v", err)
+	}
+	if expected := "100.50|1|TRY|" + p.merchantKey + "|GP1"; decrypted != expected {
+		t.Errorf("Expected %s, got %s", expected, decrypted)
+	}
+
+	// Every hash_key gets its own IV and salt
+	if other, _ := p.generateHashKey("100.50", "1", "TRY", p.merchantKey, "GP1"); other == hashKey {
+		t.Error("Expected a different hash_key for each call")
+	}
+
+	// A hash_key of another merchant does not decrypt
+	other := NewProvider().(*SipayProvider)
+	config := testConfig("sandbox")
+	config["appSecret"] = "another-app-secret"
+	if err := other.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if decrypted, err := other.decryptHashKey(hashKey); err == nil && decrypted == "100.50|1|TRY|"+p.merchantKey+"|GP1" {
+		t.Error("Expected a hash_key to decrypt only with its own app secret")
+	}
+
+	if _, err := p.decryptHashKey("not-a-hash-key"); err == nil {
+		t.Error("Expected an error for a malformed hash_key")
+	}
+}
+
+func testPaymentRequest() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      100.50,
+		Currency:    "TRY",
+		CallbackURL: "https://example.com/callback",
+		ClientIP:    "10.0.0.1",
+		Customer:    provider.Customer{Name: "John", Surname: "Doe", Email: "john@example.com"},
+		CardInfo:    provider.CardInfo{CardNumber: "4508 0345 0803 4509", ExpireMonth: "12", ExpireYear: "26", CVV: "000"},
+	}
+}
+
+func TestSipayProvider_Build3DFormParams(t *testing.T) {
+	p := NewProvider().(*SipayProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	request := testPaymentRequest()
+	request.InstallmentCount = 3
+	request.Items = []provider.Item{{Name: "Book", Price: 50.25, Quantity: 2}}
+	params, err := p.build3DFormParams(request, "GP1", "https://gopay.example.com/cb")
+	if err != nil {
+		t.Fatalf("build3DFormParams failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"cc_no":               "4508034508034509",
+		"expiry_year":         "2026",
+		"cc_holder_name":      "John Doe",
+		"total":               "100.50",
+		"installments_number": "3",
+		"currency_code":       "TRY",
+		"invoice_id":          "GP1",
+		"return_url":          "https://gopay.example.com/cb",
+		"cancel_url":          "https://gopay.example.com/cb",
+		"items":               `[{"name":"Book","price":"50.25","quantity":2,"description":""}]`,
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%s, got %s", key, value, params[key])
+		}
+	}
+	if _, ok := params["order_type"]; ok {
+		t.Error("Expected no order_type without a recurring plan")
+	}
+
+	decrypted, err := p.decryptHashKey(params["hash_key"])
+	if err != nil || decrypted != "100.50|3|TRY|"+p.merchantKey+"|GP1" {
+		t.Errorf("Unexpected hash_key %q (%v)", decrypted, err)
+	}
+
+	html := p.generate3DSecureHTML(params)
+	if !strings.Contains(html, `action="`+apiSandboxURL+endpointPay3D+`"`) || !strings.Contains(html, `name="hash_key"`) {
+		t.Errorf("Unexpected 3D form %s", html)
+	}
+}
+
+func TestSipayProvider_RecurringPassthrough(t *testing.T) {
+	p := NewProvider().(*SipayProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	request := testPaymentRequest()
+	request.Recurring = &provider.RecurringPlan{Count: 12, Interval: "month", WebhookKey: "recurring-hook"}
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	params, err := p.buildPaymentParams(request, "GP1")
+	if err != nil {
+		t.Fatalf("buildPaymentParams failed: %v", err)
+	}
+	expected := map[string]any{
+		"order_type":                 "1",
+		"recurring_payment_number":   "12",
+		"recurring_payment_cycle":    "M",
+		"recurring_payment_interval": "1",
+		"recurring_web_hook_key":     "recurring-hook",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, params[key])
+		}
+	}
+
+	request.Recurring = &provider.RecurringPlan{Count: 12, Interval: "fortnight"}
+	if err := p.validatePaymentRequest(request, false); err == nil {
+		t.Error("Expected an error for an unknown recurring interval")
+	}
+	request.Recurring = &provider.RecurringPlan{Count: 1, Interval: "week"}
+	if err := p.validatePaymentRequest(request, false); err == nil {
+		t.Error("Expected an error for a recurring plan of a single payment")
+	}
+}
+
+func TestSipayProvider_Complete3DPayment(t *testing.T) {
+	p := NewProvider().(*SipayProvider)
+	if err := p.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://example.com/callback",
+		Amount:           100.50,
+		Currency:         "TRY",
+		Provider:         "sipay",
+		Environment:      "sandbox",
+	}
+
+	callback := func(sipayStatus, invoiceID, total string) map[string]string {
+		hashKey, err := p.generateHashKey("Completed", total, invoiceID, "VP1000", "TRY")
+		if err != nil {
+			t.Fatalf("generateHashKey failed: %v", err)
+		}
+		return map[string]string{
+			"sipay_status":       sipayStatus,
+			"payment_status":     sipayStatus,
+			"order_no":           "VP1000",
+			"order_id":           "VP1000",
+			"invoice_id":         invoiceID,
+			"status_code":        "100",
+			"status_description": "Payment process successful",
+			"error_code":         "100",
+			"hash_key":           hashKey,
+		}
+	}
+
+	ctx := context.Background()
+	response, err := p.Complete3DPayment(ctx, callbackState, callback("1", "GP1", "100.50"))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "VP1000" || response.RedirectURL != callbackState.OriginalCallback {
+		t.Errorf("Unexpected response for an approved callback: %+v", response)
+	}
+
+	declined := callback("0", "GP1", "100.50")
+	declined["status_code"] = "41"
+	declined["error_code"] = "51"
+	declined["error"] = "Yetersiz bakiye"
+	response, err = p.Complete3DPayment(ctx, callbackState, declined)
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected response for a declined callback: %+v", response)
+	}
+
+	missing := callback("1", "GP1", "100.50")
+	delete(missing, "hash_key")
+	if _, err := p.Complete3DPayment(ctx, callbackState, missing); err == nil || !strings.Contains(err.Error(), "missing hash_key") {
+		t.Errorf("Expected a missing hash error, got %v", err)
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState, callback("1", "GP2", "100.50")); err == nil {
+		t.Error("Expected a callback of another invoice to be rejected")
+	}
+	if _, err := p.Complete3DPayment(ctx, callbackState, callback("1", "GP1", "1.00")); err == nil {
+		t.Error("Expected a callback of another amount to be rejected")
+	}
+}
+
+// newTestProvider returns a provider whose API answers each endpoint with the JSON handler
+// returns, counting the token requests
+func newTestProvider(t *testing.T, handler func(endpoint string, body map[string]any) any) (*SipayProvider, *atomic.Int32) {
+	t.Helper()
+	p := providertest.Initialize[*SipayProvider](t, NewProvider, testConfig("sandbox"))
+
+	var tokenRequests atomic.Int32
+	server := providertest.Server(t, providertest.JSON(func(r *http.Request, body map[string]any) (int, any) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/ccpayment")
+		if endpoint == endpointToken {
+			tokenRequests.Add(1)
+			return http.StatusOK, map[string]any{
+				"status_code":        100,
+				"status_description": "Successfully",
+				"data":               map[string]any{"token": "test-token", "is_3d": 2, "expires_at": "2099-01-01 00:00:00"},
+			}
+		}
+
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Expected the bearer token on %s, got %q", endpoint, r.Header.Get("Authorization"))
+		}
+		return http.StatusOK, handler(endpoint, body)
+	}))
+
+	p.baseURL = server.URL + "/ccpayment"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("sipay"))
+	return p, &tokenRequests
+}
+
+func saleData(sipayStatus int, errorCode any, errorMessage string) map[string]any {
+	return map[string]any{
+		"sipay_status":         sipayStatus,
+		"order_no":             "VP1001",
+		"order_id":             "VP1001",
+		"invoice_id":           "GP1",
+		"sipay_payment_method": 1,
+		"credit_card_no":       "450803****4509",
+		"transaction_type":     "Auth",
+		"payment_status":       sipayStatus,
+		"payment_method":       1,
+		"error_code":           errorCode,
+		"error":                errorMessage,
+		"auth_code":            "123456",
+		"hash_key":             "",
+	}
+}
+
+func TestSipayProvider_CreatePayment(t *testing.T) {
+	var sale map[string]any
+	p, tokenRequests := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		if endpoint != endpointPay2D {
+			t.Errorf("Unexpected endpoint %s", endpoint)
+		}
+		sale = body
+		if body["cc_no"] == "4111111111111111" {
+			return map[string]any{"status_code": 41, "status_description": "Kart limiti yetersiz", "data": saleData(0, 51, "Yetersiz bakiye")}
+		}
+		return map[string]any{"status_code": 100, "status_description": "Payment process successful", "data": saleData(1, 100, "")}
+	})
+	ctx := context.Background()
+
+	response, err := p.CreatePayment(ctx, testPaymentRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.TransactionID != "VP1001" || !strings.HasPrefix(response.PaymentID, "GP") {
+		t.Errorf("Unexpected sale response: %+v", response)
+	}
+	if sale["invoice_id"] != response.PaymentID || sale["total"] != "100.50" || sale["merchant_key"] != p.merchantKey {
+		t.Errorf("Unexpected sale request %v", sale)
+	}
+
+	declined := testPaymentRequest()
+	declined.CardInfo.CardNumber = "4111111111111111"
+	response, err = p.CreatePayment(ctx, declined)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if response.Success || response.Status != provider.StatusFailed || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Unexpected declined sale response: %+v", response)
+	}
+
+	// The token is reused until it expires
+	if got := tokenRequests.Load(); got != 1 {
+		t.Errorf("Expected a single token request, got %d", got)
+	}
+}
+
+func TestSipayProvider_RefundPayment(t *testing.T) {
+	var refund map[string]any
+	p, _ := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		refund = body
+		if body["amount"] == "999.99" {
+			return map[string]any{"status_code": 45, "status_description": "Refund amount exceeds the sale", "order_no": "", "invoice_id": "GP1", "ref_no": ""}
+		}
+		return map[string]any{"status_code": 100, "status_description": "Refund completed", "order_no": "VP1001", "invoice_id": "GP1", "ref_no": "R1"}
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 25, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund["invoice_id"] != "GP1" || refund["amount"] != "25.00" {
+		t.Errorf("Unexpected refund request %v", refund)
+	}
+	if decrypted, err := p.decryptHashKey(refund["hash_key"].(string)); err != nil || decrypted != "25.00|GP1|"+p.merchantKey {
+		t.Errorf("Unexpected refund hash_key %q (%v)", decrypted, err)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "VP1001" {
+		t.Errorf("Unexpected refund response: %+v", response)
+	}
+
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 999.99, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if response.Success || response.ErrorCode != "45" {
+		t.Errorf("Unexpected declined refund response: %+v", response)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error for a refund without amount")
+	}
+}
+
+func TestSipayProvider_GetPaymentStatus(t *testing.T) {
+	p, _ := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		status := map[string]any{
+			"status_code":        100,
+			"status_description": "Successfully",
+			"order_id":           "VP1001",
+			"transaction_id":     "T1001",
+			"message":            "",
+			"reason":             "",
+			"sale_web_hook_key":  "",
+			"recurring_id":       "",
+		}
+		switch body["invoice_id"] {
+		case "GP-REFUNDED":
+			status["transaction_status"] = "Partial Refunded"
+		case "GP-PENDING":
+			status["transaction_status"] = "Pending"
+		case "GP-FAILED":
+			status["transaction_status"] = "Failed"
+			status["reason"] = "Do not honour"
+		case "GP-MISSING":
+			return map[string]any{"status_code": 31, "status_description": "Order not found"}
+		default:
+			status["transaction_status"] = "Completed"
+		}
+		return status
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		paymentID string
+		status    provider.PaymentStatus
+	}{
+		{"GP1", provider.StatusSuccessful},
+		{"GP-REFUNDED", provider.StatusRefunded},
+		{"GP-PENDING", provider.StatusPending},
+		{"GP-FAILED", provider.StatusFailed},
+	}
+	for _, tt := range tests {
+		response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: tt.paymentID})
+		if err != nil {
+			t.Fatalf("GetPaymentStatus(%s) failed: %v", tt.paymentID, err)
+		}
+		if response.Status != tt.status {
+			t.Errorf("Expected %s for %s, got %s", tt.status, tt.paymentID, response.Status)
+		}
+	}
+
+	response, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: "GP-MISSING"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound for an unknown invoice, got %+v (%v)", response, err)
+	}
+}
+
+func TestSipayProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &SipayProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL+endpointToken {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL+endpointToken {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
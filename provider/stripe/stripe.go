@@ -2,8 +2,10 @@ package stripe
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +24,15 @@ func parseInt64(s string) int64 {
 	return i
 }
 
+// supportedCurrencies lists the Stripe presentment currencies GoPay can charge in. Amounts are
+// always sent in hundredths, so zero-decimal (JPY, KRW, ...) and three-decimal (KWD, BHD, ...)
+// currencies are deliberately left out.
+var supportedCurrencies = []string{
+	"USD", "EUR", "GBP", "TRY", "AUD", "CAD", "CHF", "CNY", "CZK", "DKK",
+	"HKD", "HUF", "ILS", "INR", "MXN", "MYR", "NOK", "NZD", "PHP", "PLN",
+	"RON", "SAR", "SEK", "SGD", "THB", "AED", "BRL", "ZAR", "EGP", "QAR",
+}
+
 // StripeProvider implements the provider.PaymentProvider interface for Stripe
 type StripeProvider struct {
 	client       *stripe.Client
@@ -94,6 +105,17 @@ func (p *StripeProvider) ValidateConfig(config map[string]string) error {
 	return nil
 }
 
+// SupportedCurrencies returns the currencies accepted by Stripe
+func (p *StripeProvider) SupportedCurrencies() []string {
+	return slices.Clone(supportedCurrencies)
+}
+
+// HealthCheckEndpoint returns the Stripe balance endpoint. Stripe uses the same host for
+// test and live mode.
+func (p *StripeProvider) HealthCheckEndpoint() string {
+	return stripe.APIURL + "/v1/balance"
+}
+
 // Initialize sets up the Stripe payment provider with authentication credentials
 func (p *StripeProvider) Initialize(conf map[string]string) error {
 	secretKey := conf["secretKey"]
@@ -125,20 +147,50 @@ func (p *StripeProvider) GetCommission(ctx context.Context, request provider.Com
 func (p *StripeProvider) CreatePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, false); err != nil {
-		return nil, fmt.Errorf("stripe: invalid payment request: %w", err)
+		return nil, fmt.Errorf("stripe: invalid payment request: %w", provider.InvalidRequest(err))
+	}
+
+	return p.processPayment(ctx, request, false, "automatic")
+}
+
+// AuthorizePayment places a hold on the card without capturing the funds
+func (p *StripeProvider) AuthorizePayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	p.logID = request.LogID
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		return nil, fmt.Errorf("stripe: invalid authorize request: %w", provider.InvalidRequest(err))
+	}
+
+	return p.processPayment(ctx, request, false, "manual")
+}
+
+// CapturePayment captures a PaymentIntent previously created by AuthorizePayment
+func (p *StripeProvider) CapturePayment(ctx context.Context, request provider.CaptureRequest) (*provider.PaymentResponse, error) {
+	p.logID = request.LogID
+	if request.PaymentID == "" {
+		return nil, errors.New("stripe: paymentID is required for capture")
+	}
+
+	params := &stripe.PaymentIntentCaptureParams{}
+	if request.Amount > 0 {
+		params.AmountToCapture = stripe.Int64(int64(request.Amount * 100)) // Convert to cents
 	}
 
-	return p.processPayment(ctx, request, false)
+	pi, err := p.client.V1PaymentIntents.Capture(ctx, request.PaymentID, params)
+	if err != nil {
+		return nil, fmt.Errorf("stripe: failed to capture payment intent: %w", err)
+	}
+
+	return p.mapPaymentIntentToResponse(pi), nil
 }
 
 // Create3DPayment starts a 3D secure payment process
 func (p *StripeProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("stripe: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("stripe: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
-	return p.processPayment(ctx, request, true)
+	return p.processPayment(ctx, request, true, "automatic")
 }
 
 // Complete3DPayment completes a 3D secure payment after user authentication
@@ -152,12 +204,18 @@ func (p *StripeProvider) Complete3DPayment(ctx context.Context, callbackState *p
 		ReturnURL: stripe.String(fmt.Sprintf("%s/v1/callback/stripe", p.gopayBaseURL)),
 	}
 
+	params.AddExpand("latest_charge")
+
 	pi, err := p.client.V1PaymentIntents.Confirm(ctx, callbackState.PaymentID, params)
 	if err != nil {
 		return nil, fmt.Errorf("stripe: failed to confirm payment intent: %w", err)
 	}
 
-	return p.mapPaymentIntentToResponse(pi), nil
+	response := p.mapPaymentIntentToResponse(pi)
+	if response.ThreeDS == nil {
+		response.ThreeDS = provider.NewThreeDSResult("")
+	}
+	return response, nil
 }
 
 // GetPaymentStatus retrieves the current status of a payment
@@ -166,7 +224,12 @@ func (p *StripeProvider) GetPaymentStatus(ctx context.Context, request provider.
 		return nil, errors.New("stripe: paymentID is required")
 	}
 
-	pi, err := p.client.V1PaymentIntents.Retrieve(ctx, request.PaymentID, nil)
+	// The balance transaction may not exist yet when the payment is charged, so the
+	// settlement currency is looked up again here
+	params := &stripe.PaymentIntentRetrieveParams{}
+	params.AddExpand("latest_charge.balance_transaction")
+
+	pi, err := p.client.V1PaymentIntents.Retrieve(ctx, request.PaymentID, params)
 	if err != nil {
 		return nil, fmt.Errorf("stripe: failed to get payment intent: %w", err)
 	}
@@ -217,6 +280,11 @@ func (p *StripeProvider) RefundPayment(ctx context.Context, request provider.Ref
 		}
 	}
 
+	// Stripe dedupes on its side as well, which also covers retries after a lost response
+	if request.IdempotencyKey != "" {
+		params.SetIdempotencyKey(request.IdempotencyKey)
+	}
+
 	ref, err := p.client.V1Refunds.Create(ctx, params)
 	if err != nil {
 		return nil, fmt.Errorf("stripe: failed to create refund: %w", err)
@@ -260,16 +328,19 @@ func (p *StripeProvider) validatePaymentRequest(request provider.PaymentRequest,
 		return errors.New("customer email is required")
 	}
 
-	if request.CardInfo.CardNumber == "" {
-		return errors.New("card number is required")
-	}
+	// A wallet token replaces the card details
+	if request.WalletPayment == nil {
+		if request.CardInfo.CardNumber == "" {
+			return errors.New("card number is required")
+		}
 
-	if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
-		return errors.New("card expiry month and year are required")
-	}
+		if request.CardInfo.ExpireMonth == "" || request.CardInfo.ExpireYear == "" {
+			return errors.New("card expiry month and year are required")
+		}
 
-	if request.CardInfo.CVV == "" {
-		return errors.New("card CVV is required")
+		if request.CardInfo.CVV == "" {
+			return errors.New("card CVV is required")
+		}
 	}
 
 	if is3D && request.CallbackURL == "" {
@@ -279,8 +350,67 @@ func (p *StripeProvider) validatePaymentRequest(request provider.PaymentRequest,
 	return nil
 }
 
+var _ provider.WalletPaymentProvider = (*StripeProvider)(nil)
+
+// SupportedWallets returns the wallet tokens Stripe can charge
+func (p *StripeProvider) SupportedWallets() []string {
+	return []string{provider.WalletTypeApplePay, provider.WalletTypeGooglePay}
+}
+
+// createApplePayToken exchanges an Apple Pay payment token for a Stripe card token
+func (p *StripeProvider) createApplePayToken(ctx context.Context, token provider.ApplePayToken) (string, error) {
+	paymentData, err := json.Marshal(token.PaymentData)
+	if err != nil {
+		return "", fmt.Errorf("stripe: failed to encode apple pay token: %w", err)
+	}
+
+	params := &stripe.TokenCreateParams{}
+	params.AddExtra("pk_token", string(paymentData))
+	params.AddExtra("pk_token_instrument_name", token.PaymentMethod.DisplayName)
+	params.AddExtra("pk_token_payment_network", token.PaymentMethod.Network)
+	params.AddExtra("pk_token_transaction_id", token.TransactionIdentifier)
+
+	tok, err := p.client.V1Tokens.Create(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("stripe: failed to create apple pay token: %w", err)
+	}
+	return tok.ID, nil
+}
+
+// googlePayTokenID returns the Stripe card token inside a Google Pay gateway token. The token
+// must have been created in the same mode (live or test) as the provider.
+func (p *StripeProvider) googlePayTokenID(data *provider.GooglePayPaymentData) (string, error) {
+	if data.PaymentMethodData.TokenizationData.Type != provider.GooglePayTokenizationGateway {
+		return "", fmt.Errorf("%w: stripe only accepts PAYMENT_GATEWAY Google Pay tokens", provider.ErrWalletPaymentUnsupported)
+	}
+
+	var token struct {
+		ID       string `json:"id"`
+		LiveMode bool   `json:"livemode"`
+	}
+	if err := json.Unmarshal([]byte(data.PaymentMethodData.TokenizationData.Token), &token); err != nil || !strings.HasPrefix(token.ID, "tok_") {
+		return "", fmt.Errorf("%w: not a stripe Google Pay token", provider.ErrWalletPaymentInvalid)
+	}
+	if token.LiveMode != p.isProduction {
+		return "", fmt.Errorf("%w: Google Pay token was created in another stripe mode", provider.ErrWalletPaymentInvalid)
+	}
+	return token.ID, nil
+}
+
+// walletTokenID returns the Stripe card token of a wallet payment
+func (p *StripeProvider) walletTokenID(ctx context.Context, wallet *provider.WalletPayment) (string, error) {
+	switch wallet.Type {
+	case provider.WalletTypeApplePay:
+		return p.createApplePayToken(ctx, *wallet.Token)
+	case provider.WalletTypeGooglePay:
+		return p.googlePayTokenID(wallet.GooglePay)
+	}
+	return "", provider.ErrWalletPaymentUnsupported
+}
+
 // Helper method to process a payment
-func (p *StripeProvider) processPayment(ctx context.Context, request provider.PaymentRequest, force3D bool) (*provider.PaymentResponse, error) {
+// captureMethod is "automatic" for a sale or "manual" to only authorize.
+func (p *StripeProvider) processPayment(ctx context.Context, request provider.PaymentRequest, force3D bool, captureMethod string) (*provider.PaymentResponse, error) {
 	// Step 1: Create PaymentMethod
 	pmParams := &stripe.PaymentMethodCreateParams{
 		Type: stripe.String("card"),
@@ -296,6 +426,15 @@ func (p *StripeProvider) processPayment(ctx context.Context, request provider.Pa
 		},
 	}
 
+	// Stripe decrypts wallet tokens itself; the card is the token it returns
+	if request.WalletPayment != nil {
+		tokenID, err := p.walletTokenID(ctx, request.WalletPayment)
+		if err != nil {
+			return nil, err
+		}
+		pmParams.Card = &stripe.PaymentMethodCreateCardParams{Token: stripe.String(tokenID)}
+	}
+
 	// Add address if available
 	if request.Customer.Address != nil && request.Customer.Address.Address != "" {
 		pmParams.BillingDetails.Address = &stripe.AddressParams{
@@ -317,7 +456,7 @@ func (p *StripeProvider) processPayment(ctx context.Context, request provider.Pa
 		Currency:           stripe.String(strings.ToLower(request.Currency)),
 		PaymentMethod:      stripe.String(pm.ID),
 		ConfirmationMethod: stripe.String("manual"),
-		CaptureMethod:      stripe.String("automatic"),
+		CaptureMethod:      stripe.String(captureMethod),
 		// Only accept card payments
 		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
 		Metadata: map[string]string{
@@ -366,6 +505,10 @@ func (p *StripeProvider) processPayment(ctx context.Context, request provider.Pa
 			confirmParams.ReturnURL = stripe.String(returnURL)
 		}
 
+		// The charge carries the card brand, funding and country for PaymentMethodDetails, and
+		// its balance transaction the settlement currency
+		confirmParams.AddExpand("latest_charge.balance_transaction")
+
 		pi, err = p.client.V1PaymentIntents.Confirm(ctx, pi.ID, confirmParams)
 		if err != nil {
 			return nil, fmt.Errorf("stripe: failed to confirm payment intent: %w", err)
@@ -408,15 +551,61 @@ func (p *StripeProvider) processPayment(ctx context.Context, request provider.Pa
 	return p.mapPaymentIntentToResponse(pi), nil
 }
 
+// chargePaymentMethodDetails reads the card of an expanded charge. It returns nil when the
+// charge was not expanded or was not a card payment.
+func chargePaymentMethodDetails(charge *stripe.Charge) *provider.PaymentMethodDetails {
+	if charge.PaymentMethodDetails == nil || charge.PaymentMethodDetails.Card == nil {
+		return nil
+	}
+
+	card := charge.PaymentMethodDetails.Card
+	details := &provider.PaymentMethodDetails{
+		Type:          provider.PaymentMethodTypeCard,
+		Brand:         provider.NormalizeCardBrand(string(card.Brand)),
+		FundingType:   provider.NormalizeFundingType(string(card.Funding)),
+		Network:       provider.NormalizeCardBrand(string(card.Network)),
+		IssuerCountry: card.Country,
+	}
+	if details.Network == "" {
+		details.Network = details.Brand
+	}
+	return details
+}
+
+// chargeThreeDSResult reads the 3D secure authentication of an expanded charge. It returns
+// nil when the card payment was not authenticated with 3D secure.
+func chargeThreeDSResult(charge *stripe.Charge) *provider.ThreeDSResult {
+	if charge.PaymentMethodDetails == nil || charge.PaymentMethodDetails.Card == nil ||
+		charge.PaymentMethodDetails.Card.ThreeDSecure == nil {
+		return nil
+	}
+	return provider.NewThreeDSResult(charge.PaymentMethodDetails.Card.ThreeDSecure.Version)
+}
+
+// chargeSettlement reads the settlement currency and amount from the balance transaction of
+// an expanded charge. It returns "" when the balance transaction was not expanded or the
+// charge settles in the currency it was made in.
+func chargeSettlement(charge *stripe.Charge) (string, float64) {
+	balance := charge.BalanceTransaction
+	if balance == nil || balance.Currency == "" || balance.Currency == charge.Currency {
+		return "", 0
+	}
+
+	currency := strings.ToUpper(string(balance.Currency))
+	return currency, provider.FromMinorUnits(balance.Amount, currency)
+}
+
 // Helper method to map Stripe PaymentIntent to our PaymentResponse
 func (p *StripeProvider) mapPaymentIntentToResponse(pi *stripe.PaymentIntent) *provider.PaymentResponse {
 	now := time.Now()
+	livemode := pi.Livemode
 	response := &provider.PaymentResponse{
 		PaymentID:        pi.ID,
 		Amount:           float64(pi.Amount) / 100, // Convert from cents
 		Currency:         strings.ToUpper(string(pi.Currency)),
 		SystemTime:       &now,
 		ProviderResponse: pi,
+		LiveMode:         &livemode,
 	}
 
 	// Map Stripe status to our common status
@@ -434,7 +623,11 @@ func (p *StripeProvider) mapPaymentIntentToResponse(pi *stripe.PaymentIntent) *p
 		if pi.NextAction != nil && pi.NextAction.RedirectToURL != nil {
 			response.RedirectURL = pi.NextAction.RedirectToURL.URL
 		}
-	case stripe.PaymentIntentStatusProcessing, stripe.PaymentIntentStatusRequiresCapture:
+	case stripe.PaymentIntentStatusRequiresCapture:
+		response.Success = true
+		response.Status = provider.StatusAuthorized
+		response.Message = "Payment authorized, awaiting capture"
+	case stripe.PaymentIntentStatusProcessing:
 		response.Success = true
 		response.Status = provider.StatusProcessing
 		response.Message = "Payment is being processed"
@@ -455,6 +648,9 @@ func (p *StripeProvider) mapPaymentIntentToResponse(pi *stripe.PaymentIntent) *p
 	// Extract transaction ID - we'll use the latest charge ID if available
 	if pi.LatestCharge != nil {
 		response.TransactionID = pi.LatestCharge.ID
+		response.PaymentMethodDetails = chargePaymentMethodDetails(pi.LatestCharge)
+		response.ThreeDS = chargeThreeDSResult(pi.LatestCharge)
+		response.SettlementCurrency, response.SettlementAmount = chargeSettlement(pi.LatestCharge)
 	}
 
 	return response
@@ -1,8 +1,16 @@
 package stripe
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/stripe/stripe-go/v82"
 )
 
 func TestStripeProvider_GetRequiredConfig(t *testing.T) {
@@ -194,3 +202,262 @@ func TestStripeProvider_ValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestStripeProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &StripeProvider{}
+	if got := p.HealthCheckEndpoint(); got != "https://api.stripe.com/v1/balance" {
+		t.Errorf("Expected balance endpoint, got %s", got)
+	}
+}
+
+func TestChargePaymentMethodDetails(t *testing.T) {
+	charge := &stripe.Charge{
+		ID: "ch_123",
+		PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+			Card: &stripe.ChargePaymentMethodDetailsCard{
+				Brand:   "visa",
+				Funding: "debit",
+				Network: "visa",
+				Country: "US",
+			},
+		},
+	}
+
+	expected := provider.PaymentMethodDetails{
+		Type:          provider.PaymentMethodTypeCard,
+		Brand:         "visa",
+		FundingType:   provider.FundingDebit,
+		Network:       "visa",
+		IssuerCountry: "US",
+	}
+	if got := chargePaymentMethodDetails(charge); got == nil || *got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	// A charge that was not expanded only carries its ID
+	if got := chargePaymentMethodDetails(&stripe.Charge{ID: "ch_123"}); got != nil {
+		t.Errorf("Expected no details for an unexpanded charge, got %+v", got)
+	}
+}
+
+func TestChargeThreeDSResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		charge   *stripe.Charge
+		expected *provider.ThreeDSResult
+	}{
+		{
+			name: "3DS2",
+			charge: &stripe.Charge{PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+				Card: &stripe.ChargePaymentMethodDetailsCard{
+					ThreeDSecure: &stripe.ChargePaymentMethodDetailsCardThreeDSecure{Version: "2.2.0"},
+				},
+			}},
+			expected: &provider.ThreeDSResult{Version: "2.2.0"},
+		},
+		{
+			name: "3DS1",
+			charge: &stripe.Charge{PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+				Card: &stripe.ChargePaymentMethodDetailsCard{
+					ThreeDSecure: &stripe.ChargePaymentMethodDetailsCardThreeDSecure{Version: "1.0.2"},
+				},
+			}},
+			expected: &provider.ThreeDSResult{Version: "1.0.2", Legacy: true},
+		},
+		{
+			name: "version not reported",
+			charge: &stripe.Charge{PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+				Card: &stripe.ChargePaymentMethodDetailsCard{
+					ThreeDSecure: &stripe.ChargePaymentMethodDetailsCardThreeDSecure{},
+				},
+			}},
+			expected: &provider.ThreeDSResult{Version: provider.ThreeDSVersionUnknown},
+		},
+		{
+			name: "not authenticated",
+			charge: &stripe.Charge{PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+				Card: &stripe.ChargePaymentMethodDetailsCard{Brand: "visa"},
+			}},
+		},
+		{
+			name:   "unexpanded charge",
+			charge: &stripe.Charge{ID: "ch_123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chargeThreeDSResult(tt.charge)
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestChargeSettlement(t *testing.T) {
+	// Charged in EUR, settled to a USD account
+	charge := &stripe.Charge{
+		Currency:           stripe.CurrencyEUR,
+		BalanceTransaction: &stripe.BalanceTransaction{Amount: 10842, Currency: stripe.CurrencyUSD, ExchangeRate: 1.0842},
+	}
+	currency, amount := chargeSettlement(charge)
+	if currency != "USD" || amount != 108.42 {
+		t.Errorf("Expected settlement of 108.42 USD, got %v %s", amount, currency)
+	}
+
+	// Settled in a zero-decimal currency
+	charge.BalanceTransaction = &stripe.BalanceTransaction{Amount: 15900, Currency: stripe.CurrencyJPY}
+	if currency, amount := chargeSettlement(charge); currency != "JPY" || amount != 15900 {
+		t.Errorf("Expected settlement of 15900 JPY, got %v %s", amount, currency)
+	}
+
+	// Settled in the charge currency
+	charge.BalanceTransaction = &stripe.BalanceTransaction{Amount: 10000, Currency: stripe.CurrencyEUR}
+	if currency, _ := chargeSettlement(charge); currency != "" {
+		t.Errorf("Expected no settlement currency for a same-currency charge, got %s", currency)
+	}
+
+	// Balance transaction not created or not expanded yet
+	if currency, _ := chargeSettlement(&stripe.Charge{ID: "ch_123", Currency: stripe.CurrencyEUR}); currency != "" {
+		t.Errorf("Expected no settlement currency without a balance transaction, got %s", currency)
+	}
+}
+
+func TestStripeProvider_GetPaymentStatus_Settlement(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("expand[0]") != "latest_charge.balance_transaction" {
+			t.Errorf("Expected the balance transaction to be expanded, got query %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "pi_123",
+			"object": "payment_intent",
+			"amount": 10000,
+			"currency": "eur",
+			"status": "succeeded",
+			"latest_charge": {
+				"id": "ch_123",
+				"object": "charge",
+				"currency": "eur",
+				"balance_transaction": {"id": "txn_123", "object": "balance_transaction", "amount": 10842, "currency": "usd", "exchange_rate": 1.0842}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	backends := stripe.NewBackendsWithConfig(&stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+		LeveledLogger:     &stripe.LeveledLogger{Level: stripe.LevelNull},
+	})
+	p := &StripeProvider{client: stripe.NewClient("sk_test_123", stripe.WithBackends(backends))}
+
+	resp, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "pi_123"})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if resp.Currency != "EUR" || resp.Amount != 100 {
+		t.Errorf("Expected a charge of 100 EUR, got %v %s", resp.Amount, resp.Currency)
+	}
+	if resp.SettlementCurrency != "USD" || resp.SettlementAmount != 108.42 {
+		t.Errorf("Expected settlement of 108.42 USD, got %v %s", resp.SettlementAmount, resp.SettlementCurrency)
+	}
+}
+
+func TestStripeProvider_mapPaymentIntentToResponse_LiveMode(t *testing.T) {
+	p := &StripeProvider{}
+
+	for _, livemode := range []bool{true, false} {
+		pi := &stripe.PaymentIntent{ID: "pi_123", Status: stripe.PaymentIntentStatusSucceeded, Livemode: livemode}
+		resp := p.mapPaymentIntentToResponse(pi)
+		if resp.LiveMode == nil || *resp.LiveMode != livemode {
+			t.Errorf("Expected liveMode %v from the payment intent, got %v", livemode, resp.LiveMode)
+		}
+
+		// The response keeps its own value when the intent is reused
+		pi.Livemode = !livemode
+		if *resp.LiveMode != livemode {
+			t.Errorf("Expected liveMode to be copied from the payment intent")
+		}
+	}
+}
+
+func TestStripeProvider_ApplePayToken(t *testing.T) {
+	var tokenForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/tokens" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		tokenForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "tok_applepay", "object": "token"}`))
+	}))
+	defer server.Close()
+
+	backends := stripe.NewBackendsWithConfig(&stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+		LeveledLogger:     &stripe.LeveledLogger{Level: stripe.LevelNull},
+	})
+	p := &StripeProvider{client: stripe.NewClient("sk_test_123", stripe.WithBackends(backends))}
+
+	request := provider.PaymentRequest{
+		TenantID: 1,
+		Amount:   100,
+		Currency: "USD",
+		Customer: provider.Customer{Name: "Ada", Surname: "Lovelace", Email: "ada@example.com"},
+		WalletPayment: &provider.WalletPayment{
+			Type: provider.WalletTypeApplePay,
+			Token: &provider.ApplePayToken{
+				PaymentData:           provider.ApplePayPaymentData{Version: "EC_v1", Data: "ZGF0YQ==", Header: provider.ApplePayPaymentHeader{EphemeralPublicKey: "a2V5"}},
+				PaymentMethod:         provider.ApplePayPaymentMethod{DisplayName: "Visa 0121", Network: "Visa", Type: "debit"},
+				TransactionIdentifier: "txn_1",
+			},
+		},
+	}
+	if err := p.validatePaymentRequest(request, false); err != nil {
+		t.Errorf("Expected a wallet payment without card details to be valid, got %v", err)
+	}
+
+	tokenID, err := p.createApplePayToken(context.Background(), *request.WalletPayment.Token)
+	if err != nil {
+		t.Fatalf("createApplePayToken failed: %v", err)
+	}
+	if tokenID != "tok_applepay" {
+		t.Errorf("Expected tok_applepay, got %s", tokenID)
+	}
+	if !strings.Contains(tokenForm.Get("pk_token"), `"version":"EC_v1"`) || tokenForm.Get("pk_token_payment_network") != "Visa" ||
+		tokenForm.Get("pk_token_instrument_name") != "Visa 0121" || tokenForm.Get("pk_token_transaction_id") != "txn_1" {
+		t.Errorf("Expected the Apple Pay token to be forwarded, got %v", tokenForm)
+	}
+}
+
+func TestStripeProvider_GooglePayTokenID(t *testing.T) {
+	googlePay := func(tokenizationType, token string) *provider.GooglePayPaymentData {
+		data := &provider.GooglePayPaymentData{Environment: provider.GooglePayEnvironmentTest}
+		data.PaymentMethodData.Type = "CARD"
+		data.PaymentMethodData.TokenizationData.Type = tokenizationType
+		data.PaymentMethodData.TokenizationData.Token = token
+		return data
+	}
+
+	p := &StripeProvider{}
+	tokenID, err := p.googlePayTokenID(googlePay(provider.GooglePayTokenizationGateway, `{"id": "tok_googlepay", "object": "token", "livemode": false}`))
+	if err != nil || tokenID != "tok_googlepay" {
+		t.Errorf("Expected tok_googlepay, got %q (%v)", tokenID, err)
+	}
+
+	if _, err := p.googlePayTokenID(googlePay(provider.GooglePayTokenizationDirect, `{"protocolVersion": "ECv2"}`)); !errors.Is(err, provider.ErrWalletPaymentUnsupported) {
+		t.Errorf("Expected ErrWalletPaymentUnsupported for a DIRECT token, got %v", err)
+	}
+	if _, err := p.googlePayTokenID(googlePay(provider.GooglePayTokenizationGateway, `{"id": "pm_123"}`)); !errors.Is(err, provider.ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for another gateway's token, got %v", err)
+	}
+
+	live := &StripeProvider{isProduction: true}
+	if _, err := live.googlePayTokenID(googlePay(provider.GooglePayTokenizationGateway, `{"id": "tok_googlepay", "livemode": false}`)); !errors.Is(err, provider.ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for a test token in production, got %v", err)
+	}
+}
@@ -0,0 +1,457 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// Subscriptions are recurring charges of a saved card, scheduled by GoPay: each charge is a
+// regular saved-card payment (CardStorageProvider.PayWithSavedCard) made when the subscription
+// is due, so every provider with card storage supports them. The core PaymentProvider interface
+// is not extended.
+
+const (
+	SubscriptionStatusActive    = "active"
+	SubscriptionStatusPastDue   = "past_due"
+	SubscriptionStatusUnpaid    = "unpaid"
+	SubscriptionStatusCancelled = "cancelled"
+
+	SubscriptionIntervalDay   = "day"
+	SubscriptionIntervalWeek  = "week"
+	SubscriptionIntervalMonth = "month"
+	SubscriptionIntervalYear  = "year"
+
+	subscriptionBatchSize = 50
+	// subscriptionClaimLease is how long a claimed charge is hidden from other GoPay instances
+	subscriptionClaimLease = 15 * time.Minute
+	// subscriptionMaxAttempts is how often a failed charge is tried before the subscription is
+	// marked unpaid
+	subscriptionMaxAttempts = 3
+	subscriptionRetryDelay  = 24 * time.Hour
+)
+
+var (
+	// ErrSubscriptionNotFound is returned for a subscription that does not exist for the tenant
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionInvalid is returned for a subscription request that cannot be scheduled
+	ErrSubscriptionInvalid = errors.New("invalid subscription")
+)
+
+// Subscription charges a saved card every IntervalCount Intervals
+type Subscription struct {
+	ID            string     `json:"id"`
+	TenantID      int        `json:"tenantId"`
+	Provider      string     `json:"provider"`
+	Environment   string     `json:"environment"`
+	CardID        int        `json:"cardId"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Interval      string     `json:"interval"`
+	IntervalCount int        `json:"intervalCount"`
+	Status        string     `json:"status"`
+	NextChargeAt  time.Time  `json:"nextChargeAt"`
+	ChargeCount   int        `json:"chargeCount"`
+	Attempts      int        `json:"attempts"`
+	LastPaymentID string     `json:"lastPaymentId,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	CancelledAt   *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// CreateSubscriptionRequest starts a subscription. The first charge is made at StartAt, or
+// right away when it is not set.
+type CreateSubscriptionRequest struct {
+	CardID        int        `json:"cardId"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Interval      string     `json:"interval"`
+	IntervalCount int        `json:"intervalCount,omitempty"`
+	StartAt       *time.Time `json:"startAt,omitempty"`
+}
+
+// nextChargeAfter returns the charge date one period after at. Months are added to the
+// calendar date, so a subscription started on the 31st is charged on the 1st of shorter months.
+func nextChargeAfter(at time.Time, interval string, count int) time.Time {
+	switch interval {
+	case SubscriptionIntervalDay:
+		return at.AddDate(0, 0, count)
+	case SubscriptionIntervalWeek:
+		return at.AddDate(0, 0, 7*count)
+	case SubscriptionIntervalYear:
+		return at.AddDate(count, 0, 0)
+	default:
+		return at.AddDate(0, count, 0)
+	}
+}
+
+// SubscriptionStore persists subscriptions so their schedule survives restarts
+type SubscriptionStore interface {
+	Create(ctx context.Context, subscription *Subscription) error
+	Get(ctx context.Context, tenantID int, id string) (*Subscription, error)
+	List(ctx context.Context, tenantID int) ([]Subscription, error)
+
+	// Cancel cancels an active or past due subscription. It returns ErrSubscriptionNotFound
+	// when the tenant has no such subscription left to cancel.
+	Cancel(ctx context.Context, tenantID int, id string) error
+
+	// ClaimDue hides up to limit subscriptions due at now from other claims until leaseUntil
+	// and returns them with their due NextChargeAt
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Subscription, error)
+
+	// Charged records a successful charge and the next charge date
+	Charged(ctx context.Context, id string, paymentID string, nextChargeAt time.Time) error
+
+	// ChargeFailed records a failed charge with the subscription's new status and, for a
+	// retry, when it is charged again
+	ChargeFailed(ctx context.Context, id string, status string, nextChargeAt time.Time, lastError string) error
+}
+
+// StoredCardCharger looks up and charges saved cards for subscriptions; CardService implements it
+type StoredCardCharger interface {
+	// SubscribableCard returns the tenant's saved card when it can be charged by providerName
+	// in environment
+	SubscribableCard(ctx context.Context, tenantID int, environment, providerName string, cardRowID int) (*SavedCard, error)
+
+	// ChargeStoredCard charges a saved card without 3D secure, outside of a request
+	ChargeStoredCard(ctx context.Context, tenantID int, environment, providerName string, cardRowID int, request SavedCardPaymentRequest) (*PaymentResponse, error)
+}
+
+// SubscriptionService creates subscriptions and charges them when they are due
+type SubscriptionService struct {
+	store SubscriptionStore
+	cards StoredCardCharger
+	now   func() time.Time
+}
+
+// NewSubscriptionService creates a subscription service
+func NewSubscriptionService(store SubscriptionStore, cards StoredCardCharger) *SubscriptionService {
+	return &SubscriptionService{store: store, cards: cards, now: time.Now}
+}
+
+// CreateSubscription schedules the recurring charges of one of the tenant's saved cards
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, environment, providerName string, request CreateSubscriptionRequest) (*Subscription, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := strings.ToLower(strings.TrimSpace(request.Interval))
+	switch interval {
+	case SubscriptionIntervalDay, SubscriptionIntervalWeek, SubscriptionIntervalMonth, SubscriptionIntervalYear:
+	default:
+		return nil, fmt.Errorf("%w: interval must be day, week, month or year", ErrSubscriptionInvalid)
+	}
+	if request.IntervalCount == 0 {
+		request.IntervalCount = 1
+	}
+	if request.IntervalCount < 0 || request.IntervalCount > 12 {
+		return nil, fmt.Errorf("%w: intervalCount must be between 1 and 12", ErrSubscriptionInvalid)
+	}
+	if request.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrSubscriptionInvalid)
+	}
+	currency := strings.ToUpper(strings.TrimSpace(request.Currency))
+	if len(currency) != 3 {
+		return nil, fmt.Errorf("%w: currency must be an ISO 4217 code", ErrSubscriptionInvalid)
+	}
+
+	// Tenant-scoped lookup is the IDOR/BOLA guard: a tenant can only subscribe its own card.
+	if _, err := s.cards.SubscribableCard(ctx, tenantID, environment, providerName, request.CardID); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	nextChargeAt := now
+	if request.StartAt != nil && request.StartAt.After(now) {
+		nextChargeAt = *request.StartAt
+	}
+
+	subscription := &Subscription{
+		ID:            "sub" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		TenantID:      tenantID,
+		Provider:      providerName,
+		Environment:   environment,
+		CardID:        request.CardID,
+		Amount:        request.Amount,
+		Currency:      currency,
+		Interval:      interval,
+		IntervalCount: request.IntervalCount,
+		Status:        SubscriptionStatusActive,
+		NextChargeAt:  nextChargeAt,
+		CreatedAt:     now,
+	}
+	if err := s.store.Create(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// CancelSubscription stops the future charges of a subscription
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, id string) (*Subscription, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Cancel(ctx, tenantID, id); err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, tenantID, id)
+}
+
+// GetSubscription returns one of the tenant's subscriptions
+func (s *SubscriptionService) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, tenantID, id)
+}
+
+// ListSubscriptions returns the tenant's subscriptions
+func (s *SubscriptionService) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.List(ctx, tenantID)
+}
+
+// RunDue charges every subscription that is due and returns how many were charged
+func (s *SubscriptionService) RunDue(ctx context.Context) (int, error) {
+	now := s.now()
+	subscriptions, err := s.store.ClaimDue(ctx, now, now.Add(subscriptionClaimLease), subscriptionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	charged := 0
+	for _, subscription := range subscriptions {
+		if s.charge(ctx, subscription) {
+			charged++
+		}
+	}
+	return charged, nil
+}
+
+// charge makes the due charge of a subscription and records its result
+func (s *SubscriptionService) charge(ctx context.Context, subscription Subscription) bool {
+	resp, err := s.cards.ChargeStoredCard(ctx, subscription.TenantID, subscription.Environment, subscription.Provider, subscription.CardID, SavedCardPaymentRequest{
+		Amount:         subscription.Amount,
+		Currency:       subscription.Currency,
+		ConversationID: fmt.Sprintf("%s-%d", subscription.ID, subscription.ChargeCount+1),
+		SubscriptionID: subscription.ID,
+	})
+	if err == nil && (resp == nil || !resp.Success) {
+		err = errors.New("charge was not successful")
+		if resp != nil && resp.Message != "" {
+			err = fmt.Errorf("charge was not successful: %s", resp.Message)
+		}
+	}
+
+	logContext := logger.LogContext{
+		TenantID: strconv.Itoa(subscription.TenantID),
+		Provider: subscription.Provider,
+		Fields: map[string]any{
+			"subscription_id": subscription.ID,
+		},
+	}
+
+	if err == nil {
+		next := nextChargeAfter(subscription.NextChargeAt, subscription.Interval, subscription.IntervalCount)
+		if recordErr := s.store.Charged(ctx, subscription.ID, resp.PaymentID, next); recordErr != nil {
+			logContext.Fields["payment_id"] = resp.PaymentID
+			logContext.Fields["error"] = recordErr.Error()
+			logger.Warn("Failed to record subscription charge", logContext)
+		}
+		return true
+	}
+
+	attempts := subscription.Attempts + 1
+	logContext.Fields["attempts"] = attempts
+	logContext.Fields["error"] = err.Error()
+	logger.Warn("Subscription charge failed", logContext)
+
+	// A card that needs updating will not be charged by retrying
+	status, next := SubscriptionStatusPastDue, s.now().Add(subscriptionRetryDelay)
+	if attempts >= subscriptionMaxAttempts || (resp != nil && resp.CardUpdateRequired) {
+		status, next = SubscriptionStatusUnpaid, subscription.NextChargeAt
+	}
+	if recordErr := s.store.ChargeFailed(ctx, subscription.ID, status, next, err.Error()); recordErr != nil {
+		logContext.Fields["error"] = recordErr.Error()
+		logger.Warn("Failed to record failed subscription charge", logContext)
+	}
+	return false
+}
+
+// Start charges due subscriptions every interval until ctx is done
+func (s *SubscriptionService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			if _, err := s.RunDue(runCtx); err != nil {
+				logger.Warn("Failed to run subscription charges", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+			cancel()
+		}
+	}
+}
+
+// PostgresSubscriptionStore keeps subscriptions in the subscriptions table
+type PostgresSubscriptionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresSubscriptionStore creates a store over the shared *sql.DB connection
+func NewPostgresSubscriptionStore(db *sql.DB) *PostgresSubscriptionStore {
+	return &PostgresSubscriptionStore{db: db}
+}
+
+const subscriptionColumns = `id, tenant_id, provider, environment, card_id, amount, currency, interval, interval_count,
+		status, next_charge_at, charge_count, attempts, COALESCE(last_payment_id, ''), COALESCE(last_error, ''),
+		created_at, cancelled_at`
+
+// Create inserts a subscription
+func (r *PostgresSubscriptionStore) Create(ctx context.Context, subscription *Subscription) error {
+	query := `
+		INSERT INTO subscriptions (id, tenant_id, provider, environment, card_id, amount, currency, interval, interval_count, status, next_charge_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := r.db.ExecContext(ctx, query, subscription.ID, subscription.TenantID, subscription.Provider, subscription.Environment,
+		subscription.CardID, subscription.Amount, subscription.Currency, subscription.Interval, subscription.IntervalCount,
+		subscription.Status, subscription.NextChargeAt, subscription.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+	return nil
+}
+
+// Get returns a subscription, scoped to the tenant
+func (r *PostgresSubscriptionStore) Get(ctx context.Context, tenantID int, id string) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1 AND tenant_id = $2`
+	subscription, err := scanSubscription(r.db.QueryRowContext(ctx, query, id, tenantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// List returns the tenant's subscriptions, newest first
+func (r *PostgresSubscriptionStore) List(ctx context.Context, tenantID int) ([]Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE tenant_id = $1 ORDER BY created_at DESC`
+	return r.query(ctx, query, tenantID)
+}
+
+// Cancel cancels an active or past due subscription, scoped to the tenant
+func (r *PostgresSubscriptionStore) Cancel(ctx context.Context, tenantID int, id string) error {
+	query := `
+		UPDATE subscriptions SET status = $3, cancelled_at = now(), updated_at = now()
+		WHERE id = $1 AND tenant_id = $2 AND status IN ($4, $5)`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID, SubscriptionStatusCancelled, SubscriptionStatusActive, SubscriptionStatusPastDue)
+	if err != nil {
+		return fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ClaimDue claims due subscriptions by moving their next charge to leaseUntil. SKIP LOCKED
+// lets several GoPay instances poll the same table without charging a subscription twice.
+func (r *PostgresSubscriptionStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Subscription, error) {
+	query := `
+		WITH due AS (
+			SELECT id, next_charge_at FROM subscriptions
+			WHERE status IN ($2, $3) AND next_charge_at <= $1
+			ORDER BY next_charge_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE subscriptions s SET next_charge_at = $4, updated_at = now()
+		FROM due WHERE s.id = due.id
+		RETURNING s.id, s.tenant_id, s.provider, s.environment, s.card_id, s.amount, s.currency, s.interval, s.interval_count,
+			s.status, due.next_charge_at, s.charge_count, s.attempts, COALESCE(s.last_payment_id, ''), COALESCE(s.last_error, ''),
+			s.created_at, s.cancelled_at`
+
+	return r.query(ctx, query, now, SubscriptionStatusActive, SubscriptionStatusPastDue, leaseUntil, limit)
+}
+
+// Charged records a successful charge
+func (r *PostgresSubscriptionStore) Charged(ctx context.Context, id string, paymentID string, nextChargeAt time.Time) error {
+	query := `
+		UPDATE subscriptions
+		SET status = $2, next_charge_at = $3, last_payment_id = $4, charge_count = charge_count + 1,
+		    attempts = 0, last_error = NULL, updated_at = now()
+		WHERE id = $1 AND status <> $5`
+	if _, err := r.db.ExecContext(ctx, query, id, SubscriptionStatusActive, nextChargeAt, nullString(paymentID), SubscriptionStatusCancelled); err != nil {
+		return fmt.Errorf("failed to record subscription charge: %w", err)
+	}
+	return nil
+}
+
+// ChargeFailed records a failed charge
+func (r *PostgresSubscriptionStore) ChargeFailed(ctx context.Context, id string, status string, nextChargeAt time.Time, lastError string) error {
+	query := `
+		UPDATE subscriptions
+		SET status = $2, next_charge_at = $3, attempts = attempts + 1, last_error = $4, updated_at = now()
+		WHERE id = $1 AND status <> $5`
+	if _, err := r.db.ExecContext(ctx, query, id, status, nextChargeAt, nullString(lastError), SubscriptionStatusCancelled); err != nil {
+		return fmt.Errorf("failed to record failed subscription charge: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresSubscriptionStore) query(ctx context.Context, query string, args ...any) ([]Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+func scanSubscription(row rowScanner) (*Subscription, error) {
+	var s Subscription
+	var cancelledAt sql.NullTime
+	err := row.Scan(&s.ID, &s.TenantID, &s.Provider, &s.Environment, &s.CardID, &s.Amount, &s.Currency, &s.Interval,
+		&s.IntervalCount, &s.Status, &s.NextChargeAt, &s.ChargeCount, &s.Attempts, &s.LastPaymentID, &s.LastError,
+		&s.CreatedAt, &cancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	if cancelledAt.Valid {
+		s.CancelledAt = &cancelledAt.Time
+	}
+	return &s, nil
+}
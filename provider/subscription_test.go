@@ -0,0 +1,348 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memorySubscriptionStore is an in-memory SubscriptionStore for scheduler tests.
+type memorySubscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]*Subscription
+}
+
+func newMemorySubscriptionStore() *memorySubscriptionStore {
+	return &memorySubscriptionStore{subscriptions: make(map[string]*Subscription)}
+}
+
+func (m *memorySubscriptionStore) Create(_ context.Context, subscription *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *subscription
+	m.subscriptions[subscription.ID] = &stored
+	return nil
+}
+
+func (m *memorySubscriptionStore) Get(_ context.Context, tenantID int, id string) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription, ok := m.subscriptions[id]
+	if !ok || subscription.TenantID != tenantID {
+		return nil, ErrSubscriptionNotFound
+	}
+	copied := *subscription
+	return &copied, nil
+}
+
+func (m *memorySubscriptionStore) List(_ context.Context, tenantID int) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscriptions := []Subscription{}
+	for _, subscription := range m.subscriptions {
+		if subscription.TenantID == tenantID {
+			subscriptions = append(subscriptions, *subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (m *memorySubscriptionStore) Cancel(_ context.Context, tenantID int, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription, ok := m.subscriptions[id]
+	if !ok || subscription.TenantID != tenantID || (subscription.Status != SubscriptionStatusActive && subscription.Status != SubscriptionStatusPastDue) {
+		return ErrSubscriptionNotFound
+	}
+	now := time.Now()
+	subscription.Status = SubscriptionStatusCancelled
+	subscription.CancelledAt = &now
+	return nil
+}
+
+func (m *memorySubscriptionStore) ClaimDue(_ context.Context, now, leaseUntil time.Time, limit int) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Subscription
+	for _, subscription := range m.subscriptions {
+		if len(due) >= limit {
+			break
+		}
+		active := subscription.Status == SubscriptionStatusActive || subscription.Status == SubscriptionStatusPastDue
+		if active && !subscription.NextChargeAt.After(now) {
+			due = append(due, *subscription)
+			subscription.NextChargeAt = leaseUntil
+		}
+	}
+	return due, nil
+}
+
+func (m *memorySubscriptionStore) Charged(_ context.Context, id string, paymentID string, nextChargeAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscriptions[id]
+	subscription.Status = SubscriptionStatusActive
+	subscription.NextChargeAt = nextChargeAt
+	subscription.LastPaymentID = paymentID
+	subscription.ChargeCount++
+	subscription.Attempts = 0
+	subscription.LastError = ""
+	return nil
+}
+
+func (m *memorySubscriptionStore) ChargeFailed(_ context.Context, id string, status string, nextChargeAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscriptions[id]
+	subscription.Status = status
+	subscription.NextChargeAt = nextChargeAt
+	subscription.Attempts++
+	subscription.LastError = lastError
+	return nil
+}
+
+// fakeStoredCardCharger serves one saved card and answers charges with resp/err.
+type fakeStoredCardCharger struct {
+	card     SavedCard
+	provider string
+	resp     *PaymentResponse
+	err      error
+	charges  []SavedCardPaymentRequest
+}
+
+func (f *fakeStoredCardCharger) SubscribableCard(_ context.Context, tenantID int, environment, providerName string, cardRowID int) (*SavedCard, error) {
+	if cardRowID != f.card.ID || tenantID != f.card.TenantID || environment != f.card.Environment || providerName != f.provider {
+		return nil, ErrSavedCardNotFound
+	}
+	card := f.card
+	return &card, nil
+}
+
+func (f *fakeStoredCardCharger) ChargeStoredCard(_ context.Context, tenantID int, environment, providerName string, cardRowID int, request SavedCardPaymentRequest) (*PaymentResponse, error) {
+	f.charges = append(f.charges, request)
+	return f.resp, f.err
+}
+
+func newTestSubscriptionService(now time.Time) (*SubscriptionService, *memorySubscriptionStore, *fakeStoredCardCharger) {
+	store := newMemorySubscriptionStore()
+	cards := &fakeStoredCardCharger{
+		card:     SavedCard{ID: 5, TenantID: 7, Environment: "sandbox"},
+		provider: "paycell",
+		resp:     &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_1"},
+	}
+	service := NewSubscriptionService(store, cards)
+	service.now = func() time.Time { return now }
+	return service, store, cards
+}
+
+func subscriptionContext(tenantID string) context.Context {
+	return context.WithValue(context.Background(), tenantctx.IDKey, tenantID)
+}
+
+func TestSubscriptionService_CreateSubscription(t *testing.T) {
+	now := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	service, store, _ := newTestSubscriptionService(now)
+
+	subscription, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID:   5,
+		Amount:   99.9,
+		Currency: "try",
+		Interval: "Month",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if subscription.Status != SubscriptionStatusActive || subscription.Interval != SubscriptionIntervalMonth || subscription.IntervalCount != 1 {
+		t.Errorf("Unexpected subscription: %+v", subscription)
+	}
+	if subscription.Currency != "TRY" || !subscription.NextChargeAt.Equal(now) {
+		t.Errorf("Expected a TRY subscription charged now, got %s at %v", subscription.Currency, subscription.NextChargeAt)
+	}
+	if _, err := store.Get(context.Background(), 7, subscription.ID); err != nil {
+		t.Errorf("Expected the subscription to be stored: %v", err)
+	}
+
+	startAt := now.Add(72 * time.Hour)
+	later, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID: 5, Amount: 10, Currency: "TRY", Interval: "week", StartAt: &startAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if !later.NextChargeAt.Equal(startAt) {
+		t.Errorf("Expected the first charge at %v, got %v", startAt, later.NextChargeAt)
+	}
+}
+
+func TestSubscriptionService_CreateSubscription_Rejected(t *testing.T) {
+	service, _, _ := newTestSubscriptionService(time.Now())
+
+	tests := []struct {
+		name     string
+		tenantID string
+		provider string
+		request  CreateSubscriptionRequest
+		expected error
+	}{
+		{"unknown interval", "7", "paycell", CreateSubscriptionRequest{CardID: 5, Amount: 10, Currency: "TRY", Interval: "hour"}, ErrSubscriptionInvalid},
+		{"interval count too large", "7", "paycell", CreateSubscriptionRequest{CardID: 5, Amount: 10, Currency: "TRY", Interval: "month", IntervalCount: 24}, ErrSubscriptionInvalid},
+		{"zero amount", "7", "paycell", CreateSubscriptionRequest{CardID: 5, Currency: "TRY", Interval: "month"}, ErrSubscriptionInvalid},
+		{"invalid currency", "7", "paycell", CreateSubscriptionRequest{CardID: 5, Amount: 10, Currency: "LIRA", Interval: "month"}, ErrSubscriptionInvalid},
+		{"another tenant's card", "8", "paycell", CreateSubscriptionRequest{CardID: 5, Amount: 10, Currency: "TRY", Interval: "month"}, ErrSavedCardNotFound},
+		{"card of another provider", "7", "iyzico", CreateSubscriptionRequest{CardID: 5, Amount: 10, Currency: "TRY", Interval: "month"}, ErrSavedCardNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateSubscription(subscriptionContext(tt.tenantID), "sandbox", tt.provider, tt.request)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_CancelSubscription(t *testing.T) {
+	service, _, cards := newTestSubscriptionService(time.Now())
+	subscription, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID: 5, Amount: 10, Currency: "TRY", Interval: "month",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	if _, err := service.CancelSubscription(subscriptionContext("8"), subscription.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Errorf("Expected another tenant's cancel to fail with ErrSubscriptionNotFound, got %v", err)
+	}
+
+	cancelled, err := service.CancelSubscription(subscriptionContext("7"), subscription.ID)
+	if err != nil {
+		t.Fatalf("CancelSubscription failed: %v", err)
+	}
+	if cancelled.Status != SubscriptionStatusCancelled || cancelled.CancelledAt == nil {
+		t.Errorf("Expected a cancelled subscription, got %+v", cancelled)
+	}
+
+	if charged, _ := service.RunDue(context.Background()); charged != 0 || len(cards.charges) != 0 {
+		t.Errorf("Expected a cancelled subscription not to be charged, got %d charges", len(cards.charges))
+	}
+}
+
+func TestSubscriptionService_RunDue_Charged(t *testing.T) {
+	now := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	service, store, cards := newTestSubscriptionService(now)
+	subscription, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID: 5, Amount: 49.5, Currency: "TRY", Interval: "month",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	charged, err := service.RunDue(context.Background())
+	if err != nil || charged != 1 {
+		t.Fatalf("Expected 1 charge, got %d (%v)", charged, err)
+	}
+	if len(cards.charges) != 1 {
+		t.Fatalf("Expected the card to be charged once, got %d", len(cards.charges))
+	}
+	charge := cards.charges[0]
+	if charge.Amount != 49.5 || charge.Currency != "TRY" || charge.SubscriptionID != subscription.ID {
+		t.Errorf("Unexpected charge request: %+v", charge)
+	}
+	if charge.ConversationID != subscription.ID+"-1" {
+		t.Errorf("Expected conversation ID %s-1, got %s", subscription.ID, charge.ConversationID)
+	}
+
+	stored, _ := store.Get(context.Background(), 7, subscription.ID)
+	if stored.ChargeCount != 1 || stored.LastPaymentID != "pay_1" || stored.Status != SubscriptionStatusActive {
+		t.Errorf("Unexpected subscription after charge: %+v", stored)
+	}
+	if expected := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC); !stored.NextChargeAt.Equal(expected) {
+		t.Errorf("Expected next charge at %v, got %v", expected, stored.NextChargeAt)
+	}
+
+	if charged, _ := service.RunDue(context.Background()); charged != 0 {
+		t.Errorf("Expected nothing due until the next period, got %d charges", charged)
+	}
+}
+
+func TestSubscriptionService_RunDue_Failed(t *testing.T) {
+	now := time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)
+	service, store, cards := newTestSubscriptionService(now)
+	subscription, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID: 5, Amount: 10, Currency: "TRY", Interval: "month",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	cards.resp = &PaymentResponse{Success: false, Status: StatusFailed, Message: "Insufficient funds"}
+	service.now = func() time.Time { return now }
+
+	for attempt := 1; attempt <= subscriptionMaxAttempts; attempt++ {
+		if charged, err := service.RunDue(context.Background()); err != nil || charged != 0 {
+			t.Fatalf("Attempt %d: expected a failed charge, got %d (%v)", attempt, charged, err)
+		}
+		stored, _ := store.Get(context.Background(), 7, subscription.ID)
+		if stored.Attempts != attempt || stored.LastError == "" {
+			t.Fatalf("Attempt %d: unexpected subscription %+v", attempt, stored)
+		}
+		if attempt < subscriptionMaxAttempts {
+			if stored.Status != SubscriptionStatusPastDue || !stored.NextChargeAt.Equal(now.Add(subscriptionRetryDelay)) {
+				t.Fatalf("Attempt %d: expected a retry tomorrow, got %s at %v", attempt, stored.Status, stored.NextChargeAt)
+			}
+			now = now.Add(subscriptionRetryDelay)
+			continue
+		}
+		if stored.Status != SubscriptionStatusUnpaid {
+			t.Errorf("Expected the subscription to be unpaid after %d attempts, got %s", attempt, stored.Status)
+		}
+	}
+
+	if charged, _ := service.RunDue(context.Background()); charged != 0 || len(cards.charges) != subscriptionMaxAttempts {
+		t.Errorf("Expected an unpaid subscription not to be charged again, got %d charges", len(cards.charges))
+	}
+}
+
+func TestSubscriptionService_RunDue_CardUpdateRequired(t *testing.T) {
+	service, store, cards := newTestSubscriptionService(time.Now())
+	subscription, err := service.CreateSubscription(subscriptionContext("7"), "sandbox", "paycell", CreateSubscriptionRequest{
+		CardID: 5, Amount: 10, Currency: "TRY", Interval: "month",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	cards.resp = &PaymentResponse{Success: false, Status: StatusFailed, ErrorCode: ErrorCodeCardExpired, CardUpdateRequired: true}
+
+	_, _ = service.RunDue(context.Background())
+
+	stored, _ := store.Get(context.Background(), 7, subscription.ID)
+	if stored.Status != SubscriptionStatusUnpaid || stored.Attempts != 1 {
+		t.Errorf("Expected a card that needs updating to leave the subscription unpaid, got %+v", stored)
+	}
+}
+
+func TestNextChargeAfter(t *testing.T) {
+	start := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		count    int
+		expected time.Time
+	}{
+		{SubscriptionIntervalDay, 1, time.Date(2024, 1, 16, 9, 30, 0, 0, time.UTC)},
+		{SubscriptionIntervalWeek, 2, time.Date(2024, 1, 29, 9, 30, 0, 0, time.UTC)},
+		{SubscriptionIntervalMonth, 1, time.Date(2024, 2, 15, 9, 30, 0, 0, time.UTC)},
+		{SubscriptionIntervalMonth, 3, time.Date(2024, 4, 15, 9, 30, 0, 0, time.UTC)},
+		{SubscriptionIntervalYear, 1, time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := nextChargeAfter(start, tt.interval, tt.count); !got.Equal(tt.expected) {
+			t.Errorf("nextChargeAfter(%s, %d): expected %v, got %v", tt.interval, tt.count, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+)
+
+// ErrSurchargeNotAllowed is returned when a payment's surcharge is negative, not enabled for
+// the provider, or above the configured cap
+var ErrSurchargeNotAllowed = errors.New("surcharge not allowed")
+
+// SurchargeRules caps the surcharge a merchant may add to a card payment. Surcharging is
+// illegal in some markets, so it is off unless a cap is configured.
+type SurchargeRules struct {
+	MaxPercent float64 // of the payment amount, 0 for no percentage cap
+	MaxAmount  float64 // absolute, 0 for no absolute cap
+}
+
+// SurchargeRulesFromEnv reads SURCHARGE_MAX_PERCENT and SURCHARGE_MAX_AMOUNT. A provider
+// specific value, e.g. SURCHARGE_MAX_PERCENT_IYZICO, takes precedence over the global one.
+func SurchargeRulesFromEnv(providerName string) SurchargeRules {
+	read := func(key string) float64 {
+		for _, name := range []string{key + "_" + strings.ToUpper(providerName), key} {
+			value := strings.TrimSpace(config.GetEnv(name, ""))
+			if value == "" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed >= 0 {
+				return parsed
+			}
+		}
+		return 0
+	}
+
+	return SurchargeRules{
+		MaxPercent: read("SURCHARGE_MAX_PERCENT"),
+		MaxAmount:  read("SURCHARGE_MAX_AMOUNT"),
+	}
+}
+
+// Enabled reports whether any surcharge is accepted
+func (r SurchargeRules) Enabled() bool {
+	return r.MaxPercent > 0 || r.MaxAmount > 0
+}
+
+// Validate returns an error wrapping ErrSurchargeNotAllowed when the surcharge on amount
+// breaks the rules. A zero surcharge is always valid.
+func (r SurchargeRules) Validate(amount, surcharge float64) error {
+	switch {
+	case surcharge == 0:
+		return nil
+	case surcharge < 0:
+		return fmt.Errorf("%w: surcharge must not be negative", ErrSurchargeNotAllowed)
+	case !r.Enabled():
+		return fmt.Errorf("%w: surcharges are not enabled for this provider", ErrSurchargeNotAllowed)
+	}
+
+	// Compared in cents so a cap of exactly 2.5% of 99.99 is not rejected by float rounding
+	cents := func(value float64) int64 { return int64(math.Round(value * 100)) }
+	if r.MaxPercent > 0 && cents(surcharge) > cents(amount*r.MaxPercent/100) {
+		return fmt.Errorf("%w: %.2f is above %g%% of the amount", ErrSurchargeNotAllowed, surcharge, r.MaxPercent)
+	}
+	if r.MaxAmount > 0 && cents(surcharge) > cents(r.MaxAmount) {
+		return fmt.Errorf("%w: %.2f is above the maximum of %.2f", ErrSurchargeNotAllowed, surcharge, r.MaxAmount)
+	}
+	return nil
+}
+
+// SurchargeBreakdown splits the charged total of a surcharged payment into the amount of the
+// goods and the surcharge, for reporting
+type SurchargeBreakdown struct {
+	Amount    float64 `json:"amount"`
+	Surcharge float64 `json:"surcharge"`
+	Total     float64 `json:"total"`
+}
+
+// NewSurchargeBreakdown adds the surcharge to amount, rounded to the currency's minor unit
+func NewSurchargeBreakdown(amount, surcharge float64, currency string) *SurchargeBreakdown {
+	scale := math.Pow10(CurrencyExponent(currency))
+	return &SurchargeBreakdown{
+		Amount:    amount,
+		Surcharge: surcharge,
+		Total:     math.Round((amount+surcharge)*scale) / scale,
+	}
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+func TestSurchargeRulesFromEnv(t *testing.T) {
+	t.Setenv("SURCHARGE_MAX_PERCENT", "2.5")
+	t.Setenv("SURCHARGE_MAX_AMOUNT", "")
+	t.Setenv("SURCHARGE_MAX_PERCENT_PAYCELL", "1")
+	t.Setenv("SURCHARGE_MAX_AMOUNT_PAYCELL", "10")
+	t.Setenv("SURCHARGE_MAX_PERCENT_STRIPE", "invalid")
+
+	tests := []struct {
+		provider string
+		expected SurchargeRules
+	}{
+		{"iyzico", SurchargeRules{MaxPercent: 2.5}},
+		{"paycell", SurchargeRules{MaxPercent: 1, MaxAmount: 10}},
+		{"stripe", SurchargeRules{MaxPercent: 2.5}},
+	}
+	for _, tt := range tests {
+		if got := SurchargeRulesFromEnv(tt.provider); got != tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.provider, tt.expected, got)
+		}
+	}
+}
+
+func TestSurchargeRules_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     SurchargeRules
+		amount    float64
+		surcharge float64
+		allowed   bool
+	}{
+		{"no surcharge without rules", SurchargeRules{}, 100, 0, true},
+		{"surcharge without rules", SurchargeRules{}, 100, 1, false},
+		{"negative surcharge", SurchargeRules{MaxPercent: 3}, 100, -1, false},
+		{"within percentage cap", SurchargeRules{MaxPercent: 3}, 100, 2.99, true},
+		{"at percentage cap", SurchargeRules{MaxPercent: 2.5}, 99.99, 2.5, true},
+		{"above percentage cap", SurchargeRules{MaxPercent: 3}, 100, 3.01, false},
+		{"within absolute cap", SurchargeRules{MaxAmount: 5}, 1000, 5, true},
+		{"above absolute cap", SurchargeRules{MaxAmount: 5}, 1000, 5.01, false},
+		{"both caps, absolute is lower", SurchargeRules{MaxPercent: 3, MaxAmount: 20}, 1000, 25, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate(tt.amount, tt.surcharge)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected surcharge to be allowed, got %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrSurchargeNotAllowed) {
+				t.Errorf("Expected ErrSurchargeNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewSurchargeBreakdown(t *testing.T) {
+	if got := *NewSurchargeBreakdown(100.10, 2.20, "TRY"); got != (SurchargeBreakdown{Amount: 100.10, Surcharge: 2.20, Total: 102.30}) {
+		t.Errorf("Unexpected breakdown %+v", got)
+	}
+	if got := NewSurchargeBreakdown(1500, 45, "JPY").Total; got != 1545 {
+		t.Errorf("Expected total 1545, got %v", got)
+	}
+}
+
+// amountRecordingProvider remembers the amount it was asked to charge
+type amountRecordingProvider struct {
+	PaymentProvider
+	charged float64
+}
+
+func (p *amountRecordingProvider) SupportedCurrencies() []string { return []string{"TRY"} }
+
+func (p *amountRecordingProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.charged = request.Amount
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, Amount: request.Amount, Currency: request.Currency}, nil
+}
+
+type requestRecordingPaymentLogger struct {
+	recordingPaymentLogger
+	request any
+}
+
+func (l *requestRecordingPaymentLogger) LogRequest(ctx context.Context, tenantID int, providerName, method, endpoint string, request any, userAgent, clientIP string) (int64, error) {
+	l.request = request
+	return l.recordingPaymentLogger.LogRequest(ctx, tenantID, providerName, method, endpoint, request, userAgent, clientIP)
+}
+
+func TestPaymentService_CreatePayment_Surcharge(t *testing.T) {
+	const tenantID, providerName = 9111, "surchargetest"
+	t.Setenv("SURCHARGE_MAX_PERCENT", "3")
+	t.Setenv("SURCHARGE_MAX_AMOUNT", "")
+
+	fake := &amountRecordingProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &requestRecordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9111")
+
+	request := riskRequest()
+	request.Amount = 250
+	request.Surcharge = 5.75
+	resp, err := service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+
+	if fake.charged != 255.75 {
+		t.Errorf("Expected the provider to charge 255.75, got %v", fake.charged)
+	}
+	expected := SurchargeBreakdown{Amount: 250, Surcharge: 5.75, Total: 255.75}
+	if resp.Surcharge == nil || *resp.Surcharge != expected {
+		t.Errorf("Expected breakdown %+v, got %+v", expected, resp.Surcharge)
+	}
+
+	logged, ok := paymentLogger.request.(PaymentRequest)
+	if !ok || logged.Amount != 250 || logged.Surcharge != 5.75 {
+		t.Errorf("Expected the logged request to keep amount and surcharge apart, got %+v", paymentLogger.request)
+	}
+	if len(paymentLogger.responses) != 1 || paymentLogger.responses[0].(*PaymentResponse).Surcharge == nil {
+		t.Error("Expected the logged response to carry the surcharge breakdown")
+	}
+
+	// Payments without a surcharge are charged as before
+	request.Surcharge = 0
+	resp, err = service.CreatePayment(ctx, "sandbox", providerName, request)
+	if err != nil || fake.charged != 250 || resp.Surcharge != nil {
+		t.Errorf("Expected an unsurcharged payment of 250, got charged=%v breakdown=%+v (%v)", fake.charged, resp.Surcharge, err)
+	}
+}
+
+func TestPaymentService_CreatePayment_SurchargeAboveCap(t *testing.T) {
+	const tenantID, providerName = 9111, "surchargetest"
+	t.Setenv("SURCHARGE_MAX_PERCENT", "3")
+	t.Setenv("SURCHARGE_MAX_AMOUNT", "")
+
+	fake := &amountRecordingProvider{}
+	GetProviderCache().Set(tenantID, providerName, "sandbox", fake)
+	t.Cleanup(func() { GetProviderCache().Delete(tenantID, providerName, "sandbox") })
+
+	paymentLogger := &requestRecordingPaymentLogger{}
+	service := NewPaymentService(paymentLogger)
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9111")
+
+	request := riskRequest()
+	request.Amount = 100
+	request.Surcharge = 10
+	if _, err := service.CreatePayment(ctx, "sandbox", providerName, request); !errors.Is(err, ErrSurchargeNotAllowed) {
+		t.Fatalf("Expected ErrSurchargeNotAllowed, got %v", err)
+	}
+	if fake.charged != 0 || paymentLogger.requests != 0 {
+		t.Error("Expected a rejected surcharge to reach neither the provider nor the logs")
+	}
+}
@@ -0,0 +1,40 @@
+package provider
+
+import "sync"
+
+// TenantConfigSource supplies the provider configuration of tenants, for GoPay embedded in a
+// service that keeps provider credentials itself instead of in tenant_configs
+type TenantConfigSource interface {
+	// TenantConfig returns the configuration of a tenant's provider in an environment, keyed
+	// like tenant_configs. An empty configuration means the provider is not configured.
+	TenantConfig(tenantID int, providerName, environment string) (map[string]string, error)
+}
+
+// TenantConfigFunc adapts a function to TenantConfigSource
+type TenantConfigFunc func(tenantID int, providerName, environment string) (map[string]string, error)
+
+// TenantConfig calls f
+func (f TenantConfigFunc) TenantConfig(tenantID int, providerName, environment string) (map[string]string, error) {
+	return f(tenantID, providerName, environment)
+}
+
+var (
+	tenantConfigSourceMu sync.RWMutex
+	tenantConfigSource   TenantConfigSource
+)
+
+// SetTenantConfigSource makes GetProvider read tenant configurations from source instead of
+// tenant_configs; nil restores the table. Cached providers are dropped so no provider keeps a
+// configuration of the previous source.
+func SetTenantConfigSource(source TenantConfigSource) {
+	tenantConfigSourceMu.Lock()
+	tenantConfigSource = source
+	tenantConfigSourceMu.Unlock()
+	GetProviderCache().Clear()
+}
+
+func getTenantConfigSource() TenantConfigSource {
+	tenantConfigSourceMu.RLock()
+	defer tenantConfigSourceMu.RUnlock()
+	return tenantConfigSource
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+)
+
+// sourcedTestProvider records the configuration it was initialized with
+type sourcedTestProvider struct {
+	configTestProvider
+	configs map[string]string
+}
+
+func (p *sourcedTestProvider) Initialize(configs map[string]string) error {
+	p.configs = configs
+	return nil
+}
+
+func TestGetProvider_TenantConfigSource(t *testing.T) {
+	Register("sourcetest", func() PaymentProvider { return &sourcedTestProvider{} })
+
+	configs := map[int]map[string]string{
+		9201: {"apiKey": "api_123", "secretKey": "secret_123"},
+	}
+	var requested []string
+	SetTenantConfigSource(TenantConfigFunc(func(tenantID int, providerName, environment string) (map[string]string, error) {
+		requested = append(requested, providerName+"/"+environment)
+		return configs[tenantID], nil
+	}))
+	t.Cleanup(func() { SetTenantConfigSource(nil) })
+
+	provider, err := GetProvider(9201, "sourcetest", "sandbox")
+	if err != nil {
+		t.Fatalf("GetProvider failed: %v", err)
+	}
+	sourced := provider.(*sourcedTestProvider)
+	if sourced.configs["apiKey"] != "api_123" || sourced.configs["environment"] != "sandbox" {
+		t.Errorf("Expected the sourced configuration with its environment, got %v", sourced.configs)
+	}
+	if _, ok := configs[9201]["environment"]; ok {
+		t.Error("Expected the caller's configuration to be left unchanged")
+	}
+	if len(requested) != 1 || requested[0] != "sourcetest/sandbox" {
+		t.Errorf("Expected one lookup of sourcetest/sandbox, got %v", requested)
+	}
+
+	// A tenant the source has no configuration for is not configured
+	if _, err := GetProvider(9202, "sourcetest", "sandbox"); !errors.Is(err, ErrProviderNotConfigured) {
+		t.Errorf("Expected ErrProviderNotConfigured, got %v", err)
+	}
+
+	// Setting a source drops providers initialized from the previous one
+	SetTenantConfigSource(TenantConfigFunc(func(int, string, string) (map[string]string, error) {
+		return nil, errors.New("vault unavailable")
+	}))
+	if _, err := GetProvider(9201, "sourcetest", "sandbox"); err == nil {
+		t.Error("Expected the source's error once the cached provider was dropped")
+	}
+}
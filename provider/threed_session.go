@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrorCode3DSessionExpired is the PaymentResponse.ErrorCode of a 3D payment whose session
+// timed out before the customer finished authentication
+const ErrorCode3DSessionExpired = "3d_session_expired"
+
+// Err3DSessionExpired is returned by Complete3DPayment when the provider reports that the
+// 3D secure session timed out. The card was not charged and the session cannot be resumed,
+// so the client has to start a new payment.
+var Err3DSessionExpired = errors.New("3D secure session expired, start a new payment")
+
+// threeDSessionExpiredPhrases are the wordings providers use for a timed out 3D session.
+// Card expiry ("expired card") is a decline, so a bare "expired" is not matched.
+var threeDSessionExpiredPhrases = []string{
+	"session expired",
+	"session has expired",
+	"session timeout",
+	"session timed out",
+	"timed out",
+	"timeout",
+	"zaman aşım",
+	"zaman aşim",
+	"oturum süresi",
+}
+
+// Is3DSessionExpiredMessage reports whether any of the provider messages describes a timed
+// out 3D secure session
+func Is3DSessionExpiredMessage(messages ...string) bool {
+	for _, message := range messages {
+		message = strings.ToLower(message)
+		for _, phrase := range threeDSessionExpiredPhrases {
+			if strings.Contains(message, phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ErrorCodeThreeDSCancelled is the PaymentResponse.ErrorCode of a 3D payment whose customer
+// cancelled the 3D secure challenge on the bank page. Its status is StatusCancelled: the card
+// was not declined, so the client can offer to retry rather than show a hard decline.
+const ErrorCodeThreeDSCancelled = "3ds_cancelled"
+
+// threeDSCancelledPhrases are the wordings providers use for a challenge the customer
+// cancelled. A bare "cancel" is not matched, as it also describes reversed payments.
+var threeDSCancelledPhrases = []string{
+	"cancelled by user",
+	"canceled by user",
+	"cancelled by the user",
+	"canceled by the user",
+	"cancelled by cardholder",
+	"canceled by cardholder",
+	"cancelled by the cardholder",
+	"canceled by the cardholder",
+	"user cancelled",
+	"user canceled",
+	"cardholder cancelled",
+	"cardholder canceled",
+	"kullanıcı tarafından iptal",
+	"kullanici tarafindan iptal",
+	"müşteri tarafından iptal",
+	"müşteri tarafindan iptal",
+	"kart sahibi tarafından iptal",
+	"kart sahibi tarafindan iptal",
+	"kullanıcı vazgeçti",
+	"kullanici vazgeçti",
+}
+
+// Is3DSChallengeCancelledMessage reports whether any of the provider messages describes a 3D
+// secure challenge the customer cancelled
+func Is3DSChallengeCancelledMessage(messages ...string) bool {
+	for _, message := range messages {
+		message = strings.ToLower(message)
+		for _, phrase := range threeDSCancelledPhrases {
+			if strings.Contains(message, phrase) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MarkThreeDSCancelled turns a failed 3D completion into a customer-cancelled one
+func MarkThreeDSCancelled(response *PaymentResponse) {
+	response.Success = false
+	response.Status = StatusCancelled
+	response.ErrorCode = ErrorCodeThreeDSCancelled
+	response.Message = "3D secure challenge cancelled by the customer"
+}
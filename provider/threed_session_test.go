@@ -0,0 +1,59 @@
+package provider
+
+import "testing"
+
+func TestIs3DSessionExpiredMessage(t *testing.T) {
+	tests := []struct {
+		messages []string
+		expected bool
+	}{
+		{[]string{"3D session expired"}, true},
+		{[]string{"", "Session Timed Out"}, true},
+		{[]string{"İşlem zaman aşımına uğradı"}, true},
+		{[]string{"İŞLEM ZAMAN AŞIMI"}, true},
+		{[]string{"OTURUM SÜRESİ DOLDU"}, true},
+		{[]string{"Expired card"}, false},
+		{[]string{"Insufficient funds", ""}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := Is3DSessionExpiredMessage(tt.messages...); got != tt.expected {
+			t.Errorf("Is3DSessionExpiredMessage(%q): expected %v, got %v", tt.messages, tt.expected, got)
+		}
+	}
+}
+
+func TestIs3DSChallengeCancelledMessage(t *testing.T) {
+	tests := []struct {
+		messages []string
+		expected bool
+	}{
+		{[]string{"Authentication cancelled by user"}, true},
+		{[]string{"", "Cardholder Canceled"}, true},
+		{[]string{"İşlem kullanıcı tarafından iptal edildi"}, true},
+		{[]string{"İŞLEM KULLANICI TARAFINDAN İPTAL EDİLDİ"}, true},
+		{[]string{"Kart sahibi tarafından iptal edildi"}, true},
+		{[]string{"Payment cancelled"}, false},
+		{[]string{"Not authenticated", "3D session expired"}, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := Is3DSChallengeCancelledMessage(tt.messages...); got != tt.expected {
+			t.Errorf("Is3DSChallengeCancelledMessage(%q): expected %v, got %v", tt.messages, tt.expected, got)
+		}
+	}
+}
+
+func TestMarkThreeDSCancelled(t *testing.T) {
+	response := &PaymentResponse{Success: false, Status: StatusFailed, ErrorCode: "0", Message: "Failed", RedirectURL: "https://merchant.example/callback"}
+	MarkThreeDSCancelled(response)
+
+	if response.Status != StatusCancelled || response.ErrorCode != ErrorCodeThreeDSCancelled || response.Success {
+		t.Errorf("Expected a cancelled response with code %s, got %+v", ErrorCodeThreeDSCancelled, response)
+	}
+	if response.RedirectURL != "https://merchant.example/callback" {
+		t.Errorf("Expected the redirect to the merchant to be kept, got %q", response.RedirectURL)
+	}
+}
@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// ThreeDSEnrollmentStatus tells whether a card BIN is enrolled in 3D Secure
+type ThreeDSEnrollmentStatus string
+
+const (
+	ThreeDSEnrolled          ThreeDSEnrollmentStatus = "enrolled"
+	ThreeDSNotEnrolled       ThreeDSEnrollmentStatus = "not_enrolled"
+	ThreeDSEnrollmentUnknown ThreeDSEnrollmentStatus = "unknown"
+)
+
+// ErrInvalidBIN is returned when a 3DS enrollment check gets something other than the
+// first 6 or 8 digits of a card number
+var ErrInvalidBIN = errors.New("bin must be the first 6 or 8 digits of the card number")
+
+var binPattern = regexp.MustCompile(`^(\d{6}|\d{8})$`)
+
+// ThreeDSEnrollmentProvider is an OPTIONAL capability interface implemented by providers that
+// can tell whether a card BIN is enrolled in 3D Secure before a payment is started. Callers
+// type-assert on it, like CaptureProvider; other providers report ThreeDSEnrollmentUnknown.
+type ThreeDSEnrollmentProvider interface {
+	// Check3DSEnrollment looks up the 3DS enrollment of a 6 or 8 digit card BIN
+	Check3DSEnrollment(ctx context.Context, bin string) (ThreeDSEnrollmentStatus, error)
+}
+
+// ThreeDSEnrollmentResponse is the result of a 3DS enrollment pre-check
+type ThreeDSEnrollmentResponse struct {
+	BIN      string                  `json:"bin"`
+	Provider string                  `json:"provider"`
+	Status   ThreeDSEnrollmentStatus `json:"status"`
+	// Supported is false when the provider cannot check enrollment, so Status is always unknown
+	Supported bool `json:"supported"`
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// enrollmentTestProvider reports a fixed 3DS enrollment status and records the BIN it got
+type enrollmentTestProvider struct {
+	riskTestProvider
+	status ThreeDSEnrollmentStatus
+	bin    string
+}
+
+func (p *enrollmentTestProvider) Check3DSEnrollment(_ context.Context, bin string) (ThreeDSEnrollmentStatus, error) {
+	p.bin = bin
+	return p.status, nil
+}
+
+func TestPaymentService_Check3DSEnrollment(t *testing.T) {
+	const tenantID = 9107
+	checker := &enrollmentTestProvider{status: ThreeDSEnrolled}
+	GetProviderCache().Set(tenantID, "enrollmenttest", "sandbox", checker)
+	GetProviderCache().Set(tenantID, "noenrollmenttest", "sandbox", &riskTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "enrollmenttest", "sandbox")
+		GetProviderCache().Delete(tenantID, "noenrollmenttest", "sandbox")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9107")
+
+	resp, err := service.Check3DSEnrollment(ctx, "sandbox", "enrollmenttest", "55287900")
+	if err != nil {
+		t.Fatalf("Check3DSEnrollment failed: %v", err)
+	}
+	if !resp.Supported || resp.Status != ThreeDSEnrolled || resp.BIN != "55287900" || checker.bin != "55287900" {
+		t.Errorf("Expected supported enrolled response for 55287900, got %+v (provider got %q)", resp, checker.bin)
+	}
+
+	resp, err = service.Check3DSEnrollment(ctx, "sandbox", "noenrollmenttest", "552879")
+	if err != nil {
+		t.Fatalf("Check3DSEnrollment failed: %v", err)
+	}
+	if resp.Supported || resp.Status != ThreeDSEnrollmentUnknown {
+		t.Errorf("Expected unsupported unknown response, got %+v", resp)
+	}
+
+	for _, bin := range []string{"", "55287", "5528790", "552879000", "55287a", "5528790000000008"} {
+		if _, err := service.Check3DSEnrollment(ctx, "sandbox", "enrollmenttest", bin); !errors.Is(err, ErrInvalidBIN) {
+			t.Errorf("BIN %q: expected ErrInvalidBIN, got %v", bin, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ThreeDSVersionUnknown is the ThreeDSResult.Version of a 3D payment whose provider did not
+// report the 3DS protocol version
+const ThreeDSVersionUnknown = "unknown"
+
+// threeDSVersionPattern matches protocol versions such as "1.0.2", "2.1.0" and "2.2"
+var threeDSVersionPattern = regexp.MustCompile(`^[12](\.\d+){0,2}$`)
+
+// ThreeDSResult describes the 3D secure authentication of a completed 3D payment, for
+// reporting and liability analysis
+type ThreeDSResult struct {
+	// Version is the 3DS protocol version the provider reported, e.g. "1.0.2" or "2.2.0",
+	// or ThreeDSVersionUnknown
+	Version string `json:"version"`
+	// Legacy is set for 3DS1, which the card schemes have retired
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// NewThreeDSResult builds the ThreeDSResult of a provider-reported version. A missing or
+// unrecognized version ("", "N/A") is reported as ThreeDSVersionUnknown.
+func NewThreeDSResult(version string) *ThreeDSResult {
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	if !threeDSVersionPattern.MatchString(version) {
+		return &ThreeDSResult{Version: ThreeDSVersionUnknown}
+	}
+	return &ThreeDSResult{Version: version, Legacy: strings.HasPrefix(version, "1")}
+}
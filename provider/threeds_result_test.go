@@ -0,0 +1,27 @@
+package provider
+
+import "testing"
+
+func TestNewThreeDSResult(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected ThreeDSResult
+	}{
+		{"1.0.2", ThreeDSResult{Version: "1.0.2", Legacy: true}},
+		{"2.1.0", ThreeDSResult{Version: "2.1.0"}},
+		{" 2.2.0 ", ThreeDSResult{Version: "2.2.0"}},
+		{"v2.2", ThreeDSResult{Version: "2.2"}},
+		{"2", ThreeDSResult{Version: "2"}},
+		{"", ThreeDSResult{Version: ThreeDSVersionUnknown}},
+		{"N/A", ThreeDSResult{Version: ThreeDSVersionUnknown}},
+		{"3.0.0", ThreeDSResult{Version: ThreeDSVersionUnknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := NewThreeDSResult(tt.version); *got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *got)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package tosla
+
+import "github.com/mstgnz/gopay/provider"
+
+func init() {
+	// Register Tosla provider with the global registry
+	provider.Register("tosla", NewProvider)
+}
@@ -0,0 +1,103 @@
+package tosla
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// setupRealTestProvider returns a provider with the merchant from TOSLA_CLIENT_ID,
+// TOSLA_API_USER and TOSLA_API_PASS, skipping the test when they are not set. Tosla's
+// published sandbox merchant (1000000494) can be used for them.
+func setupRealTestProvider(t *testing.T) *ToslaProvider {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping real API test in short mode")
+	}
+
+	config := map[string]string{
+		"clientId":    os.Getenv("TOSLA_CLIENT_ID"),
+		"apiUser":     os.Getenv("TOSLA_API_USER"),
+		"apiPass":     os.Getenv("TOSLA_API_PASS"),
+		"environment": "sandbox",
+	}
+	if config["clientId"] == "" || config["apiUser"] == "" || config["apiPass"] == "" {
+		t.Skip("tosla test merchant not set; skipping real API test")
+	}
+
+	p := NewProvider().(*ToslaProvider)
+	if err := p.Initialize(config); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	return p
+}
+
+func realPaymentRequest() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		Amount:      10.00,
+		Currency:    "TRY",
+		CallbackURL: "https://example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:    "Ahmet",
+			Surname: "Yılmaz",
+			Email:   "ahmet@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "4159560047417732",
+			ExpireMonth: "08",
+			ExpireYear:  "2030",
+			CVV:         "123",
+		},
+	}
+}
+
+// TestToslaProvider_RealAPI_PaymentLifecycle makes a sale, reads it back and voids it
+func TestToslaProvider_RealAPI_PaymentLifecycle(t *testing.T) {
+	p := setupRealTestProvider(t)
+	ctx := context.Background()
+
+	payment, err := p.CreatePayment(ctx, realPaymentRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v payment=%s message=%s", payment.Success, payment.PaymentID, payment.Message)
+	if !payment.Success {
+		return
+	}
+
+	status, err := p.GetPaymentStatus(ctx, provider.GetPaymentStatusRequest{PaymentID: payment.PaymentID})
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if status.Status != provider.StatusSuccessful {
+		t.Errorf("Expected a successful payment, got %s", status.Status)
+	}
+
+	cancel, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: payment.PaymentID})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	t.Logf("Cancel: success=%v message=%s", cancel.Success, cancel.Message)
+}
+
+// TestToslaProvider_RealAPI_Create3DPayment opens a 3D session. Completing it needs the
+// customer on the 3D page, so it stops at the form.
+func TestToslaProvider_RealAPI_Create3DPayment(t *testing.T) {
+	p := setupRealTestProvider(t)
+
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	response, err := p.Create3DPayment(context.Background(), realPaymentRequest())
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+	t.Logf("Payment: success=%v payment=%s message=%s", response.Success, response.PaymentID, response.Message)
+	if response.Success && response.HTML == "" {
+		t.Error("Expected a 3D form")
+	}
+}
@@ -0,0 +1,395 @@
+package tosla
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/provider/internal/providertest"
+)
+
+func testConfig(environment string) map[string]string {
+	return map[string]string{
+		"clientId":    "1000000100",
+		"apiUser":     "POS_USER",
+		"apiPass":     "POS_PASS!*",
+		"environment": environment,
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p := NewProvider()
+	if p == nil {
+		t.Fatal("NewProvider should return a non-nil provider")
+	}
+
+	toslaProvider, ok := p.(*ToslaProvider)
+	if !ok {
+		t.Fatal("NewProvider should return a ToslaProvider instance")
+	}
+
+	// HTTP client is created only after Initialize() is called
+	if toslaProvider.httpClient != nil {
+		t.Error("ToslaProvider should have nil HTTP client before Initialize()")
+	}
+
+	if err := toslaProvider.Initialize(testConfig("sandbox")); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if toslaProvider.httpClient == nil {
+		t.Error("ToslaProvider should have a non-nil HTTP client after Initialize()")
+	}
+}
+
+func TestToslaProvider_Initialize(t *testing.T) {
+	with := func(environment, key, value string) map[string]string {
+		config := testConfig(environment)
+		config[key] = value
+		return config
+	}
+	withoutCredentials := func(environment string) map[string]string {
+		return map[string]string{"environment": environment}
+	}
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		baseURL     string
+		clientID    int64
+		expectError bool
+	}{
+		{"sandbox", testConfig("sandbox"), apiSandboxURL, 1000000100, false},
+		{"production", testConfig("production"), apiProductionURL, 1000000100, false},
+		{"sandbox test merchant", withoutCredentials("sandbox"), apiSandboxURL, 1000000494, false},
+		{"production without credentials", withoutCredentials("production"), "", 0, true},
+		{"production with the test merchant", with("production", "clientId", sandboxClientID), "", 0, true},
+		{"partial sandbox credentials", with("sandbox", "apiPass", ""), "", 0, true},
+		{"non-numeric client ID", with("sandbox", "clientId", "abc"), "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ToslaProvider{}
+			err := p.Initialize(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.baseURL != tt.baseURL || p.clientID != tt.clientID {
+				t.Errorf("Expected base URL %s and client %d, got %s and %d", tt.baseURL, tt.clientID, p.baseURL, p.clientID)
+			}
+		})
+	}
+}
+
+func TestToslaProvider_GetRequiredConfig(t *testing.T) {
+	p := &ToslaProvider{}
+
+	if err := p.ValidateConfig(map[string]string{"environment": "sandbox"}); err != nil {
+		t.Errorf("Expected sandbox credentials to be optional, got %v", err)
+	}
+	if err := p.ValidateConfig(map[string]string{"environment": "production"}); err == nil {
+		t.Error("Expected production credentials to be required")
+	}
+	if err := p.ValidateConfig(testConfig("production")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// newTestProvider returns a provider whose API is answered by handler, after checking the
+// request is signed with the merchant's credentials
+func newTestProvider(t *testing.T, handler func(endpoint string, body map[string]any) any) *ToslaProvider {
+	t.Helper()
+	p := providertest.Initialize[*ToslaProvider](t, NewProvider, testConfig("sandbox"))
+
+	server := providertest.Server(t, providertest.JSON(func(r *http.Request, body map[string]any) (int, any) {
+		rnd, _ := body["rnd"].(string)
+		timeSpan, _ := body["timeSpan"].(string)
+		if len(timeSpan) != len(timeSpanLayout) || body["hash"] != p.hash("1000000100", "POS_USER", rnd, timeSpan) || body["clientId"] != float64(1000000100) {
+			t.Errorf("Unsigned %s request: %v", r.URL.Path, body)
+		}
+		return http.StatusOK, handler(r.URL.Path, body)
+	}))
+
+	p.baseURL = server.URL
+	p.gopayBaseURL = "https://gopay.example.com"
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, false).ForProvider("tosla"))
+	return p
+}
+
+func paymentRequestFixture() provider.PaymentRequest {
+	return provider.PaymentRequest{
+		TenantID:    1,
+		ID:          "GP1",
+		Amount:      150.75,
+		Currency:    "TRY",
+		CallbackURL: "https://merchant.example.com/return",
+		ClientIP:    "203.0.113.10",
+		Customer: provider.Customer{
+			Name:    "Ahmet",
+			Surname: "Yılmaz",
+			Email:   "ahmet@example.com",
+		},
+		CardInfo: provider.CardInfo{
+			CardNumber:  "4159 5600 4741 7732",
+			ExpireMonth: "8",
+			ExpireYear:  "2030",
+			CVV:         "123",
+		},
+	}
+}
+
+func TestToslaProvider_CreatePayment(t *testing.T) {
+	var sale map[string]any
+	result := map[string]any{"Code": 0, "Message": "Başarılı", "OrderId": "GP1", "BankResponseCode": "00", "BankResponseMessage": "Onaylandı", "AuthCode": "123456", "HostReferenceNumber": "401510000001", "TransactionId": "T1", "CardHolderName": "AHMET YILMAZ"}
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		if endpoint != endpointPayment {
+			t.Errorf("Unexpected endpoint %s", endpoint)
+		}
+		sale = body
+		return result
+	})
+	ctx := context.Background()
+
+	response, err := p.CreatePayment(ctx, paymentRequestFixture())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if sale["orderId"] != "GP1" || sale["amount"] != float64(15075) || sale["currency"] != float64(949) || sale["installmentCount"] != float64(1) {
+		t.Errorf("Unexpected sale request %v", sale)
+	}
+	if sale["cardNo"] != "4159560047417732" || sale["expireDate"] != "08/30" || sale["cardHolderName"] != "Ahmet Yılmaz" {
+		t.Errorf("Unexpected card fields %v", sale)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.PaymentID != "GP1" || response.TransactionID != "T1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	result = map[string]any{"Code": 0, "Message": "Başarılı", "BankResponseCode": "51", "BankResponseMessage": "Yetersiz bakiye"}
+	response, err = p.CreatePayment(ctx, paymentRequestFixture())
+	if err != nil || response.Success || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Expected the bank's decline, got %+v (%v)", response, err)
+	}
+}
+
+func TestToslaProvider_Create3DPayment(t *testing.T) {
+	provider.SetCallbackStateStore(provider.NewMemoryCallbackStateStore())
+	t.Cleanup(func() { provider.SetCallbackStateStore(nil) })
+
+	var session map[string]any
+	result := map[string]any{"Code": 0, "Message": "Başarılı", "ThreeDSessionId": "SESSION1", "TransactionId": "T1"}
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		if endpoint != endpointThreeDPayment {
+			t.Errorf("Unexpected endpoint %s", endpoint)
+		}
+		session = body
+		return result
+	})
+
+	request := paymentRequestFixture()
+	request.InstallmentCount = 3
+	response, err := p.Create3DPayment(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Create3DPayment failed: %v", err)
+	}
+
+	callbackURL, _ := session["callbackUrl"].(string)
+	if session["orderId"] != "GP1" || session["amount"] != float64(15075) || session["installmentCount"] != float64(3) || !strings.HasPrefix(callbackURL, "https://gopay.example.com/") {
+		t.Errorf("Unexpected session request %v", session)
+	}
+	if _, ok := session["cardNo"]; ok {
+		t.Error("The card must be posted by the customer, not sent with the session")
+	}
+
+	if !response.Success || response.Status != provider.StatusPending || response.PaymentID != "GP1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	for _, want := range []string{p.baseURL + endpointProcessCardForm, `name="ThreeDSessionId" value="SESSION1"`, `name="CardNo" value="4159560047417732"`, `name="ExpireDate" value="08/30"`} {
+		if !strings.Contains(response.HTML, want) {
+			t.Errorf("Expected the form to contain %s", want)
+		}
+	}
+
+	result = map[string]any{"Code": 1, "Message": "Hash doğrulanamadı"}
+	response, err = p.Create3DPayment(context.Background(), request)
+	if err != nil || response.Success || response.ErrorCode != "1" || response.Message != "Hash doğrulanamadı" {
+		t.Errorf("Expected Tosla's error, got %+v (%v)", response, err)
+	}
+}
+
+// callbackData returns a 3D callback signed the way Tosla signs it
+func callbackData(p *ToslaProvider, orderID, mdStatus, bankCode, bankMessage, requestStatus string) map[string]string {
+	data := map[string]string{
+		"ClientId":            "1000000100",
+		"OrderId":             orderID,
+		"MdStatus":            mdStatus,
+		"ThreeDSessionId":     "SESSION1",
+		"BankResponseCode":    bankCode,
+		"BankResponseMessage": bankMessage,
+		"RequestStatus":       requestStatus,
+		"HashParameters":      strings.Join(callbackHashFields, ","),
+	}
+	data["Hash"] = p.hash("1000000100", "POS_USER", orderID, mdStatus, bankCode, bankMessage, requestStatus)
+	return data
+}
+
+func TestToslaProvider_Complete3DPayment(t *testing.T) {
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		t.Errorf("Unexpected request to %s", endpoint)
+		return nil
+	})
+
+	callbackState := &provider.CallbackState{
+		TenantID:         1,
+		PaymentID:        "GP1",
+		OriginalCallback: "https://merchant.example.com/return",
+		Amount:           150.75,
+		Currency:         "TRY",
+		Provider:         "tosla",
+		Environment:      "sandbox",
+	}
+	ctx := context.Background()
+
+	response, err := p.Complete3DPayment(ctx, callbackState, callbackData(p, "GP1", "1", "00", "Onaylandı", "1"))
+	if err != nil {
+		t.Fatalf("Complete3DPayment failed: %v", err)
+	}
+	if !response.Success || response.Status != provider.StatusSuccessful || response.RedirectURL != "https://merchant.example.com/return" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, callbackData(p, "GP1", "1", "51", "Yetersiz bakiye", "0"))
+	if err != nil || response.Success || response.ErrorCode != "51" || response.Message != "Yetersiz bakiye" {
+		t.Errorf("Expected the bank's decline, got %+v (%v)", response, err)
+	}
+
+	response, err = p.Complete3DPayment(ctx, callbackState, callbackData(p, "GP1", "0", "", "", "0"))
+	if err != nil || response.Success || response.ErrorCode != "0" {
+		t.Errorf("Expected a failed authentication, got %+v (%v)", response, err)
+	}
+
+	forged := callbackData(p, "GP1", "0", "", "", "0")
+	forged["MdStatus"], forged["RequestStatus"] = "1", "1"
+	if _, err := p.Complete3DPayment(ctx, callbackState, forged); err == nil {
+		t.Error("Expected a forged callback to be rejected")
+	}
+
+	if _, err := p.Complete3DPayment(ctx, callbackState, callbackData(p, "GP2", "1", "00", "Onaylandı", "1")); err == nil {
+		t.Error("Expected a callback for another payment to be rejected")
+	}
+
+	otherMerchant := callbackData(p, "GP1", "1", "00", "Onaylandı", "1")
+	otherMerchant["ClientId"] = "1000000494"
+	if _, err := p.Complete3DPayment(ctx, callbackState, otherMerchant); err == nil {
+		t.Error("Expected a callback for another merchant to be rejected")
+	}
+}
+
+func TestToslaProvider_GetPaymentStatus(t *testing.T) {
+	inquiry := func(requestStatus int, voided bool, refunded int64) map[string]any {
+		return map[string]any{"Code": 0, "Message": "Başarılı", "OrderId": "GP1", "TransactionId": "T1", "Amount": 15075, "RefundedAmount": refunded, "Currency": 949, "InstallmentCount": 1, "RequestStatus": requestStatus, "IsVoided": voided, "CreateDate": "2024-01-15T10:20:30"}
+	}
+
+	tests := []struct {
+		name   string
+		result map[string]any
+		want   provider.PaymentStatus
+	}{
+		{"completed", inquiry(1, false, 0), provider.StatusSuccessful},
+		{"failed", inquiry(0, false, 0), provider.StatusFailed},
+		{"voided", inquiry(1, true, 0), provider.StatusCancelled},
+		{"refunded", inquiry(1, false, 5000), provider.StatusRefunded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+				if endpoint != endpointInquiry || body["orderId"] != "GP1" {
+					t.Errorf("Unexpected request to %s: %v", endpoint, body)
+				}
+				return tt.result
+			})
+			response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "GP1"})
+			if err != nil || response.Status != tt.want || response.Amount != 150.75 || response.Currency != "TRY" || response.TransactionID != "T1" || response.ProviderTime == nil {
+				t.Errorf("Unexpected response: %+v (%v)", response, err)
+			}
+		})
+	}
+
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		return map[string]any{"Code": 404, "Message": "Order not found"}
+	})
+	response, err := p.GetPaymentStatus(context.Background(), provider.GetPaymentStatusRequest{PaymentID: "GP2"})
+	if !errors.Is(err, provider.ErrPaymentNotFound) || response.ErrorCode != provider.ErrorCodePaymentNotFound {
+		t.Errorf("Expected ErrPaymentNotFound, got %+v (%v)", response, err)
+	}
+}
+
+func TestToslaProvider_CancelPayment(t *testing.T) {
+	var void map[string]any
+	result := map[string]any{"Code": 0, "Message": "Başarılı"}
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		if endpoint != endpointVoid {
+			t.Errorf("Unexpected endpoint %s", endpoint)
+		}
+		void = body
+		return result
+	})
+	ctx := context.Background()
+
+	response, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"})
+	if err != nil || !response.Success || response.Status != provider.StatusCancelled || void["orderId"] != "GP1" {
+		t.Errorf("Expected the order to be voided, got %+v (%v) with %v", response, err, void)
+	}
+
+	result = map[string]any{"Code": 1, "Message": "Order not found"}
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{PaymentID: "GP1"}); !errors.Is(err, provider.ErrPaymentNotFound) {
+		t.Errorf("Expected Tosla's refusal to be recognized, got %v", err)
+	}
+
+	if _, err := p.CancelPayment(ctx, provider.CancelRequest{}); err == nil {
+		t.Error("Expected an error without a payment ID")
+	}
+}
+
+func TestToslaProvider_RefundPayment(t *testing.T) {
+	var refund map[string]any
+	result := map[string]any{"Code": 0, "Message": "Başarılı", "OrderId": "GP1", "TransactionId": "R1"}
+	p := newTestProvider(t, func(endpoint string, body map[string]any) any {
+		if endpoint != endpointRefund {
+			t.Errorf("Unexpected endpoint %s", endpoint)
+		}
+		refund = body
+		return result
+	})
+	ctx := context.Background()
+
+	response, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 50, Currency: "TRY"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund["orderId"] != "GP1" || refund["amount"] != float64(5000) {
+		t.Errorf("Unexpected refund request %v", refund)
+	}
+	if !response.Success || response.Status != "success" || response.RefundID != "R1" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	result = map[string]any{"Code": 1, "Message": "İade tutarı satış tutarını aşıyor"}
+	response, err = p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1", RefundAmount: 500})
+	if err != nil || response.Success || response.ErrorCode != "1" {
+		t.Errorf("Expected Tosla's error, got %+v (%v)", response, err)
+	}
+
+	if _, err := p.RefundPayment(ctx, provider.RefundRequest{PaymentID: "GP1"}); err == nil {
+		t.Error("Expected an error without a refund amount")
+	}
+}
@@ -0,0 +1,355 @@
+package provider
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Wallet payments carry a token issued by the customer's device wallet instead of raw card
+// details. Providers that accept them either forward the token (e.g. Stripe) or decrypt it
+// with the merchant's payment processing key and charge the device card number it contains.
+
+const (
+	WalletTypeApplePay  = "apple_pay"
+	WalletTypeGooglePay = "google_pay"
+
+	// applePayTokenVersion is the only Apple Pay token version outside China (RSA_v1)
+	applePayTokenVersion = "EC_v1"
+
+	// Google Pay environments, matching the sandbox and production environments of GoPay
+	GooglePayEnvironmentTest       = "TEST"
+	GooglePayEnvironmentProduction = "PRODUCTION"
+
+	// Google Pay tokenization types: a token of the merchant's gateway, or a token encrypted
+	// for the merchant
+	GooglePayTokenizationGateway = "PAYMENT_GATEWAY"
+	GooglePayTokenizationDirect  = "DIRECT"
+
+	// googlePayProtocolVersion is the protocol version of DIRECT tokens
+	googlePayProtocolVersion = "ECv2"
+)
+
+var (
+	// ErrWalletPaymentInvalid is returned for a wallet payment that cannot be made as sent
+	ErrWalletPaymentInvalid = errors.New("invalid wallet payment")
+	// ErrWalletPaymentUnsupported is returned when the provider cannot charge the wallet
+	ErrWalletPaymentUnsupported = errors.New("provider does not support this wallet")
+)
+
+// WalletPayment is a payment token of a device wallet, sent instead of CardInfo
+type WalletPayment struct {
+	Type string `json:"type" validate:"required,oneof=apple_pay google_pay"`
+	// Token is the Apple Pay payment token (PKPaymentToken) as received from the device, for
+	// apple_pay
+	Token *ApplePayToken `json:"token,omitempty"`
+	// GooglePay is the payment data returned by the Google Pay API, for google_pay
+	GooglePay *GooglePayPaymentData `json:"googlePay,omitempty"`
+}
+
+// ApplePayToken is the payment token of an Apple Pay authorization
+type ApplePayToken struct {
+	PaymentData           ApplePayPaymentData   `json:"paymentData"`
+	PaymentMethod         ApplePayPaymentMethod `json:"paymentMethod"`
+	TransactionIdentifier string                `json:"transactionIdentifier"`
+}
+
+// ApplePayPaymentData is the encrypted part of an Apple Pay token
+type ApplePayPaymentData struct {
+	Version   string                `json:"version"`
+	Data      string                `json:"data"`
+	Signature string                `json:"signature"`
+	Header    ApplePayPaymentHeader `json:"header"`
+}
+
+// ApplePayPaymentHeader holds what is needed to decrypt the payment data
+type ApplePayPaymentHeader struct {
+	EphemeralPublicKey string `json:"ephemeralPublicKey"`
+	PublicKeyHash      string `json:"publicKeyHash"`
+	TransactionID      string `json:"transactionId"`
+	ApplicationData    string `json:"applicationData,omitempty"`
+}
+
+// ApplePayPaymentMethod describes the card the customer picked in the wallet
+type ApplePayPaymentMethod struct {
+	DisplayName string `json:"displayName"`
+	Network     string `json:"network"`
+	Type        string `json:"type"`
+}
+
+// ApplePayDecryptedData is the decrypted payment data of an Apple Pay token
+type ApplePayDecryptedData struct {
+	// ApplicationPrimaryAccountNumber is the device card number (DPAN), not the card's PAN
+	ApplicationPrimaryAccountNumber string `json:"applicationPrimaryAccountNumber"`
+	// ApplicationExpirationDate is YYMMDD
+	ApplicationExpirationDate string `json:"applicationExpirationDate"`
+	CurrencyCode              string `json:"currencyCode"`
+	TransactionAmount         int64  `json:"transactionAmount"`
+	CardholderName            string `json:"cardholderName,omitempty"`
+	PaymentDataType           string `json:"paymentDataType"`
+	PaymentData               struct {
+		OnlinePaymentCryptogram string `json:"onlinePaymentCryptogram,omitempty"`
+		ECIIndicator            string `json:"eciIndicator,omitempty"`
+	} `json:"paymentData"`
+}
+
+// CardInfo returns the device card as card details, for providers without a token field.
+// The cryptogram is not part of it.
+func (d *ApplePayDecryptedData) CardInfo() CardInfo {
+	card := CardInfo{CardHolderName: d.CardholderName, CardNumber: d.ApplicationPrimaryAccountNumber}
+	if len(d.ApplicationExpirationDate) == 6 {
+		card.ExpireYear = "20" + d.ApplicationExpirationDate[:2]
+		card.ExpireMonth = d.ApplicationExpirationDate[2:4]
+	}
+	return card
+}
+
+// GooglePayPaymentData is the PaymentData of a Google Pay authorization with the environment
+// the client loaded the Google Pay API in
+type GooglePayPaymentData struct {
+	// Environment is TEST or PRODUCTION and must match the environment of the payment
+	Environment       string                     `json:"environment"`
+	PaymentMethodData GooglePayPaymentMethodData `json:"paymentMethodData"`
+}
+
+// GooglePayPaymentMethodData describes the card the customer picked and carries its token
+type GooglePayPaymentMethodData struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Info        struct {
+		CardNetwork string `json:"cardNetwork,omitempty"`
+		CardDetails string `json:"cardDetails,omitempty"`
+	} `json:"info"`
+	TokenizationData struct {
+		// Type is PAYMENT_GATEWAY or DIRECT
+		Type string `json:"type"`
+		// Token is the token as a JSON string: the gateway's token for PAYMENT_GATEWAY, the
+		// signed and encrypted card for DIRECT
+		Token string `json:"token"`
+	} `json:"tokenizationData"`
+}
+
+// googlePayDirectToken is the structure of a DIRECT Google Pay token
+type googlePayDirectToken struct {
+	ProtocolVersion        string `json:"protocolVersion"`
+	Signature              string `json:"signature"`
+	SignedMessage          string `json:"signedMessage"`
+	IntermediateSigningKey struct {
+		SignedKey  string   `json:"signedKey"`
+		Signatures []string `json:"signatures"`
+	} `json:"intermediateSigningKey"`
+}
+
+// validate checks the structure of the payment data and that its environment matches the
+// environment (sandbox or production) it is charged in
+func (g *GooglePayPaymentData) validate(environment string) error {
+	expected := GooglePayEnvironmentTest
+	if environment == "production" {
+		expected = GooglePayEnvironmentProduction
+	}
+	if g.Environment != expected {
+		return fmt.Errorf("%w: Google Pay %s tokens cannot be charged in %s", ErrWalletPaymentInvalid, g.Environment, environment)
+	}
+
+	method := g.PaymentMethodData
+	if method.Type != "CARD" {
+		return fmt.Errorf("%w: Google Pay payment method must be CARD", ErrWalletPaymentInvalid)
+	}
+	if !json.Valid([]byte(method.TokenizationData.Token)) {
+		return fmt.Errorf("%w: Google Pay token is not JSON", ErrWalletPaymentInvalid)
+	}
+
+	switch method.TokenizationData.Type {
+	case GooglePayTokenizationGateway:
+	case GooglePayTokenizationDirect:
+		var token googlePayDirectToken
+		if err := json.Unmarshal([]byte(method.TokenizationData.Token), &token); err != nil {
+			return fmt.Errorf("%w: malformed Google Pay token", ErrWalletPaymentInvalid)
+		}
+		if token.ProtocolVersion != googlePayProtocolVersion {
+			return fmt.Errorf("%w: unsupported Google Pay protocol version %q", ErrWalletPaymentInvalid, token.ProtocolVersion)
+		}
+		if token.Signature == "" || token.SignedMessage == "" || token.IntermediateSigningKey.SignedKey == "" || len(token.IntermediateSigningKey.Signatures) == 0 {
+			return fmt.Errorf("%w: Google Pay token is not signed", ErrWalletPaymentInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: unknown Google Pay tokenization type %q", ErrWalletPaymentInvalid, method.TokenizationData.Type)
+	}
+	return nil
+}
+
+// WalletPaymentProvider is implemented by providers that can charge wallet tokens in
+// CreatePayment. Wallet payments are authenticated by the device, so they are never 3D.
+type WalletPaymentProvider interface {
+	// SupportedWallets returns the wallet types the provider accepts, e.g. WalletTypeApplePay
+	SupportedWallets() []string
+}
+
+// checkWalletPayment checks a wallet payment before it reaches provider
+func checkWalletPayment(provider PaymentProvider, request PaymentRequest) error {
+	wallet := request.WalletPayment
+	switch wallet.Type {
+	case WalletTypeApplePay:
+		if wallet.Token == nil || wallet.Token.PaymentData.Data == "" || wallet.Token.PaymentData.Header.EphemeralPublicKey == "" {
+			return fmt.Errorf("%w: token has no payment data", ErrWalletPaymentInvalid)
+		}
+	case WalletTypeGooglePay:
+		if wallet.GooglePay == nil {
+			return fmt.Errorf("%w: googlePay payment data is required", ErrWalletPaymentInvalid)
+		}
+		if err := wallet.GooglePay.validate(request.Environment); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown wallet type %q", ErrWalletPaymentInvalid, wallet.Type)
+	}
+	if !request.CardInfo.IsEmpty() {
+		return fmt.Errorf("%w: send either cardInfo or walletPayment", ErrWalletPaymentInvalid)
+	}
+	if request.Use3D {
+		return fmt.Errorf("%w: wallet payments are authenticated by the device and cannot use 3D", ErrWalletPaymentInvalid)
+	}
+
+	walletProvider, ok := provider.(WalletPaymentProvider)
+	if !ok || !slices.Contains(walletProvider.SupportedWallets(), wallet.Type) {
+		return fmt.Errorf("%w: %s", ErrWalletPaymentUnsupported, wallet.Type)
+	}
+	return nil
+}
+
+// ParseApplePayPrivateKey parses the PEM private key of an Apple Pay payment processing
+// certificate (SEC 1 or PKCS #8)
+func ParseApplePayPrivateKey(pemKey string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("apple pay private key is not PEM encoded")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apple pay private key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("apple pay private key is not an EC key")
+	}
+	return ecKey, nil
+}
+
+// ApplePayMerchant is what a provider needs to accept the Apple Pay tokens of a merchant
+type ApplePayMerchant struct {
+	// ID is the Apple Pay merchant identifier, e.g. merchant.com.example.shop
+	ID string
+	// PrivateKey is the key of the merchant's payment processing certificate
+	PrivateKey *ecdsa.PrivateKey
+	// Roots are the certificates token signatures must chain to, Apple Root CA - G3
+	Roots *x509.CertPool
+}
+
+// DecryptApplePayToken verifies the signature of an EC_v1 Apple Pay token and decrypts its
+// payment data with the merchant's payment processing key. The token must have been
+// authorized for the amount and currency of request.
+func DecryptApplePayToken(token ApplePayToken, merchant ApplePayMerchant, request PaymentRequest) (*ApplePayDecryptedData, error) {
+	paymentData := token.PaymentData
+	if paymentData.Version != applePayTokenVersion {
+		return nil, fmt.Errorf("%w: unsupported token version %q", ErrWalletPaymentInvalid, paymentData.Version)
+	}
+	if err := verifyApplePaySignature(paymentData, merchant.Roots, time.Now()); err != nil {
+		return nil, err
+	}
+	merchantID, privateKey := merchant.ID, merchant.PrivateKey
+
+	// The token must have been encrypted for this key
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode apple pay public key: %w", err)
+	}
+	publicKeyHash := sha256.Sum256(publicKeyDER)
+	if subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(publicKeyHash[:])), []byte(paymentData.Header.PublicKeyHash)) != 1 {
+		return nil, fmt.Errorf("%w: token was encrypted for another merchant key", ErrWalletPaymentInvalid)
+	}
+
+	ephemeralDER, err := base64.StdEncoding.DecodeString(paymentData.Header.EphemeralPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed ephemeral public key", ErrWalletPaymentInvalid)
+	}
+	ephemeralKey, err := x509.ParsePKIXPublicKey(ephemeralDER)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed ephemeral public key", ErrWalletPaymentInvalid)
+	}
+	ephemeralECDSA, ok := ephemeralKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: ephemeral public key is not an EC key", ErrWalletPaymentInvalid)
+	}
+	ephemeralECDH, err := ephemeralECDSA.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWalletPaymentInvalid, err)
+	}
+	privateECDH, err := privateKey.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("invalid apple pay private key: %w", err)
+	}
+	sharedSecret, err := privateECDH.ECDH(ephemeralECDH)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWalletPaymentInvalid, err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(paymentData.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed payment data", ErrWalletPaymentInvalid)
+	}
+	block, err := aes.NewCipher(applePaySymmetricKey(sharedSecret, merchantID))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, 16)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, make([]byte, 16), ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payment data could not be decrypted", ErrWalletPaymentInvalid)
+	}
+
+	var decrypted ApplePayDecryptedData
+	if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+		return nil, fmt.Errorf("%w: malformed decrypted payment data", ErrWalletPaymentInvalid)
+	}
+
+	// A token authorized for another payment must not be charged for this one
+	currency, err := CurrencyCode(request.Currency, CurrencyFormatNumeric)
+	if err != nil || decrypted.CurrencyCode != currency {
+		return nil, fmt.Errorf("%w: token was authorized in currency %s, not %s", ErrWalletPaymentInvalid, decrypted.CurrencyCode, request.Currency)
+	}
+	if amount := ToMinorUnits(request.Amount, request.Currency); decrypted.TransactionAmount != amount {
+		return nil, fmt.Errorf("%w: token was authorized for %d, not %d", ErrWalletPaymentInvalid, decrypted.TransactionAmount, amount)
+	}
+	return &decrypted, nil
+}
+
+// applePaySymmetricKey derives the AES-256 key of a token with the NIST SP 800-56A
+// single-step KDF Apple specifies for EC_v1
+func applePaySymmetricKey(sharedSecret []byte, merchantID string) []byte {
+	merchantIDHash := sha256.Sum256([]byte(merchantID))
+
+	hash := sha256.New()
+	_ = binary.Write(hash, binary.BigEndian, uint32(1))
+	hash.Write(sharedSecret)
+	hash.Write([]byte("\x0did-aes256-GCM"))
+	hash.Write([]byte("Apple"))
+	hash.Write(merchantIDHash[:])
+	return hash.Sum(nil)
+}
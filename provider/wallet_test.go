@@ -0,0 +1,490 @@
+package provider
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+const testApplePayMerchantID = "merchant.com.example.shop"
+
+// applePayTestChain stands in for Apple's signing chain: a root, an intermediate and a leaf
+// carrying the Apple Pay extensions
+type applePayTestChain struct {
+	root         *x509.Certificate
+	roots        *x509.CertPool
+	intermediate *x509.Certificate
+	leaf         *x509.Certificate
+	leafKey      *ecdsa.PrivateKey
+}
+
+func newApplePayTestChain(t *testing.T) *applePayTestChain {
+	t.Helper()
+	now := time.Now()
+	issue := func(serial int64, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore, template.NotAfter = now.Add(-time.Hour), now.Add(time.Hour)
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certificate, _ := x509.ParseCertificate(der)
+		return certificate, key
+	}
+	appleExtension := func(oid asn1.ObjectIdentifier) []pkix.Extension {
+		return []pkix.Extension{{Id: oid, Value: []byte{0x05, 0x00}}}
+	}
+
+	chain := &applePayTestChain{roots: x509.NewCertPool()}
+	root, rootKey := issue(1, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "Test Root CA - G3"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	intermediate, intermediateKey := issue(2, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "Test Application Integration CA - G3"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+		ExtraExtensions: appleExtension(oidApplePayIntermediate),
+	}, root, rootKey)
+	chain.leaf, chain.leafKey = issue(3, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "ecc-smp-broker-sign_UC4-PROD"}, KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtraExtensions: appleExtension(oidApplePayLeaf),
+	}, intermediate, intermediateKey)
+	chain.root, chain.intermediate = root, intermediate
+	chain.roots.AddCert(root)
+	return chain
+}
+
+// sign sets the detached PKCS #7 signature Apple makes over the payment data of token
+func (c *applePayTestChain) sign(t *testing.T, token *ApplePayToken, signingTime time.Time) {
+	t.Helper()
+	content, err := applePaySignedContent(token.PaymentData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(content)
+
+	attribute := func(oid asn1.ObjectIdentifier, value any) []byte {
+		valueDER, err := asn1.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, _ := asn1.Marshal(cmsAttribute{Type: oid, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: valueDER}})
+		return der
+	}
+	attributes := slices.Concat(
+		attribute(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}),
+		attribute(oidCMSSigningTime, signingTime.UTC()),
+		attribute(oidCMSMessageDigest, digest[:]),
+	)
+	attributesSet, _ := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attributes})
+	attributesDigest := sha256.Sum256(attributesSet)
+	signature, err := ecdsa.SignASN1(rand.Reader, c.leafKey, attributesDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}}
+	digestAlgorithms, _ := asn1.Marshal(sha256Algorithm)
+	issuerAndSerial, _ := asn1.Marshal(struct {
+		Issuer asn1.RawValue
+		Serial *big.Int
+	}{asn1.RawValue{FullBytes: c.leaf.RawIssuer}, c.leaf.SerialNumber})
+	encapContentInfo, _ := asn1.Marshal(struct{ Type asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+
+	signedData, err := asn1.Marshal(cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: digestAlgorithms},
+		EncapContentInfo: asn1.RawValue{FullBytes: encapContentInfo},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: slices.Concat(c.leaf.Raw, c.intermediate.Raw)},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: issuerAndSerial},
+			DigestAlgorithm:    sha256Algorithm,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attributes},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentInfo, err := asn1.Marshal(cmsContentInfo{
+		ContentType: oidCMSSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.PaymentData.Signature = base64.StdEncoding.EncodeToString(contentInfo)
+}
+
+// sealApplePayToken encrypts plaintext for merchantKey and signs it with chain the way Apple
+// Pay does for EC_v1
+func sealApplePayToken(t *testing.T, chain *applePayTestChain, merchantKey *ecdsa.PrivateKey, merchantID, plaintext string) ApplePayToken {
+	t.Helper()
+	ephemeralKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ephemeralECDH, _ := ephemeralKey.ECDH()
+	merchantPublic, _ := merchantKey.PublicKey.ECDH()
+	sharedSecret, err := ephemeralECDH.ECDH(merchantPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := aes.NewCipher(applePaySymmetricKey(sharedSecret, merchantID))
+	gcm, _ := cipher.NewGCMWithNonceSize(block, 16)
+	ciphertext := gcm.Seal(nil, make([]byte, 16), []byte(plaintext), nil)
+
+	ephemeralDER, _ := x509.MarshalPKIXPublicKey(&ephemeralKey.PublicKey)
+	merchantDER, _ := x509.MarshalPKIXPublicKey(&merchantKey.PublicKey)
+	publicKeyHash := sha256.Sum256(merchantDER)
+
+	token := ApplePayToken{
+		PaymentData: ApplePayPaymentData{
+			Version: "EC_v1",
+			Data:    base64.StdEncoding.EncodeToString(ciphertext),
+			Header: ApplePayPaymentHeader{
+				EphemeralPublicKey: base64.StdEncoding.EncodeToString(ephemeralDER),
+				PublicKeyHash:      base64.StdEncoding.EncodeToString(publicKeyHash[:]),
+				TransactionID:      "c1caf5ae72f0039a82bad92b828363734f85bf2f9cadf193d1bad9ddcb60a795",
+			},
+		},
+		PaymentMethod:         ApplePayPaymentMethod{DisplayName: "Visa 0121", Network: "Visa", Type: "debit"},
+		TransactionIdentifier: "C1CAF5AE72F0039A82BAD92B828363734F85BF2F9CADF193D1BAD9DDCB60A795",
+	}
+	chain.sign(t, &token, time.Now())
+	return token
+}
+
+const testApplePayPlaintext = `{
+	"applicationPrimaryAccountNumber": "4817499999990121",
+	"applicationExpirationDate": "281231",
+	"currencyCode": "949",
+	"transactionAmount": 10000,
+	"deviceManufacturerIdentifier": "040010030273",
+	"paymentDataType": "3DSecure",
+	"paymentData": {"onlinePaymentCryptogram": "Af9x/QwAA/DjmU65oyc1MAABAAA=", "eciIndicator": "5"}
+}`
+
+// testApplePayRequest is the payment testApplePayPlaintext was authorized for
+var testApplePayRequest = PaymentRequest{Amount: 100, Currency: "TRY"}
+
+func TestDecryptApplePayToken(t *testing.T) {
+	chain := newApplePayTestChain(t)
+	merchantKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merchant := ApplePayMerchant{ID: testApplePayMerchantID, PrivateKey: merchantKey, Roots: chain.roots}
+	token := sealApplePayToken(t, chain, merchantKey, testApplePayMerchantID, testApplePayPlaintext)
+
+	decrypted, err := DecryptApplePayToken(token, merchant, testApplePayRequest)
+	if err != nil {
+		t.Fatalf("DecryptApplePayToken failed: %v", err)
+	}
+	if decrypted.TransactionAmount != 10000 || decrypted.PaymentData.OnlinePaymentCryptogram == "" {
+		t.Errorf("Unexpected decrypted data: %+v", decrypted)
+	}
+	card := decrypted.CardInfo()
+	if card.CardNumber != "4817499999990121" || card.ExpireYear != "2028" || card.ExpireMonth != "12" || card.CVV != "" {
+		t.Errorf("Unexpected device card: %+v", card)
+	}
+
+	otherMerchant := merchant
+	otherMerchant.ID = "merchant.com.example.other"
+	if _, err := DecryptApplePayToken(token, otherMerchant, testApplePayRequest); !errors.Is(err, ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for another merchant ID, got %v", err)
+	}
+	otherKey := merchant
+	otherKey.PrivateKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := DecryptApplePayToken(token, otherKey, testApplePayRequest); !errors.Is(err, ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for another merchant key, got %v", err)
+	}
+	token.PaymentData.Version = "RSA_v1"
+	if _, err := DecryptApplePayToken(token, merchant, testApplePayRequest); !errors.Is(err, ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for RSA_v1, got %v", err)
+	}
+}
+
+func TestDecryptApplePayToken_RejectsUntrustedSignatures(t *testing.T) {
+	chain := newApplePayTestChain(t)
+	merchantKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	merchant := ApplePayMerchant{ID: testApplePayMerchantID, PrivateKey: merchantKey, Roots: chain.roots}
+
+	tests := []struct {
+		name   string
+		tamper func(token *ApplePayToken)
+	}{
+		{name: "unsigned", tamper: func(token *ApplePayToken) { token.PaymentData.Signature = "" }},
+		{name: "signed too long ago", tamper: func(token *ApplePayToken) { chain.sign(t, token, time.Now().Add(-time.Hour)) }},
+		{name: "signed in the future", tamper: func(token *ApplePayToken) { chain.sign(t, token, time.Now().Add(time.Hour)) }},
+		{name: "chain to another root", tamper: func(token *ApplePayToken) { newApplePayTestChain(t).sign(t, token, time.Now()) }},
+		{name: "leaf without the apple pay extension", tamper: func(token *ApplePayToken) {
+			other := *chain
+			other.leaf = other.intermediate
+			other.sign(t, token, time.Now())
+		}},
+		{name: "payment data changed after signing", tamper: func(token *ApplePayToken) {
+			token.PaymentData.Header.TransactionID = "00" + token.PaymentData.Header.TransactionID[2:]
+		}},
+		{name: "signature by another key", tamper: func(token *ApplePayToken) {
+			other := *chain
+			other.leafKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			other.sign(t, token, time.Now())
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sealApplePayToken(t, chain, merchantKey, testApplePayMerchantID, testApplePayPlaintext)
+			tt.tamper(&token)
+			if _, err := DecryptApplePayToken(token, merchant, testApplePayRequest); !errors.Is(err, ErrWalletPaymentInvalid) {
+				t.Errorf("Expected ErrWalletPaymentInvalid, got %v", err)
+			}
+		})
+	}
+
+	// Without a configured root no token is trusted
+	token := sealApplePayToken(t, chain, merchantKey, testApplePayMerchantID, testApplePayPlaintext)
+	merchant.Roots = nil
+	if _, err := DecryptApplePayToken(token, merchant, testApplePayRequest); err == nil {
+		t.Error("Expected an error without Apple Pay roots")
+	}
+}
+
+func TestDecryptApplePayToken_RejectsAnotherPayment(t *testing.T) {
+	chain := newApplePayTestChain(t)
+	merchantKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	merchant := ApplePayMerchant{ID: testApplePayMerchantID, PrivateKey: merchantKey, Roots: chain.roots}
+	token := sealApplePayToken(t, chain, merchantKey, testApplePayMerchantID, testApplePayPlaintext)
+
+	for name, request := range map[string]PaymentRequest{
+		"another amount":   {Amount: 1000, Currency: "TRY"},
+		"another currency": {Amount: 100, Currency: "USD"},
+		"unknown currency": {Amount: 100, Currency: "XXX"},
+	} {
+		if _, err := DecryptApplePayToken(token, merchant, request); !errors.Is(err, ErrWalletPaymentInvalid) {
+			t.Errorf("%s: expected ErrWalletPaymentInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadApplePayRoots(t *testing.T) {
+	chain := newApplePayTestChain(t)
+	path := filepath.Join(t.TempDir(), "AppleRootCA-G3.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: chain.root.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("APPLE_PAY_ROOT_CA_FILE", "")
+	if _, err := LoadApplePayRoots(); err == nil {
+		t.Error("Expected an error without APPLE_PAY_ROOT_CA_FILE")
+	}
+
+	t.Setenv("APPLE_PAY_ROOT_CA_FILE", path)
+	roots, err := LoadApplePayRoots()
+	if err != nil {
+		t.Fatalf("LoadApplePayRoots failed: %v", err)
+	}
+	if _, err := chain.intermediate.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Errorf("Expected the intermediate to chain to the loaded root: %v", err)
+	}
+}
+
+func TestParseApplePayPrivateKey(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sec1, _ := x509.MarshalECPrivateKey(key)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	for name, block := range map[string]*pem.Block{
+		"SEC 1":   {Type: "EC PRIVATE KEY", Bytes: sec1},
+		"PKCS #8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		parsed, err := ParseApplePayPrivateKey(string(pem.EncodeToMemory(block)))
+		if err != nil || !parsed.Equal(key) {
+			t.Errorf("%s: expected the key to be parsed, got %v", name, err)
+		}
+	}
+	if _, err := ParseApplePayPrivateKey("not a key"); err == nil {
+		t.Error("Expected an error for a key that is not PEM")
+	}
+}
+
+// walletTestProvider charges Apple Pay and Google Pay tokens
+type walletTestProvider struct {
+	riskTestProvider
+	requests []PaymentRequest
+}
+
+func (p *walletTestProvider) SupportedWallets() []string {
+	return []string{WalletTypeApplePay, WalletTypeGooglePay}
+}
+
+func (p *walletTestProvider) CreatePayment(_ context.Context, request PaymentRequest) (*PaymentResponse, error) {
+	p.requests = append(p.requests, request)
+	return &PaymentResponse{Success: true, Status: StatusSuccessful, PaymentID: "pay_wallet"}, nil
+}
+
+func TestPaymentService_WalletPayment(t *testing.T) {
+	const tenantID = 9139
+
+	fake := &walletTestProvider{}
+	GetProviderCache().Set(tenantID, "wallettest", "sandbox", fake)
+	GetProviderCache().Set(tenantID, "walletless", "sandbox", &riskTestProvider{})
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "wallettest", "sandbox")
+		GetProviderCache().Delete(tenantID, "walletless", "sandbox")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9139")
+
+	walletRequest := func() PaymentRequest {
+		request := riskRequest()
+		request.CardInfo = CardInfo{}
+		request.WalletPayment = &WalletPayment{
+			Type:  WalletTypeApplePay,
+			Token: &ApplePayToken{PaymentData: ApplePayPaymentData{Version: "EC_v1", Data: "ZGF0YQ==", Header: ApplePayPaymentHeader{EphemeralPublicKey: "a2V5"}}},
+		}
+		return request
+	}
+
+	response, err := service.CreatePayment(ctx, "sandbox", "wallettest", walletRequest())
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !response.Success || len(fake.requests) != 1 || fake.requests[0].WalletPayment == nil {
+		t.Errorf("Expected the wallet token to reach the provider, got %+v", fake.requests)
+	}
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "walletless", walletRequest()); !errors.Is(err, ErrWalletPaymentUnsupported) {
+		t.Errorf("Expected ErrWalletPaymentUnsupported, got %v", err)
+	}
+
+	invalid := map[string]func(*PaymentRequest){
+		"with card":    func(r *PaymentRequest) { r.CardInfo = CardInfo{CardNumber: "5528790000000008"} },
+		"3D":           func(r *PaymentRequest) { r.Use3D = true },
+		"no token":     func(r *PaymentRequest) { r.WalletPayment.Token = nil },
+		"unknown type": func(r *PaymentRequest) { r.WalletPayment.Type = "paypal" },
+	}
+	for name, mutate := range invalid {
+		request := walletRequest()
+		mutate(&request)
+		if _, err := service.CreatePayment(ctx, "sandbox", "wallettest", request); !errors.Is(err, ErrWalletPaymentInvalid) {
+			t.Errorf("%s: expected ErrWalletPaymentInvalid, got %v", name, err)
+		}
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("Expected rejected wallet payments not to reach the provider, got %d calls", len(fake.requests))
+	}
+}
+
+// googlePayData returns Google Pay payment data with a gateway token
+func googlePayData(environment string) *GooglePayPaymentData {
+	data := &GooglePayPaymentData{Environment: environment}
+	data.PaymentMethodData.Type = "CARD"
+	data.PaymentMethodData.TokenizationData.Type = GooglePayTokenizationGateway
+	data.PaymentMethodData.TokenizationData.Token = `{"id": "tok_googlepay", "livemode": false}`
+	return data
+}
+
+func TestGooglePayPaymentData_Validate(t *testing.T) {
+	if err := googlePayData(GooglePayEnvironmentTest).validate("sandbox"); err != nil {
+		t.Errorf("Expected a TEST token to be valid in sandbox, got %v", err)
+	}
+	if err := googlePayData(GooglePayEnvironmentProduction).validate("production"); err != nil {
+		t.Errorf("Expected a PRODUCTION token to be valid in production, got %v", err)
+	}
+
+	direct := googlePayData(GooglePayEnvironmentTest)
+	direct.PaymentMethodData.TokenizationData.Type = GooglePayTokenizationDirect
+	direct.PaymentMethodData.TokenizationData.Token = `{"protocolVersion": "ECv2", "signature": "MEQCIH6Q4OwQ0jAceFEkGF0JID6sJNXxOEi4r+mA7biRxqBQAiAondqoUpU/bdsrAOpZIsrHQS9nwiiNwOrr24RyPeHA0Q==", "intermediateSigningKey": {"signedKey": "{\"keyValue\":\"MFkw\",\"keyExpiration\":\"1542323393147\"}", "signatures": ["MEYCIQCO2EIi48s8VTH+ilMEpoXLFfkxAwHjfPSCVED/QDSHmQIhALLJmrUlNAY8hDQRV/y1iKZGsWpeNmIP+z+tCQHQxP0v"]}, "signedMessage": "{\"tag\":\"jpGz1F1Bcoi/fCNxI9n7Qrsw7i7KHrGtTf3NrRclt+U=\"}"}`
+	if err := direct.validate("sandbox"); err != nil {
+		t.Errorf("Expected a signed DIRECT token to be valid, got %v", err)
+	}
+
+	invalid := map[string]func(*GooglePayPaymentData){
+		"environment":       func(g *GooglePayPaymentData) { g.Environment = GooglePayEnvironmentProduction },
+		"payment method":    func(g *GooglePayPaymentData) { g.PaymentMethodData.Type = "PAYPAL" },
+		"tokenization type": func(g *GooglePayPaymentData) { g.PaymentMethodData.TokenizationData.Type = "NETWORK" },
+		"token not JSON":    func(g *GooglePayPaymentData) { g.PaymentMethodData.TokenizationData.Token = "tok_googlepay" },
+		"unsigned DIRECT": func(g *GooglePayPaymentData) {
+			g.PaymentMethodData.TokenizationData.Type = GooglePayTokenizationDirect
+			g.PaymentMethodData.TokenizationData.Token = `{"protocolVersion": "ECv2", "signedMessage": "{}"}`
+		},
+		"DIRECT version": func(g *GooglePayPaymentData) {
+			g.PaymentMethodData.TokenizationData.Type = GooglePayTokenizationDirect
+			g.PaymentMethodData.TokenizationData.Token = `{"protocolVersion": "ECv1"}`
+		},
+	}
+	for name, mutate := range invalid {
+		data := googlePayData(GooglePayEnvironmentTest)
+		mutate(data)
+		if err := data.validate("sandbox"); !errors.Is(err, ErrWalletPaymentInvalid) {
+			t.Errorf("%s: expected ErrWalletPaymentInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestPaymentService_GooglePayPayment(t *testing.T) {
+	const tenantID = 9140
+
+	fake := &walletTestProvider{}
+	GetProviderCache().Set(tenantID, "wallettest", "sandbox", fake)
+	GetProviderCache().Set(tenantID, "wallettest", "production", fake)
+	t.Cleanup(func() {
+		GetProviderCache().Delete(tenantID, "wallettest", "sandbox")
+		GetProviderCache().Delete(tenantID, "wallettest", "production")
+	})
+
+	service := NewPaymentService(&recordingPaymentLogger{})
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9140")
+
+	request := riskRequest()
+	request.CardInfo = CardInfo{}
+	request.WalletPayment = &WalletPayment{Type: WalletTypeGooglePay, GooglePay: googlePayData(GooglePayEnvironmentTest)}
+
+	if _, err := service.CreatePayment(ctx, "sandbox", "wallettest", request); err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if len(fake.requests) != 1 || fake.requests[0].WalletPayment.GooglePay == nil {
+		t.Errorf("Expected the Google Pay token to reach the provider, got %+v", fake.requests)
+	}
+
+	// A TEST token charged with the production configuration of the tenant
+	if _, err := service.CreatePayment(ctx, "production", "wallettest", request); !errors.Is(err, ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid for a TEST token in production, got %v", err)
+	}
+	request.WalletPayment.GooglePay = nil
+	if _, err := service.CreatePayment(ctx, "sandbox", "wallettest", request); !errors.Is(err, ErrWalletPaymentInvalid) {
+		t.Errorf("Expected ErrWalletPaymentInvalid without payment data, got %v", err)
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("Expected rejected Google Pay payments not to reach the provider, got %d calls", len(fake.requests))
+	}
+}
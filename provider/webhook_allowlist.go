@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+// WebhookAllowlistFromEnv reads WEBHOOK_ALLOWED_IPS, the comma separated IPs and CIDR ranges a
+// provider sends its webhooks from. A provider specific value, e.g. WEBHOOK_ALLOWED_IPS_PAYTR,
+// takes precedence over the global one. ok is false when no allowlist is configured; invalid
+// entries are logged and skipped.
+func WebhookAllowlistFromEnv(providerName string) (prefixes []netip.Prefix, ok bool) {
+	for _, name := range []string{"WEBHOOK_ALLOWED_IPS_" + strings.ToUpper(providerName), "WEBHOOK_ALLOWED_IPS"} {
+		value := strings.TrimSpace(config.GetEnv(name, ""))
+		if value == "" {
+			continue
+		}
+
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			prefix, err := parseAllowlistEntry(entry)
+			if err != nil {
+				logger.Warn("Ignoring invalid webhook allowlist entry", logger.LogContext{
+					Provider: providerName,
+					Fields: map[string]any{
+						"env":   name,
+						"value": entry,
+					},
+				})
+				continue
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		return prefixes, true
+	}
+	return nil, false
+}
+
+// parseAllowlistEntry parses a CIDR range or a single IP, which is a range of one address
+func parseAllowlistEntry(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// WebhookSourceAllowed reports whether a webhook from remoteAddr, an IP with or without a port,
+// is accepted for the provider. Without an allowlist every source is accepted and the webhook
+// relies on its signature alone. An allowlist without any valid entry accepts no source.
+func WebhookSourceAllowed(providerName, remoteAddr string) bool {
+	prefixes, ok := WebhookAllowlistFromEnv(providerName)
+	if !ok {
+		return true
+	}
+
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(host))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
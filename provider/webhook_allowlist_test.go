@@ -0,0 +1,44 @@
+package provider
+
+import "testing"
+
+func TestWebhookSourceAllowed(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOWED_IPS", "10.0.0.1")
+	t.Setenv("WEBHOOK_ALLOWED_IPS_PAYTR", "185.12.0.0/22, 2001:db8::/32, not-an-ip")
+
+	tests := []struct {
+		name       string
+		provider   string
+		remoteAddr string
+		want       bool
+	}{
+		{"address in provider range", "paytr", "185.12.1.20", true},
+		{"address with port in provider range", "paytr", "185.12.3.255:44321", true},
+		{"IPv4-mapped address in provider range", "paytr", "::ffff:185.12.2.1", true},
+		{"IPv6 address in provider range", "paytr", "[2001:db8::1]:443", true},
+		{"address outside provider range", "paytr", "185.12.4.1", false},
+		{"global allowlist does not widen provider one", "paytr", "10.0.0.1", false},
+		{"unparsable address", "paytr", "unknown", false},
+		{"global allowlist", "papara", "10.0.0.1:8080", true},
+		{"outside global allowlist", "papara", "10.0.0.2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WebhookSourceAllowed(tt.provider, tt.remoteAddr); got != tt.want {
+				t.Errorf("WebhookSourceAllowed(%q, %q) = %v, want %v", tt.provider, tt.remoteAddr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSourceAllowed_Unconfigured(t *testing.T) {
+	if !WebhookSourceAllowed("iyzico", "203.0.113.7:443") {
+		t.Error("expected every source to be allowed without an allowlist")
+	}
+
+	t.Setenv("WEBHOOK_ALLOWED_IPS_IYZICO", "not-an-ip")
+	if WebhookSourceAllowed("iyzico", "203.0.113.7:443") {
+		t.Error("expected an allowlist without valid entries to allow no source")
+	}
+}
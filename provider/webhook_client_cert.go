@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"sync"
+
+	"github.com/mstgnz/gopay/infra/fingerprint"
+)
+
+// WebhookClientCertConfigKey is the tenant config key that pins the client certificates a
+// provider must present on its webhooks, as comma separated SHA-256 fingerprints. It is set
+// per provider and environment like the provider credentials; without it webhooks need no
+// client certificate.
+const WebhookClientCertConfigKey = "webhookClientCertFingerprints"
+
+// webhookClientCertPins holds the pinned fingerprints of loaded providers by cache key
+var webhookClientCertPins sync.Map
+
+// setWebhookClientCertPins records the tenant's WebhookClientCertConfigKey value of a loaded
+// provider
+func setWebhookClientCertPins(tenantID int, providerName, environment, value string) {
+	key := generateCacheKey(tenantID, providerName, environment)
+	fingerprints := fingerprint.Parse(value)
+	if len(fingerprints) == 0 {
+		webhookClientCertPins.Delete(key)
+		return
+	}
+	webhookClientCertPins.Store(key, fingerprints)
+}
+
+// WebhookClientCertPins returns the client certificate fingerprints a tenant pins for the
+// webhooks of a provider, loading the provider's configuration if needed. None means the
+// webhooks need no client certificate.
+func WebhookClientCertPins(tenantID int, providerName, environment string) ([]string, error) {
+	if _, err := GetProvider(tenantID, providerName, environment); err != nil {
+		return nil, err
+	}
+	fingerprints, _ := webhookClientCertPins.Load(generateCacheKey(tenantID, providerName, environment))
+	pins, _ := fingerprints.([]string)
+	return pins, nil
+}
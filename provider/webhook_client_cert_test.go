@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWebhookClientCertPins(t *testing.T) {
+	Register("pintest", func() PaymentProvider { return &sourcedTestProvider{} })
+
+	pinned := strings.Repeat("ab", 32)
+	SetTenantConfigSource(TenantConfigFunc(func(tenantID int, providerName, environment string) (map[string]string, error) {
+		if tenantID == 9301 {
+			return map[string]string{"apiKey": "api_123", "secretKey": "secret_123", WebhookClientCertConfigKey: "SHA256:" + strings.ToUpper(pinned[:2]) + ":" + pinned[2:]}, nil
+		}
+		return map[string]string{"apiKey": "api_123", "secretKey": "secret_123"}, nil
+	}))
+	t.Cleanup(func() { SetTenantConfigSource(nil) })
+
+	pins, err := WebhookClientCertPins(9301, "pintest", "sandbox")
+	if err != nil {
+		t.Fatalf("WebhookClientCertPins failed: %v", err)
+	}
+	if len(pins) != 1 || pins[0] != pinned {
+		t.Errorf("Expected the normalized pin %s, got %v", pinned, pins)
+	}
+
+	// A tenant without pins needs no client certificate
+	if pins, err := WebhookClientCertPins(9302, "pintest", "sandbox"); err != nil || len(pins) != 0 {
+		t.Errorf("Expected no pins, got %v (%v)", pins, err)
+	}
+}
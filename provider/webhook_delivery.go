@@ -0,0 +1,421 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/logger"
+)
+
+const (
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff         = 6 * time.Hour
+	webhookBatchSize          = 50
+
+	WebhookDeliveryStatusPending    = "pending"
+	WebhookDeliveryStatusDelivering = "delivering"
+	WebhookDeliveryStatusDelivered  = "delivered"
+	WebhookDeliveryStatusDead       = "dead"
+)
+
+var (
+	// ErrWebhookDeliveryNotFound is returned when a delivery does not exist for the tenant
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrWebhookDeliveryInProgress is returned when a delivery is replayed while an attempt
+	// is being made
+	ErrWebhookDeliveryInProgress = errors.New("webhook delivery is being attempted")
+)
+
+// WebhookDelivery is an outbound webhook and the state of its delivery. The body and
+// signature are stored as sent, so a retry or replay posts exactly the same bytes.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	TenantID       int        `json:"tenantId"`
+	Event          string     `json:"event"`
+	URL            string     `json:"url"`
+	Body           []byte     `json:"-"`
+	Signature      string     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// WebhookDeliveryStore persists outbound webhooks so pending retries survive restarts and
+// exhausted ones can be replayed.
+type WebhookDeliveryStore interface {
+	// Create stores a pending delivery and sets its ID
+	Create(ctx context.Context, delivery *WebhookDelivery) error
+
+	// ClaimDue marks up to limit pending deliveries due at now as delivering, counts the
+	// attempt and returns them
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+
+	// MarkDelivered records a successful attempt
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+
+	// Retry puts a claimed delivery back to pending for another attempt at nextAttemptAt
+	Retry(ctx context.Context, id int64, nextAttemptAt time.Time, statusCode int, lastError string) error
+
+	// DeadLetter marks a delivery whose attempts ran out as dead and records it in the
+	// dead-letter table
+	DeadLetter(ctx context.Context, id int64, statusCode int, lastError string) error
+
+	// Replay puts a delivery of the tenant back to pending with a fresh set of attempts. It
+	// returns ErrWebhookDeliveryNotFound when the tenant has no such delivery and
+	// ErrWebhookDeliveryInProgress while it is being attempted.
+	Replay(ctx context.Context, tenantID int, id int64, now time.Time) (*WebhookDelivery, error)
+}
+
+// WebhookDispatcher delivers outbound webhooks, retrying failed attempts with exponential
+// backoff and moving deliveries that keep failing to the dead-letter table.
+type WebhookDispatcher struct {
+	store       WebhookDeliveryStore
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	now         func() time.Time
+}
+
+// NewWebhookDispatcher creates a dispatcher. The number of attempts before a delivery is
+// dead-lettered is read from WEBHOOK_MAX_ATTEMPTS (default 8) and the delay before the first
+// retry from WEBHOOK_RETRY_BACKOFF_SECONDS (default 30); each further retry waits twice as
+// long, up to 6 hours.
+func NewWebhookDispatcher(store WebhookDeliveryStore) *WebhookDispatcher {
+	maxAttempts := config.GetIntEnv("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts)
+	if maxAttempts <= 0 {
+		maxAttempts = defaultWebhookMaxAttempts
+	}
+	backoff := defaultWebhookBaseBackoff
+	if seconds := config.GetIntEnv("WEBHOOK_RETRY_BACKOFF_SECONDS", 0); seconds > 0 {
+		backoff = time.Duration(seconds) * time.Second
+	}
+
+	return &WebhookDispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		now:         time.Now,
+	}
+}
+
+// retryDelay returns how long to wait after the given failed attempt
+func (d *WebhookDispatcher) retryDelay(attempt int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempt && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
+
+// Enqueue stores a delivery and makes the first attempt in the background
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, delivery WebhookDelivery) error {
+	delivery.Status = WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = d.now()
+	if err := d.store.Create(ctx, &delivery); err != nil {
+		return err
+	}
+	go d.runDueInBackground()
+	return nil
+}
+
+// Replay re-sends a delivery of the tenant in ctx, dead-lettered or not
+func (d *WebhookDispatcher) Replay(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	tenantID, err := getTenantIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := d.store.Replay(ctx, tenantID, id, d.now())
+	if err != nil {
+		return nil, err
+	}
+	go d.runDueInBackground()
+	return delivery, nil
+}
+
+func (d *WebhookDispatcher) runDueInBackground() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := d.RunDue(ctx); err != nil {
+		logger.Warn("Failed to run webhook deliveries", logger.LogContext{
+			Fields: map[string]any{
+				"error": err.Error(),
+			},
+		})
+	}
+}
+
+// RunDue attempts every delivery whose next attempt is due and returns how many were delivered
+func (d *WebhookDispatcher) RunDue(ctx context.Context) (int, error) {
+	deliveries, err := d.store.ClaimDue(ctx, d.now(), webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		statusCode, postErr := d.post(ctx, delivery)
+		if postErr == nil {
+			delivered++
+			err = d.store.MarkDelivered(ctx, delivery.ID, statusCode)
+		} else {
+			logger.Warn("Webhook delivery attempt failed", logger.LogContext{
+				TenantID: strconv.Itoa(delivery.TenantID),
+				Fields: map[string]any{
+					"delivery_id": delivery.ID,
+					"event":       delivery.Event,
+					"attempts":    delivery.Attempts,
+					"error":       postErr.Error(),
+				},
+			})
+			if delivery.Attempts >= d.maxAttempts {
+				err = d.store.DeadLetter(ctx, delivery.ID, statusCode, postErr.Error())
+			} else {
+				err = d.store.Retry(ctx, delivery.ID, d.now().Add(d.retryDelay(delivery.Attempts)), statusCode, postErr.Error())
+			}
+		}
+		if err != nil {
+			logger.Warn("Failed to update webhook delivery", logger.LogContext{
+				Fields: map[string]any{
+					"delivery_id": delivery.ID,
+					"error":       err.Error(),
+				},
+			})
+		}
+	}
+
+	return delivered, nil
+}
+
+// Start runs due deliveries every interval until ctx is done
+func (d *WebhookDispatcher) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, interval)
+			if _, err := d.RunDue(runCtx); err != nil {
+				logger.Warn("Failed to run webhook deliveries", logger.LogContext{
+					Fields: map[string]any{
+						"error": err.Error(),
+					},
+				})
+			}
+			cancel()
+		}
+	}
+}
+
+// post sends the delivery once. It returns the status code the receiver answered with, 0
+// when there was no answer, and an error unless the status is 2xx.
+func (d *WebhookDispatcher) post(ctx context.Context, delivery WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GoPay-Event", delivery.Event)
+	req.Header.Set("X-GoPay-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(PaymentLinkSignatureHeader, delivery.Signature)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// PostgresWebhookDeliveryStore keeps deliveries in the webhook_deliveries table and exhausted
+// ones in webhook_dead_letters.
+type PostgresWebhookDeliveryStore struct {
+	db *sql.DB
+}
+
+// NewPostgresWebhookDeliveryStore creates a store over the shared *sql.DB connection.
+func NewPostgresWebhookDeliveryStore(db *sql.DB) *PostgresWebhookDeliveryStore {
+	return &PostgresWebhookDeliveryStore{db: db}
+}
+
+const webhookDeliveryColumns = `id, tenant_id, event, url, body, signature, status, attempts, next_attempt_at,
+	COALESCE(last_status_code, 0), COALESCE(last_error, ''), delivered_at, created_at`
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }) (*WebhookDelivery, error) {
+	var (
+		delivery    WebhookDelivery
+		deliveredAt sql.NullTime
+	)
+	if err := row.Scan(&delivery.ID, &delivery.TenantID, &delivery.Event, &delivery.URL, &delivery.Body, &delivery.Signature,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError,
+		&deliveredAt, &delivery.CreatedAt); err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
+
+// Create inserts a pending delivery
+func (r *PostgresWebhookDeliveryStore) Create(ctx context.Context, delivery *WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (tenant_id, event, url, body, signature, status, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		delivery.TenantID, delivery.Event, delivery.URL, delivery.Body, delivery.Signature, WebhookDeliveryStatusPending, delivery.NextAttemptAt,
+	).Scan(&delivery.ID, &delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue claims due deliveries. SKIP LOCKED lets several GoPay instances poll the same
+// table without posting a delivery twice.
+func (r *PostgresWebhookDeliveryStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries SET status = $3, attempts = attempts + 1, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.QueryContext(ctx, query, now, WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivering, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered records a successful attempt
+func (r *PostgresWebhookDeliveryStore) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries SET status = $2, last_status_code = $3, last_error = NULL,
+			delivered_at = now(), updated_at = now()
+		WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, WebhookDeliveryStatusDelivered, statusCode); err != nil {
+		return fmt.Errorf("failed to mark webhook delivery as delivered: %w", err)
+	}
+	return nil
+}
+
+// Retry puts a claimed delivery back to pending
+func (r *PostgresWebhookDeliveryStore) Retry(ctx context.Context, id int64, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries SET status = $2, next_attempt_at = $3, last_status_code = $4,
+			last_error = $5, updated_at = now()
+		WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, WebhookDeliveryStatusPending, nextAttemptAt, nullStatusCode(statusCode), nullString(lastError)); err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeadLetter marks the delivery as dead and copies it to webhook_dead_letters in one
+// transaction
+func (r *PostgresWebhookDeliveryStore) DeadLetter(ctx context.Context, id int64, statusCode int, lastError string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries SET status = $2, last_status_code = $3, last_error = $4, updated_at = now()
+		WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id, WebhookDeliveryStatusDead, nullStatusCode(statusCode), nullString(lastError)); err != nil {
+		return fmt.Errorf("failed to mark webhook delivery as dead: %w", err)
+	}
+
+	query = `
+		INSERT INTO webhook_dead_letters (delivery_id, tenant_id, event, url, attempts, last_status_code, last_error)
+		SELECT id, tenant_id, event, url, attempts, last_status_code, last_error
+		FROM webhook_deliveries WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to dead-letter webhook delivery: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Replay resets the tenant's delivery to pending and marks its open dead letter as replayed
+func (r *PostgresWebhookDeliveryStore) Replay(ctx context.Context, tenantID int, id int64, now time.Time) (*WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries SET status = $3, attempts = 0, next_attempt_at = $4, updated_at = now()
+		WHERE tenant_id = $1 AND id = $2 AND status <> $5
+		RETURNING ` + webhookDeliveryColumns
+
+	delivery, err := scanWebhookDelivery(tx.QueryRowContext(ctx, query, tenantID, id, WebhookDeliveryStatusPending, now, WebhookDeliveryStatusDelivering))
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE tenant_id = $1 AND id = $2)`
+		if err := tx.QueryRowContext(ctx, query, tenantID, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+		}
+		if exists {
+			return nil, ErrWebhookDeliveryInProgress
+		}
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+
+	query = `UPDATE webhook_dead_letters SET replayed_at = now() WHERE delivery_id = $1 AND replayed_at IS NULL`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return nil, fmt.Errorf("failed to mark dead letter as replayed: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// nullStatusCode stores a missing HTTP answer as NULL
+func nullStatusCode(statusCode int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(statusCode), Valid: statusCode > 0}
+}
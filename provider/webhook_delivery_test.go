@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/tenantctx"
+)
+
+// memoryWebhookDeliveryStore is an in-memory WebhookDeliveryStore with the rules of the
+// Postgres store
+type memoryWebhookDeliveryStore struct {
+	mu          sync.Mutex
+	nextID      int64
+	deliveries  map[int64]*WebhookDelivery
+	deadLetters []int64
+}
+
+func newMemoryWebhookDeliveryStore() *memoryWebhookDeliveryStore {
+	return &memoryWebhookDeliveryStore{deliveries: make(map[int64]*WebhookDelivery)}
+}
+
+func (m *memoryWebhookDeliveryStore) Create(_ context.Context, delivery *WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	delivery.ID = m.nextID
+	stored := *delivery
+	m.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+func (m *memoryWebhookDeliveryStore) ClaimDue(_ context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []WebhookDelivery
+	for id := int64(1); id <= m.nextID && len(due) < limit; id++ {
+		delivery, ok := m.deliveries[id]
+		if !ok || delivery.Status != WebhookDeliveryStatusPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		delivery.Status = WebhookDeliveryStatusDelivering
+		delivery.Attempts++
+		due = append(due, *delivery)
+	}
+	return due, nil
+}
+
+func (m *memoryWebhookDeliveryStore) MarkDelivered(_ context.Context, id int64, statusCode int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	delivery := m.deliveries[id]
+	delivery.Status, delivery.LastStatusCode, delivery.LastError, delivery.DeliveredAt = WebhookDeliveryStatusDelivered, statusCode, "", &now
+	return nil
+}
+
+func (m *memoryWebhookDeliveryStore) Retry(_ context.Context, id int64, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	delivery.Status, delivery.NextAttemptAt, delivery.LastStatusCode, delivery.LastError = WebhookDeliveryStatusPending, nextAttemptAt, statusCode, lastError
+	return nil
+}
+
+func (m *memoryWebhookDeliveryStore) DeadLetter(_ context.Context, id int64, statusCode int, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	delivery.Status, delivery.LastStatusCode, delivery.LastError = WebhookDeliveryStatusDead, statusCode, lastError
+	m.deadLetters = append(m.deadLetters, id)
+	return nil
+}
+
+func (m *memoryWebhookDeliveryStore) Replay(_ context.Context, tenantID int, id int64, now time.Time) (*WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok || delivery.TenantID != tenantID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if delivery.Status == WebhookDeliveryStatusDelivering {
+		return nil, ErrWebhookDeliveryInProgress
+	}
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt = WebhookDeliveryStatusPending, 0, now
+	replayed := *delivery
+	return &replayed, nil
+}
+
+func (m *memoryWebhookDeliveryStore) get(id int64) WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.deliveries[id]
+}
+
+// newTestWebhookDispatcher creates a dispatcher over a memory store whose clock is moved
+// by the test
+func newTestWebhookDispatcher(client *http.Client, maxAttempts int) (*WebhookDispatcher, *memoryWebhookDeliveryStore, *time.Time) {
+	store := newMemoryWebhookDeliveryStore()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	dispatcher := &WebhookDispatcher{
+		store:       store,
+		client:      client,
+		maxAttempts: maxAttempts,
+		backoff:     time.Minute,
+		now:         func() time.Time { return now },
+	}
+	return dispatcher, store, &now
+}
+
+func TestWebhookDispatcher_RetryDelayIsExponential(t *testing.T) {
+	dispatcher, _, _ := newTestWebhookDispatcher(http.DefaultClient, 8)
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{5, 16 * time.Minute},
+		{20, webhookMaxBackoff},
+	}
+	for _, tt := range tests {
+		if delay := dispatcher.retryDelay(tt.attempt); delay != tt.delay {
+			t.Errorf("Expected %s after attempt %d, got %s", tt.delay, tt.attempt, delay)
+		}
+	}
+}
+
+func TestWebhookDispatcher_RetriesThenDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dispatcher, store, now := newTestWebhookDispatcher(server.Client(), 3)
+	delivery := WebhookDelivery{TenantID: 9140, Event: PaymentLinkEventPaid, URL: server.URL, Body: []byte(`{}`),
+		Status: WebhookDeliveryStatusPending, NextAttemptAt: *now}
+	if err := store.Create(context.Background(), &delivery); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := dispatcher.RunDue(context.Background()); err != nil {
+		t.Fatalf("RunDue failed: %v", err)
+	}
+	stored := store.get(delivery.ID)
+	if stored.Status != WebhookDeliveryStatusPending || !stored.NextAttemptAt.Equal(now.Add(time.Minute)) || stored.LastStatusCode != http.StatusBadGateway {
+		t.Fatalf("Expected a retry in a minute after a 502, got %+v", stored)
+	}
+
+	// Not due yet
+	if _, err := dispatcher.RunDue(context.Background()); err != nil || calls.Load() != 1 {
+		t.Fatalf("Expected no attempt before the retry is due, got %d calls (%v)", calls.Load(), err)
+	}
+
+	*now = now.Add(time.Minute)
+	_, _ = dispatcher.RunDue(context.Background())
+	if stored = store.get(delivery.ID); !stored.NextAttemptAt.Equal(now.Add(2 * time.Minute)) {
+		t.Fatalf("Expected the second retry to wait twice as long, got %s", stored.NextAttemptAt.Sub(*now))
+	}
+
+	*now = now.Add(2 * time.Minute)
+	_, _ = dispatcher.RunDue(context.Background())
+	if stored = store.get(delivery.ID); stored.Status != WebhookDeliveryStatusDead || stored.Attempts != 3 {
+		t.Errorf("Expected the delivery to be dead after 3 attempts, got %+v", stored)
+	}
+	if len(store.deadLetters) != 1 || store.deadLetters[0] != delivery.ID {
+		t.Errorf("Expected the delivery in the dead letters, got %v", store.deadLetters)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestWebhookDispatcher_Replay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get("X-GoPay-Delivery"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher, store, now := newTestWebhookDispatcher(server.Client(), 3)
+	delivery := WebhookDelivery{TenantID: 9140, Event: PaymentLinkEventPaid, URL: server.URL, Body: []byte(`{}`),
+		Status: WebhookDeliveryStatusDead, Attempts: 3, NextAttemptAt: *now}
+	if err := store.Create(context.Background(), &delivery); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	otherTenant := context.WithValue(context.Background(), tenantctx.IDKey, "9141")
+	if _, err := dispatcher.Replay(otherTenant, delivery.ID); !errors.Is(err, ErrWebhookDeliveryNotFound) {
+		t.Errorf("Expected another tenant's delivery not to be found, got %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), tenantctx.IDKey, "9140")
+	replayed, err := dispatcher.Replay(ctx, delivery.ID)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if replayed.Status != WebhookDeliveryStatusPending || replayed.Attempts != 0 {
+		t.Errorf("Expected the replayed delivery to be pending with fresh attempts, got %+v", replayed)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.get(delivery.ID).Status != WebhookDeliveryStatusDelivered && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stored := store.get(delivery.ID); stored.Status != WebhookDeliveryStatusDelivered {
+		t.Fatalf("Expected the replayed delivery to be delivered, got %+v", stored)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0] != strconv.FormatInt(delivery.ID, 10) {
+		t.Errorf("Expected one delivery with its ID header, got %v", received)
+	}
+}
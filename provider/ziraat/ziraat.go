@@ -33,11 +33,16 @@ const (
 	txnCodeCancel = "2000" // Cancel/void
 	txnCodeRefund = "2100" // Refund
 
-	// Currency Codes
-	currencyCodeTRY = 949
+	// Default currency, sent as its ISO 4217 numeric code
+	defaultCurrency = "TRY"
 
 	// Default version
 	apiVersion = "1.00"
+
+	// Timestamp layouts, both in Turkey time: txnDateTime on API responses uses the
+	// requestDateTime format, 3D callbacks send EXTRA.TRXDATE
+	txnDateTimeLayout = "2006-01-02T15:04:05.000"
+	trxDateLayout     = "20060102 15:04:05"
 )
 
 // ZiraatProvider implements the provider.PaymentProvider interface for Ziraat
@@ -106,6 +111,22 @@ func (p *ZiraatProvider) ValidateConfig(config map[string]string) error {
 	return provider.ValidateConfigFields("ziraat", config, requiredFields)
 }
 
+// SupportedCurrencies returns the currencies accepted by Ziraat.
+// Ziraat merchants are set up for TRY only (sent as ISO 4217 numeric 949).
+func (p *ZiraatProvider) SupportedCurrencies() []string {
+	return []string{"TRY"}
+}
+
+// HealthCheckEndpoint returns the Ziraat API. The transaction type is part of the request
+// body, so a bodiless probe changes nothing.
+func (p *ZiraatProvider) HealthCheckEndpoint() string {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = apiSandboxURL
+	}
+	return baseURL
+}
+
 // Initialize sets up the Ziraat payment provider with authentication credentials
 func (p *ZiraatProvider) Initialize(conf map[string]string) error {
 	p.username = conf["username"]
@@ -129,7 +150,7 @@ func (p *ZiraatProvider) Initialize(conf map[string]string) error {
 		p.threeDPostURL = api3DProductionURL
 	}
 
-	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction))
+	p.httpClient = provider.NewProviderHTTPClient(provider.CreateHTTPClientConfig(p.baseURL, p.isProduction).ForProvider("ziraat"))
 
 	return nil
 }
@@ -154,7 +175,7 @@ func (p *ZiraatProvider) CreatePayment(ctx context.Context, request provider.Pay
 func (p *ZiraatProvider) Create3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
 	p.logID = request.LogID
 	if err := p.validatePaymentRequest(request, true); err != nil {
-		return nil, fmt.Errorf("ziraat: invalid 3D payment request: %w", err)
+		return nil, fmt.Errorf("ziraat: invalid 3D payment request: %w", provider.InvalidRequest(err))
 	}
 
 	return p.processPayment(ctx, request, true)
@@ -205,6 +226,7 @@ func (p *ZiraatProvider) Complete3DPayment(ctx context.Context, callbackState *p
 		Amount:           callbackState.Amount,
 		Currency:         callbackState.Currency,
 		SystemTime:       &now,
+		ProviderTime:     callbackProviderTime(data),
 		ProviderResponse: data,
 		RedirectURL:      callbackState.OriginalCallback,
 	}
@@ -256,9 +278,31 @@ func (p *ZiraatProvider) Complete3DPayment(ctx context.Context, callbackState *p
 		}
 	}
 
+	if !success && callbackSessionExpired(data) {
+		response.ErrorCode = provider.ErrorCode3DSessionExpired
+		return response, fmt.Errorf("ziraat: %w", provider.Err3DSessionExpired)
+	}
+
+	// The customer left the bank page; the card was never declined
+	if !success && callbackChallengeCancelled(data) {
+		provider.MarkThreeDSCancelled(response)
+	}
+
 	return response, nil
 }
 
+// callbackSessionExpired reports whether a failed callback was caused by the 3D session
+// timing out rather than by the bank declining the card
+func callbackSessionExpired(data map[string]string) bool {
+	return provider.Is3DSessionExpiredMessage(data["ErrMsg"], data["mdErrorMsg"], data["Response"])
+}
+
+// callbackChallengeCancelled reports whether a failed callback was caused by the customer
+// cancelling the 3D challenge
+func callbackChallengeCancelled(data map[string]string) bool {
+	return provider.Is3DSChallengeCancelledMessage(data["ErrMsg"], data["mdErrorMsg"], data["Response"])
+}
+
 // GetPaymentStatus retrieves the current status of a payment
 func (p *ZiraatProvider) GetPaymentStatus(ctx context.Context, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
 	// Ziraat doesn't have a separate status inquiry endpoint in the PHP example
@@ -284,7 +328,25 @@ func (p *ZiraatProvider) CancelPayment(ctx context.Context, request provider.Can
 		"orderId": originalOrderId,
 	}
 
-	return p.sendPaymentRequest(ctx, ziraatReq)
+	response, err := p.sendPaymentRequest(ctx, ziraatReq)
+	if err != nil {
+		return nil, err
+	}
+	return cancelResponse(response)
+}
+
+// cancelResponse returns provider.ErrAlreadyCaptured, ErrAlreadyCancelled or
+// ErrPaymentNotFound for a refused cancel when Ziraat's respText tells the reason
+func cancelResponse(response *provider.PaymentResponse) (*provider.PaymentResponse, error) {
+	if response.Success {
+		return response, nil
+	}
+
+	if code, err := provider.CancelFailure(response.Message); err != nil {
+		response.ErrorCode = code
+		return response, fmt.Errorf("ziraat: %w", err)
+	}
+	return response, nil
 }
 
 // RefundPayment issues a refund for a payment
@@ -303,6 +365,11 @@ func (p *ZiraatProvider) RefundPayment(ctx context.Context, request provider.Ref
 		return nil, fmt.Errorf("failed to get order ID: %s %w", request.PaymentID, err)
 	}
 
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare refund request
 	ziraatReq := p.buildBaseRequest(txnCodeRefund)
 	ziraatReq["order"] = map[string]any{
@@ -310,7 +377,7 @@ func (p *ZiraatProvider) RefundPayment(ctx context.Context, request provider.Ref
 	}
 	ziraatReq["transaction"] = map[string]any{
 		"amount":       int(request.RefundAmount * 100), // Convert to kuruş
-		"currencyCode": currencyCodeTRY,
+		"currencyCode": currencyCode,
 	}
 
 	resp, err := p.sendRequest(ctx, ziraatReq)
@@ -389,8 +456,13 @@ func (p *ZiraatProvider) validatePaymentRequest(request provider.PaymentRequest,
 
 // processPayment handles the main payment processing logic
 func (p *ZiraatProvider) processPayment(ctx context.Context, request provider.PaymentRequest, is3D bool) (*provider.PaymentResponse, error) {
+	currencyCode, err := p.currencyCode(request.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	if is3D {
-		return p.process3DPayment(ctx, request)
+		return p.process3DPayment(ctx, request, currencyCode)
 	}
 
 	// Non-3D payment flow
@@ -423,7 +495,7 @@ func (p *ZiraatProvider) processPayment(ctx context.Context, request provider.Pa
 
 	ziraatReq["transaction"] = map[string]any{
 		"amount":       int(request.Amount * 100), // Convert to kuruş
-		"currencyCode": currencyCodeTRY,
+		"currencyCode": currencyCode,
 		"motoInd":      0,
 		"installCount": installCount,
 	}
@@ -452,7 +524,7 @@ func (p *ZiraatProvider) processPayment(ctx context.Context, request provider.Pa
 }
 
 // process3DPayment handles 3D Secure payment flow using Payten form-based approach
-func (p *ZiraatProvider) process3DPayment(ctx context.Context, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+func (p *ZiraatProvider) process3DPayment(ctx context.Context, request provider.PaymentRequest, currencyCode int) (*provider.PaymentResponse, error) {
 	// Generate order ID
 	orderId := p.generateOrderId()
 
@@ -479,7 +551,7 @@ func (p *ZiraatProvider) process3DPayment(ctx context.Context, request provider.
 	}
 
 	// Prepare 3D form parameters
-	formParams := p.build3DFormParams(request, gopayCallbackURL)
+	formParams := p.build3DFormParams(request, gopayCallbackURL, strconv.Itoa(currencyCode))
 
 	// Calculate hash for 3D form
 	hash, err := p.calculate3DHash(formParams)
@@ -511,10 +583,10 @@ func (p *ZiraatProvider) process3DPayment(ctx context.Context, request provider.
 }
 
 // build3DFormParams builds form parameters for 3D Secure payment
-func (p *ZiraatProvider) build3DFormParams(request provider.PaymentRequest, callbackURL string) map[string]string {
+func (p *ZiraatProvider) build3DFormParams(request provider.PaymentRequest, callbackURL, currencyCode string) map[string]string {
 	// Determine card type (1=Visa, 2=MasterCard)
 	cardType := "1" // Default to Visa
-	cardNumber := strings.ReplaceAll(request.CardInfo.CardNumber, " ", "")
+	cardNumber := provider.NormalizePAN(request.CardInfo.CardNumber)
 	if len(cardNumber) > 0 {
 		firstDigit := cardNumber[0]
 		if firstDigit == '5' {
@@ -554,12 +626,12 @@ func (p *ZiraatProvider) build3DFormParams(request provider.PaymentRequest, call
 		"TranType":                        "Auth",
 		"Instalment":                      "",
 		"callbackUrl":                     callbackURL,
-		"currency":                        "949", // TRY
+		"currency":                        currencyCode,
 		"rnd":                             rnd,
 		"storetype":                       "3D_PAY_HOSTING",
 		"hashAlgorithm":                   "ver3",
 		"lang":                            "tr",
-		"pan":                             request.CardInfo.CardNumber,
+		"pan":                             cardNumber,
 		"cv2":                             request.CardInfo.CVV,
 		"Ecom_Payment_Card_ExpDate_Year":  expYear,
 		"Ecom_Payment_Card_ExpDate_Month": request.CardInfo.ExpireMonth,
@@ -650,6 +722,18 @@ func (p *ZiraatProvider) generate3DSecureHTML(params map[string]string) string {
 	return html
 }
 
+// currencyCode returns the ISO 4217 numeric code Ziraat expects, using TRY when currency is empty
+func (p *ZiraatProvider) currencyCode(currency string) (int, error) {
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	code, err := provider.CurrencyCode(currency, provider.CurrencyFormatNumeric)
+	if err != nil {
+		return 0, fmt.Errorf("ziraat: %w", err)
+	}
+	return strconv.Atoi(code)
+}
+
 // buildBaseRequest builds the base request structure for Ziraat
 func (p *ZiraatProvider) buildBaseRequest(txnCode string) map[string]any {
 	return map[string]any{
@@ -680,6 +764,7 @@ func (p *ZiraatProvider) sendPaymentRequest(ctx context.Context, requestData map
 	// Map Ziraat response to common PaymentResponse
 	paymentResp := &provider.PaymentResponse{
 		SystemTime:       &now,
+		ProviderTime:     responseProviderTime(resp),
 		ProviderResponse: resp,
 	}
 
@@ -722,6 +807,17 @@ func (p *ZiraatProvider) sendPaymentRequest(ctx context.Context, requestData map
 	return paymentResp, nil
 }
 
+// responseProviderTime reads txnDateTime from an API response
+func responseProviderTime(resp map[string]any) *time.Time {
+	txnDateTime, _ := resp["txnDateTime"].(string)
+	return provider.ParseProviderTime(txnDateTime, provider.TurkeyTimeZone, txnDateTimeLayout, "2006-01-02T15:04:05")
+}
+
+// callbackProviderTime reads EXTRA.TRXDATE from a 3D callback
+func callbackProviderTime(data map[string]string) *time.Time {
+	return provider.ParseProviderTime(data["EXTRA.TRXDATE"], provider.TurkeyTimeZone, trxDateLayout)
+}
+
 // sendRequest sends a request to Ziraat API
 func (p *ZiraatProvider) sendRequest(ctx context.Context, requestData map[string]any) (map[string]any, error) {
 	// Convert request data to JSON
@@ -2,8 +2,10 @@ package ziraat
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mstgnz/gopay/provider"
 )
@@ -368,7 +370,7 @@ func TestZiraatProvider_Build3DFormParams(t *testing.T) {
 	}
 
 	callbackURL := "https://example.com/callback"
-	params := p.build3DFormParams(request, callbackURL)
+	params := p.build3DFormParams(request, callbackURL, "949")
 
 	// Check required fields
 	requiredFields := []string{"clientid", "amount", "okurl", "failUrl", "TranType", "callbackUrl", "currency", "rnd", "storetype", "hashAlgorithm", "pan", "cv2", "Ecom_Payment_Card_ExpDate_Year", "Ecom_Payment_Card_ExpDate_Month", "cardType"}
@@ -385,6 +387,9 @@ func TestZiraatProvider_Build3DFormParams(t *testing.T) {
 	if params["amount"] != "100.50" {
 		t.Errorf("Expected amount '100.50', got '%s'", params["amount"])
 	}
+	if params["currency"] != "949" {
+		t.Errorf("Expected currency '949', got '%s'", params["currency"])
+	}
 	if params["cardType"] != "2" { // MasterCard (starts with 5)
 		t.Errorf("Expected cardType '2' for MasterCard, got '%s'", params["cardType"])
 	}
@@ -401,6 +406,42 @@ func TestZiraatProvider_Build3DFormParams(t *testing.T) {
 	}
 }
 
+func TestZiraatProvider_Generate3DSecureHTML_ChallengeForm(t *testing.T) {
+	p := &ZiraatProvider{username: "test_user", threeDPostURL: api3DSandboxURL}
+	request := provider.PaymentRequest{
+		Amount:   100.50,
+		Currency: "TRY",
+		CardInfo: provider.CardInfo{
+			CardNumber:     "5528 7900 0000 0008",
+			ExpireMonth:    "12",
+			ExpireYear:     "2030",
+			CVV:            "123",
+			CardHolderName: "John Doe",
+		},
+	}
+	params := p.build3DFormParams(request, "https://example.com/callback?state=abc", "949")
+	params["hash"] = "aGFzaA=="
+
+	form, ok := provider.ParseChallengeForm(p.generate3DSecureHTML(params))
+	if !ok {
+		t.Fatal("Expected a challenge form in the 3D HTML")
+	}
+	if form.URL != api3DSandboxURL || form.Method != "POST" {
+		t.Errorf("Expected POST to %s, got %s %s", api3DSandboxURL, form.Method, form.URL)
+	}
+	if len(form.Fields) != len(params) {
+		t.Errorf("Expected %d fields, got %d", len(params), len(form.Fields))
+	}
+	for name, value := range params {
+		if form.Fields[name] != value {
+			t.Errorf("Expected field %s=%q, got %q", name, value, form.Fields[name])
+		}
+	}
+	if form.Fields["pan"] != "5528790000000008" {
+		t.Errorf("Expected the card number without spaces, got %q", form.Fields["pan"])
+	}
+}
+
 func TestZiraatProvider_CreatePaymentAlwaysUses3D(t *testing.T) {
 	p := NewProvider().(*ZiraatProvider)
 	config := map[string]string{
@@ -542,3 +583,133 @@ func TestZiraatProvider_Complete3DPayment(t *testing.T) {
 		})
 	}
 }
+
+func TestZiraatProvider_CurrencyCode(t *testing.T) {
+	p := &ZiraatProvider{}
+
+	tests := []struct {
+		currency    string
+		expected    int
+		expectError bool
+	}{
+		{"TRY", 949, false},
+		{"", 949, false},
+		{"TL", 949, false},
+		{"EUR", 978, false},
+		{"XYZ", 0, true},
+	}
+
+	for _, tt := range tests {
+		code, err := p.currencyCode(tt.currency)
+		if tt.expectError {
+			if err == nil || !strings.HasPrefix(err.Error(), "ziraat:") {
+				t.Errorf("currencyCode(%q) expected ziraat error, got %v", tt.currency, err)
+			}
+			continue
+		}
+		if err != nil || code != tt.expected {
+			t.Errorf("currencyCode(%q) = %d, %v; want %d", tt.currency, code, err, tt.expected)
+		}
+	}
+}
+
+func TestZiraatProvider_ProviderTime(t *testing.T) {
+	expected := time.Date(2024, 1, 15, 7, 30, 0, 250_000_000, time.UTC)
+
+	got := responseProviderTime(map[string]any{"txnDateTime": "2024-01-15T10:30:00.250"})
+	if got == nil || !got.Equal(expected) {
+		t.Errorf("Expected txnDateTime to parse as %v, got %v", expected, got)
+	}
+
+	got = callbackProviderTime(map[string]string{"EXTRA.TRXDATE": "20240115 10:30:00"})
+	if got == nil || !got.Equal(expected.Truncate(time.Second)) {
+		t.Errorf("Expected EXTRA.TRXDATE to parse as %v, got %v", expected.Truncate(time.Second), got)
+	}
+
+	if got := responseProviderTime(map[string]any{"respCode": "00"}); got != nil {
+		t.Errorf("Expected no provider time without txnDateTime, got %v", got)
+	}
+	if got := callbackProviderTime(map[string]string{"EXTRA.TRXDATE": "15/01/2024"}); got != nil {
+		t.Errorf("Expected unparsable EXTRA.TRXDATE to be ignored, got %v", got)
+	}
+}
+
+func TestCallbackSessionExpired(t *testing.T) {
+	tests := []struct {
+		data     map[string]string
+		expected bool
+	}{
+		{map[string]string{"status": "FAILED", "mdStatus": "0", "ErrMsg": "3D Secure session timed out"}, true},
+		{map[string]string{"ErrMsg": "Oturum süresi doldu"}, true},
+		{map[string]string{"mdErrorMsg": "Session has expired"}, true},
+		{map[string]string{"Response": "Declined", "ErrMsg": "Kart süresi dolmuş"}, false},
+		{map[string]string{"Response": "Declined"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := callbackSessionExpired(tt.data); got != tt.expected {
+			t.Errorf("callbackSessionExpired(%v): expected %v, got %v", tt.data, tt.expected, got)
+		}
+	}
+}
+
+func TestCallbackChallengeCancelled(t *testing.T) {
+	tests := []struct {
+		data     map[string]string
+		expected bool
+	}{
+		{map[string]string{"status": "FAILED", "mdStatus": "0", "mdErrorMsg": "Authentication cancelled by user"}, true},
+		{map[string]string{"status": "FAILED", "ErrMsg": "İşlem kullanıcı tarafından iptal edildi"}, true},
+		{map[string]string{"status": "FAILED", "mdStatus": "0", "mdErrorMsg": "Not authenticated"}, false},
+		{map[string]string{"Response": "Declined", "ErrMsg": "Yetersiz bakiye"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := callbackChallengeCancelled(tt.data); got != tt.expected {
+			t.Errorf("callbackChallengeCancelled(%v): expected %v, got %v", tt.data, tt.expected, got)
+		}
+	}
+}
+
+func TestZiraatProvider_HealthCheckEndpoint(t *testing.T) {
+	p := &ZiraatProvider{}
+	if got := p.HealthCheckEndpoint(); got != apiSandboxURL {
+		t.Errorf("Expected sandbox endpoint before Initialize, got %s", got)
+	}
+
+	p.baseURL = apiProductionURL
+	if got := p.HealthCheckEndpoint(); got != apiProductionURL {
+		t.Errorf("Expected production endpoint, got %s", got)
+	}
+}
+
+func TestCancelResponse(t *testing.T) {
+	failed := func(code, text string) *provider.PaymentResponse {
+		return &provider.PaymentResponse{Status: provider.StatusFailed, ErrorCode: code, Message: text}
+	}
+
+	tests := []struct {
+		name      string
+		response  *provider.PaymentResponse
+		err       error
+		errorCode string
+	}{
+		{"cancelled", &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful}, nil, ""},
+		{"already captured", failed("99", "Batch closed, transaction cannot be voided"), provider.ErrAlreadyCaptured, provider.ErrorCodeAlreadyCaptured},
+		{"already cancelled", failed("99", "Transaction already voided"), provider.ErrAlreadyCancelled, provider.ErrorCodeAlreadyCancelled},
+		{"not found", failed("99", "Order not found"), provider.ErrPaymentNotFound, provider.ErrorCodePaymentNotFound},
+		{"other failure", failed("05", "Do not honour"), nil, "05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := cancelResponse(tt.response)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if response.ErrorCode != tt.errorCode {
+				t.Errorf("expected error code %q, got %q", tt.errorCode, response.ErrorCode)
+			}
+		})
+	}
+}
@@ -716,7 +716,18 @@ class GoPayAnalytics {
                 const data = await response.json();
                 if (data.success) {
                     const activities = data.data;
-                    activityContainer.innerHTML = activities.map((activity, index) => `
+                    activityContainer.innerHTML = activities.map((activity, index) => activity.type === 'provider_outage' ? `
+                        <div class="activity-item" style="background: #fef3c7; border-left: 4px solid #d97706;">
+                            <div class="activity-info">
+                                <div class="activity-icon" style="background: #fde68a; color: #b45309;">⚠️</div>
+                                <div class="activity-details">
+                                    <h4>${activity.provider} is having issues</h4>
+                                    <p style="font-size: 0.8rem;">${activity.message}</p>
+                                </div>
+                            </div>
+                            <span class="activity-time">${activity.time}</span>
+                        </div>
+                    ` : `
                         <div class="activity-item clickable-activity" data-activity-index="${index}">
                             <div class="activity-info">
                                 <div class="activity-icon" style="background: ${
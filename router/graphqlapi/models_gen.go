@@ -0,0 +1,46 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphqlapi
+
+import (
+	"time"
+)
+
+type PaymentFilter struct {
+	Provider      *string    `json:"provider,omitempty"`
+	Status        *string    `json:"status,omitempty"`
+	From          *time.Time `json:"from,omitempty"`
+	To            *time.Time `json:"to,omitempty"`
+	MinAmount     *float64   `json:"minAmount,omitempty"`
+	MaxAmount     *float64   `json:"maxAmount,omitempty"`
+	CustomerEmail *string    `json:"customerEmail,omitempty"`
+}
+
+type PaymentLog struct {
+	ID           int       `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Provider     string    `json:"provider"`
+	Method       string    `json:"method"`
+	Endpoint     string    `json:"endpoint"`
+	RequestID    string    `json:"requestId"`
+	PaymentID    *string   `json:"paymentId,omitempty"`
+	Amount       *float64  `json:"amount,omitempty"`
+	Currency     *string   `json:"currency,omitempty"`
+	Status       *string   `json:"status,omitempty"`
+	ErrorCode    *string   `json:"errorCode,omitempty"`
+	ErrorMessage *string   `json:"errorMessage,omitempty"`
+	ProcessingMs int       `json:"processingMs"`
+}
+
+type ProviderStats struct {
+	Provider        string   `json:"provider"`
+	TotalRequests   int      `json:"totalRequests"`
+	SuccessCount    int      `json:"successCount"`
+	ErrorCount      int      `json:"errorCount"`
+	BlockedCount    int      `json:"blockedCount"`
+	SuccessRate     float64  `json:"successRate"`
+	AvgProcessingMs *float64 `json:"avgProcessingMs,omitempty"`
+}
+
+type Query struct {
+}
@@ -0,0 +1,159 @@
+// Package graphqlapi serves payments, logs and analytics as a GraphQL graph at /v1/graphql, so
+// dashboards can fetch the fields they need in one call. Every resolver is scoped to the
+// tenant of the JWT token. The schema is schema.graphqls; generated.go and models_gen.go are
+// generated from it with `make graphql`.
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/provider"
+)
+
+// maxComplexity bounds the fields a query may resolve, a page of payments with their logs
+// included
+const maxComplexity = 1000
+
+// errUnauthenticated is returned by resolvers without a tenant in the context
+var errUnauthenticated = errors.New("invalid or missing authentication")
+
+// PaymentLister lists the payments of the tenant in the context, typically *provider.PaymentService
+type PaymentLister interface {
+	ListPayments(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error)
+}
+
+// LogSource reads the provider logs of a tenant, typically *provider.ProviderSpecificLogger
+type LogSource interface {
+	GetPaymentLogs(ctx context.Context, tenantID, provider, paymentID string) ([]postgres.PaymentLog, error)
+	GetRecentErrorLogs(ctx context.Context, tenantID, provider string, hours int) ([]postgres.PaymentLog, error)
+}
+
+// StatsSource reads payment statistics and the active providers, typically *postgres.Logger
+type StatsSource interface {
+	GetPaymentStats(ctx context.Context, tenantID int, provider string, hours int) (map[string]any, error)
+	GetActiveProviders(ctx context.Context) ([]map[string]any, error)
+}
+
+// Resolver is the root resolver of the graph
+type Resolver struct {
+	payments PaymentLister
+	logs     LogSource
+	stats    StatsSource
+}
+
+// NewHandler creates the GraphQL endpoint, queried with POST (or GET) and introspectable
+func NewHandler(payments PaymentLister, logs LogSource, stats StatsSource) http.Handler {
+	server := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{payments: payments, logs: logs, stats: stats}}))
+	server.AddTransport(transport.GET{})
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(extension.FixedComplexityLimit(maxComplexity))
+	return server
+}
+
+// tenantFromContext returns the tenant of the JWT token
+func tenantFromContext(ctx context.Context) (string, error) {
+	tenantID := middle.GetTenantIDFromContext(ctx)
+	if tenantID == "" {
+		return "", errUnauthenticated
+	}
+	return tenantID, nil
+}
+
+func paymentLogOf(log postgres.PaymentLog) PaymentLog {
+	entry := PaymentLog{
+		ID:           int(log.ID),
+		Timestamp:    log.Timestamp,
+		Provider:     log.Provider,
+		Method:       log.Method,
+		Endpoint:     log.Endpoint,
+		RequestID:    log.RequestID,
+		ProcessingMs: int(log.ProcessingMs),
+	}
+	if info := log.PaymentInfo; info != nil {
+		entry.PaymentID = optional(info.PaymentID)
+		entry.Currency = optional(info.Currency)
+		entry.Status = optional(info.Status)
+		if info.Amount != 0 {
+			entry.Amount = &info.Amount
+		}
+	}
+	if log.Error != nil {
+		entry.ErrorCode = optional(log.Error.Code)
+		entry.ErrorMessage = optional(log.Error.Message)
+	}
+	return entry
+}
+
+func paymentLogsOf(logs []postgres.PaymentLog) []PaymentLog {
+	entries := make([]PaymentLog, 0, len(logs))
+	for _, log := range logs {
+		entries = append(entries, paymentLogOf(log))
+	}
+	return entries
+}
+
+// providerStatsOf reads the statistics map of postgres.Logger.GetPaymentStats
+func providerStatsOf(providerName string, stats map[string]any) ProviderStats {
+	result := ProviderStats{Provider: providerName}
+	result.TotalRequests, _ = stats["total_requests"].(int)
+	result.SuccessCount, _ = stats["success_count"].(int)
+	result.ErrorCount, _ = stats["error_count"].(int)
+	result.BlockedCount, _ = stats["blocked_count"].(int)
+	result.SuccessRate, _ = stats["success_rate"].(float64)
+	if avg, ok := stats["avg_processing_ms"].(float64); ok {
+		result.AvgProcessingMs = &avg
+	}
+	return result
+}
+
+// tenantIDInt returns the tenant ID as the integer the stats are keyed by
+func tenantIDInt(tenantID string) (int, error) {
+	id, err := strconv.Atoi(tenantID)
+	if err != nil {
+		return 0, errUnauthenticated
+	}
+	return id, nil
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// knownProvider rejects provider names that are not registered, as they name log tables
+func knownProvider(providerName string) error {
+	if _, err := provider.Get(providerName); err != nil {
+		return fmt.Errorf("unknown provider: %s", providerName)
+	}
+	return nil
+}
+
+// hoursOf validates the time window of logs and stats, 24 hours by default
+func hoursOf(hours *int) (int, error) {
+	if hours == nil {
+		return 24, nil
+	}
+	if *hours < 1 || *hours > 8760 {
+		return 0, errors.New("hours must be between 1 and 8760")
+	}
+	return *hours, nil
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
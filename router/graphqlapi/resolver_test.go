@@ -0,0 +1,188 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mstgnz/gopay/infra/middle"
+	"github.com/mstgnz/gopay/infra/postgres"
+	"github.com/mstgnz/gopay/provider"
+)
+
+func init() {
+	provider.Register("graphqltest", func() provider.PaymentProvider { return nil })
+}
+
+type fakePaymentLister struct {
+	filter   provider.PaymentListFilter
+	tenantID string
+}
+
+func (f *fakePaymentLister) ListPayments(ctx context.Context, filter provider.PaymentListFilter) (*provider.PaymentPage, error) {
+	f.filter = filter
+	f.tenantID = middle.GetTenantIDFromContext(ctx)
+	return &provider.PaymentPage{
+		Payments: []provider.PaymentSummary{
+			{PaymentID: "pay_1", Provider: "graphqltest", Status: "successful", Amount: 100, Currency: "TRY", RequestedAt: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+			{Provider: "graphqltest", Status: "failed", RequestedAt: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		},
+		NextCursor: "next",
+	}, nil
+}
+
+// fakeLogSource has logs of pay_1 for tenant 42 only
+type fakeLogSource struct{}
+
+func (fakeLogSource) GetPaymentLogs(_ context.Context, tenantID, providerName, paymentID string) ([]postgres.PaymentLog, error) {
+	if tenantID != "42" || paymentID != "pay_1" {
+		return nil, nil
+	}
+	return []postgres.PaymentLog{{
+		ID:          7,
+		Provider:    providerName,
+		Method:      "POST",
+		Endpoint:    "/payment",
+		PaymentInfo: &postgres.PaymentInfo{PaymentID: "pay_1", Amount: 100, Status: "successful"},
+	}}, nil
+}
+
+func (fakeLogSource) GetRecentErrorLogs(_ context.Context, tenantID, providerName string, hours int) ([]postgres.PaymentLog, error) {
+	return []postgres.PaymentLog{{ID: 8, Provider: providerName, Error: &postgres.ErrorInfo{Code: "card_declined", Message: "Declined"}}}, nil
+}
+
+type fakeStatsSource struct {
+	tenantID int
+	hours    int
+}
+
+func (f *fakeStatsSource) GetPaymentStats(_ context.Context, tenantID int, providerName string, hours int) (map[string]any, error) {
+	f.tenantID, f.hours = tenantID, hours
+	return map[string]any{"total_requests": 10, "success_count": 9, "error_count": 1, "blocked_count": 0, "success_rate": 90.0, "avg_processing_ms": 120.5}, nil
+}
+
+func (f *fakeStatsSource) GetActiveProviders(context.Context) ([]map[string]any, error) {
+	return []map[string]any{{"id": "graphqltest", "name": "Graphqltest"}}, nil
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, h http.Handler, tenantID, q string) graphQLResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": q})
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if tenantID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), middle.TenantIDKey, tenantID))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp graphQLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func TestGraphQL_PaymentsWithLogs(t *testing.T) {
+	payments := &fakePaymentLister{}
+	h := NewHandler(payments, fakeLogSource{}, &fakeStatsSource{})
+
+	resp := query(t, h, "42", `{
+		payments(filter: {provider: "GraphQLTest", status: "successful", minAmount: 50}, first: 10) {
+			payments { paymentId status amount logs { id method paymentId } }
+			nextCursor
+		}
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %+v", resp.Errors)
+	}
+	if payments.tenantID != "42" || payments.filter.Provider != "graphqltest" || payments.filter.Status != "successful" ||
+		payments.filter.MinAmount != 50 || payments.filter.Limit != 10 {
+		t.Errorf("Unexpected listing of tenant %q: %+v", payments.tenantID, payments.filter)
+	}
+
+	var data struct {
+		Payments struct {
+			Payments []struct {
+				PaymentID string `json:"paymentId"`
+				Logs      []struct {
+					ID        int    `json:"id"`
+					Method    string `json:"method"`
+					PaymentID string `json:"paymentId"`
+				} `json:"logs"`
+			} `json:"payments"`
+			NextCursor string `json:"nextCursor"`
+		} `json:"payments"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("Invalid data: %v", err)
+	}
+	page := data.Payments
+	if len(page.Payments) != 2 || page.NextCursor != "next" {
+		t.Fatalf("Unexpected page: %s", resp.Data)
+	}
+	if logs := page.Payments[0].Logs; len(logs) != 1 || logs[0].ID != 7 || logs[0].PaymentID != "pay_1" {
+		t.Errorf("Expected the logs of pay_1, got %+v", logs)
+	}
+	// A payment without a payment ID has no logs
+	if page.Payments[1].PaymentID != "" || len(page.Payments[1].Logs) != 0 {
+		t.Errorf("Expected no payment ID and no logs, got %+v", page.Payments[1])
+	}
+}
+
+func TestGraphQL_LogsAndStats(t *testing.T) {
+	stats := &fakeStatsSource{}
+	h := NewHandler(&fakePaymentLister{}, fakeLogSource{}, stats)
+
+	resp := query(t, h, "42", `{
+		errorLogs(provider: "graphqltest", hours: 6) { id errorCode errorMessage }
+		stats { provider totalRequests successRate avgProcessingMs }
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %+v", resp.Errors)
+	}
+	want := `{"errorLogs":[{"id":8,"errorCode":"card_declined","errorMessage":"Declined"}],"stats":[{"provider":"graphqltest","totalRequests":10,"successRate":90,"avgProcessingMs":120.5}]}`
+	if string(resp.Data) != want {
+		t.Errorf("Unexpected data:\n got %s\nwant %s", resp.Data, want)
+	}
+	if stats.tenantID != 42 || stats.hours != 24 {
+		t.Errorf("Expected tenant 42 stats of the last 24 hours, got tenant %d and %d hours", stats.tenantID, stats.hours)
+	}
+}
+
+func TestGraphQL_Rejected(t *testing.T) {
+	h := NewHandler(&fakePaymentLister{}, fakeLogSource{}, &fakeStatsSource{})
+
+	tests := []struct {
+		name     string
+		tenantID string
+		query    string
+		message  string
+	}{
+		{"no tenant", "", `{ errorLogs(provider: "graphqltest") { id } }`, "invalid or missing authentication"},
+		{"unknown provider", "42", `{ paymentLogs(provider: "users; --", paymentId: "pay_1") { id } }`, "unknown provider"},
+		{"hours out of range", "42", `{ stats(hours: 0) { provider } }`, "hours must be between"},
+		{"page too large", "42", `{ payments(first: 1000) { nextCursor } }`, "first must be between"},
+		{"malformed cursor", "42", `{ payments(after: "!!") { nextCursor } }`, "malformed cursor"},
+		{"unknown field", "42", `{ payments { secret } }`, "Cannot query field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := query(t, h, tt.tenantID, tt.query)
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.message) {
+				t.Errorf("Expected an error containing %q, got %+v", tt.message, resp.Errors)
+			}
+		})
+	}
+}
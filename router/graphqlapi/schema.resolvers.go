@@ -0,0 +1,156 @@
+package graphqlapi
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.66
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mstgnz/gopay/provider"
+)
+
+// Logs is the resolver for the logs field.
+func (r *paymentResolver) Logs(ctx context.Context, obj *provider.PaymentSummary) ([]PaymentLog, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if obj.PaymentID == "" {
+		return []PaymentLog{}, nil
+	}
+	logs, err := r.logs.GetPaymentLogs(ctx, tenantID, obj.Provider, obj.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve logs: %w", err)
+	}
+	return paymentLogsOf(logs), nil
+}
+
+// Payments is the resolver for the payments field.
+func (r *queryResolver) Payments(ctx context.Context, filter *PaymentFilter, first *int, after *string) (*provider.PaymentPage, error) {
+	if _, err := tenantFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	listFilter := provider.PaymentListFilter{}
+	if filter != nil {
+		listFilter.Provider = strings.ToLower(deref(filter.Provider))
+		listFilter.Status = deref(filter.Status)
+		listFilter.CustomerEmail = deref(filter.CustomerEmail)
+		if filter.From != nil {
+			listFilter.From = *filter.From
+		}
+		if filter.To != nil {
+			listFilter.To = *filter.To
+		}
+		if filter.MinAmount != nil {
+			listFilter.MinAmount = *filter.MinAmount
+		}
+		if filter.MaxAmount != nil {
+			listFilter.MaxAmount = *filter.MaxAmount
+		}
+	}
+	if first != nil {
+		if *first < 1 || *first > provider.MaxPaymentListLimit {
+			return nil, fmt.Errorf("first must be between 1 and %d", provider.MaxPaymentListLimit)
+		}
+		listFilter.Limit = *first
+	}
+	if after != nil && *after != "" {
+		cursor, err := provider.DecodePaymentCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+		listFilter.After = cursor
+	}
+
+	return r.payments.ListPayments(ctx, listFilter)
+}
+
+// PaymentLogs is the resolver for the paymentLogs field.
+func (r *queryResolver) PaymentLogs(ctx context.Context, provider string, paymentID string) ([]PaymentLog, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := knownProvider(provider); err != nil {
+		return nil, err
+	}
+	logs, err := r.logs.GetPaymentLogs(ctx, tenantID, provider, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve logs: %w", err)
+	}
+	return paymentLogsOf(logs), nil
+}
+
+// ErrorLogs is the resolver for the errorLogs field.
+func (r *queryResolver) ErrorLogs(ctx context.Context, provider string, hours *int) ([]PaymentLog, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := knownProvider(provider); err != nil {
+		return nil, err
+	}
+	window, err := hoursOf(hours)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := r.logs.GetRecentErrorLogs(ctx, tenantID, provider, window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve error logs: %w", err)
+	}
+	return paymentLogsOf(logs), nil
+}
+
+// Stats is the resolver for the stats field.
+func (r *queryResolver) Stats(ctx context.Context, providers []string, hours *int) ([]ProviderStats, error) {
+	tenant, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := tenantIDInt(tenant)
+	if err != nil {
+		return nil, err
+	}
+	window, err := hoursOf(hours)
+	if err != nil {
+		return nil, err
+	}
+
+	if providers == nil {
+		active, err := r.stats.GetActiveProviders(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get active providers: %w", err)
+		}
+		for _, p := range active {
+			if name, ok := p["id"].(string); ok {
+				providers = append(providers, name)
+			}
+		}
+	}
+
+	results := make([]ProviderStats, 0, len(providers))
+	for _, providerName := range providers {
+		if err := knownProvider(providerName); err != nil {
+			return nil, err
+		}
+		stats, err := r.stats.GetPaymentStats(ctx, id, providerName, window)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s stats: %w", providerName, err)
+		}
+		results = append(results, providerStatsOf(providerName, stats))
+	}
+	return results, nil
+}
+
+// Payment returns PaymentResolver implementation.
+func (r *Resolver) Payment() PaymentResolver { return &paymentResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type paymentResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
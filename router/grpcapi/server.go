@@ -0,0 +1,349 @@
+// Package grpcapi serves the payment operations of the REST API over gRPC, for internal
+// services that would rather not speak REST. It uses the same PaymentService and the same
+// tenant JWTs as the REST API.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+	gopayv1 "github.com/mstgnz/gopay/proto/gopay/v1"
+	"github.com/mstgnz/gopay/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// callTimeout bounds a call, like the timeout of the REST payment handlers
+const callTimeout = 30 * time.Second
+
+// PaymentService is the part of *provider.PaymentService served over gRPC
+type PaymentService interface {
+	CreatePayment(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error)
+	GetPaymentStatus(ctx context.Context, environment, providerName string, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error)
+	RefundPayment(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error)
+	CancelPayment(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error)
+}
+
+// TokenValidator validates tenant JWTs, typically *auth.JWTService
+type TokenValidator interface {
+	ValidateToken(token string) (*auth.JWTClaims, error)
+}
+
+// RoleResolver returns the role of a tenant user, typically *auth.RoleService
+type RoleResolver interface {
+	RoleOf(ctx context.Context, tenantID int, username string) (auth.Role, error)
+}
+
+// methodPermissions are the permissions of the calls, like those of their REST routes
+var methodPermissions = map[string]auth.Permission{
+	gopayv1.PaymentService_CreatePayment_FullMethodName:    auth.PermissionPaymentsWrite,
+	gopayv1.PaymentService_GetPaymentStatus_FullMethodName: auth.PermissionPaymentsRead,
+	gopayv1.PaymentService_RefundPayment_FullMethodName:    auth.PermissionRefunds,
+	gopayv1.PaymentService_CancelPayment_FullMethodName:    auth.PermissionPaymentsWrite,
+}
+
+// Server implements gopayv1.PaymentServiceServer over a PaymentService
+type Server struct {
+	gopayv1.UnimplementedPaymentServiceServer
+	payments       PaymentService
+	validate       *validator.Validate
+	metadataLimits provider.MetadataLimits
+}
+
+// NewServer creates the gRPC server of the payment API. Calls are authenticated with the
+// tenant JWT in the "authorization" metadata and, with roles, checked against the role of its
+// user. A nil roles checks no roles.
+func NewServer(payments PaymentService, tokens TokenValidator, roles RoleResolver) *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{authInterceptor(tokens)}
+	if roles != nil {
+		interceptors = append(interceptors, roleInterceptor(roles))
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	gopayv1.RegisterPaymentServiceServer(server, &Server{
+		payments:       payments,
+		validate:       validator.New(),
+		metadataLimits: provider.MetadataLimitsFromEnv(),
+	})
+	return server
+}
+
+// authInterceptor validates the "Bearer <token>" of the "authorization" metadata and puts the
+// tenant in the context, like middle.JWTAuthMiddleware does for REST requests
+func authInterceptor(tokens TokenValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+		}
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || token == "" {
+			return nil, status.Error(codes.Unauthenticated, "invalid authorization format, use: Bearer <jwt_token>")
+		}
+
+		claims, err := tokens.ValidateToken(token)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrExpiredToken):
+				return nil, status.Error(codes.Unauthenticated, "token has expired")
+			case errors.Is(err, auth.ErrSessionRevoked):
+				return nil, status.Error(codes.Unauthenticated, "session has been revoked")
+			default:
+				return nil, status.Error(codes.Unauthenticated, "invalid token")
+			}
+		}
+
+		ctx = context.WithValue(ctx, middle.TenantIDKey, claims.TenantID)
+		ctx = context.WithValue(ctx, middle.TenantUserKey, claims.Username)
+		ctx = context.WithValue(ctx, middle.TenantClaimsKey, claims)
+		return handler(ctx, req)
+	}
+}
+
+// roleInterceptor rejects calls the role of the user does not allow, like
+// middle.RequirePermission does for REST requests. It runs after authInterceptor.
+func roleInterceptor(roles RoleResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		permission, ok := methodPermissions[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		tenantID, err := strconv.Atoi(middle.GetTenantIDFromContext(ctx))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid tenant")
+		}
+		role, err := roles.RoleOf(ctx, tenantID, middle.GetTenantUserFromContext(ctx))
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to resolve role")
+		}
+		if !role.Allows(permission) {
+			return nil, status.Errorf(codes.PermissionDenied, "role %s does not allow %s", role, permission)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CreatePayment processes a payment
+func (s *Server) CreatePayment(ctx context.Context, req *gopayv1.CreatePaymentRequest) (*gopayv1.PaymentResponse, error) {
+	if req.GetProvider() == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	request := paymentRequestOf(req)
+	if request.ClientIP == "" {
+		request.ClientIP = peerIP(ctx)
+	}
+	if err := s.validate.Struct(request); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+	}
+	if err := s.metadataLimits.Validate(request.Metadata); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp, err := s.payments.CreatePayment(ctx, environmentOf(req.GetEnvironment()), req.GetProvider(), request)
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return paymentResponseOf(resp), nil
+}
+
+// GetPaymentStatus returns the status of a payment
+func (s *Server) GetPaymentStatus(ctx context.Context, req *gopayv1.GetPaymentStatusRequest) (*gopayv1.PaymentResponse, error) {
+	if req.GetProvider() == "" || req.GetPaymentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider and payment_id are required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	resp, err := s.payments.GetPaymentStatus(ctx, environmentOf(req.GetEnvironment()), req.GetProvider(), provider.GetPaymentStatusRequest{PaymentID: req.GetPaymentId()})
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return paymentResponseOf(resp), nil
+}
+
+// RefundPayment refunds a payment in full or in part
+func (s *Server) RefundPayment(ctx context.Context, req *gopayv1.RefundPaymentRequest) (*gopayv1.RefundResponse, error) {
+	if req.GetProvider() == "" || req.GetPaymentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider and payment_id are required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	resp, err := s.payments.RefundPayment(ctx, environmentOf(req.GetEnvironment()), req.GetProvider(), provider.RefundRequest{
+		PaymentID:      req.GetPaymentId(),
+		RefundAmount:   req.GetRefundAmount(),
+		Currency:       req.GetCurrency(),
+		Reason:         req.GetReason(),
+		Description:    req.GetDescription(),
+		IdempotencyKey: req.GetIdempotencyKey(),
+	})
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return &gopayv1.RefundResponse{
+		Success:      resp.Success,
+		RefundId:     resp.RefundID,
+		PaymentId:    resp.PaymentID,
+		Status:       resp.Status,
+		RefundAmount: resp.RefundAmount,
+		Message:      resp.Message,
+		ErrorCode:    resp.ErrorCode,
+		Replayed:     resp.Replayed,
+	}, nil
+}
+
+// CancelPayment cancels a payment
+func (s *Server) CancelPayment(ctx context.Context, req *gopayv1.CancelPaymentRequest) (*gopayv1.PaymentResponse, error) {
+	if req.GetProvider() == "" || req.GetPaymentId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider and payment_id are required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	resp, err := s.payments.CancelPayment(ctx, environmentOf(req.GetEnvironment()), req.GetProvider(), provider.CancelRequest{
+		PaymentID: req.GetPaymentId(),
+		Reason:    req.GetReason(),
+	})
+	if err != nil {
+		return nil, statusOf(err)
+	}
+	return paymentResponseOf(resp), nil
+}
+
+// statusOf maps a PaymentService error to the gRPC status the REST API's HTTP status
+// corresponds to
+func statusOf(err error) error {
+	var notConfigured *provider.ProviderNotConfiguredError
+	switch {
+	case errors.As(err, &notConfigured):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, provider.ErrPaymentInProgress), errors.Is(err, provider.ErrRefundInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, provider.ErrPaymentIdempotencyKeyReused), errors.Is(err, provider.ErrIdempotencyKeyReused):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, provider.ErrPaymentNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, provider.ErrAlreadyCaptured), errors.Is(err, provider.ErrAlreadyCancelled),
+		errors.Is(err, provider.ErrRefundWindowClosed), errors.Is(err, provider.ErrProviderWeightsNotConfigured):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, provider.ErrNoProviderAvailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, provider.ErrUnsupportedCurrency), errors.Is(err, provider.ErrInvalidCardNumber),
+		errors.Is(err, provider.ErrAutoCaptureDelayInvalid), errors.Is(err, provider.ErrCaptureUnsupported),
+		errors.Is(err, provider.ErrMetadataTooLarge), errors.Is(err, provider.ErrSurchargeNotAllowed),
+		errors.Is(err, provider.ErrInstallmentNotAllowed), errors.Is(err, provider.ErrBasketTotalMismatch),
+		errors.Is(err, provider.ErrRefundExceedsRefundable), errors.Is(err, provider.ErrRefundAmountTooSmall):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// environmentOf returns the environment name of the PaymentService, sandbox unless production
+func environmentOf(environment gopayv1.Environment) string {
+	if environment == gopayv1.Environment_ENVIRONMENT_PRODUCTION {
+		return "production"
+	}
+	return "sandbox"
+}
+
+// peerIP returns the IP of the caller, used as the client IP when the request has none
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func paymentRequestOf(req *gopayv1.CreatePaymentRequest) provider.PaymentRequest {
+	request := provider.PaymentRequest{
+		Amount:           req.GetAmount(),
+		Currency:         req.GetCurrency(),
+		Description:      req.GetDescription(),
+		CallbackURL:      req.GetCallbackUrl(),
+		Use3D:            req.GetUse_3D(),
+		InstallmentCount: int(req.GetInstallmentCount()),
+		ConversationID:   req.GetConversationId(),
+		ReferenceID:      req.GetReferenceId(),
+		Locale:           req.GetLocale(),
+		ClientIP:         req.GetClientIp(),
+		PaymentType:      req.GetPaymentType(),
+		Metadata:         req.GetMetadata(),
+		IdempotencyKey:   req.GetIdempotencyKey(),
+	}
+	if customer := req.GetCustomer(); customer != nil {
+		request.Customer = provider.Customer{
+			ID:          customer.GetId(),
+			Name:        customer.GetName(),
+			Surname:     customer.GetSurname(),
+			Email:       customer.GetEmail(),
+			PhoneNumber: customer.GetPhoneNumber(),
+			IPAddress:   customer.GetIpAddress(),
+		}
+		if address := customer.GetAddress(); address != nil {
+			request.Customer.Address = &provider.Address{
+				City:        address.GetCity(),
+				Country:     address.GetCountry(),
+				Address:     address.GetAddress(),
+				ZipCode:     address.GetZipCode(),
+				Description: address.GetDescription(),
+			}
+		}
+	}
+	if card := req.GetCardInfo(); card != nil {
+		request.CardInfo = provider.CardInfo{
+			CardHolderName: card.GetCardHolderName(),
+			CardNumber:     card.GetCardNumber(),
+			ExpireMonth:    card.GetExpireMonth(),
+			ExpireYear:     card.GetExpireYear(),
+			CVV:            card.GetCvv(),
+		}
+	}
+	for _, item := range req.GetItems() {
+		request.Items = append(request.Items, provider.Item{
+			ID:          item.GetId(),
+			Name:        item.GetName(),
+			Description: item.GetDescription(),
+			Category:    item.GetCategory(),
+			Price:       item.GetPrice(),
+			Quantity:    int(item.GetQuantity()),
+		})
+	}
+	return request
+}
+
+func paymentResponseOf(resp *provider.PaymentResponse) *gopayv1.PaymentResponse {
+	return &gopayv1.PaymentResponse{
+		Success:       resp.Success,
+		Status:        string(resp.Status),
+		Message:       resp.Message,
+		ErrorCode:     resp.ErrorCode,
+		TransactionId: resp.TransactionID,
+		PaymentId:     resp.PaymentID,
+		Amount:        resp.Amount,
+		Currency:      resp.Currency,
+		RedirectUrl:   resp.RedirectURL,
+		Html:          resp.HTML,
+		Provider:      resp.Provider,
+		Replayed:      resp.Replayed,
+	}
+}
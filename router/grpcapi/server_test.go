@@ -0,0 +1,232 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/mstgnz/gopay/infra/auth"
+	"github.com/mstgnz/gopay/infra/middle"
+	gopayv1 "github.com/mstgnz/gopay/proto/gopay/v1"
+	"github.com/mstgnz/gopay/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeTokens accepts "valid-token" as the token of tenant 42
+type fakeTokens struct{}
+
+func (fakeTokens) ValidateToken(token string) (*auth.JWTClaims, error) {
+	switch token {
+	case "valid-token":
+		return &auth.JWTClaims{TenantID: "42", Username: "acme"}, nil
+	case "expired-token":
+		return nil, auth.ErrExpiredToken
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+// fakePayments records the calls and the tenant they ran as
+type fakePayments struct {
+	environment string
+	tenantID    string
+	payment     provider.PaymentRequest
+	refund      provider.RefundRequest
+	err         error
+}
+
+func (f *fakePayments) CreatePayment(ctx context.Context, environment, providerName string, request provider.PaymentRequest) (*provider.PaymentResponse, error) {
+	f.environment, f.payment = environment, request
+	f.tenantID, _ = ctx.Value(middle.TenantIDKey).(string)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful, PaymentID: "pay_1", Amount: request.Amount, Currency: request.Currency, Provider: providerName}, nil
+}
+
+func (f *fakePayments) GetPaymentStatus(ctx context.Context, environment, providerName string, request provider.GetPaymentStatusRequest) (*provider.PaymentResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &provider.PaymentResponse{Success: true, Status: provider.StatusSuccessful, PaymentID: request.PaymentID}, nil
+}
+
+func (f *fakePayments) RefundPayment(ctx context.Context, environment, providerName string, request provider.RefundRequest) (*provider.RefundResponse, error) {
+	f.refund = request
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &provider.RefundResponse{Success: true, RefundID: "re_1", PaymentID: request.PaymentID, RefundAmount: request.RefundAmount}, nil
+}
+
+func (f *fakePayments) CancelPayment(ctx context.Context, environment, providerName string, request provider.CancelRequest) (*provider.PaymentResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &provider.PaymentResponse{Success: true, Status: provider.StatusCancelled, PaymentID: request.PaymentID}, nil
+}
+
+// fakeRoles gives the user of the valid token a fixed role
+type fakeRoles auth.Role
+
+func (f fakeRoles) RoleOf(context.Context, int, string) (auth.Role, error) {
+	return auth.Role(f), nil
+}
+
+func newTestClient(t *testing.T, payments PaymentService) gopayv1.PaymentServiceClient {
+	t.Helper()
+	return newTestClientWithRoles(t, payments, nil)
+}
+
+func newTestClientWithRoles(t *testing.T, payments PaymentService, roles RoleResolver) gopayv1.PaymentServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(payments, fakeTokens{}, roles)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gopayv1.NewPaymentServiceClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestServer_Authentication(t *testing.T) {
+	client := newTestClient(t, &fakePayments{})
+	request := &gopayv1.GetPaymentStatusRequest{Provider: "iyzico", PaymentId: "pay_1"}
+
+	for name, ctx := range map[string]context.Context{
+		"no metadata":   context.Background(),
+		"invalid token": withToken("forged-token"),
+		"expired token": withToken("expired-token"),
+		"not bearer":    metadata.AppendToOutgoingContext(context.Background(), "authorization", "valid-token"),
+	} {
+		if _, err := client.GetPaymentStatus(ctx, request); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: expected Unauthenticated, got %v", name, err)
+		}
+	}
+
+	if _, err := client.GetPaymentStatus(withToken("valid-token"), request); err != nil {
+		t.Errorf("Expected the call to pass with a valid token, got %v", err)
+	}
+}
+
+func TestServer_CreatePayment(t *testing.T) {
+	payments := &fakePayments{}
+	client := newTestClient(t, payments)
+
+	resp, err := client.CreatePayment(withToken("valid-token"), &gopayv1.CreatePaymentRequest{
+		Provider:    "iyzico",
+		Environment: gopayv1.Environment_ENVIRONMENT_PRODUCTION,
+		Amount:      100.5,
+		Currency:    "TRY",
+		Customer:    &gopayv1.Customer{Name: "John", Email: "john@example.com", Address: &gopayv1.Address{Country: "TR"}},
+		CardInfo:    &gopayv1.CardInfo{CardNumber: "5528790000000008", Cvv: "123"},
+		Items:       []*gopayv1.Item{{Id: "item_1", Price: 100.5, Quantity: 1}},
+		Metadata:    map[string]string{"order": "A-1"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if !resp.GetSuccess() || resp.GetPaymentId() != "pay_1" || resp.GetStatus() != string(provider.StatusSuccessful) || resp.GetProvider() != "iyzico" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	// The payment runs as the token's tenant with the converted request
+	if payments.tenantID != "42" || payments.environment != "production" {
+		t.Errorf("Expected tenant 42 in production, got tenant %q in %q", payments.tenantID, payments.environment)
+	}
+	if payments.payment.Customer.Address == nil || payments.payment.Customer.Address.Country != "TR" ||
+		payments.payment.CardInfo.CVV != "123" || len(payments.payment.Items) != 1 || payments.payment.Metadata["order"] != "A-1" {
+		t.Errorf("Unexpected payment request: %+v", payments.payment)
+	}
+	// Without a client IP the caller's address is used
+	if payments.payment.ClientIP == "" {
+		t.Error("Expected the client IP to default to the peer address")
+	}
+
+	if _, err := client.CreatePayment(withToken("valid-token"), &gopayv1.CreatePaymentRequest{Amount: 10}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a provider, got %v", err)
+	}
+}
+
+func TestServer_RefundAndCancel(t *testing.T) {
+	payments := &fakePayments{}
+	client := newTestClient(t, payments)
+	ctx := withToken("valid-token")
+
+	refund, err := client.RefundPayment(ctx, &gopayv1.RefundPaymentRequest{Provider: "iyzico", PaymentId: "pay_1", RefundAmount: 25, IdempotencyKey: "rf-1"})
+	if err != nil {
+		t.Fatalf("RefundPayment failed: %v", err)
+	}
+	if refund.GetRefundId() != "re_1" || refund.GetRefundAmount() != 25 || payments.refund.IdempotencyKey != "rf-1" {
+		t.Errorf("Unexpected refund: %+v", refund)
+	}
+
+	cancelled, err := client.CancelPayment(ctx, &gopayv1.CancelPaymentRequest{Provider: "iyzico", PaymentId: "pay_1"})
+	if err != nil {
+		t.Fatalf("CancelPayment failed: %v", err)
+	}
+	if cancelled.GetStatus() != string(provider.StatusCancelled) {
+		t.Errorf("Unexpected cancel response: %+v", cancelled)
+	}
+
+	if _, err := client.CancelPayment(ctx, &gopayv1.CancelPaymentRequest{Provider: "iyzico"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a payment ID, got %v", err)
+	}
+}
+
+func TestServer_Roles(t *testing.T) {
+	client := newTestClientWithRoles(t, &fakePayments{}, fakeRoles(auth.RoleFinance))
+	ctx := withToken("valid-token")
+
+	// Finance users follow and refund payments, but do not create or cancel them
+	if _, err := client.GetPaymentStatus(ctx, &gopayv1.GetPaymentStatusRequest{Provider: "iyzico", PaymentId: "pay_1"}); err != nil {
+		t.Errorf("GetPaymentStatus failed: %v", err)
+	}
+	if _, err := client.RefundPayment(ctx, &gopayv1.RefundPaymentRequest{Provider: "iyzico", PaymentId: "pay_1", RefundAmount: 10}); err != nil {
+		t.Errorf("RefundPayment failed: %v", err)
+	}
+	if _, err := client.CancelPayment(ctx, &gopayv1.CancelPaymentRequest{Provider: "iyzico", PaymentId: "pay_1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for CancelPayment, got %v", err)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{provider.ErrPaymentInProgress, codes.Aborted},
+		{provider.ErrPaymentIdempotencyKeyReused, codes.AlreadyExists},
+		{provider.ErrPaymentNotFound, codes.NotFound},
+		{provider.ErrAlreadyCancelled, codes.FailedPrecondition},
+		{provider.ErrNoProviderAvailable, codes.Unavailable},
+		{provider.ErrRefundExceedsRefundable, codes.InvalidArgument},
+		{errors.New("provider timeout"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if code := status.Code(statusOf(tt.err)); code != tt.code {
+			t.Errorf("statusOf(%v) = %v, want %v", tt.err, code, tt.code)
+		}
+	}
+
+	// The code reaches the client
+	client := newTestClient(t, &fakePayments{err: provider.ErrPaymentNotFound})
+	if _, err := client.GetPaymentStatus(withToken("valid-token"), &gopayv1.GetPaymentStatusRequest{Provider: "iyzico", PaymentId: "pay_x"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mstgnz/gopay/handler"
+	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/openapi"
+	"github.com/mstgnz/gopay/infra/response"
+)
+
+// OpenAPIHandler serves the OpenAPI document generated from the routes of router and the
+// operations registered by the handlers. The document is generated on the first request,
+// once every route is registered.
+func OpenAPIHandler(router chi.Routes) http.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *openapi.Document
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var routes []openapi.Route
+			if routes, err = openapi.Walk(router); err != nil {
+				return
+			}
+			info := openapi.Info{
+				Title:       "GoPay API",
+				Version:     "1.5.0",
+				Description: "Generated from the routes of the server; see /docs for the guide.",
+			}
+			doc = openapi.Build(info, config.GetEnv("APP_URL", "http://localhost:9999"), routes, handler.OpenAPIOperations(), "middle.JWTAuthMiddleware")
+		})
+		if err != nil {
+			response.Error(w, http.StatusInternalServerError, "Failed to generate the OpenAPI document", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+}
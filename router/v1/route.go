@@ -4,84 +4,182 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/mstgnz/gopay/handler"
+	"github.com/mstgnz/gopay/infra/audit"
+	"github.com/mstgnz/gopay/infra/auth"
 	"github.com/mstgnz/gopay/infra/config"
+	"github.com/mstgnz/gopay/infra/middle"
 	"github.com/mstgnz/gopay/infra/postgres"
 	"github.com/mstgnz/gopay/provider"
+	"github.com/mstgnz/gopay/router/graphqlapi"
 
 	// Import for side-effect registration
 	_ "github.com/mstgnz/gopay/provider/akbank"
+	_ "github.com/mstgnz/gopay/provider/craftgate"
+	_ "github.com/mstgnz/gopay/provider/garanti"
+	_ "github.com/mstgnz/gopay/provider/halkbank"
+	_ "github.com/mstgnz/gopay/provider/isbank"
 	_ "github.com/mstgnz/gopay/provider/iyzico"
+	_ "github.com/mstgnz/gopay/provider/klarna"
+	_ "github.com/mstgnz/gopay/provider/kuveytturk"
+	_ "github.com/mstgnz/gopay/provider/mercadopago"
 	_ "github.com/mstgnz/gopay/provider/nkolay"
 	_ "github.com/mstgnz/gopay/provider/ozanpay"
 	_ "github.com/mstgnz/gopay/provider/papara"
+	_ "github.com/mstgnz/gopay/provider/param"
+	_ "github.com/mstgnz/gopay/provider/paratika"
 	_ "github.com/mstgnz/gopay/provider/paycell"
 	_ "github.com/mstgnz/gopay/provider/payten"
 	_ "github.com/mstgnz/gopay/provider/paytr"
 	_ "github.com/mstgnz/gopay/provider/payu"
+	_ "github.com/mstgnz/gopay/provider/qnb"
+	_ "github.com/mstgnz/gopay/provider/razorpay"
+	_ "github.com/mstgnz/gopay/provider/sipay"
 	_ "github.com/mstgnz/gopay/provider/stripe"
+	_ "github.com/mstgnz/gopay/provider/tosla"
 	_ "github.com/mstgnz/gopay/provider/ziraat"
 )
 
 // Routes defines all v1 API routes
-func Routes(r chi.Router, postgresLogger *postgres.Logger, paymentService *provider.PaymentService, providerConfig *config.ProviderConfig) {
+func Routes(r chi.Router, postgresLogger *postgres.Logger, paymentService *provider.PaymentService, providerConfig *config.ProviderConfig, subscriptionService *provider.SubscriptionService, paymentLinkService *provider.PaymentLinkService, paymentJobQueue *provider.PaymentJobQueue) {
 	// Initialize handlers
 	validator := validator.New()
 	analyticsHandler := handler.NewAnalyticsHandler(postgresLogger)
 	paymentHandler := handler.NewPaymentHandler(paymentService, validator)
+	paymentHandler.SetPaymentJobQueue(paymentJobQueue)
+	paymentJobHandler := handler.NewPaymentJobHandler(paymentJobQueue)
 	configHandler := handler.NewConfigHandler(providerConfig, paymentService, validator)
+	configHandler.SetTemplateStore(config.NewPostgresConfigTemplateStore(config.App().DB.DB))
+	providerHandler := handler.NewProviderHandler(provider.DefaultRegistry)
 
 	// Card storage (saved cards) handler
 	cardRepo := provider.NewSavedCardRepository(config.App().DB.DB)
 	cardService := provider.NewCardService(provider.NewDBPaymentLogger(config.App().DB), cardRepo, providerConfig)
 	cardHandler := handler.NewCardHandler(cardService, validator)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService, validator)
+	payoutService := provider.NewPayoutService(provider.NewPostgresPayoutStore(config.App().DB.DB), provider.NewDBPaymentLogger(config.App().DB))
+	payoutHandler := handler.NewPayoutHandler(payoutService, validator)
+	paymentLinkHandler := handler.NewPaymentLinkHandler(paymentLinkService, validator)
 
 	// Initialize provider-specific logger for logs handler
 	providerLogger := provider.NewProviderSpecificLogger(config.App().DB)
 	logsHandler := handler.NewLogsHandler(providerLogger, postgresLogger)
+	auditHandler := handler.NewAuditHandler(audit.NewPostgresStore(config.App().DB.DB))
+
+	// Role permissions of the tenant user: reads need the read permission, changes the write one
+	paymentsAccess := middle.RequireReadWritePermission(auth.PermissionPaymentsRead, auth.PermissionPaymentsWrite)
+	analyticsAccess := middle.RequirePermission(auth.PermissionAnalyticsRead)
 
 	// Payment routes (JWT protected)
 	r.Route("/payments", func(r chi.Router) {
-		r.Post("/{provider}", paymentHandler.ProcessPayment)
-
-		// Card storage (saved cards) routes. Static "cards" segment takes precedence over the
-		// {paymentID} wildcard in chi, so these do not collide with status/cancel routes.
-		r.Post("/{provider}/cards/otp/send", cardHandler.SendOTP)
-		r.Post("/{provider}/cards/otp/validate", cardHandler.ValidateOTP)
-		r.Post("/{provider}/cards/register", cardHandler.RegisterCard)
-		r.Get("/{provider}/cards", cardHandler.ListCards)
-		r.Delete("/{provider}/cards/{cardId}", cardHandler.DeleteCard)
-		r.Post("/{provider}/cards/{cardId}/pay", cardHandler.PayWithCard)
-
-		r.Get("/{provider}/{paymentID}", paymentHandler.GetPaymentStatus)
-		r.Delete("/{provider}/{paymentID}", paymentHandler.CancelPayment)
-		r.Post("/{provider}/refund", paymentHandler.RefundPayment)
-		r.Post("/{provider}/installments", paymentHandler.GetInstallments)
-		r.Post("/{provider}/commission", paymentHandler.GetCommission)
+		// Refunds have their own permission, so finance users refund without creating payments,
+		// and installment and commission quotes only read
+		r.With(middle.RequirePermission(auth.PermissionRefunds)).Post("/{provider}/refund", paymentHandler.RefundPayment)
+		r.With(middle.RequirePermission(auth.PermissionPaymentsRead)).Post("/{provider}/installments", paymentHandler.GetInstallments)
+		r.With(middle.RequirePermission(auth.PermissionPaymentsRead)).Post("/{provider}/commission", paymentHandler.GetCommission)
+
+		r.Group(func(r chi.Router) {
+			r.Use(paymentsAccess)
+			r.Get("/", paymentHandler.ListPayments) // GET /v1/payments?provider=iyzico&status=failed&from=2024-01-01&limit=50
+			r.Post("/{provider}", paymentHandler.ProcessPayment)
+			r.Post("/{provider}/batch", paymentHandler.ProcessPaymentBatch)                // POST /v1/payments/iyzico/batch
+			r.Get("/by-order/{merchantOrderID}/attempts", paymentHandler.GetOrderAttempts) // GET /v1/payments/by-order/ORDER-1/attempts
+
+			// Card storage (saved cards) routes. Static "cards" segment takes precedence over the
+			// {paymentID} wildcard in chi, so these do not collide with status/cancel routes.
+			r.Post("/{provider}/cards/otp/send", cardHandler.SendOTP)
+			r.Post("/{provider}/cards/otp/validate", cardHandler.ValidateOTP)
+			r.Post("/{provider}/cards/register", cardHandler.RegisterCard)
+			r.Get("/{provider}/cards", cardHandler.ListCards)
+			r.Delete("/{provider}/cards/{cardId}", cardHandler.DeleteCard)
+			r.Post("/{provider}/cards/{cardId}/pay", cardHandler.PayWithCard)
+
+			r.Get("/{provider}/{paymentID}", paymentHandler.GetPaymentStatus)
+			r.Get("/{provider}/{paymentID}/events", paymentHandler.GetPaymentEvents)    // GET /v1/payments/iyzico/pay_123/events
+			r.Get("/{provider}/{paymentID}/stream", paymentHandler.StreamPaymentEvents) // GET /v1/payments/iyzico/pay_123/stream (Server-Sent Events)
+			r.Delete("/{provider}/{paymentID}", paymentHandler.CancelPayment)
+			r.Post("/{provider}/{paymentID}/capture", paymentHandler.CapturePayment) // POST /v1/payments/stripe/pi_123/capture
+		})
+	})
+
+	// Payment job routes (JWT protected): payments sent with async=true
+	r.With(paymentsAccess).Get("/jobs/{jobID}", paymentJobHandler.GetJob) // GET /v1/jobs/pj123
+
+	// Subscription routes (JWT protected): recurring charges of a saved card
+	r.Route("/subscriptions", func(r chi.Router) {
+		r.Use(paymentsAccess)
+		r.Post("/", subscriptionHandler.CreateSubscription)                   // POST /v1/subscriptions?environment=sandbox
+		r.Get("/", subscriptionHandler.ListSubscriptions)                     // GET /v1/subscriptions
+		r.Get("/{subscriptionID}", subscriptionHandler.GetSubscription)       // GET /v1/subscriptions/sub123
+		r.Delete("/{subscriptionID}", subscriptionHandler.CancelSubscription) // DELETE /v1/subscriptions/sub123
+	})
+
+	// Payout routes (JWT protected): money sent from the merchant balance at a provider
+	r.Route("/payouts", func(r chi.Router) {
+		r.Use(paymentsAccess)
+		r.Post("/", payoutHandler.CreatePayout)       // POST /v1/payouts?environment=sandbox
+		r.Get("/", payoutHandler.ListPayouts)         // GET /v1/payouts
+		r.Get("/{payoutID}", payoutHandler.GetPayout) // GET /v1/payouts/po123
+	})
+
+	// Payment link routes (JWT protected): the links are paid on the public /pay/{linkID} page
+	r.Route("/payment-links", func(r chi.Router) {
+		r.Use(paymentsAccess)
+		r.Post("/", paymentLinkHandler.CreatePaymentLink)           // POST /v1/payment-links?environment=sandbox
+		r.Get("/", paymentLinkHandler.ListPaymentLinks)             // GET /v1/payment-links
+		r.Get("/{linkID}", paymentLinkHandler.GetPaymentLink)       // GET /v1/payment-links/pl123
+		r.Delete("/{linkID}", paymentLinkHandler.CancelPaymentLink) // DELETE /v1/payment-links/pl123
+	})
+
+	// 3D Secure routes (JWT protected)
+	r.Route("/3ds", func(r chi.Router) {
+		r.Use(paymentsAccess)
+		r.Get("/{provider}/enrollment/{bin}", paymentHandler.Check3DSEnrollment) // GET /v1/3ds/iyzico/enrollment/552879
+	})
+
+	// Provider information routes (JWT protected)
+	r.Route("/providers", func(r chi.Router) {
+		r.Get("/{provider}/currencies", providerHandler.GetSupportedCurrencies) // GET /v1/providers/paycell/currencies
 	})
 
 	// Configuration routes (JWT protected)
 	r.Route("/config", func(r chi.Router) {
+		r.Use(middle.RequireReadWritePermission(auth.PermissionConfigRead, auth.PermissionConfigWrite))
 		r.Post("/tenant", configHandler.PostTenantConfig)
 		r.Get("/tenant", configHandler.GetTenantConfig)
 		r.Delete("/tenant", configHandler.DeleteTenantConfig)
+		r.Get("/validate-all", configHandler.ValidateAllTenantConfigs) // Admin only
+
+		// Provider config templates for onboarding tenants (admin only)
+		r.Get("/templates", configHandler.ListConfigTemplates)
+		r.Post("/templates", configHandler.SaveConfigTemplate)
+		r.Delete("/templates/{name}", configHandler.DeleteConfigTemplate)
+		r.Post("/apply-template", configHandler.ApplyConfigTemplate) // POST /v1/config/apply-template
 	})
 
 	// Logs routes (JWT protected)
 	r.Route("/logs", func(r chi.Router) {
+		r.Use(analyticsAccess)
 		r.Get("/{provider}", logsHandler.ListLogs)                           // GET /v1/logs/{provider}?status=success&hours=24
 		r.Get("/{provider}/payment/{paymentID}", logsHandler.GetPaymentLogs) // GET /v1/logs/{provider}/payment/{paymentID}
 		r.Get("/{provider}/errors", logsHandler.GetErrorLogs)                // GET /v1/logs/{provider}/errors?hours=24
 		r.Get("/{provider}/stats", logsHandler.GetLogStats)                  // GET /v1/logs/{provider}/stats?hours=24
 	})
 
+	// Audit trail of mutating operations (JWT protected, tenant scoped)
+	r.With(analyticsAccess).Get("/audit", auditHandler.ListAuditEvents) // GET /v1/audit?from=2024-01-01&to=2024-01-31&action=payment.refund
+
+	// GraphQL queries of payments, logs and analytics (JWT protected, tenant scoped)
+	r.With(analyticsAccess).Handle("/graphql", graphqlapi.NewHandler(paymentService, providerLogger, postgresLogger)) // POST /v1/graphql
+
 	// Analytics routes (JWT protected)
 	r.Route("/analytics", func(r chi.Router) {
+		r.Use(analyticsAccess)
 		r.Get("/dashboard", analyticsHandler.GetDashboardStats)       // GET /v1/analytics/dashboard?hours=24
 		r.Get("/providers", analyticsHandler.GetProviderStats)        // GET /v1/analytics/providers
 		r.Get("/activity", analyticsHandler.GetRecentActivity)        // GET /v1/analytics/activity?limit=10
 		r.Get("/trends", analyticsHandler.GetPaymentTrends)           // GET /v1/analytics/trends?hours=24
 		r.Get("/tenants", analyticsHandler.GetActiveTenants)          // GET /v1/analytics/tenants
 		r.Get("/providers/list", analyticsHandler.GetActiveProviders) // GET /v1/analytics/providers/list
-		r.Get("/search", analyticsHandler.SearchPaymentByID)          // GET /v1/analytics/search?tenant_id=1&provider_id=paycell&payment_id=pay_123
+		r.Get("/search", analyticsHandler.SearchPaymentByID)          // GET /v1/analytics/search?tenant_id=1&provider_id=paycell&payment_id=pay_123&metadata=campaign:summer2024
 	})
 }